@@ -9,6 +9,7 @@ func NewAgentCommand() *cobra.Command {
 		message    string
 		sessionKey string
 		model      string
+		schema     string
 		debug      bool
 	)
 
@@ -17,7 +18,7 @@ func NewAgentCommand() *cobra.Command {
 		Short: "Interact with the agent directly",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			return agentCmd(message, sessionKey, model, debug)
+			return agentCmd(message, sessionKey, model, schema, debug)
 		},
 	}
 
@@ -25,6 +26,8 @@ func NewAgentCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&message, "message", "m", "", "Send a single message (non-interactive mode)")
 	cmd.Flags().StringVarP(&sessionKey, "session", "s", "cli:default", "Session key")
 	cmd.Flags().StringVarP(&model, "model", "", "", "Model to use")
+	cmd.Flags().StringVar(&schema, "schema", "",
+		"JSON schema, or a file with one, the reply must conform to; prints the reply as JSON (needs --message)")
 
 	return cmd
 }
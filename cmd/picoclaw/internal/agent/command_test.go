@@ -30,4 +30,5 @@ func TestNewAgentCommand(t *testing.T) {
 	assert.NotNil(t, cmd.Flags().Lookup("message"))
 	assert.NotNil(t, cmd.Flags().Lookup("session"))
 	assert.NotNil(t, cmd.Flags().Lookup("model"))
+	assert.NotNil(t, cmd.Flags().Lookup("schema"))
 }
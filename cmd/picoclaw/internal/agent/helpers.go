@@ -16,12 +16,23 @@ import (
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/structured"
 )
 
-func agentCmd(message, sessionKey, model string, debug bool) error {
+func agentCmd(message, sessionKey, model, schemaArg string, debug bool) error {
 	if sessionKey == "" {
 		sessionKey = "cli:default"
 	}
+	var schema *structured.Schema
+	if schemaArg != "" {
+		if message == "" {
+			return fmt.Errorf("--schema needs --message")
+		}
+		var err error
+		if schema, err = structured.Load(schemaArg); err != nil {
+			return err
+		}
+	}
 
 	if debug {
 		logger.SetLevel(logger.DEBUG)
@@ -61,6 +72,15 @@ func agentCmd(message, sessionKey, model string, debug bool) error {
 			"skills_available": startupInfo["skills"].(map[string]any)["available"],
 		})
 
+	if schema != nil {
+		data, err := agentLoop.ProcessStructured(context.Background(), message, sessionKey, schema)
+		if err != nil {
+			return fmt.Errorf("error processing message: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
 	if message != "" {
 		ctx := context.Background()
 		response, err := agentLoop.ProcessDirect(ctx, message, sessionKey)
@@ -0,0 +1,35 @@
+package analytics
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/database"
+)
+
+func NewAnalyticsCommand() *cobra.Command {
+	var dbPath string
+
+	cmd := &cobra.Command{
+		Use:   "analytics",
+		Short: "Work with per-message analytics",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			dbPath = database.Path(cfg.WorkspacePath())
+			return nil
+		},
+	}
+
+	cmd.AddCommand(newExportCommand(func() string { return dbPath }))
+
+	return cmd
+}
@@ -0,0 +1,74 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/analytics"
+)
+
+func TestNewAnalyticsCommand(t *testing.T) {
+	cmd := NewAnalyticsCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "Work with per-message analytics", cmd.Short)
+	assert.NotNil(t, cmd.PersistentPreRunE)
+	assert.True(t, cmd.HasSubCommands())
+
+	subcommands := cmd.Commands()
+	require.Len(t, subcommands, 1)
+	assert.Equal(t, "export", subcommands[0].Name())
+}
+
+func TestExportCommand(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "picoclaw.db")
+	store, err := analytics.Open(context.Background(), path)
+	require.NoError(t, err)
+	for _, at := range []string{"2026-03-01T10:00:00Z", "2026-03-02T10:00:00Z"} {
+		ts, _ := time.Parse(time.RFC3339, at)
+		require.NoError(t, store.Record(context.Background(), analytics.Record{
+			Time: ts, Channel: "telegram", Outcome: analytics.OutcomeOK,
+		}))
+	}
+	require.NoError(t, store.Close())
+
+	run := func(args ...string) (string, error) {
+		cmd := newExportCommand(func() string { return path })
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run()
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(out, "\n"))
+	assert.True(t, strings.HasPrefix(out, "time,request_id,"))
+
+	out, err = run("--since", "2026-03-02T00:00:00Z")
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(out, "\n"))
+
+	parquetPath := filepath.Join(dir, "out.parquet")
+	_, err = run("--format", "parquet", "-o", parquetPath)
+	require.NoError(t, err)
+	data, err := os.ReadFile(parquetPath)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("PAR1")))
+
+	_, err = run("--format", "xlsx")
+	assert.Error(t, err)
+	_, err = run("--until", "tomorrow")
+	assert.Error(t, err)
+}
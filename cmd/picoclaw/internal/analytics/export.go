@@ -0,0 +1,95 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/analytics"
+)
+
+func newExportCommand(dbPath func() string) *cobra.Command {
+	var (
+		since  string
+		until  string
+		format string
+		output string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export per-message analytics for offline analysis",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var from, to time.Time
+			var err error
+			if since != "" {
+				if from, err = parseTime("--since", since); err != nil {
+					return err
+				}
+			}
+			if until != "" {
+				if to, err = parseTime("--until", until); err != nil {
+					return err
+				}
+			}
+			write := analytics.WriteCSV
+			switch format {
+			case "csv":
+			case "parquet":
+				write = analytics.WriteParquet
+			default:
+				return fmt.Errorf("--format must be csv or parquet")
+			}
+
+			ctx := context.Background()
+			store, err := analytics.Open(ctx, dbPath())
+			if err != nil {
+				return fmt.Errorf("error opening database: %w", err)
+			}
+			defer store.Close()
+			records, err := store.Records(ctx, from, to)
+			if err != nil {
+				return err
+			}
+
+			var w io.Writer = cmd.OutOrStdout()
+			if output != "" && output != "-" {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			if err := write(w, records); err != nil {
+				return fmt.Errorf("error writing export: %w", err)
+			}
+			if output != "" && output != "-" {
+				fmt.Printf("✓ Exported %d messages to %s\n", len(records), output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "", "Only export messages since a date (2006-01-02) or RFC 3339 time")
+	cmd.Flags().StringVar(&until, "until", "", "Only export messages before a date (2006-01-02) or RFC 3339 time")
+	cmd.Flags().StringVarP(&format, "format", "f", "csv", "Output format: csv or parquet")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write to a file instead of stdout")
+
+	return cmd
+}
+
+func parseTime(flag, s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s must be a date (2006-01-02) or RFC 3339 time", flag)
+}
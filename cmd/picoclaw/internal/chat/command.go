@@ -0,0 +1,34 @@
+package chat
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewChatCommand() *cobra.Command {
+	var opts chatOptions
+
+	cmd := &cobra.Command{
+		Use:   "chat",
+		Short: "Chat with the bot from the terminal through the full channel pipeline",
+		Long: `Chat with the bot from the terminal as if it were a chat platform.
+
+Unlike "picoclaw agent", messages pass through the channel manager, so slash
+commands, reply splitting, tool approvals, and guardrails behave as they do
+on Discord or Telegram. Other channels in the config are not started.
+
+Lines piped on stdin are sent one at a time, each after the previous reply.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return chatCmd(cmd.Context(), opts)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.debug, "debug", "d", false, "Show the gateway log")
+	cmd.Flags().StringVarP(&opts.user, "user", "u", "local", "Sender ID, for allow lists and per-user sessions")
+	cmd.Flags().StringVar(&opts.chat, "chat", "", "Chat ID (defaults to the user)")
+	cmd.Flags().IntVar(&opts.maxLength, "max-length", 0, "Split replies like a platform with this limit, e.g. 2000 for Discord")
+	cmd.Flags().StringVar(&opts.model, "model", "", "Model to use")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", defaultReplyTimeout, "How long piped input waits for each reply")
+
+	return cmd
+}
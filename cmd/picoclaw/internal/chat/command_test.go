@@ -0,0 +1,32 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChatCommand(t *testing.T) {
+	cmd := NewChatCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "chat", cmd.Use)
+	assert.False(t, cmd.HasSubCommands())
+	assert.NotNil(t, cmd.RunE)
+
+	for _, name := range []string{"debug", "user", "chat", "max-length", "model", "timeout"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), name)
+	}
+}
+
+func TestWaitForReply(t *testing.T) {
+	replies := make(chan struct{}, 1)
+	assert.False(t, waitForReply(context.Background(), replies, 50*time.Millisecond))
+
+	replies <- struct{}{}
+	assert.True(t, waitForReply(context.Background(), replies, time.Second))
+}
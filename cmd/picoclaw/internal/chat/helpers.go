@@ -0,0 +1,178 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ergochat/readline"
+	"golang.org/x/term"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/channels/terminal"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	defaultReplyTimeout = 5 * time.Minute
+	// settleTime is how long piped input waits after a reply for more
+	// chunks or messages before sending the next line.
+	settleTime = 1500 * time.Millisecond
+)
+
+type chatOptions struct {
+	debug     bool
+	user      string
+	chat      string
+	maxLength int
+	model     string
+	timeout   time.Duration
+}
+
+func chatCmd(ctx context.Context, opts chatOptions) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.debug {
+		logger.SetLevel(logger.DEBUG)
+	} else {
+		logger.SetLevel(logger.ERROR)
+	}
+
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	if opts.model != "" {
+		cfg.Agents.Defaults.ModelName = opts.model
+	}
+	provider, modelID, err := providers.CreateProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating provider: %w", err)
+	}
+	if modelID != "" {
+		cfg.Agents.Defaults.ModelName = modelID
+	}
+	// Only the terminal talks to this agent
+	cfg.Channels = config.ChannelsConfig{}
+
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	defer agentLoop.Close()
+	manager, err := channels.NewManager(cfg, msgBus, nil)
+	if err != nil {
+		return fmt.Errorf("error creating channel manager: %w", err)
+	}
+	agentLoop.SetChannelManager(manager)
+
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	var rl *readline.Instance
+	out := io.Writer(os.Stdout)
+	if interactive {
+		rl, err = readline.NewEx(&readline.Config{
+			Prompt:          fmt.Sprintf("%s You: ", internal.Logo),
+			HistoryFile:     filepath.Join(os.TempDir(), ".picoclaw_chat_history"),
+			HistoryLimit:    100,
+			InterruptPrompt: "^C",
+			EOFPrompt:       "exit",
+		})
+		if err != nil {
+			return fmt.Errorf("error initializing readline: %w", err)
+		}
+		defer rl.Close()
+		// Replies arrive while the prompt is shown; let readline redraw it
+		out = rl.Stdout()
+	}
+
+	ch := terminal.NewTerminalChannel(terminal.Options{
+		User:      opts.user,
+		ChatID:    opts.chat,
+		MaxLength: opts.maxLength,
+		Out:       out,
+		Prefix:    internal.Logo + " ",
+	}, msgBus)
+	manager.RegisterChannel(terminal.Name, ch)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := manager.StartAll(runCtx); err != nil {
+		return fmt.Errorf("error starting channels: %w", err)
+	}
+	defer manager.StopAll(context.Background())
+	go agentLoop.Run(runCtx)
+	defer agentLoop.Stop()
+
+	if !interactive {
+		return pipeMode(runCtx, ch, os.Stdin, opts.timeout)
+	}
+	fmt.Printf("%s Chat mode on the %q channel as %s (/help for commands, Ctrl+C to exit)\n\n",
+		internal.Logo, terminal.Name, opts.user)
+	for {
+		line, err := rl.Readline()
+		if err != nil {
+			if err == readline.ErrInterrupt || err == io.EOF {
+				fmt.Println("\nGoodbye!")
+				return nil
+			}
+			return err
+		}
+		input := strings.TrimSpace(line)
+		if input == "" {
+			continue
+		}
+		if input == "exit" || input == "quit" {
+			fmt.Println("Goodbye!")
+			return nil
+		}
+		ch.Receive(runCtx, input)
+	}
+}
+
+// pipeMode sends each input line once the bot has answered the previous
+// one and gone quiet, so scripted sessions read like interactive ones.
+func pipeMode(ctx context.Context, ch *terminal.TerminalChannel, in io.Reader, timeout time.Duration) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		input := strings.TrimSpace(scanner.Text())
+		if input == "" {
+			continue
+		}
+		ch.Receive(ctx, input)
+		if !waitForReply(ctx, ch.Replies(), timeout) {
+			return fmt.Errorf("no reply to %q within %s", input, timeout)
+		}
+	}
+	return scanner.Err()
+}
+
+// waitForReply waits for a first reply and then until none arrive for
+// settleTime. It returns false when nothing arrived within timeout.
+func waitForReply(ctx context.Context, replies <-chan struct{}, timeout time.Duration) bool {
+	select {
+	case <-replies:
+	case <-time.After(timeout):
+		return false
+	case <-ctx.Done():
+		return false
+	}
+	for {
+		select {
+		case <-replies:
+		case <-time.After(settleTime):
+			return true
+		case <-ctx.Done():
+			return true
+		}
+	}
+}
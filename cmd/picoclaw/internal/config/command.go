@@ -0,0 +1,23 @@
+package config
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and check the configuration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(
+		newSchemaCommand(),
+		newValidateCommand(),
+	)
+
+	return cmd
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigCommand(t *testing.T) {
+	cmd := NewConfigCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "config", cmd.Use)
+	assert.True(t, cmd.HasSubCommands())
+
+	schema, _, err := cmd.Find([]string{"schema"})
+	require.NoError(t, err)
+	assert.Equal(t, "schema", schema.Use)
+	assert.NotNil(t, schema.Flags().Lookup("output"))
+
+	validate, _, err := cmd.Find([]string{"validate"})
+	require.NoError(t, err)
+	assert.Equal(t, "validate", validate.Use)
+	assert.NotNil(t, validate.Flags().Lookup("offline"))
+}
+
+func TestSchemaCommand_PrintsSchema(t *testing.T) {
+	cmd := newSchemaCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs(nil)
+
+	require.NoError(t, cmd.Execute())
+
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &schema))
+	assert.Equal(t, "object", schema["type"])
+	assert.Contains(t, schema["properties"], "channels")
+}
+
+func runValidate(t *testing.T, configJSON string, args ...string) (string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(configJSON), 0o600))
+	t.Setenv("PICOCLAW_CONFIG", path)
+
+	cmd := newValidateCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func modelConfigJSON(apiBase string) string {
+	return `{
+  "agents": {"defaults": {"model_name": "main"}},
+  "model_list": [{"model_name": "main", "model": "openai/gpt-4o-mini", "api_key": "sk-test", "api_base": "` +
+		apiBase + `"}]
+}`
+}
+
+func TestValidateCommand_PointsAtJSONErrors(t *testing.T) {
+	out, err := runValidate(t, "{\n  \"agents\": {\n    \"defaults\": {\"model_name\": \"main\",}\n  }\n}", "--offline")
+	require.Error(t, err)
+	assert.Contains(t, out, "line 3, column")
+}
+
+func TestValidateCommand_ReportsUnknownDefaultModel(t *testing.T) {
+	cfg := strings.Replace(modelConfigJSON("http://127.0.0.1:1"), `"model_name": "main"}}`, `"model_name": "gpt"}}`, 1)
+	out, err := runValidate(t, cfg, "--offline")
+	require.Error(t, err)
+	assert.Contains(t, out, `agents.defaults.model_name "gpt" is not a model_name in model_list`)
+}
+
+func TestValidateCommand_CallsProviders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"Incorrect API key provided"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"p"},"finish_reason":"length"}]}`))
+	}))
+	defer srv.Close()
+
+	out, err := runValidate(t, modelConfigJSON(srv.URL))
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "model_list[0] (main): openai/gpt-4o-mini answered")
+
+	out, err = runValidate(t, strings.Replace(modelConfigJSON(srv.URL), "sk-test", "sk-wrong", 1))
+	require.Error(t, err)
+	assert.Contains(t, out, "the API key was rejected")
+}
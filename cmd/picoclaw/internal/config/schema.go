@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newSchemaCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:     "schema",
+		Short:   "Print the JSON Schema for config.json",
+		Args:    cobra.NoArgs,
+		Example: `  picoclaw config schema --output ~/.picoclaw/config.schema.json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			data, err := config.Schema()
+			if err != nil {
+				return fmt.Errorf("generate schema: %w", err)
+			}
+			data = append(data, '\n')
+			if output == "" {
+				_, err = cmd.OutOrStdout().Write(data)
+				return err
+			}
+			if err := os.WriteFile(output, data, 0o644); err != nil {
+				return fmt.Errorf("write schema: %w", err)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "✓ Schema written to %s\n", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the schema to a file instead of stdout")
+
+	return cmd
+}
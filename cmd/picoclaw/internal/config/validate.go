@@ -0,0 +1,244 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/channels/discord"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// checkTimeout bounds each call the online checks make.
+const checkTimeout = 20 * time.Second
+
+func newValidateCommand() *cobra.Command {
+	var offline bool
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check config.json, its API keys, and its channel tokens",
+		Long: "Loads the config and checks it, then makes a one-token request to every model_list entry " +
+			"and verifies the Discord bot token and intents. Exits non-zero when anything would fail at runtime.",
+		Args:    cobra.NoArgs,
+		Example: "  picoclaw config validate\n  picoclaw config validate --offline",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			v := &validator{out: cmd.OutOrStdout()}
+			v.run(cmd.Context(), internal.GetConfigPath(), offline)
+			if v.errors > 0 {
+				return fmt.Errorf("config has %d problem(s)", v.errors)
+			}
+			fmt.Fprintln(v.out, "✓ Config is valid")
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&offline, "offline", false, "Skip the checks that call providers and channels")
+
+	return cmd
+}
+
+// validator prints the outcome of each check and counts the failures.
+type validator struct {
+	out    io.Writer
+	errors int
+}
+
+func (v *validator) ok(format string, args ...any) {
+	fmt.Fprintf(v.out, "✓ "+format+"\n", args...)
+}
+
+func (v *validator) warn(format string, args ...any) {
+	fmt.Fprintf(v.out, "⚠ "+format+"\n", args...)
+}
+
+func (v *validator) fail(format string, args ...any) {
+	v.errors++
+	fmt.Fprintf(v.out, "✗ "+format+"\n", args...)
+}
+
+func (v *validator) run(ctx context.Context, path string, offline bool) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		v.fail("%s: %v; run picoclaw onboard or set PICOCLAW_CONFIG", path, err)
+		return
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		v.fail("%s: %s", path, describeLoadError(data, err))
+		return
+	}
+	v.ok("%s loaded", path)
+
+	v.checkModels(cfg)
+	v.checkChannels(cfg)
+	if offline {
+		return
+	}
+	v.checkProviders(ctx, cfg)
+	v.checkDiscord(ctx, cfg)
+}
+
+// describeLoadError points JSON errors at their line and column.
+func describeLoadError(data []byte, err error) string {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+		if typeErr.Field != "" {
+			err = fmt.Errorf("%s must be %s, not a JSON %s", typeErr.Field, typeErr.Type, typeErr.Value)
+		}
+	default:
+		return err.Error()
+	}
+	before := data[:min(int(offset), len(data))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("line %d, column %d: %v", line, col, err)
+}
+
+// checkModels checks that the default model and its fallbacks are in
+// model_list.
+func (v *validator) checkModels(cfg *config.Config) {
+	defaults := cfg.Agents.Defaults
+	model := defaults.GetModelName()
+	if model == "" {
+		v.fail("agents.defaults.model_name is empty; set it to a model_name from model_list")
+	} else if len(cfg.GetModelConfigs(model)) == 0 {
+		v.fail("agents.defaults.model_name %q is not a model_name in model_list", model)
+	}
+	for _, name := range defaults.ModelFallbacks {
+		if len(cfg.GetModelConfigs(name)) == 0 {
+			v.fail("agents.defaults.model_fallbacks: %q is not a model_name in model_list", name)
+		}
+	}
+}
+
+// checkChannels reports enabled channels missing what they need to start.
+func (v *validator) checkChannels(cfg *config.Config) {
+	c := cfg.Channels
+	for _, missing := range []struct {
+		enabled bool
+		field   string
+		value   string
+	}{
+		{c.Telegram.Enabled, "channels.telegram.token", c.Telegram.Token},
+		{c.WhatsAppCloud.Enabled, "channels.whatsapp_cloud.access_token", c.WhatsAppCloud.AccessToken},
+		{c.WhatsAppCloud.Enabled, "channels.whatsapp_cloud.app_secret", c.WhatsAppCloud.AppSecret},
+		{c.Discord.Enabled, "channels.discord.token", c.Discord.Token},
+		{c.Slack.Enabled, "channels.slack.bot_token", c.Slack.BotToken},
+		{c.DingTalk.Enabled, "channels.dingtalk.client_id", c.DingTalk.ClientID},
+		{c.LINE.Enabled, "channels.line.channel_access_token", c.LINE.ChannelAccessToken},
+		{c.OneBot.Enabled, "channels.onebot.ws_url", c.OneBot.WSUrl},
+		{c.IRC.Enabled, "channels.irc.server", c.IRC.Server},
+		{c.HTTP.Enabled, "channels.http.secret", c.HTTP.Secret},
+		{c.Mattermost.Enabled, "channels.mattermost.token", c.Mattermost.Token},
+		{c.XMPP.Enabled, "channels.xmpp.jid", c.XMPP.JID},
+		{c.XMPP.Enabled, "channels.xmpp.password", c.XMPP.Password},
+		{c.Email.Enabled, "channels.email.imap_host", c.Email.IMAPHost},
+	} {
+		if missing.enabled && missing.value == "" {
+			v.fail("%s is empty, so the enabled channel will not start", missing.field)
+		}
+	}
+}
+
+// checkProviders sends a one-token request to every model_list entry, so
+// rejected keys, unknown models, and unreachable endpoints show up now.
+// CLI-based entries are not called.
+func (v *validator) checkProviders(ctx context.Context, cfg *config.Config) {
+	for i := range cfg.ModelList {
+		entry := cfg.ModelList[i]
+		subject := fmt.Sprintf("model_list[%d] (%s)", i, entry.ModelName)
+		if protocol, _ := providers.ExtractProtocol(entry.Model); protocol == "claude-cli" || protocol == "codex-cli" {
+			v.ok("%s: %s runs a local CLI, not called", subject, protocol)
+			continue
+		}
+		if entry.Workspace == "" {
+			entry.Workspace = cfg.WorkspacePath()
+		}
+		provider, modelID, err := providers.CreateProviderFromConfig(&entry)
+		if err != nil {
+			v.fail("%s: %v", subject, err)
+			continue
+		}
+		callCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		_, err = provider.Chat(callCtx, []providers.Message{{Role: "user", Content: "ping"}}, nil, modelID,
+			map[string]any{"max_tokens": 1})
+		cancel()
+		if stateful, ok := provider.(providers.StatefulProvider); ok {
+			stateful.Close()
+		}
+		if err == nil {
+			v.ok("%s: %s answered", subject, entry.Model)
+			continue
+		}
+		v.providerFailure(subject, entry, err)
+	}
+}
+
+// providerFailure explains a failed provider check by its cause.
+func (v *validator) providerFailure(subject string, entry config.ModelConfig, err error) {
+	reason := providers.FailoverUnknown
+	if fe := providers.ClassifyError(err, entry.Model, entry.Model); fe != nil {
+		reason = fe.Reason
+	}
+	switch reason {
+	case providers.FailoverAuth:
+		v.fail("%s: the API key was rejected; check api_key: %v", subject, err)
+	case providers.FailoverBilling:
+		v.fail("%s: the account has no credit or billing is not set up: %v", subject, err)
+	case providers.FailoverRateLimit, providers.FailoverOverloaded:
+		v.warn("%s: rate limited or overloaded, but the key was accepted: %v", subject, err)
+	case providers.FailoverFormat:
+		v.fail("%s: the request was rejected; check that model %q exists there: %v", subject, entry.Model, err)
+	case providers.FailoverTimeout:
+		v.fail("%s: no answer from %s; check api_base and proxy: %v", subject, apiBaseOf(entry), err)
+	default:
+		v.fail("%s: %v", subject, err)
+	}
+}
+
+func apiBaseOf(entry config.ModelConfig) string {
+	if entry.APIBase != "" {
+		return entry.APIBase
+	}
+	return "the provider"
+}
+
+// checkDiscord verifies the Discord bot token and its intents.
+func (v *validator) checkDiscord(ctx context.Context, cfg *config.Config) {
+	dc := cfg.Channels.Discord
+	if !dc.Enabled || dc.Token == "" {
+		return
+	}
+	callCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	warnings, err := discord.CheckToken(callCtx, dc)
+	if err != nil {
+		v.fail("channels.discord: %v", err)
+		return
+	}
+	for _, w := range warnings {
+		v.warn("channels.discord: %s", w)
+	}
+	if len(warnings) == 0 {
+		v.ok("channels.discord: token, intents, and servers OK")
+	}
+}
@@ -0,0 +1,42 @@
+package experiment
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/digest"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// source locates the records a report is built from.
+type source struct {
+	workspace string
+	pricing   usage.Pricing
+}
+
+func NewExperimentCommand() *cobra.Command {
+	var src source
+
+	cmd := &cobra.Command{
+		Use:   "experiment",
+		Short: "Compare rollout variants against stable traffic",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			src = source{workspace: cfg.WorkspacePath(), pricing: digest.PricingFromConfig(cfg)}
+			return nil
+		},
+	}
+
+	cmd.AddCommand(newReportCommand(func() source { return src }))
+
+	return cmd
+}
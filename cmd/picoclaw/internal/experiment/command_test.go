@@ -0,0 +1,67 @@
+package experiment
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/experiment"
+	"github.com/sipeed/picoclaw/pkg/feedback"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func TestNewExperimentCommand(t *testing.T) {
+	cmd := NewExperimentCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "Compare rollout variants against stable traffic", cmd.Short)
+	assert.NotNil(t, cmd.PersistentPreRunE)
+
+	subcommands := cmd.Commands()
+	require.Len(t, subcommands, 1)
+	assert.Equal(t, "report", subcommands[0].Name())
+}
+
+func TestReportCommand(t *testing.T) {
+	workspace := t.TempDir()
+	tracker := usage.NewTracker(usage.Path(workspace))
+	require.NoError(t, tracker.Record(usage.Record{RequestID: "r1", ChatID: "a", Model: "gpt", DurationMS: 800}))
+	require.NoError(t, tracker.Record(usage.Record{
+		RequestID: "r2", ChatID: "b", Model: "claude", Variant: "canary", DurationMS: 1200,
+	}))
+	store := feedback.NewStore(feedback.Path(workspace))
+	store.Remember(feedback.Turn{ID: "r2", Prompt: "hi", Response: "hello"})
+	_, err := store.Rate("r2", feedback.RatingUp, "u1")
+	require.NoError(t, err)
+
+	run := func(args ...string) (string, error) {
+		cmd := newReportCommand(func() source { return source{workspace: workspace} })
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run()
+	require.NoError(t, err)
+	assert.Contains(t, out, "VARIANT")
+	assert.Contains(t, out, "canary")
+
+	out, err = run("--format", "json")
+	require.NoError(t, err)
+	var arms []experiment.Arm
+	require.NoError(t, json.Unmarshal([]byte(out), &arms))
+	require.Len(t, arms, 2)
+	assert.Equal(t, usage.VariantStable, arms[0].Variant)
+	assert.Equal(t, 1, arms[1].Up)
+
+	_, err = run("--format", "xml")
+	assert.Error(t, err)
+	_, err = run("--days", "0")
+	assert.Error(t, err)
+}
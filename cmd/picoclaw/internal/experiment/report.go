@@ -0,0 +1,61 @@
+package experiment
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/experiment"
+	"github.com/sipeed/picoclaw/pkg/feedback"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func newReportCommand(src func() source) *cobra.Command {
+	var (
+		days   int
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Show latency, cost, and feedback per rollout variant",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if days <= 0 {
+				return fmt.Errorf("--days must be positive")
+			}
+			if format != "table" && format != "json" {
+				return fmt.Errorf("--format must be table or json")
+			}
+			s := src()
+			since := time.Now().AddDate(0, 0, -days)
+			records, err := usage.NewTracker(usage.Path(s.workspace)).Load(since)
+			if err != nil {
+				return fmt.Errorf("error reading usage: %w", err)
+			}
+			ratings, err := feedback.ReadRecords(feedback.Path(s.workspace), feedback.Filter{Since: since})
+			if err != nil {
+				return fmt.Errorf("error reading feedback: %w", err)
+			}
+
+			arms := experiment.Compare(records, ratings, s.pricing)
+			if format == "json" {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(arms)
+			}
+			if len(arms) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "No LLM usage in the last %d days.\n", days)
+				return nil
+			}
+			return experiment.WriteTable(cmd.OutOrStdout(), arms)
+		},
+	}
+
+	cmd.Flags().IntVarP(&days, "days", "d", 7, "Compare the traffic of the last n days")
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "Output format: table or json")
+
+	return cmd
+}
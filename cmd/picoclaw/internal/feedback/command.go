@@ -0,0 +1,35 @@
+package feedback
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/feedback"
+)
+
+func NewFeedbackCommand() *cobra.Command {
+	var storePath string
+
+	cmd := &cobra.Command{
+		Use:   "feedback",
+		Short: "Work with feedback users gave on replies",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			storePath = feedback.Path(cfg.WorkspacePath())
+			return nil
+		},
+	}
+
+	cmd.AddCommand(newExportCommand(func() string { return storePath }))
+
+	return cmd
+}
@@ -0,0 +1,62 @@
+package feedback
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/feedback"
+)
+
+func TestNewFeedbackCommand(t *testing.T) {
+	cmd := NewFeedbackCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "Work with feedback users gave on replies", cmd.Short)
+	assert.NotNil(t, cmd.PersistentPreRunE)
+	assert.True(t, cmd.HasSubCommands())
+
+	subcommands := cmd.Commands()
+	require.Len(t, subcommands, 1)
+	assert.Equal(t, "export", subcommands[0].Name())
+}
+
+func TestExportCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feedback.jsonl")
+	store := feedback.NewStore(path)
+	store.Remember(feedback.Turn{ID: "t1", Prompt: "hi", Response: "hello"})
+	store.Remember(feedback.Turn{ID: "t2", Prompt: "2+2?", Response: "5"})
+	_, err := store.Rate("t1", feedback.RatingUp, "u1")
+	require.NoError(t, err)
+	_, err = store.Rate("t2", feedback.RatingDown, "u1")
+	require.NoError(t, err)
+
+	run := func(args ...string) (string, error) {
+		cmd := newExportCommand(func() string { return path })
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run("--rating", "down")
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(out, "\n"))
+	assert.Contains(t, out, `"prompt":"2+2?"`)
+
+	out, err = run("--format", "csv")
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(out, "\n"))
+	assert.True(t, strings.HasPrefix(out, "turn_id,"))
+
+	_, err = run("--rating", "meh")
+	assert.Error(t, err)
+	_, err = run("--since", "yesterday")
+	assert.Error(t, err)
+}
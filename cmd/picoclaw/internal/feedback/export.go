@@ -0,0 +1,93 @@
+package feedback
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/feedback"
+)
+
+func newExportCommand(storePath func() string) *cobra.Command {
+	var (
+		rating string
+		since  string
+		format string
+		output string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export rated prompt/response pairs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			filter := feedback.Filter{}
+			switch rating {
+			case "", "all":
+			case feedback.RatingUp, feedback.RatingDown:
+				filter.Rating = rating
+			default:
+				return fmt.Errorf("--rating must be up, down, or all")
+			}
+			if since != "" {
+				t, err := parseSince(since)
+				if err != nil {
+					return err
+				}
+				filter.Since = t
+			}
+			write := feedback.WriteJSONL
+			switch format {
+			case "jsonl":
+			case "dataset":
+				write = feedback.WriteDataset
+			case "csv":
+				write = feedback.WriteCSV
+			default:
+				return fmt.Errorf("--format must be jsonl, dataset, or csv")
+			}
+
+			records, err := feedback.ReadRecords(storePath(), filter)
+			if err != nil {
+				return fmt.Errorf("error reading feedback: %w", err)
+			}
+
+			var w io.Writer = cmd.OutOrStdout()
+			if output != "" && output != "-" {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			if err := write(w, records); err != nil {
+				return fmt.Errorf("error writing export: %w", err)
+			}
+			if output != "" && output != "-" {
+				fmt.Printf("✓ Exported %d rated replies to %s\n", len(records), output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&rating, "rating", "r", "all", "Only export ratings that are up, down, or all")
+	cmd.Flags().StringVar(&since, "since", "", "Only export ratings since a date (2006-01-02) or RFC 3339 time")
+	cmd.Flags().StringVarP(&format, "format", "f", "jsonl", "Output format: jsonl, dataset (chat examples), or csv")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write to a file instead of stdout")
+
+	return cmd
+}
+
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("--since must be a date (2006-01-02) or RFC 3339 time")
+}
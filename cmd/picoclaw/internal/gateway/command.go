@@ -1,12 +1,17 @@
 package gateway
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 
+	"github.com/sipeed/picoclaw"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
-	"github.com/sipeed/picoclaw/pkg/gateway"
+	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
@@ -34,7 +39,7 @@ func NewGatewayCommand() *cobra.Command {
 			return nil
 		},
 		RunE: func(_ *cobra.Command, _ []string) error {
-			return gateway.Run(debug, internal.GetConfigPath(), allowEmpty)
+			return runGateway(debug, internal.GetConfigPath(), allowEmpty)
 		},
 	}
 
@@ -50,3 +55,26 @@ func NewGatewayCommand() *cobra.Command {
 
 	return cmd
 }
+
+// runGateway runs the bot of the config at configPath until SIGINT or
+// SIGTERM.
+func runGateway(debug bool, configPath string, allowEmpty bool) error {
+	if debug {
+		logger.SetLevel(logger.DEBUG)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	bot, err := picoclaw.New(cfg,
+		picoclaw.WithConfigPath(configPath),
+		picoclaw.WithDebug(debug),
+		picoclaw.WithAllowEmptyStartup(allowEmpty),
+	)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return bot.Run(ctx)
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
 const Logo = "🦞"
@@ -27,7 +28,12 @@ func GetConfigPath() string {
 }
 
 func LoadConfig() (*config.Config, error) {
-	return config.LoadConfig(GetConfigPath())
+	cfg, err := config.LoadConfig(GetConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	logger.SetRedaction(cfg.LogRedaction())
+	return cfg, nil
 }
 
 // FormatVersion returns the version string with optional git commit
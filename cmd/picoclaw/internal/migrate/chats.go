@@ -0,0 +1,212 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/branch"
+	"github.com/sipeed/picoclaw/pkg/chatimport"
+	"github.com/sipeed/picoclaw/pkg/knowledge"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/routing"
+)
+
+// Import targets.
+const (
+	intoSession = "session"
+	intoMemory  = "memory"
+)
+
+type chatsOptions struct {
+	export      string
+	list        bool
+	selectors   []string
+	into        string
+	session     string // base session key the branches are added to
+	maxMessages int
+	dryRun      bool
+
+	sessionsWorkspace  string // workspace of the agent owning the session
+	knowledgeWorkspace string // workspace holding the knowledge index
+}
+
+func newChatsCommand() *cobra.Command {
+	var (
+		opts    chatsOptions
+		agentID string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "chats <export>",
+		Short: "Import ChatGPT, Claude, or picoclaw conversation exports",
+		Args:  cobra.ExactArgs(1),
+		Example: `  picoclaw migrate chats chatgpt-export.zip --list
+  picoclaw migrate chats chatgpt-export.zip --select 3,"trip to lisbon"
+  picoclaw migrate chats claude-export.zip --into memory
+  picoclaw migrate chats conversation.json --session agent:main:telegram:direct:123456
+  picoclaw migrate chats conversations.json --session agent:main:main --dry-run`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			opts.export = args[0]
+			if opts.session == "" {
+				opts.session = routing.BuildAgentMainSessionKey(agentID)
+			}
+			opts.sessionsWorkspace = agent.AgentWorkspace(cfg, agentID)
+			opts.knowledgeWorkspace = cfg.WorkspacePath()
+			return runChats(cmd.Context(), opts, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.list, "list", false,
+		"List the conversations in the export without importing")
+	cmd.Flags().StringSliceVar(&opts.selectors, "select", nil,
+		"Conversations to import, by position, ID, or title (default: all)")
+	cmd.Flags().StringVar(&opts.into, "into", intoSession,
+		"Import as session branches (session) or knowledge documents (memory)")
+	cmd.Flags().StringVar(&agentID, "agent", routing.DefaultAgentID,
+		"Agent whose sessions receive the conversations")
+	cmd.Flags().StringVar(&opts.session, "session", "",
+		"Session the conversations are added to as branches (default: the agent's main session)")
+	cmd.Flags().IntVar(&opts.maxMessages, "max-messages", 50,
+		"Keep the last N messages of each conversation in session mode (0 keeps all)")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false,
+		"Show what would be imported without making changes")
+
+	return cmd
+}
+
+func runChats(ctx context.Context, opts chatsOptions, out io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.into != intoSession && opts.into != intoMemory {
+		return fmt.Errorf("--into must be %q or %q", intoSession, intoMemory)
+	}
+	convs, err := chatimport.Read(opts.export)
+	if err != nil {
+		return err
+	}
+	if len(convs) == 0 {
+		fmt.Fprintln(out, "The export has no conversations.")
+		return nil
+	}
+	if opts.list {
+		for i, c := range convs {
+			fmt.Fprintf(out, "%4d  %s  %s (%d messages)\n",
+				i+1, c.Created.Local().Format("2006-01-02"), titleOf(c), len(c.Messages))
+		}
+		return nil
+	}
+	picked, err := chatimport.Select(convs, opts.selectors)
+	if err != nil {
+		return err
+	}
+
+	if opts.into == intoMemory {
+		return importToKnowledge(picked, opts, out)
+	}
+	return importToSessions(ctx, picked, opts, out)
+}
+
+func importToKnowledge(convs []chatimport.Conversation, opts chatsOptions, out io.Writer) error {
+	idx := knowledge.NewIndex(knowledge.Path(opts.knowledgeWorkspace))
+	for _, c := range convs {
+		doc := c.Document()
+		if opts.dryRun {
+			fmt.Fprintf(out, "  would index %s (%d passages)\n", titleOf(c), len(doc.Chunks))
+			continue
+		}
+		idx.Upsert(doc)
+		fmt.Fprintf(out, "✓ Indexed %s (%d passages)\n", titleOf(c), len(doc.Chunks))
+	}
+	if opts.dryRun {
+		return nil
+	}
+	return idx.Save()
+}
+
+func importToSessions(ctx context.Context, convs []chatimport.Conversation, opts chatsOptions, out io.Writer) error {
+	branches := branch.NewStore(branch.Dir(opts.sessionsWorkspace))
+	active, _ := branches.Branches(opts.session)
+	var store *memory.JSONLStore
+	if !opts.dryRun {
+		s, err := memory.NewJSONLStore(filepath.Join(opts.sessionsWorkspace, "sessions"))
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		store = s
+	}
+
+	taken := make(map[string]bool)
+	for _, c := range convs {
+		history := c.History(opts.maxMessages)
+		if len(history) == 0 {
+			fmt.Fprintf(out, "  skipped %s: no user message to start from\n", titleOf(c))
+			continue
+		}
+		name := branchName(c, func(n string) bool { return taken[n] || branches.Has(opts.session, n) })
+		taken[name] = true
+		if opts.dryRun {
+			fmt.Fprintf(out, "  would add branch %s: %s (%d messages)\n", name, titleOf(c), len(history))
+			continue
+		}
+		key := branch.Key(opts.session, name)
+		if err := store.SetHistory(ctx, key, history); err != nil {
+			return fmt.Errorf("import %s: %w", titleOf(c), err)
+		}
+		if c.Summary != "" {
+			if err := store.SetSummary(ctx, key, c.Summary); err != nil {
+				return fmt.Errorf("import %s: %w", titleOf(c), err)
+			}
+		}
+		err := branches.Add(opts.session, branch.Branch{Name: name, Parent: branch.Main, Created: time.Now()})
+		if err != nil {
+			return fmt.Errorf("import %s: %w", titleOf(c), err)
+		}
+		fmt.Fprintf(out, "✓ Added branch %s: %s (%d messages)\n", name, titleOf(c), len(history))
+	}
+	if opts.dryRun || len(taken) == 0 {
+		return nil
+	}
+	// Adding a branch makes it active; the chat stays where the user left it.
+	return branches.Switch(opts.session, active)
+}
+
+var nonNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// branchName derives a branch name from the conversation title, adding a
+// numeric suffix when taken reports the name as used.
+func branchName(c chatimport.Conversation, taken func(string) bool) string {
+	slug := strings.Trim(nonNameChars.ReplaceAllString(strings.ToLower(c.Title), "-"), "-")
+	if len(slug) > 24 {
+		slug = strings.TrimRight(slug[:24], "-")
+	}
+	if !branch.ValidName(slug) {
+		slug = c.Source
+	}
+	name := slug
+	for n := 2; taken(name); n++ {
+		name = fmt.Sprintf("%s-%d", slug, n)
+	}
+	return name
+}
+
+func titleOf(c chatimport.Conversation) string {
+	if c.Title == "" {
+		return "untitled conversation"
+	}
+	return fmt.Sprintf("%q", c.Title)
+}
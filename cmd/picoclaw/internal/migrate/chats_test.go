@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/branch"
+	"github.com/sipeed/picoclaw/pkg/knowledge"
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+const claudeExport = `[
+  {"uuid": "u-1", "name": "Go generics", "created_at": "2024-05-01T12:00:00Z",
+   "chat_messages": [{"sender": "human", "text": "Explain type sets"}, {"sender": "assistant", "text": "A type set is the set of types."}]},
+  {"uuid": "u-2", "name": "Go generics", "created_at": "2024-05-02T12:00:00Z",
+   "chat_messages": [{"sender": "human", "text": "Constraints?"}]}
+]`
+
+func TestNewChatsCommand(t *testing.T) {
+	cmd := newChatsCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "chats <export>", cmd.Use)
+	assert.True(t, cmd.HasExample())
+	for _, name := range []string{"list", "select", "into", "agent", "session", "max-messages", "dry-run"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), name)
+	}
+}
+
+func TestRunChats_Sessions(t *testing.T) {
+	workspace := t.TempDir()
+	export := filepath.Join(t.TempDir(), "conversations.json")
+	require.NoError(t, os.WriteFile(export, []byte(claudeExport), 0o644))
+	ctx := context.Background()
+	opts := chatsOptions{
+		export:            export,
+		into:              intoSession,
+		session:           "agent:main:main",
+		sessionsWorkspace: workspace,
+	}
+
+	var out bytes.Buffer
+	opts.list = true
+	require.NoError(t, runChats(ctx, opts, &out))
+	assert.Contains(t, out.String(), `2  2024-05-02  "Go generics" (1 messages)`)
+
+	opts.list = false
+	require.NoError(t, runChats(ctx, opts, &out))
+	store, err := memory.NewJSONLStore(filepath.Join(workspace, "sessions"))
+	require.NoError(t, err)
+	defer store.Close()
+	history, err := store.GetHistory(ctx, branch.Key("agent:main:main", "go-generics"))
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "Explain type sets", history[0].Content)
+
+	branches := branch.NewStore(branch.Dir(workspace))
+	active, list := branches.Branches("agent:main:main")
+	assert.Equal(t, branch.Main, active)
+	require.Len(t, list, 2)
+	assert.Equal(t, "go-generics-2", list[1].Name)
+
+	opts.into = "nowhere"
+	assert.Error(t, runChats(ctx, opts, &out))
+}
+
+func TestRunChats_Memory(t *testing.T) {
+	workspace := t.TempDir()
+	export := filepath.Join(t.TempDir(), "conversations.json")
+	require.NoError(t, os.WriteFile(export, []byte(claudeExport), 0o644))
+	opts := chatsOptions{
+		export:             export,
+		into:               intoMemory,
+		selectors:          []string{"1"},
+		knowledgeWorkspace: workspace,
+	}
+
+	var out bytes.Buffer
+	opts.dryRun = true
+	require.NoError(t, runChats(context.Background(), opts, &out))
+	_, err := os.Stat(knowledge.Path(workspace))
+	assert.True(t, os.IsNotExist(err), "dry run wrote the index")
+
+	opts.dryRun = false
+	require.NoError(t, runChats(context.Background(), opts, &out))
+	idx := knowledge.NewIndex(knowledge.Path(workspace))
+	assert.Equal(t, 1, idx.Len())
+}
@@ -48,5 +48,8 @@ func NewMigrateCommand() *cobra.Command {
 	cmd.Flags().StringVar(&opts.TargetHome, "target-home", "",
 		"Override target home directory (default: ~/.picoclaw)")
 
+	cmd.AddCommand(newDBCommand())
+	cmd.AddCommand(newChatsCommand())
+
 	return cmd
 }
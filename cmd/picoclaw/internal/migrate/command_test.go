@@ -18,7 +18,7 @@ func TestNewMigrateCommand(t *testing.T) {
 	assert.Len(t, cmd.Aliases, 0)
 
 	assert.True(t, cmd.HasExample())
-	assert.False(t, cmd.HasSubCommands())
+	assert.True(t, cmd.HasSubCommands())
 
 	assert.Nil(t, cmd.Run)
 	assert.NotNil(t, cmd.RunE)
@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/database"
+	"github.com/sipeed/picoclaw/pkg/dbmigrate"
+)
+
+func newDBCommand() *cobra.Command {
+	var (
+		dbPath string
+		status bool
+		down   int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Apply or inspect database schema migrations",
+		Args:  cobra.NoArgs,
+		Example: `  picoclaw migrate db
+  picoclaw migrate db --status
+  picoclaw migrate db --down 1`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if dbPath == "" {
+				cfg, err := internal.LoadConfig()
+				if err != nil {
+					return fmt.Errorf("error loading config: %w", err)
+				}
+				dbPath = database.Path(cfg.WorkspacePath())
+			}
+			return runDBMigrate(cmd.Context(), dbPath, status, down)
+		},
+	}
+
+	cmd.Flags().StringVar(&dbPath, "db", "",
+		"Database file (default: <workspace>/state/picoclaw.db)")
+	cmd.Flags().BoolVar(&status, "status", false,
+		"Show applied and pending migrations without changing anything")
+	cmd.Flags().IntVar(&down, "down", 0,
+		"Revert the given number of most recent migrations")
+
+	return cmd
+}
+
+func runDBMigrate(ctx context.Context, path string, status bool, down int) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db, err := database.OpenRaw(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrations, err := database.Migrations()
+	if err != nil {
+		return err
+	}
+	m := dbmigrate.New(db, migrations)
+
+	switch {
+	case status:
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("  %04d_%s  %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	case down > 0:
+		reverted, err := m.Down(ctx, down)
+		for _, mig := range reverted {
+			fmt.Printf("✓ Reverted %04d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+	default:
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("✓ Applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database schema is up to date.")
+		}
+		return nil
+	}
+}
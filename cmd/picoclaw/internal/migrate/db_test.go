@@ -0,0 +1,31 @@
+package migrate
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDBCommand(t *testing.T) {
+	cmd := newDBCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "db", cmd.Use)
+	assert.True(t, cmd.HasExample())
+	assert.NotNil(t, cmd.Flags().Lookup("db"))
+	assert.NotNil(t, cmd.Flags().Lookup("status"))
+	assert.NotNil(t, cmd.Flags().Lookup("down"))
+}
+
+func TestRunDBMigrate_UpStatusDown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "picoclaw.db")
+	ctx := context.Background()
+
+	require.NoError(t, runDBMigrate(ctx, path, false, 0))
+	require.NoError(t, runDBMigrate(ctx, path, true, 0))
+	require.NoError(t, runDBMigrate(ctx, path, false, 1))
+}
@@ -0,0 +1,147 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/batch"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func NewRunCommand() *cobra.Command {
+	var (
+		input   string
+		output  string
+		model   string
+		opts    batch.Options
+		verbose bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Answer a file of prompts with the full agent pipeline",
+		Args:  cobra.NoArgs,
+		Example: `  picoclaw run --input prompts.jsonl --output results.jsonl
+  picoclaw run --input evals.jsonl --concurrency 8 --timeout 2m --model gpt-4o-mini`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if !verbose {
+				logger.SetLevel(logger.ERROR)
+			}
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			process, closeAgent, err := newProcessor(model)
+			if err != nil {
+				return err
+			}
+			defer closeAgent()
+			return runFile(ctx, input, output, process, opts, cmd.ErrOrStderr())
+		},
+	}
+
+	cmd.Flags().StringVarP(&input, "input", "i", "", "JSON Lines file of prompts (- for stdin)")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "File the results are written to (- for stdout)")
+	cmd.Flags().IntVarP(&opts.Concurrency, "concurrency", "c", 4, "Number of sessions answered at the same time")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 0, "Time limit of each prompt (0 for none)")
+	cmd.Flags().StringVar(&model, "model", "", "Model to use")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Show the agent log while prompts run")
+	_ = cmd.MarkFlagRequired("input")
+
+	return cmd
+}
+
+// newProcessor starts an agent loop like `picoclaw agent` does. Replies are
+// returned whole instead of going through a channel, so they are never split.
+func newProcessor(model string) (batch.Processor, func(), error) {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error loading config: %w", err)
+	}
+	if model != "" {
+		cfg.Agents.Defaults.ModelName = model
+	}
+	provider, modelID, err := providers.CreateProvider(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating provider: %w", err)
+	}
+	if modelID != "" {
+		cfg.Agents.Defaults.ModelName = modelID
+	}
+
+	msgBus := bus.NewMessageBus()
+	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	// Nothing delivers what tools send to a chat; drop it rather than let the
+	// bus fill up and stall the run
+	go func() {
+		for range msgBus.OutboundChan() {
+		}
+	}()
+	go func() {
+		for range msgBus.OutboundMediaChan() {
+		}
+	}()
+
+	// Every run gets its own sessions, so earlier runs do not leak into it
+	prefix := "batch:" + time.Now().Format("20060102-150405") + ":"
+	process := func(ctx context.Context, session, prompt string) (string, error) {
+		return agentLoop.ProcessDirectWithChannel(ctx, prompt, prefix+session, "cli", "batch")
+	}
+	return process, func() {
+		agentLoop.Close()
+		msgBus.Close()
+	}, nil
+}
+
+func runFile(
+	ctx context.Context,
+	input, output string,
+	process batch.Processor,
+	opts batch.Options,
+	status io.Writer,
+) error {
+	in := os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	prompts, err := batch.Read(in)
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+
+	out := os.Stdout
+	if output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	start := time.Now()
+	summary, err := batch.Run(ctx, prompts, process, opts, out)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(status, "%s Answered %d prompts in %s\n", internal.Logo, summary.Prompts,
+		time.Since(start).Round(time.Millisecond))
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d of %d prompts failed", summary.Failed, summary.Prompts)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/batch"
+)
+
+func TestNewRunCommand(t *testing.T) {
+	cmd := NewRunCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "run", cmd.Name())
+	assert.True(t, cmd.HasExample())
+	assert.NotNil(t, cmd.RunE)
+	for _, name := range []string{"input", "output", "concurrency", "timeout", "model", "verbose"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), name)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "prompts.jsonl")
+	output := filepath.Join(dir, "results.jsonl")
+	require.NoError(t, os.WriteFile(input, []byte(`{"id": "a", "prompt": "hi"}
+{"id": "b", "prompt": "fail"}
+`), 0o644))
+	process := func(_ context.Context, _, prompt string) (string, error) {
+		if prompt == "fail" {
+			return "", errors.New("no provider")
+		}
+		return "hello", nil
+	}
+
+	var status bytes.Buffer
+	err := runFile(context.Background(), input, output, process, batch.Options{Concurrency: 2}, &status)
+	require.EqualError(t, err, "1 of 2 prompts failed")
+	assert.Contains(t, status.String(), "Answered 2 prompts")
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"a","response":"hello","duration_ms":0}
+{"id":"b","error":"no provider","duration_ms":0}
+`, string(data))
+}
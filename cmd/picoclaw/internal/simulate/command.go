@@ -0,0 +1,68 @@
+package simulate
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/simulator"
+)
+
+func NewSimulateCommand() *cobra.Command {
+	var verbose bool
+
+	cmd := &cobra.Command{
+		Use:   "simulate <scenario.yaml>...",
+		Short: "Run scripted conversations against the full pipeline",
+		Args:  cobra.MinimumNArgs(1),
+		Example: `  picoclaw simulate scenarios/splitting.yaml
+  picoclaw simulate scenarios/*.yaml --verbose`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !verbose {
+				logger.SetLevel(logger.ERROR)
+			}
+			return runScenarios(cmd.Context(), args, cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Show the gateway log while scenarios run")
+
+	return cmd
+}
+
+func runScenarios(ctx context.Context, paths []string, out io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	failed := 0
+	for _, path := range paths {
+		sc, err := simulator.Load(path)
+		if err != nil {
+			return err
+		}
+		report, err := simulator.Run(ctx, sc)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		name := report.Scenario
+		if name == "" {
+			name = path
+		}
+		if report.Passed() {
+			fmt.Fprintf(out, "✓ %s (%d steps)\n", name, report.Steps)
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "✗ %s\n", name)
+		for _, f := range report.Failures {
+			fmt.Fprintf(out, "    %s\n", f)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scenarios failed", failed, len(paths))
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSimulateCommand(t *testing.T) {
+	cmd := NewSimulateCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "simulate", cmd.Name())
+	assert.True(t, cmd.HasExample())
+	assert.NotNil(t, cmd.RunE)
+	assert.NotNil(t, cmd.Flags().Lookup("verbose"))
+}
+
+func TestRunScenarios(t *testing.T) {
+	dir := t.TempDir()
+	pass := filepath.Join(dir, "pass.yaml")
+	fail := filepath.Join(dir, "fail.yaml")
+	require.NoError(t, os.WriteFile(pass, []byte(`
+name: greeting
+channels: [{name: telegram}]
+replies: [{reply: "Hello"}]
+steps:
+  - send: {channel: telegram, user: alice, text: hi}
+    expect: {chunks: 1, contains: [Hello]}
+`), 0o644))
+	require.NoError(t, os.WriteFile(fail, []byte(`
+name: wrong greeting
+channels: [{name: telegram}]
+replies: [{reply: "Hello"}]
+steps:
+  - send: {channel: telegram, user: alice, text: hi}
+    expect: {contains: [Goodbye]}
+`), 0o644))
+
+	var out bytes.Buffer
+	require.NoError(t, runScenarios(context.Background(), []string{pass}, &out))
+	assert.Contains(t, out.String(), "✓ greeting (1 steps)")
+
+	out.Reset()
+	err := runScenarios(context.Background(), []string{pass, fail}, &out)
+	require.EqualError(t, err, "1 of 2 scenarios failed")
+	assert.Contains(t, out.String(), "✗ wrong greeting")
+	assert.Contains(t, out.String(), `no chunk contains "Goodbye"`)
+}
@@ -3,10 +3,12 @@ package status
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
 	"github.com/sipeed/picoclaw/pkg/auth"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/usage"
 )
 
 func statusCmd() {
@@ -97,5 +99,23 @@ func statusCmd() {
 				fmt.Printf("  %s (%s): %s\n", provider, cred.AuthMethod, status)
 			}
 		}
+
+		printUsageByVariant(cfg.WorkspacePath())
+	}
+}
+
+// printUsageByVariant shows the last week of LLM usage per rollout variant,
+// so a canary can be compared side by side with stable traffic.
+func printUsageByVariant(workspace string) {
+	records, err := usage.NewTracker(usage.Path(workspace)).Load(time.Now().AddDate(0, 0, -7))
+	if err != nil || len(records) == 0 {
+		return
+	}
+	groups := usage.ByVariant(records)
+	fmt.Println("\nUsage (last 7 days):")
+	for _, key := range usage.SortedKeys(groups) {
+		s := groups[key]
+		fmt.Printf("  %s: %d requests, %d tokens, %.1f%% errors, avg %s\n",
+			key, s.Requests, s.TotalTokens(), s.ErrorRate()*100, s.AvgLatency())
 	}
 }
@@ -35,12 +35,19 @@ func TestNewPicoclawCommand(t *testing.T) {
 
 	allowedCommands := []string{
 		"agent",
+		"analytics",
 		"auth",
+		"chat",
+		"config",
 		"cron",
+		"experiment",
+		"feedback",
 		"gateway",
 		"migrate",
 		"model",
 		"onboard",
+		"run",
+		"simulate",
 		"skills",
 		"status",
 		"version",
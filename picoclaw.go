@@ -0,0 +1,112 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// Inspired by and based on nanobot: https://github.com/HKUDS/nanobot
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+// Package picoclaw runs the picoclaw agent inside another Go program, with
+// the channels, tools, and services of the gateway command:
+//
+//	cfg, err := config.LoadConfig("config.json")
+//	if err != nil {
+//		return err
+//	}
+//	bot, err := picoclaw.New(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	bot.AddTool(myTool)
+//	bot.AddChannel(myChannel) // built with channels.NewBaseChannel(..., bot.Bus(), ...)
+//	return bot.Run(ctx)
+package picoclaw
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/gateway"
+	"github.com/sipeed/picoclaw/pkg/middleware"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// Bot is an agent with its channels, ready to run.
+type Bot struct {
+	gw *gateway.Gateway
+}
+
+// Option configures a Bot.
+type Option func(*gateway.Options)
+
+// WithConfigPath names the file cfg was loaded from, so that it is reloaded
+// when it changes and gateway.hot_reload is on.
+func WithConfigPath(path string) Option {
+	return func(o *gateway.Options) {
+		o.ConfigPath = path
+	}
+}
+
+// WithDebug logs at debug level.
+func WithDebug(debug bool) Option {
+	return func(o *gateway.Options) {
+		o.Debug = debug
+	}
+}
+
+// WithAllowEmptyStartup starts the bot without a default model.
+func WithAllowEmptyStartup(allow bool) Option {
+	return func(o *gateway.Options) {
+		o.AllowEmptyStartup = allow
+	}
+}
+
+// New creates a bot for cfg, with the provider of its default model and
+// the channels it enables. Nothing runs until Run.
+func New(cfg *config.Config, opts ...Option) (*Bot, error) {
+	var o gateway.Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	gw, err := gateway.New(cfg, o)
+	if err != nil {
+		return nil, err
+	}
+	return &Bot{gw: gw}, nil
+}
+
+// AddChannel adds a channel beside the configured ones. It must publish
+// on Bus. Call before Run.
+func (b *Bot) AddChannel(ch channels.Channel) {
+	b.gw.AddChannel(ch)
+}
+
+// AddTool gives tool to every agent, replacing a built-in tool of the same
+// name.
+func (b *Bot) AddTool(tool tools.Tool) {
+	b.gw.AddTool(tool)
+}
+
+// Use adds mw at point of the message pipeline, one of the middleware.Point
+// constants. It runs before the middleware of the config.
+func (b *Bot) Use(point string, mw middleware.Middleware) error {
+	return b.gw.Use(point, mw)
+}
+
+// Bus returns the message bus of the bot's channels.
+func (b *Bot) Bus() *bus.MessageBus {
+	return b.gw.Bus()
+}
+
+// Agent returns the agent, for example to answer a message directly with
+// ProcessDirect.
+func (b *Bot) Agent() *agent.AgentLoop {
+	return b.gw.AgentLoop()
+}
+
+// Run serves until ctx is done, then shuts down, finishing the messages
+// being answered.
+func (b *Bot) Run(ctx context.Context) error {
+	return b.gw.Run(ctx)
+}
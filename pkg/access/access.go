@@ -0,0 +1,97 @@
+// Package access decides who may use the bot and which commands they may
+// run, by sender, Discord role, and Discord server, for every channel alike.
+package access
+
+import (
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+)
+
+// Entry prefixes and keywords besides the sender IDs of allow_from.
+const (
+	prefixRole   = "role:"
+	prefixGuild  = "guild:"
+	keywordAdmin = "admins"
+	keywordAll   = "*"
+)
+
+// Subject is the sender of a message, with what its channel knows about
+// them.
+type Subject struct {
+	Sender   bus.SenderInfo
+	SenderID string
+	GuildID  string
+	Roles    []string
+	// IsAdmin reports whether the sender is a maintenance admin; nil means
+	// no one is.
+	IsAdmin func() bool
+}
+
+// Allowed reports whether cfg lets s use the bot at all.
+func Allowed(cfg config.AccessConfig, s Subject) bool {
+	if Matches(cfg.Deny, s) {
+		return false
+	}
+	return len(cfg.Allow) == 0 || Matches(cfg.Allow, s)
+}
+
+// MayRun reports whether cfg lets s run command, which is a command name or
+// a command and sub-command name such as "switch model". Commands cfg does
+// not list are open to everyone.
+func MayRun(cfg config.AccessConfig, command string, s Subject) bool {
+	command = normalizeCommand(command)
+	for name, entries := range cfg.Commands {
+		if normalizeCommand(name) == command {
+			return Matches(entries, s)
+		}
+	}
+	return true
+}
+
+// Matches reports whether any of entries names s.
+func Matches(entries []string, s Subject) bool {
+	for _, entry := range entries {
+		if matchEntry(strings.TrimSpace(entry), s) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchEntry(entry string, s Subject) bool {
+	switch {
+	case entry == "":
+		return false
+	case entry == keywordAll:
+		return true
+	case strings.EqualFold(entry, keywordAdmin):
+		return s.IsAdmin != nil && s.IsAdmin()
+	}
+	if id, ok := cutPrefixFold(entry, prefixRole); ok {
+		for _, role := range s.Roles {
+			if role != "" && role == id {
+				return true
+			}
+		}
+		return false
+	}
+	if id, ok := cutPrefixFold(entry, prefixGuild); ok {
+		return id != "" && id == s.GuildID
+	}
+	return identity.MatchAllowed(s.Sender, entry) || (s.SenderID != "" && entry == s.SenderID)
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(s[len(prefix):]), true
+}
+
+func normalizeCommand(command string) string {
+	command = strings.TrimLeft(strings.TrimSpace(command), "/!")
+	return strings.Join(strings.Fields(strings.ToLower(command)), " ")
+}
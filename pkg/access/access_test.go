@@ -0,0 +1,71 @@
+package access
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func discordSubject(id, guild string, roles ...string) Subject {
+	return Subject{
+		Sender:   bus.SenderInfo{Platform: "discord", PlatformID: id, CanonicalID: "discord:" + id},
+		SenderID: id,
+		GuildID:  guild,
+		Roles:    roles,
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	cfg := config.AccessConfig{
+		Allow: []string{"guild:100", "discord:7"},
+		Deny:  []string{"discord:13", "role:muted"},
+	}
+	for _, tc := range []struct {
+		name string
+		s    Subject
+		want bool
+	}{
+		{"member of the allowed server", discordSubject("1", "100"), true},
+		{"allowed user outside it", discordSubject("7", "200"), true},
+		{"stranger", discordSubject("2", "200"), false},
+		{"denied user in the allowed server", discordSubject("13", "100"), false},
+		{"denied role in the allowed server", discordSubject("3", "100", "muted"), false},
+	} {
+		if got := Allowed(cfg, tc.s); got != tc.want {
+			t.Errorf("%s: Allowed = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	if !Allowed(config.AccessConfig{}, discordSubject("2", "")) {
+		t.Error("an empty allow list should let everyone in")
+	}
+}
+
+func TestMayRun(t *testing.T) {
+	cfg := config.AccessConfig{Commands: map[string][]string{
+		"model":        {"admins"},
+		"Switch Model": {"role:mods", "admins"},
+	}}
+	admin := discordSubject("1", "100")
+	admin.IsAdmin = func() bool { return true }
+	mod := discordSubject("2", "100", "mods")
+	member := discordSubject("3", "100", "members")
+
+	for _, tc := range []struct {
+		command string
+		s       Subject
+		want    bool
+	}{
+		{"model", admin, true},
+		{"model", mod, false},
+		{"/switch  model", mod, true},
+		{"switch model", member, false},
+		{"switch channel", member, true},
+		{"help", member, true},
+	} {
+		if got := MayRun(cfg, tc.command, tc.s); got != tc.want {
+			t.Errorf("MayRun(%q, %s) = %v, want %v", tc.command, tc.s.SenderID, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/chatexport"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/search"
+)
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker()
+	start := time.Now()
+	tr.Touch(Session{Agent: "main", SessionKey: "a", Channel: "telegram", ChatID: "1"}, start)
+	tr.Touch(Session{Agent: "main", SessionKey: "b", Channel: "discord", ChatID: "2"}, start.Add(time.Minute))
+	tr.Touch(Session{Agent: "main", SessionKey: "a", Channel: "telegram", ChatID: "1"}, start.Add(2*time.Minute))
+	tr.Touch(Session{Agent: "main", SessionKey: "c", Channel: "telegram", ChatID: "1"}, start)
+
+	list := tr.List(time.Time{})
+	if len(list) != 3 || list[0].SessionKey != "a" || list[0].Messages != 2 {
+		t.Fatalf("List = %+v", list)
+	}
+	if got := tr.List(start.Add(30 * time.Second)); len(got) != 2 {
+		t.Errorf("List since = %+v", got)
+	}
+	chats := tr.Chats([]string{"telegram"}, time.Time{})
+	if len(chats) != 1 || chats[0] != (Target{Channel: "telegram", ChatID: "1"}) {
+		t.Errorf("Chats = %+v", chats)
+	}
+	if got := tr.Chats(nil, time.Time{}); len(got) != 2 {
+		t.Errorf("Chats of every channel = %+v", got)
+	}
+}
+
+func TestBans(t *testing.T) {
+	path := BansPath(t.TempDir())
+	b := NewBans(path)
+	now := time.Now()
+	if err := b.Add(Ban{User: "telegram", Until: now.Add(time.Hour)}); err == nil {
+		t.Error("ban without a sender ID accepted")
+	}
+	if err := b.Add(Ban{User: "telegram:42", Until: now.Add(time.Hour), Reason: "spam"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sender := bus.SenderInfo{Platform: "telegram", PlatformID: "42", CanonicalID: "telegram:42"}
+	if ban, ok := b.Banned("telegram", sender, "42", now); !ok || ban.Reason != "spam" {
+		t.Errorf("Banned = %+v, %v", ban, ok)
+	}
+	other := bus.SenderInfo{Platform: "discord", PlatformID: "42", CanonicalID: "discord:42"}
+	if _, ok := b.Banned("discord", other, "42", now); ok {
+		t.Error("ban applied to another channel")
+	}
+	if _, ok := b.Banned("telegram", sender, "42", now.Add(2*time.Hour)); ok {
+		t.Error("expired ban still in force")
+	}
+
+	// Bans survive a restart
+	b = NewBans(path)
+	if list := b.List(now); len(list) != 1 || list[0].User != "telegram:42" {
+		t.Fatalf("reloaded bans = %+v", list)
+	}
+	if err := b.Remove("telegram:42"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Remove("telegram:42"); err != ErrUnknownBan {
+		t.Errorf("Remove twice = %v", err)
+	}
+}
+
+func TestPatchChannel(t *testing.T) {
+	var channels config.ChannelsConfig
+	channels.Discord.GroupTrigger.Prefixes = []string{"!"}
+	err := PatchChannel(&channels, "discord", map[string]any{
+		"allow_from":    []any{"discord:1"},
+		"group_trigger": map[string]any{"mention_only": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := channels.Discord
+	if len(d.AllowFrom) != 1 || !d.GroupTrigger.MentionOnly || len(d.GroupTrigger.Prefixes) != 1 {
+		t.Errorf("patched discord = %+v", d)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	tracker := NewTracker()
+	tracker.Touch(Session{Agent: "main", SessionKey: "s1", Channel: "telegram", ChatID: "1"}, time.Now())
+	tracker.Touch(Session{Agent: "main", SessionKey: "s2", Channel: "discord", ChatID: "2"}, time.Now())
+	var sent []bus.OutboundMessage
+	var cleared []string
+	var patched map[string]any
+	reloads := 0
+	cfg := &config.Config{}
+	cfg.Channels.Telegram.Token = "123:abc"
+	h := NewHandler("secret", Backend{
+		Sessions: tracker,
+		Bans:     NewBans(BansPath(t.TempDir())),
+		History: func(agentID, sessionKey string) (chatexport.Transcript, error) {
+			if sessionKey != "s1" {
+				return chatexport.Transcript{}, chatexport.ErrUnknownSession
+			}
+			return chatexport.New("main", sessionKey, "", nil), nil
+		},
+		Clear: func(agentID, sessionKey string) error {
+			cleared = append(cleared, sessionKey)
+			return nil
+		},
+		Reload: func() error {
+			reloads++
+			if reloads > 1 {
+				return ErrNoConfigFile
+			}
+			return nil
+		},
+		Publish: func(_ context.Context, msg bus.OutboundMessage) error {
+			sent = append(sent, msg)
+			return nil
+		},
+		Config: func() *config.Config { return cfg },
+		UpdateChannel: func(name string, patch map[string]any) error {
+			patched = patch
+			return PatchChannel(&cfg.Channels, name, patch)
+		},
+	})
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodGet, "/admin/sessions", "wrong", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: %d", w.Code)
+	}
+	var sessions sessionsResponse
+	w := do(http.MethodGet, "/admin/sessions?channel=discord", "secret", "")
+	if json.Unmarshal(w.Body.Bytes(), &sessions) != nil || len(sessions.Sessions) != 1 ||
+		sessions.Sessions[0].SessionKey != "s2" {
+		t.Fatalf("GET sessions: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(http.MethodGet, "/admin/history?session=s1", "secret", ""); w.Code != http.StatusOK {
+		t.Errorf("GET history: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/admin/history?session=nope", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET unknown history: %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/admin/history?session=s1", "secret", ""); w.Code != http.StatusNoContent ||
+		len(cleared) != 1 {
+		t.Errorf("DELETE history: %d, cleared %v", w.Code, cleared)
+	}
+
+	if w := do(http.MethodPost, "/admin/reload", "secret", ""); w.Code != http.StatusAccepted {
+		t.Errorf("reload: %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/admin/reload", "secret", ""); w.Code != http.StatusConflict {
+		t.Errorf("second reload: %d", w.Code)
+	}
+
+	w = do(http.MethodPost, "/admin/broadcast", "secret", `{"message":"Back at 5pm","channels":["telegram"]}`)
+	if w.Code != http.StatusOK || len(sent) != 1 || sent[0].ChatID != "1" || sent[0].Content != "Back at 5pm" {
+		t.Errorf("broadcast: %d %s, sent %+v", w.Code, w.Body.String(), sent)
+	}
+	sent = nil
+	do(http.MethodPost, "/admin/broadcast", "secret", `{"message":"hi","chats":[{"channel":"slack","chat_id":"C1"}]}`)
+	if len(sent) != 1 || sent[0].Channel != "slack" {
+		t.Errorf("broadcast to chats sent %+v", sent)
+	}
+	if w := do(http.MethodPost, "/admin/broadcast", "secret", `{"message":" "}`); w.Code != http.StatusBadRequest {
+		t.Errorf("empty broadcast: %d", w.Code)
+	}
+
+	w = do(http.MethodPost, "/admin/bans", "secret", `{"user":"telegram:7","minutes":0}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("ban without minutes: %d", w.Code)
+	}
+	var bans bansResponse
+	w = do(http.MethodPost, "/admin/bans", "secret", `{"user":"telegram:7","minutes":30,"reason":"spam"}`)
+	if json.Unmarshal(w.Body.Bytes(), &bans) != nil || len(bans.Bans) != 1 || bans.Bans[0].User != "telegram:7" {
+		t.Fatalf("POST bans: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodDelete, "/admin/bans/telegram:7", "secret", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE ban: %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/admin/bans/telegram:7", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("DELETE lifted ban: %d", w.Code)
+	}
+	w = do(http.MethodGet, "/admin/channels", "secret", "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "123:abc") ||
+		!strings.Contains(w.Body.String(), secretMask) {
+		t.Errorf("GET channels: %d %s", w.Code, w.Body.String())
+	}
+	w = do(http.MethodPatch, "/admin/channels/telegram", "secret", `{"enabled":true,"token":"********"}`)
+	if w.Code != http.StatusAccepted || !cfg.Channels.Telegram.Enabled || cfg.Channels.Telegram.Token != "123:abc" {
+		t.Errorf("PATCH channel: %d %s, telegram %+v", w.Code, w.Body.String(), cfg.Channels.Telegram)
+	}
+	if _, ok := patched["token"]; ok {
+		t.Error("masked secret passed on")
+	}
+	if w := do(http.MethodPatch, "/admin/channels/nope", "secret", `{}`); w.Code != http.StatusNotFound {
+		t.Errorf("PATCH unknown channel: %d", w.Code)
+	}
+	w = do(http.MethodPatch, "/admin/channels/telegram", "secret", `{"enabled":"yes"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("PATCH bad setting: %d", w.Code)
+	}
+
+	// Usage, transcripts, and search are off in this backend
+	if w := do(http.MethodGet, "/admin/usage", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET usage: %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/admin/search?q=x", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET search: %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/admin/nope", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown route: %d", w.Code)
+	}
+}
+
+func TestServeSearch(t *testing.T) {
+	var got search.Filter
+	h := NewHandler("secret", Backend{
+		Search: func(_ context.Context, query string, f search.Filter) ([]search.Match, error) {
+			got = f
+			return []search.Match{{
+				Entry:   search.Entry{Role: "assistant", Text: "use vault", Link: "https://discord.com/channels/1/2/3"},
+				Excerpt: "use **vault**",
+			}}, nil
+		},
+	})
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/admin/search?q=vault&channel=discord&since=2026-01-02T00:00:00Z&limit=5")
+	var resp searchResponse
+	if json.Unmarshal(w.Body.Bytes(), &resp) != nil || len(resp.Matches) != 1 ||
+		resp.Matches[0].Link != "https://discord.com/channels/1/2/3" {
+		t.Fatalf("GET search: %d %s", w.Code, w.Body.String())
+	}
+	if got.Channel != "discord" || got.Limit != 5 || got.Since.IsZero() {
+		t.Errorf("filter = %+v", got)
+	}
+	for _, path := range []string{"/admin/search", "/admin/search?q=x&since=yesterday", "/admin/search?q=x&limit=0"} {
+		if w := get(path); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ErrUnknownBan is returned when lifting a ban that does not exist.
+var ErrUnknownBan = errors.New("the user is not banned")
+
+// Ban keeps a user from talking to the bot until it expires.
+type Ban struct {
+	// User is "<channel>:<sender id>", as in allow_from.
+	User   string    `json:"user"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// BansPath returns where the bans of a workspace are kept.
+func BansPath(workspace string) string {
+	return filepath.Join(workspace, "state", "bans.json")
+}
+
+// Bans holds the temporary bans, persisted so that they survive a restart.
+// It is safe for concurrent use.
+type Bans struct {
+	path string
+
+	mu   sync.Mutex
+	bans map[string]Ban
+}
+
+// NewBans loads the bans kept at path.
+func NewBans(path string) *Bans {
+	b := &Bans{path: path, bans: make(map[string]Ban)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return b
+	}
+	var list []Ban
+	if err := json.Unmarshal(data, &list); err != nil {
+		logger.WarnCF("admin", "Ignoring unreadable bans", map[string]any{
+			"path":  path,
+			"error": err.Error(),
+		})
+		return b
+	}
+	for _, ban := range list {
+		b.bans[ban.User] = ban
+	}
+	return b
+}
+
+// Add bans ban.User until ban.Until, replacing an earlier ban of the user.
+func (b *Bans) Add(ban Ban) error {
+	channel, id, ok := strings.Cut(strings.TrimSpace(ban.User), ":")
+	if !ok || channel == "" || id == "" {
+		return fmt.Errorf("user must be <channel>:<sender id>, got %q", ban.User)
+	}
+	ban.User = channel + ":" + id
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bans[ban.User] = ban
+	return b.saveLocked()
+}
+
+// Remove lifts the ban of user.
+func (b *Bans) Remove(user string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.bans[user]; !ok {
+		return ErrUnknownBan
+	}
+	delete(b.bans, user)
+	return b.saveLocked()
+}
+
+// List returns the bans in force at now, the soonest to expire first.
+func (b *Bans) List(now time.Time) []Ban {
+	b.mu.Lock()
+	list := make([]Ban, 0, len(b.bans))
+	for _, ban := range b.bans {
+		if now.Before(ban.Until) {
+			list = append(list, ban)
+		}
+	}
+	b.mu.Unlock()
+	slices.SortFunc(list, func(a, b Ban) int {
+		return a.Until.Compare(b.Until)
+	})
+	return list
+}
+
+// Banned returns the ban in force at now of the sender of a message on
+// channel.
+func (b *Bans) Banned(channel string, sender bus.SenderInfo, senderID string, now time.Time) (Ban, bool) {
+	if b == nil {
+		return Ban{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ban := range b.bans {
+		if !now.Before(ban.Until) {
+			continue
+		}
+		if identity.MatchAllowed(sender, ban.User) || ban.User == senderID || ban.User == channel+":"+senderID {
+			return ban, true
+		}
+	}
+	return Ban{}, false
+}
+
+// saveLocked writes the bans in force, dropping the expired ones.
+func (b *Bans) saveLocked() error {
+	now := time.Now()
+	list := make([]Ban, 0, len(b.bans))
+	for user, ban := range b.bans {
+		if !now.Before(ban.Until) {
+			delete(b.bans, user)
+			continue
+		}
+		list = append(list, ban)
+	}
+	slices.SortFunc(list, func(a, b Ban) int {
+		return strings.Compare(a.User, b.User)
+	})
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err == nil {
+		err = fileutil.WriteFileAtomic(b.path, data, 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("bans not saved: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// ErrUnknownChannel is returned for a channel the config does not have.
+var ErrUnknownChannel = errors.New("no such channel")
+
+// secretMask stands for a secret setting in the API's responses. A patch
+// that sends it back keeps the secret.
+const secretMask = "********"
+
+// ChannelSettings returns the settings of every channel of channels, by
+// their name in the config.
+func ChannelSettings(channels config.ChannelsConfig) (map[string]map[string]any, error) {
+	data, err := json.Marshal(channels)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]map[string]any
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// PatchChannel merges patch into the settings of the channel called name.
+// Objects are merged key by key; other values replace the setting.
+func PatchChannel(channels *config.ChannelsConfig, name string, patch map[string]any) error {
+	settings, err := ChannelSettings(*channels)
+	if err != nil {
+		return err
+	}
+	cur, ok := settings[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownChannel, name)
+	}
+	settings[name] = merge(cur, patch)
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	var patched config.ChannelsConfig
+	if err := json.Unmarshal(data, &patched); err != nil {
+		return fmt.Errorf("invalid settings for %s: %w", name, err)
+	}
+	*channels = patched
+	return nil
+}
+
+func merge(dst, patch map[string]any) map[string]any {
+	for k, v := range patch {
+		if sub, ok := v.(map[string]any); ok {
+			if cur, ok := dst[k].(map[string]any); ok {
+				dst[k] = merge(cur, sub)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+// isSecret reports whether a setting holds a credential.
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"token", "secret", "password", "key"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskSecrets replaces the non-empty credentials of settings with secretMask.
+func maskSecrets(settings map[string]any) {
+	for k, v := range settings {
+		switch v := v.(type) {
+		case map[string]any:
+			maskSecrets(v)
+		case string:
+			if v != "" && isSecret(k) {
+				settings[k] = secretMask
+			}
+		}
+	}
+}
+
+// dropMasked removes the settings of patch that are secretMask, so that a
+// patch made from a response keeps the secrets.
+func dropMasked(patch map[string]any) {
+	for k, v := range patch {
+		switch v := v.(type) {
+		case map[string]any:
+			dropMasked(v)
+		case string:
+			if v == secretMask {
+				delete(patch, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/auth"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/chatexport"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/search"
+)
+
+// HTTPPath is where the gateway mounts the admin API.
+const HTTPPath = "/admin"
+
+// maxRequestBytes bounds the body of a request.
+const maxRequestBytes = 64 << 10
+
+// ErrNoConfigFile is returned by the reload and channel update functions of
+// a gateway that was not started from a config file.
+var ErrNoConfigFile = errors.New("the gateway was not started from a config file")
+
+// Backend is what the API acts on.
+type Backend struct {
+	Sessions *Tracker
+	Bans     *Bans
+	// History returns the transcript of a session; an empty agentID means
+	// the default agent.
+	History chatexport.ExportFunc
+	// Clear deletes the history and summary of a session.
+	Clear func(agentID, sessionKey string) error
+	// Reload reads the config file again and applies it.
+	Reload func() error
+	// Config returns the config in use.
+	Config func() *config.Config
+	// UpdateChannel merges patch into the settings of a channel in the
+	// config file, as PatchChannel does, and reloads it.
+	UpdateChannel func(name string, patch map[string]any) error
+	// Usage serves usage reports, as the usage API does; nil without usage
+	// tracking.
+	Usage http.Handler
+	// Transcripts serves tool-call transcripts, as the transcripts API
+	// does; nil while transcripts are off.
+	Transcripts http.HandlerFunc
+	// Publish sends a message to a chat.
+	Publish func(ctx context.Context, msg bus.OutboundMessage) error
+	// Search queries the full-text index of messages; nil while
+	// session.search_index is off.
+	Search func(ctx context.Context, query string, f search.Filter) ([]search.Match, error)
+}
+
+// Handler serves the admin API.
+type Handler struct {
+	token   string
+	backend Backend
+}
+
+// NewHandler returns the API guarded by token. The API is off without a
+// token.
+func NewHandler(token string, backend Backend) *Handler {
+	return &Handler{token: token, backend: backend}
+}
+
+type sessionsResponse struct {
+	Sessions []Session `json:"sessions"`
+}
+
+type broadcastRequest struct {
+	Message string   `json:"message"`
+	Chats   []Target `json:"chats,omitempty"`
+	// Channels sends to the chats of the sessions on these channels, all
+	// channels when empty and no chats are given.
+	Channels []string `json:"channels,omitempty"`
+	// ActiveWithinHours limits those sessions to the recently active ones.
+	ActiveWithinHours int `json:"active_within_hours,omitempty"`
+}
+
+type broadcastResponse struct {
+	Sent   int      `json:"sent"`
+	Failed []Target `json:"failed,omitempty"`
+}
+
+type banRequest struct {
+	User    string `json:"user"`
+	Minutes int    `json:"minutes"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type bansResponse struct {
+	Bans []Ban `json:"bans"`
+}
+
+type searchResponse struct {
+	Matches []search.Match `json:"matches"`
+}
+
+// ServeHTTP implements the admin API:
+//
+//	GET    /admin/sessions                     sessions answered since the start
+//	GET    /admin/history?session=<key>        a session's history
+//	DELETE /admin/history?session=<key>        clear a session's history
+//	POST   /admin/reload                       reload the config file
+//	GET    /admin/channels                     channel settings, secrets masked
+//	PATCH  /admin/channels/<name>              change a channel's settings
+//	GET    /admin/usage                        usage report, as GET /usage
+//	GET    /admin/transcripts                  tool calls, as GET /transcripts
+//	GET    /admin/search?q=<query>             indexed messages matching query
+//	POST   /admin/broadcast                    send an announcement
+//	GET    /admin/bans                         bans in force
+//	POST   /admin/bans                         ban a user for some minutes
+//	DELETE /admin/bans/<channel>:<sender id>   lift a ban
+//
+// sessions takes an optional channel query parameter, and history an
+// optional agent ID in agent. search takes optional agent, session,
+// channel, chat_id, since (RFC 3339), and limit parameters. It requires "Authorization: Bearer
+// <api_token>".
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	route, arg, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, HTTPPath), "/"), "/")
+
+	switch {
+	case route == "sessions" && arg == "":
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+		list := h.backend.Sessions.List(time.Time{})
+		if channel := r.URL.Query().Get("channel"); channel != "" {
+			filtered := list[:0]
+			for _, s := range list {
+				if s.Channel == channel {
+					filtered = append(filtered, s)
+				}
+			}
+			list = filtered
+		}
+		writeJSON(w, http.StatusOK, sessionsResponse{Sessions: list})
+	case route == "history" && arg == "":
+		if !allow(w, r, http.MethodGet, http.MethodDelete) {
+			return
+		}
+		h.serveHistory(w, r)
+	case route == "reload" && arg == "":
+		if !allow(w, r, http.MethodPost) {
+			return
+		}
+		reload := h.backend.Reload
+		if reload == nil {
+			reload = func() error { return ErrNoConfigFile }
+		}
+		if err := reload(); err != nil {
+			http.Error(w, err.Error(), configStatusOf(err))
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "reloading"})
+	case route == "channels":
+		h.serveChannels(w, r, arg)
+	case route == "usage" && arg == "" && h.backend.Usage != nil:
+		h.backend.Usage.ServeHTTP(w, r)
+	case route == "transcripts" && arg == "" && h.backend.Transcripts != nil:
+		h.backend.Transcripts(w, r)
+	case route == "search" && arg == "" && h.backend.Search != nil:
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+		h.serveSearch(w, r)
+	case route == "broadcast" && arg == "":
+		if !allow(w, r, http.MethodPost) {
+			return
+		}
+		h.serveBroadcast(w, r)
+	case route == "bans":
+		h.serveBans(w, r, arg)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) serveHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	agentID, session := strings.TrimSpace(q.Get("agent")), strings.TrimSpace(q.Get("session"))
+	if session == "" {
+		http.Error(w, "the session query parameter is required", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		if err := h.backend.Clear(agentID, session); err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	t, err := h.backend.History(agentID, session)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (h *Handler) serveSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := strings.TrimSpace(q.Get("q"))
+	if query == "" {
+		http.Error(w, "the q query parameter is required", http.StatusBadRequest)
+		return
+	}
+	f := search.Filter{
+		AgentID:    q.Get("agent"),
+		SessionKey: q.Get("session"),
+		Channel:    q.Get("channel"),
+		ChatID:     q.Get("chat_id"),
+		Limit:      50,
+	}
+	if v := q.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		f.Since = since
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		f.Limit = min(n, 500)
+	}
+	matches, err := h.backend.Search(r.Context(), query, f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, searchResponse{Matches: matches})
+}
+
+func (h *Handler) serveBroadcast(w http.ResponseWriter, r *http.Request) {
+	var req broadcastRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		http.Error(w, "message is required", http.StatusBadRequest)
+		return
+	}
+	targets := req.Chats
+	if len(targets) == 0 || len(req.Channels) > 0 {
+		var since time.Time
+		if req.ActiveWithinHours > 0 {
+			since = time.Now().Add(-time.Duration(req.ActiveWithinHours) * time.Hour)
+		}
+		for _, t := range h.backend.Sessions.Chats(req.Channels, since) {
+			if !slices.Contains(targets, t) {
+				targets = append(targets, t)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		http.Error(w, "no chats to send to", http.StatusNotFound)
+		return
+	}
+
+	var resp broadcastResponse
+	for _, t := range targets {
+		err := h.backend.Publish(r.Context(), bus.OutboundMessage{
+			Channel: t.Channel,
+			ChatID:  t.ChatID,
+			Content: req.Message,
+		})
+		if err != nil {
+			resp.Failed = append(resp.Failed, t)
+			continue
+		}
+		resp.Sent++
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) serveBans(w http.ResponseWriter, r *http.Request, user string) {
+	if user != "" {
+		if !allow(w, r, http.MethodDelete) {
+			return
+		}
+		if err := h.backend.Bans.Remove(user); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrUnknownBan) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !allow(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodPost {
+		var req banRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Minutes <= 0 {
+			http.Error(w, "minutes must be positive", http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		ban := Ban{
+			User:   req.User,
+			Until:  now.Add(time.Duration(req.Minutes) * time.Minute),
+			Reason: req.Reason,
+			Since:  now,
+		}
+		if err := h.backend.Bans.Add(ban); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, bansResponse{Bans: h.backend.Bans.List(time.Now())})
+}
+
+func (h *Handler) serveChannels(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" {
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+		settings, err := ChannelSettings(h.backend.Config().Channels)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, s := range settings {
+			maskSecrets(s)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"channels": settings})
+		return
+	}
+	if !allow(w, r, http.MethodPatch) {
+		return
+	}
+	var patch map[string]any
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&patch); err != nil {
+		http.Error(w, "body must be a JSON object of settings", http.StatusBadRequest)
+		return
+	}
+	dropMasked(patch)
+	update := h.backend.UpdateChannel
+	if update == nil {
+		update = func(string, map[string]any) error { return ErrNoConfigFile }
+	}
+	if err := update(name, patch); err != nil {
+		http.Error(w, err.Error(), configStatusOf(err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "reloading"})
+}
+
+// configStatusOf maps an error of a config change to a status code.
+func configStatusOf(err error) int {
+	switch {
+	case errors.Is(err, ErrNoConfigFile):
+		return http.StatusConflict
+	case errors.Is(err, ErrUnknownChannel):
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// allow reports whether r uses one of methods, answering 405 when not.
+func allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+func statusOf(err error) int {
+	if errors.Is(err, chatexport.ErrUnknownAgent) || errors.Is(err, chatexport.ErrUnknownSession) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	return auth.BearerTokenMatches(r, h.token)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
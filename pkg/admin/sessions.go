@@ -0,0 +1,86 @@
+// Package admin serves the admin API of the gateway: the sessions the agent
+// answered, their history, config reloads, announcements, and temporary
+// bans of users.
+package admin
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Session is a conversation the agent answered since the gateway started.
+type Session struct {
+	Agent      string    `json:"agent"`
+	SessionKey string    `json:"session_key"`
+	Channel    string    `json:"channel"`
+	ChatID     string    `json:"chat_id"`
+	SenderID   string    `json:"sender_id,omitempty"`
+	LastActive time.Time `json:"last_active"`
+	Messages   int       `json:"messages"`
+}
+
+// Target is a chat to send an announcement to.
+type Target struct {
+	Channel string `json:"channel"`
+	ChatID  string `json:"chat_id"`
+}
+
+// Tracker records the sessions the agent answers. It keeps them in memory
+// only, so the list starts empty with every start of the gateway. It is
+// safe for concurrent use.
+type Tracker struct {
+	mu       sync.Mutex
+	sessions map[[2]string]*Session
+}
+
+// NewTracker returns an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{sessions: make(map[[2]string]*Session)}
+}
+
+// Touch records a message of s's session at now.
+func (t *Tracker) Touch(s Session, now time.Time) {
+	key := [2]string{s.Agent, s.SessionKey}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cur, ok := t.sessions[key]; ok {
+		s.Messages = cur.Messages
+	}
+	s.Messages++
+	s.LastActive = now
+	t.sessions[key] = &s
+}
+
+// List returns the sessions active since the given time, the most recent
+// first. A zero since returns them all.
+func (t *Tracker) List(since time.Time) []Session {
+	t.mu.Lock()
+	list := make([]Session, 0, len(t.sessions))
+	for _, s := range t.sessions {
+		if !s.LastActive.Before(since) {
+			list = append(list, *s)
+		}
+	}
+	t.mu.Unlock()
+	slices.SortFunc(list, func(a, b Session) int {
+		return b.LastActive.Compare(a.LastActive)
+	})
+	return list
+}
+
+// Chats returns the chats of the sessions active since the given time on
+// the given channels, each once; no channels means every channel.
+func (t *Tracker) Chats(channels []string, since time.Time) []Target {
+	var targets []Target
+	for _, s := range t.List(since) {
+		target := Target{Channel: s.Channel, ChatID: s.ChatID}
+		if len(channels) > 0 && !slices.Contains(channels, s.Channel) {
+			continue
+		}
+		if !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
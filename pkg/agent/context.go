@@ -43,6 +43,10 @@ type ContextBuilder struct {
 	// build time. This catches nested file creations/deletions/mtime changes
 	// that may not update the top-level skill root directory mtime.
 	skillFilesAtCache map[string]time.Time
+
+	// cachedTemplates are the bootstrap files of the cached prompt that use
+	// template actions, for RenderPromptTemplates.
+	cachedTemplates []promptTemplate
 }
 
 func (cb *ContextBuilder) WithToolDiscovery(useBM25, useRegex bool) *ContextBuilder {
@@ -160,6 +164,33 @@ The following skills extend your capabilities. To use a skill, read its SKILL.md
 	return strings.Join(parts, "\n\n---\n\n")
 }
 
+// PromptSection is one layer of the static system prompt.
+type PromptSection struct {
+	Name string
+	Text string
+}
+
+// PromptSections returns the layers BuildSystemPrompt is made of, each
+// bootstrap file and each kind of memory on its own, leaving out empty ones.
+func (cb *ContextBuilder) PromptSections() []PromptSection {
+	sections := []PromptSection{{Name: "identity and rules", Text: cb.getIdentity()}}
+	for _, filename := range bootstrapFiles {
+		if data, err := os.ReadFile(filepath.Join(cb.workspace, filename)); err == nil {
+			sections = append(sections, PromptSection{Name: filename, Text: string(data)})
+		}
+	}
+	if summary := cb.skillsLoader.BuildSkillsSummary(); summary != "" {
+		sections = append(sections, PromptSection{Name: "skills", Text: summary})
+	}
+	if longTerm := cb.memory.ReadLongTerm(); longTerm != "" {
+		sections = append(sections, PromptSection{Name: "long-term memory", Text: longTerm})
+	}
+	if notes := cb.memory.GetRecentDailyNotes(3); notes != "" {
+		sections = append(sections, PromptSection{Name: "recent daily notes", Text: notes})
+	}
+	return sections
+}
+
 // BuildSystemPromptWithCache returns the cached system prompt if available
 // and source files haven't changed, otherwise builds and caches it.
 // Source file changes are detected via mtime checks (cheap stat calls).
@@ -191,6 +222,7 @@ func (cb *ContextBuilder) BuildSystemPromptWithCache() string {
 	baseline := cb.buildCacheBaseline()
 	prompt := cb.BuildSystemPrompt()
 	cb.cachedSystemPrompt = prompt
+	cb.cachedTemplates = cb.loadPromptTemplates()
 	cb.cachedAt = baseline.maxMtime
 	cb.existedAtCache = baseline.existed
 	cb.skillFilesAtCache = baseline.skillFiles
@@ -211,6 +243,7 @@ func (cb *ContextBuilder) InvalidateCache() {
 	defer cb.systemPromptMutex.Unlock()
 
 	cb.cachedSystemPrompt = ""
+	cb.cachedTemplates = nil
 	cb.cachedAt = time.Time{}
 	cb.existedAtCache = nil
 	cb.skillFilesAtCache = nil
@@ -431,14 +464,15 @@ func skillFilesChangedSince(skillRoots []string, filesAtCache map[string]time.Ti
 	return false
 }
 
-func (cb *ContextBuilder) LoadBootstrapFiles() string {
-	bootstrapFiles := []string{
-		"AGENTS.md",
-		"SOUL.md",
-		"USER.md",
-		"IDENTITY.md",
-	}
+// bootstrapFiles are the workspace files added to the system prompt, in order.
+var bootstrapFiles = []string{
+	"AGENTS.md",
+	"SOUL.md",
+	"USER.md",
+	"IDENTITY.md",
+}
 
+func (cb *ContextBuilder) LoadBootstrapFiles() string {
 	var sb strings.Builder
 	for _, filename := range bootstrapFiles {
 		filePath := filepath.Join(cb.workspace, filename)
@@ -8,13 +8,18 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/database"
 	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/metacache"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
@@ -31,6 +36,9 @@ type AgentInstance struct {
 	Temperature               float64
 	ThinkingLevel             ThinkingLevel
 	ContextWindow             int
+	Batch                     bool // the model answers background jobs through a batch API
+	ReserveTokens             int
+	Tokenizer                 tokenizer.Tokenizer
 	SummarizeMessageThreshold int
 	SummarizeTokenPercent     int
 	Provider                  providers.LLMProvider
@@ -48,6 +56,25 @@ type AgentInstance struct {
 	// LightCandidates holds the resolved provider candidates for the light model.
 	// Pre-computed at agent creation to avoid repeated model_list lookups at runtime.
 	LightCandidates []providers.FallbackCandidate
+
+	// Canary is non-nil when a canary rollout is configured. Sessions it
+	// assigns use CanaryCandidates (when a canary model is set) and get the
+	// contents of CanaryPromptPath appended to the system prompt, and
+	// CanaryPersona when the session chose no persona.
+	Canary           *routing.Canary
+	CanaryModel      string
+	CanaryCandidates []providers.FallbackCandidate
+	CanaryPromptPath string
+	CanaryPersona    string
+
+	// ownsProvider is set when Provider was created for this agent alone.
+	ownsProvider bool
+	// trimToContext is set when the model's context window is configured,
+	// so history can be trimmed to fit it.
+	trimToContext bool
+	// modelProviders holds the providers of models conversations switched
+	// to with /model, by model_list name.
+	modelProviders sync.Map
 }
 
 // NewAgentInstance creates an agent instance from config.
@@ -71,6 +98,7 @@ func NewAgentInstance(
 	allowWritePaths := compilePatterns(cfg.Tools.AllowWritePaths)
 
 	toolsRegistry := tools.NewToolRegistry()
+	toolsRegistry.SetTimeouts(toolTimeouts(cfg))
 
 	if cfg.Tools.IsToolEnabled("read_file") {
 		maxReadFileSize := cfg.Tools.ReadFile.MaxReadFileSize
@@ -89,6 +117,18 @@ func NewAgentInstance(
 		}
 		toolsRegistry.Register(execTool)
 	}
+	if cfg.Tools.IsToolEnabled("project") {
+		if root := strings.TrimSpace(cfg.Tools.Project.Root); root != "" {
+			project := cfg.Tools.Project
+			project.Root = expandHome(root)
+			for _, tool := range tools.NewProjectTools(project, cfg.Tools.ReadFile.MaxReadFileSize) {
+				toolsRegistry.Register(tool)
+			}
+		}
+	}
+	if cfg.Tools.IsToolEnabled("sandbox") {
+		toolsRegistry.Register(tools.NewSandboxTool(workspace, cfg.Tools.Sandbox))
+	}
 
 	if cfg.Tools.IsToolEnabled("edit_file") {
 		toolsRegistry.Register(tools.NewEditFileTool(workspace, restrict, allowWritePaths))
@@ -98,7 +138,8 @@ func NewAgentInstance(
 	}
 
 	sessionsDir := filepath.Join(workspace, "sessions")
-	sessions := initSessionStore(sessionsDir)
+	// Ephemeral conversations are kept in memory in front of the real store
+	sessions := session.NewEphemeralStore(initSessionStore(cfg.Session, workspace, sessionsDir))
 
 	mcpDiscoveryActive := cfg.Tools.MCP.Enabled && cfg.Tools.MCP.Discovery.Enabled
 	contextBuilder := NewContextBuilder(workspace).WithToolDiscovery(
@@ -119,6 +160,9 @@ func NewAgentInstance(
 	}
 
 	maxIter := defaults.MaxToolIterations
+	if agentCfg != nil && agentCfg.MaxToolIterations > 0 {
+		maxIter = agentCfg.MaxToolIterations
+	}
 	if maxIter == 0 {
 		maxIter = 20
 	}
@@ -134,8 +178,22 @@ func NewAgentInstance(
 	}
 
 	var thinkingLevelStr string
+	// Without a context_window for the model, ContextWindow only sets the
+	// summarization threshold and history is not trimmed to fit it.
+	contextWindow, trimToContext := maxTokens, false
+	var tok tokenizer.Tokenizer = tokenizer.Heuristic{}
+	batch := false
 	if mc, err := cfg.GetModelConfig(model); err == nil {
 		thinkingLevelStr = mc.ThinkingLevel
+		batch = mc.Batch
+		if mc.ContextWindow > 0 {
+			contextWindow, trimToContext = mc.ContextWindow, true
+		}
+		if t, err := loadTokenizer(cfg, mc.Tokenizer); err != nil {
+			log.Printf("Warning: %v; estimating token counts instead", err)
+		} else {
+			tok = t
+		}
 	}
 	thinkingLevel := parseThinkingLevel(thinkingLevelStr)
 
@@ -215,6 +273,45 @@ func NewAgentInstance(
 		}
 	}
 
+	// Canary rollout setup: resolve the canary model once, like the light model.
+	var canary *routing.Canary
+	var canaryModel, canaryPromptPath, canaryPersona string
+	var canaryCandidates []providers.FallbackCandidate
+	if cc := defaults.Canary; cc != nil && cc.Enabled {
+		ok := true
+		if cc.ModelName != "" {
+			canaryCandidates = providers.ResolveCandidatesWithLookup(
+				providers.ModelConfig{Primary: cc.ModelName}, defaults.Provider, resolveFromModelList)
+			if len(canaryCandidates) == 0 {
+				log.Printf("canary: model_name %q not found in model_list — canary disabled for agent %q",
+					cc.ModelName, agentID)
+				ok = false
+			}
+			canaryModel = cc.ModelName
+		}
+		if cc.PromptFile != "" {
+			canaryPromptPath = cc.PromptFile
+			if !filepath.IsAbs(canaryPromptPath) {
+				canaryPromptPath = filepath.Join(workspace, canaryPromptPath)
+			}
+		}
+		if ok {
+			canaryPersona = cc.Persona
+			canary = routing.NewCanary(routing.CanaryConfig{
+				Label:    cc.Label,
+				Percent:  cc.Percent,
+				Channels: cc.Channels,
+			})
+		}
+	}
+
+	// An agent with a model of its own talks to that model's backend rather
+	// than the one shared by the rest.
+	var ownsProvider bool
+	if p, modelID, ok := agentProvider(agentCfg, defaults, cfg, workspace); ok {
+		provider, model, ownsProvider = p, modelID, true
+	}
+
 	return &AgentInstance{
 		ID:                        agentID,
 		Name:                      agentName,
@@ -225,7 +322,10 @@ func NewAgentInstance(
 		MaxTokens:                 maxTokens,
 		Temperature:               temperature,
 		ThinkingLevel:             thinkingLevel,
-		ContextWindow:             maxTokens,
+		ContextWindow:             contextWindow,
+		Batch:                     batch,
+		ReserveTokens:             defaults.ReserveTokens,
+		Tokenizer:                 tok,
 		SummarizeMessageThreshold: summarizeMessageThreshold,
 		SummarizeTokenPercent:     summarizeTokenPercent,
 		Provider:                  provider,
@@ -237,9 +337,65 @@ func NewAgentInstance(
 		Candidates:                candidates,
 		Router:                    router,
 		LightCandidates:           lightCandidates,
+		Canary:                    canary,
+		CanaryModel:               canaryModel,
+		CanaryCandidates:          canaryCandidates,
+		CanaryPromptPath:          canaryPromptPath,
+		CanaryPersona:             canaryPersona,
+		ownsProvider:              ownsProvider,
+		trimToContext:             trimToContext,
 	}
 }
 
+// agentProvider creates the provider for an agent whose primary model is a
+// model_list entry other than the default model. It returns false when the
+// agent shares the default provider.
+func agentProvider(
+	agentCfg *config.AgentConfig,
+	defaults *config.AgentDefaults,
+	cfg *config.Config,
+	workspace string,
+) (providers.LLMProvider, string, bool) {
+	if agentCfg == nil || agentCfg.Model == nil || cfg == nil {
+		return nil, "", false
+	}
+	primary := strings.TrimSpace(agentCfg.Model.Primary)
+	if primary == "" || primary == defaults.GetModelName() {
+		return nil, "", false
+	}
+	if len(cfg.GetModelConfigs(primary)) == 0 {
+		return nil, "", false
+	}
+	provider, modelID, err := providers.CreateProviderForModel(cfg, primary, workspace)
+	if err != nil {
+		log.Printf("agent %q: cannot create a provider for model %q (%v) — using the default provider",
+			agentCfg.ID, primary, err)
+		return nil, "", false
+	}
+	return provider, modelID, true
+}
+
+// toolTimeouts converts the tool timeouts of cfg for ToolRegistry.SetTimeouts.
+func toolTimeouts(cfg *config.Config) (time.Duration, map[string]time.Duration) {
+	perTool := make(map[string]time.Duration, len(cfg.Tools.Timeouts))
+	for name, secs := range cfg.Tools.Timeouts {
+		perTool[name] = time.Duration(secs) * time.Second
+	}
+	return time.Duration(cfg.Tools.TimeoutSeconds) * time.Second, perTool
+}
+
+// AgentWorkspace returns the workspace of the configured agent agentID, as
+// the gateway resolves it. Unknown IDs resolve like an unconfigured agent.
+func AgentWorkspace(cfg *config.Config, agentID string) string {
+	id := routing.NormalizeAgentID(agentID)
+	for i := range cfg.Agents.List {
+		if routing.NormalizeAgentID(cfg.Agents.List[i].ID) == id {
+			return resolveAgentWorkspace(&cfg.Agents.List[i], &cfg.Agents.Defaults)
+		}
+	}
+	return resolveAgentWorkspace(&config.AgentConfig{ID: id}, &cfg.Agents.Defaults)
+}
+
 // resolveAgentWorkspace determines the workspace directory for an agent.
 func resolveAgentWorkspace(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) string {
 	if agentCfg != nil && strings.TrimSpace(agentCfg.Workspace) != "" {
@@ -307,6 +463,15 @@ func mediaTempDirPattern() string {
 
 // Close releases resources held by the agent's session store.
 func (a *AgentInstance) Close() error {
+	if stateful, ok := a.Provider.(providers.StatefulProvider); ok && a.ownsProvider {
+		stateful.Close()
+	}
+	a.modelProviders.Range(func(_, v any) bool {
+		if stateful, ok := v.(modelProvider).provider.(providers.StatefulProvider); ok {
+			stateful.Close()
+		}
+		return true
+	})
 	if a.Sessions != nil {
 		return a.Sessions.Close()
 	}
@@ -317,20 +482,57 @@ func (a *AgentInstance) Close() error {
 // It uses the JSONL store by default and auto-migrates legacy JSON sessions.
 // Falls back to SessionManager if the JSONL store cannot be initialized or
 // if migration fails (which indicates the store cannot write reliably).
-func initSessionStore(dir string) session.SessionStore {
-	store, err := memory.NewJSONLStore(dir)
+// With store "sqlite" it uses the workspace database instead, importing the
+// JSONL sessions too, and with "redis" the Redis server, whose sessions are
+// shared and so are not overwritten by local ones. Either falls back to
+// JSONL if it cannot be opened.
+//
+// With encryption or retention configured the store is wrapped in a
+// memory.SealedStore. Encrypted sessions never fall back to the plaintext
+// JSON files: without the key, or without a working store, they are kept in
+// memory only.
+func initSessionStore(cfg config.SessionConfig, workspace, dir string) session.SessionStore {
+	seal, err := sessionSealer(cfg)
 	if err != nil {
-		log.Printf("memory: init store: %v; using json sessions", err)
+		log.Printf("%v; keeping sessions in memory only", err)
+		return session.NewSessionManager("")
+	}
+	fallback := func() session.SessionStore {
+		if cfg.Encryption.Enabled {
+			return session.NewSessionManager("")
+		}
 		return session.NewSessionManager(dir)
 	}
 
+	switch cfg.Store {
+	case config.SessionStoreSQLite:
+		if backend, ok := initSQLiteSessionStore(workspace, dir, seal); ok {
+			return backend
+		}
+	case config.SessionStoreRedis:
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		store, err := memory.NewRedisStore(ctx, cfg.RedisURL, time.Duration(cfg.RedisTTLDays)*24*time.Hour)
+		cancel()
+		if err == nil {
+			return session.NewJSONLBackend(seal(store))
+		}
+		log.Printf("memory: init redis store: %v; using jsonl sessions", err)
+	}
+
+	jsonl, err := memory.NewJSONLStore(dir)
+	if err != nil {
+		log.Printf("memory: init store: %v; using json sessions", err)
+		return fallback()
+	}
+	store := seal(jsonl)
+
 	if n, merr := memory.MigrateFromJSON(context.Background(), dir, store); merr != nil {
 		// Migration failure means the store could not write data.
 		// Fall back to SessionManager to avoid a split state where
 		// some sessions are in JSONL and others remain in JSON.
 		log.Printf("memory: migration failed: %v; falling back to json sessions", merr)
 		store.Close()
-		return session.NewSessionManager(dir)
+		return fallback()
 	} else if n > 0 {
 		log.Printf("memory: migrated %d session(s) to jsonl", n)
 	}
@@ -338,6 +540,96 @@ func initSessionStore(dir string) session.SessionStore {
 	return session.NewJSONLBackend(store)
 }
 
+// sessionSealer returns the wrapper of the session store that cfg's
+// encryption and retention ask for, which is a no-op without either.
+func sessionSealer(cfg config.SessionConfig) (func(memory.Store) memory.Store, error) {
+	if !cfg.Encryption.Enabled && !cfg.Retention.Enabled() {
+		return func(store memory.Store) memory.Store { return store }, nil
+	}
+	key, err := sessionEncryptionKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var retain memory.Retention
+	if cfg.Retention.Enabled() {
+		retain = func(sessionKey string) time.Duration {
+			return cfg.Retention.MaxAge(routing.SessionChannel(sessionKey))
+		}
+	}
+	if _, err := memory.NewSealedStore(nil, key, retain); err != nil {
+		return nil, err
+	}
+	return func(store memory.Store) memory.Store {
+		sealed, _ := memory.NewSealedStore(store, key, retain)
+		return sealed
+	}, nil
+}
+
+// sessionEncryptionKey loads the key that encrypts stored conversations, or
+// returns nil when encryption is off.
+func sessionEncryptionKey(cfg config.SessionConfig) ([]byte, error) {
+	if !cfg.Encryption.Enabled {
+		return nil, nil
+	}
+	env := cfg.Encryption.KeyEnv
+	if env == "" {
+		env = "PICOCLAW_SESSION_KEY"
+	}
+	return memory.LoadKey(env, cfg.Encryption.KeyCommand)
+}
+
+func initSQLiteSessionStore(
+	workspace, dir string, seal func(memory.Store) memory.Store,
+) (session.SessionStore, bool) {
+	ctx := context.Background()
+	sqlite, err := memory.NewSQLiteStore(ctx, database.Path(workspace))
+	if err != nil {
+		log.Printf("memory: init sqlite store: %v; using jsonl sessions", err)
+		return nil, false
+	}
+	store := seal(sqlite)
+	// Legacy JSON sessions first, then the JSONL ones, which are newer.
+	migrations := []struct {
+		name    string
+		migrate func(context.Context, string, memory.Store) (int, error)
+	}{
+		{"json", memory.MigrateFromJSON},
+		{"jsonl", memory.MigrateFromJSONL},
+	}
+	for _, m := range migrations {
+		n, merr := m.migrate(ctx, dir, store)
+		if merr != nil {
+			log.Printf("memory: %s migration failed: %v; using jsonl sessions", m.name, merr)
+			store.Close()
+			return nil, false
+		}
+		if n > 0 {
+			log.Printf("memory: migrated %d %s session(s) to sqlite", n, m.name)
+		}
+	}
+	return session.NewJSONLBackend(store), true
+}
+
+// tokenizerDownloadTimeout bounds fetching a tokenizer file given by URL.
+const tokenizerDownloadTimeout = 30 * time.Second
+
+// loadTokenizer loads the tokenizer of a model_list entry. A tokenizer given
+// by URL is downloaded into the provider cache, which falls back to the last
+// download while the URL cannot be reached.
+func loadTokenizer(cfg *config.Config, spec string) (tokenizer.Tokenizer, error) {
+	if !strings.HasPrefix(spec, "https://") && !strings.HasPrefix(spec, "http://") {
+		return tokenizer.Load(expandHome(spec))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tokenizerDownloadTimeout)
+	defer cancel()
+	cache := metacache.New(metacache.Dir(cfg.WorkspacePath()), cfg.ProviderCache.TTL())
+	path, err := cache.Path(ctx, "tokenizer:"+spec, metacache.Download(spec))
+	if err != nil {
+		return nil, fmt.Errorf("loading tokenizer %s: %w", spec, err)
+	}
+	return tokenizer.Load(path)
+}
+
 func expandHome(path string) string {
 	if path == "" {
 		return path
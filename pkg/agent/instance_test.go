@@ -43,6 +43,23 @@ func TestNewAgentInstance_UsesDefaultsTemperatureAndMaxTokens(t *testing.T) {
 	}
 }
 
+func TestNewAgentInstance_AgentOverridesMaxToolIterations(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{Workspace: t.TempDir(), Model: "test-model", MaxToolIterations: 5},
+		},
+	}
+	agent := NewAgentInstance(&config.AgentConfig{ID: "coder", MaxToolIterations: 40},
+		&cfg.Agents.Defaults, cfg, &mockProvider{})
+	if agent.MaxIterations != 40 {
+		t.Errorf("MaxIterations = %d, want 40", agent.MaxIterations)
+	}
+	agent = NewAgentInstance(nil, &cfg.Agents.Defaults, cfg, &mockProvider{})
+	if agent.MaxIterations != 5 {
+		t.Errorf("default MaxIterations = %d, want 5", agent.MaxIterations)
+	}
+}
+
 func TestNewAgentInstance_DefaultsTemperatureWhenZero(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "agent-instance-test-*")
 	if err != nil {
@@ -165,6 +182,41 @@ func TestNewAgentInstance_ResolveCandidatesFromModelListAlias(t *testing.T) {
 	}
 }
 
+func TestNewAgentInstance_OwnModelGetsItsOwnProvider(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace: tmpDir,
+				Model:     "gpt-4o",
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "gpt-4o", Model: "openai/gpt-4o", APIKey: "sk-test"},
+			{ModelName: "claude", Model: "anthropic-messages/claude-sonnet-4-6", APIKey: "sk-ant-test"},
+		},
+	}
+
+	shared := &mockProvider{}
+	main := NewAgentInstance(&config.AgentConfig{ID: "main", Default: true}, &cfg.Agents.Defaults, cfg, shared)
+	if main.Provider != shared || main.Model != "gpt-4o" {
+		t.Fatalf("default agent = %T %q, want the shared provider", main.Provider, main.Model)
+	}
+
+	agentCfg := &config.AgentConfig{
+		ID:        "claude",
+		Workspace: filepath.Join(tmpDir, "claude"),
+		Model:     &config.AgentModelConfig{Primary: "claude"},
+	}
+	claude := NewAgentInstance(agentCfg, &cfg.Agents.Defaults, cfg, shared)
+	if claude.Provider == shared {
+		t.Fatal("agent with its own model uses the shared provider")
+	}
+	if claude.Model != "claude-sonnet-4-6" {
+		t.Fatalf("Model = %q, want the model ID of the entry", claude.Model)
+	}
+}
+
 func TestNewAgentInstance_AllowsMediaTempDirForReadListAndExec(t *testing.T) {
 	workspace := t.TempDir()
 	mediaDir := media.TempDir()
@@ -246,3 +298,30 @@ func TestNewAgentInstance_AllowsMediaTempDirForReadListAndExec(t *testing.T) {
 		t.Fatalf("exec output missing media content: %s", execResult.ForLLM)
 	}
 }
+
+func TestInitSessionStore_Encryption(t *testing.T) {
+	workspace := t.TempDir()
+	dir := filepath.Join(workspace, "sessions")
+	cfg := config.SessionConfig{
+		Encryption: config.SessionEncryptionConfig{Enabled: true, KeyEnv: "TEST_PICOCLAW_SESSION_KEY"},
+	}
+
+	// Without the key nothing is written to disk.
+	store := initSessionStore(cfg, workspace, dir)
+	store.AddMessage("agent:main:main", "user", "secret plans")
+	store.Save("agent:main:main")
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("sessions written without a key: %v", entries)
+	}
+
+	t.Setenv("TEST_PICOCLAW_SESSION_KEY", "BwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwc=")
+	store = initSessionStore(cfg, workspace, dir)
+	store.AddMessage("agent:main:main", "user", "secret plans")
+	data, err := os.ReadFile(filepath.Join(dir, "agent_main_main.jsonl"))
+	if err != nil || strings.Contains(string(data), "secret plans") {
+		t.Errorf("session file = %q, %v", data, err)
+	}
+	if history := store.GetHistory("agent:main:main"); len(history) != 1 || history[0].Content != "secret plans" {
+		t.Errorf("history = %+v", history)
+	}
+}
@@ -11,26 +11,59 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
-	"unicode/utf8"
 
+	"github.com/sipeed/picoclaw/pkg/admin"
+	"github.com/sipeed/picoclaw/pkg/alert"
+	"github.com/sipeed/picoclaw/pkg/analytics"
+	"github.com/sipeed/picoclaw/pkg/branch"
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/calendar"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/commands"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/dispatch"
+	"github.com/sipeed/picoclaw/pkg/facts"
+	"github.com/sipeed/picoclaw/pkg/feedback"
+	"github.com/sipeed/picoclaw/pkg/flagged"
+	"github.com/sipeed/picoclaw/pkg/guardrail"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/knowledge"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/maintenance"
 	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/metacache"
+	"github.com/sipeed/picoclaw/pkg/middleware"
+	"github.com/sipeed/picoclaw/pkg/offline"
+	"github.com/sipeed/picoclaw/pkg/plugin"
+	"github.com/sipeed/picoclaw/pkg/prefs"
+	"github.com/sipeed/picoclaw/pkg/promptlib"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/ratelimit"
+	"github.com/sipeed/picoclaw/pkg/recall"
+	"github.com/sipeed/picoclaw/pkg/requestid"
+	"github.com/sipeed/picoclaw/pkg/respcache"
 	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/search"
+	"github.com/sipeed/picoclaw/pkg/shadow"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/state"
+	"github.com/sipeed/picoclaw/pkg/structured"
+	"github.com/sipeed/picoclaw/pkg/tenant"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/tracing"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+	"github.com/sipeed/picoclaw/pkg/translate"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+	"github.com/sipeed/picoclaw/pkg/usage"
 	"github.com/sipeed/picoclaw/pkg/utils"
 	"github.com/sipeed/picoclaw/pkg/voice"
 )
@@ -46,8 +79,49 @@ type AgentLoop struct {
 	channelManager *channels.Manager
 	mediaStore     media.MediaStore
 	transcriber    voice.Transcriber
+	speech         voice.Synthesizer
 	cmdRegistry    *commands.Registry
 	mcp            mcpRuntime
+	pluginsOnce    sync.Once
+	plugins        *plugin.Host
+	extraTools     []tools.Tool // added with AddTool, registered again on reload
+	middleware     *middleware.Chain
+	usage          *usage.Tracker
+	alerts         *alert.Monitor
+	maintenance    *maintenance.Controller
+	calendarLinker *calendar.Linker
+	cronService    *cron.CronService
+	approvals      *tools.ApprovalQueue
+	translations   *translate.Store
+	prefs          *prefs.Store
+	branches       *branch.Store
+	facts          *facts.Store
+	promptLib      *promptlib.Store
+	transcripts    *transcript.Store
+	feedback       *feedback.Store
+	prompts        *turnPrompts
+	tuning         *tuning.Store
+	offline        *offline.Queue
+	analytics      *analytics.Store
+	searchIndex    *search.Index
+	searchExpired  atomic.Int64 // day of the last expiry of the search index
+	tenants        *tenant.Store
+	tenantUsage    *tenant.Meter
+	guard          *guardrail.Guard
+	flagged        *flagged.Store
+	moderationLog  *guardrail.Log
+	shadowLog      *shadow.Log
+	recall         *recall.Store
+	limits         *ratelimit.Limiter
+	responses      *respcache.Cache
+	providerCache  *metacache.Cache // model lists; nil when provider_cache is off
+	activity       *admin.Tracker
+	bans           *admin.Bans
+	dispatcher     atomic.Pointer[dispatch.Dispatcher]
+	turnTimes      turnDurations
+	turns          sync.Map // turnKey -> *activeTurn
+	editedPrompts  sync.Map // turnKey:messageID of edits that cancelled their turn
+	checkpointMu   sync.Mutex
 	mu             sync.RWMutex
 	// Track active requests for safe provider cleanup
 	activeRequests sync.WaitGroup
@@ -58,6 +132,7 @@ type processOptions struct {
 	SessionKey        string   // Session identifier for history/context
 	Channel           string   // Target channel for tool execution
 	ChatID            string   // Target chat ID for tool execution
+	GuildID           string   // Guild or workspace the message came from, for per-guild tool settings
 	SenderID          string   // Current sender ID for dynamic context
 	SenderDisplayName string   // Current sender display name for dynamic context
 	UserMessage       string   // User message content (may include prefix)
@@ -66,6 +141,24 @@ type processOptions struct {
 	EnableSummary     bool     // Whether to trigger summarization
 	SendResponse      bool     // Whether to send response via bus
 	NoHistory         bool     // If true, don't load session history (for heartbeat)
+	Variant           string   // Rollout variant for this turn; empty means stable
+	VariantPersona    string   // Persona of the rollout variant, for sessions that picked none
+	RequestID         string   // Correlation ID for logs, provider calls, and usage records
+	MessageID         string   // Platform ID of the user message, recorded for /branch
+	Ephemeral         bool     // Keep the session in memory only; no transcript or branch marks
+	NoCache           bool     // Ask the LLM even when the response cache has an answer
+	PromptTemplate    string   // Prompt library template UserMessage was rendered from with /prompt
+	Locale            string   // i18n code of the language of the bot's own messages; "" is English
+	// JSON schema the reply must conform to, for callers that read it as data
+	Schema *structured.Schema
+	// Few-shot exchanges of the template, sent before the user message but never saved
+	Examples []providers.Message
+	// Identity of the sender, matched against the users of tool permission rules
+	Sender bus.SenderInfo
+	// Keeps the chat showing the reply is on its way; set for the LLM loop
+	heartbeat *turnHeartbeat
+	// Collects the tool calls of a turn answered in shadow mode, which skips them
+	shadow *shadowTurn
 }
 
 const (
@@ -76,6 +169,8 @@ const (
 	metadataKeyTeamID         = "team_id"
 	metadataKeyParentPeerKind = "parent_peer_kind"
 	metadataKeyParentPeerID   = "parent_peer_id"
+	metadataKeyEdited         = "edited"
+	metadataKeyRoles          = "roles"
 )
 
 func NewAgentLoop(
@@ -85,8 +180,11 @@ func NewAgentLoop(
 ) *AgentLoop {
 	registry := NewAgentRegistry(cfg, provider)
 
+	// Tool actions held for /approve post their prompt straight to the chat
+	approvals := tools.NewApprovalQueue()
+
 	// Register shared tools to all agents
-	registerSharedTools(cfg, msgBus, registry, provider)
+	registerSharedTools(cfg, msgBus, registry, provider, approvals)
 
 	// Set up shared fallback chain
 	cooldown := providers.NewCooldownTracker()
@@ -95,32 +193,112 @@ func NewAgentLoop(
 	// Create state manager using default agent's workspace for channel recording
 	defaultAgent := registry.GetDefaultAgent()
 	var stateManager *state.Manager
+	var usageTracker *usage.Tracker
+	var translations *translate.Store
+	var userPrefs *prefs.Store
+	var branches *branch.Store
+	var guildFacts *facts.Store
+	var promptLibrary *promptlib.Store
+	var transcripts *transcript.Store
+	var ratings *feedback.Store
+	var generation *tuning.Store
+	var offlineQueue *offline.Queue
 	if defaultAgent != nil {
 		stateManager = state.NewManager(defaultAgent.Workspace)
+		usageTracker = usage.NewTracker(usage.Path(defaultAgent.Workspace))
+		translations = translate.NewStore(translate.Path(defaultAgent.Workspace))
+		userPrefs = prefs.NewStore(prefs.Path(defaultAgent.Workspace))
+		branches = branch.NewStore(branch.Dir(defaultAgent.Workspace))
+		guildFacts = facts.NewStore(facts.Path(defaultAgent.Workspace))
+		promptLibrary = promptlib.NewStore(promptlib.Path(defaultAgent.Workspace))
+		if cfg.Transcripts.Enabled {
+			transcripts = transcript.NewStore(transcript.Dir(defaultAgent.Workspace), cfg.Transcripts)
+		}
+		if cfg.Feedback.Enabled {
+			ratings = feedback.NewStore(feedback.Path(defaultAgent.Workspace))
+		}
+		generation = tuning.NewStore(tuning.Path(defaultAgent.Workspace))
+		offlineQueue = offline.NewQueue(cfg.OfflineQueue, offline.Path(defaultAgent.Workspace))
+		offlineQueue.SetBus(msgBus)
 	}
 
 	al := &AgentLoop{
-		bus:         msgBus,
-		cfg:         cfg,
-		registry:    registry,
-		state:       stateManager,
-		summarizing: sync.Map{},
-		fallback:    fallbackChain,
-		cmdRegistry: commands.NewRegistry(commands.BuiltinDefinitions()),
+		bus:          msgBus,
+		cfg:          cfg,
+		registry:     registry,
+		state:        stateManager,
+		summarizing:  sync.Map{},
+		fallback:     fallbackChain,
+		cmdRegistry:  commands.NewRegistry(commands.BuiltinDefinitions()),
+		usage:        usageTracker,
+		alerts:       newAlertMonitor(cfg, usageTracker),
+		approvals:    approvals,
+		translations: translations,
+		prefs:        userPrefs,
+		branches:     branches,
+		facts:        guildFacts,
+		promptLib:    promptLibrary,
+		transcripts:  transcripts,
+		feedback:     ratings,
+		prompts:      newTurnPrompts(),
+		tuning:       generation,
+		offline:      offlineQueue,
+		guard:        newGuard(cfg.Guardrails),
+		limits:       newRateLimiter(cfg.RateLimits, usageTracker),
+		middleware:   middleware.NewChain(cfg.Middleware),
+		activity:     admin.NewTracker(),
+	}
+	approvals.SetPromptCallback(al.approvalPrompt)
+	if defaultAgent != nil {
+		al.analytics = openAnalytics(cfg.Analytics, defaultAgent.Workspace)
+		al.searchIndex = openSearchIndex(cfg.Session, defaultAgent.Workspace)
+		al.tenants, al.tenantUsage = openTenants(cfg, defaultAgent.Workspace, usageTracker)
+		if al.guard != nil {
+			al.flagged = flagged.NewStore(flagged.Path(defaultAgent.Workspace))
+			if cfg.Guardrails.Log {
+				al.moderationLog = guardrail.NewLog(guardrail.LogPath(defaultAgent.Workspace))
+			}
+		}
+		al.recall = openRecall(cfg, defaultAgent.Workspace)
+		loadLocales(defaultAgent.Workspace)
+		al.responses = respcache.New(cfg.ResponseCache, respcache.Path(defaultAgent.Workspace))
+		if cfg.ProviderCache.Enabled {
+			al.providerCache = metacache.New(metacache.Dir(cfg.WorkspacePath()), cfg.ProviderCache.TTL())
+		}
+		al.bans = admin.NewBans(admin.BansPath(defaultAgent.Workspace))
+		al.shadowLog = shadow.NewLog(shadow.LogPath(defaultAgent.Workspace))
 	}
+	al.registerDelegateTools(cfg, registry)
 
 	return al
 }
 
 // registerSharedTools registers tools that are shared across all agents (web, message, spawn).
+// maxSendFileSize is the largest file send_file accepts. With object storage
+// enabled, files over a channel's upload limit are sent as download links, so
+// the cap rises to the largest object allowed.
+func maxSendFileSize(cfg *config.Config) int {
+	size := cfg.Agents.Defaults.GetMaxMediaSize()
+	if cfg.ObjectStorage.Enabled && cfg.ObjectStorage.MaxObjectSize > int64(size) {
+		size = int(min(cfg.ObjectStorage.MaxObjectSize, math.MaxInt))
+	}
+	return size
+}
+
 func registerSharedTools(
 	cfg *config.Config,
 	msgBus *bus.MessageBus,
 	registry *AgentRegistry,
 	provider providers.LLMProvider,
+	approvals *tools.ApprovalQueue,
 ) {
 	allowReadPaths := buildAllowReadPatterns(cfg)
 
+	var knowledgeIndex *knowledge.Index
+	if cfg.Knowledge.Enabled {
+		knowledgeIndex = knowledge.NewIndex(knowledge.Path(cfg.WorkspacePath()))
+	}
+
 	for _, agentID := range registry.ListAgentIDs() {
 		agent, ok := registry.GetAgent(agentID)
 		if !ok {
@@ -153,6 +331,7 @@ func registerSharedTools(
 				GLMSearchMaxResults:  cfg.Tools.Web.GLMSearch.MaxResults,
 				GLMSearchEnabled:     cfg.Tools.Web.GLMSearch.Enabled,
 				Proxy:                cfg.Tools.Web.Proxy,
+				Provider:             cfg.Tools.Web.Provider,
 			})
 			if err != nil {
 				logger.ErrorCF("agent", "Failed to create web search tool", map[string]any{"error": err.Error()})
@@ -174,6 +353,50 @@ func registerSharedTools(
 			}
 		}
 
+		// Issue tracker tool (Jira/Linear), credentials resolved per guild at call time
+		if cfg.Tools.IsToolEnabled("issues") {
+			agent.Tools.Register(tools.NewIssuesTool(cfg.Tools.Issues))
+		}
+		if cfg.Tools.IsToolEnabled("github") {
+			agent.Tools.Register(tools.NewGitHubTool(cfg.Tools.GitHub))
+		}
+
+		// Calendar tool; the Google account linker is injected later by SetCalendarLinker
+		if cfg.Tools.IsToolEnabled("calendar") {
+			agent.Tools.Register(tools.NewCalendarTool(cfg.Tools.Calendar))
+		}
+
+		// Home Assistant; service calls wait for /approve
+		if cfg.Tools.IsToolEnabled("home_assistant") {
+			agent.Tools.Register(tools.NewHomeAssistantTool(cfg.Tools.HomeAssistant, approvals))
+		}
+
+		// Utility bundle; each can be limited to some channels
+		if cfg.Tools.IsToolEnabled("weather") {
+			agent.Tools.Register(tools.NewWeatherTool(cfg.Tools.Weather))
+		}
+		if cfg.Tools.IsToolEnabled("time") {
+			agent.Tools.Register(tools.NewTimeTool(cfg.Tools.Time))
+		}
+		if cfg.Tools.IsToolEnabled("currency") {
+			agent.Tools.Register(tools.NewCurrencyTool(cfg.Tools.Currency))
+		}
+
+		// Image generation; the image is sent through the media store
+		if cfg.Tools.IsToolEnabled("generate_image") {
+			imageTool, err := tools.NewImageGenTool(cfg.Tools.ImageGen, newGuard(cfg.Guardrails))
+			if err != nil {
+				logger.ErrorCF("agent", "Failed to create image generation tool", map[string]any{"error": err.Error()})
+			} else {
+				agent.Tools.Register(imageTool)
+			}
+		}
+
+		// Notion/Confluence pages synced by the gateway's knowledge sync
+		if knowledgeIndex != nil {
+			agent.Tools.Register(tools.NewKnowledgeSearchTool(knowledgeIndex))
+		}
+
 		// Hardware tools (I2C, SPI) - Linux only, returns error on other platforms
 		if cfg.Tools.IsToolEnabled("i2c") {
 			agent.Tools.Register(tools.NewI2CTool())
@@ -202,7 +425,7 @@ func registerSharedTools(
 			sendFileTool := tools.NewSendFileTool(
 				agent.Workspace,
 				cfg.Agents.Defaults.RestrictToWorkspace,
-				cfg.Agents.Defaults.GetMaxMediaSize(),
+				maxSendFileSize(cfg),
 				nil,
 				allowReadPaths,
 			)
@@ -262,6 +485,13 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 	if err := al.ensureMCPInitialized(ctx); err != nil {
 		return err
 	}
+	al.StartPlugins(ctx)
+	al.offline.Start()
+	defer al.offline.Stop()
+
+	d := al.startDispatcher(ctx)
+	defer al.drainInbound()
+	al.resumeInterrupted()
 
 	for al.running.Load() {
 		select {
@@ -285,45 +515,24 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 			// 	}
 			// }()
 
-			response, err := al.processMessage(ctx, msg)
-			if err != nil {
-				response = fmt.Sprintf("Error processing message: %v", err)
+			if !al.accessAllowed(msg) || al.banned(msg) {
+				continue
 			}
-
-			if response != "" {
-				// Check if the message tool already sent a response during this round.
-				// If so, skip publishing to avoid duplicate messages to the user.
-				// Use default agent's tools to check (message tool is shared).
-				alreadySent := false
-				defaultAgent := al.GetRegistry().GetDefaultAgent()
-				if defaultAgent != nil {
-					if tool, ok := defaultAgent.Tools.Get("message"); ok {
-						if mt, ok := tool.(*tools.MessageTool); ok {
-							alreadySent = mt.HasSentInRound()
-						}
-					}
-				}
-
-				if !alreadySent {
+			if reply, blocked := al.maintenanceGate(msg); blocked {
+				if reply != "" {
 					al.bus.PublishOutbound(ctx, bus.OutboundMessage{
 						Channel: msg.Channel,
 						ChatID:  msg.ChatID,
-						Content: response,
+						Content: reply,
 					})
-					logger.InfoCF("agent", "Published outbound response",
-						map[string]any{
-							"channel":     msg.Channel,
-							"chat_id":     msg.ChatID,
-							"content_len": len(response),
-						})
-				} else {
-					logger.DebugCF(
-						"agent",
-						"Skipped outbound (message tool already sent)",
-						map[string]any{"channel": msg.Channel},
-					)
 				}
+				continue
 			}
+
+			// An edit cuts the reply to the old text short before it waits
+			// behind it
+			al.stopEditedPrompt(msg)
+			al.submitInbound(ctx, d, msg)
 		default:
 			time.Sleep(time.Microsecond * 200)
 		}
@@ -332,8 +541,103 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 	return nil
 }
 
+// handleInbound answers one inbound message and publishes the reply. It
+// runs on a dispatcher worker, at the same time as the messages of other
+// conversations.
+func (al *AgentLoop) handleInbound(ctx context.Context, msg bus.InboundMessage) {
+	// The trace of the message covers its answer and, through the
+	// bus, the delivery of the reply.
+	turnCtx, span := tracing.Start(ctx, "message "+msg.Channel, tracing.KindServer, map[string]any{
+		"channel":    msg.Channel,
+		"chat_id":    msg.ChatID,
+		"request_id": msg.RequestID,
+	})
+	turnCtx, endTurn := al.beginTurn(turnCtx, msg)
+	turnCtx, stats := analytics.NewContext(turnCtx)
+	response, err := al.processMessage(turnCtx, msg)
+	span.RecordError(err)
+	stopped := stoppedTurn(turnCtx) || promptDeleted(turnCtx) || promptEdited(turnCtx)
+	endTurn()
+	if (err != nil || response == "") && shutDown(turnCtx) {
+		// The agent stopped before the turn could finish: answer it after
+		// the restart instead
+		al.checkpointInterrupted(msg)
+		span.End()
+		return
+	}
+	notice, held := al.holdOffline(msg, err)
+	al.recordAnalytics(stats, msg, turnOutcome(err, stopped, held))
+	if held {
+		response, err = notice, nil
+	} else {
+		if offline.Replayed(msg) {
+			// The retried message got through one way or another
+			al.offline.Resume()
+		}
+		if err != nil {
+			response = fmt.Sprintf("Error processing message: %v", err)
+			if msg.RequestID != "" {
+				response += fmt.Sprintf(" (request %s)", msg.RequestID)
+			}
+		}
+	}
+
+	var turnID string
+	if err == nil {
+		turnID = al.rememberTurn(msg, response)
+	}
+
+	if response != "" {
+		// Check if the message tool already sent a response during this round.
+		// If so, skip publishing to avoid duplicate messages to the user.
+		// Use default agent's tools to check (message tool is shared).
+		alreadySent := false
+		defaultAgent := al.GetRegistry().GetDefaultAgent()
+		if defaultAgent != nil {
+			if tool, ok := defaultAgent.Tools.Get("message"); ok {
+				if mt, ok := tool.(*tools.MessageTool); ok {
+					alreadySent = mt.HasSentInRound(msg.Channel, msg.ChatID)
+				}
+			}
+		}
+
+		if !alreadySent {
+			al.bus.PublishOutbound(ctx, bus.OutboundMessage{
+				Channel:      msg.Channel,
+				ChatID:       msg.ChatID,
+				Content:      response,
+				ReplaceReply: isEdited(msg) || variantStep(msg) != 0,
+				TurnID:       turnID,
+				RequestID:    msg.RequestID,
+				TraceParent:  tracing.TraceParent(turnCtx),
+				Mute:         al.speechMuted(msg),
+				SenderID:     msg.SenderID,
+				Locale:       al.uiLanguage(msg),
+			})
+			al.attachSpeech(ctx, msg, turnID, response)
+			logger.InfoCF("agent", "Published outbound response",
+				map[string]any{
+					"channel":     msg.Channel,
+					"chat_id":     msg.ChatID,
+					"request_id":  msg.RequestID,
+					"content_len": len(response),
+				})
+		} else {
+			logger.DebugCF(
+				"agent",
+				"Skipped outbound (message tool already sent)",
+				map[string]any{"channel": msg.Channel},
+			)
+		}
+	}
+	span.End()
+}
+
+// Stop stops taking inbound messages and waits for the queued ones to be
+// answered, up to the drain timeout.
 func (al *AgentLoop) Stop() {
 	al.running.Store(false)
+	al.drainInbound()
 }
 
 // Close releases resources held by agent session stores. Call after Stop.
@@ -349,7 +653,22 @@ func (al *AgentLoop) Close() {
 		}
 	}
 
+	al.mu.RLock()
+	plugins := al.plugins
+	al.mu.RUnlock()
+	plugins.Close()
+
 	al.GetRegistry().Close()
+
+	if err := al.analytics.Close(); err != nil {
+		logger.WarnCF("agent", "Failed to close analytics database", map[string]any{"error": err.Error()})
+	}
+	if err := al.recall.Close(); err != nil {
+		logger.WarnCF("agent", "Failed to close recall database", map[string]any{"error": err.Error()})
+	}
+	if err := al.searchIndex.Close(); err != nil {
+		logger.WarnCF("agent", "Failed to close search index", map[string]any{"error": err.Error()})
+	}
 }
 
 func (al *AgentLoop) RegisterTool(tool tools.Tool) {
@@ -361,8 +680,46 @@ func (al *AgentLoop) RegisterTool(tool tools.Tool) {
 	}
 }
 
+// Approvals returns the queue that holds tool actions until /approve, for
+// tools registered with RegisterTool that need confirmation.
+func (al *AgentLoop) Approvals() *tools.ApprovalQueue {
+	return al.approvals
+}
+
+// AddTool registers tool with every agent, replacing a tool of the same
+// name, and again with the agents of every config reload.
+func (al *AgentLoop) AddTool(tool tools.Tool) {
+	al.mu.Lock()
+	al.extraTools = append(al.extraTools, tool)
+	registry := al.registry
+	al.mu.Unlock()
+	registerExtraTools(registry, []tools.Tool{tool})
+}
+
+func registerExtraTools(registry *AgentRegistry, extra []tools.Tool) {
+	for _, agentID := range registry.ListAgentIDs() {
+		if agent, ok := registry.GetAgent(agentID); ok {
+			for _, tool := range extra {
+				agent.Tools.Register(tool)
+			}
+		}
+	}
+}
+
 func (al *AgentLoop) SetChannelManager(cm *channels.Manager) {
 	al.channelManager = cm
+	if cm != nil {
+		cm.SetTurnStopper(al.StopTurn)
+		cm.SetPromptStopper(al.StopPrompt)
+		cm.SetRegenerator(al.regenerateTurn)
+		cm.SetVariantSwitcher(al.switchVariantReaction)
+		cm.SetApprovalResolver(al.resolveApprovalReaction)
+		cm.SetSummarizer(al.summarizeTurn)
+		cm.SetMiddleware(al.middleware)
+		if al.feedback != nil {
+			cm.SetFeedbackRecorder(al.recordFeedback)
+		}
+	}
 }
 
 // ReloadProviderAndConfig atomically swaps the provider and config with proper synchronization.
@@ -419,7 +776,11 @@ func (al *AgentLoop) ReloadProviderAndConfig(
 	}
 
 	// Ensure shared tools are re-registered on the new registry
-	registerSharedTools(cfg, al.bus, registry, provider)
+	registerSharedTools(cfg, al.bus, registry, provider, al.approvals)
+	al.registerDelegateTools(cfg, registry)
+	al.mu.RLock()
+	registerExtraTools(registry, al.extraTools)
+	al.mu.RUnlock()
 
 	// Atomically swap the config and registry under write lock
 	// This ensures readers see a consistent pair
@@ -432,9 +793,19 @@ func (al *AgentLoop) ReloadProviderAndConfig(
 
 	// Also update fallback chain with new config
 	al.fallback = providers.NewFallbackChain(providers.NewCooldownTracker())
+	al.alerts = newAlertMonitor(cfg, al.usage)
+	al.offline.Configure(cfg.OfflineQueue)
+	al.configureRateLimits(cfg.RateLimits)
+	al.responses.Configure(cfg.ResponseCache)
+	al.middleware.Configure(cfg.Middleware)
 
 	al.mu.Unlock()
 
+	// The new registry's media tools need the store too
+	if al.mediaStore != nil {
+		al.SetMediaStore(al.mediaStore)
+	}
+
 	// Close old provider after releasing the lock
 	// This prevents blocking readers while closing
 	if oldProvider, ok := extractProvider(oldRegistry); ok {
@@ -479,13 +850,18 @@ func (al *AgentLoop) GetConfig() *config.Config {
 func (al *AgentLoop) SetMediaStore(s media.MediaStore) {
 	al.mediaStore = s
 
-	// Propagate store to send_file tools in all agents.
+	// Propagate store to send_file and generate_image tools in all agents.
 	registry := al.GetRegistry()
 	registry.ForEachTool("send_file", func(t tools.Tool) {
 		if sf, ok := t.(*tools.SendFileTool); ok {
 			sf.SetMediaStore(s)
 		}
 	})
+	registry.ForEachTool("generate_image", func(t tools.Tool) {
+		if ig, ok := t.(*tools.ImageGenTool); ok {
+			ig.SetMediaStore(s)
+		}
+	})
 }
 
 // SetTranscriber injects a voice transcriber for agent-level audio transcription.
@@ -652,6 +1028,7 @@ func (al *AgentLoop) ProcessDirectWithChannel(
 	if err := al.ensureMCPInitialized(ctx); err != nil {
 		return "", err
 	}
+	al.StartPlugins(ctx)
 
 	msg := bus.InboundMessage{
 		Channel:    channel,
@@ -686,7 +1063,32 @@ func (al *AgentLoop) ProcessHeartbeat(
 	})
 }
 
+// ProcessBackground runs a one-off prompt through the default agent without
+// session history, for background jobs such as feed summaries. The result is
+// returned rather than sent.
+func (al *AgentLoop) ProcessBackground(ctx context.Context, content, sessionKey string) (string, error) {
+	agent := al.GetRegistry().GetDefaultAgent()
+	if agent == nil {
+		return "", fmt.Errorf("no default agent for %s", sessionKey)
+	}
+	return al.runAgentLoop(ctx, agent, processOptions{
+		SessionKey:      sessionKey,
+		Channel:         "cli",
+		ChatID:          sessionKey,
+		UserMessage:     content,
+		DefaultResponse: defaultResponse,
+		NoHistory:       true,
+	})
+}
+
 func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
+	ctx = requestid.NewContext(ctx, msg.RequestID)
+	ephemeral := al.isEphemeral(msg)
+	if ephemeral {
+		logger.SetPrivateRequest(msg.RequestID, true)
+		defer logger.SetPrivateRequest(msg.RequestID, false)
+	}
+
 	// Add message preview to log (show full content for error messages)
 	var logContent string
 	if strings.Contains(msg.Content, "Error:") || strings.Contains(msg.Content, "error") {
@@ -694,9 +1096,11 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 	} else {
 		logContent = utils.Truncate(msg.Content, 80)
 	}
-	logger.InfoCF(
+	logger.InfoCtx(
+		ctx,
 		"agent",
-		fmt.Sprintf("Processing message from %s:%s: %s", msg.Channel, msg.SenderID, logContent),
+		fmt.Sprintf("Processing message from %s:%s: %s", msg.Channel, msg.SenderID,
+			logger.RedactRequestContent(msg.RequestID, logContent)),
 		map[string]any{
 			"channel":     msg.Channel,
 			"chat_id":     msg.ChatID,
@@ -719,23 +1123,34 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		return al.processSystemMessage(ctx, msg)
 	}
 
+	if !al.inboundMiddleware(ctx, &msg) {
+		return "", nil
+	}
+
 	route, agent, routeErr := al.resolveMessageRoute(msg)
+	if errors.Is(routeErr, errNoSharedAgent) {
+		logger.WarnCtx(ctx, "agent", "Dropping message outside every tenant", map[string]any{
+			"channel": msg.Channel,
+			"chat_id": msg.ChatID,
+		})
+		return "", nil
+	}
 	if routeErr != nil {
 		return "", routeErr
 	}
 
 	// Reset message-tool state for this round so we don't skip publishing due to a previous round.
 	if tool, ok := agent.Tools.Get("message"); ok {
-		if resetter, ok := tool.(interface{ ResetSentInRound() }); ok {
-			resetter.ResetSentInRound()
+		if resetter, ok := tool.(interface{ ResetSentInRound(channel, chatID string) }); ok {
+			resetter.ResetSentInRound(msg.Channel, msg.ChatID)
 		}
 	}
 
 	// Resolve session key from route, while preserving explicit agent-scoped keys.
 	scopeKey := resolveScopeKey(route, msg.SessionKey)
-	sessionKey := scopeKey
+	sessionKey := al.branches.ActiveKey(scopeKey)
 
-	logger.InfoCF("agent", "Routed message",
+	logger.InfoCtx(ctx, "agent", "Routed message",
 		map[string]any{
 			"agent_id":      agent.ID,
 			"scope_key":     scopeKey,
@@ -745,29 +1160,114 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 			"route_channel": route.Channel,
 		})
 
+	al.recordActivity(agent, sessionKey, msg)
+
+	if ephemeral {
+		if err := al.keepEphemeral(agent, sessionKey); err != nil {
+			return "", err
+		}
+	}
+
+	// ◀️ and ▶️ show another reply of a regenerated turn instead of
+	// answering again
+	if step := variantStep(msg); step != 0 {
+		reply, err := al.stepVariant(agent, sessionKey, step)
+		if err != nil {
+			logger.DebugCF("agent", "Cannot switch the reply variant", map[string]any{
+				"session_key": sessionKey,
+				"error":       err.Error(),
+			})
+			return "", nil
+		}
+		return reply, nil
+	}
+
+	// An edited prompt replaces its turn, or is dropped when it cannot. An
+	// edit that cut its turn short is answered as a new message, unless that
+	// turn got to finish anyway.
+	regenerated := false
+	if isEdited(msg) {
+		cancelled := al.takeEditedPrompt(msg)
+		rolledBack := al.rollbackEditedTurn(agent, sessionKey, msg)
+		if !rolledBack && !cancelled {
+			return "", nil
+		}
+		regenerated = rolledBack && isRegenerated(msg)
+	}
+
 	opts := processOptions{
 		SessionKey:        sessionKey,
 		Channel:           msg.Channel,
 		ChatID:            msg.ChatID,
+		GuildID:           messageGuildID(msg),
 		SenderID:          msg.SenderID,
+		Sender:            msg.Sender,
 		SenderDisplayName: msg.Sender.DisplayName,
 		UserMessage:       msg.Content,
 		Media:             msg.Media,
 		DefaultResponse:   defaultResponse,
+		NoCache:           isRegenerated(msg),
 		EnableSummary:     true,
 		SendResponse:      false,
+		RequestID:         msg.RequestID,
+		MessageID:         msg.MessageID,
+		Ephemeral:         ephemeral,
+		Locale:            al.uiLanguage(msg),
+	}
+	schema, err := responseSchema(msg)
+	if err != nil {
+		return "", err
+	}
+	if schema != nil {
+		opts.Schema, opts.NoCache = schema, true
 	}
 
 	// context-dependent commands check their own Runtime fields and report
 	// "unavailable" when the required capability is nil.
 	if response, handled := al.handleCommand(ctx, msg, agent, &opts); handled {
+		analytics.FromContext(ctx).MarkCommand()
 		return response, nil
 	}
 
-	return al.runAgentLoop(ctx, agent, opts)
+	if reply := al.rateLimitReply(msg); reply != "" {
+		return reply, nil
+	}
+
+	if reply := al.tenantQuotaReply(agent, msg); reply != "" {
+		return reply, nil
+	}
+
+	al.ingestAttachments(&opts)
+
+	if reply := al.guardInput(ctx, &opts); reply != "" {
+		return reply, nil
+	}
+
+	// Chats in auto-translate mode get a translation instead of an answer
+	if al.autoTranslate(ctx, agent, msg) {
+		return "", nil
+	}
+
+	if al.shadowed(msg.Channel) {
+		opts.shadow = &shadowTurn{}
+	}
+
+	response, err := al.runAgentLoop(ctx, agent, opts)
+	if opts.shadow != nil {
+		al.recordShadowReply(agent, msg, opts, response, err)
+		return "", err
+	}
+	if err == nil && regenerated {
+		al.recordVariant(agent, sessionKey, msg.MessageID)
+	}
+	return response, err
 }
 
 func (al *AgentLoop) resolveMessageRoute(msg bus.InboundMessage) (routing.ResolvedRoute, *AgentInstance, error) {
+	agents, err := al.routeAgents(msg)
+	if err != nil {
+		return routing.ResolvedRoute{}, nil, err
+	}
 	registry := al.GetRegistry()
 	route := registry.ResolveRoute(routing.RouteInput{
 		Channel:    msg.Channel,
@@ -776,6 +1276,7 @@ func (al *AgentLoop) resolveMessageRoute(msg bus.InboundMessage) (routing.Resolv
 		ParentPeer: extractParentPeer(msg),
 		GuildID:    inboundMetadata(msg, metadataKeyGuildID),
 		TeamID:     inboundMetadata(msg, metadataKeyTeamID),
+		Agents:     agents,
 	})
 
 	agent, ok := registry.GetAgent(route.AgentID)
@@ -785,10 +1286,23 @@ func (al *AgentLoop) resolveMessageRoute(msg bus.InboundMessage) (routing.Resolv
 	if agent == nil {
 		return routing.ResolvedRoute{}, nil, fmt.Errorf("no agent available for route (agent_id=%s)", route.AgentID)
 	}
+	if agents != nil {
+		if err := al.checkTenantRoute(msg, agent); err != nil {
+			return routing.ResolvedRoute{}, nil, err
+		}
+	}
 
 	return route, agent, nil
 }
 
+// messageGuildID returns the Discord guild or Slack team a message came from.
+func messageGuildID(msg bus.InboundMessage) string {
+	if id := inboundMetadata(msg, metadataKeyGuildID); id != "" {
+		return id
+	}
+	return inboundMetadata(msg, metadataKeyTeamID)
+}
+
 func resolveScopeKey(route routing.ResolvedRoute, msgSessionKey string) string {
 	if msgSessionKey != "" && strings.HasPrefix(msgSessionKey, sessionKeyAgentPrefix) {
 		return msgSessionKey
@@ -867,12 +1381,22 @@ func (al *AgentLoop) runAgentLoop(
 	agent *AgentInstance,
 	opts processOptions,
 ) (string, error) {
+	if opts.RequestID == "" {
+		opts.RequestID = requestid.FromContext(ctx)
+		if opts.RequestID == "" {
+			opts.RequestID = requestid.New()
+		}
+	}
+	ctx = requestid.NewContext(ctx, opts.RequestID)
+	ctx = logger.WithFields(ctx, map[string]any{"agent_id": agent.ID, "session_key": opts.SessionKey})
+
 	// 0. Record last channel for heartbeat notifications (skip internal channels and cli)
 	if opts.Channel != "" && opts.ChatID != "" {
 		if !constants.IsInternalChannel(opts.Channel) {
 			channelKey := fmt.Sprintf("%s:%s", opts.Channel, opts.ChatID)
 			if err := al.RecordLastChannel(channelKey); err != nil {
-				logger.WarnCF(
+				logger.WarnCtx(
+					ctx,
 					"agent",
 					"Failed to record last channel",
 					map[string]any{"error": err.Error()},
@@ -881,6 +1405,14 @@ func (al *AgentLoop) runAgentLoop(
 		}
 	}
 
+	// Canary rollout: pick the variant once per turn, before any LLM call
+	// and before the persona is added to the prompt.
+	canary := agent.Canary != nil && agent.Canary.Assign(opts.Channel, opts.ChatID, opts.SessionKey)
+	if canary {
+		opts.Variant = agent.Canary.Label()
+		opts.VariantPersona = agent.CanaryPersona
+	}
+
 	// 1. Build messages (skip history for heartbeat)
 	var history []providers.Message
 	var summary string
@@ -898,19 +1430,65 @@ func (al *AgentLoop) runAgentLoop(
 		opts.SenderID,
 		opts.SenderDisplayName,
 	)
+	al.applyPromptTemplates(agent, messages, opts)
+	applyUserPrefs(messages, al.turnPrefs(opts))
+	appendSystemSection(messages, al.guildFactsPrompt(opts))
+	appendSystemSection(messages, al.recallPrompt(ctx, opts))
+	appendSystemSection(messages, al.personaPrompt(agent, opts))
+	appendSystemSection(messages, opts.Schema.Instruction())
+	messages = insertExamples(messages, opts.Examples)
 
 	// Resolve media:// refs: images→base64 data URLs, non-images→local paths in content
 	cfg := al.GetConfig()
 	maxMediaSize := cfg.Agents.Defaults.GetMaxMediaSize()
 	messages = resolveMediaRefs(messages, al.mediaStore, maxMediaSize)
 
+	if canary {
+		applyCanaryPrompt(agent, messages)
+	}
+
+	analytics.FromContext(ctx).SetAgent(agent.ID, opts.Variant)
+
 	// 2. Save user message to session
+	if opts.MessageID != "" && !opts.NoHistory && !opts.Ephemeral {
+		al.markTurn(opts, len(history))
+	}
 	agent.Sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage)
 
 	// 3. Run LLM iteration loop
 	finalContent, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
+	if promptDeleted(ctx) || promptEdited(ctx) || shutDown(ctx) || errors.Is(err, errMiddlewareStopped) {
+		// The user took the question back, or rewrote it and the new text
+		// is answered next, or the agent stopped and answers it after the
+		// restart, or a middleware ended the turn without a reply: forget
+		// the turn altogether.
+		if !opts.NoHistory {
+			agent.Sessions.SetHistory(opts.SessionKey, history)
+			agent.Sessions.Save(opts.SessionKey)
+		}
+		return "", nil
+	}
+	if err != nil && stoppedTurn(ctx) {
+		// The channel has already told the user; keep what was done so far
+		// and mark the reply as cut short.
+		agent.Sessions.AddMessage(opts.SessionKey, "assistant", interruptedNote)
+		agent.Sessions.Save(opts.SessionKey)
+		return "", nil
+	}
 	if err != nil {
-		return "", err
+		return "", al.offlineTurnError(agent, opts, history, iteration, err)
+	}
+	// A provider answered, so anything held during an outage can go now
+	al.offline.Resume()
+
+	if opts.Schema != nil {
+		if finalContent, err = al.conformReply(ctx, agent, messages, opts, finalContent); err != nil {
+			if !opts.NoHistory {
+				agent.Sessions.SetHistory(opts.SessionKey, history)
+				agent.Sessions.Save(opts.SessionKey)
+			}
+			return "", err
+		}
 	}
 
 	// If last tool had ForUser content and we already sent it, we might not need to send final response
@@ -920,10 +1498,12 @@ func (al *AgentLoop) runAgentLoop(
 	if finalContent == "" {
 		finalContent = opts.DefaultResponse
 	}
+	finalContent = al.guardOutput(ctx, opts, finalContent)
 
 	// 5. Save final assistant message to session
 	agent.Sessions.AddMessage(opts.SessionKey, "assistant", finalContent)
 	agent.Sessions.Save(opts.SessionKey)
+	al.indexTurn(agent, opts, finalContent)
 
 	// 6. Optional: summarization
 	if opts.EnableSummary {
@@ -941,7 +1521,7 @@ func (al *AgentLoop) runAgentLoop(
 
 	// 8. Log response
 	responsePreview := utils.Truncate(finalContent, 120)
-	logger.InfoCF("agent", fmt.Sprintf("Response: %s", responsePreview),
+	logger.InfoCtx(ctx, "agent", fmt.Sprintf("Response: %s", logger.RedactRequestContent(opts.RequestID, responsePreview)),
 		map[string]any{
 			"agent_id":     agent.ID,
 			"session_key":  opts.SessionKey,
@@ -1023,11 +1603,55 @@ func (al *AgentLoop) runLLMIteration(
 	// all tool-follow-up iterations within the same turn so that a multi-step
 	// tool chain doesn't switch models mid-way through.
 	activeCandidates, activeModel := al.selectCandidates(agent, opts.UserMessage, messages)
+	if opts.Variant != "" && len(agent.CanaryCandidates) > 0 {
+		// The canary model replaces both routing tiers so the comparison
+		// against stable traffic is not skewed by light-model turns.
+		activeCandidates, activeModel = agent.CanaryCandidates, agent.CanaryModel
+	}
+
+	// Sampling settings adjusted with /temp and /maxtokens apply to the
+	// whole turn, as does the persona, which fills in the model and
+	// temperature the conversation did not set.
+	generation := al.generationSettings(opts)
+	_, persona, _ := al.turnPersona(opts, generation)
+	generation = withPersona(generation, persona)
+	limits := al.generationLimits(agent)
+	temperature, maxTokens := limits.Temperature(generation), limits.MaxTokens(generation)
+
+	// A model picked with /model replaces routing and the canary for the
+	// whole conversation.
+	provider := agent.Provider
+	if p, modelID, ok := al.conversationModel(agent, generation); ok {
+		provider, activeCandidates, activeModel = p, nil, modelID
+	}
+
+	// A prompt answered recently is answered again from the cache
+	cacheKey := al.responseCacheKey(messages, opts, activeModel)
+	if cached, ok := al.responses.Get(cacheKey); ok {
+		logger.InfoCtx(ctx, "agent", "Answered from response cache", map[string]any{
+			"agent_id": agent.ID,
+			"model":    activeModel,
+		})
+		return cached, 0, nil
+	}
+
+	// The stream is closed before the final reply is published, which then
+	// replaces the streamed text.
+	stream := al.replyStream(ctx, agent, opts)
+	if stream != nil {
+		defer stream.Close()
+	}
+	heartbeat := al.startHeartbeat(ctx, opts, stream)
+	defer heartbeat.Stop()
+	opts.heartbeat = heartbeat
 
 	for iteration < agent.MaxIterations {
+		if err := ctx.Err(); err != nil {
+			return "", iteration, err
+		}
 		iteration++
 
-		logger.DebugCF("agent", "LLM iteration",
+		logger.DebugCtx(ctx, "agent", "LLM iteration",
 			map[string]any{
 				"agent_id":  agent.ID,
 				"iteration": iteration,
@@ -1035,7 +1659,8 @@ func (al *AgentLoop) runLLMIteration(
 			})
 
 		// Build tool definitions
-		providerToolDefs := agent.Tools.ToProviderDefs()
+		providerToolDefs := permittedTools(al.GetConfig().Tools.Permissions,
+			personaTools(agent.Tools.ToProviderDefsFor(opts.Channel), persona), opts)
 
 		// Determine whether the provider's native web search should replace
 		// the client-side web_search tool for this request. Only enable when web
@@ -1043,29 +1668,31 @@ func (al *AgentLoop) runLLMIteration(
 		// access do not get provider-side search or billing).
 		_, hasWebSearch := agent.Tools.Get("web_search")
 		useNativeSearch := al.cfg.Tools.Web.PreferNative &&
-			isNativeSearchProvider(agent.Provider) &&
+			isNativeSearchProvider(provider) &&
 			hasWebSearch
 
 		if useNativeSearch {
 			providerToolDefs = filterClientWebSearch(providerToolDefs)
 		}
+		messages = fitContextWindow(agent, messages, providerToolDefs, maxTokens)
 
 		// Log LLM request details
-		logger.DebugCF("agent", "LLM request",
+		logger.DebugCtx(ctx, "agent", "LLM request",
 			map[string]any{
 				"agent_id":          agent.ID,
 				"iteration":         iteration,
 				"model":             activeModel,
+				"variant":           opts.Variant,
 				"messages_count":    len(messages),
 				"tools_count":       len(providerToolDefs),
 				"native_search":     useNativeSearch,
-				"max_tokens":        agent.MaxTokens,
-				"temperature":       agent.Temperature,
+				"max_tokens":        maxTokens,
+				"temperature":       temperature,
 				"system_prompt_len": len(messages[0].Content),
 			})
 
 		// Log full messages (detailed)
-		logger.DebugCF("agent", "Full LLM request",
+		logger.DebugCtx(ctx, "agent", "Full LLM request",
 			map[string]any{
 				"iteration":     iteration,
 				"messages_json": formatMessagesForLog(messages),
@@ -1077,24 +1704,58 @@ func (al *AgentLoop) runLLMIteration(
 		var err error
 
 		llmOpts := map[string]any{
-			"max_tokens":       agent.MaxTokens,
-			"temperature":      agent.Temperature,
+			"max_tokens":       maxTokens,
+			"temperature":      temperature,
 			"prompt_cache_key": agent.ID,
 		}
 		if useNativeSearch {
 			llmOpts["native_search"] = true
 		}
+		if allowsBatch(ctx) {
+			llmOpts["batch"] = true
+		}
+		if opts.Schema != nil {
+			llmOpts[structured.Option] = opts.Schema.Map()
+		}
 		// parseThinkingLevel guarantees ThinkingOff for empty/unknown values,
 		// so checking != ThinkingOff is sufficient.
 		if agent.ThinkingLevel != ThinkingOff {
-			if tc, ok := agent.Provider.(providers.ThinkingCapable); ok && tc.SupportsThinking() {
+			if tc, ok := provider.(providers.ThinkingCapable); ok && tc.SupportsThinking() {
 				llmOpts["thinking_level"] = string(agent.ThinkingLevel)
 			} else {
-				logger.WarnCF("agent", "thinking_level is set but current provider does not support it, ignoring",
+				logger.WarnCtx(ctx, "agent", "thinking_level is set but current provider does not support it, ignoring",
 					map[string]any{"agent_id": agent.ID, "thinking_level": string(agent.ThinkingLevel)})
 			}
 		}
 
+		// request is the messages of this call, as the pre_llm middleware left them
+		var request []providers.Message
+		chat := func(ctx context.Context, model string) (*providers.LLMResponse, error) {
+			ctx, span := tracing.Start(ctx, "chat "+model, tracing.KindClient, map[string]any{
+				"gen_ai.request.model": model,
+				"iteration":            iteration,
+				"streaming":            stream != nil,
+			})
+			defer span.End()
+			var resp *providers.LLMResponse
+			var err error
+			if stream == nil {
+				resp, err = provider.Chat(ctx, request, providerToolDefs, model, llmOpts)
+			} else {
+				tokens := providers.StreamChat(ctx, provider, request, providerToolDefs, model, llmOpts)
+				stream.Pipe(tokens.Deltas())
+				resp, err = tokens.Wait()
+			}
+			span.RecordError(err)
+			if resp != nil && resp.Usage != nil {
+				span.SetAttributes(map[string]any{
+					"gen_ai.usage.input_tokens":  resp.Usage.PromptTokens,
+					"gen_ai.usage.output_tokens": resp.Usage.CompletionTokens,
+				})
+			}
+			return resp, err
+		}
+
 		callLLM := func() (*providers.LLMResponse, error) {
 			al.activeRequests.Add(1)
 			defer al.activeRequests.Done()
@@ -1104,14 +1765,15 @@ func (al *AgentLoop) runLLMIteration(
 					ctx,
 					activeCandidates,
 					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
-						return agent.Provider.Chat(ctx, messages, providerToolDefs, model, llmOpts)
+						return chat(ctx, model)
 					},
 				)
 				if fbErr != nil {
 					return nil, fbErr
 				}
 				if fbResult.Provider != "" && len(fbResult.Attempts) > 0 {
-					logger.InfoCF(
+					logger.InfoCtx(
+						ctx,
 						"agent",
 						fmt.Sprintf("Fallback: succeeded with %s/%s after %d attempts",
 							fbResult.Provider, fbResult.Model, len(fbResult.Attempts)+1),
@@ -1120,14 +1782,28 @@ func (al *AgentLoop) runLLMIteration(
 				}
 				return fbResult.Response, nil
 			}
-			return agent.Provider.Chat(ctx, messages, providerToolDefs, activeModel, llmOpts)
+			return chat(ctx, activeModel)
 		}
 
 		// Retry loop for context/token errors
 		maxRetries := 2
 		for retry := 0; retry <= maxRetries; retry++ {
+			var reply string
+			var stopped bool
+			if request, reply, stopped = al.preLLMMiddleware(ctx, opts, messages); stopped {
+				// The middleware answered in place of the model
+				if reply == "" {
+					return "", iteration, errMiddlewareStopped
+				}
+				return reply, iteration, nil
+			}
+			callStart := time.Now()
 			response, err = callLLM()
-			if err == nil {
+			callElapsed := time.Since(callStart)
+			al.recordUsage(agent, opts, activeModel, response, err, callElapsed,
+				iteration == 1 && retry == 0)
+			recordLLMCall(ctx, activeModel, response, callElapsed)
+			if err == nil || ctx.Err() != nil {
 				break
 			}
 
@@ -1153,7 +1829,7 @@ func (al *AgentLoop) runLLMIteration(
 
 			if isTimeoutError && retry < maxRetries {
 				backoff := time.Duration(retry+1) * 5 * time.Second
-				logger.WarnCF("agent", "Timeout error, retrying after backoff", map[string]any{
+				logger.WarnCtx(ctx, "agent", "Timeout error, retrying after backoff", map[string]any{
 					"error":   err.Error(),
 					"retry":   retry,
 					"backoff": backoff.String(),
@@ -1163,7 +1839,8 @@ func (al *AgentLoop) runLLMIteration(
 			}
 
 			if isContextError && retry < maxRetries {
-				logger.WarnCF(
+				logger.WarnCtx(
+					ctx,
 					"agent",
 					"Context window error detected, attempting compression",
 					map[string]any{
@@ -1176,7 +1853,7 @@ func (al *AgentLoop) runLLMIteration(
 					al.bus.PublishOutbound(ctx, bus.OutboundMessage{
 						Channel: opts.Channel,
 						ChatID:  opts.ChatID,
-						Content: "Context window exceeded. Compressing history and retrying...",
+						Content: i18n.T(opts.Locale, "Context window exceeded. Compressing history and retrying..."),
 					})
 				}
 
@@ -1187,13 +1864,18 @@ func (al *AgentLoop) runLLMIteration(
 					newHistory, newSummary, "",
 					nil, opts.Channel, opts.ChatID, opts.SenderID, opts.SenderDisplayName,
 				)
+				al.applyPromptTemplates(agent, messages, opts)
+				applyUserPrefs(messages, al.turnPrefs(opts))
+				appendSystemSection(messages, al.guildFactsPrompt(opts))
+				appendSystemSection(messages, al.recallPrompt(ctx, opts))
+				appendSystemSection(messages, al.personaPrompt(agent, opts))
 				continue
 			}
 			break
 		}
 
 		if err != nil {
-			logger.ErrorCF("agent", "LLM call failed",
+			logger.ErrorCtx(ctx, "agent", "LLM call failed",
 				map[string]any{
 					"agent_id":  agent.ID,
 					"iteration": iteration,
@@ -1203,6 +1885,10 @@ func (al *AgentLoop) runLLMIteration(
 			return "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
 		}
 
+		if !al.postLLMMiddleware(ctx, opts, response) && response.Content == "" {
+			return "", iteration, errMiddlewareStopped
+		}
+
 		go al.handleReasoning(
 			ctx,
 			response.Reasoning,
@@ -1210,7 +1896,7 @@ func (al *AgentLoop) runLLMIteration(
 			al.targetReasoningChannelID(opts.Channel),
 		)
 
-		logger.DebugCF("agent", "LLM response",
+		logger.DebugCtx(ctx, "agent", "LLM response",
 			map[string]any{
 				"agent_id":       agent.ID,
 				"iteration":      iteration,
@@ -1226,7 +1912,7 @@ func (al *AgentLoop) runLLMIteration(
 			if finalContent == "" && response.ReasoningContent != "" {
 				finalContent = response.ReasoningContent
 			}
-			logger.InfoCF("agent", "LLM response without tool calls (direct answer)",
+			logger.InfoCtx(ctx, "agent", "LLM response without tool calls (direct answer)",
 				map[string]any{
 					"agent_id":      agent.ID,
 					"iteration":     iteration,
@@ -1245,7 +1931,7 @@ func (al *AgentLoop) runLLMIteration(
 		for _, tc := range normalizedToolCalls {
 			toolNames = append(toolNames, tc.Name)
 		}
-		logger.InfoCF("agent", "LLM requested tool calls",
+		logger.InfoCtx(ctx, "agent", "LLM requested tool calls",
 			map[string]any{
 				"agent_id":  agent.ID,
 				"tools":     toolNames,
@@ -1286,6 +1972,8 @@ func (al *AgentLoop) runLLMIteration(
 		// Save assistant message with tool calls to session
 		agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg)
 
+		al.showProgress(ctx, opts, normalizedToolCalls, iteration)
+
 		// Execute tool calls in parallel
 		type indexedAgentResult struct {
 			result *tools.ToolResult
@@ -1294,6 +1982,7 @@ func (al *AgentLoop) runLLMIteration(
 
 		agentResults := make([]indexedAgentResult, len(normalizedToolCalls))
 		var wg sync.WaitGroup
+		toolsStart := time.Now()
 
 		for i, tc := range normalizedToolCalls {
 			agentResults[i].tc = tc
@@ -1304,7 +1993,8 @@ func (al *AgentLoop) runLLMIteration(
 
 				argsJSON, _ := json.Marshal(tc.Arguments)
 				argsPreview := utils.Truncate(string(argsJSON), 200)
-				logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
+				logger.InfoCtx(ctx, "agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name,
+					logger.RedactRequestContent(opts.RequestID, argsPreview)),
 					map[string]any{
 						"agent_id":  agent.ID,
 						"tool":      tc.Name,
@@ -1337,7 +2027,7 @@ func (al *AgentLoop) runLLMIteration(
 						return
 					}
 
-					logger.InfoCF("agent", "Async tool completed, publishing result",
+					logger.InfoCtx(ctx, "agent", "Async tool completed, publishing result",
 						map[string]any{
 							"tool":        tc.Name,
 							"content_len": len(content),
@@ -1354,18 +2044,24 @@ func (al *AgentLoop) runLLMIteration(
 					})
 				}
 
-				toolResult := agent.Tools.ExecuteWithContext(
-					ctx,
-					tc.Name,
-					tc.Arguments,
-					opts.Channel,
-					opts.ChatID,
-					asyncCallback,
-				)
+				toolCtx := tools.WithToolGuild(ctx, opts.GuildID)
+				toolCtx = tools.WithToolSender(toolCtx, opts.SenderID)
+				toolCtx = tools.WithToolSenderInfo(toolCtx, opts.Sender)
+				toolStart := time.Now()
+				var toolResult *tools.ToolResult
+				if opts.shadow != nil {
+					toolResult = opts.shadow.skip(tc)
+				} else if personaAllowsTool(persona, tc.Name) {
+					toolResult = al.executePermittedTool(toolCtx, agent, opts, tc, asyncCallback)
+				} else {
+					toolResult = tools.ErrorResult(fmt.Sprintf("tool %s is not available to this persona", tc.Name))
+				}
+				al.recordToolCall(agent, opts, tc, toolResult, time.Since(toolStart))
 				agentResults[idx].result = toolResult
 			}(i, tc)
 		}
 		wg.Wait()
+		analytics.FromContext(ctx).AddTools(len(normalizedToolCalls), time.Since(toolsStart))
 
 		// Process results in original order (send to user, save to session)
 		for _, r := range agentResults {
@@ -1376,7 +2072,7 @@ func (al *AgentLoop) runLLMIteration(
 					ChatID:  opts.ChatID,
 					Content: r.result.ForUser,
 				})
-				logger.DebugCF("agent", "Sent tool result to user",
+				logger.DebugCtx(ctx, "agent", "Sent tool result to user",
 					map[string]any{
 						"tool":        r.tc.Name,
 						"content_len": len(r.result.ForUser),
@@ -1428,11 +2124,15 @@ func (al *AgentLoop) runLLMIteration(
 		// If per-agent concurrency is added, TTL consistency between
 		// ToProviderDefs and Get must be re-evaluated.
 		agent.Tools.TickTTL()
-		logger.DebugCF("agent", "TTL tick after tool execution", map[string]any{
+		logger.DebugCtx(ctx, "agent", "TTL tick after tool execution", map[string]any{
 			"agent_id": agent.ID, "iteration": iteration,
 		})
 	}
 
+	// Replies that needed tools depend on what the tools found
+	if cacheKey != "" && iteration == 1 && finalContent != "" {
+		al.responses.Put(cacheKey, finalContent, opts.Channel, opts.SenderID)
+	}
 	return finalContent, iteration, nil
 }
 
@@ -1477,7 +2177,7 @@ func (al *AgentLoop) selectCandidates(
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
 func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, chatID string) {
 	newHistory := agent.Sessions.GetHistory(sessionKey)
-	tokenEstimate := al.estimateTokens(newHistory)
+	tokenEstimate := countMessageTokens(agent.tokens(), newHistory)
 	threshold := agent.ContextWindow * agent.SummarizeTokenPercent / 100
 
 	if len(newHistory) > agent.SummarizeMessageThreshold || tokenEstimate > threshold {
@@ -1493,55 +2193,54 @@ func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, c
 }
 
 // forceCompression aggressively reduces context when the limit is hit.
-// It drops the oldest 50% of messages (keeping system prompt and last user message).
+// It drops the oldest half of the history, cut at a user message so whole
+// turns go, and folds what was dropped into the session summary so the
+// conversation keeps its context. The fold is extractive rather than an
+// LLM call, since the model just rejected the request; the next regular
+// summarization rewrites it.
 func (al *AgentLoop) forceCompression(agent *AgentInstance, sessionKey string) {
 	history := agent.Sessions.GetHistory(sessionKey)
 	if len(history) <= 4 {
 		return
 	}
 
-	// Keep system prompt (usually [0]) and the very last message (user's trigger)
-	// We want to drop the oldest half of the *conversation*
-	// Assuming [0] is system, [1:] is conversation
-	conversation := history[1 : len(history)-1]
-	if len(conversation) == 0 {
-		return
+	// Cut at the user message nearest the middle, searching back first.
+	// At worst only the last message, the user's trigger, is kept.
+	cut := len(history) / 2
+	for cut > 0 && history[cut].Role != "user" {
+		cut--
 	}
+	if cut == 0 {
+		cut = len(history) / 2
+		for cut < len(history)-1 && history[cut].Role != "user" {
+			cut++
+		}
+	}
+	dropped := history[:cut]
 
-	// Helper to find the mid-point of the conversation
-	mid := len(conversation) / 2
-
-	// New history structure:
-	// 1. System Prompt (with compression note appended)
-	// 2. Second half of conversation
-	// 3. Last message
-
-	droppedCount := mid
-	keptConversation := conversation[mid:]
-
-	newHistory := make([]providers.Message, 0, 1+len(keptConversation)+1)
-
-	// Append compression note to the original system prompt instead of adding a new system message
-	// This avoids having two consecutive system messages which some APIs (like Zhipu) reject
-	compressionNote := fmt.Sprintf(
-		"\n\n[System Note: Emergency compression dropped %d oldest messages due to context limit]",
-		droppedCount,
-	)
-	enhancedSystemPrompt := history[0]
-	enhancedSystemPrompt.Content = enhancedSystemPrompt.Content + compressionNote
-	newHistory = append(newHistory, enhancedSystemPrompt)
-
-	newHistory = append(newHistory, keptConversation...)
-	newHistory = append(newHistory, history[len(history)-1]) // Last message
+	var turns []providers.Message
+	for _, m := range dropped {
+		if m.Role == "user" || m.Role == "assistant" {
+			turns = append(turns, m)
+		}
+	}
+	note := fmt.Sprintf("[%d older messages were compressed to fit the context window.]", len(dropped))
+	if len(turns) > 0 {
+		note = fallbackSummary(turns) + "\n" + note
+	}
+	summary := agent.Sessions.GetSummary(sessionKey)
+	if summary != "" {
+		summary += "\n\n"
+	}
 
-	// Update session
-	agent.Sessions.SetHistory(sessionKey, newHistory)
+	agent.Sessions.SetSummary(sessionKey, summary+note)
+	agent.Sessions.SetHistory(sessionKey, history[cut:])
 	agent.Sessions.Save(sessionKey)
 
 	logger.WarnCF("agent", "Forced compression executed", map[string]any{
 		"session_key":  sessionKey,
-		"dropped_msgs": droppedCount,
-		"new_count":    len(newHistory),
+		"dropped_msgs": len(dropped),
+		"new_count":    len(history) - cut,
 	})
 }
 
@@ -1635,30 +2334,39 @@ func formatToolsForLog(toolDefs []providers.ToolDefinition) string {
 
 // summarizeSession summarizes the conversation history for a session.
 func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	ctx, cancel := context.WithTimeout(withBatch(context.Background()), summarizeTimeout(agent))
 	defer cancel()
 
 	history := agent.Sessions.GetHistory(sessionKey)
 	summary := agent.Sessions.GetSummary(sessionKey)
 
-	// Keep last 4 messages for continuity
+	// Keep at least the last 4 messages verbatim for continuity, back to
+	// the user message that starts their turn so no tool result is cut off
+	// from its call.
 	if len(history) <= 4 {
 		return
 	}
+	cut := len(history) - 4
+	for cut > 0 && history[cut].Role != "user" {
+		cut--
+	}
+	if cut == 0 {
+		return
+	}
 
-	toSummarize := history[:len(history)-4]
+	toSummarize := history[:cut]
 
 	// Oversized Message Guard
 	maxMessageTokens := agent.ContextWindow / 2
 	validMessages := make([]providers.Message, 0)
 	omitted := false
+	tok := agent.tokens()
 
 	for _, m := range toSummarize {
 		if m.Role != "user" && m.Role != "assistant" {
 			continue
 		}
-		msgTokens := len(m.Content) / 2
-		if msgTokens > maxMessageTokens {
+		if countMessage(tok, m) > maxMessageTokens {
 			omitted = true
 			continue
 		}
@@ -1709,11 +2417,18 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 		finalSummary += "\n[Note: Some oversized messages were omitted from this summary for efficiency.]"
 	}
 
-	if finalSummary != "" {
-		agent.Sessions.SetSummary(sessionKey, finalSummary)
-		agent.Sessions.TruncateHistory(sessionKey, 4)
-		agent.Sessions.Save(sessionKey)
+	if finalSummary == "" {
+		return
 	}
+	// Messages may have arrived while the LLM summarized; keep those too.
+	// A history that shrank was replaced meanwhile, and the summary with it.
+	current := len(agent.Sessions.GetHistory(sessionKey))
+	if current < len(history) {
+		return
+	}
+	agent.Sessions.SetSummary(sessionKey, finalSummary)
+	agent.Sessions.TruncateHistory(sessionKey, current-cut)
+	agent.Sessions.Save(sessionKey)
 }
 
 // findNearestUserMessage finds the nearest user message to the given index.
@@ -1768,6 +2483,7 @@ func (al *AgentLoop) retryLLMCall(
 					"max_tokens":       agent.MaxTokens,
 					"temperature":      llmTemperature,
 					"prompt_cache_key": agent.ID,
+					"batch":            allowsBatch(ctx),
 				},
 			)
 		}()
@@ -1791,10 +2507,8 @@ func (al *AgentLoop) summarizeBatch(
 	existingSummary string,
 ) (string, error) {
 	const (
-		llmMaxRetries             = 3
-		llmTemperature            = 0.3
-		fallbackMinContentLength  = 200
-		fallbackMaxContentPercent = 10
+		llmMaxRetries  = 3
+		llmTemperature = 0.3
 	)
 
 	var sb strings.Builder
@@ -1816,6 +2530,16 @@ func (al *AgentLoop) summarizeBatch(
 	if err == nil && response.Content != "" {
 		return strings.TrimSpace(response.Content), nil
 	}
+	return fallbackSummary(batch), nil
+}
+
+// fallbackSummary summarizes batch without the LLM by keeping the start of
+// every message.
+func fallbackSummary(batch []providers.Message) string {
+	const (
+		fallbackMinContentLength  = 200
+		fallbackMaxContentPercent = 10
+	)
 
 	var fallback strings.Builder
 	fallback.WriteString("Conversation summary: ")
@@ -1845,19 +2569,7 @@ func (al *AgentLoop) summarizeBatch(
 		}
 		fallback.WriteString(fmt.Sprintf("%s: %s", m.Role, content))
 	}
-	return fallback.String(), nil
-}
-
-// estimateTokens estimates the number of tokens in a message list.
-// Uses a safe heuristic of 2.5 characters per token to account for CJK and other
-// overheads better than the previous 3 chars/token.
-func (al *AgentLoop) estimateTokens(messages []providers.Message) int {
-	totalChars := 0
-	for _, m := range messages {
-		totalChars += utf8.RuneCountInString(m.Content)
-	}
-	// 2.5 chars per token = totalChars * 2 / 5
-	return totalChars * 2 / 5
+	return fallback.String()
 }
 
 func (al *AgentLoop) handleCommand(
@@ -1875,6 +2587,30 @@ func (al *AgentLoop) handleCommand(
 	}
 
 	rt := al.buildCommandsRuntime(agent, opts)
+	al.addMaintenanceRuntime(rt, msg)
+	al.addAccessRuntime(rt, msg)
+	al.addQuotaRuntime(rt, msg)
+	al.addUsageRuntime(rt, msg)
+	al.addFeedbackRuntime(rt, msg)
+	al.addCalendarRuntime(rt, msg)
+	al.addApprovalRuntime(ctx, rt, msg)
+	al.addTranslateRuntime(rt, msg, agent)
+	al.addPrefsRuntime(rt, msg)
+	al.addBranchRuntime(rt, agent, opts)
+	al.addVariantRuntime(rt, agent, opts)
+	al.addFactsRuntime(rt, msg, opts)
+	al.addPromptLibraryRuntime(rt, msg, opts)
+	al.addRecallRuntime(rt, msg, opts)
+	al.addKBRuntime(rt, msg, agent, opts)
+	al.addTranscriptRuntime(rt, opts)
+	al.addGenerationRuntime(rt, msg, agent, opts)
+	al.addGuildConfigRuntime(rt, msg, agent, opts)
+	al.addModelRuntime(rt, agent, opts)
+	al.addPersonaRuntime(rt, opts)
+	al.addSearchRuntime(rt, agent, opts)
+	al.addExportRuntime(rt, agent, opts)
+	al.addForgetMeRuntime(rt, msg)
+	al.addContextRuntime(rt, msg, agent, opts)
 	executor := commands.NewExecutor(al.cmdRegistry, rt)
 
 	var commandReply string
@@ -1883,6 +2619,7 @@ func (al *AgentLoop) handleCommand(
 		ChatID:   msg.ChatID,
 		SenderID: msg.SenderID,
 		Text:     msg.Content,
+		Locale:   opts.Locale,
 		Reply: func(text string) error {
 			commandReply = text
 			return nil
@@ -1892,7 +2629,11 @@ func (al *AgentLoop) handleCommand(
 	switch result.Outcome {
 	case commands.OutcomeHandled:
 		if result.Err != nil {
-			return mapCommandError(result), true
+			return mapCommandError(result, opts.Locale), true
+		}
+		// /prompt <name> rewrote the message for the agent to answer
+		if opts != nil && opts.PromptTemplate != "" {
+			return "", false
 		}
 		if commandReply != "" {
 			return commandReply, true
@@ -1931,6 +2672,9 @@ func (al *AgentLoop) buildCommandsRuntime(agent *AgentInstance, opts *processOpt
 			return agent.Model, cfg.Agents.Defaults.Provider
 		}
 		rt.SwitchModel = func(value string) (string, error) {
+			if err := al.checkModelForAgent(agent, value); err != nil {
+				return "", err
+			}
 			oldModel := agent.Model
 			agent.Model = value
 			return oldModel, nil
@@ -1953,11 +2697,11 @@ func (al *AgentLoop) buildCommandsRuntime(agent *AgentInstance, opts *processOpt
 	return rt
 }
 
-func mapCommandError(result commands.ExecuteResult) string {
+func mapCommandError(result commands.ExecuteResult, lang string) string {
 	if result.Command == "" {
-		return fmt.Sprintf("Failed to execute command: %v", result.Err)
+		return i18n.Sprintf(lang, "Failed to execute command: %v", result.Err)
 	}
-	return fmt.Sprintf("Failed to execute /%s: %v", result.Command, result.Err)
+	return i18n.Sprintf(lang, "Failed to execute /%s: %v", result.Command, result.Err)
 }
 
 // extractPeer extracts the routing peer from the inbound message's structured Peer field.
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/access"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// accessSubject describes the sender of msg for the access config.
+func (al *AgentLoop) accessSubject(msg bus.InboundMessage) access.Subject {
+	var roles []string
+	if joined := inboundMetadata(msg, metadataKeyRoles); joined != "" {
+		roles = strings.Split(joined, ",")
+	}
+	return access.Subject{
+		Sender:   msg.Sender,
+		SenderID: msg.SenderID,
+		GuildID:  inboundMetadata(msg, metadataKeyGuildID),
+		Roles:    roles,
+		IsAdmin: func() bool {
+			return al.maintenance.IsAdmin(msg.Sender, msg.SenderID)
+		},
+	}
+}
+
+// accessAllowed reports whether the access config lets the sender of msg
+// use the bot. Messages it keeps out are dropped without an answer, as the
+// allow_from of a channel drops them.
+func (al *AgentLoop) accessAllowed(msg bus.InboundMessage) bool {
+	if msg.Channel == "system" {
+		return true
+	}
+	if access.Allowed(al.GetConfig().Access, al.accessSubject(msg)) {
+		return true
+	}
+	logger.InfoCF("agent", "Ignored message denied by access config", map[string]any{
+		"channel":   msg.Channel,
+		"chat_id":   msg.ChatID,
+		"sender_id": msg.SenderID,
+	})
+	return false
+}
+
+// addAccessRuntime lets the command executor check access.commands.
+func (al *AgentLoop) addAccessRuntime(rt *commands.Runtime, msg bus.InboundMessage) {
+	cfg := al.GetConfig().Access
+	if len(cfg.Commands) == 0 {
+		return
+	}
+	subject := al.accessSubject(msg)
+	rt.MayRun = func(command string) bool {
+		return access.MayRun(cfg, command, subject)
+	}
+}
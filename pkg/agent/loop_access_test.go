@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/maintenance"
+)
+
+func TestAccess_GateAndCommandPermissions(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Access = config.AccessConfig{
+		Allow:    []string{"guild:100"},
+		Deny:     []string{"role:muted"},
+		Commands: map[string][]string{"model": {"admins", "role:mods"}},
+	}
+	al := &AgentLoop{cfg: cfg}
+	al.SetMaintenance(maintenance.NewController(config.MaintenanceConfig{Admins: []string{"discord:1"}}, t.TempDir()))
+
+	msg := func(senderID, guild, roles string) bus.InboundMessage {
+		return bus.InboundMessage{
+			Channel:  "discord",
+			SenderID: senderID,
+			Sender:   bus.SenderInfo{Platform: "discord", PlatformID: senderID, CanonicalID: "discord:" + senderID},
+			Metadata: map[string]string{metadataKeyGuildID: guild, metadataKeyRoles: roles},
+		}
+	}
+	admin, mod, member := msg("1", "100", ""), msg("2", "100", "mods,members"), msg("3", "100", "members")
+
+	for _, tc := range []struct {
+		name string
+		msg  bus.InboundMessage
+		want bool
+	}{
+		{"member of the allowed server", member, true},
+		{"other server", msg("4", "200", ""), false},
+		{"muted member", msg("5", "100", "members,muted"), false},
+		{"system", bus.InboundMessage{Channel: "system"}, true},
+	} {
+		if got := al.accessAllowed(tc.msg); got != tc.want {
+			t.Errorf("%s: accessAllowed = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		msg  bus.InboundMessage
+		want bool
+	}{
+		{"admin", admin, true},
+		{"mod", mod, true},
+		{"member", member, false},
+	} {
+		rt := &commands.Runtime{}
+		al.addAccessRuntime(rt, tc.msg)
+		if got := rt.MayRun("model"); got != tc.want {
+			t.Errorf("%s: MayRun(model) = %v, want %v", tc.name, got, tc.want)
+		}
+		if !rt.MayRun("help") {
+			t.Errorf("%s: commands not listed must stay open", tc.name)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/admin"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// AdminAPI returns the admin API when it has a token, or nil. gw carries
+// the gateway's part of it, the config reload and channel updates; the agent
+// fills in the rest.
+func (al *AgentLoop) AdminAPI(gw admin.Backend) *admin.Handler {
+	token := al.GetConfig().Admin.APIToken
+	if token == "" || al.bans == nil {
+		return nil
+	}
+	gw.Sessions = al.activity
+	gw.Bans = al.bans
+	gw.History = al.ExportConversation
+	gw.Clear = al.ClearConversation
+	gw.Publish = al.bus.PublishOutbound
+	gw.Config = al.GetConfig
+	if al.usage != nil {
+		gw.Usage = usage.NewHandler([]string{token}, al.usage, al.usagePricing)
+	}
+	if al.transcripts != nil {
+		gw.Transcripts = al.transcripts.ServeQuery
+	}
+	if al.searchIndex != nil {
+		gw.Search = al.SearchMessages
+	}
+	return admin.NewHandler(token, gw)
+}
+
+// ClearConversation deletes the history and summary of a session of an
+// agent, the default agent when agentID is empty.
+func (al *AgentLoop) ClearConversation(agentID, sessionKey string) error {
+	agent, err := al.exportAgent(agentID)
+	if err != nil {
+		return err
+	}
+	agent.Sessions.SetHistory(sessionKey, nil)
+	agent.Sessions.SetSummary(sessionKey, "")
+	return agent.Sessions.Save(sessionKey)
+}
+
+// recordActivity lists the session of msg in the admin API.
+func (al *AgentLoop) recordActivity(agent *AgentInstance, sessionKey string, msg bus.InboundMessage) {
+	al.activity.Touch(admin.Session{
+		Agent:      agent.ID,
+		SessionKey: sessionKey,
+		Channel:    msg.Channel,
+		ChatID:     msg.ChatID,
+		SenderID:   msg.SenderID,
+	}, time.Now())
+}
+
+// banned reports whether the sender of msg is banned through the admin API.
+// Their messages are dropped without an answer until the ban expires.
+func (al *AgentLoop) banned(msg bus.InboundMessage) bool {
+	if msg.Channel == "system" {
+		return false
+	}
+	ban, ok := al.bans.Banned(msg.Channel, msg.Sender, msg.SenderID, time.Now())
+	if !ok {
+		return false
+	}
+	logger.InfoCF("agent", "Ignored message from banned user", map[string]any{
+		"channel":   msg.Channel,
+		"chat_id":   msg.ChatID,
+		"sender_id": msg.SenderID,
+		"until":     ban.Until,
+	})
+	return true
+}
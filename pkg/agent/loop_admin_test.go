@@ -0,0 +1,33 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/admin"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestBanned(t *testing.T) {
+	al := &AgentLoop{cfg: &config.Config{}, bans: admin.NewBans(admin.BansPath(t.TempDir()))}
+	if err := al.bans.Add(admin.Ban{User: "telegram:42", Until: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	msg := func(senderID string) bus.InboundMessage {
+		return bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: "telegram:" + senderID,
+			Sender:   bus.SenderInfo{Platform: "telegram", PlatformID: senderID, CanonicalID: "telegram:" + senderID},
+		}
+	}
+	if !al.banned(msg("42")) {
+		t.Error("banned user got through")
+	}
+	if al.banned(msg("43")) {
+		t.Error("other user banned")
+	}
+	if al.banned(bus.InboundMessage{Channel: "system", SenderID: "telegram:42"}) {
+		t.Error("system message banned")
+	}
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/analytics"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/database"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// openAnalytics opens the analytics table of the workspace database, or
+// returns nil when analytics are disabled or the database cannot be opened.
+func openAnalytics(cfg config.AnalyticsConfig, workspace string) *analytics.Store {
+	if !cfg.Enabled {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store, err := analytics.Open(ctx, database.Path(workspace))
+	if err != nil {
+		logger.WarnCF("agent", "Analytics disabled: database unavailable", map[string]any{
+			"error": err.Error(),
+		})
+		return nil
+	}
+	return store
+}
+
+// recordAnalytics stores the figures of a handled inbound message. Internal
+// system messages and ephemeral conversations are not recorded.
+func (al *AgentLoop) recordAnalytics(turn *analytics.Turn, msg bus.InboundMessage, outcome string) {
+	if al.analytics == nil || msg.Channel == "system" || al.isEphemeral(msg) {
+		return
+	}
+	rec := turn.Finish(outcome)
+	rec.RequestID = msg.RequestID
+	rec.Channel = msg.Channel
+	rec.ChatID = msg.ChatID
+	rec.SenderID = msg.SenderID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := al.analytics.Record(ctx, rec); err != nil {
+		logger.WarnCF("agent", "Failed to record analytics", map[string]any{"error": err.Error()})
+	}
+}
+
+// turnOutcome classifies how the message of a turn was handled.
+func turnOutcome(err error, stopped, held bool) string {
+	switch {
+	case held:
+		return analytics.OutcomeQueued
+	case stopped:
+		return analytics.OutcomeStopped
+	case err != nil:
+		return analytics.OutcomeError
+	}
+	return analytics.OutcomeOK
+}
+
+// recordLLMCall adds a provider call to the analytics of the turn in ctx.
+func recordLLMCall(ctx context.Context, model string, resp *providers.LLMResponse, elapsed time.Duration) {
+	var prompt, completion int
+	if resp != nil && resp.Usage != nil {
+		prompt, completion = resp.Usage.PromptTokens, resp.Usage.CompletionTokens
+	}
+	analytics.FromContext(ctx).AddLLMCall(model, prompt, completion, elapsed)
+}
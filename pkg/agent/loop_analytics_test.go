@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/analytics"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+type usageProvider struct{}
+
+func (usageProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{
+		Content: "Hi there",
+		Usage:   &providers.UsageInfo{PromptTokens: 42, CompletionTokens: 7},
+	}, nil
+}
+
+func (usageProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestAnalytics_RecordsHandledMessages(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Analytics: config.AnalyticsConfig{Enabled: true},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), usageProvider{})
+	defer al.Close()
+	if al.analytics == nil {
+		t.Fatal("analytics store not opened")
+	}
+
+	handle := func(content string) {
+		msg := bus.InboundMessage{
+			Channel: "telegram", SenderID: "alice", ChatID: "chat-1", Content: content, RequestID: "req_" + content,
+		}
+		ctx, stats := analytics.NewContext(context.Background())
+		_, err := al.processMessage(ctx, msg)
+		al.recordAnalytics(stats, msg, turnOutcome(err, false, false))
+	}
+	handle("hello")
+	handle("/help")
+
+	recs, err := al.analytics.Records(context.Background(), time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Records: %v", err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("got %d records, want 2", len(recs))
+	}
+	chat := recs[0]
+	if chat.Outcome != analytics.OutcomeOK || chat.RequestID != "req_hello" || chat.AgentID != "main" ||
+		chat.Model != "test-model" || chat.LLMCalls != 1 || chat.PromptTokens != 42 || chat.CompletionTokens != 7 {
+		t.Errorf("chat record = %+v", chat)
+	}
+	if cmd := recs[1]; cmd.Outcome != analytics.OutcomeCommand || cmd.LLMCalls != 0 {
+		t.Errorf("command record = %+v", cmd)
+	}
+}
+
+func TestTurnOutcome(t *testing.T) {
+	err := context.Canceled
+	if got := turnOutcome(nil, false, false); got != analytics.OutcomeOK {
+		t.Errorf("ok = %q", got)
+	}
+	if got := turnOutcome(err, false, true); got != analytics.OutcomeQueued {
+		t.Errorf("held = %q", got)
+	}
+	if got := turnOutcome(nil, true, false); got != analytics.OutcomeStopped {
+		t.Errorf("stopped = %q", got)
+	}
+	if got := turnOutcome(err, false, false); got != analytics.OutcomeError {
+		t.Errorf("error = %q", got)
+	}
+}
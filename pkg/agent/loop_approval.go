@@ -0,0 +1,28 @@
+package agent
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+)
+
+// addApprovalRuntime lets /approve and /deny resolve tool actions held for
+// the sender of msg.
+func (al *AgentLoop) addApprovalRuntime(ctx context.Context, rt *commands.Runtime, msg bus.InboundMessage) {
+	q := al.approvals
+	if q == nil {
+		return
+	}
+	rt.ResolveApproval = func(id string, approve bool) (string, string, error) {
+		summary, result, err := q.Resolve(ctx, id, msg.Channel, msg.SenderID, approve)
+		if err != nil || result == nil {
+			return summary, "", err
+		}
+		if result.IsError {
+			return summary, "", errors.New(result.ForLLM)
+		}
+		return summary, result.ForLLM, nil
+	}
+}
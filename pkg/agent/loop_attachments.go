@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"errors"
+
+	"github.com/sipeed/picoclaw/pkg/attachments"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// extractAttachments reads the text of the PDFs, text, and source files
+// among the media refs, with the limits of the attachments config. Files
+// over the size limit are returned in tooLarge, without text.
+func (al *AgentLoop) extractAttachments(media []string) (docs, tooLarge []attachments.Document) {
+	if al.mediaStore == nil {
+		return nil, nil
+	}
+	cfg := al.GetConfig().Attachments
+	limits := attachments.Limits{MaxBytes: int64(cfg.MaxBytes), MaxPages: cfg.MaxPages}
+	for _, ref := range media {
+		path, meta, err := al.mediaStore.ResolveWithMeta(ref)
+		if err != nil || !attachments.Supported(meta.Filename, meta.ContentType) {
+			continue
+		}
+		doc, err := attachments.Extract(path, meta.Filename, meta.ContentType, limits)
+		if err != nil {
+			logger.WarnCF("agent", "Failed to read attachment", map[string]any{
+				"ref":   ref,
+				"file":  doc.Name,
+				"error": err.Error(),
+			})
+			if errors.Is(err, attachments.ErrTooLarge) {
+				tooLarge = append(tooLarge, doc)
+			}
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	return docs, tooLarge
+}
+
+// ingestAttachments appends the text of the files attached to the turn's
+// message to it. The files stay attached, so the agent can still read what
+// the limits cut with its file tools.
+func (al *AgentLoop) ingestAttachments(opts *processOptions) {
+	if !al.GetConfig().Attachments.Enabled || len(opts.Media) == 0 {
+		return
+	}
+	docs, tooLarge := al.extractAttachments(opts.Media)
+	for _, doc := range tooLarge {
+		doc.Text = "(too large to include; read it with the file tools)"
+		docs = append(docs, doc)
+	}
+	if len(docs) == 0 {
+		return
+	}
+
+	cfg := al.GetConfig().Attachments
+	text := attachments.Format(docs, cfg.ChunkChars, cfg.MaxChunks)
+	if opts.UserMessage == "" {
+		opts.UserMessage = text
+	} else {
+		opts.UserMessage += "\n\n" + text
+	}
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+func TestIngestAttachments(t *testing.T) {
+	dir := t.TempDir()
+	store := media.NewFileMediaStore()
+	attach := func(name, contentType, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		ref, err := store.Store(path, media.MediaMeta{Filename: name, ContentType: contentType}, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ref
+	}
+	notes := attach("notes.md", "text/markdown", "# Plan\n\nShip it.")
+	photo := attach("photo.jpg", "image/jpeg", "\xff\xd8")
+	big := attach("dump.log", "", strings.Repeat("x", 64))
+
+	cfg := config.DefaultConfig()
+	cfg.Attachments.MaxBytes = 32
+	al := &AgentLoop{cfg: cfg, mediaStore: store}
+
+	opts := &processOptions{UserMessage: "summarize this", Media: []string{notes, photo, big}}
+	al.ingestAttachments(opts)
+	want := "summarize this\n\n[attachment: notes.md]\n# Plan\n\nShip it.\n\n" +
+		"[attachment: dump.log]\n(too large to include; read it with the file tools)"
+	if opts.UserMessage != want {
+		t.Errorf("UserMessage =\n%s\nwant\n%s", opts.UserMessage, want)
+	}
+	if len(opts.Media) != 3 {
+		t.Errorf("Media = %v, want the attachments kept", opts.Media)
+	}
+
+	cfg.Attachments.Enabled = false
+	opts = &processOptions{UserMessage: "hi", Media: []string{notes}}
+	if al.ingestAttachments(opts); opts.UserMessage != "hi" {
+		t.Errorf("disabled: UserMessage = %q, want it unchanged", opts.UserMessage)
+	}
+}
@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"context"
+	"time"
+)
+
+// batchTimeout bounds the wait for a batch API answer, which providers
+// promise within 24 hours.
+const batchTimeout = 25 * time.Hour
+
+type batchKey struct{}
+
+// withBatch marks ctx as belonging to a job nobody waits on, whose LLM calls
+// may go through the provider's batch API.
+func withBatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, batchKey{}, true)
+}
+
+// allowsBatch reports whether ctx was marked by withBatch.
+func allowsBatch(ctx context.Context) bool {
+	ok, _ := ctx.Value(batchKey{}).(bool)
+	return ok
+}
+
+// ProcessDeferred is ProcessBackground for jobs that can wait hours for the
+// answer, such as feed digests: models with "batch" set send it through their
+// batch API at a lower price. ctx should allow for that wait.
+func (al *AgentLoop) ProcessDeferred(ctx context.Context, content, sessionKey string) (string, error) {
+	return al.ProcessBackground(withBatch(ctx), content, sessionKey)
+}
+
+// summarizeTimeout is how long a session summary may take. Summaries of an
+// agent whose model answers through a batch API wait for the batch.
+func summarizeTimeout(agent *AgentInstance) time.Duration {
+	if agent.Batch {
+		return batchTimeout
+	}
+	return 120 * time.Second
+}
@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestProcessDeferred_AllowsBatch(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	if _, err := al.ProcessBackground(ctx, "summarize this entry", "feeds"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := provider.lastOpts["batch"]; ok {
+		t.Errorf("background job allowed batching: %v", provider.lastOpts)
+	}
+	if _, err := al.ProcessDeferred(ctx, "write the digest", "feeds"); err != nil {
+		t.Fatal(err)
+	}
+	if provider.lastOpts["batch"] != true {
+		t.Errorf("deferred job options = %v", provider.lastOpts)
+	}
+
+	agent := al.GetRegistry().GetDefaultAgent()
+	if _, err := al.retryLLMCall(withBatch(ctx), agent, "summarize", 1); err != nil {
+		t.Fatal(err)
+	}
+	if provider.lastOpts["batch"] != true {
+		t.Errorf("summary options = %v", provider.lastOpts)
+	}
+}
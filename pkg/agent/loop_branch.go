@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/branch"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+var errTurnCompacted = errors.New("that message has been summarized away and can no longer be branched from")
+
+// markTurn records where the user message of this turn starts in the
+// session, so /branch can fork from it later.
+func (al *AgentLoop) markTurn(opts processOptions, index int) {
+	if err := al.branches.Mark(opts.SessionKey, opts.MessageID, index, opts.UserMessage); err != nil {
+		logger.WarnCF("agent", "Failed to record message for branching", map[string]any{
+			"session_key": opts.SessionKey,
+			"error":       err.Error(),
+		})
+	}
+}
+
+// addBranchRuntime exposes the conversation branches of this chat to /branch.
+func (al *AgentLoop) addBranchRuntime(rt *commands.Runtime, agent *AgentInstance, opts *processOptions) {
+	// Branches are saved sessions, which an ephemeral conversation must not create
+	if al.branches == nil || agent == nil || opts == nil || agent.Sessions == nil || opts.Ephemeral {
+		return
+	}
+	base := branch.BaseKey(opts.SessionKey)
+	rt.ForkBranch = func(messageRef, name string) (string, error) {
+		return al.forkBranch(agent, base, messageRef, name)
+	}
+	rt.SwitchBranch = func(name string) error {
+		return al.branches.Switch(base, strings.ToLower(name))
+	}
+	rt.ListBranches = func() (string, []branch.Branch) {
+		return al.branches.Branches(base)
+	}
+}
+
+// forkBranch copies the history of the session the referenced message was
+// sent in, up to the end of that message's turn, into a new branch and makes
+// the branch active. The original session is left untouched.
+func (al *AgentLoop) forkBranch(agent *AgentInstance, base, messageRef, name string) (string, error) {
+	messageID := branch.ParseMessageRef(messageRef)
+	if messageID == "" {
+		return "", branch.ErrUnknownMessage
+	}
+	name = strings.ToLower(name)
+	switch {
+	case name == "":
+		name = nextBranchName(al.branches, base)
+	case !branch.ValidName(name):
+		return "", fmt.Errorf("branch names use up to 32 lowercase letters, digits, '-' and '_', and cannot be %q",
+			branch.Main)
+	case al.branches.Has(base, name):
+		return "", branch.ErrExists
+	}
+
+	mark, err := al.branches.Find(base, messageID)
+	if err != nil {
+		return "", err
+	}
+	history := agent.Sessions.GetHistory(mark.Session)
+	start := locateTurn(history, mark)
+	if start < 0 {
+		return "", errTurnCompacted
+	}
+	forked := history[:turnEnd(history, start)]
+
+	key := branch.Key(base, name)
+	agent.Sessions.AddFullMessage(key, forked[0])
+	agent.Sessions.SetHistory(key, forked)
+	agent.Sessions.SetSummary(key, agent.Sessions.GetSummary(mark.Session))
+	if err := agent.Sessions.Save(key); err != nil {
+		return "", err
+	}
+	if err := al.branches.Add(base, branch.Branch{
+		Name:    name,
+		Parent:  branch.NameOf(mark.Session),
+		From:    messageID,
+		Created: time.Now(),
+	}); err != nil {
+		return "", err
+	}
+	logger.InfoCF("agent", "Conversation branched", map[string]any{
+		"session_key": key,
+		"from":        mark.Session,
+		"message_id":  messageID,
+		"messages":    len(forked),
+	})
+	return name, nil
+}
+
+// locateTurn returns the index of the marked user message in history. The
+// recorded index is tried first; after history compaction the message has
+// moved towards the start, so earlier positions are searched by content.
+func locateTurn(history []providers.Message, mark branch.Mark) int {
+	matches := func(i int) bool {
+		return history[i].Role == "user" && branch.HashText(history[i].Content) == mark.Hash
+	}
+	for i := min(mark.Index, len(history)-1); i >= 0; i-- {
+		if matches(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// turnEnd returns the index just past the turn that starts at start, which
+// is where the next user message begins.
+func turnEnd(history []providers.Message, start int) int {
+	for i := start + 1; i < len(history); i++ {
+		if history[i].Role == "user" {
+			return i
+		}
+	}
+	return len(history)
+}
+
+func nextBranchName(store *branch.Store, base string) string {
+	for n := 1; ; n++ {
+		if name := fmt.Sprintf("b%d", n); !store.Has(base, name) {
+			return name
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestProcessMessage_BranchFromEarlierMessage(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	send := func(messageID, content string) string {
+		t.Helper()
+		response, err := al.processMessage(ctx, bus.InboundMessage{
+			Channel:   "discord",
+			SenderID:  "u1",
+			ChatID:    "c1",
+			MessageID: messageID,
+			Content:   content,
+		})
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", content, err)
+		}
+		return response
+	}
+	userTurns := func() []string {
+		var turns []string
+		for _, m := range provider.lastMessages {
+			if m.Role == "user" {
+				turns = append(turns, m.Content)
+			}
+		}
+		return turns
+	}
+
+	send("101", "first question")
+	send("102", "second question")
+	send("103", "third question")
+
+	reply := send("", "/branch https://discord.com/channels/1/2/102 alt")
+	if !strings.Contains(reply, "Created branch alt") {
+		t.Fatalf("/branch reply = %q", reply)
+	}
+	send("104", "different follow-up")
+	if got := strings.Join(userTurns(), "|"); got != "first question|second question|different follow-up" {
+		t.Fatalf("branch history = %q", got)
+	}
+
+	if reply := send("", "/branch switch main"); reply != "Switched to branch main." {
+		t.Fatalf("/branch switch reply = %q", reply)
+	}
+	send("105", "back on main")
+	if got := strings.Join(userTurns(), "|"); got != "first question|second question|third question|back on main" {
+		t.Fatalf("main history = %q", got)
+	}
+
+	// Messages sent on a branch can be branched from too.
+	if reply := send("", "/branch 104"); !strings.Contains(reply, "Created branch b1") {
+		t.Fatalf("nested /branch reply = %q", reply)
+	}
+	if reply := send("", "/branch list"); !strings.Contains(reply, "* b1 (from alt at message 104") {
+		t.Fatalf("/branch list reply = %q", reply)
+	}
+	if reply := send("", "/branch 999"); !strings.Contains(reply, "not part of this conversation") {
+		t.Fatalf("unknown message reply = %q", reply)
+	}
+}
@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"encoding/json"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
+)
+
+const (
+	// messageOverheadTokens covers the role and separators every message
+	// is wrapped in.
+	messageOverheadTokens = 4
+	// imageTokens is charged per attached image. Images are scaled to fit
+	// 1568px, which Claude bills at about 1600 tokens and OpenAI at less.
+	imageTokens = 1600
+)
+
+// tokens returns the agent's tokenizer, the heuristic if none is set.
+func (a *AgentInstance) tokens() tokenizer.Tokenizer {
+	if a.Tokenizer == nil {
+		return tokenizer.Heuristic{}
+	}
+	return a.Tokenizer
+}
+
+// countMessageTokens counts the tokens messages take up in a request.
+func countMessageTokens(tok tokenizer.Tokenizer, messages []providers.Message) int {
+	n := 0
+	for _, m := range messages {
+		n += countMessage(tok, m)
+	}
+	return n
+}
+
+func countMessage(tok tokenizer.Tokenizer, m providers.Message) int {
+	n := messageOverheadTokens + tok.Count(m.Content) + len(m.Media)*imageTokens
+	for _, tc := range m.ToolCalls {
+		n += tok.Count(tc.Name)
+		if tc.Function != nil && tc.Arguments == nil {
+			n += tok.Count(tc.Function.Arguments)
+		} else if args, err := json.Marshal(tc.Arguments); err == nil {
+			n += tok.Count(string(args))
+		}
+	}
+	return n
+}
+
+// countToolTokens counts the tokens the tool definitions take up.
+func countToolTokens(tok tokenizer.Tokenizer, tools []providers.ToolDefinition) int {
+	if len(tools) == 0 {
+		return 0
+	}
+	defs, err := json.Marshal(tools)
+	if err != nil {
+		return 0
+	}
+	return tok.Count(string(defs))
+}
+
+// fitContextWindow drops the oldest turns of the conversation until the
+// request fits in the agent's context window with reserve tokens left for
+// the reply. The system prompt and the current turn are always kept, and
+// whole turns are dropped so that no tool result loses its call. It
+// returns messages unchanged when the context window is not configured.
+func fitContextWindow(
+	agent *AgentInstance,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	reserve int,
+) []providers.Message {
+	if !agent.trimToContext || len(messages) == 0 {
+		return messages
+	}
+	if agent.ReserveTokens > 0 {
+		reserve = agent.ReserveTokens
+	}
+	budget := agent.ContextWindow - reserve
+	tok := agent.tokens()
+
+	start := 0
+	if messages[0].Role == "system" {
+		start = 1
+	}
+	total := countToolTokens(tok, tools) + countMessageTokens(tok, messages)
+	if total <= budget {
+		return messages
+	}
+
+	// The current turn starts at the last user message.
+	current := len(messages) - 1
+	for current > start && messages[current].Role != "user" {
+		current--
+	}
+	cut := start
+	for total > budget && cut < current {
+		// Drop everything up to the next user message.
+		next := cut + 1
+		for next < current && messages[next].Role != "user" {
+			next++
+		}
+		for _, m := range messages[cut:next] {
+			total -= countMessage(tok, m)
+		}
+		cut = next
+	}
+	if cut == start {
+		return messages
+	}
+
+	logger.InfoCF("agent", "Trimmed history to fit the context window",
+		map[string]any{
+			"agent_id":       agent.ID,
+			"dropped":        cut - start,
+			"tokens":         total,
+			"budget":         budget,
+			"context_window": agent.ContextWindow,
+		})
+	trimmed := make([]providers.Message, 0, start+len(messages)-cut)
+	trimmed = append(trimmed, messages[:start]...)
+	return append(trimmed, messages[cut:]...)
+}
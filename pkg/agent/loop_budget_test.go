@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
+)
+
+func TestFitContextWindow_DropsOldestTurns(t *testing.T) {
+	long := strings.Repeat("x", 500) // 200 tokens with the heuristic
+	messages := []providers.Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: long},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "1", Name: "read_file"}}},
+		{Role: "tool", Content: long, ToolCallID: "1"},
+		{Role: "assistant", Content: "done"},
+		{Role: "user", Content: long},
+		{Role: "assistant", Content: "ok"},
+		{Role: "user", Content: "now"},
+	}
+	agent := &AgentInstance{
+		ContextWindow: 1000,
+		Tokenizer:     tokenizer.Heuristic{},
+		trimToContext: true,
+	}
+
+	got := fitContextWindow(agent, messages, nil, 600)
+	if len(got) != 4 || got[0].Role != "system" || got[1].Content != long || got[3].Content != "now" {
+		t.Fatalf("trimmed to %d messages, want the system prompt and the last two turns: %+v", len(got), got)
+	}
+
+	// A reserve that leaves room for everything keeps everything.
+	if got := fitContextWindow(agent, messages, nil, 100); len(got) != len(messages) {
+		t.Errorf("kept %d messages, want all %d", len(got), len(messages))
+	}
+
+	// The current turn is never dropped, even if it does not fit.
+	if got := fitContextWindow(agent, messages, nil, 1000); got[len(got)-1].Content != "now" || len(got) != 2 {
+		t.Errorf("kept %+v, want the system prompt and the current turn", got)
+	}
+
+	// Without a configured context window nothing is trimmed.
+	agent.trimToContext = false
+	if got := fitContextWindow(agent, messages, nil, 1000); len(got) != len(messages) {
+		t.Errorf("kept %d messages without a context window, want all", len(got))
+	}
+}
+
+func TestFitContextWindow_ReserveTokensOverridesMaxTokens(t *testing.T) {
+	messages := []providers.Message{
+		{Role: "user", Content: strings.Repeat("x", 500)},
+		{Role: "assistant", Content: "ok"},
+		{Role: "user", Content: "now"},
+	}
+	agent := &AgentInstance{ContextWindow: 1000, ReserveTokens: 900, trimToContext: true}
+
+	if got := fitContextWindow(agent, messages, nil, 0); len(got) != 1 {
+		t.Errorf("kept %d messages, want only the current turn", len(got))
+	}
+}
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"errors"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/calendar"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// errLinkInGroup keeps sign-in links out of group chats, where anyone who
+// clicked first would link their account to the requester.
+var errLinkInGroup = errors.New("send /calendar link in a direct message so only you get the sign-in link")
+
+// SetCalendarLinker injects the Google account linker used by the calendar
+// tool and /calendar.
+func (al *AgentLoop) SetCalendarLinker(l *calendar.Linker) {
+	al.calendarLinker = l
+
+	registry := al.GetRegistry()
+	registry.ForEachTool("calendar", func(t tools.Tool) {
+		if ct, ok := t.(*tools.CalendarTool); ok {
+			ct.SetLinker(l)
+		}
+	})
+}
+
+// addCalendarRuntime exposes account linking for the sender to /calendar.
+func (al *AgentLoop) addCalendarRuntime(rt *commands.Runtime, msg bus.InboundMessage) {
+	l := al.calendarLinker
+	if l == nil {
+		return
+	}
+	user := calendar.UserKey(msg.Channel, msg.SenderID)
+	rt.CalendarLinked = func() bool {
+		return l.Linked(user)
+	}
+	rt.CalendarLink = func() (string, error) {
+		if msg.Peer.Kind != "" && msg.Peer.Kind != "direct" {
+			return "", errLinkInGroup
+		}
+		return l.AuthURL(user)
+	}
+	rt.CalendarUnlink = func() (bool, error) {
+		return l.Unlink(user)
+	}
+}
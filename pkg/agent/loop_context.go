@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/facts"
+)
+
+// errContextInGroup keeps the breakdown, which names the memory and history
+// the bot holds, out of group chats.
+var errContextInGroup = errors.New("send /context in a direct message; it shows what the bot remembers")
+
+// Groups of the /context breakdown.
+const (
+	contextGroupSystem  = "System prompt"
+	contextGroupTools   = "Tools"
+	contextGroupHistory = "History"
+)
+
+// contextSections estimates the parts of the next request of the session of
+// opts, built the way runAgentLoop builds it.
+func (al *AgentLoop) contextSections(agent *AgentInstance, opts *processOptions) []commands.ContextSection {
+	var sections []commands.ContextSection
+	add := func(group, name, text string) {
+		if text != "" {
+			sections = append(sections, commands.ContextSection{Group: group, Name: name, Tokens: facts.EstimateTokens(text)})
+		}
+	}
+
+	for _, s := range agent.ContextBuilder.PromptSections() {
+		add(contextGroupSystem, s.Name, s.Text)
+	}
+	add(contextGroupSystem, "time and session",
+		agent.ContextBuilder.buildDynamicContext(opts.Channel, opts.ChatID, opts.SenderID, opts.SenderDisplayName))
+	add(contextGroupSystem, "conversation summary", agent.Sessions.GetSummary(opts.SessionKey))
+	add(contextGroupSystem, "your preferences", al.turnPrefs(*opts).Instructions())
+	add(contextGroupSystem, "guild facts", al.guildFactsPrompt(*opts))
+
+	defs := agent.Tools.ToProviderDefsFor(opts.Channel)
+	if data, err := json.Marshal(defs); err == nil && len(defs) > 0 {
+		add(contextGroupTools, fmt.Sprintf("%d definitions", len(defs)), string(data))
+	}
+
+	history := sanitizeHistoryForProvider(agent.Sessions.GetHistory(opts.SessionKey))
+	var chat, toolResults []byte
+	turns, messages := 0, 0
+	for _, m := range history {
+		if m.Role == "tool" {
+			toolResults = append(toolResults, m.Content...)
+			continue
+		}
+		if m.Role == "user" {
+			turns++
+		}
+		messages++
+		chat = append(chat, m.Content...)
+		for _, tc := range m.ToolCalls {
+			if tc.Function != nil {
+				chat = append(chat, tc.Function.Arguments...)
+			}
+		}
+	}
+	add(contextGroupHistory, countOf(messages, "message")+" in "+countOf(turns, "turn"), string(chat))
+	add(contextGroupHistory, "tool results and retrieved content", string(toolResults))
+	return sections
+}
+
+func countOf(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// addContextRuntime exposes the breakdown of the next request to /context in
+// direct messages.
+func (al *AgentLoop) addContextRuntime(
+	rt *commands.Runtime,
+	msg bus.InboundMessage,
+	agent *AgentInstance,
+	opts *processOptions,
+) {
+	if agent == nil || agent.Sessions == nil || opts == nil || opts.SessionKey == "" {
+		return
+	}
+	rt.InspectContext = func() ([]commands.ContextSection, int, error) {
+		if msg.Peer.Kind != "" && msg.Peer.Kind != "direct" {
+			return nil, 0, errContextInGroup
+		}
+		return al.contextSections(agent, opts), agent.ContextWindow, nil
+	}
+}
@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestContextCommand_Breakdown(t *testing.T) {
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "AGENTS.md"), []byte("Be brief."), 0o644)
+	os.MkdirAll(filepath.Join(workspace, "memory"), 0o755)
+	os.WriteFile(filepath.Join(workspace, "memory", "MEMORY.md"), []byte(strings.Repeat("likes tea ", 50)), 0o644)
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), usageProvider{})
+	defer al.Close()
+	agent := al.GetRegistry().GetDefaultAgent()
+	agent.Sessions.SetHistory("agent:main:main", []providers.Message{
+		{Role: "user", Content: "find the docs"},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "c1", Name: "knowledge_search", Function: &providers.FunctionCall{Name: "knowledge_search", Arguments: `{"q":"docs"}`}}}},
+		{Role: "tool", ToolCallID: "c1", Content: strings.Repeat("chunk ", 100)},
+		{Role: "assistant", Content: "Here they are."},
+	})
+
+	reply, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "telegram", SenderID: "alice", ChatID: "alice", Content: "!context",
+		Peer: bus.Peer{Kind: "direct", ID: "alice"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"context window", "AGENTS.md: 4", "long-term memory: 200", "3 messages in 1 turn", "tool results and retrieved content: 240"} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("reply missing %q:\n%s", want, reply)
+		}
+	}
+
+	reply, _ = al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "telegram", SenderID: "alice", ChatID: "-100", Content: "/context",
+		Peer: bus.Peer{Kind: "group", ID: "-100"},
+	})
+	if reply != errContextInGroup.Error() {
+		t.Errorf("group reply = %q", reply)
+	}
+}
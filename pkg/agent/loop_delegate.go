@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// registerDelegateTools gives every agent of registry the delegate tool,
+// whose sub-agents pick their tools from the agent's own and may use the
+// models its conversations could switch to.
+func (al *AgentLoop) registerDelegateTools(cfg *config.Config, registry *AgentRegistry) {
+	if !cfg.Tools.IsToolEnabled("delegate") {
+		return
+	}
+	dc := cfg.Tools.Delegate
+	for _, agentID := range registry.ListAgentIDs() {
+		agent, ok := registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		agent.Tools.Register(tools.NewDelegateTool(tools.DelegateOptions{
+			Provider: agent.Provider,
+			Model:    agent.Model,
+			Tools:    agent.Tools,
+			ResolveModel: func(name string) (providers.LLMProvider, string, error) {
+				if _, err := cfg.GetModelConfig(name); err != nil {
+					return nil, "", fmt.Errorf("model %s is not in the config", name)
+				}
+				if err := al.checkModelForAgent(agent, name); err != nil {
+					return nil, "", err
+				}
+				mp, err := agent.providerForModel(cfg, name)
+				if err != nil {
+					return nil, "", err
+				}
+				return mp.provider, mp.modelID, nil
+			},
+			LLMOptions: map[string]any{
+				"max_tokens":  agent.MaxTokens,
+				"temperature": agent.Temperature,
+			},
+			MaxDepth:       dc.MaxDepth,
+			MaxConcurrency: dc.MaxConcurrency,
+			MaxTasks:       dc.MaxTasks,
+			MaxIterations:  dc.MaxIterations,
+		}))
+	}
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/dispatch"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/notify"
+)
+
+// defaultDrainTimeout bounds the wait for queued messages on shutdown when
+// the config sets none.
+const defaultDrainTimeout = 30 * time.Second
+
+// startDispatcher starts the workers that answer inbound messages. Without
+// a worker count in the config the agent answers one message at a time.
+func (al *AgentLoop) startDispatcher(ctx context.Context) *dispatch.Dispatcher {
+	cfg := al.GetConfig().Dispatch
+	d := dispatch.New(ctx, dispatch.Options{
+		Workers:    cfg.Workers,
+		QueueSize:  cfg.QueueSize,
+		MaxPending: cfg.MaxPending,
+	}, al.handleInbound)
+	if old := al.dispatcher.Swap(d); old != nil {
+		go old.Shutdown(context.Background())
+	}
+	return d
+}
+
+// submitInbound queues msg for a worker. A message over the queue limits is
+// answered with the busy notification, and one received while shutting down
+// is saved for the restart. Messages of internal channels, such as subagent
+// results, are always queued.
+func (al *AgentLoop) submitInbound(ctx context.Context, d *dispatch.Dispatcher, msg bus.InboundMessage) {
+	var err error
+	if constants.IsInternalChannel(msg.Channel) {
+		err = d.Add(msg)
+	} else {
+		err = d.Submit(msg)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, dispatch.ErrFull):
+		logger.WarnCF("agent", "Message queue full, turning message away", map[string]any{
+			"channel": msg.Channel,
+			"chat_id": msg.ChatID,
+			"pending": d.Pending(),
+		})
+		renderer, _ := notify.NewRenderer(al.GetConfig().Notifications.Templates)
+		al.bus.PublishOutbound(ctx, bus.OutboundMessage{
+			Channel:   msg.Channel,
+			ChatID:    msg.ChatID,
+			Content:   renderer.RenderIn(al.uiLanguage(msg), notify.EventQueueFull, msg.Channel, notify.Vars{}),
+			RequestID: msg.RequestID,
+		})
+	default:
+		// Received while shutting down: answered after the restart
+		al.checkpointInterrupted(msg)
+	}
+}
+
+// drainInbound stops the dispatcher and waits for the messages it holds to
+// be answered, up to the configured drain timeout. The ones still waiting
+// then are saved to be answered after the restart.
+func (al *AgentLoop) drainInbound() {
+	d := al.dispatcher.Load()
+	if d == nil {
+		return
+	}
+	timeout := defaultDrainTimeout
+	if s := al.GetConfig().Dispatch.DrainTimeoutSeconds; s > 0 {
+		timeout = time.Duration(s) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if dropped, err := d.Shutdown(ctx); err != nil {
+		logger.WarnCF("agent", "Gave up waiting for queued messages", map[string]any{
+			"dropped": len(dropped),
+			"timeout": timeout.String(),
+		})
+		al.checkpointInterrupted(dropped...)
+	}
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/dispatch"
+)
+
+func TestDispatch_AnswersAndDrains(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Dispatch: config.DispatchConfig{Workers: 2, QueueSize: 5},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, usageProvider{})
+	defer al.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		al.Run(ctx)
+		close(done)
+	}()
+	for _, chat := range []string{"a", "b", "a"} {
+		msgBus.PublishInbound(ctx, bus.InboundMessage{Channel: "telegram", SenderID: "u", ChatID: chat, Content: "hi"})
+	}
+
+	got := map[string]int{}
+	for range 3 {
+		select {
+		case out := <-msgBus.OutboundChan():
+			if out.Content != "Hi there" {
+				t.Errorf("reply to %s = %q", out.ChatID, out.Content)
+			}
+			got[out.ChatID]++
+		case <-time.After(5 * time.Second):
+			t.Fatalf("replies so far %v, want 3", got)
+		}
+	}
+	if got["a"] != 2 || got["b"] != 1 {
+		t.Errorf("replies per chat = %v", got)
+	}
+
+	al.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after Stop")
+	}
+}
+
+func TestDispatch_BusyReplyWhenFull(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace: t.TempDir(),
+				Model:     "test-model",
+			},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, usageProvider{})
+	defer al.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	d := dispatch.New(context.Background(), dispatch.Options{Workers: 1, QueueSize: 1},
+		func(context.Context, bus.InboundMessage) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-release
+		})
+	defer d.Shutdown(context.Background())
+	defer close(release)
+
+	msg := bus.InboundMessage{Channel: "telegram", ChatID: "a", Content: "hi"}
+	al.submitInbound(context.Background(), d, msg)
+	<-started
+	al.submitInbound(context.Background(), d, msg)
+	al.submitInbound(context.Background(), d, msg)
+
+	select {
+	case out := <-msgBus.OutboundChan():
+		if out.ChatID != "a" || out.Content != "I'm busy with other messages right now. Please try again in a moment." {
+			t.Errorf("busy reply = %+v", out)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no busy reply for the message over the queue")
+	}
+
+	internal := bus.InboundMessage{Channel: "system", ChatID: "telegram:a", Content: "done"}
+	al.submitInbound(context.Background(), d, internal)
+	al.submitInbound(context.Background(), d, internal)
+	if n := d.Pending(); n != 3 {
+		t.Errorf("Pending = %d, want internal messages queued past the limit", n)
+	}
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"github.com/sipeed/picoclaw/pkg/branch"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// isEdited reports whether msg is a new version of an earlier user message.
+func isEdited(msg bus.InboundMessage) bool {
+	return inboundMetadata(msg, metadataKeyEdited) == "true"
+}
+
+// rollbackEditedTurn prepares the session for regenerating the answer to an
+// edited prompt. This only happens with regenerate_on_edit enabled, or for
+// a 🔁 reaction, and when the prompt is the latest user message of the
+// session: its turn is removed from history, so the edited content is
+// answered as if sent in its place. A regenerated turn is kept as a reply
+// variant. It reports whether the edited message should be processed.
+func (al *AgentLoop) rollbackEditedTurn(agent *AgentInstance, sessionKey string, msg bus.InboundMessage) bool {
+	regenerate := isRegenerated(msg)
+	if (!al.cfg.Agents.Defaults.RegenerateOnEdit && !regenerate) || commands.HasCommandPrefix(msg.Content) {
+		return false
+	}
+	mark, err := al.branches.Find(branch.BaseKey(sessionKey), msg.MessageID)
+	if err != nil || mark.Session != sessionKey || (!regenerate && mark.Hash == branch.HashText(msg.Content)) {
+		return false
+	}
+	history := agent.Sessions.GetHistory(sessionKey)
+	start := locateTurn(history, mark)
+	if start < 0 || turnEnd(history, start) != len(history) {
+		logger.DebugCF("agent", "Ignoring edit of an earlier message", map[string]any{
+			"session_key": sessionKey,
+			"message_id":  msg.MessageID,
+		})
+		return false
+	}
+
+	if regenerate {
+		al.keepReplacedVariant(sessionKey, msg.MessageID, history[start:])
+	}
+	agent.Sessions.SetHistory(sessionKey, history[:start])
+	agent.Sessions.Save(sessionKey)
+	logger.InfoCF("agent", "Regenerating reply to edited message", map[string]any{
+		"session_key": sessionKey,
+		"message_id":  msg.MessageID,
+		"removed":     len(history) - start,
+	})
+	return true
+}
@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestProcessMessage_RegenerateOnEdit(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				RegenerateOnEdit:  true,
+			},
+		},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	send := func(messageID, content string, edited bool) string {
+		t.Helper()
+		msg := bus.InboundMessage{
+			Channel:   "telegram",
+			SenderID:  "u1",
+			ChatID:    "c1",
+			MessageID: messageID,
+			Content:   content,
+		}
+		if edited {
+			msg.Metadata = map[string]string{metadataKeyEdited: "true"}
+		}
+		response, err := al.processMessage(ctx, msg)
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", content, err)
+		}
+		return response
+	}
+	history := func() string {
+		agent := al.GetRegistry().GetDefaultAgent()
+		var turns []string
+		for _, m := range agent.Sessions.GetHistory("agent:main:main") {
+			turns = append(turns, m.Role+":"+m.Content)
+		}
+		return strings.Join(turns, "|")
+	}
+
+	send("1", "first", false)
+	send("2", "secnd", false)
+	if got := send("2", "second", true); got == "" {
+		t.Fatal("edit of the latest message was not answered")
+	}
+	if got := history(); strings.Count(got, "user:") != 2 || !strings.Contains(got, "user:second") ||
+		strings.Contains(got, "secnd") {
+		t.Fatalf("history after edit = %q", got)
+	}
+
+	if got := send("1", "frist", true); got != "" {
+		t.Fatalf("edit of an earlier message got a reply %q", got)
+	}
+	if got := send("2", "second", true); got != "" {
+		t.Fatalf("edit without a content change got a reply %q", got)
+	}
+
+	cfg.Agents.Defaults.RegenerateOnEdit = false
+	if got := send("2", "second!", true); got != "" {
+		t.Fatalf("edit with regenerate_on_edit off got a reply %q", got)
+	}
+}
+
+func TestRegenerateTurn_ReplacesLatestTurn(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, &recordingProvider{})
+	ctx := context.Background()
+
+	send := func(msg bus.InboundMessage) string {
+		t.Helper()
+		response, err := al.processMessage(ctx, msg)
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", msg.Content, err)
+		}
+		return response
+	}
+	regenerate := func(turnID string) bus.InboundMessage {
+		t.Helper()
+		if err := al.regenerateTurn(ctx, turnID, bus.SenderInfo{PlatformID: "u2"}); err != nil {
+			t.Fatalf("regenerateTurn(%q) error = %v", turnID, err)
+		}
+		return <-msgBus.InboundChan()
+	}
+
+	for i, content := range []string{"first", "second"} {
+		msg := bus.InboundMessage{
+			Channel:   "discord",
+			SenderID:  "u1",
+			ChatID:    "c1",
+			MessageID: string(rune('1' + i)),
+			Content:   content,
+			RequestID: "req-" + content,
+		}
+		if id := al.rememberTurn(msg, send(msg)); id != msg.RequestID {
+			t.Fatalf("rememberTurn = %q without feedback enabled", id)
+		}
+	}
+
+	again := regenerate("req-second")
+	if !isEdited(again) || !isRegenerated(again) || again.RequestID == "req-second" || again.Content != "second" {
+		t.Fatalf("regenerated message = %+v", again)
+	}
+	if got := send(again); got == "" {
+		t.Fatal("regenerating the latest reply got no answer")
+	}
+	history := al.GetRegistry().GetDefaultAgent().Sessions.GetHistory("agent:main:main")
+	var users int
+	for _, m := range history {
+		if m.Role == "user" {
+			users++
+		}
+	}
+	if users != 2 {
+		t.Errorf("history has %d user messages after regenerating, want 2", users)
+	}
+
+	if got := send(regenerate("req-first")); got != "" {
+		t.Errorf("regenerating an earlier reply got %q", got)
+	}
+	if err := al.regenerateTurn(ctx, "req-unknown", bus.SenderInfo{}); err == nil {
+		t.Error("unknown turns cannot be regenerated")
+	}
+}
+
+func TestSummarizeTurn_AsksInTheSameConversation(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, &recordingProvider{})
+	ctx := context.Background()
+
+	msg := bus.InboundMessage{
+		Channel:   "discord",
+		SenderID:  "u1",
+		ChatID:    "c1",
+		Peer:      bus.Peer{Kind: "group", ID: "c1"},
+		MessageID: "1",
+		Content:   "explain everything",
+		RequestID: "req-1",
+		Metadata:  map[string]string{metadataKeyEdited: "true"},
+	}
+	al.rememberTurn(msg, "a very long reply")
+
+	if err := al.summarizeTurn(ctx, "req-1", bus.SenderInfo{PlatformID: "u1"}); err != nil {
+		t.Fatalf("summarizeTurn error = %v", err)
+	}
+	got := <-msgBus.InboundChan()
+	if got.Content != channels.LongOutputSummaryPrompt || got.Peer != msg.Peer || got.SenderID != "u1" {
+		t.Errorf("summary request = %+v", got)
+	}
+	if got.RequestID == "req-1" || got.MessageID != "" || isEdited(got) {
+		t.Errorf("summary request must be a new message, got %+v", got)
+	}
+	if msg.Metadata[metadataKeyEdited] != "true" {
+		t.Error("the remembered prompt must not change")
+	}
+	if err := al.summarizeTurn(ctx, "req-unknown", bus.SenderInfo{}); err == nil {
+		t.Error("unknown turns cannot be summarized")
+	}
+}
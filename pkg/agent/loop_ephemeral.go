@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+var errEphemeralUnsupported = errors.New("this conversation is ephemeral, but the session store cannot keep it in memory")
+
+// defaultEphemeralIdle is how long an ephemeral session lives without a
+// message when idle_minutes is unset.
+const defaultEphemeralIdle = 30 * time.Minute
+
+// ephemeralSessions is implemented by session stores that can keep a
+// session in memory only, such as session.EphemeralStore.
+type ephemeralSessions interface {
+	MarkEphemeral(key string, ttl time.Duration)
+}
+
+// isEphemeral reports whether msg belongs to a conversation that must not be
+// persisted or logged.
+func (al *AgentLoop) isEphemeral(msg bus.InboundMessage) bool {
+	return channels.IsEphemeral(al.GetConfig().Ephemeral, msg.Channel, msg.ChatID, msg.SenderID, msg.Sender)
+}
+
+// keepEphemeral moves the session of an ephemeral turn to memory, restarting
+// its idle timer.
+func (al *AgentLoop) keepEphemeral(agent *AgentInstance, sessionKey string) error {
+	store, ok := agent.Sessions.(ephemeralSessions)
+	if !ok {
+		logger.ErrorCF("agent", "Refusing ephemeral message: session store cannot keep it in memory", map[string]any{
+			"agent_id":    agent.ID,
+			"session_key": sessionKey,
+		})
+		return errEphemeralUnsupported
+	}
+	idle := defaultEphemeralIdle
+	if minutes := al.GetConfig().Ephemeral.IdleMinutes; minutes > 0 {
+		idle = time.Duration(minutes) * time.Minute
+	}
+	store.MarkEphemeral(sessionKey, idle)
+	return nil
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/analytics"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/feedback"
+)
+
+func TestEphemeral_ConversationIsNotPersisted(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Ephemeral: config.EphemeralConfig{Enabled: true, Users: []string{"alice"}},
+		Analytics: config.AnalyticsConfig{Enabled: true},
+		Feedback:  config.FeedbackConfig{Enabled: true},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), usageProvider{})
+	defer al.Close()
+	agent := al.GetRegistry().GetDefaultAgent()
+
+	msg := bus.InboundMessage{
+		Channel: "telegram", SenderID: "alice", ChatID: "chat-1", Content: "my secret plan", RequestID: "req_1",
+	}
+	reply, err := al.processMessage(context.Background(), msg)
+	if err != nil || reply != "Hi there" {
+		t.Fatalf("processMessage = %q, %v", reply, err)
+	}
+	if id := al.rememberTurn(msg, reply); id != "" {
+		t.Errorf("ephemeral turn can be rated as %q", id)
+	}
+	al.recordAnalytics(nil, msg, analytics.OutcomeOK)
+
+	sessionKey := "agent:main:main"
+	if history := agent.Sessions.GetHistory(sessionKey); len(history) != 2 {
+		t.Fatalf("in-memory history has %d messages, want 2", len(history))
+	}
+	filepath.WalkDir(workspace, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if data, _ := os.ReadFile(path); strings.Contains(string(data), "my secret plan") {
+			t.Errorf("ephemeral content written to %s", path)
+		}
+		return nil
+	})
+	if recs, _ := al.analytics.Records(context.Background(), time.Time{}, time.Time{}); len(recs) != 0 {
+		t.Errorf("ephemeral message recorded in analytics: %+v", recs)
+	}
+	if recs, _ := feedback.ReadRecords(feedback.Path(workspace), feedback.Filter{}); len(recs) != 0 {
+		t.Errorf("ephemeral turn stored for feedback: %+v", recs)
+	}
+
+}
+
+func TestIsEphemeral(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{Defaults: config.AgentDefaults{Workspace: t.TempDir(), Model: "test-model"}},
+		Ephemeral: config.EphemeralConfig{
+			Enabled:  true,
+			Channels: []string{"whatsapp", "telegram:-100123"},
+			Users:    []string{"discord:42"},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), usageProvider{})
+	for _, tt := range []struct {
+		msg  bus.InboundMessage
+		want bool
+	}{
+		{bus.InboundMessage{Channel: "whatsapp", ChatID: "1"}, true},
+		{bus.InboundMessage{Channel: "telegram", ChatID: "-100123"}, true},
+		{bus.InboundMessage{Channel: "telegram", ChatID: "-100999"}, false},
+		{bus.InboundMessage{Channel: "discord", SenderID: "42", Sender: bus.SenderInfo{
+			Platform: "discord", PlatformID: "42", CanonicalID: "discord:42",
+		}}, true},
+		{bus.InboundMessage{Channel: "system", ChatID: "whatsapp:1"}, false},
+	} {
+		if got := al.isEphemeral(tt.msg); got != tt.want {
+			t.Errorf("isEphemeral(%s:%s) = %v, want %v", tt.msg.Channel, tt.msg.ChatID, got, tt.want)
+		}
+	}
+
+	cfg.Ephemeral.Enabled = false
+	if al.isEphemeral(bus.InboundMessage{Channel: "whatsapp"}) {
+		t.Error("disabled ephemeral mode still matched")
+	}
+}
@@ -0,0 +1,281 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/analytics"
+	"github.com/sipeed/picoclaw/pkg/branch"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/calendar"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/database"
+	"github.com/sipeed/picoclaw/pkg/erasure"
+	"github.com/sipeed/picoclaw/pkg/feedback"
+	"github.com/sipeed/picoclaw/pkg/flagged"
+	"github.com/sipeed/picoclaw/pkg/guardrail"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/prefs"
+	"github.com/sipeed/picoclaw/pkg/recall"
+	"github.com/sipeed/picoclaw/pkg/respcache"
+	"github.com/sipeed/picoclaw/pkg/search"
+	"github.com/sipeed/picoclaw/pkg/shadow"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// SetCronService injects the scheduler whose reminders ForgetUser removes.
+func (al *AgentLoop) SetCronService(cs *cron.CronService) {
+	al.cronService = cs
+}
+
+// ForgetUser deletes the data stored about subject and saves the receipt in
+// the workspace. Stores that are disabled now are still cleared of what they
+// recorded while they were enabled. Group conversations are shared with
+// other users and are kept; long-term memory is shared by the whole agent
+// and is kept too.
+func (al *AgentLoop) ForgetUser(subject erasure.Subject, requestedBy string) *erasure.Receipt {
+	receipt := erasure.NewReceipt(subject, requestedBy)
+	cfg := al.GetConfig()
+	workspace := cfg.WorkspacePath()
+	if agent := al.GetRegistry().GetDefaultAgent(); agent != nil {
+		workspace = agent.Workspace
+	}
+	channel, sender := subject.Channel, subject.SenderID
+
+	dm := al.forgetDirectSession(receipt, subject)
+	if dm != "" {
+		n, err := al.forgetSearchIndex(workspace, dm)
+		receipt.Add("search index", erasure.ActionDeleted, n, err)
+	}
+
+	memories, documents, err := al.forgetMemories(workspace, dm, channel+":"+sender)
+	receipt.Add("memories", erasure.ActionDeleted, memories, err)
+	receipt.Add("knowledge base documents", erasure.ActionDeleted, documents, err)
+
+	if al.prefs != nil {
+		key := prefs.UserKey(channel, sender)
+		n := 0
+		if !al.prefs.Get(key).IsZero() {
+			n = 1
+		}
+		err := al.prefs.Put(key, prefs.Prefs{})
+		receipt.Add("preferences", erasure.ActionDeleted, n, err)
+	}
+
+	receipt.Add("offline queue", erasure.ActionDeleted, al.offline.Forget(channel, sender), nil)
+
+	responses := al.responses
+	if responses == nil {
+		responses = respcache.New(cfg.ResponseCache, respcache.Path(workspace))
+	}
+	n, err := responses.ForgetSender(channel, sender)
+	receipt.Add("response cache", erasure.ActionDeleted, n, err)
+
+	transcripts := al.transcripts
+	if transcripts == nil {
+		transcripts = transcript.NewStore(transcript.Dir(workspace), cfg.Transcripts)
+	}
+	n, err = transcripts.ForgetSender(channel, sender)
+	receipt.Add("tool-call transcripts", erasure.ActionDeleted, n, err)
+
+	ratings := al.feedback
+	if ratings == nil {
+		ratings = feedback.NewStore(feedback.Path(workspace))
+	}
+	n, err = ratings.ForgetUser(channel, sender, identity.BuildCanonicalID(channel, sender))
+	receipt.Add("feedback", erasure.ActionDeleted, n, err)
+
+	n, err = flagged.NewStore(flagged.Path(workspace)).ForgetSender(channel, sender)
+	receipt.Add("flagged conversations", erasure.ActionDeleted, n, err)
+
+	n, err = guardrail.NewLog(guardrail.LogPath(workspace)).ForgetSender(channel, sender)
+	receipt.Add("moderation log", erasure.ActionDeleted, n, err)
+
+	n, err = shadow.NewLog(shadow.LogPath(workspace)).ForgetSender(channel, sender)
+	receipt.Add("shadow log", erasure.ActionDeleted, n, err)
+
+	n, err = al.forgetAnalytics(workspace, channel, sender)
+	receipt.Add("analytics", erasure.ActionDeleted, n, err)
+
+	n, err = al.forgetCalendarAccount(cfg.WorkspacePath(), channel, sender)
+	receipt.Add("calendar account", erasure.ActionDeleted, n, err)
+
+	scheduler := al.cronService
+	if scheduler == nil {
+		scheduler = cron.NewCronService(cron.Path(cfg.WorkspacePath()), nil)
+	}
+	n, err = scheduler.RemoveJobsBy(channel, sender)
+	receipt.Add("reminders", erasure.ActionDeleted, n, err)
+
+	tracker := al.usage
+	if tracker == nil {
+		tracker = usage.NewTracker(usage.Path(workspace))
+	}
+	n, err = tracker.Anonymize(channel, sender)
+	receipt.Add("usage records", erasure.ActionAnonymized, n, err)
+	receipt.Keep("Token counts of usage records stay, without chat or sender, for cost reports.")
+	receipt.Keep("Long-term memory (memory/MEMORY.md) is shared by every user and was not changed.")
+	receipt.Keep("Group conversations are shared with other members and were not changed.")
+
+	if err := erasure.Save(erasure.Path(workspace), receipt); err != nil {
+		logger.ErrorCF("agent", "Failed to save deletion receipt", map[string]any{
+			"receipt_id": receipt.ID,
+			"error":      err.Error(),
+		})
+	}
+	logger.InfoCF("agent", "Deleted user data", map[string]any{
+		"receipt_id":   receipt.ID,
+		"requested_by": requestedBy,
+		"failed":       receipt.Failed(),
+	})
+	return receipt
+}
+
+// forgetDirectSession clears the direct-message session of subject, its
+// branches, and its generation settings, and returns its key. A DM session
+// shared by all users (session.dm_scope "main") is kept, and "" returned.
+func (al *AgentLoop) forgetDirectSession(receipt *erasure.Receipt, subject erasure.Subject) string {
+	route, agent, err := al.resolveMessageRoute(bus.InboundMessage{
+		Channel:  subject.Channel,
+		SenderID: subject.SenderID,
+		ChatID:   subject.SenderID,
+		Peer:     bus.Peer{Kind: "direct"},
+	})
+	if err != nil {
+		receipt.Add("conversations", erasure.ActionDeleted, 0, err)
+		return ""
+	}
+	base := route.SessionKey
+	if base == route.MainSessionKey {
+		receipt.Keep("The direct-message conversation is shared by every user (session.dm_scope is \"main\") and was not changed.")
+		return ""
+	}
+
+	branches, err := al.branches.Remove(base)
+	keys := []string{base}
+	for _, b := range branches {
+		keys = append(keys, branch.Key(base, b.Name))
+	}
+	n := 0
+	for _, key := range keys {
+		if len(agent.Sessions.GetHistory(key)) == 0 && agent.Sessions.GetSummary(key) == "" {
+			continue
+		}
+		agent.Sessions.SetHistory(key, nil)
+		agent.Sessions.SetSummary(key, "")
+		if saveErr := agent.Sessions.Save(key); saveErr != nil && err == nil {
+			err = saveErr
+		}
+		n++
+	}
+	if al.tuning != nil && err == nil {
+		err = al.tuning.Put(base, tuning.Settings{})
+	}
+	receipt.Add("conversations", erasure.ActionDeleted, n, err)
+	return base
+}
+
+// forgetAnalytics deletes the analytics of sender. With analytics disabled,
+// a database left from when they were enabled is still cleared.
+func (al *AgentLoop) forgetAnalytics(workspace, channel, sender string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store := al.analytics
+	if store == nil {
+		path := database.Path(workspace)
+		if _, err := os.Stat(path); err != nil {
+			return 0, nil
+		}
+		opened, err := analytics.Open(ctx, path)
+		if err != nil {
+			return 0, err
+		}
+		defer opened.Close()
+		store = opened
+	}
+	return store.ForgetSender(ctx, channel, sender)
+}
+
+// forgetCalendarAccount unlinks the Google account of the sender. Without a
+// running linker the links file is edited directly, so an account linked
+// while calendar linking was enabled is still removed.
+func (al *AgentLoop) forgetCalendarAccount(workspace, channel, sender string) (int, error) {
+	user := calendar.UserKey(channel, sender)
+	var unlinked bool
+	var err error
+	if al.calendarLinker != nil {
+		unlinked, err = al.calendarLinker.Unlink(user)
+	} else {
+		unlinked, err = calendar.UnlinkFile(calendar.LinksPath(workspace), user)
+	}
+	if unlinked {
+		return 1, err
+	}
+	return 0, err
+}
+
+// forgetSearchIndex removes the messages of session from the search index.
+// With the index disabled, a database left from when it was enabled is still
+// cleared.
+func (al *AgentLoop) forgetSearchIndex(workspace, session string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	index := al.searchIndex
+	if index == nil {
+		path := database.Path(workspace)
+		if _, err := os.Stat(path); err != nil {
+			return 0, nil
+		}
+		opened, err := search.OpenIndex(ctx, path)
+		if err != nil {
+			return 0, err
+		}
+		defer opened.Close()
+		index = opened
+	}
+	return index.ForgetSession(ctx, session)
+}
+
+// forgetMemories deletes the memories and knowledge base documents of the
+// direct-message session dm and those addedBy saved in other conversations.
+// With recall disabled, a database left from when it was enabled is still
+// cleared.
+func (al *AgentLoop) forgetMemories(workspace, dm, addedBy string) (memories, documents int, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store := al.recall
+	if store == nil {
+		path := database.Path(workspace)
+		if _, err := os.Stat(path); err != nil {
+			return 0, 0, nil
+		}
+		// Deleting needs no embeddings.
+		opened, err := recall.Open(ctx, path, nil, "")
+		if err != nil {
+			return 0, 0, err
+		}
+		defer opened.Close()
+		store = opened
+	}
+	if memories, err = store.Forget(ctx, dm, addedBy); err != nil {
+		return memories, 0, err
+	}
+	documents, err = store.ForgetDocuments(ctx, dm, addedBy)
+	return memories, documents, err
+}
+
+// addForgetMeRuntime lets the sender delete their own data with /forgetme.
+func (al *AgentLoop) addForgetMeRuntime(rt *commands.Runtime, msg bus.InboundMessage) {
+	if msg.Channel == "" || msg.Channel == "system" || msg.SenderID == "" {
+		return
+	}
+	subject := erasure.Subject{Channel: msg.Channel, SenderID: msg.SenderID}
+	rt.ForgetMe = func() string {
+		return al.ForgetUser(subject, "user").Text()
+	}
+}
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/calendar"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/erasure"
+	"github.com/sipeed/picoclaw/pkg/prefs"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func TestForgetMe_DeletesUserData(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Session:       config.SessionConfig{DMScope: "per-channel-peer"},
+		Analytics:     config.AnalyticsConfig{Enabled: true},
+		ResponseCache: config.ResponseCacheConfig{Enabled: true},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), usageProvider{})
+	defer al.Close()
+	agent := al.GetRegistry().GetDefaultAgent()
+
+	dm := func(sender, content string) bus.InboundMessage {
+		return bus.InboundMessage{
+			Channel: "telegram", SenderID: sender, ChatID: sender, Content: content,
+			Peer: bus.Peer{Kind: "direct", ID: sender}, RequestID: "req_" + sender,
+		}
+	}
+	for _, msg := range []bus.InboundMessage{dm("7", "my address is 1 Main St"), dm("8", "hello")} {
+		if _, err := al.processMessage(context.Background(), msg); err != nil {
+			t.Fatalf("processMessage: %v", err)
+		}
+		al.recordAnalytics(nil, msg, "ok")
+	}
+	al.prefs.Put(prefs.UserKey("telegram", "7"), prefs.Prefs{Verbosity: "brief"})
+	al.usage.Record(usage.Record{Channel: "telegram", ChatID: "7", SenderID: "7", Model: "test-model"})
+
+	reply, handled := al.handleCommand(context.Background(), dm("7", "/forgetme confirm"), agent, &processOptions{
+		Channel: "telegram", ChatID: "7", SenderID: "7", SessionKey: "agent:main:telegram:direct:7",
+	})
+	if !handled || !strings.Contains(reply, "Deletion receipt") || !strings.Contains(reply, "conversations: 1 deleted") {
+		t.Fatalf("/forgetme confirm = %q, %v", reply, handled)
+	}
+
+	if h := agent.Sessions.GetHistory("agent:main:telegram:direct:7"); len(h) != 0 {
+		t.Errorf("session of the user survived: %+v", h)
+	}
+	if h := agent.Sessions.GetHistory("agent:main:telegram:direct:8"); len(h) == 0 {
+		t.Error("session of another user was deleted")
+	}
+	if !al.prefs.Get(prefs.UserKey("telegram", "7")).IsZero() {
+		t.Error("preferences survived")
+	}
+	if n := al.responses.Len(); n != 1 {
+		t.Errorf("cached replies left = %d, want the other user's", n)
+	}
+	recs, _ := al.analytics.Records(context.Background(), time.Time{}, time.Time{})
+	if len(recs) != 1 || recs[0].SenderID != "8" {
+		t.Errorf("analytics left = %+v", recs)
+	}
+	records, _ := al.usage.Load(time.Time{})
+	for _, r := range records {
+		if r.SenderID == "7" {
+			t.Errorf("usage record not anonymized: %+v", r)
+		}
+	}
+	data, err := os.ReadFile(erasure.Path(workspace))
+	if err != nil || strings.Contains(string(data), "Main St") || !strings.Contains(string(data), `"requested_by":"user"`) {
+		t.Errorf("receipt log = %s, %v", data, err)
+	}
+}
+
+func TestForgetUser_KeepsSharedDirectSession(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{Defaults: config.AgentDefaults{Workspace: t.TempDir(), Model: "test-model"}},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), usageProvider{})
+	defer al.Close()
+	agent := al.GetRegistry().GetDefaultAgent()
+	agent.Sessions.AddMessage("agent:main:main", "user", "shared")
+
+	receipt := al.ForgetUser(erasure.Subject{Channel: "telegram", SenderID: "7"}, "admin")
+	if len(agent.Sessions.GetHistory("agent:main:main")) != 1 {
+		t.Error("shared main session was cleared")
+	}
+	if !strings.Contains(strings.Join(receipt.Kept, "\n"), "dm_scope") || receipt.Failed() {
+		t.Errorf("receipt = %+v", receipt)
+	}
+}
+
+func TestForgetUser_UnlinksCalendarAndRemovesReminders(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{Defaults: config.AgentDefaults{Workspace: workspace, Model: "test-model"}},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), usageProvider{})
+	defer al.Close()
+
+	// Neither the linker nor the scheduler is running, so the files are
+	// edited directly.
+	links := `{"links":{"telegram:7":{"access_token":"a"},"telegram:8":{"access_token":"b"}}}`
+	if err := os.MkdirAll(filepath.Dir(calendar.LinksPath(workspace)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(calendar.LinksPath(workspace), []byte(links), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	jobs := cron.NewCronService(cron.Path(workspace), nil)
+	everyMS := int64(60000)
+	every := cron.CronSchedule{Kind: "every", EveryMS: &everyMS}
+	for _, sender := range []string{"7", "8"} {
+		job, err := jobs.AddJob("remind "+sender, every, "stretch", true, "telegram", "-100")
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.CreatedBy = sender
+		jobs.UpdateJob(job)
+	}
+
+	receipt := al.ForgetUser(erasure.Subject{Channel: "telegram", SenderID: "7"}, "admin")
+	counts := map[string]int{}
+	for _, item := range receipt.Items {
+		counts[item.Store] = item.Count
+	}
+	if counts["calendar account"] != 1 || counts["reminders"] != 1 || receipt.Failed() {
+		t.Errorf("receipt = %+v", receipt)
+	}
+	data, _ := os.ReadFile(calendar.LinksPath(workspace))
+	if strings.Contains(string(data), "telegram:7") || !strings.Contains(string(data), "telegram:8") {
+		t.Errorf("links file = %s", data)
+	}
+	left := cron.NewCronService(cron.Path(workspace), nil).ListJobs(true)
+	if len(left) != 1 || left[0].CreatedBy != "8" {
+		t.Errorf("jobs left = %+v", left)
+	}
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"github.com/sipeed/picoclaw/pkg/chatexport"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// exportAgent returns the agent with agentID, or the default agent when
+// agentID is empty.
+func (al *AgentLoop) exportAgent(agentID string) (*AgentInstance, error) {
+	registry := al.GetRegistry()
+	if agentID == "" {
+		if agent := registry.GetDefaultAgent(); agent != nil && agent.Sessions != nil {
+			return agent, nil
+		}
+		return nil, chatexport.ErrUnknownAgent
+	}
+	agent, ok := registry.GetAgent(agentID)
+	if !ok || agent.Sessions == nil {
+		return nil, chatexport.ErrUnknownAgent
+	}
+	return agent, nil
+}
+
+// ExportConversation returns the transcript of a session of an agent, the
+// default agent when agentID is empty.
+func (al *AgentLoop) ExportConversation(agentID, sessionKey string) (chatexport.Transcript, error) {
+	agent, err := al.exportAgent(agentID)
+	if err != nil {
+		return chatexport.Transcript{}, err
+	}
+	history := agent.Sessions.GetHistory(sessionKey)
+	summary := agent.Sessions.GetSummary(sessionKey)
+	if len(history) == 0 && summary == "" {
+		return chatexport.Transcript{}, chatexport.ErrUnknownSession
+	}
+	return chatexport.New(agent.ID, sessionKey, summary, history), nil
+}
+
+// ImportConversation seeds a session of an agent with an exported
+// conversation. A session that already has messages or a summary is left
+// alone, so an import never overwrites a conversation.
+func (al *AgentLoop) ImportConversation(agentID, sessionKey string, t chatexport.Transcript) error {
+	agent, err := al.exportAgent(agentID)
+	if err != nil {
+		return err
+	}
+	if len(agent.Sessions.GetHistory(sessionKey)) > 0 || agent.Sessions.GetSummary(sessionKey) != "" {
+		return chatexport.ErrSessionExists
+	}
+	if len(t.Messages) > 0 {
+		agent.Sessions.AddFullMessage(sessionKey, t.Messages[0])
+		agent.Sessions.SetHistory(sessionKey, t.Messages)
+	}
+	agent.Sessions.SetSummary(sessionKey, t.Summary)
+	if err := agent.Sessions.Save(sessionKey); err != nil {
+		return err
+	}
+	logger.InfoCF("agent", "Conversation imported", map[string]any{
+		"agent_id":    agent.ID,
+		"session_key": sessionKey,
+		"from":        t.SessionKey,
+		"messages":    len(t.Messages),
+	})
+	return nil
+}
+
+// addExportRuntime exposes the history of this conversation to /export.
+func (al *AgentLoop) addExportRuntime(rt *commands.Runtime, agent *AgentInstance, opts *processOptions) {
+	if agent == nil || agent.Sessions == nil || opts == nil || opts.SessionKey == "" {
+		return
+	}
+	session := opts.SessionKey
+	rt.ExportConversation = func() chatexport.Transcript {
+		return chatexport.New(agent.ID, session, agent.Sessions.GetSummary(session), agent.Sessions.GetHistory(session))
+	}
+}
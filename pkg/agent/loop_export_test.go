@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/chatexport"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestExportAndImportConversation(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{Workspace: t.TempDir(), Model: "test-model", MaxTokens: 4096},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &recordingProvider{})
+	defer al.Close()
+	agent := al.GetRegistry().GetDefaultAgent()
+	agent.Sessions.SetHistory("old", []providers.Message{
+		{Role: "user", Content: "hello"},
+		{Role: "assistant", Content: "hi there"},
+	})
+	agent.Sessions.SetSummary("old", "greetings")
+
+	if _, err := al.ExportConversation("", "missing"); !errors.Is(err, chatexport.ErrUnknownSession) {
+		t.Errorf("export of an empty session = %v", err)
+	}
+	if _, err := al.ExportConversation("nope", "old"); !errors.Is(err, chatexport.ErrUnknownAgent) {
+		t.Errorf("export from an unknown agent = %v", err)
+	}
+	export, err := al.ExportConversation("", "old")
+	if err != nil || export.Agent != agent.ID || len(export.Messages) != 2 || export.Summary != "greetings" {
+		t.Fatalf("export = %+v, %v", export, err)
+	}
+
+	if err := al.ImportConversation("", "old", export); !errors.Is(err, chatexport.ErrSessionExists) {
+		t.Errorf("import over a session = %v", err)
+	}
+	if err := al.ImportConversation("", "new", export); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if h := agent.Sessions.GetHistory("new"); len(h) != 2 || h[1].Content != "hi there" {
+		t.Errorf("imported history = %+v", h)
+	}
+	if s := agent.Sessions.GetSummary("new"); s != "greetings" {
+		t.Errorf("imported summary = %q", s)
+	}
+
+	msg := bus.InboundMessage{Channel: "telegram", SenderID: "7", ChatID: "7", Content: "/export"}
+	opts := &processOptions{Channel: "telegram", ChatID: "7", SenderID: "7", SessionKey: "new"}
+	if reply, _ := al.handleCommand(context.Background(), msg, agent, opts); !strings.Contains(reply, "## User\n\nhello") {
+		t.Errorf("/export = %q", reply)
+	}
+}
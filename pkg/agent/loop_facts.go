@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/facts"
+	"github.com/sipeed/picoclaw/pkg/identity"
+)
+
+// factsBudget returns the configured token budget for one guild's facts.
+func (al *AgentLoop) factsBudget() int {
+	if n := al.GetConfig().Facts.MaxTokens; n > 0 {
+		return n
+	}
+	return facts.DefaultMaxTokens
+}
+
+// guildFactsPrompt returns the pinned facts section for the guild of a turn.
+func (al *AgentLoop) guildFactsPrompt(opts processOptions) string {
+	if opts.GuildID == "" {
+		return ""
+	}
+	return facts.Prompt(al.facts.List(facts.GuildKey(opts.Channel, opts.GuildID)), al.factsBudget())
+}
+
+// isFactOperator reports whether the sender of msg is listed in
+// facts.operators or administers the chat's tenant.
+func (al *AgentLoop) isFactOperator(msg bus.InboundMessage) bool {
+	if al.isTenantAdmin(msg) {
+		return true
+	}
+	for _, op := range al.GetConfig().Facts.Operators {
+		if identity.MatchAllowed(msg.Sender, op) || (msg.SenderID != "" && strings.TrimSpace(op) == msg.SenderID) {
+			return true
+		}
+	}
+	return false
+}
+
+// addFactsRuntime exposes the facts of the message's guild to /fact.
+func (al *AgentLoop) addFactsRuntime(rt *commands.Runtime, msg bus.InboundMessage, opts *processOptions) {
+	if al.facts == nil || opts == nil || opts.GuildID == "" {
+		return
+	}
+	guild := facts.GuildKey(msg.Channel, opts.GuildID)
+	rt.IsFactOperator = func() bool {
+		return al.isFactOperator(msg)
+	}
+	rt.ListFacts = func() []facts.Fact {
+		return al.facts.List(guild)
+	}
+	rt.AddFact = func(text string) (facts.Fact, error) {
+		return al.facts.Add(guild, text, msg.Channel+":"+msg.SenderID, al.factsBudget())
+	}
+	rt.RemoveFact = func(id int) error {
+		return al.facts.Remove(guild, id)
+	}
+}
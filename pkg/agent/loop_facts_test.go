@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestProcessMessage_GuildFacts(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Facts: config.FactsConfig{MaxTokens: 200, Operators: []string{"mod"}},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	send := func(sender, guild, content string) string {
+		t.Helper()
+		response, err := al.processMessage(ctx, bus.InboundMessage{
+			Channel:  "discord",
+			SenderID: sender,
+			ChatID:   "c-" + guild,
+			Content:  content,
+			Peer:     bus.Peer{Kind: "channel", ID: "c-" + guild},
+			Metadata: map[string]string{metadataKeyGuildID: guild},
+		})
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", content, err)
+		}
+		return response
+	}
+
+	if reply := send("member", "g1", "!fact add Never share API keys."); !strings.Contains(reply, "operators") {
+		t.Fatalf("member add reply = %q", reply)
+	}
+	if reply := send("mod", "g1", "!fact add Never share API keys."); reply != "Pinned fact #1." {
+		t.Fatalf("operator add reply = %q", reply)
+	}
+
+	send("member", "g1", "hello")
+	if system := provider.lastMessages[0].Content; !strings.Contains(system, "- Never share API keys.") {
+		t.Fatalf("system prompt lacks the guild fact:\n%s", system)
+	}
+	send("member", "g2", "hello")
+	if system := provider.lastMessages[0].Content; strings.Contains(system, "Server Facts") {
+		t.Fatal("another guild picked up the fact")
+	}
+}
@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/feedback"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// rememberTurn keeps the prompt of msg and the agent's response so that
+// reactions to the reply can be saved with them or regenerate it. It returns
+// the turn ID to send with the reply, or "" when the reply cannot be reacted
+// to.
+func (al *AgentLoop) rememberTurn(msg bus.InboundMessage, response string) string {
+	if response == "" || msg.RequestID == "" || msg.Channel == "system" || commands.HasCommandPrefix(msg.Content) ||
+		al.isEphemeral(msg) {
+		return ""
+	}
+	al.prompts.remember(msg)
+	al.feedback.Remember(feedback.Turn{
+		ID:       msg.RequestID,
+		Channel:  msg.Channel,
+		ChatID:   msg.ChatID,
+		SenderID: msg.SenderID,
+		Prompt:   msg.Content,
+		Response: response,
+	})
+	return msg.RequestID
+}
+
+// raterID returns the ID a rating by sender is saved under.
+func raterID(sender bus.SenderInfo) string {
+	if sender.CanonicalID != "" {
+		return sender.CanonicalID
+	}
+	return sender.PlatformID
+}
+
+// recordFeedback saves a user's rating of the turn turnID.
+func (al *AgentLoop) recordFeedback(turnID, rating string, sender bus.SenderInfo) {
+	ratedBy := raterID(sender)
+	rec, err := al.feedback.Rate(turnID, rating, ratedBy)
+	if err != nil {
+		logger.WarnCF("agent", "Failed to record feedback", map[string]any{
+			"turn_id": turnID,
+			"error":   err.Error(),
+		})
+		return
+	}
+	logger.InfoCF("agent", "Recorded feedback", map[string]any{
+		"turn_id": turnID,
+		"channel": rec.Channel,
+		"rating":  rating,
+	})
+}
+
+// addFeedbackRuntime lets /feedback rate the latest reply in the chat of msg.
+func (al *AgentLoop) addFeedbackRuntime(rt *commands.Runtime, msg bus.InboundMessage) {
+	if al.feedback == nil || msg.Channel == "" || msg.Channel == "system" {
+		return
+	}
+	rt.RateLatestReply = func(rating, comment string) error {
+		ratedBy := raterID(msg.Sender)
+		if ratedBy == "" {
+			ratedBy = msg.SenderID
+		}
+		rec, err := al.feedback.RateLatest(msg.Channel, msg.ChatID, rating, ratedBy, comment)
+		if err != nil {
+			return err
+		}
+		logger.InfoCF("agent", "Recorded feedback", map[string]any{
+			"turn_id": rec.ID,
+			"channel": rec.Channel,
+			"rating":  rating,
+		})
+		return nil
+	}
+}
+
+// FeedbackAPI returns the feedback export API, or nil when feedback is off
+// or neither it nor the admin API has an api_token.
+func (al *AgentLoop) FeedbackAPI() *feedback.Handler {
+	cfg := al.GetConfig()
+	tokens := cfg.APITokens(cfg.Feedback.APIToken)
+	if al.feedback == nil || len(tokens) == 0 {
+		return nil
+	}
+	return feedback.NewHandler(tokens, al.feedback.Path())
+}
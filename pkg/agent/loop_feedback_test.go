@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/feedback"
+)
+
+func TestRememberTurn_RecordsRatingsWithPrompt(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Feedback: config.FeedbackConfig{Enabled: true},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+
+	msg := bus.InboundMessage{Channel: "discord", ChatID: "c1", SenderID: "u1", Content: "hi", RequestID: "req-1"}
+	turnID := al.rememberTurn(msg, "hello")
+	if turnID != "req-1" {
+		t.Fatalf("turn ID = %q", turnID)
+	}
+	for _, skipped := range []bus.InboundMessage{
+		{Channel: "discord", ChatID: "c1", Content: "/help", RequestID: "req-2"},
+		{Channel: "system", ChatID: "discord:c1", Content: "done", RequestID: "req-3"},
+	} {
+		if id := al.rememberTurn(skipped, "reply"); id != "" {
+			t.Errorf("rememberTurn(%q) = %q, want no turn", skipped.Content, id)
+		}
+	}
+
+	al.recordFeedback(turnID, feedback.RatingUp, bus.SenderInfo{CanonicalID: "discord:u9"})
+	records, err := feedback.ReadRecords(feedback.Path(workspace), feedback.Filter{})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("records = %+v, %v", records, err)
+	}
+	if r := records[0]; r.Prompt != "hi" || r.Response != "hello" || r.RatedBy != "discord:u9" || r.Rating != feedback.RatingUp {
+		t.Errorf("record = %+v", r)
+	}
+}
+
+func TestProcessMessage_FeedbackCommandRatesLatestReply(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Feedback: config.FeedbackConfig{Enabled: true},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	send := func(content, requestID string) string {
+		t.Helper()
+		msg := bus.InboundMessage{
+			Channel:   "discord",
+			SenderID:  "u1",
+			ChatID:    "c1",
+			Content:   content,
+			RequestID: requestID,
+		}
+		reply, err := al.processMessage(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", content, err)
+		}
+		al.rememberTurn(msg, reply) // as the run loop does on sending the reply
+		return reply
+	}
+
+	if reply := send("/feedback up", "req-0"); reply != "There is no recent reply in this chat to rate." {
+		t.Errorf("reply before any turn = %q", reply)
+	}
+	answer := send("what is 2+2?", "req-1")
+	if reply := send("/feedback down it skipped the working", "req-2"); reply != "Thanks for the feedback!" {
+		t.Fatalf("reply = %q", reply)
+	}
+	records, err := feedback.ReadRecords(feedback.Path(workspace), feedback.Filter{})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("records = %+v, %v", records, err)
+	}
+	r := records[0]
+	if r.ID != "req-1" || r.Prompt != "what is 2+2?" || r.Response != answer || r.Rating != feedback.RatingDown ||
+		r.Comment != "it skipped the working" || r.RatedBy != "u1" {
+		t.Errorf("record = %+v", r)
+	}
+}
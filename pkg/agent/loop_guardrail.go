@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/analytics"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/flagged"
+	"github.com/sipeed/picoclaw/pkg/guardrail"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// newGuard builds the guardrail services from config, or returns nil when
+// guardrails are disabled or their config is invalid.
+func newGuard(cfg config.GuardrailsConfig) *guardrail.Guard {
+	guard, err := guardrail.NewGuardFromConfig(cfg)
+	if err != nil {
+		logger.ErrorCF("guardrail", "Guardrails disabled: invalid config", map[string]any{"error": err.Error()})
+		return nil
+	}
+	return guard
+}
+
+// guardInput checks the user message of a turn. It returns the reply for a
+// blocked message, or "" when the turn may go on; a redacted message goes on
+// redacted.
+func (al *AgentLoop) guardInput(ctx context.Context, opts *processOptions) string {
+	verdict := al.guard.Check(ctx, guardrail.Request{
+		Stage:    guardrail.StageInput,
+		Text:     opts.UserMessage,
+		Channel:  opts.Channel,
+		ChatID:   opts.ChatID,
+		SenderID: opts.SenderID,
+	})
+	if verdict.Action == "" {
+		return ""
+	}
+	if verdict.Action == guardrail.ActionRedact {
+		opts.UserMessage = verdict.Redact(opts.UserMessage)
+	}
+	al.recordGuardrail(*opts, guardrail.StageInput, verdict, "")
+	if verdict.Action != guardrail.ActionBlock {
+		return ""
+	}
+	analytics.FromContext(ctx).MarkBlocked()
+	return al.GetConfig().Guardrails.Message
+}
+
+// guardOutput checks the reply of a turn before it is saved and sent, and
+// returns the reply to use in its place.
+func (al *AgentLoop) guardOutput(ctx context.Context, opts processOptions, reply string) string {
+	if al.guard == nil || constants.IsInternalChannel(opts.Channel) {
+		return reply
+	}
+	verdict := al.guard.Check(ctx, guardrail.Request{
+		Stage:    guardrail.StageOutput,
+		Text:     reply,
+		Prompt:   opts.UserMessage,
+		Channel:  opts.Channel,
+		ChatID:   opts.ChatID,
+		SenderID: opts.SenderID,
+	})
+	if verdict.Action == "" {
+		return reply
+	}
+	if verdict.Action == guardrail.ActionRedact {
+		reply = verdict.Redact(reply)
+	}
+	al.recordGuardrail(opts, guardrail.StageOutput, verdict, reply)
+	if verdict.Action != guardrail.ActionBlock {
+		return reply
+	}
+	analytics.FromContext(ctx).MarkBlocked()
+	return al.GetConfig().Guardrails.Message
+}
+
+// moderationExcerpt bounds the text kept in the moderation log.
+const moderationExcerpt = 200
+
+// recordGuardrail logs a verdict, writes it to the moderation log, and adds
+// the exchange to the flagged conversations, except in ephemeral
+// conversations. Redacted texts are recorded redacted.
+func (al *AgentLoop) recordGuardrail(opts processOptions, stage string, verdict guardrail.Verdict, reply string) {
+	logger.InfoCF("guardrail", "Guardrail triggered", map[string]any{
+		"stage":       stage,
+		"action":      verdict.Action,
+		"service":     verdict.Service,
+		"reason":      verdict.Reason,
+		"session_key": opts.SessionKey,
+		"request_id":  opts.RequestID,
+	})
+	entry := guardrail.LogEntry{
+		Stage:     stage,
+		Action:    verdict.Action,
+		Service:   verdict.Service,
+		Reason:    verdict.Reason,
+		Channel:   opts.Channel,
+		ChatID:    opts.ChatID,
+		SenderID:  opts.SenderID,
+		RequestID: opts.RequestID,
+	}
+	if !opts.Ephemeral {
+		entry.Excerpt = opts.UserMessage
+		if stage == guardrail.StageOutput {
+			entry.Excerpt = reply
+		}
+		entry.Excerpt = utils.Truncate(entry.Excerpt, moderationExcerpt)
+	}
+	if err := al.moderationLog.Record(entry); err != nil {
+		logger.WarnCF("guardrail", "Failed to write moderation log", map[string]any{"error": err.Error()})
+	}
+	if opts.Ephemeral {
+		return
+	}
+	err := al.flagged.Record(flagged.Flag{
+		Source:     flagged.SourceModeration,
+		Reason:     verdict.Service + " " + verdict.Action + " (" + stage + "): " + verdict.Reason,
+		Channel:    opts.Channel,
+		ChatID:     opts.ChatID,
+		SenderID:   opts.SenderID,
+		SessionKey: opts.SessionKey,
+		Prompt:     opts.UserMessage,
+		Response:   reply,
+	})
+	if err != nil {
+		logger.WarnCF("guardrail", "Failed to record flagged conversation", map[string]any{"error": err.Error()})
+	}
+}
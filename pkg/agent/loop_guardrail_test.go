@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/flagged"
+	"github.com/sipeed/picoclaw/pkg/guardrail"
+)
+
+func TestGuardrails_BlockAndFlag(t *testing.T) {
+	// The service blocks messages saying "forbidden" and replies to "trap".
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req guardrail.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		unsafe := (req.Stage == guardrail.StageInput && strings.Contains(req.Text, "forbidden")) ||
+			(req.Stage == guardrail.StageOutput && req.Prompt == "trap")
+		json.NewEncoder(w).Encode(guardrail.Result{Unsafe: unsafe, Reason: "test"})
+	}))
+	defer srv.Close()
+
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Guardrails: config.GuardrailsConfig{
+			Enabled: true,
+			Message: "Blocked.",
+			Services: []config.GuardrailServiceConfig{
+				{Name: "custom", Type: "http", URL: srv.URL},
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), usageProvider{})
+	defer al.Close()
+	agent := al.GetRegistry().GetDefaultAgent()
+	send := func(content string) string {
+		reply, err := al.processMessage(context.Background(), bus.InboundMessage{
+			Channel: "telegram", SenderID: "alice", ChatID: "chat-1", Content: content,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	if reply := send("something forbidden"); reply != "Blocked." {
+		t.Errorf("blocked input got %q", reply)
+	}
+	if history := agent.Sessions.GetHistory("agent:main:main"); len(history) != 0 {
+		t.Errorf("blocked message reached the session: %v", history)
+	}
+	if reply := send("trap"); reply != "Blocked." {
+		t.Errorf("blocked reply got %q", reply)
+	}
+	history := agent.Sessions.GetHistory("agent:main:main")
+	if len(history) != 2 || history[1].Content != "Blocked." {
+		t.Errorf("session after a blocked reply: %v", history)
+	}
+	if reply := send("hello"); reply != "Hi there" {
+		t.Errorf("safe message got %q", reply)
+	}
+
+	flags, err := flagged.NewStore(flagged.Path(workspace)).Load(time.Time{}, time.Now().Add(time.Minute))
+	if err != nil || len(flags) != 2 {
+		t.Fatalf("flags = %v, %v", flags, err)
+	}
+	if f := flags[1]; f.Source != flagged.SourceModeration || f.Prompt != "trap" || f.Response != "Hi there" {
+		t.Errorf("output flag = %+v", f)
+	}
+}
+
+func TestGuardrails_RedactPerChannelAndModerationLog(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Guardrails: config.GuardrailsConfig{
+			Enabled: true,
+			Log:     true,
+			Services: []config.GuardrailServiceConfig{{
+				Type:     "rules",
+				Keywords: []string{"hunter2"},
+				Stages:   []string{guardrail.StageInput},
+				Action:   "redact",
+				Channels: map[string]string{"cli": "off"},
+			}},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), usageProvider{})
+	defer al.Close()
+	agent := al.GetRegistry().GetDefaultAgent()
+
+	for _, channel := range []string{"telegram", "cli"} {
+		if _, err := al.processMessage(context.Background(), bus.InboundMessage{
+			Channel: channel, SenderID: "alice", ChatID: "chat-1", Content: "my password is hunter2",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history := agent.Sessions.GetHistory("agent:main:main")
+	if len(history) != 4 || history[0].Content != "my password is [redacted]" ||
+		history[2].Content != "my password is hunter2" {
+		t.Fatalf("session = %v", history)
+	}
+
+	data, err := os.ReadFile(guardrail.LogPath(workspace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var entry guardrail.LogEntry
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &entry) != nil {
+		t.Fatalf("moderation log = %q", data)
+	}
+	if entry.Action != guardrail.ActionRedact || entry.Channel != "telegram" ||
+		entry.Excerpt != "my password is [redacted]" {
+		t.Errorf("log entry = %+v", entry)
+	}
+}
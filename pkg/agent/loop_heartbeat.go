@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+)
+
+// heartbeatInterval is how often a running turn shows it is still alive.
+// The status, once shown, is edited at most every statusInterval, which keeps
+// clear of the platforms' edit rate limits.
+var (
+	heartbeatInterval = 5 * time.Second
+	statusInterval    = 15 * time.Second
+)
+
+// maxTurnTimes is how many turn durations the ETA is estimated from.
+const maxTurnTimes = 100
+
+// turnDurations keeps how long the latest turns took.
+type turnDurations struct {
+	mu    sync.Mutex
+	times []time.Duration
+}
+
+func (d *turnDurations) add(took time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.times = append(d.times, took)
+	if len(d.times) > maxTurnTimes {
+		d.times = d.times[len(d.times)-maxTurnTimes:]
+	}
+}
+
+// eta estimates the time left of a turn that has run for elapsed: the median
+// of the turns that took longer, minus elapsed. It is 0 when fewer than three
+// turns took that long.
+func (d *turnDurations) eta(elapsed time.Duration) time.Duration {
+	d.mu.Lock()
+	var longer []time.Duration
+	for _, t := range d.times {
+		if t > elapsed {
+			longer = append(longer, t)
+		}
+	}
+	d.mu.Unlock()
+	if len(longer) < 3 {
+		return 0
+	}
+	slices.Sort(longer)
+	return longer[len(longer)/2] - elapsed
+}
+
+// turnHeartbeat keeps the chat of a turn showing that a reply is on its way:
+// the typing indicator stays on, and a turn that runs longer than
+// progress.status_after_seconds shows how long it has taken and how long it
+// may still take, below the tool progress if there is any.
+type turnHeartbeat struct {
+	al         *AgentLoop
+	opts       processOptions
+	stream     *channels.ReplyStream
+	statusFrom time.Duration
+	start      time.Time
+	done       chan struct{}
+	stopped    chan struct{}
+
+	mu       sync.Mutex
+	progress string
+}
+
+// startHeartbeat starts the heartbeat of the turn opts describes, or returns
+// nil for turns no chat is waiting on. stream is the turn's reply stream,
+// whose text the status must not replace.
+func (al *AgentLoop) startHeartbeat(
+	ctx context.Context,
+	opts processOptions,
+	stream *channels.ReplyStream,
+) *turnHeartbeat {
+	if al.channelManager == nil || opts.Channel == "" || constants.IsInternalChannel(opts.Channel) {
+		return nil
+	}
+	h := &turnHeartbeat{
+		al:         al,
+		opts:       opts,
+		stream:     stream,
+		statusFrom: time.Duration(al.GetConfig().Agents.Defaults.Progress.StatusAfterSeconds) * time.Second,
+		start:      time.Now(),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	go h.run(ctx)
+	return h
+}
+
+// setProgress records the tool progress shown, which the status keeps.
+func (h *turnHeartbeat) setProgress(text string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	h.progress = text
+	h.mu.Unlock()
+}
+
+// Stop ends the heartbeat before the reply is sent, and records how long the
+// turn took for later estimates.
+func (h *turnHeartbeat) Stop() {
+	if h == nil {
+		return
+	}
+	close(h.done)
+	<-h.stopped
+	h.al.turnTimes.add(time.Since(h.start))
+}
+
+func (h *turnHeartbeat) run(ctx context.Context) {
+	defer close(h.stopped)
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	var lastStatus time.Time
+	for {
+		select {
+		case <-ticker.C:
+		case <-h.done:
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		elapsed := time.Since(h.start)
+		status := channels.HeartbeatStatus{Elapsed: elapsed, ETA: h.al.turnTimes.eta(elapsed)}
+		if h.showStatus(elapsed) && time.Since(lastStatus) >= statusInterval {
+			status.Text = h.statusText(status)
+			lastStatus = time.Now()
+		}
+		h.al.channelManager.Heartbeat(ctx, h.opts.Channel, h.opts.ChatID, status)
+	}
+}
+
+// showStatus reports whether the turn has run long enough for a status, and
+// no streamed reply would be overwritten by it.
+func (h *turnHeartbeat) showStatus(elapsed time.Duration) bool {
+	if h.statusFrom <= 0 || elapsed < h.statusFrom {
+		return false
+	}
+	return h.stream == nil || !h.stream.Started()
+}
+
+func (h *turnHeartbeat) statusText(status channels.HeartbeatStatus) string {
+	lang := h.opts.Locale
+	elapsed := formatRetryAfter(lang, status.Elapsed)
+	text := i18n.Sprintf(lang, "Still working… %s so far", elapsed)
+	if status.ETA > 0 {
+		text = i18n.Sprintf(lang, "Still working… %s so far, about %s left", elapsed,
+			formatRetryAfter(lang, status.ETA))
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.progress != "" {
+		return h.progress + "\n" + text
+	}
+	return text
+}
@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/channels"
+)
+
+func TestTurnDurations_ETA(t *testing.T) {
+	var d turnDurations
+	for _, s := range []int{5, 10, 40, 60, 90} {
+		d.add(time.Duration(s) * time.Second)
+	}
+	if got := d.eta(30 * time.Second); got != 30*time.Second {
+		t.Errorf("eta(30s) = %v, want the median of the longer turns minus 30s", got)
+	}
+	if got := d.eta(70 * time.Second); got != 0 {
+		t.Errorf("eta(70s) = %v, want 0 with one longer turn", got)
+	}
+
+	for range maxTurnTimes {
+		d.add(time.Second)
+	}
+	if got := d.eta(0); got != time.Second {
+		t.Errorf("old turns are still counted: eta(0) = %v", got)
+	}
+}
+
+func TestTurnHeartbeat_StatusText(t *testing.T) {
+	h := &turnHeartbeat{statusFrom: 30 * time.Second}
+	if h.showStatus(10 * time.Second) {
+		t.Error("status shown before status_after_seconds")
+	}
+	if !h.showStatus(45 * time.Second) {
+		t.Error("status not shown after status_after_seconds")
+	}
+
+	status := channels.HeartbeatStatus{Elapsed: 45 * time.Second}
+	if got := h.statusText(status); got != "Still working… 45 seconds so far" {
+		t.Errorf("statusText = %q", got)
+	}
+	h.setProgress("Running tests…")
+	status.ETA = 2 * time.Minute
+	if got := h.statusText(status); got != "Running tests…\nStill working… 45 seconds so far, about 2 minutes left" {
+		t.Errorf("statusText with progress = %q", got)
+	}
+	h.opts.Locale = "de"
+	if got := h.statusText(status); got != "Running tests…\nIch arbeite noch… bisher 45 Sekunden, noch etwa 2 Minuten" {
+		t.Errorf("statusText(de) = %q", got)
+	}
+}
@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/branch"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/knowledge"
+	"github.com/sipeed/picoclaw/pkg/recall"
+)
+
+const kbRetries = 2
+
+// addKBRuntime lets /kb manage and ask the knowledge base of the
+// conversation, kept in the recall store. Ephemeral conversations keep none.
+func (al *AgentLoop) addKBRuntime(
+	rt *commands.Runtime, msg bus.InboundMessage, agent *AgentInstance, opts *processOptions,
+) {
+	if al.recall == nil || agent == nil || opts == nil || opts.SessionKey == "" || opts.Ephemeral {
+		return
+	}
+	scope := branch.BaseKey(opts.SessionKey)
+	addedBy := msg.Channel + ":" + msg.SenderID
+
+	rt.AddDocuments = func(ctx context.Context, pageURL string) ([]recall.Document, error) {
+		if pageURL != "" {
+			title, text, err := al.fetchPage(ctx, agent, pageURL)
+			if err != nil {
+				return nil, err
+			}
+			d, err := al.recall.AddDocument(ctx, scope, title, pageURL, addedBy, knowledge.Chunk(text))
+			if err != nil {
+				return nil, err
+			}
+			return []recall.Document{d}, nil
+		}
+
+		docs, tooLarge := al.extractAttachments(msg.Media)
+		if len(docs) == 0 && len(tooLarge) > 0 {
+			return nil, fmt.Errorf("%s is over the %d byte limit", tooLarge[0].Name, al.GetConfig().Attachments.MaxBytes)
+		}
+		var added []recall.Document
+		for _, doc := range docs {
+			d, err := al.recall.AddDocument(ctx, scope, doc.Name, doc.Name, addedBy, knowledge.Chunk(doc.Text))
+			if err != nil {
+				return added, err
+			}
+			added = append(added, d)
+		}
+		return added, nil
+	}
+	rt.ListDocuments = func(ctx context.Context) ([]recall.Document, error) {
+		return al.recall.Documents(ctx, scope)
+	}
+	rt.RemoveDocument = func(ctx context.Context, id int64) error {
+		return al.recall.RemoveDocument(ctx, scope, id)
+	}
+	rt.AskDocuments = func(ctx context.Context, question string) (string, []recall.Passage, error) {
+		cfg := al.GetConfig().Recall
+		topK, minScore := cfg.TopK, cfg.MinScore
+		if topK <= 0 {
+			topK = recall.DefaultTopK
+		}
+		if minScore <= 0 {
+			minScore = recall.DefaultMinScore
+		}
+		searchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		passages, err := al.recall.SearchDocuments(searchCtx, scope, question, topK, minScore)
+		if err != nil || len(passages) == 0 {
+			return "", nil, err
+		}
+		resp, err := al.retryLLMCall(ctx, agent, recall.AskPrompt(question, passages), kbRetries)
+		if err != nil {
+			return "", nil, err
+		}
+		if resp == nil || strings.TrimSpace(resp.Content) == "" {
+			return "", nil, errors.New("empty response from provider")
+		}
+		return resp.Content, passages, nil
+	}
+}
+
+// fetchPage reads a web page with the agent's web_fetch tool, so /kb add
+// follows the same network rules as the agent.
+func (al *AgentLoop) fetchPage(
+	ctx context.Context, agent *AgentInstance, pageURL string,
+) (title, text string, err error) {
+	tool, ok := agent.Tools.Get("web_fetch")
+	if !ok {
+		return "", "", errors.New("web fetching is disabled")
+	}
+	result := tool.Execute(ctx, map[string]any{"url": pageURL})
+	if result == nil {
+		return "", "", errors.New("no response from web_fetch")
+	}
+	if result.IsError {
+		return "", "", errors.New(result.ForLLM)
+	}
+	var page struct {
+		Title string `json:"title"`
+		Text  string `json:"text"`
+	}
+	if err := json.Unmarshal([]byte(result.ForLLM), &page); err != nil {
+		return "", "", err
+	}
+	title = page.Title
+	if title == "" {
+		title = pageURL
+		if u, err := url.Parse(pageURL); err == nil && u.Host != "" {
+			title = u.Host + strings.TrimSuffix(u.Path, "/")
+		}
+	}
+	return title, page.Text, nil
+}
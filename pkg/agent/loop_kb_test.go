@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/database"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/recall"
+)
+
+func TestKB_AddAttachmentAndAsk(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{Workspace: workspace, Model: "test-model", MaxTokens: 4096},
+		},
+		Session: config.SessionConfig{DMScope: "per-channel-peer"},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	defer al.Close()
+	store, err := recall.Open(context.Background(), database.Path(workspace), sameEmbedder{}, "embed")
+	if err != nil {
+		t.Fatalf("recall.Open: %v", err)
+	}
+	al.recall = store
+	mediaStore := media.NewFileMediaStore()
+	al.SetMediaStore(mediaStore)
+	agent := al.GetRegistry().GetDefaultAgent()
+
+	path := filepath.Join(t.TempDir(), "handbook.md")
+	if err := os.WriteFile(path, []byte("The office opens at nine."), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := mediaStore.Store(path, media.MediaMeta{Filename: "handbook.md"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dm := bus.InboundMessage{
+		Channel: "telegram", SenderID: "7", ChatID: "7",
+		Peer: bus.Peer{Kind: "direct", ID: "7"}, Content: "/kb add", Media: []string{ref},
+	}
+	opts := &processOptions{Channel: "telegram", ChatID: "7", SenderID: "7", SessionKey: "agent:main:telegram:direct:7"}
+	reply, _ := al.handleCommand(context.Background(), dm, agent, opts)
+	if reply != "Added to the knowledge base:\n#1 handbook.md (1 parts)" {
+		t.Fatalf("/kb add = %q", reply)
+	}
+
+	dm.Content, dm.Media = "/kb ask when does the office open?", nil
+	reply, _ = al.handleCommand(context.Background(), dm, agent, opts)
+	if reply != "Mock response\n\nSources:\n[1] #1 handbook.md, part 1" {
+		t.Errorf("/kb ask = %q", reply)
+	}
+	prompt := provider.lastMessages[len(provider.lastMessages)-1].Content
+	if !strings.Contains(prompt, "[1] handbook.md, part 1:\nThe office opens at nine.") ||
+		!strings.Contains(prompt, "Question: when does the office open?") {
+		t.Errorf("prompt lacks the retrieved chunk:\n%s", prompt)
+	}
+}
@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+// loadLocales adds the locale bundles in workspace/locales to the built-in
+// ones.
+func loadLocales(workspace string) {
+	dir := filepath.Join(workspace, "locales")
+	if err := i18n.LoadDir(dir); err != nil {
+		logger.WarnCF("agent", "Ignoring unreadable locale bundles", map[string]any{
+			"dir":   dir,
+			"error": err.Error(),
+		})
+	}
+}
+
+// uiLanguage returns the i18n code of the language the bot's own messages to
+// the sender of msg are written in: their /prefs language, then the
+// /config language of their server, then the locale their platform reports,
+// then localization.default_language. "" means English.
+func (al *AgentLoop) uiLanguage(msg bus.InboundMessage) string {
+	var cfg config.LocalizationConfig
+	if c := al.GetConfig(); c != nil {
+		cfg = c.Localization
+	}
+	if code := i18n.Match(al.userPrefs(msg.Channel, msg.SenderID).Language); code != "" {
+		return code
+	}
+	if guild := messageGuildID(msg); guild != "" {
+		if code := al.tuning.Get(tuning.GuildKey(msg.Channel, guild)).Language; code != "" {
+			return code
+		}
+	}
+	if cfg.DetectLocale {
+		if code := i18n.Match(inboundMetadata(msg, channels.MetadataKeyLocale)); code != "" {
+			return code
+		}
+	}
+	return i18n.Match(cfg.DefaultLanguage)
+}
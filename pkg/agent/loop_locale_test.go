@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestProcessMessage_LocalizesBotMessages(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Localization: config.LocalizationConfig{DetectLocale: true, DefaultLanguage: "fr"},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &recordingProvider{})
+
+	send := func(content string, metadata map[string]string) string {
+		t.Helper()
+		reply, err := al.processMessage(context.Background(), bus.InboundMessage{
+			Channel:  "discord",
+			SenderID: "u1",
+			ChatID:   "dm-u1",
+			Content:  content,
+			Peer:     bus.Peer{Kind: "direct", ID: "u1"},
+			Metadata: metadata,
+		})
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", content, err)
+		}
+		return reply
+	}
+
+	// /fact is unavailable outside servers
+	if reply := send("/fact list", nil); reply != "Cette commande n'est pas disponible ici." {
+		t.Errorf("default language reply = %q", reply)
+	}
+	spanish := map[string]string{channels.MetadataKeyLocale: "es-ES"}
+	if reply := send("/fact list", spanish); reply != "Este comando no está disponible aquí." {
+		t.Errorf("detected locale reply = %q", reply)
+	}
+	send("/prefs set language German", spanish)
+	if reply := send("/fact list", spanish); reply != "Dieser Befehl ist hier nicht verfügbar." {
+		t.Errorf("/prefs language should win over the locale, reply = %q", reply)
+	}
+}
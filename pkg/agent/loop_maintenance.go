@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/maintenance"
+)
+
+const queuedNotice = "Your message has been queued and will be answered when maintenance ends."
+
+// SetMaintenance injects the maintenance controller consulted for every
+// inbound message.
+func (al *AgentLoop) SetMaintenance(c *maintenance.Controller) {
+	al.maintenance = c
+}
+
+// maintenanceGate decides whether msg is held back by maintenance mode. When
+// blocked is true the message must not be processed, and reply (if any) is
+// sent to the user instead.
+func (al *AgentLoop) maintenanceGate(msg bus.InboundMessage) (reply string, blocked bool) {
+	c := al.maintenance
+	if !c.Active() {
+		return "", false
+	}
+	// System messages finish work that started before maintenance; admins
+	// keep full access so they can test the migration and switch it off.
+	if msg.Channel == "system" || c.IsAdmin(msg.Sender, msg.SenderID) {
+		return "", false
+	}
+
+	if c.Hold(msg) {
+		logger.InfoCF("agent", "Queued message during maintenance", map[string]any{
+			"channel":    msg.Channel,
+			"chat_id":    msg.ChatID,
+			"request_id": msg.RequestID,
+		})
+		return c.Notice() + "\n\n" + i18n.T(al.uiLanguage(msg), queuedNotice), true
+	}
+	logger.InfoCF("agent", "Rejected message during maintenance", map[string]any{
+		"channel":    msg.Channel,
+		"chat_id":    msg.ChatID,
+		"request_id": msg.RequestID,
+	})
+	return c.Notice(), true
+}
+
+// addMaintenanceRuntime exposes the maintenance controller to /maintenance.
+func (al *AgentLoop) addMaintenanceRuntime(rt *commands.Runtime, msg bus.InboundMessage) {
+	c := al.maintenance
+	if c == nil {
+		return
+	}
+	rt.IsAdmin = func() bool {
+		return c.IsAdmin(msg.Sender, msg.SenderID)
+	}
+	rt.MaintenanceStatus = func() (bool, string, int) {
+		return c.Active(), c.Notice(), c.Queued()
+	}
+	rt.SetMaintenance = func(on bool, message string) (int, error) {
+		by := msg.Channel + ":" + msg.SenderID
+		if on {
+			return 0, c.Enable(by, message)
+		}
+		return c.Disable(by)
+	}
+}
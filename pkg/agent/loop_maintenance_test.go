@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/maintenance"
+)
+
+func TestMaintenanceGate(t *testing.T) {
+	c := maintenance.NewController(config.MaintenanceConfig{
+		Message:    "Down for maintenance",
+		Mode:       maintenance.ModeQueue,
+		QueueLimit: 1,
+		Admins:     []string{"telegram:1"},
+	}, t.TempDir())
+	al := &AgentLoop{}
+
+	user := bus.InboundMessage{Channel: "telegram", SenderID: "telegram:2", Content: "hi"}
+	if _, blocked := al.maintenanceGate(user); blocked {
+		t.Fatal("nil controller must not block")
+	}
+	al.SetMaintenance(c)
+	if _, blocked := al.maintenanceGate(user); blocked {
+		t.Fatal("inactive maintenance must not block")
+	}
+
+	c.Enable("test", "")
+	reply, blocked := al.maintenanceGate(user)
+	if !blocked || !strings.HasPrefix(reply, "Down for maintenance") || !strings.Contains(reply, "queued") {
+		t.Fatalf("first message: blocked=%v reply=%q", blocked, reply)
+	}
+	reply, blocked = al.maintenanceGate(user)
+	if !blocked || reply != "Down for maintenance" {
+		t.Fatalf("full queue should reject: blocked=%v reply=%q", blocked, reply)
+	}
+
+	admin := bus.InboundMessage{Channel: "telegram", SenderID: "telegram:1", Content: "/maintenance off"}
+	if _, blocked := al.maintenanceGate(admin); blocked {
+		t.Error("admins must get through")
+	}
+	if _, blocked := al.maintenanceGate(bus.InboundMessage{Channel: "system"}); blocked {
+		t.Error("system messages must get through")
+	}
+}
@@ -7,9 +7,7 @@
 package agent
 
 import (
-	"bytes"
 	"encoding/base64"
-	"io"
 	"os"
 	"strings"
 
@@ -101,6 +99,7 @@ func detectMIME(localPath string, meta media.MediaMeta) string {
 }
 
 // encodeImageToDataURL base64-encodes an image file into a data URL.
+// Images larger than vision models accept are scaled down first.
 // Returns empty string if the file exceeds maxSize or encoding fails.
 func encodeImageToDataURL(localPath, mime string, info os.FileInfo, maxSize int) string {
 	if info.Size() > int64(maxSize) {
@@ -112,7 +111,7 @@ func encodeImageToDataURL(localPath, mime string, info os.FileInfo, maxSize int)
 		return ""
 	}
 
-	f, err := os.Open(localPath)
+	data, err := os.ReadFile(localPath)
 	if err != nil {
 		logger.WarnCF("agent", "Failed to open media file", map[string]any{
 			"path":  localPath,
@@ -120,24 +119,22 @@ func encodeImageToDataURL(localPath, mime string, info os.FileInfo, maxSize int)
 		})
 		return ""
 	}
-	defer f.Close()
-
-	prefix := "data:" + mime + ";base64,"
-	encodedLen := base64.StdEncoding.EncodedLen(int(info.Size()))
-	var buf bytes.Buffer
-	buf.Grow(len(prefix) + encodedLen)
-	buf.WriteString(prefix)
-
-	encoder := base64.NewEncoder(base64.StdEncoding, &buf)
-	if _, err := io.Copy(encoder, f); err != nil {
-		logger.WarnCF("agent", "Failed to encode media file", map[string]any{
+	fitted, fittedMIME, err := media.FitImage(data, mime, media.MaxImageDimension, media.MaxImageBytes)
+	if err != nil {
+		// Send it as it is and let the provider decide
+		logger.WarnCF("agent", "Failed to scale image down", map[string]any{
 			"path":  localPath,
 			"error": err.Error(),
 		})
-		return ""
+	} else {
+		data, mime = fitted, fittedMIME
 	}
-	encoder.Close()
 
+	prefix := "data:" + mime + ";base64,"
+	var buf strings.Builder
+	buf.Grow(len(prefix) + base64.StdEncoding.EncodedLen(len(data)))
+	buf.WriteString(prefix)
+	buf.WriteString(base64.StdEncoding.EncodeToString(data))
 	return buf.String()
 }
 
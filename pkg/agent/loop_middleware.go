@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/middleware"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// errMiddlewareStopped ends a turn that a middleware stopped without a
+// reply.
+var errMiddlewareStopped = errors.New("turn stopped by middleware")
+
+// Use adds mw at point of the message pipeline. It runs before the
+// middleware of the config, which a config reload replaces.
+func (al *AgentLoop) Use(point string, mw middleware.Middleware) error {
+	return al.middleware.Use(point, mw)
+}
+
+// inboundMiddleware runs the inbound middleware on msg, keeping what they
+// changed. It returns false when msg is to be ignored.
+func (al *AgentLoop) inboundMiddleware(ctx context.Context, msg *bus.InboundMessage) bool {
+	m := &middleware.Message{
+		Point:      middleware.PointInbound,
+		Channel:    msg.Channel,
+		ChatID:     msg.ChatID,
+		SenderID:   msg.SenderID,
+		SessionKey: msg.SessionKey,
+		Content:    msg.Content,
+		Metadata:   msg.Metadata,
+	}
+	if !al.middleware.Run(ctx, m) {
+		logger.InfoCtx(ctx, "agent", "Inbound message stopped by middleware", map[string]any{
+			"channel": msg.Channel,
+			"chat_id": msg.ChatID,
+		})
+		return false
+	}
+	msg.Content = m.Content
+	msg.Metadata = m.Metadata
+	return true
+}
+
+// preLLMMiddleware runs the pre_llm middleware on a copy of messages and
+// returns the request to send. When a middleware stops it, the turn ends
+// with reply instead.
+func (al *AgentLoop) preLLMMiddleware(
+	ctx context.Context,
+	opts processOptions,
+	messages []providers.Message,
+) (request []providers.Message, reply string, stopped bool) {
+	m := &middleware.Message{
+		Point:      middleware.PointPreLLM,
+		Channel:    opts.Channel,
+		ChatID:     opts.ChatID,
+		SenderID:   opts.SenderID,
+		SessionKey: opts.SessionKey,
+		Messages:   append([]providers.Message(nil), messages...),
+	}
+	if !al.middleware.Run(ctx, m) {
+		return nil, m.Content, true
+	}
+	return m.Messages, "", false
+}
+
+// postLLMMiddleware runs the post_llm middleware on resp, keeping what
+// they changed. When a middleware stops it, resp loses its tool calls so
+// the turn ends with its content, and false is returned.
+func (al *AgentLoop) postLLMMiddleware(ctx context.Context, opts processOptions, resp *providers.LLMResponse) bool {
+	m := &middleware.Message{
+		Point:      middleware.PointPostLLM,
+		Channel:    opts.Channel,
+		ChatID:     opts.ChatID,
+		SenderID:   opts.SenderID,
+		SessionKey: opts.SessionKey,
+		Content:    resp.Content,
+		ToolCalls:  resp.ToolCalls,
+	}
+	passed := al.middleware.Run(ctx, m)
+	resp.Content = m.Content
+	resp.ToolCalls = m.ToolCalls
+	if !passed {
+		resp.ToolCalls = nil
+		resp.ReasoningContent = ""
+	}
+	return passed
+}
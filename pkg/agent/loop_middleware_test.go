@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/middleware"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func newMiddlewareTestLoop(t *testing.T) (*AgentLoop, *recordingProvider) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &recordingProvider{}
+	return NewAgentLoop(cfg, bus.NewMessageBus(), provider), provider
+}
+
+func middlewareTestMessage(content string) bus.InboundMessage {
+	return bus.InboundMessage{Channel: "telegram", SenderID: "telegram:1", ChatID: "chat-1", Content: content}
+}
+
+func TestMiddleware_InboundRewritesAndDrops(t *testing.T) {
+	al, provider := newMiddlewareTestLoop(t)
+	_ = al.Use(middleware.PointInbound, func(next middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, msg *middleware.Message) error {
+			if msg.Content == "ignore me" {
+				return nil
+			}
+			msg.Content = strings.ToUpper(msg.Content)
+			return next(ctx, msg)
+		}
+	})
+
+	response, err := al.processMessage(context.Background(), middlewareTestMessage("ignore me"))
+	if err != nil || response != "" {
+		t.Fatalf("dropped message: response = %q, err = %v, want no reply", response, err)
+	}
+	if provider.lastMessages != nil {
+		t.Fatal("dropped message reached the model")
+	}
+
+	if _, err := al.processMessage(context.Background(), middlewareTestMessage("hello")); err != nil {
+		t.Fatalf("processMessage() error = %v", err)
+	}
+	last := provider.lastMessages[len(provider.lastMessages)-1]
+	if last.Content != "HELLO" {
+		t.Errorf("model saw %q, want the rewritten %q", last.Content, "HELLO")
+	}
+}
+
+func TestMiddleware_PreLLMAnswersInPlaceOfModel(t *testing.T) {
+	al, provider := newMiddlewareTestLoop(t)
+	_ = al.Use(middleware.PointPreLLM, func(next middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, msg *middleware.Message) error {
+			if strings.Contains(msg.Messages[len(msg.Messages)-1].Content, "opening hours") {
+				msg.Content = "We are open 9 to 5."
+				return nil
+			}
+			if strings.Contains(msg.Messages[len(msg.Messages)-1].Content, "quiet") {
+				return nil
+			}
+			msg.Messages = append(msg.Messages, providers.Message{Role: "system", Content: "Be brief."})
+			return next(ctx, msg)
+		}
+	})
+
+	response, err := al.processMessage(context.Background(), middlewareTestMessage("opening hours?"))
+	if err != nil || response != "We are open 9 to 5." {
+		t.Fatalf("response = %q, err = %v, want the middleware's reply", response, err)
+	}
+	if provider.lastMessages != nil {
+		t.Fatal("the model was called for a message the middleware answered")
+	}
+
+	response, err = al.processMessage(context.Background(), middlewareTestMessage("be quiet"))
+	if err != nil || response != "" {
+		t.Fatalf("response = %q, err = %v, want no reply", response, err)
+	}
+
+	if _, err := al.processMessage(context.Background(), middlewareTestMessage("hello")); err != nil {
+		t.Fatalf("processMessage() error = %v", err)
+	}
+	if last := provider.lastMessages[len(provider.lastMessages)-1]; last.Content != "Be brief." {
+		t.Errorf("last message sent = %q, want the one the middleware added", last.Content)
+	}
+	for _, m := range provider.lastMessages {
+		if m.Content == "be quiet" {
+			t.Error("a turn stopped without a reply stayed in the history")
+		}
+	}
+}
+
+func TestMiddleware_PostLLMRewritesReply(t *testing.T) {
+	al, _ := newMiddlewareTestLoop(t)
+	_ = al.Use(middleware.PointPostLLM, func(next middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, msg *middleware.Message) error {
+			msg.Content = strings.ReplaceAll(msg.Content, "Mock", "Real")
+			return next(ctx, msg)
+		}
+	})
+
+	response, err := al.processMessage(context.Background(), middlewareTestMessage("hello"))
+	if err != nil || response != "Real response" {
+		t.Fatalf("response = %q, err = %v, want %q", response, err, "Real response")
+	}
+}
+
+func TestMiddleware_ConfiguredOnReload(t *testing.T) {
+	al, provider := newMiddlewareTestLoop(t)
+	cfg := *al.GetConfig()
+	cfg.Middleware = []config.MiddlewareConfig{{
+		Type:     "drop",
+		Points:   []string{middleware.PointInbound},
+		Settings: map[string]any{"patterns": []any{"^spam"}},
+	}}
+	if err := al.ReloadProviderAndConfig(context.Background(), provider, &cfg); err != nil {
+		t.Fatalf("ReloadProviderAndConfig() error = %v", err)
+	}
+
+	response, err := al.processMessage(context.Background(), middlewareTestMessage("spam and eggs"))
+	if err != nil || response != "" {
+		t.Fatalf("response = %q, err = %v, want the message dropped", response, err)
+	}
+}
@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/branch"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+// modelProvider is the backend of a model a conversation switched to.
+type modelProvider struct {
+	provider providers.LLMProvider
+	modelID  string
+}
+
+// conversationModel returns the provider and model ID of the model a
+// conversation switched to with /model. It reports false when the
+// conversation uses the agent's own model, or when its model can no longer
+// be used, as after a config change.
+func (al *AgentLoop) conversationModel(
+	agent *AgentInstance,
+	settings tuning.Settings,
+) (providers.LLMProvider, string, bool) {
+	if settings.Model == "" || settings.Model == agent.Model {
+		return nil, "", false
+	}
+	if err := al.checkConversationModel(agent, settings.Model); err != nil {
+		logger.WarnCF("agent", "Ignoring the conversation's model", map[string]any{
+			"agent_id": agent.ID,
+			"model":    settings.Model,
+			"error":    err.Error(),
+		})
+		return nil, "", false
+	}
+	mp, err := agent.providerForModel(al.GetConfig(), settings.Model)
+	if err != nil {
+		logger.WarnCF("agent", "Failed to create the conversation's provider", map[string]any{
+			"agent_id": agent.ID,
+			"model":    settings.Model,
+			"error":    err.Error(),
+		})
+		return nil, "", false
+	}
+	return mp.provider, mp.modelID, true
+}
+
+// providerForModel creates the provider of the model_list entry name once
+// and keeps it for the conversations that use it.
+func (a *AgentInstance) providerForModel(cfg *config.Config, name string) (modelProvider, error) {
+	if v, ok := a.modelProviders.Load(name); ok {
+		return v.(modelProvider), nil
+	}
+	provider, modelID, err := providers.CreateProviderForModel(cfg, name, a.Workspace)
+	if err != nil {
+		return modelProvider{}, err
+	}
+	mp := modelProvider{provider: provider, modelID: modelID}
+	if v, loaded := a.modelProviders.LoadOrStore(name, mp); loaded {
+		if sp, ok := provider.(providers.StatefulProvider); ok {
+			sp.Close()
+		}
+		return v.(modelProvider), nil
+	}
+	return mp, nil
+}
+
+// checkConversationModel reports why a conversation of agent may not use
+// the model_list entry name.
+func (al *AgentLoop) checkConversationModel(agent *AgentInstance, name string) error {
+	if name == agent.Model {
+		return nil
+	}
+	if _, err := al.GetConfig().GetModelConfig(name); err != nil {
+		return fmt.Errorf("model %s is not in the config; see /model list", name)
+	}
+	return al.checkModelForAgent(agent, name)
+}
+
+// modelChoices lists the models the conversation's provider serves and the
+// configured ones, marking those the conversation may switch to.
+func (al *AgentLoop) modelChoices(
+	ctx context.Context,
+	agent *AgentInstance,
+	settings tuning.Settings,
+) ([]commands.ModelChoice, bool) {
+	provider := agent.Provider
+	p, _, switched := al.conversationModel(agent, settings)
+	if switched {
+		provider = p
+	}
+
+	// Configured models by the ID their provider knows them by.
+	var choices []commands.ModelChoice
+	names := make(map[string]string)
+	for _, mc := range al.GetConfig().ModelList {
+		if _, seen := names[mc.ModelName]; seen || al.checkConversationModel(agent, mc.ModelName) != nil {
+			continue
+		}
+		_, id := providers.ExtractProtocol(mc.Model)
+		names[mc.ModelName] = id
+		current := mc.ModelName == agent.Model || id == agent.Model
+		if switched {
+			current = mc.ModelName == settings.Model
+		}
+		choices = append(choices, commands.ModelChoice{Name: mc.ModelName, ID: id, Current: current})
+	}
+
+	lister, ok := provider.(providers.ModelLister)
+	if !ok {
+		return choices, false
+	}
+	name := agent.Model
+	if switched {
+		name = settings.Model
+	}
+	served, err := al.providerCache.Models(ctx, name, lister)
+	if err != nil {
+		logger.WarnCF("agent", "Failed to list the provider's models", map[string]any{
+			"agent_id": agent.ID,
+			"error":    err.Error(),
+		})
+		return choices, false
+	}
+	configured := make(map[string]bool, len(names))
+	for _, id := range names {
+		configured[id] = true
+	}
+	for _, id := range served {
+		if !configured[id] {
+			choices = append(choices, commands.ModelChoice{ID: id})
+		}
+	}
+	return choices, true
+}
+
+// addModelRuntime lets /model switch and list the models of a session.
+// Switching is saved with the session's other generation settings.
+func (al *AgentLoop) addModelRuntime(
+	rt *commands.Runtime,
+	agent *AgentInstance,
+	opts *processOptions,
+) {
+	if al.tuning == nil || agent == nil || opts == nil || opts.SessionKey == "" {
+		return
+	}
+	session := branch.BaseKey(opts.SessionKey)
+	rt.CheckModel = func(name string) error {
+		return al.checkConversationModel(agent, name)
+	}
+	rt.ListModels = func(ctx context.Context) ([]commands.ModelChoice, bool) {
+		return al.modelChoices(ctx, agent, al.tuning.Get(session).Over(al.guildSettings(*opts)))
+	}
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestProcessMessage_ConversationModel(t *testing.T) {
+	var gotModel string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/models":
+			fmt.Fprint(w, `{"data":[{"id":"other-model"},{"id":"unlisted-model"}]}`)
+		case "/chat/completions":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			gotModel, _ = body["model"].(string)
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"from other"},"finish_reason":"stop"}]}`)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "other", Model: "openai/other-model", APIBase: server.URL, APIKey: "k"},
+		},
+		Generation: config.GenerationConfig{Operators: []string{"alice"}},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	send := func(chatID, content string) string {
+		t.Helper()
+		reply, err := al.processMessage(ctx, bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: "alice",
+			ChatID:   chatID,
+			Content:  content,
+		})
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", content, err)
+		}
+		return reply
+	}
+
+	if reply := send("chat-1", "/model missing"); !strings.Contains(reply, "not in the config") {
+		t.Fatalf("unknown model reply = %q", reply)
+	}
+	if reply := send("chat-1", "!model other"); !strings.Contains(reply, "switched to other") {
+		t.Fatalf("switch reply = %q", reply)
+	}
+	if reply := send("chat-1", "hello"); reply != "from other" || gotModel != "other-model" {
+		t.Fatalf("reply = %q from model %q, want the switched model to answer", reply, gotModel)
+	}
+	if provider.lastMessages != nil {
+		t.Error("the agent's own provider was called after switching")
+	}
+
+	list := send("chat-1", "/model list")
+	if !strings.Contains(list, "✓ other — other-model (current)") || !strings.Contains(list, "  unlisted-model") {
+		t.Fatalf("list reply = %q", list)
+	}
+
+	send("chat-1", "/model reset")
+	if reply := send("chat-1", "hello"); reply != "Mock response" {
+		t.Fatalf("reply after reset = %q, want the agent's own model", reply)
+	}
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/offline"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// errProvidersDown marks a turn that failed before any tool ran because no
+// provider could be reached. Its user message was taken back out of the
+// session, so the turn can be replayed from the offline queue.
+var errProvidersDown = errors.New("no LLM provider is available")
+
+// isProviderOutage reports whether err means the providers are unreachable
+// or overloaded, as opposed to rejecting the request itself.
+func isProviderOutage(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var exhausted *providers.FallbackExhaustedError
+	if errors.As(err, &exhausted) {
+		for _, a := range exhausted.Attempts {
+			if !a.Skipped && !outageReason(a.Reason) {
+				return false
+			}
+		}
+		return true
+	}
+	var failover *providers.FailoverError
+	if errors.As(err, &failover) {
+		return outageReason(failover.Reason)
+	}
+	if classified := providers.ClassifyError(err, "", ""); classified != nil {
+		return outageReason(classified.Reason)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "no such host") ||
+		strings.Contains(msg, "connection reset")
+}
+
+func outageReason(reason providers.FailoverReason) bool {
+	switch reason {
+	case providers.FailoverTimeout, providers.FailoverRateLimit, providers.FailoverOverloaded:
+		return true
+	}
+	return false
+}
+
+// offlineTurnError converts the error of a failed turn into errProvidersDown
+// when the turn can be replayed later: the offline queue exists, the first
+// model call failed because of an outage, and the user message can be taken
+// back out of the session.
+func (al *AgentLoop) offlineTurnError(
+	agent *AgentInstance,
+	opts processOptions,
+	history []providers.Message,
+	iteration int,
+	err error,
+) error {
+	if al.offline == nil || !al.GetConfig().OfflineQueue.Enabled || opts.NoHistory || iteration > 1 ||
+		!isProviderOutage(err) {
+		return err
+	}
+	agent.Sessions.SetHistory(opts.SessionKey, history)
+	return fmt.Errorf("%w: %w", errProvidersDown, err)
+}
+
+// holdOffline queues msg when its turn failed with errProvidersDown. It
+// returns the notice for the sender; a replayed message is put back silently.
+func (al *AgentLoop) holdOffline(msg bus.InboundMessage, err error) (reply string, held bool) {
+	// The queue is saved to disk, so ephemeral messages are never held
+	if !errors.Is(err, errProvidersDown) || al.isEphemeral(msg) || !al.offline.Hold(msg) {
+		return "", false
+	}
+	if offline.Replayed(msg) {
+		logger.DebugCF("agent", "Providers still unavailable, message stays queued", map[string]any{
+			"channel":    msg.Channel,
+			"chat_id":    msg.ChatID,
+			"request_id": msg.RequestID,
+		})
+		return "", true
+	}
+	return al.offline.Notice(), true
+}
@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/offline"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+type outageProvider struct {
+	down bool
+}
+
+func (p *outageProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if p.down {
+		return nil, fmt.Errorf("API request failed: status 503: service unavailable")
+	}
+	return &providers.LLMResponse{Content: "Back online"}, nil
+}
+
+func (p *outageProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestOfflineQueue_HoldsTurnsDuringOutage(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		OfflineQueue: config.OfflineQueueConfig{Enabled: true, QueueLimit: 10},
+	}
+	provider := &outageProvider{down: true}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	msg := bus.InboundMessage{Channel: "telegram", SenderID: "alice", ChatID: "chat-1", Content: "hello"}
+
+	_, err := al.processMessage(context.Background(), msg)
+	if !errors.Is(err, errProvidersDown) {
+		t.Fatalf("error = %v, want errProvidersDown", err)
+	}
+	agent := al.GetRegistry().GetDefaultAgent()
+	sessionKey := "agent:main:main"
+	if history := agent.Sessions.GetHistory(sessionKey); len(history) != 0 {
+		t.Fatalf("failed turn left %d messages in the session", len(history))
+	}
+	reply, held := al.holdOffline(msg, err)
+	if !held || reply != al.offline.Notice() {
+		t.Fatalf("holdOffline = %q, %v", reply, held)
+	}
+
+	provider.down = false
+	al.offline.Retry(time.Now())
+	replayed := <-al.bus.InboundChan()
+	if !offline.Replayed(replayed) {
+		t.Fatalf("replayed message lacks the queue marker: %+v", replayed.Metadata)
+	}
+	reply, err = al.processMessage(context.Background(), replayed)
+	if err != nil || reply != "Back online" {
+		t.Fatalf("replayed turn = %q, %v", reply, err)
+	}
+	if history := agent.Sessions.GetHistory(sessionKey); len(history) != 2 {
+		t.Fatalf("session after the replay has %d messages, want 2", len(history))
+	}
+	if n := al.offline.Len(); n != 0 {
+		t.Fatalf("%d messages still queued after recovery", n)
+	}
+}
+
+func TestIsProviderOutage(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("status 503: overloaded"), true},
+		{errors.New("dial tcp: connection refused"), true},
+		{&providers.FallbackExhaustedError{Attempts: []providers.FallbackAttempt{
+			{Reason: providers.FailoverTimeout}, {Skipped: true, Reason: providers.FailoverRateLimit},
+		}}, true},
+		{&providers.FallbackExhaustedError{Attempts: []providers.FallbackAttempt{
+			{Reason: providers.FailoverAuth},
+		}}, false},
+		{errors.New("status 401: invalid api key"), false},
+		{context.Canceled, false},
+	} {
+		if got := isProviderOutage(tt.err); got != tt.want {
+			t.Errorf("isProviderOutage(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
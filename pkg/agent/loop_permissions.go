@@ -0,0 +1,223 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	permissionAllow = "allow"
+	permissionAsk   = "ask"
+	permissionDeny  = "deny"
+)
+
+// toolPermission returns what the permission config says about a call of
+// tool by the sender of opts in its chat: "allow", "ask", or "deny". Rules are
+// tried in order and the first that matches decides. An action other than
+// the three known ones denies, so a typo never lets a call through.
+func toolPermission(cfg config.ToolPermissionsConfig, tool string, opts processOptions) string {
+	action := cfg.Default
+	for _, rule := range cfg.Rules {
+		if matchesToolName(rule.Tools, tool) && ruleAppliesTo(cfg, rule, opts) {
+			action = rule.Action
+			break
+		}
+	}
+	switch action = strings.ToLower(strings.TrimSpace(action)); action {
+	case "", permissionAllow:
+		return permissionAllow
+	case permissionAsk:
+		return permissionAsk
+	}
+	return permissionDeny
+}
+
+// matchesToolName reports whether tool is one of patterns, which are names,
+// "prefix*", or "*".
+func matchesToolName(patterns []string, tool string) bool {
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(tool, prefix) {
+				return true
+			}
+		} else if strings.EqualFold(p, tool) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleAppliesTo reports whether the sender and chat of opts fall under rule.
+// Users and Roles together make one condition, Channels another.
+func ruleAppliesTo(cfg config.ToolPermissionsConfig, rule config.ToolPermissionRule, opts processOptions) bool {
+	if len(rule.Users) > 0 || len(rule.Roles) > 0 {
+		matched := matchesSender(rule.Users, opts)
+		for _, role := range rule.Roles {
+			if matched {
+				break
+			}
+			matched = matchesSender(cfg.Roles[role], opts)
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(rule.Channels) == 0 {
+		return true
+	}
+	for _, entry := range rule.Channels {
+		ch, id, scoped := strings.Cut(strings.TrimSpace(entry), ":")
+		if !strings.EqualFold(ch, opts.Channel) {
+			continue
+		}
+		if !scoped || (id != "" && (id == opts.ChatID || id == opts.GuildID)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSender reports whether the sender of opts is in entries, which use
+// the formats of allow_from.
+func matchesSender(entries []string, opts processOptions) bool {
+	if opts.SenderID == "" {
+		return false
+	}
+	sender := identity.SenderOrID(opts.Sender, opts.Channel, opts.SenderID)
+	for _, entry := range entries {
+		if identity.MatchAllowed(sender, entry) || strings.TrimSpace(entry) == opts.SenderID {
+			return true
+		}
+	}
+	return false
+}
+
+// permittedTools drops the definitions of the tools the sender of opts may
+// never call, so the model does not try them.
+func permittedTools(
+	cfg config.ToolPermissionsConfig,
+	defs []providers.ToolDefinition,
+	opts processOptions,
+) []providers.ToolDefinition {
+	if cfg.Default == "" && len(cfg.Rules) == 0 {
+		return defs
+	}
+	kept := make([]providers.ToolDefinition, 0, len(defs))
+	for _, d := range defs {
+		if toolPermission(cfg, d.Function.Name, opts) != permissionDeny {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// executePermittedTool runs the tool call tc when the permission config
+// allows it, holds it for the sender's approval when it asks, and refuses it
+// otherwise. A held call is recorded in the transcript when it is held and
+// again when it runs.
+func (al *AgentLoop) executePermittedTool(
+	ctx context.Context,
+	agent *AgentInstance,
+	opts processOptions,
+	tc providers.ToolCall,
+	asyncCallback tools.AsyncCallback,
+) *tools.ToolResult {
+	switch toolPermission(al.GetConfig().Tools.Permissions, tc.Name, opts) {
+	case permissionAllow:
+		return agent.Tools.ExecuteWithContext(ctx, tc.Name, tc.Arguments, opts.Channel, opts.ChatID, asyncCallback)
+	case permissionAsk:
+		if al.approvals != nil && opts.SenderID != "" {
+			run := func(approveCtx context.Context) *tools.ToolResult {
+				approveCtx = tools.WithToolGuild(approveCtx, opts.GuildID)
+				approveCtx = tools.WithToolSender(approveCtx, opts.SenderID)
+				approveCtx = tools.WithToolSenderInfo(approveCtx, opts.Sender)
+				start := time.Now()
+				result := agent.Tools.ExecuteWithContext(
+					approveCtx, tc.Name, tc.Arguments, opts.Channel, opts.ChatID, nil)
+				al.recordToolCall(agent, opts, tc, result, time.Since(start))
+				return result
+			}
+			holdCtx := tools.WithToolContext(ctx, opts.Channel, opts.ChatID)
+			return al.approvals.Hold(holdCtx, toolCallSummary(tc), run)
+		}
+	}
+	logger.InfoCF("agent", "Tool call denied by permissions", map[string]any{
+		"tool":      tc.Name,
+		"channel":   opts.Channel,
+		"chat_id":   opts.ChatID,
+		"sender_id": opts.SenderID,
+	})
+	return tools.ErrorResult(fmt.Sprintf("tool %s is not permitted for this user here", tc.Name))
+}
+
+// toolCallSummary describes tc for its approval prompt.
+func toolCallSummary(tc providers.ToolCall) string {
+	if len(tc.Arguments) == 0 {
+		return tc.Name
+	}
+	args, err := json.Marshal(tc.Arguments)
+	if err != nil {
+		return tc.Name
+	}
+	return fmt.Sprintf("%s %s", tc.Name, utils.Truncate(string(args), 300))
+}
+
+// approvalPrompt posts the prompt of a held tool action tagged with its ID,
+// so reacting ✅ or ❌ to it resolves the action.
+func (al *AgentLoop) approvalPrompt(channel, chatID, id, content string) error {
+	pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer pubCancel()
+	return al.bus.PublishOutbound(pubCtx, bus.OutboundMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Content: content,
+		TurnID:  channels.ApprovalTurnPrefix + id,
+	})
+}
+
+// resolveApprovalReaction approves or denies a held tool action for the
+// user who reacted to its prompt and posts the outcome to the chat.
+func (al *AgentLoop) resolveApprovalReaction(
+	ctx context.Context,
+	channel, chatID, id string,
+	sender bus.SenderInfo,
+	approve bool,
+) error {
+	if al.approvals == nil {
+		return nil
+	}
+	senderID := sender.CanonicalID
+	if senderID == "" {
+		senderID = sender.PlatformID
+	}
+	summary, result, err := al.approvals.Resolve(ctx, id, channel, senderID, approve)
+	if err != nil {
+		// Reactions from other users, or to expired prompts, are ignored.
+		return nil
+	}
+	var reply string
+	switch {
+	case !approve:
+		reply = "Cancelled: " + summary
+	case result.IsError:
+		reply = "❌ " + summary + " failed: " + result.ForLLM
+	case result.ForLLM != "":
+		reply = "✅ " + summary + "\n" + result.ForLLM
+	default:
+		reply = "✅ " + summary
+	}
+	return al.bus.PublishOutbound(ctx, bus.OutboundMessage{Channel: channel, ChatID: chatID, Content: reply})
+}
@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestToolPermission_FirstMatchingRuleWins(t *testing.T) {
+	cfg := config.ToolPermissionsConfig{
+		Default: "ask",
+		Roles:   map[string][]string{"admin": {"discord:42"}},
+		Rules: []config.ToolPermissionRule{
+			{Tools: []string{"*"}, Roles: []string{"admin"}, Action: "allow"},
+			{Tools: []string{"exec", "sandbox"}, Action: "deny"},
+			{Tools: []string{"project_*"}, Channels: []string{"discord:dev-guild"}, Action: "allow"},
+			{Tools: []string{"web_search"}, Users: []string{"7"}, Action: "allw"},
+			{Tools: []string{"read_file"}, Action: "allow"},
+		},
+	}
+	for _, tc := range []struct {
+		tool, channel, guild, chat, sender, want string
+	}{
+		{"exec", "discord", "", "c1", "42", "allow"},
+		{"exec", "telegram", "", "c1", "42", "deny"},
+		{"sandbox", "discord", "", "c1", "7", "deny"},
+		{"project_write", "discord", "dev-guild", "c1", "7", "allow"},
+		{"project_write", "discord", "other", "c1", "7", "ask"},
+		{"web_search", "telegram", "", "c1", "7", "deny"},
+		{"web_search", "telegram", "", "c1", "8", "ask"},
+		{"READ_FILE", "telegram", "", "c1", "8", "allow"},
+	} {
+		opts := processOptions{Channel: tc.channel, GuildID: tc.guild, ChatID: tc.chat, SenderID: tc.sender}
+		if got := toolPermission(cfg, tc.tool, opts); got != tc.want {
+			t.Errorf("toolPermission(%s by %s:%s) = %q, want %q", tc.tool, tc.channel, tc.sender, got, tc.want)
+		}
+	}
+	if got := toolPermission(config.ToolPermissionsConfig{}, "exec", processOptions{}); got != "allow" {
+		t.Errorf("without rules = %q, want allow", got)
+	}
+}
+
+func TestToolPermission_MatchesSenderLikeAllowFrom(t *testing.T) {
+	cfg := config.ToolPermissionsConfig{
+		Default: "deny",
+		Rules:   []config.ToolPermissionRule{{Tools: []string{"exec"}, Users: []string{"@alice"}, Action: "allow"}},
+	}
+	for _, tc := range []struct {
+		sender bus.SenderInfo
+		want   string
+	}{
+		{bus.SenderInfo{Platform: "telegram", PlatformID: "7", CanonicalID: "telegram:7", Username: "alice"}, "allow"},
+		{bus.SenderInfo{Platform: "telegram", PlatformID: "8", CanonicalID: "telegram:8", Username: "mallory"}, "deny"},
+	} {
+		opts := processOptions{Channel: "telegram", SenderID: tc.sender.PlatformID, Sender: tc.sender}
+		if got := toolPermission(cfg, "exec", opts); got != tc.want {
+			t.Errorf("toolPermission(exec by %+v) = %q, want %q", tc.sender, got, tc.want)
+		}
+	}
+}
+
+func TestPermittedTools_DropsDeniedTools(t *testing.T) {
+	defs := []providers.ToolDefinition{
+		{Function: providers.ToolFunctionDefinition{Name: "exec"}},
+		{Function: providers.ToolFunctionDefinition{Name: "read_file"}},
+	}
+	cfg := config.ToolPermissionsConfig{Rules: []config.ToolPermissionRule{{Tools: []string{"exec"}, Action: "deny"}}}
+	got := permittedTools(cfg, defs, processOptions{Channel: "telegram", SenderID: "1"})
+	if len(got) != 1 || got[0].Function.Name != "read_file" {
+		t.Errorf("permittedTools = %+v", got)
+	}
+}
+
+func TestPermissions_AskHoldsTheCallUntilApprovedByReaction(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Tools: config.ToolsConfig{Permissions: config.ToolPermissionsConfig{
+			Rules: []config.ToolPermissionRule{{Tools: []string{"mock_custom"}, Action: "ask"}},
+		}},
+	}
+	msgBus := bus.NewMessageBus()
+	provider := &personaProvider{}
+	al := NewAgentLoop(cfg, msgBus, provider)
+	defer al.Close()
+	al.RegisterTool(&mockCustomTool{})
+
+	msg := bus.InboundMessage{Channel: "telegram", SenderID: "u1", ChatID: "c1", Content: "run it"}
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	if !slices.Contains(provider.tools[0], "mock_custom") {
+		t.Errorf("tools = %v, want mock_custom offered", provider.tools[0])
+	}
+	last := provider.messages[1][len(provider.messages[1])-1]
+	if last.Role != "tool" || !strings.Contains(last.Content, "has NOT run yet") {
+		t.Fatalf("held tool result = %+v", last)
+	}
+
+	var id string
+	for id == "" {
+		select {
+		case out := <-msgBus.OutboundChan():
+			id, _ = strings.CutPrefix(out.TurnID, channels.ApprovalTurnPrefix)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no approval prompt was posted")
+		}
+	}
+
+	// Another user's reaction does not run the call.
+	ctx := context.Background()
+	if err := al.resolveApprovalReaction(ctx, "telegram", "c1", id, bus.SenderInfo{PlatformID: "u2"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := al.resolveApprovalReaction(ctx, "telegram", "c1", id, bus.SenderInfo{PlatformID: "u1"}, true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case out := <-msgBus.OutboundChan():
+		if !strings.HasPrefix(out.Content, "✅ mock_custom") || !strings.Contains(out.Content, "Custom tool executed") {
+			t.Errorf("outcome = %q", out.Content)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the approved call posted no outcome")
+	}
+}
@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+// defaultPersona returns the persona configured for a chat, its guild, its
+// channel, or everything, in that order of precedence.
+func defaultPersona(cfg config.PersonasConfig, channel, guildID, chatID string) string {
+	var keys []string
+	if chatID != "" {
+		keys = append(keys, channel+":"+chatID)
+	}
+	if guildID != "" {
+		keys = append(keys, channel+":"+guildID)
+	}
+	for _, key := range append(keys, channel, "default") {
+		if name := cfg.Defaults[key]; name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// turnPersona returns the persona of a turn with the session's generation
+// settings: the one the conversation picked with /persona or its guild with
+// /config, or else the persona of its rollout variant, or else the default
+// for its chat. It reports false when no persona applies or the named one is
+// no longer configured.
+func (al *AgentLoop) turnPersona(opts processOptions, settings tuning.Settings) (string, config.PersonaConfig, bool) {
+	cfg := al.GetConfig().Personas
+	name := settings.Persona
+	if name == "" {
+		name = opts.VariantPersona
+	}
+	if name == "" {
+		name = defaultPersona(cfg, opts.Channel, opts.GuildID, opts.ChatID)
+	}
+	if name == "" {
+		return "", config.PersonaConfig{}, false
+	}
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		logger.WarnCF("agent", "Ignoring a persona that is not configured", map[string]any{
+			"session_key": opts.SessionKey,
+			"persona":     name,
+		})
+		return "", config.PersonaConfig{}, false
+	}
+	return name, p, true
+}
+
+// withPersona fills the model and temperature the session did not set
+// itself from the persona.
+func withPersona(settings tuning.Settings, p config.PersonaConfig) tuning.Settings {
+	if settings.Model == "" {
+		settings.Model = p.ModelName
+	}
+	if settings.Temperature == nil {
+		settings.Temperature = p.Temperature
+	}
+	return settings
+}
+
+// personaPrompt returns the prompt section of the persona of a turn, or ""
+// without one.
+func (al *AgentLoop) personaPrompt(agent *AgentInstance, opts processOptions) string {
+	name, p, ok := al.turnPersona(opts, al.generationSettings(opts))
+	if !ok {
+		return ""
+	}
+	parts := []string{}
+	if text := strings.TrimSpace(p.Prompt); text != "" {
+		parts = append(parts, text)
+	}
+	if p.PromptFile != "" {
+		path := p.PromptFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(agent.Workspace, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.WarnCF("agent", "Failed to read the persona prompt file", map[string]any{
+				"persona": name,
+				"error":   err.Error(),
+			})
+		} else if text := strings.TrimSpace(string(data)); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("## Persona: %s\n\n%s", name, strings.Join(parts, "\n\n"))
+}
+
+// personaAllowsTool reports whether the persona lets the model call tool.
+func personaAllowsTool(p config.PersonaConfig, tool string) bool {
+	if len(p.Tools) == 0 {
+		return true
+	}
+	for _, name := range p.Tools {
+		if strings.EqualFold(strings.TrimSpace(name), tool) {
+			return true
+		}
+	}
+	return false
+}
+
+// personaTools keeps the tool definitions the persona allows.
+func personaTools(defs []providers.ToolDefinition, p config.PersonaConfig) []providers.ToolDefinition {
+	if len(p.Tools) == 0 {
+		return defs
+	}
+	kept := make([]providers.ToolDefinition, 0, len(defs))
+	for _, d := range defs {
+		if personaAllowsTool(p, d.Function.Name) {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// addPersonaRuntime tells /persona which persona this chat uses by default:
+// the guild's, or else the configured one. Switching is saved with the
+// session's other generation settings.
+func (al *AgentLoop) addPersonaRuntime(rt *commands.Runtime, opts *processOptions) {
+	if al.tuning == nil || opts == nil || opts.SessionKey == "" {
+		return
+	}
+	channel, guildID, chatID := opts.Channel, opts.GuildID, opts.ChatID
+	rt.DefaultPersona = func() string {
+		if name := al.guildSettings(*opts).Persona; name != "" {
+			return name
+		}
+		return defaultPersona(al.GetConfig().Personas, channel, guildID, chatID)
+	}
+}
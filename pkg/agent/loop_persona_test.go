@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+// personaProvider asks for mock_custom once, then answers, recording what
+// each call was given.
+type personaProvider struct {
+	toolThenAnswerProvider
+	tools    [][]string
+	opts     []map[string]any
+	messages [][]providers.Message
+}
+
+func (p *personaProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	var names []string
+	for _, d := range tools {
+		names = append(names, d.Function.Name)
+	}
+	p.tools = append(p.tools, names)
+	p.opts = append(p.opts, opts)
+	p.messages = append(p.messages, append([]providers.Message(nil), messages...))
+	return p.toolThenAnswerProvider.Chat(ctx, messages, tools, model, opts)
+}
+
+func TestDefaultPersona_Precedence(t *testing.T) {
+	cfg := config.PersonasConfig{Defaults: map[string]string{
+		"default":          "helper",
+		"discord":          "gamer",
+		"discord:guild1":   "gopher",
+		"discord:channel1": "pirate",
+	}}
+	for _, tc := range []struct{ channel, guild, chat, want string }{
+		{"discord", "guild1", "channel1", "pirate"},
+		{"discord", "guild1", "channel2", "gopher"},
+		{"discord", "guild2", "channel2", "gamer"},
+		{"telegram", "", "42", "helper"},
+	} {
+		if got := defaultPersona(cfg, tc.channel, tc.guild, tc.chat); got != tc.want {
+			t.Errorf("defaultPersona(%s, %s, %s) = %q, want %q", tc.channel, tc.guild, tc.chat, got, tc.want)
+		}
+	}
+	if got := defaultPersona(config.PersonasConfig{}, "discord", "g", "c"); got != "" {
+		t.Errorf("without defaults = %q", got)
+	}
+}
+
+func TestPersona_AppliesPromptTemperatureAndTools(t *testing.T) {
+	temp := 0.9
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Generation: config.GenerationConfig{Temperature: config.FloatRange{Max: 2}},
+		Personas: config.PersonasConfig{
+			Profiles: map[string]config.PersonaConfig{
+				"pirate": {Prompt: "Talk like a pirate.", Temperature: &temp, Tools: []string{"read_file"}},
+				"plain":  {},
+			},
+			Defaults: map[string]string{"telegram": "pirate"},
+		},
+	}
+	provider := &personaProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	defer al.Close()
+	al.RegisterTool(&mockCustomTool{})
+
+	msg := bus.InboundMessage{Channel: "telegram", SenderID: "u1", ChatID: "c1", Content: "run it"}
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	if !strings.Contains(provider.messages[0][0].Content, "## Persona: pirate\n\nTalk like a pirate.") {
+		t.Errorf("system prompt lacks the persona:\n%s", provider.messages[0][0].Content)
+	}
+	if got := provider.opts[0]["temperature"]; got != 0.9 {
+		t.Errorf("temperature = %v, want the persona's 0.9", got)
+	}
+	for _, name := range provider.tools[0] {
+		if name == "mock_custom" {
+			t.Errorf("tools = %v, want mock_custom filtered out", provider.tools[0])
+		}
+	}
+	last := provider.messages[1][len(provider.messages[1])-1]
+	if last.Role != "tool" || !strings.Contains(last.Content, "not available to this persona") {
+		t.Errorf("blocked tool result = %+v", last)
+	}
+
+	// A persona picked with /persona replaces the default, and /temp wins
+	// over the persona's temperature.
+	override := 0.3
+	if err := al.tuning.Put("agent:main:main", tuning.Settings{Persona: "plain", Temperature: &override}); err != nil {
+		t.Fatal(err)
+	}
+	provider.toolThenAnswerProvider.calls = 0
+	provider.opts, provider.tools, provider.messages = nil, nil, nil
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	if strings.Contains(provider.messages[0][0].Content, "## Persona") || provider.opts[0]["temperature"] != 0.3 {
+		t.Errorf("persona override not applied: temperature %v", provider.opts[0]["temperature"])
+	}
+	if !slices.Contains(provider.tools[0], "mock_custom") {
+		t.Errorf("tools = %v, want every tool", provider.tools[0])
+	}
+}
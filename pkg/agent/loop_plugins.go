@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/plugin"
+)
+
+// StartPlugins starts the configured plugins once and registers their tools
+// with every agent. It returns the plugin host, so the gateway can add
+// their channels; later calls return the same host.
+func (al *AgentLoop) StartPlugins(ctx context.Context) *plugin.Host {
+	al.pluginsOnce.Do(func() {
+		if len(al.cfg.Plugins) == 0 {
+			return
+		}
+		host := plugin.StartHost(ctx, al.cfg.Plugins, al.cfg.WorkspacePath())
+		for _, tool := range host.Tools() {
+			if al.hasTool(tool.Name()) {
+				logger.WarnCF("agent", "Plugin tool shadows a built-in tool, ignoring it", map[string]any{
+					"tool":   tool.Name(),
+					"plugin": tool.(*plugin.Tool).Plugin(),
+				})
+				continue
+			}
+			al.AddTool(tool)
+		}
+		al.mu.Lock()
+		al.plugins = host
+		al.mu.Unlock()
+	})
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+	return al.plugins
+}
+
+// hasTool reports whether any agent has a tool called name.
+func (al *AgentLoop) hasTool(name string) bool {
+	registry := al.GetRegistry()
+	for _, agentID := range registry.ListAgentIDs() {
+		if agent, ok := registry.GetAgent(agentID); ok {
+			if _, exists := agent.Tools.Get(name); exists {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/prefs"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// userPrefs returns the preferences of the sender of a turn.
+func (al *AgentLoop) userPrefs(channel, senderID string) prefs.Prefs {
+	if senderID == "" {
+		return prefs.Prefs{}
+	}
+	return al.prefs.Get(prefs.UserKey(channel, senderID))
+}
+
+// addPrefsRuntime exposes the sender's preferences to /prefs.
+func (al *AgentLoop) addPrefsRuntime(rt *commands.Runtime, msg bus.InboundMessage) {
+	if al.prefs == nil || msg.SenderID == "" {
+		return
+	}
+	user := prefs.UserKey(msg.Channel, msg.SenderID)
+	rt.GetPrefs = func() prefs.Prefs {
+		return al.prefs.Get(user)
+	}
+	rt.SetPrefs = func(p prefs.Prefs) error {
+		return al.prefs.Put(user, p)
+	}
+}
+
+// applyUserPrefs appends the sender's reply preferences to the system
+// message, after the cached static prompt so prompt caching is unaffected.
+func applyUserPrefs(messages []providers.Message, p prefs.Prefs) {
+	appendSystemSection(messages, p.Instructions())
+}
+
+// appendSystemSection appends a per-request section to the system message.
+func appendSystemSection(messages []providers.Message, extra string) {
+	if extra == "" || len(messages) == 0 || messages[0].Role != "system" {
+		return
+	}
+	messages[0].Content += "\n\n---\n\n" + extra
+	if len(messages[0].SystemParts) > 0 {
+		messages[0].SystemParts = append(messages[0].SystemParts, providers.ContentBlock{Type: "text", Text: extra})
+	}
+}
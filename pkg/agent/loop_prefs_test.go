@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestProcessMessage_AppliesSenderPrefs(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	send := func(sender, content string) {
+		t.Helper()
+		_, err := al.processMessage(ctx, bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: sender,
+			ChatID:   "chat-1",
+			Content:  content,
+		})
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", content, err)
+		}
+	}
+
+	send("alice", "/prefs set language Italian")
+	if _, err := os.Stat(tmpDir + "/state/prefs.json"); err != nil {
+		t.Fatalf("prefs were not persisted: %v", err)
+	}
+
+	send("alice", "hello")
+	if system := provider.lastMessages[0].Content; !strings.Contains(system, "Reply in Italian") {
+		t.Fatalf("alice's system prompt lacks her language preference:\n%s", system)
+	}
+
+	send("bob", "hello")
+	if system := provider.lastMessages[0].Content; strings.Contains(system, "User Preferences") {
+		t.Fatal("bob's request picked up alice's preferences")
+	}
+}
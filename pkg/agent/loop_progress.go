@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// progressLabels describe the built-in tools in a status message.
+var progressLabels = map[string]string{
+	"web_search":       "Searching the web…",
+	"web_fetch":        "Reading a web page…",
+	"exec":             "Running a command…",
+	"sandbox":          "Running code…",
+	"read_file":        "Reading files…",
+	"list_dir":         "Looking through files…",
+	"write_file":       "Editing files…",
+	"edit_file":        "Editing files…",
+	"append_file":      "Editing files…",
+	"patch_file":       "Editing files…",
+	"knowledge_search": "Searching the knowledge base…",
+	"spawn":            "Handing a task to a subagent…",
+	"subagent":         "Handing a task to a subagent…",
+	"delegate":         "Handing tasks to sub-agents…",
+	"generate_image":   "Drawing an image…",
+	"calendar":         "Checking the calendar…",
+	"github":           "Checking GitHub…",
+	"weather":          "Checking the weather…",
+}
+
+// testCommand matches commands of exec that run a test suite.
+var testCommand = regexp.MustCompile(
+	`\b(go test|pytest|(npm|pnpm) (run )?test|yarn test|cargo test|make test|mvn test|gradle test)\b`)
+
+// showProgress posts what the tool calls of a step do as the status of the
+// turn's reply, when progress updates are on.
+func (al *AgentLoop) showProgress(ctx context.Context, opts processOptions, calls []providers.ToolCall, step int) {
+	cfg := al.GetConfig().Agents.Defaults.Progress
+	if !cfg.Enabled || al.channelManager == nil || opts.Channel == "" || constants.IsInternalChannel(opts.Channel) {
+		return
+	}
+	text := progressText(cfg.Labels, calls)
+	if text == "" {
+		return
+	}
+	if step > 1 {
+		text = fmt.Sprintf("%s (step %d)", text, step)
+	}
+	opts.heartbeat.setProgress(text)
+	al.channelManager.ShowProgress(ctx, opts.Channel, opts.ChatID, text)
+}
+
+// progressText describes calls, one line per kind of work, with labels
+// overriding the built-in descriptions. Tools that only talk to the user
+// are left out.
+func progressText(labels map[string]string, calls []providers.ToolCall) string {
+	var lines []string
+	for _, tc := range calls {
+		label := progressLabel(labels, tc)
+		if label != "" && !slices.Contains(lines, label) {
+			lines = append(lines, label)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func progressLabel(labels map[string]string, tc providers.ToolCall) string {
+	if label, ok := labels[tc.Name]; ok {
+		return label
+	}
+	switch tc.Name {
+	case "message", "send_file", "spawn_status", "time":
+		return ""
+	case "exec":
+		if command, _ := tc.Arguments["command"].(string); testCommand.MatchString(command) {
+			return "Running tests…"
+		}
+	}
+	if label, ok := progressLabels[tc.Name]; ok {
+		return label
+	}
+	return fmt.Sprintf("Using %s…", tc.Name)
+}
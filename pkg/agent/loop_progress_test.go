@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestProgressText(t *testing.T) {
+	call := func(name string, args map[string]any) providers.ToolCall {
+		return providers.ToolCall{Name: name, Arguments: args}
+	}
+	for _, tc := range []struct {
+		name   string
+		labels map[string]string
+		calls  []providers.ToolCall
+		want   string
+	}{
+		{"built-in", nil, []providers.ToolCall{call("web_search", nil)}, "Searching the web…"},
+		{
+			"tests",
+			nil,
+			[]providers.ToolCall{call("exec", map[string]any{"command": "cd app && go test ./..."})},
+			"Running tests…",
+		},
+		{"command", nil, []providers.ToolCall{call("exec", map[string]any{"command": "ls"})}, "Running a command…"},
+		{
+			"one line per kind",
+			nil,
+			[]providers.ToolCall{call("read_file", nil), call("read_file", nil), call("lookup", nil)},
+			"Reading files…\nUsing lookup…",
+		},
+		{"message only", nil, []providers.ToolCall{call("message", nil)}, ""},
+		{
+			"label",
+			map[string]string{"lookup": "Asking the CRM…"},
+			[]providers.ToolCall{call("lookup", nil)},
+			"Asking the CRM…",
+		},
+		{"hidden", map[string]string{"web_search": ""}, []providers.ToolCall{call("web_search", nil)}, ""},
+	} {
+		if got := progressText(tc.labels, tc.calls); got != tc.want {
+			t.Errorf("%s: progressText = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
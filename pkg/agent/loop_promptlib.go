@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/promptlib"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// isPromptOperator reports whether the sender of msg is listed in
+// prompt_library.operators or administers the chat's tenant.
+func (al *AgentLoop) isPromptOperator(msg bus.InboundMessage) bool {
+	if al.isTenantAdmin(msg) {
+		return true
+	}
+	for _, op := range al.GetConfig().PromptLibrary.Operators {
+		if identity.MatchAllowed(msg.Sender, op) || (msg.SenderID != "" && strings.TrimSpace(op) == msg.SenderID) {
+			return true
+		}
+	}
+	return false
+}
+
+// usePrompt makes the turn of opts answer template name rendered over text
+// instead of the /prompt command itself.
+func (al *AgentLoop) usePrompt(opts *processOptions, name, text string) error {
+	t, ok := al.promptLib.Get(al.GetConfig().PromptLibrary.Templates, name)
+	if !ok {
+		return promptlib.ErrUnknownPrompt
+	}
+	userName := strings.TrimSpace(opts.SenderDisplayName)
+	if userName == "" {
+		userName = opts.SenderID
+	}
+	now := time.Now()
+	rendered, err := promptlib.Render(t, promptlib.Data{
+		Text:        text,
+		UserName:    userName,
+		ChannelName: opts.Channel,
+		Date:        now.Format("2006-01-02"),
+		Weekday:     now.Weekday().String(),
+	})
+	if err != nil {
+		return err
+	}
+	if rendered == "" {
+		return errors.New("the template " + t.Name + " rendered an empty message")
+	}
+	opts.UserMessage = rendered
+	opts.PromptTemplate = t.Name
+	opts.Examples = nil
+	for _, ex := range t.Examples {
+		opts.Examples = append(opts.Examples,
+			providers.Message{Role: "user", Content: ex.User},
+			providers.Message{Role: "assistant", Content: ex.Assistant})
+	}
+	logger.InfoCF("agent", "Running prompt template", map[string]any{
+		"template":    t.Name,
+		"examples":    len(t.Examples),
+		"session_key": opts.SessionKey,
+	})
+	return nil
+}
+
+// insertExamples places the few-shot examples of a prompt template right
+// before the user message of the turn, which BuildMessages puts last.
+func insertExamples(messages, examples []providers.Message) []providers.Message {
+	if len(examples) == 0 || len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		return messages
+	}
+	return slices.Insert(messages, len(messages)-1, examples...)
+}
+
+// addPromptLibraryRuntime lets /prompt run the templates of the library and
+// operators manage them.
+func (al *AgentLoop) addPromptLibraryRuntime(rt *commands.Runtime, msg bus.InboundMessage, opts *processOptions) {
+	if al.promptLib == nil || opts == nil {
+		return
+	}
+	rt.IsPromptOperator = func() bool {
+		return al.isPromptOperator(msg)
+	}
+	rt.ListPrompts = func() []promptlib.Template {
+		return al.promptLib.List(al.GetConfig().PromptLibrary.Templates)
+	}
+	rt.SavePrompt = func(t promptlib.Template) error {
+		t.AddedBy = msg.Channel + ":" + msg.SenderID
+		t.Added = time.Time{}
+		return al.promptLib.Put(t)
+	}
+	rt.RemovePrompt = al.promptLib.Remove
+	rt.UsePrompt = func(name, text string) error {
+		return al.usePrompt(opts, name, text)
+	}
+}
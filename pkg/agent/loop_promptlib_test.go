@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestProcessMessage_RunsPromptTemplate(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		PromptLibrary: config.PromptLibraryConfig{
+			Templates: map[string]config.PromptPreset{
+				"bugreport": {
+					Template: "Write a bug report for: {{.Text}}",
+					Examples: []config.PromptExample{{User: "it crashes", Assistant: "**Summary:** crash"}},
+				},
+			},
+		},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	reply, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "u1",
+		ChatID:   "c1",
+		Content:  "/prompt bugreport the app freezes on save",
+		Peer:     bus.Peer{Kind: "direct", ID: "u1"},
+	})
+	if err != nil || reply != "Mock response" {
+		t.Fatalf("processMessage() = %q, %v", reply, err)
+	}
+
+	msgs := provider.lastMessages
+	if len(msgs) != 4 {
+		t.Fatalf("sent %d messages, want system, example pair, and prompt: %+v", len(msgs), msgs)
+	}
+	if msgs[1].Content != "it crashes" || msgs[2].Role != "assistant" || msgs[2].Content != "**Summary:** crash" {
+		t.Errorf("examples = %+v, %+v", msgs[1], msgs[2])
+	}
+	if msgs[3].Content != "Write a bug report for: the app freezes on save" {
+		t.Errorf("prompt = %q", msgs[3].Content)
+	}
+
+	// Only the rendered message and the reply are kept, not the examples.
+	history := al.GetRegistry().GetDefaultAgent().Sessions.GetHistory("agent:main:main")
+	if len(history) != 2 || history[0].Content != msgs[3].Content {
+		t.Errorf("history = %+v", history)
+	}
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/notify"
+	"github.com/sipeed/picoclaw/pkg/ratelimit"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// newRateLimiter builds the rate limiter from config, with today's token
+// use read back from the usage log when limits are enabled.
+func newRateLimiter(cfg config.RateLimitsConfig, tracker *usage.Tracker) *ratelimit.Limiter {
+	l := ratelimit.New(cfg)
+	if cfg.Enabled {
+		seedRateLimiter(l, tracker)
+	}
+	return l
+}
+
+func seedRateLimiter(l *ratelimit.Limiter, tracker *usage.Tracker) {
+	if tracker == nil {
+		return
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if records, err := tracker.Load(today); err == nil {
+		l.Seed(records)
+	}
+}
+
+// configureRateLimits applies reloaded limits. Limits that were off did not
+// count tokens, so they are read back from the usage log when turned on.
+func (al *AgentLoop) configureRateLimits(cfg config.RateLimitsConfig) {
+	wasEnabled := al.limits.Enabled()
+	al.limits.Configure(cfg)
+	if cfg.Enabled && !wasEnabled {
+		al.limits.ResetAll()
+		seedRateLimiter(al.limits, al.usage)
+	}
+}
+
+// rateLimitReply counts msg against the rate limits and returns the reply
+// for a message over one, or "" when it may go on.
+func (al *AgentLoop) rateLimitReply(msg bus.InboundMessage) string {
+	if constants.IsInternalChannel(msg.Channel) {
+		return ""
+	}
+	denial, ok := al.limits.Allow(msg.Channel, msg.SenderID, msg.Sender)
+	if ok {
+		return ""
+	}
+	logger.InfoCF("agent", "Rate limit reached", map[string]any{
+		"channel":   msg.Channel,
+		"sender_id": msg.SenderID,
+		"scope":     denial.Scope,
+		"resource":  denial.Resource,
+		"limit":     denial.Limit,
+	})
+	lang := al.uiLanguage(msg)
+	renderer, _ := notify.NewRenderer(al.GetConfig().Notifications.Templates)
+	return renderer.RenderIn(lang, notify.EventRateLimited, msg.Channel, notify.Vars{
+		"Scope":      denial.Scope,
+		"Resource":   denial.Resource,
+		"Limit":      denial.Limit,
+		"RetryAfter": formatRetryAfter(lang, denial.RetryAfter),
+	})
+}
+
+// formatRetryAfter rounds d up to whole seconds, or minutes past a minute,
+// in the language lang.
+func formatRetryAfter(lang string, d time.Duration) string {
+	if d <= time.Minute {
+		return i18n.Sprintf(lang, "%d seconds", max(int((d+time.Second-1)/time.Second), 1))
+	}
+	return i18n.Sprintf(lang, "%d minutes", int((d+time.Minute-1)/time.Minute))
+}
+
+var errUnknownQuotaUser = errors.New("no usage recorded for that user since the last restart")
+
+// addQuotaRuntime lets /quota show what the sender, or a user an admin
+// names, used of the rate limits, and lets admins reset them.
+func (al *AgentLoop) addQuotaRuntime(rt *commands.Runtime, msg bus.InboundMessage) {
+	l := al.limits
+	if l == nil || !l.Enabled() {
+		return
+	}
+	// resolve finds the channel and sender ID of user, given as the sender
+	// ID or "channel:id"; "" is the sender of msg.
+	resolve := func(user string) (channel, senderID string, err error) {
+		if user == "" {
+			return msg.Channel, msg.SenderID, nil
+		}
+		candidates := [][2]string{{msg.Channel, user}}
+		if ch, id, ok := strings.Cut(user, ":"); ok {
+			candidates = append(candidates, [2]string{ch, user}, [2]string{ch, id})
+		}
+		for _, c := range candidates {
+			if l.Known(c[0], c[1]) {
+				return c[0], c[1], nil
+			}
+		}
+		return "", "", errUnknownQuotaUser
+	}
+	rt.QuotaStatus = func(user string) (string, []ratelimit.ScopeStatus, error) {
+		channel, senderID, err := resolve(user)
+		if err != nil {
+			return "", nil, err
+		}
+		return channel + ":" + senderID, l.Status(channel, senderID), nil
+	}
+	rt.ResetQuota = func(user string) (string, error) {
+		if user == "all" {
+			l.ResetAll()
+			return "everyone", nil
+		}
+		channel, senderID, err := resolve(user)
+		if err != nil {
+			return "", err
+		}
+		l.Reset(channel, senderID)
+		logger.InfoCF("agent", "Rate limits reset", map[string]any{
+			"user":  channel + ":" + senderID,
+			"by":    msg.Channel + ":" + msg.SenderID,
+			"scope": ratelimit.ScopeUser,
+		})
+		return channel + ":" + senderID, nil
+	}
+}
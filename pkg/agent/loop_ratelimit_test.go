@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestRateLimits_StopRepliesPerUser(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		RateLimits: config.RateLimitsConfig{
+			Enabled: true,
+			PerUser: config.RateLimit{TokensPerDay: 40},
+			Exempt:  []string{"telegram:admin"},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), usageProvider{})
+	defer al.Close()
+	ctx := context.Background()
+	msg := func(sender string) bus.InboundMessage {
+		return bus.InboundMessage{Channel: "telegram", SenderID: sender, ChatID: "c-" + sender, Content: "hi"}
+	}
+
+	if reply, err := al.processMessage(ctx, msg("1")); err != nil || reply != "Hi there" {
+		t.Fatalf("first message = %q, %v", reply, err)
+	}
+	reply, err := al.processMessage(ctx, msg("1"))
+	if err != nil || !strings.Contains(reply, "resets at midnight") {
+		t.Fatalf("over the token limit = %q, %v", reply, err)
+	}
+	for _, sender := range []string{"2", "admin", "admin"} {
+		if reply, _ := al.processMessage(ctx, msg(sender)); reply != "Hi there" {
+			t.Errorf("%s was limited by another user's tokens: %q", sender, reply)
+		}
+	}
+
+	rt := &commands.Runtime{}
+	al.addQuotaRuntime(rt, msg("1"))
+	if rt.QuotaStatus == nil || rt.ResetQuota == nil {
+		t.Fatal("quota runtime missing")
+	}
+	who, status, err := rt.QuotaStatus("")
+	if err != nil || who != "telegram:1" || status[0].Tokens != 49 {
+		t.Fatalf("QuotaStatus = %q %+v %v", who, status, err)
+	}
+	if _, err := rt.ResetQuota("telegram:1"); err != nil {
+		t.Fatal(err)
+	}
+	if reply, _ := al.processMessage(ctx, msg("1")); reply != "Hi there" {
+		t.Errorf("after reset = %q", reply)
+	}
+}
@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/branch"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/database"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/recall"
+)
+
+// openRecall opens the long-term memory store when recall is enabled and
+// its embedding model can embed. With session encryption on, memories are
+// encrypted with the same key, and recall stays off without it.
+func openRecall(cfg *config.Config, workspace string) *recall.Store {
+	if !cfg.Recall.Enabled {
+		return nil
+	}
+	embedder, modelID, err := providers.CreateEmbedder(cfg, cfg.Recall.EmbeddingModel, workspace)
+	if err != nil {
+		logger.WarnCF("agent", "Recall disabled: no embedding model", map[string]any{
+			"embedding_model": cfg.Recall.EmbeddingModel,
+			"error":           err.Error(),
+		})
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store, err := recall.Open(ctx, database.Path(workspace), embedder, modelID)
+	if err != nil {
+		logger.WarnCF("agent", "Recall disabled: database unavailable", map[string]any{
+			"error": err.Error(),
+		})
+		return nil
+	}
+	if err := encryptRecall(ctx, cfg.Session, store); err != nil {
+		store.Close()
+		logger.WarnCF("agent", "Recall disabled: cannot encrypt memories", map[string]any{
+			"error": err.Error(),
+		})
+		return nil
+	}
+	return store
+}
+
+// encryptRecall has store encrypt what it keeps with the session key, when
+// session encryption is on.
+func encryptRecall(ctx context.Context, cfg config.SessionConfig, store *recall.Store) error {
+	key, err := sessionEncryptionKey(cfg)
+	if err != nil || key == nil {
+		return err
+	}
+	sealer, err := memory.NewSealer(key)
+	if err != nil {
+		return err
+	}
+	return store.Encrypt(ctx, sealer)
+}
+
+// recallPrompt returns the memories of the turn's conversation that are
+// relevant to its message, as a system prompt section.
+func (al *AgentLoop) recallPrompt(ctx context.Context, opts processOptions) string {
+	if al.recall == nil || opts.Ephemeral || opts.NoHistory || opts.SessionKey == "" {
+		return ""
+	}
+	cfg := al.GetConfig().Recall
+	topK, minScore := cfg.TopK, cfg.MinScore
+	if topK <= 0 {
+		topK = recall.DefaultTopK
+	}
+	if minScore <= 0 {
+		minScore = recall.DefaultMinScore
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	hits, err := al.recall.Search(ctx, branch.BaseKey(opts.SessionKey), opts.UserMessage, topK, minScore)
+	if err != nil {
+		logger.WarnCF("agent", "Failed to retrieve memories", map[string]any{
+			"session_key": opts.SessionKey,
+			"error":       err.Error(),
+		})
+		return ""
+	}
+	return recall.Prompt(hits)
+}
+
+// addRecallRuntime lets /remember and /forget manage the memories of the
+// conversation. Ephemeral conversations keep none.
+func (al *AgentLoop) addRecallRuntime(rt *commands.Runtime, msg bus.InboundMessage, opts *processOptions) {
+	if al.recall == nil || opts == nil || opts.SessionKey == "" || opts.Ephemeral {
+		return
+	}
+	scope := branch.BaseKey(opts.SessionKey)
+	rt.Remember = func(ctx context.Context, text string) (recall.Memory, error) {
+		return al.recall.Add(ctx, scope, text, msg.Channel+":"+msg.SenderID)
+	}
+	rt.ListMemories = func(ctx context.Context) ([]recall.Memory, error) {
+		return al.recall.List(ctx, scope)
+	}
+	rt.RemoveMemory = func(ctx context.Context, id int64) error {
+		return al.recall.Remove(ctx, scope, id)
+	}
+	rt.ForgetMemories = func(ctx context.Context) (int, error) {
+		return al.recall.Forget(ctx, scope, "")
+	}
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/database"
+	"github.com/sipeed/picoclaw/pkg/erasure"
+	"github.com/sipeed/picoclaw/pkg/recall"
+)
+
+// sameEmbedder embeds every text as the same vector, so every memory matches.
+type sameEmbedder struct{}
+
+func (sameEmbedder) Embed(_ context.Context, texts []string, _ string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i := range out {
+		out[i] = []float32{1, 0}
+	}
+	return out, nil
+}
+
+func TestRecall_InjectsMemoriesAndForgetsThem(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{Workspace: workspace, Model: "test-model", MaxTokens: 4096},
+		},
+		Session: config.SessionConfig{DMScope: "per-channel-peer"},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	defer al.Close()
+	store, err := recall.Open(context.Background(), database.Path(workspace), sameEmbedder{}, "embed")
+	if err != nil {
+		t.Fatalf("recall.Open: %v", err)
+	}
+	al.recall = store
+	agent := al.GetRegistry().GetDefaultAgent()
+
+	dm := bus.InboundMessage{
+		Channel: "telegram", SenderID: "7", ChatID: "7",
+		Peer: bus.Peer{Kind: "direct", ID: "7"}, Content: "/remember I am vegetarian",
+	}
+	opts := &processOptions{Channel: "telegram", ChatID: "7", SenderID: "7", SessionKey: "agent:main:telegram:direct:7"}
+	if reply, _ := al.handleCommand(context.Background(), dm, agent, opts); reply != "Remembered as #1." {
+		t.Fatalf("/remember = %q", reply)
+	}
+	store.Add(context.Background(), "agent:main:discord:group:1", "The team ships on Fridays", "telegram:7")
+
+	dm.Content = "suggest a dinner"
+	if _, err := al.processMessage(context.Background(), dm); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	system := provider.lastMessages[0].Content
+	if !strings.Contains(system, "## Memories") || !strings.Contains(system, "I am vegetarian") {
+		t.Errorf("system prompt lacks the memory:\n%s", system)
+	}
+	if strings.Contains(system, "Fridays") {
+		t.Error("memory of another conversation was injected")
+	}
+
+	receipt := al.ForgetUser(erasure.Subject{Channel: "telegram", SenderID: "7"}, "admin")
+	if !strings.Contains(receipt.Text(), "memories: 2 deleted") {
+		t.Errorf("receipt = %s", receipt.Text())
+	}
+}
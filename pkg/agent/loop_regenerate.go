@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// maxRegenerableTurns bounds the prompts kept for 🔁 reactions.
+const maxRegenerableTurns = 500
+
+// metadataKeyRegenerate marks a prompt sent again because its reply is to
+// be regenerated.
+const metadataKeyRegenerate = "regenerate"
+
+// errUnknownTurn is returned when the prompt of a turn is no longer kept.
+var errUnknownTurn = errors.New("turn is no longer known")
+
+// turnPrompts keeps the user messages of recent turns, oldest dropped first,
+// so their replies can be regenerated.
+type turnPrompts struct {
+	mu    sync.Mutex
+	msgs  map[string]bus.InboundMessage
+	order []string // turn IDs, oldest first
+}
+
+func newTurnPrompts() *turnPrompts {
+	return &turnPrompts{msgs: make(map[string]bus.InboundMessage)}
+}
+
+func (p *turnPrompts) remember(msg bus.InboundMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.msgs[msg.RequestID]; !ok {
+		p.order = append(p.order, msg.RequestID)
+	}
+	p.msgs[msg.RequestID] = msg
+	for len(p.order) > maxRegenerableTurns {
+		delete(p.msgs, p.order[0])
+		p.order = p.order[1:]
+	}
+}
+
+func (p *turnPrompts) get(turnID string) (bus.InboundMessage, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	msg, ok := p.msgs[turnID]
+	return msg, ok
+}
+
+// isRegenerated reports whether msg asks for a new answer to its prompt.
+func isRegenerated(msg bus.InboundMessage) bool {
+	return inboundMetadata(msg, metadataKeyRegenerate) == "true"
+}
+
+// regenerateTurn answers the prompt of turnID again, as if it had been
+// edited: when it is still the latest message of its session, its turn is
+// rolled back and the new answer replaces the old reply.
+func (al *AgentLoop) regenerateTurn(ctx context.Context, turnID string, sender bus.SenderInfo) error {
+	msg, ok := al.prompts.get(turnID)
+	if !ok || msg.MessageID == "" {
+		return errUnknownTurn
+	}
+
+	msg.RequestID = ""
+	msg.Metadata = maps.Clone(msg.Metadata)
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]string)
+	}
+	msg.Metadata[metadataKeyEdited] = "true"
+	msg.Metadata[metadataKeyRegenerate] = "true"
+	delete(msg.Metadata, metadataKeyVariant)
+
+	logger.InfoCF("agent", "Regenerating reply on request", map[string]any{
+		"turn_id":      turnID,
+		"channel":      msg.Channel,
+		"chat_id":      msg.ChatID,
+		"requested_by": sender.PlatformID,
+	})
+	return al.bus.PublishInbound(ctx, msg)
+}
+
+// summarizeTurn asks for a short version of the reply of turnID, which was
+// held for being too long, as if its user had asked for one.
+func (al *AgentLoop) summarizeTurn(ctx context.Context, turnID string, sender bus.SenderInfo) error {
+	msg, ok := al.prompts.get(turnID)
+	if !ok {
+		return errUnknownTurn
+	}
+
+	msg.RequestID = ""
+	msg.MessageID = ""
+	msg.Media = nil
+	msg.Content = channels.LongOutputSummaryPrompt
+	msg.Metadata = maps.Clone(msg.Metadata)
+	delete(msg.Metadata, metadataKeyEdited)
+	delete(msg.Metadata, metadataKeyRegenerate)
+	delete(msg.Metadata, metadataKeyVariant)
+
+	logger.InfoCF("agent", "Summarizing long reply on request", map[string]any{
+		"turn_id":      turnID,
+		"channel":      msg.Channel,
+		"chat_id":      msg.ChatID,
+		"requested_by": sender.PlatformID,
+	})
+	return al.bus.PublishInbound(ctx, msg)
+}
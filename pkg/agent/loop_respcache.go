@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/respcache"
+)
+
+// responseCacheKey returns the response cache key of the turn, or "" when
+// its reply is not to be cached: the cache is off for the channel, the turn
+// has media, asks for a new answer, is off the record, or runs without
+// history, as the heartbeat does. The key covers the earlier conversation,
+// and the session unless the cache is shared, so a "yes" is only answered
+// from a reply to the same question.
+func (al *AgentLoop) responseCacheKey(messages []providers.Message, opts processOptions, model string) string {
+	if !al.responses.Enabled(opts.Channel) || len(opts.Media) > 0 || opts.NoCache || opts.NoHistory ||
+		opts.Ephemeral || len(messages) < 2 {
+		return ""
+	}
+	var conversation strings.Builder
+	if !al.GetConfig().ResponseCache.Shared {
+		conversation.WriteString(opts.SessionKey)
+	}
+	// The last message is the prompt itself
+	for _, m := range messages[1 : len(messages)-1] {
+		fmt.Fprintf(&conversation, "\x00%s\x00%s", m.Role, m.Content)
+		for _, tc := range m.ToolCalls {
+			fmt.Fprintf(&conversation, "\x00%s", tc.ID)
+		}
+	}
+	return respcache.Key(opts.UserMessage, stableSystemPrompt(messages[0]), conversation.String(), model)
+}
+
+// stableSystemPrompt is the system prompt of a turn without its dynamic
+// context, the block BuildMessages puts second with the time, chat, and
+// sender, so the same question from another chat or minute still matches.
+func stableSystemPrompt(msg providers.Message) string {
+	if msg.Role != "system" {
+		return ""
+	}
+	if len(msg.SystemParts) < 2 {
+		return msg.Content
+	}
+	parts := make([]string, 0, len(msg.SystemParts)-1)
+	for i, part := range msg.SystemParts {
+		if i != 1 {
+			parts = append(parts, part.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n---\n\n")
+}
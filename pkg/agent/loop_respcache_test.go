@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+type countingProvider struct {
+	calls int
+}
+
+func (p *countingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.calls++
+	return &providers.LLMResponse{Content: "Go is a language"}, nil
+}
+
+func (p *countingProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func newResponseCacheLoop(t *testing.T, cache config.ResponseCacheConfig) (*AgentLoop, *countingProvider) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Session:       config.SessionConfig{DMScope: "per-channel-peer"},
+		Ephemeral:     config.EphemeralConfig{Enabled: true, Users: []string{"u-9"}},
+		ResponseCache: cache,
+	}
+	provider := &countingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	t.Cleanup(al.Close)
+	return al, provider
+}
+
+func askIn(t *testing.T, al *AgentLoop, channel, chatID, content string) string {
+	t.Helper()
+	reply, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: channel, SenderID: "u-" + chatID, ChatID: chatID, Content: content,
+		Peer: bus.Peer{Kind: "direct", ID: "u-" + chatID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func TestResponseCache_AnswersRepeatedPrompt(t *testing.T) {
+	al, provider := newResponseCacheLoop(t, config.ResponseCacheConfig{
+		Enabled: true, Shared: true, Channels: []string{"telegram"},
+	})
+
+	askIn(t, al, "telegram", "1", "What is Go?")
+	if reply := askIn(t, al, "telegram", "2", "what is  go?"); reply != "Go is a language" || provider.calls != 1 {
+		t.Errorf("repeated prompt = %q after %d calls, want the cached reply", reply, provider.calls)
+	}
+	askIn(t, al, "telegram", "1", "What is Rust?")
+	if provider.calls != 2 {
+		t.Errorf("calls = %d, want a new prompt to reach the provider", provider.calls)
+	}
+	askIn(t, al, "discord", "1", "What is Go?")
+	if provider.calls != 3 {
+		t.Errorf("calls = %d, want channels outside the list uncached", provider.calls)
+	}
+
+	// A follow-up depends on the conversation before it
+	askIn(t, al, "telegram", "1", "yes")
+	askIn(t, al, "telegram", "3", "What is Zig?")
+	askIn(t, al, "telegram", "3", "yes")
+	if provider.calls != 6 {
+		t.Errorf("calls = %d, want follow-ups in other conversations uncached", provider.calls)
+	}
+}
+
+func TestResponseCache_ScopedToSession(t *testing.T) {
+	al, provider := newResponseCacheLoop(t, config.ResponseCacheConfig{Enabled: true})
+
+	askIn(t, al, "telegram", "1", "What is Go?")
+	askIn(t, al, "telegram", "2", "What is Go?")
+	if provider.calls != 2 {
+		t.Errorf("calls = %d, want another chat answered by the provider", provider.calls)
+	}
+	askIn(t, al, "telegram", "1", "/clear")
+	askIn(t, al, "telegram", "1", "What is Go?")
+	if provider.calls != 2 {
+		t.Errorf("calls = %d, want the same session answered from the cache", provider.calls)
+	}
+
+	// Off-the-record turns are never cached, nor answered from the cache
+	askIn(t, al, "telegram", "9", "What is Go?")
+	askIn(t, al, "telegram", "9", "/clear")
+	askIn(t, al, "telegram", "9", "What is Go?")
+	if provider.calls != 4 || al.responses.Len() != 2 {
+		t.Errorf("calls = %d, cached = %d after ephemeral turns", provider.calls, al.responses.Len())
+	}
+}
@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/branch"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/database"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/search"
+)
+
+// openSearchIndex opens the full-text index of the workspace database, or
+// returns nil when session.search_index is off or the database cannot be
+// opened. The index holds messages in the clear, so encrypted sessions are
+// not indexed.
+func openSearchIndex(cfg config.SessionConfig, workspace string) *search.Index {
+	if !cfg.SearchIndex {
+		return nil
+	}
+	if cfg.Encryption.Enabled {
+		logger.WarnCF("agent", "Search index disabled: sessions are encrypted", nil)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	index, err := search.OpenIndex(ctx, database.Path(workspace))
+	if err != nil {
+		logger.WarnCF("agent", "Search index disabled: database unavailable", map[string]any{
+			"error": err.Error(),
+		})
+		return nil
+	}
+	return index
+}
+
+// indexTurn adds the question and answer of a finished turn to the search
+// index. Both link to the platform message that asked. Once a day messages
+// past session.retention are dropped from the index.
+func (al *AgentLoop) indexTurn(agent *AgentInstance, opts processOptions, answer string) {
+	if al.searchIndex == nil || opts.NoHistory || opts.Ephemeral || constants.IsInternalChannel(opts.Channel) {
+		return
+	}
+	now := time.Now()
+	entry := search.Entry{
+		Time:       now,
+		AgentID:    agent.ID,
+		SessionKey: branch.BaseKey(opts.SessionKey),
+		Channel:    opts.Channel,
+		ChatID:     opts.ChatID,
+		Link:       branch.MessageLink(opts.Channel, opts.GuildID, opts.ChatID, opts.MessageID),
+	}
+	question, reply := entry, entry
+	question.Role, question.Text, question.SenderID = "user", opts.UserMessage, opts.SenderID
+	reply.Role, reply.Text = "assistant", answer
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := al.searchIndex.Add(ctx, question, reply); err != nil {
+		logger.WarnCF("agent", "Failed to index messages for search", map[string]any{"error": err.Error()})
+	}
+
+	day := now.Unix() / 86400
+	retention := al.GetConfig().Session.Retention
+	if last := al.searchExpired.Load(); last < day && al.searchExpired.CompareAndSwap(last, day) &&
+		retention.Enabled() {
+		if _, err := al.searchIndex.Expire(ctx, now, retention.MaxAge); err != nil {
+			logger.WarnCF("agent", "Failed to expire indexed messages", map[string]any{"error": err.Error()})
+		}
+	}
+}
+
+// conversationMessages returns the user and assistant messages stored for
+// the chat of opts, across all of its branches. User messages get a link
+// when their platform message ID was recorded and the platform has links.
+func (al *AgentLoop) conversationMessages(agent *AgentInstance, opts *processOptions) []search.Message {
+	base := branch.BaseKey(opts.SessionKey)
+	sessions := []string{base}
+	_, branches := al.branches.Branches(base)
+	for _, b := range branches {
+		sessions = append(sessions, branch.Key(base, b.Name))
+	}
+
+	// Forks copy the history they were taken from; keep one copy.
+	seen := make(map[string]bool)
+	var msgs []search.Message
+	for _, session := range sessions {
+		history := agent.Sessions.GetHistory(session)
+		links := make(map[int]string)
+		for _, mark := range al.branches.Marks(session) {
+			if i := locateTurn(history, mark); i >= 0 {
+				links[i] = branch.MessageLink(opts.Channel, opts.GuildID, opts.ChatID, mark.MessageID)
+			}
+		}
+		for i, m := range history {
+			if (m.Role != "user" && m.Role != "assistant") || strings.TrimSpace(m.Content) == "" {
+				continue
+			}
+			key := m.Role + "\x00" + m.Content
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			msgs = append(msgs, search.Message{Role: m.Role, Text: m.Content, Link: links[i]})
+		}
+	}
+	return msgs
+}
+
+// addSearchRuntime exposes the stored history of this chat to /search. With
+// the search index, its matches come first, and the history fills up the
+// rest with messages from before the index was turned on.
+func (al *AgentLoop) addSearchRuntime(rt *commands.Runtime, agent *AgentInstance, opts *processOptions) {
+	if agent == nil || agent.Sessions == nil || opts == nil || opts.SessionKey == "" {
+		return
+	}
+	rt.SearchHistory = func(query string, limit int) []search.Hit {
+		hits := al.searchIndexed(agent, opts, query, limit)
+		seen := make(map[string]bool, len(hits))
+		for _, h := range hits {
+			seen[h.Role+"\x00"+h.Text] = true
+		}
+		for _, h := range search.Search(al.conversationMessages(agent, opts), query, limit) {
+			if len(hits) == limit {
+				break
+			}
+			if !seen[h.Role+"\x00"+h.Text] {
+				hits = append(hits, h)
+			}
+		}
+		return hits
+	}
+}
+
+// searchIndexed returns the matches of query in the search index among the
+// messages of this chat's session and its branches.
+func (al *AgentLoop) searchIndexed(agent *AgentInstance, opts *processOptions, query string, limit int) []search.Hit {
+	if al.searchIndex == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	matches, err := al.searchIndex.Query(ctx, query, search.Filter{
+		AgentID:    agent.ID,
+		SessionKey: branch.BaseKey(opts.SessionKey),
+		Limit:      limit,
+	})
+	if err != nil {
+		logger.WarnCF("agent", "Search index query failed", map[string]any{"error": err.Error()})
+		return nil
+	}
+	hits := make([]search.Hit, 0, len(matches))
+	for _, m := range matches {
+		hits = append(hits, search.Hit{
+			Message: search.Message{Role: m.Role, Text: m.Text, Link: m.Link},
+			Excerpt: m.Excerpt,
+		})
+	}
+	return hits
+}
+
+// SearchMessages searches the search index for the admin API. It returns nil
+// when the index is off.
+func (al *AgentLoop) SearchMessages(ctx context.Context, query string, f search.Filter) ([]search.Match, error) {
+	return al.searchIndex.Query(ctx, query, f)
+}
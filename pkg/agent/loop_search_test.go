@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/erasure"
+	"github.com/sipeed/picoclaw/pkg/search"
+)
+
+func TestProcessMessage_SearchesConversation(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &recordingProvider{})
+	ctx := context.Background()
+
+	send := func(messageID, content string) string {
+		t.Helper()
+		response, err := al.processMessage(ctx, bus.InboundMessage{
+			Channel:   "discord",
+			SenderID:  "u1",
+			ChatID:    "c1",
+			MessageID: messageID,
+			Content:   content,
+		})
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", content, err)
+		}
+		return response
+	}
+
+	send("m1", "remember the wifi password is hunter2")
+	send("m2", "what is the weather like")
+	send("m3", "/branch fork m2 other")
+	send("m4", "another wifi question")
+
+	reply := send("m5", "/search wifi password")
+	if !strings.Contains(reply, "1. You: remember the wifi password is hunter2") ||
+		!strings.Contains(reply, "https://discord.com/channels/@me/c1/m1") {
+		t.Fatalf("search reply lacks the best match and its link:\n%s", reply)
+	}
+	if !strings.Contains(reply, "another wifi question") {
+		t.Fatalf("search reply lacks the message sent on a branch:\n%s", reply)
+	}
+	if strings.Count(reply, "hunter2") != 1 {
+		t.Fatalf("message copied into the branch was listed twice:\n%s", reply)
+	}
+	if reply := send("m6", "/search zebra"); !strings.HasPrefix(reply, "No messages") {
+		t.Fatalf("no-match reply = %q", reply)
+	}
+}
+
+func TestProcessMessage_SearchIndexKeepsOldTurns(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Session: config.SessionConfig{DMScope: "per-channel-peer", SearchIndex: true},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &recordingProvider{})
+	defer al.Close()
+	ctx := context.Background()
+	msg := bus.InboundMessage{Channel: "discord", SenderID: "u1", ChatID: "c1", Peer: bus.Peer{Kind: "direct", ID: "u1"}}
+	send := func(messageID, content string) string {
+		t.Helper()
+		msg.MessageID, msg.Content = messageID, content
+		response, err := al.processMessage(ctx, msg)
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", content, err)
+		}
+		return response
+	}
+
+	send("m1", "where is the staging database")
+	// The turn leaves the history, as after summarization
+	route, agent, err := al.resolveMessageRoute(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.Sessions.SetHistory(route.SessionKey, nil)
+
+	reply := send("m2", "/search staging database")
+	if !strings.Contains(reply, "You: where is the **staging** **database**") ||
+		!strings.Contains(reply, "https://discord.com/channels/@me/c1/m1") {
+		t.Fatalf("search reply lacks the indexed turn:\n%s", reply)
+	}
+
+	matches, err := al.SearchMessages(ctx, "mock response", search.Filter{Channel: "discord"})
+	if err != nil || len(matches) != 1 || matches[0].Role != "assistant" || matches[0].ChatID != "c1" {
+		t.Fatalf("SearchMessages = %+v, %v", matches, err)
+	}
+
+	receipt := al.ForgetUser(erasure.Subject{Channel: "discord", SenderID: "u1"}, "test")
+	if matches, _ := al.SearchMessages(ctx, "staging", search.Filter{}); len(matches) != 0 || receipt.Failed() {
+		t.Errorf("after erasure: %+v, receipt %+v", matches, receipt)
+	}
+}
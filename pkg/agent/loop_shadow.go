@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/shadow"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// shadowTurn collects the tool calls of a turn answered in shadow mode,
+// none of which runs.
+type shadowTurn struct {
+	mu    sync.Mutex
+	calls []shadow.ToolCall
+}
+
+// skip records tc in place of running it, and tells the model so it goes on
+// to a reply.
+func (s *shadowTurn) skip(tc providers.ToolCall) *tools.ToolResult {
+	s.mu.Lock()
+	s.calls = append(s.calls, shadow.ToolCall{Name: tc.Name, Arguments: tc.Arguments})
+	s.mu.Unlock()
+	return tools.SilentResult(fmt.Sprintf(
+		"%s was not run: this is a dry run. Answer as you would without its result.", tc.Name))
+}
+
+func (s *shadowTurn) toolCalls() []shadow.ToolCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// shadowed reports whether the messages of channel are answered in shadow
+// mode.
+func (al *AgentLoop) shadowed(channel string) bool {
+	cfg := al.GetConfig()
+	return cfg != nil && !constants.IsInternalChannel(channel) && cfg.Shadow.Covers(channel)
+}
+
+// recordShadowReply logs what a turn answered in shadow mode would have
+// replied to msg, and the tool calls it would have made. The text of
+// ephemeral conversations is left out.
+func (al *AgentLoop) recordShadowReply(
+	agent *AgentInstance,
+	msg bus.InboundMessage,
+	opts processOptions,
+	reply string,
+	err error,
+) {
+	entry := shadow.Entry{
+		Channel:   msg.Channel,
+		ChatID:    msg.ChatID,
+		SenderID:  msg.SenderID,
+		RequestID: msg.RequestID,
+		Agent:     agent.ID,
+		Model:     agent.Model,
+		Message:   opts.UserMessage,
+		Reply:     reply,
+		ToolCalls: opts.shadow.toolCalls(),
+	}
+	if opts.Ephemeral {
+		entry.Message, entry.Reply = "", ""
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	logger.InfoCF("agent", "Shadow mode: would have replied", map[string]any{
+		"channel":    msg.Channel,
+		"chat_id":    msg.ChatID,
+		"request_id": msg.RequestID,
+		"tool_calls": len(entry.ToolCalls),
+		"reply":      logger.RedactRequestContent(msg.RequestID, utils.Truncate(reply, 200)),
+	})
+	if err := al.shadowLog.Record(entry); err != nil {
+		logger.WarnCF("agent", "Failed to write shadow log", map[string]any{"error": err.Error()})
+	}
+}
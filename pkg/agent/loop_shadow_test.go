@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/shadow"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+)
+
+func TestProcessMessage_ShadowModeLogsInsteadOfReplying(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Transcripts: config.TranscriptsConfig{Enabled: true},
+		Shadow:      config.ShadowConfig{Enabled: true, Channels: []string{"telegram"}},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &toolThenAnswerProvider{})
+	al.RegisterTool(&mockCustomTool{})
+
+	msg := bus.InboundMessage{Channel: "telegram", SenderID: "u1", ChatID: "c1", Content: "run it"}
+	reply, err := al.processMessage(context.Background(), msg)
+	if err != nil || reply != "" {
+		t.Fatalf("processMessage() = %q, %v; want no reply", reply, err)
+	}
+
+	entries, err := al.Transcripts().Query(transcript.Filter{Session: "agent:main:main"})
+	if err != nil || len(entries) != 1 || strings.Contains(entries[0].Result, "Custom tool executed") {
+		t.Fatalf("the tool ran in shadow mode: %+v, %v", entries, err)
+	}
+
+	data, err := os.ReadFile(shadow.LogPath(workspace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e shadow.Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		t.Fatalf("shadow log %q: %v", data, err)
+	}
+	if e.Reply != "done" || e.Message != "run it" || e.ChatID != "c1" ||
+		len(e.ToolCalls) != 1 || e.ToolCalls[0].Name != "mock_custom" || e.ToolCalls[0].Arguments["q"] != "x" {
+		t.Errorf("shadow entry = %+v", e)
+	}
+
+	// Other channels are answered as usual
+	msg.Channel = "discord"
+	if reply, err := al.processMessage(context.Background(), msg); err != nil || reply != "done" {
+		t.Errorf("discord reply = %q, %v", reply, err)
+	}
+}
@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/dispatch"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/notify"
+)
+
+// interruptedPath is where the messages a shutdown kept from being answered
+// wait for the next start.
+func interruptedPath(workspace string) string {
+	return filepath.Join(workspace, "state", "interrupted.json")
+}
+
+// shutDown reports whether ctx was cancelled because the agent stopped
+// before the turn could finish.
+func shutDown(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), dispatch.ErrClosed)
+}
+
+// checkpointInterrupted saves msgs to be answered after the restart and
+// tells their senders so. Messages of ephemeral conversations are not
+// saved; their senders are asked to send them again.
+func (al *AgentLoop) checkpointInterrupted(msgs ...bus.InboundMessage) {
+	defaultAgent := al.GetRegistry().GetDefaultAgent()
+	if len(msgs) == 0 || defaultAgent == nil {
+		return
+	}
+	var kept, dropped []bus.InboundMessage
+	for _, msg := range msgs {
+		if al.isEphemeral(msg) {
+			dropped = append(dropped, msg)
+		} else {
+			kept = append(kept, msg)
+		}
+	}
+	if len(kept) > 0 && !al.saveInterrupted(defaultAgent.Workspace, kept) {
+		kept = nil
+	}
+
+	renderer, _ := notify.NewRenderer(al.GetConfig().Notifications.Templates)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	notice := func(msgs []bus.InboundMessage, event string) {
+		for _, msg := range msgs {
+			if constants.IsInternalChannel(msg.Channel) {
+				continue
+			}
+			al.bus.PublishOutbound(ctx, bus.OutboundMessage{
+				Channel:   msg.Channel,
+				ChatID:    msg.ChatID,
+				Content:   renderer.RenderIn(al.uiLanguage(msg), event, msg.Channel, notify.Vars{}),
+				RequestID: msg.RequestID,
+			})
+		}
+	}
+	notice(kept, notify.EventInterrupted)
+	notice(dropped, notify.EventInterruptedDropped)
+}
+
+// saveInterrupted adds msgs to the checkpoint file of workspace and reports
+// whether they were saved.
+func (al *AgentLoop) saveInterrupted(workspace string, msgs []bus.InboundMessage) bool {
+	path := interruptedPath(workspace)
+
+	al.checkpointMu.Lock()
+	var saved []bus.InboundMessage
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &saved)
+	}
+	saved = append(saved, msgs...)
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err == nil {
+		err = fileutil.WriteFileAtomic(path, data, 0o600)
+	}
+	al.checkpointMu.Unlock()
+	if err != nil {
+		logger.ErrorCF("agent", "Failed to save interrupted messages", map[string]any{
+			"path":     path,
+			"messages": len(msgs),
+			"error":    err.Error(),
+		})
+		return false
+	}
+	logger.InfoCF("agent", "Saved interrupted messages for the next start", map[string]any{
+		"messages": len(msgs),
+	})
+	return true
+}
+
+// resumeInterrupted puts the messages saved by the last shutdown back on
+// the bus. The file is removed first, so a message that keeps failing is
+// not replayed forever.
+func (al *AgentLoop) resumeInterrupted() {
+	defaultAgent := al.GetRegistry().GetDefaultAgent()
+	if defaultAgent == nil {
+		return
+	}
+	path := interruptedPath(defaultAgent.Workspace)
+
+	al.checkpointMu.Lock()
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = os.Remove(path)
+	}
+	al.checkpointMu.Unlock()
+	if err != nil {
+		return
+	}
+	var msgs []bus.InboundMessage
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		logger.WarnCF("agent", "Ignoring unreadable interrupted messages", map[string]any{
+			"path":  path,
+			"error": err.Error(),
+		})
+		return
+	}
+	logger.InfoCF("agent", "Resuming messages interrupted by the last shutdown", map[string]any{
+		"messages": len(msgs),
+	})
+	go func() {
+		for _, msg := range msgs {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := al.bus.PublishInbound(ctx, msg); err != nil {
+				logger.WarnCF("agent", "Failed to resume interrupted message", map[string]any{
+					"channel": msg.Channel,
+					"chat_id": msg.ChatID,
+					"error":   err.Error(),
+				})
+			}
+			cancel()
+		}
+	}()
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestShutdown_CheckpointsInterruptedTurn(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Dispatch: config.DispatchConfig{Workers: 1, DrainTimeoutSeconds: 1},
+	}
+	msgBus := bus.NewMessageBus()
+	provider := &blockingProvider{started: make(chan struct{})}
+	al := NewAgentLoop(cfg, msgBus, provider)
+	defer al.Close()
+
+	go al.Run(context.Background())
+	msg := bus.InboundMessage{Channel: "telegram", SenderID: "u1", ChatID: "c1", Content: "write an essay"}
+	msgBus.PublishInbound(context.Background(), msg)
+	select {
+	case <-provider.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn did not start")
+	}
+	al.Stop()
+
+	select {
+	case out := <-msgBus.OutboundChan():
+		if out.ChatID != "c1" || !strings.Contains(out.Content, "interrupted by a restart") {
+			t.Errorf("notice = %+v", out)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no interrupted notice")
+	}
+	if history := al.GetRegistry().GetDefaultAgent().Sessions.GetHistory("agent:main:main"); len(history) != 0 {
+		t.Errorf("history = %+v, want the interrupted turn forgotten", history)
+	}
+
+	next := NewAgentLoop(cfg, msgBus, usageProvider{})
+	defer next.Close()
+	next.resumeInterrupted()
+	select {
+	case in := <-msgBus.InboundChan():
+		if in.ChatID != "c1" || in.Content != "write an essay" {
+			t.Errorf("resumed message = %+v", in)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("interrupted message not resumed")
+	}
+	if _, err := os.Stat(interruptedPath(cfg.Agents.Defaults.Workspace)); !os.IsNotExist(err) {
+		t.Errorf("checkpoint file left after resuming: %v", err)
+	}
+}
+
+func TestShutdown_DoesNotCheckpointEphemeralMessages(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Ephemeral: config.EphemeralConfig{Enabled: true, Users: []string{"alice"}},
+	}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, usageProvider{})
+	defer al.Close()
+
+	al.checkpointInterrupted(
+		bus.InboundMessage{Channel: "telegram", SenderID: "alice", ChatID: "c1", Content: "my secret plans"},
+		bus.InboundMessage{Channel: "telegram", SenderID: "bob", ChatID: "c2", Content: "write an essay"},
+	)
+	notices := map[string]string{}
+	for range 2 {
+		select {
+		case out := <-msgBus.OutboundChan():
+			notices[out.ChatID] = out.Content
+		case <-time.After(5 * time.Second):
+			t.Fatalf("notices = %v, want two", notices)
+		}
+	}
+	if !strings.Contains(notices["c1"], "send your message again") ||
+		!strings.Contains(notices["c2"], "as soon as I'm back") {
+		t.Errorf("notices = %v", notices)
+	}
+
+	data, err := os.ReadFile(interruptedPath(cfg.Agents.Defaults.Workspace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret plans") || !strings.Contains(string(data), "write an essay") {
+		t.Errorf("checkpoint = %s", data)
+	}
+}
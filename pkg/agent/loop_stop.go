@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// errTurnStopped is the cancellation cause of a turn stopped with /stop.
+var errTurnStopped = errors.New("stopped by user")
+
+// errPromptDeleted is the cancellation cause of a turn whose prompt the user
+// deleted before it was answered.
+var errPromptDeleted = errors.New("prompt deleted by user")
+
+// errPromptEdited is the cancellation cause of a turn whose prompt the user
+// edited before it was answered. The edited version is answered instead.
+var errPromptEdited = errors.New("prompt edited by user")
+
+// interruptedNote is saved in place of the reply of a stopped turn, so the
+// model knows on the next turn that its previous answer was cut short.
+const interruptedNote = "[Interrupted: the user stopped this reply before it was finished.]"
+
+// activeTurn is the reply being generated for one chat.
+type activeTurn struct {
+	messageID string
+	cancel    context.CancelCauseFunc
+}
+
+func turnKey(channel, chatID string) string {
+	return channel + ":" + chatID
+}
+
+// beginTurn registers the turn handling msg so StopTurn can cancel it. The
+// returned function must be called when the turn ends.
+func (al *AgentLoop) beginTurn(ctx context.Context, msg bus.InboundMessage) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	key := turnKey(msg.Channel, msg.ChatID)
+	turn := &activeTurn{messageID: msg.MessageID, cancel: cancel}
+	al.turns.Store(key, turn)
+	return ctx, func() {
+		al.turns.CompareAndDelete(key, turn)
+		cancel(nil)
+	}
+}
+
+// StopTurn cancels the reply being generated for a chat, including any
+// provider request or tool call in progress. It reports whether a reply was
+// in flight.
+func (al *AgentLoop) StopTurn(channel, chatID string) bool {
+	v, ok := al.turns.LoadAndDelete(turnKey(channel, chatID))
+	if !ok {
+		return false
+	}
+	v.(*activeTurn).cancel(errTurnStopped)
+	logger.InfoCF("agent", "Reply stopped by user", map[string]any{
+		"channel": channel,
+		"chat_id": chatID,
+	})
+	return true
+}
+
+// StopPrompt cancels the reply being generated for the user message
+// messageID, because the user deleted it. It reports whether that message
+// was being answered.
+func (al *AgentLoop) StopPrompt(channel, chatID, messageID string) bool {
+	key := turnKey(channel, chatID)
+	v, ok := al.turns.Load(key)
+	if !ok || messageID == "" || v.(*activeTurn).messageID != messageID || !al.turns.CompareAndDelete(key, v) {
+		return false
+	}
+	v.(*activeTurn).cancel(errPromptDeleted)
+	logger.InfoCF("agent", "Reply cancelled, prompt deleted", map[string]any{
+		"channel":    channel,
+		"chat_id":    chatID,
+		"message_id": messageID,
+	})
+	return true
+}
+
+// stopEditedPrompt cancels the reply being generated for the user message
+// that msg is a new version of, so the edit is answered from scratch rather
+// than after an answer to the old text. It does nothing for edits that are
+// not regenerated. It reports whether a reply was cancelled.
+func (al *AgentLoop) stopEditedPrompt(msg bus.InboundMessage) bool {
+	if !isEdited(msg) || msg.MessageID == "" || commands.HasCommandPrefix(msg.Content) ||
+		(!al.GetConfig().Agents.Defaults.RegenerateOnEdit && !isRegenerated(msg)) {
+		return false
+	}
+	key := turnKey(msg.Channel, msg.ChatID)
+	v, ok := al.turns.Load(key)
+	if !ok || v.(*activeTurn).messageID != msg.MessageID || !al.turns.CompareAndDelete(key, v) {
+		return false
+	}
+	al.editedPrompts.Store(key+":"+msg.MessageID, true)
+	v.(*activeTurn).cancel(errPromptEdited)
+	logger.InfoCF("agent", "Reply cancelled, prompt edited", map[string]any{
+		"channel":    msg.Channel,
+		"chat_id":    msg.ChatID,
+		"message_id": msg.MessageID,
+	})
+	return true
+}
+
+// takeEditedPrompt reports whether msg is the edit that cancelled the reply
+// to its earlier version. That turn was forgotten, so msg is answered as a
+// new message.
+func (al *AgentLoop) takeEditedPrompt(msg bus.InboundMessage) bool {
+	_, ok := al.editedPrompts.LoadAndDelete(turnKey(msg.Channel, msg.ChatID) + ":" + msg.MessageID)
+	return ok
+}
+
+// promptEdited reports whether ctx was cancelled by stopEditedPrompt.
+func promptEdited(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errPromptEdited)
+}
+
+// promptDeleted reports whether ctx was cancelled by StopPrompt.
+func promptDeleted(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errPromptDeleted)
+}
+
+// stoppedTurn reports whether ctx was cancelled by StopTurn.
+func stoppedTurn(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errTurnStopped)
+}
@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// blockingProvider answers only once the request context is cancelled.
+type blockingProvider struct {
+	started chan struct{}
+}
+
+func (p *blockingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	close(p.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p *blockingProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestStopTurn_CancelsInFlightReply(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &blockingProvider{started: make(chan struct{})}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	if al.StopTurn("telegram", "c1") {
+		t.Fatal("expected nothing to stop before the turn starts")
+	}
+
+	msg := bus.InboundMessage{Channel: "telegram", SenderID: "u1", ChatID: "c1", Content: "write an essay"}
+	type result struct {
+		response string
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		ctx, endTurn := al.beginTurn(context.Background(), msg)
+		defer endTurn()
+		response, err := al.processMessage(ctx, msg)
+		done <- result{response, err}
+	}()
+
+	<-provider.started
+	if al.StopTurn("telegram", "other") {
+		t.Fatal("stopped a turn in another chat")
+	}
+	if !al.StopTurn("telegram", "c1") {
+		t.Fatal("expected the in-flight turn to be stopped")
+	}
+
+	var res result
+	select {
+	case res = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn did not end after StopTurn")
+	}
+	if res.err != nil || res.response != "" {
+		t.Fatalf("processMessage = %q, %v; want a silent end", res.response, res.err)
+	}
+
+	history := al.GetRegistry().GetDefaultAgent().Sessions.GetHistory("agent:main:main")
+	if len(history) == 0 || history[len(history)-1].Content != interruptedNote {
+		t.Fatalf("history does not end with the interrupted note: %+v", history)
+	}
+	if al.StopTurn("telegram", "c1") {
+		t.Fatal("expected the finished turn to be unregistered")
+	}
+}
+
+func TestStopPrompt_ForgetsTheTurn(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &blockingProvider{started: make(chan struct{})}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	msg := bus.InboundMessage{
+		Channel: "discord", SenderID: "u1", ChatID: "c1", MessageID: "m1", Content: "write an essay",
+	}
+	done := make(chan string, 1)
+	go func() {
+		ctx, endTurn := al.beginTurn(context.Background(), msg)
+		defer endTurn()
+		response, _ := al.processMessage(ctx, msg)
+		done <- response
+	}()
+
+	<-provider.started
+	if al.StopPrompt("discord", "c1", "m0") {
+		t.Fatal("stopped the turn for another message")
+	}
+	if !al.StopPrompt("discord", "c1", "m1") {
+		t.Fatal("expected the turn answering m1 to be stopped")
+	}
+
+	select {
+	case response := <-done:
+		if response != "" {
+			t.Fatalf("response = %q, want none", response)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn did not end after StopPrompt")
+	}
+	if history := al.GetRegistry().GetDefaultAgent().Sessions.GetHistory("agent:main:main"); len(history) != 0 {
+		t.Fatalf("history = %+v, want the deleted prompt forgotten", history)
+	}
+}
+
+// blockOnceProvider blocks its first request until it is cancelled and
+// answers the later ones.
+type blockOnceProvider struct {
+	started chan struct{}
+	calls   atomic.Int32
+}
+
+func (p *blockOnceProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if p.calls.Add(1) == 1 {
+		close(p.started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &providers.LLMResponse{Content: "answer to " + messages[len(messages)-1].Content}, nil
+}
+
+func (p *blockOnceProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestStopEditedPrompt_AnswersTheEdit(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				RegenerateOnEdit:  true,
+			},
+		},
+	}
+	provider := &blockOnceProvider{started: make(chan struct{})}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	msg := bus.InboundMessage{
+		Channel: "telegram", SenderID: "u1", ChatID: "c1", MessageID: "m1", Content: "write an esay",
+	}
+	done := make(chan string, 1)
+	go func() {
+		ctx, endTurn := al.beginTurn(context.Background(), msg)
+		defer endTurn()
+		response, _ := al.processMessage(ctx, msg)
+		done <- response
+	}()
+	<-provider.started
+
+	edit := msg
+	edit.Content = "write an essay"
+	edit.Metadata = map[string]string{metadataKeyEdited: "true"}
+	other := edit
+	other.MessageID = "m0"
+	if al.stopEditedPrompt(other) {
+		t.Fatal("stopped the turn for the edit of another message")
+	}
+	if !al.stopEditedPrompt(edit) {
+		t.Fatal("expected the turn answering m1 to be stopped")
+	}
+	select {
+	case response := <-done:
+		if response != "" {
+			t.Fatalf("cancelled turn replied %q", response)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn did not end after the edit")
+	}
+
+	response, err := al.processMessage(context.Background(), edit)
+	if err != nil || response != "answer to write an essay" {
+		t.Fatalf("edit = %q, %v; want it answered", response, err)
+	}
+	history := al.GetRegistry().GetDefaultAgent().Sessions.GetHistory("agent:main:main")
+	if len(history) != 2 || history[0].Content != "write an essay" {
+		t.Fatalf("history = %+v, want only the edited turn", history)
+	}
+}
@@ -0,0 +1,25 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// replyStream starts showing the reply to a user message while it is
+// generated, when the provider and the channel can stream and the user has
+// not turned streaming off. It returns nil otherwise.
+func (al *AgentLoop) replyStream(ctx context.Context, agent *AgentInstance, opts processOptions) *channels.ReplyStream {
+	if al.channelManager == nil || opts.MessageID == "" || constants.IsInternalChannel(opts.Channel) {
+		return nil
+	}
+	if _, ok := agent.Provider.(providers.StreamingProvider); !ok {
+		return nil
+	}
+	if !al.turnPrefs(opts).StreamingOn(al.channelManager.StreamingDefault(opts.Channel)) {
+		return nil
+	}
+	return al.channelManager.StreamReply(ctx, opts.Channel, opts.ChatID)
+}
@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/structured"
+)
+
+// schemaRetries is how many times a reply that does not conform to the
+// response schema is sent back for correction.
+const schemaRetries = 2
+
+// ProcessStructured answers content in the session like ProcessDirect, with
+// a reply that conforms to schema, returned as compact JSON.
+func (al *AgentLoop) ProcessStructured(
+	ctx context.Context,
+	content, sessionKey string,
+	schema *structured.Schema,
+) (json.RawMessage, error) {
+	if err := al.ensureMCPInitialized(ctx); err != nil {
+		return nil, err
+	}
+	al.StartPlugins(ctx)
+
+	reply, err := al.processMessage(ctx, bus.InboundMessage{
+		Channel:    "cli",
+		SenderID:   "cron",
+		ChatID:     "direct",
+		Content:    content,
+		SessionKey: sessionKey,
+		Metadata:   map[string]string{structured.MetadataKey: schema.String()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Commands answer in prose
+	data, err := schema.Validate(reply)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", structured.ErrNoMatch, err)
+	}
+	return data, nil
+}
+
+// responseSchema parses the response schema a message asks for, if any.
+func responseSchema(msg bus.InboundMessage) (*structured.Schema, error) {
+	raw := inboundMetadata(msg, structured.MetadataKey)
+	if raw == "" {
+		return nil, nil
+	}
+	return structured.Parse([]byte(raw))
+}
+
+// conformReply checks reply against the response schema of the turn. A
+// reply that does not conform goes back to the model with the validation
+// error, without tools, up to schemaRetries times. It returns the reply as
+// compact JSON.
+func (al *AgentLoop) conformReply(
+	ctx context.Context,
+	agent *AgentInstance,
+	messages []providers.Message,
+	opts processOptions,
+	reply string,
+) (string, error) {
+	data, err := opts.Schema.Validate(reply)
+	messages = append([]providers.Message(nil), messages...)
+	for attempt := 0; err != nil && attempt < schemaRetries; attempt++ {
+		logger.DebugCF("agent", "Reply does not match the response schema, asking again", map[string]any{
+			"agent_id": agent.ID,
+			"attempt":  attempt + 1,
+			"error":    err.Error(),
+		})
+		messages = append(messages,
+			providers.Message{Role: "assistant", Content: reply},
+			providers.Message{Role: "user", Content: structured.RetryPrompt(err)},
+		)
+		resp, callErr := agent.Provider.Chat(ctx, messages, nil, agent.Model, map[string]any{
+			"max_tokens":       agent.MaxTokens,
+			"temperature":      agent.Temperature,
+			"prompt_cache_key": agent.ID,
+			structured.Option:  opts.Schema.Map(),
+		})
+		if callErr != nil {
+			return "", callErr
+		}
+		reply = resp.Content
+		data, err = opts.Schema.Validate(reply)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", structured.ErrNoMatch, err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/structured"
+)
+
+// scriptedProvider answers with replies in turn, repeating the last, and
+// records what each call was given.
+type scriptedProvider struct {
+	replies  []string
+	opts     []map[string]any
+	messages [][]providers.Message
+}
+
+func (p *scriptedProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.opts = append(p.opts, opts)
+	p.messages = append(p.messages, append([]providers.Message(nil), messages...))
+	reply := p.replies[min(len(p.opts), len(p.replies))-1]
+	return &providers.LLMResponse{Content: reply}, nil
+}
+
+func (p *scriptedProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func newStructuredLoop(t *testing.T, replies ...string) (*AgentLoop, *scriptedProvider) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &scriptedProvider{replies: replies}
+	return NewAgentLoop(cfg, bus.NewMessageBus(), provider), provider
+}
+
+func TestProcessStructured_RetriesUntilTheReplyConforms(t *testing.T) {
+	schema, err := structured.Parse([]byte(`{"type": "object", "required": ["ok"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	al, provider := newStructuredLoop(t, "Sure, everything is fine.", "```json\n{\"ok\": true}\n```")
+
+	data, err := al.ProcessStructured(context.Background(), "status?", "structured", schema)
+	if err != nil {
+		t.Fatalf("ProcessStructured() error = %v", err)
+	}
+	if string(data) != `{"ok":true}` {
+		t.Errorf("ProcessStructured() = %s", data)
+	}
+	if len(provider.opts) != 2 {
+		t.Fatalf("provider calls = %d, want 2", len(provider.opts))
+	}
+	for i, opts := range provider.opts {
+		if opts[structured.Option] == nil {
+			t.Errorf("call %d options = %v", i, opts)
+		}
+	}
+	if system := provider.messages[0][0]; !strings.Contains(system.Content, "## Response format") {
+		t.Errorf("system prompt lacks the response format: %q", system.Content)
+	}
+	retry := provider.messages[1]
+	if last := retry[len(retry)-1]; last.Role != "user" || !strings.Contains(last.Content, "does not conform") {
+		t.Errorf("retry message = %+v", last)
+	}
+
+	// The session keeps the conforming reply, not the prose
+	history := al.GetRegistry().GetDefaultAgent().Sessions.GetHistory("agent:main:main")
+	if last := history[len(history)-1]; last.Content != `{"ok":true}` {
+		t.Errorf("last history message = %+v", last)
+	}
+}
+
+func TestProcessStructured_GivesUp(t *testing.T) {
+	schema, err := structured.Parse([]byte(`{"type": "array"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	al, provider := newStructuredLoop(t, "no")
+
+	_, err = al.ProcessStructured(context.Background(), "list", "structured", schema)
+	if !errors.Is(err, structured.ErrNoMatch) {
+		t.Fatalf("ProcessStructured() error = %v, want ErrNoMatch", err)
+	}
+	if len(provider.opts) != 1+schemaRetries {
+		t.Errorf("provider calls = %d, want %d", len(provider.opts), 1+schemaRetries)
+	}
+	// Without a schema the same reply is fine
+	if reply, err := al.ProcessDirect(context.Background(), "hi", "plain"); err != nil || reply != "no" {
+		t.Errorf("ProcessDirect() = %q, %v", reply, err)
+	}
+	if _, ok := provider.opts[len(provider.opts)-1][structured.Option]; ok {
+		t.Error("a plain turn was sent the response schema")
+	}
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestSummarizeSession_KeepsWholeRecentTurns(t *testing.T) {
+	al, _, _, _, cleanup := newTestAgentLoop(t)
+	defer cleanup()
+	agent := al.registry.GetDefaultAgent()
+
+	const key = "summarize-turns"
+	agent.Sessions.SetHistory(key, []providers.Message{
+		{Role: "user", Content: "plan a trip"},
+		{Role: "assistant", Content: "where to?"},
+		{Role: "user", Content: "check the weather in Lisbon"},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "1", Name: "weather"}}},
+		{Role: "tool", Content: "sunny", ToolCallID: "1"},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "2", Name: "weather"}}},
+		{Role: "tool", Content: "warm", ToolCallID: "2"},
+		{Role: "assistant", Content: "sunny and warm"},
+	})
+
+	al.summarizeSession(agent, key)
+
+	history := agent.Sessions.GetHistory(key)
+	if len(history) != 6 || history[0].Content != "check the weather in Lisbon" {
+		t.Fatalf("kept %+v, want the whole last turn", history)
+	}
+	if got := agent.Sessions.GetSummary(key); got != "Mock response" {
+		t.Errorf("summary = %q, want the model's", got)
+	}
+}
+
+func TestForceCompression_FoldsDroppedTurnsIntoSummary(t *testing.T) {
+	al, _, _, _, cleanup := newTestAgentLoop(t)
+	defer cleanup()
+	agent := al.registry.GetDefaultAgent()
+
+	const key = "force-compression"
+	agent.Sessions.SetSummary(key, "earlier summary")
+	agent.Sessions.SetHistory(key, []providers.Message{
+		{Role: "user", Content: "my name is Ana"},
+		{Role: "assistant", Content: "hi Ana"},
+		{Role: "user", Content: "read the file"},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "1", Name: "read_file"}}},
+		{Role: "tool", Content: "contents", ToolCallID: "1"},
+		{Role: "assistant", Content: "done"},
+		{Role: "user", Content: "what is my name?"},
+	})
+
+	al.forceCompression(agent, key)
+
+	history := agent.Sessions.GetHistory(key)
+	if len(history) != 5 || history[0].Content != "read the file" {
+		t.Fatalf("kept %+v, want the turns from the user message nearest the middle", history)
+	}
+	summary := agent.Sessions.GetSummary(key)
+	if !strings.HasPrefix(summary, "earlier summary") || !strings.Contains(summary, "my name is Ana") {
+		t.Errorf("summary = %q, want the earlier summary and the dropped turn", summary)
+	}
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// PromptData is what a templated prompt file can reference.
+type PromptData struct {
+	AgentID     string
+	ChannelName string // "discord", "telegram", ...
+	ChatID      string
+	GuildID     string // empty outside guilds and workspaces
+	UserName    string // display name of the sender, or their ID without one
+	UserID      string
+	Date        string // 2006-01-02
+	Time        string // 15:04
+	Weekday     string // Monday
+	Vars        map[string]string
+}
+
+// promptTemplate is a bootstrap file that uses template actions.
+type promptTemplate struct {
+	raw  string
+	tmpl *template.Template
+}
+
+// loadPromptTemplates parses the bootstrap files that contain template
+// actions. A file that does not parse is logged and used as written.
+func (cb *ContextBuilder) loadPromptTemplates() []promptTemplate {
+	var out []promptTemplate
+	for _, filename := range bootstrapFiles {
+		data, err := os.ReadFile(filepath.Join(cb.workspace, filename))
+		if err != nil || !strings.Contains(string(data), "{{") {
+			continue
+		}
+		tmpl, err := template.New(filename).Option("missingkey=zero").Parse(string(data))
+		if err != nil {
+			logger.WarnCF("agent", "Prompt file is not a valid template; using it as written", map[string]any{
+				"file":  filename,
+				"error": err.Error(),
+			})
+			continue
+		}
+		out = append(out, promptTemplate{raw: string(data), tmpl: tmpl})
+	}
+	return out
+}
+
+// RenderPromptTemplates replaces the templated bootstrap files in the
+// static part of the system message built by BuildMessages with their
+// rendering for data. The rest of the prompt, such as skills and memory, is
+// never rendered, so text the model or users wrote cannot run templates.
+func (cb *ContextBuilder) RenderPromptTemplates(messages []providers.Message, data PromptData) {
+	if len(messages) == 0 || messages[0].Role != "system" {
+		return
+	}
+	cb.systemPromptMutex.RLock()
+	templates := cb.cachedTemplates
+	cb.systemPromptMutex.RUnlock()
+
+	for _, t := range templates {
+		var b strings.Builder
+		if err := t.tmpl.Execute(&b, data); err != nil {
+			logger.WarnCF("agent", "Prompt template failed; using it as written", map[string]any{
+				"file":  t.tmpl.Name(),
+				"error": err.Error(),
+			})
+			continue
+		}
+		rendered := b.String()
+		messages[0].Content = strings.Replace(messages[0].Content, t.raw, rendered, 1)
+		if len(messages[0].SystemParts) > 0 {
+			part := &messages[0].SystemParts[0]
+			part.Text = strings.Replace(part.Text, t.raw, rendered, 1)
+		}
+	}
+}
+
+// applyPromptTemplates renders the templated prompt files for the turn of
+// opts when prompt templates are enabled.
+func (al *AgentLoop) applyPromptTemplates(agent *AgentInstance, messages []providers.Message, opts processOptions) {
+	cfg := al.GetConfig().PromptTemplates
+	if !cfg.Enabled {
+		return
+	}
+	agent.ContextBuilder.RenderPromptTemplates(messages, promptData(agent.ID, cfg.Vars, opts, time.Now()))
+}
+
+// promptData collects the template data of a turn. Vars are merged from
+// "default", the channel, the channel and guild, and the channel and chat,
+// each overriding the ones before.
+func promptData(agentID string, vars map[string]map[string]string, opts processOptions, now time.Time) PromptData {
+	merged := make(map[string]string)
+	scopes := []string{"default", opts.Channel}
+	if opts.GuildID != "" {
+		scopes = append(scopes, opts.Channel+":"+opts.GuildID)
+	}
+	if opts.ChatID != "" {
+		scopes = append(scopes, opts.Channel+":"+opts.ChatID)
+	}
+	for _, scope := range scopes {
+		for k, v := range vars[scope] {
+			merged[k] = v
+		}
+	}
+
+	userName := strings.TrimSpace(opts.SenderDisplayName)
+	if userName == "" {
+		userName = opts.SenderID
+	}
+	return PromptData{
+		AgentID:     agentID,
+		ChannelName: opts.Channel,
+		ChatID:      opts.ChatID,
+		GuildID:     opts.GuildID,
+		UserName:    userName,
+		UserID:      opts.SenderID,
+		Date:        now.Format("2006-01-02"),
+		Time:        now.Format("15:04"),
+		Weekday:     now.Weekday().String(),
+		Vars:        merged,
+	}
+}
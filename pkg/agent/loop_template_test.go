@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestPromptData_MergesVarsBySpecificity(t *testing.T) {
+	vars := map[string]map[string]string{
+		"default":           {"server": "our community", "tone": "friendly"},
+		"discord":           {"tone": "casual"},
+		"discord:guild1":    {"server": "Go Gophers"},
+		"discord:chan1":     {"tone": "formal"},
+		"telegram:chan1":    {"tone": "ignored"},
+		"discord:otherchan": {"server": "ignored"},
+	}
+	opts := processOptions{Channel: "discord", ChatID: "chan1", GuildID: "guild1", SenderID: "42"}
+	now := time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)
+
+	data := promptData("main", vars, opts, now)
+	if data.Vars["server"] != "Go Gophers" || data.Vars["tone"] != "formal" {
+		t.Errorf("vars = %v", data.Vars)
+	}
+	if data.UserName != "42" || data.Date != "2026-03-02" || data.Time != "09:30" || data.Weekday != "Monday" {
+		t.Errorf("data = %+v", data)
+	}
+	opts.SenderDisplayName = "Alice"
+	if data := promptData("main", nil, opts, now); data.UserName != "Alice" || len(data.Vars) != 0 {
+		t.Errorf("data without vars = %+v", data)
+	}
+}
+
+func TestPromptTemplates_RenderedPerMessage(t *testing.T) {
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "SOUL.md"),
+		[]byte("You serve {{.Vars.server}} on {{.ChannelName}}. Greet {{.UserName}}.{{.Vars.missing}}"), 0o644)
+	os.WriteFile(filepath.Join(workspace, "USER.md"), []byte("Broken {{.Nope"), 0o644)
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{Workspace: workspace, Model: "test-model", MaxTokens: 4096},
+		},
+		PromptTemplates: config.PromptTemplatesConfig{
+			Enabled: true,
+			Vars:    map[string]map[string]string{"default": {"server": "the lab"}},
+		},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	defer al.Close()
+
+	msg := bus.InboundMessage{
+		Channel: "telegram", SenderID: "7", ChatID: "7",
+		Peer: bus.Peer{Kind: "direct", ID: "7"}, Content: "hello",
+	}
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	system := provider.lastMessages[0]
+	if !strings.Contains(system.Content, "You serve the lab on telegram. Greet 7.\n") {
+		t.Errorf("system prompt was not rendered:\n%s", system.Content)
+	}
+	if !strings.Contains(system.SystemParts[0].Text, "You serve the lab on telegram.") {
+		t.Errorf("static block was not rendered:\n%s", system.SystemParts[0].Text)
+	}
+	if !strings.Contains(system.Content, "Broken {{.Nope") {
+		t.Errorf("an invalid template must be kept as written:\n%s", system.Content)
+	}
+
+	cfg.PromptTemplates.Enabled = false
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+	if !strings.Contains(provider.lastMessages[0].Content, "You serve {{.Vars.server}}") {
+		t.Errorf("templates rendered while disabled:\n%s", provider.lastMessages[0].Content)
+	}
+}
@@ -0,0 +1,219 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/notify"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/tenant"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// errNoSharedAgent is returned for a message outside every tenant when all
+// agents belong to tenants.
+var errNoSharedAgent = errors.New("message belongs to no tenant and every agent is owned by one")
+
+// openTenants loads the tenants of the workspace and seeds today's usage
+// from the usage log, or returns nils when multi-tenant mode is off.
+func openTenants(cfg *config.Config, workspace string, tracker *usage.Tracker) (*tenant.Store, *tenant.Meter) {
+	if !cfg.Tenants.Enabled {
+		return nil, nil
+	}
+	store := tenant.NewStore(tenant.Path(workspace))
+	meter := tenant.NewMeter()
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if records, err := tracker.Load(today); err == nil {
+		for _, r := range records {
+			if t, ok := store.ForAgent(r.AgentID); ok {
+				meter.Observe(t.ID, r)
+			}
+		}
+	}
+	return store, meter
+}
+
+// TenantsAPI returns the tenants admin API, or nil when multi-tenant mode is
+// off or neither it nor the admin API has an api_token.
+func (al *AgentLoop) TenantsAPI() *tenant.Handler {
+	cfg := al.GetConfig()
+	tokens := cfg.APITokens(cfg.Tenants.APIToken)
+	if al.tenants == nil || len(tokens) == 0 {
+		return nil
+	}
+	return tenant.NewHandler(al.tenants, al.tenantUsage, tokens, al.checkTenant)
+}
+
+// messageScope describes the chat of msg for tenant matching.
+func messageScope(msg bus.InboundMessage) tenant.Match {
+	return tenant.Match{
+		Channel:   msg.Channel,
+		AccountID: inboundMetadata(msg, metadataKeyAccountID),
+		GuildID:   inboundMetadata(msg, metadataKeyGuildID),
+		TeamID:    inboundMetadata(msg, metadataKeyTeamID),
+		ChatID:    msg.ChatID,
+	}
+}
+
+// routeAgents returns the agents that may answer msg: the tenant's own for a
+// tenant chat, every agent outside the tenants otherwise, with the agent
+// used when no binding applies first. It returns nil when no tenant is
+// defined.
+func (al *AgentLoop) routeAgents(msg bus.InboundMessage) ([]string, error) {
+	if al.tenants.Empty() {
+		return nil, nil
+	}
+	if t, ok := al.tenants.ForMessage(messageScope(msg)); ok {
+		return t.Agents, nil
+	}
+	registry := al.GetRegistry()
+	ids := registry.ListAgentIDs()
+	sort.Strings(ids)
+	if agent := registry.GetDefaultAgent(); agent != nil {
+		if i := slices.Index(ids, agent.ID); i > 0 {
+			ids = append([]string{agent.ID}, slices.Delete(ids, i, i+1)...)
+		}
+	}
+	shared := al.tenants.SharedAgents(ids)
+	if len(shared) == 0 {
+		return nil, errNoSharedAgent
+	}
+	return shared, nil
+}
+
+// checkTenantRoute makes sure a routed message stays inside its tenant, even
+// when the tenant lists an agent the config no longer has.
+func (al *AgentLoop) checkTenantRoute(msg bus.InboundMessage, agent *AgentInstance) error {
+	want, inTenant := al.tenants.ForMessage(messageScope(msg))
+	owner, owned := al.tenants.ForAgent(agent.ID)
+	switch {
+	case inTenant && (!owned || owner.ID != want.ID):
+		return fmt.Errorf("tenant %s has no agent available", want.ID)
+	case !inTenant && owned:
+		return errNoSharedAgent
+	}
+	return nil
+}
+
+// tenantQuotaReply returns the reply for a message whose tenant used up its
+// daily quota, or "" when the message may go on.
+func (al *AgentLoop) tenantQuotaReply(agent *AgentInstance, msg bus.InboundMessage) string {
+	t, ok := al.tenants.ForAgent(agent.ID)
+	if !ok {
+		return ""
+	}
+	resource, limit := al.tenantUsage.Exceeded(t.ID, t.Quota)
+	if resource == "" {
+		return ""
+	}
+	logger.InfoCF("agent", "Tenant quota reached", map[string]any{
+		"tenant":   t.ID,
+		"resource": resource,
+		"limit":    limit,
+	})
+	renderer, _ := notify.NewRenderer(al.GetConfig().Notifications.Templates)
+	return renderer.RenderIn(al.uiLanguage(msg), notify.EventTenantQuota, msg.Channel, notify.Vars{
+		"Tenant":   t.ID,
+		"Resource": resource,
+		"Limit":    limit,
+	})
+}
+
+// observeTenantUsage counts an LLM call against the quota of the agent's
+// tenant.
+func (al *AgentLoop) observeTenantUsage(rec usage.Record) {
+	if t, ok := al.tenants.ForAgent(rec.AgentID); ok {
+		al.tenantUsage.Observe(t.ID, rec)
+	}
+}
+
+// isTenantAdmin reports whether the sender of msg is an admin of the tenant
+// that owns the chat.
+func (al *AgentLoop) isTenantAdmin(msg bus.InboundMessage) bool {
+	t, ok := al.tenants.ForMessage(messageScope(msg))
+	if !ok {
+		return false
+	}
+	for _, admin := range t.Admins {
+		if identity.MatchAllowed(msg.Sender, admin) || (msg.SenderID != "" && strings.TrimSpace(admin) == msg.SenderID) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkModelForAgent refuses a model reserved for a tenant other than the
+// agent's.
+func (al *AgentLoop) checkModelForAgent(agent *AgentInstance, model string) error {
+	owner := al.tenants.ModelOwner(model)
+	if owner == "" {
+		return nil
+	}
+	if t, ok := al.tenants.ForAgent(agent.ID); ok && t.ID == owner {
+		return nil
+	}
+	return fmt.Errorf("model %s is reserved for another tenant", model)
+}
+
+// checkTenant validates t against the agents of the deployment before the
+// API saves it: its agents must exist and keep their workspaces to
+// themselves, and its models may only be used by its own agents.
+func (al *AgentLoop) checkTenant(t tenant.Tenant) error {
+	registry := al.GetRegistry()
+	owns := func(agentID string) bool {
+		return slices.ContainsFunc(t.Agents, func(id string) bool {
+			return routing.NormalizeAgentID(id) == agentID
+		})
+	}
+	for _, id := range t.Agents {
+		if _, ok := registry.GetAgent(routing.NormalizeAgentID(id)); !ok {
+			return fmt.Errorf("agent %s does not exist", id)
+		}
+	}
+	for _, id := range registry.ListAgentIDs() {
+		agent, _ := registry.GetAgent(id)
+		models := append([]string{agent.Model, agent.CanaryModel}, agent.Fallbacks...)
+		for _, model := range models {
+			if model == "" {
+				continue
+			}
+			owner := al.tenants.ModelOwner(model)
+			if slices.Contains(t.Models, model) {
+				owner = t.ID
+			} else if owner == t.ID {
+				owner = ""
+			}
+			if owner == "" {
+				continue
+			}
+			agentOwner := ""
+			if owns(id) {
+				agentOwner = t.ID
+			} else if other, ok := al.tenants.ForAgent(id); ok && other.ID != t.ID {
+				agentOwner = other.ID
+			}
+			if agentOwner != owner {
+				return fmt.Errorf("agent %s uses model %s, which is reserved for tenant %s", id, model, owner)
+			}
+		}
+		if !owns(id) {
+			continue
+		}
+		for _, otherID := range registry.ListAgentIDs() {
+			other, _ := registry.GetAgent(otherID)
+			if !owns(otherID) && other.Workspace == agent.Workspace {
+				return fmt.Errorf("agent %s shares its workspace with agent %s outside the tenant", id, otherID)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/tenant"
+)
+
+func newTenantLoop(t *testing.T) *AgentLoop {
+	t.Helper()
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true},
+				{ID: "acme", Workspace: filepath.Join(workspace, "..", "acme")},
+				{ID: "helper", Workspace: workspace},
+			},
+		},
+		Tenants: config.TenantsConfig{Enabled: true},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), usageProvider{})
+	t.Cleanup(al.Close)
+	err := al.tenants.Put(tenant.Tenant{
+		ID:       "acme",
+		Agents:   []string{"acme"},
+		Channels: []tenant.Match{{Channel: "discord", GuildID: "g1"}},
+		Models:   []string{"acme-model"},
+		Admins:   []string{"discord:7"},
+		Quota:    tenant.Quota{DailyMessages: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return al
+}
+
+func guildMessage(guild, content string) bus.InboundMessage {
+	return bus.InboundMessage{
+		Channel: "discord", SenderID: "7", ChatID: "c1", Content: content,
+		Sender:   bus.SenderInfo{Platform: "discord", PlatformID: "7", CanonicalID: "discord:7"},
+		Peer:     bus.Peer{Kind: "channel", ID: "c1"},
+		Metadata: map[string]string{metadataKeyGuildID: guild},
+	}
+}
+
+func TestTenant_RoutesChatsToTheirAgents(t *testing.T) {
+	al := newTenantLoop(t)
+
+	route, agent, err := al.resolveMessageRoute(guildMessage("g1", "hi"))
+	if err != nil || agent.ID != "acme" || !strings.HasPrefix(route.SessionKey, "agent:acme:") {
+		t.Fatalf("tenant chat routed to %v (%q), %v", agent, route.SessionKey, err)
+	}
+	_, agent, err = al.resolveMessageRoute(guildMessage("g2", "hi"))
+	if err != nil || agent.ID != "main" {
+		t.Fatalf("shared chat routed to %v, %v", agent, err)
+	}
+
+	if !al.isGenerationOperator(guildMessage("g1", "")) || al.isGenerationOperator(guildMessage("g2", "")) {
+		t.Error("tenant admins must operate their own chats only")
+	}
+}
+
+func TestTenant_QuotaStopsReplies(t *testing.T) {
+	al := newTenantLoop(t)
+
+	if reply, err := al.processMessage(context.Background(), guildMessage("g1", "first")); err != nil || reply != "Hi there" {
+		t.Fatalf("first message = %q, %v", reply, err)
+	}
+	reply, err := al.processMessage(context.Background(), guildMessage("g1", "second"))
+	if err != nil || !strings.Contains(reply, "daily quota of 1 messages") {
+		t.Fatalf("over quota = %q, %v", reply, err)
+	}
+	if reply, _ := al.processMessage(context.Background(), guildMessage("g2", "other")); reply != "Hi there" {
+		t.Errorf("quota of a tenant limited shared chats: %q", reply)
+	}
+}
+
+func TestTenant_ModelsAreReserved(t *testing.T) {
+	al := newTenantLoop(t)
+	registry := al.GetRegistry()
+	main, _ := registry.GetAgent("main")
+	acme, _ := registry.GetAgent("acme")
+
+	if err := al.checkModelForAgent(main, "acme-model"); err == nil {
+		t.Error("shared agent may switch to a tenant's model")
+	}
+	if err := al.checkModelForAgent(acme, "acme-model"); err != nil {
+		t.Errorf("tenant agent refused its own model: %v", err)
+	}
+
+	for name, tn := range map[string]tenant.Tenant{
+		"missing agent":    {ID: "b", Agents: []string{"nope"}, Channels: []tenant.Match{{Channel: "slack"}}},
+		"used model":       {ID: "b", Agents: []string{"acme"}, Channels: []tenant.Match{{Channel: "slack"}}, Models: []string{"test-model"}},
+		"shared workspace": {ID: "b", Agents: []string{"helper"}, Channels: []tenant.Match{{Channel: "slack"}}},
+	} {
+		if err := al.checkTenant(tn); err == nil {
+			t.Errorf("%s: tenant accepted", name)
+		}
+	}
+}
@@ -32,6 +32,7 @@ func (f *fakeChannel) ReasoningChannelID() string                              {
 
 type recordingProvider struct {
 	lastMessages []providers.Message
+	lastOpts     map[string]any
 }
 
 func (r *recordingProvider) Chat(
@@ -42,6 +43,7 @@ func (r *recordingProvider) Chat(
 	opts map[string]any,
 ) (*providers.LLMResponse, error) {
 	r.lastMessages = append([]providers.Message(nil), messages...)
+	r.lastOpts = opts
 	return &providers.LLMResponse{
 		Content:   "Mock response",
 		ToolCalls: []providers.ToolCall{},
@@ -1507,3 +1509,22 @@ func TestFilterClientWebSearch_EmptyInput(t *testing.T) {
 		t.Fatalf("len(result) = %d, want 0", len(result))
 	}
 }
+
+func TestAddTool_KeptAcrossReload(t *testing.T) {
+	al, cfg, _, provider, cleanup := newTestAgentLoop(t)
+	defer cleanup()
+
+	al.AddTool(&mockCustomTool{})
+	agent := al.GetRegistry().GetDefaultAgent()
+	if _, ok := agent.Tools.Get("mock_custom"); !ok {
+		t.Fatal("added tool not registered")
+	}
+
+	if err := al.ReloadProviderAndConfig(context.Background(), provider, cfg); err != nil {
+		t.Fatalf("ReloadProviderAndConfig() error = %v", err)
+	}
+	agent = al.GetRegistry().GetDefaultAgent()
+	if _, ok := agent.Tools.Get("mock_custom"); !ok {
+		t.Fatal("added tool lost on reload")
+	}
+}
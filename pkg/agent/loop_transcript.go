@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+)
+
+// Transcripts returns the tool-call transcript store, or nil when
+// transcripts are disabled.
+func (al *AgentLoop) Transcripts() *transcript.Store {
+	return al.transcripts
+}
+
+// recordToolCall adds one finished tool call to the transcript of the turn's
+// session.
+func (al *AgentLoop) recordToolCall(
+	agent *AgentInstance,
+	opts processOptions,
+	tc providers.ToolCall,
+	result *tools.ToolResult,
+	duration time.Duration,
+) {
+	if al.transcripts == nil || result == nil || opts.Ephemeral {
+		return
+	}
+	entry := transcript.Entry{
+		ID:         tc.ID,
+		Session:    opts.SessionKey,
+		Channel:    opts.Channel,
+		ChatID:     opts.ChatID,
+		SenderID:   opts.SenderID,
+		RequestID:  opts.RequestID,
+		Agent:      agent.ID,
+		Tool:       tc.Name,
+		Arguments:  tc.Arguments,
+		Result:     result.ForLLM,
+		DurationMS: duration.Milliseconds(),
+		Status:     transcript.StatusOK,
+	}
+	switch {
+	case result.IsError:
+		entry.Status = transcript.StatusError
+		if result.Err != nil {
+			entry.Error = result.Err.Error()
+		}
+	case result.Async:
+		entry.Status = transcript.StatusAsync
+	}
+	if err := al.transcripts.Record(entry); err != nil {
+		logger.WarnCF("agent", "Failed to record tool call", map[string]any{
+			"tool":  tc.Name,
+			"error": err.Error(),
+		})
+	}
+}
+
+// addTranscriptRuntime exposes the tool calls of this conversation to
+// /transcript.
+func (al *AgentLoop) addTranscriptRuntime(rt *commands.Runtime, opts *processOptions) {
+	store := al.transcripts
+	if store == nil {
+		return
+	}
+	session := opts.SessionKey
+	rt.ToolCalls = func(tool string, limit int) ([]transcript.Entry, error) {
+		return store.Query(transcript.Filter{Session: session, Tool: tool, Limit: limit})
+	}
+	rt.ToolCall = func(id string) (transcript.Entry, bool) {
+		return store.Find(session, id)
+	}
+}
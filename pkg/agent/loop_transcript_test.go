@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+)
+
+// toolThenAnswerProvider asks for mock_custom once, then answers.
+type toolThenAnswerProvider struct {
+	calls int
+}
+
+func (p *toolThenAnswerProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.calls++
+	if p.calls == 1 {
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID:        "call_1",
+			Type:      "function",
+			Name:      "mock_custom",
+			Arguments: map[string]any{"q": "x"},
+			Function:  &providers.FunctionCall{Name: "mock_custom", Arguments: `{"q":"x"}`},
+		}}}, nil
+	}
+	return &providers.LLMResponse{Content: "done"}, nil
+}
+
+func (p *toolThenAnswerProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestRunLLMIteration_RecordsToolTranscript(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Transcripts: config.TranscriptsConfig{Enabled: true},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &toolThenAnswerProvider{})
+	al.RegisterTool(&mockCustomTool{})
+
+	msg := bus.InboundMessage{Channel: "telegram", SenderID: "u1", ChatID: "c1", Content: "run it"}
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatalf("processMessage: %v", err)
+	}
+
+	entries, err := al.Transcripts().Query(transcript.Filter{Session: "agent:main:main"})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("transcript = %+v, %v", entries, err)
+	}
+	e := entries[0]
+	if e.ID != "call_1" || e.Tool != "mock_custom" || e.Status != transcript.StatusOK ||
+		e.Result != "Custom tool executed" || e.Arguments["q"] != "x" || e.ChatID != "c1" {
+		t.Errorf("entry = %+v", e)
+	}
+}
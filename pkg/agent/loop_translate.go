@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/translate"
+)
+
+const translateRetries = 2
+
+// translate runs one translation prompt through the agent's provider.
+func (al *AgentLoop) translate(ctx context.Context, agent *AgentInstance, prompt string) (string, error) {
+	resp, err := al.retryLLMCall(ctx, agent, prompt, translateRetries)
+	if err != nil {
+		return "", err
+	}
+	if resp == nil {
+		return "", errors.New("empty response from provider")
+	}
+	return resp.Content, nil
+}
+
+// addTranslateRuntime exposes translation and this chat's auto-translate
+// setting to /translate.
+func (al *AgentLoop) addTranslateRuntime(rt *commands.Runtime, msg bus.InboundMessage, agent *AgentInstance) {
+	if agent == nil {
+		return
+	}
+	rt.Translate = func(ctx context.Context, text, target string) (string, error) {
+		reply, err := al.translate(ctx, agent, translate.Prompt(target, text))
+		if err != nil {
+			return "", err
+		}
+		translated, _ := translate.Clean(reply)
+		if translated == "" {
+			return "", errors.New("the provider returned no translation")
+		}
+		return translated, nil
+	}
+	if al.translations == nil {
+		return
+	}
+	rt.AutoTranslate = func() []string {
+		return al.translations.Languages(msg.Channel, msg.ChatID)
+	}
+	rt.SetAutoTranslate = func(languages []string) error {
+		return al.translations.Set(msg.Channel, msg.ChatID, languages)
+	}
+}
+
+// autoTranslate handles a message in a chat that is in auto-translate mode.
+// The translation is posted as a reply to the message, which opens a thread
+// on platforms that have them. It reports false when the chat is not in
+// auto-translate mode, so the message goes to the agent as usual.
+func (al *AgentLoop) autoTranslate(ctx context.Context, agent *AgentInstance, msg bus.InboundMessage) bool {
+	languages := al.translations.Languages(msg.Channel, msg.ChatID)
+	if len(languages) == 0 {
+		return false
+	}
+	text := strings.TrimSpace(msg.Content)
+	if text == "" {
+		return true
+	}
+
+	reply, err := al.translate(ctx, agent, translate.AutoPrompt(languages, text))
+	if err != nil {
+		logger.WarnCF("agent", "Auto-translate failed", map[string]any{
+			"channel":    msg.Channel,
+			"chat_id":    msg.ChatID,
+			"request_id": msg.RequestID,
+			"error":      err.Error(),
+		})
+		return true
+	}
+	translated, ok := translate.Clean(reply)
+	if !ok {
+		return true
+	}
+	al.bus.PublishOutbound(ctx, bus.OutboundMessage{
+		Channel:          msg.Channel,
+		ChatID:           msg.ChatID,
+		Content:          "🌐 " + translated,
+		ReplyToMessageID: msg.MessageID,
+	})
+	return true
+}
@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestProcessMessage_AutoTranslate(t *testing.T) {
+	al, _, msgBus, _, cleanup := newTestAgentLoop(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	msg := bus.InboundMessage{
+		Channel:   "slack",
+		SenderID:  "U1",
+		ChatID:    "C1",
+		MessageID: "1700000000.000100",
+		Peer:      bus.Peer{Kind: "channel", ID: "C1"},
+	}
+	send := func(content string) string {
+		t.Helper()
+		m := msg
+		m.Content = content
+		response, err := al.processMessage(ctx, m)
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", content, err)
+		}
+		return response
+	}
+
+	if got := send("/translate auto English Japanese"); got == "" {
+		t.Fatal("expected a confirmation for /translate auto")
+	}
+	if got := send("good morning"); got != "" {
+		t.Fatalf("auto-translated message got an agent answer %q", got)
+	}
+	select {
+	case out := <-msgBus.OutboundChan():
+		if out.Content != "🌐 Mock response" || out.ReplyToMessageID != msg.MessageID {
+			t.Fatalf("outbound = %+v, want the translation as a reply", out)
+		}
+	default:
+		t.Fatal("no translation was posted")
+	}
+
+	send("/translate off")
+	if got := send("good morning"); got != "Mock response" {
+		t.Fatalf("after /translate off response = %q, want the agent answer", got)
+	}
+}
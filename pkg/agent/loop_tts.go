@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
+
+// speechTimeout bounds synthesizing and sending one spoken reply.
+const speechTimeout = 2 * time.Minute
+
+var (
+	speechCodeRe     = regexp.MustCompile("(?s)```.*?```")
+	speechLinkRe     = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	speechMarkupRe   = regexp.MustCompile("[*_`~>#|]+")
+	speechSpaceRe    = regexp.MustCompile(`[ \t]+`)
+	speechNewlinesRe = regexp.MustCompile(`\n{3,}`)
+)
+
+// SetSynthesizer injects the speech synthesizer used for spoken replies.
+func (al *AgentLoop) SetSynthesizer(s voice.Synthesizer) {
+	al.speech = s
+}
+
+// speechMuted reports whether the sender of msg turned spoken replies off,
+// which also keeps channels from speaking the reply in voice channels.
+func (al *AgentLoop) speechMuted(msg bus.InboundMessage) bool {
+	p := al.userPrefs(msg.Channel, msg.SenderID)
+	return p.TTS != nil && !*p.TTS
+}
+
+// attachSpeech sends the reply of the turn turnID spoken, as an audio file,
+// when its sender wants spoken replies. It returns at once; the audio
+// follows the text when it is ready.
+func (al *AgentLoop) attachSpeech(ctx context.Context, msg bus.InboundMessage, turnID, response string) {
+	speech := al.speech
+	if speech == nil || al.mediaStore == nil || turnID == "" {
+		return
+	}
+	if !al.userPrefs(msg.Channel, msg.SenderID).TTSOn(al.GetConfig().Voice.TTS.Replies) {
+		return
+	}
+	text := speechText(response)
+	if text == "" {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, speechTimeout)
+		defer cancel()
+		audio, err := speech.SynthesizeFile(ctx, text)
+		if err != nil {
+			logger.WarnCF("voice", "Failed to synthesize reply", map[string]any{
+				"provider": speech.Name(),
+				"error":    err.Error(),
+			})
+			return
+		}
+		ref, err := al.storeSpeech(audio, msg)
+		if err != nil {
+			logger.WarnCF("voice", "Failed to store spoken reply", map[string]any{"error": err.Error()})
+			return
+		}
+		al.bus.PublishOutboundMedia(ctx, bus.OutboundMediaMessage{
+			Channel: msg.Channel,
+			ChatID:  msg.ChatID,
+			Parts: []bus.MediaPart{{
+				Type:        "audio",
+				Ref:         ref,
+				Filename:    "reply" + audio.Ext,
+				ContentType: audio.ContentType,
+			}},
+		})
+	}()
+}
+
+// storeSpeech saves audio to the media store for the chat of msg.
+func (al *AgentLoop) storeSpeech(audio voice.Audio, msg bus.InboundMessage) (string, error) {
+	dir := media.TempDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "speech-"+uuid.New().String()+audio.Ext)
+	if err := os.WriteFile(path, audio.Data, 0o600); err != nil {
+		return "", err
+	}
+	ref, err := al.mediaStore.Store(path, media.MediaMeta{
+		Filename:    "reply" + audio.Ext,
+		ContentType: audio.ContentType,
+		Source:      "agent:tts",
+	}, "tts:"+msg.Channel+":"+msg.ChatID)
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return ref, nil
+}
+
+// speechText returns a reply as it should be read aloud: code blocks are
+// left out, links keep their text, and markdown marks are dropped.
+func speechText(content string) string {
+	text := speechCodeRe.ReplaceAllString(content, "\n")
+	text = speechLinkRe.ReplaceAllString(text, "$1")
+	text = speechMarkupRe.ReplaceAllString(text, "")
+	text = speechSpaceRe.ReplaceAllString(text, " ")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	text = speechNewlinesRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/prefs"
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
+
+type fakeSynthesizer struct{ texts chan string }
+
+func (f *fakeSynthesizer) Name() string { return "fake" }
+
+func (f *fakeSynthesizer) Synthesize(context.Context, string) ([][]byte, error) {
+	return nil, voice.ErrNoOpus
+}
+
+func (f *fakeSynthesizer) SynthesizeFile(_ context.Context, text string) (voice.Audio, error) {
+	f.texts <- text
+	return voice.Audio{Data: []byte("OggS"), ContentType: "audio/ogg", Ext: ".ogg"}, nil
+}
+
+func TestAttachSpeech(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	store := media.NewFileMediaStore()
+	speech := &fakeSynthesizer{texts: make(chan string, 2)}
+	userPrefs := prefs.NewStore(filepath.Join(t.TempDir(), "prefs.json"))
+	al := &AgentLoop{cfg: config.DefaultConfig(), bus: msgBus, mediaStore: store, prefs: userPrefs, speech: speech}
+	msg := bus.InboundMessage{Channel: "telegram", ChatID: "42", SenderID: "7"}
+
+	// Spoken replies are off by default.
+	al.attachSpeech(context.Background(), msg, "turn-1", "Hello")
+	if al.speechMuted(msg) {
+		t.Error("speechMuted = true without a preference")
+	}
+
+	on := true
+	if err := userPrefs.Put(prefs.UserKey("telegram", "7"), prefs.Prefs{TTS: &on}); err != nil {
+		t.Fatal(err)
+	}
+	al.attachSpeech(context.Background(), msg, "turn-2", "**Done.** See [the docs](https://x.test).\n```go\nx := 1\n```")
+	select {
+	case text := <-speech.texts:
+		if text != "Done. See the docs." {
+			t.Errorf("spoken text = %q", text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reply was not synthesized")
+	}
+	select {
+	case out := <-msgBus.OutboundMediaChan():
+		if out.ChatID != "42" || len(out.Parts) != 1 || out.Parts[0].Type != "audio" ||
+			out.Parts[0].Filename != "reply.ogg" {
+			t.Fatalf("outbound media = %+v", out)
+		}
+		path, err := store.Resolve(out.Parts[0].Ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(path)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no audio published")
+	}
+
+	off := false
+	if err := userPrefs.Put(prefs.UserKey("telegram", "7"), prefs.Prefs{TTS: &off}); err != nil {
+		t.Fatal(err)
+	}
+	al.GetConfig().Voice.TTS.Replies = true
+	al.attachSpeech(context.Background(), msg, "turn-3", "Hello")
+	if !al.speechMuted(msg) {
+		t.Error("speechMuted = false after tts off")
+	}
+	select {
+	case text := <-speech.texts:
+		t.Errorf("synthesized %q for a user with tts off", text)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/branch"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/prefs"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+// generationLimits returns the allowed ranges for /temp and /maxtokens with
+// the agent's own values as the defaults.
+func (al *AgentLoop) generationLimits(agent *AgentInstance) tuning.Limits {
+	return tuning.Limits{
+		GenerationConfig:   al.GetConfig().Generation,
+		DefaultTemperature: agent.Temperature,
+		DefaultMaxTokens:   agent.MaxTokens,
+	}
+}
+
+// generationSettings returns the overrides of a turn's session over those of
+// its guild. Branches share the settings of their base session.
+func (al *AgentLoop) generationSettings(opts processOptions) tuning.Settings {
+	return al.tuning.Get(branch.BaseKey(opts.SessionKey)).Over(al.guildSettings(opts))
+}
+
+// guildSettings returns the overrides a guild saved with /config.
+func (al *AgentLoop) guildSettings(opts processOptions) tuning.Settings {
+	if opts.GuildID == "" {
+		return tuning.Settings{}
+	}
+	return al.tuning.Get(tuning.GuildKey(opts.Channel, opts.GuildID))
+}
+
+// turnPrefs returns the sender's preferences with the session's verbosity,
+// if one is set, taking precedence.
+func (al *AgentLoop) turnPrefs(opts processOptions) prefs.Prefs {
+	p := al.userPrefs(opts.Channel, opts.SenderID)
+	if v := al.generationSettings(opts).Verbosity; v != "" {
+		p.Verbosity = v
+	}
+	return p
+}
+
+// isGenerationOperator reports whether the sender of msg is listed in
+// generation.operators or administers the chat's tenant.
+func (al *AgentLoop) isGenerationOperator(msg bus.InboundMessage) bool {
+	if al.isTenantAdmin(msg) {
+		return true
+	}
+	for _, op := range al.GetConfig().Generation.Operators {
+		if identity.MatchAllowed(msg.Sender, op) || (msg.SenderID != "" && strings.TrimSpace(op) == msg.SenderID) {
+			return true
+		}
+	}
+	return false
+}
+
+// addGenerationRuntime exposes the session's generation settings to /temp,
+// /maxtokens, and /verbosity. Settings the guild changed are their defaults.
+func (al *AgentLoop) addGenerationRuntime(
+	rt *commands.Runtime,
+	msg bus.InboundMessage,
+	agent *AgentInstance,
+	opts *processOptions,
+) {
+	if al.tuning == nil || agent == nil || opts == nil || opts.SessionKey == "" {
+		return
+	}
+	session := branch.BaseKey(opts.SessionKey)
+	rt.IsGenerationOperator = func() bool {
+		return al.isGenerationOperator(msg)
+	}
+	rt.GenerationSettings = func() (tuning.Settings, tuning.Limits) {
+		limits := al.generationLimits(agent)
+		guild := al.guildSettings(*opts)
+		limits.DefaultTemperature, limits.DefaultMaxTokens = limits.Temperature(guild), limits.MaxTokens(guild)
+		return al.tuning.Get(session), limits
+	}
+	rt.SetGenerationSettings = func(s tuning.Settings) error {
+		return al.tuning.Put(session, s)
+	}
+}
+
+// isGuildAdmin reports whether the sender of msg may manage the server it
+// was sent in, or is a generation operator.
+func (al *AgentLoop) isGuildAdmin(msg bus.InboundMessage) bool {
+	return inboundMetadata(msg, channels.MetadataKeyGuildAdmin) == "true" || al.isGenerationOperator(msg)
+}
+
+// addGuildConfigRuntime exposes the generation settings of the message's
+// guild to /config.
+func (al *AgentLoop) addGuildConfigRuntime(
+	rt *commands.Runtime,
+	msg bus.InboundMessage,
+	agent *AgentInstance,
+	opts *processOptions,
+) {
+	if al.tuning == nil || agent == nil || opts == nil || opts.GuildID == "" {
+		return
+	}
+	guild := tuning.GuildKey(opts.Channel, opts.GuildID)
+	rt.IsGuildAdmin = func() bool {
+		return al.isGuildAdmin(msg)
+	}
+	rt.GuildSettings = func() (tuning.Settings, tuning.Limits) {
+		return al.tuning.Get(guild), al.generationLimits(agent)
+	}
+	rt.SetGuildSettings = func(s tuning.Settings) error {
+		return al.tuning.Put(guild, s)
+	}
+}
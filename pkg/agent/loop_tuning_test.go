@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestProcessMessage_AppliesGenerationSettings(t *testing.T) {
+	temperature := 0.7
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				Temperature:       &temperature,
+				MaxToolIterations: 10,
+			},
+		},
+		Generation: config.GenerationConfig{
+			Operators:   []string{"alice"},
+			Temperature: config.FloatRange{Min: 0, Max: 1.5},
+			MaxTokens:   config.IntRange{Min: 256, Max: 8192},
+		},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	send := func(sender, content string) string {
+		t.Helper()
+		reply, err := al.processMessage(ctx, bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: sender,
+			ChatID:   "chat-1",
+			Content:  content,
+		})
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", content, err)
+		}
+		return reply
+	}
+
+	if reply := send("bob", "/temp 1.2"); !strings.Contains(reply, "Only generation operators") {
+		t.Fatalf("non-operator reply = %q", reply)
+	}
+	if reply := send("alice", "/temp 3"); !strings.Contains(reply, "Temperature set to 1.5") {
+		t.Fatalf("clamped reply = %q", reply)
+	}
+	send("alice", "/maxtokens 1000")
+	send("alice", "/verbosity brief")
+
+	send("bob", "hello")
+	if got := provider.lastOpts["temperature"]; got != 1.5 {
+		t.Errorf("temperature = %v, want 1.5", got)
+	}
+	if got := provider.lastOpts["max_tokens"]; got != 1000 {
+		t.Errorf("max_tokens = %v, want 1000", got)
+	}
+	if system := provider.lastMessages[0].Content; !strings.Contains(system, "Keep replies brief") {
+		t.Errorf("system prompt lacks the session verbosity:\n%s", system)
+	}
+
+	send("alice", "/temp reset")
+	send("bob", "hello")
+	if got := provider.lastOpts["temperature"]; got != 0.7 {
+		t.Errorf("temperature after reset = %v, want 0.7", got)
+	}
+}
+
+func TestProcessMessage_AppliesGuildConfig(t *testing.T) {
+	temperature := 0.7
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				Temperature:       &temperature,
+				MaxToolIterations: 10,
+			},
+		},
+		Generation: config.GenerationConfig{Operators: []string{"alice"}},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	send := func(sender, chatID, content string, admin bool) string {
+		t.Helper()
+		metadata := map[string]string{"guild_id": "g1"}
+		if admin {
+			metadata["guild_admin"] = "true"
+		}
+		reply, err := al.processMessage(ctx, bus.InboundMessage{
+			Channel:  "discord",
+			SenderID: sender,
+			ChatID:   chatID,
+			Content:  content,
+			Metadata: metadata,
+		})
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", content, err)
+		}
+		return reply
+	}
+
+	if reply := send("bob", "c1", "/config set temperature 0.3", false); !strings.Contains(reply, "Only server admins") {
+		t.Fatalf("non-admin reply = %q", reply)
+	}
+	if reply := send("bob", "c1", "/config set temperature 0.3", true); !strings.Contains(reply, "set to 0.3") {
+		t.Fatalf("admin reply = %q", reply)
+	}
+	send("carol", "c2", "hello", false)
+	if got := provider.lastOpts["temperature"]; got != 0.3 {
+		t.Errorf("temperature in another chat of the guild = %v, want 0.3", got)
+	}
+	if reply := send("alice", "c2", "/temp", false); !strings.Contains(reply, "Temperature: 0.3 (default") {
+		t.Errorf("/temp should show the guild value as the default: %q", reply)
+	}
+
+	send("alice", "c2", "/temp 0.9", false)
+	send("carol", "c2", "hello", false)
+	if got := provider.lastOpts["temperature"]; got != 0.9 {
+		t.Errorf("session temperature = %v, want 0.9 over the guild's", got)
+	}
+
+	send("alice", "c2", "/temp reset", false)
+	al.processMessage(ctx, bus.InboundMessage{Channel: "discord", SenderID: "dave", ChatID: "dm", Content: "hello"})
+	if got := provider.lastOpts["temperature"]; got != 0.7 {
+		t.Errorf("temperature outside the guild = %v, want 0.7", got)
+	}
+}
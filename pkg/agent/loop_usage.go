@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/alert"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/digest"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// recordUsage logs one LLM call to the usage tracker. Failures to record
+// never affect the conversation.
+func (al *AgentLoop) recordUsage(
+	agent *AgentInstance,
+	opts processOptions,
+	model string,
+	resp *providers.LLMResponse,
+	callErr error,
+	elapsed time.Duration,
+	turnStart bool,
+) {
+	if al.usage == nil {
+		return
+	}
+	rec := usage.Record{
+		AgentID:    agent.ID,
+		Channel:    opts.Channel,
+		ChatID:     opts.ChatID,
+		SenderID:   opts.SenderID,
+		Model:      model,
+		Variant:    opts.Variant,
+		RequestID:  opts.RequestID,
+		DurationMS: elapsed.Milliseconds(),
+		Error:      callErr != nil,
+		TurnStart:  turnStart,
+	}
+	if resp != nil && resp.Usage != nil {
+		rec.PromptTokens = resp.Usage.PromptTokens
+		rec.CompletionTokens = resp.Usage.CompletionTokens
+	}
+	if err := al.usage.Record(rec); err != nil {
+		logger.WarnCF("agent", "Failed to record usage", map[string]any{"error": err.Error()})
+	}
+
+	al.mu.RLock()
+	monitor := al.alerts
+	al.mu.RUnlock()
+	monitor.Observe(rec)
+	al.observeTenantUsage(rec)
+	al.limits.Observe(rec)
+}
+
+// UsageAPI returns the usage report API, or nil when neither it nor the
+// admin API has an api_token.
+func (al *AgentLoop) UsageAPI() *usage.Handler {
+	cfg := al.GetConfig()
+	tokens := cfg.APITokens(cfg.Usage.APIToken)
+	if al.usage == nil || len(tokens) == 0 {
+		return nil
+	}
+	return usage.NewHandler(tokens, al.usage, al.usagePricing)
+}
+
+// usagePricing returns the prices of the current config.
+func (al *AgentLoop) usagePricing() usage.Pricing {
+	return digest.PricingFromConfig(al.GetConfig())
+}
+
+// addUsageRuntime lets /usage report the tokens and cost since a time, of
+// the sender of msg or of everyone.
+func (al *AgentLoop) addUsageRuntime(rt *commands.Runtime, msg bus.InboundMessage) {
+	if al.usage == nil {
+		return
+	}
+	rt.UsageReport = func(since time.Time, groupBy string, mine bool) (usage.Report, error) {
+		records, err := al.usage.Load(since)
+		if err != nil {
+			return usage.Report{}, err
+		}
+		var keep func(usage.Record) bool
+		if mine {
+			keep = func(r usage.Record) bool { return r.Channel == msg.Channel && r.SenderID == msg.SenderID }
+		}
+		pricing := digest.PricingFromConfig(al.GetConfig())
+		return usage.BuildReport(records, since, time.Now().Add(time.Second), pricing, groupBy, keep)
+	}
+}
+
+// newAlertMonitor builds the alert monitor from config, or returns nil when
+// alerting is disabled or its sinks are invalid.
+func newAlertMonitor(cfg *config.Config, tracker *usage.Tracker) *alert.Monitor {
+	if !cfg.Alerts.Enabled {
+		return nil
+	}
+	manager, err := alert.NewManagerFromConfig(cfg.Alerts)
+	if err != nil {
+		logger.ErrorCF("alert", "Alerting disabled: invalid config", map[string]any{"error": err.Error()})
+		return nil
+	}
+	monitor := alert.NewMonitor(cfg.Alerts, manager, digest.PricingFromConfig(cfg))
+	if cfg.Alerts.DailyBudget > 0 && tracker != nil {
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		if records, err := tracker.Load(today); err == nil {
+			monitor.Seed(records)
+		}
+	}
+	return monitor
+}
+
+// applyCanaryPrompt appends the canary prompt file to the system message.
+// The file is read on every turn so prompt edits take effect without a restart.
+func applyCanaryPrompt(agent *AgentInstance, messages []providers.Message) {
+	if agent.CanaryPromptPath == "" || len(messages) == 0 || messages[0].Role != "system" {
+		return
+	}
+	data, err := os.ReadFile(agent.CanaryPromptPath)
+	if err != nil {
+		logger.WarnCF("agent", "Failed to read canary prompt", map[string]any{
+			"agent_id": agent.ID,
+			"path":     agent.CanaryPromptPath,
+			"error":    err.Error(),
+		})
+		return
+	}
+	extra := strings.TrimSpace(string(data))
+	if extra == "" {
+		return
+	}
+	messages[0].Content += "\n\n---\n\n" + extra
+	if len(messages[0].SystemParts) > 0 {
+		messages[0].SystemParts = append(messages[0].SystemParts, providers.ContentBlock{Type: "text", Text: extra})
+	}
+}
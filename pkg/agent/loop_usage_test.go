@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func TestProcessMessage_CanaryChannelGetsPromptAndVariant(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "CANARY.md"), []byte("Answer like a pirate."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Canary: &config.CanaryConfig{
+					Enabled:    true,
+					Label:      "pirate-v2",
+					PromptFile: "CANARY.md",
+					Channels:   []string{"telegram:canary-chat"},
+				},
+			},
+		},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	send := func(chatID string) string {
+		t.Helper()
+		_, err := al.processMessage(context.Background(), bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: "telegram:1",
+			ChatID:   chatID,
+			Content:  "hello",
+		})
+		if err != nil {
+			t.Fatalf("processMessage: %v", err)
+		}
+		return provider.lastMessages[0].Content
+	}
+
+	if prompt := send("canary-chat"); !strings.Contains(prompt, "Answer like a pirate.") {
+		t.Errorf("canary chat should get the canary prompt")
+	}
+	if prompt := send("other-chat"); strings.Contains(prompt, "Answer like a pirate.") {
+		t.Errorf("stable chat must not get the canary prompt")
+	}
+
+	records, err := usage.NewTracker(usage.Path(tmpDir)).Load(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 usage records, got %d", len(records))
+	}
+	if records[0].Variant != "pirate-v2" || records[0].ChatID != "canary-chat" {
+		t.Errorf("first record = %+v, want canary variant", records[0])
+	}
+	if records[1].Variant != usage.VariantStable {
+		t.Errorf("second record variant = %q, want stable", records[1].Variant)
+	}
+	if records[0].RequestID == "" || records[0].RequestID == records[1].RequestID {
+		t.Errorf("each turn should record its own request ID, got %q and %q",
+			records[0].RequestID, records[1].RequestID)
+	}
+}
+
+func TestProcessMessage_CanaryPersona(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Canary: &config.CanaryConfig{
+					Enabled:  true,
+					Label:    "concise",
+					Persona:  "concise",
+					Channels: []string{"telegram:canary-chat"},
+				},
+			},
+		},
+		Personas: config.PersonasConfig{
+			Profiles: map[string]config.PersonaConfig{
+				"concise": {Prompt: "Answer in one sentence."},
+				"chatty":  {Prompt: "Answer at length."},
+			},
+			Defaults: map[string]string{"default": "chatty"},
+		},
+	}
+	provider := &recordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	send := func(chatID string) string {
+		t.Helper()
+		_, err := al.processMessage(context.Background(), bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: "telegram:1",
+			ChatID:   chatID,
+			Content:  "hello",
+		})
+		if err != nil {
+			t.Fatalf("processMessage: %v", err)
+		}
+		return provider.lastMessages[0].Content
+	}
+
+	if prompt := send("canary-chat"); !strings.Contains(prompt, "Answer in one sentence.") ||
+		strings.Contains(prompt, "Answer at length.") {
+		t.Errorf("canary chat should get the canary persona over the default, prompt:\n%s", prompt)
+	}
+	if prompt := send("other-chat"); !strings.Contains(prompt, "Answer at length.") {
+		t.Errorf("stable chat should keep the default persona, prompt:\n%s", prompt)
+	}
+}
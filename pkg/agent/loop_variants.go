@@ -0,0 +1,172 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/sipeed/picoclaw/pkg/branch"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// metadataKeyVariant marks a prompt sent again to show another reply
+// variant of its turn: the number of variants to move by.
+const metadataKeyVariant = "variant"
+
+var errVariantsStale = errors.New(
+	"only the replies to the latest message can be switched; use /branch to continue from an earlier one")
+
+// variantStep returns how many variants msg asks to move by, or 0 when it
+// is not a variant switch.
+func variantStep(msg bus.InboundMessage) int {
+	step, _ := strconv.Atoi(inboundMetadata(msg, metadataKeyVariant))
+	return step
+}
+
+// switchVariantReaction shows the reply variant step places away from the
+// current one of the turn turnID, in place of its reply, for ◀️ and ▶️.
+func (al *AgentLoop) switchVariantReaction(ctx context.Context, turnID string, step int, sender bus.SenderInfo) error {
+	msg, ok := al.prompts.get(turnID)
+	if !ok || msg.MessageID == "" {
+		return errUnknownTurn
+	}
+
+	msg.RequestID = ""
+	msg.Metadata = maps.Clone(msg.Metadata)
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]string)
+	}
+	delete(msg.Metadata, metadataKeyEdited)
+	delete(msg.Metadata, metadataKeyRegenerate)
+	msg.Metadata[metadataKeyVariant] = strconv.Itoa(step)
+	logger.InfoCF("agent", "Switching reply variant on request", map[string]any{
+		"turn_id":      turnID,
+		"step":         step,
+		"requested_by": sender.PlatformID,
+	})
+	return al.bus.PublishInbound(ctx, msg)
+}
+
+// keepReplacedVariant saves the turn a 🔁 is about to replace, so the new
+// reply does not lose it.
+func (al *AgentLoop) keepReplacedVariant(sessionKey, messageID string, turn []providers.Message) {
+	if err := al.branches.KeepVariant(sessionKey, messageID, slices.Clone(turn)); err != nil {
+		logger.WarnCF("agent", "Failed to keep the replaced reply", map[string]any{
+			"session_key": sessionKey,
+			"error":       err.Error(),
+		})
+	}
+}
+
+// recordVariant saves the regenerated turn of messageID as a new variant.
+func (al *AgentLoop) recordVariant(agent *AgentInstance, sessionKey, messageID string) {
+	history, start, err := al.latestTurn(agent, sessionKey, messageID)
+	if err != nil {
+		return
+	}
+	if err := al.branches.AddVariant(sessionKey, messageID, slices.Clone(history[start:])); err != nil {
+		logger.WarnCF("agent", "Failed to save the regenerated reply", map[string]any{
+			"session_key": sessionKey,
+			"error":       err.Error(),
+		})
+	}
+}
+
+// latestTurn returns the history of a session and where the turn of
+// messageID starts in it, which must be the last turn.
+func (al *AgentLoop) latestTurn(
+	agent *AgentInstance,
+	sessionKey, messageID string,
+) ([]providers.Message, int, error) {
+	mark, err := al.branches.Find(branch.BaseKey(sessionKey), messageID)
+	if err != nil || mark.Session != sessionKey {
+		return nil, 0, errVariantsStale
+	}
+	history := agent.Sessions.GetHistory(sessionKey)
+	start := locateTurn(history, mark)
+	if start < 0 || turnEnd(history, start) != len(history) {
+		return nil, 0, errVariantsStale
+	}
+	return history, start, nil
+}
+
+// selectVariant puts the reply variant at index into the session in place
+// of the current one, so the conversation continues from it, and returns
+// its reply.
+func (al *AgentLoop) selectVariant(agent *AgentInstance, sessionKey string, index int) (string, error) {
+	v, ok := al.branches.Variants(sessionKey)
+	if !ok || len(v.Turns) < 2 {
+		return "", branch.ErrNoVariants
+	}
+	if index < 0 || index >= len(v.Turns) {
+		return "", branch.ErrUnknownVariant
+	}
+	history, start, err := al.latestTurn(agent, sessionKey, v.MessageID)
+	if err != nil {
+		return "", err
+	}
+	turn := v.Turns[index]
+	// An edited prompt makes the variants answers to another text
+	if len(turn) == 0 || turn[0].Content != history[start].Content {
+		return "", errVariantsStale
+	}
+	if err := al.branches.SelectVariant(sessionKey, index, slices.Clone(history[start:])); err != nil {
+		return "", err
+	}
+	agent.Sessions.SetHistory(sessionKey, append(slices.Clone(history[:start]), turn...))
+	agent.Sessions.Save(sessionKey)
+	logger.InfoCF("agent", "Switched reply variant", map[string]any{
+		"session_key": sessionKey,
+		"variant":     index + 1,
+		"variants":    len(v.Turns),
+	})
+	return turnReply(turn), nil
+}
+
+// stepVariant selects the variant step places after the current one,
+// wrapping around.
+func (al *AgentLoop) stepVariant(agent *AgentInstance, sessionKey string, step int) (string, error) {
+	v, ok := al.branches.Variants(sessionKey)
+	if !ok || len(v.Turns) < 2 {
+		return "", branch.ErrNoVariants
+	}
+	n := len(v.Turns)
+	return al.selectVariant(agent, sessionKey, ((v.Current+step)%n+n)%n)
+}
+
+// turnReply returns the final answer of a turn.
+func turnReply(turn []providers.Message) string {
+	for i := len(turn) - 1; i > 0; i-- {
+		if turn[i].Role == "assistant" && turn[i].Content != "" {
+			return turn[i].Content
+		}
+	}
+	return ""
+}
+
+// addVariantRuntime lets /variant switch between the replies of the latest
+// turn of the session.
+func (al *AgentLoop) addVariantRuntime(rt *commands.Runtime, agent *AgentInstance, opts *processOptions) {
+	if al.branches == nil || agent == nil || opts == nil || agent.Sessions == nil || opts.Ephemeral {
+		return
+	}
+	sessionKey := opts.SessionKey
+	rt.ReplyVariants = func() (current, count int) {
+		v, ok := al.branches.Variants(sessionKey)
+		if !ok {
+			return 0, 0
+		}
+		if _, _, err := al.latestTurn(agent, sessionKey, v.MessageID); err != nil {
+			return 0, 0
+		}
+		return v.Current, len(v.Turns)
+	}
+	rt.SelectVariant = func(index int) (string, error) {
+		return al.selectVariant(agent, sessionKey, index)
+	}
+}
@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+type numberedProvider struct {
+	calls int
+}
+
+func (p *numberedProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.calls++
+	return &providers.LLMResponse{Content: fmt.Sprintf("answer %d", p.calls)}, nil
+}
+
+func (p *numberedProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestRegenerate_KeepsReplyVariants(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	provider := &numberedProvider{}
+	al := NewAgentLoop(cfg, msgBus, provider)
+	ctx := context.Background()
+
+	send := func(msg bus.InboundMessage) (string, string) {
+		t.Helper()
+		response, err := al.processMessage(ctx, msg)
+		if err != nil {
+			t.Fatalf("processMessage(%q) error = %v", msg.Content, err)
+		}
+		return response, al.rememberTurn(msg, response)
+	}
+	command := func(content string) string {
+		t.Helper()
+		reply, _ := send(bus.InboundMessage{Channel: "discord", SenderID: "u1", ChatID: "c1", Content: content})
+		return reply
+	}
+	lastReply := func() string {
+		history := al.GetRegistry().GetDefaultAgent().Sessions.GetHistory("agent:main:main")
+		return history[len(history)-1].Content
+	}
+
+	_, turnID := send(bus.InboundMessage{
+		Channel: "discord", SenderID: "u1", ChatID: "c1", MessageID: "1", Content: "question", RequestID: "req-1",
+	})
+	if reply := command("/variant"); !strings.Contains(reply, "has not been regenerated") {
+		t.Fatalf("/variant before regenerating = %q", reply)
+	}
+
+	if err := al.regenerateTurn(ctx, turnID, bus.SenderInfo{PlatformID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	again := <-msgBus.InboundChan()
+	reply, turnID := send(again)
+	if reply != "answer 2" || lastReply() != "answer 2" {
+		t.Fatalf("regenerated reply = %q, history ends with %q", reply, lastReply())
+	}
+
+	// ◀️ on the new reply brings back the first one without asking the model
+	if err := al.switchVariantReaction(ctx, turnID, -1, bus.SenderInfo{PlatformID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	previous := <-msgBus.InboundChan()
+	if isRegenerated(previous) || variantStep(previous) != -1 {
+		t.Fatalf("variant switch message = %+v", previous)
+	}
+	if reply, _ := send(previous); reply != "answer 1" || lastReply() != "answer 1" || provider.calls != 2 {
+		t.Fatalf("previous variant = %q, history ends with %q, %d model calls", reply, lastReply(), provider.calls)
+	}
+
+	if reply := command("/variant"); !strings.HasPrefix(reply, "Continuing from reply 1 of 2.") {
+		t.Fatalf("/variant = %q", reply)
+	}
+	if reply := command("/variant next"); reply != "Reply 2 of 2:\n\nanswer 2" || lastReply() != "answer 2" {
+		t.Fatalf("/variant next = %q, history ends with %q", reply, lastReply())
+	}
+	if reply := command("/variant 5"); !strings.Contains(reply, "no reply with that number") {
+		t.Fatalf("/variant 5 = %q", reply)
+	}
+
+	// The conversation continues from the selected reply
+	send(bus.InboundMessage{Channel: "discord", SenderID: "u1", ChatID: "c1", MessageID: "2", Content: "thanks"})
+	history := al.GetRegistry().GetDefaultAgent().Sessions.GetHistory("agent:main:main")
+	var turns []string
+	for _, m := range history {
+		turns = append(turns, m.Content)
+	}
+	if got := strings.Join(turns, "|"); got != "question|answer 2|thanks|answer 3" {
+		t.Errorf("history = %q", got)
+	}
+	if reply := command("/variant"); !strings.Contains(reply, "has not been regenerated") {
+		t.Errorf("variants of an earlier message should not be offered: %q", reply)
+	}
+}
@@ -0,0 +1,112 @@
+// Package alert delivers operator alerts (error-rate spikes, provider
+// outages, budget exhaustion) to webhook, Slack, Discord, and PagerDuty sinks.
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// Severity levels, matching the PagerDuty Events v2 values.
+const (
+	SeverityCritical = "critical"
+	SeverityError    = "error"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+const defaultCooldown = 30 * time.Minute
+
+// Alert is a single notification for operators.
+type Alert struct {
+	// Key identifies the condition; alerts with the same key are deduplicated.
+	Key      string            `json:"key"`
+	Severity string            `json:"severity"`
+	Title    string            `json:"title"`
+	Message  string            `json:"message"`
+	Time     time.Time         `json:"time"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+// Text renders the alert as a single chat message.
+func (a Alert) Text() string {
+	text := fmt.Sprintf("🚨 [%s] %s", a.Severity, a.Title)
+	if a.Message != "" {
+		text += "\n" + a.Message
+	}
+	return text
+}
+
+// Sink delivers alerts to one destination.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, a Alert) error
+}
+
+// Manager fans alerts out to sinks, dropping repeats of the same key that
+// arrive within the cooldown.
+type Manager struct {
+	sinks    []Sink
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+}
+
+// NewManager creates a manager for the given sinks. A non-positive cooldown
+// uses the default of 30 minutes.
+func NewManager(sinks []Sink, cooldown time.Duration) *Manager {
+	if cooldown <= 0 {
+		cooldown = defaultCooldown
+	}
+	return &Manager{
+		sinks:    sinks,
+		cooldown: cooldown,
+		now:      time.Now,
+		lastSent: make(map[string]time.Time),
+	}
+}
+
+// NewManagerFromConfig builds the sinks listed in cfg.
+func NewManagerFromConfig(cfg config.AlertsConfig) (*Manager, error) {
+	sinks := make([]Sink, 0, len(cfg.Sinks))
+	for i, sc := range cfg.Sinks {
+		sink, err := NewSink(sc)
+		if err != nil {
+			return nil, fmt.Errorf("alerts.sinks[%d]: %w", i, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	return NewManager(sinks, time.Duration(cfg.CooldownMinutes)*time.Minute), nil
+}
+
+// Fire sends a to every sink unless an alert with the same key was sent
+// within the cooldown. It reports whether the alert was sent, and the
+// combined errors of any sinks that failed.
+func (m *Manager) Fire(ctx context.Context, a Alert) (bool, error) {
+	if a.Time.IsZero() {
+		a.Time = m.now()
+	}
+
+	m.mu.Lock()
+	if last, ok := m.lastSent[a.Key]; ok && a.Time.Sub(last) < m.cooldown {
+		m.mu.Unlock()
+		return false, nil
+	}
+	m.lastSent[a.Key] = a.Time
+	m.mu.Unlock()
+
+	var errs []error
+	for _, sink := range m.sinks {
+		if err := sink.Send(ctx, a); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return true, errors.Join(errs...)
+}
@@ -0,0 +1,201 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	alerts []Alert
+	err    error
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Send(_ context.Context, a Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerts = append(s.alerts, a)
+	return s.err
+}
+
+func TestManager_Cooldown(t *testing.T) {
+	sink := &recordingSink{}
+	m := NewManager([]Sink{sink}, time.Minute)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	fire := func(key string, at time.Time) bool {
+		sent, err := m.Fire(ctx, Alert{Key: key, Time: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sent
+	}
+
+	if !fire("a", start) {
+		t.Error("first alert should be sent")
+	}
+	if fire("a", start.Add(30*time.Second)) {
+		t.Error("repeat within cooldown should be suppressed")
+	}
+	if !fire("b", start.Add(30*time.Second)) {
+		t.Error("different key should not be suppressed")
+	}
+	if !fire("a", start.Add(2*time.Minute)) {
+		t.Error("repeat after cooldown should be sent")
+	}
+	if len(sink.alerts) != 3 {
+		t.Errorf("sink got %d alerts, want 3", len(sink.alerts))
+	}
+}
+
+func TestManager_SinkErrorsAreJoined(t *testing.T) {
+	ok := &recordingSink{}
+	bad := &recordingSink{err: errors.New("boom")}
+	m := NewManager([]Sink{bad, ok}, 0)
+
+	sent, err := m.Fire(context.Background(), Alert{Key: "x"})
+	if !sent || err == nil {
+		t.Fatalf("sent=%v err=%v, want sent with error", sent, err)
+	}
+	if len(ok.alerts) != 1 {
+		t.Error("a failing sink must not block the others")
+	}
+}
+
+func TestSinks_Payloads(t *testing.T) {
+	var got map[string]any
+	var header string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = nil
+		json.Unmarshal(body, &got)
+		header = r.Header.Get("X-Token")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	a := Alert{Key: "error_rate", Severity: SeverityError, Title: "Errors up", Time: time.Now()}
+	ctx := context.Background()
+
+	tests := []struct {
+		cfg   config.AlertSinkConfig
+		check func(t *testing.T)
+	}{
+		{config.AlertSinkConfig{Type: "webhook", URL: srv.URL, Headers: map[string]string{"X-Token": "t"}}, func(t *testing.T) {
+			if got["key"] != "error_rate" || header != "t" {
+				t.Errorf("webhook body=%v header=%q", got, header)
+			}
+		}},
+		{config.AlertSinkConfig{Type: "slack", URL: srv.URL}, func(t *testing.T) {
+			if got["text"] != "🚨 [error] Errors up" {
+				t.Errorf("slack body = %v", got)
+			}
+		}},
+		{config.AlertSinkConfig{Type: "discord", URL: srv.URL}, func(t *testing.T) {
+			if got["content"] != "🚨 [error] Errors up" {
+				t.Errorf("discord body = %v", got)
+			}
+		}},
+		{config.AlertSinkConfig{Type: "pagerduty", URL: srv.URL, RoutingKey: "rk"}, func(t *testing.T) {
+			payload, _ := got["payload"].(map[string]any)
+			if got["routing_key"] != "rk" || got["dedup_key"] != "error_rate" || payload["severity"] != "error" {
+				t.Errorf("pagerduty body = %v", got)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cfg.Type, func(t *testing.T) {
+			sink, err := NewSink(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Send(ctx, a); err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t)
+		})
+	}
+
+	if _, err := NewSink(config.AlertSinkConfig{Type: "pagerduty"}); err == nil {
+		t.Error("pagerduty without routing_key should be rejected")
+	}
+	if _, err := NewSink(config.AlertSinkConfig{Type: "sms"}); err == nil {
+		t.Error("unknown sink type should be rejected")
+	}
+}
+
+func newTestMonitor(cfg config.AlertsConfig, pricing usage.Pricing) (*Monitor, *[]Alert) {
+	var fired []Alert
+	m := NewMonitor(cfg, nil, pricing)
+	m.fire = func(a Alert) { fired = append(fired, a) }
+	return m, &fired
+}
+
+func TestMonitor_ErrorRate(t *testing.T) {
+	m, fired := newTestMonitor(config.AlertsConfig{
+		ErrorRate: config.ErrorRateAlert{Threshold: 0.5, WindowMinutes: 5, MinRequests: 4},
+	}, nil)
+
+	for _, failed := range []bool{false, true, true} {
+		m.Observe(usage.Record{Error: failed})
+	}
+	if len(*fired) != 0 {
+		t.Fatal("should not fire before min_requests")
+	}
+	m.Observe(usage.Record{Error: false})
+	if len(*fired) != 1 || (*fired)[0].Key != "error_rate" {
+		t.Fatalf("fired = %+v, want one error_rate alert", *fired)
+	}
+}
+
+func TestMonitor_OutageResetsOnSuccess(t *testing.T) {
+	m, fired := newTestMonitor(config.AlertsConfig{OutageFailures: 3}, nil)
+
+	m.Observe(usage.Record{Model: "gpt", Error: true})
+	m.Observe(usage.Record{Model: "gpt", Error: true})
+	m.Observe(usage.Record{Model: "gpt"})
+	m.Observe(usage.Record{Model: "gpt", Error: true})
+	m.Observe(usage.Record{Model: "gpt", Error: true})
+	if len(*fired) != 0 {
+		t.Fatal("success should reset the consecutive failure count")
+	}
+	m.Observe(usage.Record{Model: "gpt", Error: true})
+	if len(*fired) != 1 || (*fired)[0].Key != "provider_outage:gpt" {
+		t.Fatalf("fired = %+v", *fired)
+	}
+}
+
+func TestMonitor_BudgetFiresOnceWhenCrossed(t *testing.T) {
+	pricing := usage.Pricing{"gpt": {Input: 1_000_000}} // $1 per prompt token
+	m, fired := newTestMonitor(config.AlertsConfig{DailyBudget: 5}, pricing)
+	m.Seed([]usage.Record{{Time: time.Now(), Model: "gpt", PromptTokens: 3}})
+
+	m.Observe(usage.Record{Model: "gpt", PromptTokens: 1})
+	if len(*fired) != 0 {
+		t.Fatal("$4 of $5 should not fire")
+	}
+	m.Observe(usage.Record{Model: "gpt", PromptTokens: 1})
+	m.Observe(usage.Record{Model: "gpt", PromptTokens: 1})
+	if len(*fired) != 1 || (*fired)[0].Severity != SeverityWarning {
+		t.Fatalf("fired = %+v, want one budget alert", *fired)
+	}
+}
+
+func TestMonitor_NilIsNoop(t *testing.T) {
+	var m *Monitor
+	m.Observe(usage.Record{Error: true})
+	m.Seed(nil)
+}
@@ -0,0 +1,208 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+const (
+	defaultErrorWindow = 10 * time.Minute
+	defaultMinRequests = 10
+)
+
+type outcome struct {
+	at     time.Time
+	failed bool
+}
+
+// Monitor watches LLM usage records and raises alerts when a configured
+// condition trips. A nil *Monitor ignores everything.
+type Monitor struct {
+	cfg     config.AlertsConfig
+	manager *Manager
+	pricing usage.Pricing
+	now     func() time.Time
+	fire    func(Alert)
+
+	mu       sync.Mutex
+	outcomes []outcome
+	failures map[string]int
+	spendDay string
+	spend    float64
+}
+
+// NewMonitor creates a monitor that fires through manager.
+func NewMonitor(cfg config.AlertsConfig, manager *Manager, pricing usage.Pricing) *Monitor {
+	m := &Monitor{
+		cfg:      cfg,
+		manager:  manager,
+		pricing:  pricing,
+		now:      time.Now,
+		failures: make(map[string]int),
+	}
+	m.fire = m.fireAsync
+	return m
+}
+
+// fireAsync delivers off the request path so a slow sink never delays a reply.
+func (m *Monitor) fireAsync(a Alert) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*sinkTimeout)
+		defer cancel()
+		if _, err := m.manager.Fire(ctx, a); err != nil {
+			logger.WarnCF("alert", "Failed to deliver alert", map[string]any{
+				"key":   a.Key,
+				"error": err.Error(),
+			})
+		}
+	}()
+}
+
+// Seed restores today's spend from stored records so a restart does not
+// reset the budget.
+func (m *Monitor) Seed(records []usage.Record) {
+	if m == nil || m.cfg.DailyBudget <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	day := m.now().Format(time.DateOnly)
+	for _, r := range records {
+		if r.Time.Local().Format(time.DateOnly) == day {
+			m.addSpendLocked(day, m.pricing.Cost(r))
+		}
+	}
+}
+
+// Observe records one LLM call and fires any alert it trips.
+func (m *Monitor) Observe(r usage.Record) {
+	if m == nil {
+		return
+	}
+	now := m.now()
+
+	m.mu.Lock()
+	alerts := m.checkErrorRateLocked(now, r.Error)
+	if a, ok := m.checkOutageLocked(r); ok {
+		alerts = append(alerts, a)
+	}
+	if a, ok := m.checkBudgetLocked(now, r); ok {
+		alerts = append(alerts, a)
+	}
+	m.mu.Unlock()
+
+	for _, a := range alerts {
+		a.Time = now
+		m.fire(a)
+	}
+}
+
+func (m *Monitor) checkErrorRateLocked(now time.Time, failed bool) []Alert {
+	ec := m.cfg.ErrorRate
+	if ec.Threshold <= 0 {
+		return nil
+	}
+	window := time.Duration(ec.WindowMinutes) * time.Minute
+	if window <= 0 {
+		window = defaultErrorWindow
+	}
+	minRequests := ec.MinRequests
+	if minRequests <= 0 {
+		minRequests = defaultMinRequests
+	}
+
+	m.outcomes = append(m.outcomes, outcome{at: now, failed: failed})
+	cutoff := now.Add(-window)
+	drop := 0
+	for drop < len(m.outcomes) && m.outcomes[drop].at.Before(cutoff) {
+		drop++
+	}
+	m.outcomes = m.outcomes[drop:]
+
+	if len(m.outcomes) < minRequests {
+		return nil
+	}
+	errs := 0
+	for _, o := range m.outcomes {
+		if o.failed {
+			errs++
+		}
+	}
+	rate := float64(errs) / float64(len(m.outcomes))
+	if rate < ec.Threshold {
+		return nil
+	}
+	return []Alert{{
+		Key:      "error_rate",
+		Severity: SeverityError,
+		Title:    fmt.Sprintf("LLM error rate %.0f%% over the last %s", rate*100, window),
+		Message:  fmt.Sprintf("%d of %d requests failed (threshold %.0f%%).", errs, len(m.outcomes), ec.Threshold*100),
+		Fields: map[string]string{
+			"errors":   strconv.Itoa(errs),
+			"requests": strconv.Itoa(len(m.outcomes)),
+		},
+	}}
+}
+
+func (m *Monitor) checkOutageLocked(r usage.Record) (Alert, bool) {
+	if m.cfg.OutageFailures <= 0 {
+		return Alert{}, false
+	}
+	if !r.Error {
+		delete(m.failures, r.Model)
+		return Alert{}, false
+	}
+	m.failures[r.Model]++
+	n := m.failures[r.Model]
+	if n < m.cfg.OutageFailures {
+		return Alert{}, false
+	}
+	return Alert{
+		Key:      "provider_outage:" + r.Model,
+		Severity: SeverityCritical,
+		Title:    fmt.Sprintf("Model %s appears to be down", r.Model),
+		Message:  fmt.Sprintf("%d consecutive requests failed.", n),
+		Fields:   map[string]string{"model": r.Model, "failures": strconv.Itoa(n)},
+	}, true
+}
+
+func (m *Monitor) checkBudgetLocked(now time.Time, r usage.Record) (Alert, bool) {
+	budget := m.cfg.DailyBudget
+	if budget <= 0 {
+		return Alert{}, false
+	}
+	day := now.Format(time.DateOnly)
+	before := m.spendForLocked(day)
+	m.addSpendLocked(day, m.pricing.Cost(r))
+	if before >= budget || m.spend < budget {
+		return Alert{}, false
+	}
+	return Alert{
+		Key:      "budget_exhausted:" + day,
+		Severity: SeverityWarning,
+		Title:    fmt.Sprintf("Daily LLM budget of $%.2f exhausted", budget),
+		Message:  fmt.Sprintf("Spend today is $%.2f.", m.spend),
+		Fields:   map[string]string{"day": day, "spend": fmt.Sprintf("%.2f", m.spend)},
+	}, true
+}
+
+func (m *Monitor) spendForLocked(day string) float64 {
+	if m.spendDay != day {
+		return 0
+	}
+	return m.spend
+}
+
+func (m *Monitor) addSpendLocked(day string, cost float64) {
+	if m.spendDay != day {
+		m.spendDay, m.spend = day, 0
+	}
+	m.spend += cost
+}
@@ -0,0 +1,127 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// PagerDutyEventsURL is the PagerDuty Events API v2 endpoint.
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+const sinkTimeout = 10 * time.Second
+
+// NewSink creates the sink described by cfg.
+func NewSink(cfg config.AlertSinkConfig) (Sink, error) {
+	client := &http.Client{Timeout: sinkTimeout}
+	switch strings.ToLower(cfg.Type) {
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, errors.New("webhook sink requires url")
+		}
+		return &webhookSink{name: "webhook", url: cfg.URL, headers: cfg.Headers, client: client, body: webhookBody}, nil
+	case "slack":
+		if cfg.URL == "" {
+			return nil, errors.New("slack sink requires url")
+		}
+		return &webhookSink{name: "slack", url: cfg.URL, client: client, body: slackBody}, nil
+	case "discord":
+		if cfg.URL == "" {
+			return nil, errors.New("discord sink requires url")
+		}
+		return &webhookSink{name: "discord", url: cfg.URL, client: client, body: discordBody}, nil
+	case "pagerduty":
+		if cfg.RoutingKey == "" {
+			return nil, errors.New("pagerduty sink requires routing_key")
+		}
+		url := cfg.URL
+		if url == "" {
+			url = PagerDutyEventsURL
+		}
+		return &webhookSink{name: "pagerduty", url: url, client: client, body: pagerDutyBody(cfg.RoutingKey)}, nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", cfg.Type)
+	}
+}
+
+// webhookSink POSTs a JSON body built from the alert. The sink types differ
+// only in the body they send.
+type webhookSink struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+	body    func(Alert) any
+}
+
+func (s *webhookSink) Name() string { return s.name }
+
+func (s *webhookSink) Send(ctx context.Context, a Alert) error {
+	payload, err := json.Marshal(s.body(a))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func webhookBody(a Alert) any {
+	return a
+}
+
+func slackBody(a Alert) any {
+	return map[string]string{"text": a.Text()}
+}
+
+func discordBody(a Alert) any {
+	return map[string]string{"content": a.Text()}
+}
+
+func pagerDutyBody(routingKey string) func(Alert) any {
+	return func(a Alert) any {
+		details := make(map[string]any, len(a.Fields)+1)
+		for k, v := range a.Fields {
+			details[k] = v
+		}
+		if a.Message != "" {
+			details["message"] = a.Message
+		}
+		return map[string]any{
+			"routing_key":  routingKey,
+			"event_action": "trigger",
+			"dedup_key":    a.Key,
+			"payload": map[string]any{
+				"summary":        a.Title,
+				"source":         "picoclaw",
+				"severity":       a.Severity,
+				"timestamp":      a.Time.UTC().Format(time.RFC3339),
+				"custom_details": details,
+			},
+		}
+	}
+}
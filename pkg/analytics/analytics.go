@@ -0,0 +1,177 @@
+// Package analytics records one row per handled message (channel, model,
+// tokens, latency breakdown and outcome) in the message_analytics table of
+// the workspace database, and exports the rows as CSV or Parquet for offline
+// analysis.
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/database"
+)
+
+// Outcomes of a handled message.
+const (
+	OutcomeOK      = "ok"      // answered by the model
+	OutcomeCommand = "command" // answered by a chat command
+	OutcomeError   = "error"   // the turn failed
+	OutcomeStopped = "stopped" // the user stopped the reply
+	OutcomeQueued  = "queued"  // held by the offline queue
+	OutcomeBlocked = "blocked" // the message or reply was blocked by a guardrail
+)
+
+// timeFormat is fixed-width so stored times sort and compare as text.
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// Record is one handled message.
+type Record struct {
+	Time             time.Time
+	RequestID        string
+	AgentID          string
+	Channel          string
+	ChatID           string
+	SenderID         string
+	Model            string
+	Variant          string
+	Outcome          string
+	LLMCalls         int
+	ToolCalls        int
+	PromptTokens     int
+	CompletionTokens int
+	TotalMS          int64 // from receiving the message to having the reply
+	LLMMS            int64 // spent waiting for the provider
+	ToolMS           int64 // spent running tools
+}
+
+// Columns lists the exported columns, in order.
+var Columns = []string{
+	"time", "request_id", "agent_id", "channel", "chat_id", "sender_id", "model", "variant", "outcome",
+	"llm_calls", "tool_calls", "prompt_tokens", "completion_tokens", "total_ms", "llm_ms", "tool_ms",
+}
+
+// Store writes and reads records. A nil *Store records nothing.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the workspace database at path, migrating it if needed.
+func Open(ctx context.Context, path string) (*Store, error) {
+	db, err := database.Open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Record stores rec. A zero Time means now.
+func (s *Store) Record(ctx context.Context, rec Record) error {
+	if s == nil {
+		return nil
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO message_analytics (
+		time, request_id, agent_id, channel, chat_id, sender_id, model, variant, outcome,
+		llm_calls, tool_calls, prompt_tokens, completion_tokens, total_ms, llm_ms, tool_ms
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.Time.UTC().Format(timeFormat), rec.RequestID, rec.AgentID, rec.Channel, rec.ChatID,
+		rec.SenderID, rec.Model, rec.Variant, rec.Outcome,
+		rec.LLMCalls, rec.ToolCalls, rec.PromptTokens, rec.CompletionTokens,
+		rec.TotalMS, rec.LLMMS, rec.ToolMS,
+	)
+	if err != nil {
+		return fmt.Errorf("record analytics: %w", err)
+	}
+	return nil
+}
+
+// Records returns the records from since up to, but not including, until,
+// oldest first. Zero times leave that end open.
+func (s *Store) Records(ctx context.Context, since, until time.Time) ([]Record, error) {
+	if s == nil {
+		return nil, nil
+	}
+	query := `SELECT time, request_id, agent_id, channel, chat_id, sender_id, model, variant, outcome,
+		llm_calls, tool_calls, prompt_tokens, completion_tokens, total_ms, llm_ms, tool_ms
+		FROM message_analytics WHERE 1 = 1`
+	var args []any
+	if !since.IsZero() {
+		query += " AND time >= ?"
+		args = append(args, since.UTC().Format(timeFormat))
+	}
+	if !until.IsZero() {
+		query += " AND time < ?"
+		args = append(args, until.UTC().Format(timeFormat))
+	}
+	query += " ORDER BY time, id"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("read analytics: %w", err)
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var rec Record
+		var at string
+		if err := rows.Scan(&at, &rec.RequestID, &rec.AgentID, &rec.Channel, &rec.ChatID, &rec.SenderID,
+			&rec.Model, &rec.Variant, &rec.Outcome, &rec.LLMCalls, &rec.ToolCalls,
+			&rec.PromptTokens, &rec.CompletionTokens, &rec.TotalMS, &rec.LLMMS, &rec.ToolMS); err != nil {
+			return nil, fmt.Errorf("read analytics: %w", err)
+		}
+		rec.Time, _ = time.Parse(timeFormat, at)
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read analytics: %w", err)
+	}
+	return records, nil
+}
+
+// ForgetSender deletes the records of senderID on channel and returns how
+// many were deleted.
+func (s *Store) ForgetSender(ctx context.Context, channel, senderID string) (int, error) {
+	if s == nil || senderID == "" {
+		return 0, nil
+	}
+	res, err := s.db.ExecContext(ctx,
+		"DELETE FROM message_analytics WHERE channel = ? AND sender_id = ?", channel, senderID)
+	if err != nil {
+		return 0, fmt.Errorf("forget analytics: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// WriteCSV writes records as CSV with a header row of Columns.
+func WriteCSV(w io.Writer, records []Record) error {
+	cw := csv.NewWriter(w)
+	cw.Write(Columns)
+	for _, rec := range records {
+		cw.Write([]string{
+			rec.Time.UTC().Format(time.RFC3339Nano), rec.RequestID, rec.AgentID, rec.Channel, rec.ChatID,
+			rec.SenderID, rec.Model, rec.Variant, rec.Outcome,
+			strconv.Itoa(rec.LLMCalls), strconv.Itoa(rec.ToolCalls),
+			strconv.Itoa(rec.PromptTokens), strconv.Itoa(rec.CompletionTokens),
+			strconv.FormatInt(rec.TotalMS, 10), strconv.FormatInt(rec.LLMMS, 10), strconv.FormatInt(rec.ToolMS, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,148 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(context.Background(), filepath.Join(t.TempDir(), "picoclaw.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStore_RecordAndRange(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, outcome := range []string{OutcomeOK, OutcomeError, OutcomeCommand} {
+		err := store.Record(ctx, Record{
+			Time:         base.Add(time.Duration(i) * time.Hour),
+			Channel:      "telegram",
+			ChatID:       "42",
+			Model:        "gpt-test",
+			Outcome:      outcome,
+			LLMCalls:     i,
+			PromptTokens: 100 * i,
+			TotalMS:      1500,
+			LLMMS:        1200,
+		})
+		if err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	all, err := store.Records(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Records: %v", err)
+	}
+	if len(all) != 3 || all[0].Outcome != OutcomeOK || all[2].Outcome != OutcomeCommand {
+		t.Fatalf("Records = %+v", all)
+	}
+	if !all[1].Time.Equal(base.Add(time.Hour)) || all[1].PromptTokens != 100 || all[1].LLMMS != 1200 {
+		t.Errorf("record round trip = %+v", all[1])
+	}
+
+	ranged, err := store.Records(ctx, base.Add(time.Hour), base.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Records: %v", err)
+	}
+	if len(ranged) != 1 || ranged[0].Outcome != OutcomeError {
+		t.Errorf("ranged Records = %+v", ranged)
+	}
+}
+
+func TestStore_ForgetSender(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	for _, sender := range []string{"7", "8", "7"} {
+		if err := store.Record(ctx, Record{Channel: "telegram", SenderID: sender}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	store.Record(ctx, Record{Channel: "discord", SenderID: "7"})
+
+	n, err := store.ForgetSender(ctx, "telegram", "7")
+	if err != nil || n != 2 {
+		t.Fatalf("ForgetSender = %d, %v", n, err)
+	}
+	if left, _ := store.Records(ctx, time.Time{}, time.Time{}); len(left) != 2 {
+		t.Errorf("left %d records, want 2", len(left))
+	}
+}
+
+func TestStore_Nil(t *testing.T) {
+	var store *Store
+	if err := store.Record(context.Background(), Record{Channel: "cli"}); err != nil {
+		t.Errorf("nil Record: %v", err)
+	}
+	if recs, err := store.Records(context.Background(), time.Time{}, time.Time{}); recs != nil || err != nil {
+		t.Errorf("nil Records = %v, %v", recs, err)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	recs := []Record{{
+		Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Channel: "discord", Model: "m, with comma",
+		Outcome: OutcomeOK, ToolCalls: 2, ToolMS: 340,
+	}}
+	if err := WriteCSV(&buf, recs); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read back: %v", err)
+	}
+	if len(rows) != 2 || len(rows[0]) != len(Columns) || rows[0][0] != "time" {
+		t.Fatalf("rows = %v", rows)
+	}
+	if rows[1][0] != "2026-03-01T12:00:00Z" || rows[1][6] != "m, with comma" || rows[1][10] != "2" ||
+		rows[1][15] != "340" {
+		t.Errorf("row = %v", rows[1])
+	}
+}
+
+func TestTurn(t *testing.T) {
+	ctx, turn := NewContext(context.Background())
+	if FromContext(ctx) != turn {
+		t.Fatal("FromContext did not return the turn")
+	}
+	turn.SetAgent("main", "canary")
+	turn.AddLLMCall("a", 10, 5, 200*time.Millisecond)
+	turn.AddLLMCall("b", 20, 7, 300*time.Millisecond)
+	turn.AddTools(3, 150*time.Millisecond)
+
+	rec := turn.Finish(OutcomeOK)
+	if rec.AgentID != "main" || rec.Variant != "canary" || rec.Model != "b" || rec.Outcome != OutcomeOK {
+		t.Errorf("rec = %+v", rec)
+	}
+	if rec.LLMCalls != 2 || rec.PromptTokens != 30 || rec.CompletionTokens != 12 || rec.LLMMS != 500 {
+		t.Errorf("llm figures = %+v", rec)
+	}
+	if rec.ToolCalls != 3 || rec.ToolMS != 150 || rec.Time.IsZero() {
+		t.Errorf("tool figures = %+v", rec)
+	}
+
+	turn.MarkCommand()
+	if got := turn.Finish(OutcomeOK).Outcome; got != OutcomeCommand {
+		t.Errorf("command outcome = %q", got)
+	}
+	if got := turn.Finish(OutcomeError).Outcome; got != OutcomeError {
+		t.Errorf("failed command outcome = %q", got)
+	}
+
+	var none *Turn
+	none.AddLLMCall("a", 1, 1, time.Second)
+	if FromContext(context.Background()) != nil {
+		t.Error("FromContext on a bare context should be nil")
+	}
+}
@@ -0,0 +1,252 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Parquet physical and converted types, encodings and protocol constants
+// used by WriteParquet; see parquet-format's parquet.thrift.
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	convertedNone            = -1
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	repetitionRequired = 0
+	pageTypeData       = 0
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+
+	// Thrift compact protocol type IDs.
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+var parquetMagic = []byte("PAR1")
+
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	int64Of   func(Record) int64
+	stringOf  func(Record) string
+}
+
+func int64Column(name string, of func(Record) int64) parquetColumn {
+	return parquetColumn{name: name, typ: parquetInt64, converted: convertedNone, int64Of: of}
+}
+
+func stringColumn(name string, of func(Record) string) parquetColumn {
+	return parquetColumn{name: name, typ: parquetByteArray, converted: convertedUTF8, stringOf: of}
+}
+
+// parquetColumns follows Columns.
+var parquetColumns = []parquetColumn{
+	{
+		name: "time", typ: parquetInt64, converted: convertedTimestampMillis,
+		int64Of: func(r Record) int64 { return r.Time.UnixMilli() },
+	},
+	stringColumn("request_id", func(r Record) string { return r.RequestID }),
+	stringColumn("agent_id", func(r Record) string { return r.AgentID }),
+	stringColumn("channel", func(r Record) string { return r.Channel }),
+	stringColumn("chat_id", func(r Record) string { return r.ChatID }),
+	stringColumn("sender_id", func(r Record) string { return r.SenderID }),
+	stringColumn("model", func(r Record) string { return r.Model }),
+	stringColumn("variant", func(r Record) string { return r.Variant }),
+	stringColumn("outcome", func(r Record) string { return r.Outcome }),
+	int64Column("llm_calls", func(r Record) int64 { return int64(r.LLMCalls) }),
+	int64Column("tool_calls", func(r Record) int64 { return int64(r.ToolCalls) }),
+	int64Column("prompt_tokens", func(r Record) int64 { return int64(r.PromptTokens) }),
+	int64Column("completion_tokens", func(r Record) int64 { return int64(r.CompletionTokens) }),
+	int64Column("total_ms", func(r Record) int64 { return r.TotalMS }),
+	int64Column("llm_ms", func(r Record) int64 { return r.LLMMS }),
+	int64Column("tool_ms", func(r Record) int64 { return r.ToolMS }),
+}
+
+// WriteParquet writes records as an uncompressed Parquet file with one row
+// group and one PLAIN-encoded page per column. All columns are required;
+// time is a millisecond UTC timestamp.
+func WriteParquet(w io.Writer, records []Record) error {
+	type chunk struct {
+		offset int64
+		size   int64
+	}
+	var body bytes.Buffer
+	body.Write(parquetMagic)
+
+	chunks := make([]chunk, len(parquetColumns))
+	var rowGroupSize int64
+	if len(records) > 0 {
+		for i, col := range parquetColumns {
+			var values bytes.Buffer
+			for _, rec := range records {
+				if col.typ == parquetInt64 {
+					binary.Write(&values, binary.LittleEndian, col.int64Of(rec))
+					continue
+				}
+				s := col.stringOf(rec)
+				binary.Write(&values, binary.LittleEndian, uint32(len(s)))
+				values.WriteString(s)
+			}
+
+			var header compactWriter
+			header.i32(1, pageTypeData)
+			header.i32(2, int32(values.Len()))
+			header.i32(3, int32(values.Len()))
+			header.structField(5, func() {
+				header.i32(1, int32(len(records)))
+				header.i32(2, encodingPlain)
+				header.i32(3, encodingRLE)
+				header.i32(4, encodingRLE)
+			})
+			header.stop()
+
+			chunks[i] = chunk{offset: int64(body.Len()), size: int64(header.buf.Len() + values.Len())}
+			rowGroupSize += chunks[i].size
+			body.Write(header.buf.Bytes())
+			body.Write(values.Bytes())
+		}
+	}
+
+	var meta compactWriter
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(parquetColumns)+1)
+	meta.elemStruct(func() {
+		meta.str(4, "schema")
+		meta.i32(5, int32(len(parquetColumns)))
+	})
+	for _, col := range parquetColumns {
+		meta.elemStruct(func() {
+			meta.i32(1, col.typ)
+			meta.i32(3, repetitionRequired)
+			meta.str(4, col.name)
+			if col.converted != convertedNone {
+				meta.i32(6, col.converted)
+			}
+		})
+	}
+	meta.i64(3, int64(len(records)))
+	if len(records) == 0 {
+		meta.list(4, thriftStruct, 0)
+	} else {
+		meta.list(4, thriftStruct, 1)
+		meta.elemStruct(func() {
+			meta.list(1, thriftStruct, len(parquetColumns))
+			for i, col := range parquetColumns {
+				meta.elemStruct(func() {
+					meta.i64(2, chunks[i].offset)
+					meta.structField(3, func() {
+						meta.i32(1, col.typ)
+						meta.list(2, thriftI32, 1)
+						meta.elemI32(encodingPlain)
+						meta.list(3, thriftBinary, 1)
+						meta.elemStr(col.name)
+						meta.i32(4, codecUncompressed)
+						meta.i64(5, int64(len(records)))
+						meta.i64(6, chunks[i].size)
+						meta.i64(7, chunks[i].size)
+						meta.i64(9, chunks[i].offset)
+					})
+				})
+			}
+			meta.i64(2, rowGroupSize)
+			meta.i64(3, int64(len(records)))
+		})
+	}
+	meta.str(6, "picoclaw")
+	meta.stop()
+
+	body.Write(meta.buf.Bytes())
+	binary.Write(&body, binary.LittleEndian, uint32(meta.buf.Len()))
+	body.Write(parquetMagic)
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// compactWriter encodes Thrift structs with the compact protocol, which is
+// what Parquet uses for its page headers and file metadata.
+type compactWriter struct {
+	buf   bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func (c *compactWriter) field(typ byte, id int16) {
+	if delta := id - c.last; delta > 0 && delta <= 15 {
+		c.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		c.buf.WriteByte(typ)
+		c.zigzag(int64(id))
+	}
+	c.last = id
+}
+
+func (c *compactWriter) varint(v uint64) {
+	c.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (c *compactWriter) zigzag(v int64) {
+	c.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (c *compactWriter) i32(id int16, v int32) {
+	c.field(thriftI32, id)
+	c.zigzag(int64(v))
+}
+
+func (c *compactWriter) i64(id int16, v int64) {
+	c.field(thriftI64, id)
+	c.zigzag(v)
+}
+
+func (c *compactWriter) str(id int16, s string) {
+	c.field(thriftBinary, id)
+	c.elemStr(s)
+}
+
+func (c *compactWriter) structField(id int16, body func()) {
+	c.field(thriftStruct, id)
+	c.elemStruct(body)
+}
+
+// list writes the header of a list field; the caller writes its n elements.
+func (c *compactWriter) list(id int16, elem byte, n int) {
+	c.field(thriftList, id)
+	if n < 15 {
+		c.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	c.buf.WriteByte(0xf0 | elem)
+	c.varint(uint64(n))
+}
+
+func (c *compactWriter) elemI32(v int32) {
+	c.zigzag(int64(v))
+}
+
+func (c *compactWriter) elemStr(s string) {
+	c.varint(uint64(len(s)))
+	c.buf.WriteString(s)
+}
+
+func (c *compactWriter) elemStruct(body func()) {
+	c.stack = append(c.stack, c.last)
+	c.last = 0
+	body()
+	c.stop()
+	c.last = c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+}
+
+// stop ends the current struct.
+func (c *compactWriter) stop() {
+	c.buf.WriteByte(0)
+}
@@ -0,0 +1,147 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// compactReader decodes Thrift compact structs into maps from field ID to
+// int64, []byte, []any or map[int16]any, enough to check WriteParquet.
+type compactReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *compactReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) value(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.uvarint())
+		b := r.data[r.pos : r.pos+n]
+		r.pos += n
+		return b
+	case thriftList:
+		h := r.data[r.pos]
+		r.pos++
+		n, elem := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(elem)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic("unsupported thrift type")
+}
+
+func (r *compactReader) readStruct() map[int16]any {
+	fields := make(map[int16]any)
+	var last int16
+	for {
+		h := r.data[r.pos]
+		r.pos++
+		if h == 0 {
+			return fields
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(h & 0x0f)
+		last = id
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	recs := []Record{
+		{Time: at, Channel: "telegram", Outcome: OutcomeOK, PromptTokens: 120, TotalMS: 900},
+		{Time: at.Add(time.Minute), Channel: "discord", Outcome: OutcomeError, PromptTokens: 7},
+	}
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, recs); err != nil {
+		t.Fatalf("WriteParquet: %v", err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := (&compactReader{data: data[len(data)-8-footerLen : len(data)-8]}).readStruct()
+
+	if meta[3] != int64(2) {
+		t.Errorf("num_rows = %v", meta[3])
+	}
+	schema := meta[2].([]any)
+	if len(schema) != len(Columns)+1 {
+		t.Fatalf("schema has %d elements", len(schema))
+	}
+	for i, name := range Columns {
+		if got := string(schema[i+1].(map[int16]any)[4].([]byte)); got != name {
+			t.Errorf("column %d = %q, want %q", i, got, name)
+		}
+	}
+
+	// Read the values of the channel and prompt_tokens columns back.
+	columns := meta[4].([]any)[0].(map[int16]any)[1].([]any)
+	page := func(col int) []byte {
+		chunk := columns[col].(map[int16]any)[3].(map[int16]any)
+		r := &compactReader{data: data, pos: int(chunk[9].(int64))}
+		header := r.readStruct()
+		return data[r.pos : r.pos+int(header[3].(int64))]
+	}
+	channels := page(3)
+	if n := binary.LittleEndian.Uint32(channels); string(channels[4:4+n]) != "telegram" {
+		t.Errorf("channel column = %q", channels)
+	}
+	tokens := page(11)
+	if binary.LittleEndian.Uint64(tokens) != 120 || binary.LittleEndian.Uint64(tokens[8:]) != 7 {
+		t.Errorf("prompt_tokens column = %v", tokens)
+	}
+	times := page(0)
+	if int64(binary.LittleEndian.Uint64(times)) != at.UnixMilli() {
+		t.Errorf("time column = %v", times)
+	}
+}
+
+func TestWriteParquet_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, nil); err != nil {
+		t.Fatalf("WriteParquet: %v", err)
+	}
+	data := buf.Bytes()
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := (&compactReader{data: data[len(data)-8-footerLen : len(data)-8]}).readStruct()
+	if meta[3] != int64(0) || len(meta[4].([]any)) != 0 {
+		t.Errorf("empty file metadata = %v", meta)
+	}
+}
+
+func TestParquetColumnsMatchColumns(t *testing.T) {
+	if len(parquetColumns) != len(Columns) {
+		t.Fatalf("%d parquet columns, %d columns", len(parquetColumns), len(Columns))
+	}
+	for i, col := range parquetColumns {
+		if col.name != Columns[i] {
+			t.Errorf("parquet column %d = %q, want %q", i, col.name, Columns[i])
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type ctxKey struct{}
+
+// Turn collects the figures of one message while it is handled. It is safe
+// for concurrent use; the methods of a nil *Turn do nothing.
+type Turn struct {
+	mu      sync.Mutex
+	start   time.Time
+	rec     Record
+	llm     time.Duration
+	tool    time.Duration
+	command bool
+	blocked bool
+}
+
+// NewContext returns a copy of ctx carrying a new Turn that starts now.
+func NewContext(ctx context.Context) (context.Context, *Turn) {
+	t := &Turn{start: time.Now()}
+	return context.WithValue(ctx, ctxKey{}, t), t
+}
+
+// FromContext returns the Turn carried by ctx, or nil.
+func FromContext(ctx context.Context) *Turn {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(ctxKey{}).(*Turn)
+	return t
+}
+
+// SetAgent records which agent handled the message.
+func (t *Turn) SetAgent(agentID, variant string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rec.AgentID = agentID
+	t.rec.Variant = variant
+}
+
+// AddLLMCall adds one provider call. The model of the last call is kept.
+func (t *Turn) AddLLMCall(model string, promptTokens, completionTokens int, elapsed time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rec.Model = model
+	t.rec.LLMCalls++
+	t.rec.PromptTokens += promptTokens
+	t.rec.CompletionTokens += completionTokens
+	t.llm += elapsed
+}
+
+// AddTools adds a batch of calls tools ran in parallel, taking elapsed.
+func (t *Turn) AddTools(calls int, elapsed time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rec.ToolCalls += calls
+	t.tool += elapsed
+}
+
+// MarkCommand records that a chat command answered the message.
+func (t *Turn) MarkCommand() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.command = true
+}
+
+// MarkBlocked records that a guardrail blocked the message or its reply.
+func (t *Turn) MarkBlocked() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.blocked = true
+}
+
+// Finish returns the record of the turn, ending now. An OutcomeOK turn
+// answered by a command is reported as OutcomeCommand, one stopped by a
+// guardrail as OutcomeBlocked.
+func (t *Turn) Finish(outcome string) Record {
+	if t == nil {
+		return Record{Outcome: outcome}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rec := t.rec
+	rec.Time = t.start
+	rec.Outcome = outcome
+	if outcome == OutcomeOK && t.command {
+		rec.Outcome = OutcomeCommand
+	}
+	if outcome == OutcomeOK && t.blocked {
+		rec.Outcome = OutcomeBlocked
+	}
+	rec.TotalMS = time.Since(t.start).Milliseconds()
+	rec.LLMMS = t.llm.Milliseconds()
+	rec.ToolMS = t.tool.Milliseconds()
+	return rec
+}
@@ -0,0 +1,190 @@
+// Package attachments extracts the text of PDFs, text, Markdown, and source
+// files attached to a message, so the model can read them without a tool
+// call.
+package attachments
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/knowledge"
+)
+
+// ErrTooLarge is returned for files over Limits.MaxBytes.
+var ErrTooLarge = errors.New("attachment too large")
+
+// ErrUnsupported is returned for files whose text cannot be extracted.
+var ErrUnsupported = errors.New("unsupported attachment type")
+
+// Limits bounds the text extracted from one file. Zero means no limit.
+type Limits struct {
+	MaxBytes int64
+	MaxPages int
+}
+
+// Document is the text of one attached file.
+type Document struct {
+	Name       string
+	Text       string
+	Pages      int // PDF pages read
+	TotalPages int // PDF pages in the file
+}
+
+var textExtensions = map[string]bool{
+	".txt": true, ".md": true, ".markdown": true, ".rst": true, ".log": true,
+	".csv": true, ".tsv": true, ".json": true, ".jsonl": true, ".yaml": true, ".yml": true,
+	".toml": true, ".ini": true, ".cfg": true, ".conf": true, ".env": true, ".xml": true,
+	".html": true, ".htm": true, ".css": true, ".scss": true, ".sql": true, ".proto": true,
+	".go": true, ".py": true, ".js": true, ".mjs": true, ".ts": true, ".tsx": true, ".jsx": true,
+	".java": true, ".kt": true, ".scala": true, ".c": true, ".h": true, ".cc": true, ".cpp": true,
+	".hpp": true, ".cs": true, ".rs": true, ".rb": true, ".php": true, ".swift": true, ".m": true,
+	".lua": true, ".pl": true, ".r": true, ".dart": true, ".zig": true, ".ex": true, ".exs": true,
+	".erl": true, ".hs": true, ".ml": true, ".vue": true, ".svelte": true, ".sh": true,
+	".bash": true, ".zsh": true, ".ps1": true, ".bat": true, ".gradle": true, ".tf": true,
+	".diff": true, ".patch": true,
+}
+
+var textNames = map[string]bool{
+	"makefile": true, "dockerfile": true, "readme": true, "license": true, "go.mod": true,
+}
+
+var textContentTypes = []string{
+	"text/", "application/json", "application/xml", "application/x-yaml", "application/yaml",
+	"application/toml", "application/javascript", "application/x-sh",
+}
+
+// IsPDF reports whether a file is a PDF by its name or content type.
+func IsPDF(filename, contentType string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".pdf") ||
+		strings.HasPrefix(strings.ToLower(contentType), "application/pdf")
+}
+
+// IsText reports whether a file is plain text, Markdown, or source code by
+// its name or content type.
+func IsText(filename, contentType string) bool {
+	name := strings.ToLower(filepath.Base(filename))
+	if textExtensions[filepath.Ext(name)] || textNames[name] {
+		return true
+	}
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range textContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Supported reports whether Extract can read a file.
+func Supported(filename, contentType string) bool {
+	return IsPDF(filename, contentType) || IsText(filename, contentType)
+}
+
+// Extract reads the text of the file at path. filename and contentType,
+// which may be empty, choose how; the name of the document is filename or
+// the base of path.
+func Extract(path, filename, contentType string, limits Limits) (Document, error) {
+	doc := Document{Name: filename}
+	if doc.Name == "" {
+		doc.Name = filepath.Base(path)
+	}
+	if !Supported(doc.Name, contentType) {
+		return doc, ErrUnsupported
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return doc, err
+	}
+	if limits.MaxBytes > 0 && info.Size() > limits.MaxBytes {
+		return doc, fmt.Errorf("%w: %d bytes, limit %d", ErrTooLarge, info.Size(), limits.MaxBytes)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return doc, err
+	}
+
+	if IsPDF(doc.Name, contentType) {
+		text, total, err := extractPDF(data, limits.MaxPages)
+		if err != nil {
+			return doc, fmt.Errorf("%s: %w", doc.Name, err)
+		}
+		doc.Text, doc.TotalPages = text, total
+		doc.Pages = total
+		if limits.MaxPages > 0 && total > limits.MaxPages {
+			doc.Pages = limits.MaxPages
+		}
+		return doc, nil
+	}
+
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return doc, fmt.Errorf("%w: %s is not UTF-8 text", ErrUnsupported, doc.Name)
+	}
+	doc.Text = string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	return doc, nil
+}
+
+// Format renders documents for the prompt, each split into chunks of about
+// chunkChars characters and all of them together cut at maxChunks chunks.
+// Cut text is replaced by a note, so the model knows it has not seen it.
+func Format(docs []Document, chunkChars, maxChunks int) string {
+	var out strings.Builder
+	left := maxChunks
+	for _, doc := range docs {
+		var chunks []string
+		if chunkChars > 0 {
+			chunks = knowledge.ChunkSize(doc.Text, chunkChars)
+		} else if s := strings.TrimSpace(doc.Text); s != "" {
+			chunks = []string{s}
+		}
+
+		if out.Len() > 0 {
+			out.WriteString("\n\n")
+		}
+		out.WriteString("[attachment: " + doc.Name)
+		if doc.TotalPages > 0 {
+			fmt.Fprintf(&out, ", %d pages", doc.TotalPages)
+			if doc.Pages < doc.TotalPages {
+				fmt.Fprintf(&out, ", first %d read", doc.Pages)
+			}
+		}
+		out.WriteString("]")
+		if len(chunks) == 0 {
+			out.WriteString("\n(no text found)")
+			continue
+		}
+
+		shown := len(chunks)
+		if maxChunks > 0 && shown > left {
+			shown = left
+		}
+		for i, chunk := range chunks[:shown] {
+			if len(chunks) > 1 {
+				fmt.Fprintf(&out, "\n--- part %d/%d ---", i+1, len(chunks))
+			}
+			out.WriteString("\n" + chunk)
+		}
+		left -= shown
+		if shown < len(chunks) {
+			fmt.Fprintf(&out, "\n[%d of %d parts of %s not included]", len(chunks)-shown, len(chunks), doc.Name)
+		}
+	}
+	return out.String()
+}
+
+var blankLinesRe = regexp.MustCompile(`\n{3,}`)
+
+// cleanText trims the lines of extracted text and collapses runs of blank
+// lines.
+func cleanText(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(blankLinesRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
@@ -0,0 +1,140 @@
+package attachments
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildPDF writes a PDF with one page per content stream, compressing the
+// streams when flate is set. Font F1 is a simple font; F2 is a composite font
+// with a ToUnicode map from 2-byte codes 0x0001-0x0003 to "Hé!".
+func buildPDF(t *testing.T, flate bool, pages ...string) []byte {
+	t.Helper()
+	var objects []string
+	add := func(body string) int {
+		objects = append(objects, body)
+		return len(objects)
+	}
+	stream := func(dict, data string) string {
+		if !flate {
+			return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+		}
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write([]byte(data))
+		w.Close()
+		return fmt.Sprintf("<< %s /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream", dict, buf.Len(), buf.String())
+	}
+
+	cmap := "/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n" +
+		"1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		"1 beginbfchar <0003> <0021> endbfchar\n" +
+		"1 beginbfrange <0001> <0002> [<0048> <00E9>] endbfrange\n" +
+		"endcmap CMapName currentdict /CMap defineresource pop end end"
+	toUnicode := add(stream("", cmap))
+	f1 := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	f2 := add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /X /ToUnicode %d 0 R >>", toUnicode))
+
+	pagesNum := len(objects) + 2*len(pages) + 1
+	var kids []string
+	for _, content := range pages {
+		c := add(stream("", content))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /Contents %d 0 R >>", pagesNum, c))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	add(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> >>",
+		strings.Join(kids, " "), len(pages), f1, f2))
+	catalog := add(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesNum))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	for i, body := range objects {
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Root %d 0 R /Size %d >>\n%%%%EOF\n", catalog, len(objects)+1)
+	return out.Bytes()
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtract_PDF(t *testing.T) {
+	pdf := buildPDF(t, true,
+		"BT /F1 12 Tf 72 720 Td (Quarterly report) Tj 0 -14 Td [(Revenue ) -300 (grew \\(a lot\\))] TJ ET",
+		"BT /F2 12 Tf 72 720 Td <000100020003> Tj ET",
+		"BT /F1 12 Tf 72 720 Td (Appendix) Tj ET",
+	)
+	path := writeFile(t, "report.pdf", pdf)
+
+	doc, err := Extract(path, "", "", Limits{MaxPages: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Quarterly report\nRevenue grew (a lot)\n\nHé!"
+	if doc.Text != want {
+		t.Errorf("Text = %q, want %q", doc.Text, want)
+	}
+	if doc.Name != "report.pdf" || doc.Pages != 2 || doc.TotalPages != 3 {
+		t.Errorf("doc = %+v, want report.pdf with 2 of 3 pages", doc)
+	}
+
+	plain := writeFile(t, "upload", buildPDF(t, false, "BT /F1 12 Tf (Uncompressed) Tj ET"))
+	doc, err = Extract(plain, "scan.pdf", "application/pdf", Limits{})
+	if err != nil || doc.Text != "Uncompressed" {
+		t.Errorf("Extract(uncompressed) = %q, %v", doc.Text, err)
+	}
+
+	if _, err := Extract(path, "", "", Limits{MaxBytes: 10}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Extract over MaxBytes: err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestExtract_TextFiles(t *testing.T) {
+	path := writeFile(t, "main.go", []byte("\xef\xbb\xbfpackage main\n"))
+	doc, err := Extract(path, "", "", Limits{})
+	if err != nil || doc.Text != "package main\n" {
+		t.Errorf("Extract(main.go) = %q, %v", doc.Text, err)
+	}
+
+	notes := writeFile(t, "file_123", []byte("# Notes"))
+	if doc, err := Extract(notes, "notes", "text/markdown", Limits{}); err != nil || doc.Text != "# Notes" {
+		t.Errorf("Extract(text/markdown) = %q, %v", doc.Text, err)
+	}
+
+	binary := writeFile(t, "data.txt", []byte{'a', 0, 'b'})
+	if _, err := Extract(binary, "", "", Limits{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Extract(binary .txt): err = %v, want ErrUnsupported", err)
+	}
+	image := writeFile(t, "photo.jpg", []byte{0xff, 0xd8})
+	if _, err := Extract(image, "", "image/jpeg", Limits{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Extract(photo.jpg): err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	docs := []Document{
+		{Name: "a.pdf", Text: "one\n\ntwo\n\nthree", Pages: 1, TotalPages: 4},
+		{Name: "b.txt", Text: "four"},
+		{Name: "empty.txt"},
+	}
+	got := Format(docs, 5, 2)
+	want := "[attachment: a.pdf, 4 pages, first 1 read]\n" +
+		"--- part 1/3 ---\none\n--- part 2/3 ---\ntwo\n[1 of 3 parts of a.pdf not included]\n\n" +
+		"[attachment: b.txt]\n[1 of 1 parts of b.txt not included]\n\n" +
+		"[attachment: empty.txt]\n(no text found)"
+	if got != want {
+		t.Errorf("Format =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,833 @@
+package attachments
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxStreamBytes caps a decompressed PDF stream, so a small file cannot
+// inflate without bound.
+const maxStreamBytes = 64 << 20
+
+var errEncryptedPDF = errors.New("encrypted PDF")
+
+// The values of PDF objects: float64, bool, nil, and the types below.
+type (
+	pdfName    string
+	pdfString  []byte
+	pdfKeyword string
+	pdfArray   []any
+	pdfDict    map[string]any
+	pdfRef     struct{ num, gen int }
+)
+
+type pdfObject struct {
+	value  any
+	stream []byte // raw, still encoded
+}
+
+// pdfFile is a parsed PDF. Objects are found by scanning the file rather
+// than by its cross-reference table, so files with a broken table still read.
+type pdfFile struct {
+	objects map[int]pdfObject
+	fonts   map[pdfRef]*pdfFont
+}
+
+type pdfPage struct {
+	contents  any
+	resources pdfDict
+}
+
+var objHeaderRe = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// extractPDF returns the text of the first maxPages pages of a PDF, or of all
+// of them when maxPages is 0, and the number of pages it has.
+func extractPDF(data []byte, maxPages int) (string, int, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-")) {
+		return "", 0, errors.New("not a PDF file")
+	}
+	f := parsePDF(data)
+	for _, obj := range f.objects {
+		if d, ok := obj.value.(pdfDict); ok && d["Encrypt"] != nil {
+			return "", 0, errEncryptedPDF
+		}
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) && trailerHasEncrypt(data) {
+		return "", 0, errEncryptedPDF
+	}
+
+	pages := f.pages()
+	if len(pages) == 0 {
+		return "", 0, errors.New("no pages found")
+	}
+	total := len(pages)
+	if maxPages > 0 && len(pages) > maxPages {
+		pages = pages[:maxPages]
+	}
+
+	var out strings.Builder
+	for i, page := range pages {
+		if i > 0 {
+			out.WriteString("\n\n")
+		}
+		out.WriteString(f.pageText(page))
+	}
+	return cleanText(out.String()), total, nil
+}
+
+func trailerHasEncrypt(data []byte) bool {
+	i := bytes.LastIndex(data, []byte("trailer"))
+	if i < 0 {
+		return false
+	}
+	p := &pdfParser{data: data, pos: i + len("trailer")}
+	d, ok := p.value().(pdfDict)
+	return ok && d["Encrypt"] != nil
+}
+
+func parsePDF(data []byte) *pdfFile {
+	f := &pdfFile{objects: make(map[int]pdfObject), fonts: make(map[pdfRef]*pdfFont)}
+	for pos := 0; pos < len(data); {
+		loc := objHeaderRe.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		p := &pdfParser{data: data, pos: pos + loc[1]}
+		obj := pdfObject{value: p.value()}
+		pos = p.pos
+		if p.keyword() == "stream" {
+			start := p.pos
+			if start < len(data) && data[start] == '\r' {
+				start++
+			}
+			if start < len(data) && data[start] == '\n' {
+				start++
+			}
+			end := streamEnd(data, start, obj.value)
+			obj.stream = data[start:end]
+			pos = end
+		}
+		// Later objects are incremental updates of earlier ones.
+		f.objects[num] = obj
+	}
+	f.expandObjectStreams()
+	return f
+}
+
+// streamEnd finds where a stream starting at start ends, trusting a direct
+// /Length only when endstream follows it.
+func streamEnd(data []byte, start int, dict any) int {
+	if d, ok := dict.(pdfDict); ok {
+		if n, ok := d["Length"].(float64); ok {
+			end := start + int(n)
+			if end >= start && end <= len(data) {
+				rest := bytes.TrimLeft(data[end:min(end+32, len(data))], " \t\r\n")
+				if bytes.HasPrefix(rest, []byte("endstream")) {
+					return end
+				}
+			}
+		}
+	}
+	if i := bytes.Index(data[start:], []byte("endstream")); i >= 0 {
+		return start + i
+	}
+	return len(data)
+}
+
+// expandObjectStreams adds the objects compressed into object streams.
+func (f *pdfFile) expandObjectStreams() {
+	for _, obj := range f.objects {
+		d, ok := obj.value.(pdfDict)
+		if !ok || d["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		data, err := f.decodeStream(obj)
+		if err != nil {
+			continue
+		}
+		n, _ := f.resolve(d["N"]).(float64)
+		first, _ := f.resolve(d["First"]).(float64)
+		header := &pdfParser{data: data}
+		for i := 0; i < int(n); i++ {
+			num, ok1 := header.value().(float64)
+			offset, ok2 := header.value().(float64)
+			if !ok1 || !ok2 {
+				break
+			}
+			if _, defined := f.objects[int(num)]; defined {
+				continue
+			}
+			at := int(first) + int(offset)
+			if at < 0 || at >= len(data) {
+				continue
+			}
+			p := &pdfParser{data: data, pos: at}
+			f.objects[int(num)] = pdfObject{value: p.value()}
+		}
+	}
+}
+
+func (f *pdfFile) resolve(v any) any {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = f.objects[ref.num].value
+	}
+	return nil
+}
+
+func (f *pdfFile) dict(v any) pdfDict {
+	d, _ := f.resolve(v).(pdfDict)
+	return d
+}
+
+// decodeStream undoes the filters of a stream. Only FlateDecode is
+// supported, which is what text content uses in practice.
+func (f *pdfFile) decodeStream(obj pdfObject) ([]byte, error) {
+	d, _ := obj.value.(pdfDict)
+	var filters []any
+	switch filter := f.resolve(d["Filter"]).(type) {
+	case pdfName:
+		filters = []any{filter}
+	case pdfArray:
+		filters = filter
+	}
+	data := obj.stream
+	for _, filter := range filters {
+		switch f.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			// Keep what inflated before a truncated or damaged end.
+			decoded, err := io.ReadAll(io.LimitReader(r, maxStreamBytes))
+			if err != nil && len(decoded) == 0 {
+				return nil, err
+			}
+			data = decoded
+		default:
+			return nil, fmt.Errorf("unsupported filter %v", filter)
+		}
+	}
+	return data, nil
+}
+
+func (f *pdfFile) streamOf(v any) ([]byte, bool) {
+	if ref, ok := v.(pdfRef); ok {
+		obj, found := f.objects[ref.num]
+		if !found || obj.stream == nil {
+			return nil, false
+		}
+		data, err := f.decodeStream(obj)
+		return data, err == nil
+	}
+	return nil, false
+}
+
+// pages lists the pages in reading order, from the page tree of the catalog
+// or, failing that, every page object by number.
+func (f *pdfFile) pages() []pdfPage {
+	var pages []pdfPage
+	seen := make(map[int]bool)
+	var walk func(v any, resources pdfDict, depth int)
+	walk = func(v any, resources pdfDict, depth int) {
+		if ref, ok := v.(pdfRef); ok {
+			if seen[ref.num] {
+				return
+			}
+			seen[ref.num] = true
+		}
+		node := f.dict(v)
+		if node == nil || depth > 64 {
+			return
+		}
+		if r := f.dict(node["Resources"]); r != nil {
+			resources = r
+		}
+		if kids, ok := f.resolve(node["Kids"]).(pdfArray); ok {
+			for _, kid := range kids {
+				walk(kid, resources, depth+1)
+			}
+			return
+		}
+		if node["Type"] == pdfName("Page") || node["Contents"] != nil {
+			pages = append(pages, pdfPage{contents: node["Contents"], resources: resources})
+		}
+	}
+
+	nums := make([]int, 0, len(f.objects))
+	for num := range f.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if d, ok := f.objects[num].value.(pdfDict); ok && d["Type"] == pdfName("Catalog") {
+			walk(d["Pages"], nil, 0)
+			if len(pages) > 0 {
+				return pages
+			}
+		}
+	}
+	for _, num := range nums {
+		if d, ok := f.objects[num].value.(pdfDict); ok && d["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{contents: d["Contents"], resources: f.dict(d["Resources"])})
+		}
+	}
+	return pages
+}
+
+func (f *pdfFile) pageText(page pdfPage) string {
+	var content [][]byte
+	switch c := f.resolve(page.contents).(type) {
+	case pdfArray:
+		for _, part := range c {
+			if data, ok := f.streamOf(part); ok {
+				content = append(content, data)
+			}
+		}
+	default:
+		if data, ok := f.streamOf(page.contents); ok {
+			content = append(content, data)
+		}
+	}
+
+	fonts := make(map[string]*pdfFont)
+	for name, v := range f.dict(page.resources["Font"]) {
+		fonts[name] = f.font(v)
+	}
+	return contentText(bytes.Join(content, []byte("\n")), fonts)
+}
+
+// pdfFont maps the codes of a font to text, through its ToUnicode map when it
+// has one and as Latin-1 otherwise. Composite fonts without one cannot be
+// read and give no text.
+type pdfFont struct {
+	codeLen   int
+	toUnicode map[uint32]string
+}
+
+func (f *pdfFile) font(v any) *pdfFont {
+	ref, isRef := v.(pdfRef)
+	if isRef {
+		if font, ok := f.fonts[ref]; ok {
+			return font
+		}
+	}
+	d := f.dict(v)
+	font := &pdfFont{codeLen: 1}
+	if d["Subtype"] == pdfName("Type0") {
+		font.codeLen = 2
+	}
+	if data, ok := f.streamOf(d["ToUnicode"]); ok {
+		font.parseCMap(data)
+	}
+	if isRef {
+		f.fonts[ref] = font
+	}
+	return font
+}
+
+func (font *pdfFont) parseCMap(data []byte) {
+	font.toUnicode = make(map[uint32]string)
+	p := &pdfParser{data: data}
+	var operands []any
+	for {
+		v, ok := p.next()
+		if !ok {
+			return
+		}
+		kw, isKeyword := v.(pdfKeyword)
+		if !isKeyword {
+			operands = append(operands, v)
+			continue
+		}
+		switch kw {
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if lo, ok := operands[0].(pdfString); ok && len(lo) > 0 {
+					font.codeLen = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					font.toUnicode[codeOf(src)] = utf16BE(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || codeOf(hi) < codeOf(lo) || codeOf(hi)-codeOf(lo) > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					base := []rune(utf16BE(dst))
+					if len(base) == 0 {
+						continue
+					}
+					for c := codeOf(lo); c <= codeOf(hi); c++ {
+						r := append([]rune(nil), base...)
+						r[len(r)-1] += rune(c - codeOf(lo))
+						font.toUnicode[c] = string(r)
+					}
+				case pdfArray:
+					for j, d := range dst {
+						if s, ok := d.(pdfString); ok {
+							font.toUnicode[codeOf(lo)+uint32(j)] = utf16BE(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+func (font *pdfFont) decode(s pdfString) string {
+	if font == nil {
+		font = &pdfFont{codeLen: 1}
+	}
+	var out strings.Builder
+	for i := 0; i+font.codeLen <= len(s); i += font.codeLen {
+		code := codeOf(s[i : i+font.codeLen])
+		if font.toUnicode != nil {
+			if text, ok := font.toUnicode[code]; ok {
+				out.WriteString(text)
+				continue
+			}
+		}
+		if font.codeLen == 1 {
+			out.WriteRune(rune(code))
+		}
+	}
+	return out.String()
+}
+
+func codeOf(b []byte) uint32 {
+	var c uint32
+	for _, x := range b {
+		c = c<<8 | uint32(x)
+	}
+	return c
+}
+
+func utf16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// contentText runs the text operators of a content stream. Lines start where
+// the text moves down; wide gaps within a line become spaces.
+func contentText(content []byte, fonts map[string]*pdfFont) string {
+	var out strings.Builder
+	var font *pdfFont
+	var operands []any
+	lineY, haveY := 0.0, false
+
+	newline := func() {
+		if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+			out.WriteByte('\n')
+		}
+	}
+	space := func() {
+		if s := out.String(); s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+			out.WriteByte(' ')
+		}
+	}
+	moveTo := func(y float64) {
+		if haveY && y != lineY {
+			newline()
+		}
+		lineY, haveY = y, true
+	}
+	number := func(i int) float64 {
+		if i < 0 || i >= len(operands) {
+			return 0
+		}
+		n, _ := operands[i].(float64)
+		return n
+	}
+
+	p := &pdfParser{data: content}
+	for {
+		v, ok := p.next()
+		if !ok {
+			break
+		}
+		op, isOp := v.(pdfKeyword)
+		if !isOp {
+			operands = append(operands, v)
+			continue
+		}
+		switch op {
+		case "BI":
+			p.skipInlineImage()
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					font = fonts[string(name)]
+				}
+			}
+		case "Td", "TD":
+			if ty := number(1); ty != 0 {
+				newline()
+				lineY += ty
+			} else if number(0) > 0 {
+				space()
+			}
+		case "Tm":
+			moveTo(number(5))
+		case "T*":
+			newline()
+		case "Tj":
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					out.WriteString(font.decode(s))
+				}
+			}
+		case "'", "\"":
+			newline()
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					out.WriteString(font.decode(s))
+				}
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				parts, _ := operands[len(operands)-1].(pdfArray)
+				for _, part := range parts {
+					switch part := part.(type) {
+					case pdfString:
+						out.WriteString(font.decode(part))
+					case float64:
+						// Offsets are in thousandths of a unit of text space.
+						if part < -200 {
+							space()
+						}
+					}
+				}
+			}
+		case "ET":
+			space()
+		}
+		operands = operands[:0]
+	}
+	return out.String()
+}
+
+// pdfParser reads PDF objects and content stream tokens.
+type pdfParser struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n' || b == '\f' || b == 0
+}
+
+func isPDFDelimiter(b byte) bool {
+	return strings.IndexByte("()<>[]{}/%", b) >= 0
+}
+
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch b := p.data[p.pos]; {
+		case isPDFSpace(b):
+			p.pos++
+		case b == '%':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// keyword reads a keyword if one comes next, and leaves the position alone
+// otherwise.
+func (p *pdfParser) keyword() pdfKeyword {
+	save := p.pos
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.data) && !isPDFSpace(p.data[p.pos]) && !isPDFDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	word := string(p.data[start:p.pos])
+	if word == "" || isNumber(word) {
+		p.pos = save
+		return ""
+	}
+	return pdfKeyword(word)
+}
+
+// value reads one object, joining "num gen R" into a reference.
+func (p *pdfParser) value() any {
+	v, _ := p.next()
+	n, ok := v.(float64)
+	if !ok {
+		return v
+	}
+	save := p.pos
+	if gen, ok := p.peekNumber(); ok {
+		if p.keyword() == "R" {
+			return pdfRef{num: int(n), gen: int(gen)}
+		}
+	}
+	p.pos = save
+	return n
+}
+
+func (p *pdfParser) peekNumber() (float64, bool) {
+	v, ok := p.next()
+	n, isNum := v.(float64)
+	return n, ok && isNum
+}
+
+// next reads the next token: a complete object, or a keyword. It reports
+// false at the end of the data.
+func (p *pdfParser) next() (any, bool) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, false
+	}
+	switch b := p.data[p.pos]; b {
+	case '/':
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.data) && !isPDFSpace(p.data[p.pos]) && !isPDFDelimiter(p.data[p.pos]) {
+			p.pos++
+		}
+		return pdfName(unescapeName(p.data[start:p.pos])), true
+	case '(':
+		return p.literalString(), true
+	case '<':
+		if p.pos+1 < len(p.data) && p.data[p.pos+1] == '<' {
+			p.pos += 2
+			return p.dictionary(), true
+		}
+		return p.hexString(), true
+	case '[':
+		p.pos++
+		var arr pdfArray
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.data) {
+				return arr, true
+			}
+			if p.data[p.pos] == ']' {
+				p.pos++
+				return arr, true
+			}
+			arr = append(arr, p.value())
+		}
+	case ']', '>', ')', '{', '}':
+		p.pos++
+		return p.next()
+	}
+
+	start := p.pos
+	for p.pos < len(p.data) && !isPDFSpace(p.data[p.pos]) && !isPDFDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	word := string(p.data[start:p.pos])
+	if isNumber(word) {
+		n, _ := strconv.ParseFloat(word, 64)
+		return n, true
+	}
+	switch word {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	case "null":
+		return nil, true
+	}
+	return pdfKeyword(word), true
+}
+
+func (p *pdfParser) dictionary() pdfDict {
+	d := make(pdfDict)
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return d
+		}
+		if bytes.HasPrefix(p.data[p.pos:], []byte(">>")) {
+			p.pos += 2
+			return d
+		}
+		key, ok := p.next()
+		if !ok {
+			return d
+		}
+		name, isName := key.(pdfName)
+		if !isName {
+			continue
+		}
+		d[string(name)] = p.value()
+	}
+}
+
+func (p *pdfParser) literalString() pdfString {
+	p.pos++ // (
+	var out []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		b := p.data[p.pos]
+		p.pos++
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return out
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				return out
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				b = '\n'
+			case 'r':
+				b = '\r'
+			case 't':
+				b = '\t'
+			case 'b':
+				b = '\b'
+			case 'f':
+				b = '\f'
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						n = n*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					b = byte(n)
+				} else {
+					b = e
+				}
+			}
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func (p *pdfParser) hexString() pdfString {
+	p.pos++ // <
+	var digits []byte
+	for p.pos < len(p.data) && p.data[p.pos] != '>' {
+		if v, ok := hexValue(p.data[p.pos]); ok {
+			digits = append(digits, v)
+		}
+		p.pos++
+	}
+	p.pos++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, 0)
+	}
+	out := make(pdfString, len(digits)/2)
+	for i := range out {
+		out[i] = digits[2*i]<<4 | digits[2*i+1]
+	}
+	return out
+}
+
+// skipInlineImage skips the data of an inline image, up to its EI.
+func (p *pdfParser) skipInlineImage() {
+	if i := bytes.Index(p.data[p.pos:], []byte("ID")); i >= 0 {
+		p.pos += i + 2
+	}
+	for p.pos < len(p.data) {
+		i := bytes.Index(p.data[p.pos:], []byte("EI"))
+		if i < 0 {
+			p.pos = len(p.data)
+			return
+		}
+		p.pos += i + 2
+		if p.pos >= 3 && isPDFSpace(p.data[p.pos-3]) && (p.pos >= len(p.data) || isPDFSpace(p.data[p.pos])) {
+			return
+		}
+	}
+}
+
+func hexValue(b byte) (byte, bool) {
+	switch {
+	case '0' <= b && b <= '9':
+		return b - '0', true
+	case 'a' <= b && b <= 'f':
+		return b - 'a' + 10, true
+	case 'A' <= b && b <= 'F':
+		return b - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func unescapeName(b []byte) string {
+	if bytes.IndexByte(b, '#') < 0 {
+		return string(b)
+	}
+	var out []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] == '#' && i+2 < len(b) {
+			hi, ok1 := hexValue(b[i+1])
+			lo, ok2 := hexValue(b[i+2])
+			if ok1 && ok2 {
+				out = append(out, hi<<4|lo)
+				i += 2
+				continue
+			}
+		}
+		out = append(out, b[i])
+	}
+	return string(out)
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	digits := 0
+	for i, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == '.':
+		case (c == '-' || c == '+') && i == 0:
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
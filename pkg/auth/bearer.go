@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// BearerTokenMatches reports whether r carries "Authorization: Bearer <token>"
+// for one of tokens. Empty tokens match nothing, so an API given no token
+// refuses every request.
+func BearerTokenMatches(r *http.Request, tokens ...string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	matched := false
+	for _, token := range tokens {
+		if token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+			matched = true
+		}
+	}
+	return matched
+}
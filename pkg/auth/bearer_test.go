@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBearerTokenMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		tokens []string
+		want   bool
+	}{
+		{"own token", "Bearer secret", []string{"secret", "admin"}, true},
+		{"admin token", "Bearer admin", []string{"secret", "admin"}, true},
+		{"wrong token", "Bearer other", []string{"secret", "admin"}, false},
+		{"no scheme", "secret", []string{"secret"}, false},
+		{"missing header", "", []string{"secret"}, false},
+		{"no tokens", "Bearer ", nil, false},
+		{"empty token", "Bearer ", []string{""}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if got := BearerTokenMatches(r, tt.tokens...); got != tt.want {
+				t.Errorf("BearerTokenMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
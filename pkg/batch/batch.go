@@ -0,0 +1,199 @@
+// Package batch runs a file of prompts through the agent, for evaluation
+// suites and bulk jobs that should take the same code paths as the live bot.
+//
+// The input is JSON Lines, one prompt per line:
+//
+//	{"id": "q1", "prompt": "What is the capital of France?"}
+//	{"id": "q2", "prompt": "And of Spain?", "session": "geo"}
+//
+// Prompts that name the same session run one after another in file order
+// and share its history; other prompts each get a fresh session. Results are
+// written in input order, one JSON object per line.
+package batch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prompt is one line of the input.
+type Prompt struct {
+	ID      string `json:"id,omitempty"` // defaults to the line number
+	Prompt  string `json:"prompt"`
+	Session string `json:"session,omitempty"`
+}
+
+// Result is one line of the output.
+type Result struct {
+	ID         string `json:"id"`
+	Response   string `json:"response,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Processor answers prompt in session. Prompts without a session get one
+// of their own, "prompt-" followed by their line number.
+type Processor func(ctx context.Context, session, prompt string) (string, error)
+
+// Options controls a run.
+type Options struct {
+	Concurrency int           // sessions answered at the same time; < 1 means 1
+	Timeout     time.Duration // per prompt; 0 means none
+}
+
+// Summary counts the prompts of a run.
+type Summary struct {
+	Prompts int
+	Failed  int
+}
+
+// maxLineSize bounds a single prompt line.
+const maxLineSize = 4 << 20
+
+// Read parses the prompts of r, skipping blank lines.
+func Read(r io.Reader) ([]Prompt, error) {
+	var prompts []Prompt
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		var p Prompt
+		if err := json.Unmarshal([]byte(text), &p); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if strings.TrimSpace(p.Prompt) == "" {
+			return nil, fmt.Errorf("line %d: prompt is empty", line)
+		}
+		if p.ID == "" {
+			p.ID = strconv.Itoa(line)
+		}
+		if p.Session == "" {
+			p.Session = "prompt-" + strconv.Itoa(line)
+		}
+		prompts = append(prompts, p)
+	}
+	return prompts, sc.Err()
+}
+
+// Run answers prompts with process and writes their results to out in
+// input order as they become available. A failed prompt is recorded in its
+// result; only a failure to write out, or ctx ending, stops the run.
+func Run(ctx context.Context, prompts []Prompt, process Processor, opts Options, out io.Writer) (Summary, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Prompts of one session stay in order on one worker
+	var sessions [][]int
+	index := make(map[string]int)
+	for i, p := range prompts {
+		n, ok := index[p.Session]
+		if !ok {
+			n = len(sessions)
+			index[p.Session] = n
+			sessions = append(sessions, nil)
+		}
+		sessions[n] = append(sessions[n], i)
+	}
+
+	w := &writer{enc: json.NewEncoder(out), results: make([]*Result, len(prompts)), cancel: cancel}
+	queue := make(chan []int)
+	var wg sync.WaitGroup
+	for range min(max(opts.Concurrency, 1), max(len(sessions), 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for session := range queue {
+				for _, i := range session {
+					w.put(i, answer(ctx, prompts[i], process, opts.Timeout))
+				}
+			}
+		}()
+	}
+feed:
+	for _, session := range sessions {
+		select {
+		case queue <- session:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.summary, w.err
+	}
+	return w.summary, ctx.Err()
+}
+
+func answer(ctx context.Context, p Prompt, process Processor, timeout time.Duration) Result {
+	res := Result{ID: p.ID}
+	if err := ctx.Err(); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	response, err := process(ctx, p.Session, p.Prompt)
+	res.DurationMS = time.Since(start).Milliseconds()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		res.Error = fmt.Sprintf("timed out after %s", timeout)
+	case err != nil:
+		res.Error = err.Error()
+	default:
+		res.Response = response
+	}
+	return res
+}
+
+// writer writes results in input order.
+type writer struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	results []*Result
+	next    int
+	summary Summary
+	err     error
+	cancel  context.CancelFunc
+}
+
+func (w *writer) put(i int, res Result) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.results[i] = &res
+	for w.next < len(w.results) && w.results[w.next] != nil {
+		r := w.results[w.next]
+		w.results[w.next] = nil
+		w.next++
+		if w.err != nil {
+			continue
+		}
+		if err := w.enc.Encode(r); err != nil {
+			w.err = err
+			w.cancel()
+			continue
+		}
+		w.summary.Prompts++
+		if r.Error != "" {
+			w.summary.Failed++
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRead(t *testing.T) {
+	prompts, err := Read(strings.NewReader(`{"id": "a", "prompt": "hi"}
+
+{"prompt": "again", "session": "s"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 2 || prompts[0].ID != "a" || prompts[0].Session != "prompt-1" ||
+		prompts[1].ID != "3" || prompts[1].Session != "s" {
+		t.Errorf("prompts = %+v", prompts)
+	}
+	if _, err := Read(strings.NewReader(`{"id": "a"}`)); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("empty prompt: error = %v", err)
+	}
+	if _, err := Read(strings.NewReader("{}\nnot json")); err == nil {
+		t.Error("bad JSON was accepted")
+	}
+}
+
+func TestRun(t *testing.T) {
+	prompts := []Prompt{
+		{ID: "1", Prompt: "slow", Session: "a"},
+		{ID: "2", Prompt: "fast", Session: "b"},
+		{ID: "3", Prompt: "fail", Session: "c"},
+		{ID: "4", Prompt: "follow-up", Session: "a"},
+	}
+	var (
+		mu      sync.Mutex
+		order   []string
+		running atomic.Int32
+		peak    atomic.Int32
+	)
+	process := func(ctx context.Context, session, prompt string) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		if n > peak.Load() {
+			peak.Store(n)
+		}
+		if prompt == "slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		mu.Lock()
+		order = append(order, session+":"+prompt)
+		mu.Unlock()
+		if prompt == "fail" {
+			return "", errors.New("provider down")
+		}
+		return "re: " + prompt, nil
+	}
+
+	var out bytes.Buffer
+	summary, err := Run(context.Background(), prompts, process, Options{Concurrency: 2}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != (Summary{Prompts: 4, Failed: 1}) {
+		t.Errorf("summary = %+v", summary)
+	}
+	if peak.Load() > 2 {
+		t.Errorf("%d prompts ran at once, want at most 2", peak.Load())
+	}
+
+	var results []Result
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var r Result
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, r)
+	}
+	if len(results) != 4 {
+		t.Fatalf("results = %+v", results)
+	}
+	for i, r := range results {
+		if r.ID != prompts[i].ID {
+			t.Errorf("result %d is %q, want input order", i, r.ID)
+		}
+	}
+	if results[2].Error != "provider down" || results[3].Response != "re: follow-up" {
+		t.Errorf("results = %+v", results)
+	}
+
+	// The follow-up waited for the first prompt of its session
+	mu.Lock()
+	defer mu.Unlock()
+	first, second := -1, -1
+	for i, o := range order {
+		switch o {
+		case "a:slow":
+			first = i
+		case "a:follow-up":
+			second = i
+		}
+	}
+	if first < 0 || second < first {
+		t.Errorf("session order = %v", order)
+	}
+}
+
+func TestRun_Timeout(t *testing.T) {
+	process := func(ctx context.Context, session, prompt string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	var out bytes.Buffer
+	summary, err := Run(context.Background(), []Prompt{{ID: "1", Prompt: "hang", Session: "s"}}, process,
+		Options{Timeout: 10 * time.Millisecond}, &out)
+	if err != nil || summary.Failed != 1 || !strings.Contains(out.String(), "timed out after 10ms") {
+		t.Errorf("Run = %+v, %v; output %s", summary, err, out.String())
+	}
+}
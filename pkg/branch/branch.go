@@ -0,0 +1,397 @@
+// Package branch lets a chat fork its conversation from an earlier message
+// and switch between the resulting branches.
+//
+// Every branch is an ordinary session whose key is the chat's base session
+// key plus a branch suffix; the main branch uses the base key itself. To find
+// where a message sits in a session, the ID of each user message is recorded
+// with its position in the history when the turn starts.
+//
+// A reply regenerated with 🔁 keeps the replies it replaced as variants of
+// its turn, and the chat can switch the session to any of them.
+package branch
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Main is the name of the branch every conversation starts on.
+const Main = "main"
+
+const (
+	keySep = "#branch:"
+	// maxMarks bounds the recorded user messages per chat. Older ones can no
+	// longer be branched from.
+	maxMarks = 1000
+)
+
+var (
+	// ErrUnknownMessage means the message was never seen in this chat.
+	ErrUnknownMessage = errors.New("that message is not part of this conversation")
+	// ErrExists means a branch with the requested name already exists.
+	ErrExists = errors.New("a branch with that name already exists")
+	// ErrUnknownBranch means no branch has the requested name.
+	ErrUnknownBranch = errors.New("no branch with that name")
+
+	namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+)
+
+// Key returns the session key of a branch of the base session.
+func Key(base, name string) string {
+	if name == "" || name == Main {
+		return base
+	}
+	return base + keySep + name
+}
+
+// BaseKey returns the base session key of a branch session key.
+func BaseKey(key string) string {
+	base, _, _ := strings.Cut(key, keySep)
+	return base
+}
+
+// NameOf returns the branch name of a session key.
+func NameOf(key string) string {
+	if _, name, ok := strings.Cut(key, keySep); ok {
+		return name
+	}
+	return Main
+}
+
+// ValidName reports whether name can be used for a new branch.
+func ValidName(name string) bool {
+	return name != Main && namePattern.MatchString(name)
+}
+
+// Branch describes one fork of a conversation.
+type Branch struct {
+	Name    string    `json:"name"`
+	Parent  string    `json:"parent"` // branch the fork was taken from
+	From    string    `json:"from"`   // message ID the fork was taken at
+	Created time.Time `json:"created"`
+}
+
+// Mark records where a user message starts its turn in a session.
+type Mark struct {
+	Session   string `json:"session"`
+	MessageID string `json:"message_id"`
+	Index     int    `json:"index"`
+	Hash      string `json:"hash"` // HashText of the message, to relocate it after compaction
+}
+
+// HashText returns the fingerprint stored in marks for a user message.
+func HashText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+type chatState struct {
+	Active   string               `json:"active,omitempty"`
+	Branches []Branch             `json:"branches,omitempty"`
+	Variants map[string]*Variants `json:"variants,omitempty"` // by session key
+}
+
+// Store keeps the branches, reply variants, and message marks of every chat,
+// one pair of files per chat. It is safe for concurrent use; a nil *Store has only main
+// branches.
+type Store struct {
+	dir string
+
+	mu    sync.Mutex
+	chats map[string]*chatState
+}
+
+// Dir returns the branch state directory for a workspace.
+func Dir(workspace string) string {
+	return filepath.Join(workspace, "state", "branches")
+}
+
+// NewStore creates a store that keeps its files in dir.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, chats: make(map[string]*chatState)}
+}
+
+func fileBase(base string) string {
+	return strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(base)
+}
+
+func (s *Store) statePath(base string) string {
+	return filepath.Join(s.dir, fileBase(base)+".json")
+}
+
+func (s *Store) marksPath(base string) string {
+	return filepath.Join(s.dir, fileBase(base)+".marks.jsonl")
+}
+
+// chatLocked returns the cached state of a chat, loading it on first use.
+func (s *Store) chatLocked(base string) *chatState {
+	if c, ok := s.chats[base]; ok {
+		return c
+	}
+	c := &chatState{}
+	if data, err := os.ReadFile(s.statePath(base)); err == nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			logger.WarnCF("branch", "Ignoring unreadable branch state", map[string]any{
+				"session": base,
+				"error":   err.Error(),
+			})
+			c = &chatState{}
+		}
+	}
+	s.chats[base] = c
+	return c
+}
+
+func (s *Store) saveLocked(base string, c *chatState) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(s.statePath(base), data, 0o600)
+}
+
+// ActiveKey returns the session key of the active branch of base.
+func (s *Store) ActiveKey(base string) string {
+	if s == nil {
+		return base
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Key(base, s.chatLocked(base).Active)
+}
+
+// Branches returns the branches forked from base, oldest first, and the name
+// of the active one.
+func (s *Store) Branches(base string) (active string, branches []Branch) {
+	if s == nil {
+		return Main, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.chatLocked(base)
+	active = c.Active
+	if active == "" {
+		active = Main
+	}
+	return active, append([]Branch(nil), c.Branches...)
+}
+
+// Add records a new branch of base and makes it active.
+func (s *Store) Add(base string, b Branch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.chatLocked(base)
+	for _, existing := range c.Branches {
+		if existing.Name == b.Name {
+			return ErrExists
+		}
+	}
+	c.Branches = append(c.Branches, b)
+	c.Active = b.Name
+	return s.saveLocked(base, c)
+}
+
+// Has reports whether base has a branch called name.
+func (s *Store) Has(base, name string) bool {
+	if name == Main {
+		return true
+	}
+	_, branches := s.Branches(base)
+	for _, b := range branches {
+		if b.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Switch makes name the active branch of base.
+func (s *Store) Switch(base, name string) error {
+	if !s.Has(base, name) {
+		return ErrUnknownBranch
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.chatLocked(base)
+	if name == Main {
+		name = ""
+	}
+	if c.Active == name {
+		return nil
+	}
+	c.Active = name
+	return s.saveLocked(base, c)
+}
+
+// Mark records that the user message messageID starts at index in the
+// history of session.
+func (s *Store) Mark(session, messageID string, index int, text string) error {
+	if s == nil || messageID == "" {
+		return nil
+	}
+	line, err := json.Marshal(Mark{Session: session, MessageID: messageID, Index: index, Hash: HashText(text)})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.marksPath(BaseKey(session)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Find returns the latest mark of messageID in the chat of base.
+func (s *Store) Find(base, messageID string) (Mark, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	marks, err := s.marksLocked(base)
+	if err != nil {
+		return Mark{}, err
+	}
+	for i := len(marks) - 1; i >= 0; i-- {
+		if marks[i].MessageID == messageID {
+			return marks[i], nil
+		}
+	}
+	return Mark{}, ErrUnknownMessage
+}
+
+// Marks returns the marks recorded for session, oldest first.
+func (s *Store) Marks(session string) []Mark {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	marks, _ := s.marksLocked(BaseKey(session))
+	out := marks[:0]
+	for _, m := range marks {
+		if m.Session == session {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// marksLocked reads the marks of the chat of base, dropping the oldest ones
+// past maxMarks.
+func (s *Store) marksLocked(base string) ([]Mark, error) {
+	path := s.marksPath(base)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUnknownMessage
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var marks []Mark
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var m Mark
+		if json.Unmarshal(scanner.Bytes(), &m) == nil {
+			marks = append(marks, m)
+		}
+	}
+	if len(marks) > maxMarks {
+		marks = marks[len(marks)-maxMarks:]
+		s.compactLocked(path, marks)
+	}
+	return marks, nil
+}
+
+func (s *Store) compactLocked(path string, marks []Mark) {
+	var buf bytes.Buffer
+	for _, m := range marks {
+		line, _ := json.Marshal(m)
+		buf.Write(append(line, '\n'))
+	}
+	if err := fileutil.WriteFileAtomic(path, buf.Bytes(), 0o600); err != nil {
+		logger.WarnCF("branch", "Failed to compact message marks", map[string]any{"error": err.Error()})
+	}
+}
+
+// slackPermalink matches the message part of a Slack permalink, p followed by
+// the timestamp without its dot.
+var slackPermalink = regexp.MustCompile(`^p(\d{10})(\d{6})$`)
+
+// Remove deletes the branches and message marks of base and returns the
+// branches it had. The sessions of the branches are left to the caller.
+func (s *Store) Remove(base string) ([]Branch, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	branches := s.chatLocked(base).Branches
+	delete(s.chats, base)
+	for _, path := range []string{s.statePath(base), s.marksPath(base)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return branches, err
+		}
+	}
+	return branches, nil
+}
+
+// ParseMessageRef extracts a message ID from a message link or returns ref
+// unchanged when it is already an ID. It understands Discord, Slack, and
+// Telegram links, whose message ID is the last path segment.
+func ParseMessageRef(ref string) string {
+	ref = strings.Trim(strings.TrimSpace(ref), "<>")
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" {
+		return ref
+	}
+	last := u.Path[strings.LastIndex(u.Path, "/")+1:]
+	if m := slackPermalink.FindStringSubmatch(last); m != nil {
+		return fmt.Sprintf("%s.%s", m[1], m[2])
+	}
+	return last
+}
+
+// MessageLink returns a link to a message where the platform has stable
+// message URLs: Discord, and Telegram supergroups. It returns "" otherwise.
+func MessageLink(channel, guildID, chatID, messageID string) string {
+	if messageID == "" || chatID == "" {
+		return ""
+	}
+	switch channel {
+	case "discord":
+		if guildID == "" {
+			guildID = "@me"
+		}
+		return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, chatID, messageID)
+	case "telegram":
+		if id, ok := strings.CutPrefix(chatID, "-100"); ok {
+			return fmt.Sprintf("https://t.me/c/%s/%s", id, messageID)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,181 @@
+package branch
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestKeys(t *testing.T) {
+	base := "agent:main:discord:group:42"
+	if got := Key(base, Main); got != base {
+		t.Fatalf("Key(main) = %q", got)
+	}
+	key := Key(base, "alt")
+	if BaseKey(key) != base || NameOf(key) != "alt" || NameOf(base) != Main {
+		t.Fatalf("key %q does not round-trip", key)
+	}
+	for name, want := range map[string]bool{"alt": true, "idea-2": true, "main": false, "Alt": false, "": false} {
+		if ValidName(name) != want {
+			t.Errorf("ValidName(%q) = %v, want %v", name, !want, want)
+		}
+	}
+}
+
+func TestParseMessageRef(t *testing.T) {
+	cases := map[string]string{
+		"https://discord.com/channels/1/2/1234567890":             "1234567890",
+		"<https://acme.slack.com/archives/C01/p1700000000000100>": "1700000000.000100",
+		"https://t.me/c/1234/56":                                  "56",
+		"1700000000.000100":                                       "1700000000.000100",
+		"  987  ":                                                 "987",
+	}
+	for ref, want := range cases {
+		if got := ParseMessageRef(ref); got != want {
+			t.Errorf("ParseMessageRef(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestMessageLink(t *testing.T) {
+	cases := []struct {
+		channel, guild, chat, msg, want string
+	}{
+		{"discord", "1", "2", "3", "https://discord.com/channels/1/2/3"},
+		{"discord", "", "2", "3", "https://discord.com/channels/@me/2/3"},
+		{"telegram", "", "-1001234", "56", "https://t.me/c/1234/56"},
+		{"telegram", "", "42", "56", ""},
+		{"slack", "T1", "C1", "1700000000.000100", ""},
+		{"discord", "1", "2", "", ""},
+	}
+	for _, c := range cases {
+		if got := MessageLink(c.channel, c.guild, c.chat, c.msg); got != c.want {
+			t.Errorf("MessageLink(%q, %q, %q, %q) = %q, want %q", c.channel, c.guild, c.chat, c.msg, got, c.want)
+		}
+	}
+	// Links round-trip through ParseMessageRef.
+	if got := ParseMessageRef(MessageLink("discord", "1", "2", "3")); got != "3" {
+		t.Errorf("round trip = %q", got)
+	}
+}
+
+func TestStore_BranchesPersist(t *testing.T) {
+	dir := t.TempDir()
+	base := "agent:main:telegram:direct:7"
+	s := NewStore(dir)
+	if err := s.Add(base, Branch{Name: "alt", Parent: Main, From: "5", Created: time.Now()}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := s.Add(base, Branch{Name: "alt"}); !errors.Is(err, ErrExists) {
+		t.Fatalf("duplicate Add() error = %v", err)
+	}
+	if got := s.ActiveKey(base); got != Key(base, "alt") {
+		t.Fatalf("ActiveKey() = %q after Add", got)
+	}
+
+	reloaded := NewStore(dir)
+	active, branches := reloaded.Branches(base)
+	if active != "alt" || len(branches) != 1 || branches[0].From != "5" {
+		t.Fatalf("reloaded branches = %q %+v", active, branches)
+	}
+	if err := reloaded.Switch(base, "nope"); !errors.Is(err, ErrUnknownBranch) {
+		t.Fatalf("Switch(nope) error = %v", err)
+	}
+	if err := reloaded.Switch(base, Main); err != nil {
+		t.Fatalf("Switch(main) error = %v", err)
+	}
+	if got := NewStore(dir).ActiveKey(base); got != base {
+		t.Fatalf("ActiveKey() = %q after switching to main", got)
+	}
+}
+
+func TestStore_MarkFind(t *testing.T) {
+	s := NewStore(t.TempDir())
+	base := "agent:main:slack:channel:c1"
+	if _, err := s.Find(base, "1"); !errors.Is(err, ErrUnknownMessage) {
+		t.Fatalf("Find() on empty store error = %v", err)
+	}
+	if err := s.Mark(base, "1", 0, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Mark(Key(base, "alt"), "2", 4, "world"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.Find(base, "2")
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if m.Session != Key(base, "alt") || m.Index != 4 || m.Hash != HashText("world") {
+		t.Fatalf("Find() = %+v", m)
+	}
+	if marks := s.Marks(base); len(marks) != 1 || marks[0].MessageID != "1" {
+		t.Fatalf("Marks(main) = %+v", marks)
+	}
+	var nilStore *Store
+	if nilStore.ActiveKey(base) != base || nilStore.Mark(base, "3", 0, "x") != nil {
+		t.Fatal("nil store should stay on main and ignore marks")
+	}
+}
+
+func TestStore_Remove(t *testing.T) {
+	dir := t.TempDir()
+	base := "agent:main:telegram:direct:7"
+	s := NewStore(dir)
+	s.Add(base, Branch{Name: "alt", Parent: Main})
+	s.Mark(base, "1", 0, "hello")
+
+	branches, err := s.Remove(base)
+	if err != nil || len(branches) != 1 || branches[0].Name != "alt" {
+		t.Fatalf("Remove() = %+v, %v", branches, err)
+	}
+	reloaded := NewStore(dir)
+	if _, left := reloaded.Branches(base); len(left) != 0 || len(reloaded.Marks(base)) != 0 {
+		t.Errorf("branches or marks survived Remove: %+v", left)
+	}
+}
+
+func TestStore_Variants(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+	session := "agent:main:discord:group:42"
+	turn := func(reply string) []providers.Message {
+		return []providers.Message{{Role: "user", Content: "q"}, {Role: "assistant", Content: reply}}
+	}
+
+	if err := s.KeepVariant(session, "m1", turn("a")); err != nil {
+		t.Fatal(err)
+	}
+	for _, reply := range []string{"b", "c"} {
+		if err := s.AddVariant(session, "m1", turn(reply)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SelectVariant(session, 0, turn("c!")); err != nil {
+		t.Fatal(err)
+	}
+
+	v, ok := NewStore(dir).Variants(session)
+	if !ok || v.MessageID != "m1" || len(v.Turns) != 3 || v.Current != 0 || v.Turns[2][1].Content != "c!" {
+		t.Fatalf("reloaded variants = %+v", v)
+	}
+	if err := s.SelectVariant(session, 3, turn("a")); !errors.Is(err, ErrUnknownVariant) {
+		t.Errorf("SelectVariant(3) error = %v", err)
+	}
+	for range maxVariants {
+		s.AddVariant(session, "m1", turn("more"))
+	}
+	if v, _ := s.Variants(session); len(v.Turns) != maxVariants || v.Current != maxVariants-1 {
+		t.Errorf("kept %d variants, current %d", len(v.Turns), v.Current)
+	}
+
+	// Regenerating another message starts over
+	s.KeepVariant(session, "m2", turn("x"))
+	if v, _ := s.Variants(session); v.MessageID != "m2" || len(v.Turns) != 1 {
+		t.Errorf("variants of a new message = %+v", v)
+	}
+	if err := s.SelectVariant("agent:main:other", 0, nil); !errors.Is(err, ErrNoVariants) {
+		t.Errorf("SelectVariant without variants error = %v", err)
+	}
+}
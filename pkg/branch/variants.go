@@ -0,0 +1,107 @@
+package branch
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// maxVariants bounds the replies kept for one turn. The oldest is dropped
+// first.
+const maxVariants = 10
+
+var (
+	// ErrNoVariants means the latest turn of a session was never regenerated.
+	ErrNoVariants = errors.New("this reply has not been regenerated")
+	// ErrUnknownVariant means no reply variant has the requested number.
+	ErrUnknownVariant = errors.New("there is no reply with that number")
+)
+
+// Variants are the replies the latest regenerated turn of a session has been
+// answered with. Each turn holds the messages from the user message to the
+// end of the turn; Current is the one in the session history.
+type Variants struct {
+	MessageID string                `json:"message_id"`
+	Turns     [][]providers.Message `json:"turns"`
+	Current   int                   `json:"current"`
+}
+
+// Variants returns the reply variants kept for session.
+func (s *Store) Variants(session string) (Variants, bool) {
+	if s == nil {
+		return Variants{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.chatLocked(BaseKey(session)).Variants[session]
+	if !ok {
+		return Variants{}, false
+	}
+	out := *v
+	out.Turns = slices.Clone(v.Turns)
+	return out, true
+}
+
+// KeepVariant stores turn, the answer to messageID about to be replaced,
+// as the current variant. A turn of another message starts a new set.
+func (s *Store) KeepVariant(session, messageID string, turn []providers.Message) error {
+	return s.updateVariants(session, func(v *Variants) {
+		if v.MessageID != messageID || len(v.Turns) == 0 {
+			*v = Variants{MessageID: messageID, Turns: [][]providers.Message{turn}}
+			return
+		}
+		v.Turns[v.Current] = turn
+	})
+}
+
+// AddVariant stores turn as a new answer to messageID and makes it current.
+func (s *Store) AddVariant(session, messageID string, turn []providers.Message) error {
+	return s.updateVariants(session, func(v *Variants) {
+		if v.MessageID != messageID {
+			*v = Variants{MessageID: messageID}
+		}
+		v.Turns = append(v.Turns, turn)
+		if over := len(v.Turns) - maxVariants; over > 0 {
+			v.Turns = v.Turns[over:]
+		}
+		v.Current = len(v.Turns) - 1
+	})
+}
+
+// SelectVariant stores current, the latest state of the current variant,
+// and makes the variant at index current instead.
+func (s *Store) SelectVariant(session string, index int, current []providers.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.chatLocked(BaseKey(session))
+	v, ok := c.Variants[session]
+	if !ok || len(v.Turns) < 2 {
+		return ErrNoVariants
+	}
+	if index < 0 || index >= len(v.Turns) {
+		return ErrUnknownVariant
+	}
+	v.Turns[v.Current] = current
+	v.Current = index
+	return s.saveLocked(BaseKey(session), c)
+}
+
+func (s *Store) updateVariants(session string, update func(v *Variants)) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.chatLocked(BaseKey(session))
+	if c.Variants == nil {
+		c.Variants = make(map[string]*Variants)
+	}
+	v := c.Variants[session]
+	if v == nil {
+		v = &Variants{}
+		c.Variants[session] = v
+	}
+	update(v)
+	return s.saveLocked(BaseKey(session), c)
+}
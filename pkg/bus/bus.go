@@ -7,6 +7,7 @@ import (
 	"sync/atomic"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/requestid"
 )
 
 // ErrBusClosed is returned when publishing to a closed MessageBus.
@@ -62,7 +63,12 @@ func publish[T any](ctx context.Context, mb *MessageBus, ch chan T, msg T) error
 	}
 }
 
+// PublishInbound queues msg for the agent, assigning a request ID when the
+// channel did not set one.
 func (mb *MessageBus) PublishInbound(ctx context.Context, msg InboundMessage) error {
+	if msg.RequestID == "" {
+		msg.RequestID = requestid.New()
+	}
 	return publish(ctx, mb, mb.inbound, msg)
 }
 
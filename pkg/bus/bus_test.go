@@ -36,6 +36,26 @@ func TestPublishConsume(t *testing.T) {
 	}
 }
 
+func TestPublishInbound_AssignsRequestID(t *testing.T) {
+	mb := NewMessageBus()
+	defer mb.Close()
+	ctx := context.Background()
+
+	if err := mb.PublishInbound(ctx, InboundMessage{Content: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mb.PublishInbound(ctx, InboundMessage{Content: "b", RequestID: "req_keep"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := <-mb.InboundChan(); got.RequestID == "" {
+		t.Error("expected a generated request ID")
+	}
+	if got := <-mb.InboundChan(); got.RequestID != "req_keep" {
+		t.Errorf("RequestID = %q, want the one set by the channel", got.RequestID)
+	}
+}
+
 func TestPublishOutboundSubscribe(t *testing.T) {
 	mb := NewMessageBus()
 	defer mb.Close()
@@ -26,6 +26,7 @@ type InboundMessage struct {
 	MessageID  string            `json:"message_id,omitempty"`  // platform message ID
 	MediaScope string            `json:"media_scope,omitempty"` // media lifecycle scope
 	SessionKey string            `json:"session_key"`
+	RequestID  string            `json:"request_id,omitempty"` // correlation ID, set on publish
 	Metadata   map[string]string `json:"metadata,omitempty"`
 }
 
@@ -34,6 +35,25 @@ type OutboundMessage struct {
 	ChatID           string `json:"chat_id"`
 	Content          string `json:"content"`
 	ReplyToMessageID string `json:"reply_to_message_id,omitempty"`
+	// ReplaceReply edits the bot's latest message in the chat instead of
+	// sending a new one, on channels that can edit messages.
+	ReplaceReply bool `json:"replace_reply,omitempty"`
+	// TurnID identifies the agent turn that produced the message, so
+	// reactions to it can be recorded as feedback on that turn.
+	TurnID string `json:"turn_id,omitempty"`
+	// RequestID is the correlation ID of the inbound message answered.
+	RequestID string `json:"request_id,omitempty"`
+	// TraceParent is the W3C traceparent of the agent turn, so the
+	// delivery is traced as part of it.
+	TraceParent string `json:"trace_parent,omitempty"`
+	// Mute asks channels that speak replies aloud not to speak this one,
+	// as its user turned speech off.
+	Mute bool `json:"mute,omitempty"`
+	// SenderID is the user whose message is answered, and Locale the i18n
+	// code of the language of the bot's own messages to them ("" is
+	// English), so channels can ask that user about the reply.
+	SenderID string `json:"sender_id,omitempty"`
+	Locale   string `json:"locale,omitempty"`
 }
 
 // MediaPart describes a single media attachment to send.
@@ -0,0 +1,282 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CalDAVClient talks to one CalDAV calendar collection with basic auth.
+type CalDAVClient struct {
+	url      string
+	username string
+	password string
+	loc      *time.Location
+}
+
+// NewCalDAVClient returns a client for the collection at calendarURL. Times
+// without a zone are interpreted in loc.
+func NewCalDAVClient(calendarURL, username, password string, loc *time.Location) *CalDAVClient {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &CalDAVClient{
+		url:      strings.TrimRight(calendarURL, "/") + "/",
+		username: username,
+		password: password,
+		loc:      loc,
+	}
+}
+
+const calendarQuery = `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <c:calendar-data><c:expand start="%[1]s" end="%[2]s"/></c:calendar-data>
+  </d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT"><c:time-range start="%[1]s" end="%[2]s"/></c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`
+
+type multistatus struct {
+	Responses []struct {
+		Href string `xml:"href"`
+		Data string `xml:"propstat>prop>calendar-data"`
+	} `xml:"response"`
+}
+
+func (c *CalDAVClient) List(ctx context.Context, from, to time.Time, limit int) ([]Event, error) {
+	body := fmt.Sprintf(calendarQuery, icsUTC(from), icsUTC(to))
+	req, err := http.NewRequestWithContext(ctx, "REPORT", c.url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse("caldav", resp); err != nil {
+		return nil, err
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("caldav: parsing response: %w", err)
+	}
+	var events []Event
+	for _, r := range ms.Responses {
+		for _, ev := range parseICS(r.Data, c.loc) {
+			ev.URL = c.resolve(r.Href)
+			events = append(events, ev)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+func (c *CalDAVClient) Create(ctx context.Context, ev Event) (*Event, error) {
+	uid, err := newUID()
+	if err != nil {
+		return nil, err
+	}
+	ev.ID = uid
+	ev.URL = c.url + uid + ".ics"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, ev.URL, bytes.NewReader(formatICS(ev, time.Now())))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	req.Header.Set("If-None-Match", "*")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse("caldav", resp); err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
+
+func (c *CalDAVClient) resolve(href string) string {
+	base, err := url.Parse(c.url)
+	if err != nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return base.ResolveReference(ref).String()
+}
+
+func newUID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf) + "@picoclaw", nil
+}
+
+func icsUTC(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// parseICS returns the VEVENTs in an iCalendar document.
+func parseICS(data string, loc *time.Location) []Event {
+	var events []Event
+	var cur *Event
+	for _, line := range unfoldICS(data) {
+		name, params, value := splitICSLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			cur = &Event{}
+		case name == "END" && value == "VEVENT" && cur != nil:
+			if cur.End.IsZero() {
+				cur.End = cur.Start
+				if cur.AllDay {
+					cur.End = cur.Start.AddDate(0, 0, 1)
+				}
+			}
+			events = append(events, *cur)
+			cur = nil
+		case cur == nil:
+		case name == "UID":
+			cur.ID = value
+		case name == "SUMMARY":
+			cur.Title = unescapeICS(value)
+		case name == "LOCATION":
+			cur.Location = unescapeICS(value)
+		case name == "DESCRIPTION":
+			cur.Description = unescapeICS(value)
+		case name == "DTSTART":
+			cur.Start, cur.AllDay = parseICSTime(value, params, loc)
+		case name == "DTEND":
+			cur.End, _ = parseICSTime(value, params, loc)
+		}
+	}
+	return events
+}
+
+func unfoldICS(data string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitICSLine splits "NAME;PARAM=X:VALUE" into its parts. Parameter names
+// are upper-cased.
+func splitICSLine(line string) (name string, params map[string]string, value string) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", nil, ""
+	}
+	parts := strings.Split(head, ";")
+	params = make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+func parseICSTime(value string, params map[string]string, loc *time.Location) (time.Time, bool) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, _ := time.ParseInLocation("20060102", value, loc)
+		return t, true
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, _ := time.Parse("20060102T150405Z", value)
+		return t, false
+	}
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, _ := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false
+}
+
+var (
+	icsUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	icsEscaper   = strings.NewReplacer(`\`, `\\`, "\r\n", `\n`, "\n", `\n`, ",", `\,`, ";", `\;`)
+)
+
+func unescapeICS(s string) string { return icsUnescaper.Replace(s) }
+
+// formatICS renders ev as a single-event iCalendar document.
+func formatICS(ev Event, now time.Time) []byte {
+	var b strings.Builder
+	line := func(s string) { b.WriteString(foldICS(s) + "\r\n") }
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//PicoClaw//Calendar//EN")
+	line("BEGIN:VEVENT")
+	line("UID:" + ev.ID)
+	line("DTSTAMP:" + icsUTC(now))
+	if ev.AllDay {
+		line("DTSTART;VALUE=DATE:" + ev.Start.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + ev.End.Format("20060102"))
+	} else {
+		line("DTSTART:" + icsUTC(ev.Start))
+		line("DTEND:" + icsUTC(ev.End))
+	}
+	line("SUMMARY:" + icsEscaper.Replace(ev.Title))
+	if ev.Location != "" {
+		line("LOCATION:" + icsEscaper.Replace(ev.Location))
+	}
+	if ev.Description != "" {
+		line("DESCRIPTION:" + icsEscaper.Replace(ev.Description))
+	}
+	line("END:VEVENT")
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// foldICS wraps lines longer than 75 octets without splitting UTF-8
+// sequences, as RFC 5545 requires.
+func foldICS(s string) string {
+	const limit = 75
+	if len(s) <= limit {
+		return s
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		n := len(string(r))
+		if width+n > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	return b.String()
+}
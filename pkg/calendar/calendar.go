@@ -0,0 +1,54 @@
+// Package calendar lists and creates events in Google Calendar or a CalDAV
+// calendar, and links chat users to their Google accounts through OAuth.
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const requestTimeout = 30 * time.Second
+
+// Event is a calendar event. All-day events have Start and End at midnight,
+// with End exclusive as in both Google Calendar and iCalendar.
+type Event struct {
+	ID          string
+	Title       string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Location    string
+	Description string
+	URL         string
+}
+
+// Client reads and writes one calendar.
+type Client interface {
+	// List returns events overlapping [from, to), ordered by start time.
+	List(ctx context.Context, from, to time.Time, limit int) ([]Event, error)
+	// Create adds ev and returns it as stored by the server.
+	Create(ctx context.Context, ev Event) (*Event, error)
+}
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+// checkResponse returns an error carrying the start of the body for non-2xx
+// responses.
+func checkResponse(service string, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("%s returned %s: %s", service, resp.Status, utils.Truncate(strings.TrimSpace(string(body)), 300))
+}
+
+// UserKey identifies a chat user across channels for account linking.
+func UserKey(channel, senderID string) string {
+	return channel + ":" + senderID
+}
@@ -0,0 +1,203 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const sampleICS = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:abc\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20260314T150000\r\nDTEND;TZID=Europe/Berlin:20260314T160000\r\n" +
+	"SUMMARY:Standup\\, weekly\r\nDESCRIPTION:Line one\\nline\r\n  two\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:def\r\nDTSTART;VALUE=DATE:20260313\r\nSUMMARY:Holiday\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	events := parseICS(sampleICS, time.UTC)
+	if len(events) != 2 {
+		t.Fatalf("got %d events", len(events))
+	}
+	ev := events[0]
+	if ev.Title != "Standup, weekly" || ev.Description != "Line one\nline two" {
+		t.Errorf("text fields = %q / %q", ev.Title, ev.Description)
+	}
+	if got := ev.Start.UTC().Format(time.RFC3339); got != "2026-03-14T14:00:00Z" {
+		t.Errorf("start = %s", got)
+	}
+	if !events[1].AllDay || events[1].End.Sub(events[1].Start) != 24*time.Hour {
+		t.Errorf("all-day event = %+v", events[1])
+	}
+}
+
+func TestFormatICSRoundTrip(t *testing.T) {
+	ev := Event{
+		ID:          "uid-1",
+		Title:       "Lunch; with Bob, maybe",
+		Start:       time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC),
+		End:         time.Date(2026, 3, 14, 13, 0, 0, 0, time.UTC),
+		Description: strings.Repeat("long notes ", 20),
+	}
+	data := string(formatICS(ev, time.Now()))
+	for _, line := range strings.Split(data, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line not folded: %q", line)
+		}
+	}
+	got := parseICS(data, time.UTC)
+	if len(got) != 1 || got[0].Title != ev.Title || got[0].Description != ev.Description || !got[0].Start.Equal(ev.Start) {
+		t.Fatalf("round trip = %+v", got)
+	}
+}
+
+func TestCalDAVClient(t *testing.T) {
+	var put string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "me" || pass != "pw" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case "REPORT":
+			body, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(body), `start="20260313T000000Z"`) || r.Header.Get("Depth") != "1" {
+				t.Errorf("unexpected query: %s", body)
+			}
+			w.WriteHeader(http.StatusMultiStatus)
+			w.Write([]byte(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">` +
+				`<d:response><d:href>/cal/abc.ics</d:href><d:propstat><d:prop><c:calendar-data>` +
+				strings.ReplaceAll(sampleICS, "&", "&amp;") +
+				`</c:calendar-data></d:prop></d:propstat></d:response></d:multistatus>`))
+		case http.MethodPut:
+			if r.Header.Get("If-None-Match") != "*" {
+				t.Error("create should not overwrite existing events")
+			}
+			body, _ := io.ReadAll(r.Body)
+			put = string(body)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewCalDAVClient(srv.URL+"/cal", "me", "pw", time.UTC)
+	from := time.Date(2026, 3, 13, 0, 0, 0, 0, time.UTC)
+	events, err := c.List(context.Background(), from, from.AddDate(0, 0, 7), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Title != "Holiday" || events[1].URL != srv.URL+"/cal/abc.ics" {
+		t.Fatalf("events = %+v", events)
+	}
+
+	created, err := c.Create(context.Background(), Event{Title: "Dentist", Start: from, End: from.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.URL, srv.URL+"/cal/") || !strings.Contains(put, "SUMMARY:Dentist") {
+		t.Errorf("created %+v with body %q", created, put)
+	}
+}
+
+func TestGoogleClientCreateAllDay(t *testing.T) {
+	var got googleEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" || r.URL.Path != "/calendars/primary/events" {
+			t.Errorf("request %s %s", r.Header.Get("Authorization"), r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		got.ID, got.HTMLLink = "ev1", "https://calendar.google.com/event?eid=ev1"
+		json.NewEncoder(w).Encode(got)
+	}))
+	defer srv.Close()
+
+	c := NewGoogleClient("tok", "", time.UTC)
+	c.baseURL = srv.URL
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	ev, err := c.Create(context.Background(), Event{Title: "Offsite", Start: day, End: day.AddDate(0, 0, 2), AllDay: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Start.Date != "2026-03-14" || got.End.Date != "2026-03-16" || got.Start.DateTime != "" {
+		t.Errorf("sent %+v", got)
+	}
+	if !ev.AllDay || ev.URL == "" || !ev.Start.Equal(day) {
+		t.Errorf("returned %+v", ev)
+	}
+}
+
+func TestLinker_CallbackStoresTokenForUser(t *testing.T) {
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			if r.Form.Get("code") != "the-code" || r.Form.Get("code_verifier") == "" {
+				t.Errorf("exchange form = %v", r.Form)
+			}
+			w.Write([]byte(`{"access_token":"old","refresh_token":"refresh","expires_in":1}`))
+		case "refresh_token":
+			if r.Form.Has("scope") {
+				t.Error("refresh must not narrow the granted scope")
+			}
+			w.Write([]byte(`{"access_token":"new","expires_in":3600}`))
+		}
+	}))
+	defer tokenSrv.Close()
+
+	workspace := t.TempDir()
+	l, err := NewLinker(config.GoogleCalendarConfig{
+		ClientID: "id", ClientSecret: "secret", RedirectURL: "https://bot.example.com/cb",
+	}, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.oauth.TokenURL = tokenSrv.URL
+
+	authURL, err := l.AuthURL("telegram:42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	if u.Query().Get("redirect_uri") != "https://bot.example.com/cb" || l.Path() != "/cb" {
+		t.Fatalf("auth url %s, path %s", authURL, l.Path())
+	}
+
+	callback := func(state string) int {
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cb?code=the-code&state="+url.QueryEscape(state), nil))
+		return w.Code
+	}
+	if code := callback("forged"); code != http.StatusBadRequest {
+		t.Errorf("unknown state: status %d", code)
+	}
+	if code := callback(u.Query().Get("state")); code != http.StatusOK {
+		t.Fatalf("callback status %d", code)
+	}
+	if code := callback(u.Query().Get("state")); code != http.StatusBadRequest {
+		t.Errorf("state reused: status %d", code)
+	}
+
+	// The stored token expires within the refresh window, so Token refreshes it.
+	tok, err := l.Token(context.Background(), "telegram:42")
+	if err != nil || tok != "new" {
+		t.Fatalf("Token = %q, %v", tok, err)
+	}
+	if _, err := l.Token(context.Background(), "telegram:7"); err != ErrNotLinked {
+		t.Errorf("other user: %v", err)
+	}
+
+	reloaded, _ := NewLinker(config.GoogleCalendarConfig{
+		ClientID: "id", ClientSecret: "secret", RedirectURL: "https://bot.example.com/cb",
+	}, workspace)
+	if !reloaded.Linked("telegram:42") {
+		t.Error("link not persisted")
+	}
+	if ok, err := reloaded.Unlink("telegram:42"); !ok || err != nil || reloaded.Linked("telegram:42") {
+		t.Errorf("Unlink = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,145 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const googleAPIBase = "https://www.googleapis.com/calendar/v3"
+
+// GoogleClient talks to the Google Calendar API v3 with a user's OAuth token.
+type GoogleClient struct {
+	token      string
+	calendarID string
+	baseURL    string
+	loc        *time.Location
+}
+
+// NewGoogleClient returns a client for calendarID ("primary" if empty).
+// All-day dates are interpreted in loc.
+func NewGoogleClient(accessToken, calendarID string, loc *time.Location) *GoogleClient {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	return &GoogleClient{token: accessToken, calendarID: calendarID, baseURL: googleAPIBase, loc: loc}
+}
+
+type googleTime struct {
+	DateTime string `json:"dateTime,omitempty"`
+	Date     string `json:"date,omitempty"`
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+type googleEvent struct {
+	ID          string     `json:"id,omitempty"`
+	Summary     string     `json:"summary"`
+	Description string     `json:"description,omitempty"`
+	Location    string     `json:"location,omitempty"`
+	HTMLLink    string     `json:"htmlLink,omitempty"`
+	Start       googleTime `json:"start"`
+	End         googleTime `json:"end"`
+}
+
+func (c *GoogleClient) List(ctx context.Context, from, to time.Time, limit int) ([]Event, error) {
+	q := url.Values{
+		"timeMin":      {from.Format(time.RFC3339)},
+		"timeMax":      {to.Format(time.RFC3339)},
+		"singleEvents": {"true"},
+		"orderBy":      {"startTime"},
+		"maxResults":   {strconv.Itoa(limit)},
+	}
+	var resp struct {
+		Items []googleEvent `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.eventsURL()+"?"+q.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(resp.Items))
+	for _, it := range resp.Items {
+		events = append(events, c.fromGoogle(it))
+	}
+	return events, nil
+}
+
+func (c *GoogleClient) Create(ctx context.Context, ev Event) (*Event, error) {
+	body := googleEvent{
+		Summary:     ev.Title,
+		Description: ev.Description,
+		Location:    ev.Location,
+	}
+	if ev.AllDay {
+		body.Start = googleTime{Date: ev.Start.Format(time.DateOnly)}
+		body.End = googleTime{Date: ev.End.Format(time.DateOnly)}
+	} else {
+		body.Start = googleTime{DateTime: ev.Start.Format(time.RFC3339)}
+		body.End = googleTime{DateTime: ev.End.Format(time.RFC3339)}
+	}
+	var created googleEvent
+	if err := c.do(ctx, http.MethodPost, c.eventsURL(), body, &created); err != nil {
+		return nil, err
+	}
+	out := c.fromGoogle(created)
+	return &out, nil
+}
+
+func (c *GoogleClient) eventsURL() string {
+	return c.baseURL + "/calendars/" + url.PathEscape(c.calendarID) + "/events"
+}
+
+func (c *GoogleClient) fromGoogle(it googleEvent) Event {
+	ev := Event{
+		ID:          it.ID,
+		Title:       it.Summary,
+		Location:    it.Location,
+		Description: it.Description,
+		URL:         it.HTMLLink,
+	}
+	if it.Start.Date != "" {
+		ev.AllDay = true
+		ev.Start, _ = time.ParseInLocation(time.DateOnly, it.Start.Date, c.loc)
+		ev.End, _ = time.ParseInLocation(time.DateOnly, it.End.Date, c.loc)
+	} else {
+		ev.Start, _ = time.Parse(time.RFC3339, it.Start.DateTime)
+		ev.End, _ = time.Parse(time.RFC3339, it.End.DateTime)
+	}
+	return ev
+}
+
+func (c *GoogleClient) do(ctx context.Context, method, rawURL string, body, out any) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse("google calendar", resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
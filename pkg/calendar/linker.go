@@ -0,0 +1,312 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/auth"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// DefaultCallbackPath is used when the redirect URL has no path.
+	DefaultCallbackPath = "/oauth/calendar/callback"
+
+	googleScope = "https://www.googleapis.com/auth/calendar.events"
+	linkTTL     = 10 * time.Minute
+)
+
+// ErrNotLinked is returned by Token for users without a linked account.
+var ErrNotLinked = errors.New("calendar account not linked")
+
+// LinksPath returns where linked accounts are stored for a workspace.
+func LinksPath(workspace string) string {
+	return filepath.Join(workspace, "state", "calendar_links.json")
+}
+
+type pendingLink struct {
+	user     string
+	verifier string
+	expires  time.Time
+}
+
+type linksFile struct {
+	Links map[string]*auth.AuthCredential `json:"links"`
+}
+
+// Linker connects chat users to their Google accounts. A user asks for an
+// authorization URL, signs in, and Google redirects to the gateway, where
+// ServeHTTP stores the tokens under that user's key.
+type Linker struct {
+	oauth     auth.OAuthProviderConfig
+	redirect  string
+	path      string
+	storePath string
+
+	mu      sync.Mutex
+	links   map[string]*auth.AuthCredential
+	pending map[string]pendingLink
+}
+
+// NewLinker validates cfg and loads previously linked accounts.
+func NewLinker(cfg config.GoogleCalendarConfig, workspace string) (*Linker, error) {
+	if cfg.ClientID == "" || cfg.ClientSecret == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("calendar: google needs client_id, client_secret, and redirect_url")
+	}
+	u, err := url.Parse(cfg.RedirectURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("calendar: invalid redirect_url %q", cfg.RedirectURL)
+	}
+	path := u.Path
+	if path == "" || path == "/" {
+		path = DefaultCallbackPath
+	}
+
+	l := &Linker{
+		oauth: auth.OAuthProviderConfig{
+			Issuer:       "https://accounts.google.com/o/oauth2/v2",
+			TokenURL:     "https://oauth2.googleapis.com/token",
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Scopes:       googleScope,
+		},
+		redirect:  cfg.RedirectURL,
+		path:      path,
+		storePath: LinksPath(workspace),
+		links:     make(map[string]*auth.AuthCredential),
+		pending:   make(map[string]pendingLink),
+	}
+	l.load()
+	return l, nil
+}
+
+// Path returns the path to mount the OAuth callback on.
+func (l *Linker) Path() string { return l.path }
+
+// AuthURL starts linking for user and returns the URL to sign in at. The URL
+// is valid for ten minutes.
+func (l *Linker) AuthURL(user string) (string, error) {
+	state, err := auth.GenerateState()
+	if err != nil {
+		return "", err
+	}
+	pkce, err := auth.GeneratePKCE()
+	if err != nil {
+		return "", err
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	for s, p := range l.pending {
+		if now.After(p.expires) {
+			delete(l.pending, s)
+		}
+	}
+	l.pending[state] = pendingLink{user: user, verifier: pkce.CodeVerifier, expires: now.Add(linkTTL)}
+	l.mu.Unlock()
+
+	return auth.BuildAuthorizeURL(l.oauth, pkce, state, l.redirect), nil
+}
+
+// Linked reports whether user has a linked account.
+func (l *Linker) Linked(user string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.links[user] != nil
+}
+
+// Unlink forgets user's tokens. It reports whether an account was linked.
+func (l *Linker) Unlink(user string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.links[user] == nil {
+		return false, nil
+	}
+	delete(l.links, user)
+	return true, l.saveLocked()
+}
+
+// UnlinkFile removes the account of user from the links file at path, for
+// when no Linker is running.
+func UnlinkFile(path, user string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	var f linksFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return false, err
+	}
+	if _, ok := f.Links[user]; !ok {
+		return false, nil
+	}
+	delete(f.Links, user)
+	data, err = json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	return true, fileutil.WriteFileAtomic(path, data, 0o600)
+}
+
+// Token returns a valid access token for user, refreshing it if needed.
+func (l *Linker) Token(ctx context.Context, user string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cred := l.links[user]
+	if cred == nil {
+		return "", ErrNotLinked
+	}
+	if !cred.NeedsRefresh() {
+		return cred.AccessToken, nil
+	}
+
+	refreshed, err := l.refresh(ctx, cred)
+	if err != nil {
+		return "", fmt.Errorf("refreshing calendar token (try /calendar link again): %w", err)
+	}
+	l.links[user] = refreshed
+	if err := l.saveLocked(); err != nil {
+		logger.WarnCF("calendar", "Failed to save refreshed token", map[string]any{"error": err.Error()})
+	}
+	return refreshed.AccessToken, nil
+}
+
+func (l *Linker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+
+	l.mu.Lock()
+	p, ok := l.pending[q.Get("state")]
+	delete(l.pending, q.Get("state"))
+	l.mu.Unlock()
+	if !ok || time.Now().After(p.expires) {
+		writeLinkPage(w, http.StatusBadRequest, "This link has expired. Run /calendar link again.")
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		writeLinkPage(w, http.StatusBadRequest, "Google sign-in was cancelled ("+e+").")
+		return
+	}
+
+	cred, err := auth.ExchangeCodeForTokens(l.oauth, q.Get("code"), p.verifier, l.redirect)
+	if err != nil {
+		logger.WarnCF("calendar", "OAuth code exchange failed", map[string]any{"error": err.Error()})
+		writeLinkPage(w, http.StatusBadGateway, "Could not finish linking your calendar. Please try again.")
+		return
+	}
+	cred.Provider = "google-calendar"
+
+	l.mu.Lock()
+	l.links[p.user] = cred
+	err = l.saveLocked()
+	l.mu.Unlock()
+	if err != nil {
+		logger.ErrorCF("calendar", "Failed to save linked account", map[string]any{"error": err.Error()})
+		writeLinkPage(w, http.StatusInternalServerError, "Your calendar was linked but could not be saved.")
+		return
+	}
+	logger.InfoCF("calendar", "Calendar account linked", map[string]any{"user": p.user})
+	writeLinkPage(w, http.StatusOK, "Your calendar is linked. You can close this window and return to the chat.")
+}
+
+func writeLinkPage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<!doctype html><title>PicoClaw calendar</title><p>%s</p>", html.EscapeString(message))
+}
+
+// refresh exchanges the refresh token for a new access token. The scope is
+// left out so Google keeps the one that was granted.
+func (l *Linker) refresh(ctx context.Context, cred *auth.AuthCredential) (*auth.AuthCredential, error) {
+	if cred.RefreshToken == "" {
+		return nil, errors.New("no refresh token")
+	}
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {cred.RefreshToken},
+		"client_id":     {l.oauth.ClientID},
+		"client_secret": {l.oauth.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.oauth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse("google oauth", resp); err != nil {
+		return nil, err
+	}
+
+	var tok struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return nil, err
+	}
+	if tok.AccessToken == "" {
+		return nil, errors.New("no access token in response")
+	}
+	refreshed := *cred
+	refreshed.AccessToken = tok.AccessToken
+	if tok.RefreshToken != "" {
+		refreshed.RefreshToken = tok.RefreshToken
+	}
+	refreshed.ExpiresAt = time.Time{}
+	if tok.ExpiresIn > 0 {
+		refreshed.ExpiresAt = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	}
+	return &refreshed, nil
+}
+
+func (l *Linker) load() {
+	data, err := os.ReadFile(l.storePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.WarnCF("calendar", "Failed to read linked accounts", map[string]any{"error": err.Error()})
+		}
+		return
+	}
+	var f linksFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		logger.WarnCF("calendar", "Failed to parse linked accounts", map[string]any{"error": err.Error()})
+		return
+	}
+	for user, cred := range f.Links {
+		if cred != nil {
+			l.links[user] = cred
+		}
+	}
+}
+
+func (l *Linker) saveLocked() error {
+	data, err := json.MarshalIndent(linksFile{Links: l.links}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(l.storePath, data, 0o600)
+}
@@ -0,0 +1,150 @@
+package channels
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// ANSI SGR codes understood by Discord's ```ansi renderer.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiGray   = "\x1b[0;30m"
+	ansiRed    = "\x1b[0;31m"
+	ansiGreen  = "\x1b[0;32m"
+	ansiYellow = "\x1b[0;33m"
+	ansiBlue   = "\x1b[0;34m"
+	ansiCyan   = "\x1b[0;36m"
+)
+
+var (
+	ansiEscapeRe = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+	logErrorRe = regexp.MustCompile(`(?i)\b(error|fatal|panic|err)\b`)
+	logWarnRe  = regexp.MustCompile(`(?i)\b(warn|warning|wrn)\b`)
+	logInfoRe  = regexp.MustCompile(`(?i)\b(info|inf)\b`)
+	logDebugRe = regexp.MustCompile(`(?i)\b(debug|dbg|trace)\b`)
+
+	testFailRe = regexp.MustCompile(`^\s*(--- FAIL|FAIL\b|✗|✘|not ok\b)`)
+	testPassRe = regexp.MustCompile(`^\s*(--- PASS|PASS\b|ok\b|✓|✔)`)
+	testSkipRe = regexp.MustCompile(`^\s*(--- SKIP|SKIP\b)`)
+)
+
+// ansiColorizers maps a fence language to the function that colors one line.
+var ansiColorizers = map[string]func(line string) string{
+	"diff":  colorDiffLine,
+	"patch": colorDiffLine,
+	"log":   colorLogLine,
+	"logs":  colorLogLine,
+	"test":  colorTestLine,
+	"tests": colorTestLine,
+}
+
+// FormatANSICodeBlocks rewrites fenced code blocks for the target channel.
+// With ansi enabled, diff, log and test blocks become ```ansi blocks with
+// per-line colors. With ansi disabled, existing ```ansi blocks fall back to
+// plain fences with the escape codes stripped, so they stay readable.
+func FormatANSICodeBlocks(content string, ansi bool) string {
+	if !strings.Contains(content, "```") && !strings.Contains(content, "~~~") {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	fenced := utils.FencedLines(lines)
+	out := make([]string, 0, len(lines))
+	var colorize func(string) string
+	stripping := false
+
+	for i, line := range lines {
+		switch fenced[i] {
+		case utils.FenceClose:
+			stripping, colorize = false, nil
+			out = append(out, line)
+			continue
+		case utils.FenceOpen:
+			info := utils.FenceInfo(line)
+			fence := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), info))
+			lang := strings.ToLower(info)
+			switch {
+			case ansi && ansiColorizers[lang] != nil:
+				colorize = ansiColorizers[lang]
+				out = append(out, fence+"ansi")
+			case !ansi && lang == "ansi":
+				stripping = true
+				out = append(out, fence)
+			default:
+				out = append(out, line)
+			}
+			continue
+		}
+
+		switch {
+		case colorize != nil:
+			out = append(out, colorize(line))
+		case stripping:
+			out = append(out, ansiEscapeRe.ReplaceAllString(line, ""))
+		default:
+			out = append(out, line)
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
+
+func colorDiffLine(line string) string {
+	switch {
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		return wrapANSI(ansiGray, line)
+	case strings.HasPrefix(line, "@@"):
+		return wrapANSI(ansiCyan, line)
+	case strings.HasPrefix(line, "+"):
+		return wrapANSI(ansiGreen, line)
+	case strings.HasPrefix(line, "-"):
+		return wrapANSI(ansiRed, line)
+	}
+	return line
+}
+
+func colorLogLine(line string) string {
+	switch {
+	case logErrorRe.MatchString(line):
+		return wrapANSI(ansiRed, line)
+	case logWarnRe.MatchString(line):
+		return wrapANSI(ansiYellow, line)
+	case logInfoRe.MatchString(line):
+		return wrapANSI(ansiBlue, line)
+	case logDebugRe.MatchString(line):
+		return wrapANSI(ansiGray, line)
+	}
+	return line
+}
+
+func colorTestLine(line string) string {
+	switch {
+	case testFailRe.MatchString(line):
+		return wrapANSI(ansiRed, line)
+	case testSkipRe.MatchString(line):
+		return wrapANSI(ansiYellow, line)
+	case testPassRe.MatchString(line):
+		return wrapANSI(ansiGreen, line)
+	}
+	return line
+}
+
+func wrapANSI(code, line string) string {
+	if line == "" {
+		return line
+	}
+	return code + line + ansiReset
+}
+
+// formatOutboundCodeBlocks applies FormatANSICodeBlocks for the channel's
+// ANSI support.
+func formatOutboundCodeBlocks(ch Channel, content string) string {
+	ansi := false
+	if ac, ok := ch.(ANSICapable); ok {
+		ansi = ac.SupportsANSI()
+	}
+	return FormatANSICodeBlocks(content, ansi)
+}
@@ -0,0 +1,76 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatANSICodeBlocks_Diff(t *testing.T) {
+	content := "Changes:\n```diff\n--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-old\n+new\n same\n```"
+	got := FormatANSICodeBlocks(content, true)
+
+	for _, want := range []string{
+		"```ansi\n",
+		ansiRed + "-old" + ansiReset,
+		ansiGreen + "+new" + ansiReset,
+		ansiCyan + "@@ -1 +1 @@" + ansiReset,
+		ansiGray + "--- a/x.go" + ansiReset,
+		"\n same\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%q", want, got)
+		}
+	}
+	if !strings.HasPrefix(got, "Changes:\n") || !strings.HasSuffix(got, "\n```") {
+		t.Errorf("text outside the block must be untouched, got:\n%q", got)
+	}
+}
+
+func TestFormatANSICodeBlocks_LogAndTest(t *testing.T) {
+	content := "```log\nINFO started\nWARN slow\nERROR boom\nplain\n```\n```test\n--- PASS: TestA\n--- FAIL: TestB\n--- SKIP: TestC\n```"
+	got := FormatANSICodeBlocks(content, true)
+
+	for _, want := range []string{
+		ansiBlue + "INFO started" + ansiReset,
+		ansiYellow + "WARN slow" + ansiReset,
+		ansiRed + "ERROR boom" + ansiReset,
+		"\nplain\n",
+		ansiGreen + "--- PASS: TestA" + ansiReset,
+		ansiRed + "--- FAIL: TestB" + ansiReset,
+		ansiYellow + "--- SKIP: TestC" + ansiReset,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%q", want, got)
+		}
+	}
+}
+
+func TestFormatANSICodeBlocks_OtherLanguagesUntouched(t *testing.T) {
+	content := "```go\nfunc main() {}\n```"
+	if got := FormatANSICodeBlocks(content, true); got != content {
+		t.Errorf("non-colorized languages must be untouched, got %q", got)
+	}
+}
+
+func TestFormatANSICodeBlocks_Fallback(t *testing.T) {
+	content := "```ansi\n\x1b[0;31mred\x1b[0m text\n```"
+	want := "```\nred text\n```"
+	if got := FormatANSICodeBlocks(content, false); got != want {
+		t.Errorf("FormatANSICodeBlocks(fallback) = %q, want %q", got, want)
+	}
+
+	diff := "```diff\n+new\n```"
+	if got := FormatANSICodeBlocks(diff, false); got != diff {
+		t.Errorf("diff blocks must stay plain on non-ANSI channels, got %q", got)
+	}
+}
+
+func TestFormatANSICodeBlocks_TildeFence(t *testing.T) {
+	// The ``` line belongs to the outer block, so it neither closes the
+	// diff nor starts a new one.
+	content := "~~~diff\n-old\n```\n+new\n~~~\n+outside"
+	want := "~~~ansi\n" + ansiRed + "-old" + ansiReset + "\n```\n" + ansiGreen + "+new" + ansiReset + "\n~~~\n+outside"
+	if got := FormatANSICodeBlocks(content, true); got != want {
+		t.Errorf("FormatANSICodeBlocks = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,24 @@
+package channels
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// ApprovalTurnPrefix starts the TurnID of the prompt of a tool action held
+// for approval; the action's ID follows it. Reacting ✅ or ❌ to the prompt
+// approves or denies the action.
+const ApprovalTurnPrefix = "approval:"
+
+// ApprovalResolver approves or denies the held tool action id on behalf of
+// sender, who reacted to its prompt in chatID.
+type ApprovalResolver func(ctx context.Context, channel, chatID, id string, sender bus.SenderInfo, approve bool) error
+
+// SetApprovalResolver registers the function that resolves held tool
+// actions from reactions.
+func (m *Manager) SetApprovalResolver(resolve ApprovalResolver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.approvalResolver = resolve
+}
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -16,6 +17,8 @@ import (
 	"github.com/sipeed/picoclaw/pkg/identity"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/requestid"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 var (
@@ -33,6 +36,22 @@ func init() {
 	uniqueIDPrefix = hex.EncodeToString(b[:])
 }
 
+// MetadataKeyEdited marks an inbound message as a new version of the message
+// with the same ID. Channels that see edits set it to "true".
+const MetadataKeyEdited = "edited"
+
+// MetadataKeyRoles holds the comma-separated IDs of the server roles of the
+// sender, on channels that have roles.
+const MetadataKeyRoles = "roles"
+
+// MetadataKeyGuildAdmin is "true" when the sender may manage the server the
+// message was sent in.
+const MetadataKeyGuildAdmin = "guild_admin"
+
+// MetadataKeyLocale is the locale the platform reports for the sender or
+// their server, such as "pt-BR".
+const MetadataKeyLocale = "locale"
+
 // audioAnnotationRe matches audio/voice annotations injected by channels (e.g. [voice], [audio: file.ogg]).
 var audioAnnotationRe = regexp.MustCompile(`\[(voice|audio)(?::[^\]]*)?\]`)
 
@@ -58,7 +77,8 @@ type Channel interface {
 // BaseChannelOption is a functional option for configuring a BaseChannel.
 type BaseChannelOption func(*BaseChannel)
 
-// WithMaxMessageLength sets the maximum message length (in runes) for a channel.
+// WithMaxMessageLength sets the maximum message length for a channel, measured
+// in runes unless WithLengthFunc says otherwise.
 // Messages exceeding this limit will be automatically split by the Manager.
 // A value of 0 means no limit.
 func WithMaxMessageLength(n int) BaseChannelOption {
@@ -75,6 +95,38 @@ func WithReasoningChannelID(id string) BaseChannelOption {
 	return func(c *BaseChannel) { c.reasoningChannelID = id }
 }
 
+// WithLengthFunc sets how the platform counts message length (see LengthFunc).
+// Channels that do not set one are measured in runes.
+func WithLengthFunc(fn LengthFunc) BaseChannelOption {
+	return func(c *BaseChannel) { c.lengthFunc = fn }
+}
+
+// WithLengthUnit overrides the length function with the one a channel's
+// config names (see LengthFuncForUnit). An empty unit keeps the platform's
+// own count, and an unknown one is logged and ignored.
+func WithLengthUnit(unit string) BaseChannelOption {
+	return func(c *BaseChannel) {
+		if unit == "" {
+			return
+		}
+		fn := LengthFuncForUnit(unit)
+		if fn == nil {
+			logger.WarnCF("channels", "Unknown message length unit, keeping the platform default", map[string]any{
+				"channel": c.name,
+				"unit":    unit,
+			})
+			return
+		}
+		c.lengthFunc = fn
+	}
+}
+
+// WithCustomEmoji maps emoji shortcodes (without colons) to platform-specific
+// custom emoji syntax, e.g. {"party": "<:party:123456>"} on Discord.
+func WithCustomEmoji(m map[string]string) BaseChannelOption {
+	return func(c *BaseChannel) { c.customEmoji = m }
+}
+
 // MessageLengthProvider is an opt-in interface that channels implement
 // to advertise their maximum message length. The Manager uses this via
 // type assertion to decide whether to split outbound messages.
@@ -82,11 +134,18 @@ type MessageLengthProvider interface {
 	MaxMessageLength() int
 }
 
+// CustomEmojiProvider is an opt-in interface for channels that translate
+// :shortcode: emoji into their own custom-emoji syntax on output.
+type CustomEmojiProvider interface {
+	CustomEmoji() map[string]string
+}
+
 type BaseChannel struct {
 	config              any
 	bus                 *bus.MessageBus
 	running             atomic.Bool
 	name                string
+	allowMu             sync.RWMutex
 	allowList           []string
 	maxMessageLength    int
 	groupTrigger        config.GroupTriggerConfig
@@ -94,6 +153,14 @@ type BaseChannel struct {
 	placeholderRecorder PlaceholderRecorder
 	owner               Channel // the concrete channel that embeds this BaseChannel
 	reasoningChannelID  string
+	customEmoji         map[string]string
+	lengthFunc          LengthFunc
+	longOutputThreshold int
+	longOutputGate      LongOutputGate
+	stopGate            StopGate
+	feedbackSink        FeedbackSink
+	replyActionSink     ReplyActionSink
+	ephemeral           config.EphemeralConfig
 }
 
 func NewBaseChannel(
@@ -115,12 +182,24 @@ func NewBaseChannel(
 	return bc
 }
 
-// MaxMessageLength returns the maximum message length (in runes) for this channel.
+// MaxMessageLength returns the maximum message length for this channel,
+// measured with MessageLengthFunc.
 // A value of 0 means no limit.
 func (c *BaseChannel) MaxMessageLength() int {
 	return c.maxMessageLength
 }
 
+// MessageLengthFunc returns the length function used against MaxMessageLength
+// (may be nil, meaning runes).
+func (c *BaseChannel) MessageLengthFunc() LengthFunc {
+	return c.lengthFunc
+}
+
+// CustomEmoji returns the shortcode → custom emoji mapping for this channel (may be nil).
+func (c *BaseChannel) CustomEmoji() map[string]string {
+	return c.customEmoji
+}
+
 // ShouldRespondInGroup determines whether the bot should respond in a group chat.
 // Each channel is responsible for:
 //  1. Detecting isMentioned (platform-specific)
@@ -134,8 +213,12 @@ func (c *BaseChannel) MaxMessageLength() int {
 //   - If prefixes configured but no match and not mentioned → ignore
 //   - Otherwise (no group_trigger configured) → respond to all (permissive default)
 func (c *BaseChannel) ShouldRespondInGroup(isMentioned bool, content string) (bool, string) {
-	gt := c.groupTrigger
+	return RespondInGroup(c.groupTrigger, isMentioned, content)
+}
 
+// RespondInGroup is ShouldRespondInGroup with an explicit trigger, for
+// channels that let single chats override the channel's group_trigger.
+func RespondInGroup(gt config.GroupTriggerConfig, isMentioned bool, content string) (bool, string) {
 	// Mentioned → always respond
 	if isMentioned {
 		return true, strings.TrimSpace(content)
@@ -173,8 +256,23 @@ func (c *BaseChannel) IsRunning() bool {
 	return c.running.Load()
 }
 
+// SetAllowList replaces the allow-list, so a config reload applies it
+// without reconnecting the channel.
+func (c *BaseChannel) SetAllowList(allowList []string) {
+	c.allowMu.Lock()
+	defer c.allowMu.Unlock()
+	c.allowList = allowList
+}
+
+func (c *BaseChannel) allowed() []string {
+	c.allowMu.RLock()
+	defer c.allowMu.RUnlock()
+	return c.allowList
+}
+
 func (c *BaseChannel) IsAllowed(senderID string) bool {
-	if len(c.allowList) == 0 {
+	allowList := c.allowed()
+	if len(allowList) == 0 {
 		return true
 	}
 
@@ -186,7 +284,7 @@ func (c *BaseChannel) IsAllowed(senderID string) bool {
 		userPart = senderID[idx+1:]
 	}
 
-	for _, allowed := range c.allowList {
+	for _, allowed := range allowList {
 		// Strip leading "@" from allowed value for username matching
 		trimmed := strings.TrimPrefix(allowed, "@")
 		allowedID := trimmed
@@ -216,11 +314,12 @@ func (c *BaseChannel) IsAllowed(senderID string) bool {
 // It delegates to identity.MatchAllowed for each entry, providing unified matching
 // across all legacy formats and the new canonical "platform:id" format.
 func (c *BaseChannel) IsAllowedSender(sender bus.SenderInfo) bool {
-	if len(c.allowList) == 0 {
+	allowList := c.allowed()
+	if len(allowList) == 0 {
 		return true
 	}
 
-	for _, allowed := range c.allowList {
+	for _, allowed := range allowList {
 		if identity.MatchAllowed(sender, allowed) {
 			return true
 		}
@@ -260,6 +359,10 @@ func (c *BaseChannel) HandleMessage(
 
 	scope := BuildMediaScope(c.name, chatID, messageID)
 
+	// Normalize emoji to :shortcode: form so the LLM sees the same text
+	// regardless of how the platform encoded it.
+	content = utils.EmojiToShortcodes(content, c.customEmoji)
+
 	msg := bus.InboundMessage{
 		Channel:    c.name,
 		SenderID:   resolvedSenderID,
@@ -270,12 +373,26 @@ func (c *BaseChannel) HandleMessage(
 		Peer:       peer,
 		MessageID:  messageID,
 		MediaScope: scope,
+		RequestID:  requestid.New(),
 		Metadata:   metadata,
 	}
 
+	// An answer to a pending long-output prompt is handled by the Manager.
+	if c.longOutputGate != nil && c.longOutputGate.ResolveLongOutput(ctx, msg) {
+		return
+	}
+	// So is a stop request for a reply in flight.
+	if c.stopGate != nil && c.stopGate.ResolveStop(ctx, msg) {
+		return
+	}
+
 	// Auto-trigger typing indicator, message reaction, and placeholder before publishing.
 	// Each capability is independent — all three may fire for the same message.
-	if c.owner != nil && c.placeholderRecorder != nil {
+	// Edited messages get none of them: most edits are not answered, and a
+	// regenerated reply replaces the previous one in place. Nor do messages
+	// answered in shadow mode.
+	if c.owner != nil && c.placeholderRecorder != nil && metadata[MetadataKeyEdited] != "true" &&
+		!shadowedBy(c.placeholderRecorder, c.name) {
 		// Typing — independent pipeline
 		if tc, ok := c.owner.(TypingCapable); ok {
 			if stop, err := tc.StartTyping(ctx, chatID); err == nil {
@@ -303,9 +420,10 @@ func (c *BaseChannel) HandleMessage(
 
 	if err := c.bus.PublishInbound(ctx, msg); err != nil {
 		logger.ErrorCF("channels", "Failed to publish inbound message", map[string]any{
-			"channel": c.name,
-			"chat_id": chatID,
-			"error":   err.Error(),
+			"channel":    c.name,
+			"chat_id":    chatID,
+			"request_id": msg.RequestID,
+			"error":      err.Error(),
 		})
 	}
 }
@@ -320,6 +438,15 @@ func (c *BaseChannel) SetMediaStore(s media.MediaStore) { c.mediaStore = s }
 // GetMediaStore returns the injected MediaStore (may be nil).
 func (c *BaseChannel) GetMediaStore() media.MediaStore { return c.mediaStore }
 
+// RecordReply registers a message the channel sent to chatID with the
+// Manager, so a regenerated reply can replace it. Channels call it from Send
+// when the platform returns the ID of the new message.
+func (c *BaseChannel) RecordReply(chatID, messageID string) {
+	if c.placeholderRecorder != nil {
+		c.placeholderRecorder.RecordReply(c.name, chatID, messageID)
+	}
+}
+
 // SetPlaceholderRecorder injects a PlaceholderRecorder into the channel.
 func (c *BaseChannel) SetPlaceholderRecorder(r PlaceholderRecorder) {
 	c.placeholderRecorder = r
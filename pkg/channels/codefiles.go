@@ -0,0 +1,153 @@
+package channels
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// defaultCodeFileMinLines is used when code files are enabled without a
+// line count.
+const defaultCodeFileMinLines = 40
+
+// codeExtensions maps fence languages to file extensions. Languages not
+// listed get .txt.
+var codeExtensions = map[string]string{
+	"go": ".go", "golang": ".go",
+	"python": ".py", "py": ".py",
+	"javascript": ".js", "js": ".js", "jsx": ".jsx", "typescript": ".ts", "ts": ".ts", "tsx": ".tsx",
+	"rust": ".rs", "rs": ".rs",
+	"java": ".java", "kotlin": ".kt", "kt": ".kt", "scala": ".scala", "swift": ".swift", "dart": ".dart",
+	"c": ".c", "h": ".h", "cpp": ".cpp", "c++": ".cpp", "cc": ".cpp", "hpp": ".hpp",
+	"csharp": ".cs", "cs": ".cs", "c#": ".cs", "fsharp": ".fs",
+	"ruby": ".rb", "rb": ".rb", "php": ".php", "perl": ".pl", "lua": ".lua", "r": ".r",
+	"elixir": ".ex", "erlang": ".erl", "haskell": ".hs", "hs": ".hs", "clojure": ".clj", "zig": ".zig",
+	"bash": ".sh", "sh": ".sh", "shell": ".sh", "zsh": ".sh", "console": ".sh",
+	"powershell": ".ps1", "ps1": ".ps1", "bat": ".bat", "batch": ".bat",
+	"sql": ".sql", "graphql": ".graphql", "proto": ".proto", "protobuf": ".proto",
+	"json": ".json", "jsonc": ".json", "yaml": ".yaml", "yml": ".yaml", "toml": ".toml", "ini": ".ini",
+	"xml": ".xml", "html": ".html", "css": ".css", "scss": ".scss", "vue": ".vue", "svelte": ".svelte",
+	"markdown": ".md", "md": ".md", "diff": ".diff", "patch": ".diff",
+	"dockerfile": ".dockerfile", "docker": ".dockerfile", "makefile": ".mk", "make": ".mk",
+	"hcl": ".tf", "terraform": ".tf", "nix": ".nix", "csv": ".csv",
+}
+
+// codeFile is a code block moved out of a reply.
+type codeFile struct {
+	name string
+	code string
+}
+
+// extractCodeFiles moves the fenced code blocks of content that have at
+// least minLines lines out of it. Each one is left as a line naming its file.
+// Unclosed fences are kept inline.
+func extractCodeFiles(content string, minLines int) (string, []codeFile) {
+	if minLines <= 0 || (!strings.Contains(content, "```") && !strings.Contains(content, "~~~")) {
+		return content, nil
+	}
+
+	type block struct {
+		start, end int // line indexes of the fences
+		lang       string
+	}
+	lines := strings.Split(content, "\n")
+	var blocks []block
+	open, lang := -1, ""
+	for i, kind := range utils.FencedLines(lines) {
+		switch kind {
+		case utils.FenceOpen:
+			open, lang = i, ""
+			if fields := strings.Fields(utils.FenceInfo(lines[i])); len(fields) > 0 {
+				lang = strings.ToLower(fields[0])
+			}
+		case utils.FenceClose:
+			if i-open-1 >= minLines {
+				blocks = append(blocks, block{start: open, end: i, lang: lang})
+			}
+		}
+	}
+	if len(blocks) == 0 {
+		return content, nil
+	}
+
+	files := make([]codeFile, len(blocks))
+	for i, b := range blocks {
+		ext, ok := codeExtensions[b.lang]
+		if !ok {
+			ext = ".txt"
+		}
+		name := "snippet" + ext
+		if len(blocks) > 1 {
+			name = fmt.Sprintf("snippet-%d%s", i+1, ext)
+		}
+		files[i] = codeFile{name: name, code: strings.Join(lines[b.start+1:b.end], "\n") + "\n"}
+	}
+
+	out := make([]string, 0, len(lines))
+	next := 0
+	for i, b := range blocks {
+		out = append(out, lines[next:b.start]...)
+		out = append(out, fmt.Sprintf("📎 %s (%d lines, attached)", files[i].name, b.end-b.start-1))
+		next = b.end + 1
+	}
+	out = append(out, lines[next:]...)
+	return strings.Join(out, "\n"), files
+}
+
+// codeFileMinLines returns the line count from which the channel of w sends
+// code blocks as files, or 0 when it keeps them inline.
+func (m *Manager) codeFileMinLines(w *channelWorker) int {
+	if m.config == nil || m.mediaStore == nil || !m.config.CodeFiles.Enabled {
+		return 0
+	}
+	if _, ok := w.ch.(MediaSender); !ok {
+		return 0
+	}
+	if n := m.config.CodeFiles.MinLines; n > 0 {
+		return n
+	}
+	return defaultCodeFileMinLines
+}
+
+// moveCodeToFiles takes the long code blocks out of msg and stores them as
+// files, returning the parts to send after the text. msg is left unchanged
+// when the channel keeps code inline or a file cannot be stored.
+func (m *Manager) moveCodeToFiles(name string, w *channelWorker, msg *bus.OutboundMessage) []bus.MediaPart {
+	minLines := m.codeFileMinLines(w)
+	if minLines <= 0 {
+		return nil
+	}
+	prose, files := extractCodeFiles(msg.Content, minLines)
+	if len(files) == 0 {
+		return nil
+	}
+	parts, ok := m.storeCodeFiles(name, msg.ChatID, files)
+	if !ok {
+		return nil
+	}
+	msg.Content = prose
+	return parts
+}
+
+// storeCodeFiles puts files into the media store for the chat. It returns
+// false when one of them cannot be stored, so the reply keeps its code
+// inline; the ones stored already expire with the store's cleanup.
+func (m *Manager) storeCodeFiles(name, chatID string, files []codeFile) ([]bus.MediaPart, bool) {
+	parts := make([]bus.MediaPart, 0, len(files))
+	for _, f := range files {
+		ref, err := m.storeText(name, chatID, f.name, "text/plain", f.code)
+		if err != nil {
+			logger.WarnCF("channels", "Failed to store code block as file, posting inline", map[string]any{
+				"channel": name,
+				"file":    f.name,
+				"error":   err.Error(),
+			})
+			return nil, false
+		}
+		parts = append(parts, bus.MediaPart{Type: "file", Ref: ref, Filename: f.name, ContentType: "text/plain"})
+	}
+	return parts, true
+}
@@ -0,0 +1,61 @@
+package channels
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestExtractCodeFiles(t *testing.T) {
+	code := strings.Repeat("x := 1\n", 3)
+	content := "Here is the fix:\n```go\n" + code + "```\nAnd a short one:\n```sh\nmake\n```\n" +
+		"Then:\n````Python title=app.py\n" + code + "```\n````\nDone."
+
+	prose, files := extractCodeFiles(content, 3)
+	want := "Here is the fix:\n📎 snippet-1.go (3 lines, attached)\nAnd a short one:\n```sh\nmake\n```\n" +
+		"Then:\n📎 snippet-2.py (4 lines, attached)\nDone."
+	if prose != want {
+		t.Errorf("prose =\n%s\nwant\n%s", prose, want)
+	}
+	if len(files) != 2 || files[0].code != code || files[1].code != code+"```\n" {
+		t.Errorf("files = %+v", files)
+	}
+
+	if _, files := extractCodeFiles("```\n"+code+"```", 3); len(files) != 1 || files[0].name != "snippet.txt" {
+		t.Errorf("unlabeled block: files = %+v, want snippet.txt", files)
+	}
+	if prose, files := extractCodeFiles("```go\n"+code, 1); prose != "```go\n"+code || files != nil {
+		t.Errorf("unclosed fence: prose = %q, files = %v", prose, files)
+	}
+}
+
+func TestDeliver_SendsLongCodeAsFile(t *testing.T) {
+	m, ch := newActionTestSetup(t)
+	m.config = &config.Config{CodeFiles: config.CodeFilesConfig{Enabled: true, MinLines: 2}}
+	var sent []string
+	ch.sendFn = func(_ context.Context, msg bus.OutboundMessage) error {
+		sent = append(sent, msg.Content)
+		return nil
+	}
+
+	m.deliver(context.Background(), "test", m.workers["test"], bus.OutboundMessage{
+		Channel: "test", ChatID: "1", Content: "Run this:\n```bash\necho one\necho two\n```",
+	})
+	if len(sent) != 1 || sent[0] != "Run this:\n📎 snippet.sh (2 lines, attached)" {
+		t.Errorf("sent = %q", sent)
+	}
+	if _, files := ch.snapshot(); len(files) != 1 || files[0] != "snippet.sh=echo one\necho two\n" {
+		t.Errorf("files = %q", files)
+	}
+
+	m.config.CodeFiles.Enabled = false
+	m.deliver(context.Background(), "test", m.workers["test"], bus.OutboundMessage{
+		Channel: "test", ChatID: "1", Content: "```bash\necho one\necho two\n```",
+	})
+	if _, files := ch.snapshot(); len(sent) != 2 || len(files) != 1 {
+		t.Errorf("disabled: sent = %q, files = %q", sent, files)
+	}
+}
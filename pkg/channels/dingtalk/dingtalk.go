@@ -17,7 +17,6 @@ import (
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/identity"
 	"github.com/sipeed/picoclaw/pkg/logger"
-	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 // DingTalkChannel implements the Channel interface for DingTalk (钉钉)
@@ -121,7 +120,7 @@ func (c *DingTalkChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 
 	logger.DebugCF("dingtalk", "Sending message", map[string]any{
 		"chat_id": msg.ChatID,
-		"preview": utils.Truncate(msg.Content, 100),
+		"preview": c.LogPreview(msg.ChatID, "", msg.Content, 100),
 	})
 
 	// Use the session webhook to send the reply
@@ -185,7 +184,7 @@ func (c *DingTalkChannel) onChatBotMessageReceived(
 	logger.DebugCF("dingtalk", "Received message", map[string]any{
 		"sender_nick": senderNick,
 		"sender_id":   senderID,
-		"preview":     utils.Truncate(content, 50),
+		"preview":     c.LogPreview(chatID, senderID, content, 50),
 	})
 
 	// Build sender info
@@ -0,0 +1,88 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// discordAPI is the REST API CheckToken calls, and checkClient, when set,
+// the client it calls it with instead of the session's.
+var (
+	discordAPI  = "https://discord.com/api/v10"
+	checkClient *http.Client
+)
+
+// Application flags saying a privileged gateway intent is turned on.
+const (
+	appFlagGatewayMessageContent        = 1 << 19
+	appFlagGatewayMessageContentLimited = 1 << 18
+)
+
+// CheckToken verifies the bot token of cfg with the Discord API. A rejected
+// token is an error; a Message Content intent that is off, or a bot that
+// was invited to no server, are returned as warnings saying how to fix
+// them.
+func CheckToken(ctx context.Context, cfg config.DiscordConfig) ([]string, error) {
+	session, err := newDiscordSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client := session.Client
+	if checkClient != nil {
+		client = checkClient
+	}
+	get := func(path string, v any) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, discordAPI+path, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bot "+cfg.Token)
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("could not reach Discord: %w", err)
+		}
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized:
+			return fmt.Errorf("the bot token was rejected; reset it on the Bot page of the Discord developer portal " +
+				"and copy it to channels.discord.token")
+		case resp.StatusCode != http.StatusOK:
+			return fmt.Errorf("GET %s: Discord answered %s", path, resp.Status)
+		}
+		return json.NewDecoder(resp.Body).Decode(v)
+	}
+
+	var bot struct {
+		Username string `json:"username"`
+	}
+	if err := get("/users/@me", &bot); err != nil {
+		return nil, err
+	}
+	var app struct {
+		ID    string `json:"id"`
+		Flags int    `json:"flags"`
+	}
+	if err := get("/applications/@me", &app); err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	if app.Flags&(appFlagGatewayMessageContent|appFlagGatewayMessageContentLimited) == 0 {
+		warnings = append(warnings, fmt.Sprintf("the Message Content intent of %s is off, so the bot reads "+
+			"server messages only when mentioned; turn it on under Bot → Privileged Gateway Intents", bot.Username))
+	}
+	var guilds []json.RawMessage
+	if err := get("/users/@me/guilds?limit=1", &guilds); err != nil {
+		return nil, err
+	}
+	if len(guilds) == 0 {
+		warnings = append(warnings, fmt.Sprintf("%s is in no server; invite it with "+
+			"https://discord.com/oauth2/authorize?client_id=%s&scope=bot+applications.commands&permissions=274878024768",
+			bot.Username, app.ID))
+	}
+	return warnings, nil
+}
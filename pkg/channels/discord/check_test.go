@@ -0,0 +1,62 @@
+package discord
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func fakeDiscordAPI(t *testing.T, flags, guilds string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/users/@me":
+			w.Write([]byte(`{"username":"claw"}`))
+		case "/applications/@me":
+			w.Write([]byte(`{"id":"42","flags":` + flags + `}`))
+		case "/users/@me/guilds":
+			w.Write([]byte(guilds))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	// The server's client skips the proxy environment, which the proxy
+	// tests of this package set.
+	oldAPI, oldClient := discordAPI, checkClient
+	discordAPI, checkClient = srv.URL, srv.Client()
+	t.Cleanup(func() { discordAPI, checkClient = oldAPI, oldClient })
+}
+
+func TestCheckToken(t *testing.T) {
+	fakeDiscordAPI(t, "524288", `[{"id":"1"}]`)
+	warnings, err := CheckToken(context.Background(), config.DiscordConfig{Token: "good"})
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("CheckToken = %v, %v; want no problems", warnings, err)
+	}
+
+	if _, err := CheckToken(context.Background(), config.DiscordConfig{Token: "bad"}); err == nil ||
+		!strings.Contains(err.Error(), "token was rejected") {
+		t.Errorf("bad token: err = %v", err)
+	}
+}
+
+func TestCheckToken_WarnsAboutIntentAndInvite(t *testing.T) {
+	fakeDiscordAPI(t, "0", `[]`)
+	warnings, err := CheckToken(context.Background(), config.DiscordConfig{Token: "good"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "Message Content intent") ||
+		!strings.Contains(warnings[1], "client_id=42") {
+		t.Errorf("warnings = %q", warnings)
+	}
+}
@@ -0,0 +1,391 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// slashArgsOption is the free-form option that carries a command's
+	// arguments; the shared command parser splits it like typed text.
+	slashArgsOption         = "args"
+	maxSlashDescriptionRune = 100
+
+	defaultSummarizeMessages = 50
+	maxSummarizeMessages     = 200
+)
+
+// summarizePrompt asks the agent to summarize a channel transcript.
+const summarizePrompt = "Summarize the last %d messages of this channel for someone who missed them. " +
+	"Group related messages into topics and note decisions and open questions.\n\n%s"
+
+var slashNamePattern = regexp.MustCompile(`^[-_a-z0-9]{1,32}$`)
+
+// RegisterCommands registers the agent commands and the command definitions
+// as Discord slash commands, replacing any the bot registered before.
+func (c *DiscordChannel) RegisterCommands(ctx context.Context, defs []commands.Definition) error {
+	var extra []*discordgo.ApplicationCommand
+	if c.config.Voice.Enabled {
+		extra = voiceCommands()
+	}
+	_, err := c.session.ApplicationCommandBulkOverwrite(c.botUserID, "", applicationCommands(defs, extra...),
+		discordgo.WithContext(ctx))
+	return err
+}
+
+// agentCommands are the Discord-only commands: /ask, /reset, /model and
+// /summarize.
+func agentCommands() []*discordgo.ApplicationCommand {
+	minMessages := 1.0
+	return []*discordgo.ApplicationCommand{
+		{
+			Name:        "ask",
+			Description: "Ask the assistant",
+			Options: []*discordgo.ApplicationCommandOption{{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "prompt",
+				Description: "What to ask",
+				Required:    true,
+			}},
+		},
+		{
+			Name:        "reset",
+			Description: "Start a new conversation",
+		},
+		{
+			Name:        "model",
+			Description: "Show the current model or switch to another",
+			Options: []*discordgo.ApplicationCommandOption{{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "name",
+				Description: "Model to switch to",
+			}},
+		},
+		{
+			Name:        "summarize",
+			Description: "Summarize the recent messages of this channel",
+			Options: []*discordgo.ApplicationCommandOption{{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "messages",
+				Description: fmt.Sprintf("How many messages to read (default %d)", defaultSummarizeMessages),
+				MinValue:    &minMessages,
+				MaxValue:    maxSummarizeMessages,
+			}},
+		},
+	}
+}
+
+// applicationCommands lists the agent commands and extra first; a definition
+// with the same name as one of them is left out.
+func applicationCommands(
+	defs []commands.Definition,
+	extra ...*discordgo.ApplicationCommand,
+) []*discordgo.ApplicationCommand {
+	cmds := append(agentCommands(), extra...)
+	taken := make(map[string]bool, len(cmds))
+	for _, cmd := range cmds {
+		taken[cmd.Name] = true
+	}
+	for _, cmd := range slashCommands(defs) {
+		if !taken[cmd.Name] {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+// agentCommandText turns /ask, /reset and /model into the text handed to the
+// agent. ok is false for any other command.
+func agentCommandText(data discordgo.ApplicationCommandInteractionData) (text string, ok bool) {
+	option := func(name string) string {
+		for _, opt := range data.Options {
+			if opt.Name == name && opt.Type == discordgo.ApplicationCommandOptionString {
+				return strings.TrimSpace(opt.StringValue())
+			}
+		}
+		return ""
+	}
+	switch data.Name {
+	case "ask":
+		return option("prompt"), true
+	case "reset":
+		return "/clear", true
+	case "model":
+		if name := option("name"); name != "" {
+			return "/switch model to " + name, true
+		}
+		return "/show model", true
+	}
+	return "", false
+}
+
+// summarizeCount is the number of messages /summarize reads.
+func summarizeCount(data discordgo.ApplicationCommandInteractionData) int {
+	for _, opt := range data.Options {
+		if opt.Name == "messages" && opt.Type == discordgo.ApplicationCommandOptionInteger {
+			return min(max(int(opt.IntValue()), 1), maxSummarizeMessages)
+		}
+	}
+	return defaultSummarizeMessages
+}
+
+// summarizeText reads the recent messages of channelID and builds the prompt
+// that asks the agent to summarize them.
+func (c *DiscordChannel) summarizeText(ctx context.Context, channelID string, count int) (string, error) {
+	msgs, err := c.ReadHistory(ctx, channelID, time.Time{}, count)
+	if err != nil {
+		return "", err
+	}
+	if len(msgs) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf(summarizePrompt, len(msgs), renderHistory(msgs)), nil
+}
+
+// renderHistory formats messages one per line.
+func renderHistory(msgs []channels.HistoryMessage) string {
+	lines := make([]string, len(msgs))
+	for i, m := range msgs {
+		lines[i] = fmt.Sprintf("[%s] %s: %s", m.Time.Local().Format("15:04"), m.Author,
+			strings.Join(strings.Fields(m.Text), " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// slashCommands maps definitions onto application commands. Sub-commands
+// become Discord sub-commands, and anything that takes arguments gets one
+// optional "args" text option.
+func slashCommands(defs []commands.Definition) []*discordgo.ApplicationCommand {
+	cmds := make([]*discordgo.ApplicationCommand, 0, len(defs))
+	for _, def := range defs {
+		if !slashNamePattern.MatchString(def.Name) || def.Description == "" {
+			continue
+		}
+		cmd := &discordgo.ApplicationCommand{
+			Name:        def.Name,
+			Description: slashDescription(def.Description),
+		}
+		if len(def.SubCommands) == 0 {
+			if usage := strings.TrimSpace(strings.TrimPrefix(def.Usage, "/"+def.Name)); usage != "" ||
+				len(def.Flags) > 0 {
+				cmd.Options = []*discordgo.ApplicationCommandOption{argsOption(usage)}
+			}
+			cmds = append(cmds, cmd)
+			continue
+		}
+		for _, sc := range def.SubCommands {
+			if !slashNamePattern.MatchString(sc.Name) {
+				continue
+			}
+			desc := sc.Description
+			if desc == "" {
+				desc = def.Description
+			}
+			opt := &discordgo.ApplicationCommandOption{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        sc.Name,
+				Description: slashDescription(desc),
+			}
+			if sc.ArgsUsage != "" || len(sc.Flags) > 0 {
+				opt.Options = []*discordgo.ApplicationCommandOption{argsOption(sc.ArgsUsage)}
+			}
+			cmd.Options = append(cmd.Options, opt)
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+func argsOption(usage string) *discordgo.ApplicationCommandOption {
+	desc := "Arguments"
+	if usage != "" {
+		desc += ": " + usage
+	}
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        slashArgsOption,
+		Description: slashDescription(desc),
+	}
+}
+
+func slashDescription(s string) string {
+	return utils.Truncate(s, maxSlashDescriptionRune)
+}
+
+// slashCommandText turns an invoked slash command back into the text the
+// user would have typed, so it runs through the same command parser.
+func slashCommandText(data discordgo.ApplicationCommandInteractionData) string {
+	parts := []string{"/" + data.Name}
+	options := data.Options
+	if len(options) == 1 && options[0].Type == discordgo.ApplicationCommandOptionSubCommand {
+		parts = append(parts, options[0].Name)
+		options = options[0].Options
+	}
+	for _, opt := range options {
+		if opt.Name == slashArgsOption && opt.Type == discordgo.ApplicationCommandOptionString {
+			if args := strings.TrimSpace(opt.StringValue()); args != "" {
+				parts = append(parts, args)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// handleSlashCommand acknowledges the command with a deferred response, so
+// Discord shows "thinking…" while the agent works, and hands it to the agent.
+// The first reply to the channel then fills in the response, privately in
+// ephemeral conversations with ephemeral.private_replies on.
+func (c *DiscordChannel) handleSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := i.User
+	if i.Member != nil && i.Member.User != nil {
+		user = i.Member.User
+	}
+	if user == nil {
+		return
+	}
+	sender := bus.SenderInfo{
+		Platform:    "discord",
+		PlatformID:  user.ID,
+		CanonicalID: identity.BuildCanonicalID("discord", user.ID),
+		Username:    user.Username,
+		DisplayName: user.Username,
+	}
+	if !c.IsAllowedSender(sender) {
+		c.respondEphemeral(s, i.Interaction, "You are not allowed to use this bot.")
+		return
+	}
+	if name := i.ApplicationCommandData().Name; c.config.Voice.Enabled && (name == "join" || name == "leave") {
+		c.handleVoiceCommand(s, i, user.ID)
+		return
+	}
+
+	resp := &discordgo.InteractionResponse{Type: discordgo.InteractionResponseDeferredChannelMessageWithSource}
+	private := c.wantsPrivateReply(i.ChannelID, sender)
+	if private {
+		resp.Data = &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral}
+	}
+	if err := s.InteractionRespond(i.Interaction, resp); err != nil {
+		logger.DebugCF("discord", "Failed to acknowledge slash command", map[string]any{
+			"error": err.Error(),
+		})
+	} else {
+		if private {
+			c.markPrivate(i.ChannelID, i.Interaction)
+		}
+		c.deferReply(i.ChannelID, i.Interaction)
+	}
+
+	data := i.ApplicationCommandData()
+	text, ok := agentCommandText(data)
+	switch {
+	case data.Name == "summarize":
+		var err error
+		text, err = c.summarizeText(c.ctx, i.ChannelID, summarizeCount(data))
+		if err != nil || text == "" {
+			reply := "There are no messages to summarize."
+			if err != nil {
+				reply = "Could not read the messages of this channel: " + err.Error()
+			}
+			if sendErr := c.Send(c.ctx, bus.OutboundMessage{ChatID: i.ChannelID, Content: reply}); sendErr != nil {
+				logger.DebugCF("discord", "Failed to answer slash command", map[string]any{
+					"error": sendErr.Error(),
+				})
+			}
+			return
+		}
+	case !ok:
+		text = slashCommandText(data)
+	}
+
+	peer := bus.Peer{Kind: "channel", ID: i.ChannelID}
+	if i.GuildID == "" {
+		peer = bus.Peer{Kind: "direct", ID: user.ID}
+	}
+	metadata := map[string]string{
+		"user_id":      user.ID,
+		"username":     user.Username,
+		"display_name": user.Username,
+		"guild_id":     i.GuildID,
+		"channel_id":   i.ChannelID,
+		"is_dm":        fmt.Sprintf("%t", i.GuildID == ""),
+	}
+	if i.Member != nil && len(i.Member.Roles) > 0 {
+		metadata[channels.MetadataKeyRoles] = strings.Join(i.Member.Roles, ",")
+	}
+	if i.Member != nil && isGuildAdmin(i.Member.Permissions) {
+		metadata[channels.MetadataKeyGuildAdmin] = "true"
+	}
+	if locale := interactionLocale(i.Interaction); locale != "" {
+		metadata[channels.MetadataKeyLocale] = locale
+	}
+	c.HandleMessage(c.ctx, peer, i.ID, user.ID, i.ChannelID, text, nil, metadata, sender)
+}
+
+// interactionLocale returns the language of the user's Discord client, or
+// the server's preferred one when Discord does not send it.
+func interactionLocale(i *discordgo.Interaction) string {
+	if i.Locale != "" {
+		return string(i.Locale)
+	}
+	if i.GuildLocale != nil {
+		return string(*i.GuildLocale)
+	}
+	return ""
+}
+
+func (c *DiscordChannel) respondEphemeral(s *discordgo.Session, i *discordgo.Interaction, content string) {
+	c.respondWith(s, i, &discordgo.InteractionResponseData{
+		Content: content,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+}
+
+// respond answers a slash command with a message everyone in the channel
+// sees.
+func (c *DiscordChannel) respond(s *discordgo.Session, i *discordgo.Interaction, content string) {
+	c.respondWith(s, i, &discordgo.InteractionResponseData{Content: content})
+}
+
+func (c *DiscordChannel) respondWith(
+	s *discordgo.Session,
+	i *discordgo.Interaction,
+	data *discordgo.InteractionResponseData,
+) {
+	if err := s.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: data,
+	}); err != nil {
+		logger.DebugCF("discord", "Failed to answer slash command", map[string]any{
+			"error": err.Error(),
+		})
+	}
+}
+
+// registerSlashCommands registers the built-in commands in the background so
+// a slow or failing API call never delays message intake.
+func (c *DiscordChannel) registerSlashCommands() {
+	defs := commands.BuiltinDefinitions()
+	go func() {
+		if err := c.RegisterCommands(c.ctx, defs); err != nil {
+			logger.WarnCF("discord", "Failed to register slash commands", map[string]any{
+				"error": err.Error(),
+			})
+			return
+		}
+		logger.InfoCF("discord", "Discord slash commands registered", map[string]any{
+			"count": len(defs),
+		})
+	}()
+}
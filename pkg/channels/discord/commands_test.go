@@ -0,0 +1,186 @@
+package discord
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestSlashCommands_MapsDefinitions(t *testing.T) {
+	cmds := slashCommands([]commands.Definition{
+		{Name: "help", Description: "Show help", Usage: "/help [command]"},
+		{Name: "clear", Description: "Clear the chat", Usage: "/clear"},
+		{Name: "transcript", Description: "Tool calls", SubCommands: []commands.SubCommand{
+			{Name: "recent", Description: "List calls", ArgsUsage: "[count]"},
+			{Name: "off"},
+		}},
+		{Name: "Bad Name", Description: "skipped"},
+	})
+	if len(cmds) != 3 {
+		t.Fatalf("got %d commands, want 3", len(cmds))
+	}
+	if len(cmds[0].Options) != 1 || cmds[0].Options[0].Name != slashArgsOption {
+		t.Errorf("/help options = %+v", cmds[0].Options)
+	}
+	if len(cmds[1].Options) != 0 {
+		t.Errorf("/clear should take no options, got %+v", cmds[1].Options)
+	}
+	subs := cmds[2].Options
+	if len(subs) != 2 || subs[0].Type != discordgo.ApplicationCommandOptionSubCommand ||
+		len(subs[0].Options) != 1 || len(subs[1].Options) != 0 || subs[1].Description != "Tool calls" {
+		t.Errorf("/transcript options = %+v", subs)
+	}
+}
+
+func TestSlashCommandText(t *testing.T) {
+	data := discordgo.ApplicationCommandInteractionData{
+		Name: "transcript",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+			Name: "recent",
+			Type: discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+				Name:  slashArgsOption,
+				Type:  discordgo.ApplicationCommandOptionString,
+				Value: ` 5 --tool "web fetch" `,
+			}},
+		}},
+	}
+	if got, want := slashCommandText(data), `/transcript recent 5 --tool "web fetch"`; got != want {
+		t.Errorf("slashCommandText = %q, want %q", got, want)
+	}
+	if got := slashCommandText(discordgo.ApplicationCommandInteractionData{Name: "help"}); got != "/help" {
+		t.Errorf("bare command = %q", got)
+	}
+}
+
+func TestApplicationCommands_AgentCommandsFirst(t *testing.T) {
+	cmds := applicationCommands([]commands.Definition{
+		{Name: "help", Description: "Show help", Usage: "/help"},
+		{Name: "model", Description: "Clashes with the agent command", Usage: "/model"},
+	})
+	var names []string
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name)
+	}
+	if got, want := strings.Join(names, ","), "ask,reset,model,summarize,help"; got != want {
+		t.Errorf("commands = %s, want %s", got, want)
+	}
+	if cmds[2].Description == "Clashes with the agent command" {
+		t.Error("definition replaced the agent /model")
+	}
+}
+
+func TestAgentCommandText(t *testing.T) {
+	str := func(name, value string) []*discordgo.ApplicationCommandInteractionDataOption {
+		return []*discordgo.ApplicationCommandInteractionDataOption{{
+			Name: name, Type: discordgo.ApplicationCommandOptionString, Value: value,
+		}}
+	}
+	tests := []struct {
+		data discordgo.ApplicationCommandInteractionData
+		want string
+		ok   bool
+	}{
+		{discordgo.ApplicationCommandInteractionData{Name: "ask", Options: str("prompt", " what is Go? ")}, "what is Go?", true},
+		{discordgo.ApplicationCommandInteractionData{Name: "reset"}, "/clear", true},
+		{discordgo.ApplicationCommandInteractionData{Name: "model"}, "/show model", true},
+		{discordgo.ApplicationCommandInteractionData{Name: "model", Options: str("name", "gpt-4o")}, "/switch model to gpt-4o", true},
+		{discordgo.ApplicationCommandInteractionData{Name: "help"}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := agentCommandText(tt.data)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("agentCommandText(/%s) = %q, %v; want %q, %v", tt.data.Name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSummarizeCount(t *testing.T) {
+	count := func(v float64) discordgo.ApplicationCommandInteractionData {
+		return discordgo.ApplicationCommandInteractionData{
+			Name: "summarize",
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+				Name: "messages", Type: discordgo.ApplicationCommandOptionInteger, Value: v,
+			}},
+		}
+	}
+	if got := summarizeCount(discordgo.ApplicationCommandInteractionData{Name: "summarize"}); got != defaultSummarizeMessages {
+		t.Errorf("default = %d", got)
+	}
+	if got := summarizeCount(count(20)); got != 20 {
+		t.Errorf("count = %d, want 20", got)
+	}
+	if got := summarizeCount(count(5000)); got != maxSummarizeMessages {
+		t.Errorf("count = %d, want the maximum", got)
+	}
+}
+
+func TestDeferredInteractions(t *testing.T) {
+	c := &DiscordChannel{deferred: make(map[string][]deferredInteraction)}
+	first := &discordgo.Interaction{ID: "1"}
+	second := &discordgo.Interaction{ID: "2"}
+	c.deferReply("chan", first)
+	c.deferReply("chan", second)
+
+	if !c.hasDeferred("chan") || c.hasDeferred("other") {
+		t.Fatal("hasDeferred does not match the queued commands")
+	}
+	if got := c.takeDeferred("chan"); got != first {
+		t.Errorf("first take = %v, want the oldest command", got)
+	}
+	if got := c.takeDeferred("chan"); got != second {
+		t.Errorf("second take = %v", got)
+	}
+	if got := c.takeDeferred("chan"); got != nil || len(c.deferred) != 0 {
+		t.Errorf("empty queue returned %v, left %v", got, c.deferred)
+	}
+
+	// Discord rejects edits after the interaction token expires
+	c.deferred["chan"] = []deferredInteraction{{interaction: first, at: time.Now().Add(-interactionTTL)}}
+	if c.hasDeferred("chan") || c.takeDeferred("chan") != nil {
+		t.Error("expired interaction was returned")
+	}
+}
+
+func TestPrivateReplies(t *testing.T) {
+	c := &DiscordChannel{
+		private: make(map[string]privateReply),
+		ephemeral: config.EphemeralConfig{
+			Enabled:        true,
+			Channels:       []string{"discord:secret"},
+			PrivateReplies: true,
+		},
+	}
+	sender := bus.SenderInfo{Platform: "discord", PlatformID: "42"}
+	if !c.wantsPrivateReply("secret", sender) || c.wantsPrivateReply("lobby", sender) {
+		t.Fatal("wantsPrivateReply does not follow the ephemeral channels")
+	}
+
+	// A snowflake minted now, so the token counts as fresh
+	id := strconv.FormatInt((time.Now().UnixMilli()-1420070400000)<<22, 10)
+	i := &discordgo.Interaction{ID: id}
+	c.markPrivate("secret", i)
+	if c.privateFollowup("secret", "req-1") != nil {
+		t.Error("an unanswered command took a follow-up")
+	}
+	c.answeredPrivately(i, "req-1")
+	if got := c.privateFollowup("secret", "req-1"); got != i {
+		t.Errorf("privateFollowup = %v, want the answered command", got)
+	}
+	if c.privateFollowup("secret", "req-2") != nil || c.privateFollowup("secret", "") != nil {
+		t.Error("another turn's reply would follow the private response")
+	}
+
+	public := &discordgo.Interaction{ID: "7"}
+	c.answeredPrivately(public, "req-3")
+	if c.privateFollowup("secret", "req-3") != nil {
+		t.Error("a public response was treated as private")
+	}
+}
@@ -0,0 +1,112 @@
+package discord
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// interactionTTL is how long Discord accepts edits of a deferred response.
+const interactionTTL = 15 * time.Minute
+
+// deferredInteraction is a slash command acknowledged with "thinking…" whose
+// response is filled in by the next reply to its channel.
+type deferredInteraction struct {
+	interaction *discordgo.Interaction
+	at          time.Time
+}
+
+// deferReply marks i as waiting for the next reply to chatID. Replies are
+// matched to commands in the order the commands came in.
+func (c *DiscordChannel) deferReply(chatID string, i *discordgo.Interaction) {
+	c.deferredMu.Lock()
+	defer c.deferredMu.Unlock()
+	c.deferred[chatID] = append(c.deferred[chatID], deferredInteraction{interaction: i, at: time.Now()})
+}
+
+// takeDeferred returns the oldest deferred interaction of chatID that can
+// still be answered, dropping expired ones.
+func (c *DiscordChannel) takeDeferred(chatID string) *discordgo.Interaction {
+	c.deferredMu.Lock()
+	defer c.deferredMu.Unlock()
+	queue := c.deferred[chatID]
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		if time.Since(d.at) < interactionTTL {
+			c.setDeferred(chatID, queue)
+			return d.interaction
+		}
+	}
+	c.setDeferred(chatID, nil)
+	return nil
+}
+
+// hasDeferred reports whether a reply to chatID would fill a deferred
+// response.
+func (c *DiscordChannel) hasDeferred(chatID string) bool {
+	c.deferredMu.Lock()
+	defer c.deferredMu.Unlock()
+	for _, d := range c.deferred[chatID] {
+		if time.Since(d.at) < interactionTTL {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *DiscordChannel) setDeferred(chatID string, queue []deferredInteraction) {
+	if len(queue) == 0 {
+		delete(c.deferred, chatID)
+		return
+	}
+	c.deferred[chatID] = queue
+}
+
+// answerDeferred replaces the "thinking…" of a deferred response with
+// content.
+func (c *DiscordChannel) answerDeferred(ctx context.Context, i *discordgo.Interaction, content string) error {
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	sent, err := c.session.InteractionResponseEdit(i, &discordgo.WebhookEdit{Content: &content},
+		discordgo.WithContext(sendCtx))
+	if err != nil {
+		return err
+	}
+	if sent != nil {
+		c.rememberAnswer(sent.ID, i)
+		c.RecordReply(i.ChannelID, sent.ID)
+	}
+	return nil
+}
+
+// rememberAnswer keeps the interaction a response message belongs to, since
+// later edits of it have to go through the interaction.
+func (c *DiscordChannel) rememberAnswer(messageID string, i *discordgo.Interaction) {
+	c.deferredMu.Lock()
+	defer c.deferredMu.Unlock()
+	for id, d := range c.answered {
+		if time.Since(d.at) >= interactionTTL {
+			delete(c.answered, id)
+		}
+	}
+	// The token expires a fixed time after the command was invoked
+	at, err := discordgo.SnowflakeTimestamp(i.ID)
+	if err != nil {
+		at = time.Now()
+	}
+	c.answered[messageID] = deferredInteraction{interaction: i, at: at}
+}
+
+// answeredInteraction returns the interaction whose response is messageID,
+// while it can still be edited.
+func (c *DiscordChannel) answeredInteraction(messageID string) *discordgo.Interaction {
+	c.deferredMu.Lock()
+	defer c.deferredMu.Unlock()
+	d, ok := c.answered[messageID]
+	if !ok || time.Since(d.at) >= interactionTTL {
+		return nil
+	}
+	return d.interaction
+}
@@ -17,14 +17,23 @@ import (
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/format"
 	"github.com/sipeed/picoclaw/pkg/identity"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/utils"
+	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
 const (
 	sendTimeout = 10 * time.Second
+	// discordMessageLimit is Discord's content limit for bot messages. Nitro
+	// and server boosts raise it only for users, so it is not queried;
+	// max_message_length can lower it but not raise it.
+	discordMessageLimit = 2000
+	// defaultStreamEditInterval keeps streamed edits within Discord's limit
+	// of five edits per five seconds, leaving room for other messages.
+	defaultStreamEditInterval = 1500 * time.Millisecond
 )
 
 var (
@@ -42,6 +51,35 @@ type DiscordChannel struct {
 	typingMu   sync.Mutex
 	typingStop map[string]chan struct{} // chatID → stop signal
 	botUserID  string                   // stored for mention checking
+	// shards holds the open gateway sessions; shards[0] is session.
+	shards         []*discordgo.Session
+	removeHandlers []func()
+
+	deferredMu sync.Mutex
+	deferred   map[string][]deferredInteraction // chatID → slash commands awaiting a reply
+	answered   map[string]deferredInteraction   // message ID → interaction it answers
+	private    map[string]privateReply          // interaction ID → private slash command
+	ephemeral  config.EphemeralConfig
+
+	voiceMu sync.Mutex
+	voices  map[string]*voiceSession // guild ID → voice channel the bot is in
+	speech  voice.Synthesizer        // speaks replies in voice channels; nil = text only
+}
+
+func newDiscordSession(cfg config.DiscordConfig) (*discordgo.Session, error) {
+	session, err := discordgo.New("Bot " + cfg.Token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discord session: %w", err)
+	}
+	if err := applyDiscordProxy(session, cfg.Proxy); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// newSession creates an additional gateway session for another shard.
+func (c *DiscordChannel) newSession() (*discordgo.Session, error) {
+	return newDiscordSession(c.config)
 }
 
 func NewDiscordChannel(cfg config.DiscordConfig, bus *bus.MessageBus) (*DiscordChannel, error) {
@@ -53,18 +91,24 @@ func NewDiscordChannel(cfg config.DiscordConfig, bus *bus.MessageBus) (*DiscordC
 			discordgo.LogDebug:         logger.DEBUG,
 		}).Log
 
-	session, err := discordgo.New("Bot " + cfg.Token)
+	session, err := newDiscordSession(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create discord session: %w", err)
-	}
-
-	if err := applyDiscordProxy(session, cfg.Proxy); err != nil {
 		return nil, err
 	}
+	maxLen := discordMessageLimit
+	if cfg.MaxMessageLength > 0 {
+		maxLen = min(cfg.MaxMessageLength, discordMessageLimit)
+	}
+
 	base := channels.NewBaseChannel("discord", cfg, bus, cfg.AllowFrom,
-		channels.WithMaxMessageLength(2000),
+		channels.WithMaxMessageLength(maxLen),
+		// Discord counts code points, not bytes or UTF-16 units.
+		channels.WithLengthFunc(channels.RuneLength),
+		channels.WithLengthUnit(cfg.LengthUnit),
 		channels.WithGroupTrigger(cfg.GroupTrigger),
 		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+		channels.WithLongOutput(cfg.LongOutput),
+		channels.WithCustomEmoji(cfg.CustomEmoji),
 	)
 
 	return &DiscordChannel{
@@ -73,6 +117,10 @@ func NewDiscordChannel(cfg config.DiscordConfig, bus *bus.MessageBus) (*DiscordC
 		config:      cfg,
 		ctx:         context.Background(),
 		typingStop:  make(map[string]chan struct{}),
+		deferred:    make(map[string][]deferredInteraction),
+		answered:    make(map[string]deferredInteraction),
+		private:     make(map[string]privateReply),
+		voices:      make(map[string]*voiceSession),
 	}, nil
 }
 
@@ -88,13 +136,12 @@ func (c *DiscordChannel) Start(ctx context.Context) error {
 	}
 	c.botUserID = botUser.ID
 
-	c.session.AddHandler(c.handleMessage)
-
-	if err := c.session.Open(); err != nil {
-		return fmt.Errorf("failed to open discord session: %w", err)
+	if err := c.openShards(c.ctx); err != nil {
+		return err
 	}
 
 	c.SetRunning(true)
+	c.registerSlashCommands()
 
 	logger.InfoCF("discord", "Discord bot connected", map[string]any{
 		"username": botUser.Username,
@@ -116,12 +163,14 @@ func (c *DiscordChannel) Stop(ctx context.Context) error {
 	}
 	c.typingMu.Unlock()
 
+	c.leaveAllVoice()
+
 	// Cancel our context so typing goroutines using c.ctx.Done() exit
 	if c.cancel != nil {
 		c.cancel()
 	}
 
-	if err := c.session.Close(); err != nil {
+	if err := c.closeShards(); err != nil {
 		return fmt.Errorf("failed to close discord session: %w", err)
 	}
 
@@ -142,7 +191,44 @@ func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 		return nil
 	}
 
-	return c.sendChunk(ctx, channelID, msg.Content, msg.ReplyToMessageID)
+	content := format.Render(msg.Content, format.Discord)
+
+	// The rest of a reply that began in a private response stays private
+	if i := c.privateFollowup(channelID, msg.RequestID); i != nil {
+		return c.sendPrivateFollowup(ctx, i, content)
+	}
+
+	// The first reply after a slash command fills its deferred response
+	if i := c.takeDeferred(channelID); i != nil {
+		err := c.answerDeferred(ctx, i, content)
+		if err == nil {
+			c.answeredPrivately(i, msg.RequestID)
+			return nil
+		}
+		logger.DebugCF("discord", "Failed to answer deferred slash command", map[string]any{
+			"error": err.Error(),
+		})
+	}
+
+	if err := c.sendChunk(ctx, channelID, content, msg.ReplyToMessageID); err != nil {
+		return err
+	}
+	// Agent replies in a voice channel's chat are also spoken there
+	if msg.TurnID != "" && !msg.Mute {
+		c.speakReply(channelID, msg.Content)
+	}
+	return nil
+}
+
+// SupportsANSI implements channels.ANSICapable: Discord colors ```ansi blocks.
+func (c *DiscordChannel) SupportsANSI() bool {
+	return true
+}
+
+// SupportsReplyReactions implements channels.ReplyReactionCapable: reactions
+// to the bot's messages are always reported.
+func (c *DiscordChannel) SupportsReplyReactions() bool {
+	return true
 }
 
 // SendMedia implements the channels.MediaSender interface.
@@ -242,15 +328,63 @@ func (c *DiscordChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMes
 
 // EditMessage implements channels.MessageEditor.
 func (c *DiscordChannel) EditMessage(ctx context.Context, chatID string, messageID string, content string) error {
-	_, err := c.session.ChannelMessageEdit(chatID, messageID, content)
+	content = format.Render(content, format.Discord)
+	if i := c.answeredInteraction(messageID); i != nil {
+		_, err := c.session.InteractionResponseEdit(i, &discordgo.WebhookEdit{Content: &content},
+			discordgo.WithContext(ctx))
+		return err
+	}
+	// Clear components so a placeholder's stop button goes away with it.
+	_, err := c.session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         messageID,
+		Channel:    chatID,
+		Content:    &content,
+		Components: &[]discordgo.MessageComponent{},
+	})
 	return err
 }
 
+// DeleteMessage implements channels.MessageDeleter.
+func (c *DiscordChannel) DeleteMessage(ctx context.Context, chatID string, messageID string) error {
+	if i := c.answeredInteraction(messageID); i != nil {
+		return c.session.InteractionResponseDelete(i, discordgo.WithContext(ctx))
+	}
+	return c.session.ChannelMessageDelete(chatID, messageID, discordgo.WithContext(ctx))
+}
+
+// StreamingDefault implements channels.StreamingCapable.
+func (c *DiscordChannel) StreamingDefault() (bool, time.Duration) {
+	interval := defaultStreamEditInterval
+	if c.config.Streaming.EditIntervalMS > 0 {
+		interval = time.Duration(c.config.Streaming.EditIntervalMS) * time.Millisecond
+	}
+	return c.config.Streaming.Enabled, interval
+}
+
+// BeginStream implements channels.StreamingCapable. After a slash command
+// the stream is written into its deferred response.
+func (c *DiscordChannel) BeginStream(ctx context.Context, chatID, content string) (string, error) {
+	if i := c.takeDeferred(chatID); i != nil {
+		sent, err := c.session.InteractionResponseEdit(i, &discordgo.WebhookEdit{Content: &content},
+			discordgo.WithContext(ctx))
+		if err == nil {
+			c.rememberAnswer(sent.ID, i)
+			return sent.ID, nil
+		}
+	}
+	sent, err := c.session.ChannelMessageSend(chatID, content, discordgo.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	return sent.ID, nil
+}
+
 // SendPlaceholder implements channels.PlaceholderCapable.
 // It sends a placeholder message that will later be edited to the actual
 // response via EditMessage (channels.MessageEditor).
 func (c *DiscordChannel) SendPlaceholder(ctx context.Context, chatID string) (string, error) {
-	if !c.config.Placeholder.Enabled {
+	// A deferred slash command already shows that the bot is thinking
+	if !c.config.Placeholder.Enabled || c.hasDeferred(chatID) {
 		return "", nil
 	}
 
@@ -259,7 +393,18 @@ func (c *DiscordChannel) SendPlaceholder(ctx context.Context, chatID string) (st
 		text = "Thinking... 💭"
 	}
 
-	msg, err := c.session.ChannelMessageSend(chatID, text)
+	send := &discordgo.MessageSend{Content: text}
+	if c.config.Placeholder.StopButton {
+		send.Components = []discordgo.MessageComponent{discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{discordgo.Button{
+				Label:    "Stop",
+				Style:    discordgo.DangerButton,
+				CustomID: channels.StopButtonID,
+				Emoji:    &discordgo.ComponentEmoji{Name: "⏹️"},
+			}},
+		}}
+	}
+	msg, err := c.session.ChannelMessageSendComplex(chatID, send)
 	if err != nil {
 		return "", err
 	}
@@ -276,9 +421,11 @@ func (c *DiscordChannel) sendChunk(ctx context.Context, channelID, content, repl
 	go func() {
 		var err error
 
+		var sent *discordgo.Message
+
 		// If we have an ID, we send the message as "Reply"
 		if replyToID != "" {
-			_, err = c.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			sent, err = c.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
 				Content: content,
 				Reference: &discordgo.MessageReference{
 					MessageID: replyToID,
@@ -287,7 +434,10 @@ func (c *DiscordChannel) sendChunk(ctx context.Context, channelID, content, repl
 			})
 		} else {
 			// Otherwise, we send a normal message
-			_, err = c.session.ChannelMessageSend(channelID, content)
+			sent, err = c.session.ChannelMessageSend(channelID, content)
+		}
+		if err == nil && sent != nil {
+			c.RecordReply(channelID, sent.ID)
 		}
 
 		done <- err
@@ -313,6 +463,85 @@ func appendContent(content, suffix string) string {
 }
 
 func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m == nil {
+		return
+	}
+	c.handleInbound(s, m.Message, false)
+}
+
+// handleMessageUpdate forwards edits of user messages. Updates without an
+// edit timestamp only attach link previews and are ignored.
+func (c *DiscordChannel) handleMessageUpdate(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	if m == nil || m.Message == nil || m.EditedTimestamp == nil {
+		return
+	}
+	c.handleInbound(s, m.Message, true)
+}
+
+// handleMessageDelete cancels the reply to a message the user deleted while
+// it was being answered.
+func (c *DiscordChannel) handleMessageDelete(s *discordgo.Session, m *discordgo.MessageDelete) {
+	if m == nil || m.Message == nil {
+		return
+	}
+	c.HandlePromptDeleted(c.ctx, m.ChannelID, m.ID)
+}
+
+// handleInteraction handles slash commands and presses of the stop button on
+// placeholders.
+func (c *DiscordChannel) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i != nil && i.Interaction != nil && i.Type == discordgo.InteractionApplicationCommand {
+		c.handleSlashCommand(s, i)
+		return
+	}
+	if i == nil || i.Interaction == nil || i.Type != discordgo.InteractionMessageComponent ||
+		i.MessageComponentData().CustomID != channels.StopButtonID {
+		return
+	}
+	// Acknowledge without changing the message; the reply edits it later.
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	}); err != nil {
+		logger.DebugCF("discord", "Failed to acknowledge interaction", map[string]any{
+			"error": err.Error(),
+		})
+	}
+
+	user := i.User
+	if i.Member != nil && i.Member.User != nil {
+		user = i.Member.User
+	}
+	if user == nil {
+		return
+	}
+	c.HandleStopButton(c.ctx, i.ChannelID, bus.SenderInfo{
+		Platform:    "discord",
+		PlatformID:  user.ID,
+		CanonicalID: identity.BuildCanonicalID("discord", user.ID),
+		Username:    user.Username,
+		DisplayName: user.Username,
+	})
+}
+
+// handleReactionAdd reports reactions to the bot's replies, which rate them
+// or act on them.
+func (c *DiscordChannel) handleReactionAdd(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	if r == nil || r.MessageReaction == nil || (s.State.User != nil && r.UserID == s.State.User.ID) {
+		return
+	}
+	sender := bus.SenderInfo{
+		Platform:    "discord",
+		PlatformID:  r.UserID,
+		CanonicalID: identity.BuildCanonicalID("discord", r.UserID),
+	}
+	if r.Member != nil && r.Member.User != nil {
+		sender.Username = r.Member.User.Username
+		sender.DisplayName = r.Member.User.Username
+	}
+	c.HandleReaction(r.ChannelID, r.MessageID, r.Emoji.Name, sender)
+}
+
+func (c *DiscordChannel) handleInbound(s *discordgo.Session, m *discordgo.Message, edited bool) {
 	if m == nil || m.Author == nil {
 		return
 	}
@@ -393,11 +622,12 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 	scope := channels.BuildMediaScope("discord", m.ChannelID, m.ID)
 
 	// Helper to register a local file with the media store
-	storeMedia := func(localPath, filename string) string {
+	storeMedia := func(localPath, filename, contentType string) string {
 		if store := c.GetMediaStore(); store != nil {
 			ref, err := store.Store(localPath, media.MediaMeta{
-				Filename: filename,
-				Source:   "discord",
+				Filename:    filename,
+				ContentType: contentType,
+				Source:      "discord",
 			}, scope)
 			if err == nil {
 				return ref
@@ -407,25 +637,31 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 	}
 
 	for _, attachment := range m.Attachments {
-		isAudio := utils.IsAudioFile(attachment.Filename, attachment.ContentType)
+		// Audio is downloaded for transcription and images so vision models
+		// get the image itself rather than a CDN link; the agent scales
+		// images down if needed. Anything else is passed on as a link.
+		kind, contentType := "", ""
+		switch {
+		case isVisionImage(attachment):
+			kind, contentType = "image", attachment.ContentType
+		case utils.IsAudioFile(attachment.Filename, attachment.ContentType):
+			kind = "audio"
+		}
 
-		if isAudio {
+		if kind != "" {
 			localPath := c.downloadAttachment(attachment.URL, attachment.Filename)
 			if localPath != "" {
-				mediaPaths = append(mediaPaths, storeMedia(localPath, attachment.Filename))
-				content = appendContent(content, fmt.Sprintf("[audio: %s]", attachment.Filename))
-			} else {
-				logger.WarnCF("discord", "Failed to download audio attachment", map[string]any{
-					"url":      attachment.URL,
-					"filename": attachment.Filename,
-				})
-				mediaPaths = append(mediaPaths, attachment.URL)
-				content = appendContent(content, fmt.Sprintf("[attachment: %s]", attachment.URL))
+				mediaPaths = append(mediaPaths, storeMedia(localPath, attachment.Filename, contentType))
+				content = appendContent(content, fmt.Sprintf("[%s: %s]", kind, attachment.Filename))
+				continue
 			}
-		} else {
-			mediaPaths = append(mediaPaths, attachment.URL)
-			content = appendContent(content, fmt.Sprintf("[attachment: %s]", attachment.URL))
+			logger.WarnCF("discord", "Failed to download "+kind+" attachment", map[string]any{
+				"url":      attachment.URL,
+				"filename": attachment.Filename,
+			})
 		}
+		mediaPaths = append(mediaPaths, attachment.URL)
+		content = appendContent(content, fmt.Sprintf("[attachment: %s]", attachment.URL))
 	}
 
 	if content == "" && len(mediaPaths) == 0 {
@@ -439,7 +675,7 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 	logger.DebugCF("discord", "Received message", map[string]any{
 		"sender_name": sender.DisplayName,
 		"sender_id":   senderID,
-		"preview":     utils.Truncate(content, 50),
+		"preview":     c.LogPreview(m.ChannelID, senderID, content, 50, sender),
 	})
 
 	peerKind := "channel"
@@ -459,10 +695,30 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		"channel_id":   m.ChannelID,
 		"is_dm":        fmt.Sprintf("%t", m.GuildID == ""),
 	}
+	if edited {
+		metadata[channels.MetadataKeyEdited] = "true"
+	}
+	if m.Member != nil && len(m.Member.Roles) > 0 {
+		metadata[channels.MetadataKeyRoles] = strings.Join(m.Member.Roles, ",")
+	}
+	if m.GuildID != "" && c.session != nil {
+		if perms, err := c.session.State.MessagePermissions(m); err == nil && isGuildAdmin(perms) {
+			metadata[channels.MetadataKeyGuildAdmin] = "true"
+		}
+		// Messages carry no user locale, only the server has one
+		if g, err := c.session.State.Guild(m.GuildID); err == nil && g.PreferredLocale != "" {
+			metadata[channels.MetadataKeyLocale] = g.PreferredLocale
+		}
+	}
 
 	c.HandleMessage(c.ctx, peer, m.ID, senderID, m.ChannelID, content, mediaPaths, metadata, sender)
 }
 
+// isGuildAdmin reports whether perms let a member manage the server.
+func isGuildAdmin(perms int64) bool {
+	return perms&(discordgo.PermissionAdministrator|discordgo.PermissionManageGuild) != 0
+}
+
 // startTyping starts a continuous typing indicator loop for the given chatID.
 // It stops any existing typing loop for that chatID before starting a new one.
 func (c *DiscordChannel) startTyping(chatID string) {
@@ -516,6 +772,21 @@ func (c *DiscordChannel) StartTyping(ctx context.Context, chatID string) (func()
 	return func() { c.stopTyping(chatID) }, nil
 }
 
+// visionImageTypes are the image formats vision models accept.
+var visionImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// isVisionImage reports whether attachment is an image a vision model can
+// look at. Larger images than the agent would send are left as links.
+func isVisionImage(attachment *discordgo.MessageAttachment) bool {
+	contentType, _, _ := strings.Cut(attachment.ContentType, ";")
+	return visionImageTypes[strings.TrimSpace(contentType)] && attachment.Size <= config.DefaultMaxMediaSize
+}
+
 func (c *DiscordChannel) downloadAttachment(url, filename string) string {
 	return utils.DownloadFile(url, filename, utils.DownloadOptions{
 		LoggerPrefix: "discord",
@@ -6,6 +6,9 @@ import (
 	"testing"
 
 	"github.com/bwmarrin/discordgo"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 func TestApplyDiscordProxy_CustomProxy(t *testing.T) {
@@ -89,3 +92,81 @@ func TestApplyDiscordProxy_InvalidProxyURL(t *testing.T) {
 		t.Fatal("applyDiscordProxy() expected error for invalid proxy URL, got nil")
 	}
 }
+
+func TestNewDiscordChannel_MaxMessageLength(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+
+	ch, err := NewDiscordChannel(config.DiscordConfig{Token: "test-token"}, msgBus)
+	if err != nil {
+		t.Fatalf("NewDiscordChannel() error: %v", err)
+	}
+	if got := ch.MaxMessageLength(); got != discordMessageLimit {
+		t.Errorf("MaxMessageLength() = %d, want default %d", got, discordMessageLimit)
+	}
+
+	ch, err = NewDiscordChannel(config.DiscordConfig{Token: "test-token", MaxMessageLength: 1500}, msgBus)
+	if err != nil {
+		t.Fatalf("NewDiscordChannel() error: %v", err)
+	}
+	if got := ch.MaxMessageLength(); got != 1500 {
+		t.Errorf("MaxMessageLength() = %d, want configured 1500", got)
+	}
+
+	ch, err = NewDiscordChannel(config.DiscordConfig{Token: "test-token", MaxMessageLength: 4000}, msgBus)
+	if err != nil {
+		t.Fatalf("NewDiscordChannel() error: %v", err)
+	}
+	if got := ch.MaxMessageLength(); got != discordMessageLimit {
+		t.Errorf("MaxMessageLength() = %d, want it capped at %d", got, discordMessageLimit)
+	}
+}
+
+func TestIsVisionImage(t *testing.T) {
+	for _, tt := range []struct {
+		contentType string
+		size        int
+		want        bool
+	}{
+		{"image/png", 1024, true},
+		{"image/jpeg; charset=binary", 1024, true},
+		{"image/webp", 1024, true},
+		{"image/svg+xml", 1024, false},
+		{"application/pdf", 1024, false},
+		{"image/png", config.DefaultMaxMediaSize + 1, false},
+	} {
+		a := &discordgo.MessageAttachment{ContentType: tt.contentType, Size: tt.size}
+		if got := isVisionImage(a); got != tt.want {
+			t.Errorf("isVisionImage(%q, %d) = %v, want %v", tt.contentType, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestIsGuildAdmin(t *testing.T) {
+	for perms, want := range map[int64]bool{
+		discordgo.PermissionAdministrator:                                     true,
+		discordgo.PermissionManageGuild | discordgo.PermissionSendMessages:    true,
+		discordgo.PermissionSendMessages | discordgo.PermissionManageMessages: false,
+		0: false,
+	} {
+		if got := isGuildAdmin(perms); got != want {
+			t.Errorf("isGuildAdmin(%b) = %v, want %v", perms, got, want)
+		}
+	}
+}
+
+func TestInteractionLocale(t *testing.T) {
+	guild := discordgo.Locale("de")
+	tests := []struct {
+		i    discordgo.Interaction
+		want string
+	}{
+		{discordgo.Interaction{Locale: discordgo.PortugueseBR, GuildLocale: &guild}, "pt-BR"},
+		{discordgo.Interaction{GuildLocale: &guild}, "de"},
+		{discordgo.Interaction{}, ""},
+	}
+	for _, tt := range tests {
+		if got := interactionLocale(&tt.i); got != tt.want {
+			t.Errorf("interactionLocale(%+v) = %q, want %q", tt.i, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package discord
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/sipeed/picoclaw/pkg/channels"
+)
+
+// historyPageSize is the most messages Discord returns per request.
+const historyPageSize = 100
+
+// ReadHistory implements channels.HistoryCapable. It needs the View Channel
+// and Read Message History permissions in the channel; without them Discord
+// answers 403 and the error is returned as is.
+func (c *DiscordChannel) ReadHistory(
+	ctx context.Context,
+	chatID string,
+	since time.Time,
+	limit int,
+) ([]channels.HistoryMessage, error) {
+	var (
+		out    []channels.HistoryMessage
+		before string
+	)
+	// Pages come newest first; out is reversed before it is returned.
+	for limit <= 0 || len(out) < limit {
+		page, err := c.session.ChannelMessages(chatID, historyPageSize, before, "", "", discordgo.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range page {
+			if m.Timestamp.Before(since) {
+				slices.Reverse(out)
+				return out, nil
+			}
+			if m.Author == nil || m.Content == "" {
+				continue
+			}
+			out = append(out, channels.HistoryMessage{
+				ID:     m.ID,
+				Time:   m.Timestamp,
+				Author: m.Author.Username,
+				Bot:    m.Author.Bot,
+				Text:   m.Content,
+			})
+			if limit > 0 && len(out) == limit {
+				break
+			}
+		}
+		if len(page) < historyPageSize {
+			break
+		}
+		before = page[len(page)-1].ID
+	}
+	slices.Reverse(out)
+	return out, nil
+}
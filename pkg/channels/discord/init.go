@@ -4,10 +4,19 @@ import (
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
 func init() {
 	channels.RegisterFactory("discord", func(cfg *config.Config, b *bus.MessageBus) (channels.Channel, error) {
-		return NewDiscordChannel(cfg.Channels.Discord, b)
+		ch, err := NewDiscordChannel(cfg.Channels.Discord, b)
+		if err != nil {
+			return nil, err
+		}
+		ch.ephemeral = cfg.Ephemeral
+		if cfg.Channels.Discord.Voice.SpeakReplies {
+			ch.speech = voice.DetectSynthesizer(cfg)
+		}
+		return ch, nil
 	})
 }
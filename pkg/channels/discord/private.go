@@ -0,0 +1,84 @@
+package discord
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+)
+
+// privateReply is a slash command of an ephemeral conversation, answered
+// with a response only the user who ran it can see.
+type privateReply struct {
+	interaction *discordgo.Interaction
+	chatID      string
+	requestID   string // the turn the response belongs to, once answered
+	at          time.Time
+}
+
+// wantsPrivateReply reports whether a slash command from sender in chatID is
+// answered privately: ephemeral.private_replies is on and the conversation is
+// ephemeral.
+func (c *DiscordChannel) wantsPrivateReply(chatID string, sender bus.SenderInfo) bool {
+	return c.ephemeral.PrivateReplies &&
+		channels.IsEphemeral(c.ephemeral, "discord", chatID, sender.PlatformID, sender)
+}
+
+// markPrivate records that the deferred response of i is private.
+func (c *DiscordChannel) markPrivate(chatID string, i *discordgo.Interaction) {
+	c.deferredMu.Lock()
+	defer c.deferredMu.Unlock()
+	for id, p := range c.private {
+		if time.Since(p.at) >= interactionTTL {
+			delete(c.private, id)
+		}
+	}
+	at, err := discordgo.SnowflakeTimestamp(i.ID)
+	if err != nil {
+		at = time.Now()
+	}
+	c.private[i.ID] = privateReply{interaction: i, chatID: chatID, at: at}
+}
+
+// answeredPrivately ties the private response of i to the turn requestID, so
+// the rest of that turn's reply follows it privately. Public responses are
+// left alone.
+func (c *DiscordChannel) answeredPrivately(i *discordgo.Interaction, requestID string) {
+	c.deferredMu.Lock()
+	defer c.deferredMu.Unlock()
+	if p, ok := c.private[i.ID]; ok {
+		p.requestID = requestID
+		c.private[i.ID] = p
+	}
+}
+
+// privateFollowup returns the private slash command whose response started
+// the reply to requestID in chatID, while its token is still valid.
+func (c *DiscordChannel) privateFollowup(chatID, requestID string) *discordgo.Interaction {
+	if requestID == "" {
+		return nil
+	}
+	c.deferredMu.Lock()
+	defer c.deferredMu.Unlock()
+	for _, p := range c.private {
+		if p.chatID == chatID && p.requestID == requestID && time.Since(p.at) < interactionTTL {
+			return p.interaction
+		}
+	}
+	return nil
+}
+
+// sendPrivateFollowup sends content as a follow-up of i that only the user
+// who ran the command can see.
+func (c *DiscordChannel) sendPrivateFollowup(ctx context.Context, i *discordgo.Interaction, content string) error {
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	_, err := c.session.FollowupMessageCreate(i, true, &discordgo.WebhookParams{
+		Content: content,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	}, discordgo.WithContext(sendCtx))
+	return err
+}
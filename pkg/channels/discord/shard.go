@@ -0,0 +1,132 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// identifyInterval is Discord's window for one identify per concurrency bucket.
+const identifyInterval = 5 * time.Second
+
+// shardPlan describes which gateway shards this process opens.
+type shardPlan struct {
+	count          int   // total shards across all processes
+	ids            []int // shards opened by this process, ascending
+	maxConcurrency int   // identifies allowed per interval
+}
+
+// planShards resolves the shard layout. When no count is configured, the
+// count Discord recommends for the bot is used; gateway is only called then.
+func planShards(cfg config.DiscordShardConfig, gateway func() (*discordgo.GatewayBotResponse, error)) (shardPlan, error) {
+	plan := shardPlan{count: cfg.Count, maxConcurrency: 1}
+
+	if plan.count <= 0 {
+		resp, err := gateway()
+		if err != nil {
+			if len(cfg.IDs) > 0 {
+				return plan, fmt.Errorf("discord: shard ids set but shard count unknown: %w", err)
+			}
+			logger.WarnCF("discord", "Could not fetch recommended shard count, using a single shard", map[string]any{
+				"error": err.Error(),
+			})
+			plan.count = 1
+		} else {
+			plan.count = max(resp.Shards, 1)
+			plan.maxConcurrency = max(resp.SessionStartLimit.MaxConcurrency, 1)
+		}
+	}
+
+	if len(cfg.IDs) == 0 {
+		for id := range plan.count {
+			plan.ids = append(plan.ids, id)
+		}
+		return plan, nil
+	}
+
+	plan.ids = slices.Clone(cfg.IDs)
+	slices.Sort(plan.ids)
+	plan.ids = slices.Compact(plan.ids)
+	for _, id := range plan.ids {
+		if id < 0 || id >= plan.count {
+			return plan, fmt.Errorf("discord: shard id %d out of range for %d shards", id, plan.count)
+		}
+	}
+	return plan, nil
+}
+
+// openShards opens one gateway session per planned shard. The primary session
+// runs the first shard and keeps serving REST calls for every shard.
+func (c *DiscordChannel) openShards(ctx context.Context) error {
+	plan, err := planShards(c.config.Shards, func() (*discordgo.GatewayBotResponse, error) {
+		return c.session.GatewayBot()
+	})
+	if err != nil {
+		return err
+	}
+
+	c.shards = c.shards[:0]
+	for i, id := range plan.ids {
+		// Shards in the same concurrency bucket may identify together;
+		// the next bucket has to wait for the rate-limit window.
+		if i > 0 && i%plan.maxConcurrency == 0 {
+			select {
+			case <-time.After(identifyInterval):
+			case <-ctx.Done():
+				c.closeShards()
+				return ctx.Err()
+			}
+		}
+
+		s := c.session
+		if i > 0 {
+			if s, err = c.newSession(); err != nil {
+				c.closeShards()
+				return err
+			}
+		}
+		s.ShardID = id
+		s.ShardCount = plan.count
+		c.removeHandlers = append(c.removeHandlers,
+			s.AddHandler(c.handleMessage), s.AddHandler(c.handleMessageUpdate),
+			s.AddHandler(c.handleMessageDelete), s.AddHandler(c.handleInteraction),
+			s.AddHandler(c.handleReactionAdd))
+
+		if err := s.Open(); err != nil {
+			c.closeShards()
+			return fmt.Errorf("failed to open discord shard %d/%d: %w", id, plan.count, err)
+		}
+		c.shards = append(c.shards, s)
+	}
+
+	if plan.count > 1 {
+		logger.InfoCF("discord", "Discord shards connected", map[string]any{
+			"shard_count": plan.count,
+			"shard_ids":   plan.ids,
+		})
+	}
+	return nil
+}
+
+// closeShards closes every open shard session and returns the first error.
+func (c *DiscordChannel) closeShards() error {
+	for _, remove := range c.removeHandlers {
+		remove()
+	}
+	c.removeHandlers = nil
+
+	var firstErr error
+	for _, s := range c.shards {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	c.shards = nil
+	return firstErr
+}
@@ -0,0 +1,63 @@
+package discord
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestPlanShards(t *testing.T) {
+	recommended := func() (*discordgo.GatewayBotResponse, error) {
+		return &discordgo.GatewayBotResponse{
+			Shards:            4,
+			SessionStartLimit: discordgo.SessionInformation{MaxConcurrency: 2},
+		}, nil
+	}
+	failing := func() (*discordgo.GatewayBotResponse, error) {
+		return nil, errors.New("boom")
+	}
+	mustNotCall := func() (*discordgo.GatewayBotResponse, error) {
+		t.Fatal("gateway must not be queried when the count is configured")
+		return nil, nil
+	}
+
+	tests := []struct {
+		name     string
+		cfg      config.DiscordShardConfig
+		gateway  func() (*discordgo.GatewayBotResponse, error)
+		wantIDs  []int
+		wantN    int
+		wantConc int
+		wantErr  bool
+	}{
+		{"auto", config.DiscordShardConfig{}, recommended, []int{0, 1, 2, 3}, 4, 2, false},
+		{"auto fallback", config.DiscordShardConfig{}, failing, []int{0}, 1, 1, false},
+		{"fixed count", config.DiscordShardConfig{Count: 2}, mustNotCall, []int{0, 1}, 2, 1, false},
+		{"subset", config.DiscordShardConfig{Count: 8, IDs: []int{5, 3, 3}}, mustNotCall, []int{3, 5}, 8, 1, false},
+		{"subset auto", config.DiscordShardConfig{IDs: []int{1}}, recommended, []int{1}, 4, 2, false},
+		{"out of range", config.DiscordShardConfig{Count: 2, IDs: []int{2}}, mustNotCall, nil, 0, 0, true},
+		{"ids without count", config.DiscordShardConfig{IDs: []int{0}}, failing, nil, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planShards(tt.cfg, tt.gateway)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan.count != tt.wantN || plan.maxConcurrency != tt.wantConc || !slices.Equal(plan.ids, tt.wantIDs) {
+				t.Errorf("plan = %+v, want count=%d conc=%d ids=%v", plan, tt.wantN, tt.wantConc, tt.wantIDs)
+			}
+		})
+	}
+}
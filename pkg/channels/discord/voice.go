@@ -0,0 +1,396 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
+
+const (
+	// defaultVoiceSilence is the pause that ends what a user is saying.
+	defaultVoiceSilence = 800 * time.Millisecond
+	voiceFlushInterval  = 200 * time.Millisecond
+	// Utterances shorter than minUtterance are taken for noise; longer
+	// than maxUtterance they are cut so the agent answers in time.
+	minUtterance  = 300 * time.Millisecond
+	maxUtterance  = 60 * time.Second
+	speechTimeout = 2 * time.Minute
+)
+
+// opusSilence is the frame Discord clients send after a user stops talking.
+var opusSilence = []byte{0xf8, 0xff, 0xfe}
+
+// voiceSession is the bot's connection to a voice channel of one guild.
+type voiceSession struct {
+	conn      *discordgo.VoiceConnection
+	guildID   string
+	channelID string
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu         sync.Mutex
+	speakers   map[uint32]string // SSRC → user ID
+	utterances map[uint32]*utterance
+
+	playing sync.Mutex // one reply is spoken at a time
+}
+
+// utterance collects the audio of one user until they pause.
+type utterance struct {
+	userID  string
+	packets [][]byte
+	samples int
+	last    time.Time
+}
+
+func newVoiceSession(conn *discordgo.VoiceConnection, guildID, channelID string) *voiceSession {
+	return &voiceSession{
+		conn:       conn,
+		guildID:    guildID,
+		channelID:  channelID,
+		done:       make(chan struct{}),
+		speakers:   make(map[uint32]string),
+		utterances: make(map[uint32]*utterance),
+	}
+}
+
+func (vs *voiceSession) setSpeaker(ssrc uint32, userID string) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.speakers[ssrc] = userID
+}
+
+// add appends a received packet to the utterance of its speaker. Packets
+// of speakers not announced yet are dropped, since nobody can be answered
+// for them.
+func (vs *voiceSession) add(ssrc uint32, packet []byte, now time.Time) {
+	if len(packet) == 0 || bytes.Equal(packet, opusSilence) {
+		return
+	}
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	userID, ok := vs.speakers[ssrc]
+	if !ok {
+		return
+	}
+	u := vs.utterances[ssrc]
+	if u == nil {
+		u = &utterance{userID: userID}
+		vs.utterances[ssrc] = u
+	}
+	u.packets = append(u.packets, append([]byte(nil), packet...))
+	u.samples += voice.OpusPacketSamples(packet)
+	u.last = now
+}
+
+// finished removes and returns the utterances whose speaker paused for
+// silence, or which reached maxUtterance. Ones too short to be speech are
+// dropped.
+func (vs *voiceSession) finished(now time.Time, silence time.Duration) []*utterance {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	var done []*utterance
+	for ssrc, u := range vs.utterances {
+		length := time.Duration(u.samples) * time.Second / 48000
+		if now.Sub(u.last) < silence && length < maxUtterance {
+			continue
+		}
+		delete(vs.utterances, ssrc)
+		if length >= minUtterance {
+			done = append(done, u)
+		}
+	}
+	return done
+}
+
+func (vs *voiceSession) close() {
+	vs.closeOnce.Do(func() { close(vs.done) })
+}
+
+// voiceCommands are /join and /leave, registered when voice is enabled.
+func voiceCommands() []*discordgo.ApplicationCommand {
+	return []*discordgo.ApplicationCommand{
+		{
+			Name:        "join",
+			Description: "Join your voice channel and answer what is said there",
+		},
+		{
+			Name:        "leave",
+			Description: "Leave the voice channel",
+		},
+	}
+}
+
+// handleVoiceCommand runs /join or /leave for userID.
+func (c *DiscordChannel) handleVoiceCommand(s *discordgo.Session, i *discordgo.InteractionCreate, userID string) {
+	if i.GuildID == "" {
+		c.respondEphemeral(s, i.Interaction, "Voice channels are only available in servers.")
+		return
+	}
+
+	if i.ApplicationCommandData().Name == "leave" {
+		if !c.leaveVoice(i.GuildID) {
+			c.respondEphemeral(s, i.Interaction, "I am not in a voice channel.")
+			return
+		}
+		c.respond(s, i.Interaction, "Left the voice channel.")
+		return
+	}
+
+	state, err := s.State.VoiceState(i.GuildID, userID)
+	if err != nil || state.ChannelID == "" {
+		c.respondEphemeral(s, i.Interaction, "Join a voice channel first.")
+		return
+	}
+	// Connecting can take longer than Discord waits for the answer
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	}); err != nil {
+		logger.DebugCF("discord", "Failed to acknowledge slash command", map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+	content := fmt.Sprintf("Listening in <#%s>. Replies are posted in its chat.", state.ChannelID)
+	if err := c.joinVoice(s, i.GuildID, state.ChannelID); err != nil {
+		content = "Could not join the voice channel: " + err.Error()
+	}
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &content}); err != nil {
+		logger.DebugCF("discord", "Failed to answer slash command", map[string]any{
+			"error": err.Error(),
+		})
+	}
+}
+
+// joinVoice connects to channelID, replacing the guild's current voice
+// channel, and starts listening.
+func (c *DiscordChannel) joinVoice(s *discordgo.Session, guildID, channelID string) error {
+	c.voiceMu.Lock()
+	defer c.voiceMu.Unlock()
+	if vs, ok := c.voices[guildID]; ok {
+		if vs.channelID == channelID {
+			return nil
+		}
+		c.closeVoice(vs)
+		delete(c.voices, guildID)
+	}
+
+	conn, err := s.ChannelVoiceJoin(guildID, channelID, false, false)
+	if err != nil {
+		return err
+	}
+	vs := newVoiceSession(conn, guildID, channelID)
+	conn.AddHandler(func(_ *discordgo.VoiceConnection, u *discordgo.VoiceSpeakingUpdate) {
+		vs.setSpeaker(uint32(u.SSRC), u.UserID)
+	})
+	c.voices[guildID] = vs
+	go c.listen(s, vs)
+
+	logger.InfoCF("discord", "Joined voice channel", map[string]any{
+		"guild_id":   guildID,
+		"channel_id": channelID,
+	})
+	return nil
+}
+
+// leaveVoice disconnects from the guild's voice channel. It reports whether
+// the bot was in one.
+func (c *DiscordChannel) leaveVoice(guildID string) bool {
+	c.voiceMu.Lock()
+	defer c.voiceMu.Unlock()
+	vs, ok := c.voices[guildID]
+	if !ok {
+		return false
+	}
+	c.closeVoice(vs)
+	delete(c.voices, guildID)
+	return true
+}
+
+// leaveAllVoice disconnects from every voice channel.
+func (c *DiscordChannel) leaveAllVoice() {
+	c.voiceMu.Lock()
+	defer c.voiceMu.Unlock()
+	for guildID, vs := range c.voices {
+		c.closeVoice(vs)
+		delete(c.voices, guildID)
+	}
+}
+
+func (c *DiscordChannel) closeVoice(vs *voiceSession) {
+	vs.close()
+	if err := vs.conn.Disconnect(); err != nil {
+		logger.DebugCF("discord", "Failed to leave voice channel", map[string]any{
+			"guild_id": vs.guildID,
+			"error":    err.Error(),
+		})
+	}
+}
+
+// voiceSessionFor returns the voice session whose channel is chatID.
+func (c *DiscordChannel) voiceSessionFor(chatID string) *voiceSession {
+	c.voiceMu.Lock()
+	defer c.voiceMu.Unlock()
+	for _, vs := range c.voices {
+		if vs.channelID == chatID {
+			return vs
+		}
+	}
+	return nil
+}
+
+// listen splits the audio received in a voice channel into utterances and
+// hands each to the agent.
+func (c *DiscordChannel) listen(s *discordgo.Session, vs *voiceSession) {
+	silence := defaultVoiceSilence
+	if c.config.Voice.SilenceMS > 0 {
+		silence = time.Duration(c.config.Voice.SilenceMS) * time.Millisecond
+	}
+	ticker := time.NewTicker(voiceFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case p, ok := <-vs.conn.OpusRecv:
+			if !ok {
+				return
+			}
+			vs.add(p.SSRC, p.Opus, time.Now())
+		case now := <-ticker.C:
+			for _, u := range vs.finished(now, silence) {
+				c.handleUtterance(s, vs, u)
+			}
+		case <-vs.done:
+			return
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// handleUtterance saves what a user said as an Ogg Opus file and sends it to
+// the agent, which transcribes it like any voice message.
+func (c *DiscordChannel) handleUtterance(s *discordgo.Session, vs *voiceSession, u *utterance) {
+	sender := bus.SenderInfo{
+		Platform:    "discord",
+		PlatformID:  u.userID,
+		CanonicalID: identity.BuildCanonicalID("discord", u.userID),
+		Username:    u.userID,
+		DisplayName: u.userID,
+	}
+	if member, err := s.State.Member(vs.guildID, u.userID); err == nil && member.User != nil {
+		sender.Username = member.User.Username
+		sender.DisplayName = member.User.Username
+	}
+	if !c.IsAllowedSender(sender) {
+		return
+	}
+	store := c.GetMediaStore()
+	if store == nil {
+		logger.WarnC("discord", "Voice message dropped: no media store available")
+		return
+	}
+
+	path, err := writeUtterance(u)
+	if err != nil {
+		logger.WarnCF("discord", "Failed to save voice message", map[string]any{"error": err.Error()})
+		return
+	}
+	id := fmt.Sprintf("voice-%s-%d", u.userID, u.last.UnixMilli())
+	ref, err := store.Store(path, media.MediaMeta{
+		Filename:    "voice.ogg",
+		ContentType: "audio/ogg",
+		Source:      "discord",
+	}, channels.BuildMediaScope("discord", vs.channelID, id))
+	if err != nil {
+		os.Remove(path)
+		logger.WarnCF("discord", "Failed to store voice message", map[string]any{"error": err.Error()})
+		return
+	}
+
+	metadata := map[string]string{
+		"user_id":      u.userID,
+		"username":     sender.Username,
+		"display_name": sender.DisplayName,
+		"guild_id":     vs.guildID,
+		"channel_id":   vs.channelID,
+		"is_dm":        "false",
+	}
+	c.HandleMessage(c.ctx, bus.Peer{Kind: "channel", ID: vs.channelID}, "", u.userID, vs.channelID,
+		"[voice]", []string{ref}, metadata, sender)
+}
+
+func writeUtterance(u *utterance) (string, error) {
+	f, err := os.CreateTemp("", "picoclaw-voice-*.ogg")
+	if err != nil {
+		return "", err
+	}
+	err = func() error {
+		w, err := voice.NewOggOpusWriter(f, 2)
+		if err != nil {
+			return err
+		}
+		for _, p := range u.packets {
+			if err := w.WritePacket(p); err != nil {
+				return err
+			}
+		}
+		return w.Close()
+	}()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// speakReply reads content aloud in the voice channel chatID, if the bot is
+// in it and speaking replies is enabled.
+func (c *DiscordChannel) speakReply(chatID, content string) {
+	if c.speech == nil {
+		return
+	}
+	vs := c.voiceSessionFor(chatID)
+	if vs == nil {
+		return
+	}
+	go func() {
+		vs.playing.Lock()
+		defer vs.playing.Unlock()
+
+		ctx, cancel := context.WithTimeout(c.ctx, speechTimeout)
+		defer cancel()
+		packets, err := c.speech.Synthesize(ctx, content)
+		if err != nil {
+			logger.WarnCF("discord", "Failed to synthesize reply", map[string]any{"error": err.Error()})
+			return
+		}
+		if err := vs.conn.Speaking(true); err != nil {
+			logger.DebugCF("discord", "Failed to start speaking", map[string]any{"error": err.Error()})
+		}
+		defer vs.conn.Speaking(false)
+		for _, p := range packets {
+			select {
+			case vs.conn.OpusSend <- p:
+			case <-vs.done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,84 @@
+package discord
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/voice"
+)
+
+// frame20ms is a CELT packet of one 20 ms frame.
+var frame20ms = []byte{0xfc, 0x01}
+
+func TestVoiceSession_SplitsUtterancesOnSilence(t *testing.T) {
+	vs := newVoiceSession(nil, "g1", "v1")
+	start := time.Now()
+
+	vs.add(7, frame20ms, start) // speaker not announced yet
+	vs.setSpeaker(7, "u1")
+	vs.setSpeaker(8, "u2")
+	for i := range 50 { // 1 s of speech
+		vs.add(7, frame20ms, start.Add(time.Duration(i)*20*time.Millisecond))
+	}
+	vs.add(8, frame20ms, start) // a click, too short to be speech
+	vs.add(7, opusSilence, start.Add(2*time.Second))
+
+	if got := vs.finished(start.Add(1100*time.Millisecond), time.Second); len(got) != 0 {
+		t.Fatalf("utterances ended before the pause: %d", len(got))
+	}
+	got := vs.finished(start.Add(2500*time.Millisecond), time.Second)
+	if len(got) != 1 || got[0].userID != "u1" || len(got[0].packets) != 50 {
+		t.Fatalf("finished = %+v", got)
+	}
+	if len(vs.utterances) != 0 {
+		t.Errorf("short utterances should be dropped, %d left", len(vs.utterances))
+	}
+}
+
+func TestVoiceSession_CutsLongUtterances(t *testing.T) {
+	vs := newVoiceSession(nil, "g1", "v1")
+	vs.setSpeaker(7, "u1")
+	now := time.Now()
+	for range int(maxUtterance / (20 * time.Millisecond)) {
+		vs.add(7, frame20ms, now)
+	}
+	if got := vs.finished(now, time.Second); len(got) != 1 {
+		t.Fatalf("an utterance of %s was not cut", maxUtterance)
+	}
+}
+
+func TestWriteUtterance(t *testing.T) {
+	path, err := writeUtterance(&utterance{packets: [][]byte{frame20ms, frame20ms}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	packets, err := voice.ReadOggOpus(f)
+	if err != nil || len(packets) != 2 {
+		t.Fatalf("ReadOggOpus = %d packets, %v", len(packets), err)
+	}
+}
+
+func TestApplicationCommands_VoiceCommands(t *testing.T) {
+	defs := []commands.Definition{{Name: "join", Description: "Join a team"}}
+	cmds := applicationCommands(defs, voiceCommands()...)
+	var joins int
+	for _, cmd := range cmds {
+		if cmd.Name == "join" {
+			joins++
+			if cmd.Description != voiceCommands()[0].Description {
+				t.Errorf("/join = %q, want the voice command", cmd.Description)
+			}
+		}
+	}
+	if joins != 1 {
+		t.Errorf("got %d /join commands", joins)
+	}
+}
@@ -0,0 +1,419 @@
+// Package email is a channel that answers mail: it polls an IMAP mailbox
+// for unread messages and replies over SMTP, one session per email thread.
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+	"github.com/emersion/go-message/mail"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultIMAPPort     = 993
+	defaultSMTPPort     = 587
+	smtpsPort           = 465
+	defaultMailbox      = "INBOX"
+	defaultPollInterval = 60 * time.Second
+	dialTimeout         = 30 * time.Second
+	sendTimeout         = 2 * time.Minute
+	// maxMessageSize skips mail too large to be a question, typically
+	// one carrying big attachments.
+	maxMessageSize = 10 << 20
+)
+
+// thread is what a reply to a conversation needs.
+type thread struct {
+	to         *mail.Address
+	subject    string
+	last       string   // Message-ID the next reply answers
+	references []string // the thread so far, oldest first
+}
+
+// EmailChannel answers unread mail. The chat ID of a conversation is the
+// Message-ID of the first mail in its thread.
+type EmailChannel struct {
+	*channels.BaseChannel
+	config config.EmailConfig
+	from   *mail.Address
+
+	dial     func(ctx context.Context) (*imapclient.Client, error)
+	sendMail func(ctx context.Context, from string, to []string, msg []byte) error
+
+	mu      sync.Mutex
+	threads map[string]*thread // root Message-ID -> thread
+	roots   map[string]string  // Message-ID -> root, for clients that omit References
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewEmailChannel creates the channel.
+func NewEmailChannel(cfg config.EmailConfig, messageBus *bus.MessageBus) (*EmailChannel, error) {
+	if cfg.IMAPHost == "" || cfg.SMTPHost == "" {
+		return nil, fmt.Errorf("email imap_host and smtp_host are required")
+	}
+	if cfg.Username == "" {
+		return nil, fmt.Errorf("email username is required")
+	}
+	fromAddr := cfg.From
+	if fromAddr == "" {
+		fromAddr = cfg.Username
+	}
+	from, err := mail.ParseAddress(fromAddr)
+	if err != nil {
+		return nil, fmt.Errorf("email from address %q: %w", fromAddr, err)
+	}
+
+	base := channels.NewBaseChannel("email", cfg, messageBus, cfg.AllowFrom)
+	c := &EmailChannel{
+		BaseChannel: base,
+		config:      cfg,
+		from:        from,
+		threads:     make(map[string]*thread),
+		roots:       make(map[string]string),
+	}
+	c.dial = c.dialIMAP
+	c.sendMail = c.smtpSend
+	return c, nil
+}
+
+// Start polls the mailbox in the background; the first poll runs at once.
+func (c *EmailChannel) Start(ctx context.Context) error {
+	pollCtx, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	c.SetRunning(true)
+
+	interval := defaultPollInterval
+	if c.config.PollInterval > 0 {
+		interval = time.Duration(c.config.PollInterval) * time.Second
+	}
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := c.poll(pollCtx); err != nil && pollCtx.Err() == nil {
+				logger.WarnCF("email", "Mailbox poll failed", map[string]any{"error": err.Error()})
+			}
+			select {
+			case <-pollCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	logger.InfoCF("email", "Email channel started", map[string]any{
+		"mailbox":  c.mailbox(),
+		"interval": interval.String(),
+	})
+	return nil
+}
+
+// Stop ends polling and waits for a poll in progress.
+func (c *EmailChannel) Stop(ctx context.Context) error {
+	c.SetRunning(false)
+	if c.cancel != nil {
+		c.cancel()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+		}
+	}
+	logger.InfoC("email", "Email channel stopped")
+	return nil
+}
+
+// Send replies in the thread the chat ID names.
+func (c *EmailChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	c.mu.Lock()
+	t, ok := c.threads[msg.ChatID]
+	var r reply
+	if ok {
+		r = reply{
+			From:       c.from,
+			To:         t.to,
+			Subject:    t.subject,
+			InReplyTo:  t.last,
+			References: slices.Clone(t.references),
+			Content:    msg.Content,
+			Date:       time.Now(),
+		}
+	}
+	c.mu.Unlock()
+	if !ok {
+		// Threads are kept in memory, so answers to mail received before a
+		// restart have nowhere to go.
+		return fmt.Errorf("email send: no thread %q: %w", msg.ChatID, channels.ErrSendFailed)
+	}
+
+	data, msgID, err := buildReply(r)
+	if err != nil {
+		return fmt.Errorf("email send: %w: %v", channels.ErrSendFailed, err)
+	}
+	if err := c.sendMail(ctx, c.from.Address, []string{r.To.Address}, data); err != nil {
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+			return fmt.Errorf("email send: %w: %v", channels.ErrSendFailed, err)
+		}
+		return fmt.Errorf("email send: %w", channels.ClassifyNetError(err))
+	}
+
+	c.mu.Lock()
+	if t, ok := c.threads[msg.ChatID]; ok {
+		t.last = msgID
+		t.references = append(t.references, msgID)
+		c.roots[msgID] = msg.ChatID
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// poll answers the unread mail in the mailbox. Mail is marked read once it
+// has been handed to the agent, so a failed poll is retried next time.
+func (c *EmailChannel) poll(ctx context.Context) error {
+	client, err := c.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("imap connect: %w", err)
+	}
+	defer client.Close()
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	if err := client.Login(c.config.Username, c.config.Password).Wait(); err != nil {
+		return fmt.Errorf("imap login: %w", err)
+	}
+	selected, err := client.Select(c.mailbox(), nil).Wait()
+	if err != nil {
+		return fmt.Errorf("imap select %s: %w", c.mailbox(), err)
+	}
+	search, err := client.UIDSearch(&imap.SearchCriteria{NotFlag: []imap.Flag{imap.FlagSeen}}, nil).Wait()
+	if err != nil {
+		return fmt.Errorf("imap search: %w", err)
+	}
+	uids := search.AllUIDs()
+	if len(uids) == 0 {
+		return client.Logout().Wait()
+	}
+
+	sizes, err := client.Fetch(imap.UIDSetNum(uids...), &imap.FetchOptions{UID: true, RFC822Size: true}).Collect()
+	if err != nil {
+		return fmt.Errorf("imap fetch: %w", err)
+	}
+	for _, m := range sizes {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if m.RFC822Size > maxMessageSize {
+			logger.WarnCF("email", "Skipping oversized mail", map[string]any{
+				"uid":  uint32(m.UID),
+				"size": m.RFC822Size,
+			})
+		} else if err := c.handle(ctx, client, selected.UIDValidity, m.UID); err != nil {
+			return err
+		}
+		if err := markSeen(client, m.UID); err != nil {
+			return err
+		}
+	}
+	return client.Logout().Wait()
+}
+
+// handle passes one mail to the agent. Only IMAP errors are returned; a
+// mail that cannot be parsed is logged and skipped.
+func (c *EmailChannel) handle(ctx context.Context, client *imapclient.Client, uidValidity uint32, uid imap.UID) error {
+	section := &imap.FetchItemBodySection{Peek: true}
+	msgs, err := client.Fetch(imap.UIDSetNum(uid), &imap.FetchOptions{
+		UID:         true,
+		BodySection: []*imap.FetchItemBodySection{section},
+	}).Collect()
+	if err != nil {
+		return fmt.Errorf("imap fetch: %w", err)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	in, err := parseMessage(bytes.NewReader(msgs[0].FindBodySection(section)))
+	if err != nil {
+		logger.WarnCF("email", "Skipping unreadable mail", map[string]any{
+			"uid":   uint32(uid),
+			"error": err.Error(),
+		})
+		return nil
+	}
+
+	sender := strings.ToLower(in.From.Address)
+	if sender == strings.ToLower(c.from.Address) || in.Auto {
+		logger.DebugCF("email", "Skipping automatic mail", map[string]any{"from": sender})
+		return nil
+	}
+	if in.MessageID == "" {
+		in.MessageID = fmt.Sprintf("%d.%d@%s", uidValidity, uid, c.config.IMAPHost)
+	}
+
+	root, first := c.track(in)
+	content := in.Text
+	if first && in.Subject != "" {
+		content = "Subject: " + in.Subject + "\n\n" + content
+	}
+	peer := bus.Peer{Kind: "direct", ID: sender}
+	c.HandleMessage(ctx, peer, in.MessageID, sender, root, content, nil, map[string]string{
+		"subject": in.Subject,
+	})
+	return nil
+}
+
+// track records in as the latest mail of its thread and returns the
+// thread's root Message-ID, and whether in starts a new thread.
+func (c *EmailChannel) track(in *inbound) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	root := ""
+	for _, id := range append(slices.Clone(in.InReplyTo), in.References...) {
+		if r, ok := c.roots[id]; ok {
+			root = r
+			break
+		}
+	}
+	// RFC 5322: References lists the thread from its first message, or a
+	// reply carries only In-Reply-To
+	refs := in.References
+	if len(refs) == 0 {
+		refs = in.InReplyTo
+	}
+	if root == "" {
+		root = in.MessageID
+		if len(refs) > 0 {
+			root = refs[0]
+		}
+	}
+
+	c.threads[root] = &thread{
+		to:         in.ReplyTo,
+		subject:    in.Subject,
+		last:       in.MessageID,
+		references: append(slices.Clone(refs), in.MessageID),
+	}
+	c.roots[in.MessageID] = root
+	return root, len(refs) == 0
+}
+
+func markSeen(client *imapclient.Client, uid imap.UID) error {
+	err := client.Store(imap.UIDSetNum(uid), &imap.StoreFlags{
+		Op:     imap.StoreFlagsAdd,
+		Flags:  []imap.Flag{imap.FlagSeen},
+		Silent: true,
+	}, nil).Close()
+	if err != nil {
+		return fmt.Errorf("imap store: %w", err)
+	}
+	return nil
+}
+
+func (c *EmailChannel) mailbox() string {
+	if c.config.Mailbox != "" {
+		return c.config.Mailbox
+	}
+	return defaultMailbox
+}
+
+// dialIMAP connects with implicit TLS on port 993 and STARTTLS otherwise.
+func (c *EmailChannel) dialIMAP(ctx context.Context) (*imapclient.Client, error) {
+	port := c.config.IMAPPort
+	if port == 0 {
+		port = defaultIMAPPort
+	}
+	addr := net.JoinHostPort(c.config.IMAPHost, strconv.Itoa(port))
+	opts := &imapclient.Options{Dialer: &net.Dialer{Timeout: dialTimeout}}
+	if port == defaultIMAPPort {
+		return imapclient.DialTLS(addr, opts)
+	}
+	return imapclient.DialStartTLS(addr, opts)
+}
+
+// smtpSend delivers msg with implicit TLS on port 465 and STARTTLS, when
+// offered, otherwise. net/smtp refuses to send the password in the clear.
+func (c *EmailChannel) smtpSend(ctx context.Context, from string, to []string, msg []byte) error {
+	host := c.config.SMTPHost
+	port := c.config.SMTPPort
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: host}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if port == smtpsPort {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(sendTimeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if port != smtpsPort {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if ok, _ := client.Extension("AUTH"); ok {
+		if err := client.Auth(smtp.PlainAuth("", c.config.Username, c.config.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
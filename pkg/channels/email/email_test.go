@@ -0,0 +1,307 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+	"github.com/emersion/go-imap/v2/imapserver"
+	"github.com/emersion/go-imap/v2/imapserver/imapmemserver"
+	"github.com/emersion/go-message/mail"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const question = "From: Alice <Alice@Example.com>\r\n" +
+	"To: bot@example.org\r\n" +
+	"Subject: Quarterly numbers\r\n" +
+	"Message-ID: <q1@example.com>\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"What were the Q3 numbers?\r\n"
+
+func TestParseMessage_StripsQuotedReply(t *testing.T) {
+	raw := "From: alice@example.com\r\n" +
+		"Subject: Re: Quarterly numbers\r\n" +
+		"Message-ID: <q2@example.com>\r\n" +
+		"In-Reply-To: <r1@example.org>\r\n" +
+		"References: <q1@example.com> <r1@example.org>\r\n" +
+		"\r\n" +
+		"And Q4?\r\n" +
+		"\r\n" +
+		"On Mon, 1 Jan 2024 at 10:00, Bot <bot@example.org> wrote:\r\n" +
+		"> Q3 was up 4%.\r\n" +
+		"-- \r\n" +
+		"Alice\r\n"
+	in, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage: %v", err)
+	}
+	if in.Text != "And Q4?" {
+		t.Errorf("Text = %q, want %q", in.Text, "And Q4?")
+	}
+	if in.MessageID != "q2@example.com" {
+		t.Errorf("MessageID = %q", in.MessageID)
+	}
+	if len(in.References) != 2 || in.References[0] != "q1@example.com" {
+		t.Errorf("References = %v", in.References)
+	}
+}
+
+func TestParseMessage_HTMLOnly(t *testing.T) {
+	raw := "From: alice@example.com\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"\r\n" +
+		"<html><head><style>p{}</style></head><body><p>Hello &amp; welcome</p>" +
+		"<div>second<br>line</div><blockquote>old reply</blockquote></body></html>"
+	in, err := parseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMessage: %v", err)
+	}
+	if want := "Hello & welcome\nsecond\nline"; in.Text != want {
+		t.Errorf("Text = %q, want %q", in.Text, want)
+	}
+}
+
+func TestParseMessage_Automatic(t *testing.T) {
+	for _, header := range []string{"Auto-Submitted: auto-replied", "Precedence: bulk", "X-Autoreply: yes"} {
+		raw := "From: alice@example.com\r\n" + header + "\r\n\r\nOut of office\r\n"
+		in, err := parseMessage(strings.NewReader(raw))
+		if err != nil {
+			t.Fatalf("parseMessage: %v", err)
+		}
+		if !in.Auto {
+			t.Errorf("%s: Auto = false, want true", header)
+		}
+	}
+	raw := "From: alice@example.com\r\nAuto-Submitted: no\r\n\r\nHi\r\n"
+	if in, _ := parseMessage(strings.NewReader(raw)); in.Auto {
+		t.Error("Auto-Submitted: no marked automatic")
+	}
+}
+
+func TestStripQuoted_KeepsAllQuotedMessage(t *testing.T) {
+	if got := stripQuoted("> only a quote"); got != "> only a quote" {
+		t.Errorf("stripQuoted = %q", got)
+	}
+}
+
+func TestBuildReply(t *testing.T) {
+	data, msgID, err := buildReply(reply{
+		From:       &mail.Address{Name: "Bot", Address: "bot@example.org"},
+		To:         &mail.Address{Address: "alice@example.com"},
+		Subject:    "Quarterly numbers",
+		InReplyTo:  "q1@example.com",
+		References: []string{"q1@example.com"},
+		Content:    "Q3 was **up** 4%.",
+		Date:       time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("buildReply: %v", err)
+	}
+	if !strings.HasSuffix(msgID, "@example.org") {
+		t.Errorf("Message-ID = %q, want the sender's domain", msgID)
+	}
+
+	mr, err := mail.CreateReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("CreateReader: %v", err)
+	}
+	if subject, _ := mr.Header.Subject(); subject != "Re: Quarterly numbers" {
+		t.Errorf("Subject = %q", subject)
+	}
+	if ids, _ := mr.Header.MsgIDList("In-Reply-To"); len(ids) != 1 || ids[0] != "q1@example.com" {
+		t.Errorf("In-Reply-To = %v", ids)
+	}
+	if ct, _, _ := mr.Header.ContentType(); ct != "multipart/alternative" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	bodies := map[string]string{}
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		ct, _, _ := p.Header.(*mail.InlineHeader).ContentType()
+		b, _ := io.ReadAll(p.Body)
+		bodies[ct] = string(b)
+	}
+	if bodies["text/plain"] != "Q3 was **up** 4%." {
+		t.Errorf("text/plain = %q", bodies["text/plain"])
+	}
+	if !strings.Contains(bodies["text/html"], "<strong>up</strong>") {
+		t.Errorf("text/html = %q", bodies["text/html"])
+	}
+}
+
+func TestReplySubject(t *testing.T) {
+	tests := map[string]string{
+		"Hello":     "Re: Hello",
+		"RE: Hello": "RE: Hello",
+		"":          "Re: (no subject)",
+	}
+	for in, want := range tests {
+		if got := replySubject(in); got != want {
+			t.Errorf("replySubject(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTrack_ThreadsWithoutReferences(t *testing.T) {
+	ch := newTestChannel(t, nil)
+	root, first := ch.track(&inbound{MessageID: "q1@x", From: &mail.Address{Address: "a@x"}, ReplyTo: &mail.Address{Address: "a@x"}})
+	if root != "q1@x" || !first {
+		t.Fatalf("track = %q, %v; want q1@x, true", root, first)
+	}
+	// Our reply, then an answer to it that only carries In-Reply-To
+	ch.roots["r1@bot"] = root
+	root, first = ch.track(&inbound{MessageID: "q2@x", InReplyTo: []string{"r1@bot"}, ReplyTo: &mail.Address{Address: "a@x"}})
+	if root != "q1@x" || first {
+		t.Errorf("track = %q, %v; want q1@x, false", root, first)
+	}
+}
+
+func TestPoll_AnswersUnreadMailInThread(t *testing.T) {
+	addr := startIMAPServer(t, question)
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+
+	type sent struct {
+		to   []string
+		data []byte
+	}
+	sentCh := make(chan sent, 1)
+	ch := newTestChannel(t, msgBus)
+	ch.dial = func(context.Context) (*imapclient.Client, error) {
+		return imapclient.DialInsecure(addr, nil)
+	}
+	ch.sendMail = func(_ context.Context, from string, to []string, msg []byte) error {
+		sentCh <- sent{to: to, data: msg}
+		return nil
+	}
+	ch.SetRunning(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ch.poll(ctx); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	var inbound bus.InboundMessage
+	select {
+	case inbound = <-msgBus.InboundChan():
+	case <-ctx.Done():
+		t.Fatal("no inbound message")
+	}
+	if inbound.ChatID != "q1@example.com" || inbound.SenderID != "alice@example.com" {
+		t.Errorf("inbound chat %q sender %q", inbound.ChatID, inbound.SenderID)
+	}
+	if want := "Subject: Quarterly numbers\n\nWhat were the Q3 numbers?"; inbound.Content != want {
+		t.Errorf("Content = %q, want %q", inbound.Content, want)
+	}
+
+	// The mail is read now, so a second poll finds nothing
+	if err := ch.poll(ctx); err != nil {
+		t.Fatalf("second poll: %v", err)
+	}
+	select {
+	case m := <-msgBus.InboundChan():
+		t.Fatalf("mail answered twice: %+v", m)
+	default:
+	}
+
+	if err := ch.Send(ctx, bus.OutboundMessage{Channel: "email", ChatID: inbound.ChatID, Content: "Up 4%."}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	got := <-sentCh
+	if len(got.to) != 1 || got.to[0] != "Alice@Example.com" {
+		t.Errorf("sent to %v", got.to)
+	}
+	if !bytes.Contains(got.data, []byte("In-Reply-To: <q1@example.com>")) {
+		t.Errorf("reply is not threaded:\n%s", got.data)
+	}
+}
+
+func TestSend_UnknownThread(t *testing.T) {
+	ch := newTestChannel(t, nil)
+	ch.SetRunning(true)
+	err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "unknown@x", Content: "hi"})
+	if !errors.Is(err, channels.ErrSendFailed) {
+		t.Errorf("Send = %v, want ErrSendFailed", err)
+	}
+}
+
+func newTestChannel(t *testing.T, msgBus *bus.MessageBus) *EmailChannel {
+	t.Helper()
+	if msgBus == nil {
+		msgBus = bus.NewMessageBus()
+		t.Cleanup(msgBus.Close)
+	}
+	ch, err := NewEmailChannel(config.EmailConfig{
+		IMAPHost: "imap.example.org",
+		SMTPHost: "smtp.example.org",
+		Username: "bot@example.org",
+		Password: "secret",
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewEmailChannel: %v", err)
+	}
+	return ch
+}
+
+// startIMAPServer serves an INBOX holding msgs for bot@example.org.
+func startIMAPServer(t *testing.T, msgs ...string) string {
+	t.Helper()
+	memServer := imapmemserver.New()
+	user := imapmemserver.NewUser("bot@example.org", "secret")
+	if err := user.Create("INBOX", nil); err != nil {
+		t.Fatal(err)
+	}
+	memServer.AddUser(user)
+	server := imapserver.New(&imapserver.Options{
+		NewSession: func(*imapserver.Conn) (imapserver.Session, *imapserver.GreetingData, error) {
+			return memServer.NewSession(), nil, nil
+		},
+		Caps:         imap.CapSet{imap.CapIMAP4rev1: {}},
+		InsecureAuth: true,
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+
+	client, err := imapclient.DialInsecure(ln.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Login("bot@example.org", "secret").Wait(); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range msgs {
+		cmd := client.Append("INBOX", int64(len(m)), nil)
+		if _, err := cmd.Write([]byte(m)); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cmd.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ln.Addr().String()
+}
@@ -0,0 +1,259 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message"
+	_ "github.com/emersion/go-message/charset" // decode non-UTF-8 mail
+	"github.com/emersion/go-message/mail"
+	"github.com/gomarkdown/markdown"
+	mdhtml "github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// maxTextSize bounds how much of a text part is read.
+const maxTextSize = 1 << 20
+
+var (
+	reQuoteHeader = regexp.MustCompile(`^On .+ wrote:$`)
+	reHTMLSkip    = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)>`)
+	reHTMLBreak   = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|h[1-6]|blockquote)>`)
+	reHTMLQuote   = regexp.MustCompile(`(?is)<blockquote\b.*?</blockquote>`)
+	reHTMLTag     = regexp.MustCompile(`<[^>]*>`)
+	reBlankLines  = regexp.MustCompile(`\n{3,}`)
+)
+
+// inbound is an email parsed for the agent.
+type inbound struct {
+	MessageID  string
+	InReplyTo  []string
+	References []string
+	From       *mail.Address
+	ReplyTo    *mail.Address // where replies go; From unless the sender set Reply-To
+	Subject    string
+	Text       string // the new text, with quoted history and signature removed
+	Auto       bool   // auto-reply, bulk or list mail, never answered
+}
+
+// parseMessage reads an RFC 5322 message.
+func parseMessage(r io.Reader) (*inbound, error) {
+	mr, err := mail.CreateReader(r)
+	if err != nil && !message.IsUnknownCharset(err) {
+		return nil, err
+	}
+	defer mr.Close()
+
+	in := &inbound{}
+	h := mr.Header
+	from, err := h.AddressList("From")
+	if err != nil || len(from) == 0 {
+		return nil, fmt.Errorf("no From address")
+	}
+	in.From, in.ReplyTo = from[0], from[0]
+	if replyTo, err := h.AddressList("Reply-To"); err == nil && len(replyTo) > 0 {
+		in.ReplyTo = replyTo[0]
+	}
+	in.MessageID, _ = h.MessageID()
+	in.InReplyTo, _ = h.MsgIDList("In-Reply-To")
+	in.References, _ = h.MsgIDList("References")
+	in.Subject, _ = h.Subject()
+	in.Auto = isAutomatic(h)
+
+	var plain, htmlText string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if message.IsUnknownCharset(err) {
+				continue
+			}
+			return nil, err
+		}
+		ih, ok := p.Header.(*mail.InlineHeader)
+		if !ok {
+			continue // attachments are not passed on
+		}
+		ct, _, _ := ih.ContentType()
+		if (ct == "text/plain" && plain != "") || (ct == "text/html" && htmlText != "") {
+			continue
+		}
+		if ct != "text/plain" && ct != "text/html" && ct != "" {
+			continue
+		}
+		b, err := io.ReadAll(io.LimitReader(p.Body, maxTextSize))
+		if err != nil {
+			return nil, err
+		}
+		if ct == "text/html" {
+			htmlText = string(b)
+		} else {
+			plain = string(b)
+		}
+	}
+
+	text := plain
+	if strings.TrimSpace(text) == "" && htmlText != "" {
+		text = htmlToText(htmlText)
+	}
+	in.Text = stripQuoted(text)
+	return in, nil
+}
+
+// isAutomatic reports mail that must not be answered, so the bot never
+// talks to a vacation responder or a mailing list (RFC 3834).
+func isAutomatic(h mail.Header) bool {
+	if v := strings.ToLower(strings.TrimSpace(h.Get("Auto-Submitted"))); v != "" && v != "no" {
+		return true
+	}
+	switch strings.ToLower(strings.TrimSpace(h.Get("Precedence"))) {
+	case "bulk", "junk", "list", "auto_reply":
+		return true
+	}
+	return h.Get("X-Autoreply") != "" || h.Get("X-Autorespond") != ""
+}
+
+// stripQuoted drops the quoted history and signature a mail client adds to
+// a reply; the session already holds the earlier messages.
+func stripQuoted(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	var out []string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if line == "-- " || trimmed == "-----Original Message-----" {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		if reQuoteHeader.MatchString(trimmed) && quoteFollows(lines[i+1:]) {
+			break
+		}
+		out = append(out, line)
+	}
+	stripped := strings.TrimSpace(strings.Join(out, "\n"))
+	if stripped == "" {
+		return strings.TrimSpace(text)
+	}
+	return stripped
+}
+
+// quoteFollows reports whether the next non-blank line is quoted.
+func quoteFollows(lines []string) bool {
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			return strings.HasPrefix(trimmed, ">")
+		}
+	}
+	return true
+}
+
+// htmlToText reduces an HTML-only mail to its text. Quoted history in
+// <blockquote> is dropped along with the markup.
+func htmlToText(s string) string {
+	s = reHTMLSkip.ReplaceAllString(s, "")
+	s = reHTMLQuote.ReplaceAllString(s, "")
+	s = reHTMLBreak.ReplaceAllString(s, "\n")
+	s = reHTMLTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(reBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// reply is an outgoing answer in a thread.
+type reply struct {
+	From       *mail.Address
+	To         *mail.Address
+	Subject    string
+	InReplyTo  string
+	References []string
+	Content    string // Markdown
+	Date       time.Time
+}
+
+// buildReply renders r as multipart/alternative with the Markdown as the
+// text/plain part and its HTML rendering as the text/html part. It returns
+// the message and its Message-ID.
+func buildReply(r reply) ([]byte, string, error) {
+	var h mail.Header
+	h.SetDate(r.Date)
+	h.SetAddressList("From", []*mail.Address{r.From})
+	h.SetAddressList("To", []*mail.Address{r.To})
+	h.SetSubject(replySubject(r.Subject))
+	if err := h.GenerateMessageIDWithHostname(addressDomain(r.From.Address)); err != nil {
+		return nil, "", err
+	}
+	if r.InReplyTo != "" {
+		h.SetMsgIDList("In-Reply-To", []string{r.InReplyTo})
+	}
+	if len(r.References) > 0 {
+		h.SetMsgIDList("References", r.References)
+	}
+	// Keeps vacation responders from answering the bot
+	h.Set("Auto-Submitted", "auto-replied")
+	msgID, _ := h.MessageID()
+
+	var buf bytes.Buffer
+	w, err := mail.CreateInlineWriter(&buf, h)
+	if err != nil {
+		return nil, "", err
+	}
+	parts := []struct{ contentType, body string }{
+		{"text/plain", r.Content},
+		{"text/html", "<!DOCTYPE html>\n<html><body>\n" + markdownToHTML(r.Content) + "\n</body></html>"},
+	}
+	for _, part := range parts {
+		var ph mail.InlineHeader
+		ph.SetContentType(part.contentType, map[string]string{"charset": "utf-8"})
+		pw, err := w.CreatePart(ph)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := io.WriteString(pw, part.body); err != nil {
+			return nil, "", err
+		}
+		if err := pw.Close(); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), msgID, nil
+}
+
+// markdownToHTML renders the reply; raw HTML from the model is dropped.
+func markdownToHTML(md string) string {
+	p := parser.NewWithExtensions(parser.CommonExtensions)
+	renderer := mdhtml.NewRenderer(mdhtml.RendererOptions{Flags: mdhtml.CommonFlags | mdhtml.SkipHTML})
+	return strings.TrimSpace(string(markdown.ToHTML([]byte(md), p, renderer)))
+}
+
+func replySubject(subject string) string {
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return "Re: (no subject)"
+	}
+	if strings.HasPrefix(strings.ToLower(subject), "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+func addressDomain(addr string) string {
+	if _, domain, ok := strings.Cut(addr, "@"); ok && domain != "" {
+		return domain
+	}
+	return "localhost"
+}
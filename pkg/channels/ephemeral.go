@@ -0,0 +1,62 @@
+package channels
+
+import (
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// IsEphemeral reports whether a message from sender (senderID on the
+// platform) in chatID of channel belongs to a conversation cfg keeps off the
+// record.
+func IsEphemeral(cfg config.EphemeralConfig, channel, chatID, senderID string, sender bus.SenderInfo) bool {
+	if !cfg.Enabled || channel == "system" {
+		return false
+	}
+	for _, ch := range cfg.Channels {
+		ch = strings.TrimSpace(ch)
+		if ch == channel || ch == channel+":"+chatID {
+			return true
+		}
+	}
+	for _, user := range cfg.Users {
+		if identity.MatchAllowed(sender, user) || (senderID != "" && strings.TrimSpace(user) == senderID) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetEphemeral tells the channel which conversations are ephemeral, so
+// LogPreview keeps their text out of its log lines.
+func (c *BaseChannel) SetEphemeral(cfg config.EphemeralConfig) {
+	c.ephemeral = cfg
+}
+
+// LogPreview returns the first n characters of content for a log line about
+// a message in chatID from senderID, or only its length when the
+// conversation is ephemeral: channels log a message before HandleMessage
+// passes it on, and the text of ephemeral conversations is never logged.
+// senderID may be a platform ID or a canonical "platform:id"; pass "" for
+// outbound messages. Pass the SenderInfo, when the channel has built it, for
+// users given by @username to match.
+func (c *BaseChannel) LogPreview(chatID, senderID, content string, n int, senderOpts ...bus.SenderInfo) string {
+	sender := bus.SenderInfo{
+		Platform:    c.name,
+		PlatformID:  senderID,
+		CanonicalID: identity.BuildCanonicalID(c.name, senderID),
+	}
+	if len(senderOpts) > 0 {
+		sender = senderOpts[0]
+	} else if platform, id, ok := identity.ParseCanonicalID(senderID); ok {
+		sender = bus.SenderInfo{Platform: platform, PlatformID: id, CanonicalID: senderID}
+	}
+	if IsEphemeral(c.ephemeral, c.name, chatID, senderID, sender) {
+		return logger.RedactPrivateContent(content)
+	}
+	return utils.Truncate(content, n)
+}
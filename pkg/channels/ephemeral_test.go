@@ -0,0 +1,36 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestLogPreview(t *testing.T) {
+	ch := NewBaseChannel("discord", nil, nil, nil)
+	content := "my secret plans for the weekend"
+	if got := ch.LogPreview("room", "42", content, 10); got != "my secr..." {
+		t.Errorf("LogPreview() without ephemeral config = %q", got)
+	}
+
+	ch.SetEphemeral(config.EphemeralConfig{
+		Enabled:  true,
+		Channels: []string{"discord:private-room"},
+		Users:    []string{"discord:7"},
+	})
+	for _, tt := range []struct{ chatID, senderID string }{
+		{"private-room", "42"},
+		{"private-room", ""},
+		{"room", "7"},
+		{"room", "discord:7"},
+	} {
+		got := ch.LogPreview(tt.chatID, tt.senderID, content, 10)
+		if strings.Contains(got, "secret") || !strings.HasPrefix(got, "[redacted") {
+			t.Errorf("LogPreview(%q, %q) = %q, want the text redacted", tt.chatID, tt.senderID, got)
+		}
+	}
+	if got := ch.LogPreview("room", "42", content, 10); got != "my secr..." {
+		t.Errorf("LogPreview() of another chat = %q", got)
+	}
+}
@@ -0,0 +1,71 @@
+package channels
+
+import (
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/feedback"
+)
+
+// sentTurn is the agent turn a sent message belongs to.
+type sentTurn struct {
+	turnID    string
+	createdAt time.Time
+}
+
+// FeedbackRecorder saves a rating of the agent turn turnID.
+type FeedbackRecorder func(turnID, rating string, sender bus.SenderInfo)
+
+// FeedbackSink is injected into channels by Manager and receives the
+// reactions users add to the bot's messages.
+type FeedbackSink interface {
+	ResolveFeedback(channel, chatID, messageID, rating string, sender bus.SenderInfo) bool
+}
+
+// SetFeedbackSink injects the sink that reactions are reported to.
+func (c *BaseChannel) SetFeedbackSink(s FeedbackSink) {
+	c.feedbackSink = s
+}
+
+// HandleReaction reports a reaction a user added to messageID in chatID.
+// 🔁, ◀️, ▶️, 🗑️ and 📋 act on the reply (see ReplyActionForEmoji), 👍 and 👎 rate
+// it, ✅ and ❌ resolve a tool action waiting for approval, and ✅, 📝, 📋 and
+// ❌ answer a long-output prompt. Other reactions are ignored, as are
+// reactions to messages that were not sent by the agent.
+func (c *BaseChannel) HandleReaction(chatID, messageID, emoji string, sender bus.SenderInfo) bool {
+	if action := ReplyActionForEmoji(emoji); action != "" {
+		if c.replyActionSink == nil || !c.IsAllowedSender(sender) {
+			return false
+		}
+		return c.replyActionSink.ResolveReplyAction(c.name, chatID, messageID, action, sender)
+	}
+	rating := feedback.RatingForEmoji(emoji)
+	if rating == "" || c.feedbackSink == nil || !c.IsAllowedSender(sender) {
+		return false
+	}
+	return c.feedbackSink.ResolveFeedback(c.name, chatID, messageID, rating, sender)
+}
+
+// SetFeedbackRecorder registers the function that saves feedback.
+func (m *Manager) SetFeedbackRecorder(record FeedbackRecorder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.feedbackRecorder = record
+}
+
+// ResolveFeedback records rating for the turn that messageID was sent for.
+// Implements FeedbackSink.
+func (m *Manager) ResolveFeedback(channel, chatID, messageID, rating string, sender bus.SenderInfo) bool {
+	v, ok := m.sentTurns.Load(channel + ":" + chatID + ":" + messageID)
+	if !ok {
+		return false
+	}
+	m.mu.RLock()
+	record := m.feedbackRecorder
+	m.mu.RUnlock()
+	if record == nil {
+		return false
+	}
+	record(v.(sentTurn).turnID, rating, sender)
+	return true
+}
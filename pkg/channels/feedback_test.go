@@ -0,0 +1,40 @@
+package channels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestResolveFeedback_MapsSentMessagesToTurns(t *testing.T) {
+	m := newTestManager()
+	var rated []string
+	m.SetFeedbackRecorder(func(turnID, rating string, sender bus.SenderInfo) {
+		rated = append(rated, turnID+"="+rating+"@"+sender.PlatformID)
+	})
+
+	ch := &mockChannel{}
+	ch.sendFn = func(_ context.Context, msg bus.OutboundMessage) error {
+		// Channels record the IDs of the messages they send.
+		m.RecordReply("test", msg.ChatID, "m-"+msg.Content)
+		return nil
+	}
+	w := newChannelWorker("test", ch)
+	m.sendWithRetry(context.Background(), "test", w, bus.OutboundMessage{ChatID: "123", Content: "answer", TurnID: "req-1"})
+	m.sendWithRetry(context.Background(), "test", w, bus.OutboundMessage{ChatID: "123", Content: "notice"})
+
+	sender := bus.SenderInfo{PlatformID: "u1"}
+	if !m.ResolveFeedback("test", "123", "m-answer", "up", sender) {
+		t.Fatal("reaction to the reply was not recorded")
+	}
+	if m.ResolveFeedback("test", "123", "m-notice", "up", sender) {
+		t.Error("messages without a turn cannot be rated")
+	}
+	if m.ResolveFeedback("test", "999", "m-answer", "up", sender) {
+		t.Error("message IDs are scoped to their chat")
+	}
+	if len(rated) != 1 || rated[0] != "req-1=up@u1" {
+		t.Errorf("rated = %v", rated)
+	}
+}
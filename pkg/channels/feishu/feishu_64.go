@@ -421,7 +421,7 @@ func (c *FeishuChannel) handleMessageReceive(ctx context.Context, event *larkim.
 		"sender_id":  senderID,
 		"chat_id":    chatID,
 		"message_id": messageID,
-		"preview":    utils.Truncate(content, 80),
+		"preview":    c.LogPreview(chatID, senderID, content, 80, senderInfo),
 	})
 
 	c.HandleMessage(ctx, peer, messageID, senderID, chatID, content, mediaRefs, metadata, senderInfo)
@@ -0,0 +1,84 @@
+package channels
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/leader"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// haLeaseKey returns the lease key guarding a channel's platform connection.
+func (m *Manager) haLeaseKey(name string) string {
+	return leader.LeaseKey(m.config.Gateway.HA, "channel-"+name)
+}
+
+// startElectors campaigns for every channel's lease. A channel is started
+// when this replica becomes its leader and stopped when the lease is lost,
+// so at most one replica talks to each platform at a time.
+// The caller must hold m.mu.
+func (m *Manager) startElectors(ctx, dispatchCtx context.Context) {
+	ha := m.config.Gateway.HA
+	identity := leader.Identity(ha)
+	ttl := leader.LeaseDuration(ha)
+
+	// Leases outlive ctx cancellation on purpose: StopAll ends the election
+	// after the channels are closed.
+	haCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	m.haCancel = cancel
+
+	logger.InfoCF("channels", "High availability enabled, campaigning for channel leases", map[string]any{
+		"identity": identity,
+		"channels": len(m.channels),
+	})
+
+	for name, channel := range m.channels {
+		e := leader.NewElector(m.haLock, m.haLeaseKey(name), identity, ttl)
+		e.OnStartedLeading = func(context.Context) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if haCtx.Err() != nil {
+				return
+			}
+			if _, running := m.workers[name]; !running {
+				m.startChannel(ctx, dispatchCtx, name, channel)
+			}
+		}
+		e.OnStoppedLeading = func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if haCtx.Err() != nil {
+				return
+			}
+			m.stopChannel(haCtx, name, channel)
+		}
+
+		m.haWG.Add(1)
+		go func() {
+			defer m.haWG.Done()
+			e.Run(haCtx)
+		}()
+	}
+}
+
+// stopChannel stops a single channel and its workers after this replica lost
+// its lease. Queued messages are dropped; the caller must hold m.mu.
+func (m *Manager) stopChannel(ctx context.Context, name string, channel Channel) {
+	if w, ok := m.workers[name]; ok && w != nil {
+		delete(m.workers, name)
+		if w.cancel != nil {
+			w.cancel()
+		}
+		<-w.done
+		<-w.mediaDone
+	}
+
+	logger.InfoCF("channels", "Stopping channel after losing leadership", map[string]any{
+		"channel": name,
+	})
+	if err := channel.Stop(ctx); err != nil {
+		logger.ErrorCF("channels", "Error stopping channel", map[string]any{
+			"channel": name,
+			"error":   err.Error(),
+		})
+	}
+}
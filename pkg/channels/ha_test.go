@@ -0,0 +1,113 @@
+package channels
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/leader"
+)
+
+type lifecycleChannel struct {
+	mockChannel
+	starts atomic.Int32
+	stops  atomic.Int32
+}
+
+func (c *lifecycleChannel) Start(context.Context) error { c.starts.Add(1); return nil }
+func (c *lifecycleChannel) Stop(context.Context) error  { c.stops.Add(1); return nil }
+
+func newHATestManager(lock leader.Lock, identity string, ch Channel) *Manager {
+	m := newTestManager()
+	m.bus = bus.NewMessageBus()
+	m.config = &config.Config{}
+	m.config.Gateway.HA = config.HAConfig{Enabled: true, Identity: identity, LeaseSeconds: 1}
+	m.haLock = lock
+	m.channels["test"] = ch
+	return m
+}
+
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met in time")
+}
+
+func hasWorker(m *Manager, name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.workers[name]
+	return ok
+}
+
+func TestHA_OnlyLeaderStartsChannel(t *testing.T) {
+	lock := leader.NewMemoryLock()
+	chA, chB := &lifecycleChannel{}, &lifecycleChannel{}
+	a := newHATestManager(lock, "a", chA)
+	b := newHATestManager(lock, "b", chB)
+
+	if err := a.StartAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, func() bool { return hasWorker(a, "test") })
+
+	if err := b.StartAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer b.StopAll(context.Background())
+
+	time.Sleep(500 * time.Millisecond)
+	if chB.starts.Load() != 0 || hasWorker(b, "test") {
+		t.Fatal("standby replica must not start the channel")
+	}
+
+	// Stopping the leader releases the lease and the standby takes over.
+	if err := a.StopAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if chA.stops.Load() != 1 {
+		t.Errorf("leader channel should be stopped once, got %d", chA.stops.Load())
+	}
+	waitUntil(t, func() bool { return hasWorker(b, "test") })
+	if chB.starts.Load() != 1 {
+		t.Errorf("standby channel should start once, got %d", chB.starts.Load())
+	}
+}
+
+func TestHA_StandbyStopAllSkipsChannels(t *testing.T) {
+	lock := leader.NewMemoryLock()
+	lock.TryAcquire(context.Background(), "picoclaw-channel-test", "other", time.Minute)
+
+	ch := &lifecycleChannel{}
+	m := newHATestManager(lock, "a", ch)
+	if err := m.StartAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := m.StopAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if ch.starts.Load() != 0 || ch.stops.Load() != 0 {
+		t.Errorf("channel owned elsewhere must be left alone: starts=%d stops=%d", ch.starts.Load(), ch.stops.Load())
+	}
+}
+
+func TestHA_LeaseKey(t *testing.T) {
+	m := newHATestManager(leader.NewMemoryLock(), "a", &lifecycleChannel{})
+	if got := m.haLeaseKey("discord"); got != "picoclaw-channel-discord" {
+		t.Errorf("haLeaseKey = %q", got)
+	}
+	m.config.Gateway.HA.LeasePrefix = "prod"
+	if got := m.haLeaseKey("discord"); got != "prod-channel-discord" {
+		t.Errorf("haLeaseKey = %q", got)
+	}
+}
@@ -0,0 +1,46 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrHistoryUnsupported means the channel cannot read past messages of a chat.
+var ErrHistoryUnsupported = errors.New("channel cannot read message history")
+
+// HistoryMessage is one message read back from a chat's history.
+type HistoryMessage struct {
+	ID     string
+	Time   time.Time
+	Author string
+	Bot    bool // sent by a bot, including this one
+	Text   string
+}
+
+// HistoryCapable is implemented by channels whose platform lets the bot read
+// earlier messages of a chat, within the permissions the bot was granted.
+type HistoryCapable interface {
+	// ReadHistory returns up to limit messages of chatID sent at or after
+	// since, oldest first.
+	ReadHistory(ctx context.Context, chatID string, since time.Time, limit int) ([]HistoryMessage, error)
+}
+
+// ReadHistory reads the recent messages of a chat on the named channel.
+func (m *Manager) ReadHistory(
+	ctx context.Context,
+	channel, chatID string,
+	since time.Time,
+	limit int,
+) ([]HistoryMessage, error) {
+	ch, ok := m.GetChannel(channel)
+	if !ok {
+		return nil, fmt.Errorf("channel %s is not enabled", channel)
+	}
+	reader, ok := ch.(HistoryCapable)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", channel, ErrHistoryUnsupported)
+	}
+	return reader.ReadHistory(ctx, chatID, since, limit)
+}
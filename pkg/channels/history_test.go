@@ -0,0 +1,44 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type mockHistoryChannel struct {
+	mockChannel
+	msgs []HistoryMessage
+}
+
+func (m *mockHistoryChannel) ReadHistory(_ context.Context, _ string, since time.Time, limit int) ([]HistoryMessage, error) {
+	var out []HistoryMessage
+	for _, msg := range m.msgs {
+		if !msg.Time.Before(since) && len(out) < limit {
+			out = append(out, msg)
+		}
+	}
+	return out, nil
+}
+
+func TestManager_ReadHistory(t *testing.T) {
+	m := newTestManager()
+	now := time.Now()
+	m.channels["discord"] = &mockHistoryChannel{msgs: []HistoryMessage{
+		{ID: "1", Time: now.Add(-2 * time.Hour), Text: "old"},
+		{ID: "2", Time: now, Text: "new"},
+	}}
+	m.channels["telegram"] = &mockChannel{}
+
+	msgs, err := m.ReadHistory(context.Background(), "discord", "c1", now.Add(-time.Hour), 10)
+	if err != nil || len(msgs) != 1 || msgs[0].ID != "2" {
+		t.Fatalf("ReadHistory = %+v, %v", msgs, err)
+	}
+	if _, err := m.ReadHistory(context.Background(), "telegram", "c1", now, 10); !errors.Is(err, ErrHistoryUnsupported) {
+		t.Errorf("telegram err = %v, want ErrHistoryUnsupported", err)
+	}
+	if _, err := m.ReadHistory(context.Background(), "slack", "c1", now, 10); err == nil {
+		t.Error("disabled channel should fail")
+	}
+}
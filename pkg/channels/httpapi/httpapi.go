@@ -0,0 +1,271 @@
+// Package httpapi is a channel for external systems: they POST a prompt
+// to the gateway and receive the bot's reply in the response body.
+package httpapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/structured"
+)
+
+const (
+	defaultPath         = "/message"
+	defaultReplyTimeout = 120 * time.Second
+	maxBodySize         = 1 << 20
+	signatureHeader     = "X-Signature-256"
+)
+
+// Request is the body of POST /message.
+type Request struct {
+	User     string            `json:"user"`
+	ChatID   string            `json:"chat_id,omitempty"` // defaults to user, one session per caller
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// JSON schema the reply must conform to; it is then returned as data
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// Response is the body returned with the bot's reply.
+type Response struct {
+	ChatID string          `json:"chat_id"`
+	Reply  string          `json:"reply,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"` // the reply, for requests with a schema
+	Error  string          `json:"error,omitempty"`
+}
+
+// HTTPChannel answers signed HTTP requests. Each request waits for the
+// agent's next reply to its chat; replies are handed out in request order.
+type HTTPChannel struct {
+	*channels.BaseChannel
+	config config.HTTPConfig
+	secret []byte
+
+	mu      sync.Mutex
+	waiting map[string][]chan string // chat ID -> replies awaited, oldest first
+	nextID  atomic.Int64
+}
+
+// NewHTTPChannel creates the channel. A secret is required: the endpoint
+// lets anyone who can sign run the agent.
+func NewHTTPChannel(cfg config.HTTPConfig, messageBus *bus.MessageBus) (*HTTPChannel, error) {
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("http channel secret is required")
+	}
+	base := channels.NewBaseChannel("http", cfg, messageBus, cfg.AllowFrom)
+	return &HTTPChannel{
+		BaseChannel: base,
+		config:      cfg,
+		secret:      []byte(cfg.Secret),
+		waiting:     make(map[string][]chan string),
+	}, nil
+}
+
+// Start marks the channel ready; requests arrive on the shared HTTP server.
+func (c *HTTPChannel) Start(ctx context.Context) error {
+	c.SetRunning(true)
+	logger.InfoCF("http", "HTTP channel started", map[string]any{"path": c.WebhookPath()})
+	return nil
+}
+
+// Stop fails the requests still waiting for a reply.
+func (c *HTTPChannel) Stop(ctx context.Context) error {
+	c.SetRunning(false)
+	c.mu.Lock()
+	for chatID, queue := range c.waiting {
+		for _, ch := range queue {
+			close(ch)
+		}
+		delete(c.waiting, chatID)
+	}
+	c.mu.Unlock()
+	logger.InfoC("http", "HTTP channel stopped")
+	return nil
+}
+
+// Send hands the reply to the oldest request waiting on the chat.
+func (c *HTTPChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	c.mu.Lock()
+	queue := c.waiting[msg.ChatID]
+	if len(queue) == 0 {
+		c.mu.Unlock()
+		logger.DebugCF("http", "Reply without a waiting request dropped", map[string]any{
+			"chat_id": msg.ChatID,
+		})
+		return nil
+	}
+	ch := queue[0]
+	c.dequeueLocked(msg.ChatID, ch)
+	ch <- msg.Content // buffered; under the lock so Stop cannot close it first
+	c.mu.Unlock()
+	return nil
+}
+
+// WebhookPath returns the path for registering on the shared HTTP server.
+func (c *HTTPChannel) WebhookPath() string {
+	if c.config.Path != "" {
+		return c.config.Path
+	}
+	return defaultPath
+}
+
+// ServeHTTP implements http.Handler for the shared HTTP server.
+func (c *HTTPChannel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !c.IsRunning() {
+		writeJSON(w, http.StatusServiceUnavailable, Response{Error: "channel is not running"})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil || len(body) > maxBodySize {
+		writeJSON(w, http.StatusRequestEntityTooLarge, Response{Error: "request body too large"})
+		return
+	}
+	if !c.verifySignature(body, r.Header.Get(signatureHeader)) {
+		logger.WarnC("http", "Invalid request signature")
+		writeJSON(w, http.StatusUnauthorized, Response{Error: "invalid signature"})
+		return
+	}
+
+	var req Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	req.User = strings.TrimSpace(req.User)
+	if req.User == "" || strings.TrimSpace(req.Text) == "" {
+		writeJSON(w, http.StatusBadRequest, Response{Error: "user and text are required"})
+		return
+	}
+	chatID := req.ChatID
+	if chatID == "" {
+		chatID = req.User
+	}
+	var schema *structured.Schema
+	if len(req.Schema) > 0 {
+		if schema, err = structured.Parse(req.Schema); err != nil {
+			writeJSON(w, http.StatusBadRequest, Response{ChatID: chatID, Error: err.Error()})
+			return
+		}
+		metadata := make(map[string]string, len(req.Metadata)+1)
+		for k, v := range req.Metadata {
+			metadata[k] = v
+		}
+		metadata[structured.MetadataKey] = schema.String()
+		req.Metadata = metadata
+	}
+	// HandleMessage drops disallowed senders silently; answer instead of
+	// waiting for a reply that never comes
+	if !c.IsAllowed(req.User) {
+		writeJSON(w, http.StatusForbidden, Response{ChatID: chatID, Error: "sender not allowed"})
+		return
+	}
+
+	reply := make(chan string, 1)
+	c.mu.Lock()
+	c.waiting[chatID] = append(c.waiting[chatID], reply)
+	c.mu.Unlock()
+
+	msgID := strconv.FormatInt(c.nextID.Add(1), 10)
+	peer := bus.Peer{Kind: "direct", ID: req.User}
+	c.HandleMessage(r.Context(), peer, msgID, req.User, chatID, req.Text, nil, req.Metadata)
+
+	timeout := defaultReplyTimeout
+	if c.config.ReplyTimeout > 0 {
+		timeout = time.Duration(c.config.ReplyTimeout) * time.Second
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case content, ok := <-reply:
+		if !ok {
+			writeJSON(w, http.StatusServiceUnavailable, Response{ChatID: chatID, Error: "channel stopped"})
+			return
+		}
+		if schema == nil {
+			writeJSON(w, http.StatusOK, Response{ChatID: chatID, Reply: content})
+			return
+		}
+		// A reply that is not data is the agent's error message
+		data, err := schema.Validate(content)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, Response{
+				ChatID: chatID,
+				Reply:  content,
+				Error:  structured.ErrNoMatch.Error(),
+			})
+			return
+		}
+		writeJSON(w, http.StatusOK, Response{ChatID: chatID, Data: data})
+	case <-timer.C:
+		c.dequeue(chatID, reply)
+		writeJSON(w, http.StatusGatewayTimeout, Response{ChatID: chatID, Error: "no reply within " + timeout.String()})
+	case <-r.Context().Done():
+		c.dequeue(chatID, reply)
+	}
+}
+
+// verifySignature checks the sha256=<hex> HMAC of the body, as GitHub
+// webhooks sign it.
+func (c *HTTPChannel) verifySignature(body []byte, signature string) bool {
+	sig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// dequeue stops waiting for a reply, unless Send already took the slot.
+func (c *HTTPChannel) dequeue(chatID string, ch chan string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dequeueLocked(chatID, ch)
+}
+
+func (c *HTTPChannel) dequeueLocked(chatID string, ch chan string) {
+	queue := c.waiting[chatID]
+	for i, q := range queue {
+		if q == ch {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(c.waiting, chatID)
+	} else {
+		c.waiting[chatID] = queue
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
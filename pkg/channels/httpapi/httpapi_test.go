@@ -0,0 +1,145 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/structured"
+)
+
+const testSecret = "s3cret"
+
+func sign(body string) string {
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newTestChannel(t *testing.T, cfg config.HTTPConfig) (*HTTPChannel, *bus.MessageBus) {
+	t.Helper()
+	cfg.Secret = testSecret
+	mb := bus.NewMessageBus()
+	t.Cleanup(mb.Close)
+	ch, err := NewHTTPChannel(cfg, mb)
+	if err != nil {
+		t.Fatalf("NewHTTPChannel() error = %v", err)
+	}
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	return ch, mb
+}
+
+func post(ch *HTTPChannel, body, signature string) (*httptest.ResponseRecorder, Response) {
+	req := httptest.NewRequest(http.MethodPost, ch.WebhookPath(), strings.NewReader(body))
+	req.Header.Set(signatureHeader, signature)
+	rec := httptest.NewRecorder()
+	ch.ServeHTTP(rec, req)
+	var resp Response
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp
+}
+
+func TestNewHTTPChannel_RequiresSecret(t *testing.T) {
+	if _, err := NewHTTPChannel(config.HTTPConfig{}, nil); err == nil {
+		t.Fatal("expected error without a secret")
+	}
+}
+
+func TestServeHTTP_ReturnsReply(t *testing.T) {
+	ch, mb := newTestChannel(t, config.HTTPConfig{})
+	go func() {
+		in := <-mb.InboundChan()
+		ch.Send(context.Background(), bus.OutboundMessage{
+			Channel: "http", ChatID: in.ChatID, Content: "echo: " + in.Content,
+		})
+	}()
+
+	body := `{"user": "ci", "text": "build status?"}`
+	rec, resp := post(ch, body, sign(body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if resp.ChatID != "ci" || resp.Reply != "echo: build status?" {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestServeHTTP_RejectsBadRequests(t *testing.T) {
+	ch, _ := newTestChannel(t, config.HTTPConfig{AllowFrom: config.FlexibleStringSlice{"ci"}})
+
+	body := `{"user": "ci", "text": "hi"}`
+	if rec, _ := post(ch, body, "sha256=00"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("bad signature: status = %d", rec.Code)
+	}
+	if rec, _ := post(ch, body, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("missing signature: status = %d", rec.Code)
+	}
+	empty := `{"user": "ci"}`
+	if rec, _ := post(ch, empty, sign(empty)); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing text: status = %d", rec.Code)
+	}
+	other := `{"user": "mallory", "text": "hi"}`
+	if rec, _ := post(ch, other, sign(other)); rec.Code != http.StatusForbidden {
+		t.Errorf("disallowed sender: status = %d", rec.Code)
+	}
+}
+
+func TestServeHTTP_TimesOut(t *testing.T) {
+	ch, _ := newTestChannel(t, config.HTTPConfig{ReplyTimeout: 1})
+
+	start := time.Now()
+	body := `{"user": "ci", "chat_id": "builds", "text": "hi"}`
+	rec, resp := post(ch, body, sign(body))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusGatewayTimeout)
+	}
+	if resp.ChatID != "builds" || time.Since(start) < time.Second {
+		t.Errorf("response = %+v after %s", resp, time.Since(start))
+	}
+	// A late reply finds no waiting request and is dropped
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "builds", Content: "late"}); err != nil {
+		t.Errorf("Send() error = %v", err)
+	}
+	if len(ch.waiting) != 0 {
+		t.Errorf("waiting = %v, want empty", ch.waiting)
+	}
+}
+
+func TestServeHTTP_Schema(t *testing.T) {
+	ch, mb := newTestChannel(t, config.HTTPConfig{})
+	go func() {
+		for _, reply := range []string{`{"status": "green"}`, "all good"} {
+			in := <-mb.InboundChan()
+			if in.Metadata[structured.MetadataKey] == "" {
+				reply = "no schema"
+			}
+			ch.Send(context.Background(), bus.OutboundMessage{Channel: "http", ChatID: in.ChatID, Content: reply})
+		}
+	}()
+
+	body := `{"user": "ci", "text": "build status?", "schema": {"type": "object", "required": ["status"]}}`
+	rec, resp := post(ch, body, sign(body))
+	if rec.Code != http.StatusOK || string(resp.Data) != `{"status":"green"}` || resp.Reply != "" {
+		t.Fatalf("status = %d, response = %+v", rec.Code, resp)
+	}
+	rec, resp = post(ch, body, sign(body))
+	if rec.Code != http.StatusBadGateway || resp.Reply != "all good" || resp.Error == "" {
+		t.Errorf("not conforming: status = %d, response = %+v", rec.Code, resp)
+	}
+
+	invalid := `{"user": "ci", "text": "hi", "schema": {"type": 3}}`
+	if rec, _ := post(ch, invalid, sign(invalid)); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid schema: status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,13 @@
+package httpapi
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func init() {
+	channels.RegisterFactory("http", func(cfg *config.Config, b *bus.MessageBus) (channels.Channel, error) {
+		return NewHTTPChannel(cfg.Channels.HTTP, b)
+	})
+}
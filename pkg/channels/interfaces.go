@@ -2,6 +2,7 @@ package channels
 
 import (
 	"context"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/commands"
 )
@@ -19,6 +20,11 @@ type MessageEditor interface {
 	EditMessage(ctx context.Context, chatID string, messageID string, content string) error
 }
 
+// MessageDeleter — channels that can delete a message they sent.
+type MessageDeleter interface {
+	DeleteMessage(ctx context.Context, chatID string, messageID string) error
+}
+
 // ReactionCapable — channels that can add a reaction (e.g. 👀) to an inbound message.
 // ReactToMessage adds a reaction and returns an undo function to remove it.
 // The undo function MUST be idempotent and safe to call multiple times.
@@ -35,6 +41,34 @@ type PlaceholderCapable interface {
 	SendPlaceholder(ctx context.Context, chatID string) (messageID string, err error)
 }
 
+// StreamingCapable — channels that can show a reply while it is generated by
+// editing one message. The channel MUST also implement MessageEditor.
+// StreamingDefault reports whether replies stream unless the user turned
+// streaming off, and the shortest time between two edits the platform allows.
+// BeginStream posts the message the streamed text is written into.
+type StreamingCapable interface {
+	StreamingDefault() (enabled bool, editInterval time.Duration)
+	BeginStream(ctx context.Context, chatID, content string) (messageID string, err error)
+}
+
+// HeartbeatCapable — channels with their own way of showing that a long reply
+// is still being prepared, such as a status card or a "working" reaction.
+// Heartbeat is called every few seconds while a turn runs. Channels without
+// it get their typing indicator kept alive and the status text shown as
+// progress.
+type HeartbeatCapable interface {
+	Heartbeat(ctx context.Context, chatID string, status HeartbeatStatus) error
+}
+
+// HeartbeatStatus is what a heartbeat reports about the turn in progress.
+// Text is empty until the turn runs long enough to post a status; ETA is 0
+// when the time left cannot be estimated.
+type HeartbeatStatus struct {
+	Elapsed time.Duration
+	ETA     time.Duration
+	Text    string
+}
+
 // PlaceholderRecorder is injected into channels by Manager.
 // Channels call these methods on inbound to register typing/placeholder state.
 // Manager uses the registered state on outbound to stop typing and edit placeholders.
@@ -42,6 +76,9 @@ type PlaceholderRecorder interface {
 	RecordPlaceholder(channel, chatID, placeholderID string)
 	RecordTypingStop(channel, chatID string, stop func())
 	RecordReactionUndo(channel, chatID string, undo func())
+	// RecordReply registers a message the bot sent, so a regenerated reply
+	// can later replace it in place.
+	RecordReply(channel, chatID, messageID string)
 }
 
 // CommandRegistrarCapable is implemented by channels that can register
@@ -50,3 +87,17 @@ type PlaceholderRecorder interface {
 type CommandRegistrarCapable interface {
 	RegisterCommands(ctx context.Context, defs []commands.Definition) error
 }
+
+// ANSICapable — channels that render ```ansi code blocks with colors (e.g. Discord).
+// When it reports true, the Manager colorizes diff/log/test blocks before sending;
+// every other channel gets ```ansi blocks downgraded to plain fences.
+type ANSICapable interface {
+	SupportsANSI() bool
+}
+
+// ReplyReactionCapable — channels that report reactions to the bot's messages
+// through BaseChannel.HandleReaction. When it reports true, prompts ask users
+// to react instead of typing their answer.
+type ReplyReactionCapable interface {
+	SupportsReplyReactions() bool
+}
@@ -0,0 +1,165 @@
+package irc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/format"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// mIRC formatting control codes.
+const (
+	ircBold      = "\x02"
+	ircItalic    = "\x1D"
+	ircStrike    = "\x1E"
+	ircMonospace = "\x11"
+	ircReset     = "\x0F"
+)
+
+const (
+	// ircLineLimit is the protocol limit for a line, CRLF included.
+	ircLineLimit = 512
+	// maxHostLen reserves room for our host in the prefix the server adds
+	// when relaying a message; the client cannot see its own cloak.
+	maxHostLen = 63
+	// formatReserve leaves room to close and reopen formatting at a cut.
+	formatReserve = 6
+	// minLineBudget keeps splitting sane for very long nicks or targets.
+	minLineBudget = 64
+)
+
+var (
+	reHeading    = regexp.MustCompile(`^#{1,6}\s+(.+?)\s*#*$`)
+	reListItem   = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	reInlineCode = regexp.MustCompile("`([^`]+)`")
+	reLink       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	reBold       = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	reItalicStar = regexp.MustCompile(`(^|[^\w*])\*([^*\s](?:[^*]*[^*\s])?)\*`)
+	reItalicUnd  = regexp.MustCompile(`(^|[^\w])_([^_\s](?:[^_]*[^_\s])?)_`)
+	reStrike     = regexp.MustCompile(`~~(.+?)~~`)
+)
+
+// markdownToIRC rewrites the model's Markdown line by line. Emphasis becomes
+// mIRC formatting codes, or plain text when plain is set; code blocks lose
+// their fences and are sent verbatim.
+func markdownToIRC(text string, plain bool) []string {
+	if plain {
+		return strings.Split(format.Render(text, format.Plain), "\n")
+	}
+	style := func(code, s string) string {
+		return code + s + code
+	}
+
+	var lines []string
+	source := strings.Split(text, "\n")
+	for i, line := range source {
+		source[i] = strings.TrimRight(line, "\r")
+	}
+	fenced := utils.FencedLines(source)
+	for i, line := range source {
+		switch fenced[i] {
+		case utils.FenceOpen, utils.FenceClose:
+			continue
+		case utils.FencedCode:
+			lines = append(lines, line)
+			continue
+		}
+
+		var codes []string
+		line = reInlineCode.ReplaceAllStringFunc(line, func(m string) string {
+			codes = append(codes, reInlineCode.FindStringSubmatch(m)[1])
+			return fmt.Sprintf("\x00%d\x00", len(codes)-1)
+		})
+		if m := reHeading.FindStringSubmatch(line); m != nil {
+			line = style(ircBold, m[1])
+		}
+		line = reListItem.ReplaceAllString(line, "$1• ")
+		line = reLink.ReplaceAllStringFunc(line, func(m string) string {
+			sub := reLink.FindStringSubmatch(m)
+			if sub[1] == sub[2] {
+				return sub[2]
+			}
+			return sub[1] + " (" + sub[2] + ")"
+		})
+		line = reBold.ReplaceAllStringFunc(line, func(m string) string {
+			sub := reBold.FindStringSubmatch(m)
+			return style(ircBold, sub[1]+sub[2])
+		})
+		line = reItalicStar.ReplaceAllStringFunc(line, func(m string) string {
+			sub := reItalicStar.FindStringSubmatch(m)
+			return sub[1] + style(ircItalic, sub[2])
+		})
+		line = reItalicUnd.ReplaceAllStringFunc(line, func(m string) string {
+			sub := reItalicUnd.FindStringSubmatch(m)
+			return sub[1] + style(ircItalic, sub[2])
+		})
+		line = reStrike.ReplaceAllStringFunc(line, func(m string) string {
+			return style(ircStrike, reStrike.FindStringSubmatch(m)[1])
+		})
+		for i, code := range codes {
+			line = strings.ReplaceAll(line, fmt.Sprintf("\x00%d\x00", i), style(ircMonospace, code))
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// lineBudget returns how many bytes of text fit in one PRIVMSG to target,
+// so the line the server relays (":nick!~user@host PRIVMSG target :text")
+// stays within the 512-byte limit.
+func lineBudget(nick, user, target string) int {
+	prefix := len(":") + len(nick) + len("!~") + len(user) + len("@") + maxHostLen
+	budget := ircLineLimit - prefix - len(" PRIVMSG ") - len(target) - len(" :") - len("\r\n")
+	return max(budget, minLineBudget)
+}
+
+// splitLine cuts line into pieces of at most budget bytes, preferring
+// spaces and never splitting a UTF-8 sequence. Formatting still open at a
+// cut is reset and reopened on the next piece.
+func splitLine(line string, budget int) []string {
+	var pieces []string
+	carry := ""
+	for {
+		s := carry + line
+		if len(s) <= budget {
+			return append(pieces, s)
+		}
+		limit := budget - formatReserve
+		cut := strings.LastIndexByte(s[:limit], ' ')
+		if cut <= len(carry) {
+			cut = limit
+			for cut > len(carry) && !utf8.RuneStart(s[cut]) {
+				cut--
+			}
+		}
+		piece := s[:cut]
+		line = strings.TrimLeft(s[cut:], " ")
+		carry = openFormatting(piece)
+		if carry != "" {
+			piece += ircReset
+		}
+		pieces = append(pieces, piece)
+	}
+}
+
+// openFormatting returns the formatting codes left on at the end of s, in
+// the order they were turned on.
+func openFormatting(s string) string {
+	var open []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ircBold[0], ircItalic[0], ircStrike[0], ircMonospace[0]:
+			if j := strings.IndexByte(string(open), c); j >= 0 {
+				open = append(open[:j], open[j+1:]...)
+			} else {
+				open = append(open, c)
+			}
+		case ircReset[0]:
+			open = open[:0]
+		}
+	}
+	return string(open)
+}
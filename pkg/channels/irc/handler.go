@@ -23,7 +23,11 @@ func (c *IRCChannel) onConnect(conn *ircevent.Connection) {
 
 	// Join configured channels
 	for _, ch := range c.config.Channels {
-		conn.Join(ch)
+		if key := c.config.ChannelKeys[ch]; key != "" {
+			conn.Send("JOIN", ch, key)
+		} else {
+			conn.Join(ch)
+		}
 		logger.InfoCF("irc", "Joined IRC channel", map[string]any{
 			"channel": ch,
 		})
@@ -50,10 +50,7 @@ func (c *IRCChannel) Start(ctx context.Context) error {
 	logger.InfoC("irc", "Starting IRC channel")
 	c.ctx, c.cancel = context.WithCancel(ctx)
 
-	user := c.config.User
-	if user == "" {
-		user = c.config.Nick
-	}
+	user := c.user()
 	realName := c.config.RealName
 	if realName == "" {
 		realName = c.config.Nick
@@ -144,23 +141,43 @@ func (c *IRCChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
 		return nil
 	}
 
-	// Send each line separately (IRC is line-oriented)
-	lines := strings.Split(msg.Content, "\n")
-	for _, line := range lines {
-		line = strings.TrimRight(line, "\r")
-		if line == "" {
+	// Send each line separately (IRC is line-oriented), cut to what fits
+	// in one protocol line
+	budget := lineBudget(c.conn.CurrentNick(), c.user(), target)
+	plain := c.config.MessageFormat == "plain"
+	sent := 0
+	for _, line := range markdownToIRC(msg.Content, plain) {
+		if strings.TrimSpace(line) == "" {
 			continue
 		}
-		c.conn.Privmsg(target, line)
+		for _, piece := range splitLine(line, budget) {
+			if err := c.conn.Privmsg(target, piece); err != nil {
+				// A retry resends the whole message, so once lines went out
+				// it would repeat them in the channel.
+				if sent > 0 {
+					return fmt.Errorf("irc send after %d lines: %w", sent, channels.ErrSendFailed)
+				}
+				return fmt.Errorf("irc send: %w", channels.ErrTemporary)
+			}
+			sent++
+		}
 	}
 
 	logger.DebugCF("irc", "Message sent", map[string]any{
 		"target": target,
-		"lines":  len(lines),
+		"lines":  sent,
 	})
 	return nil
 }
 
+// user returns the username sent at registration.
+func (c *IRCChannel) user() string {
+	if c.config.User != "" {
+		return c.config.User
+	}
+	return c.config.Nick
+}
+
 // StartTyping implements channels.TypingCapable using IRCv3 +typing client tag.
 // Requires typing.enabled in config and server support for message-tags capability.
 func (c *IRCChannel) StartTyping(ctx context.Context, chatID string) (func(), error) {
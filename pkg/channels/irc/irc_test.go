@@ -1,7 +1,9 @@
 package irc
 
 import (
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -143,3 +145,73 @@ func TestStripBotMention(t *testing.T) {
 		})
 	}
 }
+
+func TestMarkdownToIRC(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		plain bool
+		want  []string
+	}{
+		{"bold", "a **b** c", false, []string{"a \x02b\x02 c"}},
+		{"italic", "an *it* and _it_", false, []string{"an \x1Dit\x1D and \x1Dit\x1D"}},
+		{"strike", "~~gone~~", false, []string{"\x1Egone\x1E"}},
+		{"inline code", "run `a **b**`", false, []string{"run \x11a **b**\x11"}},
+		{"heading", "## Steps", false, []string{"\x02Steps\x02"}},
+		{"list", "- one\n  * two", false, []string{"• one", "  • two"}},
+		{"link", "see [docs](https://x.io)", false, []string{"see docs (https://x.io)"}},
+		{"code block", "```go\nx := *p\n```\ndone", false, []string{"x := *p", "done"}},
+		{"plain drops formatting", "**b** *i* `c`", true, []string{"b i c"}},
+		{"snake_case kept", "use snake_case_name", false, []string{"use snake_case_name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := markdownToIRC(tt.input, tt.plain)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("markdownToIRC(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineBudget(t *testing.T) {
+	// ":bot!~bot@<63> PRIVMSG #go :" plus CRLF
+	if got, want := lineBudget("bot", "bot", "#go"), 512-(1+3+2+3+1+63)-9-3-2-2; got != want {
+		t.Errorf("lineBudget() = %d, want %d", got, want)
+	}
+	if got := lineBudget(strings.Repeat("n", 300), "u", strings.Repeat("#", 300)); got != minLineBudget {
+		t.Errorf("lineBudget() with huge overhead = %d, want %d", got, minLineBudget)
+	}
+}
+
+func TestSplitLine(t *testing.T) {
+	line := strings.Repeat("héllo wörld ", 60)
+	pieces := splitLine(line, 100)
+	if len(pieces) < 2 {
+		t.Fatalf("expected several pieces, got %d", len(pieces))
+	}
+	for i, p := range pieces {
+		if len(p) > 100 {
+			t.Errorf("piece %d is %d bytes", i, len(p))
+		}
+		if !utf8.ValidString(p) {
+			t.Errorf("piece %d splits a UTF-8 sequence", i)
+		}
+	}
+	if got := strings.Join(pieces, " "); strings.Join(strings.Fields(got), " ") != strings.TrimSpace(line) {
+		t.Error("pieces do not add up to the line")
+	}
+
+	// Bold left open at a cut is reset and reopened
+	pieces = splitLine("\x02"+strings.Repeat("word ", 40)+"\x02", 80)
+	if !strings.HasSuffix(pieces[0], "\x0F") || !strings.HasPrefix(pieces[1], "\x02") {
+		t.Errorf("formatting not carried across the cut: %q", pieces[:2])
+	}
+
+	nospace := strings.Repeat("界", 100)
+	for i, p := range splitLine(nospace, 70) {
+		if len(p) > 70 || !utf8.ValidString(p) {
+			t.Errorf("piece %d = %q", i, p)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package channels
+
+import (
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// LengthFunc measures a message the way a platform counts it against its
+// message length limit.
+type LengthFunc func(s string) int
+
+// RuneLength counts Unicode code points (Discord, Slack and most platforms).
+func RuneLength(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// UTF16Length counts UTF-16 code units, as Telegram does: characters outside
+// the Basic Multilingual Plane (most emoji) count as two.
+func UTF16Length(s string) int {
+	n := 0
+	for _, r := range s {
+		if l := utf16.RuneLen(r); l > 0 {
+			n += l
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// ByteLength counts UTF-8 bytes, for protocols with byte-based limits such as IRC.
+func ByteLength(s string) int {
+	return len(s)
+}
+
+// LengthFuncForUnit returns the length function for a configured unit:
+// "runes", "bytes", or "utf16". It returns nil for an empty or unknown unit.
+func LengthFuncForUnit(unit string) LengthFunc {
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "runes", "chars", "characters":
+		return RuneLength
+	case "bytes":
+		return ByteLength
+	case "utf16", "utf-16":
+		return UTF16Length
+	}
+	return nil
+}
+
+// LengthFuncProvider is an opt-in interface for channels whose platform does
+// not count message length in runes. The Manager uses it to decide when and
+// where to split outbound messages.
+type LengthFuncProvider interface {
+	MessageLengthFunc() LengthFunc
+}
+
+// messageLengthFunc returns the length function a channel advertises, or
+// RuneLength when it does not provide one.
+func messageLengthFunc(ch Channel) LengthFunc {
+	if lp, ok := ch.(LengthFuncProvider); ok {
+		if fn := lp.MessageLengthFunc(); fn != nil {
+			return fn
+		}
+	}
+	return RuneLength
+}
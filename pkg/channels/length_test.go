@@ -0,0 +1,115 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLengthFuncs(t *testing.T) {
+	tests := []struct {
+		name                string
+		input               string
+		runes, utf16, bytes int
+	}{
+		{"ascii", "hello", 5, 5, 5},
+		{"cjk", "你好", 2, 2, 6},
+		{"emoji outside BMP", "👍", 1, 2, 4},
+		{"mixed", "ok 🎉!", 5, 6, 8},
+		{"empty", "", 0, 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := RuneLength(tc.input); got != tc.runes {
+				t.Errorf("RuneLength(%q) = %d, want %d", tc.input, got, tc.runes)
+			}
+			if got := UTF16Length(tc.input); got != tc.utf16 {
+				t.Errorf("UTF16Length(%q) = %d, want %d", tc.input, got, tc.utf16)
+			}
+			if got := ByteLength(tc.input); got != tc.bytes {
+				t.Errorf("ByteLength(%q) = %d, want %d", tc.input, got, tc.bytes)
+			}
+		})
+	}
+}
+
+func TestSplitMessageWithLength_UTF16(t *testing.T) {
+	// 150 emoji are 150 runes but 300 UTF-16 code units.
+	content := strings.Repeat("👍 ", 150)
+	maxLen := 200
+
+	if chunks := SplitMessage(content, 400); len(chunks) != 1 {
+		t.Fatalf("rune-based split should keep one chunk, got %d", len(chunks))
+	}
+
+	chunks := SplitMessageWithLength(content, maxLen, UTF16Length)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected UTF-16 split into multiple chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if l := UTF16Length(chunk); l > maxLen {
+			t.Errorf("Chunk %d is %d UTF-16 units, exceeds %d", i, l, maxLen)
+		}
+	}
+	if joined := strings.Join(chunks, " "); strings.Count(joined, "👍") != 150 {
+		t.Errorf("Expected all 150 emoji preserved, got %d", strings.Count(joined, "👍"))
+	}
+}
+
+func TestSplitMessageWithLength_Bytes(t *testing.T) {
+	content := strings.Repeat("é", 100) // 2 bytes each
+	chunks := SplitMessageWithLength(content, 60, ByteLength)
+	for i, chunk := range chunks {
+		if len(chunk) > 60 {
+			t.Errorf("Chunk %d is %d bytes, exceeds 60", i, len(chunk))
+		}
+	}
+	if got := strings.Join(chunks, ""); got != content {
+		t.Errorf("Hard byte split must not lose content")
+	}
+}
+
+func TestSplitMessageWithLength_RuneCountingMatchesRuneLength(t *testing.T) {
+	content := strings.Repeat("Hello 👋🏽 wörld. ", 40) + "\n```go\n" +
+		strings.Repeat("x := 1 // 🇯🇵\n", 30) + "```\n> " + strings.Repeat("quoted ", 50)
+	for _, maxLen := range []int{60, 200, 500} {
+		counted, measured := SplitMessageWithLength(content, maxLen, nil), SplitMessageWithLength(content, maxLen, RuneLength)
+		if strings.Join(counted, "\x00") != strings.Join(measured, "\x00") {
+			t.Errorf("maxLen %d: counting runes gives %q, RuneLength %q", maxLen, counted, measured)
+		}
+	}
+}
+
+func TestMessageLengthFunc(t *testing.T) {
+	ch := &mockChannel{}
+	if messageLengthFunc(ch)("👍") != 1 {
+		t.Error("expected rune length when channel does not set one")
+	}
+	WithLengthFunc(UTF16Length)(&ch.BaseChannel)
+	if messageLengthFunc(ch)("👍") != 2 {
+		t.Error("expected channel-provided UTF-16 length func")
+	}
+}
+
+func TestLengthFuncForUnit(t *testing.T) {
+	for unit, want := range map[string]int{"runes": 1, "bytes": 4, "UTF16": 2} {
+		if fn := LengthFuncForUnit(unit); fn == nil || fn("👍") != want {
+			t.Errorf("LengthFuncForUnit(%q) counts 👍 as %v, want %d", unit, fn, want)
+		}
+	}
+	if fn := LengthFuncForUnit("words"); fn != nil {
+		t.Error("expected nil for an unknown unit")
+	}
+
+	ch := &mockChannel{}
+	WithLengthFunc(UTF16Length)(&ch.BaseChannel)
+	WithLengthUnit("")(&ch.BaseChannel)
+	WithLengthUnit("words")(&ch.BaseChannel)
+	if messageLengthFunc(ch)("👍") != 2 {
+		t.Error("empty or unknown unit should keep the platform length func")
+	}
+	WithLengthUnit("bytes")(&ch.BaseChannel)
+	if messageLengthFunc(ch)("👍") != 4 {
+		t.Error("configured unit should override the platform length func")
+	}
+}
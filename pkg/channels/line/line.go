@@ -17,6 +17,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/format"
 	"github.com/sipeed/picoclaw/pkg/identity"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/media"
@@ -378,7 +379,7 @@ func (c *LINEChannel) processEvent(event lineEvent) {
 		"chat_id":      chatID,
 		"message_type": msg.Type,
 		"is_group":     isGroup,
-		"preview":      utils.Truncate(content, 50),
+		"preview":      c.LogPreview(chatID, senderID, content, 50),
 	})
 
 	sender := bus.SenderInfo{
@@ -500,6 +501,9 @@ func (c *LINEChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
 		return channels.ErrNotRunning
 	}
 
+	// LINE shows text messages as written, so Markdown would show its marks
+	text := format.Render(msg.Content, format.Plain)
+
 	// Load and consume quote token for this chat
 	var quoteToken string
 	if qt, ok := c.quoteTokens.LoadAndDelete(msg.ChatID); ok {
@@ -510,7 +514,7 @@ func (c *LINEChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
 	if entry, ok := c.replyTokens.LoadAndDelete(msg.ChatID); ok {
 		tokenEntry := entry.(replyTokenEntry)
 		if time.Since(tokenEntry.timestamp) < lineReplyTokenMaxAge {
-			if err := c.sendReply(ctx, tokenEntry.token, msg.Content, quoteToken); err == nil {
+			if err := c.sendReply(ctx, tokenEntry.token, text, quoteToken); err == nil {
 				logger.DebugCF("line", "Message sent via Reply API", map[string]any{
 					"chat_id": msg.ChatID,
 					"quoted":  quoteToken != "",
@@ -522,7 +526,7 @@ func (c *LINEChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
 	}
 
 	// Fall back to Push API
-	return c.sendPush(ctx, msg.ChatID, msg.Content, quoteToken)
+	return c.sendPush(ctx, msg.ChatID, text, quoteToken)
 }
 
 // SendMedia implements the channels.MediaSender interface.
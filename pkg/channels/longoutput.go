@@ -0,0 +1,274 @@
+package channels
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// defaultLongOutputMaxChunks is used when the gate is enabled without a threshold.
+	defaultLongOutputMaxChunks = 5
+	// pendingOutputTTL bounds how long a held reply waits for the user's choice.
+	pendingOutputTTL = 10 * time.Minute
+)
+
+// LongOutputSummaryPrompt is sent to the agent, on behalf of the user, when
+// they ask for a summary of a held reply.
+const LongOutputSummaryPrompt = "Your previous reply was too long to post. " +
+	"Summarize it in a few short paragraphs, keeping the key points and any commands or code the user needs."
+
+// LongOutputTurnPrefix starts the TurnID of the prompt about a held reply;
+// the key of the reply follows it. Reacting ✅, 📝 or 📋 to the prompt posts
+// the reply, asks for a summary, or attaches it as a file, and ❌ drops it.
+const LongOutputTurnPrefix = "long-output:"
+
+// Summarizer asks the agent, on behalf of sender, for a short version of
+// the reply of the agent turn turnID.
+type Summarizer func(ctx context.Context, turnID string, sender bus.SenderInfo) error
+
+// LongOutputGate is injected into channels by Manager. BaseChannel.HandleMessage
+// offers every inbound message to it first, so the user's answer to a
+// long-output prompt is consumed here instead of reaching the agent.
+type LongOutputGate interface {
+	ResolveLongOutput(ctx context.Context, msg bus.InboundMessage) bool
+}
+
+// LongOutputThresholdProvider is an opt-in interface for channels that gate
+// replies longer than a number of chunks. A threshold of 0 disables the gate.
+type LongOutputThresholdProvider interface {
+	LongOutputThreshold() int
+}
+
+// WithLongOutput enables the long-output confirmation gate from channel config.
+func WithLongOutput(cfg config.LongOutputConfig) BaseChannelOption {
+	return func(c *BaseChannel) {
+		if !cfg.Enabled {
+			c.longOutputThreshold = 0
+			return
+		}
+		c.longOutputThreshold = cfg.MaxChunks
+		if c.longOutputThreshold <= 0 {
+			c.longOutputThreshold = defaultLongOutputMaxChunks
+		}
+	}
+}
+
+// LongOutputThreshold returns the chunk count above which replies are held (0 = never).
+func (c *BaseChannel) LongOutputThreshold() int {
+	return c.longOutputThreshold
+}
+
+// SetLongOutputGate injects the gate that resolves pending long outputs.
+func (c *BaseChannel) SetLongOutputGate(g LongOutputGate) {
+	c.longOutputGate = g
+}
+
+// SetSummarizer registers the function that asks for the summary of a held
+// reply when its user reacts 📝 to the prompt.
+func (m *Manager) SetSummarizer(summarize Summarizer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.summarizer = summarize
+}
+
+type pendingOutput struct {
+	msg       bus.OutboundMessage
+	chunks    []string
+	createdAt time.Time
+}
+
+// longOutputThreshold returns the channel's gate threshold, or 0 when it has none.
+func longOutputThreshold(ch Channel) int {
+	if p, ok := ch.(LongOutputThresholdProvider); ok {
+		return p.LongOutputThreshold()
+	}
+	return 0
+}
+
+// longOutputKey identifies a held reply by its chat and the user it answers,
+// so only that user's answer resolves it.
+func longOutputKey(channel, chatID, senderID string) string {
+	return channel + ":" + chatID + ":" + senderID
+}
+
+// longOutputPrompt asks how a reply of n chunks should be delivered, in the
+// language lang. Channels that report reactions get reactions to choose from.
+func longOutputPrompt(ch Channel, lang string, n int) string {
+	if rc, ok := ch.(ReplyReactionCapable); ok && rc.SupportsReplyReactions() {
+		return i18n.Sprintf(lang, "This reply is %d messages long. React with ✅ to post it anyway, "+
+			"📝 for a short version, or 📋 to get it as an attachment.", n)
+	}
+	return i18n.Sprintf(lang, "This reply is %d messages long. Reply with \"all\" to post it anyway, "+
+		"\"summary\" for a short version, or \"file\" to get it as an attachment.", n)
+}
+
+// holdLongOutput parks a reply that exceeds the channel's chunk threshold and
+// asks the user how they want to receive it.
+func (m *Manager) holdLongOutput(
+	ctx context.Context,
+	name string,
+	w *channelWorker,
+	msg bus.OutboundMessage,
+	chunks []string,
+) {
+	key := longOutputKey(name, msg.ChatID, msg.SenderID)
+	m.pendingOutputs.Store(key, pendingOutput{msg: msg, chunks: chunks, createdAt: time.Now()})
+
+	logger.InfoCF("channels", "Holding long output for confirmation", map[string]any{
+		"channel": name,
+		"chat_id": msg.ChatID,
+		"chunks":  len(chunks),
+	})
+
+	prompt := msg
+	prompt.TurnID = LongOutputTurnPrefix + key
+	prompt.Content = longOutputPrompt(w.ch, msg.Locale, len(chunks))
+	m.sendWithRetry(ctx, name, w, prompt)
+}
+
+// ResolveLongOutput handles the user's answer to a long-output prompt.
+// It returns true when msg was consumed as an answer. Any other message
+// from the same user discards the held reply and is delivered to the agent
+// as usual. Implements LongOutputGate.
+func (m *Manager) ResolveLongOutput(ctx context.Context, msg bus.InboundMessage) bool {
+	key := longOutputKey(msg.Channel, msg.ChatID, msg.SenderID)
+	v, ok := m.pendingOutputs.LoadAndDelete(key)
+	if !ok {
+		return false
+	}
+	pending, ok := v.(pendingOutput)
+	if !ok || time.Since(pending.createdAt) > pendingOutputTTL {
+		return false
+	}
+
+	choice := strings.ToLower(strings.TrimSpace(msg.Content))
+	choice = strings.TrimLeft(choice, "/!")
+	switch choice {
+	case "all", "post all", "yes":
+		go m.sendHeldChunks(ctx, msg.Channel, pending)
+	case "summary", "summarize":
+		summary := msg
+		summary.Content = LongOutputSummaryPrompt
+		if err := m.bus.PublishInbound(ctx, summary); err != nil {
+			logger.ErrorCF("channels", "Failed to request summary of long output", map[string]any{
+				"channel": msg.Channel,
+				"chat_id": msg.ChatID,
+				"error":   err.Error(),
+			})
+		}
+	case "file", "attach", "attachment":
+		go m.sendHeldAsFile(ctx, msg.Channel, pending)
+	default:
+		return false
+	}
+	return true
+}
+
+// resolveLongOutputReaction handles a reaction to the prompt about the held
+// reply key. Reactions of users other than the one it answers are ignored.
+func (m *Manager) resolveLongOutputReaction(channel, chatID, key, action string, sender bus.SenderInfo) bool {
+	if key != longOutputKey(channel, chatID, sender.PlatformID) {
+		return false
+	}
+	m.mu.RLock()
+	summarize := m.summarizer
+	m.mu.RUnlock()
+	switch action {
+	case ReplyActionApprove, ReplyActionRaw, ReplyActionDeny:
+	case ReplyActionSummary:
+		if summarize == nil {
+			return false
+		}
+	default:
+		return false
+	}
+
+	v, ok := m.pendingOutputs.LoadAndDelete(key)
+	if !ok {
+		return false
+	}
+	pending, ok := v.(pendingOutput)
+	if !ok || time.Since(pending.createdAt) > pendingOutputTTL {
+		return false
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), replyActionTimeout)
+		defer cancel()
+		switch action {
+		case ReplyActionApprove:
+			m.sendHeldChunks(ctx, channel, pending)
+		case ReplyActionRaw:
+			m.sendHeldAsFile(ctx, channel, pending)
+		case ReplyActionSummary:
+			if err := summarize(ctx, pending.msg.TurnID, sender); err != nil {
+				logger.ErrorCF("channels", "Failed to request summary of long output", map[string]any{
+					"channel": channel,
+					"chat_id": chatID,
+					"error":   err.Error(),
+				})
+			}
+		}
+	}()
+	return true
+}
+
+func (m *Manager) sendHeldChunks(ctx context.Context, name string, pending pendingOutput) {
+	m.mu.RLock()
+	w, ok := m.workers[name]
+	m.mu.RUnlock()
+	if !ok || w == nil {
+		return
+	}
+	for _, chunk := range pending.chunks {
+		chunkMsg := pending.msg
+		chunkMsg.Content = chunk
+		chunkMsg.ReplaceReply = false // the prompt already replaced the previous reply
+		m.sendWithRetry(ctx, name, w, chunkMsg)
+	}
+	m.forgetReply(name, pending.msg.ChatID)
+}
+
+// sendHeldAsFile attaches the held reply as a Markdown file, falling back to
+// posting every chunk when the channel or media store cannot take files.
+func (m *Manager) sendHeldAsFile(ctx context.Context, name string, pending pendingOutput) {
+	m.mu.RLock()
+	w, ok := m.workers[name]
+	m.mu.RUnlock()
+	if !ok || w == nil {
+		return
+	}
+
+	_, canSendMedia := w.ch.(MediaSender)
+	if !canSendMedia || m.mediaStore == nil {
+		m.sendHeldChunks(ctx, name, pending)
+		return
+	}
+
+	ref, err := m.storeMarkdown(name, pending.msg.ChatID, "response", pending.msg.Content)
+	if err != nil {
+		logger.WarnCF("channels", "Failed to store long output as file, posting inline", map[string]any{
+			"channel": name,
+			"error":   err.Error(),
+		})
+		m.sendHeldChunks(ctx, name, pending)
+		return
+	}
+
+	m.sendMediaWithRetry(ctx, name, w, bus.OutboundMediaMessage{
+		Channel: name,
+		ChatID:  pending.msg.ChatID,
+		Parts: []bus.MediaPart{{
+			Type:        "file",
+			Ref:         ref,
+			Filename:    "response.md",
+			ContentType: "text/markdown",
+		}},
+	})
+}
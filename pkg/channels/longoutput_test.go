@@ -0,0 +1,292 @@
+package channels
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// recordingSender collects sent contents behind a mutex for use from goroutines.
+type recordingSender struct {
+	mu       sync.Mutex
+	contents []string
+}
+
+func (r *recordingSender) send(_ context.Context, msg bus.OutboundMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.contents = append(r.contents, msg.Content)
+	return nil
+}
+
+func (r *recordingSender) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.contents...)
+}
+
+func waitForSends(t *testing.T, r *recordingSender, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if got := r.snapshot(); len(got) >= n {
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := r.snapshot()
+	t.Fatalf("expected %d sends, got %d: %q", n, len(got), got)
+	return nil
+}
+
+func newGatedTestSetup(threshold int) (*Manager, *channelWorker, *recordingSender) {
+	rec := &recordingSender{}
+	ch := &mockChannelWithLength{mockChannel: mockChannel{sendFn: rec.send}, maxLen: 100}
+	WithLongOutput(config.LongOutputConfig{Enabled: true, MaxChunks: threshold})(&ch.BaseChannel)
+
+	m := newTestManager()
+	m.bus = bus.NewMessageBus()
+	w := &channelWorker{
+		ch:      ch,
+		queue:   make(chan bus.OutboundMessage, 10),
+		done:    make(chan struct{}),
+		limiter: rate.NewLimiter(rate.Inf, 1),
+	}
+	m.workers["test"] = w
+	return m, w, rec
+}
+
+func TestWithLongOutput(t *testing.T) {
+	c := &BaseChannel{}
+	WithLongOutput(config.LongOutputConfig{})(c)
+	if c.LongOutputThreshold() != 0 {
+		t.Errorf("disabled gate should have threshold 0, got %d", c.LongOutputThreshold())
+	}
+	WithLongOutput(config.LongOutputConfig{Enabled: true})(c)
+	if c.LongOutputThreshold() != defaultLongOutputMaxChunks {
+		t.Errorf("expected default threshold %d, got %d", defaultLongOutputMaxChunks, c.LongOutputThreshold())
+	}
+	WithLongOutput(config.LongOutputConfig{Enabled: true, MaxChunks: 3})(c)
+	if c.LongOutputThreshold() != 3 {
+		t.Errorf("expected threshold 3, got %d", c.LongOutputThreshold())
+	}
+}
+
+func TestRunWorker_HoldsLongOutput(t *testing.T) {
+	m, w, rec := newGatedTestSetup(2)
+	go m.runWorker(t.Context(), "test", w)
+
+	w.queue <- bus.OutboundMessage{Channel: "test", ChatID: "1", Content: strings.Repeat("word ", 100)}
+
+	sent := waitForSends(t, rec, 1)
+	if !strings.Contains(sent[0], "\"all\"") {
+		t.Fatalf("expected a confirmation prompt, got %q", sent[0])
+	}
+	if _, ok := m.pendingOutputs.Load("test:1:"); !ok {
+		t.Fatal("expected the reply to be held")
+	}
+}
+
+func TestRunWorker_ShortOutputNotHeld(t *testing.T) {
+	m, w, rec := newGatedTestSetup(5)
+	go m.runWorker(t.Context(), "test", w)
+
+	w.queue <- bus.OutboundMessage{Channel: "test", ChatID: "1", Content: strings.Repeat("word ", 30)}
+
+	sent := waitForSends(t, rec, 2)
+	if strings.Contains(sent[0], "\"all\"") {
+		t.Fatalf("short replies must not be gated, got %q", sent[0])
+	}
+}
+
+func TestResolveLongOutput_All(t *testing.T) {
+	m, w, rec := newGatedTestSetup(1)
+	ctx := t.Context()
+	msg := bus.OutboundMessage{Channel: "test", ChatID: "1", Content: "a b"}
+	m.holdLongOutput(ctx, "test", w, msg, []string{"a", "b"})
+
+	if !m.ResolveLongOutput(ctx, bus.InboundMessage{Channel: "test", ChatID: "1", Content: " All "}) {
+		t.Fatal("expected the answer to be consumed")
+	}
+	sent := waitForSends(t, rec, 3)
+	if sent[1] != "a" || sent[2] != "b" {
+		t.Errorf("expected held chunks after the prompt, got %q", sent)
+	}
+	if _, ok := m.pendingOutputs.Load("test:1:"); ok {
+		t.Error("pending output should be cleared")
+	}
+}
+
+func TestResolveLongOutput_Summary(t *testing.T) {
+	m, w, _ := newGatedTestSetup(1)
+	ctx := t.Context()
+	m.holdLongOutput(ctx, "test", w, bus.OutboundMessage{Channel: "test", ChatID: "1", SenderID: "u1"}, []string{"a", "b"})
+
+	in := bus.InboundMessage{Channel: "test", ChatID: "1", SenderID: "u1", Content: "summary"}
+	if !m.ResolveLongOutput(ctx, in) {
+		t.Fatal("expected the answer to be consumed")
+	}
+
+	select {
+	case got := <-m.bus.InboundChan():
+		if got.Content != LongOutputSummaryPrompt || got.SenderID != "u1" {
+			t.Errorf("unexpected summary request: %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a summary request on the inbound bus")
+	}
+}
+
+func TestResolveLongOutput_FileFallsBackWithoutMediaSupport(t *testing.T) {
+	m, w, rec := newGatedTestSetup(1)
+	ctx := t.Context()
+	m.holdLongOutput(ctx, "test", w, bus.OutboundMessage{Channel: "test", ChatID: "1"}, []string{"a", "b"})
+
+	if !m.ResolveLongOutput(ctx, bus.InboundMessage{Channel: "test", ChatID: "1", Content: "file"}) {
+		t.Fatal("expected the answer to be consumed")
+	}
+	waitForSends(t, rec, 3)
+}
+
+func TestResolveLongOutput_OtherMessageDiscardsPending(t *testing.T) {
+	m, w, _ := newGatedTestSetup(1)
+	ctx := t.Context()
+	m.holdLongOutput(ctx, "test", w, bus.OutboundMessage{Channel: "test", ChatID: "1"}, []string{"a", "b"})
+
+	if m.ResolveLongOutput(ctx, bus.InboundMessage{Channel: "test", ChatID: "1", Content: "never mind"}) {
+		t.Fatal("unrelated messages must reach the agent")
+	}
+	if _, ok := m.pendingOutputs.Load("test:1:"); ok {
+		t.Error("pending output should be discarded")
+	}
+	if m.ResolveLongOutput(ctx, bus.InboundMessage{Channel: "test", ChatID: "1", Content: "all"}) {
+		t.Error("nothing is pending any more")
+	}
+}
+
+func TestResolveLongOutput_OnlyTheUserAnsweredResolves(t *testing.T) {
+	m, w, _ := newGatedTestSetup(1)
+	ctx := t.Context()
+	m.holdLongOutput(ctx, "test", w, bus.OutboundMessage{Channel: "test", ChatID: "1", SenderID: "u1"}, []string{"a", "b"})
+
+	if m.ResolveLongOutput(ctx, bus.InboundMessage{Channel: "test", ChatID: "1", SenderID: "u2", Content: "all"}) {
+		t.Fatal("another user's message must not resolve the held reply")
+	}
+	if _, ok := m.pendingOutputs.Load("test:1:u1"); !ok {
+		t.Fatal("another user's message must not discard the held reply")
+	}
+	if !m.ResolveLongOutput(ctx, bus.InboundMessage{Channel: "test", ChatID: "1", SenderID: "u1", Content: "all"}) {
+		t.Fatal("expected the answer of the user to be consumed")
+	}
+}
+
+// reactingChannel reports reactions to the bot's messages.
+type reactingChannel struct {
+	mockChannelWithLength
+}
+
+func (c *reactingChannel) SupportsReplyReactions() bool { return true }
+
+func newReactingTestSetup(t *testing.T) (*Manager, *channelWorker, *recordingSender) {
+	t.Helper()
+	m, w, rec := newGatedTestSetup(1)
+	ch := &reactingChannel{mockChannelWithLength{mockChannel: mockChannel{sendFn: rec.send}, maxLen: 100}}
+	w.ch = ch
+	m.channels["test"] = ch
+	return m, w, rec
+}
+
+// promptTurn returns the TurnID the prompt of the held reply was sent with.
+func promptTurn(chatID, senderID string) string {
+	return LongOutputTurnPrefix + longOutputKey("test", chatID, senderID)
+}
+
+func TestHoldLongOutput_OffersReactions(t *testing.T) {
+	m, w, rec := newReactingTestSetup(t)
+	m.holdLongOutput(t.Context(), "test", w, bus.OutboundMessage{Channel: "test", ChatID: "1", SenderID: "u1"}, []string{"a", "b"})
+
+	sent := waitForSends(t, rec, 1)
+	if !strings.Contains(sent[0], "✅") || strings.Contains(sent[0], "\"all\"") {
+		t.Errorf("expected the prompt to offer reactions, got %q", sent[0])
+	}
+}
+
+func TestHoldLongOutput_LocalizesPrompt(t *testing.T) {
+	m, w, rec := newGatedTestSetup(1)
+	m.holdLongOutput(t.Context(), "test", w, bus.OutboundMessage{Channel: "test", ChatID: "1", Locale: "de"}, []string{"a", "b"})
+
+	sent := waitForSends(t, rec, 1)
+	if !strings.Contains(sent[0], "Nachrichten") {
+		t.Errorf("expected a German prompt, got %q", sent[0])
+	}
+}
+
+func TestResolveReplyAction_LongOutputReactions(t *testing.T) {
+	m, w, rec := newReactingTestSetup(t)
+	ctx := t.Context()
+	m.holdLongOutput(ctx, "test", w, bus.OutboundMessage{Channel: "test", ChatID: "1", SenderID: "u1"}, []string{"a", "b"})
+	m.sentTurns.Store("test:1:m1", sentTurn{turnID: promptTurn("1", "u1"), createdAt: time.Now()})
+
+	if m.ResolveReplyAction("test", "1", "m1", ReplyActionApprove, bus.SenderInfo{PlatformID: "u2"}) {
+		t.Fatal("another user's reaction must not resolve the held reply")
+	}
+	if m.ResolveReplyAction("test", "1", "m1", ReplyActionRegenerate, bus.SenderInfo{PlatformID: "u1"}) {
+		t.Fatal("only the long-output choices apply to the prompt")
+	}
+	if !m.ResolveReplyAction("test", "1", "m1", ReplyActionApprove, bus.SenderInfo{PlatformID: "u1"}) {
+		t.Fatal("expected ✅ to post the held reply")
+	}
+	sent := waitForSends(t, rec, 3)
+	if sent[1] != "a" || sent[2] != "b" {
+		t.Errorf("expected held chunks after the prompt, got %q", sent)
+	}
+	if m.ResolveReplyAction("test", "1", "m1", ReplyActionApprove, bus.SenderInfo{PlatformID: "u1"}) {
+		t.Error("nothing is pending any more")
+	}
+}
+
+func TestResolveReplyAction_LongOutputSummary(t *testing.T) {
+	m, w, _ := newReactingTestSetup(t)
+	ctx := t.Context()
+	summarized := make(chan string, 1)
+	m.SetSummarizer(func(_ context.Context, turnID string, sender bus.SenderInfo) error {
+		summarized <- turnID + "/" + sender.PlatformID
+		return nil
+	})
+	msg := bus.OutboundMessage{Channel: "test", ChatID: "1", SenderID: "u1", TurnID: "turn-1"}
+	m.holdLongOutput(ctx, "test", w, msg, []string{"a", "b"})
+	m.sentTurns.Store("test:1:m1", sentTurn{turnID: promptTurn("1", "u1"), createdAt: time.Now()})
+
+	if !m.ResolveReplyAction("test", "1", "m1", ReplyActionSummary, bus.SenderInfo{PlatformID: "u1"}) {
+		t.Fatal("expected 📝 to ask for a summary")
+	}
+	select {
+	case got := <-summarized:
+		if got != "turn-1/u1" {
+			t.Errorf("summarized %q, want turn-1/u1", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a summary request")
+	}
+}
+
+func TestResolveReplyAction_LongOutputDeny(t *testing.T) {
+	m, w, _ := newReactingTestSetup(t)
+	ctx := t.Context()
+	m.holdLongOutput(ctx, "test", w, bus.OutboundMessage{Channel: "test", ChatID: "1", SenderID: "u1"}, []string{"a", "b"})
+	m.sentTurns.Store("test:1:m1", sentTurn{turnID: promptTurn("1", "u1"), createdAt: time.Now()})
+
+	if !m.ResolveReplyAction("test", "1", "m1", ReplyActionDeny, bus.SenderInfo{PlatformID: "u1"}) {
+		t.Fatal("expected ❌ to drop the held reply")
+	}
+	if _, ok := m.pendingOutputs.Load("test:1:u1"); ok {
+		t.Error("pending output should be dropped")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"math"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -21,8 +22,13 @@ import (
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
 	"github.com/sipeed/picoclaw/pkg/health"
+	"github.com/sipeed/picoclaw/pkg/leader"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/middleware"
+	"github.com/sipeed/picoclaw/pkg/objectstore"
+	"github.com/sipeed/picoclaw/pkg/tracing"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 const (
@@ -36,6 +42,7 @@ const (
 	janitorInterval = 10 * time.Second
 	typingStopTTL   = 5 * time.Minute
 	placeholderTTL  = 10 * time.Minute
+	replyTTL        = 24 * time.Hour
 )
 
 // typingEntry wraps a typing stop function with a creation timestamp for TTL eviction.
@@ -56,6 +63,12 @@ type placeholderEntry struct {
 	createdAt time.Time
 }
 
+// replyEntry wraps the ID of the bot's latest message in a chat.
+type replyEntry struct {
+	id        string
+	createdAt time.Time
+}
+
 // channelRateConfig maps channel name to per-second rate limit.
 var channelRateConfig = map[string]float64{
 	"telegram": 20,
@@ -74,6 +87,12 @@ type channelWorker struct {
 	done       chan struct{}
 	mediaDone  chan struct{}
 	limiter    *rate.Limiter
+	// cancel stops the worker without closing its queues; used when the
+	// channel is handed over to another replica.
+	cancel context.CancelFunc
+	// drain stops the worker once it sent what is queued; used when a config
+	// reload cycles the channel.
+	drain chan struct{}
 }
 
 type Manager struct {
@@ -82,6 +101,7 @@ type Manager struct {
 	bus           *bus.MessageBus
 	config        *config.Config
 	mediaStore    media.MediaStore
+	objectStore   *objectstore.Client
 	dispatchTask  *asyncTask
 	mux           *http.ServeMux
 	httpServer    *http.Server
@@ -89,10 +109,51 @@ type Manager struct {
 	placeholders  sync.Map // "channel:chatID" → placeholderID (string)
 	typingStops   sync.Map // "channel:chatID" → func()
 	reactionUndos sync.Map // "channel:chatID" → reactionEntry
+	replies       sync.Map // "channel:chatID" → replyEntry
+	// delivering holds the turn whose message a channel is sending, and
+	// sentTurns the turn of every message sent for one.
+	delivering sync.Map // channel → turn ID
+	sentTurns  sync.Map // "channel:chatID:messageID" → sentTurn
+	// feedbackRecorder saves reactions to the bot's replies.
+	feedbackRecorder FeedbackRecorder
+	// turnContents keeps the reply text of each turn for the 📋 reaction,
+	// regenerator answers a turn again for 🔁, and variantSwitcher shows the
+	// other replies of a regenerated turn for ◀️ and ▶️.
+	turnContents    sync.Map // "channel:turnID" → sentContent
+	regenerator     Regenerator
+	variantSwitcher VariantSwitcher
+	// approvalResolver approves or denies held tool actions for ✅ and ❌.
+	approvalResolver ApprovalResolver
+	// middleware runs the pre_send middleware on every outbound message.
+	middleware atomic.Pointer[middleware.Chain]
+	// pendingOutputs holds replies waiting for the long-output choice, and
+	// summarizer asks for the summary of one for 📝.
+	pendingOutputs sync.Map // "channel:chatID:senderID" → pendingOutput
+	summarizer     Summarizer
+	// haLock is set when gateway HA is enabled; channels are then started
+	// only while this replica holds their lease.
+	haLock leader.Lock
+	// turnStopper cancels the agent's in-flight reply for a chat.
+	turnStopper TurnStopper
+	// promptStopper cancels the agent's reply to a deleted user message.
+	promptStopper PromptStopper
+	haCancel      context.CancelFunc
+	haWG          sync.WaitGroup
+	// httpPaths are the channel webhook and health paths on the shared mux.
+	httpPaths map[string]bool
+	// redactOutbound masks secrets in everything sent; it is read by the
+	// workers, which must not wait for m.mu.
+	redactOutbound atomic.Bool
+	// shadow is the shadow mode config, read by the workers like
+	// redactOutbound.
+	shadow atomic.Pointer[config.ShadowConfig]
 }
 
 type asyncTask struct {
-	cancel context.CancelFunc
+	// ctx and dispatchCtx are those StartAll started the channels with, so
+	// Reload starts the channels it cycles the same way.
+	ctx, dispatchCtx context.Context
+	cancel           context.CancelFunc
 }
 
 // RecordPlaceholder registers a placeholder message for later editing.
@@ -105,6 +166,9 @@ func (m *Manager) RecordPlaceholder(channel, chatID, placeholderID string) {
 // SendPlaceholder sends a "Thinking…" placeholder for the given channel/chatID
 // and records it for later editing. Returns true if a placeholder was sent.
 func (m *Manager) SendPlaceholder(ctx context.Context, channel, chatID string) bool {
+	if m.Shadowed(channel) {
+		return false
+	}
 	m.mu.RLock()
 	ch, ok := m.channels[channel]
 	m.mu.RUnlock()
@@ -142,6 +206,24 @@ func (m *Manager) RecordReactionUndo(channel, chatID string, undo func()) {
 	m.reactionUndos.Store(key, reactionEntry{undo: undo, createdAt: time.Now()})
 }
 
+// RecordReply registers the bot's latest message in a chat.
+// Implements PlaceholderRecorder.
+func (m *Manager) RecordReply(channel, chatID, messageID string) {
+	if messageID == "" {
+		return
+	}
+	m.replies.Store(channel+":"+chatID, replyEntry{id: messageID, createdAt: time.Now()})
+	if turnID, ok := m.delivering.Load(channel); ok {
+		m.sentTurns.Store(channel+":"+chatID+":"+messageID, sentTurn{turnID: turnID.(string), createdAt: time.Now()})
+	}
+}
+
+// forgetReply drops the recorded reply of a chat once the bot's latest answer
+// spans several messages, which cannot be replaced by a single edit.
+func (m *Manager) forgetReply(channel, chatID string) {
+	m.replies.Delete(channel + ":" + chatID)
+}
+
 // preSend handles typing stop, reaction undo, and placeholder editing before sending a message.
 // Returns true if the message was edited into a placeholder (skip Send).
 func (m *Manager) preSend(ctx context.Context, name string, msg bus.OutboundMessage, ch Channel) bool {
@@ -161,11 +243,26 @@ func (m *Manager) preSend(ctx context.Context, name string, msg bus.OutboundMess
 		}
 	}
 
-	// 3. Try editing placeholder
+	// 3. Replace the previous reply when regenerating
+	if msg.ReplaceReply {
+		if v, ok := m.replies.Load(key); ok {
+			if editor, ok := ch.(MessageEditor); ok {
+				id := v.(replyEntry).id
+				if err := editor.EditMessage(ctx, msg.ChatID, id, msg.Content); err == nil {
+					m.RecordReply(name, msg.ChatID, id)
+					return true
+				}
+				// edit failed → fall through to placeholder or normal Send
+			}
+		}
+	}
+
+	// 4. Try editing placeholder
 	if v, loaded := m.placeholders.LoadAndDelete(key); loaded {
 		if entry, ok := v.(placeholderEntry); ok && entry.id != "" {
 			if editor, ok := ch.(MessageEditor); ok {
 				if err := editor.EditMessage(ctx, msg.ChatID, entry.id, msg.Content); err == nil {
+					m.RecordReply(name, msg.ChatID, entry.id)
 					return true // edited successfully, skip Send
 				}
 				// edit failed → fall through to normal Send
@@ -184,6 +281,23 @@ func NewManager(cfg *config.Config, messageBus *bus.MessageBus, store media.Medi
 		config:     cfg,
 		mediaStore: store,
 	}
+	m.redactOutbound.Store(cfg.Redaction.Outbound)
+	m.shadow.Store(&cfg.Shadow)
+
+	if cfg.Gateway.HA.Enabled {
+		lock, err := leader.NewLock(cfg.Gateway.HA)
+		if err != nil {
+			return nil, err
+		}
+		m.haLock = lock
+	}
+	if cfg.ObjectStorage.Enabled {
+		client, err := objectstore.New(cfg.ObjectStorage)
+		if err != nil {
+			return nil, err
+		}
+		m.objectStore = client
+	}
 
 	if err := m.initChannels(); err != nil {
 		return nil, err
@@ -211,20 +325,7 @@ func (m *Manager) initChannel(name, displayName string) {
 			"error":   err.Error(),
 		})
 	} else {
-		// Inject MediaStore if channel supports it
-		if m.mediaStore != nil {
-			if setter, ok := ch.(interface{ SetMediaStore(s media.MediaStore) }); ok {
-				setter.SetMediaStore(m.mediaStore)
-			}
-		}
-		// Inject PlaceholderRecorder if channel supports it
-		if setter, ok := ch.(interface{ SetPlaceholderRecorder(r PlaceholderRecorder) }); ok {
-			setter.SetPlaceholderRecorder(m)
-		}
-		// Inject owner reference so BaseChannel.HandleMessage can auto-trigger typing/reaction
-		if setter, ok := ch.(interface{ SetOwner(ch Channel) }); ok {
-			setter.SetOwner(ch)
-		}
+		m.attach(ch)
 		m.channels[name] = ch
 		logger.InfoCF("channels", "Channel enabled successfully", map[string]any{
 			"channel": displayName,
@@ -232,86 +333,164 @@ func (m *Manager) initChannel(name, displayName string) {
 	}
 }
 
+// attach injects the Manager's hooks into ch for the optional capabilities
+// it implements.
+func (m *Manager) attach(ch Channel) {
+	// Inject MediaStore if channel supports it
+	if m.mediaStore != nil {
+		if setter, ok := ch.(interface{ SetMediaStore(s media.MediaStore) }); ok {
+			setter.SetMediaStore(m.mediaStore)
+		}
+	}
+	// Inject PlaceholderRecorder if channel supports it
+	if setter, ok := ch.(interface{ SetPlaceholderRecorder(r PlaceholderRecorder) }); ok {
+		setter.SetPlaceholderRecorder(m)
+	}
+	// Inject LongOutputGate so answers to long-output prompts are consumed
+	if setter, ok := ch.(interface{ SetLongOutputGate(g LongOutputGate) }); ok {
+		setter.SetLongOutputGate(m)
+	}
+	// Inject StopGate so stop requests skip the agent's queue
+	if setter, ok := ch.(interface{ SetStopGate(g StopGate) }); ok {
+		setter.SetStopGate(m)
+	}
+	// Inject FeedbackSink so reactions to replies are recorded
+	if setter, ok := ch.(interface{ SetFeedbackSink(s FeedbackSink) }); ok {
+		setter.SetFeedbackSink(m)
+	}
+	// Inject ReplyActionSink so reactions can regenerate, delete or export replies
+	if setter, ok := ch.(interface{ SetReplyActionSink(s ReplyActionSink) }); ok {
+		setter.SetReplyActionSink(m)
+	}
+	// Inject the ephemeral conversations so channels keep them out of the log
+	if m.config != nil {
+		if setter, ok := ch.(interface{ SetEphemeral(config.EphemeralConfig) }); ok {
+			setter.SetEphemeral(m.config.Ephemeral)
+		}
+	}
+	// Inject owner reference so BaseChannel.HandleMessage can auto-trigger typing/reaction
+	if setter, ok := ch.(interface{ SetOwner(ch Channel) }); ok {
+		setter.SetOwner(ch)
+	}
+}
+
 func (m *Manager) initChannels() error {
 	logger.InfoC("channels", "Initializing channel manager")
 
-	if m.config.Channels.Telegram.Enabled && m.config.Channels.Telegram.Token != "" {
-		m.initChannel("telegram", "Telegram")
+	for _, spec := range configuredChannels(m.config) {
+		m.initChannel(spec.name, spec.displayName)
+	}
+
+	logger.InfoCF("channels", "Channel initialization completed", map[string]any{
+		"enabled_channels": len(m.channels),
+	})
+
+	return nil
+}
+
+// channelSpec names a channel the config enables.
+type channelSpec struct {
+	name, displayName string
+}
+
+// configuredChannels returns the channels cfg enables, in start order.
+func configuredChannels(cfg *config.Config) []channelSpec {
+	c := &cfg.Channels
+	var specs []channelSpec
+
+	if c.Telegram.Enabled && c.Telegram.Token != "" {
+		specs = append(specs, channelSpec{"telegram", "Telegram"})
 	}
 
-	if m.config.Channels.WhatsApp.Enabled {
-		waCfg := m.config.Channels.WhatsApp
+	if c.WhatsApp.Enabled {
+		waCfg := c.WhatsApp
 		if waCfg.UseNative {
-			m.initChannel("whatsapp_native", "WhatsApp Native")
+			specs = append(specs, channelSpec{"whatsapp_native", "WhatsApp Native"})
 		} else if waCfg.BridgeURL != "" {
-			m.initChannel("whatsapp", "WhatsApp")
+			specs = append(specs, channelSpec{"whatsapp", "WhatsApp"})
 		}
 	}
 
-	if m.config.Channels.Feishu.Enabled {
-		m.initChannel("feishu", "Feishu")
+	if c.WhatsAppCloud.Enabled && c.WhatsAppCloud.AccessToken != "" {
+		specs = append(specs, channelSpec{"whatsapp_cloud", "WhatsApp Cloud"})
 	}
 
-	if m.config.Channels.Discord.Enabled && m.config.Channels.Discord.Token != "" {
-		m.initChannel("discord", "Discord")
+	if c.Feishu.Enabled {
+		specs = append(specs, channelSpec{"feishu", "Feishu"})
 	}
 
-	if m.config.Channels.MaixCam.Enabled {
-		m.initChannel("maixcam", "MaixCam")
+	if c.Discord.Enabled && c.Discord.Token != "" {
+		specs = append(specs, channelSpec{"discord", "Discord"})
 	}
 
-	if m.config.Channels.QQ.Enabled {
-		m.initChannel("qq", "QQ")
+	if c.MaixCam.Enabled {
+		specs = append(specs, channelSpec{"maixcam", "MaixCam"})
 	}
 
-	if m.config.Channels.DingTalk.Enabled && m.config.Channels.DingTalk.ClientID != "" {
-		m.initChannel("dingtalk", "DingTalk")
+	if c.QQ.Enabled {
+		specs = append(specs, channelSpec{"qq", "QQ"})
 	}
 
-	if m.config.Channels.Slack.Enabled && m.config.Channels.Slack.BotToken != "" {
-		m.initChannel("slack", "Slack")
+	if c.DingTalk.Enabled && c.DingTalk.ClientID != "" {
+		specs = append(specs, channelSpec{"dingtalk", "DingTalk"})
 	}
 
-	if m.config.Channels.Matrix.Enabled &&
-		m.config.Channels.Matrix.Homeserver != "" &&
-		m.config.Channels.Matrix.UserID != "" &&
-		m.config.Channels.Matrix.AccessToken != "" {
-		m.initChannel("matrix", "Matrix")
+	if c.Slack.Enabled && c.Slack.BotToken != "" {
+		specs = append(specs, channelSpec{"slack", "Slack"})
 	}
 
-	if m.config.Channels.LINE.Enabled && m.config.Channels.LINE.ChannelAccessToken != "" {
-		m.initChannel("line", "LINE")
+	if c.Matrix.Enabled &&
+		c.Matrix.Homeserver != "" &&
+		c.Matrix.UserID != "" &&
+		c.Matrix.AccessToken != "" {
+		specs = append(specs, channelSpec{"matrix", "Matrix"})
 	}
 
-	if m.config.Channels.OneBot.Enabled && m.config.Channels.OneBot.WSUrl != "" {
-		m.initChannel("onebot", "OneBot")
+	if c.LINE.Enabled && c.LINE.ChannelAccessToken != "" {
+		specs = append(specs, channelSpec{"line", "LINE"})
 	}
 
-	if m.config.Channels.WeCom.Enabled && m.config.Channels.WeCom.Token != "" {
-		m.initChannel("wecom", "WeCom")
+	if c.OneBot.Enabled && c.OneBot.WSUrl != "" {
+		specs = append(specs, channelSpec{"onebot", "OneBot"})
 	}
 
-	if m.config.Channels.WeComAIBot.Enabled && m.config.Channels.WeComAIBot.Token != "" {
-		m.initChannel("wecom_aibot", "WeCom AI Bot")
+	if c.WeCom.Enabled && c.WeCom.Token != "" {
+		specs = append(specs, channelSpec{"wecom", "WeCom"})
 	}
 
-	if m.config.Channels.WeComApp.Enabled && m.config.Channels.WeComApp.CorpID != "" {
-		m.initChannel("wecom_app", "WeCom App")
+	if c.WeComAIBot.Enabled && c.WeComAIBot.Token != "" {
+		specs = append(specs, channelSpec{"wecom_aibot", "WeCom AI Bot"})
 	}
 
-	if m.config.Channels.Pico.Enabled && m.config.Channels.Pico.Token != "" {
-		m.initChannel("pico", "Pico")
+	if c.WeComApp.Enabled && c.WeComApp.CorpID != "" {
+		specs = append(specs, channelSpec{"wecom_app", "WeCom App"})
 	}
 
-	if m.config.Channels.IRC.Enabled && m.config.Channels.IRC.Server != "" {
-		m.initChannel("irc", "IRC")
+	if c.Pico.Enabled && c.Pico.Token != "" {
+		specs = append(specs, channelSpec{"pico", "Pico"})
 	}
 
-	logger.InfoCF("channels", "Channel initialization completed", map[string]any{
-		"enabled_channels": len(m.channels),
-	})
+	if c.IRC.Enabled && c.IRC.Server != "" {
+		specs = append(specs, channelSpec{"irc", "IRC"})
+	}
 
-	return nil
+	if c.HTTP.Enabled && c.HTTP.Secret != "" {
+		specs = append(specs, channelSpec{"http", "HTTP"})
+	}
+
+	if c.Mattermost.Enabled && c.Mattermost.Token != "" {
+		specs = append(specs, channelSpec{"mattermost", "Mattermost"})
+	}
+
+	if c.XMPP.Enabled && c.XMPP.JID != "" {
+		specs = append(specs, channelSpec{"xmpp", "XMPP"})
+	}
+
+	if c.Email.Enabled && c.Email.IMAPHost != "" {
+		specs = append(specs, channelSpec{"email", "Email"})
+	}
+
+	return specs
 }
 
 // SetupHTTPServer creates a shared HTTP server with the given listen address.
@@ -326,21 +505,9 @@ func (m *Manager) SetupHTTPServer(addr string, healthServer *health.Server) {
 	}
 
 	// Discover and register webhook handlers and health checkers
+	m.httpPaths = make(map[string]bool)
 	for name, ch := range m.channels {
-		if wh, ok := ch.(WebhookHandler); ok {
-			m.mux.Handle(wh.WebhookPath(), wh)
-			logger.InfoCF("channels", "Webhook handler registered", map[string]any{
-				"channel": name,
-				"path":    wh.WebhookPath(),
-			})
-		}
-		if hc, ok := ch.(HealthChecker); ok {
-			m.mux.HandleFunc(hc.HealthPath(), hc.HealthHandler)
-			logger.InfoCF("channels", "Health endpoint registered", map[string]any{
-				"channel": name,
-				"path":    hc.HealthPath(),
-			})
-		}
+		m.registerChannelHTTP(name, ch)
 	}
 
 	m.httpServer = &http.Server{
@@ -351,6 +518,15 @@ func (m *Manager) SetupHTTPServer(addr string, healthServer *health.Server) {
 	}
 }
 
+// Handle registers an extra handler on the shared HTTP server. It must be
+// called after SetupHTTPServer and before StartAll.
+func (m *Manager) Handle(pattern string, handler http.Handler) {
+	if m.mux == nil {
+		return
+	}
+	m.mux.Handle(pattern, handler)
+}
+
 func (m *Manager) StartAll(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -362,24 +538,14 @@ func (m *Manager) StartAll(ctx context.Context) error {
 	logger.InfoC("channels", "Starting all channels")
 
 	dispatchCtx, cancel := context.WithCancel(ctx)
-	m.dispatchTask = &asyncTask{cancel: cancel}
+	m.dispatchTask = &asyncTask{ctx: ctx, dispatchCtx: dispatchCtx, cancel: cancel}
 
-	for name, channel := range m.channels {
-		logger.InfoCF("channels", "Starting channel", map[string]any{
-			"channel": name,
-		})
-		if err := channel.Start(ctx); err != nil {
-			logger.ErrorCF("channels", "Failed to start channel", map[string]any{
-				"channel": name,
-				"error":   err.Error(),
-			})
-			continue
+	if m.haLock != nil {
+		m.startElectors(ctx, dispatchCtx)
+	} else {
+		for name, channel := range m.channels {
+			m.startChannel(ctx, dispatchCtx, name, channel)
 		}
-		// Lazily create worker only after channel starts successfully
-		w := newChannelWorker(name, channel)
-		m.workers[name] = w
-		go m.runWorker(dispatchCtx, name, w)
-		go m.runMediaWorker(dispatchCtx, name, w)
 	}
 
 	// Start the dispatcher that reads from the bus and routes to workers
@@ -408,6 +574,9 @@ func (m *Manager) StartAll(ctx context.Context) error {
 }
 
 func (m *Manager) StopAll(ctx context.Context) error {
+	// Wait for electors to release their leases only after m.mu is unlocked,
+	// since an elector may be blocked on it in a leadership callback.
+	defer m.haWG.Wait()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -456,6 +625,10 @@ func (m *Manager) StopAll(ctx context.Context) error {
 
 	// Stop all channels
 	for name, channel := range m.channels {
+		if _, running := m.workers[name]; m.haLock != nil && !running {
+			// Another replica owns this channel.
+			continue
+		}
 		logger.InfoCF("channels", "Stopping channel", map[string]any{
 			"channel": name,
 		})
@@ -467,10 +640,39 @@ func (m *Manager) StopAll(ctx context.Context) error {
 		}
 	}
 
+	// Release leases last so a standby replica takes over only once our
+	// connections are closed.
+	if m.haCancel != nil {
+		m.haCancel()
+		m.haCancel = nil
+	}
+
 	logger.InfoC("channels", "All channels stopped")
 	return nil
 }
 
+// startChannel starts a channel and its workers. The caller must hold m.mu.
+func (m *Manager) startChannel(ctx, dispatchCtx context.Context, name string, channel Channel) bool {
+	logger.InfoCF("channels", "Starting channel", map[string]any{
+		"channel": name,
+	})
+	if err := channel.Start(ctx); err != nil {
+		logger.ErrorCF("channels", "Failed to start channel", map[string]any{
+			"channel": name,
+			"error":   err.Error(),
+		})
+		return false
+	}
+	// Lazily create worker only after channel starts successfully
+	w := newChannelWorker(name, channel)
+	workerCtx, cancel := context.WithCancel(dispatchCtx)
+	w.cancel = cancel
+	m.workers[name] = w
+	go m.runWorker(workerCtx, name, w)
+	go m.runMediaWorker(workerCtx, name, w)
+	return true
+}
+
 // newChannelWorker creates a channelWorker with a rate limiter configured
 // for the given channel name.
 func newChannelWorker(name string, ch Channel) *channelWorker {
@@ -486,10 +688,21 @@ func newChannelWorker(name string, ch Channel) *channelWorker {
 		mediaQueue: make(chan bus.OutboundMediaMessage, defaultChannelQueueSize),
 		done:       make(chan struct{}),
 		mediaDone:  make(chan struct{}),
+		drain:      make(chan struct{}),
 		limiter:    rate.NewLimiter(rate.Limit(rateVal), burst),
 	}
 }
 
+// normalizeOutboundEmoji converts :shortcode: emoji in outbound text to Unicode,
+// or to the channel's custom-emoji syntax when it provides one.
+func normalizeOutboundEmoji(ch Channel, content string) string {
+	var custom map[string]string
+	if cep, ok := ch.(CustomEmojiProvider); ok {
+		custom = cep.CustomEmoji()
+	}
+	return utils.ShortcodesToEmoji(content, custom)
+}
+
 // runWorker processes outbound messages for a single channel, splitting
 // messages that exceed the channel's maximum message length.
 func (m *Manager) runWorker(ctx context.Context, name string, w *channelWorker) {
@@ -500,19 +713,18 @@ func (m *Manager) runWorker(ctx context.Context, name string, w *channelWorker)
 			if !ok {
 				return
 			}
-			maxLen := 0
-			if mlp, ok := w.ch.(MessageLengthProvider); ok {
-				maxLen = mlp.MaxMessageLength()
-			}
-			if maxLen > 0 && len([]rune(msg.Content)) > maxLen {
-				chunks := SplitMessage(msg.Content, maxLen)
-				for _, chunk := range chunks {
-					chunkMsg := msg
-					chunkMsg.Content = chunk
-					m.sendWithRetry(ctx, name, w, chunkMsg)
+			m.deliver(ctx, name, w, msg)
+		case <-w.drain:
+			for {
+				select {
+				case msg, ok := <-w.queue:
+					if !ok {
+						return
+					}
+					m.deliver(ctx, name, w, msg)
+				default:
+					return
 				}
-			} else {
-				m.sendWithRetry(ctx, name, w, msg)
 			}
 		case <-ctx.Done():
 			return
@@ -520,12 +732,67 @@ func (m *Manager) runWorker(ctx context.Context, name string, w *channelWorker)
 	}
 }
 
+// deliver sends one outbound message from the worker of channel name,
+// traced as part of the turn that produced it.
+func (m *Manager) deliver(ctx context.Context, name string, w *channelWorker, msg bus.OutboundMessage) {
+	ctx, span := tracing.Start(tracing.WithTraceParent(ctx, msg.TraceParent), "deliver "+name, tracing.KindConsumer,
+		map[string]any{"channel": name, "chat_id": msg.ChatID, "content_len": len(msg.Content)})
+	defer span.End()
+
+	if !m.preSendMiddleware(ctx, name, &msg) {
+		return
+	}
+	msg.Content = m.redactSecrets(msg.Content)
+	if msg.TurnID != "" {
+		m.recordTurnContent(name, msg.TurnID, msg.Content)
+	}
+	msg.Content = normalizeOutboundEmoji(w.ch, msg.Content)
+	// Long code blocks go out as files after the text
+	if parts := m.moveCodeToFiles(name, w, &msg); len(parts) > 0 {
+		defer m.sendMediaWithRetry(ctx, name, w, bus.OutboundMediaMessage{Channel: name, ChatID: msg.ChatID, Parts: parts})
+	}
+	msg.Content = formatOutboundCodeBlocks(w.ch, msg.Content)
+	maxLen := 0
+	if mlp, ok := w.ch.(MessageLengthProvider); ok {
+		maxLen = mlp.MaxMessageLength()
+	}
+	lengthFn := messageLengthFunc(w.ch)
+	if maxLen > 0 && lengthFn(msg.Content) > maxLen {
+		_, splitSpan := tracing.Start(ctx, "split", tracing.KindInternal, map[string]any{"max_len": maxLen})
+		chunks := SplitMessageWithLength(msg.Content, maxLen, lengthFn)
+		splitSpan.SetAttributes(map[string]any{"chunks": len(chunks)})
+		splitSpan.End()
+		if threshold := longOutputThreshold(w.ch); threshold > 0 && len(chunks) > threshold {
+			m.holdLongOutput(ctx, name, w, msg, chunks)
+			return
+		}
+		for i, chunk := range chunks {
+			chunkMsg := msg
+			chunkMsg.Content = chunk
+			chunkMsg.ReplaceReply = msg.ReplaceReply && i == 0
+			m.sendWithRetry(ctx, name, w, chunkMsg)
+		}
+		m.forgetReply(name, msg.ChatID)
+	} else {
+		m.sendWithRetry(ctx, name, w, msg)
+	}
+}
+
 // sendWithRetry sends a message through the channel with rate limiting and
 // retry logic. It classifies errors to determine the retry strategy:
 //   - ErrNotRunning / ErrSendFailed: permanent, no retry
 //   - ErrRateLimit: fixed delay retry
 //   - ErrTemporary / unknown: exponential backoff retry
 func (m *Manager) sendWithRetry(ctx context.Context, name string, w *channelWorker, msg bus.OutboundMessage) {
+	if m.Shadowed(name) {
+		logShadowed(name, msg.ChatID, msg.Content)
+		return
+	}
+	if msg.TurnID != "" {
+		m.delivering.Store(name, msg.TurnID)
+		defer m.delivering.Delete(name)
+	}
+
 	// Rate limit: wait for token
 	if err := w.limiter.Wait(ctx); err != nil {
 		// ctx canceled, shutting down
@@ -574,11 +841,12 @@ func (m *Manager) sendWithRetry(ctx context.Context, name string, w *channelWork
 	}
 
 	// All retries exhausted or permanent failure
-	logger.ErrorCF("channels", "Send failed", map[string]any{
-		"channel": name,
-		"chat_id": msg.ChatID,
-		"error":   lastErr.Error(),
-		"retries": maxRetries,
+	logger.ErrorCtx(ctx, "channels", "Send failed", map[string]any{
+		"channel":    name,
+		"chat_id":    msg.ChatID,
+		"request_id": msg.RequestID,
+		"error":      lastErr.Error(),
+		"retries":    maxRetries,
 	})
 }
 
@@ -641,6 +909,9 @@ func (m *Manager) dispatchOutbound(ctx context.Context) {
 			select {
 			case w.queue <- msg:
 				return true
+			case <-w.done:
+				// Worker stopped after losing leadership; drop the message.
+				return true
 			case <-ctx.Done():
 				return false
 			}
@@ -661,6 +932,9 @@ func (m *Manager) dispatchOutboundMedia(ctx context.Context) {
 			select {
 			case w.mediaQueue <- msg:
 				return true
+			case <-w.mediaDone:
+				// Worker stopped after losing leadership; drop the message.
+				return true
 			case <-ctx.Done():
 				return false
 			}
@@ -682,6 +956,18 @@ func (m *Manager) runMediaWorker(ctx context.Context, name string, w *channelWor
 				return
 			}
 			m.sendMediaWithRetry(ctx, name, w, msg)
+		case <-w.drain:
+			for {
+				select {
+				case msg, ok := <-w.mediaQueue:
+					if !ok {
+						return
+					}
+					m.sendMediaWithRetry(ctx, name, w, msg)
+				default:
+					return
+				}
+			}
 		case <-ctx.Done():
 			return
 		}
@@ -689,8 +975,18 @@ func (m *Manager) runMediaWorker(ctx context.Context, name string, w *channelWor
 }
 
 // sendMediaWithRetry sends a media message through the channel with rate limiting and
-// retry logic. If the channel does not implement MediaSender, it silently skips.
+// retry logic. Parts over the channel's upload limit go out as object storage
+// links. If the channel does not implement MediaSender, it silently skips.
 func (m *Manager) sendMediaWithRetry(ctx context.Context, name string, w *channelWorker, msg bus.OutboundMediaMessage) {
+	if m.Shadowed(name) {
+		logShadowed(name, msg.ChatID, fmt.Sprintf("%d media parts", len(msg.Parts)))
+		return
+	}
+	msg = m.offloadOversizedMedia(ctx, name, w, msg)
+	if len(msg.Parts) == 0 {
+		return
+	}
+	msg.Parts = m.redactCaptions(msg.Parts)
 	ms, ok := w.ch.(MediaSender)
 	if !ok {
 		logger.DebugCF("channels", "Channel does not support MediaSender, skipping media", map[string]any{
@@ -789,6 +1085,38 @@ func (m *Manager) runTTLJanitor(ctx context.Context) {
 				}
 				return true
 			})
+			m.replies.Range(func(key, value any) bool {
+				if entry, ok := value.(replyEntry); ok {
+					if now.Sub(entry.createdAt) > replyTTL {
+						m.replies.Delete(key)
+					}
+				}
+				return true
+			})
+			m.sentTurns.Range(func(key, value any) bool {
+				if entry, ok := value.(sentTurn); ok {
+					if now.Sub(entry.createdAt) > replyTTL {
+						m.sentTurns.Delete(key)
+					}
+				}
+				return true
+			})
+			m.turnContents.Range(func(key, value any) bool {
+				if entry, ok := value.(sentContent); ok {
+					if now.Sub(entry.createdAt) > replyTTL {
+						m.turnContents.Delete(key)
+					}
+				}
+				return true
+			})
+			m.pendingOutputs.Range(func(key, value any) bool {
+				if entry, ok := value.(pendingOutput); ok {
+					if now.Sub(entry.createdAt) > pendingOutputTTL {
+						m.pendingOutputs.Delete(key)
+					}
+				}
+				return true
+			})
 		}
 	}
 }
@@ -825,7 +1153,10 @@ func (m *Manager) GetEnabledChannels() []string {
 	return names
 }
 
+// RegisterChannel adds a channel created outside the configured factories,
+// wired like a configured one. It is started by StartAll.
 func (m *Manager) RegisterChannel(name string, channel Channel) {
+	m.attach(channel)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.channels[name] = channel
@@ -861,16 +1192,27 @@ func (m *Manager) SendMessage(ctx context.Context, msg bus.OutboundMessage) erro
 		return fmt.Errorf("channel %s has no active worker", msg.Channel)
 	}
 
+	msg.Content = m.redactSecrets(msg.Content)
+	msg.Content = normalizeOutboundEmoji(w.ch, msg.Content)
+	if parts := m.moveCodeToFiles(msg.Channel, w, &msg); len(parts) > 0 {
+		defer m.sendMediaWithRetry(ctx, msg.Channel, w, bus.OutboundMediaMessage{
+			Channel: msg.Channel, ChatID: msg.ChatID, Parts: parts,
+		})
+	}
+	msg.Content = formatOutboundCodeBlocks(w.ch, msg.Content)
 	maxLen := 0
 	if mlp, ok := w.ch.(MessageLengthProvider); ok {
 		maxLen = mlp.MaxMessageLength()
 	}
-	if maxLen > 0 && len([]rune(msg.Content)) > maxLen {
-		for _, chunk := range SplitMessage(msg.Content, maxLen) {
+	lengthFn := messageLengthFunc(w.ch)
+	if maxLen > 0 && lengthFn(msg.Content) > maxLen {
+		for i, chunk := range SplitMessageWithLength(msg.Content, maxLen, lengthFn) {
 			chunkMsg := msg
 			chunkMsg.Content = chunk
+			chunkMsg.ReplaceReply = msg.ReplaceReply && i == 0
 			m.sendWithRetry(ctx, msg.Channel, w, chunkMsg)
 		}
+		m.forgetReply(msg.Channel, msg.ChatID)
 	} else {
 		m.sendWithRetry(ctx, msg.Channel, w, msg)
 	}
@@ -903,6 +1245,10 @@ func (m *Manager) SendToChannel(ctx context.Context, channelName, chatID, conten
 	}
 
 	// Fallback: direct send (should not happen)
+	if m.Shadowed(channelName) {
+		logShadowed(channelName, chatID, content)
+		return nil
+	}
 	channel, _ := m.channels[channelName]
 	return channel.Send(ctx, msg)
 }
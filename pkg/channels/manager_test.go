@@ -487,6 +487,35 @@ func TestPreSend_PlaceholderEditSuccess(t *testing.T) {
 	}
 }
 
+func TestPreSend_ReplaceReply(t *testing.T) {
+	m := newTestManager()
+	var edits []string
+	ch := &mockMessageEditor{
+		editFn: func(_ context.Context, _, messageID, content string) error {
+			edits = append(edits, messageID+"="+content)
+			return nil
+		},
+	}
+
+	// The placeholder that carried the first answer becomes the reply to replace.
+	m.RecordPlaceholder("test", "123", "456")
+	m.preSend(context.Background(), "test", bus.OutboundMessage{ChatID: "123", Content: "first"}, ch)
+
+	msg := bus.OutboundMessage{Channel: "test", ChatID: "123", Content: "second", ReplaceReply: true}
+	if !m.preSend(context.Background(), "test", msg, ch) {
+		t.Fatal("expected preSend to replace the previous reply")
+	}
+	if len(edits) != 2 || edits[1] != "456=second" {
+		t.Fatalf("edits = %v", edits)
+	}
+
+	// Without a recorded single-message reply the answer is sent normally.
+	m.forgetReply("test", "123")
+	if m.preSend(context.Background(), "test", msg, ch) {
+		t.Fatal("expected preSend to fall through to Send")
+	}
+}
+
 func TestPreSend_PlaceholderEditFails_FallsThrough(t *testing.T) {
 	m := newTestManager()
 
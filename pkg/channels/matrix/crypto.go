@@ -0,0 +1,67 @@
+//go:build goolm
+
+package matrix
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.mau.fi/util/dbutil"
+	"maunium.net/go/mautrix/crypto/cryptohelper"
+	_ "modernc.org/sqlite"
+)
+
+// setupEncryption loads the device's Olm account from the crypto store, or
+// creates it on first start, and installs it on the client. From then on the
+// syncer decrypts incoming events and SendMessageEvent encrypts for
+// encrypted rooms.
+func (c *MatrixChannel) setupEncryption(ctx context.Context) error {
+	if c.client.DeviceID == "" {
+		return fmt.Errorf("device_id is required: use the device the access token was issued for")
+	}
+	if c.config.PickleKey == "" {
+		return fmt.Errorf("pickle_key is required to protect the stored keys")
+	}
+	path := c.config.CryptoStorePath
+	if path == "" {
+		return fmt.Errorf("crypto_store_path is not set")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create crypto store directory: %w", err)
+	}
+
+	sqlDB, err := sql.Open("sqlite", "file:"+path+
+		"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_txlock=immediate")
+	if err != nil {
+		return fmt.Errorf("open crypto store: %w", err)
+	}
+	db, err := dbutil.NewWithDB(sqlDB, "sqlite")
+	if err != nil {
+		sqlDB.Close()
+		return fmt.Errorf("open crypto store: %w", err)
+	}
+	helper, err := cryptohelper.NewCryptoHelper(c.client, []byte(c.config.PickleKey), db)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	if err := helper.Init(ctx); err != nil {
+		helper.Close()
+		return err
+	}
+	c.client.Crypto = helper
+	c.crypto = helper
+	return nil
+}
+
+func (c *MatrixChannel) closeEncryption() {
+	if c.crypto == nil {
+		return
+	}
+	c.client.Crypto = nil
+	_ = c.crypto.Close()
+	c.crypto = nil
+}
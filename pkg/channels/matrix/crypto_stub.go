@@ -0,0 +1,16 @@
+//go:build !goolm
+
+package matrix
+
+import (
+	"context"
+	"fmt"
+)
+
+// setupEncryption returns an error when the binary was not built with -tags goolm.
+// Build with: go build -tags goolm ./cmd/...
+func (c *MatrixChannel) setupEncryption(ctx context.Context) error {
+	return fmt.Errorf("end-to-end encryption not compiled in; build with -tags goolm")
+}
+
+func (c *MatrixChannel) closeEncryption() {}
@@ -1,6 +1,8 @@
 package matrix
 
 import (
+	"path/filepath"
+
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -8,6 +10,10 @@ import (
 
 func init() {
 	channels.RegisterFactory("matrix", func(cfg *config.Config, b *bus.MessageBus) (channels.Channel, error) {
-		return NewMatrixChannel(cfg.Channels.Matrix, b)
+		mxCfg := cfg.Channels.Matrix
+		if mxCfg.Encryption && mxCfg.CryptoStorePath == "" {
+			mxCfg.CryptoStorePath = filepath.Join(cfg.WorkspacePath(), "matrix", "crypto.db")
+		}
+		return NewMatrixChannel(mxCfg, b)
 	})
 }
@@ -18,6 +18,7 @@ import (
 	mdhtml "github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
 	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/crypto/attachment"
 	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/id"
 
@@ -181,6 +182,8 @@ type MatrixChannel struct {
 
 	roomKindCache     *roomKindCache
 	localpartMentionR *regexp.Regexp
+
+	crypto io.Closer // set when end-to-end encryption is enabled
 }
 
 func NewMatrixChannel(cfg config.MatrixConfig, messageBus *bus.MessageBus) (*MatrixChannel, error) {
@@ -239,6 +242,16 @@ func (c *MatrixChannel) Start(ctx context.Context) error {
 	c.ctx, c.cancel = context.WithCancel(ctx)
 	c.startTime = time.Now()
 
+	if c.config.Encryption {
+		if err := c.setupEncryption(c.ctx); err != nil {
+			c.cancel()
+			return fmt.Errorf("matrix encryption: %w", err)
+		}
+		logger.InfoCF("matrix", "End-to-end encryption enabled", map[string]any{
+			"device_id": c.client.DeviceID.String(),
+		})
+	}
+
 	c.syncer.OnEventType(event.EventMessage, c.handleMessageEvent)
 	c.syncer.OnEventType(event.StateMember, c.handleMemberEvent)
 
@@ -265,6 +278,7 @@ func (c *MatrixChannel) Stop(ctx context.Context) error {
 		c.cancel()
 	}
 	c.stopTypingSessions(ctx)
+	c.closeEncryption()
 
 	logger.InfoC("matrix", "Matrix channel stopped")
 	return nil
@@ -381,12 +395,28 @@ func (c *MatrixChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMess
 			contentType = "application/octet-stream"
 		}
 
+		// Files sent to encrypted rooms are encrypted before upload; the key
+		// travels in the (encrypted) message event.
+		var upload io.Reader = file
+		uploadType := contentType
+		var encFile *attachment.EncryptedFile
+		var encReader io.ReadCloser
+		if c.roomEncrypted(sendCtx, roomID) {
+			encFile = attachment.NewEncryptedFile()
+			encReader = encFile.EncryptStream(file)
+			upload, uploadType = encReader, "application/octet-stream"
+		}
+
 		uploadResp, err := c.client.UploadMedia(sendCtx, mautrix.ReqUploadMedia{
-			Content:       file,
+			Content:       upload,
 			ContentLength: fileInfo.Size(),
-			ContentType:   contentType,
+			ContentType:   uploadType,
 			FileName:      filename,
 		})
+		if encReader != nil {
+			// Closing computes the hash the recipients verify
+			_ = encReader.Close()
+		}
 		file.Close()
 		if err != nil {
 			logger.ErrorCF("matrix", "Failed to upload media", map[string]any{
@@ -406,6 +436,10 @@ func (c *MatrixChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMess
 			fileInfo.Size(),
 			uploadResp.ContentURI.CUString(),
 		)
+		if encFile != nil {
+			content.File = &event.EncryptedFileInfo{EncryptedFile: *encFile, URL: content.URL}
+			content.URL = ""
+		}
 
 		if _, err := c.client.SendMessageEvent(sendCtx, roomID, event.EventMessage, content); err != nil {
 			logger.ErrorCF("matrix", "Failed to send media message", map[string]any{
@@ -920,6 +954,15 @@ func matrixMediaExt(filename, contentType, mediaKind string) string {
 	}
 }
 
+// roomEncrypted reports whether messages to roomID must be encrypted.
+func (c *MatrixChannel) roomEncrypted(ctx context.Context, roomID id.RoomID) bool {
+	if c.client.Crypto == nil || c.client.StateStore == nil {
+		return false
+	}
+	encrypted, err := c.client.StateStore.IsEncrypted(ctx, roomID)
+	return err == nil && encrypted
+}
+
 func (c *MatrixChannel) isGroupRoom(ctx context.Context, roomID id.RoomID) bool {
 	now := time.Now()
 	if isGroup, ok := c.roomKindCache.get(roomID.String(), now); ok {
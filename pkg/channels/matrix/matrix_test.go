@@ -386,3 +386,30 @@ func TestMessageContent(t *testing.T) {
 		t.Errorf("plain: expected no formatting, got format=%q formattedBody=%q", mc.Format, mc.FormattedBody)
 	}
 }
+
+func TestStart_EncryptionErrorStopsStart(t *testing.T) {
+	// Without -tags goolm the stub refuses; with it, the missing device ID does.
+	ch, err := NewMatrixChannel(config.MatrixConfig{
+		Homeserver:  "https://matrix.example.org",
+		UserID:      "@bot:matrix.example.org",
+		AccessToken: "token",
+		Encryption:  true,
+		PickleKey:   "secret",
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewMatrixChannel() error = %v", err)
+	}
+	if err := ch.Start(context.Background()); err == nil {
+		t.Fatal("Start() succeeded, want an encryption setup error")
+	}
+	if ch.IsRunning() {
+		t.Fatal("channel is running after failed encryption setup")
+	}
+}
+
+func TestRoomEncrypted_FalseWithoutCrypto(t *testing.T) {
+	ch := &MatrixChannel{client: &mautrix.Client{}}
+	if ch.roomEncrypted(context.Background(), id.RoomID("!room:matrix.org")) {
+		t.Fatal("roomEncrypted() = true without a crypto helper")
+	}
+}
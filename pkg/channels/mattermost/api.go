@@ -0,0 +1,219 @@
+package mattermost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// post is the part of a Mattermost post the channel uses.
+type post struct {
+	ID        string       `json:"id,omitempty"`
+	UserID    string       `json:"user_id,omitempty"`
+	ChannelID string       `json:"channel_id"`
+	RootID    string       `json:"root_id,omitempty"`
+	Message   string       `json:"message"`
+	Type      string       `json:"type,omitempty"`
+	FileIDs   []string     `json:"file_ids,omitempty"`
+	Metadata  postMetadata `json:"metadata,omitzero"`
+}
+
+type postMetadata struct {
+	Files []fileInfo `json:"files,omitempty"`
+}
+
+type fileInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+}
+
+type user struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type team struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// command is a custom slash command.
+type command struct {
+	ID               string `json:"id,omitempty"`
+	Token            string `json:"token,omitempty"`
+	CreatorID        string `json:"creator_id,omitempty"`
+	TeamID           string `json:"team_id"`
+	Trigger          string `json:"trigger"`
+	Method           string `json:"method"`
+	URL              string `json:"url"`
+	AutoComplete     bool   `json:"auto_complete"`
+	AutoCompleteDesc string `json:"auto_complete_desc,omitempty"`
+	AutoCompleteHint string `json:"auto_complete_hint,omitempty"`
+	DisplayName      string `json:"display_name,omitempty"`
+	Description      string `json:"description,omitempty"`
+}
+
+// apiError is an error response of the REST API.
+type apiError struct {
+	StatusCode int    `json:"status_code"`
+	ID         string `json:"id"`
+	Message    string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("mattermost api: %d %s", e.StatusCode, e.Message)
+}
+
+// apiClient calls the Mattermost REST API v4.
+type apiClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func (a *apiClient) do(ctx context.Context, method, path string, body, out any) error {
+	var rd io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+"/api/v4"+path, rd)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return a.send(req, out)
+}
+
+func (a *apiClient) send(req *http.Request, out any) error {
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		apiErr := &apiError{}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(apiErr)
+		apiErr.StatusCode = resp.StatusCode
+		if apiErr.Message == "" {
+			apiErr.Message = resp.Status
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (a *apiClient) me(ctx context.Context) (*user, error) {
+	var u user
+	if err := a.do(ctx, http.MethodGet, "/users/me", nil, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (a *apiClient) createPost(ctx context.Context, p post) (*post, error) {
+	var created post
+	if err := a.do(ctx, http.MethodPost, "/posts", p, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (a *apiClient) patchPost(ctx context.Context, postID, message string) error {
+	return a.do(ctx, http.MethodPut, "/posts/"+postID+"/patch", map[string]string{"message": message}, nil)
+}
+
+func (a *apiClient) typing(ctx context.Context, userID, channelID, parentID string) error {
+	return a.do(ctx, http.MethodPost, "/users/"+userID+"/typing", map[string]string{
+		"channel_id": channelID,
+		"parent_id":  parentID,
+	}, nil)
+}
+
+// uploadFile uploads a local file to channelID and returns its file ID.
+func (a *apiClient) uploadFile(ctx context.Context, channelID, path, filename string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if filename == "" {
+		filename = filepath.Base(path)
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.WriteField("channel_id", channelID); err != nil {
+		return "", err
+	}
+	part, err := w.CreateFormFile("files", filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/api/v4/files", &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	var out struct {
+		FileInfos []fileInfo `json:"file_infos"`
+	}
+	if err := a.send(req, &out); err != nil {
+		return "", err
+	}
+	if len(out.FileInfos) == 0 {
+		return "", fmt.Errorf("mattermost upload returned no file")
+	}
+	return out.FileInfos[0].ID, nil
+}
+
+func (a *apiClient) teams(ctx context.Context) ([]team, error) {
+	var teams []team
+	err := a.do(ctx, http.MethodGet, "/users/me/teams", nil, &teams)
+	return teams, err
+}
+
+func (a *apiClient) commands(ctx context.Context, teamID string) ([]command, error) {
+	var cmds []command
+	err := a.do(ctx, http.MethodGet, "/commands?custom_only=true&team_id="+teamID, nil, &cmds)
+	return cmds, err
+}
+
+func (a *apiClient) createCommand(ctx context.Context, cmd command) (*command, error) {
+	var created command
+	if err := a.do(ctx, http.MethodPost, "/commands", cmd, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (a *apiClient) updateCommand(ctx context.Context, cmd command) (*command, error) {
+	var updated command
+	if err := a.do(ctx, http.MethodPut, "/commands/"+cmd.ID, cmd, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
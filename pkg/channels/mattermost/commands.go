@@ -0,0 +1,216 @@
+package mattermost
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	webhookPath        = "/webhook/mattermost"
+	maxCommandBodySize = 64 << 10
+	maxAutoCompleteLen = 128
+)
+
+var triggerPattern = regexp.MustCompile(`^[-_a-z0-9]{1,128}$`)
+
+// WebhookPath returns the path Mattermost posts slash commands to.
+func (c *MattermostChannel) WebhookPath() string {
+	return webhookPath
+}
+
+// RegisterCommands creates a custom slash command for each definition in
+// every team the bot belongs to, reusing the ones it created before.
+// Triggers taken by Mattermost's built-in commands are skipped.
+func (c *MattermostChannel) RegisterCommands(ctx context.Context, defs []commands.Definition) error {
+	teams, err := c.api.teams(ctx)
+	if err != nil {
+		return fmt.Errorf("list teams: %w", err)
+	}
+	var failed []string
+	for _, t := range teams {
+		existing, err := c.api.commands(ctx, t.ID)
+		if err != nil {
+			return fmt.Errorf("list commands of team %s: %w", t.Name, err)
+		}
+		ours := make(map[string]command)
+		for _, cmd := range existing {
+			if cmd.CreatorID == c.botUserID {
+				ours[cmd.Trigger] = cmd
+			}
+		}
+		for _, want := range slashCommands(t.ID, c.config.CommandURL, defs) {
+			registered, err := c.ensureCommand(ctx, want, ours)
+			if err != nil {
+				failed = append(failed, t.Name+"/"+want.Trigger)
+				logger.DebugCF("mattermost", "Failed to register slash command", map[string]any{
+					"team":    t.Name,
+					"trigger": want.Trigger,
+					"error":   err.Error(),
+				})
+				continue
+			}
+			c.commandMu.Lock()
+			c.commandTokens[registered.Token] = true
+			c.commandMu.Unlock()
+		}
+	}
+	if len(failed) > 0 {
+		logger.WarnCF("mattermost", "Some slash commands were not registered", map[string]any{
+			"commands": strings.Join(failed, ", "),
+		})
+	}
+	return nil
+}
+
+// ensureCommand creates want, or updates the bot's earlier command with the
+// same trigger when it differs.
+func (c *MattermostChannel) ensureCommand(ctx context.Context, want command, ours map[string]command) (*command, error) {
+	cur, ok := ours[want.Trigger]
+	if !ok {
+		return c.api.createCommand(ctx, want)
+	}
+	if cur.URL == want.URL && cur.Method == want.Method && cur.AutoCompleteDesc == want.AutoCompleteDesc &&
+		cur.AutoCompleteHint == want.AutoCompleteHint && cur.Token != "" {
+		return &cur, nil
+	}
+	want.ID, want.Token, want.CreatorID = cur.ID, cur.Token, cur.CreatorID
+	return c.api.updateCommand(ctx, want)
+}
+
+// slashCommands maps definitions onto custom commands of a team.
+func slashCommands(teamID, callbackURL string, defs []commands.Definition) []command {
+	cmds := make([]command, 0, len(defs))
+	for _, def := range defs {
+		if !triggerPattern.MatchString(def.Name) || def.Description == "" {
+			continue
+		}
+		hint := strings.TrimSpace(strings.TrimPrefix(def.EffectiveUsage(), "/"+def.Name))
+		cmds = append(cmds, command{
+			TeamID:           teamID,
+			Trigger:          def.Name,
+			Method:           "P",
+			URL:              callbackURL,
+			AutoComplete:     true,
+			AutoCompleteDesc: utils.Truncate(def.Description, maxAutoCompleteLen),
+			AutoCompleteHint: utils.Truncate(hint, maxAutoCompleteLen),
+			DisplayName:      def.Name,
+			Description:      utils.Truncate(def.Description, maxAutoCompleteLen),
+		})
+	}
+	return cmds
+}
+
+// ServeHTTP answers a slash command. The command is echoed in the channel
+// and handed to the agent like typed text; the reply follows as a post.
+func (c *MattermostChannel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxCommandBodySize)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if !c.validCommandToken(r.PostForm.Get("token")) {
+		logger.WarnC("mattermost", "Slash command with unknown token")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !c.IsRunning() {
+		writeCommandResponse(w, "ephemeral", "The bot is not running.")
+		return
+	}
+
+	userID := r.PostForm.Get("user_id")
+	username := r.PostForm.Get("user_name")
+	channelID := r.PostForm.Get("channel_id")
+	if userID == "" || channelID == "" {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	sender := bus.SenderInfo{
+		Platform:    "mattermost",
+		PlatformID:  userID,
+		CanonicalID: identity.BuildCanonicalID("mattermost", userID),
+		Username:    username,
+		DisplayName: username,
+	}
+	if !c.IsAllowedSender(sender) {
+		writeCommandResponse(w, "ephemeral", "You are not allowed to use this bot.")
+		return
+	}
+
+	text := strings.TrimSpace(r.PostForm.Get("command") + " " + r.PostForm.Get("text"))
+	writeCommandResponse(w, "in_channel", text)
+
+	rootID := r.PostForm.Get("root_id")
+	chatID := channelID
+	if rootID != "" {
+		chatID = channelID + "/" + rootID
+	}
+	// Direct message channels are named "<user id>__<user id>"
+	isDM := strings.Contains(r.PostForm.Get("channel_name"), "__")
+	peer := bus.Peer{Kind: "channel", ID: channelID}
+	if isDM {
+		peer = bus.Peer{Kind: "direct", ID: userID}
+	}
+	metadata := map[string]string{
+		"user_id":      userID,
+		"username":     username,
+		"display_name": username,
+		"team_id":      r.PostForm.Get("team_id"),
+		"channel_id":   channelID,
+		"root_id":      rootID,
+		"is_dm":        fmt.Sprintf("%t", isDM),
+	}
+	messageID := r.PostForm.Get("trigger_id")
+	c.HandleMessage(c.ctx, peer, messageID, userID, chatID, text, nil, metadata, sender)
+}
+
+func (c *MattermostChannel) validCommandToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	c.commandMu.RLock()
+	defer c.commandMu.RUnlock()
+	for known := range c.commandTokens {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func writeCommandResponse(w http.ResponseWriter, responseType, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"response_type": responseType, "text": text})
+}
+
+// registerSlashCommands registers the built-in commands in the background so
+// a slow or failing API call never delays message intake.
+func (c *MattermostChannel) registerSlashCommands() {
+	defs := commands.BuiltinDefinitions()
+	go func() {
+		if err := c.RegisterCommands(c.ctx, defs); err != nil {
+			logger.WarnCF("mattermost", "Failed to register slash commands", map[string]any{
+				"error": err.Error(),
+			})
+			return
+		}
+		logger.InfoCF("mattermost", "Mattermost slash commands registered", map[string]any{
+			"count": len(defs),
+		})
+	}()
+}
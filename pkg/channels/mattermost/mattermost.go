@@ -0,0 +1,567 @@
+// Package mattermost connects a Mattermost bot account over the WebSocket
+// event API and answers in channels, threads and direct messages.
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// defaultMaxMessageLength is Mattermost's default post size limit.
+	defaultMaxMessageLength = 16383
+	apiTimeout              = 15 * time.Second
+	pingInterval            = 30 * time.Second
+	pongWait                = 70 * time.Second
+	minReconnectDelay       = time.Second
+	maxReconnectDelay       = time.Minute
+)
+
+// wsEvent is an event of the WebSocket API.
+type wsEvent struct {
+	Event     string          `json:"event"`
+	Data      json.RawMessage `json:"data"`
+	Broadcast struct {
+		ChannelID string `json:"channel_id"`
+		TeamID    string `json:"team_id"`
+	} `json:"broadcast"`
+}
+
+// postedData is the data of a "posted" event. Post and Mentions are JSON
+// encoded as strings.
+type postedData struct {
+	Post        string `json:"post"`
+	ChannelType string `json:"channel_type"` // O public, P private, D direct, G group message
+	ChannelName string `json:"channel_name"`
+	SenderName  string `json:"sender_name"`
+	TeamID      string `json:"team_id"`
+	Mentions    string `json:"mentions"`
+}
+
+type MattermostChannel struct {
+	*channels.BaseChannel
+	config config.MattermostConfig
+	api    *apiClient
+	wsURL  string
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	done        chan struct{}
+	botUserID   string
+	botUsername string
+	mentionRe   *regexp.Regexp
+
+	typingMu   sync.Mutex
+	typingStop map[string]chan struct{} // chatID → stop signal
+
+	commandMu     sync.RWMutex
+	commandTokens map[string]bool // tokens of the slash commands we registered
+}
+
+func NewMattermostChannel(cfg config.MattermostConfig, messageBus *bus.MessageBus) (*MattermostChannel, error) {
+	if cfg.URL == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("mattermost url and token are required")
+	}
+	base, err := url.Parse(strings.TrimRight(cfg.URL, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid mattermost url %q", cfg.URL)
+	}
+	wsBase := *base
+	wsBase.Scheme = "wss"
+	if base.Scheme == "http" {
+		wsBase.Scheme = "ws"
+	}
+
+	maxLen := defaultMaxMessageLength
+	if cfg.MaxMessageLength > 0 {
+		maxLen = cfg.MaxMessageLength
+	}
+	bc := channels.NewBaseChannel("mattermost", cfg, messageBus, cfg.AllowFrom,
+		channels.WithMaxMessageLength(maxLen),
+		channels.WithLengthUnit(cfg.LengthUnit),
+		channels.WithGroupTrigger(cfg.GroupTrigger),
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+
+	return &MattermostChannel{
+		BaseChannel: bc,
+		config:      cfg,
+		api: &apiClient{
+			baseURL: base.String(),
+			token:   cfg.Token,
+			http:    &http.Client{Timeout: apiTimeout},
+		},
+		wsURL:         wsBase.String() + "/api/v4/websocket",
+		ctx:           context.Background(),
+		typingStop:    make(map[string]chan struct{}),
+		commandTokens: make(map[string]bool),
+	}, nil
+}
+
+func (c *MattermostChannel) Start(ctx context.Context) error {
+	logger.InfoC("mattermost", "Starting Mattermost bot")
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	me, err := c.api.me(c.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get mattermost bot user: %w", err)
+	}
+	c.botUserID = me.ID
+	c.botUsername = me.Username
+	c.mentionRe = mentionRegexp(me.Username)
+
+	c.done = make(chan struct{})
+	go c.listen(c.ctx)
+
+	c.SetRunning(true)
+	if c.config.CommandURL != "" {
+		c.registerSlashCommands()
+	}
+
+	logger.InfoCF("mattermost", "Mattermost bot connected", map[string]any{
+		"username": me.Username,
+		"user_id":  me.ID,
+	})
+	return nil
+}
+
+func (c *MattermostChannel) Stop(ctx context.Context) error {
+	logger.InfoC("mattermost", "Stopping Mattermost bot")
+	c.SetRunning(false)
+
+	c.typingMu.Lock()
+	for chatID, stop := range c.typingStop {
+		close(stop)
+		delete(c.typingStop, chatID)
+	}
+	c.typingMu.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+	if c.done != nil {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+		}
+	}
+	return nil
+}
+
+func (c *MattermostChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	channelID, rootID := parseChatID(msg.ChatID)
+	if channelID == "" {
+		return fmt.Errorf("invalid mattermost chat ID: %s", msg.ChatID)
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+	if rootID == "" {
+		// Answer the message in a thread under it
+		rootID = msg.ReplyToMessageID
+	}
+
+	sent, err := c.api.createPost(ctx, post{ChannelID: channelID, RootID: rootID, Message: msg.Content})
+	if err != nil {
+		return fmt.Errorf("mattermost send: %w", classifyError(err))
+	}
+	c.RecordReply(msg.ChatID, sent.ID)
+	return nil
+}
+
+// SendMedia implements the channels.MediaSender interface.
+func (c *MattermostChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	channelID, rootID := parseChatID(msg.ChatID)
+	if channelID == "" {
+		return fmt.Errorf("invalid mattermost chat ID: %s", msg.ChatID)
+	}
+	store := c.GetMediaStore()
+	if store == nil {
+		return fmt.Errorf("no media store available: %w", channels.ErrSendFailed)
+	}
+
+	var fileIDs []string
+	var caption string
+	for _, part := range msg.Parts {
+		localPath, err := store.Resolve(part.Ref)
+		if err != nil {
+			logger.ErrorCF("mattermost", "Failed to resolve media ref", map[string]any{
+				"ref":   part.Ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+		fileID, err := c.api.uploadFile(ctx, channelID, localPath, part.Filename)
+		if err != nil {
+			return fmt.Errorf("mattermost upload: %w", classifyError(err))
+		}
+		fileIDs = append(fileIDs, fileID)
+		if part.Caption != "" && caption == "" {
+			caption = part.Caption
+		}
+	}
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	if _, err := c.api.createPost(ctx, post{
+		ChannelID: channelID,
+		RootID:    rootID,
+		Message:   caption,
+		FileIDs:   fileIDs,
+	}); err != nil {
+		return fmt.Errorf("mattermost send media: %w", classifyError(err))
+	}
+	return nil
+}
+
+// EditMessage implements channels.MessageEditor.
+func (c *MattermostChannel) EditMessage(ctx context.Context, chatID string, messageID string, content string) error {
+	return c.api.patchPost(ctx, messageID, content)
+}
+
+// SendPlaceholder implements channels.PlaceholderCapable.
+func (c *MattermostChannel) SendPlaceholder(ctx context.Context, chatID string) (string, error) {
+	if !c.config.Placeholder.Enabled {
+		return "", nil
+	}
+	text := c.config.Placeholder.Text
+	if text == "" {
+		text = "Thinking... 💭"
+	}
+	channelID, rootID := parseChatID(chatID)
+	sent, err := c.api.createPost(ctx, post{ChannelID: channelID, RootID: rootID, Message: text})
+	if err != nil {
+		return "", err
+	}
+	return sent.ID, nil
+}
+
+// StartTyping implements channels.TypingCapable. Mattermost shows the
+// indicator for a few seconds, so it is refreshed until stopped.
+func (c *MattermostChannel) StartTyping(ctx context.Context, chatID string) (func(), error) {
+	if !c.config.Typing.Enabled {
+		return func() {}, nil
+	}
+	channelID, rootID := parseChatID(chatID)
+
+	c.typingMu.Lock()
+	if stop, ok := c.typingStop[chatID]; ok {
+		close(stop)
+	}
+	stop := make(chan struct{})
+	c.typingStop[chatID] = stop
+	c.typingMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(4 * time.Second)
+		defer ticker.Stop()
+		timeout := time.After(5 * time.Minute)
+		for {
+			if err := c.api.typing(c.ctx, c.botUserID, channelID, rootID); err != nil {
+				logger.DebugCF("mattermost", "Typing error", map[string]any{"chat_id": chatID, "error": err.Error()})
+			}
+			select {
+			case <-stop:
+				return
+			case <-timeout:
+				return
+			case <-c.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		c.typingMu.Lock()
+		defer c.typingMu.Unlock()
+		if s, ok := c.typingStop[chatID]; ok && s == stop {
+			close(stop)
+			delete(c.typingStop, chatID)
+		}
+	}, nil
+}
+
+// listen keeps the WebSocket connected until ctx ends.
+func (c *MattermostChannel) listen(ctx context.Context) {
+	defer close(c.done)
+	delay := minReconnectDelay
+	for {
+		connected, err := c.readEvents(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = minReconnectDelay
+		}
+		logger.WarnCF("mattermost", "WebSocket disconnected, reconnecting", map[string]any{
+			"error":    err.Error(),
+			"retry_in": delay.String(),
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// readEvents handles events until the connection drops. It reports whether
+// the connection was established.
+func (c *MattermostChannel) readEvents(ctx context.Context) (bool, error) {
+	header := http.Header{"Authorization": {"Bearer " + c.config.Token}}
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, c.wsURL, header)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	pingDone := make(chan struct{})
+	defer close(pingDone)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pingDone:
+				return
+			case <-ticker.C:
+				_ = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+			}
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+		var ev wsEvent
+		if err := json.Unmarshal(data, &ev); err != nil || ev.Event != "posted" {
+			continue
+		}
+		c.handlePosted(ev)
+	}
+}
+
+func (c *MattermostChannel) handlePosted(ev wsEvent) {
+	var data postedData
+	if err := json.Unmarshal(ev.Data, &data); err != nil {
+		return
+	}
+	var p post
+	if err := json.Unmarshal([]byte(data.Post), &p); err != nil {
+		return
+	}
+	// System messages (joins, header changes) have a type
+	if p.UserID == "" || p.UserID == c.botUserID || p.Type != "" {
+		return
+	}
+
+	username := strings.TrimPrefix(data.SenderName, "@")
+	sender := bus.SenderInfo{
+		Platform:    "mattermost",
+		PlatformID:  p.UserID,
+		CanonicalID: identity.BuildCanonicalID("mattermost", p.UserID),
+		Username:    username,
+		DisplayName: username,
+	}
+	if !c.IsAllowedSender(sender) {
+		logger.DebugCF("mattermost", "Message rejected by allowlist", map[string]any{
+			"user_id": p.UserID,
+		})
+		return
+	}
+
+	override, hasOverride := c.channelConfig(p.ChannelID, data.ChannelName)
+	if override.Ignore {
+		return
+	}
+
+	isDM := data.ChannelType == "D"
+	content := c.stripBotMention(p.Message)
+	// DMs always get a response; elsewhere apply the group trigger
+	if !isDM {
+		var mentions []string
+		_ = json.Unmarshal([]byte(data.Mentions), &mentions)
+		isMentioned := false
+		for _, id := range mentions {
+			if id == c.botUserID {
+				isMentioned = true
+				break
+			}
+		}
+		gt := c.config.GroupTrigger
+		if hasOverride && override.GroupTrigger != nil {
+			gt = *override.GroupTrigger
+		}
+		respond, cleaned := channels.RespondInGroup(gt, isMentioned, content)
+		if !respond {
+			logger.DebugCF("mattermost", "Group message ignored by group trigger", map[string]any{
+				"user_id": p.UserID,
+			})
+			return
+		}
+		content = cleaned
+	}
+
+	chatID := p.ChannelID
+	if p.RootID != "" {
+		chatID = p.ChannelID + "/" + p.RootID
+	}
+
+	mediaPaths := c.downloadFiles(p, chatID, &content)
+	if content == "" && len(mediaPaths) == 0 {
+		return
+	}
+	if content == "" {
+		content = "[media only]"
+	}
+
+	logger.DebugCF("mattermost", "Received message", map[string]any{
+		"sender_id": p.UserID,
+		"chat_id":   chatID,
+		"preview":   c.LogPreview(chatID, p.UserID, content, 50, sender),
+	})
+
+	peer := bus.Peer{Kind: "channel", ID: p.ChannelID}
+	if isDM {
+		peer = bus.Peer{Kind: "direct", ID: p.UserID}
+	}
+	teamID := data.TeamID
+	if teamID == "" {
+		teamID = ev.Broadcast.TeamID
+	}
+	metadata := map[string]string{
+		"user_id":      p.UserID,
+		"username":     username,
+		"display_name": username,
+		"team_id":      teamID,
+		"channel_id":   p.ChannelID,
+		"root_id":      p.RootID,
+		"is_dm":        fmt.Sprintf("%t", isDM),
+	}
+	c.HandleMessage(c.ctx, peer, p.ID, p.UserID, chatID, content, mediaPaths, metadata, sender)
+}
+
+// downloadFiles stores the files attached to p and notes them in content.
+func (c *MattermostChannel) downloadFiles(p post, chatID string, content *string) []string {
+	if len(p.FileIDs) == 0 {
+		return nil
+	}
+	names := make(map[string]string, len(p.Metadata.Files))
+	for _, f := range p.Metadata.Files {
+		names[f.ID] = f.Name
+	}
+	scope := channels.BuildMediaScope("mattermost", chatID, p.ID)
+	var paths []string
+	for _, id := range p.FileIDs {
+		name := names[id]
+		if name == "" {
+			name = "file"
+		}
+		localPath := utils.DownloadFile(c.api.baseURL+"/api/v4/files/"+id, name, utils.DownloadOptions{
+			LoggerPrefix: "mattermost",
+			ExtraHeaders: map[string]string{"Authorization": "Bearer " + c.config.Token},
+		})
+		if localPath == "" {
+			continue
+		}
+		ref := localPath
+		if store := c.GetMediaStore(); store != nil {
+			if r, err := store.Store(localPath, media.MediaMeta{Filename: name, Source: "mattermost"}, scope); err == nil {
+				ref = r
+			}
+		}
+		paths = append(paths, ref)
+		tag := "file"
+		if utils.IsAudioFile(name, "") {
+			tag = "audio"
+		}
+		*content = strings.TrimSpace(*content + fmt.Sprintf("\n[%s: %s]", tag, name))
+	}
+	return paths
+}
+
+// channelConfig returns the overrides for a channel, looked up by ID and
+// then by name.
+func (c *MattermostChannel) channelConfig(channelID, channelName string) (config.MattermostChannelConfig, bool) {
+	if cc, ok := c.config.Channels[channelID]; ok {
+		return cc, true
+	}
+	if channelName != "" {
+		if cc, ok := c.config.Channels[channelName]; ok {
+			return cc, true
+		}
+	}
+	return config.MattermostChannelConfig{}, false
+}
+
+// stripBotMention removes @botname from the message.
+func (c *MattermostChannel) stripBotMention(text string) string {
+	if c.mentionRe == nil {
+		return strings.TrimSpace(text)
+	}
+	return strings.TrimSpace(c.mentionRe.ReplaceAllString(text, ""))
+}
+
+// mentionRegexp matches @username in a message.
+func mentionRegexp(username string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(username) + `\b`)
+}
+
+// parseChatID splits "channel" or "channel/root" into its parts.
+func parseChatID(chatID string) (channelID, rootID string) {
+	channelID, rootID, _ = strings.Cut(chatID, "/")
+	return channelID, rootID
+}
+
+// classifyError maps an API or network error to the channel sentinels.
+func classifyError(err error) error {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return channels.ClassifySendError(apiErr.StatusCode, err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return channels.ClassifyNetError(err)
+	}
+	return err
+}
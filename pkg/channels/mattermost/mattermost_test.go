@@ -0,0 +1,311 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeServer is the part of the Mattermost API the channel uses.
+type fakeServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	posts  []post
+	events chan string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	fs := &fakeServer{events: make(chan string, 4)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v4/users/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(user{ID: "bot1", Username: "picobot"})
+	})
+	mux.HandleFunc("POST /api/v4/posts", func(w http.ResponseWriter, r *http.Request) {
+		var p post
+		json.NewDecoder(r.Body).Decode(&p)
+		fs.mu.Lock()
+		fs.posts = append(fs.posts, p)
+		p.ID = "post" + string(rune('0'+len(fs.posts)))
+		fs.mu.Unlock()
+		json.NewEncoder(w).Encode(p)
+	})
+	mux.HandleFunc("GET /api/v4/websocket", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for ev := range fs.events {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(ev)); err != nil {
+				return
+			}
+		}
+	})
+	fs.Server = httptest.NewServer(mux)
+	t.Cleanup(func() {
+		close(fs.events)
+		fs.Close()
+	})
+	return fs
+}
+
+func newTestChannel(t *testing.T, cfg config.MattermostConfig, msgBus *bus.MessageBus) *MattermostChannel {
+	t.Helper()
+	if cfg.URL == "" {
+		cfg.URL = "https://chat.example.com"
+	}
+	cfg.Token = "tok"
+	ch, err := NewMattermostChannel(cfg, msgBus)
+	if err != nil {
+		t.Fatalf("NewMattermostChannel: %v", err)
+	}
+	ch.botUserID = "bot1"
+	ch.mentionRe = mentionRegexp("picobot")
+	return ch
+}
+
+// postedEvent builds a "posted" event the way the server sends it.
+func postedEvent(p post, channelType, channelName string, mentions ...string) wsEvent {
+	postJSON, _ := json.Marshal(p)
+	mentionsJSON, _ := json.Marshal(mentions)
+	data, _ := json.Marshal(postedData{
+		Post:        string(postJSON),
+		ChannelType: channelType,
+		ChannelName: channelName,
+		SenderName:  "@alice",
+		TeamID:      "team1",
+		Mentions:    string(mentionsJSON),
+	})
+	return wsEvent{Event: "posted", Data: data}
+}
+
+func receive(t *testing.T, msgBus *bus.MessageBus) (bus.InboundMessage, bool) {
+	t.Helper()
+	select {
+	case msg := <-msgBus.InboundChan():
+		return msg, true
+	case <-time.After(100 * time.Millisecond):
+		return bus.InboundMessage{}, false
+	}
+}
+
+func TestHandlePosted_MentionTrigger(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	ch := newTestChannel(t, config.MattermostConfig{
+		GroupTrigger: config.GroupTriggerConfig{MentionOnly: true},
+	}, msgBus)
+
+	ch.handlePosted(postedEvent(post{ID: "p1", UserID: "u1", ChannelID: "town", Message: "just chatting"}, "O", "town-square"))
+	if msg, ok := receive(t, msgBus); ok {
+		t.Fatalf("unmentioned channel message was handled: %+v", msg)
+	}
+
+	ch.handlePosted(postedEvent(post{ID: "p2", UserID: "u1", ChannelID: "town", RootID: "p0", Message: "@picobot what time is it?"},
+		"O", "town-square", "bot1"))
+	msg, ok := receive(t, msgBus)
+	if !ok {
+		t.Fatal("mention was not handled")
+	}
+	if msg.Content != "what time is it?" {
+		t.Errorf("Content = %q, want the mention stripped", msg.Content)
+	}
+	if msg.ChatID != "town/p0" || msg.Peer.Kind != "channel" {
+		t.Errorf("ChatID = %q, Peer = %+v", msg.ChatID, msg.Peer)
+	}
+
+	// Direct messages need no mention
+	ch.handlePosted(postedEvent(post{ID: "p3", UserID: "u1", ChannelID: "dm", Message: "hello"}, "D", "bot1__u1"))
+	msg, ok = receive(t, msgBus)
+	if !ok || msg.Peer.Kind != "direct" || msg.Peer.ID != "u1" {
+		t.Errorf("DM = %+v, %v", msg, ok)
+	}
+}
+
+func TestHandlePosted_IgnoresOwnAndSystemPosts(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	ch := newTestChannel(t, config.MattermostConfig{}, msgBus)
+
+	ch.handlePosted(postedEvent(post{ID: "p1", UserID: "bot1", ChannelID: "dm", Message: "my reply"}, "D", ""))
+	ch.handlePosted(postedEvent(post{ID: "p2", UserID: "u1", ChannelID: "town", Type: "system_join_channel"}, "O", ""))
+	if msg, ok := receive(t, msgBus); ok {
+		t.Errorf("handled %+v", msg)
+	}
+}
+
+func TestHandlePosted_ChannelOverrides(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	ch := newTestChannel(t, config.MattermostConfig{
+		GroupTrigger: config.GroupTriggerConfig{MentionOnly: true},
+		Channels: map[string]config.MattermostChannelConfig{
+			"bot-lab":   {GroupTrigger: &config.GroupTriggerConfig{}},
+			"announce1": {Ignore: true},
+		},
+	}, msgBus)
+
+	// Looked up by name: every message in bot-lab is answered
+	ch.handlePosted(postedEvent(post{ID: "p1", UserID: "u1", ChannelID: "lab1", Message: "no mention"}, "O", "bot-lab"))
+	if _, ok := receive(t, msgBus); !ok {
+		t.Error("override did not replace the mention trigger")
+	}
+	// Looked up by ID: announce1 is never answered
+	ch.handlePosted(postedEvent(post{ID: "p2", UserID: "u1", ChannelID: "announce1", Message: "@picobot hi"},
+		"O", "announcements", "bot1"))
+	if msg, ok := receive(t, msgBus); ok {
+		t.Errorf("ignored channel handled %+v", msg)
+	}
+}
+
+func TestStart_ReceivesWebSocketEvents(t *testing.T) {
+	fs := newFakeServer(t)
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	ch := newTestChannel(t, config.MattermostConfig{URL: fs.URL}, msgBus)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ch.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer ch.Stop(context.Background())
+	if ch.botUserID != "bot1" {
+		t.Errorf("botUserID = %q", ch.botUserID)
+	}
+
+	ev := postedEvent(post{ID: "p1", UserID: "u1", ChannelID: "dm", Message: "ping"}, "D", "")
+	raw, _ := json.Marshal(ev)
+	fs.events <- `{"event":"hello","data":{}}`
+	fs.events <- string(raw)
+
+	select {
+	case msg := <-msgBus.InboundChan():
+		if msg.Content != "ping" || msg.Sender.PlatformID != "u1" {
+			t.Errorf("inbound = %+v", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("no inbound message")
+	}
+}
+
+func TestSend_PostsInThread(t *testing.T) {
+	fs := newFakeServer(t)
+	ch := newTestChannel(t, config.MattermostConfig{URL: fs.URL}, nil)
+	ch.SetRunning(true)
+
+	ctx := context.Background()
+	if err := ch.Send(ctx, bus.OutboundMessage{ChatID: "town/root1", Content: "in thread"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := ch.Send(ctx, bus.OutboundMessage{ChatID: "town", Content: "top level"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if len(fs.posts) != 2 {
+		t.Fatalf("posts = %+v", fs.posts)
+	}
+	if fs.posts[0].ChannelID != "town" || fs.posts[0].RootID != "root1" {
+		t.Errorf("thread post = %+v", fs.posts[0])
+	}
+	if fs.posts[1].RootID != "" {
+		t.Errorf("top-level post = %+v", fs.posts[1])
+	}
+}
+
+func TestSend_ClassifiesAPIErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"id":"api.context.permissions.app_error","message":"no permission"}`))
+	}))
+	defer srv.Close()
+	ch := newTestChannel(t, config.MattermostConfig{URL: srv.URL}, nil)
+	ch.SetRunning(true)
+
+	err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "town", Content: "hi"})
+	if !errors.Is(err, channels.ErrSendFailed) {
+		t.Errorf("Send = %v, want ErrSendFailed", err)
+	}
+}
+
+func TestServeHTTP_SlashCommand(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	ch := newTestChannel(t, config.MattermostConfig{AllowFrom: config.FlexibleStringSlice{"u1"}}, msgBus)
+	ch.SetRunning(true)
+	ch.commandTokens["cmdtok"] = true
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, webhookPath, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		ch.ServeHTTP(rec, req)
+		return rec
+	}
+
+	form := url.Values{
+		"token":      {"cmdtok"},
+		"user_id":    {"u1"},
+		"user_name":  {"alice"},
+		"channel_id": {"town"},
+		"command":    {"/show"},
+		"text":       {"model"},
+	}
+	rec := post(form)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"in_channel"`) {
+		t.Errorf("response = %d %s", rec.Code, rec.Body.String())
+	}
+	msg, ok := receive(t, msgBus)
+	if !ok || msg.Content != "/show model" || msg.ChatID != "town" {
+		t.Errorf("inbound = %+v, %v", msg, ok)
+	}
+
+	form.Set("user_id", "u2")
+	if rec := post(form); !strings.Contains(rec.Body.String(), `"ephemeral"`) {
+		t.Errorf("disallowed user got %s", rec.Body.String())
+	}
+	if msg, ok := receive(t, msgBus); ok {
+		t.Errorf("disallowed user handled: %+v", msg)
+	}
+
+	form.Set("token", "forged")
+	if rec := post(form); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown token = %d, want 401", rec.Code)
+	}
+}
+
+func TestSlashCommands(t *testing.T) {
+	defs := []commands.Definition{
+		{Name: "show", Description: "Show current configuration", Usage: "/show [model|channel]"},
+		{Name: "Bad Name", Description: "skipped"},
+		{Name: "nodesc"},
+	}
+	cmds := slashCommands("team1", "https://bot.example.com/webhook/mattermost", defs)
+	if len(cmds) != 1 {
+		t.Fatalf("got %d commands, want 1: %+v", len(cmds), cmds)
+	}
+	cmd := cmds[0]
+	if cmd.Trigger != "show" || cmd.Method != "P" || cmd.TeamID != "team1" || cmd.AutoCompleteHint != "[model|channel]" {
+		t.Errorf("command = %+v", cmd)
+	}
+}
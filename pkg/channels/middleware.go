@@ -0,0 +1,35 @@
+package channels
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/middleware"
+)
+
+// SetMiddleware registers the chain whose pre_send middleware sees every
+// outbound message.
+func (m *Manager) SetMiddleware(chain *middleware.Chain) {
+	m.middleware.Store(chain)
+}
+
+// preSendMiddleware runs the pre_send middleware on msg, keeping what they
+// changed. It returns false when msg is not to be sent.
+func (m *Manager) preSendMiddleware(ctx context.Context, name string, msg *bus.OutboundMessage) bool {
+	mm := &middleware.Message{
+		Point:   middleware.PointPreSend,
+		Channel: name,
+		ChatID:  msg.ChatID,
+		Content: msg.Content,
+	}
+	if !m.middleware.Load().Run(ctx, mm) {
+		logger.InfoCF("channels", "Outbound message stopped by middleware", map[string]any{
+			"channel": name,
+			"chat_id": msg.ChatID,
+		})
+		return false
+	}
+	msg.Content = mm.Content
+	return true
+}
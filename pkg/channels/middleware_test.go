@@ -0,0 +1,41 @@
+package channels
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/middleware"
+)
+
+func TestDeliver_RunsPreSendMiddleware(t *testing.T) {
+	var sent []string
+	ch := &mockChannel{sendFn: func(_ context.Context, msg bus.OutboundMessage) error {
+		sent = append(sent, msg.Content)
+		return nil
+	}}
+	w := &channelWorker{ch: ch, limiter: rate.NewLimiter(rate.Inf, 1)}
+
+	m := newTestManager()
+	m.SetMiddleware(middleware.NewChain([]config.MiddlewareConfig{
+		{
+			Type:     "drop",
+			Points:   []string{middleware.PointPreSend},
+			Settings: map[string]any{"patterns": []any{"^DRAFT"}},
+		},
+		{
+			Type:     "replace",
+			Points:   []string{middleware.PointPreSend},
+			Settings: map[string]any{"rules": []any{map[string]any{"pattern": "colour", "with": "color"}}},
+		},
+	}))
+
+	m.deliver(context.Background(), "test", w, bus.OutboundMessage{Channel: "test", ChatID: "1", Content: "DRAFT: hi"})
+	m.deliver(context.Background(), "test", w, bus.OutboundMessage{Channel: "test", ChatID: "1", Content: "Pick a colour"})
+	if len(sent) != 1 || sent[0] != "Pick a color" {
+		t.Errorf("sent %q, want only the rewritten second message", sent)
+	}
+}
@@ -0,0 +1,131 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// defaultUploadLimits are the largest files each platform accepts from a
+// bot. Channels not listed use agents.defaults.max_media_size.
+var defaultUploadLimits = map[string]int64{
+	"telegram":        50 << 20,
+	"discord":         10 << 20,
+	"slack":           1 << 30,
+	"whatsapp":        100 << 20,
+	"whatsapp_native": 100 << 20,
+	"feishu":          30 << 20,
+	"matrix":          50 << 20,
+	"line":            10 << 20,
+}
+
+// uploadLimit returns the largest attachment the named channel can send.
+func (m *Manager) uploadLimit(name string) int64 {
+	if limit, ok := m.config.ObjectStorage.UploadLimits[name]; ok && limit > 0 {
+		return limit
+	}
+	if limit, ok := defaultUploadLimits[name]; ok {
+		return limit
+	}
+	return int64(m.config.Agents.Defaults.GetMaxMediaSize())
+}
+
+// offloadOversizedMedia uploads parts too large for the channel to object
+// storage and posts a download link for each instead. It returns the
+// message with only the parts the channel can send itself.
+func (m *Manager) offloadOversizedMedia(
+	ctx context.Context,
+	name string,
+	w *channelWorker,
+	msg bus.OutboundMediaMessage,
+) bus.OutboundMediaMessage {
+	if m.objectStore == nil || m.mediaStore == nil {
+		return msg
+	}
+	limit := m.uploadLimit(name)
+
+	kept := msg.Parts[:0:0]
+	for _, part := range msg.Parts {
+		localPath, meta, err := m.mediaStore.ResolveWithMeta(part.Ref)
+		if err != nil {
+			kept = append(kept, part)
+			continue
+		}
+		info, err := os.Stat(localPath)
+		if err != nil || info.Size() <= limit {
+			kept = append(kept, part)
+			continue
+		}
+
+		filename := part.Filename
+		if filename == "" {
+			filename = meta.Filename
+		}
+		if filename == "" {
+			filename = filepath.Base(localPath)
+		}
+		contentType := part.ContentType
+		if contentType == "" {
+			contentType = meta.ContentType
+		}
+		link, err := m.objectStore.UploadFile(ctx, localPath, filename, contentType)
+		if err != nil {
+			logger.ErrorCF("channels", "Failed to upload oversized media to object storage", map[string]any{
+				"channel": name,
+				"file":    filename,
+				"size":    info.Size(),
+				"error":   err.Error(),
+			})
+			kept = append(kept, part)
+			continue
+		}
+
+		content := fmt.Sprintf("📎 %s (%s) is too large to send here. Download it: %s\nThe link expires in %s.",
+			filename, formatSize(info.Size()), link, formatTTL(m.objectStore.LinkTTL()))
+		if part.Caption != "" {
+			content = part.Caption + "\n" + content
+		}
+		if err := w.ch.Send(ctx, bus.OutboundMessage{Channel: name, ChatID: msg.ChatID, Content: content}); err != nil {
+			logger.ErrorCF("channels", "Failed to send download link", map[string]any{
+				"channel": name,
+				"chat_id": msg.ChatID,
+				"error":   err.Error(),
+			})
+		}
+		logger.InfoCF("channels", "Oversized media sent as download link", map[string]any{
+			"channel": name,
+			"file":    filename,
+			"size":    info.Size(),
+			"limit":   limit,
+		})
+	}
+	msg.Parts = kept
+	return msg
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	default:
+		return fmt.Sprintf("%d KB", (n+1023)/1024)
+	}
+}
+
+func formatTTL(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour && d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%d days", int(d/(24*time.Hour)))
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%d minutes", int(d/time.Minute))
+	}
+}
@@ -0,0 +1,86 @@
+package channels
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/objectstore"
+)
+
+type mockMediaChannel struct {
+	mockChannel
+	sentMedia []bus.OutboundMediaMessage
+}
+
+func (m *mockMediaChannel) SendMedia(_ context.Context, msg bus.OutboundMediaMessage) error {
+	m.sentMedia = append(m.sentMedia, msg)
+	return nil
+}
+
+func TestSendMedia_OffloadsOversizedParts(t *testing.T) {
+	var uploads []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploads = append(uploads, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.ObjectStorage = config.ObjectStorageConfig{
+		Enabled: true, Endpoint: srv.URL, Bucket: "b", AccessKeyID: "k", SecretAccessKey: "s",
+		UploadLimits: map[string]int64{"test": 10},
+	}
+	client, err := objectstore.New(cfg.ObjectStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := media.NewFileMediaStore()
+	m := newTestManager()
+	m.config, m.mediaStore, m.objectStore = cfg, store, client
+
+	dir := t.TempDir()
+	ref := func(name string, size int) string {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, make([]byte, size), 0o644)
+		r, err := store.Store(p, media.MediaMeta{Filename: name}, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	ch := &mockMediaChannel{mockChannel: mockChannel{sendFn: func(context.Context, bus.OutboundMessage) error { return nil }}}
+	w := &channelWorker{ch: ch, limiter: rate.NewLimiter(rate.Inf, 1)}
+	m.sendMediaWithRetry(context.Background(), "test", w, bus.OutboundMediaMessage{
+		Channel: "test",
+		ChatID:  "1",
+		Parts: []bus.MediaPart{
+			{Type: "image", Ref: ref("small.png", 5)},
+			{Type: "file", Ref: ref("export.zip", 100), Caption: "Here is the export"},
+		},
+	})
+
+	if len(ch.sentMedia) != 1 || len(ch.sentMedia[0].Parts) != 1 || ch.sentMedia[0].Parts[0].Type != "image" {
+		t.Fatalf("sent media = %+v", ch.sentMedia)
+	}
+	if len(uploads) != 1 || !strings.HasSuffix(uploads[0], "/export.zip") {
+		t.Fatalf("uploads = %v", uploads)
+	}
+	if len(ch.sentMessages) != 1 {
+		t.Fatalf("expected one link message, got %+v", ch.sentMessages)
+	}
+	text := ch.sentMessages[0].Content
+	if !strings.HasPrefix(text, "Here is the export\n📎 export.zip (1 KB)") ||
+		!strings.Contains(text, srv.URL+"/b/") || !strings.Contains(text, "expires in 24 hours") {
+		t.Errorf("link message = %q", text)
+	}
+}
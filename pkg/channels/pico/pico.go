@@ -461,7 +461,7 @@ func (c *PicoChannel) handleMessageSend(pc *picoConn, msg PicoMessage) {
 
 	logger.DebugCF("pico", "Received message", map[string]any{
 		"session_id": sessionID,
-		"preview":    truncate(content, 50),
+		"preview":    c.LogPreview(chatID, senderID, content, 50),
 	})
 
 	sender := bus.SenderInfo{
@@ -476,12 +476,3 @@ func (c *PicoChannel) handleMessageSend(pc *picoConn, msg PicoMessage) {
 
 	c.HandleMessage(c.ctx, peer, msg.ID, senderID, chatID, content, nil, metadata, sender)
 }
-
-// truncate truncates a string to maxLen runes.
-func truncate(s string, maxLen int) string {
-	runes := []rune(s)
-	if len(runes) <= maxLen {
-		return s
-	}
-	return string(runes[:maxLen]) + "..."
-}
@@ -0,0 +1,92 @@
+package channels
+
+import (
+	"context"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ShowProgress shows text as the status of the reply being prepared in a
+// chat. It edits the chat's placeholder, or posts a status message on a
+// channel that can stream and records it as the placeholder, so the reply
+// replaces it. It reports whether the status is shown.
+func (m *Manager) ShowProgress(ctx context.Context, channel, chatID, text string) bool {
+	if m.Shadowed(channel) {
+		return false
+	}
+	m.mu.RLock()
+	ch, ok := m.channels[channel]
+	m.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	editor, ok := ch.(MessageEditor)
+	if !ok {
+		return false
+	}
+	text = m.redactSecrets(text)
+
+	key := channel + ":" + chatID
+	if v, ok := m.placeholders.Load(key); ok {
+		if err := editor.EditMessage(ctx, chatID, v.(placeholderEntry).id, text); err != nil {
+			logger.DebugCF("channels", "Failed to update progress", map[string]any{
+				"channel": channel,
+				"error":   err.Error(),
+			})
+			return false
+		}
+		return true
+	}
+
+	sc, ok := ch.(StreamingCapable)
+	if !ok {
+		return false
+	}
+	id, err := sc.BeginStream(ctx, chatID, text)
+	if err != nil || id == "" {
+		return false
+	}
+	m.RecordPlaceholder(channel, chatID, id)
+	return true
+}
+
+// Heartbeat shows that the turn answering a chat is still running. A channel
+// that implements HeartbeatCapable shows it its own way; any other has its
+// typing indicator kept alive, restarted if a message sent during the turn
+// stopped it, and status.Text, when set, shown like ShowProgress does.
+func (m *Manager) Heartbeat(ctx context.Context, channel, chatID string, status HeartbeatStatus) {
+	if m.Shadowed(channel) {
+		return
+	}
+	m.mu.RLock()
+	ch, ok := m.channels[channel]
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+	if hc, ok := ch.(HeartbeatCapable); ok {
+		if err := hc.Heartbeat(ctx, chatID, status); err != nil {
+			logger.DebugCF("channels", "Failed to send heartbeat", map[string]any{
+				"channel": channel,
+				"error":   err.Error(),
+			})
+		}
+		return
+	}
+
+	if tc, ok := ch.(TypingCapable); ok {
+		key := channel + ":" + chatID
+		if v, ok := m.typingStops.Load(key); ok {
+			// Keep the TTL janitor from stopping typing during a long turn
+			entry := v.(typingEntry)
+			entry.createdAt = time.Now()
+			m.typingStops.Store(key, entry)
+		} else if stop, err := tc.StartTyping(ctx, chatID); err == nil {
+			m.RecordTypingStop(channel, chatID, stop)
+		}
+	}
+	if status.Text != "" {
+		m.ShowProgress(ctx, channel, chatID, status.Text)
+	}
+}
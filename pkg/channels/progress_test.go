@@ -0,0 +1,103 @@
+package channels
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestShowProgress_PostsThenEditsAndReplyReplacesIt(t *testing.T) {
+	m := newTestManager()
+	var sent []string
+	ch := &streamingChannel{mockChannel: mockChannel{
+		sendFn: func(_ context.Context, msg bus.OutboundMessage) error {
+			sent = append(sent, msg.Content)
+			return nil
+		},
+	}}
+	m.channels["test"] = ch
+
+	if !m.ShowProgress(context.Background(), "test", "chat", "Searching") {
+		t.Fatal("progress not shown on a streaming channel")
+	}
+	if !m.ShowProgress(context.Background(), "test", "chat", "Reading") {
+		t.Fatal("progress not updated")
+	}
+	if len(ch.begun) != 1 || ch.begun[0] != "Searching" || len(ch.edits) != 1 || ch.edits[0] != "Reading" {
+		t.Errorf("begun %q, edits %q", ch.begun, ch.edits)
+	}
+
+	// The reply takes the place of the status message
+	if !m.preSend(context.Background(), "test", bus.OutboundMessage{ChatID: "chat", Content: "Done"}, ch) {
+		t.Error("reply did not replace the status message")
+	}
+	if len(ch.edits) != 2 || ch.edits[1] != "Done" || ch.editFor[1] != "stream-1" || len(sent) != 0 {
+		t.Errorf("edits %q for %q, sent %q", ch.edits, ch.editFor, sent)
+	}
+
+	if m.ShowProgress(context.Background(), "missing", "chat", "Searching") {
+		t.Error("progress shown on a missing channel")
+	}
+	m.channels["plain"] = &mockChannel{}
+	if m.ShowProgress(context.Background(), "plain", "chat", "Searching") {
+		t.Error("progress shown without a placeholder on a channel that cannot stream")
+	}
+}
+
+type typingChannel struct {
+	mockChannel
+	starts, stops int
+}
+
+func (c *typingChannel) StartTyping(context.Context, string) (func(), error) {
+	c.starts++
+	return func() { c.stops++ }, nil
+}
+
+type heartbeatChannel struct {
+	mockChannel
+	beats []HeartbeatStatus
+}
+
+func (c *heartbeatChannel) Heartbeat(_ context.Context, _ string, status HeartbeatStatus) error {
+	c.beats = append(c.beats, status)
+	return nil
+}
+
+func TestHeartbeat_KeepsTypingAliveAndShowsStatus(t *testing.T) {
+	m := newTestManager()
+	ch := &typingChannel{}
+	m.channels["test"] = ch
+	ctx := context.Background()
+
+	m.Heartbeat(ctx, "test", "chat", HeartbeatStatus{Elapsed: 5 * time.Second})
+	if ch.starts != 1 {
+		t.Fatalf("typing started %d times, want 1", ch.starts)
+	}
+	m.Heartbeat(ctx, "test", "chat", HeartbeatStatus{Elapsed: 10 * time.Second})
+	if ch.starts != 1 || ch.stops != 0 {
+		t.Errorf("running typing restarted: %d starts, %d stops", ch.starts, ch.stops)
+	}
+
+	// A message sent during the turn stops typing; the next beat restarts it
+	m.preSend(ctx, "test", bus.OutboundMessage{ChatID: "chat", Content: "Halfway there"}, ch)
+	m.Heartbeat(ctx, "test", "chat", HeartbeatStatus{Elapsed: 15 * time.Second})
+	if ch.starts != 2 || ch.stops != 1 {
+		t.Errorf("typing not restarted: %d starts, %d stops", ch.starts, ch.stops)
+	}
+
+	m.RecordPlaceholder("test", "chat", "ph-1")
+	m.Heartbeat(ctx, "test", "chat", HeartbeatStatus{Elapsed: time.Minute, Text: "Still working… 1m"})
+	if ch.editedMessages != 1 {
+		t.Errorf("status not shown in the placeholder, %d edits", ch.editedMessages)
+	}
+
+	hc := &heartbeatChannel{}
+	m.channels["own"] = hc
+	m.Heartbeat(ctx, "own", "chat", HeartbeatStatus{Elapsed: time.Minute, ETA: time.Minute})
+	if len(hc.beats) != 1 || hc.beats[0].ETA != time.Minute {
+		t.Errorf("beats = %+v", hc.beats)
+	}
+}
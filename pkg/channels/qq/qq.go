@@ -61,6 +61,7 @@ type QQChannel struct {
 func NewQQChannel(cfg config.QQConfig, messageBus *bus.MessageBus) (*QQChannel, error) {
 	base := channels.NewBaseChannel("qq", cfg, messageBus, cfg.AllowFrom,
 		channels.WithMaxMessageLength(cfg.MaxMessageLength),
+		channels.WithLengthUnit(cfg.LengthUnit),
 		channels.WithGroupTrigger(cfg.GroupTrigger),
 		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
 	)
@@ -0,0 +1,29 @@
+package channels
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// redactSecrets masks the secrets in content, the kinds the log masks, when
+// redaction.outbound is on. Replies, tool output relayed to the chat, and
+// streamed text all pass through it before they reach a channel.
+func (m *Manager) redactSecrets(content string) string {
+	if content == "" || !m.redactOutbound.Load() {
+		return content
+	}
+	return logger.MaskSecrets(content)
+}
+
+// redactCaptions returns parts with the secrets in their captions masked.
+func (m *Manager) redactCaptions(parts []bus.MediaPart) []bus.MediaPart {
+	if !m.redactOutbound.Load() {
+		return parts
+	}
+	out := make([]bus.MediaPart, len(parts))
+	for i, part := range parts {
+		part.Caption = m.redactSecrets(part.Caption)
+		out[i] = part
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package channels
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestDeliver_RedactsSecretsBeforeSplitting(t *testing.T) {
+	const key = "sk-proj-abcdefghijklmnopqrstuvwxyz0123456789"
+	var sent []string
+	ch := &mockChannelWithLength{
+		mockChannel: mockChannel{sendFn: func(_ context.Context, msg bus.OutboundMessage) error {
+			sent = append(sent, msg.Content)
+			return nil
+		}},
+		maxLen: 30,
+	}
+	w := &channelWorker{ch: ch, limiter: rate.NewLimiter(rate.Inf, 1)}
+	msg := bus.OutboundMessage{Channel: "test", ChatID: "1", Content: "Your key is " + key + " keep it safe"}
+
+	m := newTestManager()
+	m.deliver(context.Background(), "test", w, msg)
+	if joined := strings.Join(sent, ""); !strings.Contains(joined, "abcdefghij") {
+		t.Fatalf("redaction off, but the key was masked: %q", sent)
+	}
+
+	sent = nil
+	m.redactOutbound.Store(true)
+	m.deliver(context.Background(), "test", w, msg)
+	joined := strings.Join(sent, "")
+	if strings.Contains(joined, "abcdefghij") || !strings.Contains(joined, "sk-proj-****") {
+		t.Errorf("sent %q, want the key masked", sent)
+	}
+
+	parts := m.redactCaptions([]bus.MediaPart{{Ref: "media://1", Caption: "key " + key}})
+	if parts[0].Caption != "key sk-proj-****" {
+		t.Errorf("caption = %q", parts[0].Caption)
+	}
+}
@@ -0,0 +1,128 @@
+package channels
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+var (
+	// refDefinitionRe matches a reference definition line: [label]: url "optional title"
+	refDefinitionRe = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:\s*<?([^\s>]+)>?(?:\s+(?:"[^"]*"|'[^']*'|\([^)]*\)))?\s*$`)
+	// refUsageRe matches full [text][label], collapsed [text][] and shortcut [label] references.
+	refUsageRe = regexp.MustCompile(`\[([^\[\]]+)\](?:\[([^\[\]]*)\])?`)
+)
+
+// ResolveReferenceLinks rewrites Markdown reference-style links ([text][1],
+// [text][] and [label]) into inline links and drops the definitions they used;
+// definitions nothing refers to are kept.
+// Splitting a message otherwise leaves the definitions at the end of the last
+// chunk, so earlier chunks would carry references that cannot be resolved.
+// Fenced code blocks and inline code spans are left untouched, as are
+// references without a matching definition.
+func ResolveReferenceLinks(content string) string {
+	if !strings.Contains(content, "]:") {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	fenced := utils.FencedLines(lines)
+	defs := make(map[string]string)
+	defLabels := make([]string, len(lines))
+	for i, line := range lines {
+		if fenced[i] != utils.NotFenced {
+			continue
+		}
+		if m := refDefinitionRe.FindStringSubmatch(line); m != nil {
+			label := normalizeRefLabel(m[1])
+			// CommonMark: the first definition for a label wins.
+			if _, ok := defs[label]; !ok {
+				defs[label] = m[2]
+			}
+			defLabels[i] = label
+		}
+	}
+	if len(defs) == 0 {
+		return content
+	}
+
+	used := make(map[string]bool)
+	resolved := make([]string, len(lines))
+	for i, line := range lines {
+		if fenced[i] != utils.NotFenced || defLabels[i] != "" {
+			resolved[i] = line
+			continue
+		}
+		resolved[i] = resolveRefsInLine(line, defs, used)
+	}
+	if len(used) == 0 {
+		return content
+	}
+
+	out := make([]string, 0, len(lines))
+	for i, line := range resolved {
+		if !used[defLabels[i]] {
+			out = append(out, line)
+		}
+	}
+
+	// Dropped definitions usually end the message; the blank lines before
+	// them go too, but the message keeps the whitespace it ended with.
+	trailing := content[len(strings.TrimRight(content, " \t\r\n")):]
+	return strings.TrimRight(strings.Join(out, "\n"), " \t\r\n") + trailing
+}
+
+// resolveRefsInLine replaces references outside inline code spans and marks
+// the labels it replaced in used.
+func resolveRefsInLine(line string, defs map[string]string, used map[string]bool) string {
+	if !strings.Contains(line, "[") {
+		return line
+	}
+
+	// Odd segments of a backtick split are inside inline code.
+	parts := strings.Split(line, "`")
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = replaceRefs(parts[i], defs, used)
+	}
+	return strings.Join(parts, "`")
+}
+
+func replaceRefs(s string, defs map[string]string, used map[string]bool) string {
+	matches := refUsageRe.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		// Already an inline link, or a definition-like construct.
+		if end < len(s) && (s[end] == '(' || s[end] == ':') {
+			continue
+		}
+		text := s[m[2]:m[3]]
+		label := text
+		if m[4] >= 0 && m[5] > m[4] {
+			label = s[m[4]:m[5]]
+		}
+		label = normalizeRefLabel(label)
+		url, ok := defs[label]
+		if !ok {
+			continue
+		}
+		used[label] = true
+		sb.WriteString(s[last:start])
+		sb.WriteString("[" + text + "](" + url + ")")
+		last = end
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+// normalizeRefLabel applies CommonMark label matching: case-insensitive with
+// internal whitespace collapsed.
+func normalizeRefLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
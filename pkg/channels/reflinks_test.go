@@ -0,0 +1,115 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveReferenceLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "no definitions",
+			content: "See [docs][1] for more.",
+			want:    "See [docs][1] for more.",
+		},
+		{
+			name:    "full reference",
+			content: "See [docs][1] for more.\n\n[1]: https://example.com/docs",
+			want:    "See [docs](https://example.com/docs) for more.",
+		},
+		{
+			name:    "collapsed reference",
+			content: "Read [the guide][] first.\n\n[The Guide]: https://example.com/guide",
+			want:    "Read [the guide](https://example.com/guide) first.",
+		},
+		{
+			name:    "shortcut reference",
+			content: "Ask [Go] about it.\n\n[go]: https://go.dev",
+			want:    "Ask [Go](https://go.dev) about it.",
+		},
+		{
+			name:    "definition with title and angle brackets",
+			content: "[a][x]\n\n[x]: <https://a.example> \"Title\"",
+			want:    "[a](https://a.example)",
+		},
+		{
+			name:    "undefined reference left alone",
+			content: "[a][1] and [b][2]\n\n[1]: https://a.example",
+			want:    "[a](https://a.example) and [b][2]",
+		},
+		{
+			name:    "inline link untouched",
+			content: "[a](https://inline.example) [b][1]\n\n[1]: https://b.example",
+			want:    "[a](https://inline.example) [b](https://b.example)",
+		},
+		{
+			name:    "fenced code untouched",
+			content: "```\n[x][1]\n[1]: not-a-def\n```\n[y][1]\n\n[1]: https://y.example",
+			want:    "```\n[x][1]\n[1]: not-a-def\n```\n[y](https://y.example)",
+		},
+		{
+			name:    "inline code untouched",
+			content: "`arr[i][1]` vs [link][1]\n\n[1]: https://l.example",
+			want:    "`arr[i][1]` vs [link](https://l.example)",
+		},
+		{
+			name:    "tilde fence untouched",
+			content: "See [docs].\n\n~~~md\nUse [docs] here\n[docs]: https://example.com\n~~~\n",
+			want:    "See [docs].\n\n~~~md\nUse [docs] here\n[docs]: https://example.com\n~~~\n",
+		},
+		{
+			name:    "unused definition kept",
+			content: "[a][1]\n\n[1]: https://a.example\n[2]: https://b.example\n",
+			want:    "[a](https://a.example)\n\n[2]: https://b.example\n",
+		},
+		{
+			name:    "trailing whitespace kept",
+			content: "[a][1]\n\n[1]: https://a.example\n\n",
+			want:    "[a](https://a.example)\n\n",
+		},
+		{
+			name:    "first definition wins",
+			content: "[a][1]\n\n[1]: https://first.example\n[1]: https://second.example",
+			want:    "[a](https://first.example)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ResolveReferenceLinks(tc.content)
+			if got != tc.want {
+				t.Errorf("ResolveReferenceLinks() =\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSplitMessage_ResolvesReferenceLinks(t *testing.T) {
+	content := "See [the docs][1] first.\n\n" + strings.Repeat("filler text ", 30) +
+		"\n\n[1]: https://example.com/docs"
+	chunks := SplitMessage(content, 150)
+
+	if len(chunks) < 2 {
+		t.Fatalf("Expected multiple chunks, got %d", len(chunks))
+	}
+	if !strings.Contains(chunks[0], "[the docs](https://example.com/docs)") {
+		t.Errorf("First chunk should carry the inline link. Got: %q", chunks[0])
+	}
+	for i, chunk := range chunks {
+		if strings.Contains(chunk, "[1]:") {
+			t.Errorf("Chunk %d still contains the reference definition: %q", i, chunk)
+		}
+	}
+}
+
+func TestSplitMessage_KeepsDefinitionsInTildeFence(t *testing.T) {
+	content := "See [docs].\n\n~~~md\nUse [docs] here\n[docs]: https://example.com\n~~~\n"
+	chunks := SplitMessage(content, 2000)
+	if len(chunks) != 1 || !strings.Contains(chunks[0], "[docs]: https://example.com\n~~~") {
+		t.Errorf("SplitMessage() = %q", chunks)
+	}
+}
@@ -0,0 +1,174 @@
+package channels
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Reload applies cfg to the running channels. A channel whose config
+// section changed is cycled: its worker sends what it has queued, the
+// channel is stopped, and a new one is created from cfg and started, while
+// outbound messages for every channel wait. Channels turned on or off are
+// started or stopped, and a change of allow_from alone is applied without
+// reconnecting. The other channels are left alone.
+//
+// Reload reports false when it cannot apply cfg per channel: before
+// StartAll, or with high availability on, where replicas hold the channel
+// leases. The caller must then recreate the manager.
+func (m *Manager) Reload(ctx context.Context, cfg *config.Config) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.haLock != nil || m.dispatchTask == nil {
+		return false
+	}
+	old := m.config
+	m.config = cfg
+	m.redactOutbound.Store(cfg.Redaction.Outbound)
+	m.shadow.Store(&cfg.Shadow)
+
+	specs := configuredChannels(cfg)
+	wanted := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		wanted[spec.name] = true
+	}
+	for name, ch := range m.channels {
+		if !wanted[name] && channelConfig(old, name) != nil {
+			logger.InfoCF("channels", "Channel turned off by config reload", map[string]any{"channel": name})
+			m.retireChannel(ctx, name, ch)
+			delete(m.channels, name)
+		}
+	}
+
+	for _, spec := range specs {
+		if ch, ok := m.channels[spec.name]; ok {
+			before, after := channelConfig(old, spec.name), channelConfig(cfg, spec.name)
+			if reflect.DeepEqual(before, after) {
+				continue
+			}
+			rest, allowList := withoutAllowList(after)
+			if setter, ok := ch.(interface{ SetAllowList([]string) }); ok {
+				if before, _ := withoutAllowList(before); reflect.DeepEqual(before, rest) {
+					setter.SetAllowList(allowList)
+					logger.InfoCF("channels", "Channel allow-list reloaded", map[string]any{
+						"channel": spec.name,
+						"entries": len(allowList),
+					})
+					continue
+				}
+			}
+			logger.InfoCF("channels", "Cycling channel for config reload", map[string]any{"channel": spec.name})
+			m.retireChannel(ctx, spec.name, ch)
+			delete(m.channels, spec.name)
+		}
+		m.initChannel(spec.name, spec.displayName)
+		if ch, ok := m.channels[spec.name]; ok && ch != nil {
+			m.registerChannelHTTP(spec.name, ch)
+			m.startChannel(m.dispatchTask.ctx, m.dispatchTask.dispatchCtx, spec.name, ch)
+		}
+	}
+	return true
+}
+
+// retireChannel stops a channel after its workers sent what they had
+// queued. The caller must hold m.mu.
+func (m *Manager) retireChannel(ctx context.Context, name string, channel Channel) {
+	if w, ok := m.workers[name]; ok && w != nil {
+		delete(m.workers, name)
+		close(w.drain)
+		<-w.done
+		<-w.mediaDone
+		if w.cancel != nil {
+			w.cancel()
+		}
+	}
+	if channel == nil {
+		return
+	}
+	if err := channel.Stop(ctx); err != nil {
+		logger.ErrorCF("channels", "Error stopping channel", map[string]any{
+			"channel": name,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// registerChannelHTTP mounts the webhook and health handlers of channel
+// name on the shared mux. The handlers look the channel up per request, so
+// they reach the channel a reload put in its place.
+func (m *Manager) registerChannelHTTP(name string, ch Channel) {
+	if m.mux == nil {
+		return
+	}
+	if wh, ok := ch.(WebhookHandler); ok && !m.httpPaths[wh.WebhookPath()] {
+		m.httpPaths[wh.WebhookPath()] = true
+		m.mux.HandleFunc(wh.WebhookPath(), func(w http.ResponseWriter, r *http.Request) {
+			if wh, ok := m.lookupChannel(name).(WebhookHandler); ok {
+				wh.ServeHTTP(w, r)
+				return
+			}
+			http.NotFound(w, r)
+		})
+		logger.InfoCF("channels", "Webhook handler registered", map[string]any{
+			"channel": name,
+			"path":    wh.WebhookPath(),
+		})
+	}
+	if hc, ok := ch.(HealthChecker); ok && !m.httpPaths[hc.HealthPath()] {
+		m.httpPaths[hc.HealthPath()] = true
+		m.mux.HandleFunc(hc.HealthPath(), func(w http.ResponseWriter, r *http.Request) {
+			if hc, ok := m.lookupChannel(name).(HealthChecker); ok {
+				hc.HealthHandler(w, r)
+				return
+			}
+			http.NotFound(w, r)
+		})
+		logger.InfoCF("channels", "Health endpoint registered", map[string]any{
+			"channel": name,
+			"path":    hc.HealthPath(),
+		})
+	}
+}
+
+func (m *Manager) lookupChannel(name string) Channel {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.channels[name]
+}
+
+// channelConfig returns the section of cfg.Channels channel name is created
+// from, or nil for channels not created from the config.
+func channelConfig(cfg *config.Config, name string) any {
+	if name == "whatsapp_native" {
+		name = "whatsapp"
+	}
+	v := reflect.ValueOf(cfg.Channels)
+	for i := 0; i < v.NumField(); i++ {
+		tag, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if tag == name {
+			return v.Field(i).Interface()
+		}
+	}
+	return nil
+}
+
+// withoutAllowList returns a copy of the channel config section section
+// with its AllowFrom cleared, and the AllowFrom entries.
+func withoutAllowList(section any) (any, []string) {
+	if section == nil {
+		return nil, nil
+	}
+	v := reflect.New(reflect.TypeOf(section)).Elem()
+	v.Set(reflect.ValueOf(section))
+	f := v.FieldByName("AllowFrom")
+	if !f.IsValid() || f.Kind() != reflect.Slice {
+		return section, nil
+	}
+	allowList := append([]string(nil), f.Convert(reflect.TypeOf([]string(nil))).Interface().([]string)...)
+	f.Set(reflect.Zero(f.Type()))
+	return v.Interface(), allowList
+}
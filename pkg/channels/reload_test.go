@@ -0,0 +1,150 @@
+package channels
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// reloadChannel records how often it was started and stopped and what it
+// sent.
+type reloadChannel struct {
+	*BaseChannel
+	mu      sync.Mutex
+	server  string
+	started int
+	stopped int
+	sent    []string
+}
+
+func (c *reloadChannel) Start(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started++
+	c.SetRunning(true)
+	return nil
+}
+
+func (c *reloadChannel) Stop(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped++
+	c.SetRunning(false)
+	return nil
+}
+
+func (c *reloadChannel) Send(_ context.Context, msg bus.OutboundMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, msg.Content)
+	return nil
+}
+
+func (c *reloadChannel) counts() (started, stopped, sent int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.started, c.stopped, len(c.sent)
+}
+
+func TestManagerReload_CyclesOnlyChangedChannels(t *testing.T) {
+	for _, name := range []string{"irc", "slack"} {
+		RegisterFactory(name, func(cfg *config.Config, b *bus.MessageBus) (Channel, error) {
+			section, allowList := withoutAllowList(channelConfig(cfg, name))
+			ch := &reloadChannel{BaseChannel: NewBaseChannel(name, section, b, allowList)}
+			if irc, ok := section.(config.IRCConfig); ok {
+				ch.server = irc.Server
+			}
+			return ch, nil
+		})
+	}
+	defer func() {
+		factoriesMu.Lock()
+		delete(factories, "irc")
+		delete(factories, "slack")
+		factoriesMu.Unlock()
+	}()
+
+	cfg := &config.Config{}
+	cfg.Channels.IRC = config.IRCConfig{Enabled: true, Server: "irc.one:6697", AllowFrom: []string{"alice"}}
+	cfg.Channels.Slack = config.SlackConfig{Enabled: true, BotToken: "xoxb"}
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	m, err := NewManager(cfg, msgBus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Reload(context.Background(), cfg) {
+		t.Error("Reload applied before StartAll")
+	}
+	ctx := context.Background()
+	if err := m.StartAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer m.StopAll(ctx)
+	channel := func(name string) *reloadChannel {
+		ch, _ := m.GetChannel(name)
+		rc, _ := ch.(*reloadChannel)
+		return rc
+	}
+	irc, slack := channel("irc"), channel("slack")
+
+	// An allow_from change is applied to the running channel.
+	next := *cfg
+	next.Channels.IRC.AllowFrom = []string{"alice", "bob"}
+	if !m.Reload(ctx, &next) {
+		t.Fatal("Reload refused")
+	}
+	if channel("irc") != irc || !irc.IsAllowed("bob") {
+		t.Fatal("the allow-list change recreated the channel or was not applied")
+	}
+
+	// A server change cycles IRC after it sent what was queued; Slack keeps
+	// running.
+	m.mu.RLock()
+	m.workers["irc"].queue <- bus.OutboundMessage{Channel: "irc", ChatID: "#c", Content: "queued"}
+	m.mu.RUnlock()
+	cycled := next
+	cycled.Channels.IRC.Server = "irc.two:6697"
+	if !m.Reload(ctx, &cycled) {
+		t.Fatal("Reload refused")
+	}
+	if _, stopped, sent := irc.counts(); stopped != 1 || sent != 1 {
+		t.Errorf("old IRC channel stopped %d times and sent %d messages, want 1 and 1", stopped, sent)
+	}
+	fresh := channel("irc")
+	if fresh == irc || fresh == nil || fresh.server != "irc.two:6697" || !fresh.IsRunning() {
+		t.Fatalf("IRC was not recreated from the new config: %+v", fresh)
+	}
+	if started, stopped, _ := slack.counts(); started != 1 || stopped != 0 || channel("slack") != slack {
+		t.Errorf("Slack started %d and stopped %d times, want it left alone", started, stopped)
+	}
+
+	// The new channel gets the outbound messages.
+	if err := msgBus.PublishOutbound(ctx, bus.OutboundMessage{Channel: "irc", ChatID: "#c", Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for _, _, sent := fresh.counts(); sent == 0 && time.Now().Before(deadline); _, _, sent = fresh.counts() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, _, sent := fresh.counts(); sent != 1 {
+		t.Errorf("new IRC channel sent %d messages, want 1", sent)
+	}
+
+	// Turning a channel off stops it.
+	off := cycled
+	off.Channels.Slack.Enabled = false
+	if !m.Reload(ctx, &off) {
+		t.Fatal("Reload refused")
+	}
+	if _, ok := m.GetChannel("slack"); ok {
+		t.Error("Slack still registered after it was turned off")
+	}
+	if _, stopped, _ := slack.counts(); stopped != 1 {
+		t.Errorf("Slack stopped %d times, want 1", stopped)
+	}
+}
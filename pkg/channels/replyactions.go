@@ -0,0 +1,263 @@
+package channels
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+// Actions users can take on a reply of the agent by reacting to it.
+const (
+	ReplyActionRegenerate = "regenerate"
+	ReplyActionDelete     = "delete"
+	ReplyActionRaw        = "raw"
+	ReplyActionApprove    = "approve"
+	ReplyActionDeny       = "deny"
+	ReplyActionPrevious   = "previous"
+	ReplyActionNext       = "next"
+	ReplyActionSummary    = "summary"
+)
+
+// replyActionTimeout bounds one reaction-triggered action.
+const replyActionTimeout = time.Minute
+
+// sentContent is the reply text of an agent turn, as the agent wrote it.
+type sentContent struct {
+	content   string
+	createdAt time.Time
+}
+
+// Regenerator answers the prompt of the agent turn turnID again, on behalf
+// of sender.
+type Regenerator func(ctx context.Context, turnID string, sender bus.SenderInfo) error
+
+// VariantSwitcher shows the reply variant step places after the current one
+// of the agent turn turnID, on behalf of sender.
+type VariantSwitcher func(ctx context.Context, turnID string, step int, sender bus.SenderInfo) error
+
+// ReplyActionSink is injected into channels by Manager and receives the
+// reactions that act on the bot's replies.
+type ReplyActionSink interface {
+	ResolveReplyAction(channel, chatID, messageID, action string, sender bus.SenderInfo) bool
+}
+
+// ReplyActionForEmoji returns the action a reaction stands for, or "" when
+// it is not one. Both the emoji and its shortcode name are accepted.
+func ReplyActionForEmoji(emoji string) string {
+	emoji = strings.ReplaceAll(strings.Trim(emoji, ":"), "\uFE0F", "")
+	switch emoji {
+	case "🔁", "repeat":
+		return ReplyActionRegenerate
+	case "🗑", "wastebasket":
+		return ReplyActionDelete
+	case "📋", "clipboard":
+		return ReplyActionRaw
+	case "✅", "white_check_mark", "✔", "heavy_check_mark":
+		return ReplyActionApprove
+	case "❌", "x":
+		return ReplyActionDeny
+	case "◀", "⬅", "arrow_backward", "arrow_left":
+		return ReplyActionPrevious
+	case "▶", "➡", "arrow_forward", "arrow_right":
+		return ReplyActionNext
+	case "📝", "memo", "pencil":
+		return ReplyActionSummary
+	}
+	return ""
+}
+
+// SetReplyActionSink injects the sink that reply actions are reported to.
+func (c *BaseChannel) SetReplyActionSink(s ReplyActionSink) {
+	c.replyActionSink = s
+}
+
+// SetRegenerator registers the function that answers a turn again.
+func (m *Manager) SetRegenerator(regenerate Regenerator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.regenerator = regenerate
+}
+
+// SetVariantSwitcher registers the function that switches between the
+// replies of a regenerated turn.
+func (m *Manager) SetVariantSwitcher(switchVariant VariantSwitcher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.variantSwitcher = switchVariant
+}
+
+// recordTurnContent keeps the reply text of turnID for the 📋 action.
+func (m *Manager) recordTurnContent(channel, turnID, content string) {
+	m.turnContents.Store(channel+":"+turnID, sentContent{content: content, createdAt: time.Now()})
+}
+
+// ResolveReplyAction carries out action on the turn that messageID was sent
+// for. It reports whether the action applies; the action itself runs in the
+// background. Implements ReplyActionSink.
+func (m *Manager) ResolveReplyAction(channel, chatID, messageID, action string, sender bus.SenderInfo) bool {
+	v, ok := m.sentTurns.Load(channel + ":" + chatID + ":" + messageID)
+	if !ok {
+		return false
+	}
+	turnID := v.(sentTurn).turnID
+	if key, ok := strings.CutPrefix(turnID, LongOutputTurnPrefix); ok {
+		return m.resolveLongOutputReaction(channel, chatID, key, action, sender)
+	}
+
+	m.mu.RLock()
+	ch, hasChannel := m.channels[channel]
+	w := m.workers[channel]
+	regenerate := m.regenerator
+	switchVariant := m.variantSwitcher
+	resolveApproval := m.approvalResolver
+	m.mu.RUnlock()
+	if !hasChannel {
+		return false
+	}
+
+	var run func(ctx context.Context) error
+	switch action {
+	case ReplyActionRegenerate:
+		if regenerate == nil {
+			return false
+		}
+		run = func(ctx context.Context) error { return regenerate(ctx, turnID, sender) }
+	case ReplyActionPrevious, ReplyActionNext:
+		if switchVariant == nil {
+			return false
+		}
+		step := 1
+		if action == ReplyActionPrevious {
+			step = -1
+		}
+		run = func(ctx context.Context) error { return switchVariant(ctx, turnID, step, sender) }
+	case ReplyActionDelete:
+		deleter, ok := ch.(MessageDeleter)
+		if !ok {
+			return false
+		}
+		run = func(ctx context.Context) error { return m.deleteTurn(ctx, deleter, channel, chatID, turnID) }
+	case ReplyActionRaw:
+		c, ok := m.turnContents.Load(channel + ":" + turnID)
+		if !ok || w == nil || m.mediaStore == nil {
+			return false
+		}
+		if _, ok := ch.(MediaSender); !ok {
+			return false
+		}
+		content := c.(sentContent).content
+		run = func(ctx context.Context) error { return m.sendRawReply(ctx, channel, w, chatID, content) }
+	case ReplyActionApprove, ReplyActionDeny:
+		id, ok := strings.CutPrefix(turnID, ApprovalTurnPrefix)
+		if !ok || resolveApproval == nil {
+			return false
+		}
+		approve := action == ReplyActionApprove
+		run = func(ctx context.Context) error { return resolveApproval(ctx, channel, chatID, id, sender, approve) }
+	default:
+		return false
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), replyActionTimeout)
+		defer cancel()
+		if err := run(ctx); err != nil {
+			logger.WarnCF("channels", "Reply action failed", map[string]any{
+				"channel": channel,
+				"chat_id": chatID,
+				"action":  action,
+				"turn_id": turnID,
+				"error":   err.Error(),
+			})
+		}
+	}()
+	return true
+}
+
+// deleteTurn deletes every message sent in chatID for turnID.
+func (m *Manager) deleteTurn(ctx context.Context, deleter MessageDeleter, channel, chatID, turnID string) error {
+	prefix := channel + ":" + chatID + ":"
+	var ids []string
+	m.sentTurns.Range(func(key, value any) bool {
+		if id, ok := strings.CutPrefix(key.(string), prefix); ok && value.(sentTurn).turnID == turnID {
+			ids = append(ids, id)
+		}
+		return true
+	})
+
+	for _, id := range ids {
+		if err := deleter.DeleteMessage(ctx, chatID, id); err != nil {
+			return err
+		}
+		m.sentTurns.Delete(prefix + id)
+		if v, ok := m.replies.Load(channel + ":" + chatID); ok && v.(replyEntry).id == id {
+			m.forgetReply(channel, chatID)
+		}
+	}
+	logger.InfoCF("channels", "Deleted reply on request", map[string]any{
+		"channel":  channel,
+		"chat_id":  chatID,
+		"turn_id":  turnID,
+		"messages": len(ids),
+	})
+	return nil
+}
+
+// sendRawReply attaches content to chatID as a Markdown file.
+func (m *Manager) sendRawReply(ctx context.Context, channel string, w *channelWorker, chatID, content string) error {
+	ref, err := m.storeMarkdown(channel, chatID, "reply", content)
+	if err != nil {
+		return err
+	}
+	m.sendMediaWithRetry(ctx, channel, w, bus.OutboundMediaMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Parts: []bus.MediaPart{{
+			Type:        "file",
+			Ref:         ref,
+			Filename:    "reply.md",
+			ContentType: "text/markdown",
+		}},
+	})
+	return nil
+}
+
+// storeMarkdown writes content to a temporary Markdown file named
+// <name>.md and puts it into the media store, scoped to chatID.
+func (m *Manager) storeMarkdown(channel, chatID, name, content string) (string, error) {
+	return m.storeText(channel, chatID, name+".md", "text/markdown", content)
+}
+
+// storeText writes content to a temporary file named filename and puts it
+// into the media store, scoped to chatID.
+func (m *Manager) storeText(channel, chatID, filename, contentType, content string) (string, error) {
+	ext := filepath.Ext(filename)
+	name := strings.TrimSuffix(filename, ext)
+	f, err := os.CreateTemp("", "picoclaw-"+name+"-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	_, writeErr := f.WriteString(content)
+	closeErr := f.Close()
+	if writeErr != nil {
+		os.Remove(f.Name())
+		return "", writeErr
+	}
+	if closeErr != nil {
+		os.Remove(f.Name())
+		return "", closeErr
+	}
+
+	scope := BuildMediaScope(channel, chatID, name+"-"+uniqueID())
+	return m.mediaStore.Store(f.Name(), media.MediaMeta{
+		Filename:    filename,
+		ContentType: contentType,
+		Source:      channel,
+	}, scope)
+}
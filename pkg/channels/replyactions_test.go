@@ -0,0 +1,269 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+// actionChannel records the messages deleted and files sent through it.
+type actionChannel struct {
+	mockChannel
+	mu      sync.Mutex
+	deleted []string
+	files   []string
+	store   media.MediaStore
+}
+
+func (c *actionChannel) DeleteMessage(_ context.Context, _, messageID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, messageID)
+	return nil
+}
+
+func (c *actionChannel) SendMedia(_ context.Context, msg bus.OutboundMediaMessage) error {
+	path, err := c.store.Resolve(msg.Parts[0].Ref)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = append(c.files, msg.Parts[0].Filename+"="+string(data))
+	return nil
+}
+
+func (c *actionChannel) snapshot() (deleted, files []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.deleted...), append([]string(nil), c.files...)
+}
+
+func newActionTestSetup(t *testing.T) (*Manager, *actionChannel) {
+	t.Helper()
+	m := newTestManager()
+	m.mediaStore = media.NewFileMediaStore()
+	ch := &actionChannel{store: m.mediaStore}
+	ch.sendFn = func(_ context.Context, msg bus.OutboundMessage) error {
+		m.RecordReply("test", msg.ChatID, "m-"+msg.Content)
+		return nil
+	}
+	m.channels["test"] = ch
+	m.workers["test"] = &channelWorker{
+		ch:      ch,
+		queue:   make(chan bus.OutboundMessage, 10),
+		done:    make(chan struct{}),
+		limiter: rate.NewLimiter(rate.Inf, 1),
+	}
+	return m, ch
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReplyActionForEmoji(t *testing.T) {
+	for emoji, want := range map[string]string{
+		"🔁":             ReplyActionRegenerate,
+		":repeat:":      ReplyActionRegenerate,
+		"🗑️":            ReplyActionDelete,
+		"🗑":             ReplyActionDelete,
+		"📋":             ReplyActionRaw,
+		"clipboard":     ReplyActionRaw,
+		"👍":             "",
+		"🔂":             "",
+		":wastebasket:": ReplyActionDelete,
+		"✅":             ReplyActionApprove,
+		"✔️":            ReplyActionApprove,
+		"❌":             ReplyActionDeny,
+		":x:":           ReplyActionDeny,
+		"◀️":            ReplyActionPrevious,
+		":arrow_right:": ReplyActionNext,
+	} {
+		if got := ReplyActionForEmoji(emoji); got != want {
+			t.Errorf("ReplyActionForEmoji(%q) = %q, want %q", emoji, got, want)
+		}
+	}
+}
+
+func TestResolveReplyAction_DeletesEveryMessageOfTheTurn(t *testing.T) {
+	m, ch := newActionTestSetup(t)
+	w := m.workers["test"]
+	m.sendWithRetry(context.Background(), "test", w, bus.OutboundMessage{ChatID: "1", Content: "part1", TurnID: "req-1"})
+	m.sendWithRetry(context.Background(), "test", w, bus.OutboundMessage{ChatID: "1", Content: "part2", TurnID: "req-1"})
+	m.sendWithRetry(context.Background(), "test", w, bus.OutboundMessage{ChatID: "1", Content: "other", TurnID: "req-2"})
+
+	sender := bus.SenderInfo{PlatformID: "u1"}
+	if m.ResolveReplyAction("test", "1", "m-unknown", ReplyActionDelete, sender) {
+		t.Error("messages that were not a reply cannot be deleted")
+	}
+	if !m.ResolveReplyAction("test", "1", "m-part2", ReplyActionDelete, sender) {
+		t.Fatal("delete was not accepted")
+	}
+	waitFor(t, func() bool { deleted, _ := ch.snapshot(); return len(deleted) == 2 })
+
+	deleted, _ := ch.snapshot()
+	for _, id := range deleted {
+		if id != "m-part1" && id != "m-part2" {
+			t.Errorf("deleted %q, which belongs to another turn", id)
+		}
+	}
+	if _, ok := m.sentTurns.Load("test:1:m-part1"); ok {
+		t.Error("deleted messages should be forgotten")
+	}
+	if _, ok := m.sentTurns.Load("test:1:m-other"); !ok {
+		t.Error("messages of other turns should be kept")
+	}
+}
+
+func TestResolveReplyAction_RawSendsTheUnformattedReply(t *testing.T) {
+	m, ch := newActionTestSetup(t)
+	w := m.workers["test"]
+	go m.runWorker(t.Context(), "test", w)
+	w.queue <- bus.OutboundMessage{ChatID: "1", Content: "**done** :tada:", TurnID: "req-1"}
+	waitFor(t, func() bool { _, ok := m.turnContents.Load("test:req-1"); return ok })
+
+	var replyID string
+	waitFor(t, func() bool {
+		m.sentTurns.Range(func(key, _ any) bool {
+			replyID = key.(string)[len("test:1:"):]
+			return false
+		})
+		return replyID != ""
+	})
+	if !m.ResolveReplyAction("test", "1", replyID, ReplyActionRaw, bus.SenderInfo{PlatformID: "u1"}) {
+		t.Fatal("raw was not accepted")
+	}
+	waitFor(t, func() bool { _, files := ch.snapshot(); return len(files) == 1 })
+	if _, files := ch.snapshot(); files[0] != "reply.md=**done** :tada:" {
+		t.Errorf("file = %q", files[0])
+	}
+}
+
+func TestResolveReplyAction_Regenerate(t *testing.T) {
+	m, _ := newActionTestSetup(t)
+	w := m.workers["test"]
+	m.sendWithRetry(context.Background(), "test", w, bus.OutboundMessage{ChatID: "1", Content: "answer", TurnID: "req-1"})
+
+	sender := bus.SenderInfo{PlatformID: "u1"}
+	if m.ResolveReplyAction("test", "1", "m-answer", ReplyActionRegenerate, sender) {
+		t.Error("regenerate needs a regenerator")
+	}
+
+	got := make(chan string, 1)
+	m.SetRegenerator(func(_ context.Context, turnID string, s bus.SenderInfo) error {
+		got <- turnID + "@" + s.PlatformID
+		return nil
+	})
+	if !m.ResolveReplyAction("test", "1", "m-answer", ReplyActionRegenerate, sender) {
+		t.Fatal("regenerate was not accepted")
+	}
+	select {
+	case v := <-got:
+		if v != "req-1@u1" {
+			t.Errorf("regenerated %q", v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("regenerator was not called")
+	}
+}
+
+func TestResolveReplyAction_SwitchesVariants(t *testing.T) {
+	m, _ := newActionTestSetup(t)
+	w := m.workers["test"]
+	m.sendWithRetry(context.Background(), "test", w, bus.OutboundMessage{ChatID: "1", Content: "answer", TurnID: "req-1"})
+
+	sender := bus.SenderInfo{PlatformID: "u1"}
+	if m.ResolveReplyAction("test", "1", "m-answer", ReplyActionNext, sender) {
+		t.Error("switching needs a variant switcher")
+	}
+
+	got := make(chan int, 1)
+	m.SetVariantSwitcher(func(_ context.Context, turnID string, step int, _ bus.SenderInfo) error {
+		if turnID != "req-1" {
+			t.Errorf("turn = %q", turnID)
+		}
+		got <- step
+		return nil
+	})
+	if !m.ResolveReplyAction("test", "1", "m-answer", ReplyActionPrevious, sender) {
+		t.Fatal("previous was not accepted")
+	}
+	select {
+	case step := <-got:
+		if step != -1 {
+			t.Errorf("step = %d, want -1", step)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("variant switcher was not called")
+	}
+}
+
+func TestResolveReplyAction_ResolvesHeldApprovals(t *testing.T) {
+	m, _ := newActionTestSetup(t)
+	w := m.workers["test"]
+	m.sendWithRetry(context.Background(), "test", w, bus.OutboundMessage{ChatID: "1", Content: "answer", TurnID: "req-1"})
+	m.sendWithRetry(context.Background(), "test", w,
+		bus.OutboundMessage{ChatID: "1", Content: "approve?", TurnID: ApprovalTurnPrefix + "AB12CD"})
+
+	got := make(chan string, 1)
+	m.SetApprovalResolver(func(_ context.Context, channel, chatID, id string, s bus.SenderInfo, approve bool) error {
+		got <- fmt.Sprintf("%s:%s:%s@%s=%v", channel, chatID, id, s.PlatformID, approve)
+		return nil
+	})
+	sender := bus.SenderInfo{PlatformID: "u1"}
+	if m.ResolveReplyAction("test", "1", "m-answer", ReplyActionApprove, sender) {
+		t.Error("✅ on an ordinary reply should be ignored")
+	}
+	if !m.ResolveReplyAction("test", "1", "m-approve?", ReplyActionDeny, sender) {
+		t.Fatal("❌ on an approval prompt was not accepted")
+	}
+	select {
+	case v := <-got:
+		if v != "test:1:AB12CD@u1=false" {
+			t.Errorf("resolved %q", v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("approval resolver was not called")
+	}
+}
+
+func TestHandleReaction_RoutesReplyActions(t *testing.T) {
+	m, _ := newActionTestSetup(t)
+	w := m.workers["test"]
+	m.sendWithRetry(context.Background(), "test", w, bus.OutboundMessage{ChatID: "1", Content: "answer", TurnID: "req-1"})
+	var rated []string
+	m.SetFeedbackRecorder(func(turnID, rating string, _ bus.SenderInfo) { rated = append(rated, rating) })
+
+	base := NewBaseChannel("test", nil, nil, nil)
+	base.SetFeedbackSink(m)
+	if base.HandleReaction("1", "m-answer", "🗑️", bus.SenderInfo{PlatformID: "u1"}) {
+		t.Error("reply actions need a sink")
+	}
+	base.SetReplyActionSink(m)
+	if !base.HandleReaction("1", "m-answer", "🗑️", bus.SenderInfo{PlatformID: "u1"}) {
+		t.Error("🗑️ should delete the reply")
+	}
+	if !base.HandleReaction("1", "m-answer", "👍", bus.SenderInfo{PlatformID: "u1"}) || len(rated) != 1 {
+		t.Errorf("👍 should still rate the reply, rated = %v", rated)
+	}
+}
@@ -0,0 +1,32 @@
+package channels
+
+import (
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Shadowed reports whether channel runs in shadow mode, where nothing is
+// sent to its chats: no reply, placeholder, progress, or typing indicator.
+func (m *Manager) Shadowed(channel string) bool {
+	cfg := m.shadow.Load()
+	return cfg != nil && cfg.Covers(channel)
+}
+
+// shadowChecker is implemented by the Manager, which channels only know as
+// their PlaceholderRecorder.
+type shadowChecker interface {
+	Shadowed(channel string) bool
+}
+
+func shadowedBy(recorder PlaceholderRecorder, channel string) bool {
+	sc, ok := recorder.(shadowChecker)
+	return ok && sc.Shadowed(channel)
+}
+
+func logShadowed(channel, chatID, content string) {
+	logger.InfoCF("channels", "Shadow mode: not sending", map[string]any{
+		"channel": channel,
+		"chat_id": chatID,
+		"content": utils.Truncate(content, 200),
+	})
+}
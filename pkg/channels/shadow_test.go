@@ -0,0 +1,47 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestShadowMode_SendsNothing(t *testing.T) {
+	m := newTestManager()
+	m.shadow.Store(&config.ShadowConfig{Enabled: true, Channels: []string{"test"}})
+	ch := &typingChannel{mockChannel: mockChannel{
+		sendFn: func(context.Context, bus.OutboundMessage) error { return errors.New("sent in shadow mode") },
+	}}
+	ch.BaseChannel = *NewBaseChannel("test", nil, bus.NewMessageBus(), nil)
+	ch.SetOwner(ch)
+	m.attach(ch)
+	m.channels["test"] = ch
+	ctx := context.Background()
+
+	ch.HandleMessage(ctx, bus.Peer{Kind: "direct", ID: "u1"}, "m1", "u1", "chat", "hello", nil, nil)
+	if ch.starts != 0 || ch.placeholdersSent != 0 {
+		t.Errorf("typing started %d times, %d placeholders sent in shadow mode", ch.starts, ch.placeholdersSent)
+	}
+
+	m.sendWithRetry(ctx, "test", newChannelWorker("test", ch), bus.OutboundMessage{ChatID: "chat", Content: "Hi!"})
+	if len(ch.sentMessages) != 0 || ch.editedMessages != 0 {
+		t.Errorf("sent %+v, %d edits", ch.sentMessages, ch.editedMessages)
+	}
+	if m.SendPlaceholder(ctx, "test", "chat") || m.ShowProgress(ctx, "test", "chat", "Searching") {
+		t.Error("placeholder or progress shown in shadow mode")
+	}
+	if m.StreamReply(ctx, "test", "chat") != nil {
+		t.Error("reply streamed in shadow mode")
+	}
+
+	if m.Shadowed("other") {
+		t.Error("a channel outside shadow.channels is shadowed")
+	}
+	m.shadow.Store(&config.ShadowConfig{})
+	if m.Shadowed("test") {
+		t.Error("shadow mode off still shadows")
+	}
+}
@@ -0,0 +1,11 @@
+package slack
+
+import "github.com/sipeed/picoclaw/pkg/format"
+
+// markdownToSlackMrkdwn converts the Markdown the model writes to Slack's
+// mrkdwn: *bold*, _italic_, ~strike~, and <url|text> links. Code is kept
+// verbatim apart from the escaping Slack requires, and language tags are
+// dropped from fences since Slack would show them as text.
+func markdownToSlackMrkdwn(text string) string {
+	return format.Render(text, format.Slack)
+}
@@ -19,6 +19,9 @@ import (
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
+// defaultMaxMessageLength is Slack's limit for the text field of chat.postMessage.
+const defaultMaxMessageLength = 40000
+
 type SlackChannel struct {
 	*channels.BaseChannel
 	config       config.SlackConfig
@@ -48,10 +51,17 @@ func NewSlackChannel(cfg config.SlackConfig, messageBus *bus.MessageBus) (*Slack
 
 	socketClient := socketmode.New(api)
 
+	maxLen := defaultMaxMessageLength
+	if cfg.MaxMessageLength > 0 {
+		maxLen = cfg.MaxMessageLength
+	}
+
 	base := channels.NewBaseChannel("slack", cfg, messageBus, cfg.AllowFrom,
-		channels.WithMaxMessageLength(40000),
+		channels.WithMaxMessageLength(maxLen),
+		channels.WithLengthUnit(cfg.LengthUnit),
 		channels.WithGroupTrigger(cfg.GroupTrigger),
 		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+		channels.WithLongOutput(cfg.LongOutput),
 	)
 
 	return &SlackChannel{
@@ -119,7 +129,7 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 	}
 
 	opts := []slack.MsgOption{
-		slack.MsgOptionText(msg.Content, false),
+		slack.MsgOptionText(markdownToSlackMrkdwn(msg.Content), false),
 	}
 
 	if msg.ReplyToMessageID != "" && threadTS == "" {
@@ -372,7 +382,7 @@ func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
 	logger.DebugCF("slack", "Received message", map[string]any{
 		"sender_id":  senderID,
 		"chat_id":    chatID,
-		"preview":    utils.Truncate(content, 50),
+		"preview":    c.LogPreview(chatID, senderID, content, 50, sender),
 		"has_thread": threadTS != "",
 	})
 
@@ -172,3 +172,56 @@ func TestSlackChannelIsAllowed(t *testing.T) {
 		}
 	})
 }
+
+func TestNewSlackChannel_MaxMessageLength(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	cfg := config.SlackConfig{BotToken: "xoxb-test", AppToken: "xapp-test"}
+
+	ch, err := NewSlackChannel(cfg, msgBus)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ch.MaxMessageLength(); got != defaultMaxMessageLength {
+		t.Errorf("MaxMessageLength() = %d, want default %d", got, defaultMaxMessageLength)
+	}
+
+	cfg.MaxMessageLength = 3000
+	ch, err = NewSlackChannel(cfg, msgBus)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ch.MaxMessageLength(); got != 3000 {
+		t.Errorf("MaxMessageLength() = %d, want configured 3000", got)
+	}
+}
+
+func TestMarkdownToSlackMrkdwn(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"bold", "a **b** c", "a *b* c"},
+		{"underscore bold", "__b__", "*b*"},
+		{"italic", "an *emphasized* word", "an _emphasized_ word"},
+		{"underscore italic", "_kept_", "_kept_"},
+		{"bold and italic", "**bold** and *it*", "*bold* and _it_"},
+		{"strike", "~~gone~~", "~gone~"},
+		{"link", "see [docs](https://example.com/a)", "see <https://example.com/a|docs>"},
+		{"heading", "## Steps", "*Steps*"},
+		{"list", "- one\n* two\n  - nested", "• one\n• two\n  • nested"},
+		{"quote", "> quoted & <b>", "> quoted &amp; &lt;b&gt;"},
+		{"escaping", "1 < 2 && 3 > 2", "1 &lt; 2 &amp;&amp; 3 &gt; 2"},
+		{"mentions survive", "hi <@U123> see <https://x.io>", "hi <@U123> see <https://x.io>"},
+		{"inline code", "run `a **b** <c>`", "run `a **b** &lt;c&gt;`"},
+		{"code block drops language", "```go\nx := *p\n```", "```\nx := *p\n```"},
+		{"plain text", "just text", "just text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownToSlackMrkdwn(tt.input); got != tt.want {
+				t.Errorf("markdownToSlackMrkdwn(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,16 +1,122 @@
 package channels
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
+	"unicode"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 // SplitMessage splits long messages into chunks, preserving code block integrity.
-// The maxLen parameter is measured in runes (Unicode characters), not bytes.
+// The maxLen parameter is measured in runes (Unicode characters), not bytes;
+// use SplitMessageWithLength for platforms that count differently. Chunks are
+// always valid UTF-8, and hard cuts keep grapheme clusters such as emoji
+// sequences whole.
 // The function reserves a buffer (10% of maxLen, min 50) to leave room for closing code blocks,
 // but may extend to maxLen when needed.
 // Call SplitMessage with the full text content and the maximum allowed length of a single message;
 // it returns a slice of message chunks that each respect maxLen and avoid splitting fenced code blocks.
+// Chunks end at paragraph breaks where possible, then at line breaks outside tables, block quotes
+// and list items, then at sentence ends (see findSplitPoint).
+// When a chunk boundary falls in the middle of a blockquote line, the next chunk
+// is prefixed with the same quote marker so the quoted text stays visually quoted.
+// Reference-style links are rewritten inline first (see ResolveReferenceLinks).
 func SplitMessage(content string, maxLen int) []string {
+	return SplitMessageWithLength(content, maxLen, nil)
+}
+
+// SplitMessageWithLength is like SplitMessage but measures maxLen with lengthFn,
+// so platforms that count UTF-16 code units or bytes get chunks that fit their
+// real limit. A nil lengthFn counts runes.
+func SplitMessageWithLength(content string, maxLen int, lengthFn LengthFunc) []string {
+	return SplitMessageWithOptions(content, SplitOptions{MaxLen: maxLen, LengthFunc: lengthFn})
+}
+
+// defaultBreakChars are where a line is cut when no better break fits.
+const defaultBreakChars = " \t"
+
+// SplitOptions controls how SplitMessageWithOptions cuts a message.
+type SplitOptions struct {
+	// MaxLen is the hard limit for a chunk, measured with LengthFunc. Zero
+	// or less keeps the message in one chunk.
+	MaxLen int
+	// LengthFunc measures chunks; nil counts runes.
+	LengthFunc LengthFunc
+	// SoftMaxLen is where chunks are normally cut. They grow past it, up to
+	// MaxLen, only to take in the end of a code block or to reach a break
+	// when there is none before it. Zero keeps CodeBlockBuffer below MaxLen.
+	SoftMaxLen int
+	// CodeBlockBuffer is the room left below MaxLen when SoftMaxLen is
+	// zero: 10% of MaxLen and at least 50 by default, at most half of it.
+	CodeBlockBuffer int
+	// BreakChars are the characters a line may be cut at when no paragraph,
+	// line, or sentence break fits; space and tab by default. A chunk ends
+	// before a space and after any other break character.
+	BreakChars string
+	// PagePrefix and PageSuffix are fmt formats added before and after each
+	// chunk of a message that needs more than one, given the chunk number
+	// and the count: "(%d/%d) " gives "(1/3) ". Room for them is kept in
+	// every chunk.
+	PagePrefix string
+	PageSuffix string
+}
+
+// SplitMessageWithOptions splits content into chunks like SplitMessage,
+// with the limits, break characters, and page markers of opts.
+func SplitMessageWithOptions(content string, opts SplitOptions) []string {
+	if opts.BreakChars == "" {
+		opts.BreakChars = defaultBreakChars
+	}
+	size := opts.LengthFunc
+	if size == nil {
+		size = RuneLength
+	}
+	chunks := splitMessage(content, opts)
+	if len(chunks) < 2 || (opts.PagePrefix == "" && opts.PageSuffix == "") {
+		return chunks
+	}
+
+	// Reserve room for the markers as wide as the chunk count, and split
+	// again if reserving it took the count to more digits.
+	for range 3 {
+		total := len(chunks)
+		reserve := size(pageMarker(opts.PagePrefix, total, total)) +
+			size(pageMarker(opts.PageSuffix, total, total))
+		reduced := opts
+		reduced.MaxLen -= reserve
+		if reduced.SoftMaxLen > 0 {
+			reduced.SoftMaxLen = max(reduced.SoftMaxLen-reserve, 1)
+		}
+		if reduced.MaxLen <= 0 {
+			return chunks
+		}
+		chunks = splitMessage(content, reduced)
+		if len(strconv.Itoa(len(chunks))) <= len(strconv.Itoa(total)) {
+			break
+		}
+	}
+	for i, chunk := range chunks {
+		chunks[i] = pageMarker(opts.PagePrefix, i+1, len(chunks)) + chunk +
+			pageMarker(opts.PageSuffix, i+1, len(chunks))
+	}
+	return chunks
+}
+
+// pageMarker formats a PagePrefix or PageSuffix for chunk n of total; an
+// unset one is empty.
+func pageMarker(format string, n, total int) string {
+	if format == "" {
+		return ""
+	}
+	return fmt.Sprintf(format, n, total)
+}
+
+// splitMessage does the splitting for SplitMessageWithOptions, whose opts
+// have break characters. A nil length function counts runes.
+func splitMessage(content string, opts SplitOptions) []string {
+	maxLen, lengthFn := opts.MaxLen, opts.LengthFunc
 	if maxLen <= 0 {
 		if content == "" {
 			return nil
@@ -18,35 +124,83 @@ func SplitMessage(content string, maxLen int) []string {
 		return []string{content}
 	}
 
+	// Reference definitions usually sit at the very end, so resolve them
+	// before splitting or only the last chunk could use them.
+	content = ResolveReferenceLinks(content)
+
 	runes := []rune(content)
 	totalLen := len(runes)
 	var messages []string
 
-	// Dynamic buffer: 10% of maxLen, but at least 50 chars if possible
-	codeBlockBuffer := max(maxLen/10, 50)
-	if codeBlockBuffer > maxLen/2 {
-		codeBlockBuffer = maxLen / 2
+	// Chunks are cut at the soft limit, leaving room below maxLen to close
+	// a code block: by default 10% of maxLen, but at least 50 chars if possible.
+	softLen := opts.SoftMaxLen
+	if softLen <= 0 || softLen > maxLen {
+		codeBlockBuffer := opts.CodeBlockBuffer
+		if codeBlockBuffer <= 0 {
+			codeBlockBuffer = max(maxLen/10, 50)
+		}
+		if codeBlockBuffer > maxLen/2 {
+			codeBlockBuffer = maxLen / 2
+		}
+		softLen = max(maxLen-codeBlockBuffer, maxLen/2)
+	}
+
+	// measure returns the platform length of runes[from:to], and advance the
+	// furthest index reachable from "from" within n length units (always at
+	// least one rune, so a single oversized rune cannot stall the loop). The
+	// index advance returns never falls inside a grapheme cluster unless the
+	// cluster alone is over the limit.
+	measure := func(from, to int) int {
+		if lengthFn == nil {
+			return to - from
+		}
+		n := 0
+		for _, r := range runes[from:to] {
+			n += lengthFn(string(r))
+		}
+		return n
+	}
+	advance := func(from, n int) int {
+		i := from
+		if lengthFn == nil {
+			i = min(from+max(n, 0), totalLen)
+		} else {
+			for used := 0; i < totalLen; i++ {
+				w := lengthFn(string(runes[i]))
+				if used+w > n {
+					break
+				}
+				used += w
+			}
+		}
+		if i == from && from < totalLen {
+			i++
+		}
+		return clusterStart(runes, from, i)
+	}
+	size := lengthFn
+	if size == nil {
+		size = RuneLength
 	}
 
 	start := 0
 	for start < totalLen {
-		remaining := totalLen - start
-		if remaining <= maxLen {
+		if measure(start, totalLen) <= maxLen {
 			messages = append(messages, string(runes[start:totalLen]))
 			break
 		}
 
-		// Effective split point: maxLen minus buffer, to leave room for code blocks
-		effectiveLimit := max(maxLen-codeBlockBuffer, maxLen/2)
-
-		end := start + effectiveLimit
+		end := advance(start, softLen)
 
-		// Find natural split point within the effective limit
-		msgEnd := findLastNewlineInRange(runes, start, end, 200)
-		if msgEnd <= start {
-			msgEnd = findLastSpaceInRange(runes, start, end, 100)
+		// Find natural split point within the soft limit, or up to the hard
+		// one when there is none.
+		msgEnd := findSplitPoint(runes, start, end, opts.BreakChars)
+		if msgEnd <= start && softLen < maxLen {
+			msgEnd = findSplitPoint(runes, start, advance(start, maxLen), opts.BreakChars)
 		}
-		if msgEnd <= start {
+		// A chunk holding nothing but a quote marker is useless; cut hard instead.
+		if msgEnd <= start || strings.TrimLeft(string(runes[start:msgEnd]), "> ") == "" {
 			msgEnd = end
 		}
 
@@ -55,10 +209,12 @@ func SplitMessage(content string, maxLen int) []string {
 
 		if unclosedIdx >= 0 {
 			// Message would end with incomplete code block
-			// Try to extend up to maxLen to include the closing ```
+			// Try to extend up to maxLen to include the closing fence
+			fence := string(runes[unclosedIdx : unclosedIdx+utils.FenceRunAt(runes, unclosedIdx, totalLen)])
+			closing := "\n" + fence
 			if totalLen > msgEnd {
-				closingIdx := findNextClosingCodeBlockInRange(runes, msgEnd, totalLen)
-				if closingIdx > 0 && closingIdx-start <= maxLen {
+				closingIdx := findClosingFenceInRange(runes, msgEnd, totalLen, fence)
+				if closingIdx > 0 && measure(start, closingIdx) <= maxLen {
 					// Extend to include the closing ```
 					msgEnd = closingIdx
 				} else {
@@ -67,7 +223,7 @@ func SplitMessage(content string, maxLen int) []string {
 					headerEnd := findNewlineFrom(runes, unclosedIdx)
 					var header string
 					if headerEnd == -1 {
-						header = strings.TrimSpace(string(runes[unclosedIdx : unclosedIdx+3]))
+						header = fence
 					} else {
 						header = strings.TrimSpace(string(runes[unclosedIdx:headerEnd]))
 					}
@@ -79,42 +235,50 @@ func SplitMessage(content string, maxLen int) []string {
 					// If we have a reasonable amount of content after the header, split inside
 					if msgEnd > headerEndIdx+20 {
 						// Find a better split point closer to maxLen
-						innerLimit := min(
-							// Leave room for "\n```"
-							start+maxLen-5, totalLen)
+						// Leave room for the closing fence
+						innerLimit := advance(start, maxLen-size(closing)-1)
 						betterEnd := findLastNewlineInRange(runes, start, innerLimit, 200)
 						if betterEnd > headerEndIdx {
 							msgEnd = betterEnd
 						} else {
 							msgEnd = innerLimit
 						}
-						chunk := strings.TrimRight(string(runes[start:msgEnd]), " \t\n\r") + "\n```"
-						messages = append(messages, chunk)
-						remaining := strings.TrimSpace(header + "\n" + string(runes[msgEnd:totalLen]))
-						// Replace the tail of runes with the reconstructed remaining
-						runes = []rune(remaining)
-						totalLen = len(runes)
-						start = 0
-						continue
+						// Reopening after a chunk that took no code would
+						// repeat the header forever; such a chunk is cut as
+						// it is below.
+						if msgEnd > headerEndIdx+1 {
+							remaining := []rune(reopenFence(header, runes[msgEnd:totalLen]))
+							if len(remaining) < totalLen-start {
+								chunk := strings.TrimRight(string(runes[start:msgEnd]), " \t\n\r") + closing
+								messages = append(messages, chunk)
+								// Replace the tail of runes with the reconstructed remaining
+								runes = remaining
+								totalLen = len(runes)
+								start = 0
+								continue
+							}
+						}
 					}
 
 					// Otherwise, try to split before the code block starts
-					newEnd := findLastNewlineInRange(runes, start, unclosedIdx, 200)
-					if newEnd <= start {
-						newEnd = findLastSpaceInRange(runes, start, unclosedIdx, 100)
-					}
+					newEnd := findSplitPoint(runes, start, unclosedIdx, opts.BreakChars)
 					if newEnd > start {
 						msgEnd = newEnd
 					} else {
 						// If we can't split before, we MUST split inside (last resort)
 						if unclosedIdx-start > 20 {
 							msgEnd = unclosedIdx
+						} else if splitAt := advance(start, maxLen-size(closing)-1); splitAt <= headerEndIdx+1 {
+							// Not even one code rune fits after the header, so
+							// reopening would give back the same text.
+							msgEnd = splitAt
+						} else if remaining := []rune(reopenFence(header, runes[splitAt:totalLen])); len(remaining) >= totalLen-start {
+							// Reopening did not make the rest any shorter.
+							msgEnd = splitAt
 						} else {
-							splitAt := min(start+maxLen-5, totalLen)
-							chunk := strings.TrimRight(string(runes[start:splitAt]), " \t\n\r") + "\n```"
+							chunk := strings.TrimRight(string(runes[start:splitAt]), " \t\n\r") + closing
 							messages = append(messages, chunk)
-							remaining := strings.TrimSpace(header + "\n" + string(runes[splitAt:totalLen]))
-							runes = []rune(remaining)
+							runes = remaining
 							totalLen = len(runes)
 							start = 0
 							continue
@@ -125,10 +289,21 @@ func SplitMessage(content string, maxLen int) []string {
 		}
 
 		if msgEnd <= start {
-			msgEnd = start + effectiveLimit
+			msgEnd = end
 		}
 
 		messages = append(messages, string(runes[start:msgEnd]))
+
+		// If the cut landed mid-line inside a blockquote, carry the quote
+		// prefix over so the continuation doesn't read as the bot's own text.
+		if prefix := blockquotePrefixAt(runes, msgEnd); prefix != "" {
+			rest := strings.TrimLeft(string(runes[msgEnd:totalLen]), " \t")
+			runes = []rune(prefix + rest)
+			totalLen = len(runes)
+			start = 0
+			continue
+		}
+
 		// Advance start, skipping leading whitespace of next chunk
 		start = msgEnd
 		for start < totalLen && (runes[start] == ' ' || runes[start] == '\t' || runes[start] == '\n' || runes[start] == '\r') {
@@ -139,35 +314,132 @@ func SplitMessage(content string, maxLen int) []string {
 	return messages
 }
 
-// findLastUnclosedCodeBlockInRange finds the last opening ``` that doesn't have a closing ```
-// within runes[start:end]. Returns the absolute rune index or -1.
+// clusterStart moves the cut at runes[i] back to the start of the grapheme
+// cluster it falls in, so emoji sequences, flags, and letters written with
+// combining marks are not split over two chunks. The cut stays at i when the
+// cluster began at or before from, as moving it would leave an empty chunk.
+func clusterStart(runes []rune, from, i int) int {
+	j := i
+	for j > from && j < len(runes) && continuesCluster(runes, j) {
+		j--
+	}
+	if j <= from {
+		return i
+	}
+	return j
+}
+
+// continuesCluster reports whether runes[i] belongs to the grapheme cluster of
+// the rune before it: a combining mark, variation selector, skin tone, emoji
+// tag, either side of a zero-width joiner, or the second half of a flag.
+func continuesCluster(runes []rune, i int) bool {
+	r, prev := runes[i], runes[i-1]
+	switch {
+	case r == zwj || prev == zwj:
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0x1F3FB && r <= 0x1F3FF, r >= 0xE0020 && r <= 0xE007F:
+		return true
+	case isRegionalIndicator(r):
+		// Flags pair regional indicators from the start of the run.
+		n := 0
+		for k := i - 1; k >= 0 && isRegionalIndicator(runes[k]); k-- {
+			n++
+		}
+		return n%2 == 1
+	}
+	return false
+}
+
+// zwj is the zero-width joiner that glues emoji into one sequence.
+const zwj = '\u200d'
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// blockquotePrefixAt returns the blockquote marker (e.g. "> " or "> > ") of the
+// line containing the split index idx, or "" when the split falls on a line
+// boundary or the line is not quoted.
+func blockquotePrefixAt(runes []rune, idx int) string {
+	if idx <= 0 || idx >= len(runes) || runes[idx] == '\n' || runes[idx] == '\r' {
+		return ""
+	}
+
+	lineStart := idx
+	for lineStart > 0 && runes[lineStart-1] != '\n' {
+		lineStart--
+	}
+
+	// CommonMark allows up to three spaces of indentation before the marker.
+	i := lineStart
+	for i < idx && i-lineStart < 3 && runes[i] == ' ' {
+		i++
+	}
+
+	depth := 0
+	for i < idx && runes[i] == '>' {
+		depth++
+		i++
+		for i < idx && runes[i] == ' ' {
+			i++
+		}
+	}
+	if depth == 0 || i >= idx {
+		return ""
+	}
+	return strings.Repeat("> ", depth)
+}
+
+// reopenFence starts the rest of a split code block with its opening line,
+// so the continuation chunk renders as code in the same language. The line
+// break the cut landed on is dropped rather than showing as a blank line.
+func reopenFence(header string, rest []rune) string {
+	return strings.TrimSpace(header + "\n" + strings.TrimPrefix(string(rest), "\n"))
+}
+
+// findLastUnclosedCodeBlockInRange finds the last opening fence that doesn't have a closing fence
+// within runes[start:end]. A block is closed by a fence of the same character that is at least
+// as long, so ```` blocks may contain ``` lines, and with nothing but spaces after it on its
+// line, so a ```go line inside a block does not close it. Returns the absolute rune index or -1.
 func findLastUnclosedCodeBlockInRange(runes []rune, start, end int) int {
-	inCodeBlock := false
+	open := ""
 	lastOpenIdx := -1
 
 	for i := start; i < end; i++ {
-		if i+2 < end && runes[i] == '`' && runes[i+1] == '`' && runes[i+2] == '`' {
-			if !inCodeBlock {
-				lastOpenIdx = i
-			}
-			inCodeBlock = !inCodeBlock
-			i += 2
+		n := utils.FenceRunAt(runes, i, end)
+		if n == 0 {
+			continue
 		}
+		fence := string(runes[i : i+n])
+		if open == "" {
+			open, lastOpenIdx = fence, i
+		} else if utils.ClosesFence(runes, i, n, open) {
+			open = ""
+		}
+		i += n - 1
 	}
 
-	if inCodeBlock {
+	if open != "" {
 		return lastOpenIdx
 	}
 	return -1
 }
 
-// findNextClosingCodeBlockInRange finds the next closing ``` starting from startIdx
-// within runes[startIdx:end]. Returns the absolute index after the closing ``` or -1.
-func findNextClosingCodeBlockInRange(runes []rune, startIdx, end int) int {
+// findClosingFenceInRange finds the next fence closing a block opened with open,
+// starting from startIdx within runes[startIdx:end]. Returns the absolute index
+// after the closing fence or -1.
+func findClosingFenceInRange(runes []rune, startIdx, end int, open string) int {
 	for i := startIdx; i < end; i++ {
-		if i+2 < end && runes[i] == '`' && runes[i+1] == '`' && runes[i+2] == '`' {
-			return i + 3
+		n := utils.FenceRunAt(runes, i, end)
+		if n == 0 {
+			continue
+		}
+		if utils.ClosesFence(runes, i, n, open) {
+			return i + n
 		}
+		i += n - 1
 	}
 	return -1
 }
@@ -183,6 +455,149 @@ func findNewlineFrom(runes []rune, from int) int {
 	return -1
 }
 
+// findSplitPoint picks where a chunk of runes[start:end] ends. It prefers, in
+// turn, a paragraph break, a line break that is not inside a table, block
+// quote or list item, the end of a sentence, any line break, and one of
+// breaks outside inline code. It returns start-1 when the chunk must be cut hard.
+// A chunk ending inside a code block only uses line breaks and spaces, as
+// the fence handling moves the cut anyway.
+func findSplitPoint(runes []rune, start, end int, breaks string) int {
+	if findLastUnclosedCodeBlockInRange(runes, start, end) < 0 {
+		// Paragraphs may end well before the limit; keep chunks at least half full.
+		for i := end - 1; i > max(end-max((end-start)/2, 200), start); i-- {
+			if runes[i] == '\n' && runes[i-1] == '\n' {
+				for i > start+1 && runes[i-2] == '\n' {
+					i--
+				}
+				return i - 1
+			}
+		}
+		for i := end - 1; i > max(end-200, start); i-- {
+			if runes[i] == '\n' && !insideBlock(runes, i) {
+				return i
+			}
+		}
+		for i := end - 2; i > max(end-200, start); i-- {
+			if sentenceEndsAt(runes, i) && !isTableRow(lineAround(runes, i)) && !inCodeSpan(runes, i) {
+				return i + 1
+			}
+		}
+	}
+	if i := findLastNewlineInRange(runes, start, end, 200); i > start {
+		return i
+	}
+	for i := end - 1; i > max(end-100, start); i-- {
+		if strings.ContainsRune(breaks, runes[i]) && !inCodeSpan(runes, i) {
+			return breakEnd(runes, i)
+		}
+	}
+	if i := findLastBreakInRange(runes, start, end, 100, breaks); i > start {
+		return breakEnd(runes, i)
+	}
+	return start - 1
+}
+
+// breakEnd returns where a chunk cut at the break character runes[i] ends:
+// before whitespace, which the next chunk skips, and after anything else.
+func breakEnd(runes []rune, i int) int {
+	if unicode.IsSpace(runes[i]) {
+		return i
+	}
+	return i + 1
+}
+
+// insideBlock reports whether the line break at runes[i] joins two rows of a
+// table, two lines of a block quote, or a list item and its continuation.
+func insideBlock(runes []rune, i int) bool {
+	before := strings.TrimSpace(lineAround(runes, i-1))
+	after := lineAround(runes, i+1)
+	trimmed := strings.TrimSpace(after)
+	switch {
+	case before == "" || trimmed == "":
+		return false
+	case isTableRow(before) && isTableRow(after):
+		return true
+	case strings.HasPrefix(before, ">") && strings.HasPrefix(trimmed, ">"):
+		return true
+	}
+	// Indented lines that do not start an item continue the one above.
+	return (after[0] == ' ' || after[0] == '\t') && !isListMarker(trimmed)
+}
+
+// lineAround returns the line holding runes[i], without its line break. It
+// returns "" when i is out of range or on a line break.
+func lineAround(runes []rune, i int) string {
+	if i < 0 || i >= len(runes) || runes[i] == '\n' {
+		return ""
+	}
+	from, to := i, i
+	for from > 0 && runes[from-1] != '\n' {
+		from--
+	}
+	for to < len(runes) && runes[to] != '\n' {
+		to++
+	}
+	return string(runes[from:to])
+}
+
+func isTableRow(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "|")
+}
+
+// isListMarker reports whether trimmed starts a bullet or numbered list item.
+func isListMarker(trimmed string) bool {
+	return utils.ListMarkerWidth(trimmed) > 0
+}
+
+// sentenceEndsAt reports whether a sentence ends with runes[i]: a full stop,
+// question or exclamation mark followed by whitespace, or one of the CJK
+// marks, which need no space after them.
+func sentenceEndsAt(runes []rune, i int) bool {
+	switch runes[i] {
+	case '。', '！', '？':
+		return true
+	case '.', '!', '?':
+		next := runes[i+1]
+		return next == ' ' || next == '\n'
+	}
+	return false
+}
+
+// inCodeSpan reports whether runes[i] lies within an inline code span, one
+// that opens before it and closes later on the same line.
+func inCodeSpan(runes []rune, i int) bool {
+	from := i
+	for from > 0 && runes[from-1] != '\n' {
+		from--
+	}
+	open := 0
+	for k := from; k < len(runes) && runes[k] != '\n'; {
+		if runes[k] != '`' {
+			if k >= i && open == 0 {
+				return false
+			}
+			k++
+			continue
+		}
+		n := 0
+		for k+n < len(runes) && runes[k+n] == '`' {
+			n++
+		}
+		switch {
+		case open == 0 && k >= i:
+			return false
+		case open == 0:
+			open = n
+		case n == open && k >= i:
+			return true
+		case n == open:
+			open = 0
+		}
+		k += n
+	}
+	return false
+}
+
 // findLastNewlineInRange finds the last newline within the last searchWindow runes
 // of the range runes[start:end]. Returns the absolute index or start-1 (indicating not found).
 func findLastNewlineInRange(runes []rune, start, end, searchWindow int) int {
@@ -198,9 +613,14 @@ func findLastNewlineInRange(runes []rune, start, end, searchWindow int) int {
 // findLastSpaceInRange finds the last space/tab within the last searchWindow runes
 // of the range runes[start:end]. Returns the absolute index or start-1 (indicating not found).
 func findLastSpaceInRange(runes []rune, start, end, searchWindow int) int {
+	return findLastBreakInRange(runes, start, end, searchWindow, defaultBreakChars)
+}
+
+// findLastBreakInRange is findLastSpaceInRange for any of the characters in breaks.
+func findLastBreakInRange(runes []rune, start, end, searchWindow int, breaks string) int {
 	searchStart := max(end-searchWindow, start)
 	for i := end - 1; i >= searchStart; i-- {
-		if runes[i] == ' ' || runes[i] == '\t' {
+		if strings.ContainsRune(breaks, runes[i]) {
 			return i
 		}
 	}
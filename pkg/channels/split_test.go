@@ -1,8 +1,11 @@
 package channels
 
 import (
+	"fmt"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 )
 
 func TestSplitMessage(t *testing.T) {
@@ -266,6 +269,60 @@ func TestFindLastUnclosedCodeBlockInRange(t *testing.T) {
 			start:   9, end: 17,
 			want: 9,
 		},
+		{
+			name:    "longer fence holds shorter ones",
+			content: "````md\n```go\nx\n```\n",
+			start:   0, end: 19,
+			want: 0,
+		},
+		{
+			name:    "tilde fence",
+			content: "~~~\ncode",
+			start:   0, end: 8,
+			want: 0,
+		},
+		{
+			name:    "tildes inside a line are not a fence",
+			content: "wait~~~ ok",
+			start:   0, end: 10,
+			want: -1,
+		},
+		{
+			name:    "triple backticks inside a line are inline code",
+			content: "run ```ls``` now\n```sh\nls",
+			start:   0, end: 25,
+			want: 17,
+		},
+		{
+			name:    "inline triple backticks on their own line",
+			content: "```ls```\nmore",
+			start:   0, end: 13,
+			want: -1,
+		},
+		{
+			name:    "opening line inside a block does not close it",
+			content: "```\nx\n```go\ny",
+			start:   0, end: 13,
+			want: 0,
+		},
+		{
+			name:    "fence at the very end closes",
+			content: "```go\nx\n```",
+			start:   0, end: 11,
+			want: -1,
+		},
+		{
+			name:    "indented code is not a fence",
+			content: "text\n\n    ```\n    code",
+			start:   0, end: 22,
+			want: -1,
+		},
+		{
+			name:    "fence in a list item",
+			content: "1. Run:\n\n    ```sh\n    make",
+			start:   0, end: 27,
+			want: 13,
+		},
 		{
 			name:    "subrange with no code blocks",
 			content: "```a\n```\nhello",
@@ -286,7 +343,7 @@ func TestFindLastUnclosedCodeBlockInRange(t *testing.T) {
 	}
 }
 
-func TestFindNextClosingCodeBlockInRange(t *testing.T) {
+func TestFindClosingFenceInRange(t *testing.T) {
 	tests := []struct {
 		name     string
 		content  string
@@ -308,10 +365,23 @@ func TestFindNextClosingCodeBlockInRange(t *testing.T) {
 		},
 		{
 			name:     "fence at start of search",
-			content:  "```end",
-			startIdx: 0, end: 6,
+			content:  "```\nend",
+			startIdx: 0, end: 7,
 			want: 3,
 		},
+		{
+			// CommonMark closing fences take no info string
+			name:     "fence with info string does not close",
+			content:  "```end\n```",
+			startIdx: 0, end: 10,
+			want: 10,
+		},
+		{
+			name:     "fence mid-line does not close",
+			content:  "a ```\nb",
+			startIdx: 0, end: 7,
+			want: -1,
+		},
 		{
 			name:     "fence outside range",
 			content:  "code\n```",
@@ -323,9 +393,9 @@ func TestFindNextClosingCodeBlockInRange(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			runes := []rune(tc.content)
-			got := findNextClosingCodeBlockInRange(runes, tc.startIdx, tc.end)
+			got := findClosingFenceInRange(runes, tc.startIdx, tc.end, "```")
 			if got != tc.want {
-				t.Errorf("findNextClosingCodeBlockInRange(%q, %d, %d) = %d, want %d",
+				t.Errorf("findClosingFenceInRange(%q, %d, %d) = %d, want %d",
 					tc.content, tc.startIdx, tc.end, got, tc.want)
 			}
 		})
@@ -360,3 +430,334 @@ func TestSplitMessage_CodeBlockIntegrity(t *testing.T) {
 		t.Errorf("First chunk exceeded maxLen: length %d runes", len([]rune(chunks[0])))
 	}
 }
+
+func TestSplitMessage_BlockquoteContinuation(t *testing.T) {
+	content := "Intro\n\n> " + strings.Repeat("quoted words ", 20)
+	chunks := SplitMessage(content, 120)
+
+	if len(chunks) < 2 {
+		t.Fatalf("Expected at least 2 chunks, got %d: %q", len(chunks), chunks)
+	}
+	for i, chunk := range chunks[1:] {
+		if !strings.HasPrefix(chunk, "> ") {
+			t.Errorf("Chunk %d should continue the blockquote. Got: %q", i+1, chunk)
+		}
+	}
+	for i, chunk := range chunks {
+		if len([]rune(chunk)) > 120 {
+			t.Errorf("Chunk %d exceeds maxLen: %d runes", i, len([]rune(chunk)))
+		}
+	}
+}
+
+func TestSplitMessage_BlockquoteEndsAtNewline(t *testing.T) {
+	content := "> " + strings.Repeat("q", 40) + "\n" + strings.Repeat("plain ", 20)
+	chunks := SplitMessage(content, 100)
+
+	if len(chunks) < 2 {
+		t.Fatalf("Expected at least 2 chunks, got %d: %q", len(chunks), chunks)
+	}
+	for i, chunk := range chunks[1:] {
+		if strings.HasPrefix(chunk, ">") {
+			t.Errorf("Plain text after a finished quote must not be quoted. Chunk %d: %q", i+1, chunk)
+		}
+	}
+}
+
+func TestBlockquotePrefixAt(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		idx     int
+		want    string
+	}{
+		{"not quoted", "hello world", 5, ""},
+		{"simple quote", "> hello world", 7, "> "},
+		{"nested quote", ">> hello world", 8, "> > "},
+		{"spaced nested quote", "> > hello world", 9, "> > "},
+		{"indented quote", "  > hello world", 9, "> "},
+		{"split on newline", "> hello\nworld", 7, ""},
+		{"quote on earlier line", "> hello\nworld again", 13, ""},
+		{"split inside marker", "> hello", 1, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := blockquotePrefixAt([]rune(tc.content), tc.idx)
+			if got != tc.want {
+				t.Errorf("blockquotePrefixAt(%q, %d) = %q, want %q", tc.content, tc.idx, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSplitMessage_ReopensFenceWithLanguage(t *testing.T) {
+	for _, fence := range []string{"```", "~~~", "````"} {
+		t.Run(fence, func(t *testing.T) {
+			body := strings.Repeat("let total = items.reduce((a, b) => a + b, 0);\n", 30)
+			if fence == "````" {
+				// A longer fence lets the block show ``` fences as text
+				body = strings.Repeat("Example:\n```js\nconsole.log(total);\n```\n", 20)
+			}
+			content := "Here you go:\n" + fence + "js\n" + body + fence + "\nDone."
+			chunks := SplitMessage(content, 300)
+			if len(chunks) < 3 {
+				t.Fatalf("expected the block to span several chunks, got %d", len(chunks))
+			}
+			for i, c := range chunks {
+				if len([]rune(c)) > 300 {
+					t.Errorf("chunk %d is %d runes", i, len([]rune(c)))
+				}
+				if i < len(chunks)-1 && !strings.HasSuffix(c, "\n"+fence) {
+					t.Errorf("chunk %d does not close the block: %q", i, c[max(0, len(c)-40):])
+				}
+				if i > 0 && !strings.HasPrefix(c, fence+"js\n") {
+					t.Errorf("chunk %d does not reopen the block: %q", i, c[:min(40, len(c))])
+				}
+				if i > 0 && strings.HasPrefix(c, fence+"js\n\n") {
+					t.Errorf("chunk %d starts the block with a blank line", i)
+				}
+			}
+			if !strings.HasSuffix(chunks[len(chunks)-1], fence+"\nDone.") {
+				t.Errorf("last chunk = %q", chunks[len(chunks)-1])
+			}
+		})
+	}
+}
+
+func TestSplitMessage_KeepsGraphemeClusters(t *testing.T) {
+	clusters := []string{
+		"\U0001F468\u200d\U0001F469\u200d\U0001F467", // ZWJ family
+		"\U0001F44D\U0001F3FD",                       // thumbs up, skin tone
+		"\U0001F1EF\U0001F1F5",                       // flag
+		"e\u0301",                                    // e and a combining acute
+		"\u2764\ufe0f",                               // heart and emoji presentation
+	}
+	for _, cluster := range clusters {
+		content := strings.Repeat(cluster, 60)
+		for _, maxLen := range []int{50, 51, 53} {
+			chunks := SplitMessage(content, maxLen)
+			for i, chunk := range chunks {
+				if !utf8.ValidString(chunk) {
+					t.Fatalf("%q/%d: chunk %d is not valid UTF-8", cluster, maxLen, i)
+				}
+				if strings.ReplaceAll(chunk, cluster, "") != "" {
+					t.Fatalf("%q/%d: chunk %d splits a cluster: %q", cluster, maxLen, i, chunk)
+				}
+				if n := len([]rune(chunk)); n > maxLen {
+					t.Errorf("%q/%d: chunk %d has %d runes", cluster, maxLen, i, n)
+				}
+			}
+			if got := strings.Join(chunks, ""); got != content {
+				t.Errorf("%q/%d: content lost in split", cluster, maxLen)
+			}
+		}
+	}
+
+	// A cluster longer than the limit is still cut rather than stalling.
+	if chunks := SplitMessage(strings.Repeat("á", 30), 1); len(chunks) != 60 {
+		t.Errorf("oversized clusters: got %d chunks, want 60", len(chunks))
+	}
+}
+
+func TestSplitMessage_SplitPoints(t *testing.T) {
+	intro := strings.Repeat("intro ", 16) + "ends here"
+	tests := []struct {
+		name    string
+		content string
+		check   func(chunks []string) bool
+	}{
+		{
+			name:    "paragraph break before later line breaks",
+			content: intro + "\n\n" + strings.Repeat("More text here.\n", 20),
+			check:   func(c []string) bool { return c[0] == intro },
+		},
+		{
+			name:    "table kept whole",
+			content: intro + "\n" + strings.Repeat("| row | value 12 |\n", 15),
+			check:   func(c []string) bool { return c[0] == intro && strings.Count(c[1], "|") == 45 },
+		},
+		{
+			name:    "block quote kept whole",
+			content: intro + "\n" + strings.Repeat("> quoted line here\n", 10) + strings.Repeat("tail ", 20),
+			check:   func(c []string) bool { return c[0] == intro && strings.HasPrefix(c[1], "> quoted") },
+		},
+		{
+			name:    "list item kept with its continuation",
+			content: strings.Repeat("- an item of the list\n  with more detail\n", 10),
+			check:   func(c []string) bool { return strings.HasPrefix(c[1], "- an item") },
+		},
+		{
+			name:    "sentence end on a long line",
+			content: strings.Repeat("This is sentence number one. ", 15),
+			check:   func(c []string) bool { return strings.HasSuffix(c[0], "one.") },
+		},
+		{
+			name:    "inline code span not cut",
+			content: strings.Repeat("x ", 100) + "`" + strings.Repeat("y ", 60) + "` and more words",
+			check:   func(c []string) bool { return !strings.Contains(c[0], "`") },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := SplitMessage(tt.content, 300)
+			if len(chunks) < 2 || !tt.check(chunks) {
+				t.Errorf("chunks = %q", chunks)
+			}
+			for i, c := range chunks {
+				if len([]rune(c)) > 300 {
+					t.Errorf("chunk %d is %d runes", i, len([]rune(c)))
+				}
+			}
+		})
+	}
+}
+
+func TestInCodeSpan(t *testing.T) {
+	runes := []rune("a `b c` d `e")
+	for i, want := range map[int]bool{1: false, 4: true, 7: false, 11: false} {
+		if got := inCodeSpan(runes, i); got != want {
+			t.Errorf("inCodeSpan(%d) = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestSplitMessageWithOptions(t *testing.T) {
+	t.Run("page markers", func(t *testing.T) {
+		content := strings.Repeat("word ", 300)
+		chunks := SplitMessageWithOptions(content, SplitOptions{MaxLen: 200, PageSuffix: " (%d/%d)"})
+		for i, c := range chunks {
+			if len([]rune(c)) > 200 {
+				t.Errorf("chunk %d is %d runes", i, len([]rune(c)))
+			}
+			if want := fmt.Sprintf(" (%d/%d)", i+1, len(chunks)); !strings.HasSuffix(c, want) {
+				t.Errorf("chunk %d = %q, want suffix %q", i, c, want)
+			}
+		}
+		if one := SplitMessageWithOptions("short", SplitOptions{MaxLen: 200, PagePrefix: "(%d/%d) "}); one[0] != "short" {
+			t.Errorf("single chunk = %q, want no marker", one[0])
+		}
+	})
+
+	t.Run("fence header over the reduced limit", func(t *testing.T) {
+		content := "` ```go\nworld. \té```go\n```> quote 1]world. \t🇯🇵\n\n    ac `"
+		chunks := splitWithin(t, content, SplitOptions{MaxLen: 54, PagePrefix: "(%d/%d) "})
+		for i, c := range chunks {
+			if len([]rune(c)) > 54 {
+				t.Errorf("chunk %d is %d runes", i, len([]rune(c)))
+			}
+			if want := fmt.Sprintf("(%d/%d) ", i+1, len(chunks)); !strings.HasPrefix(c, want) || strings.Contains(c, "%!") {
+				t.Errorf("chunk %d = %q, want only the prefix %q", i, c, want)
+			}
+		}
+	})
+
+	t.Run("soft limit", func(t *testing.T) {
+		content := strings.Repeat("a", 180) + " " + strings.Repeat("b", 100)
+		chunks := SplitMessageWithOptions(content, SplitOptions{MaxLen: 200, SoftMaxLen: 150})
+		if len(chunks) != 2 || chunks[0] != strings.Repeat("a", 180) {
+			t.Errorf("expected a cut at the space past the soft limit, got %q", chunks)
+		}
+		chunks = SplitMessageWithOptions(strings.Repeat("c ", 150), SplitOptions{MaxLen: 200, SoftMaxLen: 100})
+		if n := len([]rune(chunks[0])); n > 100 {
+			t.Errorf("first chunk is %d runes, want at most the soft 100", n)
+		}
+	})
+
+	t.Run("code block buffer", func(t *testing.T) {
+		content := strings.Repeat("x", 500)
+		chunks := SplitMessageWithOptions(content, SplitOptions{MaxLen: 200, CodeBlockBuffer: 20})
+		if n := len([]rune(chunks[0])); n != 180 {
+			t.Errorf("first chunk is %d runes, want 180", n)
+		}
+	})
+
+	t.Run("break characters", func(t *testing.T) {
+		content := strings.Repeat("一二三四五六七八九、", 40)
+		chunks := SplitMessageWithOptions(content, SplitOptions{MaxLen: 100, BreakChars: "、"})
+		for i, c := range chunks[:len(chunks)-1] {
+			if !strings.HasSuffix(c, "、") {
+				t.Errorf("chunk %d does not end at a break character: %q", i, c)
+			}
+		}
+		if strings.Join(chunks, "") != content {
+			t.Error("content lost in split")
+		}
+	})
+}
+
+// splitWithin runs SplitMessageWithOptions and fails the test if it does not
+// return, as a split that stops advancing would loop until memory runs out.
+func splitWithin(t *testing.T, content string, opts SplitOptions) []string {
+	t.Helper()
+	done := make(chan []string, 1)
+	go func() { done <- SplitMessageWithOptions(content, opts) }()
+	select {
+	case chunks := <-done:
+		return chunks
+	case <-time.After(5 * time.Second):
+		t.Fatalf("SplitMessageWithOptions(%q, MaxLen %d) did not return", content, opts.MaxLen)
+		return nil
+	}
+}
+
+func TestSplitMessage_FenceHeaderNearLimit(t *testing.T) {
+	header := "```" + strings.Repeat("a", 20)
+	content := header + "\n😀 code\n\n- d\n```\nafter the block"
+	lengthFuncs := []struct {
+		name string
+		fn   LengthFunc
+	}{
+		{"runes", RuneLength},
+		{"utf16", UTF16Length},
+		{"bytes", ByteLength},
+	}
+	for _, lf := range lengthFuncs {
+		for maxLen := len(header) - 5; maxLen <= len(header)+15; maxLen++ {
+			t.Run(fmt.Sprintf("%s/%d", lf.name, maxLen), func(t *testing.T) {
+				chunks := splitWithin(t, content, SplitOptions{MaxLen: maxLen, LengthFunc: lf.fn})
+				for i, c := range chunks {
+					if n := lf.fn(c); n > maxLen {
+						t.Errorf("chunk %d is %d long, over %d: %q", i, n, maxLen, c)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSplitMessage_ReopenFenceAdvances(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		maxLen   int
+		lengthFn LengthFunc
+	}{
+		{"utf16 emoji after bare fence", "```\n😀\n\n- d", 10, UTF16Length},
+		{"bytes emoji after bare fence", "```\n😀\n\n- d", 10, ByteLength},
+		{"long header at discord limit", "```" + strings.Repeat("a", 1991) + "\n" + strings.Repeat("code\n", 10), 2000, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.lengthFn
+			if size == nil {
+				size = RuneLength
+			}
+			chunks := splitWithin(t, tt.content, SplitOptions{MaxLen: tt.maxLen, LengthFunc: tt.lengthFn})
+			for i, c := range chunks {
+				if n := size(c); n > tt.maxLen {
+					t.Errorf("chunk %d is %d long, over %d", i, n, tt.maxLen)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitMessage_InlineTripleBackticks(t *testing.T) {
+	content := strings.Repeat("Use ```ls -la``` to list files in the directory.\n", 20)
+	for i, c := range SplitMessage(content, 300) {
+		if strings.HasSuffix(c, "\n```") || strings.HasPrefix(c, "```\n") {
+			t.Errorf("chunk %d treats inline code as a fence: %q", i, c)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package channels
+
+import "strings"
+
+// MessageSplitter splits a message that arrives in pieces, such as an LLM
+// reply being streamed, into chunks of at most maxLen. Chunks are returned
+// as soon as later text can no longer change them, with the same rules as
+// SplitMessageWithLength. The unsent tail keeps the fence of a code block
+// that was split, so a block opened in one piece stays a block in the chunks
+// after it.
+//
+// Partial lines are held back until their newline arrives unless they are
+// too long for one chunk. Reference-style links are only resolved when the
+// definition arrives before the chunk using it is sent.
+type MessageSplitter struct {
+	maxLen   int
+	lengthFn LengthFunc
+	buf      strings.Builder
+}
+
+// NewMessageSplitter returns a splitter measuring maxLen with lengthFn. A nil
+// lengthFn counts runes, and maxLen <= 0 keeps the message in one chunk.
+func NewMessageSplitter(maxLen int, lengthFn LengthFunc) *MessageSplitter {
+	if lengthFn == nil {
+		lengthFn = RuneLength
+	}
+	return &MessageSplitter{maxLen: maxLen, lengthFn: lengthFn}
+}
+
+// Write adds content to the message and returns the chunks that are ready.
+func (s *MessageSplitter) Write(content string) []string {
+	s.buf.WriteString(content)
+	if s.maxLen <= 0 {
+		return nil
+	}
+
+	pending := s.buf.String()
+	ready, tail := pending, ""
+	if i := strings.LastIndexByte(pending, '\n'); i >= 0 && s.lengthFn(pending[i+1:]) <= s.maxLen {
+		ready, tail = pending[:i+1], pending[i+1:]
+	}
+	if s.lengthFn(ready) <= s.maxLen {
+		return nil
+	}
+
+	// Every chunk but the last was cut with more than maxLen of text after
+	// its start, so what follows cannot move its boundary any more.
+	chunks := SplitMessageWithLength(ready, s.maxLen, s.lengthFn)
+	if len(chunks) < 2 {
+		return nil
+	}
+	last := chunks[len(chunks)-1]
+	// Reopening a fence trims the remainder; keep the whitespace the next
+	// piece continues from.
+	trimmed := strings.TrimRight(ready, " \t\r\n")
+	if trailing := ready[len(trimmed):]; !strings.HasSuffix(last, trailing) {
+		last = strings.TrimRight(last, " \t\r\n") + trailing
+	}
+	s.buf.Reset()
+	s.buf.WriteString(last)
+	s.buf.WriteString(tail)
+	return chunks[:len(chunks)-1]
+}
+
+// Flush returns the rest of the message as its final chunks and resets the
+// splitter for the next message.
+func (s *MessageSplitter) Flush() []string {
+	pending := s.buf.String()
+	s.buf.Reset()
+	if strings.TrimSpace(pending) == "" {
+		return nil
+	}
+	return SplitMessageWithLength(pending, s.maxLen, s.lengthFn)
+}
@@ -0,0 +1,115 @@
+package channels
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// feed writes content to s in pieces of n bytes, like a token stream, and
+// returns every chunk plus how many were ready before Flush.
+func feed(s *MessageSplitter, content string, n int) (chunks []string, early int) {
+	for i := 0; i < len(content); i += n {
+		chunks = append(chunks, s.Write(content[i:min(i+n, len(content))])...)
+	}
+	early = len(chunks)
+	return append(chunks, s.Flush()...), early
+}
+
+func TestMessageSplitter_EmitsEarly(t *testing.T) {
+	var b strings.Builder
+	for i := range 40 {
+		b.WriteString(strings.Repeat("word ", 8))
+		if i%3 == 2 {
+			b.WriteString("\n")
+		}
+	}
+	content := b.String()
+
+	chunks, early := feed(NewMessageSplitter(200, nil), content, 7)
+	if early == 0 || early == len(chunks) {
+		t.Fatalf("%d of %d chunks ready before Flush", early, len(chunks))
+	}
+	for i, c := range chunks {
+		if n := RuneLength(c); n > 200 {
+			t.Errorf("chunk %d is %d runes", i, n)
+		}
+	}
+	if got, want := strings.Fields(strings.Join(chunks, " ")), strings.Fields(content); !slices.Equal(got, want) {
+		t.Errorf("words changed: got %d, want %d", len(got), len(want))
+	}
+}
+
+func TestMessageSplitter_CarriesCodeFence(t *testing.T) {
+	content := "Here is the program:\n```go\n" +
+		strings.Repeat("fmt.Println(\"hello, world\")\n", 30) + "```\nThat's all."
+
+	chunks, _ := feed(NewMessageSplitter(300, nil), content, 11)
+	if len(chunks) < 3 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	for i, c := range chunks {
+		if strings.Count(c, "```")%2 != 0 {
+			t.Errorf("chunk %d has an unbalanced fence:\n%s", i, c)
+		}
+		if RuneLength(c) > 300 {
+			t.Errorf("chunk %d is %d runes", i, RuneLength(c))
+		}
+		if i > 0 && i < len(chunks)-1 && !strings.HasPrefix(c, "```go\n") {
+			t.Errorf("continuation %d does not reopen the fence:\n%s", i, c)
+		}
+	}
+	if got := strings.Count(strings.Join(chunks, ""), "fmt.Println"); got != 30 {
+		t.Errorf("got %d lines of code, want 30", got)
+	}
+	if !strings.HasSuffix(chunks[len(chunks)-1], "That's all.") {
+		t.Errorf("last chunk = %q", chunks[len(chunks)-1])
+	}
+}
+
+func TestMessageSplitter_HoldsShortMessagesAndLines(t *testing.T) {
+	s := NewMessageSplitter(100, nil)
+	if got := s.Write("Hello, "); got != nil {
+		t.Errorf("short message emitted %q", got)
+	}
+	if got := s.Write("world"); got != nil {
+		t.Errorf("short message emitted %q", got)
+	}
+	if got := s.Flush(); len(got) != 1 || got[0] != "Hello, world" {
+		t.Errorf("Flush = %q", got)
+	}
+	if got := s.Flush(); got != nil {
+		t.Errorf("second Flush = %q", got)
+	}
+
+	unlimited := NewMessageSplitter(0, nil)
+	unlimited.Write(strings.Repeat("x", 5000))
+	if got := unlimited.Flush(); len(got) != 1 {
+		t.Errorf("maxLen 0 gave %d chunks", len(got))
+	}
+}
+
+func TestMessageSplitter_FenceHeaderNearLimit(t *testing.T) {
+	for _, lengthFn := range []LengthFunc{RuneLength, UTF16Length, ByteLength} {
+		for n := 40; n <= 56; n++ {
+			content := "Intro line\n```" + strings.Repeat("a", n-3) + "\n😀\n\n- d\n" +
+				strings.Repeat("code line\n", 8) + "```\nafter the block"
+			done := make(chan []string, 1)
+			go func() {
+				chunks, _ := feed(NewMessageSplitter(56, lengthFn), content, 5)
+				done <- chunks
+			}()
+			select {
+			case chunks := <-done:
+				for i, c := range chunks {
+					if lengthFn(c) > 56 {
+						t.Errorf("header of %d: chunk %d is %d long", n, i, lengthFn(c))
+					}
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("MessageSplitter did not return for a header of %d", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package channels
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// StopButtonID is the callback data or custom ID of the stop button that
+// channels attach to placeholders when placeholder.stop_button is set.
+const StopButtonID = "picoclaw:stop"
+
+// StopCommand is the content channels publish when the stop button is
+// pressed.
+const StopCommand = "/stop"
+
+// stoppedNotice replaces the placeholder of a reply that was stopped.
+const stoppedNotice = "⏹️ Stopped."
+
+// TurnStopper cancels the reply being generated for a chat and reports
+// whether one was in flight.
+type TurnStopper func(channel, chatID string) bool
+
+// PromptStopper cancels the reply being generated for the user message
+// messageID and reports whether that message was being answered.
+type PromptStopper func(channel, chatID, messageID string) bool
+
+// StopGate is injected into channels by Manager. BaseChannel.HandleMessage
+// offers every inbound message to it, so a stop request takes effect at once
+// instead of waiting behind the reply it is meant to cancel.
+type StopGate interface {
+	ResolveStop(ctx context.Context, msg bus.InboundMessage) bool
+	ResolvePromptDeleted(ctx context.Context, channel, chatID, messageID string) bool
+}
+
+// SetStopGate injects the gate that handles stop requests.
+func (c *BaseChannel) SetStopGate(g StopGate) {
+	c.stopGate = g
+}
+
+// HandleStopButton handles a press of the stop button in chatID. Unlike a
+// typed /stop, a press that comes too late is dropped rather than answered.
+func (c *BaseChannel) HandleStopButton(ctx context.Context, chatID string, sender bus.SenderInfo) bool {
+	if c.stopGate == nil || !c.IsAllowedSender(sender) {
+		return false
+	}
+	return c.stopGate.ResolveStop(ctx, bus.InboundMessage{
+		Channel:  c.name,
+		SenderID: sender.CanonicalID,
+		Sender:   sender,
+		ChatID:   chatID,
+		Content:  StopCommand,
+	})
+}
+
+// HandlePromptDeleted cancels the reply to the user message messageID when
+// the user deletes it before it is answered. Channels call it when the
+// platform reports a deleted message; it reports whether a reply was dropped.
+func (c *BaseChannel) HandlePromptDeleted(ctx context.Context, chatID, messageID string) bool {
+	if c.stopGate == nil {
+		return false
+	}
+	return c.stopGate.ResolvePromptDeleted(ctx, c.name, chatID, messageID)
+}
+
+// SetTurnStopper registers the function that cancels in-flight replies.
+func (m *Manager) SetTurnStopper(stop TurnStopper) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.turnStopper = stop
+}
+
+// ResolveStop cancels the reply in flight for msg's chat when msg is a stop
+// request. It returns false when there is nothing to stop, so the request
+// reaches the agent and is answered there.
+// Implements StopGate.
+func (m *Manager) ResolveStop(ctx context.Context, msg bus.InboundMessage) bool {
+	if !IsStopRequest(msg.Content) {
+		return false
+	}
+	m.mu.RLock()
+	stop := m.turnStopper
+	w := m.workers[msg.Channel]
+	m.mu.RUnlock()
+	if stop == nil || !stop(msg.Channel, msg.ChatID) {
+		return false
+	}
+
+	// The notice goes through the worker so preSend stops the typing
+	// indicator and turns the placeholder into the notice.
+	if w != nil {
+		select {
+		case w.queue <- bus.OutboundMessage{Channel: msg.Channel, ChatID: msg.ChatID, Content: stoppedNotice}:
+		case <-ctx.Done():
+		}
+	}
+	return true
+}
+
+// SetPromptStopper registers the function that cancels the reply to a
+// deleted prompt.
+func (m *Manager) SetPromptStopper(stop PromptStopper) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.promptStopper = stop
+}
+
+// ResolvePromptDeleted cancels the reply in flight for the deleted user
+// message messageID, then removes what was shown of it: the typing
+// indicator, the reaction and the placeholder or streamed text. Channels
+// that cannot delete messages get the stopped notice in the placeholder.
+// Implements StopGate.
+func (m *Manager) ResolvePromptDeleted(ctx context.Context, channel, chatID, messageID string) bool {
+	m.mu.RLock()
+	stop := m.promptStopper
+	ch := m.channels[channel]
+	w := m.workers[channel]
+	m.mu.RUnlock()
+	if stop == nil || !stop(channel, chatID, messageID) {
+		return false
+	}
+
+	key := channel + ":" + chatID
+	if deleter, ok := ch.(MessageDeleter); ok {
+		if v, loaded := m.typingStops.LoadAndDelete(key); loaded {
+			v.(typingEntry).stop()
+		}
+		if v, loaded := m.reactionUndos.LoadAndDelete(key); loaded {
+			v.(reactionEntry).undo()
+		}
+		v, loaded := m.placeholders.LoadAndDelete(key)
+		if !loaded || v.(placeholderEntry).id == "" {
+			return true
+		}
+		if err := deleter.DeleteMessage(ctx, chatID, v.(placeholderEntry).id); err == nil {
+			return true
+		}
+		m.placeholders.Store(key, v)
+	}
+	if w != nil {
+		select {
+		case w.queue <- bus.OutboundMessage{Channel: channel, ChatID: chatID, Content: stoppedNotice}:
+		case <-ctx.Done():
+		}
+	}
+	return true
+}
+
+// IsStopRequest reports whether content is /stop or !stop, optionally
+// addressed to the bot as in /stop@my_bot.
+func IsStopRequest(content string) bool {
+	content = strings.TrimSpace(content)
+	if content == "" || (content[0] != '/' && content[0] != '!') {
+		return false
+	}
+	name, _, _ := strings.Cut(content[1:], "@")
+	return strings.EqualFold(name, "stop")
+}
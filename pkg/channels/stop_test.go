@@ -0,0 +1,105 @@
+package channels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestIsStopRequest(t *testing.T) {
+	for content, want := range map[string]bool{
+		"/stop":         true,
+		"!stop":         true,
+		" /STOP ":       true,
+		"/stop@my_bot":  true,
+		"stop":          false,
+		"/stopwatch":    false,
+		"/stop please":  false,
+		"please /stop":  false,
+		"":              false,
+		"/":             false,
+		"!stop@bot_now": true,
+	} {
+		if got := IsStopRequest(content); got != want {
+			t.Errorf("IsStopRequest(%q) = %v, want %v", content, got, want)
+		}
+	}
+}
+
+func TestResolveStop(t *testing.T) {
+	m := newTestManager()
+	w := &channelWorker{queue: make(chan bus.OutboundMessage, 1)}
+	m.workers["test"] = w
+	msg := bus.InboundMessage{Channel: "test", ChatID: "123", Content: "/stop"}
+
+	if m.ResolveStop(context.Background(), msg) {
+		t.Fatal("expected no stop without a turn stopper")
+	}
+
+	inFlight := map[string]bool{"test:123": true}
+	var stopped []string
+	m.SetTurnStopper(func(channel, chatID string) bool {
+		stopped = append(stopped, channel+":"+chatID)
+		return inFlight[channel+":"+chatID]
+	})
+
+	other := msg
+	other.Content = "hello"
+	if m.ResolveStop(context.Background(), other) {
+		t.Fatal("ordinary messages must reach the agent")
+	}
+	if !m.ResolveStop(context.Background(), msg) {
+		t.Fatal("expected the in-flight reply to be stopped")
+	}
+	select {
+	case out := <-w.queue:
+		if out.ChatID != "123" || out.Content != stoppedNotice {
+			t.Fatalf("notice = %+v", out)
+		}
+	default:
+		t.Fatal("expected a stopped notice")
+	}
+
+	// With nothing in flight /stop falls through to the agent's command.
+	idle := msg
+	idle.ChatID = "456"
+	if m.ResolveStop(context.Background(), idle) {
+		t.Fatal("expected no stop when nothing is in flight")
+	}
+	if len(stopped) != 2 {
+		t.Fatalf("stopper calls = %v", stopped)
+	}
+}
+
+func TestResolvePromptDeleted(t *testing.T) {
+	m := newTestManager()
+	ch := &actionChannel{}
+	m.channels["test"] = ch
+	if m.ResolvePromptDeleted(context.Background(), "test", "123", "m1") {
+		t.Fatal("expected nothing to cancel without a prompt stopper")
+	}
+
+	m.SetPromptStopper(func(channel, chatID, messageID string) bool {
+		return messageID == "m1"
+	})
+	typingStopped := false
+	m.RecordTypingStop("test", "123", func() { typingStopped = true })
+	m.RecordPlaceholder("test", "123", "ph-1")
+
+	if m.ResolvePromptDeleted(context.Background(), "test", "123", "m0") {
+		t.Fatal("cancelled the reply to another message")
+	}
+	if !m.ResolvePromptDeleted(context.Background(), "test", "123", "m1") {
+		t.Fatal("expected the reply to the deleted prompt to be cancelled")
+	}
+	if deleted, _ := ch.snapshot(); len(deleted) != 1 || deleted[0] != "ph-1" {
+		t.Errorf("deleted = %q, want the placeholder", deleted)
+	}
+	if !typingStopped {
+		t.Error("typing indicator was not stopped")
+	}
+	if _, ok := m.placeholders.Load("test:123"); ok {
+		t.Error("placeholder is still recorded")
+	}
+}
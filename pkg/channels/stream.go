@@ -0,0 +1,199 @@
+package channels
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// defaultStreamEditInterval is used when a channel reports no edit interval.
+const defaultStreamEditInterval = time.Second
+
+// ReplyStream shows a reply in a chat while the agent generates it. The
+// streamed message is recorded as the chat's placeholder, so the final reply
+// is edited into it by the normal send path, and whatever does not fit in
+// one message follows as new messages.
+type ReplyStream struct {
+	m        *Manager
+	ch       Channel
+	channel  string
+	chatID   string
+	interval time.Duration
+
+	mu      sync.Mutex
+	content string
+	dirty   bool
+
+	messageID string
+	wake      chan struct{}
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// StreamingDefault reports whether replies on the named channel stream
+// unless the user turned streaming off.
+func (m *Manager) StreamingDefault(channel string) bool {
+	m.mu.RLock()
+	ch, ok := m.channels[channel]
+	m.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	sc, ok := ch.(StreamingCapable)
+	if !ok {
+		return false
+	}
+	enabled, _ := sc.StreamingDefault()
+	return enabled
+}
+
+// StreamReply starts streaming a reply to chatID. It returns nil when the
+// channel cannot stream. The caller must Close the stream before the final
+// reply is published.
+func (m *Manager) StreamReply(ctx context.Context, channel, chatID string) *ReplyStream {
+	if m.Shadowed(channel) {
+		return nil
+	}
+	m.mu.RLock()
+	ch, ok := m.channels[channel]
+	m.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	sc, ok := ch.(StreamingCapable)
+	if !ok {
+		return nil
+	}
+	if _, ok := ch.(MessageEditor); !ok {
+		return nil
+	}
+	_, interval := sc.StreamingDefault()
+	if interval <= 0 {
+		interval = defaultStreamEditInterval
+	}
+
+	s := &ReplyStream{
+		m:        m,
+		ch:       ch,
+		channel:  channel,
+		chatID:   chatID,
+		interval: interval,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go s.run(ctx)
+	return s
+}
+
+// Update replaces the text shown so far. Edits are coalesced so the channel
+// is edited at most once per edit interval.
+func (s *ReplyStream) Update(content string) {
+	s.mu.Lock()
+	s.content = content
+	s.dirty = true
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Started reports whether any text was given to the stream.
+func (s *ReplyStream) Started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.content != ""
+}
+
+// Pipe shows the text arriving on deltas until the channel is closed, and
+// returns all of it. The text replaces whatever the stream showed before,
+// so a reply retried on another model starts over.
+func (s *ReplyStream) Pipe(deltas <-chan string) string {
+	var text strings.Builder
+	for delta := range deltas {
+		text.WriteString(delta)
+		s.Update(text.String())
+	}
+	return text.String()
+}
+
+// Close stops the stream and waits for an edit in flight. Text not shown
+// yet is left to the final reply.
+func (s *ReplyStream) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+	<-s.stopped
+}
+
+func (s *ReplyStream) run(ctx context.Context) {
+	defer close(s.stopped)
+	for {
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		s.mu.Lock()
+		content, dirty := s.content, s.dirty
+		s.dirty = false
+		s.mu.Unlock()
+		if dirty && content != "" {
+			s.show(ctx, content)
+		}
+
+		select {
+		case <-time.After(s.interval):
+		case <-s.done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// show writes content into the streamed message, posting it first if the
+// chat has no placeholder to reuse.
+func (s *ReplyStream) show(ctx context.Context, content string) {
+	content = s.m.redactSecrets(content)
+	content = normalizeOutboundEmoji(s.ch, content)
+	content = formatOutboundCodeBlocks(s.ch, content)
+	if mlp, ok := s.ch.(MessageLengthProvider); ok && mlp.MaxMessageLength() > 0 {
+		lengthFn := messageLengthFunc(s.ch)
+		if lengthFn(content) > mlp.MaxMessageLength() {
+			content = SplitMessageWithLength(content, mlp.MaxMessageLength(), lengthFn)[0]
+		}
+	}
+
+	key := s.channel + ":" + s.chatID
+	if s.messageID == "" {
+		if v, ok := s.m.placeholders.Load(key); ok {
+			s.messageID = v.(placeholderEntry).id
+		}
+	}
+	if s.messageID == "" {
+		id, err := s.ch.(StreamingCapable).BeginStream(ctx, s.chatID, content)
+		if err != nil {
+			logger.DebugCF("channels", "Failed to start reply stream", map[string]any{
+				"channel": s.channel,
+				"error":   err.Error(),
+			})
+			return
+		}
+		s.messageID = id
+		s.m.RecordPlaceholder(s.channel, s.chatID, id)
+		return
+	}
+	if err := s.ch.(MessageEditor).EditMessage(ctx, s.chatID, s.messageID, content); err != nil {
+		logger.DebugCF("channels", "Failed to update reply stream", map[string]any{
+			"channel": s.channel,
+			"error":   err.Error(),
+		})
+	}
+}
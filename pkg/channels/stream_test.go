@@ -0,0 +1,146 @@
+package channels
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// streamingChannel records the streamed message and its edits.
+type streamingChannel struct {
+	mockChannel
+	mu      sync.Mutex
+	begun   []string
+	edits   []string
+	editFor []string
+}
+
+func (c *streamingChannel) StreamingDefault() (bool, time.Duration) {
+	return true, 20 * time.Millisecond
+}
+
+func (c *streamingChannel) BeginStream(_ context.Context, _, content string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.begun = append(c.begun, content)
+	return "stream-1", nil
+}
+
+func (c *streamingChannel) EditMessage(_ context.Context, _, messageID, content string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.edits = append(c.edits, content)
+	c.editFor = append(c.editFor, messageID)
+	return nil
+}
+
+func (c *streamingChannel) MaxMessageLength() int { return 10 }
+
+func TestStreamReply_EditsOneMessageThenFinalReplyReplacesIt(t *testing.T) {
+	m := newTestManager()
+	ch := &streamingChannel{mockChannel: mockChannel{
+		sendFn: func(context.Context, bus.OutboundMessage) error { return nil },
+	}}
+	m.channels["test"] = ch
+	if !m.StreamingDefault("test") || m.StreamingDefault("missing") {
+		t.Fatal("StreamingDefault does not follow the channel")
+	}
+
+	s := m.StreamReply(context.Background(), "test", "chat")
+	if s == nil {
+		t.Fatal("StreamReply returned nil for a streaming channel")
+	}
+	s.Update("Hel")
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		ch.mu.Lock()
+		begun := len(ch.begun)
+		ch.mu.Unlock()
+		if begun > 0 || time.Now().After(deadline) {
+			break
+		}
+	}
+	for _, text := range []string{"Hello", "Hello wo", "Hello world, and more"} {
+		s.Update(text)
+	}
+	time.Sleep(60 * time.Millisecond)
+	s.Close()
+
+	ch.mu.Lock()
+	if len(ch.begun) != 1 || ch.begun[0] != "Hel" {
+		t.Errorf("begun = %q, want one message with the first text", ch.begun)
+	}
+	// The three quick updates are coalesced into one edit of the first
+	// chunk: the channel fits 10 characters
+	if len(ch.edits) != 1 || ch.edits[0] != "Hello" && ch.edits[0] != "Hello worl" {
+		t.Errorf("edits = %q", ch.edits)
+	}
+	ch.mu.Unlock()
+
+	// The final reply goes through the placeholder path
+	w := &channelWorker{ch: ch, limiter: rate.NewLimiter(rate.Inf, 1)}
+	m.sendWithRetry(context.Background(), "test", w, bus.OutboundMessage{Channel: "test", ChatID: "chat", Content: "Done"})
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if last := ch.edits[len(ch.edits)-1]; last != "Done" || ch.editFor[len(ch.editFor)-1] != "stream-1" {
+		t.Errorf("final edit = %q of %q", last, ch.editFor[len(ch.editFor)-1])
+	}
+	if len(ch.sentMessages) != 0 {
+		t.Errorf("final reply was sent as a new message: %+v", ch.sentMessages)
+	}
+}
+
+func TestStreamReply_ReusesPlaceholder(t *testing.T) {
+	m := newTestManager()
+	ch := &streamingChannel{}
+	m.channels["test"] = ch
+	m.RecordPlaceholder("test", "chat", "ph-1")
+
+	s := m.StreamReply(context.Background(), "test", "chat")
+	s.Update("partial")
+	time.Sleep(40 * time.Millisecond)
+	s.Close()
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if len(ch.begun) != 0 || len(ch.editFor) != 1 || ch.editFor[0] != "ph-1" {
+		t.Errorf("begun = %q, edits of %q; want the placeholder edited", ch.begun, ch.editFor)
+	}
+}
+
+func TestStreamReply_UnsupportedChannel(t *testing.T) {
+	m := newTestManager()
+	m.channels["plain"] = &mockChannel{}
+	if s := m.StreamReply(context.Background(), "plain", "chat"); s != nil {
+		t.Error("StreamReply returned a stream for a channel that cannot stream")
+	}
+}
+
+func TestReplyStream_Pipe(t *testing.T) {
+	m := newTestManager()
+	ch := &streamingChannel{}
+	m.channels["test"] = ch
+
+	s := m.StreamReply(context.Background(), "test", "chat")
+	s.Update("stale text")
+	deltas := make(chan string, 3)
+	deltas <- "Hi"
+	deltas <- " there"
+	close(deltas)
+	if got := s.Pipe(deltas); got != "Hi there" {
+		t.Errorf("Pipe() = %q, want Hi there", got)
+	}
+	time.Sleep(40 * time.Millisecond)
+	s.Close()
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	shown := append(append([]string(nil), ch.begun...), ch.edits...)
+	if len(shown) == 0 || shown[len(shown)-1] != "Hi there" {
+		t.Errorf("shown = %q, want the piped text last", shown)
+	}
+}
@@ -2,6 +2,9 @@ package telegram
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +13,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/mymmrac/telego"
@@ -20,23 +24,22 @@ import (
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/commands"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/format"
 	"github.com/sipeed/picoclaw/pkg/identity"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
-var (
-	reHeading    = regexp.MustCompile(`^#{1,6}\s+(.+)$`)
-	reBlockquote = regexp.MustCompile(`^>\s*(.*)$`)
-	reLink       = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
-	reBoldStar   = regexp.MustCompile(`\*\*(.+?)\*\*`)
-	reBoldUnder  = regexp.MustCompile(`__(.+?)__`)
-	reItalic     = regexp.MustCompile(`_([^_]+)_`)
-	reStrike     = regexp.MustCompile(`~~(.+?)~~`)
-	reListItem   = regexp.MustCompile(`^[-*]\s+`)
-	reCodeBlock  = regexp.MustCompile("```[\\w]*\\n?([\\s\\S]*?)```")
-	reInlineCode = regexp.MustCompile("`([^`]+)`")
+const (
+	// telegramMessageLimit is the Bot API limit for message text, in UTF-16 code units.
+	telegramMessageLimit = 4096
+	// defaultMaxMessageLength leaves headroom below telegramMessageLimit for HTML expansion.
+	defaultMaxMessageLength = 4000
+	// telegramCaptionLimit is the Bot API limit for media captions.
+	telegramCaptionLimit = 1024
+	// maxWebhookBodySize bounds a single webhook update.
+	maxWebhookBodySize = 1 << 20
 )
 
 type TelegramChannel struct {
@@ -47,11 +50,19 @@ type TelegramChannel struct {
 	chatIDs map[string]int64
 	ctx     context.Context
 	cancel  context.CancelFunc
+	webhook atomic.Pointer[webhookReceiver]
 
 	registerFunc     func(context.Context, []commands.Definition) error
 	commandRegCancel context.CancelFunc
 }
 
+// webhookReceiver accepts updates posted with secret, the token registered
+// with setWebhook.
+type webhookReceiver struct {
+	handler telego.WebhookHandler
+	secret  string
+}
+
 func NewTelegramChannel(cfg *config.Config, bus *bus.MessageBus) (*TelegramChannel, error) {
 	var opts []telego.BotOption
 	telegramCfg := cfg.Channels.Telegram
@@ -85,14 +96,22 @@ func NewTelegramChannel(cfg *config.Config, bus *bus.MessageBus) (*TelegramChann
 		return nil, fmt.Errorf("failed to create telegram bot: %w", err)
 	}
 
+	maxLen := defaultMaxMessageLength
+	if telegramCfg.MaxMessageLength > 0 {
+		maxLen = min(telegramCfg.MaxMessageLength, telegramMessageLimit)
+	}
+
 	base := channels.NewBaseChannel(
 		"telegram",
 		telegramCfg,
 		bus,
 		telegramCfg.AllowFrom,
-		channels.WithMaxMessageLength(4000),
+		channels.WithMaxMessageLength(maxLen),
+		channels.WithLengthFunc(channels.UTF16Length),
+		channels.WithLengthUnit(telegramCfg.LengthUnit),
 		channels.WithGroupTrigger(telegramCfg.GroupTrigger),
 		channels.WithReasoningChannelID(telegramCfg.ReasoningChannelID),
+		channels.WithLongOutput(telegramCfg.LongOutput),
 	)
 
 	return &TelegramChannel{
@@ -104,16 +123,27 @@ func NewTelegramChannel(cfg *config.Config, bus *bus.MessageBus) (*TelegramChann
 }
 
 func (c *TelegramChannel) Start(ctx context.Context) error {
-	logger.InfoC("telegram", "Starting Telegram bot (polling mode)...")
+	mode := "polling"
+	if c.config.Channels.Telegram.WebhookURL != "" {
+		mode = "webhook"
+	}
+	logger.InfoCF("telegram", "Starting Telegram bot", map[string]any{"mode": mode})
 
 	c.ctx, c.cancel = context.WithCancel(ctx)
 
-	updates, err := c.bot.UpdatesViaLongPolling(c.ctx, &telego.GetUpdatesParams{
-		Timeout: 30,
-	})
+	params := &telego.GetUpdatesParams{Timeout: 30}
+	if c.config.Feedback.Enabled {
+		// Reactions are only delivered when asked for explicitly, which
+		// replaces the default list of update types.
+		params.AllowedUpdates = []string{
+			telego.MessageUpdates, telego.EditedMessageUpdates,
+			telego.CallbackQueryUpdates, telego.MessageReactionUpdates,
+		}
+	}
+	updates, err := c.updates(params)
 	if err != nil {
 		c.cancel()
-		return fmt.Errorf("failed to start long polling: %w", err)
+		return err
 	}
 
 	bh, err := th.NewBotHandler(c.bot, updates)
@@ -126,6 +156,16 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
 		return c.handleMessage(ctx, &message)
 	}, th.AnyMessage())
+	bh.HandleEditedMessage(func(ctx *th.Context, message telego.Message) error {
+		return c.handleMessage(ctx, &message)
+	}, th.AnyEditedMessage())
+	bh.HandleCallbackQuery(func(ctx *th.Context, query telego.CallbackQuery) error {
+		return c.handleStopButton(ctx, query)
+	}, th.CallbackDataEqual(channels.StopButtonID))
+	bh.HandleMessageReaction(func(ctx *th.Context, reaction telego.MessageReactionUpdated) error {
+		c.handleReaction(reaction)
+		return nil
+	}, th.AnyMessageReaction())
 
 	c.SetRunning(true)
 	logger.InfoCF("telegram", "Telegram bot connected", map[string]any{
@@ -145,9 +185,96 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 	return nil
 }
 
+// updates starts receiving updates by webhook when a webhook URL is set and
+// by long polling otherwise.
+func (c *TelegramChannel) updates(params *telego.GetUpdatesParams) (<-chan telego.Update, error) {
+	tgCfg := c.config.Channels.Telegram
+	if tgCfg.WebhookURL == "" {
+		// Telegram refuses getUpdates while a webhook is set, e.g. one left
+		// behind after switching back from webhook mode.
+		if info, err := c.bot.GetWebhookInfo(c.ctx); err == nil && info.URL != "" {
+			if err := c.bot.DeleteWebhook(c.ctx, nil); err != nil {
+				return nil, fmt.Errorf("failed to remove webhook before polling: %w", err)
+			}
+		}
+		updates, err := c.bot.UpdatesViaLongPolling(c.ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start long polling: %w", err)
+		}
+		return updates, nil
+	}
+
+	// Without a secret anyone who finds the URL could post updates, so one
+	// is generated when none is configured.
+	secret := tgCfg.WebhookSecret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		secret = hex.EncodeToString(buf)
+	}
+	updates, err := c.bot.UpdatesViaWebhook(c.ctx, func(handler telego.WebhookHandler) error {
+		c.webhook.Store(&webhookReceiver{handler: handler, secret: secret})
+		return nil
+	}, telego.WithWebhookSet(c.ctx, &telego.SetWebhookParams{
+		URL:            tgCfg.WebhookURL,
+		SecretToken:    secret,
+		AllowedUpdates: params.AllowedUpdates,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start webhook: %w", err)
+	}
+	return updates, nil
+}
+
+// WebhookPath returns the path for registering on the shared HTTP server.
+func (c *TelegramChannel) WebhookPath() string {
+	if p := c.config.Channels.Telegram.WebhookPath; p != "" {
+		return p
+	}
+	return "/webhook/telegram"
+}
+
+// ServeHTTP implements http.Handler for the shared HTTP server. Updates are
+// only accepted in webhook mode, with the secret token set for the webhook.
+func (c *TelegramChannel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	receiver := c.webhook.Load()
+	if receiver == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	got := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(receiver.secret)) != 1 {
+		logger.WarnC("telegram", "Invalid webhook secret token")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize+1))
+	if err != nil || int64(len(body)) > maxWebhookBodySize {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	// Updates are handled after the request returns
+	if err := receiver.handler(context.WithoutCancel(r.Context()), body); err != nil {
+		logger.ErrorCF("telegram", "Failed to accept webhook update", map[string]any{
+			"error": err.Error(),
+		})
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 func (c *TelegramChannel) Stop(ctx context.Context) error {
 	logger.InfoC("telegram", "Stopping Telegram bot...")
 	c.SetRunning(false)
+	c.webhook.Store(nil)
 
 	// Stop the bot handler
 	if c.bh != nil {
@@ -179,9 +306,10 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		return nil
 	}
 
-	// The Manager already splits messages to ≤4000 chars (WithMaxMessageLength),
-	// so msg.Content is guaranteed to be within that limit. We still need to
-	// check if HTML expansion pushes it beyond Telegram's 4096-char API limit.
+	// The Manager already splits messages to the configured max length
+	// (WithMaxMessageLength), so msg.Content is guaranteed to be within that
+	// limit. We still need to check if HTML expansion pushes it beyond
+	// Telegram's 4096-unit API limit.
 	replyToID := msg.ReplyToMessageID
 	queue := []string{msg.Content}
 	for len(queue) > 0 {
@@ -190,27 +318,34 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 
 		htmlContent := markdownToTelegramHTML(chunk)
 
-		if len([]rune(htmlContent)) > 4096 {
+		// Telegram counts message length in UTF-16 code units.
+		if channels.UTF16Length(htmlContent) > telegramMessageLimit {
 			runeChunk := []rune(chunk)
-			ratio := float64(len(runeChunk)) / float64(len([]rune(htmlContent)))
-			smallerLen := int(float64(4096) * ratio * 0.95) // 5% safety margin
+			chunkLen := channels.UTF16Length(chunk)
+			ratio := float64(chunkLen) / float64(channels.UTF16Length(htmlContent))
+			smallerLen := int(float64(telegramMessageLimit) * ratio * 0.95) // 5% safety margin
 
 			// Guarantee progress: if estimated length is >= chunk length, force it smaller
-			if smallerLen >= len(runeChunk) {
+			if smallerLen >= chunkLen {
+				smallerLen = chunkLen - 1
+			}
+			if smallerLen > len(runeChunk)-1 {
 				smallerLen = len(runeChunk) - 1
 			}
 
 			if smallerLen <= 0 {
-				if err := c.sendHTMLChunk(ctx, chatID, threadID, htmlContent, chunk, replyToID); err != nil {
+				sentID, err := c.sendHTMLChunk(ctx, chatID, threadID, htmlContent, chunk, replyToID)
+				if err != nil {
 					return err
 				}
+				c.RecordReply(msg.ChatID, sentID)
 				replyToID = ""
 				continue
 			}
 
 			// Use the estimated smaller length as a guide for SplitMessage.
 			// SplitMessage will find natural break points (newlines/spaces) and respect code blocks.
-			subChunks := channels.SplitMessage(chunk, smallerLen)
+			subChunks := channels.SplitMessageWithLength(chunk, smallerLen, channels.UTF16Length)
 
 			// Safety fallback: If SplitMessage failed to shorten the chunk, force a manual hard split.
 			if len(subChunks) == 1 && subChunks[0] == chunk {
@@ -232,9 +367,11 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 			continue
 		}
 
-		if err := c.sendHTMLChunk(ctx, chatID, threadID, htmlContent, chunk, replyToID); err != nil {
+		sentID, err := c.sendHTMLChunk(ctx, chatID, threadID, htmlContent, chunk, replyToID)
+		if err != nil {
 			return err
 		}
+		c.RecordReply(msg.ChatID, sentID)
 		// Only the first chunk should be a reply; subsequent chunks are normal messages.
 		replyToID = ""
 	}
@@ -244,9 +381,10 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 
 // sendHTMLChunk sends a single HTML message, falling back to the original
 // markdown as plain text on parse failure so users never see raw HTML tags.
+// It returns the ID of the sent message.
 func (c *TelegramChannel) sendHTMLChunk(
 	ctx context.Context, chatID int64, threadID int, htmlContent, mdFallback string, replyToID string,
-) error {
+) (string, error) {
 	tgMsg := tu.Message(tu.ID(chatID), htmlContent)
 	tgMsg.ParseMode = telego.ModeHTML
 	tgMsg.MessageThreadID = threadID
@@ -259,17 +397,21 @@ func (c *TelegramChannel) sendHTMLChunk(
 		}
 	}
 
-	if _, err := c.bot.SendMessage(ctx, tgMsg); err != nil {
+	sent, err := c.bot.SendMessage(ctx, tgMsg)
+	if err != nil {
 		logger.ErrorCF("telegram", "HTML parse failed, falling back to plain text", map[string]any{
 			"error": err.Error(),
 		})
 		tgMsg.Text = mdFallback
 		tgMsg.ParseMode = ""
-		if _, err = c.bot.SendMessage(ctx, tgMsg); err != nil {
-			return fmt.Errorf("telegram send: %w", channels.ErrTemporary)
+		if sent, err = c.bot.SendMessage(ctx, tgMsg); err != nil {
+			return "", fmt.Errorf("telegram send: %w", channels.ErrTemporary)
 		}
 	}
-	return nil
+	if sent == nil {
+		return "", nil
+	}
+	return strconv.Itoa(sent.MessageID), nil
 }
 
 // StartTyping implements channels.TypingCapable.
@@ -345,6 +487,11 @@ func (c *TelegramChannel) SendPlaceholder(ctx context.Context, chatID string) (s
 
 	phMsg := tu.Message(tu.ID(cid), text)
 	phMsg.MessageThreadID = threadID
+	if phCfg.StopButton {
+		phMsg.ReplyMarkup = tu.InlineKeyboard(tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton("⏹ Stop").WithCallbackData(channels.StopButtonID),
+		))
+	}
 	pMsg, err := c.bot.SendMessage(ctx, phMsg)
 	if err != nil {
 		return "", err
@@ -388,13 +535,17 @@ func (c *TelegramChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMe
 			continue
 		}
 
+		// Captions have a much lower limit than messages; long ones are sent
+		// as a follow-up text message instead of being rejected.
+		caption, overflow := splitCaption(part.Caption)
+
 		switch part.Type {
 		case "image":
 			params := &telego.SendPhotoParams{
 				ChatID:          tu.ID(chatID),
 				MessageThreadID: threadID,
 				Photo:           telego.InputFile{File: file},
-				Caption:         part.Caption,
+				Caption:         caption,
 			}
 			_, err = c.bot.SendPhoto(ctx, params)
 			if err != nil && strings.Contains(err.Error(), "PHOTO_INVALID_DIMENSIONS") {
@@ -407,7 +558,7 @@ func (c *TelegramChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMe
 					ChatID:          tu.ID(chatID),
 					MessageThreadID: threadID,
 					Document:        telego.InputFile{File: file},
-					Caption:         part.Caption,
+					Caption:         caption,
 				}
 				_, err = c.bot.SendDocument(ctx, docParams)
 			}
@@ -416,7 +567,7 @@ func (c *TelegramChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMe
 				ChatID:          tu.ID(chatID),
 				MessageThreadID: threadID,
 				Audio:           telego.InputFile{File: file},
-				Caption:         part.Caption,
+				Caption:         caption,
 			}
 			_, err = c.bot.SendAudio(ctx, params)
 		case "video":
@@ -424,7 +575,7 @@ func (c *TelegramChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMe
 				ChatID:          tu.ID(chatID),
 				MessageThreadID: threadID,
 				Video:           telego.InputFile{File: file},
-				Caption:         part.Caption,
+				Caption:         caption,
 			}
 			_, err = c.bot.SendVideo(ctx, params)
 		default: // "file" or unknown types
@@ -432,7 +583,7 @@ func (c *TelegramChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMe
 				ChatID:          tu.ID(chatID),
 				MessageThreadID: threadID,
 				Document:        telego.InputFile{File: file},
-				Caption:         part.Caption,
+				Caption:         caption,
 			}
 			_, err = c.bot.SendDocument(ctx, params)
 		}
@@ -446,11 +597,30 @@ func (c *TelegramChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMe
 			})
 			return fmt.Errorf("telegram send media: %w", channels.ErrTemporary)
 		}
+
+		if overflow != "" {
+			if err := c.Send(ctx, bus.OutboundMessage{
+				Channel: msg.Channel,
+				ChatID:  msg.ChatID,
+				Content: overflow,
+			}); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// splitCaption returns the caption to attach to a media message and any text
+// that must be sent separately because it exceeds telegramCaptionLimit.
+func splitCaption(caption string) (attached, overflow string) {
+	if channels.UTF16Length(caption) <= telegramCaptionLimit {
+		return caption, ""
+	}
+	return "", caption
+}
+
 func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Message) error {
 	if message == nil {
 		return fmt.Errorf("message is nil")
@@ -590,7 +760,7 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 		"sender_id": sender.CanonicalID,
 		"chat_id":   compositeChatID,
 		"thread_id": threadID,
-		"preview":   utils.Truncate(content, 50),
+		"preview":   c.LogPreview(compositeChatID, platformID, content, 50, sender),
 	})
 
 	peerKind := "direct"
@@ -609,6 +779,9 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 		"first_name": user.FirstName,
 		"is_group":   fmt.Sprintf("%t", message.Chat.Type != "private"),
 	}
+	if message.EditDate != 0 {
+		metadata[channels.MetadataKeyEdited] = "true"
+	}
 
 	// Set parent_peer metadata for per-topic agent binding.
 	if message.Chat.IsForum && threadID != 0 {
@@ -629,6 +802,73 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 	return nil
 }
 
+// handleStopButton stops the reply whose placeholder carries the pressed
+// button. Editing the placeholder into the reply removes the button.
+func (c *TelegramChannel) handleStopButton(ctx context.Context, query telego.CallbackQuery) error {
+	if err := c.bot.AnswerCallbackQuery(ctx, tu.CallbackQuery(query.ID)); err != nil {
+		logger.DebugCF("telegram", "Failed to answer callback query", map[string]any{
+			"error": err.Error(),
+		})
+	}
+	if query.Message == nil {
+		return nil
+	}
+	message := query.Message.Message()
+	if message == nil {
+		return nil
+	}
+
+	chatID := fmt.Sprintf("%d", message.Chat.ID)
+	if message.Chat.IsForum && message.MessageThreadID != 0 {
+		chatID = fmt.Sprintf("%d/%d", message.Chat.ID, message.MessageThreadID)
+	}
+	platformID := fmt.Sprintf("%d", query.From.ID)
+	c.HandleStopButton(ctx, chatID, bus.SenderInfo{
+		Platform:    "telegram",
+		PlatformID:  platformID,
+		CanonicalID: identity.BuildCanonicalID("telegram", platformID),
+		Username:    query.From.Username,
+		DisplayName: query.From.FirstName,
+	})
+	return nil
+}
+
+// handleReaction reports a reaction added to one of the bot's replies as
+// feedback. Telegram does not say which forum topic the message is in, so
+// reactions in topics cannot be matched to a reply.
+func (c *TelegramChannel) handleReaction(reaction telego.MessageReactionUpdated) {
+	if reaction.User == nil {
+		return // anonymous admins react as the chat
+	}
+	old := make(map[string]bool, len(reaction.OldReaction))
+	for _, r := range reaction.OldReaction {
+		if e, ok := r.(*telego.ReactionTypeEmoji); ok {
+			old[e.Emoji] = true
+		}
+	}
+	platformID := fmt.Sprintf("%d", reaction.User.ID)
+	sender := bus.SenderInfo{
+		Platform:    "telegram",
+		PlatformID:  platformID,
+		CanonicalID: identity.BuildCanonicalID("telegram", platformID),
+		Username:    reaction.User.Username,
+		DisplayName: reaction.User.FirstName,
+	}
+	for _, r := range reaction.NewReaction {
+		if e, ok := r.(*telego.ReactionTypeEmoji); ok && !old[e.Emoji] {
+			if c.HandleReaction(fmt.Sprintf("%d", reaction.Chat.ID), fmt.Sprintf("%d", reaction.MessageID), e.Emoji, sender) {
+				return
+			}
+		}
+	}
+}
+
+// SupportsReplyReactions implements channels.ReplyReactionCapable. Telegram
+// only delivers reactions when feedback is enabled.
+func (c *TelegramChannel) SupportsReplyReactions() bool {
+	return c.config.Feedback.Enabled
+}
+
 func (c *TelegramChannel) downloadPhoto(ctx context.Context, fileID string) string {
 	file, err := c.bot.GetFile(ctx, &telego.GetFileParams{FileID: fileID})
 	if err != nil {
@@ -687,109 +927,10 @@ func parseTelegramChatID(chatID string) (int64, int, error) {
 	return cid, tid, nil
 }
 
+// markdownToTelegramHTML converts the model's Markdown to the HTML subset
+// Telegram renders.
 func markdownToTelegramHTML(text string) string {
-	if text == "" {
-		return ""
-	}
-
-	codeBlocks := extractCodeBlocks(text)
-	text = codeBlocks.text
-
-	inlineCodes := extractInlineCodes(text)
-	text = inlineCodes.text
-
-	text = reHeading.ReplaceAllString(text, "$1")
-
-	text = reBlockquote.ReplaceAllString(text, "$1")
-
-	text = escapeHTML(text)
-
-	text = reLink.ReplaceAllString(text, `<a href="$2">$1</a>`)
-
-	text = reBoldStar.ReplaceAllString(text, "<b>$1</b>")
-
-	text = reBoldUnder.ReplaceAllString(text, "<b>$1</b>")
-
-	text = reItalic.ReplaceAllStringFunc(text, func(s string) string {
-		match := reItalic.FindStringSubmatch(s)
-		if len(match) < 2 {
-			return s
-		}
-		return "<i>" + match[1] + "</i>"
-	})
-
-	text = reStrike.ReplaceAllString(text, "<s>$1</s>")
-
-	text = reListItem.ReplaceAllString(text, "• ")
-
-	for i, code := range inlineCodes.codes {
-		escaped := escapeHTML(code)
-		text = strings.ReplaceAll(text, fmt.Sprintf("\x00IC%d\x00", i), fmt.Sprintf("<code>%s</code>", escaped))
-	}
-
-	for i, code := range codeBlocks.codes {
-		escaped := escapeHTML(code)
-		text = strings.ReplaceAll(
-			text,
-			fmt.Sprintf("\x00CB%d\x00", i),
-			fmt.Sprintf("<pre><code>%s</code></pre>", escaped),
-		)
-	}
-
-	return text
-}
-
-type codeBlockMatch struct {
-	text  string
-	codes []string
-}
-
-func extractCodeBlocks(text string) codeBlockMatch {
-	matches := reCodeBlock.FindAllStringSubmatch(text, -1)
-
-	codes := make([]string, 0, len(matches))
-	for _, match := range matches {
-		codes = append(codes, match[1])
-	}
-
-	i := 0
-	text = reCodeBlock.ReplaceAllStringFunc(text, func(m string) string {
-		placeholder := fmt.Sprintf("\x00CB%d\x00", i)
-		i++
-		return placeholder
-	})
-
-	return codeBlockMatch{text: text, codes: codes}
-}
-
-type inlineCodeMatch struct {
-	text  string
-	codes []string
-}
-
-func extractInlineCodes(text string) inlineCodeMatch {
-	matches := reInlineCode.FindAllStringSubmatch(text, -1)
-
-	codes := make([]string, 0, len(matches))
-	for _, match := range matches {
-		codes = append(codes, match[1])
-	}
-
-	i := 0
-	text = reInlineCode.ReplaceAllStringFunc(text, func(m string) string {
-		placeholder := fmt.Sprintf("\x00IC%d\x00", i)
-		i++
-		return placeholder
-	})
-
-	return inlineCodeMatch{text: text, codes: codes}
-}
-
-func escapeHTML(text string) string {
-	text = strings.ReplaceAll(text, "&", "&amp;")
-	text = strings.ReplaceAll(text, "<", "&lt;")
-	text = strings.ReplaceAll(text, ">", "&gt;")
-	return text
+	return format.Render(text, format.TelegramHTML)
 }
 
 // isBotMentioned checks if the bot is mentioned in the message via entities.
@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mymmrac/telego"
 	ta "github.com/mymmrac/telego/telegoapi"
@@ -17,6 +20,7 @@ import (
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/media"
 )
 
@@ -220,6 +224,48 @@ func TestSendMedia_ImageNonDimensionErrorDoesNotFallback(t *testing.T) {
 	assert.NotContains(t, caller.calls[0].URL, "sendDocument")
 }
 
+func TestSendMedia_LongCaptionSentAsFollowUpMessage(t *testing.T) {
+	constructor := &multipartRecordingConstructor{}
+	caller := &stubCaller{
+		callFn: func(ctx context.Context, url string, data *ta.RequestData) (*ta.Response, error) {
+			return successResponse(t), nil
+		},
+	}
+	ch := newTestChannelWithConstructor(t, caller, constructor)
+
+	store := media.NewFileMediaStore()
+	ch.SetMediaStore(store)
+
+	localPath := filepath.Join(t.TempDir(), "report.txt")
+	require.NoError(t, os.WriteFile(localPath, []byte("data"), 0o644))
+	ref, err := store.Store(localPath, media.MediaMeta{Filename: "report.txt"}, "scope-1")
+	require.NoError(t, err)
+
+	longCaption := strings.Repeat("a", telegramCaptionLimit+1)
+	err = ch.SendMedia(context.Background(), bus.OutboundMediaMessage{
+		ChatID: "12345",
+		Parts:  []bus.MediaPart{{Type: "file", Ref: ref, Caption: longCaption}},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, caller.calls, 2)
+	assert.Contains(t, caller.calls[0].URL, "sendDocument")
+	assert.Empty(t, constructor.calls[0].Parameters["caption"])
+	assert.Contains(t, caller.calls[1].URL, "sendMessage")
+}
+
+func TestSplitCaption(t *testing.T) {
+	attached, overflow := splitCaption("short")
+	assert.Equal(t, "short", attached)
+	assert.Empty(t, overflow)
+
+	// Emoji count as two UTF-16 units, so this exceeds the limit in Telegram's terms.
+	long := strings.Repeat("👍", telegramCaptionLimit/2+1)
+	attached, overflow = splitCaption(long)
+	assert.Empty(t, attached)
+	assert.Equal(t, long, overflow)
+}
+
 func TestSend_EmptyContent(t *testing.T) {
 	caller := &stubCaller{
 		callFn: func(ctx context.Context, url string, data *ta.RequestData) (*ta.Response, error) {
@@ -639,3 +685,84 @@ func TestHandleMessage_ReplyThread_NonForum_NoIsolation(t *testing.T) {
 	assert.Empty(t, inbound.Metadata["parent_peer_kind"])
 	assert.Empty(t, inbound.Metadata["parent_peer_id"])
 }
+
+func TestWebhook_DeliversUpdatesWithSecret(t *testing.T) {
+	caller := &stubCaller{
+		callFn: func(ctx context.Context, url string, data *ta.RequestData) (*ta.Response, error) {
+			require.Contains(t, url, "setWebhook")
+			assert.Contains(t, string(data.BodyRaw), `"secret_token":"s3cret"`)
+			return &ta.Response{Ok: true, Result: json.RawMessage("true")}, nil
+		},
+	}
+	ch := newTestChannel(t, caller)
+	ch.config = &config.Config{}
+	ch.config.Channels.Telegram.WebhookURL = "https://bot.example.com/webhook/telegram"
+	ch.config.Channels.Telegram.WebhookSecret = "s3cret"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch.ctx = ctx
+
+	updates, err := ch.updates(&telego.GetUpdatesParams{})
+	require.NoError(t, err)
+	assert.Equal(t, "/webhook/telegram", ch.WebhookPath())
+
+	post := func(secret string) int {
+		req := httptest.NewRequest(http.MethodPost, ch.WebhookPath(), strings.NewReader(`{"update_id": 7}`))
+		req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+		rec := httptest.NewRecorder()
+		ch.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusForbidden, post("wrong"))
+	assert.Equal(t, http.StatusOK, post("s3cret"))
+
+	select {
+	case u := <-updates:
+		assert.Equal(t, 7, u.UpdateID)
+	case <-time.After(time.Second):
+		t.Fatal("update was not delivered")
+	}
+}
+
+func TestWebhook_GeneratesSecretWhenNoneConfigured(t *testing.T) {
+	var registered string
+	caller := &stubCaller{
+		callFn: func(ctx context.Context, url string, data *ta.RequestData) (*ta.Response, error) {
+			var params telego.SetWebhookParams
+			require.NoError(t, json.Unmarshal(data.BodyRaw, &params))
+			registered = params.SecretToken
+			return &ta.Response{Ok: true, Result: json.RawMessage("true")}, nil
+		},
+	}
+	ch := newTestChannel(t, caller)
+	ch.config = &config.Config{}
+	ch.config.Channels.Telegram.WebhookURL = "https://bot.example.com/webhook/telegram"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch.ctx = ctx
+
+	_, err := ch.updates(&telego.GetUpdatesParams{})
+	require.NoError(t, err)
+	require.Len(t, registered, 64)
+
+	post := func(secret string) int {
+		req := httptest.NewRequest(http.MethodPost, ch.WebhookPath(), strings.NewReader(`{"update_id": 7}`))
+		if secret != "" {
+			req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+		}
+		rec := httptest.NewRecorder()
+		ch.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusForbidden, post(""))
+	assert.Equal(t, http.StatusOK, post(registered))
+}
+
+func TestWebhook_NotFoundWhenPolling(t *testing.T) {
+	ch := newTestChannel(t, &stubCaller{})
+	ch.config = &config.Config{}
+
+	rec := httptest.NewRecorder()
+	ch.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ch.WebhookPath(), strings.NewReader("{}")))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
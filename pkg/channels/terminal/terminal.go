@@ -0,0 +1,119 @@
+// Package terminal is a channel that talks to the agent from the local
+// terminal. Unlike `picoclaw agent`, messages go through the channel
+// manager like those of any chat platform, so commands, splitting,
+// approvals, and guardrails behave as they would on Discord.
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+)
+
+// Name is the channel name messages are routed under.
+const Name = "terminal"
+
+// Options configures a terminal channel.
+type Options struct {
+	User      string    // sender ID of everything typed
+	ChatID    string    // defaults to User
+	MaxLength int       // split replies like a platform with this limit; 0 never splits
+	Out       io.Writer // where replies are printed
+	Prefix    string    // printed before each reply
+}
+
+// TerminalChannel prints replies to a writer and feeds typed lines to the
+// pipeline as messages from a single user.
+type TerminalChannel struct {
+	*channels.BaseChannel
+	opts Options
+
+	mu      sync.Mutex
+	nextID  atomic.Int64
+	replies chan struct{}
+}
+
+// NewTerminalChannel returns a channel that is not registered with any
+// manager yet; callers pass it to Manager.RegisterChannel.
+func NewTerminalChannel(opts Options, msgBus *bus.MessageBus) *TerminalChannel {
+	if opts.User == "" {
+		opts.User = "local"
+	}
+	if opts.ChatID == "" {
+		opts.ChatID = opts.User
+	}
+	base := channels.NewBaseChannel(Name, opts, msgBus, nil,
+		channels.WithMaxMessageLength(opts.MaxLength))
+	return &TerminalChannel{BaseChannel: base, opts: opts, replies: make(chan struct{}, 1)}
+}
+
+func (c *TerminalChannel) Start(ctx context.Context) error {
+	c.SetRunning(true)
+	return nil
+}
+
+func (c *TerminalChannel) Stop(ctx context.Context) error {
+	c.SetRunning(false)
+	return nil
+}
+
+// Send prints the message; a split reply arrives as one Send per chunk.
+func (c *TerminalChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	c.print(msg.Content)
+	c.RecordReply(msg.ChatID, strconv.FormatInt(c.nextID.Add(1), 10))
+	return nil
+}
+
+// SendMedia prints the attachments, which cannot be shown in a terminal.
+func (c *TerminalChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	var b strings.Builder
+	for _, part := range msg.Parts {
+		name := part.Filename
+		if name == "" {
+			name = part.Ref
+		}
+		fmt.Fprintf(&b, "[%s: %s]", part.Type, name)
+		if part.Caption != "" {
+			b.WriteString(" " + part.Caption)
+		}
+		b.WriteString("\n")
+	}
+	c.print(strings.TrimSuffix(b.String(), "\n"))
+	return nil
+}
+
+// Receive passes a typed line to the pipeline.
+func (c *TerminalChannel) Receive(ctx context.Context, text string) {
+	id := strconv.FormatInt(c.nextID.Add(1), 10)
+	peer := bus.Peer{Kind: "direct", ID: c.opts.User}
+	c.HandleMessage(ctx, peer, id, c.opts.User, c.opts.ChatID, text, nil, nil)
+}
+
+// Replies signals after each printed message, so non-interactive callers
+// can wait for an answer before sending the next line.
+func (c *TerminalChannel) Replies() <-chan struct{} {
+	return c.replies
+}
+
+func (c *TerminalChannel) print(content string) {
+	c.mu.Lock()
+	fmt.Fprintf(c.opts.Out, "\n%s%s\n\n", c.opts.Prefix, content)
+	c.mu.Unlock()
+	select {
+	case c.replies <- struct{}{}:
+	default:
+	}
+}
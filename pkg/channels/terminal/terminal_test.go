@@ -0,0 +1,49 @@
+package terminal
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestTerminalChannel_ReceiveAndSend(t *testing.T) {
+	mb := bus.NewMessageBus()
+	defer mb.Close()
+	var out bytes.Buffer
+	ch := NewTerminalChannel(Options{User: "dev", MaxLength: 2000, Out: &out, Prefix: "> "}, mb)
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := ch.MaxMessageLength(); got != 2000 {
+		t.Errorf("MaxMessageLength() = %d, want 2000", got)
+	}
+
+	ch.Receive(context.Background(), "hello")
+	select {
+	case in := <-mb.InboundChan():
+		if in.Channel != Name || in.SenderID != "dev" || in.ChatID != "dev" || in.Content != "hello" {
+			t.Errorf("inbound = %+v", in)
+		}
+		if in.Peer.Kind != "direct" {
+			t.Errorf("peer = %+v, want a direct chat", in.Peer)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message was not published")
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "dev", Content: "hi there"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "> hi there") {
+		t.Errorf("output = %q", out.String())
+	}
+	select {
+	case <-ch.Replies():
+	default:
+		t.Error("Send did not signal a reply")
+	}
+}
@@ -21,7 +21,6 @@ import (
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/identity"
 	"github.com/sipeed/picoclaw/pkg/logger"
-	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 const (
@@ -204,7 +203,7 @@ func (c *WeComAppChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 
 	logger.DebugCF("wecom_app", "Sending message", map[string]any{
 		"chat_id": msg.ChatID,
-		"preview": utils.Truncate(msg.Content, 100),
+		"preview": c.LogPreview(msg.ChatID, "", msg.Content, 100),
 	})
 
 	return c.sendTextMessage(ctx, accessToken, msg.ChatID, msg.Content)
@@ -653,7 +652,7 @@ func (c *WeComAppChannel) processMessage(ctx context.Context, msg WeComXMLMessag
 	logger.DebugCF("wecom_app", "Received message", map[string]any{
 		"sender_id": senderID,
 		"msg_type":  msg.MsgType,
-		"preview":   utils.Truncate(content, 50),
+		"preview":   c.LogPreview(chatID, senderID, content, 50),
 	})
 
 	// Build sender info
@@ -16,7 +16,6 @@ import (
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/identity"
 	"github.com/sipeed/picoclaw/pkg/logger"
-	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 // WeComBotChannel implements the Channel interface for WeCom Bot (企业微信智能机器人)
@@ -154,7 +153,7 @@ func (c *WeComBotChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 
 	logger.DebugCF("wecom", "Sending message via webhook", map[string]any{
 		"chat_id": msg.ChatID,
-		"preview": utils.Truncate(msg.Content, 100),
+		"preview": c.LogPreview(msg.ChatID, "", msg.Content, 100),
 	})
 
 	return c.sendWebhookReply(ctx, msg.ChatID, msg.Content)
@@ -401,7 +400,7 @@ func (c *WeComBotChannel) processMessage(ctx context.Context, msg WeComBotMessag
 		"msg_type":      msg.MsgType,
 		"peer_kind":     peerKind,
 		"is_group_chat": isGroupChat,
-		"preview":       utils.Truncate(content, 50),
+		"preview":       c.LogPreview(chatID, senderID, content, 50),
 	})
 
 	// Build sender info
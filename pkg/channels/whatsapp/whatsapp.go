@@ -14,7 +14,6 @@ import (
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/identity"
 	"github.com/sipeed/picoclaw/pkg/logger"
-	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 type WhatsAppChannel struct {
@@ -232,7 +231,7 @@ func (c *WhatsAppChannel) handleIncomingMessage(msg map[string]any) {
 
 	logger.InfoCF("whatsapp", "WhatsApp message received", map[string]any{
 		"sender":  senderID,
-		"preview": utils.Truncate(content, 50),
+		"preview": c.LogPreview(chatID, senderID, content, 50),
 	})
 
 	sender := bus.SenderInfo{
@@ -0,0 +1,365 @@
+package whatsappcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/format"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// errWindowClosed is the Graph API error code for a free-form message
+	// sent outside the service window.
+	errWindowClosed = 131047
+
+	// maxTemplateParameter is the limit of a template's text parameter.
+	maxTemplateParameter = 1024
+)
+
+// apiError is an error response of the Graph API.
+type apiError struct {
+	Status  int
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("WhatsApp API error %d: %s", e.Code, e.Message)
+}
+
+// Unwrap classifies the error by its HTTP status, as
+// channels.ClassifySendError does, so the manager knows what to retry.
+func (e *apiError) Unwrap() error {
+	switch {
+	case e.Status == http.StatusTooManyRequests:
+		return channels.ErrRateLimit
+	case e.Status >= 500:
+		return channels.ErrTemporary
+	default:
+		return channels.ErrSendFailed
+	}
+}
+
+// Send sends a text reply. Outside the service window the re-engagement
+// template is sent instead.
+func (c *CloudChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	text := format.Render(msg.Content, format.WhatsApp)
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	if c.windowClosed(msg.ChatID) {
+		return c.reengage(ctx, msg.ChatID, text)
+	}
+	err := c.sendText(ctx, msg.ChatID, text, msg.ReplyToMessageID)
+	if windowError(err) {
+		c.mu.Lock()
+		c.lastSeen[msg.ChatID] = time.Time{}
+		c.mu.Unlock()
+		return c.reengage(ctx, msg.ChatID, text)
+	}
+	return err
+}
+
+func windowError(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Code == errWindowClosed
+}
+
+// reengage sends the re-engagement template to a user whose service window
+// closed. The reply goes with it as its parameter, or waits until the user
+// writes back.
+func (c *CloudChannel) reengage(ctx context.Context, chatID, text string) error {
+	tmpl := c.config.ReengageTemplate
+	if tmpl.Name == "" {
+		return fmt.Errorf("%w: the 24-hour window of %s is closed and no reengage_template is set",
+			channels.ErrSendFailed, chatID)
+	}
+	if tmpl.TextParameter {
+		if runes := []rune(text); len(runes) > maxTemplateParameter {
+			text = string(runes[:maxTemplateParameter-1]) + "…"
+		}
+		return c.sendTemplate(ctx, chatID, text)
+	}
+
+	c.mu.Lock()
+	first := len(c.held[chatID]) == 0
+	c.held[chatID] = append(c.held[chatID], text)
+	c.mu.Unlock()
+	if !first {
+		return nil
+	}
+	return c.sendTemplate(ctx, chatID, "")
+}
+
+// sendHeld sends the replies that waited for chatID's window to reopen.
+func (c *CloudChannel) sendHeld(ctx context.Context, chatID string) {
+	c.mu.Lock()
+	held := c.held[chatID]
+	delete(c.held, chatID)
+	c.mu.Unlock()
+	for _, text := range held {
+		if err := c.sendText(ctx, chatID, text, ""); err != nil {
+			logger.WarnCF("whatsapp_cloud", "Failed to send a held reply", map[string]any{
+				"chat_id": chatID,
+				"error":   err.Error(),
+			})
+		}
+	}
+}
+
+func (c *CloudChannel) sendText(ctx context.Context, chatID, text, replyTo string) error {
+	payload := map[string]any{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                chatID,
+		"type":              "text",
+		"text":              map[string]any{"body": text, "preview_url": true},
+	}
+	if replyTo != "" {
+		payload["context"] = map[string]string{"message_id": replyTo}
+	}
+	return c.postMessage(ctx, chatID, payload)
+}
+
+// sendTemplate sends the re-engagement template, with text as its first
+// body parameter unless it is empty.
+func (c *CloudChannel) sendTemplate(ctx context.Context, chatID, text string) error {
+	tmpl := c.config.ReengageTemplate
+	language := tmpl.Language
+	if language == "" {
+		language = "en_US"
+	}
+	template := map[string]any{
+		"name":     tmpl.Name,
+		"language": map[string]string{"code": language},
+	}
+	if text != "" {
+		template["components"] = []map[string]any{{
+			"type":       "body",
+			"parameters": []map[string]string{{"type": "text", "text": text}},
+		}}
+	}
+	return c.postMessage(ctx, chatID, map[string]any{
+		"messaging_product": "whatsapp",
+		"to":                chatID,
+		"type":              "template",
+		"template":          template,
+	})
+}
+
+func (c *CloudChannel) postMessage(ctx context.Context, chatID string, payload map[string]any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	var sent struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	endpoint := c.apiBase + "/" + c.config.PhoneNumberID + "/messages"
+	if err := c.call(ctx, http.MethodPost, endpoint, "application/json", bytes.NewReader(body), &sent); err != nil {
+		return err
+	}
+	if len(sent.Messages) > 0 {
+		c.RecordReply(chatID, sent.Messages[0].ID)
+	}
+	return nil
+}
+
+// SendMedia uploads each attachment and sends it as a media message.
+func (c *CloudChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	store := c.GetMediaStore()
+	if store == nil {
+		return fmt.Errorf("no media store available: %w", channels.ErrSendFailed)
+	}
+	for _, part := range msg.Parts {
+		localPath, err := store.Resolve(part.Ref)
+		if err != nil {
+			logger.ErrorCF("whatsapp_cloud", "Failed to resolve media ref", map[string]any{
+				"ref":   part.Ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+		if err := c.sendMediaPart(ctx, msg.ChatID, localPath, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CloudChannel) sendMediaPart(ctx context.Context, chatID, localPath string, part bus.MediaPart) error {
+	// Templates cannot carry the file, so it is not sent at all
+	if c.windowClosed(chatID) {
+		return fmt.Errorf("%w: the 24-hour window of %s is closed", channels.ErrSendFailed, chatID)
+	}
+	filename := part.Filename
+	if filename == "" {
+		filename = filepath.Base(localPath)
+	}
+	contentType := part.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	id, err := c.upload(ctx, localPath, filename, contentType)
+	if err != nil {
+		return err
+	}
+
+	kind := part.Type
+	object := map[string]string{"id": id}
+	switch kind {
+	case "image", "video":
+		if part.Caption != "" {
+			object["caption"] = part.Caption
+		}
+	case "audio":
+	default:
+		kind = "document"
+		object["filename"] = filename
+		if part.Caption != "" {
+			object["caption"] = part.Caption
+		}
+	}
+	payload := map[string]any{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                chatID,
+		"type":              kind,
+		kind:                object,
+	}
+	return c.postMessage(ctx, chatID, payload)
+}
+
+// upload stores a file with the media endpoint and returns its media ID.
+func (c *CloudChannel) upload(ctx context.Context, localPath, filename, contentType string) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", channels.ErrSendFailed, err)
+	}
+	defer file.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("messaging_product", "whatsapp")
+	form.WriteField("type", contentType)
+	header := make(map[string][]string)
+	header["Content-Disposition"] = []string{
+		fmt.Sprintf(`form-data; name="file"; filename=%q`, filename),
+	}
+	header["Content-Type"] = []string{contentType}
+	w, err := form.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	var uploaded struct {
+		ID string `json:"id"`
+	}
+	endpoint := c.apiBase + "/" + c.config.PhoneNumberID + "/media"
+	if err := c.call(ctx, http.MethodPost, endpoint, form.FormDataContentType(), &body, &uploaded); err != nil {
+		return "", err
+	}
+	return uploaded.ID, nil
+}
+
+// storeMedia downloads an attachment into the media store and returns its
+// ref, or "" when it cannot be fetched.
+func (c *CloudChannel) storeMedia(chatID, messageID string, att *cloudMedia, filename string) string {
+	var info struct {
+		URL string `json:"url"`
+	}
+	if err := c.call(c.ctx, http.MethodGet, c.apiBase+"/"+att.ID, "", nil, &info); err != nil || info.URL == "" {
+		logger.WarnCF("whatsapp_cloud", "Failed to look up media", map[string]any{
+			"media_id": att.ID,
+			"error":    fmt.Sprint(err),
+		})
+		return ""
+	}
+	localPath := utils.DownloadFile(info.URL, filename, utils.DownloadOptions{
+		LoggerPrefix: "whatsapp_cloud",
+		ExtraHeaders: map[string]string{"Authorization": "Bearer " + c.config.AccessToken},
+	})
+	if localPath == "" {
+		return ""
+	}
+	store := c.GetMediaStore()
+	if store == nil {
+		return localPath
+	}
+	ref, err := store.Store(localPath, media.MediaMeta{
+		Filename:    filename,
+		ContentType: att.MimeType,
+		Source:      "whatsapp",
+	}, channels.BuildMediaScope("whatsapp_cloud", chatID, messageID))
+	if err != nil {
+		return localPath
+	}
+	return ref
+}
+
+// call makes an authenticated Graph API request and decodes the response
+// into out.
+func (c *CloudChannel) call(ctx context.Context, method, endpoint, contentType string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.config.AccessToken)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.apiClient.Do(req)
+	if err != nil {
+		return channels.ClassifyNetError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error apiError `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(data, &failure) != nil || failure.Error.Code == 0 {
+			failure.Error.Message = strings.TrimSpace(string(data))
+		}
+		failure.Error.Status = resp.StatusCode
+		return &failure.Error
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,13 @@
+package whatsappcloud
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func init() {
+	channels.RegisterFactory("whatsapp_cloud", func(cfg *config.Config, b *bus.MessageBus) (channels.Channel, error) {
+		return NewCloudChannel(cfg.Channels.WhatsAppCloud, b)
+	})
+}
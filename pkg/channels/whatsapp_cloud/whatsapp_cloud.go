@@ -0,0 +1,350 @@
+// Package whatsappcloud is a WhatsApp channel over Meta's WhatsApp Business
+// Cloud API. Messages arrive on a webhook of the shared gateway server and
+// replies are sent with the Graph API, without a bridge or a linked phone.
+package whatsappcloud
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultAPIBase     = "https://graph.facebook.com/v21.0"
+	defaultWebhookPath = "/webhook/whatsapp"
+
+	// maxMessageLength is the limit of a text message body.
+	maxMessageLength = 4096
+
+	// serviceWindow is how long after the user's last message free-form
+	// messages may be sent.
+	serviceWindow = 24 * time.Hour
+
+	// Webhook payloads are a few KB; media is fetched separately.
+	maxWebhookBodySize = 1 << 20
+)
+
+// CloudChannel answers WhatsApp users through the Cloud API.
+type CloudChannel struct {
+	*channels.BaseChannel
+	config    config.WhatsAppCloudConfig
+	apiBase   string
+	apiClient *http.Client
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	mu       sync.Mutex
+	lastSeen map[string]time.Time // chat ID → the user's last message
+	held     map[string][]string  // chat ID → replies waiting for the window to reopen
+}
+
+// NewCloudChannel creates a WhatsApp Cloud API channel.
+func NewCloudChannel(cfg config.WhatsAppCloudConfig, messageBus *bus.MessageBus) (*CloudChannel, error) {
+	if cfg.PhoneNumberID == "" || cfg.AccessToken == "" {
+		return nil, fmt.Errorf("whatsapp_cloud phone_number_id and access_token are required")
+	}
+	if cfg.AppSecret == "" {
+		return nil, fmt.Errorf("whatsapp_cloud app_secret is required to verify webhook signatures")
+	}
+	apiBase := strings.TrimRight(cfg.APIBase, "/")
+	if apiBase == "" {
+		apiBase = defaultAPIBase
+	}
+
+	base := channels.NewBaseChannel("whatsapp_cloud", cfg, messageBus, cfg.AllowFrom,
+		channels.WithMaxMessageLength(maxMessageLength),
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+
+	return &CloudChannel{
+		BaseChannel: base,
+		config:      cfg,
+		apiBase:     apiBase,
+		apiClient:   &http.Client{Timeout: 30 * time.Second},
+		ctx:         context.Background(),
+		lastSeen:    make(map[string]time.Time),
+		held:        make(map[string][]string),
+	}, nil
+}
+
+func (c *CloudChannel) Start(ctx context.Context) error {
+	logger.InfoC("whatsapp_cloud", "Starting WhatsApp Cloud API channel (Webhook Mode)")
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.SetRunning(true)
+	return nil
+}
+
+func (c *CloudChannel) Stop(ctx context.Context) error {
+	logger.InfoC("whatsapp_cloud", "Stopping WhatsApp Cloud API channel")
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.SetRunning(false)
+	return nil
+}
+
+// WebhookPath implements channels.WebhookHandler.
+func (c *CloudChannel) WebhookPath() string {
+	if c.config.WebhookPath != "" {
+		return c.config.WebhookPath
+	}
+	return defaultWebhookPath
+}
+
+// ServeHTTP answers Meta's verification request and receives webhook events.
+func (c *CloudChannel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		c.verifyWebhook(w, r)
+	case http.MethodPost:
+		c.receiveWebhook(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verifyWebhook echoes the challenge when Meta subscribes the webhook with
+// the configured verify token.
+func (c *CloudChannel) verifyWebhook(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	token := q.Get("hub.verify_token")
+	if q.Get("hub.mode") != "subscribe" || c.config.VerifyToken == "" ||
+		subtle.ConstantTimeCompare([]byte(token), []byte(c.config.VerifyToken)) != 1 {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	io.WriteString(w, q.Get("hub.challenge"))
+}
+
+func (c *CloudChannel) receiveWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodySize+1))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxWebhookBodySize {
+		http.Error(w, "Request entity too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !c.verifySignature(body, r.Header.Get("X-Hub-Signature-256")) {
+		logger.WarnC("whatsapp_cloud", "Invalid webhook signature")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	// Meta retries deliveries that are not acknowledged quickly
+	w.WriteHeader(http.StatusOK)
+
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			if change.Field != "messages" {
+				continue
+			}
+			names := make(map[string]string, len(change.Value.Contacts))
+			for _, contact := range change.Value.Contacts {
+				names[contact.WaID] = contact.Profile.Name
+			}
+			for _, msg := range change.Value.Messages {
+				go c.processMessage(msg, names[msg.From])
+			}
+		}
+	}
+}
+
+// verifySignature checks X-Hub-Signature-256, the HMAC-SHA256 of the body
+// keyed with the app secret.
+func (c *CloudChannel) verifySignature(body []byte, header string) bool {
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok || c.config.AppSecret == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(c.config.AppSecret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
+
+type webhookPayload struct {
+	Entry []struct {
+		Changes []struct {
+			Field string `json:"field"`
+			Value struct {
+				Contacts []struct {
+					WaID    string `json:"wa_id"`
+					Profile struct {
+						Name string `json:"name"`
+					} `json:"profile"`
+				} `json:"contacts"`
+				Messages []cloudMessage `json:"messages"`
+			} `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+type cloudMessage struct {
+	From      string `json:"from"`
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Text      struct {
+		Body string `json:"body"`
+	} `json:"text"`
+	Image    *cloudMedia `json:"image"`
+	Audio    *cloudMedia `json:"audio"`
+	Video    *cloudMedia `json:"video"`
+	Document *cloudMedia `json:"document"`
+	Sticker  *cloudMedia `json:"sticker"`
+	Button   struct {
+		Text string `json:"text"`
+	} `json:"button"`
+	Interactive struct {
+		ButtonReply struct {
+			Title string `json:"title"`
+		} `json:"button_reply"`
+		ListReply struct {
+			Title string `json:"title"`
+		} `json:"list_reply"`
+	} `json:"interactive"`
+	Context struct {
+		ID string `json:"id"`
+	} `json:"context"`
+}
+
+type cloudMedia struct {
+	ID       string `json:"id"`
+	MimeType string `json:"mime_type"`
+	Caption  string `json:"caption"`
+	Filename string `json:"filename"`
+}
+
+// media returns the attachment of msg and the name it is stored under.
+func (msg cloudMessage) media() (*cloudMedia, string) {
+	switch msg.Type {
+	case "image":
+		return msg.Image, "image.jpg"
+	case "audio":
+		// Voice notes are Ogg Opus
+		return msg.Audio, "audio.ogg"
+	case "video":
+		return msg.Video, "video.mp4"
+	case "document":
+		if msg.Document != nil && msg.Document.Filename != "" {
+			return msg.Document, msg.Document.Filename
+		}
+		return msg.Document, "document"
+	case "sticker":
+		return msg.Sticker, "sticker.webp"
+	}
+	return nil, ""
+}
+
+func (c *CloudChannel) processMessage(msg cloudMessage, name string) {
+	chatID := msg.From
+	if chatID == "" {
+		return
+	}
+	sender := bus.SenderInfo{
+		Platform:    "whatsapp",
+		PlatformID:  chatID,
+		CanonicalID: identity.BuildCanonicalID("whatsapp", chatID),
+		DisplayName: name,
+	}
+	if !c.IsAllowedSender(sender) {
+		return
+	}
+	c.sawMessage(chatID, msg.Timestamp)
+
+	var content string
+	var mediaRefs []string
+	switch msg.Type {
+	case "text":
+		content = msg.Text.Body
+	case "button":
+		content = msg.Button.Text
+	case "interactive":
+		content = msg.Interactive.ButtonReply.Title
+		if content == "" {
+			content = msg.Interactive.ListReply.Title
+		}
+	default:
+		att, filename := msg.media()
+		if att == nil {
+			content = fmt.Sprintf("[%s]", msg.Type)
+			break
+		}
+		content = att.Caption
+		if ref := c.storeMedia(chatID, msg.ID, att, filename); ref != "" {
+			mediaRefs = append(mediaRefs, ref)
+			if content == "" {
+				content = fmt.Sprintf("[%s]", msg.Type)
+			}
+		}
+	}
+	if strings.TrimSpace(content) == "" && len(mediaRefs) == 0 {
+		return
+	}
+
+	// The user reopened the service window: deliver what waited for it
+	c.sendHeld(c.ctx, chatID)
+
+	metadata := map[string]string{
+		"platform":     "whatsapp",
+		"display_name": name,
+	}
+	if msg.Context.ID != "" {
+		metadata["reply_to_message_id"] = msg.Context.ID
+	}
+	logger.DebugCF("whatsapp_cloud", "Received message", map[string]any{
+		"chat_id": chatID,
+		"type":    msg.Type,
+		"preview": c.LogPreview(chatID, chatID, content, 50, sender),
+	})
+	c.HandleMessage(c.ctx, bus.Peer{Kind: "direct", ID: chatID}, msg.ID, chatID, chatID,
+		content, mediaRefs, metadata, sender)
+}
+
+// sawMessage records when the user last wrote, which opens the service
+// window.
+func (c *CloudChannel) sawMessage(chatID, timestamp string) {
+	at := time.Now()
+	if sec, err := strconv.ParseInt(timestamp, 10, 64); err == nil && sec > 0 {
+		at = time.Unix(sec, 0)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if at.After(c.lastSeen[chatID]) {
+		c.lastSeen[chatID] = at
+	}
+}
+
+// windowClosed reports whether free-form messages to chatID would be
+// refused. After a restart the window is unknown and assumed open; the API
+// error for a closed window is handled as well.
+func (c *CloudChannel) windowClosed(chatID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seen, ok := c.lastSeen[chatID]
+	return ok && time.Since(seen) >= serviceWindow
+}
@@ -0,0 +1,183 @@
+package whatsappcloud
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// graphServer records the messages sent to the Graph API. Free-form
+// messages to closedChat are refused as outside the service window.
+type graphServer struct {
+	*httptest.Server
+	mu   sync.Mutex
+	sent []map[string]any
+}
+
+const closedChat = "15550000000"
+
+func newGraphServer(t *testing.T) *graphServer {
+	g := &graphServer{}
+	g.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload["to"] == closedChat && payload["type"] == "text" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":{"code":131047,"message":"Re-engagement message"}}`)
+			return
+		}
+		g.mu.Lock()
+		g.sent = append(g.sent, payload)
+		g.mu.Unlock()
+		io.WriteString(w, `{"messages":[{"id":"wamid.1"}]}`)
+	}))
+	t.Cleanup(g.Close)
+	return g
+}
+
+func (g *graphServer) messages() []map[string]any {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]map[string]any(nil), g.sent...)
+}
+
+func newTestChannel(t *testing.T, apiBase string, b *bus.MessageBus) *CloudChannel {
+	t.Helper()
+	c, err := NewCloudChannel(config.WhatsAppCloudConfig{
+		PhoneNumberID:    "100",
+		AccessToken:      "token",
+		AppSecret:        "secret",
+		VerifyToken:      "verify-me",
+		APIBase:          apiBase,
+		ReengageTemplate: config.WhatsAppTemplate{Name: "follow_up", Language: "en"},
+	}, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	c := newTestChannel(t, "", bus.NewMessageBus())
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/webhook/whatsapp?hub.mode=subscribe&hub.verify_token=verify-me&hub.challenge=42", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "42" {
+		t.Errorf("verification = %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/webhook/whatsapp?hub.mode=subscribe&hub.verify_token=wrong&hub.challenge=42", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("wrong token: status %d", rec.Code)
+	}
+}
+
+func TestReceiveWebhook(t *testing.T) {
+	b := bus.NewMessageBus()
+	defer b.Close()
+	c := newTestChannel(t, "", b)
+
+	body := `{"object":"whatsapp_business_account","entry":[{"changes":[{"field":"messages","value":{
+		"contacts":[{"wa_id":"15551234567","profile":{"name":"Alice"}}],
+		"messages":[{"from":"15551234567","id":"wamid.in","timestamp":"1700000000","type":"text",
+			"text":{"body":"hello bot"}}]}}]}]}`
+	post := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook/whatsapp", strings.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", signature)
+		rec := httptest.NewRecorder()
+		c.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post("sha256=00"); code != http.StatusForbidden {
+		t.Fatalf("bad signature: status %d", code)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	if code := post("sha256=" + hex.EncodeToString(mac.Sum(nil))); code != http.StatusOK {
+		t.Fatalf("signed delivery: status %d", code)
+	}
+
+	select {
+	case msg := <-b.InboundChan():
+		if msg.Content != "hello bot" || msg.ChatID != "15551234567" || msg.Sender.DisplayName != "Alice" {
+			t.Errorf("inbound = %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no inbound message")
+	}
+}
+
+func TestSend_ReengagesAfterTheWindow(t *testing.T) {
+	g := newGraphServer(t)
+	b := bus.NewMessageBus()
+	defer b.Close()
+	c := newTestChannel(t, g.URL, b)
+	ctx := context.Background()
+
+	if err := c.Send(ctx, bus.OutboundMessage{ChatID: "15551234567", Content: "**hi**"}); err != nil {
+		t.Fatal(err)
+	}
+	sent := g.messages()
+	if len(sent) != 1 || sent[0]["text"].(map[string]any)["body"] != "*hi*" {
+		t.Fatalf("sent = %v", sent)
+	}
+
+	// The API refuses the reply: the template goes out and the reply waits
+	for _, text := range []string{"first", "second"} {
+		if err := c.Send(ctx, bus.OutboundMessage{ChatID: closedChat, Content: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent = g.messages()
+	if len(sent) != 2 || sent[1]["type"] != "template" ||
+		sent[1]["template"].(map[string]any)["name"] != "follow_up" {
+		t.Fatalf("sent = %v", sent)
+	}
+	if held := c.held[closedChat]; len(held) != 2 {
+		t.Errorf("held = %v", held)
+	}
+
+	// Once the user writes back, the held replies are delivered
+	c.mu.Lock()
+	c.lastSeen[closedChat] = time.Now()
+	c.mu.Unlock()
+	c.sendHeld(ctx, "15551234567")
+	if len(c.held[closedChat]) != 2 {
+		t.Error("another chat's held replies were sent")
+	}
+	c.config.ReengageTemplate.TextParameter = true
+	c.mu.Lock()
+	c.lastSeen["15552222222"] = time.Now().Add(-25 * time.Hour)
+	c.mu.Unlock()
+	if err := c.Send(ctx, bus.OutboundMessage{ChatID: "15552222222", Content: "your order shipped"}); err != nil {
+		t.Fatal(err)
+	}
+	sent = g.messages()
+	last, _ := json.Marshal(sent[len(sent)-1])
+	if !strings.Contains(string(last), `"text":"your order shipped"`) {
+		t.Errorf("template with the reply = %s", last)
+	}
+}
@@ -0,0 +1,16 @@
+package xmpp
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func init() {
+	channels.RegisterFactory("xmpp", func(cfg *config.Config, b *bus.MessageBus) (channels.Channel, error) {
+		if !cfg.Channels.XMPP.Enabled {
+			return nil, nil
+		}
+		return NewXMPPChannel(cfg.Channels.XMPP, b)
+	})
+}
@@ -0,0 +1,308 @@
+package xmpp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	nsStream  = "http://etherx.jabber.org/streams"
+	nsTLS     = "urn:ietf:params:xml:ns:xmpp-tls"
+	nsSASL    = "urn:ietf:params:xml:ns:xmpp-sasl"
+	nsBind    = "urn:ietf:params:xml:ns:xmpp-bind"
+	nsSession = "urn:ietf:params:xml:ns:xmpp-session"
+	nsStanzas = "urn:ietf:params:xml:ns:xmpp-stanzas"
+	nsMUC     = "http://jabber.org/protocol/muc"
+	nsCorrect = "urn:xmpp:message-correct:0"
+	nsPing    = "urn:xmpp:ping"
+)
+
+// negotiateTimeout bounds the login, from the TCP connection to the bound
+// resource.
+const negotiateTimeout = 30 * time.Second
+
+var errAuth = errors.New("xmpp authentication failed")
+
+// present marks an element whose content does not matter.
+type present struct{}
+
+type streamFeatures struct {
+	StartTLS   *present `xml:"urn:ietf:params:xml:ns:xmpp-tls starttls"`
+	Mechanisms *struct {
+		Mechanism []string `xml:"mechanism"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-sasl mechanisms"`
+	Bind    *present `xml:"urn:ietf:params:xml:ns:xmpp-bind bind"`
+	Session *struct {
+		Optional *present `xml:"optional"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-session session"`
+}
+
+// message is an inbound message stanza.
+type message struct {
+	From    string   `xml:"from,attr"`
+	ID      string   `xml:"id,attr"`
+	Type    string   `xml:"type,attr"`
+	Body    string   `xml:"body"`
+	Delay   *present `xml:"urn:xmpp:delay delay"`
+	Replace *struct {
+		ID string `xml:"id,attr"`
+	} `xml:"urn:xmpp:message-correct:0 replace"`
+}
+
+// presence is an inbound presence stanza; only errors are looked at.
+type presence struct {
+	From  string `xml:"from,attr"`
+	Type  string `xml:"type,attr"`
+	Error *struct {
+		Text string `xml:"text"`
+	} `xml:"error"`
+}
+
+type iq struct {
+	From  string   `xml:"from,attr"`
+	ID    string   `xml:"id,attr"`
+	Type  string   `xml:"type,attr"`
+	Ping  *present `xml:"urn:xmpp:ping ping"`
+	Bound *struct {
+		JID string `xml:"jid"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-bind bind"`
+}
+
+// session is one logged-in XML stream to the server.
+type session struct {
+	conn net.Conn
+	r    *bufio.Reader // shared by the decoders of restarted streams
+	dec  *xml.Decoder
+	jid  string // the full JID the server bound
+
+	mu sync.Mutex // serializes writes
+}
+
+// login is what a session needs to log in.
+type login struct {
+	server     string // host:port
+	domain     string
+	user       string
+	password   string
+	resource   string
+	directTLS  bool
+	requireTLS bool
+}
+
+// dial connects to the server and logs in.
+func dial(ctx context.Context, l login) (*session, error) {
+	d := net.Dialer{Timeout: negotiateTimeout}
+	conn, err := d.DialContext(ctx, "tcp", l.server)
+	if err != nil {
+		return nil, err
+	}
+	if l.directTLS {
+		conn = tls.Client(conn, &tls.Config{ServerName: l.domain, MinVersion: tls.VersionTLS12})
+	}
+	s := &session{conn: conn}
+	conn.SetDeadline(time.Now().Add(negotiateTimeout))
+	if err := s.negotiate(l); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return s, nil
+}
+
+// negotiate runs STARTTLS, SASL PLAIN, and resource binding (RFC 6120).
+func (s *session) negotiate(l login) error {
+	features, err := s.open(l.domain)
+	if err != nil {
+		return err
+	}
+	if _, secure := s.conn.(*tls.Conn); !secure {
+		switch {
+		case features.StartTLS != nil:
+			if err := s.write("<starttls xmlns='" + nsTLS + "'/>"); err != nil {
+				return err
+			}
+			start, err := s.next()
+			if err != nil {
+				return err
+			}
+			if start.Name.Local != "proceed" {
+				return fmt.Errorf("xmpp server refused STARTTLS")
+			}
+			tlsConn := tls.Client(s.conn, &tls.Config{ServerName: l.domain, MinVersion: tls.VersionTLS12})
+			if err := tlsConn.Handshake(); err != nil {
+				return fmt.Errorf("xmpp tls: %w", err)
+			}
+			s.conn, s.r = tlsConn, nil
+			if features, err = s.open(l.domain); err != nil {
+				return err
+			}
+		case l.requireTLS:
+			return fmt.Errorf("xmpp server %s does not offer STARTTLS", l.server)
+		}
+	}
+
+	if features.Mechanisms == nil || !slices.Contains(features.Mechanisms.Mechanism, "PLAIN") {
+		return fmt.Errorf("xmpp server does not offer SASL PLAIN")
+	}
+	credentials := base64.StdEncoding.EncodeToString([]byte("\x00" + l.user + "\x00" + l.password))
+	if err := s.write("<auth xmlns='" + nsSASL + "' mechanism='PLAIN'>" + credentials + "</auth>"); err != nil {
+		return err
+	}
+	start, err := s.next()
+	if err != nil {
+		return err
+	}
+	s.dec.Skip()
+	if start.Name.Local != "success" {
+		return errAuth
+	}
+
+	if features, err = s.open(l.domain); err != nil {
+		return err
+	}
+	if features.Bind == nil {
+		return fmt.Errorf("xmpp server does not offer resource binding")
+	}
+	if err := s.write("<iq type='set' id='bind'><bind xmlns='" + nsBind + "'><resource>" +
+		escape(l.resource) + "</resource></bind></iq>"); err != nil {
+		return err
+	}
+	bound, err := s.result()
+	if err != nil {
+		return fmt.Errorf("xmpp bind: %w", err)
+	}
+	if bound.Bound == nil || bound.Bound.JID == "" {
+		return fmt.Errorf("xmpp bind: no JID in the reply")
+	}
+	s.jid = bound.Bound.JID
+
+	// Servers that still require the RFC 3921 session say so
+	if features.Session != nil && features.Session.Optional == nil {
+		if err := s.write("<iq type='set' id='session'><session xmlns='" + nsSession + "'/></iq>"); err != nil {
+			return err
+		}
+		if _, err := s.result(); err != nil {
+			return fmt.Errorf("xmpp session: %w", err)
+		}
+	}
+	return nil
+}
+
+// open starts a new stream, as after connecting, STARTTLS, and SASL, and
+// returns the features the server offers on it.
+func (s *session) open(domain string) (streamFeatures, error) {
+	var features streamFeatures
+	if err := s.write("<?xml version='1.0'?><stream:stream to='" + escape(domain) +
+		"' xmlns='jabber:client' xmlns:stream='" + nsStream + "' version='1.0'>"); err != nil {
+		return features, err
+	}
+	if s.r == nil {
+		s.r = bufio.NewReader(s.conn)
+	}
+	s.dec = xml.NewDecoder(s.r)
+	start, err := s.next()
+	if err != nil {
+		return features, err
+	}
+	if start.Name.Space != nsStream || start.Name.Local != "stream" {
+		return features, fmt.Errorf("xmpp: unexpected <%s> instead of a stream", start.Name.Local)
+	}
+	if start, err = s.next(); err != nil {
+		return features, err
+	}
+	if start.Name.Space != nsStream || start.Name.Local != "features" {
+		return features, fmt.Errorf("xmpp: unexpected <%s> instead of stream features", start.Name.Local)
+	}
+	err = s.dec.DecodeElement(&features, &start)
+	return features, err
+}
+
+// result reads the reply to an iq the session sent during login.
+func (s *session) result() (iq, error) {
+	var reply iq
+	start, err := s.next()
+	if err != nil {
+		return reply, err
+	}
+	if start.Name.Local != "iq" {
+		return reply, fmt.Errorf("unexpected <%s>", start.Name.Local)
+	}
+	if err := s.dec.DecodeElement(&reply, &start); err != nil {
+		return reply, err
+	}
+	if reply.Type != "result" {
+		return reply, fmt.Errorf("the server answered %q", reply.Type)
+	}
+	return reply, nil
+}
+
+// next returns the next top-level element of the stream. It returns io.EOF
+// when the server closes the stream, and an error for stream errors.
+func (s *session) next() (xml.StartElement, error) {
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == nsStream && t.Name.Local == "error" {
+				var streamErr struct {
+					Inner []struct {
+						XMLName xml.Name
+					} `xml:",any"`
+				}
+				s.dec.DecodeElement(&streamErr, &t)
+				condition := "unknown"
+				if len(streamErr.Inner) > 0 {
+					condition = streamErr.Inner[0].XMLName.Local
+				}
+				return xml.StartElement{}, fmt.Errorf("xmpp stream error: %s", condition)
+			}
+			return t, nil
+		case xml.EndElement:
+			if t.Name.Space == nsStream && t.Name.Local == "stream" {
+				return xml.StartElement{}, io.EOF
+			}
+		}
+	}
+}
+
+// write sends raw XML on the stream.
+func (s *session) write(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(negotiateTimeout))
+	_, err := io.WriteString(s.conn, data)
+	return err
+}
+
+// close ends the stream and the connection.
+func (s *session) close() {
+	s.write("</stream:stream>")
+	s.conn.Close()
+}
+
+// escape returns text escaped for XML character data and attributes.
+func escape(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+// bareJID returns jid without its resource.
+func bareJID(jid string) string {
+	bare, _, _ := strings.Cut(jid, "/")
+	return bare
+}
@@ -0,0 +1,433 @@
+package xmpp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/format"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultResource = "picoclaw"
+	// Every streamed edit is a correction stanza, so they are kept sparse
+	defaultStreamEditInterval = 3 * time.Second
+	keepaliveInterval         = time.Minute
+	maxReconnectDelay         = 5 * time.Minute
+)
+
+// XMPPChannel implements the Channel interface for XMPP accounts. It answers
+// direct chats and the multi-user chat rooms (XEP-0045) in its config, and
+// shows streamed replies by correcting the message it sent (XEP-0308).
+type XMPPChannel struct {
+	*channels.BaseChannel
+	config     config.XMPPConfig
+	login      login
+	nick       string
+	rooms      map[string]bool // bare room JIDs, lowercased
+	mention    *regexp.Regexp
+	requireTLS bool // only tests log in without TLS
+	ctx        context.Context
+	cancel     context.CancelFunc
+	ids        atomic.Uint64
+
+	mu      sync.Mutex
+	session *session
+}
+
+// NewXMPPChannel creates a new XMPP channel.
+func NewXMPPChannel(cfg config.XMPPConfig, messageBus *bus.MessageBus) (*XMPPChannel, error) {
+	user, domain, ok := strings.Cut(bareJID(cfg.JID), "@")
+	if !ok || user == "" || domain == "" {
+		return nil, fmt.Errorf("xmpp jid must look like user@domain, got %q", cfg.JID)
+	}
+	if cfg.Password == "" {
+		return nil, fmt.Errorf("xmpp password is required")
+	}
+
+	l := login{
+		server:    cfg.Server,
+		domain:    domain,
+		user:      user,
+		password:  cfg.Password,
+		resource:  cfg.Resource,
+		directTLS: cfg.DirectTLS,
+	}
+	if l.server == "" {
+		l.server = domain + ":5222"
+	}
+	if l.resource == "" {
+		l.resource = defaultResource
+	}
+	nick := cfg.Nick
+	if nick == "" {
+		nick = user
+	}
+	rooms := make(map[string]bool, len(cfg.Rooms))
+	for _, room := range cfg.Rooms {
+		rooms[strings.ToLower(bareJID(room))] = true
+	}
+
+	base := channels.NewBaseChannel("xmpp", cfg, messageBus, cfg.AllowFrom,
+		channels.WithGroupTrigger(cfg.GroupTrigger),
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+
+	return &XMPPChannel{
+		BaseChannel: base,
+		config:      cfg,
+		login:       l,
+		nick:        nick,
+		rooms:       rooms,
+		requireTLS:  true,
+		mention:     regexp.MustCompile(`(?i)(^|\W)` + regexp.QuoteMeta(nick) + `($|\W)`),
+	}, nil
+}
+
+// Start logs in and begins listening. A lost connection is re-established in
+// the background.
+func (c *XMPPChannel) Start(ctx context.Context) error {
+	logger.InfoC("xmpp", "Starting XMPP channel")
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.login.requireTLS = c.requireTLS
+
+	s, err := dial(c.ctx, c.login)
+	if err != nil {
+		c.cancel()
+		return fmt.Errorf("xmpp connect failed: %w", err)
+	}
+	c.online(s)
+	go c.run(s)
+
+	c.SetRunning(true)
+	logger.InfoCF("xmpp", "XMPP channel started", map[string]any{
+		"jid":   s.jid,
+		"rooms": len(c.rooms),
+	})
+	return nil
+}
+
+// Stop ends the stream and disconnects.
+func (c *XMPPChannel) Stop(ctx context.Context) error {
+	logger.InfoC("xmpp", "Stopping XMPP channel")
+	c.SetRunning(false)
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.mu.Lock()
+	s := c.session
+	c.session = nil
+	c.mu.Unlock()
+	if s != nil {
+		s.close()
+	}
+	logger.InfoC("xmpp", "XMPP channel stopped")
+	return nil
+}
+
+// online sends the initial presence on a new session and joins the rooms.
+func (c *XMPPChannel) online(s *session) {
+	c.mu.Lock()
+	c.session = s
+	c.mu.Unlock()
+
+	s.write("<presence/>")
+	for room := range c.rooms {
+		// No history: the room's backlog is not addressed to the bot
+		s.write("<presence to='" + escape(room+"/"+c.nick) + "'><x xmlns='" + nsMUC +
+			"'><history maxstanzas='0'/></x></presence>")
+		logger.InfoCF("xmpp", "Joining XMPP room", map[string]any{
+			"room": room,
+		})
+	}
+}
+
+// run reads the stanzas of s and reconnects when the connection is lost.
+func (c *XMPPChannel) run(s *session) {
+	delay := 5 * time.Second
+	for {
+		stop := make(chan struct{})
+		go c.keepalive(s, stop)
+		err := c.read(s)
+		close(stop)
+		s.conn.Close()
+		if c.ctx.Err() != nil {
+			return
+		}
+		logger.WarnCF("xmpp", "XMPP connection lost", map[string]any{
+			"error": err.Error(),
+		})
+
+		for {
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			next, err := dial(c.ctx, c.login)
+			if err == nil {
+				s = next
+				delay = 5 * time.Second
+				c.online(s)
+				logger.InfoC("xmpp", "XMPP connection re-established")
+				break
+			}
+			logger.WarnCF("xmpp", "XMPP reconnect failed", map[string]any{
+				"error": err.Error(),
+			})
+			delay = min(delay*2, maxReconnectDelay)
+		}
+	}
+}
+
+// keepalive sends whitespace pings, so idle connections are not dropped by
+// NATs and a dead one is noticed.
+func (c *XMPPChannel) keepalive(s *session, stop <-chan struct{}) {
+	ticker := time.NewTicker(keepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.write(" "); err != nil {
+				s.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// read handles the stanzas of s until the stream ends.
+func (c *XMPPChannel) read(s *session) error {
+	for {
+		start, err := s.next()
+		if err != nil {
+			return err
+		}
+		switch start.Name.Local {
+		case "message":
+			var m message
+			if err := s.dec.DecodeElement(&m, &start); err != nil {
+				return err
+			}
+			c.onMessage(m)
+		case "presence":
+			var p presence
+			if err := s.dec.DecodeElement(&p, &start); err != nil {
+				return err
+			}
+			if p.Type == "error" && c.isRoom(bareJID(p.From)) {
+				reason := ""
+				if p.Error != nil {
+					reason = p.Error.Text
+				}
+				logger.WarnCF("xmpp", "Could not join XMPP room", map[string]any{
+					"room":   bareJID(p.From),
+					"reason": reason,
+				})
+			}
+		case "iq":
+			var q iq
+			if err := s.dec.DecodeElement(&q, &start); err != nil {
+				return err
+			}
+			c.onIQ(s, q)
+		default:
+			if err := s.dec.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// onIQ answers pings (XEP-0199) and refuses every other request, as RFC 6120
+// requires of entities that do not support them.
+func (c *XMPPChannel) onIQ(s *session, q iq) {
+	if q.Type != "get" && q.Type != "set" {
+		return
+	}
+	to := ""
+	if q.From != "" {
+		to = " to='" + escape(q.From) + "'"
+	}
+	if q.Ping != nil {
+		s.write("<iq type='result' id='" + escape(q.ID) + "'" + to + "/>")
+		return
+	}
+	s.write("<iq type='error' id='" + escape(q.ID) + "'" + to + "><error type='cancel'>" +
+		"<service-unavailable xmlns='" + nsStanzas + "'/></error></iq>")
+}
+
+// onMessage passes a chat or room message on to the agent.
+func (c *XMPPChannel) onMessage(m message) {
+	body := strings.TrimSpace(m.Body)
+	if body == "" || m.Type == "error" {
+		return
+	}
+	// The agent answered the original; an edit of it is not a new question
+	if m.Replace != nil {
+		return
+	}
+
+	from := bareJID(m.From)
+	_, resource, _ := strings.Cut(m.From, "/")
+	isRoom := m.Type == "groupchat"
+	var chatID, senderID string
+	var peer bus.Peer
+	var sender bus.SenderInfo
+	if isRoom {
+		// Ignore the room's history, our own echo, and rooms not configured
+		if m.Delay != nil || resource == "" || resource == c.nick || !c.isRoom(from) {
+			return
+		}
+		chatID, senderID = from, m.From
+		peer = bus.Peer{Kind: "group", ID: from}
+		sender = bus.SenderInfo{
+			Platform:    "xmpp",
+			PlatformID:  m.From,
+			CanonicalID: identity.BuildCanonicalID("xmpp", m.From),
+			Username:    resource,
+			DisplayName: resource,
+		}
+	} else {
+		chatID, senderID = from, from
+		peer = bus.Peer{Kind: "direct", ID: from}
+		sender = bus.SenderInfo{
+			Platform:    "xmpp",
+			PlatformID:  from,
+			CanonicalID: identity.BuildCanonicalID("xmpp", from),
+			Username:    from,
+			DisplayName: from,
+		}
+	}
+	if !c.IsAllowedSender(sender) {
+		return
+	}
+
+	if isRoom {
+		isMentioned := c.mention.MatchString(body)
+		if isMentioned {
+			body = c.stripMention(body)
+		}
+		respond, cleaned := c.ShouldRespondInGroup(isMentioned, body)
+		if !respond {
+			return
+		}
+		body = cleaned
+	}
+	if body == "" {
+		return
+	}
+
+	messageID := m.ID
+	if messageID == "" {
+		messageID = fmt.Sprintf("%s-%d", m.From, time.Now().UnixNano())
+	}
+	metadata := map[string]string{
+		"platform": "xmpp",
+	}
+	if isRoom {
+		metadata["room"] = from
+	}
+
+	c.HandleMessage(c.ctx, peer, messageID, senderID, chatID, body, nil, metadata, sender)
+}
+
+// stripMention removes a leading "nick:" or "nick," address.
+func (c *XMPPChannel) stripMention(body string) string {
+	lower := strings.ToLower(body)
+	nick := strings.ToLower(c.nick)
+	for _, sep := range []string{":", ","} {
+		if strings.HasPrefix(lower, nick+sep) {
+			return strings.TrimSpace(body[len(nick+sep):])
+		}
+	}
+	return body
+}
+
+// isRoom reports whether jid is one of the configured rooms.
+func (c *XMPPChannel) isRoom(jid string) bool {
+	return c.rooms[strings.ToLower(jid)]
+}
+
+// Send sends a message to a room or a user, as plain text.
+func (c *XMPPChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	if msg.ChatID == "" {
+		return fmt.Errorf("chat ID is empty: %w", channels.ErrSendFailed)
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+	_, err := c.sendMessage(msg.ChatID, msg.Content, "")
+	return err
+}
+
+// StreamingDefault implements channels.StreamingCapable. Clients without
+// XEP-0308 show every correction as a new message, so streaming is off
+// unless enabled.
+func (c *XMPPChannel) StreamingDefault() (bool, time.Duration) {
+	interval := defaultStreamEditInterval
+	if c.config.Streaming.EditIntervalMS > 0 {
+		interval = time.Duration(c.config.Streaming.EditIntervalMS) * time.Millisecond
+	}
+	return c.config.Streaming.Enabled, interval
+}
+
+// BeginStream implements channels.StreamingCapable.
+func (c *XMPPChannel) BeginStream(ctx context.Context, chatID, content string) (string, error) {
+	return c.sendMessage(chatID, content, "")
+}
+
+// EditMessage implements channels.MessageEditor with a last message
+// correction (XEP-0308). Every correction names the original message.
+func (c *XMPPChannel) EditMessage(ctx context.Context, chatID string, messageID string, content string) error {
+	_, err := c.sendMessage(chatID, content, messageID)
+	return err
+}
+
+// sendMessage sends content rendered as plain text, correcting the message
+// replaces when it is set, and returns the ID of the stanza.
+func (c *XMPPChannel) sendMessage(chatID, content, replaces string) (string, error) {
+	c.mu.Lock()
+	s := c.session
+	c.mu.Unlock()
+	if s == nil {
+		return "", fmt.Errorf("xmpp not connected: %w", channels.ErrTemporary)
+	}
+
+	kind := "chat"
+	if c.isRoom(chatID) {
+		kind = "groupchat"
+	}
+	id := fmt.Sprintf("picoclaw-%d-%d", time.Now().Unix(), c.ids.Add(1))
+	var b strings.Builder
+	b.WriteString("<message to='" + escape(chatID) + "' type='" + kind + "' id='" + id + "'><body>")
+	b.WriteString(escape(format.Render(content, format.Plain)))
+	b.WriteString("</body>")
+	if replaces != "" {
+		b.WriteString("<replace id='" + escape(replaces) + "' xmlns='" + nsCorrect + "'/>")
+	}
+	b.WriteString("</message>")
+
+	if err := s.write(b.String()); err != nil {
+		return "", fmt.Errorf("xmpp send: %v: %w", err, channels.ErrTemporary)
+	}
+	logger.DebugCF("xmpp", "Message sent", map[string]any{
+		"chat_id":  chatID,
+		"replaces": replaces,
+	})
+	return id, nil
+}
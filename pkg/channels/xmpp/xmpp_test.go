@@ -0,0 +1,252 @@
+package xmpp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// stanza is what the fake server records of the client's stanzas.
+type stanza struct {
+	XMLName xml.Name
+	To      string `xml:"to,attr"`
+	ID      string `xml:"id,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:"body"`
+	MUC     *struct {
+		History struct {
+			MaxStanzas string `xml:"maxstanzas,attr"`
+		} `xml:"history"`
+	} `xml:"http://jabber.org/protocol/muc x"`
+	Replace *struct {
+		ID string `xml:"id,attr"`
+	} `xml:"urn:xmpp:message-correct:0 replace"`
+}
+
+// fakeServer accepts one client without TLS, logs it in as
+// bot@example.com/picoclaw, and passes on the presences and messages it sends.
+type fakeServer struct {
+	addr     string
+	conn     chan net.Conn
+	stanzas  chan stanza
+	password chan string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeServer{
+		addr:     ln.Addr().String(),
+		conn:     make(chan net.Conn, 1),
+		stanzas:  make(chan stanza, 16),
+		password: make(chan string, 1),
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { conn.Close() })
+		f.conn <- conn
+		f.serve(conn)
+	}()
+	return f
+}
+
+func (f *fakeServer) serve(conn net.Conn) {
+	dec := xml.NewDecoder(conn)
+	authed := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "stream":
+			features := "<mechanisms xmlns='" + nsSASL + "'><mechanism>PLAIN</mechanism></mechanisms>"
+			if authed {
+				features = "<bind xmlns='" + nsBind + "'/>"
+			}
+			io.WriteString(conn, "<?xml version='1.0'?><stream:stream xmlns='jabber:client' "+
+				"xmlns:stream='"+nsStream+"' from='example.com' id='s1' version='1.0'>"+
+				"<stream:features>"+features+"</stream:features>")
+		case "auth":
+			var auth struct {
+				Credentials string `xml:",chardata"`
+			}
+			dec.DecodeElement(&auth, &start)
+			plain, _ := base64.StdEncoding.DecodeString(auth.Credentials)
+			f.password <- string(plain)
+			authed = true
+			io.WriteString(conn, "<success xmlns='"+nsSASL+"'/>")
+		case "iq":
+			dec.Skip()
+			io.WriteString(conn, "<iq type='result' id='bind'><bind xmlns='"+nsBind+"'>"+
+				"<jid>bot@example.com/picoclaw</jid></bind></iq>")
+		case "presence", "message":
+			var s stanza
+			dec.DecodeElement(&s, &start)
+			f.stanzas <- s
+		}
+	}
+}
+
+func (f *fakeServer) next(t *testing.T) stanza {
+	t.Helper()
+	select {
+	case s := <-f.stanzas:
+		return s
+	case <-time.After(2 * time.Second):
+		t.Fatal("no stanza from the client")
+		return stanza{}
+	}
+}
+
+func startTestChannel(t *testing.T, b *bus.MessageBus) (*XMPPChannel, *fakeServer, net.Conn) {
+	t.Helper()
+	f := newFakeServer(t)
+	c, err := NewXMPPChannel(config.XMPPConfig{
+		JID:          "bot@example.com",
+		Password:     "secret",
+		Server:       f.addr,
+		Rooms:        []string{"Lab@conference.example.com"},
+		Nick:         "picobot",
+		GroupTrigger: config.GroupTriggerConfig{MentionOnly: true},
+	}, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.requireTLS = false
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Stop(context.Background()) })
+	return c, f, <-f.conn
+}
+
+func TestNewXMPPChannel(t *testing.T) {
+	for _, cfg := range []config.XMPPConfig{
+		{JID: "example.com", Password: "x"},
+		{JID: "bot@example.com"},
+	} {
+		if _, err := NewXMPPChannel(cfg, bus.NewMessageBus()); err == nil {
+			t.Errorf("NewXMPPChannel(%+v) accepted the config", cfg)
+		}
+	}
+
+	c, err := NewXMPPChannel(config.XMPPConfig{JID: "bot@example.com/phone", Password: "x"}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.login.server != "example.com:5222" || c.login.resource != "picoclaw" || c.nick != "bot" {
+		t.Errorf("defaults = %+v, nick %q", c.login, c.nick)
+	}
+
+	c.requireTLS = true
+	f := newFakeServer(t)
+	c.login.server = f.addr
+	if err := c.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("login without TLS: %v", err)
+	}
+}
+
+func TestLoginAndJoin(t *testing.T) {
+	_, f, _ := startTestChannel(t, bus.NewMessageBus())
+
+	if password := <-f.password; password != "\x00bot\x00secret" {
+		t.Errorf("SASL PLAIN = %q", password)
+	}
+	if s := f.next(t); s.XMLName.Local != "presence" || s.To != "" {
+		t.Errorf("initial presence = %+v", s)
+	}
+	join := f.next(t)
+	if join.To != "lab@conference.example.com/picobot" || join.MUC == nil || join.MUC.History.MaxStanzas != "0" {
+		t.Errorf("room join = %+v", join)
+	}
+}
+
+func TestInboundMessages(t *testing.T) {
+	b := bus.NewMessageBus()
+	defer b.Close()
+	_, f, conn := startTestChannel(t, b)
+	f.next(t)
+	f.next(t)
+
+	send := func(from, kind, body string, extra string) {
+		fmt.Fprintf(conn, "<message from='%s' type='%s' id='m1'><body>%s</body>%s</message>",
+			from, kind, escape(body), extra)
+	}
+	// Not delivered: history, our echo, a message without a mention, a
+	// correction, and an unknown room
+	send("lab@conference.example.com/bob", "groupchat", "picobot: old", "<delay xmlns='urn:xmpp:delay'/>")
+	send("lab@conference.example.com/picobot", "groupchat", "picobot: echo", "")
+	send("lab@conference.example.com/bob", "groupchat", "just chatting", "")
+	send("alice@example.com/phone", "chat", "edited", "<replace xmlns='"+nsCorrect+"' id='m0'/>")
+	send("other@conference.example.com/bob", "groupchat", "picobot: hi", "")
+	// Delivered
+	send("lab@conference.example.com/bob", "groupchat", "picobot: what is <b>?", "")
+	send("alice@example.com/phone", "chat", "hello", "")
+
+	for _, want := range []struct{ chatID, sender, content string }{
+		{"lab@conference.example.com", "lab@conference.example.com/bob", "what is <b>?"},
+		{"alice@example.com", "alice@example.com", "hello"},
+	} {
+		select {
+		case msg := <-b.InboundChan():
+			if msg.ChatID != want.chatID || msg.Sender.PlatformID != want.sender || msg.Content != want.content {
+				t.Errorf("inbound = %+v, want %+v", msg, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no inbound message for %+v", want)
+		}
+	}
+}
+
+func TestStreamedReplyIsCorrected(t *testing.T) {
+	c, f, _ := startTestChannel(t, bus.NewMessageBus())
+	f.next(t)
+	f.next(t)
+	ctx := context.Background()
+
+	id, err := c.BeginStream(ctx, "lab@conference.example.com", "**Hel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := f.next(t)
+	if first.ID != id || first.Type != "groupchat" || first.Replace != nil {
+		t.Errorf("first = %+v", first)
+	}
+	for _, text := range []string{"**Hello** wor", "**Hello** world"} {
+		if err := c.EditMessage(ctx, "lab@conference.example.com", id, text); err != nil {
+			t.Fatal(err)
+		}
+		edit := f.next(t)
+		if edit.Replace == nil || edit.Replace.ID != id || edit.ID == id {
+			t.Errorf("correction = %+v", edit)
+		}
+	}
+
+	reply := bus.OutboundMessage{ChatID: "alice@example.com", Content: "# Title\n\n- [docs](https://x.io)"}
+	if err := c.Send(ctx, reply); err != nil {
+		t.Fatal(err)
+	}
+	if direct := f.next(t); direct.Type != "chat" || strings.ContainsAny(direct.Body, "#[]*") {
+		t.Errorf("direct reply = %+v", direct)
+	}
+}
@@ -0,0 +1,146 @@
+// Package chatexport writes a conversation as a portable transcript: JSON
+// that another picoclaw server can import to seed a session, or Markdown
+// for reading and audits.
+package chatexport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Format identifies a picoclaw conversation export, and Version is the
+// newest version of it this package reads and writes.
+const (
+	Format  = "picoclaw-conversation"
+	Version = 1
+)
+
+// ErrNotTranscript means the data is not a picoclaw conversation export.
+var ErrNotTranscript = errors.New("not a picoclaw conversation export")
+
+// Transcript is an exported conversation: its stored history, oldest
+// message first, and the summary of the messages compacted away before it.
+type Transcript struct {
+	Format     string              `json:"format"`
+	Version    int                 `json:"version"`
+	Agent      string              `json:"agent,omitempty"`
+	SessionKey string              `json:"session_key"`
+	Exported   time.Time           `json:"exported_at"`
+	Summary    string              `json:"summary,omitempty"`
+	Messages   []providers.Message `json:"messages"`
+}
+
+// New returns the transcript of a session as of now.
+func New(agentID, sessionKey, summary string, history []providers.Message) Transcript {
+	if history == nil {
+		history = []providers.Message{}
+	}
+	return Transcript{
+		Format:     Format,
+		Version:    Version,
+		Agent:      agentID,
+		SessionKey: sessionKey,
+		Exported:   time.Now().UTC(),
+		Summary:    summary,
+		Messages:   history,
+	}
+}
+
+// Parse reads a transcript written by JSON.
+func Parse(data []byte) (Transcript, error) {
+	var t Transcript
+	if err := json.Unmarshal(data, &t); err != nil || t.Format != Format {
+		return Transcript{}, ErrNotTranscript
+	}
+	if t.Version < 1 || t.Version > Version {
+		return Transcript{}, fmt.Errorf("export version %d is not supported; upgrade picoclaw", t.Version)
+	}
+	return t, nil
+}
+
+// JSON returns the transcript as indented JSON.
+func (t Transcript) JSON() ([]byte, error) {
+	return json.MarshalIndent(t, "", "  ")
+}
+
+// Markdown renders the transcript for reading. Tool calls and their results
+// are included, so the rendering is complete enough for an audit.
+func (t Transcript) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Conversation %s\n\n", t.SessionKey)
+	fmt.Fprintf(&b, "Exported %s", t.Exported.UTC().Format("2006-01-02 15:04 UTC"))
+	if t.Agent != "" {
+		fmt.Fprintf(&b, " from agent %s", t.Agent)
+	}
+	fmt.Fprintf(&b, ", %d messages.\n", len(t.Messages))
+	if t.Summary != "" {
+		fmt.Fprintf(&b, "\n## Summary of earlier messages\n\n%s\n", strings.TrimSpace(t.Summary))
+	}
+
+	for _, m := range t.Messages {
+		switch m.Role {
+		case "user":
+			b.WriteString("\n## User\n")
+		case "assistant":
+			b.WriteString("\n## Assistant\n")
+		case "tool":
+			fmt.Fprintf(&b, "\n## Tool result %s\n\n%s", m.ToolCallID, fenced(m.Content))
+			continue
+		default:
+			fmt.Fprintf(&b, "\n## %s\n", m.Role)
+		}
+		if content := strings.TrimSpace(m.Content); content != "" {
+			fmt.Fprintf(&b, "\n%s\n", content)
+		}
+		for _, media := range m.Media {
+			fmt.Fprintf(&b, "\nAttachment: %s\n", media)
+		}
+		for _, tc := range m.ToolCalls {
+			name, args := toolCall(tc)
+			fmt.Fprintf(&b, "\nCalled `%s` (%s):\n\n%s", name, tc.ID, fenced(args))
+		}
+	}
+	return b.String()
+}
+
+// toolCall returns the name and JSON arguments of tc, which are kept in
+// Function once the message has been stored.
+func toolCall(tc providers.ToolCall) (name, args string) {
+	name = tc.Name
+	if tc.Function != nil {
+		if name == "" {
+			name = tc.Function.Name
+		}
+		args = tc.Function.Arguments
+	}
+	if args == "" && len(tc.Arguments) > 0 {
+		if data, err := json.Marshal(tc.Arguments); err == nil {
+			args = string(data)
+		}
+	}
+	if args == "" {
+		args = "{}"
+	}
+	return name, args
+}
+
+// fenced wraps text in a code block whose fence is longer than any run of
+// backticks in text.
+func fenced(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + "\n" + strings.TrimRight(text, "\n") + "\n" + fence + "\n"
+}
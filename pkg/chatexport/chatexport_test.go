@@ -0,0 +1,136 @@
+package chatexport
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func testTranscript() Transcript {
+	return New("main", "agent:main:telegram:direct:7", "They talked about Lisbon.", []providers.Message{
+		{Role: "user", Content: "What is in notes.md?"},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{
+			ID:       "call_1",
+			Function: &providers.FunctionCall{Name: "read_file", Arguments: `{"path":"notes.md"}`},
+		}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "```go\nfmt.Println()\n```"},
+		{Role: "assistant", Content: "A Go snippet."},
+	})
+}
+
+func TestTranscript_JSONRoundtrip(t *testing.T) {
+	data, err := testTranscript().JSON()
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	got, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got.SessionKey != "agent:main:telegram:direct:7" || got.Summary == "" || len(got.Messages) != 4 ||
+		got.Messages[1].ToolCalls[0].Function.Name != "read_file" || got.Messages[2].ToolCallID != "call_1" {
+		t.Errorf("roundtrip = %+v", got)
+	}
+
+	if _, err := Parse([]byte(`[{"mapping": {}}]`)); !errors.Is(err, ErrNotTranscript) {
+		t.Errorf("Parse(ChatGPT export) = %v, want ErrNotTranscript", err)
+	}
+	if _, err := Parse([]byte(`{"format": "picoclaw-conversation", "version": 99}`)); err == nil ||
+		errors.Is(err, ErrNotTranscript) {
+		t.Errorf("Parse(future version) = %v, want a version error", err)
+	}
+}
+
+func TestTranscript_Markdown(t *testing.T) {
+	md := testTranscript().Markdown()
+	for _, want := range []string{
+		"# Conversation agent:main:telegram:direct:7\n",
+		" from agent main, 4 messages.",
+		"## Summary of earlier messages\n\nThey talked about Lisbon.",
+		"## User\n\nWhat is in notes.md?",
+		"Called `read_file` (call_1):\n\n```\n{\"path\":\"notes.md\"}\n```",
+		"## Tool result call_1\n\n````\n```go\nfmt.Println()\n```\n````",
+		"## Assistant\n\nA Go snippet.",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	sessions := map[string]Transcript{"s1": testTranscript()}
+	h := NewHandler([]string{"secret"},
+		func(agentID, key string) (Transcript, error) {
+			if agentID != "" && agentID != "main" {
+				return Transcript{}, ErrUnknownAgent
+			}
+			t, ok := sessions[key]
+			if !ok {
+				return Transcript{}, ErrUnknownSession
+			}
+			return t, nil
+		},
+		func(agentID, key string, t Transcript) error {
+			if _, ok := sessions[key]; ok {
+				return ErrSessionExists
+			}
+			sessions[key] = t
+			return nil
+		})
+	do := func(method, token, query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, HTTPPath+query, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodGet, "wrong", "?session=s1", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status %d", w.Code)
+	}
+	if w := do(http.MethodGet, "secret", "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("missing session: status %d", w.Code)
+	}
+	if w := do(http.MethodGet, "secret", "?session=s2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown session: status %d", w.Code)
+	}
+	if w := do(http.MethodGet, "secret", "?session=s1&agent=other", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown agent: status %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "secret", "?session=s1", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: status %d", w.Code)
+	}
+
+	w := do(http.MethodGet, "secret", "?session=s1&format=markdown", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "# Conversation ") {
+		t.Errorf("markdown export: %d %s", w.Code, w.Body.String())
+	}
+	w = do(http.MethodGet, "secret", "?session=s1", "")
+	exported := w.Body.String()
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("JSON export: %d %s", w.Code, exported)
+	}
+
+	if w := do(http.MethodPost, "secret", "?session=s1", exported); w.Code != http.StatusConflict {
+		t.Errorf("import over a session: status %d", w.Code)
+	}
+	if w := do(http.MethodPost, "secret", "?session=s2", `{"not": "an export"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("import of a bad body: status %d", w.Code)
+	}
+	w = do(http.MethodPost, "secret", "?session=s2", exported)
+	var resp importResponse
+	if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &resp) != nil || resp.Messages != 4 {
+		t.Fatalf("import: %d %s", w.Code, w.Body.String())
+	}
+	if got := sessions["s2"]; len(got.Messages) != 4 || got.Summary == "" {
+		t.Errorf("imported = %+v", got)
+	}
+}
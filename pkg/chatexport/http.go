@@ -0,0 +1,133 @@
+package chatexport
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/auth"
+)
+
+// HTTPPath is where the gateway mounts the conversations API.
+const HTTPPath = "/conversations"
+
+// maxImportBytes bounds the body of an import request.
+const maxImportBytes = 32 << 20
+
+// Errors the export and import functions return for the API to map to a
+// status code.
+var (
+	ErrUnknownAgent   = errors.New("no such agent")
+	ErrUnknownSession = errors.New("the session has no messages")
+	ErrSessionExists  = errors.New("the session already has messages")
+)
+
+// ExportFunc returns the transcript of a session of an agent; an empty
+// agentID means the default agent.
+type ExportFunc func(agentID, sessionKey string) (Transcript, error)
+
+// ImportFunc seeds a new session of an agent with the messages and summary
+// of t, failing with ErrSessionExists when the session has messages.
+type ImportFunc func(agentID, sessionKey string, t Transcript) error
+
+// Handler serves the conversations API.
+type Handler struct {
+	tokens []string
+	export ExportFunc
+	seed   ImportFunc
+}
+
+// NewHandler returns the API guarded by tokens. The API is off without a
+// token.
+func NewHandler(tokens []string, export ExportFunc, seed ImportFunc) *Handler {
+	return &Handler{tokens: tokens, export: export, seed: seed}
+}
+
+type importResponse struct {
+	Agent      string `json:"agent,omitempty"`
+	SessionKey string `json:"session_key"`
+	Messages   int    `json:"messages"`
+}
+
+// ServeHTTP implements the conversations API. Both methods take the session
+// key in the session query parameter and an optional agent ID in agent.
+// GET exports the session, as JSON or with format=markdown as Markdown. POST
+// a JSON export to seed the session, which must not have messages yet. It
+// requires "Authorization: Bearer <api_token>".
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	agentID, session := strings.TrimSpace(q.Get("agent")), strings.TrimSpace(q.Get("session"))
+	if session == "" {
+		http.Error(w, "the session query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		format := strings.ToLower(q.Get("format"))
+		if format != "" && format != "json" && format != "markdown" {
+			http.Error(w, "format must be json or markdown", http.StatusBadRequest)
+			return
+		}
+		t, err := h.export(agentID, session)
+		if err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		if format == "markdown" {
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			io.WriteString(w, t.Markdown())
+			return
+		}
+		data, err := t.JSON()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+		if err != nil {
+			http.Error(w, "the export is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		t, err := Parse(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.seed(agentID, session, t); err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(importResponse{Agent: agentID, SessionKey: session, Messages: len(t.Messages)})
+	}
+}
+
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, ErrUnknownAgent), errors.Is(err, ErrUnknownSession):
+		return http.StatusNotFound
+	case errors.Is(err, ErrSessionExists):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	return auth.BearerTokenMatches(r, h.tokens...)
+}
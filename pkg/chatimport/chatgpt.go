@@ -0,0 +1,107 @@
+package chatimport
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// chatgptConversation is one entry of a ChatGPT conversations.json. The
+// messages form a tree, since edited prompts and regenerated replies branch
+// off; current_node is the leaf of the branch the user last saw.
+type chatgptConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     float64                `json:"create_time"`
+	UpdateTime     float64                `json:"update_time"`
+	CurrentNode    string                 `json:"current_node"`
+	Mapping        map[string]chatgptNode `json:"mapping"`
+}
+
+type chatgptNode struct {
+	Parent  string `json:"parent"`
+	Message *struct {
+		Author struct {
+			Role string `json:"role"`
+		} `json:"author"`
+		CreateTime float64 `json:"create_time"`
+		Content    struct {
+			ContentType string            `json:"content_type"`
+			Parts       []json.RawMessage `json:"parts"`
+		} `json:"content"`
+		Metadata struct {
+			Hidden bool `json:"is_visually_hidden_from_conversation"`
+		} `json:"metadata"`
+	} `json:"message"`
+}
+
+func parseChatGPT(data []byte) ([]Conversation, error) {
+	var raw []chatgptConversation
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	convs := make([]Conversation, 0, len(raw))
+	for _, rc := range raw {
+		id := rc.ConversationID
+		if id == "" {
+			id = rc.ID
+		}
+		c := Conversation{
+			Source:  SourceChatGPT,
+			ID:      id,
+			Title:   rc.Title,
+			Created: unixTime(rc.CreateTime),
+			Updated: unixTime(rc.UpdateTime),
+		}
+		// Walk up from the current leaf; a cycle guard keeps a damaged
+		// export from looping.
+		seen := make(map[string]bool)
+		for key := rc.CurrentNode; key != "" && !seen[key]; key = rc.Mapping[key].Parent {
+			seen[key] = true
+			if m, ok := chatgptMessage(rc.Mapping[key]); ok {
+				c.Messages = append(c.Messages, m)
+			}
+		}
+		slices.Reverse(c.Messages)
+		convs = append(convs, c)
+	}
+	return convs, nil
+}
+
+// chatgptMessage returns the text of a visible user or assistant message.
+// Tool calls, system messages, and attachments without text are skipped.
+func chatgptMessage(node chatgptNode) (Message, bool) {
+	msg := node.Message
+	if msg == nil || msg.Metadata.Hidden {
+		return Message{}, false
+	}
+	role := msg.Author.Role
+	if role != "user" && role != "assistant" {
+		return Message{}, false
+	}
+	if ct := msg.Content.ContentType; ct != "text" && ct != "multimodal_text" {
+		return Message{}, false
+	}
+	var parts []string
+	for _, p := range msg.Content.Parts {
+		var s string
+		if json.Unmarshal(p, &s) == nil && strings.TrimSpace(s) != "" {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) == 0 {
+		return Message{}, false
+	}
+	return Message{Role: role, Text: strings.Join(parts, "\n\n"), Time: unixTime(msg.CreateTime)}, true
+}
+
+func unixTime(sec float64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
@@ -0,0 +1,238 @@
+// Package chatimport reads the conversation exports of ChatGPT, Claude, and
+// picoclaw itself and turns selected conversations into session history or
+// knowledge documents, so users moving to picoclaw, or to another picoclaw
+// server, keep their earlier context.
+package chatimport
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/chatexport"
+	"github.com/sipeed/picoclaw/pkg/knowledge"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Export sources.
+const (
+	SourceChatGPT  = "chatgpt"
+	SourceClaude   = "claude"
+	SourcePicoclaw = "picoclaw"
+)
+
+// conversationsFile is the file holding the conversations in both exports.
+const conversationsFile = "conversations.json"
+
+// ErrUnknownFormat means the file is not a ChatGPT, Claude, or picoclaw export.
+var ErrUnknownFormat = errors.New("not a ChatGPT, Claude, or picoclaw conversation export")
+
+// Message is one user or assistant message of a conversation.
+type Message struct {
+	Role string // "user" or "assistant"
+	Text string
+	Time time.Time
+}
+
+// Conversation is one exported conversation, oldest message first.
+type Conversation struct {
+	Source   string
+	ID       string
+	Title    string
+	Created  time.Time
+	Updated  time.Time
+	Messages []Message
+	// Summary is the summary of earlier messages a picoclaw export carries.
+	Summary string
+
+	// history is the complete session history of a picoclaw export,
+	// including tool calls and their results.
+	history []providers.Message
+}
+
+// URL links to the conversation on the service it was exported from.
+func (c Conversation) URL() string {
+	switch c.Source {
+	case SourceChatGPT:
+		return "https://chatgpt.com/c/" + c.ID
+	case SourceClaude:
+		return "https://claude.ai/chat/" + c.ID
+	}
+	return ""
+}
+
+// Read loads the conversations of an export: the archive as downloaded, its
+// extracted directory, or its conversations.json, or a file written by
+// picoclaw's /export. Conversations without messages are left out.
+func Read(name string) ([]Conversation, error) {
+	data, err := readConversations(name)
+	if err != nil {
+		return nil, err
+	}
+	t, err := chatexport.Parse(data)
+	if err == nil {
+		if c := fromTranscript(t); len(c.Messages) > 0 {
+			return []Conversation{c}, nil
+		}
+		return nil, nil
+	}
+	if !errors.Is(err, chatexport.ErrNotTranscript) {
+		return nil, err
+	}
+	var raw []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, ErrUnknownFormat
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var convs []Conversation
+	switch {
+	case raw[0]["mapping"] != nil:
+		convs, err = parseChatGPT(data)
+	case raw[0]["chat_messages"] != nil:
+		convs, err = parseClaude(data)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := convs[:0]
+	for _, c := range convs {
+		if len(c.Messages) > 0 {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func readConversations(name string) ([]byte, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return os.ReadFile(filepath.Join(name, conversationsFile))
+	}
+	if !strings.EqualFold(filepath.Ext(name), ".zip") {
+		return os.ReadFile(name)
+	}
+
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if path.Base(f.Name) != conversationsFile {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, fmt.Errorf("%s has no %s", name, conversationsFile)
+}
+
+// Select returns the conversations picked by selectors, in export order.
+// A selector is a 1-based position in the export, a conversation ID, or
+// part of a title (case-insensitive). No selectors pick every conversation.
+func Select(convs []Conversation, selectors []string) ([]Conversation, error) {
+	if len(selectors) == 0 {
+		return convs, nil
+	}
+	picked := make([]bool, len(convs))
+	for _, sel := range selectors {
+		sel = strings.TrimSpace(sel)
+		if sel == "" {
+			continue
+		}
+		found := false
+		if n, err := strconv.Atoi(sel); err == nil {
+			if n < 1 || n > len(convs) {
+				return nil, fmt.Errorf("no conversation #%d; the export has %d", n, len(convs))
+			}
+			picked[n-1], found = true, true
+		}
+		for i, c := range convs {
+			if c.ID == sel || strings.Contains(strings.ToLower(c.Title), strings.ToLower(sel)) {
+				picked[i], found = true, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no conversation matches %q", sel)
+		}
+	}
+	var out []Conversation
+	for i, c := range convs {
+		if picked[i] {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// History returns the last maxMessages messages of c as session history,
+// starting with a user message. maxMessages <= 0 keeps all of them.
+func (c Conversation) History(maxMessages int) []providers.Message {
+	if c.history != nil {
+		history := c.history
+		if maxMessages > 0 && len(history) > maxMessages {
+			history = history[len(history)-maxMessages:]
+		}
+		for len(history) > 0 && history[0].Role != "user" {
+			history = history[1:]
+		}
+		return history
+	}
+	msgs := c.Messages
+	if maxMessages > 0 && len(msgs) > maxMessages {
+		msgs = msgs[len(msgs)-maxMessages:]
+	}
+	for len(msgs) > 0 && msgs[0].Role != "user" {
+		msgs = msgs[1:]
+	}
+	history := make([]providers.Message, 0, len(msgs))
+	for _, m := range msgs {
+		history = append(history, providers.Message{Role: m.Role, Content: m.Text})
+	}
+	return history
+}
+
+// Document returns c as a knowledge document, split into passages, so the
+// knowledge_search tool can recall it.
+func (c Conversation) Document() *knowledge.Document {
+	var b strings.Builder
+	for _, m := range c.Messages {
+		who := "User"
+		if m.Role == "assistant" {
+			who = "Assistant"
+		}
+		fmt.Fprintf(&b, "%s: %s\n\n", who, m.Text)
+	}
+	updated := c.Updated
+	if updated.IsZero() {
+		updated = c.Created
+	}
+	return &knowledge.Document{
+		ID:         c.Source + ":" + c.ID,
+		Collection: "import:" + c.Source,
+		Title:      c.Title,
+		URL:        c.URL(),
+		Updated:    updated,
+		Chunks:     knowledge.Chunk(b.String()),
+	}
+}
@@ -0,0 +1,159 @@
+package chatimport
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/chatexport"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const chatgptExport = `[{
+  "title": "Trip to Lisbon",
+  "create_time": 1714550400.5,
+  "update_time": 1714554000,
+  "conversation_id": "c-1",
+  "current_node": "a2",
+  "mapping": {
+    "root": {"parent": null, "message": null},
+    "sys": {"parent": "root", "message": {"author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]}, "metadata": {"is_visually_hidden_from_conversation": true}}},
+    "u1": {"parent": "sys", "message": {"author": {"role": "user"}, "create_time": 1714550401, "content": {"content_type": "text", "parts": ["Plan three days in Lisbon"]}}},
+    "a1": {"parent": "u1", "message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["Discarded draft"]}}},
+    "a2": {"parent": "u1", "message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["Day 1: Alfama"]}}}
+  }
+}, {
+  "title": "Empty", "conversation_id": "c-2", "current_node": "x", "mapping": {"x": {"parent": null, "message": null}}
+}]`
+
+const claudeExport = `[{
+  "uuid": "u-1",
+  "name": "Go generics",
+  "created_at": "2024-05-01T12:00:00Z",
+  "updated_at": "2024-05-01T12:05:00Z",
+  "chat_messages": [
+    {"sender": "human", "text": "Explain type sets", "created_at": "2024-05-01T12:00:00Z"},
+    {"sender": "assistant", "text": "", "content": [{"type": "text", "text": "A type set is"}, {"type": "tool_use"}, {"type": "text", "text": "the set of types."}]}
+  ]
+}, {
+  "uuid": "u-2", "name": "Sourdough starter", "chat_messages": [{"sender": "human", "text": "Feed ratio?"}]
+}]`
+
+func writeZip(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "export.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create(name)
+	w.Write([]byte(content))
+	zw.Close()
+	f.Close()
+	return path
+}
+
+func TestRead_ChatGPT(t *testing.T) {
+	convs, err := Read(writeZip(t, "export/conversations.json", chatgptExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 1 {
+		t.Fatalf("got %d conversations, want the one with messages", len(convs))
+	}
+	c := convs[0]
+	if c.Source != SourceChatGPT || c.ID != "c-1" || c.Title != "Trip to Lisbon" || c.Created.Unix() != 1714550400 {
+		t.Errorf("conversation = %+v", c)
+	}
+	if len(c.Messages) != 2 || c.Messages[0].Text != "Plan three days in Lisbon" || c.Messages[1].Text != "Day 1: Alfama" {
+		t.Errorf("messages = %+v", c.Messages)
+	}
+	if c.URL() != "https://chatgpt.com/c/c-1" {
+		t.Errorf("URL = %s", c.URL())
+	}
+}
+
+func TestRead_Claude(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.json")
+	os.WriteFile(path, []byte(claudeExport), 0o644)
+	convs, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 2 || convs[0].Source != SourceClaude || convs[0].Title != "Go generics" {
+		t.Fatalf("conversations = %+v", convs)
+	}
+	if m := convs[0].Messages; len(m) != 2 || m[0].Role != "user" || m[1].Text != "A type set is\n\nthe set of types." {
+		t.Errorf("messages = %+v", m)
+	}
+
+	os.WriteFile(path, []byte(`[{"foo": 1}]`), 0o644)
+	if _, err := Read(path); err != ErrUnknownFormat {
+		t.Errorf("unknown format err = %v", err)
+	}
+}
+
+func TestRead_Picoclaw(t *testing.T) {
+	export := chatexport.New("main", "agent:main:main", "Earlier: greetings.", []providers.Message{
+		{Role: "assistant", Content: "Left over from a compacted turn"},
+		{Role: "user", Content: "Read notes.md\nplease"},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{
+			{ID: "call_1", Function: &providers.FunctionCall{Name: "read_file"}},
+		}},
+		{Role: "tool", ToolCallID: "call_1", Content: "buy milk"},
+		{Role: "assistant", Content: "It says to buy milk."},
+	})
+	data, _ := export.JSON()
+	path := filepath.Join(t.TempDir(), "conversation.json")
+	os.WriteFile(path, data, 0o644)
+
+	convs, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 1 || convs[0].Source != SourcePicoclaw || convs[0].Title != "Read notes.md" ||
+		convs[0].Summary != "Earlier: greetings." || len(convs[0].Messages) != 3 {
+		t.Fatalf("conversations = %+v", convs)
+	}
+	h := convs[0].History(0)
+	if len(h) != 4 || h[0].Role != "user" || h[1].ToolCalls[0].ID != "call_1" || h[2].Role != "tool" {
+		t.Errorf("history = %+v", h)
+	}
+	if h := convs[0].History(3); len(h) != 0 {
+		t.Errorf("history must start with a user message: %+v", h)
+	}
+}
+
+func TestSelectAndConvert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.json")
+	os.WriteFile(path, []byte(claudeExport), 0o644)
+	convs, _ := Read(path)
+
+	for sel, want := range map[string]string{"2": "u-2", "u-1": "u-1", "SOURDOUGH": "u-2"} {
+		got, err := Select(convs, []string{sel})
+		if err != nil || len(got) != 1 || got[0].ID != want {
+			t.Errorf("Select(%q) = %v, %v", sel, got, err)
+		}
+	}
+	if _, err := Select(convs, []string{"3"}); err == nil {
+		t.Error("out-of-range position accepted")
+	}
+	if _, err := Select(convs, []string{"knitting"}); err == nil {
+		t.Error("unmatched title accepted")
+	}
+
+	if h := convs[0].History(1); len(h) != 0 {
+		t.Errorf("history must start with a user message: %+v", h)
+	}
+	if h := convs[0].History(0); len(h) != 2 || h[0].Role != "user" || h[1].Role != "assistant" {
+		t.Errorf("history = %+v", h)
+	}
+	doc := convs[0].Document()
+	if doc.ID != "claude:u-1" || doc.Collection != "import:claude" || doc.URL != "https://claude.ai/chat/u-1" ||
+		len(doc.Chunks) != 1 || !strings.HasPrefix(doc.Chunks[0], "User: Explain type sets") {
+		t.Errorf("document = %+v", doc)
+	}
+}
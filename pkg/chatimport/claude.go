@@ -0,0 +1,65 @@
+package chatimport
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// claudeConversation is one entry of a Claude conversations.json.
+type claudeConversation struct {
+	UUID         string    `json:"uuid"`
+	Name         string    `json:"name"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	ChatMessages []struct {
+		Sender    string    `json:"sender"` // "human" or "assistant"
+		Text      string    `json:"text"`
+		CreatedAt time.Time `json:"created_at"`
+		Content   []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"chat_messages"`
+}
+
+func parseClaude(data []byte) ([]Conversation, error) {
+	var raw []claudeConversation
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	convs := make([]Conversation, 0, len(raw))
+	for _, rc := range raw {
+		c := Conversation{
+			Source:  SourceClaude,
+			ID:      rc.UUID,
+			Title:   rc.Name,
+			Created: rc.CreatedAt,
+			Updated: rc.UpdatedAt,
+		}
+		for _, m := range rc.ChatMessages {
+			role := "assistant"
+			if m.Sender == "human" {
+				role = "user"
+			}
+			// Newer exports split messages into content blocks; the text
+			// blocks together are the message, tool use is skipped.
+			text := m.Text
+			if len(m.Content) > 0 {
+				var parts []string
+				for _, block := range m.Content {
+					if block.Type == "text" && strings.TrimSpace(block.Text) != "" {
+						parts = append(parts, block.Text)
+					}
+				}
+				text = strings.Join(parts, "\n\n")
+			}
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			c.Messages = append(c.Messages, Message{Role: role, Text: text, Time: m.CreatedAt})
+		}
+		convs = append(convs, c)
+	}
+	return convs, nil
+}
@@ -0,0 +1,44 @@
+package chatimport
+
+import (
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/chatexport"
+)
+
+// maxTitleRunes bounds a title taken from the first user message.
+const maxTitleRunes = 60
+
+// fromTranscript turns a picoclaw export into a conversation. Its complete
+// history is kept for session imports; Messages holds the user and
+// assistant text for listing and knowledge documents.
+func fromTranscript(t chatexport.Transcript) Conversation {
+	c := Conversation{
+		Source:  SourcePicoclaw,
+		ID:      t.SessionKey,
+		Created: t.Exported,
+		Updated: t.Exported,
+		Summary: t.Summary,
+		history: t.Messages,
+	}
+	for _, m := range t.Messages {
+		text := strings.TrimSpace(m.Content)
+		if (m.Role != "user" && m.Role != "assistant") || text == "" {
+			continue
+		}
+		if c.Title == "" && m.Role == "user" {
+			c.Title = titleFrom(text)
+		}
+		c.Messages = append(c.Messages, Message{Role: m.Role, Text: text, Time: t.Exported})
+	}
+	return c
+}
+
+// titleFrom returns the first line of text, shortened to maxTitleRunes.
+func titleFrom(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	if runes := []rune(line); len(runes) > maxTitleRunes {
+		return strings.TrimSpace(string(runes[:maxTitleRunes])) + "…"
+	}
+	return line
+}
@@ -13,5 +13,33 @@ func BuiltinDefinitions() []Definition {
 		switchCommand(),
 		checkCommand(),
 		clearCommand(),
+		maintenanceCommand(),
+		calendarCommand(),
+		approveCommand(),
+		denyCommand(),
+		translateCommand(),
+		prefsCommand(),
+		branchCommand(),
+		variantCommand(),
+		feedbackCommand(),
+		factCommand(),
+		promptCommand(),
+		rememberCommand(),
+		forgetCommand(),
+		kbCommand(),
+		stopCommand(),
+		transcriptCommand(),
+		tempCommand(),
+		maxTokensCommand(),
+		verbosityCommand(),
+		modelCommand(),
+		personaCommand(),
+		configCommand(),
+		searchCommand(),
+		exportCommand(),
+		forgetMeCommand(),
+		contextCommand(),
+		quotaCommand(),
+		usageCommand(),
 	}
 }
@@ -0,0 +1,51 @@
+package commands
+
+import "context"
+
+func approveCommand() Definition {
+	return Definition{
+		Name:        "approve",
+		Description: "Run a tool action that is waiting for approval",
+		Usage:       "/approve <id>",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			return resolveApproval(req, rt, true)
+		},
+	}
+}
+
+func denyCommand() Definition {
+	return Definition{
+		Name:        "deny",
+		Description: "Cancel a tool action that is waiting for approval",
+		Usage:       "/deny <id>",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			return resolveApproval(req, rt, false)
+		},
+	}
+}
+
+func resolveApproval(req Request, rt *Runtime, approve bool) error {
+	if rt == nil || rt.ResolveApproval == nil {
+		return req.Reply(unavailableMsg)
+	}
+	id := req.Arg(0)
+	if id == "" {
+		if approve {
+			return req.Reply("Usage: /approve <id>")
+		}
+		return req.Reply("Usage: /deny <id>")
+	}
+	summary, output, err := rt.ResolveApproval(id, approve)
+	switch {
+	case err != nil && summary == "":
+		return req.Reply("No pending action " + id + ". It may have expired or belong to someone else.")
+	case err != nil:
+		return req.Reply("❌ " + summary + " failed: " + err.Error())
+	case !approve:
+		return req.Reply("Cancelled: " + summary)
+	case output != "":
+		return req.Reply("✅ " + summary + "\n" + output)
+	default:
+		return req.Reply("✅ " + summary)
+	}
+}
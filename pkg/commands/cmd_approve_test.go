@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApprove_ReportsOutcome(t *testing.T) {
+	var gotID string
+	var gotApprove bool
+	rt := &Runtime{
+		ResolveApproval: func(id string, approve bool) (string, string, error) {
+			gotID, gotApprove = id, approve
+			return "Home Assistant light.turn_on", "Called light.turn_on", nil
+		},
+	}
+	if reply := runCommand(t, rt, "/approve A1B2C3"); reply != "✅ Home Assistant light.turn_on\nCalled light.turn_on" {
+		t.Fatalf("reply=%q", reply)
+	}
+	if gotID != "A1B2C3" || !gotApprove {
+		t.Fatalf("ResolveApproval(%q, %v)", gotID, gotApprove)
+	}
+	if reply := runCommand(t, rt, "/deny A1B2C3"); reply != "Cancelled: Home Assistant light.turn_on" || gotApprove {
+		t.Fatalf("deny reply=%q", reply)
+	}
+}
+
+func TestApprove_UnknownIDAndFailure(t *testing.T) {
+	rt := &Runtime{
+		ResolveApproval: func(string, bool) (string, string, error) { return "", "", errors.New("no pending action") },
+	}
+	if reply := runCommand(t, rt, "/approve ZZZ"); reply != "No pending action ZZZ. It may have expired or belong to someone else." {
+		t.Fatalf("reply=%q", reply)
+	}
+	rt.ResolveApproval = func(string, bool) (string, string, error) { return "x.y", "", errors.New("timeout") }
+	if reply := runCommand(t, rt, "/approve 1"); reply != "❌ x.y failed: timeout" {
+		t.Fatalf("reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/approve"); reply != "Usage: /approve <id>" {
+		t.Fatalf("reply=%q", reply)
+	}
+}
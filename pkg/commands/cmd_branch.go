@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/branch"
+)
+
+const branchUsage = "Usage: /branch <message link or ID> [name] — fork the conversation from that message\n" +
+	"/branch list — show the branches of this chat\n" +
+	"/branch switch <name|main> — continue on another branch"
+
+func branchCommand() Definition {
+	return Definition{
+		Name:        "branch",
+		Description: "Fork the conversation from an earlier message or switch branches",
+		Usage:       "/branch <message-link> [name]|list|switch <name>",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.ForkBranch == nil || rt.SwitchBranch == nil || rt.ListBranches == nil {
+				return req.Reply(unavailableMsg)
+			}
+			switch arg := req.Arg(0); strings.ToLower(arg) {
+			case "":
+				return req.Reply(branchUsage)
+			case "list":
+				active, branches := rt.ListBranches()
+				return req.Reply(formatBranches(active, branches))
+			case "switch":
+				name := req.Arg(1)
+				if name == "" {
+					return req.Reply(branchUsage)
+				}
+				if err := rt.SwitchBranch(name); err != nil {
+					return req.Reply(err.Error())
+				}
+				return req.Reply(fmt.Sprintf("Switched to branch %s.", strings.ToLower(name)))
+			default:
+				name, err := rt.ForkBranch(arg, req.Arg(1))
+				if err != nil {
+					return req.Reply("Could not branch: " + err.Error())
+				}
+				return req.Reply(fmt.Sprintf(
+					"Created branch %s from that message and switched to it. Use /branch switch %s to go back.",
+					name, branch.Main))
+			}
+		},
+	}
+}
+
+func formatBranches(active string, branches []branch.Branch) string {
+	var b strings.Builder
+	line := func(name, detail string) {
+		marker := "  "
+		if name == active {
+			marker = "* "
+		}
+		fmt.Fprintf(&b, "\n%s%s%s", marker, name, detail)
+	}
+	b.WriteString("Branches:")
+	line(branch.Main, "")
+	for _, br := range branches {
+		line(br.Name, fmt.Sprintf(" (from %s at message %s, %s)",
+			br.Parent, br.From, br.Created.Format("2006-01-02 15:04")))
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/branch"
+)
+
+func TestBranch_Command(t *testing.T) {
+	var forkedRef, forkedName, switched string
+	rt := &Runtime{
+		ForkBranch: func(ref, name string) (string, error) {
+			forkedRef, forkedName = ref, name
+			if ref == "missing" {
+				return "", branch.ErrUnknownMessage
+			}
+			return "b1", nil
+		},
+		SwitchBranch: func(name string) error {
+			if name != "b1" && name != "main" {
+				return branch.ErrUnknownBranch
+			}
+			switched = name
+			return nil
+		},
+		ListBranches: func() (string, []branch.Branch) {
+			return "b1", []branch.Branch{{Name: "b1", Parent: "main", From: "42"}}
+		},
+	}
+
+	if reply := runCommand(t, rt, "/branch"); reply != branchUsage {
+		t.Fatalf("bare reply=%q", reply)
+	}
+	reply := runCommand(t, rt, "/branch https://discord.com/channels/1/2/42")
+	if !strings.HasPrefix(reply, "Created branch b1") || forkedRef != "https://discord.com/channels/1/2/42" || forkedName != "" {
+		t.Fatalf("fork reply=%q ref=%q name=%q", reply, forkedRef, forkedName)
+	}
+	if runCommand(t, rt, "/branch 42 idea"); forkedName != "idea" {
+		t.Fatalf("fork name=%q", forkedName)
+	}
+	if reply := runCommand(t, rt, "/branch missing"); reply != "Could not branch: "+branch.ErrUnknownMessage.Error() {
+		t.Fatalf("fork error reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/branch switch main"); reply != "Switched to branch main." || switched != "main" {
+		t.Fatalf("switch reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/branch switch nope"); reply != branch.ErrUnknownBranch.Error() {
+		t.Fatalf("switch unknown reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/branch list"); !strings.Contains(reply, "  main\n* b1 (from main at message 42") {
+		t.Fatalf("list reply=%q", reply)
+	}
+	if reply := runCommand(t, &Runtime{}, "/branch list"); reply != unavailableMsg {
+		t.Fatalf("unavailable reply=%q", reply)
+	}
+}
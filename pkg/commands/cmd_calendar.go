@@ -0,0 +1,56 @@
+package commands
+
+import "context"
+
+func calendarCommand() Definition {
+	return Definition{
+		Name:        "calendar",
+		Description: "Link your Google Calendar",
+		SubCommands: []SubCommand{
+			{
+				Name:        "link",
+				Description: "Get a sign-in link for your Google account",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.CalendarLink == nil {
+						return req.Reply(unavailableMsg)
+					}
+					authURL, err := rt.CalendarLink()
+					if err != nil {
+						return req.Reply("Could not start linking: " + err.Error())
+					}
+					return req.Reply("Open this link within 10 minutes to connect your Google Calendar:\n" + authURL)
+				},
+			},
+			{
+				Name:        "unlink",
+				Description: "Disconnect your Google account",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.CalendarUnlink == nil {
+						return req.Reply(unavailableMsg)
+					}
+					unlinked, err := rt.CalendarUnlink()
+					if err != nil {
+						return req.Reply("Failed to unlink your calendar: " + err.Error())
+					}
+					if !unlinked {
+						return req.Reply("No calendar is linked.")
+					}
+					return req.Reply("Calendar unlinked.")
+				},
+			},
+			{
+				Name:        "status",
+				Description: "Show whether your calendar is linked",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.CalendarLinked == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if rt.CalendarLinked() {
+						return req.Reply("Your Google Calendar is linked.")
+					}
+					return req.Reply("No calendar is linked. Use /calendar link to connect one.")
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCalendar_LinkRepliesWithURL(t *testing.T) {
+	rt := &Runtime{
+		CalendarLink: func() (string, error) { return "https://accounts.google.com/auth?x=1", nil },
+	}
+	reply := runCommand(t, rt, "/calendar link")
+	if !strings.HasSuffix(reply, "\nhttps://accounts.google.com/auth?x=1") {
+		t.Fatalf("reply=%q", reply)
+	}
+
+	rt.CalendarLink = func() (string, error) { return "", errors.New("use a direct message") }
+	if reply := runCommand(t, rt, "/calendar link"); reply != "Could not start linking: use a direct message" {
+		t.Fatalf("reply=%q", reply)
+	}
+}
+
+func TestCalendar_UnlinkAndStatus(t *testing.T) {
+	linked := true
+	rt := &Runtime{
+		CalendarLinked: func() bool { return linked },
+		CalendarUnlink: func() (bool, error) {
+			was := linked
+			linked = false
+			return was, nil
+		},
+	}
+	if reply := runCommand(t, rt, "/calendar status"); reply != "Your Google Calendar is linked." {
+		t.Fatalf("reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/calendar unlink"); reply != "Calendar unlinked." {
+		t.Fatalf("reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/calendar unlink"); reply != "No calendar is linked." {
+		t.Fatalf("reply=%q", reply)
+	}
+}
+
+func TestCalendar_UnavailableWithoutLinker(t *testing.T) {
+	if reply := runCommand(t, &Runtime{}, "/calendar link"); reply != unavailableMsg {
+		t.Fatalf("reply=%q", reply)
+	}
+}
@@ -18,7 +18,7 @@ func checkCommand() Definition {
 					if rt == nil || rt.SwitchChannel == nil {
 						return req.Reply(unavailableMsg)
 					}
-					value := nthToken(req.Text, 2)
+					value := req.Arg(0)
 					if value == "" {
 						return req.Reply("Usage: /check channel <name>")
 					}
@@ -12,7 +12,7 @@ func clearCommand() Definition {
 				return req.Reply(unavailableMsg)
 			}
 			if err := rt.ClearHistory(); err != nil {
-				return req.Reply("Failed to clear chat history: " + err.Error())
+				return req.Reply(req.T("Failed to clear chat history: %v", err))
 			}
 			return req.Reply("Chat history cleared!")
 		},
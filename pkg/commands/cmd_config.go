@@ -0,0 +1,228 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+const guildAdminOnlyMsg = "Only server admins can change the settings of this server."
+
+// guildConfigKeys are the settings /config changes, in the order /config
+// show lists them.
+var guildConfigKeys = []string{"temperature", "max_tokens", "verbosity", "model", "persona", "language"}
+
+func configCommand() Definition {
+	return Definition{
+		Name:        "config",
+		Description: "Show or change the settings of this server",
+		SubCommands: []SubCommand{
+			{
+				Name:        "show",
+				Description: "Show the settings of this server",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.GuildSettings == nil {
+						return req.Reply(unavailableMsg)
+					}
+					s, limits := rt.GuildSettings()
+					return req.Reply(showGuildConfig(s, limits))
+				},
+			},
+			{
+				Name:        "set",
+				Description: "Change a setting for every conversation in this server",
+				ArgsUsage:   "<temperature|max_tokens|verbosity|model|persona|language> <value>",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					key, value := normalizeConfigKey(req.Arg(0)), req.Arg(1)
+					if key == "" || value == "" {
+						return req.Reply("Usage: /config set <" + strings.Join(guildConfigKeys, "|") + "> <value>")
+					}
+					return adjustGuildConfig(req, rt, func(s *tuning.Settings, l tuning.Limits) (string, error) {
+						return setGuildConfig(rt, s, l, key, value)
+					})
+				},
+			},
+			{
+				Name:        "reset",
+				Description: "Go back to the configured value of one setting, or of all",
+				ArgsUsage:   "[setting]",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					key := normalizeConfigKey(req.Arg(0))
+					if req.Arg(0) != "" && key == "" {
+						return req.Reply(fmt.Sprintf("Unknown setting %s; use one of %s.",
+							req.Arg(0), strings.Join(guildConfigKeys, ", ")))
+					}
+					return adjustGuildConfig(req, rt, func(s *tuning.Settings, _ tuning.Limits) (string, error) {
+						if key == "" {
+							*s = tuning.Settings{}
+							return "All settings of this server are reset to the configured values.", nil
+						}
+						resetGuildConfig(s, key)
+						return fmt.Sprintf("%s reset to the configured value for this server.", key), nil
+					})
+				},
+			},
+		},
+	}
+}
+
+// adjustGuildConfig runs change on the settings of this guild and saves them.
+// Only server admins may change them.
+func adjustGuildConfig(
+	req Request,
+	rt *Runtime,
+	change func(s *tuning.Settings, l tuning.Limits) (string, error),
+) error {
+	if rt == nil || rt.GuildSettings == nil || rt.SetGuildSettings == nil {
+		return req.Reply(unavailableMsg)
+	}
+	if rt.IsGuildAdmin == nil || !rt.IsGuildAdmin() {
+		return req.Reply(guildAdminOnlyMsg)
+	}
+	settings, limits := rt.GuildSettings()
+	reply, err := change(&settings, limits)
+	if err != nil {
+		return req.Reply(err.Error())
+	}
+	if err := rt.SetGuildSettings(settings); err != nil {
+		return req.Reply("Failed to save the setting: " + err.Error())
+	}
+	return req.Reply(reply)
+}
+
+// normalizeConfigKey returns the /config key name matches, or "" for an
+// unknown one.
+func normalizeConfigKey(name string) string {
+	switch key := strings.ToLower(strings.ReplaceAll(name, "-", "_")); key {
+	case "temp":
+		return "temperature"
+	case "maxtokens":
+		return "max_tokens"
+	case "lang", "locale":
+		return "language"
+	default:
+		for _, k := range guildConfigKeys {
+			if k == key {
+				return k
+			}
+		}
+		return ""
+	}
+}
+
+func setGuildConfig(rt *Runtime, s *tuning.Settings, l tuning.Limits, key, value string) (string, error) {
+	switch key {
+	case "temperature":
+		v, clamped, err := l.ParseTemperature(value)
+		if err != nil {
+			return "", err
+		}
+		s.Temperature = &v
+		return guildSetReply(key, formatFloat(v), clamped, floatRange(l.GenerationConfig.Temperature)), nil
+	case "max_tokens":
+		v, clamped, err := l.ParseMaxTokens(value)
+		if err != nil {
+			return "", err
+		}
+		s.MaxTokens = v
+		return guildSetReply(key, strconv.Itoa(v), clamped, intRange(l.GenerationConfig.MaxTokens)), nil
+	case "verbosity":
+		v, err := tuning.ParseVerbosity(value)
+		if err != nil {
+			return "", err
+		}
+		s.Verbosity = v
+		return guildSetReply(key, v, false, ""), nil
+	case "model":
+		if rt.CheckModel == nil {
+			return "", fmt.Errorf("the model cannot be changed here")
+		}
+		// Model names are case-sensitive, unlike the other settings.
+		if err := rt.CheckModel(value); err != nil {
+			return "", err
+		}
+		s.Model = value
+		return guildSetReply(key, value, false, ""), nil
+	case "language":
+		code := i18n.Match(value)
+		if code == "" {
+			return "", fmt.Errorf("there is no translation for %s; use one of %s", value, languageCodes())
+		}
+		s.Language = code
+		return guildSetReply(key, code, false, ""), nil
+	default: // persona
+		if rt.Config == nil || len(rt.Config.Personas.Profiles) == 0 {
+			return "", fmt.Errorf("no personas are configured")
+		}
+		name, ok := findPersona(rt.Config.Personas.Profiles, value)
+		if !ok {
+			return "", fmt.Errorf("persona %s is not configured; see /persona list", value)
+		}
+		// The persona brings its own model and temperature.
+		s.Persona, s.Model, s.Temperature = name, "", nil
+		return guildSetReply(key, name, false, ""), nil
+	}
+}
+
+func resetGuildConfig(s *tuning.Settings, key string) {
+	switch key {
+	case "temperature":
+		s.Temperature = nil
+	case "max_tokens":
+		s.MaxTokens = 0
+	case "verbosity":
+		s.Verbosity = ""
+	case "model":
+		s.Model = ""
+	case "persona":
+		s.Persona = ""
+	case "language":
+		s.Language = ""
+	}
+}
+
+func guildSetReply(key, value string, clamped bool, allowed string) string {
+	if clamped {
+		return fmt.Sprintf("%s set to %s for this server, the closest allowed value (%s).", key, value, allowed)
+	}
+	return fmt.Sprintf("%s set to %s for this server.", key, value)
+}
+
+func showGuildConfig(s tuning.Settings, l tuning.Limits) string {
+	var b strings.Builder
+	b.WriteString("Settings of this server (conversations can still override them):")
+	line := func(key, value string, set bool) {
+		state := "configured"
+		if set {
+			state = "set for this server"
+		}
+		fmt.Fprintf(&b, "\n- %s: %s (%s)", key, value, state)
+	}
+	line("temperature", formatFloat(l.Temperature(s)), s.Temperature != nil)
+	line("max_tokens", strconv.Itoa(l.MaxTokens(s)), s.MaxTokens != 0)
+	line("verbosity", valueOr(s.Verbosity, "default"), s.Verbosity != "")
+	line("model", valueOr(s.Model, "default"), s.Model != "")
+	line("persona", valueOr(s.Persona, "default"), s.Persona != "")
+	line("language", valueOr(s.Language, "detected"), s.Language != "")
+	return b.String()
+}
+
+// languageCodes lists the languages the bot's messages are available in.
+func languageCodes() string {
+	var codes []string
+	for _, l := range i18n.Languages() {
+		codes = append(codes, l.Code+" ("+l.Name+")")
+	}
+	return strings.Join(codes, ", ")
+}
+
+func valueOr(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+func TestConfig_Command(t *testing.T) {
+	var saved tuning.Settings
+	admin := false
+	limits := tuning.Limits{
+		GenerationConfig:   config.GenerationConfig{Temperature: config.FloatRange{Min: 0, Max: 1}},
+		DefaultTemperature: 0.7,
+		DefaultMaxTokens:   4096,
+	}
+	rt := &Runtime{
+		Config: &config.Config{Personas: config.PersonasConfig{Profiles: map[string]config.PersonaConfig{
+			"support": {},
+		}}},
+		CheckModel: func(name string) error {
+			if name != "small" {
+				return errors.New("model " + name + " is not in the config")
+			}
+			return nil
+		},
+		IsGuildAdmin:     func() bool { return admin },
+		GuildSettings:    func() (tuning.Settings, tuning.Limits) { return saved, limits },
+		SetGuildSettings: func(s tuning.Settings) error { saved = s; return nil },
+	}
+
+	if reply := runCommand(t, rt, "/config set temperature 0.3"); reply != guildAdminOnlyMsg {
+		t.Fatalf("non-admin reply = %q", reply)
+	}
+	admin = true
+	if reply := runCommand(t, rt, "/config set temperature 2"); reply !=
+		"temperature set to 1 for this server, the closest allowed value (0–1)." {
+		t.Fatalf("clamped reply = %q", reply)
+	}
+	runCommand(t, rt, "/config set maxtokens 1000")
+	if reply := runCommand(t, rt, "/config set model large"); !strings.Contains(reply, "not in the config") {
+		t.Fatalf("unknown model reply = %q", reply)
+	}
+	if reply := runCommand(t, rt, "/config set color blue"); !strings.HasPrefix(reply, "Usage: /config set") {
+		t.Fatalf("unknown key reply = %q", reply)
+	}
+
+	reply := runCommand(t, rt, "/config show")
+	for _, want := range []string{
+		"- temperature: 1 (set for this server)", "- max_tokens: 1000 (set for this server)",
+		"- verbosity: default (configured)",
+	} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("show lacks %q:\n%s", want, reply)
+		}
+	}
+
+	if reply := runCommand(t, rt, "/config set persona Support"); reply != "persona set to support for this server." {
+		t.Fatalf("persona reply = %q", reply)
+	}
+	if saved.Persona != "support" || saved.Temperature != nil || saved.MaxTokens != 1000 {
+		t.Fatalf("saved = %+v, want the persona without a temperature", saved)
+	}
+	if reply := runCommand(t, rt, "/config set language Deutsch"); reply != "language set to de for this server." {
+		t.Fatalf("language reply = %q", reply)
+	}
+	if reply := runCommand(t, rt, "/config set lang klingon"); !strings.Contains(reply, "no translation for klingon") {
+		t.Fatalf("unknown language reply = %q", reply)
+	}
+	runCommand(t, rt, "/config reset max_tokens")
+	if saved.MaxTokens != 0 || saved.Persona != "support" {
+		t.Fatalf("after resetting max_tokens: %+v", saved)
+	}
+	runCommand(t, rt, "/config reset")
+	if !saved.IsZero() {
+		t.Fatalf("after reset: %+v", saved)
+	}
+
+	if reply := runCommand(t, &Runtime{}, "/config show"); reply != unavailableMsg {
+		t.Fatalf("outside guild reply = %q", reply)
+	}
+}
@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ContextSection is one part of the next request to the model. Sections
+// with the same Group are listed together under it.
+type ContextSection struct {
+	Group  string
+	Name   string
+	Tokens int
+}
+
+func contextCommand() Definition {
+	return Definition{
+		Name:        "context",
+		Description: "Show what the next request sends to the model",
+		Usage:       "/context",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.InspectContext == nil {
+				return req.Reply(unavailableMsg)
+			}
+			sections, window, err := rt.InspectContext()
+			if err != nil {
+				return req.Reply(err.Error())
+			}
+			return req.Reply(formatContext(sections, window))
+		},
+	}
+}
+
+// formatContext lists the groups of sections in the order they first
+// appear, each with its total.
+func formatContext(sections []ContextSection, window int) string {
+	var groups []string
+	byGroup := make(map[string][]ContextSection)
+	total := 0
+	for _, s := range sections {
+		if _, ok := byGroup[s.Group]; !ok {
+			groups = append(groups, s.Group)
+		}
+		byGroup[s.Group] = append(byGroup[s.Group], s)
+		total += s.Tokens
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Next request: about %d tokens", total)
+	if window > 0 {
+		fmt.Fprintf(&b, " of a %d-token context window (%d%%)", window, total*100/window)
+	}
+	b.WriteString(", before your next message.")
+	for _, group := range groups {
+		items := byGroup[group]
+		sum := 0
+		for _, s := range items {
+			sum += s.Tokens
+		}
+		fmt.Fprintf(&b, "\n\n%s: %d", group, sum)
+		if len(items) == 1 && items[0].Name == "" {
+			continue
+		}
+		for _, s := range items {
+			fmt.Fprintf(&b, "\n  %s: %d", s.Name, s.Tokens)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestContext_Command(t *testing.T) {
+	rt := &Runtime{
+		InspectContext: func() ([]ContextSection, int, error) {
+			return []ContextSection{
+				{Group: "System prompt", Name: "identity and rules", Tokens: 400},
+				{Group: "System prompt", Name: "long-term memory", Tokens: 100},
+				{Group: "Tools", Tokens: 300},
+				{Group: "History", Name: "12 messages in 5 turns", Tokens: 200},
+			}, 10000, nil
+		},
+	}
+	reply := runCommand(t, rt, "!context")
+	for _, want := range []string{
+		"about 1000 tokens of a 10000-token context window (10%)",
+		"System prompt: 500\n  identity and rules: 400\n  long-term memory: 100",
+		"Tools: 300\n\nHistory: 200\n  12 messages in 5 turns: 200",
+	} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("reply missing %q:\n%s", want, reply)
+		}
+	}
+
+	rt.InspectContext = func() ([]ContextSection, int, error) { return nil, 0, errors.New("use a DM") }
+	if reply := runCommand(t, rt, "/context"); reply != "use a DM" {
+		t.Errorf("refusal = %q", reply)
+	}
+	if reply := runCommand(t, &Runtime{}, "/context"); reply != unavailableMsg {
+		t.Errorf("without runtime = %q", reply)
+	}
+}
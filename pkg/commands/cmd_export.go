@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"context"
+	"strings"
+)
+
+func exportCommand() Definition {
+	return Definition{
+		Name:        "export",
+		Description: "Export this conversation as Markdown or JSON",
+		Usage:       "/export [markdown|json]",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.ExportConversation == nil {
+				return req.Reply(unavailableMsg)
+			}
+			format := strings.ToLower(req.Arg(0))
+			if format != "" && format != "markdown" && format != "md" && format != "json" {
+				return req.Reply("Usage: /export [markdown|json]")
+			}
+			t := rt.ExportConversation()
+			if len(t.Messages) == 0 && t.Summary == "" {
+				return req.Reply("This conversation has no messages to export.")
+			}
+			if format != "json" {
+				return req.Reply(t.Markdown())
+			}
+			data, err := t.JSON()
+			if err != nil {
+				return req.Reply("Could not export the conversation: " + err.Error())
+			}
+			return req.Reply(string(data))
+		},
+	}
+}
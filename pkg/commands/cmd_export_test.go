@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/chatexport"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestExport_Command(t *testing.T) {
+	if reply := runCommand(t, &Runtime{}, "/export"); reply != unavailableMsg {
+		t.Errorf("without runtime = %q", reply)
+	}
+	var history []providers.Message
+	rt := &Runtime{ExportConversation: func() chatexport.Transcript {
+		return chatexport.New("main", "s1", "", history)
+	}}
+	if reply := runCommand(t, rt, "/export"); !strings.Contains(reply, "no messages") {
+		t.Errorf("empty conversation = %q", reply)
+	}
+
+	history = []providers.Message{{Role: "user", Content: "hello"}}
+	if reply := runCommand(t, rt, "/export"); !strings.Contains(reply, "## User\n\nhello") {
+		t.Errorf("markdown = %q", reply)
+	}
+	reply := runCommand(t, rt, "/export json")
+	if got, err := chatexport.Parse([]byte(reply)); err != nil || got.Messages[0].Content != "hello" {
+		t.Errorf("json = %q, %v", reply, err)
+	}
+	if reply := runCommand(t, rt, "/export pdf"); !strings.HasPrefix(reply, "Usage:") {
+		t.Errorf("unknown format = %q", reply)
+	}
+}
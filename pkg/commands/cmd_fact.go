@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const factOperatorOnlyMsg = "Only operators can change the facts of this server."
+
+func factCommand() Definition {
+	return Definition{
+		Name:        "fact",
+		Description: "Manage the facts pinned for this server",
+		SubCommands: []SubCommand{
+			{
+				Name:        "add",
+				Description: "Pin a fact for every conversation in this server",
+				ArgsUsage:   "<text>",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.AddFact == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if rt.IsFactOperator == nil || !rt.IsFactOperator() {
+						return req.Reply(factOperatorOnlyMsg)
+					}
+					text := textAfterTokens(req.Text, 2)
+					if text == "" {
+						return req.Reply("Usage: /fact add <text>")
+					}
+					f, err := rt.AddFact(text)
+					if err != nil {
+						return req.Reply("Could not add the fact: " + err.Error())
+					}
+					return req.Reply(req.T("Pinned fact #%d.", f.ID))
+				},
+			},
+			{
+				Name:        "remove",
+				Description: "Unpin a fact",
+				ArgsUsage:   "<number>",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.RemoveFact == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if rt.IsFactOperator == nil || !rt.IsFactOperator() {
+						return req.Reply(factOperatorOnlyMsg)
+					}
+					id, err := strconv.Atoi(strings.TrimPrefix(req.Arg(0), "#"))
+					if err != nil {
+						return req.Reply("Usage: /fact remove <number>")
+					}
+					if err := rt.RemoveFact(id); err != nil {
+						return req.Reply(err.Error())
+					}
+					return req.Reply(req.T("Removed fact #%d.", id))
+				},
+			},
+			{
+				Name:        "list",
+				Description: "Show the facts pinned for this server",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.ListFacts == nil {
+						return req.Reply(unavailableMsg)
+					}
+					list := rt.ListFacts()
+					if len(list) == 0 {
+						return req.Reply("No facts are pinned for this server.")
+					}
+					var b strings.Builder
+					b.WriteString("Pinned facts:")
+					for _, f := range list {
+						fmt.Fprintf(&b, "\n#%d %s", f.ID, f.Text)
+					}
+					return req.Reply(b.String())
+				},
+			},
+		},
+	}
+}
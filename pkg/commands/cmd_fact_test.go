@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/facts"
+)
+
+func TestFact_Command(t *testing.T) {
+	var pinned []facts.Fact
+	operator := false
+	rt := &Runtime{
+		IsFactOperator: func() bool { return operator },
+		ListFacts:      func() []facts.Fact { return pinned },
+		AddFact: func(text string) (facts.Fact, error) {
+			f := facts.Fact{ID: len(pinned) + 1, Text: text}
+			pinned = append(pinned, f)
+			return f, nil
+		},
+		RemoveFact: func(id int) error {
+			if id != 1 {
+				return facts.ErrUnknownFact
+			}
+			pinned = nil
+			return nil
+		},
+	}
+
+	if reply := runCommand(t, rt, "!fact add Be kind"); reply != factOperatorOnlyMsg {
+		t.Fatalf("non-operator add reply=%q", reply)
+	}
+	operator = true
+	if reply := runCommand(t, rt, "!fact add Rule one:\nbe kind"); reply != "Pinned fact #1." {
+		t.Fatalf("add reply=%q", reply)
+	}
+	if pinned[0].Text != "Rule one:\nbe kind" {
+		t.Fatalf("pinned text=%q", pinned[0].Text)
+	}
+	if reply := runCommand(t, rt, "/fact list"); !strings.Contains(reply, "#1 Rule one:") {
+		t.Fatalf("list reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/fact remove #2"); reply != facts.ErrUnknownFact.Error() {
+		t.Fatalf("remove unknown reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/fact remove 1"); reply != "Removed fact #1." {
+		t.Fatalf("remove reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/fact list"); reply != "No facts are pinned for this server." {
+		t.Fatalf("empty list reply=%q", reply)
+	}
+	if reply := runCommand(t, &Runtime{}, "/fact list"); reply != unavailableMsg {
+		t.Fatalf("outside guild reply=%q", reply)
+	}
+}
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/feedback"
+)
+
+const feedbackUsage = "/feedback <up|down> [comment]"
+
+func feedbackCommand() Definition {
+	return Definition{
+		Name:        "feedback",
+		Description: "Rate the latest reply in this chat",
+		Usage:       feedbackUsage,
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.RateLatestReply == nil {
+				return req.Reply(unavailableMsg)
+			}
+			rating := feedback.RatingForEmoji(req.Arg(0))
+			switch strings.ToLower(req.Arg(0)) {
+			case "up", "good", "yes":
+				rating = feedback.RatingUp
+			case "down", "bad", "no":
+				rating = feedback.RatingDown
+			}
+			if rating == "" {
+				return req.Reply("Usage: " + feedbackUsage)
+			}
+			err := rt.RateLatestReply(rating, textAfterTokens(req.Text, 2))
+			if errors.Is(err, feedback.ErrUnknownTurn) {
+				return req.Reply("There is no recent reply in this chat to rate.")
+			}
+			if err != nil {
+				return req.Reply("Could not save the feedback: " + err.Error())
+			}
+			return req.Reply("Thanks for the feedback!")
+		},
+	}
+}
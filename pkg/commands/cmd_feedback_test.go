@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/feedback"
+)
+
+func TestFeedback_RatesLatestReply(t *testing.T) {
+	var gotRating, gotComment string
+	rt := &Runtime{
+		RateLatestReply: func(rating, comment string) error {
+			gotRating, gotComment = rating, comment
+			return nil
+		},
+	}
+	if reply := runCommand(t, rt, "/feedback down the dates are wrong"); reply != "Thanks for the feedback!" {
+		t.Fatalf("reply=%q", reply)
+	}
+	if gotRating != feedback.RatingDown || gotComment != "the dates are wrong" {
+		t.Errorf("RateLatestReply(%q, %q)", gotRating, gotComment)
+	}
+	runCommand(t, rt, "/feedback 👍")
+	if gotRating != feedback.RatingUp || gotComment != "" {
+		t.Errorf("RateLatestReply(%q, %q)", gotRating, gotComment)
+	}
+	if reply := runCommand(t, rt, "/feedback meh"); reply != "Usage: "+feedbackUsage {
+		t.Errorf("bad rating reply=%q", reply)
+	}
+
+	rt.RateLatestReply = func(string, string) error { return feedback.ErrUnknownTurn }
+	if reply := runCommand(t, rt, "/feedback up"); reply != "There is no recent reply in this chat to rate." {
+		t.Errorf("no turn reply=%q", reply)
+	}
+}
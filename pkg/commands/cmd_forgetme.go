@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"context"
+	"strings"
+)
+
+const forgetMeWarning = "This deletes what the bot has stored about you: your direct-message " +
+	"conversation and its branches and memories, the memories you saved elsewhere, your preferences, " +
+	"queued messages, cached replies, the tool calls, ratings, " +
+	"and analytics of your messages, and the chat and sender of your usage records. " +
+	"It cannot be undone. Send /forgetme confirm to go ahead."
+
+func forgetMeCommand() Definition {
+	return Definition{
+		Name:        "forgetme",
+		Description: "Delete the data stored about you",
+		Usage:       "/forgetme [confirm]",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.ForgetMe == nil {
+				return req.Reply(unavailableMsg)
+			}
+			if !strings.EqualFold(req.Arg(0), "confirm") {
+				return req.Reply(forgetMeWarning)
+			}
+			return req.Reply(rt.ForgetMe())
+		},
+	}
+}
@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestForgetMe_RequiresConfirm(t *testing.T) {
+	calls := 0
+	rt := &Runtime{
+		ForgetMe: func() string {
+			calls++
+			return "Deletion receipt abc"
+		},
+	}
+
+	if reply := runCommand(t, rt, "/forgetme"); calls != 0 || !strings.Contains(reply, "/forgetme confirm") {
+		t.Fatalf("unconfirmed /forgetme: calls = %d, reply = %q", calls, reply)
+	}
+	if reply := runCommand(t, rt, "!forgetme confirm"); calls != 1 || reply != "Deletion receipt abc" {
+		t.Fatalf("confirmed /forgetme: calls = %d, reply = %q", calls, reply)
+	}
+	if reply := runCommand(t, &Runtime{}, "/forgetme confirm"); reply != unavailableMsg {
+		t.Errorf("without runtime: %q", reply)
+	}
+}
@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+const generationOperatorOnlyMsg = "Only generation operators can change this setting."
+
+func tempCommand() Definition {
+	return Definition{
+		Name:        "temp",
+		Description: "Show or set the sampling temperature of this conversation",
+		Usage:       "/temp [value|reset]",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			return adjustGeneration(req, rt, func(s *tuning.Settings, l tuning.Limits, value string) (string, error) {
+				switch value {
+				case "":
+					state := "default"
+					if s.Temperature != nil {
+						state = "set for this conversation"
+					}
+					return fmt.Sprintf("Temperature: %s (%s; allowed %s).",
+						formatFloat(l.Temperature(*s)), state, floatRange(l.GenerationConfig.Temperature)), nil
+				case "reset":
+					s.Temperature = nil
+					return fmt.Sprintf("Temperature reset to the default (%s).", formatFloat(l.DefaultTemperature)), nil
+				}
+				v, clamped, err := l.ParseTemperature(value)
+				if err != nil {
+					return "", err
+				}
+				s.Temperature = &v
+				return setReply("Temperature", formatFloat(v), clamped, floatRange(l.GenerationConfig.Temperature)), nil
+			})
+		},
+	}
+}
+
+func maxTokensCommand() Definition {
+	return Definition{
+		Name:        "maxtokens",
+		Description: "Show or set the reply token limit of this conversation",
+		Usage:       "/maxtokens [value|reset]",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			return adjustGeneration(req, rt, func(s *tuning.Settings, l tuning.Limits, value string) (string, error) {
+				switch value {
+				case "":
+					state := "default"
+					if s.MaxTokens != 0 {
+						state = "set for this conversation"
+					}
+					return fmt.Sprintf("Max tokens: %d (%s; allowed %s).",
+						l.MaxTokens(*s), state, intRange(l.GenerationConfig.MaxTokens)), nil
+				case "reset":
+					s.MaxTokens = 0
+					return fmt.Sprintf("Max tokens reset to the default (%d).", l.DefaultMaxTokens), nil
+				}
+				v, clamped, err := l.ParseMaxTokens(value)
+				if err != nil {
+					return "", err
+				}
+				s.MaxTokens = v
+				return setReply("Max tokens", strconv.Itoa(v), clamped, intRange(l.GenerationConfig.MaxTokens)), nil
+			})
+		},
+	}
+}
+
+func verbosityCommand() Definition {
+	return Definition{
+		Name:        "verbosity",
+		Description: "Show or set how detailed replies in this conversation are",
+		Usage:       "/verbosity [brief|normal|detailed|reset]",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			return adjustGeneration(req, rt, func(s *tuning.Settings, _ tuning.Limits, value string) (string, error) {
+				switch value {
+				case "":
+					if s.Verbosity == "" {
+						return "Verbosity: default (each user's /prefs verbosity applies).", nil
+					}
+					return fmt.Sprintf("Verbosity: %s (set for this conversation).", s.Verbosity), nil
+				case "reset":
+					s.Verbosity = ""
+					return "Verbosity reset; each user's /prefs verbosity applies again.", nil
+				}
+				v, err := tuning.ParseVerbosity(value)
+				if err != nil {
+					return "", err
+				}
+				s.Verbosity = v
+				return fmt.Sprintf("Verbosity set to %s for this conversation.", v), nil
+			})
+		},
+	}
+}
+
+// adjustGeneration runs change on this conversation's generation settings.
+// Showing the current value needs no permission; changes are saved only for
+// generation operators.
+func adjustGeneration(
+	req Request,
+	rt *Runtime,
+	change func(s *tuning.Settings, l tuning.Limits, value string) (string, error),
+) error {
+	if rt == nil || rt.GenerationSettings == nil || rt.SetGenerationSettings == nil {
+		return req.Reply(unavailableMsg)
+	}
+	value := strings.ToLower(req.Arg(0))
+	if value != "" && (rt.IsGenerationOperator == nil || !rt.IsGenerationOperator()) {
+		return req.Reply(generationOperatorOnlyMsg)
+	}
+	settings, limits := rt.GenerationSettings()
+	reply, err := change(&settings, limits, value)
+	if err != nil {
+		return req.Reply(err.Error())
+	}
+	if value != "" {
+		if err := rt.SetGenerationSettings(settings); err != nil {
+			return req.Reply("Failed to save the setting: " + err.Error())
+		}
+	}
+	return req.Reply(reply)
+}
+
+func setReply(name, value string, clamped bool, allowed string) string {
+	if clamped {
+		return fmt.Sprintf("%s set to %s for this conversation, the closest allowed value (%s).", name, value, allowed)
+	}
+	return fmt.Sprintf("%s set to %s for this conversation.", name, value)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func floatRange(r config.FloatRange) string {
+	if r.Max <= 0 {
+		return formatFloat(r.Min) + " and up"
+	}
+	return formatFloat(r.Min) + "–" + formatFloat(r.Max)
+}
+
+func intRange(r config.IntRange) string {
+	if r.Max <= 0 {
+		return strconv.Itoa(r.Min) + " and up"
+	}
+	return strconv.Itoa(r.Min) + "–" + strconv.Itoa(r.Max)
+}
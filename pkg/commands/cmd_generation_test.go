@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+func TestGeneration_Commands(t *testing.T) {
+	var saved tuning.Settings
+	operator := false
+	limits := tuning.Limits{
+		GenerationConfig: config.GenerationConfig{
+			Temperature: config.FloatRange{Min: 0, Max: 1.5},
+			MaxTokens:   config.IntRange{Min: 256, Max: 8192},
+		},
+		DefaultTemperature: 0.7,
+		DefaultMaxTokens:   4096,
+	}
+	rt := &Runtime{
+		IsGenerationOperator:  func() bool { return operator },
+		GenerationSettings:    func() (tuning.Settings, tuning.Limits) { return saved, limits },
+		SetGenerationSettings: func(s tuning.Settings) error { saved = s; return nil },
+	}
+
+	if reply := runCommand(t, rt, "/temp"); reply != "Temperature: 0.7 (default; allowed 0–1.5)." {
+		t.Fatalf("show reply = %q", reply)
+	}
+	if reply := runCommand(t, rt, "/temp 1"); reply != generationOperatorOnlyMsg {
+		t.Fatalf("non-operator reply = %q", reply)
+	}
+	operator = true
+	if reply := runCommand(t, rt, "/temp 1.1"); reply != "Temperature set to 1.1 for this conversation." {
+		t.Fatalf("set reply = %q", reply)
+	}
+	if saved.Temperature == nil || *saved.Temperature != 1.1 {
+		t.Fatalf("saved temperature = %v", saved.Temperature)
+	}
+	if reply := runCommand(t, rt, "/maxtokens 100"); !strings.Contains(reply, "set to 256") ||
+		!strings.Contains(reply, "closest allowed value (256–8192)") {
+		t.Fatalf("clamped reply = %q", reply)
+	}
+	if reply := runCommand(t, rt, "/maxtokens lots"); !strings.Contains(reply, "positive whole number") {
+		t.Fatalf("bad value reply = %q", reply)
+	}
+	if saved.MaxTokens != 256 {
+		t.Fatalf("bad value changed max tokens to %d", saved.MaxTokens)
+	}
+	if reply := runCommand(t, rt, "/verbosity Detailed"); reply != "Verbosity set to detailed for this conversation." {
+		t.Fatalf("verbosity reply = %q", reply)
+	}
+	if reply := runCommand(t, rt, "/verbosity loud"); !strings.HasPrefix(reply, "verbosity must be") {
+		t.Fatalf("bad verbosity reply = %q", reply)
+	}
+	if reply := runCommand(t, rt, "/temp reset"); reply != "Temperature reset to the default (0.7)." {
+		t.Fatalf("reset reply = %q", reply)
+	}
+	if saved.Temperature != nil || saved.MaxTokens != 256 || saved.Verbosity != "detailed" {
+		t.Fatalf("settings after reset = %+v", saved)
+	}
+
+	rt.SetGenerationSettings = func(tuning.Settings) error { return errors.New("disk full") }
+	if reply := runCommand(t, rt, "/verbosity brief"); reply != "Failed to save the setting: disk full" {
+		t.Fatalf("save error reply = %q", reply)
+	}
+	if reply := runCommand(t, &Runtime{}, "/temp"); reply != unavailableMsg {
+		t.Fatalf("unavailable reply = %q", reply)
+	}
+}
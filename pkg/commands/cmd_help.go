@@ -4,13 +4,15 @@ import (
 	"context"
 	"fmt"
 	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/i18n"
 )
 
 func helpCommand() Definition {
 	return Definition{
 		Name:        "help",
 		Description: "Show this help message",
-		Usage:       "/help",
+		Usage:       "/help [command]",
 		Handler: func(_ context.Context, req Request, rt *Runtime) error {
 			var defs []Definition
 			if rt != nil && rt.ListDefinitions != nil {
@@ -18,14 +20,21 @@ func helpCommand() Definition {
 			} else {
 				defs = BuiltinDefinitions()
 			}
-			return req.Reply(formatHelpMessage(defs))
+			if name := req.Arg(0); name != "" {
+				if def, ok := NewRegistry(defs).Lookup(strings.TrimLeft(name, "/!")); ok {
+					return req.Reply(formatCommandHelp(def, req.Locale))
+				}
+				return req.Reply(req.T("Unknown command: %s. Send /help for the list.", name))
+			}
+			return req.Reply(formatHelpMessage(defs, req.Locale))
 		},
 	}
 }
 
-func formatHelpMessage(defs []Definition) string {
+// formatHelpMessage lists defs with their descriptions in lang.
+func formatHelpMessage(defs []Definition, lang string) string {
 	if len(defs) == 0 {
-		return "No commands available."
+		return i18n.T(lang, "No commands available.")
 	}
 
 	lines := make([]string, 0, len(defs))
@@ -38,7 +47,57 @@ func formatHelpMessage(defs []Definition) string {
 		if desc == "" {
 			desc = "No description"
 		}
+		desc = i18n.T(lang, desc)
 		lines = append(lines, fmt.Sprintf("%s - %s", usage, desc))
 	}
+	lines = append(lines, "", i18n.T(lang, "Send /help <command> for details. Quote arguments that contain spaces."))
 	return strings.Join(lines, "\n")
 }
+
+// formatCommandHelp describes one command with its sub-commands and flags,
+// generated from the definition so it always matches what the executor
+// accepts. The description is in lang; usage and flags stay as written.
+func formatCommandHelp(def Definition, lang string) string {
+	var b strings.Builder
+	b.WriteString("/" + def.Name)
+	if def.Description != "" {
+		b.WriteString(" - " + i18n.T(lang, def.Description))
+	}
+	if usage := def.EffectiveUsage(); usage != "" {
+		b.WriteString("\n" + i18n.Sprintf(lang, "Usage: %s", usage))
+	}
+	if len(def.Aliases) > 0 {
+		b.WriteString("\nAliases: /" + strings.Join(def.Aliases, ", /"))
+	}
+	writeFlags(&b, def.Flags, "")
+	if len(def.SubCommands) > 0 {
+		b.WriteString("\nSub-commands:")
+		for _, sc := range def.SubCommands {
+			fmt.Fprintf(&b, "\n  %s", strings.TrimPrefix(sc.usage(def.Name), "/"+def.Name+" "))
+			if sc.Description != "" {
+				b.WriteString(" - " + sc.Description)
+			}
+			writeFlags(&b, sc.Flags, "    ")
+		}
+	}
+	return b.String()
+}
+
+func writeFlags(b *strings.Builder, flags []Flag, indent string) {
+	if len(flags) == 0 {
+		return
+	}
+	if indent == "" {
+		b.WriteString("\nFlags:")
+		indent = "  "
+	}
+	for _, f := range flags {
+		fmt.Fprintf(b, "\n%s--%s", indent, f.Name)
+		if f.Value != "" {
+			b.WriteString(" " + f.Value)
+		}
+		if f.Usage != "" {
+			b.WriteString(": " + f.Usage)
+		}
+	}
+}
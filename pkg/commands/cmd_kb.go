@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func kbCommand() Definition {
+	return Definition{
+		Name:        "kb",
+		Description: "Manage and ask the knowledge base of this conversation",
+		SubCommands: []SubCommand{
+			{
+				Name:        "add",
+				Description: "Add a web page, or the files attached to the message",
+				ArgsUsage:   "[url]",
+				Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.AddDocuments == nil {
+						return req.Reply(unavailableMsg)
+					}
+					added, err := rt.AddDocuments(ctx, req.Arg(0))
+					if err != nil {
+						return req.Reply("Could not add to the knowledge base: " + err.Error())
+					}
+					if len(added) == 0 {
+						return req.Reply("Usage: /kb add <url>, or attach PDFs or text files to /kb add")
+					}
+					var b strings.Builder
+					b.WriteString("Added to the knowledge base:")
+					for _, d := range added {
+						fmt.Fprintf(&b, "\n#%d %s (%d parts)", d.ID, d.Title, d.Chunks)
+					}
+					return req.Reply(b.String())
+				},
+			},
+			{
+				Name:        "list",
+				Description: "Show the documents of the knowledge base",
+				Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.ListDocuments == nil {
+						return req.Reply(unavailableMsg)
+					}
+					list, err := rt.ListDocuments(ctx)
+					if err != nil {
+						return req.Reply("Could not list the documents: " + err.Error())
+					}
+					if len(list) == 0 {
+						return req.Reply("The knowledge base is empty. Add to it with /kb add <url> or an attachment.")
+					}
+					var b strings.Builder
+					b.WriteString("Knowledge base (remove a document with /kb remove <number>):")
+					for _, d := range list {
+						fmt.Fprintf(&b, "\n#%d %s (%d parts)", d.ID, d.Title, d.Chunks)
+						if d.Source != "" && d.Source != d.Title {
+							b.WriteString(" " + d.Source)
+						}
+					}
+					return req.Reply(b.String())
+				},
+			},
+			{
+				Name:        "ask",
+				Description: "Answer a question from the knowledge base, citing its sources",
+				ArgsUsage:   "<question>",
+				Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.AskDocuments == nil {
+						return req.Reply(unavailableMsg)
+					}
+					question := textAfterTokens(req.Text, 2)
+					if question == "" {
+						return req.Reply("Usage: /kb ask <question>")
+					}
+					answer, sources, err := rt.AskDocuments(ctx, question)
+					if err != nil {
+						return req.Reply("Could not answer from the knowledge base: " + err.Error())
+					}
+					if len(sources) == 0 {
+						return req.Reply("Nothing in the knowledge base matches the question.")
+					}
+					var b strings.Builder
+					b.WriteString(strings.TrimSpace(answer))
+					b.WriteString("\n\nSources:")
+					for i, p := range sources {
+						fmt.Fprintf(&b, "\n[%d] #%d %s, part %d", i+1, p.DocumentID, p.Title, p.Part)
+					}
+					return req.Reply(b.String())
+				},
+			},
+			{
+				Name:        "remove",
+				Description: "Remove a document from the knowledge base",
+				ArgsUsage:   "<number>",
+				Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.RemoveDocument == nil {
+						return req.Reply(unavailableMsg)
+					}
+					id, err := strconv.ParseInt(strings.TrimPrefix(req.Arg(0), "#"), 10, 64)
+					if err != nil {
+						return req.Reply("Usage: /kb remove <number>")
+					}
+					if err := rt.RemoveDocument(ctx, id); err != nil {
+						return req.Reply(err.Error())
+					}
+					return req.Reply(fmt.Sprintf("Removed document #%d.", id))
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/recall"
+)
+
+func TestKB(t *testing.T) {
+	var docs []recall.Document
+	var added []string
+	rt := &Runtime{
+		AddDocuments: func(_ context.Context, url string) ([]recall.Document, error) {
+			added = append(added, url)
+			if url == "" {
+				return nil, nil
+			}
+			d := recall.Document{ID: int64(len(docs) + 1), Title: "Menu", Source: url, Chunks: 3}
+			docs = append(docs, d)
+			return []recall.Document{d}, nil
+		},
+		ListDocuments: func(context.Context) ([]recall.Document, error) { return docs, nil },
+		RemoveDocument: func(_ context.Context, id int64) error {
+			return recall.ErrUnknownDocument
+		},
+		AskDocuments: func(_ context.Context, question string) (string, []recall.Passage, error) {
+			if question != "is there soup?" {
+				return "", nil, nil
+			}
+			return "Yes, daily [1].", []recall.Passage{{DocumentID: 1, Title: "Menu", Part: 2}}, nil
+		},
+	}
+
+	if reply := runCommand(t, rt, "/kb list"); !strings.Contains(reply, "knowledge base is empty") {
+		t.Errorf("empty list: %q", reply)
+	}
+	if reply := runCommand(t, rt, "/kb add"); !strings.HasPrefix(reply, "Usage:") {
+		t.Errorf("add without url or attachment: %q", reply)
+	}
+	reply := runCommand(t, rt, "/kb add https://example.com/menu")
+	if reply != "Added to the knowledge base:\n#1 Menu (3 parts)" {
+		t.Errorf("add: %q", reply)
+	}
+	if added[1] != "https://example.com/menu" {
+		t.Errorf("AddDocuments got %q", added[1])
+	}
+	if reply := runCommand(t, rt, "/kb list"); !strings.Contains(reply, "#1 Menu (3 parts) https://example.com/menu") {
+		t.Errorf("list: %q", reply)
+	}
+	reply = runCommand(t, rt, "/kb ask is there soup?")
+	if reply != "Yes, daily [1].\n\nSources:\n[1] #1 Menu, part 2" {
+		t.Errorf("ask: %q", reply)
+	}
+	reply = runCommand(t, rt, "/kb ask what is for dessert")
+	if !strings.Contains(reply, "Nothing in the knowledge base") {
+		t.Errorf("ask without matches: %q", reply)
+	}
+	if reply := runCommand(t, rt, "/kb remove 9"); reply != recall.ErrUnknownDocument.Error() {
+		t.Errorf("remove unknown: %q", reply)
+	}
+	if reply := runCommand(t, &Runtime{}, "/kb ask anything"); reply != unavailableMsg {
+		t.Errorf("without recall: %q", reply)
+	}
+}
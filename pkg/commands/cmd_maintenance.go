@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const adminOnlyMsg = "Only admins can use this command."
+
+func maintenanceCommand() Definition {
+	return Definition{
+		Name:        "maintenance",
+		Description: "Toggle maintenance mode",
+		SubCommands: []SubCommand{
+			{
+				Name:        "on",
+				Description: "Enter maintenance mode",
+				ArgsUsage:   "[message]",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.SetMaintenance == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if rt.IsAdmin == nil || !rt.IsAdmin() {
+						return req.Reply(adminOnlyMsg)
+					}
+					if _, err := rt.SetMaintenance(true, strings.Join(req.Args, " ")); err != nil {
+						return req.Reply("Failed to enter maintenance mode: " + err.Error())
+					}
+					return req.Reply("Maintenance mode on. Scheduled jobs are paused.")
+				},
+			},
+			{
+				Name:        "off",
+				Description: "Leave maintenance mode",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.SetMaintenance == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if rt.IsAdmin == nil || !rt.IsAdmin() {
+						return req.Reply(adminOnlyMsg)
+					}
+					released, err := rt.SetMaintenance(false, "")
+					if err != nil {
+						return req.Reply("Failed to leave maintenance mode: " + err.Error())
+					}
+					if released > 0 {
+						return req.Reply(fmt.Sprintf("Maintenance mode off. Processing %d queued messages.", released))
+					}
+					return req.Reply("Maintenance mode off.")
+				},
+			},
+			{
+				Name:        "status",
+				Description: "Show maintenance mode status",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.MaintenanceStatus == nil {
+						return req.Reply(unavailableMsg)
+					}
+					active, notice, queued := rt.MaintenanceStatus()
+					if !active {
+						return req.Reply("Maintenance mode is off.")
+					}
+					return req.Reply(fmt.Sprintf("Maintenance mode is on (%d queued).\nNotice: %s", queued, notice))
+				},
+			},
+		},
+	}
+}
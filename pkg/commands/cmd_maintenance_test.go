@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"testing"
+)
+
+func runCommand(t *testing.T, rt *Runtime, text string) string {
+	t.Helper()
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+	var reply string
+	res := ex.Execute(context.Background(), Request{
+		Text: text,
+		Reply: func(s string) error {
+			reply = s
+			return nil
+		},
+	})
+	if res.Outcome != OutcomeHandled {
+		t.Fatalf("outcome=%v, want=%v", res.Outcome, OutcomeHandled)
+	}
+	return reply
+}
+
+func TestMaintenance_OnPassesMessage(t *testing.T) {
+	var gotOn bool
+	var gotMsg string
+	rt := &Runtime{
+		IsAdmin: func() bool { return true },
+		SetMaintenance: func(on bool, message string) (int, error) {
+			gotOn, gotMsg = on, message
+			return 0, nil
+		},
+	}
+	reply := runCommand(t, rt, "/maintenance on  Switching   providers, back at 10")
+	if !gotOn || gotMsg != "Switching providers, back at 10" {
+		t.Fatalf("SetMaintenance(%v, %q)", gotOn, gotMsg)
+	}
+	if reply != "Maintenance mode on. Scheduled jobs are paused." {
+		t.Fatalf("reply=%q", reply)
+	}
+}
+
+func TestMaintenance_OffReportsReleased(t *testing.T) {
+	rt := &Runtime{
+		IsAdmin:        func() bool { return true },
+		SetMaintenance: func(bool, string) (int, error) { return 3, nil },
+	}
+	if reply := runCommand(t, rt, "/maintenance off"); reply != "Maintenance mode off. Processing 3 queued messages." {
+		t.Fatalf("reply=%q", reply)
+	}
+}
+
+func TestMaintenance_RequiresAdmin(t *testing.T) {
+	called := false
+	rt := &Runtime{
+		IsAdmin: func() bool { return false },
+		SetMaintenance: func(bool, string) (int, error) {
+			called = true
+			return 0, nil
+		},
+	}
+	if reply := runCommand(t, rt, "/maintenance on"); reply != adminOnlyMsg || called {
+		t.Fatalf("reply=%q called=%v", reply, called)
+	}
+}
+
+func TestMaintenance_Status(t *testing.T) {
+	rt := &Runtime{
+		MaintenanceStatus: func() (bool, string, int) { return true, "Back soon", 2 },
+	}
+	if reply := runCommand(t, rt, "/maintenance status"); reply != "Maintenance mode is on (2 queued).\nNotice: Back soon" {
+		t.Fatalf("reply=%q", reply)
+	}
+	if reply := runCommand(t, nil, "/maintenance status"); reply != unavailableMsg {
+		t.Fatalf("reply=%q", reply)
+	}
+}
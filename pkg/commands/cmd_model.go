@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+// ModelChoice is one model /model list shows.
+type ModelChoice struct {
+	Name    string // model_list name to switch to; empty when the config does not allow it
+	ID      string // model ID sent to the provider
+	Current bool
+}
+
+func modelCommand() Definition {
+	return Definition{
+		Name:        "model",
+		Description: "Show, switch, or list the model of this conversation",
+		Usage:       "/model [name|list|reset]",
+		Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+			if strings.EqualFold(req.Arg(0), "list") {
+				return listModelChoices(ctx, req, rt)
+			}
+			if rt == nil || rt.GetModelInfo == nil || rt.CheckModel == nil {
+				return req.Reply(unavailableMsg)
+			}
+			defaultModel, _ := rt.GetModelInfo()
+			return adjustGeneration(req, rt, func(s *tuning.Settings, _ tuning.Limits, value string) (string, error) {
+				switch value {
+				case "":
+					if s.Model == "" {
+						return fmt.Sprintf("Model: %s (default).", defaultModel), nil
+					}
+					return fmt.Sprintf("Model: %s (set for this conversation).", s.Model), nil
+				case "reset":
+					s.Model = ""
+					return fmt.Sprintf("Model reset to the default (%s).", defaultModel), nil
+				}
+				// Model names are case-sensitive, unlike the other settings.
+				name := req.Arg(0)
+				if err := rt.CheckModel(name); err != nil {
+					return "", err
+				}
+				s.Model = name
+				return fmt.Sprintf("Model switched to %s for this conversation.", name), nil
+			})
+		},
+	}
+}
+
+// listModelChoices lists the models the provider serves, marking the ones
+// the config allows a conversation to switch to.
+func listModelChoices(ctx context.Context, req Request, rt *Runtime) error {
+	if rt == nil || rt.ListModels == nil {
+		return req.Reply(unavailableMsg)
+	}
+	models, queried := rt.ListModels(ctx)
+	if len(models) == 0 {
+		return req.Reply("No models available.")
+	}
+	var b strings.Builder
+	b.WriteString("Models (✓ allowed; switch with /model <name>):")
+	for _, m := range models {
+		b.WriteString("\n")
+		if m.Name == "" {
+			b.WriteString("  " + m.ID)
+		} else if m.Name == m.ID {
+			b.WriteString("✓ " + m.Name)
+		} else {
+			b.WriteString("✓ " + m.Name + " — " + m.ID)
+		}
+		if m.Current {
+			b.WriteString(" (current)")
+		}
+	}
+	if !queried {
+		b.WriteString("\n\nThe provider does not list its models, so only configured ones are shown.")
+	}
+	return req.Reply(b.String())
+}
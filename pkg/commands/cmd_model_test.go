@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+func TestModel_Command(t *testing.T) {
+	var saved tuning.Settings
+	operator := true
+	rt := &Runtime{
+		GetModelInfo:          func() (string, string) { return "gpt4", "" },
+		IsGenerationOperator:  func() bool { return operator },
+		GenerationSettings:    func() (tuning.Settings, tuning.Limits) { return saved, tuning.Limits{} },
+		SetGenerationSettings: func(s tuning.Settings) error { saved = s; return nil },
+		CheckModel: func(name string) error {
+			if name != "Claude" {
+				return errors.New("model " + name + " is not in the config")
+			}
+			return nil
+		},
+	}
+
+	if reply := runCommand(t, rt, "/model"); reply != "Model: gpt4 (default)." {
+		t.Fatalf("show reply = %q", reply)
+	}
+	if reply := runCommand(t, rt, "!model Claude"); reply != "Model switched to Claude for this conversation." {
+		t.Fatalf("switch reply = %q", reply)
+	}
+	if saved.Model != "Claude" {
+		t.Fatalf("saved model = %q, want the name as typed", saved.Model)
+	}
+	if reply := runCommand(t, rt, "/model llama"); !strings.Contains(reply, "not in the config") || saved.Model != "Claude" {
+		t.Fatalf("unknown model reply = %q, saved %q", reply, saved.Model)
+	}
+	operator = false
+	if reply := runCommand(t, rt, "/model gpt4"); reply != generationOperatorOnlyMsg {
+		t.Fatalf("non-operator reply = %q", reply)
+	}
+	operator = true
+	if reply := runCommand(t, rt, "/model reset"); reply != "Model reset to the default (gpt4)." || saved.Model != "" {
+		t.Fatalf("reset reply = %q, saved %q", reply, saved.Model)
+	}
+}
+
+func TestModel_List(t *testing.T) {
+	queried := true
+	rt := &Runtime{
+		ListModels: func(context.Context) ([]ModelChoice, bool) {
+			return []ModelChoice{
+				{Name: "gpt4", ID: "gpt-4o", Current: true},
+				{Name: "mini", ID: "mini"},
+				{ID: "gpt-4o-audio"},
+			}, queried
+		},
+	}
+
+	want := "Models (✓ allowed; switch with /model <name>):\n" +
+		"✓ gpt4 — gpt-4o (current)\n" +
+		"✓ mini\n" +
+		"  gpt-4o-audio"
+	if reply := runCommand(t, rt, "/model list"); reply != want {
+		t.Fatalf("list reply = %q, want %q", reply, want)
+	}
+	queried = false
+	if reply := runCommand(t, rt, "/model list"); !strings.Contains(reply, "only configured ones are shown") {
+		t.Fatalf("unqueried list reply = %q", reply)
+	}
+}
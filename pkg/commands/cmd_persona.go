@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+func personaCommand() Definition {
+	return Definition{
+		Name:        "persona",
+		Description: "Show, switch, or list the persona of this conversation",
+		Usage:       "/persona [name|list|reset]",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.Config == nil || rt.DefaultPersona == nil {
+				return req.Reply(unavailableMsg)
+			}
+			profiles := rt.Config.Personas.Profiles
+			if len(profiles) == 0 {
+				return req.Reply("No personas are configured.")
+			}
+			if strings.EqualFold(req.Arg(0), "list") {
+				return req.Reply(listPersonas(profiles))
+			}
+			fallback := rt.DefaultPersona()
+			return adjustGeneration(req, rt, func(s *tuning.Settings, _ tuning.Limits, value string) (string, error) {
+				switch value {
+				case "":
+					if s.Persona != "" {
+						return fmt.Sprintf("Persona: %s (set for this conversation).", s.Persona), nil
+					}
+					if fallback != "" {
+						return fmt.Sprintf("Persona: %s (default for this chat).", fallback), nil
+					}
+					return "No persona (default). See /persona list.", nil
+				case "reset":
+					s.Persona = ""
+					if fallback != "" {
+						return fmt.Sprintf("Persona reset to the default (%s).", fallback), nil
+					}
+					return "Persona reset; this chat has no default persona.", nil
+				}
+				name, ok := findPersona(profiles, req.Arg(0))
+				if !ok {
+					return "", fmt.Errorf("persona %s is not configured; see /persona list", req.Arg(0))
+				}
+				// The persona brings its own model and temperature.
+				s.Persona, s.Model, s.Temperature = name, "", nil
+				return fmt.Sprintf("Persona switched to %s for this conversation.", name), nil
+			})
+		},
+	}
+}
+
+// findPersona returns the configured name matching name, ignoring case.
+func findPersona(profiles map[string]config.PersonaConfig, name string) (string, bool) {
+	if _, ok := profiles[name]; ok {
+		return name, true
+	}
+	for configured := range profiles {
+		if strings.EqualFold(configured, name) {
+			return configured, true
+		}
+	}
+	return "", false
+}
+
+func listPersonas(profiles map[string]config.PersonaConfig) string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var b strings.Builder
+	b.WriteString("Personas (switch with /persona <name>):")
+	for _, name := range names {
+		b.WriteString("\n- " + name)
+		if d := profiles[name].Description; d != "" {
+			b.WriteString(": " + d)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+)
+
+func TestPersona_Command(t *testing.T) {
+	temp := 0.2
+	saved := tuning.Settings{Model: "gpt4", Temperature: &temp}
+	operator := true
+	cfg := &config.Config{Personas: config.PersonasConfig{Profiles: map[string]config.PersonaConfig{
+		"Pirate": {Description: "Talks like a pirate"},
+		"tutor":  {},
+	}}}
+	rt := &Runtime{
+		Config:                cfg,
+		DefaultPersona:        func() string { return "tutor" },
+		IsGenerationOperator:  func() bool { return operator },
+		GenerationSettings:    func() (tuning.Settings, tuning.Limits) { return saved, tuning.Limits{} },
+		SetGenerationSettings: func(s tuning.Settings) error { saved = s; return nil },
+	}
+
+	if reply := runCommand(t, rt, "/persona"); reply != "Persona: tutor (default for this chat)." {
+		t.Fatalf("show reply = %q", reply)
+	}
+	if reply := runCommand(t, rt, "/persona list"); reply != "Personas (switch with /persona <name>):\n"+
+		"- Pirate: Talks like a pirate\n- tutor" {
+		t.Fatalf("list reply = %q", reply)
+	}
+	if reply := runCommand(t, rt, "/persona pirate"); reply != "Persona switched to Pirate for this conversation." {
+		t.Fatalf("switch reply = %q", reply)
+	}
+	if saved.Persona != "Pirate" || saved.Model != "" || saved.Temperature != nil {
+		t.Fatalf("saved = %+v, want the persona without model and temperature overrides", saved)
+	}
+	if reply := runCommand(t, rt, "/persona chef"); !strings.Contains(reply, "not configured") || saved.Persona != "Pirate" {
+		t.Fatalf("unknown persona reply = %q, saved %+v", reply, saved)
+	}
+	operator = false
+	if reply := runCommand(t, rt, "/persona tutor"); reply != generationOperatorOnlyMsg {
+		t.Fatalf("non-operator reply = %q", reply)
+	}
+	operator = true
+	if reply := runCommand(t, rt, "/persona reset"); reply != "Persona reset to the default (tutor)." || saved.Persona != "" {
+		t.Fatalf("reset reply = %q, saved %+v", reply, saved)
+	}
+
+	cfg.Personas.Profiles = nil
+	if reply := runCommand(t, rt, "/persona"); reply != "No personas are configured." {
+		t.Fatalf("without personas = %q", reply)
+	}
+}
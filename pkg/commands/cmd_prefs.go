@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"context"
+	"strings"
+)
+
+const prefsUsage = "Usage: /prefs — show your preferences\n" +
+	"/prefs set <language|verbosity|persona|streaming|tts> <value>\n" +
+	"/prefs reset [name] — clear one or all preferences"
+
+func prefsCommand() Definition {
+	return Definition{
+		Name:        "prefs",
+		Description: "Show or change your preferences",
+		Usage:       "/prefs [set <name> <value>|reset [name]]",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.GetPrefs == nil || rt.SetPrefs == nil {
+				return req.Reply(unavailableMsg)
+			}
+			p := rt.GetPrefs()
+			switch action := strings.ToLower(req.Arg(0)); action {
+			case "", "show":
+				return req.Reply("Your preferences:\n" + p.Describe())
+			case "set":
+				key := req.Arg(1)
+				if key == "" {
+					return req.Reply(prefsUsage)
+				}
+				if err := p.Set(key, textAfterTokens(req.Text, 3)); err != nil {
+					return req.Reply(err.Error())
+				}
+			case "reset":
+				if err := p.Reset(req.Arg(1)); err != nil {
+					return req.Reply(err.Error())
+				}
+			default:
+				return req.Reply(prefsUsage)
+			}
+			if err := rt.SetPrefs(p); err != nil {
+				return req.Reply("Failed to save preferences: " + err.Error())
+			}
+			return req.Reply("Saved. Your preferences:\n" + p.Describe())
+		},
+	}
+}
@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/prefs"
+)
+
+func TestPrefs_Command(t *testing.T) {
+	var saved prefs.Prefs
+	rt := &Runtime{
+		GetPrefs: func() prefs.Prefs { return saved },
+		SetPrefs: func(p prefs.Prefs) error { saved = p; return nil },
+	}
+
+	if reply := runCommand(t, rt, "/prefs"); !strings.Contains(reply, "language: default") {
+		t.Fatalf("show reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "!prefs set persona a dry, witty  narrator"); !strings.HasPrefix(reply, "Saved.") {
+		t.Fatalf("set reply=%q", reply)
+	}
+	if saved.Persona != "a dry, witty  narrator" {
+		t.Fatalf("persona=%q", saved.Persona)
+	}
+	if reply := runCommand(t, rt, "/prefs set verbosity loud"); !strings.Contains(reply, "verbosity must be") {
+		t.Fatalf("invalid reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/prefs reset persona"); !strings.Contains(reply, "persona: default") {
+		t.Fatalf("reset reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/prefs frobnicate"); reply != prefsUsage {
+		t.Fatalf("unknown action reply=%q", reply)
+	}
+
+	rt.SetPrefs = func(prefs.Prefs) error { return errors.New("disk full") }
+	if reply := runCommand(t, rt, "/prefs set tts on"); reply != "Failed to save preferences: disk full" {
+		t.Fatalf("save failure reply=%q", reply)
+	}
+	if reply := runCommand(t, &Runtime{}, "/prefs"); reply != unavailableMsg {
+		t.Fatalf("unavailable reply=%q", reply)
+	}
+}
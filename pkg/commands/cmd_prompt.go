@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/promptlib"
+)
+
+const (
+	promptOperatorOnlyMsg = "Only operators can change the prompt library."
+	promptUsage           = "/prompt <name> <text> | list | show <name> | add <name> <template> | " +
+		"describe <name> <text> | example <name> <message> => <answer> | remove <name>"
+)
+
+// promptActions are the /prompt arguments that are not template names.
+var promptActions = map[string]bool{
+	"list": true, "show": true, "add": true, "describe": true, "example": true, "remove": true,
+}
+
+func promptCommand() Definition {
+	return Definition{
+		Name:        "prompt",
+		Description: "Run or manage the named prompt templates",
+		Usage:       promptUsage,
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.ListPrompts == nil || rt.UsePrompt == nil {
+				return req.Reply(unavailableMsg)
+			}
+			action := strings.ToLower(req.Arg(0))
+			switch action {
+			case "", "list":
+				return req.Reply(listPrompts(rt.ListPrompts()))
+			case "show":
+				t, ok := findPrompt(rt, req.Arg(1))
+				if !ok {
+					return req.Reply(unknownPromptMsg(req.Arg(1)))
+				}
+				return req.Reply(showPrompt(t))
+			case "add", "describe", "example", "remove":
+				if rt.SavePrompt == nil || rt.RemovePrompt == nil {
+					return req.Reply(unavailableMsg)
+				}
+				if rt.IsPromptOperator == nil || !rt.IsPromptOperator() {
+					return req.Reply(promptOperatorOnlyMsg)
+				}
+				return editPrompt(req, rt, action)
+			}
+			if _, ok := findPrompt(rt, action); !ok {
+				return req.Reply(unknownPromptMsg(action))
+			}
+			if err := rt.UsePrompt(action, textAfterTokens(req.Text, 2)); err != nil {
+				return req.Reply(err.Error())
+			}
+			return nil
+		},
+	}
+}
+
+func editPrompt(req Request, rt *Runtime, action string) error {
+	name := promptlib.NormalizeName(req.Arg(1))
+	text := textAfterTokens(req.Text, 3)
+	if name == "" || (action != "remove" && text == "") {
+		return req.Reply("Usage: " + promptUsage)
+	}
+	t, exists := findPrompt(rt, name)
+	switch action {
+	case "add":
+		if promptActions[name] {
+			return req.Reply(fmt.Sprintf("%s is a /prompt option and cannot name a template.", name))
+		}
+		if !exists {
+			t = promptlib.Template{Name: name}
+		}
+		t.Text = text
+	case "describe":
+		if !exists {
+			return req.Reply(unknownPromptMsg(name))
+		}
+		t.Description = text
+	case "example":
+		if !exists {
+			return req.Reply(unknownPromptMsg(name))
+		}
+		user, assistant, ok := strings.Cut(text, "=>")
+		if !ok {
+			return req.Reply("Usage: /prompt example <name> <message> => <answer>")
+		}
+		t.Examples = append(t.Examples, config.PromptExample{
+			User:      strings.TrimSpace(user),
+			Assistant: strings.TrimSpace(assistant),
+		})
+	default: // remove
+		if err := rt.RemovePrompt(name); err != nil {
+			if exists && t.Configured {
+				return req.Reply(fmt.Sprintf("%s is set in the config file and cannot be removed from chat.", name))
+			}
+			return req.Reply(err.Error())
+		}
+		if t, ok := findPrompt(rt, name); ok && t.Configured {
+			return req.Reply(fmt.Sprintf("Removed the changes to %s; the configured template is used again.", name))
+		}
+		return req.Reply(fmt.Sprintf("Removed prompt template %s.", name))
+	}
+	if err := rt.SavePrompt(t); err != nil {
+		return req.Reply("Could not save the template: " + err.Error())
+	}
+	switch action {
+	case "add":
+		return req.Reply(fmt.Sprintf("Saved prompt template %s. Use it with /prompt %s <text>.", name, name))
+	case "describe":
+		return req.Reply(fmt.Sprintf("Updated the description of %s.", name))
+	default:
+		return req.Reply(fmt.Sprintf("Added example %d to %s.", len(t.Examples), name))
+	}
+}
+
+func findPrompt(rt *Runtime, name string) (promptlib.Template, bool) {
+	name = promptlib.NormalizeName(name)
+	for _, t := range rt.ListPrompts() {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return promptlib.Template{}, false
+}
+
+func unknownPromptMsg(name string) string {
+	if name == "" {
+		return "Usage: " + promptUsage
+	}
+	return fmt.Sprintf("There is no prompt template called %s; see /prompt list.", name)
+}
+
+func listPrompts(list []promptlib.Template) string {
+	if len(list) == 0 {
+		return "The prompt library is empty."
+	}
+	var b strings.Builder
+	b.WriteString("Prompt templates (run one with /prompt <name> <text>):")
+	for _, t := range list {
+		fmt.Fprintf(&b, "\n- %s", t.Name)
+		if t.Description != "" {
+			b.WriteString(": " + t.Description)
+		}
+		switch n := len(t.Examples); n {
+		case 0:
+		case 1:
+			b.WriteString(" (1 example)")
+		default:
+			fmt.Fprintf(&b, " (%d examples)", n)
+		}
+	}
+	return b.String()
+}
+
+func showPrompt(t promptlib.Template) string {
+	var b strings.Builder
+	source := "added in chat"
+	if t.Configured {
+		source = "configured"
+	}
+	fmt.Fprintf(&b, "Prompt template %s (%s)", t.Name, source)
+	if t.Description != "" {
+		b.WriteString("\n" + t.Description)
+	}
+	b.WriteString("\nTemplate:\n" + t.Text)
+	for i, ex := range t.Examples {
+		fmt.Fprintf(&b, "\nExample %d:\n> %s\n%s", i+1, ex.User, ex.Assistant)
+	}
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/promptlib"
+)
+
+func TestPrompt_Command(t *testing.T) {
+	configured := map[string]config.PromptPreset{"tldr": {Template: "Summarize in one line."}}
+	store := promptlib.NewStore(filepath.Join(t.TempDir(), "prompts.json"))
+	operator := false
+	var usedName, usedText string
+	rt := &Runtime{
+		IsPromptOperator: func() bool { return operator },
+		ListPrompts:      func() []promptlib.Template { return store.List(configured) },
+		SavePrompt:       store.Put,
+		RemovePrompt:     store.Remove,
+		UsePrompt: func(name, text string) error {
+			usedName, usedText = name, text
+			return nil
+		},
+	}
+
+	if reply := runCommand(t, rt, "/prompt add bugreport Write a bug report: {{.Text}}"); reply != promptOperatorOnlyMsg {
+		t.Fatalf("non-operator add reply=%q", reply)
+	}
+	operator = true
+	if reply := runCommand(t, rt, "/prompt add BugReport Write a bug report: {{.Text}}"); !strings.HasPrefix(reply,
+		"Saved prompt template bugreport.") {
+		t.Fatalf("add reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/prompt add list x"); !strings.Contains(reply, "cannot name a template") {
+		t.Fatalf("reserved name reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/prompt example bugreport it crashes => **Summary:** crash"); reply !=
+		"Added example 1 to bugreport." {
+		t.Fatalf("example reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/prompt describe bugreport Turn a complaint into a report"); reply == "" {
+		t.Fatal("describe gave no reply")
+	}
+	reply := runCommand(t, rt, "/prompt list")
+	if !strings.Contains(reply, "- bugreport: Turn a complaint into a report (1 example)") ||
+		!strings.Contains(reply, "- tldr") {
+		t.Fatalf("list reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/prompt show bugreport"); !strings.Contains(reply, "> it crashes\n**Summary:** crash") {
+		t.Fatalf("show reply=%q", reply)
+	}
+
+	if reply := runCommand(t, rt, "/prompt bugreport the app freezes on save"); reply != "" {
+		t.Fatalf("use reply=%q", reply)
+	}
+	if usedName != "bugreport" || usedText != "the app freezes on save" {
+		t.Fatalf("UsePrompt(%q, %q)", usedName, usedText)
+	}
+	if reply := runCommand(t, rt, "/prompt nope text"); !strings.Contains(reply, "no prompt template called nope") {
+		t.Fatalf("unknown reply=%q", reply)
+	}
+
+	if reply := runCommand(t, rt, "/prompt remove tldr"); !strings.Contains(reply, "set in the config file") {
+		t.Fatalf("remove configured reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/prompt remove bugreport"); reply != "Removed prompt template bugreport." {
+		t.Fatalf("remove reply=%q", reply)
+	}
+	if reply := runCommand(t, &Runtime{}, "/prompt list"); reply != unavailableMsg {
+		t.Fatalf("unavailable reply=%q", reply)
+	}
+}
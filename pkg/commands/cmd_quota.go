@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/ratelimit"
+)
+
+func quotaCommand() Definition {
+	return Definition{
+		Name:        "quota",
+		Description: "Show or reset rate limit usage",
+		SubCommands: []SubCommand{
+			{
+				Name:        "show",
+				Description: "Show what you, or a user, used of the rate limits",
+				ArgsUsage:   "[user]",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.QuotaStatus == nil {
+						return req.Reply(unavailableMsg)
+					}
+					user := strings.TrimSpace(strings.Join(req.Args, " "))
+					if user != "" && (rt.IsAdmin == nil || !rt.IsAdmin()) {
+						return req.Reply(adminOnlyMsg)
+					}
+					who, status, err := rt.QuotaStatus(user)
+					if err != nil {
+						return req.Reply("Failed to show quota: " + err.Error())
+					}
+					return req.Reply(formatQuota(who, status))
+				},
+			},
+			{
+				Name:        "reset",
+				Description: "Reset the rate limit counts of a user, or of everyone",
+				ArgsUsage:   "<user|all>",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.ResetQuota == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if rt.IsAdmin == nil || !rt.IsAdmin() {
+						return req.Reply(adminOnlyMsg)
+					}
+					user := strings.TrimSpace(strings.Join(req.Args, " "))
+					if user == "" {
+						return req.Reply("Usage: /quota reset <user|all>")
+					}
+					who, err := rt.ResetQuota(user)
+					if err != nil {
+						return req.Reply("Failed to reset quota: " + err.Error())
+					}
+					return req.Reply("Rate limit counts reset for " + who + ".")
+				},
+			},
+		},
+	}
+}
+
+func formatQuota(who string, status []ratelimit.ScopeStatus) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Rate limits for %s:", who)
+	for _, s := range status {
+		fmt.Fprintf(&b, "\n%s: %s requests this minute, %s tokens today", s.Scope,
+			quotaUse(s.Requests, s.RequestsPerMinute), quotaUse(s.Tokens, s.TokensPerDay))
+	}
+	return b.String()
+}
+
+func quotaUse(used, limit int) string {
+	if limit <= 0 {
+		return fmt.Sprintf("%d (no limit)", used)
+	}
+	return fmt.Sprintf("%d/%d", used, limit)
+}
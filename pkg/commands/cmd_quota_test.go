@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/ratelimit"
+)
+
+func TestQuota_ShowOwnUsage(t *testing.T) {
+	rt := &Runtime{
+		QuotaStatus: func(user string) (string, []ratelimit.ScopeStatus, error) {
+			if user != "" {
+				t.Fatalf("user = %q, want the sender", user)
+			}
+			return "telegram:1", []ratelimit.ScopeStatus{
+				{Scope: ratelimit.ScopeUser, Requests: 2, RequestsPerMinute: 5, Tokens: 1200, TokensPerDay: 50000},
+				{Scope: ratelimit.ScopeGlobal, Requests: 9, Tokens: 8000},
+			}, nil
+		},
+	}
+	want := "Rate limits for telegram:1:\n" +
+		"user: 2/5 requests this minute, 1200/50000 tokens today\n" +
+		"global: 9 (no limit) requests this minute, 8000 (no limit) tokens today"
+	if reply := runCommand(t, rt, "/quota show"); reply != want {
+		t.Fatalf("reply=%q", reply)
+	}
+}
+
+func TestQuota_OtherUsersNeedAdmin(t *testing.T) {
+	called := false
+	rt := &Runtime{
+		IsAdmin: func() bool { return false },
+		QuotaStatus: func(string) (string, []ratelimit.ScopeStatus, error) {
+			called = true
+			return "", nil, nil
+		},
+		ResetQuota: func(string) (string, error) {
+			called = true
+			return "", nil
+		},
+	}
+	for _, text := range []string{"/quota show 42", "/quota reset 42"} {
+		if reply := runCommand(t, rt, text); reply != adminOnlyMsg {
+			t.Errorf("%s: reply=%q", text, reply)
+		}
+	}
+	if called {
+		t.Fatal("a non-admin reached another user's quota")
+	}
+}
+
+func TestQuota_ResetAll(t *testing.T) {
+	var got string
+	rt := &Runtime{
+		IsAdmin: func() bool { return true },
+		ResetQuota: func(user string) (string, error) {
+			got = user
+			return "everyone", nil
+		},
+	}
+	if reply := runCommand(t, rt, "/quota reset all"); reply != "Rate limit counts reset for everyone." || got != "all" {
+		t.Fatalf("reply=%q user=%q", reply, got)
+	}
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func rememberCommand() Definition {
+	return Definition{
+		Name:        "remember",
+		Description: "Save a memory for this conversation, or list them",
+		Usage:       "/remember [text]",
+		Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.Remember == nil || rt.ListMemories == nil {
+				return req.Reply(unavailableMsg)
+			}
+			text := textAfterTokens(req.Text, 1)
+			if text == "" {
+				return listMemories(ctx, req, rt)
+			}
+			m, err := rt.Remember(ctx, text)
+			if err != nil {
+				return req.Reply("Could not save the memory: " + err.Error())
+			}
+			return req.Reply(fmt.Sprintf("Remembered as #%d.", m.ID))
+		},
+	}
+}
+
+func listMemories(ctx context.Context, req Request, rt *Runtime) error {
+	list, err := rt.ListMemories(ctx)
+	if err != nil {
+		return req.Reply("Could not list the memories: " + err.Error())
+	}
+	if len(list) == 0 {
+		return req.Reply("Nothing is remembered for this conversation. Save something with /remember <text>.")
+	}
+	var b strings.Builder
+	b.WriteString("Memories (remove one with /forget <number>):")
+	for _, m := range list {
+		fmt.Fprintf(&b, "\n#%d %s", m.ID, m.Text)
+	}
+	return req.Reply(b.String())
+}
+
+func forgetCommand() Definition {
+	return Definition{
+		Name:        "forget",
+		Description: "Remove a memory of this conversation, or all of them",
+		Usage:       "/forget <number|all>",
+		Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.RemoveMemory == nil || rt.ForgetMemories == nil {
+				return req.Reply(unavailableMsg)
+			}
+			if strings.EqualFold(req.Arg(0), "all") {
+				n, err := rt.ForgetMemories(ctx)
+				if err != nil {
+					return req.Reply("Could not remove the memories: " + err.Error())
+				}
+				return req.Reply(fmt.Sprintf("Removed %d memories.", n))
+			}
+			id, err := strconv.ParseInt(strings.TrimPrefix(req.Arg(0), "#"), 10, 64)
+			if err != nil {
+				return req.Reply("Usage: /forget <number|all>")
+			}
+			if err := rt.RemoveMemory(ctx, id); err != nil {
+				return req.Reply(err.Error())
+			}
+			return req.Reply(fmt.Sprintf("Removed memory #%d.", id))
+		},
+	}
+}
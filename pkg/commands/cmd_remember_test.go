@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/recall"
+)
+
+func TestRememberAndForget(t *testing.T) {
+	var memories []recall.Memory
+	rt := &Runtime{
+		Remember: func(_ context.Context, text string) (recall.Memory, error) {
+			m := recall.Memory{ID: int64(len(memories) + 1), Text: text}
+			memories = append(memories, m)
+			return m, nil
+		},
+		ListMemories: func(context.Context) ([]recall.Memory, error) {
+			return memories, nil
+		},
+		RemoveMemory: func(_ context.Context, id int64) error {
+			for i, m := range memories {
+				if m.ID == id {
+					memories = append(memories[:i], memories[i+1:]...)
+					return nil
+				}
+			}
+			return recall.ErrUnknownMemory
+		},
+		ForgetMemories: func(context.Context) (int, error) {
+			n := len(memories)
+			memories = nil
+			return n, nil
+		},
+	}
+
+	if reply := runCommand(t, rt, "/remember"); !strings.Contains(reply, "Nothing is remembered") {
+		t.Errorf("empty list: %q", reply)
+	}
+	if reply := runCommand(t, rt, "/remember I take my coffee black"); reply != "Remembered as #1." {
+		t.Errorf("remember: %q", reply)
+	}
+	runCommand(t, rt, "!remember My birthday is in May")
+	if reply := runCommand(t, rt, "/remember"); !strings.Contains(reply, "#1 I take my coffee black\n#2 My birthday") {
+		t.Errorf("list: %q", reply)
+	}
+
+	if reply := runCommand(t, rt, "/forget #1"); reply != "Removed memory #1." || len(memories) != 1 {
+		t.Errorf("forget #1: %q, %d left", reply, len(memories))
+	}
+	if reply := runCommand(t, rt, "/forget 7"); reply != recall.ErrUnknownMemory.Error() {
+		t.Errorf("forget unknown: %q", reply)
+	}
+	if reply := runCommand(t, rt, "/forget"); !strings.HasPrefix(reply, "Usage:") {
+		t.Errorf("forget without argument: %q", reply)
+	}
+	if reply := runCommand(t, rt, "/forget all"); reply != "Removed 1 memories." || len(memories) != 0 {
+		t.Errorf("forget all: %q", reply)
+	}
+
+	if reply := runCommand(t, &Runtime{}, "/remember x"); reply != unavailableMsg {
+		t.Errorf("without recall: %q", reply)
+	}
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSearchResults = 5
+	maxSearchResults     = 20
+)
+
+func searchCommand() Definition {
+	return Definition{
+		Name:        "search",
+		Description: "Search earlier messages of this conversation",
+		Usage:       "/search <query>",
+		Flags:       []Flag{{Name: "limit", Value: "<n>", Usage: "how many messages to show (default 5)"}},
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.SearchHistory == nil {
+				return req.Reply(unavailableMsg)
+			}
+			query := strings.Join(req.Args, " ")
+			if strings.TrimSpace(query) == "" {
+				return req.Reply("Usage: /search <query> [--limit <n>]")
+			}
+			limit := defaultSearchResults
+			if v := req.Flag("limit"); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 {
+					return req.Reply("The limit must be a positive number.")
+				}
+				limit = min(n, maxSearchResults)
+			}
+
+			hits := rt.SearchHistory(query, limit)
+			if len(hits) == 0 {
+				return req.Reply(fmt.Sprintf("No messages in this conversation match %q.", query))
+			}
+			var b strings.Builder
+			fmt.Fprintf(&b, "Messages matching %q:", query)
+			for i, h := range hits {
+				who := "You"
+				if h.Role == "assistant" {
+					who = "Bot"
+				}
+				fmt.Fprintf(&b, "\n%d. %s: %s", i+1, who, h.Excerpt)
+				if h.Link != "" {
+					b.WriteString("\n   " + h.Link)
+				}
+			}
+			return req.Reply(b.String())
+		},
+	}
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/search"
+)
+
+func TestSearch_Command(t *testing.T) {
+	var gotQuery string
+	var gotLimit int
+	rt := &Runtime{
+		SearchHistory: func(query string, limit int) []search.Hit {
+			gotQuery, gotLimit = query, limit
+			if query == "nothing" {
+				return nil
+			}
+			return []search.Hit{
+				{Message: search.Message{Role: "user", Link: "https://discord.com/channels/1/2/3"}, Excerpt: "deploy staging"},
+				{Message: search.Message{Role: "assistant"}, Excerpt: "run make deploy"},
+			}
+		},
+	}
+
+	reply := runCommand(t, rt, `/search deploy staging --limit 2`)
+	if gotQuery != "deploy staging" || gotLimit != 2 {
+		t.Fatalf("query = %q, limit = %d", gotQuery, gotLimit)
+	}
+	for _, want := range []string{
+		`Messages matching "deploy staging":`,
+		"1. You: deploy staging\n   https://discord.com/channels/1/2/3",
+		"2. Bot: run make deploy",
+	} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("reply missing %q:\n%s", want, reply)
+		}
+	}
+	if runCommand(t, rt, "!search deploy"); gotLimit != defaultSearchResults {
+		t.Errorf("default limit = %d", gotLimit)
+	}
+	if runCommand(t, rt, "/search deploy --limit 500"); gotLimit != maxSearchResults {
+		t.Errorf("limit not capped: %d", gotLimit)
+	}
+	if reply := runCommand(t, rt, "/search nothing"); reply != `No messages in this conversation match "nothing".` {
+		t.Errorf("no hits reply = %q", reply)
+	}
+	if reply := runCommand(t, rt, "/search"); !strings.HasPrefix(reply, "Usage:") {
+		t.Errorf("empty query reply = %q", reply)
+	}
+	if reply := runCommand(t, &Runtime{}, "/search x"); reply != unavailableMsg {
+		t.Errorf("unavailable reply = %q", reply)
+	}
+}
@@ -0,0 +1,16 @@
+package commands
+
+import "context"
+
+// stopCommand answers /stop when no reply is in flight. While one is, the
+// channel manager intercepts /stop and cancels it before it reaches the agent.
+func stopCommand() Definition {
+	return Definition{
+		Name:        "stop",
+		Description: "Stop the reply being generated",
+		Usage:       "/stop",
+		Handler: func(_ context.Context, req Request, _ *Runtime) error {
+			return req.Reply("Nothing is being generated right now.")
+		},
+	}
+}
@@ -19,8 +19,8 @@ func switchCommand() Definition {
 						return req.Reply(unavailableMsg)
 					}
 					// Parse: /switch model to <value>
-					value := nthToken(req.Text, 3) // tokens: [/switch, model, to, <value>]
-					if nthToken(req.Text, 2) != "to" || value == "" {
+					value := req.Arg(1) // args: [to, <value>]
+					if req.Arg(0) != "to" || value == "" {
 						return req.Reply("Usage: /switch model to <name>")
 					}
 					oldModel, err := rt.SwitchModel(value)
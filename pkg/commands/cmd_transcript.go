@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/transcript"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	defaultTranscriptCalls = 10
+	maxTranscriptCalls     = 50
+	// maxTranscriptShown bounds the arguments and result shown in chat; the
+	// API returns them whole.
+	maxTranscriptShown = 1500
+)
+
+func transcriptCommand() Definition {
+	return Definition{
+		Name:        "transcript",
+		Description: "Review the tool calls made in this conversation",
+		SubCommands: []SubCommand{
+			{
+				Name:        "recent",
+				Description: "List the latest tool calls",
+				ArgsUsage:   "[count]",
+				Flags:       []Flag{{Name: "tool", Value: "<name>", Usage: "only list calls of this tool"}},
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.ToolCalls == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if rt.IsAdmin == nil || !rt.IsAdmin() {
+						return req.Reply(adminOnlyMsg)
+					}
+					limit := defaultTranscriptCalls
+					if arg := req.Arg(0); arg != "" {
+						n, err := strconv.Atoi(arg)
+						if err != nil || n < 1 {
+							return req.Reply("Usage: /transcript recent [count] [--tool <name>]")
+						}
+						limit = min(n, maxTranscriptCalls)
+					}
+					calls, err := rt.ToolCalls(req.Flag("tool"), limit)
+					if err != nil {
+						return req.Reply("Could not read the transcript: " + err.Error())
+					}
+					if len(calls) == 0 {
+						return req.Reply("No tool calls recorded in this conversation.")
+					}
+					var b strings.Builder
+					b.WriteString("Recent tool calls:")
+					for _, c := range calls {
+						fmt.Fprintf(&b, "\n%s %s %s (%s, %dms) id %s",
+							c.Time.Local().Format("01-02 15:04:05"), c.Tool, c.Status,
+							utils.Truncate(formatArguments(c.Arguments), 60), c.DurationMS, c.ID)
+					}
+					return req.Reply(b.String())
+				},
+			},
+			{
+				Name:        "show",
+				Description: "Show a tool call in full",
+				ArgsUsage:   "<id>",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.ToolCall == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if rt.IsAdmin == nil || !rt.IsAdmin() {
+						return req.Reply(adminOnlyMsg)
+					}
+					id := req.Arg(0)
+					if id == "" {
+						return req.Reply("Usage: /transcript show <id>")
+					}
+					c, ok := rt.ToolCall(id)
+					if !ok {
+						return req.Reply("No tool call with that ID in this conversation.")
+					}
+					return req.Reply(formatToolCall(c))
+				},
+			},
+		},
+	}
+}
+
+func formatArguments(args map[string]any) string {
+	if len(args) == 0 {
+		return "{}"
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Sprint(args)
+	}
+	return string(data)
+}
+
+func formatToolCall(c transcript.Entry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tool call %s\n", c.ID)
+	fmt.Fprintf(&b, "Tool: %s\nStatus: %s\nTime: %s\nDuration: %dms\n",
+		c.Tool, c.Status, c.Time.Local().Format("2006-01-02 15:04:05"), c.DurationMS)
+	if c.RequestID != "" {
+		fmt.Fprintf(&b, "Request: %s\n", c.RequestID)
+	}
+	fmt.Fprintf(&b, "Arguments: %s\n", utils.Truncate(formatArguments(c.Arguments), maxTranscriptShown))
+	if c.Error != "" {
+		fmt.Fprintf(&b, "Error: %s\n", c.Error)
+	}
+	result := utils.Truncate(c.Result, maxTranscriptShown)
+	if c.Truncated && result == c.Result {
+		result += " [truncated]"
+	}
+	fmt.Fprintf(&b, "Result:\n%s", result)
+	return b.String()
+}
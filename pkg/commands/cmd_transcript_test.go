@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/transcript"
+)
+
+func TestTranscriptCommand(t *testing.T) {
+	calls := []transcript.Entry{
+		{ID: "call_1", Time: time.Now(), Tool: "exec", Status: transcript.StatusOK,
+			Arguments: map[string]any{"command": "ls"}, Result: "a.txt", DurationMS: 12},
+		{ID: "call_2", Time: time.Now(), Tool: "web_fetch", Status: transcript.StatusError, Error: "403"},
+	}
+	admin := false
+	var (
+		gotLimit int
+		gotTool  string
+	)
+	rt := &Runtime{
+		IsAdmin: func() bool { return admin },
+		ToolCalls: func(tool string, limit int) ([]transcript.Entry, error) {
+			gotTool, gotLimit = tool, limit
+			return calls, nil
+		},
+		ToolCall: func(id string) (transcript.Entry, bool) {
+			for _, c := range calls {
+				if c.ID == id {
+					return c, true
+				}
+			}
+			return transcript.Entry{}, false
+		},
+	}
+
+	if got := runCommand(t, rt, "/transcript recent"); got != adminOnlyMsg {
+		t.Fatalf("non-admin reply = %q", got)
+	}
+	admin = true
+
+	got := runCommand(t, rt, "/transcript recent 500")
+	if gotLimit != maxTranscriptCalls || !strings.Contains(got, "exec ok") ||
+		!strings.Contains(got, "web_fetch error") || !strings.Contains(got, "id call_2") {
+		t.Errorf("recent reply = %q (limit %d)", got, gotLimit)
+	}
+	runCommand(t, rt, "/transcript recent 5 --tool exec")
+	if gotTool != "exec" || gotLimit != 5 {
+		t.Errorf("--tool exec passed tool %q limit %d", gotTool, gotLimit)
+	}
+	if got := runCommand(t, rt, "/transcript recent --verbose"); !strings.Contains(got, "Unknown flag --verbose") {
+		t.Errorf("unknown flag reply = %q", got)
+	}
+	if got := runCommand(t, rt, "/transcript recent zero"); !strings.HasPrefix(got, "Usage:") {
+		t.Errorf("bad count reply = %q", got)
+	}
+
+	got = runCommand(t, rt, "!transcript show call_2")
+	if !strings.Contains(got, "Tool: web_fetch") || !strings.Contains(got, "Error: 403") {
+		t.Errorf("show reply = %q", got)
+	}
+	if got := runCommand(t, rt, "/transcript show nope"); !strings.Contains(got, "No tool call") {
+		t.Errorf("unknown id reply = %q", got)
+	}
+
+	if got := runCommand(t, &Runtime{}, "/transcript recent"); got != unavailableMsg {
+		t.Errorf("disabled reply = %q", got)
+	}
+}
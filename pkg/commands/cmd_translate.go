@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+const translateUsage = "Usage: /translate <language> <text>\n" +
+	"/translate auto <language> [language] — translate every message in this chat\n" +
+	"/translate off — stop translating this chat"
+
+func translateCommand() Definition {
+	return Definition{
+		Name:        "translate",
+		Description: "Translate text, or auto-translate this chat",
+		Usage:       "/translate <language> <text> | auto <language> [language] | off",
+		Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+			switch arg := strings.ToLower(req.Arg(0)); arg {
+			case "":
+				return req.Reply(translateUsage)
+			case "auto":
+				return setAutoTranslate(req, rt, req.Args[1:])
+			case "off":
+				return setAutoTranslate(req, rt, nil)
+			default:
+				if rt == nil || rt.Translate == nil {
+					return req.Reply(unavailableMsg)
+				}
+				text := textAfterTokens(req.Text, 2)
+				if text == "" {
+					return req.Reply(translateUsage)
+				}
+				translated, err := rt.Translate(ctx, text, req.Arg(0))
+				if err != nil {
+					return req.Reply("Translation failed: " + err.Error())
+				}
+				return req.Reply(translated)
+			}
+		},
+	}
+}
+
+func setAutoTranslate(req Request, rt *Runtime, languages []string) error {
+	if rt == nil || rt.SetAutoTranslate == nil || rt.AutoTranslate == nil {
+		return req.Reply(unavailableMsg)
+	}
+	if len(languages) > 2 {
+		return req.Reply("Auto-translate supports one or two languages.")
+	}
+	if len(languages) == 0 && strings.EqualFold(req.Arg(0), "auto") {
+		current := rt.AutoTranslate()
+		if len(current) == 0 {
+			return req.Reply("Auto-translate is off.\n" + translateUsage)
+		}
+		return req.Reply(describeAutoTranslate(current))
+	}
+	if err := rt.SetAutoTranslate(languages); err != nil {
+		return req.Reply("Failed to change auto-translate: " + err.Error())
+	}
+	if len(languages) == 0 {
+		return req.Reply("Auto-translate off.")
+	}
+	return req.Reply(describeAutoTranslate(languages))
+}
+
+func describeAutoTranslate(languages []string) string {
+	if len(languages) == 1 {
+		return "Auto-translate on: messages are translated into " + languages[0] + "."
+	}
+	return "Auto-translate on: messages in " + languages[0] + " are translated into " + languages[1] +
+		" and the other way around."
+}
+
+// textAfterTokens returns the input after its first n tokens, keeping the
+// remaining whitespace and line breaks intact. Text that is a single quoted
+// argument is returned without its quotes.
+func textAfterTokens(input string, n int) string {
+	rest := strings.TrimSpace(input)
+	for range n {
+		i := strings.IndexFunc(rest, unicode.IsSpace)
+		if i < 0 {
+			return ""
+		}
+		rest = strings.TrimLeftFunc(rest[i:], unicode.IsSpace)
+	}
+	if rest != "" {
+		if _, quoted := closingQuote[[]rune(rest)[0]]; quoted {
+			if args := splitArgs(rest); len(args) == 1 {
+				return args[0]
+			}
+		}
+	}
+	return rest
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestTranslate_Command(t *testing.T) {
+	var gotText, gotTarget string
+	rt := &Runtime{
+		Translate: func(_ context.Context, text, target string) (string, error) {
+			gotText, gotTarget = text, target
+			return "Bonjour\ntout le monde", nil
+		},
+	}
+	if reply := runCommand(t, rt, "!translate French Hello\neveryone"); reply != "Bonjour\ntout le monde" {
+		t.Fatalf("reply=%q", reply)
+	}
+	if gotText != "Hello\neveryone" || gotTarget != "French" {
+		t.Fatalf("Translate(%q, %q)", gotText, gotTarget)
+	}
+	if reply := runCommand(t, rt, "/translate French"); reply != translateUsage {
+		t.Fatalf("missing text reply=%q", reply)
+	}
+}
+
+func TestTranslate_AutoMode(t *testing.T) {
+	var languages []string
+	rt := &Runtime{
+		AutoTranslate:    func() []string { return languages },
+		SetAutoTranslate: func(l []string) error { languages = l; return nil },
+	}
+
+	if reply := runCommand(t, rt, "/translate auto English Japanese"); reply !=
+		"Auto-translate on: messages in English are translated into Japanese and the other way around." {
+		t.Fatalf("auto reply=%q", reply)
+	}
+	if !slices.Equal(languages, []string{"English", "Japanese"}) {
+		t.Fatalf("languages=%v", languages)
+	}
+	if reply := runCommand(t, rt, "/translate auto"); reply !=
+		"Auto-translate on: messages in English are translated into Japanese and the other way around." {
+		t.Fatalf("status reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/translate auto a b c"); reply != "Auto-translate supports one or two languages." {
+		t.Fatalf("too many languages reply=%q", reply)
+	}
+	if reply := runCommand(t, rt, "/translate off"); reply != "Auto-translate off." || languages != nil {
+		t.Fatalf("off reply=%q languages=%v", reply, languages)
+	}
+	if reply := runCommand(t, &Runtime{}, "/translate off"); reply != unavailableMsg {
+		t.Fatalf("unavailable reply=%q", reply)
+	}
+}
@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+const (
+	// usageReportRows bounds the rows a /usage report lists.
+	usageReportRows  = 10
+	unknownPeriodMsg = "Unknown period. Use today, week, or month."
+)
+
+func usageCommand() Definition {
+	return Definition{
+		Name:        "usage",
+		Description: "Show token use and cost",
+		SubCommands: []SubCommand{
+			{
+				Name:        "me",
+				Description: "Show your token use and cost, by model",
+				ArgsUsage:   "[today|week|month]",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.UsageReport == nil {
+						return req.Reply(unavailableMsg)
+					}
+					period := "today"
+					if len(req.Args) > 0 {
+						period = req.Args[0]
+					}
+					since, ok := usagePeriodStart(period, time.Now())
+					if !ok {
+						return req.Reply(unknownPeriodMsg)
+					}
+					rep, err := rt.UsageReport(since, usage.GroupModel, true)
+					if err != nil {
+						return req.Reply("Failed to read usage: " + err.Error())
+					}
+					return req.Reply(formatUsageReport("Your usage "+usagePeriodLabel(period), rep))
+				},
+			},
+			{
+				Name:        "report",
+				Description: "Show everyone's token use and cost",
+				ArgsUsage:   "[today|week|month] [user|channel|day|model]",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.UsageReport == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if rt.IsAdmin == nil || !rt.IsAdmin() {
+						return req.Reply(adminOnlyMsg)
+					}
+					period, groupBy := "today", usage.GroupUser
+					if len(req.Args) > 0 {
+						period = req.Args[0]
+					}
+					if len(req.Args) > 1 {
+						groupBy = strings.ToLower(req.Args[1])
+					}
+					since, ok := usagePeriodStart(period, time.Now())
+					if !ok {
+						return req.Reply(unknownPeriodMsg)
+					}
+					rep, err := rt.UsageReport(since, groupBy, false)
+					if err != nil {
+						return req.Reply("Failed to read usage: " + err.Error())
+					}
+					return req.Reply(formatUsageReport("Usage "+usagePeriodLabel(period)+" by "+groupBy, rep))
+				},
+			},
+		},
+	}
+}
+
+// usagePeriodStart returns the start of a named period ending now: today
+// since midnight, the week of the last 7 days, or the month since the 1st.
+func usagePeriodStart(period string, now time.Time) (time.Time, bool) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch strings.ToLower(period) {
+	case "today":
+		return midnight, true
+	case "week":
+		return midnight.AddDate(0, 0, -6), true
+	case "month":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), true
+	}
+	return time.Time{}, false
+}
+
+func usagePeriodLabel(period string) string {
+	switch strings.ToLower(period) {
+	case "week":
+		return "in the last 7 days"
+	case "month":
+		return "this month"
+	}
+	return "today"
+}
+
+func formatUsageReport(title string, rep usage.Report) string {
+	if rep.Total.Requests == 0 {
+		return title + ": nothing yet."
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s", title, usageLine(rep.Total))
+	for i, row := range rep.Rows {
+		if i == usageReportRows {
+			fmt.Fprintf(&b, "\n…and %d more", len(rep.Rows)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\n%s: %s", row.Key, usageLine(row))
+	}
+	return b.String()
+}
+
+func usageLine(row usage.ReportRow) string {
+	line := fmt.Sprintf("%d messages, %d tokens (prompt %d, completion %d)",
+		row.Messages, row.PromptTokens+row.CompletionTokens, row.PromptTokens, row.CompletionTokens)
+	if row.Cost > 0 {
+		line += fmt.Sprintf(", $%.4f", row.Cost)
+	}
+	return line
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func TestUsage_MeShowsOwnUsageByModel(t *testing.T) {
+	var gotGroup string
+	var gotMine bool
+	rt := &Runtime{
+		UsageReport: func(_ time.Time, groupBy string, mine bool) (usage.Report, error) {
+			gotGroup, gotMine = groupBy, mine
+			return usage.Report{
+				Total: usage.ReportRow{Messages: 2, Requests: 3, PromptTokens: 100, CompletionTokens: 20, Cost: 0.0012},
+				Rows:  []usage.ReportRow{{Key: "gpt", Messages: 2, Requests: 3, PromptTokens: 100, CompletionTokens: 20}},
+			}, nil
+		},
+	}
+	want := "Your usage this month: 2 messages, 120 tokens (prompt 100, completion 20), $0.0012\n" +
+		"gpt: 2 messages, 120 tokens (prompt 100, completion 20)"
+	if reply := runCommand(t, rt, "/usage me month"); reply != want {
+		t.Fatalf("reply=%q", reply)
+	}
+	if gotGroup != usage.GroupModel || !gotMine {
+		t.Errorf("UsageReport(%q, mine=%v)", gotGroup, gotMine)
+	}
+	if reply := runCommand(t, rt, "/usage me year"); reply != unknownPeriodMsg {
+		t.Errorf("unknown period reply=%q", reply)
+	}
+}
+
+func TestUsage_ReportNeedsAdmin(t *testing.T) {
+	rt := &Runtime{
+		IsAdmin: func() bool { return false },
+		UsageReport: func(time.Time, string, bool) (usage.Report, error) {
+			t.Fatal("a non-admin read everyone's usage")
+			return usage.Report{}, nil
+		},
+	}
+	if reply := runCommand(t, rt, "/usage report"); reply != adminOnlyMsg {
+		t.Fatalf("reply=%q", reply)
+	}
+}
+
+func TestUsagePeriodStart(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 4, 0, 0, time.UTC)
+	for period, want := range map[string]time.Time{
+		"today": time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC),
+		"week":  time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC),
+		"month": time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		if got, ok := usagePeriodStart(period, now); !ok || !got.Equal(want) {
+			t.Errorf("%s = %v, %v; want %v", period, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func variantCommand() Definition {
+	return Definition{
+		Name:        "variant",
+		Description: "Show or switch between the regenerated replies to the latest message",
+		Usage:       "/variant [next|prev|<number>]",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.ReplyVariants == nil || rt.SelectVariant == nil {
+				return req.Reply(unavailableMsg)
+			}
+			current, count := rt.ReplyVariants()
+			if count < 2 {
+				return req.Reply("The latest reply has not been regenerated. React to it with 🔁 for another one.")
+			}
+			var index int
+			switch value := strings.ToLower(req.Arg(0)); value {
+			case "":
+				return req.Reply(fmt.Sprintf(
+					"Continuing from reply %d of %d. Switch with /variant next, /variant prev, or /variant <number>.",
+					current+1, count))
+			case "next":
+				index = (current + 1) % count
+			case "prev", "previous":
+				index = (current + count - 1) % count
+			default:
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return req.Reply("Usage: /variant [next|prev|<number>]")
+				}
+				index = n - 1
+			}
+			reply, err := rt.SelectVariant(index)
+			if err != nil {
+				return req.Reply(err.Error())
+			}
+			return req.Reply(fmt.Sprintf("Reply %d of %d:\n\n%s", index+1, count, reply))
+		},
+	}
+}
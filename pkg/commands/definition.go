@@ -10,6 +10,7 @@ type SubCommand struct {
 	Name        string
 	Description string
 	ArgsUsage   string // optional, e.g. "<session-id>"
+	Flags       []Flag // optional --flags, parsed into Request.Flags
 	Handler     Handler
 }
 
@@ -25,6 +26,7 @@ type Definition struct {
 	Description string
 	Usage       string // for simple commands; ignored when SubCommands is set
 	Aliases     []string
+	Flags       []Flag       // optional --flags of a simple command
 	SubCommands []SubCommand // optional; when set, Executor routes to sub-command handlers
 	Handler     Handler      // for simple commands without sub-commands
 }
@@ -46,3 +48,15 @@ func (d Definition) EffectiveUsage() string {
 	}
 	return fmt.Sprintf("/%s [%s]", d.Name, strings.Join(names, "|"))
 }
+
+// usage returns the full usage line of the sub-command, flags included.
+func (sc SubCommand) usage(parent string) string {
+	parts := []string{"/" + parent, sc.Name}
+	if sc.ArgsUsage != "" {
+		parts = append(parts, sc.ArgsUsage)
+	}
+	if len(sc.Flags) > 0 {
+		parts = append(parts, flagsUsage(sc.Flags))
+	}
+	return strings.Join(parts, " ")
+}
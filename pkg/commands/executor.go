@@ -2,7 +2,9 @@ package commands
 
 import (
 	"context"
-	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/i18n"
 )
 
 type Outcome int
@@ -55,22 +57,38 @@ func (e *Executor) executeDefinition(ctx context.Context, req Request, def Defin
 	if req.Reply == nil {
 		req.Reply = func(string) error { return nil }
 	}
+	if req.Locale != "" {
+		reply := req.Reply
+		req.Reply = func(text string) error { return reply(i18n.T(req.Locale, text)) }
+	}
+	if !e.mayRun(def.Name) {
+		return e.refuse(req, def.Name)
+	}
+
+	tokens := splitArgs(req.Text)
+	if len(tokens) > 0 {
+		tokens = tokens[1:]
+	}
 
 	// Simple command — no sub-commands
 	if len(def.SubCommands) == 0 {
 		if def.Handler == nil {
 			return ExecuteResult{Outcome: OutcomePassthrough, Command: def.Name}
 		}
+		if err := parseArgs(&req, tokens, def.Flags); err != nil {
+			err = req.Reply(req.T("%s. Usage: %s", capitalize(err.Error()), def.EffectiveUsage()))
+			return ExecuteResult{Outcome: OutcomeHandled, Command: def.Name, Err: err}
+		}
 		err := def.Handler(ctx, req, e.rt)
 		return ExecuteResult{Outcome: OutcomeHandled, Command: def.Name, Err: err}
 	}
 
 	// Sub-command routing
-	subName := nthToken(req.Text, 1)
-	if subName == "" {
-		err := req.Reply("Usage: " + def.EffectiveUsage())
+	if len(tokens) == 0 {
+		err := req.Reply(req.T("Usage: %s", def.EffectiveUsage()))
 		return ExecuteResult{Outcome: OutcomeHandled, Command: def.Name, Err: err}
 	}
+	subName := tokens[0]
 
 	normalized := normalizeCommandName(subName)
 	for _, sc := range def.SubCommands {
@@ -78,12 +96,48 @@ func (e *Executor) executeDefinition(ctx context.Context, req Request, def Defin
 			if sc.Handler == nil {
 				return ExecuteResult{Outcome: OutcomePassthrough, Command: def.Name}
 			}
+			if !e.mayRun(def.Name + " " + sc.Name) {
+				return e.refuse(req, def.Name+" "+sc.Name)
+			}
+			if err := parseArgs(&req, tokens[1:], sc.Flags); err != nil {
+				err = req.Reply(req.T("%s. Usage: %s", capitalize(err.Error()), sc.usage(def.Name)))
+				return ExecuteResult{Outcome: OutcomeHandled, Command: def.Name, Err: err}
+			}
 			err := sc.Handler(ctx, req, e.rt)
 			return ExecuteResult{Outcome: OutcomeHandled, Command: def.Name, Err: err}
 		}
 	}
 
 	// Unknown sub-command
-	err := req.Reply(fmt.Sprintf("Unknown option: %s. Usage: %s", subName, def.EffectiveUsage()))
+	err := req.Reply(req.T("Unknown option: %s. Usage: %s", subName, def.EffectiveUsage()))
 	return ExecuteResult{Outcome: OutcomeHandled, Command: def.Name, Err: err}
 }
+
+func (e *Executor) mayRun(command string) bool {
+	return e.rt == nil || e.rt.MayRun == nil || e.rt.MayRun(command)
+}
+
+// refuse answers a command the sender may not run.
+func (e *Executor) refuse(req Request, command string) ExecuteResult {
+	err := req.Reply(req.T(notPermittedMsg, command))
+	name, _, _ := strings.Cut(command, " ")
+	return ExecuteResult{Outcome: OutcomeHandled, Command: name, Err: err}
+}
+
+// parseArgs fills req.Args and req.Flags from the tokens after the command
+// and sub-command names.
+func parseArgs(req *Request, tokens []string, flags []Flag) error {
+	args, values, err := parseFlags(tokens, flags)
+	if err != nil {
+		return err
+	}
+	req.Args, req.Flags = args, values
+	return nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -258,3 +258,63 @@ func TestExecutor_SubCommand_NilHandler_ReturnsPassthrough(t *testing.T) {
 		t.Fatalf("outcome=%v, want=%v", res.Outcome, OutcomePassthrough)
 	}
 }
+
+func TestExecutor_MayRunRefusesCommandsAndSubCommands(t *testing.T) {
+	var ran []string
+	handler := func(name string) Handler {
+		return func(context.Context, Request, *Runtime) error {
+			ran = append(ran, name)
+			return nil
+		}
+	}
+	defs := []Definition{
+		{Name: "model", Handler: handler("model")},
+		{Name: "switch", SubCommands: []SubCommand{
+			{Name: "model", Handler: handler("switch model")},
+			{Name: "channel", Handler: handler("switch channel")},
+		}},
+	}
+	rt := &Runtime{MayRun: func(command string) bool { return command == "switch" || command == "switch channel" }}
+	ex := NewExecutor(NewRegistry(defs), rt)
+
+	var reply string
+	for _, text := range []string{"/model gpt", "/switch model to gpt", "/switch channel to slack"} {
+		res := ex.Execute(context.Background(), Request{
+			Text:  text,
+			Reply: func(s string) error { reply = s; return nil },
+		})
+		if res.Outcome != OutcomeHandled {
+			t.Fatalf("%s: outcome=%v, want handled", text, res.Outcome)
+		}
+		if strings.HasPrefix(text, "/switch model") && reply != "You are not allowed to use /switch model." {
+			t.Errorf("reply = %q", reply)
+		}
+	}
+	if len(ran) != 1 || ran[0] != "switch channel" {
+		t.Errorf("ran %v, want only switch channel", ran)
+	}
+}
+
+func TestExecutor_TranslatesRepliesToLocale(t *testing.T) {
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), &Runtime{})
+	run := func(text string) string {
+		t.Helper()
+		var reply string
+		ex.Execute(context.Background(), Request{
+			Text:   text,
+			Locale: "es",
+			Reply:  func(s string) error { reply = s; return nil },
+		})
+		return reply
+	}
+
+	if reply := run("/fact list"); reply != "Este comando no está disponible aquí." {
+		t.Errorf("catalog message reply = %q", reply)
+	}
+	if reply := run("/fact nope"); !strings.HasPrefix(reply, "Opción desconocida: nope. Uso: /fact") {
+		t.Errorf("formatted reply = %q", reply)
+	}
+	if reply := run("/help"); !strings.Contains(reply, "/help [command] - Mostrar esta ayuda") {
+		t.Errorf("help does not translate descriptions:\n%s", reply)
+	}
+}
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Flag declares a --name option of a command or sub-command.
+type Flag struct {
+	Name  string
+	Value string // placeholder such as "<name>"; empty for a switch that takes no value
+	Usage string
+}
+
+// closingQuote maps each opening quote to its closing quote. Curly quotes are
+// included because phone keyboards substitute them for straight ones.
+var closingQuote = map[rune]rune{
+	'"':  '"',
+	'\'': '\'',
+	'“':  '”',
+	'‘':  '’',
+}
+
+// splitArgs splits input into whitespace-separated tokens. A token that starts
+// with a quote runs to the matching closing quote, so it may contain spaces;
+// inside double quotes a backslash escapes the next character. Quotes inside a
+// word, and an opening quote that is never closed, are kept literally so free
+// text such as "don't" splits as the user typed it.
+func splitArgs(input string) []string {
+	runes := []rune(input)
+	var tokens []string
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		if token, next, ok := quotedToken(runes, i); ok {
+			tokens = append(tokens, token)
+			i = next
+			continue
+		}
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			i++
+		}
+		tokens = append(tokens, string(runes[start:i]))
+	}
+	return tokens
+}
+
+// quotedToken reads the quoted token starting at runes[start]. It reports
+// false when runes[start] is not a quote or the quote is never closed.
+func quotedToken(runes []rune, start int) (string, int, bool) {
+	closing, ok := closingQuote[runes[start]]
+	if !ok {
+		return "", 0, false
+	}
+	var b strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && closing == '"' && i+1 < len(runes):
+			i++
+			b.WriteRune(runes[i])
+		case r == closing:
+			// A closing quote glued to more text ("a"b) is part of the word.
+			if i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+				b.WriteRune(r)
+				continue
+			}
+			return b.String(), i + 1, true
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", 0, false
+}
+
+// parseFlags separates the --flags declared in flags from the positional
+// arguments. Both "--name value" and "--name=value" are accepted, and "--"
+// ends flag parsing. When no flags are declared every token is positional, so
+// free text containing dashes passes through untouched.
+func parseFlags(tokens []string, flags []Flag) ([]string, map[string]string, error) {
+	if len(flags) == 0 {
+		return tokens, nil, nil
+	}
+	var args []string
+	values := make(map[string]string)
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token == "--" {
+			args = append(args, tokens[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(token, "--") || len(token) == 2 {
+			args = append(args, token)
+			continue
+		}
+		name, value, hasValue := strings.Cut(token[2:], "=")
+		flag, ok := findFlag(flags, name)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown flag --%s", name)
+		}
+		switch {
+		case flag.Value == "" && hasValue:
+			return nil, nil, fmt.Errorf("flag --%s does not take a value", flag.Name)
+		case flag.Value == "":
+			value = "true"
+		case !hasValue:
+			if i+1 >= len(tokens) {
+				return nil, nil, fmt.Errorf("flag --%s needs a value", flag.Name)
+			}
+			i++
+			value = tokens[i]
+		}
+		values[flag.Name] = value
+	}
+	return args, values, nil
+}
+
+func findFlag(flags []Flag, name string) (Flag, bool) {
+	name = normalizeCommandName(name)
+	for _, f := range flags {
+		if normalizeCommandName(f.Name) == name {
+			return f, true
+		}
+	}
+	return Flag{}, false
+}
+
+// flagsUsage renders flags as "[--name <value>] [--switch]".
+func flagsUsage(flags []Flag) string {
+	parts := make([]string, 0, len(flags))
+	for _, f := range flags {
+		part := "--" + f.Name
+		if f.Value != "" {
+			part += " " + f.Value
+		}
+		parts = append(parts, "["+part+"]")
+	}
+	return strings.Join(parts, " ")
+}
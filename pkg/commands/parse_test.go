@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"/prefs set persona pirate", []string{"/prefs", "set", "persona", "pirate"}},
+		{`/branch switch "my branch"`, []string{"/branch", "switch", "my branch"}},
+		{`/x 'single quoted' "say \"hi\""`, []string{"/x", "single quoted", `say "hi"`}},
+		{"/x “curly quotes” ‘too’", []string{"/x", "curly quotes", "too"}},
+		{"/fact add Don't forget", []string{"/fact", "add", "Don't", "forget"}},
+		{`/x "never closed`, []string{"/x", `"never`, "closed"}},
+		{`/x ""`, []string{"/x", ""}},
+		{`/x "a"b c"`, []string{"/x", `a"b c`}},
+		{"  /x\n\ta  ", []string{"/x", "a"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := splitArgs(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	flags := []Flag{{Name: "tool", Value: "<name>"}, {Name: "all"}}
+
+	args, values, err := parseFlags([]string{"5", "--tool", "exec", "--all", "--", "--tool"}, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(args, []string{"5", "--tool"}) {
+		t.Errorf("args = %q", args)
+	}
+	if values["tool"] != "exec" || values["all"] != "true" {
+		t.Errorf("values = %v", values)
+	}
+
+	if _, values, _ := parseFlags([]string{"--TOOL=web_fetch"}, flags); values["tool"] != "web_fetch" {
+		t.Errorf("--TOOL=web_fetch gave %v", values)
+	}
+	for _, bad := range [][]string{{"--nope"}, {"--tool"}, {"--all=yes"}} {
+		if _, _, err := parseFlags(bad, flags); err == nil {
+			t.Errorf("parseFlags(%q) succeeded", bad)
+		}
+	}
+
+	// Commands without flags keep dashes as text.
+	args, _, err = parseFlags([]string{"use", "--force"}, nil)
+	if err != nil || !reflect.DeepEqual(args, []string{"use", "--force"}) {
+		t.Errorf("no flags: args = %q, err = %v", args, err)
+	}
+}
+
+func TestExecutor_FillsArgsAndFlags(t *testing.T) {
+	var got Request
+	capture := func(_ context.Context, req Request, _ *Runtime) error {
+		got = req
+		return nil
+	}
+	defs := []Definition{
+		{Name: "say", Flags: []Flag{{Name: "loud"}}, Handler: capture},
+		{Name: "note", SubCommands: []SubCommand{
+			{Name: "add", Flags: []Flag{{Name: "tag", Value: "<tag>"}}, Handler: capture},
+		}},
+	}
+	ex := NewExecutor(NewRegistry(defs), nil)
+
+	ex.Execute(context.Background(), Request{Text: `/say "hello there" --loud`})
+	if !reflect.DeepEqual(got.Args, []string{"hello there"}) || got.Flag("loud") != "true" {
+		t.Errorf("/say args = %q flags = %v", got.Args, got.Flags)
+	}
+
+	ex.Execute(context.Background(), Request{Text: `!note add --tag=work "buy milk"`})
+	if got.Arg(0) != "buy milk" || got.Arg(1) != "" || got.Flag("tag") != "work" {
+		t.Errorf("/note add args = %q flags = %v", got.Args, got.Flags)
+	}
+
+	var reply string
+	ex.Execute(context.Background(), Request{
+		Text:  "/note add --tag",
+		Reply: func(s string) error { reply = s; return nil },
+	})
+	if reply != "Flag --tag needs a value. Usage: /note add [--tag <tag>]" {
+		t.Errorf("missing value reply = %q", reply)
+	}
+}
+
+func TestHelp_CommandDetails(t *testing.T) {
+	got := runCommand(t, nil, "/help transcript")
+	for _, want := range []string{
+		"/transcript - Review the tool calls",
+		"Usage: /transcript [recent [count]|show <id>]",
+		"recent [count] [--tool <name>] - List the latest tool calls",
+		"--tool <name>: only list calls of this tool",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("/help transcript missing %q:\n%s", want, got)
+		}
+	}
+	if got := runCommand(t, nil, "!help /nope"); !strings.HasPrefix(got, "Unknown command: /nope") {
+		t.Errorf("unknown command reply = %q", got)
+	}
+}
+
+func TestTextAfterTokens_UnquotesSingleArgument(t *testing.T) {
+	if got := textAfterTokens(`/prefs set persona "a calm pirate"`, 3); got != "a calm pirate" {
+		t.Errorf("quoted = %q", got)
+	}
+	if got := textAfterTokens(`/fact add "Quoted" words stay`, 2); got != `"Quoted" words stay` {
+		t.Errorf("partly quoted = %q", got)
+	}
+}
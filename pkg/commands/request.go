@@ -3,6 +3,8 @@ package commands
 import (
 	"context"
 	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/i18n"
 )
 
 type Handler func(ctx context.Context, req Request, rt *Runtime) error
@@ -13,9 +15,40 @@ type Request struct {
 	SenderID string
 	Text     string
 	Reply    func(text string) error
+	// Locale is the i18n code of the language to reply in; "" is English.
+	// The Executor translates replies that match a catalog message.
+	Locale string
+
+	// Args and Flags are filled in by the Executor: the positional arguments
+	// after the command and sub-command names, with quotes removed, and the
+	// values of the declared --flags.
+	Args  []string
+	Flags map[string]string
+}
+
+// Arg returns the n-th positional argument, or "" when there are fewer.
+func (r Request) Arg(n int) string {
+	if n < 0 || n >= len(r.Args) {
+		return ""
+	}
+	return r.Args[n]
 }
 
-const unavailableMsg = "Command unavailable in current context."
+// T formats format translated into the language of the request.
+func (r Request) T(format string, args ...any) string {
+	return i18n.Sprintf(r.Locale, format, args...)
+}
+
+// Flag returns the value of a declared flag, "true" for a switch that was
+// given, or "" when the flag is absent.
+func (r Request) Flag(name string) string {
+	return r.Flags[name]
+}
+
+const (
+	unavailableMsg  = "Command unavailable in current context."
+	notPermittedMsg = "You are not allowed to use /%s."
+)
 
 var commandPrefixes = []string{"/", "!"}
 
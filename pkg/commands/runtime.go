@@ -1,6 +1,22 @@
 package commands
 
-import "github.com/sipeed/picoclaw/pkg/config"
+import (
+	"context"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/branch"
+	"github.com/sipeed/picoclaw/pkg/chatexport"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/facts"
+	"github.com/sipeed/picoclaw/pkg/prefs"
+	"github.com/sipeed/picoclaw/pkg/promptlib"
+	"github.com/sipeed/picoclaw/pkg/ratelimit"
+	"github.com/sipeed/picoclaw/pkg/recall"
+	"github.com/sipeed/picoclaw/pkg/search"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+	"github.com/sipeed/picoclaw/pkg/tuning"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
 
 // Runtime provides runtime dependencies to command handlers. It is constructed
 // per-request by the agent loop so that per-request state (like session scope)
@@ -14,4 +30,121 @@ type Runtime struct {
 	SwitchModel        func(value string) (oldModel string, err error)
 	SwitchChannel      func(value string) error
 	ClearHistory       func() error
+	// IsAdmin reports whether the sender of this request is an admin.
+	IsAdmin func() bool
+	// MayRun reports whether the sender may run command, a command name or
+	// "command sub-command"; nil lets them run every command.
+	MayRun            func(command string) bool
+	MaintenanceStatus func() (active bool, notice string, queued int)
+	SetMaintenance    func(on bool, message string) (released int, err error)
+	// Calendar account linking for the sender of this request.
+	CalendarLinked func() bool
+	CalendarLink   func() (authURL string, err error)
+	CalendarUnlink func() (unlinked bool, err error)
+	// ResolveApproval runs or cancels a tool action held for this sender.
+	ResolveApproval func(id string, approve bool) (summary, output string, err error)
+	// Translate translates text into target with the agent's provider.
+	Translate func(ctx context.Context, text, target string) (string, error)
+	// AutoTranslate and SetAutoTranslate read and change this chat's
+	// auto-translate languages; no languages means auto-translate is off.
+	AutoTranslate    func() []string
+	SetAutoTranslate func(languages []string) error
+	// GetPrefs and SetPrefs read and save the sender's preferences.
+	GetPrefs func() prefs.Prefs
+	SetPrefs func(p prefs.Prefs) error
+	// ForkBranch, SwitchBranch, and ListBranches manage the conversation
+	// branches of this chat.
+	ForkBranch   func(messageRef, name string) (branchName string, err error)
+	SwitchBranch func(name string) error
+	ListBranches func() (active string, branches []branch.Branch)
+	// ReplyVariants returns which of the replies to the latest message of
+	// this conversation it continues from and how many 🔁 left, counting
+	// from 0. SelectVariant switches the conversation to another of them and
+	// returns its text.
+	ReplyVariants func() (current, count int)
+	SelectVariant func(index int) (reply string, err error)
+	// ListFacts, AddFact, and RemoveFact manage the facts pinned for this
+	// guild; they are nil outside guilds. IsFactOperator reports whether the
+	// sender may add and remove facts.
+	IsFactOperator func() bool
+	ListFacts      func() []facts.Fact
+	AddFact        func(text string) (facts.Fact, error)
+	RemoveFact     func(id int) error
+	// ListPrompts, SavePrompt, and RemovePrompt manage the prompt library;
+	// IsPromptOperator reports whether the sender may change it. UsePrompt
+	// renders the template name over text and sends the result to the model
+	// in place of the command, with the template's examples.
+	IsPromptOperator func() bool
+	ListPrompts      func() []promptlib.Template
+	SavePrompt       func(t promptlib.Template) error
+	RemovePrompt     func(name string) error
+	UsePrompt        func(name, text string) error
+	// ToolCalls returns the latest tool calls of this conversation, oldest
+	// first and limited to one tool when tool is set, and ToolCall looks one
+	// up by its ID. Both are nil when transcripts are disabled.
+	ToolCalls func(tool string, limit int) ([]transcript.Entry, error)
+	ToolCall  func(id string) (transcript.Entry, bool)
+	// GenerationSettings returns this conversation's generation overrides
+	// with the allowed ranges, and SetGenerationSettings saves new ones.
+	// IsGenerationOperator reports whether the sender may change them.
+	GenerationSettings    func() (tuning.Settings, tuning.Limits)
+	SetGenerationSettings func(s tuning.Settings) error
+	IsGenerationOperator  func() bool
+	// GuildSettings returns the generation overrides of this guild with the
+	// allowed ranges, and SetGuildSettings saves new ones; both are nil
+	// outside guilds. IsGuildAdmin reports whether the sender may change them.
+	GuildSettings    func() (tuning.Settings, tuning.Limits)
+	SetGuildSettings func(s tuning.Settings) error
+	IsGuildAdmin     func() bool
+	// SearchHistory returns up to limit earlier messages of this
+	// conversation that match query, best match first.
+	SearchHistory func(query string, limit int) []search.Hit
+	// ForgetMe deletes the data stored about the sender and returns the
+	// deletion receipt as chat text.
+	ForgetMe func() (receipt string)
+	// InspectContext estimates what the next request of this conversation
+	// sends to the model, by section, with the agent's context window. It
+	// refuses outside direct messages.
+	InspectContext func() (sections []ContextSection, window int, err error)
+	// CheckModel reports why this conversation may not switch to the
+	// model_list entry name. ListModels returns the models the
+	// conversation's provider serves and the configured ones, and whether
+	// the provider could be asked.
+	CheckModel func(name string) error
+	ListModels func(ctx context.Context) (models []ModelChoice, queried bool)
+	// Remember, ListMemories, RemoveMemory, and ForgetMemories manage the
+	// long-term memories of this conversation; they are nil when recall is
+	// disabled. ForgetMemories deletes all of them.
+	Remember       func(ctx context.Context, text string) (recall.Memory, error)
+	ListMemories   func(ctx context.Context) ([]recall.Memory, error)
+	RemoveMemory   func(ctx context.Context, id int64) error
+	ForgetMemories func(ctx context.Context) (int, error)
+	// AddDocuments, ListDocuments, RemoveDocument, and AskDocuments manage
+	// the knowledge base of this conversation; they are nil when recall is
+	// disabled. AddDocuments adds the page at url, or the files attached to
+	// the message when url is empty. AskDocuments answers question from the
+	// passages it retrieved, which the answer cites by their position.
+	AddDocuments   func(ctx context.Context, url string) ([]recall.Document, error)
+	ListDocuments  func(ctx context.Context) ([]recall.Document, error)
+	RemoveDocument func(ctx context.Context, id int64) error
+	AskDocuments   func(ctx context.Context, question string) (answer string, sources []recall.Passage, err error)
+	// ExportConversation returns the transcript of this conversation.
+	ExportConversation func() chatexport.Transcript
+	// DefaultPersona returns the persona this chat uses until the
+	// conversation picks one with /persona, or "" without one.
+	DefaultPersona func() string
+	// QuotaStatus returns what a user used of the rate limits: the sender
+	// for "", or else a sender ID or "channel:id". ResetQuota clears the
+	// counts of such a user, or of everyone for "all". Both return the user
+	// they resolved to and are nil when rate limits are off.
+	QuotaStatus func(user string) (who string, status []ratelimit.ScopeStatus, err error)
+	ResetQuota  func(user string) (who string, err error)
+	// UsageReport returns the tokens and cost since a time, grouped by one
+	// of the usage.Group keys, of the sender when mine is set or else of
+	// everyone.
+	UsageReport func(since time.Time, groupBy string, mine bool) (usage.Report, error)
+	// RateLatestReply saves the sender's rating (feedback.RatingUp or
+	// feedback.RatingDown), with an optional comment, of the latest reply in
+	// the chat.
+	RateLatestReply func(rating, comment string) error
 }
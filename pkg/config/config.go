@@ -5,13 +5,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 
 	"github.com/sipeed/picoclaw/pkg/credential"
 	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
 // rrCounter is a global counter for round-robin load balancing across models.
@@ -88,6 +92,86 @@ type Config struct {
 	Heartbeat HeartbeatConfig `json:"heartbeat"`
 	Devices   DevicesConfig   `json:"devices"`
 	Voice     VoiceConfig     `json:"voice"`
+	Digest    DigestConfig    `json:"digest"`
+	Alerts    AlertsConfig    `json:"alerts"`
+	Logging   LoggingConfig   `json:"logging"`
+	// Maintenance pauses normal service during provider migrations
+	Maintenance MaintenanceConfig `json:"maintenance"`
+	Feeds       FeedsConfig       `json:"feeds"`
+	// Knowledge syncs Notion and Confluence pages into the knowledge index
+	Knowledge KnowledgeConfig `json:"knowledge"`
+	// ObjectStorage offloads files too large for a channel to a bucket
+	ObjectStorage ObjectStorageConfig `json:"object_storage"`
+	// GitHubWebhook posts summaries of GitHub events to mapped chats
+	GitHubWebhook GitHubWebhookConfig `json:"github_webhook"`
+	// EventWebhooks explain JSON events from CI, monitoring, and other services
+	EventWebhooks EventWebhooksConfig `json:"event_webhooks"`
+	// Facts are pinned per guild by operators and added to its system prompt
+	Facts FactsConfig `json:"facts"`
+	// Transcripts records every tool call for later auditing
+	Transcripts TranscriptsConfig `json:"transcripts"`
+	// Feedback records 👍/👎 reactions to replies with the prompt they answered
+	Feedback FeedbackConfig `json:"feedback"`
+	// Recaps post a daily summary of the conversation in selected chats
+	Recaps RecapsConfig `json:"recaps"`
+	// Generation controls the per-conversation /temp, /maxtokens, and /verbosity settings
+	Generation GenerationConfig `json:"generation"`
+	// OfflineQueue holds messages while no LLM provider answers
+	OfflineQueue OfflineQueueConfig `json:"offline_queue"`
+	// Analytics records per-message latency, token, and outcome figures for export
+	Analytics AnalyticsConfig `json:"analytics"`
+	// Ephemeral keeps selected conversations in memory only
+	Ephemeral EphemeralConfig `json:"ephemeral"`
+	// Erasure controls the admin API that deletes a user's stored data
+	Erasure ErasureConfig `json:"erasure"`
+	// Tenants serves several organizations from one deployment
+	Tenants TenantsConfig `json:"tenants"`
+	// Guardrails checks messages and replies with external safety services
+	Guardrails GuardrailsConfig `json:"guardrails"`
+	// Recall embeds memories saved with /remember and adds relevant ones to each request
+	Recall RecallConfig `json:"recall"`
+	// Admin serves the admin API: sessions, history, reloads, announcements, and bans
+	Admin AdminConfig `json:"admin"`
+	// Dashboard serves a web UI over the admin API
+	Dashboard DashboardConfig `json:"dashboard"`
+	// Conversations controls the admin API that exports and imports conversations
+	Conversations ConversationsConfig `json:"conversations"`
+	// PromptTemplates renders the workspace prompt files as Go templates per message
+	PromptTemplates PromptTemplatesConfig `json:"prompt_templates"`
+	// PromptLibrary holds the named templates and few-shot presets run with /prompt
+	PromptLibrary PromptLibraryConfig `json:"prompt_library"`
+	// Personas are named presets of prompt, model, temperature, and tools switched with /persona
+	Personas PersonasConfig `json:"personas"`
+	// Localization picks the language of the bot's own messages per user and server
+	Localization LocalizationConfig `json:"localization"`
+	// Shadow answers messages without sending anything, to try prompts and models on real traffic
+	Shadow ShadowConfig `json:"shadow"`
+	// RateLimits throttles messages per user, per channel, and overall
+	RateLimits RateLimitsConfig `json:"rate_limits"`
+	// Usage serves usage and cost reports for billing
+	Usage UsageConfig `json:"usage"`
+	// Tracing exports OpenTelemetry spans of the message pipeline
+	Tracing TracingConfig `json:"tracing"`
+	// Access decides who may use the bot and its commands on every channel
+	Access AccessConfig `json:"access"`
+	// Redaction masks secrets in what the bot sends and in the log
+	Redaction RedactionConfig `json:"redaction"`
+	// Attachments adds the text of attached PDFs and text files to the prompt
+	Attachments AttachmentsConfig `json:"attachments"`
+	// CodeFiles sends long code blocks of replies as file attachments
+	CodeFiles CodeFilesConfig `json:"code_files"`
+	// Dispatch answers several conversations at once and queues the rest
+	Dispatch DispatchConfig `json:"dispatch"`
+	// ResponseCache answers repeated prompts from the reply given before
+	ResponseCache ResponseCacheConfig `json:"response_cache"`
+	// ProviderCache keeps model lists and downloaded tokenizer files on disk
+	ProviderCache ProviderCacheConfig `json:"provider_cache"`
+	// Plugins are external binaries providing tools and channels
+	Plugins map[string]PluginConfig `json:"plugins,omitempty"`
+	// Middleware filters, logs, or rewrites messages at points of the pipeline
+	Middleware []MiddlewareConfig `json:"middleware,omitempty"`
+	// Notifications customizes system-generated messages (not LLM output)
+	Notifications NotificationsConfig `json:"notifications,omitempty"`
 	// BuildInfo contains build-time version information
 	BuildInfo BuildInfo `json:"build_info,omitempty"`
 }
@@ -118,7 +202,8 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	}
 
 	// Only include session if not empty
-	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 {
+	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 || c.Session.Store != "" ||
+		c.Session.RedisURL != "" || c.Session.Encryption.Enabled || c.Session.Retention.Enabled() {
 		aux.Session = &c.Session
 	}
 
@@ -177,6 +262,8 @@ type AgentConfig struct {
 	Model     *AgentModelConfig `json:"model,omitempty"`
 	Skills    []string          `json:"skills,omitempty"`
 	Subagents *SubagentsConfig  `json:"subagents,omitempty"`
+	// MaxToolIterations overrides agents.defaults.max_tool_iterations
+	MaxToolIterations int `json:"max_tool_iterations,omitempty"`
 }
 
 type SubagentsConfig struct {
@@ -205,8 +292,71 @@ type AgentBinding struct {
 type SessionConfig struct {
 	DMScope       string              `json:"dm_scope,omitempty"`
 	IdentityLinks map[string][]string `json:"identity_links,omitempty"`
+	// Store is where conversation history is kept: "jsonl" (the default,
+	// files under workspace/sessions), "sqlite" (the workspace database), or
+	// "redis" (RedisURL, shared by every instance using it).
+	Store string `json:"store,omitempty"`
+	// RedisURL is redis://[[user]:password@]host[:port][/db], or rediss://
+	// for TLS. Sessions expire RedisTTLDays after their last message; 0
+	// keeps them.
+	RedisURL     string `json:"redis_url,omitempty"      env:"PICOCLAW_SESSION_REDIS_URL"`
+	RedisTTLDays int    `json:"redis_ttl_days,omitempty" env:"PICOCLAW_SESSION_REDIS_TTL_DAYS"`
+	// Encryption encrypts stored messages and summaries.
+	Encryption SessionEncryptionConfig `json:"encryption,omitempty"`
+	// Retention deletes stored messages once they are old enough.
+	Retention SessionRetentionConfig `json:"retention,omitempty"`
+	// SearchIndex keeps every message in a full-text index of the
+	// workspace database, so /search finds it after it left the history.
+	SearchIndex bool `json:"search_index,omitempty" env:"PICOCLAW_SESSION_SEARCH_INDEX"`
+}
+
+// SessionEncryptionConfig encrypts session history at rest with AES-256-GCM.
+// The key is the base64 of 32 bytes, printed by KeyCommand (e.g. a KMS
+// decrypt call) or else read from the environment variable KeyEnv
+// (default PICOCLAW_SESSION_KEY).
+type SessionEncryptionConfig struct {
+	Enabled    bool     `json:"enabled" env:"PICOCLAW_SESSION_ENCRYPTION_ENABLED"`
+	KeyEnv     string   `json:"key_env,omitempty"`
+	KeyCommand []string `json:"key_command,omitempty"`
+}
+
+// SessionRetentionConfig deletes session messages older than MaxAgeDays,
+// or the days Channels sets for the sessions of a channel. 0 keeps them.
+type SessionRetentionConfig struct {
+	MaxAgeDays int            `json:"max_age_days,omitempty" env:"PICOCLAW_SESSION_RETENTION_MAX_AGE_DAYS"`
+	Channels   map[string]int `json:"channels,omitempty"`
+}
+
+// Enabled reports whether any messages expire.
+func (c SessionRetentionConfig) Enabled() bool {
+	if c.MaxAgeDays > 0 {
+		return true
+	}
+	for _, days := range c.Channels {
+		if days > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxAge returns how long the messages of a session on channel are kept;
+// 0 keeps them.
+func (c SessionRetentionConfig) MaxAge(channel string) time.Duration {
+	days, ok := c.Channels[channel]
+	if !ok {
+		days = c.MaxAgeDays
+	}
+	return time.Duration(max(days, 0)) * 24 * time.Hour
 }
 
+// Session store backends.
+const (
+	SessionStoreJSONL  = "jsonl"
+	SessionStoreSQLite = "sqlite"
+	SessionStoreRedis  = "redis"
+)
+
 // RoutingConfig controls the intelligent model routing feature.
 // When enabled, each incoming message is scored against structural features
 // (message length, code blocks, tool call history, conversation depth, attachments).
@@ -219,6 +369,19 @@ type RoutingConfig struct {
 	Threshold  float64 `json:"threshold"`   // complexity score in [0,1]; score >= threshold → primary model
 }
 
+// CanaryConfig routes a share of conversations to a candidate model, prompt,
+// and/or persona while the rest stays on the stable setup. Assignment is
+// sticky per session so a conversation never switches variants mid-way.
+type CanaryConfig struct {
+	Enabled    bool     `json:"enabled"`
+	Label      string   `json:"label,omitempty"`       // variant name in usage metrics; default "canary"
+	ModelName  string   `json:"model_name,omitempty"`  // model_name from model_list; empty keeps the stable model
+	PromptFile string   `json:"prompt_file,omitempty"` // workspace-relative file appended to the system prompt
+	Persona    string   `json:"persona,omitempty"`     // personas profile, unless the session picked one with /persona
+	Percent    float64  `json:"percent"`               // share of sessions in [0,100]
+	Channels   []string `json:"channels,omitempty"`    // "channel" or "channel:chat_id" always on the canary
+}
+
 type AgentDefaults struct {
 	Workspace                 string         `json:"workspace"                       env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
 	RestrictToWorkspace       bool           `json:"restrict_to_workspace"           env:"PICOCLAW_AGENTS_DEFAULTS_RESTRICT_TO_WORKSPACE"`
@@ -235,7 +398,22 @@ type AgentDefaults struct {
 	SummarizeMessageThreshold int            `json:"summarize_message_threshold"     env:"PICOCLAW_AGENTS_DEFAULTS_SUMMARIZE_MESSAGE_THRESHOLD"`
 	SummarizeTokenPercent     int            `json:"summarize_token_percent"         env:"PICOCLAW_AGENTS_DEFAULTS_SUMMARIZE_TOKEN_PERCENT"`
 	MaxMediaSize              int            `json:"max_media_size,omitempty"        env:"PICOCLAW_AGENTS_DEFAULTS_MAX_MEDIA_SIZE"`
+	RegenerateOnEdit          bool           `json:"regenerate_on_edit,omitempty"    env:"PICOCLAW_AGENTS_DEFAULTS_REGENERATE_ON_EDIT"`
 	Routing                   *RoutingConfig `json:"routing,omitempty"`
+	Canary                    *CanaryConfig  `json:"canary,omitempty"`
+	ReserveTokens             int            `json:"reserve_tokens,omitempty"        env:"PICOCLAW_AGENTS_DEFAULTS_RESERVE_TOKENS"`
+	Progress                  ProgressConfig `json:"progress,omitempty"`
+}
+
+// ProgressConfig shows what the agent is doing while it works through the
+// steps of a turn, as a status message that the reply replaces. Labels
+// override the status shown for a tool, by tool name.
+type ProgressConfig struct {
+	Enabled bool              `json:"enabled,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_PROGRESS_ENABLED"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	// StatusAfterSeconds posts how long a turn has taken, and an estimate of
+	// the time left, once it runs longer; 0 turns the status off.
+	StatusAfterSeconds int `json:"status_after_seconds,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_PROGRESS_STATUS_AFTER_SECONDS"`
 }
 
 const DefaultMaxMediaSize = 20 * 1024 * 1024 // 20 MB
@@ -257,22 +435,27 @@ func (d *AgentDefaults) GetModelName() string {
 }
 
 type ChannelsConfig struct {
-	WhatsApp   WhatsAppConfig   `json:"whatsapp"`
-	Telegram   TelegramConfig   `json:"telegram"`
-	Feishu     FeishuConfig     `json:"feishu"`
-	Discord    DiscordConfig    `json:"discord"`
-	MaixCam    MaixCamConfig    `json:"maixcam"`
-	QQ         QQConfig         `json:"qq"`
-	DingTalk   DingTalkConfig   `json:"dingtalk"`
-	Slack      SlackConfig      `json:"slack"`
-	Matrix     MatrixConfig     `json:"matrix"`
-	LINE       LINEConfig       `json:"line"`
-	OneBot     OneBotConfig     `json:"onebot"`
-	WeCom      WeComConfig      `json:"wecom"`
-	WeComApp   WeComAppConfig   `json:"wecom_app"`
-	WeComAIBot WeComAIBotConfig `json:"wecom_aibot"`
-	Pico       PicoConfig       `json:"pico"`
-	IRC        IRCConfig        `json:"irc"`
+	WhatsApp      WhatsAppConfig      `json:"whatsapp"`
+	WhatsAppCloud WhatsAppCloudConfig `json:"whatsapp_cloud"`
+	Telegram      TelegramConfig      `json:"telegram"`
+	Feishu        FeishuConfig        `json:"feishu"`
+	Discord       DiscordConfig       `json:"discord"`
+	MaixCam       MaixCamConfig       `json:"maixcam"`
+	QQ            QQConfig            `json:"qq"`
+	DingTalk      DingTalkConfig      `json:"dingtalk"`
+	Slack         SlackConfig         `json:"slack"`
+	Matrix        MatrixConfig        `json:"matrix"`
+	LINE          LINEConfig          `json:"line"`
+	OneBot        OneBotConfig        `json:"onebot"`
+	WeCom         WeComConfig         `json:"wecom"`
+	WeComApp      WeComAppConfig      `json:"wecom_app"`
+	WeComAIBot    WeComAIBotConfig    `json:"wecom_aibot"`
+	Pico          PicoConfig          `json:"pico"`
+	IRC           IRCConfig           `json:"irc"`
+	HTTP          HTTPConfig          `json:"http"`
+	Email         EmailConfig         `json:"email"`
+	Mattermost    MattermostConfig    `json:"mattermost"`
+	XMPP          XMPPConfig          `json:"xmpp"`
 }
 
 // GroupTriggerConfig controls when the bot responds in group chats.
@@ -286,10 +469,26 @@ type TypingConfig struct {
 	Enabled bool `json:"enabled,omitempty"`
 }
 
+// StreamingConfig controls replies that are shown while they are generated.
+type StreamingConfig struct {
+	Enabled        bool `json:"enabled,omitempty"`
+	EditIntervalMS int  `json:"edit_interval_ms,omitempty"`
+}
+
 // PlaceholderConfig controls placeholder message behavior (Phase 10).
 type PlaceholderConfig struct {
 	Enabled bool   `json:"enabled,omitempty"`
 	Text    string `json:"text,omitempty"`
+	// StopButton attaches a button that stops the reply, where supported.
+	StopButton bool `json:"stop_button,omitempty"`
+}
+
+// LongOutputConfig controls the confirmation gate for very long replies.
+// When a reply would be split into more than MaxChunks messages, the user is
+// asked whether to post it all, get a summary, or receive it as a file.
+type LongOutputConfig struct {
+	Enabled   bool `json:"enabled,omitempty"`
+	MaxChunks int  `json:"max_chunks,omitempty"`
 }
 
 type WhatsAppConfig struct {
@@ -301,6 +500,33 @@ type WhatsAppConfig struct {
 	ReasoningChannelID string              `json:"reasoning_channel_id" env:"PICOCLAW_CHANNELS_WHATSAPP_REASONING_CHANNEL_ID"`
 }
 
+// WhatsAppCloudConfig connects a WhatsApp Business phone number through
+// Meta's Cloud API. Meta delivers messages to the webhook, after checking it
+// with VerifyToken, and signs them with AppSecret. More than 24 hours after
+// the user's last message only templates can be sent; ReengageTemplate is
+// sent then instead of the reply.
+type WhatsAppCloudConfig struct {
+	Enabled            bool                `json:"enabled"              env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_ENABLED"`
+	PhoneNumberID      string              `json:"phone_number_id"      env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_PHONE_NUMBER_ID"`
+	AccessToken        string              `json:"access_token"         env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_ACCESS_TOKEN"`
+	AppSecret          string              `json:"app_secret"           env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_APP_SECRET"`
+	VerifyToken        string              `json:"verify_token"         env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_VERIFY_TOKEN"`
+	WebhookPath        string              `json:"webhook_path"         env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_WEBHOOK_PATH"`
+	APIBase            string              `json:"api_base,omitempty"   env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_API_BASE"`
+	ReengageTemplate   WhatsAppTemplate    `json:"reengage_template"`
+	AllowFrom          FlexibleStringSlice `json:"allow_from"           env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_ALLOW_FROM"`
+	ReasoningChannelID string              `json:"reasoning_channel_id" env:"PICOCLAW_CHANNELS_WHATSAPP_CLOUD_REASONING_CHANNEL_ID"`
+}
+
+// WhatsAppTemplate names an approved message template. With TextParameter
+// the reply is passed as the template's first body parameter; without it the
+// reply is held and sent once the user writes back.
+type WhatsAppTemplate struct {
+	Name          string `json:"name"`
+	Language      string `json:"language"`
+	TextParameter bool   `json:"text_parameter,omitempty"`
+}
+
 type TelegramConfig struct {
 	Enabled            bool                `json:"enabled"                 env:"PICOCLAW_CHANNELS_TELEGRAM_ENABLED"`
 	Token              string              `json:"token"                   env:"PICOCLAW_CHANNELS_TELEGRAM_TOKEN"`
@@ -310,7 +536,15 @@ type TelegramConfig struct {
 	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
 	Typing             TypingConfig        `json:"typing,omitempty"`
 	Placeholder        PlaceholderConfig   `json:"placeholder,omitempty"`
+	LongOutput         LongOutputConfig    `json:"long_output,omitempty"`
 	ReasoningChannelID string              `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_TELEGRAM_REASONING_CHANNEL_ID"`
+	MaxMessageLength   int                 `json:"max_message_length"    env:"PICOCLAW_CHANNELS_TELEGRAM_MAX_MESSAGE_LENGTH"`
+	LengthUnit         string              `json:"length_unit,omitempty" env:"PICOCLAW_CHANNELS_TELEGRAM_LENGTH_UNIT"`
+	// WebhookURL switches from long polling to a webhook: the public HTTPS
+	// URL Telegram posts updates to, routed to WebhookPath on the gateway.
+	WebhookURL    string `json:"webhook_url,omitempty"    env:"PICOCLAW_CHANNELS_TELEGRAM_WEBHOOK_URL"`
+	WebhookPath   string `json:"webhook_path,omitempty"   env:"PICOCLAW_CHANNELS_TELEGRAM_WEBHOOK_PATH"`
+	WebhookSecret string `json:"webhook_secret,omitempty" env:"PICOCLAW_CHANNELS_TELEGRAM_WEBHOOK_SECRET"`
 }
 
 type FeishuConfig struct {
@@ -335,7 +569,30 @@ type DiscordConfig struct {
 	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
 	Typing             TypingConfig        `json:"typing,omitempty"`
 	Placeholder        PlaceholderConfig   `json:"placeholder,omitempty"`
+	Streaming          StreamingConfig     `json:"streaming,omitempty"`
+	LongOutput         LongOutputConfig    `json:"long_output,omitempty"`
 	ReasoningChannelID string              `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_DISCORD_REASONING_CHANNEL_ID"`
+	MaxMessageLength   int                 `json:"max_message_length"    env:"PICOCLAW_CHANNELS_DISCORD_MAX_MESSAGE_LENGTH"`
+	LengthUnit         string              `json:"length_unit,omitempty" env:"PICOCLAW_CHANNELS_DISCORD_LENGTH_UNIT"`
+	CustomEmoji        map[string]string   `json:"custom_emoji,omitempty"`
+	Shards             DiscordShardConfig  `json:"shards,omitempty"`
+	Voice              DiscordVoiceConfig  `json:"voice,omitempty"`
+}
+
+// DiscordVoiceConfig lets the bot join voice channels with /join, answer
+// what users say there and optionally speak its replies.
+type DiscordVoiceConfig struct {
+	Enabled      bool `json:"enabled"              env:"PICOCLAW_CHANNELS_DISCORD_VOICE_ENABLED"`
+	SpeakReplies bool `json:"speak_replies"        env:"PICOCLAW_CHANNELS_DISCORD_VOICE_SPEAK_REPLIES"`
+	SilenceMS    int  `json:"silence_ms,omitempty" env:"PICOCLAW_CHANNELS_DISCORD_VOICE_SILENCE_MS"` // pause that ends an utterance
+}
+
+// DiscordShardConfig controls gateway sharding for bots in many guilds.
+// Several processes can split the shards between them by listing disjoint IDs
+// with the same Count.
+type DiscordShardConfig struct {
+	Count int   `json:"count,omitempty" env:"PICOCLAW_CHANNELS_DISCORD_SHARDS_COUNT"` // 0 = use Discord's recommendation
+	IDs   []int `json:"ids,omitempty"   env:"PICOCLAW_CHANNELS_DISCORD_SHARDS_IDS"`   // shards run here; empty = all
 }
 
 type MaixCamConfig struct {
@@ -353,6 +610,7 @@ type QQConfig struct {
 	AllowFrom          FlexibleStringSlice `json:"allow_from"              env:"PICOCLAW_CHANNELS_QQ_ALLOW_FROM"`
 	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
 	MaxMessageLength   int                 `json:"max_message_length"      env:"PICOCLAW_CHANNELS_QQ_MAX_MESSAGE_LENGTH"`
+	LengthUnit         string              `json:"length_unit,omitempty"   env:"PICOCLAW_CHANNELS_QQ_LENGTH_UNIT"`
 	SendMarkdown       bool                `json:"send_markdown"           env:"PICOCLAW_CHANNELS_QQ_SEND_MARKDOWN"`
 	ReasoningChannelID string              `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_QQ_REASONING_CHANNEL_ID"`
 }
@@ -374,7 +632,50 @@ type SlackConfig struct {
 	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
 	Typing             TypingConfig        `json:"typing,omitempty"`
 	Placeholder        PlaceholderConfig   `json:"placeholder,omitempty"`
+	LongOutput         LongOutputConfig    `json:"long_output,omitempty"`
 	ReasoningChannelID string              `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_SLACK_REASONING_CHANNEL_ID"`
+	MaxMessageLength   int                 `json:"max_message_length"    env:"PICOCLAW_CHANNELS_SLACK_MAX_MESSAGE_LENGTH"`
+	LengthUnit         string              `json:"length_unit,omitempty" env:"PICOCLAW_CHANNELS_SLACK_LENGTH_UNIT"`
+}
+
+// MattermostConfig connects a bot account over the WebSocket event API.
+// Slash commands are registered in the bot's teams when CommandURL is set.
+type MattermostConfig struct {
+	Enabled            bool                               `json:"enabled"                 env:"PICOCLAW_CHANNELS_MATTERMOST_ENABLED"`
+	URL                string                             `json:"url"                     env:"PICOCLAW_CHANNELS_MATTERMOST_URL"` // e.g. https://chat.example.com
+	Token              string                             `json:"token"                   env:"PICOCLAW_CHANNELS_MATTERMOST_TOKEN"`
+	AllowFrom          FlexibleStringSlice                `json:"allow_from"              env:"PICOCLAW_CHANNELS_MATTERMOST_ALLOW_FROM"`
+	GroupTrigger       GroupTriggerConfig                 `json:"group_trigger,omitempty"`
+	Channels           map[string]MattermostChannelConfig `json:"channels,omitempty"`                                                     // channel ID or name -> overrides
+	CommandURL         string                             `json:"command_url,omitempty"   env:"PICOCLAW_CHANNELS_MATTERMOST_COMMAND_URL"` // public URL of the gateway's /webhook/mattermost
+	Typing             TypingConfig                       `json:"typing,omitempty"`
+	Placeholder        PlaceholderConfig                  `json:"placeholder,omitempty"`
+	ReasoningChannelID string                             `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_MATTERMOST_REASONING_CHANNEL_ID"`
+	MaxMessageLength   int                                `json:"max_message_length"      env:"PICOCLAW_CHANNELS_MATTERMOST_MAX_MESSAGE_LENGTH"`
+	LengthUnit         string                             `json:"length_unit,omitempty"   env:"PICOCLAW_CHANNELS_MATTERMOST_LENGTH_UNIT"`
+}
+
+// XMPPConfig logs in to an XMPP (Jabber) account, answering direct chats and
+// the multi-user chat rooms it joins.
+type XMPPConfig struct {
+	Enabled            bool                `json:"enabled"                 env:"PICOCLAW_CHANNELS_XMPP_ENABLED"`
+	JID                string              `json:"jid"                     env:"PICOCLAW_CHANNELS_XMPP_JID"` // e.g. bot@example.com
+	Password           string              `json:"password"                env:"PICOCLAW_CHANNELS_XMPP_PASSWORD"`
+	Server             string              `json:"server,omitempty"        env:"PICOCLAW_CHANNELS_XMPP_SERVER"` // host:port, default: the JID's domain on 5222
+	DirectTLS          bool                `json:"direct_tls,omitempty"    env:"PICOCLAW_CHANNELS_XMPP_DIRECT_TLS"`
+	Resource           string              `json:"resource,omitempty"      env:"PICOCLAW_CHANNELS_XMPP_RESOURCE"`
+	Rooms              FlexibleStringSlice `json:"rooms"                   env:"PICOCLAW_CHANNELS_XMPP_ROOMS"` // room@conference.example.com
+	Nick               string              `json:"nick,omitempty"          env:"PICOCLAW_CHANNELS_XMPP_NICK"`  // nickname in rooms
+	AllowFrom          FlexibleStringSlice `json:"allow_from"              env:"PICOCLAW_CHANNELS_XMPP_ALLOW_FROM"`
+	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
+	Streaming          StreamingConfig     `json:"streaming,omitempty"`
+	ReasoningChannelID string              `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_XMPP_REASONING_CHANNEL_ID"`
+}
+
+// MattermostChannelConfig overrides the Mattermost settings in one channel.
+type MattermostChannelConfig struct {
+	Ignore       bool                `json:"ignore,omitempty"`        // never answer in this channel
+	GroupTrigger *GroupTriggerConfig `json:"group_trigger,omitempty"` // replaces the channel-wide trigger
 }
 
 type MatrixConfig struct {
@@ -389,6 +690,12 @@ type MatrixConfig struct {
 	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
 	Placeholder        PlaceholderConfig   `json:"placeholder,omitempty"`
 	ReasoningChannelID string              `json:"reasoning_channel_id"     env:"PICOCLAW_CHANNELS_MATRIX_REASONING_CHANNEL_ID"`
+	// Encryption enables end-to-end encryption (requires a build with -tags
+	// goolm and DeviceID). PickleKey encrypts the keys in CryptoStorePath,
+	// which defaults to matrix/crypto.db in the workspace.
+	Encryption      bool   `json:"encryption,omitempty"        env:"PICOCLAW_CHANNELS_MATRIX_ENCRYPTION"`
+	PickleKey       string `json:"pickle_key,omitempty"        env:"PICOCLAW_CHANNELS_MATRIX_PICKLE_KEY"`
+	CryptoStorePath string `json:"crypto_store_path,omitempty" env:"PICOCLAW_CHANNELS_MATRIX_CRYPTO_STORE_PATH"`
 }
 
 type LINEConfig struct {
@@ -485,6 +792,8 @@ type IRCConfig struct {
 	SASLUser           string              `json:"sasl_user"               env:"PICOCLAW_CHANNELS_IRC_SASL_USER"`
 	SASLPassword       string              `json:"sasl_password"           env:"PICOCLAW_CHANNELS_IRC_SASL_PASSWORD"`
 	Channels           FlexibleStringSlice `json:"channels"                env:"PICOCLAW_CHANNELS_IRC_CHANNELS"`
+	ChannelKeys        map[string]string   `json:"channel_keys,omitempty"`                                              // channel -> key (+k mode)
+	MessageFormat      string              `json:"message_format,omitempty" env:"PICOCLAW_CHANNELS_IRC_MESSAGE_FORMAT"` // "mirc" (default) or "plain"
 	RequestCaps        FlexibleStringSlice `json:"request_caps,omitempty"  env:"PICOCLAW_CHANNELS_IRC_REQUEST_CAPS"`
 	AllowFrom          FlexibleStringSlice `json:"allow_from"              env:"PICOCLAW_CHANNELS_IRC_ALLOW_FROM"`
 	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
@@ -492,18 +801,740 @@ type IRCConfig struct {
 	ReasoningChannelID string              `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_IRC_REASONING_CHANNEL_ID"`
 }
 
+// HTTPConfig is a generic inbound channel: external systems POST a signed
+// prompt to Path on the gateway and get the reply as JSON.
+type HTTPConfig struct {
+	Enabled      bool                `json:"enabled"                 env:"PICOCLAW_CHANNELS_HTTP_ENABLED"`
+	Path         string              `json:"path,omitempty"          env:"PICOCLAW_CHANNELS_HTTP_PATH"`
+	Secret       string              `json:"secret"                  env:"PICOCLAW_CHANNELS_HTTP_SECRET"`
+	ReplyTimeout int                 `json:"reply_timeout,omitempty" env:"PICOCLAW_CHANNELS_HTTP_REPLY_TIMEOUT"` // seconds, default 120
+	AllowFrom    FlexibleStringSlice `json:"allow_from"              env:"PICOCLAW_CHANNELS_HTTP_ALLOW_FROM"`
+}
+
+// EmailConfig polls an IMAP mailbox and answers unread mail over SMTP. Each
+// email thread is its own conversation.
+type EmailConfig struct {
+	Enabled      bool                `json:"enabled"                 env:"PICOCLAW_CHANNELS_EMAIL_ENABLED"`
+	IMAPHost     string              `json:"imap_host"               env:"PICOCLAW_CHANNELS_EMAIL_IMAP_HOST"`
+	IMAPPort     int                 `json:"imap_port,omitempty"     env:"PICOCLAW_CHANNELS_EMAIL_IMAP_PORT"` // default 993 (TLS); other ports use STARTTLS
+	SMTPHost     string              `json:"smtp_host"               env:"PICOCLAW_CHANNELS_EMAIL_SMTP_HOST"`
+	SMTPPort     int                 `json:"smtp_port,omitempty"     env:"PICOCLAW_CHANNELS_EMAIL_SMTP_PORT"` // default 587 (STARTTLS); 465 uses TLS
+	Username     string              `json:"username"                env:"PICOCLAW_CHANNELS_EMAIL_USERNAME"`
+	Password     string              `json:"password"                env:"PICOCLAW_CHANNELS_EMAIL_PASSWORD"`
+	From         string              `json:"from,omitempty"          env:"PICOCLAW_CHANNELS_EMAIL_FROM"`          // defaults to username
+	Mailbox      string              `json:"mailbox,omitempty"       env:"PICOCLAW_CHANNELS_EMAIL_MAILBOX"`       // default INBOX
+	PollInterval int                 `json:"poll_interval,omitempty" env:"PICOCLAW_CHANNELS_EMAIL_POLL_INTERVAL"` // seconds, default 60
+	AllowFrom    FlexibleStringSlice `json:"allow_from"              env:"PICOCLAW_CHANNELS_EMAIL_ALLOW_FROM"`
+}
+
 type HeartbeatConfig struct {
 	Enabled  bool `json:"enabled"  env:"PICOCLAW_HEARTBEAT_ENABLED"`
 	Interval int  `json:"interval" env:"PICOCLAW_HEARTBEAT_INTERVAL"` // minutes, min 5
 }
 
+// DigestConfig schedules a usage digest (messages, tokens, cost, top users,
+// error rate) posted to an admin chat and/or mailed to operators.
+type DigestConfig struct {
+	Enabled  bool                `json:"enabled"            env:"PICOCLAW_DIGEST_ENABLED"`
+	Schedule string              `json:"schedule,omitempty" env:"PICOCLAW_DIGEST_SCHEDULE"` // daily | weekly
+	Hour     int                 `json:"hour"               env:"PICOCLAW_DIGEST_HOUR"`     // local hour to send, 0-23
+	Weekday  string              `json:"weekday,omitempty"  env:"PICOCLAW_DIGEST_WEEKDAY"`  // weekly only, e.g. "monday"
+	Channel  string              `json:"channel,omitempty"  env:"PICOCLAW_DIGEST_CHANNEL"`
+	ChatID   string              `json:"chat_id,omitempty"  env:"PICOCLAW_DIGEST_CHAT_ID"`
+	TopUsers int                 `json:"top_users,omitempty" env:"PICOCLAW_DIGEST_TOP_USERS"`
+	Email    DigestEmailConfig   `json:"email"`
+	Flagged  FlaggedDigestConfig `json:"flagged"`
+}
+
+// FlaggedDigestConfig mails conversations flagged by moderation or 👎
+// feedback to subscribed operators on the digest schedule, using the digest's
+// SMTP settings.
+type FlaggedDigestConfig struct {
+	To       []string `json:"to,omitempty"        env:"PICOCLAW_DIGEST_FLAGGED_TO"`
+	MaxItems int      `json:"max_items,omitempty" env:"PICOCLAW_DIGEST_FLAGGED_MAX_ITEMS"`
+}
+
+// DigestEmailConfig delivers the digest over SMTP. It is used when To is set.
+type DigestEmailConfig struct {
+	SMTPHost string   `json:"smtp_host,omitempty" env:"PICOCLAW_DIGEST_EMAIL_SMTP_HOST"`
+	SMTPPort int      `json:"smtp_port,omitempty" env:"PICOCLAW_DIGEST_EMAIL_SMTP_PORT"`
+	Username string   `json:"username,omitempty"  env:"PICOCLAW_DIGEST_EMAIL_USERNAME"`
+	Password string   `json:"password,omitempty"  env:"PICOCLAW_DIGEST_EMAIL_PASSWORD"`
+	From     string   `json:"from,omitempty"      env:"PICOCLAW_DIGEST_EMAIL_FROM"`
+	To       []string `json:"to,omitempty"        env:"PICOCLAW_DIGEST_EMAIL_TO"`
+}
+
+// LoggingConfig controls the level and format of the logs and what the
+// logger masks. Secret redaction is on by default; privacy mode logs only
+// message metadata (IDs, sizes, status).
+type LoggingConfig struct {
+	Level         string `json:"level,omitempty"  env:"PICOCLAW_LOGGING_LEVEL"`  // debug | info | warn | error
+	Format        string `json:"format,omitempty" env:"PICOCLAW_LOGGING_FORMAT"` // text | json
+	RedactSecrets bool   `json:"redact_secrets"   env:"PICOCLAW_LOGGING_REDACT_SECRETS"`
+	RedactContent bool   `json:"redact_content"   env:"PICOCLAW_LOGGING_REDACT_CONTENT"`
+	PrivacyMode   bool   `json:"privacy_mode"     env:"PICOCLAW_LOGGING_PRIVACY_MODE"`
+}
+
+// Redaction converts c to the logger's redaction settings.
+func (c LoggingConfig) Redaction() logger.Redaction {
+	return logger.Redaction{
+		Secrets:     c.RedactSecrets,
+		Content:     c.RedactContent,
+		PrivacyMode: c.PrivacyMode,
+	}
+}
+
+// LogRedaction returns the logger's redaction settings: those of Logging,
+// with the secret patterns of Redaction.
+func (c *Config) LogRedaction() logger.Redaction {
+	r := c.Logging.Redaction()
+	r.Patterns, _ = c.Redaction.SecretPatterns()
+	return r
+}
+
+// RedactionConfig masks API keys, tokens, AWS credentials, private keys, and
+// the secrets matching Patterns in replies, tool output, and every other
+// message the bot sends, when Outbound is on. The log masks the same secrets
+// when logging.redact_secrets is on.
+type RedactionConfig struct {
+	Outbound bool     `json:"outbound"           env:"PICOCLAW_REDACTION_OUTBOUND"`
+	Patterns []string `json:"patterns,omitempty"` // regular expressions
+}
+
+// SecretPatterns compiles Patterns.
+func (c RedactionConfig) SecretPatterns() ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(c.Patterns))
+	for _, p := range c.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redaction.patterns: %w", err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// AttachmentsConfig extracts the text of PDFs, text, Markdown, and source
+// files attached to a message and adds it to the prompt, split into chunks of
+// ChunkChars characters. Files over MaxBytes and PDF pages past MaxPages are
+// skipped, and a message carries at most MaxChunks chunks of all its files.
+type AttachmentsConfig struct {
+	Enabled    bool `json:"enabled"     env:"PICOCLAW_ATTACHMENTS_ENABLED"`
+	MaxBytes   int  `json:"max_bytes"   env:"PICOCLAW_ATTACHMENTS_MAX_BYTES"`
+	MaxPages   int  `json:"max_pages"   env:"PICOCLAW_ATTACHMENTS_MAX_PAGES"`
+	ChunkChars int  `json:"chunk_chars" env:"PICOCLAW_ATTACHMENTS_CHUNK_CHARS"`
+	MaxChunks  int  `json:"max_chunks"  env:"PICOCLAW_ATTACHMENTS_MAX_CHUNKS"`
+}
+
+// CodeFilesConfig sends the code blocks of a reply that have at least
+// MinLines lines as file attachments, on channels that can send files. The
+// rest of the reply stays inline.
+type CodeFilesConfig struct {
+	Enabled  bool `json:"enabled"   env:"PICOCLAW_CODE_FILES_ENABLED"`
+	MinLines int  `json:"min_lines" env:"PICOCLAW_CODE_FILES_MIN_LINES"`
+}
+
+// DispatchConfig sets how many messages the agent answers at once. The
+// messages of one conversation are answered in order; past the queue limits
+// the sender is told to try again. On shutdown the queued messages get up to
+// DrainTimeoutSeconds to be answered.
+type DispatchConfig struct {
+	Workers             int `json:"workers"               env:"PICOCLAW_DISPATCH_WORKERS"`
+	QueueSize           int `json:"queue_size"            env:"PICOCLAW_DISPATCH_QUEUE_SIZE"`
+	MaxPending          int `json:"max_pending"           env:"PICOCLAW_DISPATCH_MAX_PENDING"`
+	DrainTimeoutSeconds int `json:"drain_timeout_seconds" env:"PICOCLAW_DISPATCH_DRAIN_TIMEOUT_SECONDS"`
+}
+
+// ResponseCacheConfig answers a prompt seen within TTLMinutes, with the same
+// system prompt, earlier conversation, and model, from the reply given then.
+// Only replies that used no tools are cached. Replies are kept per session
+// unless Shared, which answers every chat from them. Channels lists the
+// channels it applies to; empty means all. With Persist the cache is kept in
+// the workspace across restarts.
+type ResponseCacheConfig struct {
+	Enabled    bool     `json:"enabled"            env:"PICOCLAW_RESPONSE_CACHE_ENABLED"`
+	TTLMinutes int      `json:"ttl_minutes"        env:"PICOCLAW_RESPONSE_CACHE_TTL_MINUTES"`
+	MaxEntries int      `json:"max_entries"        env:"PICOCLAW_RESPONSE_CACHE_MAX_ENTRIES"`
+	Persist    bool     `json:"persist"            env:"PICOCLAW_RESPONSE_CACHE_PERSIST"`
+	Shared     bool     `json:"shared"             env:"PICOCLAW_RESPONSE_CACHE_SHARED"`
+	Channels   []string `json:"channels,omitempty" env:"PICOCLAW_RESPONSE_CACHE_CHANNELS"`
+}
+
+// PluginConfig runs an external binary speaking the plugin protocol on its
+// stdin and stdout. Its tools are given to every agent and its channels run
+// in the gateway, accepting the senders in AllowFrom, or anyone when empty.
+// Settings are passed to the plugin as they are when it starts.
+type PluginConfig struct {
+	Enabled   bool                `json:"enabled"`
+	Command   string              `json:"command"`
+	Args      []string            `json:"args,omitempty"`
+	Env       map[string]string   `json:"env,omitempty"`
+	AllowFrom FlexibleStringSlice `json:"allow_from,omitempty"`
+	Settings  map[string]any      `json:"settings,omitempty"`
+}
+
+// MiddlewareConfig is one middleware of the message pipeline: a registered
+// Type (log, drop, replace, or one added by an embedding program) run at
+// Points, which are inbound, pre_llm, post_llm, and pre_send.
+type MiddlewareConfig struct {
+	Type     string         `json:"type"`
+	Points   []string       `json:"points"`
+	Settings map[string]any `json:"settings,omitempty"`
+}
+
+// AlertsConfig sends operator alerts to external sinks when the LLM error
+// rate crosses a threshold, a model keeps failing, or the daily budget runs out.
+// Repeated alerts with the same key are suppressed for the cooldown.
+type AlertsConfig struct {
+	Enabled         bool              `json:"enabled"          env:"PICOCLAW_ALERTS_ENABLED"`
+	CooldownMinutes int               `json:"cooldown_minutes" env:"PICOCLAW_ALERTS_COOLDOWN_MINUTES"`
+	ErrorRate       ErrorRateAlert    `json:"error_rate"`
+	OutageFailures  int               `json:"outage_failures"  env:"PICOCLAW_ALERTS_OUTAGE_FAILURES"` // consecutive failures, 0 disables
+	DailyBudget     float64           `json:"daily_budget"     env:"PICOCLAW_ALERTS_DAILY_BUDGET"`    // USD, 0 disables
+	Sinks           []AlertSinkConfig `json:"sinks,omitempty"`
+}
+
+// ErrorRateAlert fires when the share of failed LLM calls in the window
+// reaches Threshold (0-1). Threshold 0 disables it.
+type ErrorRateAlert struct {
+	Threshold     float64 `json:"threshold"      env:"PICOCLAW_ALERTS_ERROR_RATE_THRESHOLD"`
+	WindowMinutes int     `json:"window_minutes" env:"PICOCLAW_ALERTS_ERROR_RATE_WINDOW_MINUTES"`
+	MinRequests   int     `json:"min_requests"   env:"PICOCLAW_ALERTS_ERROR_RATE_MIN_REQUESTS"`
+}
+
+// AlertSinkConfig is one alert destination.
+type AlertSinkConfig struct {
+	Type       string            `json:"type"`                  // webhook | slack | discord | pagerduty
+	URL        string            `json:"url,omitempty"`         // webhook URL (optional for pagerduty)
+	RoutingKey string            `json:"routing_key,omitempty"` // PagerDuty Events v2 integration key
+	Headers    map[string]string `json:"headers,omitempty"`     // extra headers for the generic webhook
+}
+
+// MaintenanceConfig controls maintenance mode. While it is on, the bot
+// answers with Message, the scheduler is paused, and new work is rejected or
+// queued until maintenance ends. Admins may toggle it with /maintenance and
+// keep using the bot; the HTTP API at /maintenance requires APIToken.
+type MaintenanceConfig struct {
+	Enabled    bool     `json:"enabled"             env:"PICOCLAW_MAINTENANCE_ENABLED"` // start in maintenance mode
+	Message    string   `json:"message,omitempty"   env:"PICOCLAW_MAINTENANCE_MESSAGE"`
+	Mode       string   `json:"mode,omitempty"      env:"PICOCLAW_MAINTENANCE_MODE"` // reject | queue
+	QueueLimit int      `json:"queue_limit"         env:"PICOCLAW_MAINTENANCE_QUEUE_LIMIT"`
+	Admins     []string `json:"admins,omitempty"    env:"PICOCLAW_MAINTENANCE_ADMINS"`
+	APIToken   string   `json:"api_token,omitempty" env:"PICOCLAW_MAINTENANCE_API_TOKEN"`
+}
+
+// ProviderCacheConfig controls the cache of provider metadata in
+// workspace/cache/providers: the model lists /model shows and tokenizer
+// files given by URL. Entries are refreshed after TTLHours; when a refresh
+// fails, the stale entry is used, so the bot still starts while a provider
+// is unreachable.
+type ProviderCacheConfig struct {
+	Enabled  bool `json:"enabled"   env:"PICOCLAW_PROVIDER_CACHE_ENABLED"`
+	TTLHours int  `json:"ttl_hours" env:"PICOCLAW_PROVIDER_CACHE_TTL_HOURS"`
+}
+
+// TTL returns how long entries are fresh; 0 when the cache is off.
+func (c ProviderCacheConfig) TTL() time.Duration {
+	if !c.Enabled {
+		return 0
+	}
+	if c.TTLHours <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(c.TTLHours) * time.Hour
+}
+
+// OfflineQueueConfig controls the queue for messages that arrive while every
+// LLM provider fails. Queued messages are retried every RetrySeconds and
+// answered once a provider works again; messages older than MaxAgeHours are
+// dropped with an apology.
+type OfflineQueueConfig struct {
+	Enabled      bool   `json:"enabled"           env:"PICOCLAW_OFFLINE_QUEUE_ENABLED"`
+	Message      string `json:"message,omitempty" env:"PICOCLAW_OFFLINE_QUEUE_MESSAGE"`
+	QueueLimit   int    `json:"queue_limit"       env:"PICOCLAW_OFFLINE_QUEUE_LIMIT"`
+	RetrySeconds int    `json:"retry_seconds"     env:"PICOCLAW_OFFLINE_QUEUE_RETRY_SECONDS"`
+	MaxAgeHours  int    `json:"max_age_hours"     env:"PICOCLAW_OFFLINE_QUEUE_MAX_AGE_HOURS"`
+}
+
+// AnalyticsConfig controls the message_analytics table of the workspace
+// database: one row per handled message with its channel, model, tokens,
+// latency breakdown, and outcome. Export it with `picoclaw analytics export`.
+type AnalyticsConfig struct {
+	Enabled bool `json:"enabled" env:"PICOCLAW_ANALYTICS_ENABLED"`
+}
+
+// RecallConfig controls long-term memory. Memories saved with /remember are
+// embedded with EmbeddingModel, a model_list entry, and the TopK closest to
+// each message with a cosine similarity of at least MinScore are added to
+// its system prompt.
+type RecallConfig struct {
+	Enabled        bool    `json:"enabled"             env:"PICOCLAW_RECALL_ENABLED"`
+	EmbeddingModel string  `json:"embedding_model"     env:"PICOCLAW_RECALL_EMBEDDING_MODEL"`
+	TopK           int     `json:"top_k,omitempty"     env:"PICOCLAW_RECALL_TOP_K"`
+	MinScore       float64 `json:"min_score,omitempty" env:"PICOCLAW_RECALL_MIN_SCORE"`
+}
+
+// ConversationsConfig controls the admin API that exports a conversation as
+// JSON or Markdown and seeds a new session from an exported one. The API is
+// off without a token.
+type ConversationsConfig struct {
+	APIToken string `json:"api_token,omitempty" env:"PICOCLAW_CONVERSATIONS_API_TOKEN"`
+}
+
+// PromptTemplatesConfig renders the workspace prompt files (AGENTS.md,
+// SOUL.md, USER.md, IDENTITY.md) as Go text/templates for every message,
+// with the channel, sender, and date of the message and the custom Vars.
+// Vars maps "default", a channel name, "channel:guild_id", or
+// "channel:chat_id" to variables; more specific keys override less specific
+// ones.
+type PromptTemplatesConfig struct {
+	Enabled bool                         `json:"enabled" env:"PICOCLAW_PROMPT_TEMPLATES_ENABLED"`
+	Vars    map[string]map[string]string `json:"vars,omitempty"`
+}
+
+// PromptLibraryConfig defines the named prompt templates users run with
+// /prompt <name> <text>. Operators may add, change, and remove templates from
+// chat; those are saved in the workspace and take precedence over the ones
+// configured here.
+type PromptLibraryConfig struct {
+	Operators []string                `json:"operators,omitempty" env:"PICOCLAW_PROMPT_LIBRARY_OPERATORS"`
+	Templates map[string]PromptPreset `json:"templates,omitempty"`
+}
+
+// PromptPreset is one template of the prompt library. Template is a Go
+// text/template over the text after the name ({{.Text}}), {{.UserName}},
+// {{.ChannelName}}, {{.Date}}, and {{.Weekday}}; without {{.Text}} the text is
+// appended. Examples are sent before the message as earlier turns of the
+// conversation, to show the model the expected answer.
+type PromptPreset struct {
+	Description string          `json:"description,omitempty"`
+	Template    string          `json:"template"`
+	Examples    []PromptExample `json:"examples,omitempty"`
+}
+
+// PromptExample is one few-shot exchange of a prompt preset.
+type PromptExample struct {
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+}
+
+// LocalizationConfig controls the language of the text the bot writes
+// itself, such as errors, notices, and command descriptions. A user's
+// /prefs language wins, then the server's /config language, then the locale
+// the platform reports when DetectLocale is set, then DefaultLanguage.
+type LocalizationConfig struct {
+	DefaultLanguage string `json:"default_language,omitempty" env:"PICOCLAW_LOCALIZATION_DEFAULT_LANGUAGE"`
+	DetectLocale    bool   `json:"detect_locale"              env:"PICOCLAW_LOCALIZATION_DETECT_LOCALE"`
+}
+
+// ShadowConfig runs the bot in dry-run mode on Channels, or on every channel
+// when it is empty: messages are answered as usual, but no reply, typing
+// indicator, or status reaches the chat and no tool runs. Each reply is
+// written with the tool calls the model asked for to
+// workspace/state/shadow.jsonl instead.
+type ShadowConfig struct {
+	Enabled  bool     `json:"enabled"            env:"PICOCLAW_SHADOW_ENABLED"`
+	Channels []string `json:"channels,omitempty"`
+}
+
+// Covers reports whether messages on channel are answered in shadow mode.
+func (c ShadowConfig) Covers(channel string) bool {
+	return c.Enabled && (len(c.Channels) == 0 || slices.Contains(c.Channels, channel))
+}
+
+// PersonasConfig defines the personas conversations switch between with
+// /persona. Defaults maps "default", a channel name, "channel:guild_id", or
+// "channel:chat_id" to the persona a conversation uses until it picks one;
+// the most specific key wins.
+type PersonasConfig struct {
+	Profiles map[string]PersonaConfig `json:"profiles,omitempty"`
+	Defaults map[string]string        `json:"defaults,omitempty"`
+}
+
+// PersonaConfig is one persona. Prompt and the workspace-relative
+// PromptFile are added to the system prompt. ModelName, a model_list entry,
+// and Temperature apply unless the conversation set its own with /model or
+// /temp. Tools limits the tools the model may call; empty allows all.
+type PersonaConfig struct {
+	Description string   `json:"description,omitempty"`
+	Prompt      string   `json:"prompt,omitempty"`
+	PromptFile  string   `json:"prompt_file,omitempty"`
+	ModelName   string   `json:"model_name,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Tools       []string `json:"tools,omitempty"`
+}
+
+// EphemeralConfig selects conversations that are kept in memory only: their
+// history is never written to disk, their content is not logged, and no
+// transcript, analytics, or feedback record is kept. An ephemeral session is
+// wiped after IdleMinutes without a message.
+//
+// Channels lists channel names ("telegram") or single chats
+// ("telegram:123456"); Users lists senders in the allow_from format.
+// PrivateReplies answers Discord slash commands of these conversations with
+// responses only the user who ran the command can see.
+type EphemeralConfig struct {
+	Enabled        bool     `json:"enabled"                   env:"PICOCLAW_EPHEMERAL_ENABLED"`
+	Channels       []string `json:"channels,omitempty"        env:"PICOCLAW_EPHEMERAL_CHANNELS"`
+	Users          []string `json:"users,omitempty"           env:"PICOCLAW_EPHEMERAL_USERS"`
+	IdleMinutes    int      `json:"idle_minutes"              env:"PICOCLAW_EPHEMERAL_IDLE_MINUTES"`
+	PrivateReplies bool     `json:"private_replies,omitempty" env:"PICOCLAW_EPHEMERAL_PRIVATE_REPLIES"`
+}
+
+// ErasureConfig controls the data-subject deletion API, which does what a
+// user's /forgetme does for any user. The API is off without a token.
+type ErasureConfig struct {
+	APIToken string `json:"api_token,omitempty" env:"PICOCLAW_ERASURE_API_TOKEN"`
+}
+
+// TenantsConfig turns on multi-tenant mode. Tenants are kept in
+// workspace/state/tenants.json and managed through the /tenants API, which
+// is off without APIToken.
+type TenantsConfig struct {
+	Enabled  bool   `json:"enabled"             env:"PICOCLAW_TENANTS_ENABLED"`
+	APIToken string `json:"api_token,omitempty" env:"PICOCLAW_TENANTS_API_TOKEN"`
+}
+
+// APITokens returns the tokens an HTTP API with its own api_token accepts:
+// that token and the admin API token, leaving out unset ones. The API is off
+// when the list is empty.
+func (c *Config) APITokens(own string) []string {
+	var tokens []string
+	for _, token := range []string{own, c.Admin.APIToken} {
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// AdminConfig guards the /admin API, which lists the sessions answered since
+// the gateway started, shows and clears their history, reloads the config,
+// sends announcements, and bans users for a while. It is off without
+// APIToken.
+type AdminConfig struct {
+	APIToken string `json:"api_token,omitempty" env:"PICOCLAW_ADMIN_API_TOKEN"`
+}
+
+// DashboardConfig serves a web UI at /dashboard/ with the live sessions,
+// usage, tool calls, and channel settings. It reads them through the admin
+// API and asks for its token.
+type DashboardConfig struct {
+	Enabled bool `json:"enabled" env:"PICOCLAW_DASHBOARD_ENABLED"`
+}
+
+// GuardrailsConfig sends each user message and each reply to the guardrail
+// services before it goes on. All services of a stage run at once and the
+// stage waits at most BudgetMS for them; a blocked message or reply is
+// answered with Message instead. Log writes every decision to
+// workspace/state/moderation.jsonl.
+type GuardrailsConfig struct {
+	Enabled  bool                     `json:"enabled"           env:"PICOCLAW_GUARDRAILS_ENABLED"`
+	BudgetMS int                      `json:"budget_ms"         env:"PICOCLAW_GUARDRAILS_BUDGET_MS"`
+	Message  string                   `json:"message,omitempty" env:"PICOCLAW_GUARDRAILS_MESSAGE"`
+	Log      bool                     `json:"log,omitempty"     env:"PICOCLAW_GUARDRAILS_LOG"`
+	Services []GuardrailServiceConfig `json:"services,omitempty"`
+}
+
+// GuardrailServiceConfig is one guardrail service.
+type GuardrailServiceConfig struct {
+	Name       string            `json:"name,omitempty"`
+	Type       string            `json:"type"`                  // see docs/configuration.md for the types
+	URL        string            `json:"url,omitempty"`         // API base, nemo server, or http endpoint
+	APIKey     string            `json:"api_key,omitempty"`     // sent as a bearer token
+	Model      string            `json:"model,omitempty"`       // llama_guard or openai_moderation model name
+	ConfigID   string            `json:"config_id,omitempty"`   // nemo guardrails configuration
+	Headers    map[string]string `json:"headers,omitempty"`     // extra request headers
+	Keywords   []string          `json:"keywords,omitempty"`    // rules: words matched case-insensitively
+	Patterns   []string          `json:"patterns,omitempty"`    // rules: regular expressions
+	Path       string            `json:"path,omitempty"`        // classifier: the weights file
+	Threshold  float64           `json:"threshold,omitempty"`   // classifier: unsafe from this score; 0.5 when 0
+	Stages     []string          `json:"stages,omitempty"`      // input and/or output; both when empty
+	Action     string            `json:"action,omitempty"`      // block (default) | redact | flag
+	Channels   map[string]string `json:"channels,omitempty"`    // channel name to the action there, or off
+	TimeoutMS  int               `json:"timeout_ms,omitempty"`  // per call, within the budget
+	FailClosed bool              `json:"fail_closed,omitempty"` // block when the service errors or times out
+}
+
+// FactsConfig controls the facts operators pin for a guild with /fact. All
+// facts of one guild together must fit in MaxTokens.
+type FactsConfig struct {
+	MaxTokens int      `json:"max_tokens"          env:"PICOCLAW_FACTS_MAX_TOKENS"`
+	Operators []string `json:"operators,omitempty" env:"PICOCLAW_FACTS_OPERATORS"`
+}
+
+// GenerationConfig lets operators change the temperature, completion token
+// limit, and verbosity of a conversation from chat. Values are clamped to the
+// ranges; a zero bound is open. Anyone can view the current settings.
+type GenerationConfig struct {
+	Operators   []string   `json:"operators,omitempty" env:"PICOCLAW_GENERATION_OPERATORS"`
+	Temperature FloatRange `json:"temperature"`
+	MaxTokens   IntRange   `json:"max_tokens"`
+}
+
+// FloatRange is an inclusive range of floating-point values.
+type FloatRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// IntRange is an inclusive range of whole numbers.
+type IntRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// TranscriptsConfig controls the persisted record of tool calls, viewable
+// by admins with /transcript and through the transcripts API.
+type TranscriptsConfig struct {
+	Enabled        bool   `json:"enabled"             env:"PICOCLAW_TRANSCRIPTS_ENABLED"`
+	RetentionDays  int    `json:"retention_days"      env:"PICOCLAW_TRANSCRIPTS_RETENTION_DAYS"`   // 0 keeps transcripts forever
+	MaxResultChars int    `json:"max_result_chars"    env:"PICOCLAW_TRANSCRIPTS_MAX_RESULT_CHARS"` // 0 keeps results whole
+	APIToken       string `json:"api_token,omitempty" env:"PICOCLAW_TRANSCRIPTS_API_TOKEN"`
+}
+
+// FeedbackConfig controls the collection of reactions to the bot's replies.
+type FeedbackConfig struct {
+	Enabled  bool   `json:"enabled"             env:"PICOCLAW_FEEDBACK_ENABLED"`
+	APIToken string `json:"api_token,omitempty" env:"PICOCLAW_FEEDBACK_API_TOKEN"` // enables GET /feedback
+}
+
+// FeedsConfig polls RSS/Atom feeds and posts an LLM summary of each new
+// entry to the feed's channel. Prompt is a Go text/template; see
+// docs/configuration.md for the fields it can use.
+type FeedsConfig struct {
+	Enabled         bool             `json:"enabled"          env:"PICOCLAW_FEEDS_ENABLED"`
+	IntervalMinutes int              `json:"interval_minutes" env:"PICOCLAW_FEEDS_INTERVAL_MINUTES"`
+	MaxItems        int              `json:"max_items"        env:"PICOCLAW_FEEDS_MAX_ITEMS"` // posted per poll, per feed
+	Prompt          string           `json:"prompt,omitempty"`
+	Sources         []FeedSource     `json:"sources,omitempty"`
+	Digest          FeedDigestConfig `json:"digest"`
+}
+
+// FeedDigestConfig collects new entries and posts them as one summary on a
+// schedule, instead of a message per entry. Channel and ChatID send the
+// digest of every feed to one chat; without them each feed's chat gets a
+// digest of its own feeds.
+type FeedDigestConfig struct {
+	Enabled    bool   `json:"enabled"               env:"PICOCLAW_FEEDS_DIGEST_ENABLED"`
+	Schedule   string `json:"schedule,omitempty"    env:"PICOCLAW_FEEDS_DIGEST_SCHEDULE"` // daily | weekly
+	Hour       int    `json:"hour"                  env:"PICOCLAW_FEEDS_DIGEST_HOUR"`     // local hour to send, 0-23
+	Weekday    string `json:"weekday,omitempty"     env:"PICOCLAW_FEEDS_DIGEST_WEEKDAY"`  // weekly only, e.g. "monday"
+	Channel    string `json:"channel,omitempty"     env:"PICOCLAW_FEEDS_DIGEST_CHANNEL"`
+	ChatID     string `json:"chat_id,omitempty"     env:"PICOCLAW_FEEDS_DIGEST_CHAT_ID"`
+	MaxEntries int    `json:"max_entries,omitempty" env:"PICOCLAW_FEEDS_DIGEST_MAX_ENTRIES"` // per digest
+	Prompt     string `json:"prompt,omitempty"`
+	// Wait up to a day for the summary, so a model with "batch" set writes
+	// it through its batch API
+	Batch bool `json:"batch,omitempty" env:"PICOCLAW_FEEDS_DIGEST_BATCH"`
+}
+
+// FeedSource is one watched feed. IntervalMinutes and Prompt override the
+// FeedsConfig defaults when set. Channel and ChatID may be left out when
+// the digest has its own chat.
+type FeedSource struct {
+	Name            string `json:"name"`
+	URL             string `json:"url"`
+	Channel         string `json:"channel"`
+	ChatID          string `json:"chat_id"`
+	IntervalMinutes int    `json:"interval_minutes,omitempty"`
+	Prompt          string `json:"prompt,omitempty"`
+}
+
+// RateLimitsConfig limits how many messages the agent answers per minute
+// and how many LLM tokens they may use per day, for each user, each channel,
+// and everyone together. Zero leaves a limit off. Commands are never
+// limited, and Exempt senders are never limited at all.
+type RateLimitsConfig struct {
+	Enabled    bool      `json:"enabled"          env:"PICOCLAW_RATE_LIMITS_ENABLED"`
+	PerUser    RateLimit `json:"per_user"`
+	PerChannel RateLimit `json:"per_channel"`
+	Global     RateLimit `json:"global"`
+	Exempt     []string  `json:"exempt,omitempty" env:"PICOCLAW_RATE_LIMITS_EXEMPT"` // sender IDs or "channel:id"
+}
+
+// RateLimit is one set of limits.
+type RateLimit struct {
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	TokensPerDay      int `json:"tokens_per_day,omitempty"`
+}
+
+// TracingConfig exports a trace of each message, with spans for the
+// gateway event, each provider call, each tool call, and the delivery of
+// the reply, over OTLP/HTTP to Endpoint (default OTEL_EXPORTER_OTLP_ENDPOINT,
+// or else http://localhost:4318). SampleRatio keeps that share of traces;
+// 0 keeps all.
+type TracingConfig struct {
+	Enabled     bool              `json:"enabled"                env:"PICOCLAW_TRACING_ENABLED"`
+	Endpoint    string            `json:"endpoint,omitempty"     env:"PICOCLAW_TRACING_ENDPOINT"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ServiceName string            `json:"service_name,omitempty" env:"PICOCLAW_TRACING_SERVICE_NAME"`
+	SampleRatio float64           `json:"sample_ratio,omitempty" env:"PICOCLAW_TRACING_SAMPLE_RATIO"`
+}
+
+// AccessConfig decides who may use the bot, on every channel, after the
+// allow_from of the channel let the message in. Entries are sender IDs as in
+// allow_from, "role:<id>" for members of a Discord role, "guild:<id>" for
+// everyone in a Discord server, "admins" for the maintenance admins, or "*".
+// Deny wins over Allow, and an empty Allow lets everyone in. Commands maps a
+// command, or a command and sub-command such as "switch model", to the
+// entries that may run it; commands not listed are open to everyone.
+type AccessConfig struct {
+	Allow    []string            `json:"allow,omitempty"`
+	Deny     []string            `json:"deny,omitempty"`
+	Commands map[string][]string `json:"commands,omitempty"`
+}
+
+// UsageConfig controls the usage report API at /usage, which serves the
+// tokens and cost of the usage log as JSON. The API is off without a token.
+type UsageConfig struct {
+	APIToken string `json:"api_token,omitempty" env:"PICOCLAW_USAGE_API_TOKEN"`
+}
+
+// RecapsConfig posts a daily summary of what was said in each target chat.
+// Only channels that can read message history support it. Prompt is a Go
+// text/template; see docs/configuration.md for the fields it can use.
+type RecapsConfig struct {
+	Enabled     bool          `json:"enabled"      env:"PICOCLAW_RECAPS_ENABLED"`
+	Hour        int           `json:"hour"         env:"PICOCLAW_RECAPS_HOUR"` // local time
+	MaxMessages int           `json:"max_messages" env:"PICOCLAW_RECAPS_MAX_MESSAGES"`
+	Prompt      string        `json:"prompt,omitempty"`
+	Targets     []RecapTarget `json:"targets,omitempty"`
+}
+
+// RecapTarget is one summarized chat. PostTo is the chat on the same channel
+// that receives the summary, such as a DM; it defaults to ChatID.
+type RecapTarget struct {
+	Name    string `json:"name,omitempty"`
+	Channel string `json:"channel"`
+	ChatID  string `json:"chat_id"`
+	PostTo  string `json:"post_to,omitempty"`
+}
+
+// KnowledgeConfig syncs Notion databases and Confluence spaces into the
+// local knowledge index searched by the knowledge_search tool. Each sync only
+// fetches pages edited since the previous one.
+type KnowledgeConfig struct {
+	Enabled         bool                 `json:"enabled"          env:"PICOCLAW_KNOWLEDGE_ENABLED"`
+	IntervalMinutes int                  `json:"interval_minutes" env:"PICOCLAW_KNOWLEDGE_INTERVAL_MINUTES"`
+	Notion          NotionSyncConfig     `json:"notion"`
+	Confluence      ConfluenceSyncConfig `json:"confluence"`
+}
+
+// NotionSyncConfig reads the given databases with an internal integration
+// token. Each database must be shared with the integration.
+type NotionSyncConfig struct {
+	Token       string   `json:"token,omitempty"        env:"PICOCLAW_KNOWLEDGE_NOTION_TOKEN"`
+	DatabaseIDs []string `json:"database_ids,omitempty" env:"PICOCLAW_KNOWLEDGE_NOTION_DATABASE_IDS"`
+}
+
+// ConfluenceSyncConfig reads the pages of the given space keys. BaseURL is
+// the wiki root, e.g. https://acme.atlassian.net/wiki.
+type ConfluenceSyncConfig struct {
+	BaseURL  string   `json:"base_url,omitempty"  env:"PICOCLAW_KNOWLEDGE_CONFLUENCE_BASE_URL"`
+	Email    string   `json:"email,omitempty"     env:"PICOCLAW_KNOWLEDGE_CONFLUENCE_EMAIL"`
+	APIToken string   `json:"api_token,omitempty" env:"PICOCLAW_KNOWLEDGE_CONFLUENCE_API_TOKEN"`
+	Spaces   []string `json:"spaces,omitempty"    env:"PICOCLAW_KNOWLEDGE_CONFLUENCE_SPACES"`
+}
+
+// ObjectStorageConfig uploads files that exceed a channel's upload limit to
+// an S3-compatible bucket (AWS S3, Google Cloud Storage with HMAC keys, MinIO,
+// R2) and sends a presigned download link instead. Endpoint is only needed
+// for services other than S3 and GCS.
+type ObjectStorageConfig struct {
+	Enabled         bool             `json:"enabled"                 env:"PICOCLAW_OBJECT_STORAGE_ENABLED"`
+	Provider        string           `json:"provider"                env:"PICOCLAW_OBJECT_STORAGE_PROVIDER"` // s3 | gcs
+	Bucket          string           `json:"bucket"                  env:"PICOCLAW_OBJECT_STORAGE_BUCKET"`
+	Region          string           `json:"region,omitempty"        env:"PICOCLAW_OBJECT_STORAGE_REGION"`
+	Endpoint        string           `json:"endpoint,omitempty"      env:"PICOCLAW_OBJECT_STORAGE_ENDPOINT"`
+	AccessKeyID     string           `json:"access_key_id"           env:"PICOCLAW_OBJECT_STORAGE_ACCESS_KEY_ID"`
+	SecretAccessKey string           `json:"secret_access_key"       env:"PICOCLAW_OBJECT_STORAGE_SECRET_ACCESS_KEY"`
+	Prefix          string           `json:"prefix,omitempty"        env:"PICOCLAW_OBJECT_STORAGE_PREFIX"`
+	LinkTTLMinutes  int              `json:"link_ttl_minutes"        env:"PICOCLAW_OBJECT_STORAGE_LINK_TTL_MINUTES"`
+	MaxObjectSize   int64            `json:"max_object_size"         env:"PICOCLAW_OBJECT_STORAGE_MAX_OBJECT_SIZE"` // bytes
+	UploadLimits    map[string]int64 `json:"upload_limits,omitempty"`                                               // channel -> bytes
+}
+
+// GitHubWebhookConfig accepts GitHub webhooks on the gateway HTTP server and
+// posts an LLM summary (push, issue comment) or review checklist (pull
+// request opened) to the chats routed for the repository. Prompts overrides
+// the template for an event name.
+type GitHubWebhookConfig struct {
+	Enabled bool              `json:"enabled"          env:"PICOCLAW_GITHUB_WEBHOOK_ENABLED"`
+	Path    string            `json:"path,omitempty"   env:"PICOCLAW_GITHUB_WEBHOOK_PATH"`
+	Secret  string            `json:"secret,omitempty" env:"PICOCLAW_GITHUB_WEBHOOK_SECRET"`
+	Prompts map[string]string `json:"prompts,omitempty"`
+	Routes  []GitHubRoute     `json:"routes,omitempty"`
+}
+
+// GitHubRoute sends events from matching repositories to one chat.
+type GitHubRoute struct {
+	Repo    string   `json:"repo"`             // owner/name, owner/*, or *
+	Events  []string `json:"events,omitempty"` // push | pull_request | issue_comment; empty means all
+	Channel string   `json:"channel"`
+	ChatID  string   `json:"chat_id"`
+}
+
+// EventWebhooksConfig accepts JSON webhooks from any service on the gateway
+// HTTP server at <Path>/<hook name> and posts an LLM explanation of each
+// delivery to the hook's chat. Prompt is the default Go text/template; a
+// hook can override it.
+type EventWebhooksConfig struct {
+	Enabled bool           `json:"enabled"          env:"PICOCLAW_EVENT_WEBHOOKS_ENABLED"`
+	Path    string         `json:"path,omitempty"   env:"PICOCLAW_EVENT_WEBHOOKS_PATH"`
+	Prompt  string         `json:"prompt,omitempty"`
+	Hooks   []EventWebhook `json:"hooks,omitempty"`
+}
+
+// EventWebhook is one endpoint and the chat its events are posted to.
+// Deliveries must present Secret as a token or sign the body with it.
+type EventWebhook struct {
+	Name    string `json:"name"`
+	Secret  string `json:"secret"`
+	Channel string `json:"channel"`
+	ChatID  string `json:"chat_id"`
+	Prompt  string `json:"prompt,omitempty"`
+}
+
+// NotificationsConfig overrides the templates used for system-generated
+// notifications. Templates maps an event name (e.g. "cron.command_output")
+// to a channel name (or "default") to a Go text/template string.
+type NotificationsConfig struct {
+	Templates map[string]map[string]string `json:"templates,omitempty"`
+}
+
 type DevicesConfig struct {
 	Enabled    bool `json:"enabled"     env:"PICOCLAW_DEVICES_ENABLED"`
 	MonitorUSB bool `json:"monitor_usb" env:"PICOCLAW_DEVICES_MONITOR_USB"`
 }
 
 type VoiceConfig struct {
-	EchoTranscription bool `json:"echo_transcription" env:"PICOCLAW_VOICE_ECHO_TRANSCRIPTION"`
+	EchoTranscription bool      `json:"echo_transcription" env:"PICOCLAW_VOICE_ECHO_TRANSCRIPTION"`
+	STT               STTConfig `json:"stt,omitempty"`
+	TTS               TTSConfig `json:"tts,omitempty"`
+}
+
+// STTConfig chooses the speech-to-text provider. With no provider, a Groq
+// key is used when one is configured.
+type STTConfig struct {
+	Provider string `json:"provider,omitempty" env:"PICOCLAW_VOICE_STT_PROVIDER"` // groq, whisper or whisper_cpp
+	APIKey   string `json:"api_key,omitempty"  env:"PICOCLAW_VOICE_STT_API_KEY"`
+	APIBase  string `json:"api_base,omitempty" env:"PICOCLAW_VOICE_STT_API_BASE"`
+	Model    string `json:"model,omitempty"    env:"PICOCLAW_VOICE_STT_MODEL"`
+}
+
+// TTSConfig configures the speech provider used to speak replies in voice
+// channels and to attach spoken replies. With no provider, the OpenAI speech
+// API is used, and empty key and base fall back to the OpenAI provider.
+type TTSConfig struct {
+	Provider string `json:"provider,omitempty" env:"PICOCLAW_VOICE_TTS_PROVIDER"` // openai, elevenlabs or piper
+	APIKey   string `json:"api_key,omitempty"  env:"PICOCLAW_VOICE_TTS_API_KEY"`
+	APIBase  string `json:"api_base,omitempty" env:"PICOCLAW_VOICE_TTS_API_BASE"`
+	Model    string `json:"model,omitempty"    env:"PICOCLAW_VOICE_TTS_MODEL"`
+	Voice    string `json:"voice,omitempty"    env:"PICOCLAW_VOICE_TTS_VOICE"`
+	// Replies attaches the spoken reply to every answer, for users who have
+	// not chosen with /prefs set tts.
+	Replies bool `json:"replies,omitempty" env:"PICOCLAW_VOICE_TTS_REPLIES"`
 }
 
 type ProvidersConfig struct {
@@ -600,6 +1631,8 @@ type ModelConfig struct {
 	APIBase string `json:"api_base,omitempty"` // API endpoint URL
 	APIKey  string `json:"api_key"`            // API authentication key
 	Proxy   string `json:"proxy,omitempty"`    // HTTP proxy URL
+	// Extra request headers for OpenAI-compatible endpoints
+	Headers map[string]string `json:"headers,omitempty"`
 
 	// Special providers (CLI-based, OAuth, etc.)
 	AuthMethod  string `json:"auth_method,omitempty"`  // Authentication method: oauth, token
@@ -611,6 +1644,23 @@ type ModelConfig struct {
 	MaxTokensField string `json:"max_tokens_field,omitempty"` // Field name for max tokens (e.g., "max_completion_tokens")
 	RequestTimeout int    `json:"request_timeout,omitempty"`
 	ThinkingLevel  string `json:"thinking_level,omitempty"` // Extended thinking: off|low|medium|high|xhigh|adaptive
+	Priority       int    `json:"priority,omitempty"`       // Failover order among entries sharing a model_name
+	// Send summaries and other jobs nobody waits on through the OpenAI Batch
+	// API, at half price but answered within 24 hours
+	Batch bool `json:"batch,omitempty"`
+
+	// Ollama native API (ollama-native protocol)
+	KeepAlive string `json:"keep_alive,omitempty"` // How long the model stays loaded, e.g. "10m" or "-1"
+	AutoPull  bool   `json:"auto_pull,omitempty"`  // Pull the model on first use when the server lacks it
+
+	// Pricing in USD per million tokens, used for cost reporting
+	InputPrice  float64 `json:"input_price,omitempty"`
+	OutputPrice float64 `json:"output_price,omitempty"`
+
+	// Context budgeting: history is trimmed to fit context_window tokens,
+	// counted with the tokenizer (a tiktoken rank file, or the heuristic)
+	ContextWindow int    `json:"context_window,omitempty"`
+	Tokenizer     string `json:"tokenizer,omitempty"`
 }
 
 // Validate checks if the ModelConfig has all required fields.
@@ -625,9 +1675,24 @@ func (c *ModelConfig) Validate() error {
 }
 
 type GatewayConfig struct {
-	Host      string `json:"host"       env:"PICOCLAW_GATEWAY_HOST"`
-	Port      int    `json:"port"       env:"PICOCLAW_GATEWAY_PORT"`
-	HotReload bool   `json:"hot_reload" env:"PICOCLAW_GATEWAY_HOT_RELOAD"`
+	Host      string   `json:"host"       env:"PICOCLAW_GATEWAY_HOST"`
+	Port      int      `json:"port"       env:"PICOCLAW_GATEWAY_PORT"`
+	HotReload bool     `json:"hot_reload" env:"PICOCLAW_GATEWAY_HOT_RELOAD"`
+	HA        HAConfig `json:"ha"`
+}
+
+// HAConfig enables running several gateway replicas. Each channel is held by
+// one replica at a time through a lease in the selected lock backend.
+type HAConfig struct {
+	Enabled       bool   `json:"enabled"                  env:"PICOCLAW_GATEWAY_HA_ENABLED"`
+	Backend       string `json:"backend"                  env:"PICOCLAW_GATEWAY_HA_BACKEND"` // "kubernetes" or "redis"
+	Identity      string `json:"identity,omitempty"       env:"PICOCLAW_GATEWAY_HA_IDENTITY"`
+	LeaseSeconds  int    `json:"lease_seconds,omitempty"  env:"PICOCLAW_GATEWAY_HA_LEASE_SECONDS"`
+	LeasePrefix   string `json:"lease_prefix,omitempty"   env:"PICOCLAW_GATEWAY_HA_LEASE_PREFIX"`
+	Namespace     string `json:"namespace,omitempty"      env:"PICOCLAW_GATEWAY_HA_NAMESPACE"`
+	RedisAddr     string `json:"redis_addr,omitempty"     env:"PICOCLAW_GATEWAY_HA_REDIS_ADDR"`
+	RedisPassword string `json:"redis_password,omitempty" env:"PICOCLAW_GATEWAY_HA_REDIS_PASSWORD"`
+	RedisDB       int    `json:"redis_db,omitempty"       env:"PICOCLAW_GATEWAY_HA_REDIS_DB"`
 }
 
 type ToolDiscoveryConfig struct {
@@ -705,12 +1770,33 @@ type WebToolsConfig struct {
 	FetchLimitBytes      int64               `json:"fetch_limit_bytes,omitempty"      env:"PICOCLAW_TOOLS_WEB_FETCH_LIMIT_BYTES"`
 	Format               string              `json:"format,omitempty"                 env:"PICOCLAW_TOOLS_WEB_FORMAT"`
 	PrivateHostWhitelist FlexibleStringSlice `json:"private_host_whitelist,omitempty" env:"PICOCLAW_TOOLS_WEB_PRIVATE_HOST_WHITELIST"`
+	// Provider picks the web_search engine, such as "searxng" or "brave";
+	// empty or "auto" uses the first enabled one in priority order.
+	Provider string `json:"provider,omitempty" env:"PICOCLAW_TOOLS_WEB_PROVIDER"`
 }
 
 type CronToolsConfig struct {
 	ToolConfig         `     envPrefix:"PICOCLAW_TOOLS_CRON_"`
 	ExecTimeoutMinutes int  `                                 env:"PICOCLAW_TOOLS_CRON_EXEC_TIMEOUT_MINUTES" json:"exec_timeout_minutes"` // 0 means no timeout
 	AllowCommand       bool `                                 env:"PICOCLAW_TOOLS_CRON_ALLOW_COMMAND"        json:"allow_command"`
+
+	// Jobs are recurring prompts set by the operator; they run even when the
+	// cron tool itself is disabled.
+	Jobs []CronJobConfig `json:"jobs,omitempty"`
+}
+
+// CronJobConfig is a prompt sent to the agent on a schedule, with the reply
+// posted to ChatID. Set either Cron or EverySeconds. With Deliver, Prompt is
+// posted as written instead.
+type CronJobConfig struct {
+	Name         string `json:"name"`
+	Cron         string `json:"cron,omitempty"` // cron expression, such as "0 9 * * 1-5"
+	EverySeconds int    `json:"every_seconds,omitempty"`
+	Timezone     string `json:"timezone,omitempty"` // IANA zone of Cron; defaults to local
+	Channel      string `json:"channel"`
+	ChatID       string `json:"chat_id"`
+	Prompt       string `json:"prompt"`
+	Deliver      bool   `json:"deliver,omitempty"`
 }
 
 type ExecConfig struct {
@@ -742,29 +1828,208 @@ type ReadFileToolConfig struct {
 }
 
 type ToolsConfig struct {
-	AllowReadPaths  []string           `json:"allow_read_paths"  env:"PICOCLAW_TOOLS_ALLOW_READ_PATHS"`
-	AllowWritePaths []string           `json:"allow_write_paths" env:"PICOCLAW_TOOLS_ALLOW_WRITE_PATHS"`
-	Web             WebToolsConfig     `json:"web"`
-	Cron            CronToolsConfig    `json:"cron"`
-	Exec            ExecConfig         `json:"exec"`
-	Skills          SkillsToolsConfig  `json:"skills"`
-	MediaCleanup    MediaCleanupConfig `json:"media_cleanup"`
-	MCP             MCPConfig          `json:"mcp"`
-	AppendFile      ToolConfig         `json:"append_file"                                              envPrefix:"PICOCLAW_TOOLS_APPEND_FILE_"`
-	EditFile        ToolConfig         `json:"edit_file"                                                envPrefix:"PICOCLAW_TOOLS_EDIT_FILE_"`
-	FindSkills      ToolConfig         `json:"find_skills"                                              envPrefix:"PICOCLAW_TOOLS_FIND_SKILLS_"`
-	I2C             ToolConfig         `json:"i2c"                                                      envPrefix:"PICOCLAW_TOOLS_I2C_"`
-	InstallSkill    ToolConfig         `json:"install_skill"                                            envPrefix:"PICOCLAW_TOOLS_INSTALL_SKILL_"`
-	ListDir         ToolConfig         `json:"list_dir"                                                 envPrefix:"PICOCLAW_TOOLS_LIST_DIR_"`
-	Message         ToolConfig         `json:"message"                                                  envPrefix:"PICOCLAW_TOOLS_MESSAGE_"`
-	ReadFile        ReadFileToolConfig `json:"read_file"                                                envPrefix:"PICOCLAW_TOOLS_READ_FILE_"`
-	SendFile        ToolConfig         `json:"send_file"                                                envPrefix:"PICOCLAW_TOOLS_SEND_FILE_"`
-	Spawn           ToolConfig         `json:"spawn"                                                    envPrefix:"PICOCLAW_TOOLS_SPAWN_"`
-	SpawnStatus     ToolConfig         `json:"spawn_status"                                             envPrefix:"PICOCLAW_TOOLS_SPAWN_STATUS_"`
-	SPI             ToolConfig         `json:"spi"                                                      envPrefix:"PICOCLAW_TOOLS_SPI_"`
-	Subagent        ToolConfig         `json:"subagent"                                                 envPrefix:"PICOCLAW_TOOLS_SUBAGENT_"`
-	WebFetch        ToolConfig         `json:"web_fetch"                                                envPrefix:"PICOCLAW_TOOLS_WEB_FETCH_"`
-	WriteFile       ToolConfig         `json:"write_file"                                               envPrefix:"PICOCLAW_TOOLS_WRITE_FILE_"`
+	AllowReadPaths  []string                `json:"allow_read_paths"  env:"PICOCLAW_TOOLS_ALLOW_READ_PATHS"`
+	AllowWritePaths []string                `json:"allow_write_paths" env:"PICOCLAW_TOOLS_ALLOW_WRITE_PATHS"`
+	Web             WebToolsConfig          `json:"web"`
+	Cron            CronToolsConfig         `json:"cron"`
+	Exec            ExecConfig              `json:"exec"`
+	Skills          SkillsToolsConfig       `json:"skills"`
+	MediaCleanup    MediaCleanupConfig      `json:"media_cleanup"`
+	MCP             MCPConfig               `json:"mcp"`
+	AppendFile      ToolConfig              `json:"append_file"                                              envPrefix:"PICOCLAW_TOOLS_APPEND_FILE_"`
+	EditFile        ToolConfig              `json:"edit_file"                                                envPrefix:"PICOCLAW_TOOLS_EDIT_FILE_"`
+	FindSkills      ToolConfig              `json:"find_skills"                                              envPrefix:"PICOCLAW_TOOLS_FIND_SKILLS_"`
+	I2C             ToolConfig              `json:"i2c"                                                      envPrefix:"PICOCLAW_TOOLS_I2C_"`
+	InstallSkill    ToolConfig              `json:"install_skill"                                            envPrefix:"PICOCLAW_TOOLS_INSTALL_SKILL_"`
+	ListDir         ToolConfig              `json:"list_dir"                                                 envPrefix:"PICOCLAW_TOOLS_LIST_DIR_"`
+	Message         ToolConfig              `json:"message"                                                  envPrefix:"PICOCLAW_TOOLS_MESSAGE_"`
+	ReadFile        ReadFileToolConfig      `json:"read_file"                                                envPrefix:"PICOCLAW_TOOLS_READ_FILE_"`
+	SendFile        ToolConfig              `json:"send_file"                                                envPrefix:"PICOCLAW_TOOLS_SEND_FILE_"`
+	Spawn           ToolConfig              `json:"spawn"                                                    envPrefix:"PICOCLAW_TOOLS_SPAWN_"`
+	SpawnStatus     ToolConfig              `json:"spawn_status"                                             envPrefix:"PICOCLAW_TOOLS_SPAWN_STATUS_"`
+	SPI             ToolConfig              `json:"spi"                                                      envPrefix:"PICOCLAW_TOOLS_SPI_"`
+	Subagent        ToolConfig              `json:"subagent"                                                 envPrefix:"PICOCLAW_TOOLS_SUBAGENT_"`
+	Delegate        DelegateToolConfig      `json:"delegate"`
+	WebFetch        ToolConfig              `json:"web_fetch"                                                envPrefix:"PICOCLAW_TOOLS_WEB_FETCH_"`
+	WriteFile       ToolConfig              `json:"write_file"                                               envPrefix:"PICOCLAW_TOOLS_WRITE_FILE_"`
+	Issues          IssuesToolConfig        `json:"issues"`
+	GitHub          GitHubToolConfig        `json:"github"`
+	Calendar        CalendarToolConfig      `json:"calendar"`
+	HomeAssistant   HomeAssistantToolConfig `json:"home_assistant"`
+	Weather         WeatherToolConfig       `json:"weather"`
+	Time            TimeToolConfig          `json:"time"`
+	Currency        CurrencyToolConfig      `json:"currency"`
+	ImageGen        ImageGenToolConfig      `json:"image_gen"`
+	Sandbox         SandboxToolConfig       `json:"sandbox"`
+	Project         ProjectToolsConfig      `json:"project"`
+	Permissions     ToolPermissionsConfig   `json:"permissions"`
+
+	// TimeoutSeconds bounds every tool call; 0 means no limit. Timeouts
+	// overrides it per tool name.
+	TimeoutSeconds int            `json:"timeout_seconds,omitempty" env:"PICOCLAW_TOOLS_TIMEOUT_SECONDS"`
+	Timeouts       map[string]int `json:"timeouts,omitempty"`
+}
+
+// WeatherToolConfig configures the weather tool, which uses Open-Meteo and
+// needs no API key.
+type WeatherToolConfig struct {
+	ToolConfig `         envPrefix:"PICOCLAW_TOOLS_WEATHER_"`
+	Units      string   `json:"units"              env:"PICOCLAW_TOOLS_WEATHER_UNITS"`    // "metric" or "imperial"
+	Channels   []string `json:"channels,omitempty" env:"PICOCLAW_TOOLS_WEATHER_CHANNELS"` // channels offering the tool; empty allows all
+}
+
+// TimeToolConfig configures the time tool for current times and time zone
+// conversion.
+type TimeToolConfig struct {
+	ToolConfig `         envPrefix:"PICOCLAW_TOOLS_TIME_"`
+	Timezone   string   `json:"timezone,omitempty" env:"PICOCLAW_TOOLS_TIME_TIMEZONE"` // IANA zone used when none is given; defaults to local
+	Channels   []string `json:"channels,omitempty" env:"PICOCLAW_TOOLS_TIME_CHANNELS"`
+}
+
+// CurrencyToolConfig configures the currency tool. Without an API key it uses
+// the free ECB reference rates from Frankfurter; with one it uses Open
+// Exchange Rates.
+type CurrencyToolConfig struct {
+	ToolConfig `         envPrefix:"PICOCLAW_TOOLS_CURRENCY_"`
+	APIKey     string   `json:"api_key,omitempty"  env:"PICOCLAW_TOOLS_CURRENCY_API_KEY"` // Open Exchange Rates app ID
+	BaseURL    string   `json:"base_url,omitempty" env:"PICOCLAW_TOOLS_CURRENCY_BASE_URL"`
+	Channels   []string `json:"channels,omitempty" env:"PICOCLAW_TOOLS_CURRENCY_CHANNELS"`
+}
+
+// ImageGenToolConfig configures the generate_image tool. Provider is
+// "openai" (DALL·E, gpt-image, or any OpenAI-compatible images endpoint),
+// "stability" (Stability AI), or "sd" (a local Stable Diffusion WebUI).
+// Prompts are refused when they contain BlockedTerms or fail the
+// guardrails, and with the openai provider OpenAI's moderation endpoint.
+type ImageGenToolConfig struct {
+	ToolConfig   `         envPrefix:"PICOCLAW_TOOLS_IMAGE_GEN_"`
+	Provider     string   `json:"provider"                env:"PICOCLAW_TOOLS_IMAGE_GEN_PROVIDER"`
+	APIKey       string   `json:"api_key,omitempty"       env:"PICOCLAW_TOOLS_IMAGE_GEN_API_KEY"`
+	APIBase      string   `json:"api_base,omitempty"      env:"PICOCLAW_TOOLS_IMAGE_GEN_API_BASE"`
+	Model        string   `json:"model,omitempty"         env:"PICOCLAW_TOOLS_IMAGE_GEN_MODEL"`
+	Size         string   `json:"size,omitempty"          env:"PICOCLAW_TOOLS_IMAGE_GEN_SIZE"` // WIDTHxHEIGHT
+	BlockedTerms []string `json:"blocked_terms,omitempty" env:"PICOCLAW_TOOLS_IMAGE_GEN_BLOCKED_TERMS"`
+	Channels     []string `json:"channels,omitempty"      env:"PICOCLAW_TOOLS_IMAGE_GEN_CHANNELS"`
+}
+
+// SandboxToolConfig configures the sandbox tool, which runs shell commands
+// under resource limits, either as a subprocess with rlimits or in a
+// throwaway Docker container. Only the users in AllowFrom can trigger it.
+type SandboxToolConfig struct {
+	ToolConfig     `         envPrefix:"PICOCLAW_TOOLS_SANDBOX_"`
+	Mode           string   `json:"mode"             env:"PICOCLAW_TOOLS_SANDBOX_MODE"`       // "rlimit" or "docker"
+	AllowFrom      []string `json:"allow_from"       env:"PICOCLAW_TOOLS_SANDBOX_ALLOW_FROM"` // "channel:id" or an ID
+	TimeoutSeconds int      `json:"timeout_seconds"  env:"PICOCLAW_TOOLS_SANDBOX_TIMEOUT_SECONDS"`
+	MaxOutputBytes int      `json:"max_output_bytes" env:"PICOCLAW_TOOLS_SANDBOX_MAX_OUTPUT_BYTES"` // per stream
+	CPUSeconds     int      `json:"cpu_seconds"      env:"PICOCLAW_TOOLS_SANDBOX_CPU_SECONDS"`
+	MemoryMB       int      `json:"memory_mb"        env:"PICOCLAW_TOOLS_SANDBOX_MEMORY_MB"`
+	MaxProcesses   int      `json:"max_processes"    env:"PICOCLAW_TOOLS_SANDBOX_MAX_PROCESSES"`
+	MaxFileMB      int      `json:"max_file_mb"      env:"PICOCLAW_TOOLS_SANDBOX_MAX_FILE_MB"`
+	Image          string   `json:"image"            env:"PICOCLAW_TOOLS_SANDBOX_IMAGE"`   // docker mode
+	Network        bool     `json:"network"          env:"PICOCLAW_TOOLS_SANDBOX_NETWORK"` // docker mode
+}
+
+// DelegateToolConfig configures the delegate tool, which hands sub-tasks to
+// sub-agents running in parallel.
+type DelegateToolConfig struct {
+	ToolConfig     `    envPrefix:"PICOCLAW_TOOLS_DELEGATE_"`
+	MaxDepth       int `json:"max_depth"       env:"PICOCLAW_TOOLS_DELEGATE_MAX_DEPTH"`       // 1: no nesting
+	MaxConcurrency int `json:"max_concurrency" env:"PICOCLAW_TOOLS_DELEGATE_MAX_CONCURRENCY"` // per call
+	MaxTasks       int `json:"max_tasks"       env:"PICOCLAW_TOOLS_DELEGATE_MAX_TASKS"`
+	MaxIterations  int `json:"max_iterations"  env:"PICOCLAW_TOOLS_DELEGATE_MAX_ITERATIONS"` // per sub-agent
+}
+
+// ProjectToolsConfig configures the project_ file tools, which read, list,
+// write, and patch files of a project checkout outside the workspace.
+type ProjectToolsConfig struct {
+	ToolConfig `       envPrefix:"PICOCLAW_TOOLS_PROJECT_"`
+	Root       string `json:"root"      env:"PICOCLAW_TOOLS_PROJECT_ROOT"`
+	ReadOnly   bool   `json:"read_only" env:"PICOCLAW_TOOLS_PROJECT_READ_ONLY"` // only project_read and project_list
+}
+
+// ToolPermissionsConfig decides per user, role, and channel whether a tool
+// call runs ("allow"), waits for the user to approve it ("ask"), or is
+// refused ("deny"). The first matching rule wins; calls no rule matches get
+// Default, which is "allow" when empty.
+type ToolPermissionsConfig struct {
+	Default string               `json:"default,omitempty" env:"PICOCLAW_TOOLS_PERMISSIONS_DEFAULT"`
+	Roles   map[string][]string  `json:"roles,omitempty"` // role name → sender IDs or "channel:id"
+	Rules   []ToolPermissionRule `json:"rules,omitempty"`
+}
+
+// ToolPermissionRule applies Action to calls of Tools. Users, Roles, and
+// Channels narrow who it applies to; a rule without any applies to everyone.
+type ToolPermissionRule struct {
+	Tools    []string `json:"tools"`              // tool names, "prefix*", or "*"
+	Users    []string `json:"users,omitempty"`    // sender IDs or "channel:id"
+	Roles    []string `json:"roles,omitempty"`    // names from ToolPermissionsConfig.Roles
+	Channels []string `json:"channels,omitempty"` // channel, "channel:chat_id", or "channel:guild_id"
+	Action   string   `json:"action"`             // "allow", "ask", or "deny"
+}
+
+// HomeAssistantToolConfig configures the home_assistant tool. Service calls
+// change real devices, so they wait for /approve unless RequireApproval is off.
+type HomeAssistantToolConfig struct {
+	ToolConfig      `         envPrefix:"PICOCLAW_TOOLS_HOME_ASSISTANT_"`
+	URL             string   `json:"url"                       env:"PICOCLAW_TOOLS_HOME_ASSISTANT_URL"`
+	Token           string   `json:"token"                     env:"PICOCLAW_TOOLS_HOME_ASSISTANT_TOKEN"` // long-lived access token
+	RequireApproval bool     `json:"require_approval"          env:"PICOCLAW_TOOLS_HOME_ASSISTANT_REQUIRE_APPROVAL"`
+	AllowedDomains  []string `json:"allowed_domains,omitempty" env:"PICOCLAW_TOOLS_HOME_ASSISTANT_ALLOWED_DOMAINS"` // domains that may be controlled; empty allows all
+}
+
+// CalendarToolConfig configures the calendar tool. With the google provider
+// every user links their own account through OAuth; caldav uses one shared
+// account.
+type CalendarToolConfig struct {
+	ToolConfig `                     envPrefix:"PICOCLAW_TOOLS_CALENDAR_"`
+	Provider   string               `json:"provider"           env:"PICOCLAW_TOOLS_CALENDAR_PROVIDER"` // "google" or "caldav"
+	Timezone   string               `json:"timezone,omitempty" env:"PICOCLAW_TOOLS_CALENDAR_TIMEZONE"` // IANA zone for times without an offset
+	Google     GoogleCalendarConfig `json:"google"`
+	CalDAV     CalDAVConfig         `json:"caldav"`
+}
+
+type GoogleCalendarConfig struct {
+	ClientID     string `json:"client_id"              env:"PICOCLAW_TOOLS_CALENDAR_GOOGLE_CLIENT_ID"`
+	ClientSecret string `json:"client_secret"          env:"PICOCLAW_TOOLS_CALENDAR_GOOGLE_CLIENT_SECRET"`
+	RedirectURL  string `json:"redirect_url"           env:"PICOCLAW_TOOLS_CALENDAR_GOOGLE_REDIRECT_URL"` // public URL of the gateway callback
+	CalendarID   string `json:"calendar_id,omitempty"  env:"PICOCLAW_TOOLS_CALENDAR_GOOGLE_CALENDAR_ID"`
+}
+
+type CalDAVConfig struct {
+	URL      string `json:"url"      env:"PICOCLAW_TOOLS_CALENDAR_CALDAV_URL"` // calendar collection URL
+	Username string `json:"username" env:"PICOCLAW_TOOLS_CALENDAR_CALDAV_USERNAME"`
+	Password string `json:"password" env:"PICOCLAW_TOOLS_CALENDAR_CALDAV_PASSWORD"`
+}
+
+// IssueTrackerConfig holds the credentials for one Jira or Linear account.
+type IssueTrackerConfig struct {
+	Provider string `json:"provider"            env:"PROVIDER"`  // "jira" or "linear"
+	BaseURL  string `json:"base_url,omitempty"  env:"BASE_URL"`  // Jira site URL; optional Linear API URL override
+	Email    string `json:"email,omitempty"     env:"EMAIL"`     // Jira account email
+	APIToken string `json:"api_token,omitempty" env:"API_TOKEN"` // Jira API token or Linear API key
+	Project  string `json:"project,omitempty"   env:"PROJECT"`   // default Jira project key or Linear team key
+}
+
+// IssuesToolConfig configures the issues tool. Guilds maps a Discord guild or
+// Slack team ID to its own tracker; other chats use the top-level account.
+type IssuesToolConfig struct {
+	ToolConfig         `                                  envPrefix:"PICOCLAW_TOOLS_ISSUES_"`
+	IssueTrackerConfig `                                  envPrefix:"PICOCLAW_TOOLS_ISSUES_"`
+	Guilds             map[string]IssueTrackerConfig `json:"guilds,omitempty"`
+}
+
+// GitHubToolConfig configures the github tool. It authenticates with Token, a
+// personal access token, or else as the installation of a GitHub App, and
+// works only on the "owner/name" repositories in Repos, the first being the
+// default.
+type GitHubToolConfig struct {
+	ToolConfig     `         envPrefix:"PICOCLAW_TOOLS_GITHUB_"`
+	Token          string   `json:"token,omitempty"            env:"PICOCLAW_TOOLS_GITHUB_TOKEN"`
+	AppID          int64    `json:"app_id,omitempty"           env:"PICOCLAW_TOOLS_GITHUB_APP_ID"`
+	InstallationID int64    `json:"installation_id,omitempty"  env:"PICOCLAW_TOOLS_GITHUB_INSTALLATION_ID"`
+	PrivateKeyPath string   `json:"private_key_path,omitempty" env:"PICOCLAW_TOOLS_GITHUB_PRIVATE_KEY_PATH"`
+	BaseURL        string   `json:"base_url,omitempty"         env:"PICOCLAW_TOOLS_GITHUB_BASE_URL"`
+	Repos          []string `json:"repos"                      env:"PICOCLAW_TOOLS_GITHUB_REPOS"`
 }
 
 type SearchCacheConfig struct {
@@ -880,6 +2145,9 @@ func LoadConfig(path string) (*Config, error) {
 	if err := cfg.ValidateModelList(); err != nil {
 		return nil, err
 	}
+	if _, err := cfg.Redaction.SecretPatterns(); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
@@ -1041,6 +2309,12 @@ func (c *Config) GetModelConfig(modelName string) (*ModelConfig, error) {
 	return &matches[idx], nil
 }
 
+// GetModelConfigs returns every ModelConfig with the given model_name, in
+// model_list order.
+func (c *Config) GetModelConfigs(modelName string) []ModelConfig {
+	return c.findMatches(modelName)
+}
+
 // findMatches finds all ModelConfig entries with the given model_name.
 func (c *Config) findMatches(modelName string) []ModelConfig {
 	var matches []ModelConfig
@@ -1128,6 +2402,8 @@ func (t *ToolsConfig) IsToolEnabled(name string) bool {
 		return t.SPI.Enabled
 	case "subagent":
 		return t.Subagent.Enabled
+	case "delegate":
+		return t.Delegate.Enabled
 	case "web_fetch":
 		return t.WebFetch.Enabled
 	case "send_file":
@@ -1136,6 +2412,26 @@ func (t *ToolsConfig) IsToolEnabled(name string) bool {
 		return t.WriteFile.Enabled
 	case "mcp":
 		return t.MCP.Enabled
+	case "issues":
+		return t.Issues.Enabled
+	case "github":
+		return t.GitHub.Enabled
+	case "calendar":
+		return t.Calendar.Enabled
+	case "home_assistant":
+		return t.HomeAssistant.Enabled
+	case "weather":
+		return t.Weather.Enabled
+	case "time":
+		return t.Time.Enabled
+	case "currency":
+		return t.Currency.Enabled
+	case "generate_image":
+		return t.ImageGen.Enabled
+	case "sandbox":
+		return t.Sandbox.Enabled
+	case "project":
+		return t.Project.Enabled
 	default:
 		return true
 	}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 
@@ -486,6 +487,31 @@ func TestLoadConfig_ExecAllowRemoteDefaultsTrueWhenUnset(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_RedactionPatterns(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"redaction":{"patterns":["ACME-[0-9]{6}"]}}`), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	if !cfg.Redaction.Outbound {
+		t.Error("redaction.outbound should remain true when unset in config file")
+	}
+	if r := cfg.LogRedaction(); !r.Secrets || len(r.Patterns) != 1 {
+		t.Errorf("LogRedaction() = %+v", r)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"redaction":{"patterns":["ACME-[0-9"]}}`), 0o600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "redaction.patterns") {
+		t.Errorf("LoadConfig() with a bad pattern: err = %v", err)
+	}
+}
+
 func TestLoadConfig_CronAllowCommandDefaultsTrueWhenUnset(t *testing.T) {
 	dir := t.TempDir()
 	configPath := filepath.Join(dir, "config.json")
@@ -1041,3 +1067,20 @@ func TestLoadConfig_UsesPassphraseProvider(t *testing.T) {
 		t.Errorf("api_key = %q, want %q", cfg.ModelList[0].APIKey, plainKey)
 	}
 }
+
+func TestConfig_APITokens(t *testing.T) {
+	cfg := &Config{}
+	if got := cfg.APITokens(""); len(got) != 0 {
+		t.Errorf("APITokens() without tokens = %q", got)
+	}
+	if got := cfg.APITokens("usage"); !slices.Equal(got, []string{"usage"}) {
+		t.Errorf("APITokens() = %q", got)
+	}
+	cfg.Admin.APIToken = "admin"
+	if got := cfg.APITokens(""); !slices.Equal(got, []string{"admin"}) {
+		t.Errorf("APITokens() with only the admin token = %q", got)
+	}
+	if got := cfg.APITokens("usage"); !slices.Equal(got, []string{"usage", "admin"}) {
+		t.Errorf("APITokens() = %q", got)
+	}
+}
@@ -114,6 +114,14 @@ func DefaultConfig() *Config {
 					Text:    "Thinking... 💭",
 				},
 			},
+			WhatsAppCloud: WhatsAppCloudConfig{
+				Enabled:     false,
+				WebhookPath: "/webhook/whatsapp",
+				AllowFrom:   FlexibleStringSlice{},
+				ReengageTemplate: WhatsAppTemplate{
+					Language: "en_US",
+				},
+			},
 			LINE: LINEConfig{
 				Enabled:            false,
 				ChannelSecret:      "",
@@ -534,17 +542,222 @@ func DefaultConfig() *Config {
 			Subagent: ToolConfig{
 				Enabled: true,
 			},
+			Delegate: DelegateToolConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false,
+				},
+				MaxDepth:       1,
+				MaxConcurrency: 3,
+				MaxTasks:       8,
+				MaxIterations:  10,
+			},
 			WebFetch: ToolConfig{
 				Enabled: true,
 			},
 			WriteFile: ToolConfig{
 				Enabled: true,
 			},
+			Issues: IssuesToolConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false, // needs tracker credentials
+				},
+			},
+			GitHub: GitHubToolConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false, // needs a token or a GitHub App
+				},
+			},
+			Calendar: CalendarToolConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false, // needs a calendar account
+				},
+				Provider: "google",
+				Google: GoogleCalendarConfig{
+					CalendarID: "primary",
+				},
+			},
+			HomeAssistant: HomeAssistantToolConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false, // needs a Home Assistant URL and token
+				},
+				RequireApproval: true,
+			},
+			Weather: WeatherToolConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false,
+				},
+				Units: "metric",
+			},
+			Time: TimeToolConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false,
+				},
+			},
+			Currency: CurrencyToolConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false,
+				},
+			},
+			ImageGen: ImageGenToolConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false, // needs an image provider
+				},
+				Provider: "openai",
+				Size:     "1024x1024",
+			},
+			Sandbox: SandboxToolConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false,
+				},
+				Mode:           "rlimit",
+				TimeoutSeconds: 30,
+				MaxOutputBytes: 16 * 1024,
+				CPUSeconds:     10,
+				MemoryMB:       512,
+				MaxProcesses:   128,
+				MaxFileMB:      16,
+				Image:          "alpine:3",
+			},
+			Project: ProjectToolsConfig{
+				ToolConfig: ToolConfig{
+					Enabled: false,
+				},
+			},
 		},
 		Heartbeat: HeartbeatConfig{
 			Enabled:  true,
 			Interval: 30,
 		},
+		Digest: DigestConfig{
+			Enabled:  false,
+			Schedule: "daily",
+			Hour:     9,
+			Weekday:  "monday",
+			TopUsers: 5,
+			Email: DigestEmailConfig{
+				SMTPPort: 587,
+			},
+			Flagged: FlaggedDigestConfig{
+				MaxItems: 50,
+			},
+		},
+		Logging: LoggingConfig{
+			RedactSecrets: true,
+		},
+		Redaction: RedactionConfig{
+			Outbound: true,
+		},
+		Attachments: AttachmentsConfig{
+			Enabled:    true,
+			MaxBytes:   10 * 1024 * 1024,
+			MaxPages:   50,
+			ChunkChars: 4000,
+			MaxChunks:  10,
+		},
+		CodeFiles: CodeFilesConfig{
+			Enabled:  true,
+			MinLines: 40,
+		},
+		Dispatch: DispatchConfig{
+			Workers:             4,
+			QueueSize:           10,
+			MaxPending:          100,
+			DrainTimeoutSeconds: 30,
+		},
+		ResponseCache: ResponseCacheConfig{
+			Enabled:    false,
+			TTLMinutes: 60,
+			MaxEntries: 500,
+			Persist:    true,
+		},
+		ProviderCache: ProviderCacheConfig{
+			Enabled:  true,
+			TTLHours: 24,
+		},
+		Alerts: AlertsConfig{
+			Enabled:         false,
+			CooldownMinutes: 30,
+			ErrorRate: ErrorRateAlert{
+				Threshold:     0.5,
+				WindowMinutes: 10,
+				MinRequests:   10,
+			},
+			OutageFailures: 5,
+		},
+		Maintenance: MaintenanceConfig{
+			Enabled:    false,
+			Message:    "PicoClaw is down for maintenance. Please try again later.",
+			Mode:       "reject",
+			QueueLimit: 100,
+		},
+		Feeds: FeedsConfig{
+			Enabled:         false,
+			IntervalMinutes: 30,
+			MaxItems:        5,
+			Digest: FeedDigestConfig{
+				Enabled:    false,
+				Schedule:   "daily",
+				Hour:       9,
+				MaxEntries: 30,
+			},
+		},
+		Recaps: RecapsConfig{
+			Enabled:     false,
+			Hour:        18,
+			MaxMessages: 500,
+		},
+		Generation: GenerationConfig{
+			Temperature: FloatRange{Min: 0, Max: 1.5},
+			MaxTokens:   IntRange{Min: 256, Max: 8192},
+		},
+		OfflineQueue: OfflineQueueConfig{
+			Enabled:      false,
+			QueueLimit:   100,
+			RetrySeconds: 60,
+			MaxAgeHours:  24,
+		},
+		Analytics: AnalyticsConfig{
+			Enabled: false,
+		},
+		Ephemeral: EphemeralConfig{
+			Enabled:     false,
+			IdleMinutes: 30,
+		},
+		Guardrails: GuardrailsConfig{
+			Enabled:  false,
+			BudgetMS: 1500,
+			Message:  "Sorry, I can't help with that.",
+		},
+		Knowledge: KnowledgeConfig{
+			Enabled:         false,
+			IntervalMinutes: 60,
+		},
+		ObjectStorage: ObjectStorageConfig{
+			Enabled:        false,
+			Provider:       "s3",
+			Prefix:         "picoclaw/",
+			LinkTTLMinutes: 1440,
+			MaxObjectSize:  2 << 30,
+		},
+		GitHubWebhook: GitHubWebhookConfig{
+			Enabled: false,
+			Path:    "/webhooks/github",
+		},
+		EventWebhooks: EventWebhooksConfig{
+			Enabled: false,
+			Path:    "/webhooks/events",
+		},
+		Facts: FactsConfig{
+			MaxTokens: 500,
+		},
+		Localization: LocalizationConfig{
+			DetectLocale: true,
+		},
+		Transcripts: TranscriptsConfig{
+			Enabled:        false,
+			RetentionDays:  30,
+			MaxResultChars: 8000,
+		},
 		Devices: DevicesConfig{
 			Enabled:    false,
 			MonitorUSB: true,
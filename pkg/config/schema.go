@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// SchemaURL identifies the JSON Schema draft used by Schema.
+const SchemaURL = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	flexibleStringSliceType = reflect.TypeFor[FlexibleStringSlice]()
+	agentModelConfigType    = reflect.TypeFor[AgentModelConfig]()
+)
+
+// Schema returns a JSON Schema describing the config file, generated from
+// the Config structs. Defaults are taken from DefaultConfig so editors can
+// show them while autocompleting.
+func Schema() ([]byte, error) {
+	root := schemaForType(reflect.TypeFor[Config](), reflect.ValueOf(*DefaultConfig()))
+	root["$schema"] = SchemaURL
+	root["title"] = "PicoClaw configuration"
+	return json.MarshalIndent(root, "", "  ")
+}
+
+// schemaForType builds the schema for t. def holds the default value, or is
+// invalid when there is none.
+func schemaForType(t reflect.Type, def reflect.Value) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		if def.IsValid() {
+			if def.IsNil() {
+				def = reflect.Value{}
+			} else {
+				def = def.Elem()
+			}
+		}
+	}
+
+	// Types with custom JSON decoding accept more than their Go shape.
+	switch t {
+	case flexibleStringSliceType:
+		s := map[string]any{
+			"type":  "array",
+			"items": map[string]any{"type": []string{"string", "number"}},
+		}
+		addDefault(s, def)
+		return s
+	case agentModelConfigType:
+		return map[string]any{
+			"oneOf": []any{
+				map[string]any{"type": "string", "description": "Primary model name"},
+				schemaForStruct(t, reflect.Value{}),
+			},
+		}
+	}
+
+	var s map[string]any
+	switch t.Kind() {
+	case reflect.Struct:
+		return schemaForStruct(t, def)
+	case reflect.String:
+		s = map[string]any{"type": "string"}
+	case reflect.Bool:
+		s = map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		s = map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		s = map[string]any{"type": "array", "items": schemaForType(t.Elem(), reflect.Value{})}
+	case reflect.Map:
+		s = map[string]any{"type": "object", "additionalProperties": schemaForType(t.Elem(), reflect.Value{})}
+	default:
+		// interface values and anything else accept any JSON.
+		return map[string]any{}
+	}
+	addDefault(s, def)
+	return s
+}
+
+func schemaForStruct(t reflect.Type, def reflect.Value) map[string]any {
+	props := make(map[string]any)
+	collectProperties(t, def, props)
+	return map[string]any{"type": "object", "properties": props}
+}
+
+// collectProperties adds the JSON fields of t to props, flattening embedded
+// structs the way encoding/json does.
+func collectProperties(t reflect.Type, def reflect.Value, props map[string]any) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		var fieldDef reflect.Value
+		if def.IsValid() {
+			fieldDef = def.Field(i)
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+				fieldDef = reflect.Value{}
+			}
+			if ft.Kind() == reflect.Struct {
+				collectProperties(ft, fieldDef, props)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaForType(f.Type, fieldDef)
+	}
+}
+
+// addDefault records non-zero scalar defaults. Zero values and structured
+// defaults such as the sample model list add noise, not information.
+func addDefault(s map[string]any, def reflect.Value) {
+	if !def.IsValid() || def.IsZero() {
+		return
+	}
+	switch def.Kind() {
+	case reflect.Slice:
+		if def.Len() == 0 || def.Type().Elem().Kind() != reflect.String {
+			return
+		}
+	case reflect.Map, reflect.Struct, reflect.Interface:
+		return
+	}
+	s["default"] = def.Interface()
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func loadSchema(t *testing.T) map[string]any {
+	t.Helper()
+	data, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema: %v", err)
+	}
+	var s map[string]any
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	return s
+}
+
+// lookup walks properties by JSON field name.
+func lookup(t *testing.T, s map[string]any, path ...string) map[string]any {
+	t.Helper()
+	for _, p := range path {
+		props, ok := s["properties"].(map[string]any)
+		if !ok {
+			t.Fatalf("no properties at %q", p)
+		}
+		next, ok := props[p].(map[string]any)
+		if !ok {
+			t.Fatalf("missing property %q", p)
+		}
+		s = next
+	}
+	return s
+}
+
+func TestSchema_Structure(t *testing.T) {
+	s := loadSchema(t)
+	if s["$schema"] != SchemaURL {
+		t.Errorf("$schema = %v", s["$schema"])
+	}
+
+	if got := lookup(t, s, "gateway", "port")["type"]; got != "integer" {
+		t.Errorf("gateway.port type = %v", got)
+	}
+	if got := lookup(t, s, "gateway", "port")["default"]; got != float64(18790) {
+		t.Errorf("gateway.port default = %v", got)
+	}
+	if got := lookup(t, s, "channels", "telegram", "enabled")["type"]; got != "boolean" {
+		t.Errorf("telegram.enabled type = %v", got)
+	}
+	if got := lookup(t, s, "model_list")["type"]; got != "array" {
+		t.Errorf("model_list type = %v", got)
+	}
+}
+
+func TestSchema_EmbeddedAndCustomTypes(t *testing.T) {
+	s := loadSchema(t)
+
+	// ProviderConfig is embedded in OpenAIProviderConfig and must be flattened.
+	openai := lookup(t, s, "providers", "openai")
+	props := openai["properties"].(map[string]any)
+	for _, field := range []string{"api_key", "web_search"} {
+		if _, ok := props[field]; !ok {
+			t.Errorf("providers.openai missing %q", field)
+		}
+	}
+
+	allow := lookup(t, s, "channels", "telegram", "allow_from")
+	items := allow["items"].(map[string]any)
+	if types, ok := items["type"].([]any); !ok || len(types) != 2 {
+		t.Errorf("allow_from items should accept strings and numbers, got %v", items["type"])
+	}
+
+	model := lookup(t, s, "agents", "list")["items"].(map[string]any)
+	modelProp := model["properties"].(map[string]any)["model"].(map[string]any)
+	if _, ok := modelProp["oneOf"]; !ok {
+		t.Errorf("agent model should accept a string or an object, got %v", modelProp)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,6 +17,15 @@ import (
 	"github.com/sipeed/picoclaw/pkg/fileutil"
 )
 
+// ConfiguredJobPrefix starts the IDs of the jobs that come from the config
+// file rather than from the cron tool.
+const ConfiguredJobPrefix = "config-"
+
+// Path returns where the jobs of a workspace are stored.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "cron", "jobs.json")
+}
+
 type CronSchedule struct {
 	Kind    string `json:"kind"`
 	AtMS    *int64 `json:"atMs,omitempty"`
@@ -49,6 +60,8 @@ type CronJob struct {
 	CreatedAtMS    int64        `json:"createdAtMs"`
 	UpdatedAtMS    int64        `json:"updatedAtMs"`
 	DeleteAfterRun bool         `json:"deleteAfterRun"`
+	// CreatedBy is the sender, on Payload.Channel, who added the job.
+	CreatedBy string `json:"createdBy,omitempty"`
 }
 
 type CronStore struct {
@@ -64,6 +77,7 @@ type CronService struct {
 	onJob     JobHandler
 	mu        sync.RWMutex
 	running   bool
+	paused    bool
 	stopChan  chan struct{}
 	wakeChan  chan struct{}
 	gronx     *gronx.Gronx
@@ -123,6 +137,17 @@ func (cs *CronService) Stop() {
 	}
 }
 
+// SetPaused stops due jobs from running without stopping the service. Jobs
+// that come due while paused run once when it is resumed.
+func (cs *CronService) SetPaused(paused bool) {
+	cs.mu.Lock()
+	cs.paused = paused
+	cs.mu.Unlock()
+	if !paused {
+		cs.notify()
+	}
+}
+
 func (cs *CronService) runLoop(stopChan chan struct{}) {
 	timer := time.NewTimer(time.Hour)
 	if !timer.Stop() {
@@ -134,13 +159,14 @@ func (cs *CronService) runLoop(stopChan chan struct{}) {
 		// every loop, recalculate the next wake time
 		cs.mu.RLock()
 		nextWake := cs.getNextWakeMS()
+		paused := cs.paused
 		cs.mu.RUnlock()
 
 		var delay time.Duration
 		now := time.Now().UnixMilli()
 
-		if nextWake == nil {
-			// no jobs, sleep for a long time (or until a new job is added)
+		if nextWake == nil || paused {
+			// no jobs (or paused), sleep for a long time (or until woken)
 			delay = time.Hour
 		} else {
 			diff := *nextWake - now
@@ -173,7 +199,7 @@ func (cs *CronService) runLoop(stopChan chan struct{}) {
 func (cs *CronService) checkJobs() {
 	cs.mu.Lock()
 
-	if !cs.running {
+	if !cs.running || cs.paused {
 		cs.mu.Unlock()
 		return
 	}
@@ -319,8 +345,16 @@ func (cs *CronService) computeNextRun(schedule *CronSchedule, nowMS int64) *int6
 			return nil
 		}
 
-		// Use gronx to calculate next run time
+		// Use gronx to calculate next run time, in the job's time zone if set
 		now := time.UnixMilli(nowMS)
+		if schedule.TZ != "" {
+			loc, err := time.LoadLocation(schedule.TZ)
+			if err != nil {
+				log.Printf("[cron] unknown time zone '%s': %v", schedule.TZ, err)
+				return nil
+			}
+			now = now.In(loc)
+		}
 		nextTime, err := gronx.NextTickAfter(schedule.Expr, now, false)
 		if err != nil {
 			log.Printf("[cron] failed to compute next run for expr '%s': %v", schedule.Expr, err)
@@ -344,12 +378,21 @@ func (cs *CronService) notify() {
 	}
 }
 
+// recomputeNextRuns schedules every enabled job from now on. One-time jobs
+// that came due while the service was stopped run right away, so reminders
+// are late rather than lost across a restart.
 func (cs *CronService) recomputeNextRuns() {
 	now := time.Now().UnixMilli()
 	for i := range cs.store.Jobs {
 		job := &cs.store.Jobs[i]
-		if job.Enabled {
-			job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
+		if !job.Enabled {
+			continue
+		}
+		job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
+		if job.Schedule.Kind == "at" && job.State.NextRunAtMS == nil && job.Schedule.AtMS != nil &&
+			job.State.LastRunAtMS == nil {
+			due := now
+			job.State.NextRunAtMS = &due
 		}
 	}
 }
@@ -496,6 +539,30 @@ func (cs *CronService) removeJobUnsafe(jobID string) bool {
 	return removed
 }
 
+// RemoveJobsBy removes the jobs that senderID added on channel, and the
+// reminders delivered to their direct chat, and returns how many went.
+// Jobs from the config file are kept.
+func (cs *CronService) RemoveJobsBy(channel, senderID string) (int, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	var jobs []CronJob
+	for _, job := range cs.store.Jobs {
+		owned := job.CreatedBy == senderID || (job.CreatedBy == "" && job.Payload.To == senderID)
+		if job.Payload.Channel == channel && owned && !strings.HasPrefix(job.ID, ConfiguredJobPrefix) {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	removed := len(cs.store.Jobs) - len(jobs)
+	if removed == 0 {
+		return 0, nil
+	}
+	cs.store.Jobs = jobs
+	cs.notify()
+	return removed, cs.saveStoreUnsafe()
+}
+
 func (cs *CronService) EnableJob(jobID string, enabled bool) *CronJob {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
@@ -525,6 +592,56 @@ func (cs *CronService) EnableJob(jobID string, enabled bool) *CronJob {
 	return nil
 }
 
+// SyncConfiguredJobs makes the jobs from the config file match jobs, whose
+// IDs must start with ConfiguredJobPrefix. New jobs are added, changed ones
+// updated, and configured jobs no longer in jobs removed. A job whose
+// schedule is unchanged keeps its next run, so a restart does not move it.
+func (cs *CronService) SyncConfiguredJobs(jobs []CronJob) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	existing := make(map[string]CronJob)
+	kept := cs.store.Jobs[:0:0]
+	for _, job := range cs.store.Jobs {
+		if strings.HasPrefix(job.ID, ConfiguredJobPrefix) {
+			existing[job.ID] = job
+		} else {
+			kept = append(kept, job)
+		}
+	}
+	for _, job := range jobs {
+		if !strings.HasPrefix(job.ID, ConfiguredJobPrefix) {
+			return fmt.Errorf("configured job ID %q does not start with %q", job.ID, ConfiguredJobPrefix)
+		}
+		job.Enabled = true
+		job.UpdatedAtMS = now
+		prev, ok := existing[job.ID]
+		if ok && sameSchedule(prev.Schedule, job.Schedule) {
+			job.State = prev.State
+			job.CreatedAtMS = prev.CreatedAtMS
+		} else {
+			job.State = CronJobState{NextRunAtMS: cs.computeNextRun(&job.Schedule, now)}
+			job.CreatedAtMS = now
+		}
+		if job.State.NextRunAtMS == nil {
+			job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
+		}
+		kept = append(kept, job)
+	}
+	cs.store.Jobs = kept
+	if err := cs.saveStoreUnsafe(); err != nil {
+		return err
+	}
+	cs.notify()
+	return nil
+}
+
+func sameSchedule(a, b CronSchedule) bool {
+	sameMS := func(x, y *int64) bool { return (x == nil && y == nil) || (x != nil && y != nil && *x == *y) }
+	return a.Kind == b.Kind && a.Expr == b.Expr && a.TZ == b.TZ && sameMS(a.AtMS, b.AtMS) && sameMS(a.EveryMS, b.EveryMS)
+}
+
 func (cs *CronService) ListJobs(includeDisabled bool) []CronJob {
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
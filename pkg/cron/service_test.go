@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -160,6 +161,38 @@ func TestCronService_ExecutionFlow(t *testing.T) {
 	}
 }
 
+func TestCronService_PausedJobsRunOnResume(t *testing.T) {
+	var executed atomic.Int32
+	cs, path := setupService(func(job *CronJob) (string, error) {
+		executed.Add(1)
+		return "ok", nil
+	})
+	defer os.Remove(path)
+
+	if err := cs.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer cs.Stop()
+
+	cs.SetPaused(true)
+	target := time.Now().Add(50 * time.Millisecond).UnixMilli()
+	cs.AddJob("PausedJob", CronSchedule{Kind: "at", AtMS: &target}, "", false, "", "")
+
+	time.Sleep(300 * time.Millisecond)
+	if executed.Load() != 0 {
+		t.Fatal("job ran while the service was paused")
+	}
+
+	cs.SetPaused(false)
+	for range 20 {
+		if executed.Load() == 1 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Error("job did not run after resume")
+}
+
 func TestCronService_PersistenceIntegrity(t *testing.T) {
 	tmpFile := "persist_test.json"
 	defer os.Remove(tmpFile)
@@ -235,3 +268,157 @@ func TestCronService_ConcurrentAccess(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestCronService_ComputeNextRunInTimeZone(t *testing.T) {
+	cs, path := setupService(nil)
+	defer os.Remove(path)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC).UnixMilli()
+	got := cs.computeNextRun(&CronSchedule{Kind: "cron", Expr: "0 9 * * *", TZ: "Asia/Tokyo"}, now)
+	if got == nil {
+		t.Fatal("no next run")
+	}
+	// 09:00 in Tokyo is 00:00 UTC.
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC); !time.UnixMilli(*got).Equal(want) {
+		t.Errorf("next run = %v, want %v", time.UnixMilli(*got).UTC(), want)
+	}
+	if cs.computeNextRun(&CronSchedule{Kind: "cron", Expr: "0 9 * * *", TZ: "Mars/Olympus"}, now) != nil {
+		t.Error("an unknown time zone should not schedule the job")
+	}
+}
+
+func TestCronService_MissedOneTimeJobsRunAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	cs1 := NewCronService(path, nil)
+	past := time.Now().Add(-time.Hour).UnixMilli()
+	job, err := cs1.AddJob("Missed", CronSchedule{Kind: "at", AtMS: &past}, "remind me", true, "telegram", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ran := make(chan string, 1)
+	cs2 := NewCronService(path, func(j *CronJob) (string, error) {
+		ran <- j.ID
+		return "ok", nil
+	})
+	if err := cs2.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cs2.Stop()
+	select {
+	case id := <-ran:
+		if id != job.ID {
+			t.Errorf("ran %s, want %s", id, job.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the missed reminder did not run after the restart")
+	}
+}
+
+func TestCronService_SyncConfiguredJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	cs := NewCronService(path, nil)
+	every := CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}
+	if _, err := cs.AddJob("user", every, "hi", false, "cli", "direct"); err != nil {
+		t.Fatal(err)
+	}
+	standup := CronJob{
+		ID:       ConfiguredJobPrefix + "standup",
+		Name:     "standup",
+		Schedule: CronSchedule{Kind: "cron", Expr: "0 9 * * 1-5"},
+		Payload:  CronPayload{Kind: "agent_turn", Message: "Summarize yesterday", Channel: "slack", To: "C1"},
+	}
+	weekly := standup
+	weekly.ID, weekly.Name = ConfiguredJobPrefix+"weekly", "weekly"
+	weekly.Schedule = CronSchedule{Kind: "every", EveryMS: int64Ptr(7 * 24 * 3600 * 1000)}
+	if err := cs.SyncConfiguredJobs([]CronJob{standup, weekly}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(cs.ListJobs(true)); got != 3 {
+		t.Fatalf("jobs = %d, want 3", got)
+	}
+	var firstNext int64
+	for _, j := range cs.ListJobs(true) {
+		if j.ID == weekly.ID {
+			firstNext = *j.State.NextRunAtMS
+		}
+	}
+
+	// Resyncing keeps the next run of unchanged jobs and drops removed ones.
+	time.Sleep(5 * time.Millisecond)
+	if err := cs.SyncConfiguredJobs([]CronJob{standup, weekly}); err != nil {
+		t.Fatal(err)
+	}
+	for _, j := range cs.ListJobs(true) {
+		if j.ID == weekly.ID && *j.State.NextRunAtMS != firstNext {
+			t.Error("resyncing an unchanged job moved its next run")
+		}
+	}
+	standup.Payload.Message = "Summarize today"
+	if err := cs.SyncConfiguredJobs([]CronJob{standup}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.SyncConfiguredJobs([]CronJob{standup, weekly}); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := NewCronService(path, nil)
+	jobs := reloaded.ListJobs(true)
+	if len(jobs) != 3 {
+		t.Fatalf("jobs after resync = %+v", jobs)
+	}
+	for _, j := range jobs {
+		switch j.ID {
+		case standup.ID:
+			if j.Payload.Message != "Summarize today" || !j.Enabled {
+				t.Errorf("standup = %+v", j)
+			}
+		case weekly.ID:
+			if *j.State.NextRunAtMS == firstNext {
+				t.Error("a job removed and added again should be rescheduled")
+			}
+		}
+	}
+
+	if err := cs.SyncConfiguredJobs([]CronJob{{ID: "other"}}); err == nil {
+		t.Error("a job without the configured prefix should be refused")
+	}
+}
+
+func TestCronService_RemoveJobsBy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	cs := NewCronService(path, nil)
+	every := CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}
+	add := func(name, channel, to, createdBy string) {
+		job, err := cs.AddJob(name, every, "hi", true, channel, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.CreatedBy = createdBy
+		if err := cs.UpdateJob(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("in group", "telegram", "-100", "42")
+	add("old dm", "telegram", "42", "")
+	add("other user", "telegram", "-100", "7")
+	add("other channel", "discord", "42", "42")
+	if err := cs.SyncConfiguredJobs([]CronJob{{
+		ID:      ConfiguredJobPrefix + "standup",
+		Name:    "standup",
+		Payload: CronPayload{Kind: "agent_turn", Message: "hi", Channel: "telegram", To: "42"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := cs.RemoveJobsBy("telegram", "42")
+	if err != nil || n != 2 {
+		t.Fatalf("RemoveJobsBy = %d, %v; want 2", n, err)
+	}
+	var names []string
+	for _, j := range NewCronService(path, nil).ListJobs(true) {
+		names = append(names, j.Name)
+	}
+	if fmt.Sprint(names) != "[other user other channel standup]" {
+		t.Errorf("jobs left = %v", names)
+	}
+}
@@ -0,0 +1,36 @@
+// Package dashboard serves a web UI over the admin API: the live sessions and
+// their history, token and cost usage, tool-call transcripts, and channel
+// settings. The page holds no data itself; it asks for the admin API token
+// and reads everything through the API with it.
+package dashboard
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// HTTPPath is where the gateway mounts the dashboard.
+const HTTPPath = "/dashboard/"
+
+//go:embed index.html
+var page []byte
+
+// Handler returns the handler of the dashboard page.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != HTTPPath {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'unsafe-inline'; script-src 'unsafe-inline'")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Write(page)
+	})
+}
@@ -0,0 +1,81 @@
+// Package database opens picoclaw's SQLite database and keeps its schema
+// current with the migrations embedded in this package.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/sipeed/picoclaw/pkg/dbmigrate"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	driverName = "sqlite"
+	// FileName is the database file inside the workspace state directory.
+	FileName = "picoclaw.db"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// Path returns the database location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", FileName)
+}
+
+// Migrations returns the schema migrations shipped with this build.
+func Migrations() ([]dbmigrate.Migration, error) {
+	return dbmigrate.Load(migrationsFS, "migrations")
+}
+
+// OpenRaw opens the database without touching its schema.
+func OpenRaw(ctx context.Context, path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create database dir: %w", err)
+	}
+	db, err := sql.Open(driverName, "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	// SQLite allows a single writer; serializing here avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	return db, nil
+}
+
+// Open opens the database and applies any pending migrations, so storage
+// backends always see the schema of the running release.
+func Open(ctx context.Context, path string) (*sql.DB, error) {
+	db, err := OpenRaw(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	applied, err := dbmigrate.New(db, migrations).Up(ctx)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate database %s: %w", path, err)
+	}
+	for _, m := range applied {
+		logger.InfoCF("database", "Applied schema migration", map[string]any{
+			"version": m.Version,
+			"name":    m.Name,
+		})
+	}
+	return db, nil
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen_AppliesMigrations(t *testing.T) {
+	ctx := context.Background()
+	path := Path(t.TempDir())
+
+	db, err := Open(ctx, path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO metadata (key, value) VALUES ('k', 'v')"); err != nil {
+		t.Fatalf("metadata table missing: %v", err)
+	}
+	db.Close()
+
+	// Reopening an up-to-date database is a no-op.
+	db, err = Open(ctx, path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+	var value string
+	if err := db.QueryRowContext(ctx, "SELECT value FROM metadata WHERE key = 'k'").Scan(&value); err != nil || value != "v" {
+		t.Errorf("value = %q, err=%v", value, err)
+	}
+}
+
+func TestPath(t *testing.T) {
+	if got := Path("/ws"); got != filepath.Join("/ws", "state", FileName) {
+		t.Errorf("Path = %q", got)
+	}
+}
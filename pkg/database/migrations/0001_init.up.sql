@@ -0,0 +1,6 @@
+-- Key/value metadata shared by storage backends (e.g. feature flags, cursors).
+CREATE TABLE metadata (
+    key        TEXT PRIMARY KEY,
+    value      TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
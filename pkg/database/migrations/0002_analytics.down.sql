@@ -0,0 +1,2 @@
+DROP INDEX message_analytics_time;
+DROP TABLE message_analytics;
@@ -0,0 +1,22 @@
+-- One row per handled inbound message, for offline analysis.
+CREATE TABLE message_analytics (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    time              TEXT NOT NULL, -- UTC, 2006-01-02T15:04:05.000Z, sorts as text
+    request_id        TEXT NOT NULL DEFAULT '',
+    agent_id          TEXT NOT NULL DEFAULT '',
+    channel           TEXT NOT NULL,
+    chat_id           TEXT NOT NULL DEFAULT '',
+    sender_id         TEXT NOT NULL DEFAULT '',
+    model             TEXT NOT NULL DEFAULT '',
+    variant           TEXT NOT NULL DEFAULT '',
+    outcome           TEXT NOT NULL,
+    llm_calls         INTEGER NOT NULL DEFAULT 0,
+    tool_calls        INTEGER NOT NULL DEFAULT 0,
+    prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    total_ms          INTEGER NOT NULL DEFAULT 0,
+    llm_ms            INTEGER NOT NULL DEFAULT 0,
+    tool_ms           INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX message_analytics_time ON message_analytics (time);
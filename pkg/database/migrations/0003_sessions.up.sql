@@ -0,0 +1,15 @@
+-- Conversations of the sqlite session store, keyed like the JSONL files.
+CREATE TABLE sessions (
+    key        TEXT PRIMARY KEY,
+    summary    TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL, -- UTC, 2006-01-02T15:04:05.000Z
+    updated_at TEXT NOT NULL
+);
+
+-- One row per message of a session, in seq order.
+CREATE TABLE session_messages (
+    session_key TEXT NOT NULL REFERENCES sessions (key) ON DELETE CASCADE,
+    seq         INTEGER NOT NULL,
+    message     TEXT NOT NULL, -- JSON-encoded providers.Message
+    PRIMARY KEY (session_key, seq)
+);
@@ -0,0 +1,3 @@
+DROP INDEX memories_added_by;
+DROP INDEX memories_scope;
+DROP TABLE memories;
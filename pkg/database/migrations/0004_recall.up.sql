@@ -0,0 +1,13 @@
+-- Long-term memories saved with /remember, embedded for retrieval.
+CREATE TABLE memories (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    scope      TEXT NOT NULL,             -- conversation the memory belongs to
+    text       TEXT NOT NULL,
+    added_by   TEXT NOT NULL DEFAULT '',  -- channel:sender_id
+    created_at TEXT NOT NULL,             -- UTC, 2006-01-02T15:04:05.000Z, sorts as text
+    model      TEXT NOT NULL,             -- embedding model of vector
+    vector     BLOB NOT NULL              -- little-endian float32s
+);
+
+CREATE INDEX memories_scope ON memories (scope);
+CREATE INDEX memories_added_by ON memories (added_by);
@@ -0,0 +1,5 @@
+DROP INDEX document_chunks_document;
+DROP INDEX documents_added_by;
+DROP INDEX documents_scope;
+DROP TABLE document_chunks;
+DROP TABLE documents;
@@ -0,0 +1,22 @@
+-- Knowledge base documents added with /kb add, chunked and embedded for retrieval.
+CREATE TABLE documents (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    scope      TEXT NOT NULL,             -- conversation the document belongs to
+    title      TEXT NOT NULL,
+    source     TEXT NOT NULL DEFAULT '',  -- URL or attachment file name
+    added_by   TEXT NOT NULL DEFAULT '',  -- channel:sender_id
+    created_at TEXT NOT NULL              -- UTC, 2006-01-02T15:04:05.000Z, sorts as text
+);
+
+CREATE TABLE document_chunks (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    document_id INTEGER NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    seq         INTEGER NOT NULL,         -- position in the document, from 1
+    text        TEXT NOT NULL,
+    model       TEXT NOT NULL,            -- embedding model of vector
+    vector      BLOB NOT NULL             -- little-endian float32s
+);
+
+CREATE INDEX documents_scope ON documents (scope);
+CREATE INDEX documents_added_by ON documents (added_by);
+CREATE INDEX document_chunks_document ON document_chunks (document_id);
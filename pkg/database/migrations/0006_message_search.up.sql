@@ -0,0 +1,13 @@
+-- Full-text index of the user and assistant messages of conversations, for /search.
+CREATE VIRTUAL TABLE message_search USING fts5 (
+    text,
+    role        UNINDEXED,
+    agent_id    UNINDEXED,
+    session_key UNINDEXED, -- base session key, shared by its branches
+    channel     UNINDEXED,
+    chat_id     UNINDEXED,
+    sender_id   UNINDEXED,
+    link        UNINDEXED, -- the platform message the turn started with
+    created_at  UNINDEXED, -- UTC, 2006-01-02T15:04:05.000Z, sorts as text
+    tokenize = 'unicode61 remove_diacritics 2'
+);
@@ -0,0 +1,279 @@
+// Package dbmigrate applies versioned SQL schema migrations.
+//
+// Migrations are plain SQL files named
+//
+//	{version}_{name}.up.sql
+//	{version}_{name}.down.sql
+//
+// usually embedded with go:embed. Applied versions are recorded in the
+// schema_migrations table, and every migration runs in its own transaction.
+// Statements use SQLite syntax.
+package dbmigrate
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tableName = "schema_migrations"
+
+// Migration is a single schema version.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a known migration is applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// ErrNewerSchema is returned when the database has versions this build does
+// not know about, which means it was migrated by a newer release.
+var ErrNewerSchema = errors.New("database schema is newer than this build")
+
+// Load reads migrations from dir in fsys. Every version needs an up file;
+// down files are optional, and a missing one makes that version irreversible.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	found := make(map[int64]*Migration)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		version, name, direction, err := parseFilename(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", e.Name(), err)
+		}
+
+		m, ok := found[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			found[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(found))
+	for _, m := range found {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, byVersion)
+	return migrations, nil
+}
+
+func byVersion(a, b Migration) int { return cmp.Compare(a.Version, b.Version) }
+
+// parseFilename splits "0001_create_users.up.sql" into its parts.
+func parseFilename(filename string) (int64, string, string, error) {
+	base := strings.TrimSuffix(filename, ".sql")
+	var direction string
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		direction = "up"
+	case strings.HasSuffix(base, ".down"):
+		direction = "down"
+	default:
+		return 0, "", "", fmt.Errorf("migration %s: expected .up.sql or .down.sql suffix", filename)
+	}
+	base = strings.TrimSuffix(base, "."+direction)
+
+	num, name, _ := strings.Cut(base, "_")
+	version, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migration %s: invalid version %q", filename, num)
+	}
+	return version, name, direction, nil
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New creates a migrator for db. Migrations are sorted by version.
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	sorted := slices.Clone(migrations)
+	slices.SortFunc(sorted, byVersion)
+	return &Migrator{db: db, migrations: sorted}
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+tableName+` (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", tableName, err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM `+tableName)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", tableName, err)
+	}
+	defer rows.Close()
+
+	out := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("scan %s: %w", tableName, err)
+		}
+		out[version] = at
+	}
+	return out, rows.Err()
+}
+
+// Version returns the highest applied version, or 0 for a fresh database.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var v int64
+	for version := range applied {
+		v = max(v, version)
+	}
+	return v, nil
+}
+
+// Status lists every known migration and whether it is applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		out = append(out, Status{Migration: mig, Applied: ok, AppliedAt: at})
+	}
+	return out, nil
+}
+
+// Up applies all pending migrations in order and returns the ones applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkKnown(applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if err := m.run(ctx, mig, mig.Up, true); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down reverts the latest steps applied migrations and returns them, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkKnown(applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if strings.TrimSpace(mig.Down) == "" {
+			return done, fmt.Errorf("migration %d_%s is irreversible (no down script)", mig.Version, mig.Name)
+		}
+		if err := m.run(ctx, mig, mig.Down, false); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+func (m *Migrator) checkKnown(applied map[int64]time.Time) error {
+	for version := range applied {
+		known := slices.ContainsFunc(m.migrations, func(mig Migration) bool { return mig.Version == version })
+		if !known {
+			return fmt.Errorf("%w: version %d is not known", ErrNewerSchema, version)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) run(ctx context.Context, mig Migration, script string, up bool) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	direction := "up"
+	if !up {
+		direction = "down"
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %d_%s (%s): %w", mig.Version, mig.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO `+tableName+` (version, name, applied_at) VALUES (?, ?, ?)`,
+			mig.Version, mig.Name, time.Now().UTC())
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM `+tableName+` WHERE version = ?`, mig.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("record migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return tx.Commit()
+}
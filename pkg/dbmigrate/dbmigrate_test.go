@@ -0,0 +1,139 @@
+package dbmigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+var testFS = fstest.MapFS{
+	"m/0001_users.up.sql":      {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);")},
+	"m/0001_users.down.sql":    {Data: []byte("DROP TABLE users;")},
+	"m/0002_add_name.up.sql":   {Data: []byte("ALTER TABLE users ADD COLUMN name TEXT;")},
+	"m/0002_add_name.down.sql": {Data: []byte("ALTER TABLE users DROP COLUMN name;")},
+	"m/0003_seed.up.sql":       {Data: []byte("INSERT INTO users (id, name) VALUES (1, 'a');")},
+	"m/README.md":              {Data: []byte("ignored")},
+}
+
+func TestLoad(t *testing.T) {
+	migs, err := Load(testFS, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migs) != 3 {
+		t.Fatalf("expected 3 migrations, got %d", len(migs))
+	}
+	if migs[0].Version != 1 || migs[1].Name != "add_name" || migs[2].Down != "" {
+		t.Errorf("unexpected migrations: %+v", migs)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad version": {"m/x_users.up.sql": {Data: []byte("SELECT 1;")}},
+		"bad suffix":  {"m/0001_users.sql": {Data: []byte("SELECT 1;")}},
+		"down only":   {"m/0001_users.down.sql": {Data: []byte("SELECT 1;")}},
+		"name clash": {
+			"m/0001_a.up.sql": {Data: []byte("SELECT 1;")},
+			"m/0001_b.up.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(fsys, "m"); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	migs, err := Load(testFS, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(db, migs)
+
+	applied, err := m.Up(ctx)
+	if err != nil || len(applied) != 3 {
+		t.Fatalf("Up = %d migrations, err=%v", len(applied), err)
+	}
+	if v, _ := m.Version(ctx); v != 3 {
+		t.Errorf("Version = %d, want 3", v)
+	}
+	if again, err := m.Up(ctx); err != nil || len(again) != 0 {
+		t.Errorf("second Up should be a no-op, got %d, err=%v", len(again), err)
+	}
+
+	// 0003 has no down script, so reverting stops there.
+	if _, err := m.Down(ctx, 1); err == nil {
+		t.Fatal("expected irreversible migration error")
+	}
+
+	m = New(db, migs[:2])
+	db.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = 3")
+	reverted, err := m.Down(ctx, 2)
+	if err != nil || len(reverted) != 2 || reverted[0].Version != 2 {
+		t.Fatalf("Down = %+v, err=%v", reverted, err)
+	}
+	if v, _ := m.Version(ctx); v != 0 {
+		t.Errorf("Version = %d after full revert", v)
+	}
+	if _, err := db.ExecContext(ctx, "SELECT 1 FROM users"); err == nil {
+		t.Error("users table should be dropped")
+	}
+}
+
+func TestMigrator_FailedMigrationRollsBack(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	m := New(db, []Migration{
+		{Version: 1, Name: "ok", Up: "CREATE TABLE t (id INTEGER);"},
+		{Version: 2, Name: "broken", Up: "CREATE TABLE u (id INTEGER); NOT SQL;"},
+	})
+
+	applied, err := m.Up(ctx)
+	if err == nil || len(applied) != 1 {
+		t.Fatalf("expected first migration to apply and second to fail, got %d, err=%v", len(applied), err)
+	}
+	if _, err := db.ExecContext(ctx, "SELECT 1 FROM u"); err == nil {
+		t.Error("failed migration must be rolled back")
+	}
+	statuses, _ := m.Status(ctx)
+	if !statuses[0].Applied || statuses[1].Applied {
+		t.Errorf("unexpected status: %+v", statuses)
+	}
+}
+
+func TestMigrator_NewerSchema(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	full := []Migration{
+		{Version: 1, Name: "a", Up: "CREATE TABLE a (id INTEGER);"},
+		{Version: 2, Name: "b", Up: "CREATE TABLE b (id INTEGER);"},
+	}
+	if _, err := New(db, full).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(db, full[:1]).Up(ctx); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("expected ErrNewerSchema, got %v", err)
+	}
+}
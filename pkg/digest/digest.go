@@ -0,0 +1,141 @@
+// Package digest builds periodic usage reports from the usage store and
+// delivers them to an admin chat or by email.
+package digest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// defaultTopUsers is used when the config does not set top_users.
+const defaultTopUsers = 5
+
+// UserStat is one row of the top users table.
+type UserStat struct {
+	SenderID string
+	Messages int
+	Tokens   int
+	Cost     float64
+}
+
+// Report is a usage digest for one period.
+type Report struct {
+	From     time.Time
+	To       time.Time
+	Messages int
+	Usage    usage.Summary
+	Cost     float64
+	TopUsers []UserStat
+}
+
+// Build aggregates the records in [from, to) into a report with at most
+// topN users, ranked by tokens.
+func Build(records []usage.Record, from, to time.Time, pricing usage.Pricing, topN int) Report {
+	rep := Report{From: from, To: to}
+	users := make(map[string]*UserStat)
+
+	for _, r := range records {
+		if r.Time.Before(from) || !r.Time.Before(to) {
+			continue
+		}
+		cost := pricing.Cost(r)
+		rep.Usage.Add(r)
+		rep.Cost += cost
+		if r.TurnStart {
+			rep.Messages++
+		}
+
+		if r.SenderID == "" {
+			continue
+		}
+		u, ok := users[r.SenderID]
+		if !ok {
+			u = &UserStat{SenderID: r.SenderID}
+			users[r.SenderID] = u
+		}
+		if r.TurnStart {
+			u.Messages++
+		}
+		u.Tokens += r.PromptTokens + r.CompletionTokens
+		u.Cost += cost
+	}
+
+	for _, u := range users {
+		rep.TopUsers = append(rep.TopUsers, *u)
+	}
+	sort.Slice(rep.TopUsers, func(i, j int) bool {
+		a, b := rep.TopUsers[i], rep.TopUsers[j]
+		if a.Tokens != b.Tokens {
+			return a.Tokens > b.Tokens
+		}
+		return a.SenderID < b.SenderID
+	})
+	if topN > 0 && len(rep.TopUsers) > topN {
+		rep.TopUsers = rep.TopUsers[:topN]
+	}
+	return rep
+}
+
+// Title returns the digest headline, e.g. "PicoClaw usage digest (2026-03-01 – 2026-03-08)".
+func (r Report) Title() string {
+	last := r.To.Add(-time.Second)
+	if r.To.Sub(r.From) <= 24*time.Hour {
+		return "PicoClaw usage digest (" + r.From.Format("2006-01-02") + ")"
+	}
+	return "PicoClaw usage digest (" + r.From.Format("2006-01-02") + " – " + last.Format("2006-01-02") + ")"
+}
+
+// Render formats the report as plain text suitable for chat and email.
+func (r Report) Render() string {
+	var sb strings.Builder
+	sb.WriteString("📊 " + r.Title() + "\n\n")
+	fmt.Fprintf(&sb, "Messages handled: %d\n", r.Messages)
+	fmt.Fprintf(&sb, "LLM requests: %d\n", r.Usage.Requests)
+	fmt.Fprintf(&sb, "Tokens: %d (prompt %d, completion %d)\n",
+		r.Usage.TotalTokens(), r.Usage.PromptTokens, r.Usage.CompletionTokens)
+	if r.Cost > 0 {
+		fmt.Fprintf(&sb, "Cost: $%.2f\n", r.Cost)
+	}
+	fmt.Fprintf(&sb, "Error rate: %.1f%% (%d errors)\n", r.Usage.ErrorRate()*100, r.Usage.Errors)
+	if r.Usage.Requests > 0 {
+		fmt.Fprintf(&sb, "Avg latency: %s\n", r.Usage.AvgLatency())
+	}
+
+	if len(r.TopUsers) > 0 {
+		sb.WriteString("\nTop users:\n")
+		for i, u := range r.TopUsers {
+			fmt.Fprintf(&sb, "%d. %s: %d messages, %d tokens", i+1, u.SenderID, u.Messages, u.Tokens)
+			if u.Cost > 0 {
+				fmt.Fprintf(&sb, ", $%.2f", u.Cost)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// PricingFromConfig collects the prices set in model_list. Each price is
+// keyed by the model alias, the full model string, and the bare model ID,
+// since the usage store records whichever name the agent resolved.
+func PricingFromConfig(cfg *config.Config) usage.Pricing {
+	pricing := make(usage.Pricing)
+	for _, mc := range cfg.ModelList {
+		if mc.InputPrice == 0 && mc.OutputPrice == 0 {
+			continue
+		}
+		price := usage.Price{Input: mc.InputPrice, Output: mc.OutputPrice}
+		_, modelID := providers.ExtractProtocol(mc.Model)
+		for _, key := range []string{mc.ModelName, mc.Model, modelID} {
+			if key != "" {
+				pricing[key] = price
+			}
+		}
+	}
+	return pricing
+}
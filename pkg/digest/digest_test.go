@@ -0,0 +1,213 @@
+package digest
+
+import (
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/flagged"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func TestBuild(t *testing.T) {
+	from := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	records := []usage.Record{
+		{Time: from.Add(-time.Minute), SenderID: "old", PromptTokens: 999, TurnStart: true},
+		{Time: from.Add(time.Hour), SenderID: "alice", Model: "gpt", PromptTokens: 1000, CompletionTokens: 500, TurnStart: true},
+		{Time: from.Add(time.Hour), SenderID: "alice", Model: "gpt", PromptTokens: 2000, CompletionTokens: 100},
+		{Time: from.Add(2 * time.Hour), SenderID: "bob", Model: "gpt", PromptTokens: 100, TurnStart: true, Error: true},
+		{Time: to, SenderID: "late", PromptTokens: 1, TurnStart: true},
+	}
+	pricing := usage.Pricing{"gpt": {Input: 1, Output: 10}}
+
+	rep := Build(records, from, to, pricing, 1)
+
+	if rep.Messages != 2 || rep.Usage.Requests != 3 {
+		t.Fatalf("messages=%d requests=%d, want 2 and 3", rep.Messages, rep.Usage.Requests)
+	}
+	if rep.Usage.TotalTokens() != 3700 {
+		t.Errorf("tokens = %d, want 3700", rep.Usage.TotalTokens())
+	}
+	// (3100*1 + 600*10) / 1e6
+	if got, want := rep.Cost, 0.0091; got < want-1e-9 || got > want+1e-9 {
+		t.Errorf("cost = %f, want %f", got, want)
+	}
+	if len(rep.TopUsers) != 1 || rep.TopUsers[0].SenderID != "alice" || rep.TopUsers[0].Messages != 1 {
+		t.Errorf("top users = %+v", rep.TopUsers)
+	}
+
+	text := rep.Render()
+	for _, want := range []string{"Messages handled: 2", "Error rate: 33.3%", "1. alice", "(2026-03-01)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("rendered digest missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	loc := time.UTC
+	now := time.Date(2026, 3, 4, 10, 30, 0, 0, loc) // Wednesday
+
+	tests := []struct {
+		name string
+		cfg  config.DigestConfig
+		want time.Time
+	}{
+		{"daily later today", config.DigestConfig{Hour: 18}, time.Date(2026, 3, 4, 18, 0, 0, 0, loc)},
+		{"daily tomorrow", config.DigestConfig{Hour: 9}, time.Date(2026, 3, 5, 9, 0, 0, 0, loc)},
+		{
+			"weekly next monday",
+			config.DigestConfig{Schedule: "weekly", Hour: 9, Weekday: "Monday"},
+			time.Date(2026, 3, 9, 9, 0, 0, 0, loc),
+		},
+		{
+			"weekly same day passed",
+			config.DigestConfig{Schedule: "weekly", Hour: 9, Weekday: "wednesday"},
+			time.Date(2026, 3, 11, 9, 0, 0, 0, loc),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewService(tt.cfg, t.TempDir(), nil).NextRun(now)
+			if !got.Equal(tt.want) {
+				t.Errorf("NextRun = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStart_RequiresDestination(t *testing.T) {
+	svc := NewService(config.DigestConfig{Enabled: true}, t.TempDir(), nil)
+	if err := svc.Start(); err == nil {
+		svc.Stop()
+		t.Fatal("expected error without channel or email recipients")
+	}
+
+	svc = NewService(config.DigestConfig{Enabled: true, Channel: "telegram", ChatID: "1", Weekday: "someday"},
+		t.TempDir(), nil)
+	if err := svc.Start(); err == nil {
+		svc.Stop()
+		t.Fatal("expected error for unknown weekday")
+	}
+}
+
+func TestSend_ChatAndEmail(t *testing.T) {
+	workspace := t.TempDir()
+	end := time.Now()
+	tracker := usage.NewTracker(usage.Path(workspace))
+	if err := tracker.Record(usage.Record{Time: end.Add(-time.Hour), SenderID: "alice", TurnStart: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewService(config.DigestConfig{
+		Channel: "telegram",
+		ChatID:  "admin",
+		Email: config.DigestEmailConfig{
+			SMTPHost: "smtp.example.com",
+			From:     "bot@example.com",
+			To:       []string{"ops@example.com"},
+		},
+	}, workspace, nil)
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	svc.SetBus(msgBus)
+
+	var mailAddr string
+	var mailBody []byte
+	svc.sendMail = func(addr string, _ smtp.Auth, _ string, _ []string, msg []byte) error {
+		mailAddr, mailBody = addr, msg
+		return nil
+	}
+
+	if err := svc.Send(context.Background(), end); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	select {
+	case out := <-msgBus.OutboundChan():
+		if out.Channel != "telegram" || out.ChatID != "admin" || !strings.Contains(out.Content, "Messages handled: 1") {
+			t.Errorf("unexpected outbound message: %+v", out)
+		}
+	default:
+		t.Fatal("digest was not posted to the admin chat")
+	}
+	if mailAddr != "smtp.example.com:587" {
+		t.Errorf("smtp addr = %q", mailAddr)
+	}
+	if !strings.Contains(string(mailBody), "Subject: PicoClaw usage digest") {
+		t.Errorf("mail missing subject:\n%s", mailBody)
+	}
+}
+
+func TestPricingFromConfig(t *testing.T) {
+	cfg := &config.Config{ModelList: []config.ModelConfig{
+		{ModelName: "smart", Model: "openai/gpt-5", InputPrice: 2, OutputPrice: 8},
+		{ModelName: "free", Model: "ollama/llama3"},
+	}}
+	pricing := PricingFromConfig(cfg)
+	for _, key := range []string{"smart", "openai/gpt-5", "gpt-5"} {
+		if pricing[key] != (usage.Price{Input: 2, Output: 8}) {
+			t.Errorf("pricing[%q] = %+v", key, pricing[key])
+		}
+	}
+	if _, ok := pricing["free"]; ok {
+		t.Error("models without prices should not be listed")
+	}
+}
+
+func TestSendFlagged_HTMLEmail(t *testing.T) {
+	workspace := t.TempDir()
+	end := time.Now()
+	store := flagged.NewStore(flagged.Path(workspace))
+	store.Record(flagged.Flag{Time: end.Add(-3 * time.Hour), Source: flagged.SourceModeration, Reason: "self-harm"})
+	store.Record(flagged.Flag{
+		Time:     end.Add(-time.Hour),
+		Source:   flagged.SourceFeedback,
+		Channel:  "telegram",
+		SenderID: "alice",
+		Prompt:   "what is <b>2+2</b>?",
+		Response: "5",
+	})
+
+	svc := NewService(config.DigestConfig{
+		Email:   config.DigestEmailConfig{SMTPHost: "smtp.example.com", From: "bot@example.com"},
+		Flagged: config.FlaggedDigestConfig{To: []string{"trust@example.com"}, MaxItems: 1},
+	}, workspace, nil)
+	var rcpt []string
+	var mailBody string
+	svc.sendMail = func(_ string, _ smtp.Auth, _ string, to []string, msg []byte) error {
+		rcpt, mailBody = to, string(msg)
+		return nil
+	}
+
+	if err := svc.SendFlagged(context.Background(), end); err != nil {
+		t.Fatalf("SendFlagged: %v", err)
+	}
+	if len(rcpt) != 1 || rcpt[0] != "trust@example.com" {
+		t.Errorf("recipients = %v", rcpt)
+	}
+	for _, want := range []string{
+		"Content-Type: multipart/alternative",
+		"Content-Type: text/html",
+		"By source: feedback 1, moderation 1",
+		"Showing the latest 1.",
+		"what is &lt;b&gt;2&#43;2&lt;/b&gt;?",
+	} {
+		if !strings.Contains(mailBody, want) {
+			t.Errorf("mail missing %q:\n%s", want, mailBody)
+		}
+	}
+	if strings.Contains(mailBody, "self-harm") {
+		t.Error("max_items should keep only the newest flag")
+	}
+
+	rcpt = nil
+	if err := svc.SendFlagged(context.Background(), end.AddDate(0, 0, 2)); err != nil || rcpt != nil {
+		t.Errorf("quiet period should send nothing: %v, %v", rcpt, err)
+	}
+}
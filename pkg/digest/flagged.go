@@ -0,0 +1,126 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/flagged"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// defaultFlaggedItems is used when the config does not set max_items.
+	defaultFlaggedItems = 50
+	// flaggedExcerptLen bounds each prompt and response shown in the digest.
+	flaggedExcerptLen = 2000
+)
+
+// FlaggedReport lists the conversations flagged in one period, newest first.
+type FlaggedReport struct {
+	From  time.Time
+	To    time.Time
+	Total int
+	// BySource counts flags per source, e.g. moderation and feedback.
+	BySource map[string]int
+	Flags    []flagged.Flag
+}
+
+// BuildFlagged keeps the flags in [from, to) and at most maxItems of them.
+// Total and BySource count every flag in the period.
+func BuildFlagged(flags []flagged.Flag, from, to time.Time, maxItems int) FlaggedReport {
+	rep := FlaggedReport{From: from, To: to, BySource: make(map[string]int)}
+	for _, f := range flags {
+		if f.Time.Before(from) || !f.Time.Before(to) {
+			continue
+		}
+		rep.Total++
+		rep.BySource[f.Source]++
+		rep.Flags = append(rep.Flags, f)
+	}
+	sort.SliceStable(rep.Flags, func(i, j int) bool { return rep.Flags[i].Time.After(rep.Flags[j].Time) })
+	if maxItems > 0 && len(rep.Flags) > maxItems {
+		rep.Flags = rep.Flags[:maxItems]
+	}
+	return rep
+}
+
+// Title returns the email subject, e.g. "PicoClaw flagged conversations (2026-03-01): 3".
+func (r FlaggedReport) Title() string {
+	period := r.From.Format("2006-01-02")
+	if r.To.Sub(r.From) > 24*time.Hour {
+		period += " – " + r.To.Add(-time.Second).Format("2006-01-02")
+	}
+	return fmt.Sprintf("PicoClaw flagged conversations (%s): %d", period, r.Total)
+}
+
+func (r FlaggedReport) sourceSummary() string {
+	sources := make([]string, 0, len(r.BySource))
+	for src := range r.BySource {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+	parts := make([]string, 0, len(sources))
+	for _, src := range sources {
+		parts = append(parts, fmt.Sprintf("%s %d", src, r.BySource[src]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Render formats the report as plain text, the fallback part of the email.
+func (r FlaggedReport) Render() string {
+	var sb strings.Builder
+	sb.WriteString("🚩 " + r.Title() + "\n")
+	if r.Total > 0 {
+		sb.WriteString("By source: " + r.sourceSummary() + "\n")
+	}
+	if len(r.Flags) < r.Total {
+		fmt.Fprintf(&sb, "Showing the latest %d.\n", len(r.Flags))
+	}
+	for _, f := range r.Flags {
+		fmt.Fprintf(&sb, "\n[%s] %s", f.Time.Format("2006-01-02 15:04"), f.Source)
+		if f.Reason != "" {
+			sb.WriteString(": " + f.Reason)
+		}
+		fmt.Fprintf(&sb, "\n%s %s, user %s\n", f.Channel, f.ChatID, f.SenderID)
+		if f.Prompt != "" {
+			sb.WriteString("User: " + utils.Truncate(f.Prompt, flaggedExcerptLen) + "\n")
+		}
+		if f.Response != "" {
+			sb.WriteString("Bot: " + utils.Truncate(f.Response, flaggedExcerptLen) + "\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+var flaggedHTML = template.Must(template.New("flagged").Funcs(template.FuncMap{
+	"excerpt": func(s string) string { return utils.Truncate(s, flaggedExcerptLen) },
+}).Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif; max-width: 760px">
+<h2>🚩 {{.Title}}</h2>
+{{if .Total}}<p>By source: {{.Sources}}</p>{{end}}
+{{if lt (len .Flags) .Total}}<p>Showing the latest {{len .Flags}}.</p>{{end}}
+{{range .Flags}}<div style="border-top: 1px solid #ddd; padding: 8px 0">
+<p><b>{{.Source}}</b>{{if .Reason}}: {{.Reason}}{{end}}<br>
+<small>{{.Time.Format "2006-01-02 15:04"}} · {{.Channel}} {{.ChatID}} · user {{.SenderID}}</small></p>
+{{if .Prompt}}<p><b>User</b></p><div style="white-space: pre-wrap; background: #f4f4f4; padding: 6px">{{excerpt .Prompt}}</div>{{end}}
+{{if .Response}}<p><b>Bot</b></p><div style="white-space: pre-wrap; background: #fff4f4; padding: 6px">{{excerpt .Response}}</div>{{end}}
+</div>
+{{end}}</body></html>
+`))
+
+// RenderHTML formats the report as an HTML email body. Message text is
+// escaped, so flagged content cannot inject markup into the operator's mail.
+func (r FlaggedReport) RenderHTML() (string, error) {
+	var buf bytes.Buffer
+	err := flaggedHTML.Execute(&buf, map[string]any{
+		"Title":   r.Title(),
+		"Total":   r.Total,
+		"Sources": r.sourceSummary(),
+		"Flags":   r.Flags,
+	})
+	return buf.String(), err
+}
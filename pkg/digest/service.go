@@ -0,0 +1,318 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/flagged"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// Schedules supported by DigestConfig.Schedule.
+const (
+	ScheduleDaily  = "daily"
+	ScheduleWeekly = "weekly"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Service posts the usage digest on its schedule, and mails the flagged
+// conversations digest to its subscribers.
+type Service struct {
+	cfg      config.DigestConfig
+	tracker  *usage.Tracker
+	flags    *flagged.Store
+	pricing  usage.Pricing
+	bus      *bus.MessageBus
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+	mu       sync.Mutex
+	stopChan chan struct{}
+	paused   bool
+}
+
+// NewService creates a digest service reading usage from workspace.
+func NewService(cfg config.DigestConfig, workspace string, pricing usage.Pricing) *Service {
+	return &Service{
+		cfg:      cfg,
+		tracker:  usage.NewTracker(usage.Path(workspace)),
+		flags:    flagged.NewStore(flagged.Path(workspace)),
+		pricing:  pricing,
+		sendMail: smtp.SendMail,
+	}
+}
+
+// SetBus sets the message bus used to post the digest to a chat.
+func (s *Service) SetBus(msgBus *bus.MessageBus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bus = msgBus
+}
+
+// SetPaused skips the digests that come due while paused, so only one of
+// several replicas sends them.
+func (s *Service) SetPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+}
+
+// Start begins waiting for the next scheduled digest. It returns an error
+// when the digest is enabled but has nowhere to go.
+func (s *Service) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.cfg.Enabled {
+		return nil
+	}
+	if s.stopChan != nil {
+		return nil
+	}
+	if !s.wantsUsage() && len(s.cfg.Flagged.To) == 0 {
+		return errors.New("digest: set channel and chat_id, email.to, or flagged.to")
+	}
+	if _, err := s.weekday(); err != nil {
+		return err
+	}
+
+	s.stopChan = make(chan struct{})
+	go s.runLoop(s.stopChan)
+
+	logger.InfoCF("digest", "Usage digest scheduled", map[string]any{
+		"schedule": s.schedule(),
+		"next":     s.NextRun(time.Now()).Format(time.RFC3339),
+	})
+	return nil
+}
+
+// Stop cancels the schedule.
+func (s *Service) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopChan == nil {
+		return
+	}
+	close(s.stopChan)
+	s.stopChan = nil
+}
+
+func (s *Service) runLoop(stopChan chan struct{}) {
+	for {
+		next := s.NextRun(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.mu.Lock()
+		paused := s.paused
+		s.mu.Unlock()
+		if paused {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if s.wantsUsage() {
+			if err := s.Send(ctx, next); err != nil {
+				logger.ErrorCF("digest", "Failed to send usage digest", map[string]any{"error": err.Error()})
+			}
+		}
+		if len(s.cfg.Flagged.To) > 0 {
+			if err := s.SendFlagged(ctx, next); err != nil {
+				logger.ErrorCF("digest", "Failed to send flagged conversations digest", map[string]any{"error": err.Error()})
+			}
+		}
+		cancel()
+	}
+}
+
+// wantsUsage reports whether the usage digest has a destination.
+func (s *Service) wantsUsage() bool {
+	return (s.cfg.Channel != "" && s.cfg.ChatID != "") || len(s.cfg.Email.To) > 0
+}
+
+func (s *Service) schedule() string {
+	if strings.EqualFold(s.cfg.Schedule, ScheduleWeekly) {
+		return ScheduleWeekly
+	}
+	return ScheduleDaily
+}
+
+func (s *Service) weekday() (time.Weekday, error) {
+	if s.cfg.Weekday == "" {
+		return time.Monday, nil
+	}
+	wd, ok := weekdays[strings.ToLower(s.cfg.Weekday)]
+	if !ok {
+		return 0, fmt.Errorf("digest: unknown weekday %q", s.cfg.Weekday)
+	}
+	return wd, nil
+}
+
+// NextRun returns the first scheduled send time strictly after now.
+func (s *Service) NextRun(now time.Time) time.Time {
+	hour := min(max(s.cfg.Hour, 0), 23)
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+
+	if s.schedule() == ScheduleWeekly {
+		wd, _ := s.weekday()
+		next = next.AddDate(0, 0, (int(wd)-int(next.Weekday())+7)%7)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	}
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// periodStart returns the start of the digest period ending at end.
+func (s *Service) periodStart(end time.Time) time.Time {
+	if s.schedule() == ScheduleWeekly {
+		return end.AddDate(0, 0, -7)
+	}
+	return end.AddDate(0, 0, -1)
+}
+
+// Send builds the digest for the period ending at end and delivers it.
+func (s *Service) Send(ctx context.Context, end time.Time) error {
+	from := s.periodStart(end)
+	records, err := s.tracker.Load(from)
+	if err != nil {
+		return fmt.Errorf("load usage: %w", err)
+	}
+	topN := s.cfg.TopUsers
+	if topN <= 0 {
+		topN = defaultTopUsers
+	}
+	rep := Build(records, from, end, s.pricing, topN)
+	text := rep.Render()
+
+	var errs []error
+	s.mu.Lock()
+	msgBus := s.bus
+	s.mu.Unlock()
+	if s.cfg.Channel != "" && s.cfg.ChatID != "" {
+		if msgBus == nil {
+			errs = append(errs, errors.New("message bus not configured"))
+		} else if err := msgBus.PublishOutbound(ctx, bus.OutboundMessage{
+			Channel: s.cfg.Channel,
+			ChatID:  s.cfg.ChatID,
+			Content: text,
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("post to %s: %w", s.cfg.Channel, err))
+		}
+	}
+	if len(s.cfg.Email.To) > 0 {
+		if err := s.mail(s.cfg.Email.To, rep.Title(), text, ""); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	logger.InfoCF("digest", "Usage digest sent", map[string]any{
+		"from":     from.Format(time.RFC3339),
+		"to":       end.Format(time.RFC3339),
+		"messages": rep.Messages,
+	})
+	return nil
+}
+
+// SendFlagged mails the conversations flagged in the period ending at end to
+// the flagged digest subscribers. Nothing is sent for a quiet period.
+func (s *Service) SendFlagged(ctx context.Context, end time.Time) error {
+	from := s.periodStart(end)
+	flags, err := s.flags.Load(from, end)
+	if err != nil {
+		return fmt.Errorf("load flagged conversations: %w", err)
+	}
+	if len(flags) == 0 {
+		return nil
+	}
+	maxItems := s.cfg.Flagged.MaxItems
+	if maxItems <= 0 {
+		maxItems = defaultFlaggedItems
+	}
+	rep := BuildFlagged(flags, from, end, maxItems)
+	html, err := rep.RenderHTML()
+	if err != nil {
+		return fmt.Errorf("render flagged digest: %w", err)
+	}
+	if err := s.mail(s.cfg.Flagged.To, rep.Title(), rep.Render(), html); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	logger.InfoCF("digest", "Flagged conversations digest sent", map[string]any{
+		"from":    from.Format(time.RFC3339),
+		"to":      end.Format(time.RFC3339),
+		"flagged": rep.Total,
+	})
+	return nil
+}
+
+// mail sends body to the given recipients. When html is set the message is
+// multipart/alternative with body as the plain-text part.
+func (s *Service) mail(to []string, subject, body, html string) error {
+	ec := s.cfg.Email
+	if ec.SMTPHost == "" || ec.From == "" {
+		return errors.New("smtp_host and from are required")
+	}
+	port := ec.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+
+	var auth smtp.Auth
+	if ec.Username != "" {
+		auth = smtp.PlainAuth("", ec.Username, ec.Password, ec.SMTPHost)
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + ec.From + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	if html == "" {
+		msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		msg.WriteString(crlf(body))
+	} else {
+		boundary := "picoclaw-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+		msg.WriteString("Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n\r\n")
+		msg.WriteString("--" + boundary + "\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+		msg.WriteString(crlf(body) + "\r\n")
+		msg.WriteString("--" + boundary + "\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n")
+		msg.WriteString(crlf(html) + "\r\n")
+		msg.WriteString("--" + boundary + "--\r\n")
+	}
+
+	addr := net.JoinHostPort(ec.SMTPHost, strconv.Itoa(port))
+	return s.sendMail(addr, auth, ec.From, to, []byte(msg.String()))
+}
+
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
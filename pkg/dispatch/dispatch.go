@@ -0,0 +1,220 @@
+// Package dispatch answers inbound messages on a pool of workers. Messages
+// of different conversations run at the same time, while the messages of
+// one conversation run one after another in the order they came in.
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+var (
+	// ErrFull is returned when a message does not fit in the queues.
+	ErrFull = errors.New("dispatch: queue full")
+	// ErrClosed is returned for messages submitted after Shutdown.
+	ErrClosed = errors.New("dispatch: shut down")
+)
+
+// cancelGrace is how long Shutdown waits for handlers to return once it has
+// canceled them.
+const cancelGrace = 5 * time.Second
+
+// Handler answers one message.
+type Handler func(ctx context.Context, msg bus.InboundMessage)
+
+// Options size the pool and its queues.
+type Options struct {
+	Workers    int // messages answered at once; at least 1
+	QueueSize  int // messages waiting per conversation; 0 is unlimited
+	MaxPending int // messages waiting overall; 0 is unlimited
+}
+
+// Dispatcher queues messages per conversation and runs them on its workers.
+type Dispatcher struct {
+	opts    Options
+	handler Handler
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queues  map[string][]bus.InboundMessage // waiting messages per conversation
+	busy    map[string]bool                 // conversations being answered
+	ready   []string                        // conversations with waiting messages and no worker, oldest first
+	pending int
+	closed  bool
+}
+
+// Key returns the conversation msg belongs to.
+func Key(msg bus.InboundMessage) string {
+	return msg.Channel + ":" + msg.ChatID
+}
+
+// New starts a dispatcher running handler. The handler gets a context with
+// the values of ctx that is only canceled, with cause ErrClosed, when
+// Shutdown gives up waiting, so turns in progress are not cut off when the
+// caller stops receiving.
+func New(ctx context.Context, opts Options, handler Handler) *Dispatcher {
+	opts.Workers = max(opts.Workers, 1)
+	runCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	d := &Dispatcher{
+		opts:    opts,
+		handler: handler,
+		ctx:     runCtx,
+		cancel:  cancel,
+		queues:  make(map[string][]bus.InboundMessage),
+		busy:    make(map[string]bool),
+	}
+	d.cond = sync.NewCond(&d.mu)
+	d.wg.Add(opts.Workers)
+	for range opts.Workers {
+		go d.work()
+	}
+	return d
+}
+
+// Submit queues msg behind the other messages of its conversation. It
+// returns ErrFull when the conversation or the dispatcher has as many
+// messages waiting as allowed.
+func (d *Dispatcher) Submit(msg bus.InboundMessage) error {
+	return d.enqueue(msg, true)
+}
+
+// Add queues msg regardless of the limits, for messages the agent sent
+// itself that must not be dropped.
+func (d *Dispatcher) Add(msg bus.InboundMessage) error {
+	return d.enqueue(msg, false)
+}
+
+func (d *Dispatcher) enqueue(msg bus.InboundMessage, bounded bool) error {
+	key := Key(msg)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	q := d.queues[key]
+	if bounded && ((d.opts.MaxPending > 0 && d.pending >= d.opts.MaxPending) ||
+		(d.opts.QueueSize > 0 && len(q) >= d.opts.QueueSize)) {
+		return ErrFull
+	}
+	d.queues[key] = append(q, msg)
+	d.pending++
+	if len(q) == 0 && !d.busy[key] {
+		d.ready = append(d.ready, key)
+		d.cond.Signal()
+	}
+	return nil
+}
+
+// Pending returns the number of messages waiting for a worker.
+func (d *Dispatcher) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.pending
+}
+
+// Shutdown stops taking messages and waits for the queued ones to be
+// answered. When ctx ends first, the messages still waiting are dropped,
+// the handlers' context is canceled, and once they return, or after a short
+// grace, the dropped messages are returned with ctx's error. It may be
+// called more than once.
+func (d *Dispatcher) Shutdown(ctx context.Context) ([]bus.InboundMessage, error) {
+	d.mu.Lock()
+	d.closed = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		d.cancel(ErrClosed)
+		return nil, nil
+	case <-ctx.Done():
+	}
+
+	d.mu.Lock()
+	var dropped []bus.InboundMessage
+	for _, key := range d.ready {
+		dropped = append(dropped, d.queues[key]...)
+		delete(d.queues, key)
+	}
+	// Conversations being answered are not in ready
+	for _, q := range d.queues {
+		dropped = append(dropped, q...)
+	}
+	d.queues = make(map[string][]bus.InboundMessage)
+	d.ready = nil
+	d.pending = 0
+	d.cond.Broadcast()
+	d.mu.Unlock()
+	d.cancel(ErrClosed)
+	// Give the canceled handlers a moment to wrap up their turns
+	select {
+	case <-done:
+	case <-time.After(cancelGrace):
+	}
+	return dropped, ctx.Err()
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		msg, key, ok := d.next()
+		if !ok {
+			return
+		}
+		d.handler(d.ctx, msg)
+		d.finish(key)
+	}
+}
+
+// next waits for a conversation with a waiting message and no worker and
+// takes its oldest message. It returns false once the dispatcher is shut
+// down with nothing left waiting.
+func (d *Dispatcher) next() (bus.InboundMessage, string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for len(d.ready) == 0 {
+		if d.closed && d.pending == 0 {
+			return bus.InboundMessage{}, "", false
+		}
+		d.cond.Wait()
+	}
+	key := d.ready[0]
+	d.ready = d.ready[1:]
+	q := d.queues[key]
+	msg := q[0]
+	if len(q) == 1 {
+		delete(d.queues, key)
+	} else {
+		d.queues[key] = q[1:]
+	}
+	d.pending--
+	d.busy[key] = true
+	if d.closed && d.pending == 0 {
+		d.cond.Broadcast()
+	}
+	return msg, key, true
+}
+
+// finish frees the conversation of key. One with more messages goes to the
+// back of the line, so a busy chat does not starve the others.
+func (d *Dispatcher) finish(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.busy, key)
+	if len(d.queues[key]) > 0 {
+		d.ready = append(d.ready, key)
+		d.cond.Signal()
+	}
+}
@@ -0,0 +1,144 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func inbound(chat, content string) bus.InboundMessage {
+	return bus.InboundMessage{Channel: "telegram", ChatID: chat, Content: content}
+}
+
+func TestDispatcher_OrderPerConversation(t *testing.T) {
+	var mu sync.Mutex
+	got := map[string][]string{}
+	running := map[string]int{}
+	overlap := false
+	d := New(context.Background(), Options{Workers: 4}, func(_ context.Context, msg bus.InboundMessage) {
+		mu.Lock()
+		running[msg.ChatID]++
+		overlap = overlap || running[msg.ChatID] > 1
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running[msg.ChatID]--
+		got[msg.ChatID] = append(got[msg.ChatID], msg.Content)
+		mu.Unlock()
+	})
+	for _, c := range []string{"1", "2", "3", "4", "5"} {
+		for _, chat := range []string{"a", "b", "c"} {
+			if err := d.Submit(inbound(chat, c)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if overlap {
+		t.Error("two messages of one conversation ran at once")
+	}
+	for _, chat := range []string{"a", "b", "c"} {
+		if s := got[chat]; len(s) != 5 || s[0] != "1" || s[1] != "2" || s[2] != "3" || s[3] != "4" || s[4] != "5" {
+			t.Errorf("chat %s answered %v, want 1 to 5 in order", chat, s)
+		}
+	}
+}
+
+func TestDispatcher_ConversationsRunConcurrently(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 2)
+	d := New(context.Background(), Options{Workers: 2}, func(_ context.Context, msg bus.InboundMessage) {
+		started <- msg.ChatID
+		<-release
+	})
+	d.Submit(inbound("a", "slow"))
+	d.Submit(inbound("b", "quick"))
+	for range 2 {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("a slow conversation held up the other")
+		}
+	}
+	close(release)
+	d.Shutdown(context.Background())
+}
+
+func TestDispatcher_Backpressure(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	d := New(context.Background(), Options{Workers: 1, QueueSize: 1, MaxPending: 2},
+		func(_ context.Context, _ bus.InboundMessage) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-release
+		})
+	d.Submit(inbound("a", "answering"))
+	<-started
+
+	if err := d.Submit(inbound("a", "waits")); err != nil {
+		t.Fatalf("first waiting message = %v", err)
+	}
+	if err := d.Submit(inbound("a", "over")); !errors.Is(err, ErrFull) {
+		t.Errorf("past the conversation queue = %v, want ErrFull", err)
+	}
+	if err := d.Submit(inbound("b", "waits")); err != nil {
+		t.Fatalf("other conversation = %v", err)
+	}
+	if err := d.Submit(inbound("c", "over")); !errors.Is(err, ErrFull) {
+		t.Errorf("past max pending = %v, want ErrFull", err)
+	}
+	if err := d.Add(inbound("c", "internal")); err != nil {
+		t.Errorf("Add past the limits = %v", err)
+	}
+	if n := d.Pending(); n != 3 {
+		t.Errorf("Pending = %d, want 3", n)
+	}
+
+	close(release)
+	if _, err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Submit(inbound("a", "late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("after Shutdown = %v, want ErrClosed", err)
+	}
+}
+
+func TestDispatcher_ShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	var cause error
+	d := New(context.Background(), Options{Workers: 1}, func(ctx context.Context, _ bus.InboundMessage) {
+		close(started)
+		<-ctx.Done()
+		cause = context.Cause(ctx)
+		close(canceled)
+	})
+	d.Submit(inbound("a", "stuck"))
+	d.Submit(inbound("a", "never"))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	dropped, err := d.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || len(dropped) != 1 || dropped[0].Content != "never" {
+		t.Errorf("Shutdown = %v, %v; want the waiting message dropped and DeadlineExceeded", dropped, err)
+	}
+	select {
+	case <-canceled:
+		if !errors.Is(cause, ErrClosed) {
+			t.Errorf("handler context cause = %v, want ErrClosed", cause)
+		}
+	case <-time.After(time.Second):
+		t.Error("handler context not canceled after the drain timeout")
+	}
+}
@@ -0,0 +1,150 @@
+// Package erasure describes the deletion of a user's stored data for a
+// data-subject request and keeps a receipt of each deletion in the workspace.
+// A receipt lists what was deleted or anonymized in each store without the
+// user's identifiers; the subject is kept only as a hash so that a later
+// request can be matched to its receipt.
+package erasure
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/requestid"
+)
+
+// Actions taken on a store.
+const (
+	ActionDeleted    = "deleted"
+	ActionAnonymized = "anonymized"
+)
+
+// Subject is the user whose data is deleted.
+type Subject struct {
+	Channel  string `json:"channel"`
+	SenderID string `json:"sender_id"`
+}
+
+// Hash returns the SHA-256 of "channel:sender_id" in hex.
+func (s Subject) Hash() string {
+	sum := sha256.Sum256([]byte(s.Channel + ":" + s.SenderID))
+	return hex.EncodeToString(sum[:])
+}
+
+// Valid reports whether both the channel and the sender are set.
+func (s Subject) Valid() bool {
+	return strings.TrimSpace(s.Channel) != "" && strings.TrimSpace(s.SenderID) != ""
+}
+
+// Item is what happened to one store.
+type Item struct {
+	Store  string `json:"store"`
+	Action string `json:"action"`
+	Count  int    `json:"count"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Receipt records one deletion.
+type Receipt struct {
+	ID          string    `json:"id"`
+	Time        time.Time `json:"time"`
+	SubjectHash string    `json:"subject_hash"`
+	RequestedBy string    `json:"requested_by"` // "user" or "admin"
+	Items       []Item    `json:"items"`
+	Kept        []string  `json:"kept,omitempty"` // data deliberately left in place, and why
+}
+
+// NewReceipt starts the receipt of a deletion for subject.
+func NewReceipt(subject Subject, requestedBy string) *Receipt {
+	return &Receipt{
+		ID:          requestid.New(),
+		Time:        time.Now().UTC(),
+		SubjectHash: subject.Hash(),
+		RequestedBy: requestedBy,
+		Items:       []Item{},
+	}
+}
+
+// Add records the outcome for store. A failed store is still listed, with
+// the count it reached before failing.
+func (r *Receipt) Add(store, action string, count int, err error) {
+	item := Item{Store: store, Action: action, Count: count}
+	if err != nil {
+		item.Error = err.Error()
+	}
+	r.Items = append(r.Items, item)
+}
+
+// Keep notes data that was left in place.
+func (r *Receipt) Keep(note string) {
+	r.Kept = append(r.Kept, note)
+}
+
+// Failed reports whether any store could not be cleared.
+func (r *Receipt) Failed() bool {
+	for _, item := range r.Items {
+		if item.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Text renders the receipt for a chat reply.
+func (r *Receipt) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Deletion receipt %s (%s)\n", r.ID, r.Time.Format(time.RFC3339))
+	for _, item := range r.Items {
+		if item.Error != "" {
+			fmt.Fprintf(&b, "- %s: failed after %d: %s\n", item.Store, item.Count, item.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "- %s: %d %s\n", item.Store, item.Count, item.Action)
+	}
+	for _, note := range r.Kept {
+		fmt.Fprintf(&b, "Kept: %s\n", note)
+	}
+	if r.Failed() {
+		b.WriteString("Some data could not be deleted; an operator has the receipt ID to follow up.")
+	} else {
+		b.WriteString("Keep the receipt ID as proof of the deletion.")
+	}
+	return b.String()
+}
+
+// Path returns the receipt log location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "erasure_receipts.jsonl")
+}
+
+var saveMu sync.Mutex
+
+// Save appends r to the receipt log at path.
+func Save(path string, r *Receipt) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
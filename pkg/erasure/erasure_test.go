@@ -0,0 +1,85 @@
+package erasure
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReceipt(t *testing.T) {
+	subject := Subject{Channel: "telegram", SenderID: "7"}
+	r := NewReceipt(subject, "user")
+	r.Add("usage", ActionAnonymized, 3, nil)
+	r.Add("transcripts", ActionDeleted, 1, errors.New("disk full"))
+	r.Keep("shared memory")
+
+	if r.ID == "" || r.SubjectHash != subject.Hash() || r.SubjectHash == (Subject{Channel: "telegram", SenderID: "8"}).Hash() {
+		t.Fatalf("receipt = %+v", r)
+	}
+	if !r.Failed() {
+		t.Error("receipt with a failed store should report failure")
+	}
+	text := r.Text()
+	for _, want := range []string{r.ID, "usage: 3 anonymized", "transcripts: failed after 1: disk full", "Kept: shared memory"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Text() = %q, missing %q", text, want)
+		}
+	}
+
+	path := Path(t.TempDir())
+	if err := Save(path, r); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), `"7"`) || !strings.Contains(string(data), r.SubjectHash) {
+		t.Errorf("saved receipt = %s", data)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	var got Subject
+	h := NewHandler([]string{"secret"}, func(subject Subject, requestedBy string) *Receipt {
+		got = subject
+		r := NewReceipt(subject, requestedBy)
+		r.Add("sessions", ActionDeleted, 2, nil)
+		return r
+	})
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, HTTPPath, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("wrong", `{}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status %d", w.Code)
+	}
+	if w := post("secret", `{"channel":"telegram"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing sender: status %d", w.Code)
+	}
+	w := post("secret", `{"channel":"telegram","sender_id":" 7 "}`)
+	var receipt Receipt
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &receipt) != nil ||
+		receipt.RequestedBy != "admin" || len(receipt.Items) != 1 {
+		t.Fatalf("POST: %d %s", w.Code, w.Body.String())
+	}
+	if got.SenderID != "7" {
+		t.Errorf("subject = %+v", got)
+	}
+
+	closed := NewHandler(nil, nil)
+	req := httptest.NewRequest(http.MethodPost, HTTPPath, strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	closed.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("API without configured token should be closed, got %d", rec.Code)
+	}
+}
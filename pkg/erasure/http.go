@@ -0,0 +1,68 @@
+package erasure
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/auth"
+)
+
+// HTTPPath is where the gateway mounts the erasure API.
+const HTTPPath = "/erasure"
+
+// maxRequestBytes bounds the body of an erasure request.
+const maxRequestBytes = 4 << 10
+
+// ForgetFunc deletes the data of subject and returns the receipt.
+type ForgetFunc func(subject Subject, requestedBy string) *Receipt
+
+// Handler serves the erasure API.
+type Handler struct {
+	tokens []string
+	forget ForgetFunc
+}
+
+// NewHandler returns the API guarded by tokens. The API is off without a
+// token.
+func NewHandler(tokens []string, forget ForgetFunc) *Handler {
+	return &Handler{tokens: tokens, forget: forget}
+}
+
+// ServeHTTP implements the erasure API. POST a JSON Subject to delete the
+// user's data; the response is the receipt, with status 500 if a store
+// could not be cleared. It requires "Authorization: Bearer <api_token>".
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var subject Subject
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&subject); err != nil {
+		http.Error(w, "body must be a JSON object with channel and sender_id", http.StatusBadRequest)
+		return
+	}
+	subject.Channel = strings.TrimSpace(subject.Channel)
+	subject.SenderID = strings.TrimSpace(subject.SenderID)
+	if !subject.Valid() {
+		http.Error(w, "channel and sender_id are required", http.StatusBadRequest)
+		return
+	}
+
+	receipt := h.forget(subject, "admin")
+	w.Header().Set("Content-Type", "application/json")
+	if receipt.Failed() {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(receipt)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	return auth.BearerTokenMatches(r, h.tokens...)
+}
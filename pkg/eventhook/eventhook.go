@@ -0,0 +1,239 @@
+// Package eventhook receives arbitrary JSON webhooks, such as CI results,
+// monitoring alerts, or another service's events, and posts an LLM
+// explanation of each delivery to the chat mapped to the hook.
+package eventhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// DefaultPath is the prefix hooks are mounted under when no path is
+// configured; a hook receives deliveries at DefaultPath/<name>.
+const DefaultPath = "/webhooks/events"
+
+// DefaultPrompt is used for hooks without their own prompt template.
+const DefaultPrompt = `A webhook from "{{.Hook}}" delivered the JSON event below. ` +
+	`Explain it for a team chat in two to four sentences: what happened, how serious it is, ` +
+	`and whether anyone needs to act. Include any link the event contains. Do not use any tools.
+
+{{.JSON}}`
+
+const (
+	maxPayloadBytes  = 1 << 20
+	maxJSONRunes     = 8000
+	summarizeTimeout = 2 * time.Minute
+	maxConcurrent    = 4
+)
+
+// Summarizer turns a rendered prompt into the text posted for a delivery.
+type Summarizer func(ctx context.Context, prompt string) (string, error)
+
+// PromptData is what a prompt template can reference. Payload is the
+// decoded JSON, so a template can pick fields like {{.Payload.status}}.
+type PromptData struct {
+	Hook    string
+	Payload any
+	JSON    string // indented, cut to 8000 characters
+}
+
+type hook struct {
+	config.EventWebhook
+	prompt *template.Template
+}
+
+// Handler is the http.Handler for the event webhooks.
+type Handler struct {
+	path      string
+	hooks     map[string]hook
+	summarize Summarizer
+	sem       chan struct{}
+
+	mu  sync.RWMutex
+	bus *bus.MessageBus
+}
+
+// NewHandler validates cfg and builds a handler. summarize may be nil, in
+// which case the event is posted as JSON.
+func NewHandler(cfg config.EventWebhooksConfig, summarize Summarizer) (*Handler, error) {
+	defaultPrompt := cfg.Prompt
+	if defaultPrompt == "" {
+		defaultPrompt = DefaultPrompt
+	}
+	h := &Handler{
+		path:      strings.TrimRight(cfg.Path, "/"),
+		hooks:     make(map[string]hook, len(cfg.Hooks)),
+		summarize: summarize,
+		sem:       make(chan struct{}, maxConcurrent),
+	}
+	if h.path == "" {
+		h.path = DefaultPath
+	}
+	for i, hc := range cfg.Hooks {
+		switch {
+		case hc.Name == "" || strings.Contains(hc.Name, "/"):
+			return nil, fmt.Errorf("event_webhooks: hook %d needs a name without slashes", i)
+		case hc.Secret == "":
+			return nil, fmt.Errorf("event_webhooks: %s needs a secret", hc.Name)
+		case hc.Channel == "" || hc.ChatID == "":
+			return nil, fmt.Errorf("event_webhooks: %s needs channel and chat_id", hc.Name)
+		}
+		if _, dup := h.hooks[hc.Name]; dup {
+			return nil, fmt.Errorf("event_webhooks: duplicate hook name %q", hc.Name)
+		}
+		text := hc.Prompt
+		if text == "" {
+			text = defaultPrompt
+		}
+		tmpl, err := template.New(hc.Name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("event_webhooks: %s prompt: %w", hc.Name, err)
+		}
+		h.hooks[hc.Name] = hook{EventWebhook: hc, prompt: tmpl}
+	}
+	return h, nil
+}
+
+// Path returns the prefix to mount the handler on; hooks live below it.
+func (h *Handler) Path() string { return h.path + "/" }
+
+// SetBus sets the message bus summaries are posted on.
+func (h *Handler) SetBus(msgBus *bus.MessageBus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.bus = msgBus
+}
+
+// ServeHTTP accepts POST <path>/<name> with a JSON body, authenticated by
+// the hook's secret.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	hk, ok := h.hooks[strings.TrimPrefix(r.URL.Path, h.path+"/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadBytes))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if !authorized(r, body, hk.Secret) {
+		logger.WarnCF("webhook", "Unauthorized event webhook delivery", map[string]any{"hook": hk.Name})
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "Body must be JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Senders expect a quick answer, so the LLM call happens after the
+	// delivery is acknowledged.
+	w.WriteHeader(http.StatusAccepted)
+	logger.InfoCF("webhook", "Event webhook received", map[string]any{"hook": hk.Name, "bytes": len(body)})
+	go h.deliver(hk, payload)
+}
+
+// authorized accepts the secret as a bearer token, an X-Webhook-Token
+// header, or a token query parameter, or as the key of a sha256=<hex> HMAC
+// of the body in X-Hub-Signature-256 or X-Signature-256.
+func authorized(r *http.Request, body []byte, secret string) bool {
+	for _, sig := range []string{r.Header.Get("X-Hub-Signature-256"), r.Header.Get("X-Signature-256")} {
+		if hexSig, ok := strings.CutPrefix(sig, "sha256="); ok {
+			got, err := hex.DecodeString(hexSig)
+			if err != nil {
+				return false
+			}
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(body)
+			return hmac.Equal(got, mac.Sum(nil))
+		}
+	}
+	token := r.Header.Get("X-Webhook-Token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+func (h *Handler) deliver(hk hook, payload any) {
+	h.sem <- struct{}{}
+	defer func() { <-h.sem }()
+
+	indented, _ := json.MarshalIndent(payload, "", "  ")
+	data := PromptData{Hook: hk.Name, Payload: payload, JSON: utils.Truncate(string(indented), maxJSONRunes)}
+	text := fmt.Sprintf("🔔 %s event\n%s", hk.Name, utils.Truncate(string(indented), 1500))
+	if h.summarize != nil {
+		summary, err := h.render(hk, data)
+		if err != nil {
+			logger.WarnCF("webhook", "Summary failed, posting the event", map[string]any{
+				"hook":  hk.Name,
+				"error": err.Error(),
+			})
+		} else {
+			text = summary
+		}
+	}
+
+	h.mu.RLock()
+	msgBus := h.bus
+	h.mu.RUnlock()
+	if msgBus == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := msgBus.PublishOutbound(ctx, bus.OutboundMessage{
+		Channel: hk.Channel,
+		ChatID:  hk.ChatID,
+		Content: text,
+	}); err != nil {
+		logger.WarnCF("webhook", "Failed to post event summary", map[string]any{
+			"hook":  hk.Name,
+			"error": err.Error(),
+		})
+	}
+}
+
+func (h *Handler) render(hk hook, data PromptData) (string, error) {
+	var buf bytes.Buffer
+	if err := hk.prompt.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), summarizeTimeout)
+	defer cancel()
+	text, err := h.summarize(ctx, buf.String())
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", errors.New("empty summary")
+	}
+	return text, nil
+}
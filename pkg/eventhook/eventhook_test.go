@@ -0,0 +1,148 @@
+package eventhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const alert = `{"status":"firing","alerts":[{"labels":{"alertname":"DiskFull"}}],"externalURL":"https://am.example.com"}`
+
+func newTestHandler(t *testing.T, summarize Summarizer, hooks ...config.EventWebhook) (*Handler, *bus.MessageBus) {
+	t.Helper()
+	h, err := NewHandler(config.EventWebhooksConfig{Hooks: hooks}, summarize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgBus := bus.NewMessageBus()
+	t.Cleanup(msgBus.Close)
+	h.SetBus(msgBus)
+	return h, msgBus
+}
+
+func post(h *Handler, target, body string, header map[string]string) int {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code
+}
+
+func nextOutbound(t *testing.T, msgBus *bus.MessageBus) bus.OutboundMessage {
+	t.Helper()
+	select {
+	case msg := <-msgBus.OutboundChan():
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing was posted")
+		return bus.OutboundMessage{}
+	}
+}
+
+var alerts = config.EventWebhook{Name: "alerts", Secret: "s3cret", Channel: "discord", ChatID: "ops"}
+
+func TestHandler_SummarizesWithPromptFields(t *testing.T) {
+	hk := alerts
+	hk.Prompt = "{{.Hook}} is {{.Payload.status}}: {{(index .Payload.alerts 0).labels.alertname}}"
+	var prompt string
+	h, msgBus := newTestHandler(t, func(_ context.Context, p string) (string, error) {
+		prompt = p
+		return "The disk is full.", nil
+	}, hk)
+
+	code := post(h, DefaultPath+"/alerts", alert, map[string]string{"Authorization": "Bearer s3cret"})
+	if code != http.StatusAccepted {
+		t.Fatalf("status %d", code)
+	}
+	msg := nextOutbound(t, msgBus)
+	if msg.Channel != "discord" || msg.ChatID != "ops" || msg.Content != "The disk is full." {
+		t.Errorf("posted %+v", msg)
+	}
+	if prompt != "alerts is firing: DiskFull" {
+		t.Errorf("prompt = %q", prompt)
+	}
+}
+
+func TestHandler_DefaultPromptAndFallback(t *testing.T) {
+	var prompt string
+	h, msgBus := newTestHandler(t, func(_ context.Context, p string) (string, error) {
+		prompt = p
+		return "", errors.New("provider down")
+	}, alerts)
+
+	if code := post(h, DefaultPath+"/alerts?token=s3cret", alert, nil); code != http.StatusAccepted {
+		t.Fatalf("status %d", code)
+	}
+	msg := nextOutbound(t, msgBus)
+	if !strings.HasPrefix(msg.Content, "🔔 alerts event\n") || !strings.Contains(msg.Content, `"status": "firing"`) {
+		t.Errorf("fallback = %q", msg.Content)
+	}
+	if !strings.Contains(prompt, `"alertname": "DiskFull"`) {
+		t.Errorf("default prompt lacks the indented event: %q", prompt)
+	}
+}
+
+func TestHandler_RejectsBadDeliveries(t *testing.T) {
+	h, _ := newTestHandler(t, nil, alerts)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(alert))
+	signed := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		header map[string]string
+		want   int
+	}{
+		{"hmac", "/alerts", alert, map[string]string{"X-Hub-Signature-256": signed}, http.StatusAccepted},
+		{"token header", "/alerts", alert, map[string]string{"X-Webhook-Token": "s3cret"}, http.StatusAccepted},
+		{"no secret", "/alerts", alert, nil, http.StatusForbidden},
+		{"wrong token", "/alerts", alert, map[string]string{"Authorization": "Bearer nope"}, http.StatusForbidden},
+		{"bad signature", "/alerts", alert, map[string]string{"X-Signature-256": "sha256=00"}, http.StatusForbidden},
+		{"unknown hook", "/ci", alert, map[string]string{"X-Webhook-Token": "s3cret"}, http.StatusNotFound},
+		{"not json", "/alerts", "status=firing", map[string]string{"X-Webhook-Token": "s3cret"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code := post(h, DefaultPath+tt.target, tt.body, tt.header); code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, DefaultPath+"/alerts", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d", w.Code)
+	}
+}
+
+func TestNewHandler_Validates(t *testing.T) {
+	bad := []config.EventWebhook{
+		{Secret: "s", Channel: "discord", ChatID: "1"},
+		{Name: "a/b", Secret: "s", Channel: "discord", ChatID: "1"},
+		{Name: "a", Channel: "discord", ChatID: "1"},
+		{Name: "a", Secret: "s", Channel: "discord"},
+		{Name: "a", Secret: "s", Channel: "discord", ChatID: "1", Prompt: "{{.Hook"},
+	}
+	for _, hk := range bad {
+		if _, err := NewHandler(config.EventWebhooksConfig{Hooks: []config.EventWebhook{hk}}, nil); err == nil {
+			t.Errorf("NewHandler accepted %+v", hk)
+		}
+	}
+	if _, err := NewHandler(config.EventWebhooksConfig{Hooks: []config.EventWebhook{alerts, alerts}}, nil); err == nil {
+		t.Error("duplicate hook names should be rejected")
+	}
+}
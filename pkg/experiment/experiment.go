@@ -0,0 +1,173 @@
+// Package experiment compares the variants of a rollout, such as a canary
+// model, prompt, or persona, against stable traffic: how many conversations
+// and turns each answered, how long its turns took, what they cost, and how
+// users rated its replies.
+package experiment
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/feedback"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// Arm is the figures of one variant.
+type Arm struct {
+	Variant       string   `json:"variant"`
+	Models        []string `json:"models"`
+	Conversations int      `json:"conversations"`
+	Turns         int      `json:"turns"`
+	Errors        int      `json:"errors"` // turns with a failed LLM call
+	Tokens        int      `json:"tokens"`
+	Cost          float64  `json:"cost_usd"`
+	// LLM time per turn, summed over the turn's calls
+	LatencyP50 time.Duration `json:"latency_p50"`
+	LatencyP95 time.Duration `json:"latency_p95"`
+	Up         int           `json:"thumbs_up"`
+	Down       int           `json:"thumbs_down"`
+}
+
+// CostPerTurn returns the mean cost of a turn in USD.
+func (a Arm) CostPerTurn() float64 {
+	if a.Turns == 0 {
+		return 0
+	}
+	return a.Cost / float64(a.Turns)
+}
+
+// Approval returns the share of ratings that are thumbs up, or -1 when the
+// variant has no ratings.
+func (a Arm) Approval() float64 {
+	if a.Up+a.Down == 0 {
+		return -1
+	}
+	return float64(a.Up) / float64(a.Up+a.Down)
+}
+
+type turn struct {
+	variant  string
+	duration time.Duration
+	failed   bool
+}
+
+type arm struct {
+	Arm
+	conversations map[string]bool
+	durations     []time.Duration
+}
+
+// Compare builds the arms of the variants found in records, stable first.
+// The LLM calls of a turn share its request ID; a rating counts for the
+// variant of the turn it rates, which has the same ID.
+func Compare(records []usage.Record, ratings []feedback.Record, pricing usage.Pricing) []Arm {
+	arms := make(map[string]*arm)
+	turns := make(map[string]*turn)
+	var order []*turn
+	for i, r := range records {
+		variant := r.Variant
+		if variant == "" {
+			variant = usage.VariantStable
+		}
+		a := arms[variant]
+		if a == nil {
+			a = &arm{Arm: Arm{Variant: variant}, conversations: make(map[string]bool)}
+			arms[variant] = a
+		}
+		if r.Model != "" && !slices.Contains(a.Models, r.Model) {
+			a.Models = append(a.Models, r.Model)
+		}
+		if r.ChatID != "" {
+			a.conversations[r.Channel+":"+r.ChatID] = true
+		}
+		a.Tokens += r.PromptTokens + r.CompletionTokens
+		a.Cost += pricing.Cost(r)
+
+		id := r.RequestID
+		if id == "" {
+			id = fmt.Sprintf("#%d", i)
+		}
+		t := turns[id]
+		if t == nil {
+			t = &turn{variant: variant}
+			turns[id] = t
+			order = append(order, t)
+			a.Turns++
+		}
+		t.duration += time.Duration(r.DurationMS) * time.Millisecond
+		if r.Error && !t.failed {
+			t.failed = true
+			a.Errors++
+		}
+	}
+	for _, t := range order {
+		a := arms[t.variant]
+		a.durations = append(a.durations, t.duration)
+	}
+	for _, rec := range ratings {
+		t := turns[rec.ID]
+		if t == nil {
+			continue
+		}
+		switch rec.Rating {
+		case feedback.RatingUp:
+			arms[t.variant].Up++
+		case feedback.RatingDown:
+			arms[t.variant].Down++
+		}
+	}
+
+	out := make([]Arm, 0, len(arms))
+	for _, a := range arms {
+		a.Conversations = len(a.conversations)
+		slices.Sort(a.durations)
+		a.LatencyP50 = percentile(a.durations, 50)
+		a.LatencyP95 = percentile(a.durations, 95)
+		slices.Sort(a.Models)
+		out = append(out, a.Arm)
+	}
+	slices.SortFunc(out, func(x, y Arm) int {
+		if (x.Variant == usage.VariantStable) != (y.Variant == usage.VariantStable) {
+			if x.Variant == usage.VariantStable {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(x.Variant, y.Variant)
+	})
+	return out
+}
+
+// percentile returns the p-th percentile of sorted, by the nearest rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (len(sorted)*p + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// WriteTable writes arms as an aligned table.
+func WriteTable(w io.Writer, arms []Arm) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VARIANT\tMODELS\tCONVERSATIONS\tTURNS\tERRORS\tP50\tP95\tTOKENS/TURN\tCOST/TURN\t👍\t👎\tAPPROVAL")
+	for _, a := range arms {
+		tokens := 0
+		if a.Turns > 0 {
+			tokens = a.Tokens / a.Turns
+		}
+		approval := "-"
+		if v := a.Approval(); v >= 0 {
+			approval = fmt.Sprintf("%.0f%%", v*100)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%d\t$%.4f\t%d\t%d\t%s\n",
+			a.Variant, strings.Join(a.Models, ","), a.Conversations, a.Turns, a.Errors,
+			a.LatencyP50.Round(time.Millisecond), a.LatencyP95.Round(time.Millisecond),
+			tokens, a.CostPerTurn(), a.Up, a.Down, approval)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,63 @@
+package experiment
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/feedback"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func TestCompare(t *testing.T) {
+	records := []usage.Record{
+		{RequestID: "r1", Channel: "telegram", ChatID: "a", Model: "gpt", Variant: "stable",
+			PromptTokens: 100, CompletionTokens: 50, DurationMS: 1000},
+		{RequestID: "r1", Channel: "telegram", ChatID: "a", Model: "gpt", Variant: "stable",
+			PromptTokens: 200, CompletionTokens: 50, DurationMS: 500},
+		{RequestID: "r2", Channel: "telegram", ChatID: "a", Model: "gpt", Variant: "stable",
+			PromptTokens: 100, CompletionTokens: 0, DurationMS: 300, Error: true},
+		{RequestID: "r2", Channel: "telegram", ChatID: "a", Model: "gpt", Variant: "stable",
+			PromptTokens: 100, CompletionTokens: 0, DurationMS: 300, Error: true},
+		{RequestID: "r3", Channel: "discord", ChatID: "b", Model: "claude", Variant: "new-prompt",
+			PromptTokens: 1000, CompletionTokens: 1000, DurationMS: 2000},
+	}
+	ratings := []feedback.Record{
+		{Turn: feedback.Turn{ID: "r1"}, Rating: feedback.RatingUp},
+		{Turn: feedback.Turn{ID: "r3"}, Rating: feedback.RatingDown},
+		{Turn: feedback.Turn{ID: "gone"}, Rating: feedback.RatingUp},
+	}
+	pricing := usage.Pricing{"claude": {Input: 3, Output: 15}}
+
+	arms := Compare(records, ratings, pricing)
+	if len(arms) != 2 || arms[0].Variant != usage.VariantStable || arms[1].Variant != "new-prompt" {
+		t.Fatalf("arms = %+v", arms)
+	}
+	stable, canary := arms[0], arms[1]
+	if stable.Turns != 2 || stable.Conversations != 1 || stable.Errors != 1 || stable.Tokens != 600 {
+		t.Errorf("stable = %+v", stable)
+	}
+	if stable.LatencyP50 != 600*time.Millisecond || stable.LatencyP95 != 1500*time.Millisecond {
+		t.Errorf("stable latency = %v / %v", stable.LatencyP50, stable.LatencyP95)
+	}
+	if stable.Up != 1 || stable.Down != 0 || stable.Approval() != 1 || stable.CostPerTurn() != 0 {
+		t.Errorf("stable feedback or cost = %+v", stable)
+	}
+	if canary.Turns != 1 || canary.Down != 1 || canary.Approval() != 0 || canary.CostPerTurn() != 0.018 {
+		t.Errorf("canary = %+v, cost/turn %v", canary, canary.CostPerTurn())
+	}
+
+	var out bytes.Buffer
+	if err := WriteTable(&out, arms); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[2], "new-prompt") || !strings.Contains(lines[2], "$0.0180") {
+		t.Errorf("table:\n%s", out.String())
+	}
+
+	if (Arm{}).Approval() != -1 {
+		t.Error("Approval without ratings should be -1")
+	}
+}
@@ -0,0 +1,165 @@
+// Package facts keeps the facts operators pin for a guild (a Discord server
+// or Slack workspace), such as server rules or project context. They are
+// added to the system prompt of every request from that guild.
+package facts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// DefaultMaxTokens is the prompt budget for the facts of one guild when the
+// config does not set one.
+const DefaultMaxTokens = 500
+
+// ErrUnknownFact means no fact of the guild has the given ID.
+var ErrUnknownFact = errors.New("no fact with that number")
+
+// Fact is one pinned fact.
+type Fact struct {
+	ID      int       `json:"id"`
+	Text    string    `json:"text"`
+	AddedBy string    `json:"added_by,omitempty"`
+	Added   time.Time `json:"added"`
+}
+
+type guildFacts struct {
+	NextID int    `json:"next_id"`
+	Facts  []Fact `json:"facts"`
+}
+
+// EstimateTokens approximates the prompt tokens of text with the same 2.5
+// characters per token heuristic the agent uses for its context budget.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text)*2 + 4) / 5
+}
+
+// GuildKey identifies a guild across channels.
+func GuildKey(channel, guildID string) string {
+	return channel + ":" + guildID
+}
+
+// Path returns the facts location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "facts.json")
+}
+
+// Store holds the facts of every guild. It is safe for concurrent use; a nil
+// *Store has no facts.
+type Store struct {
+	path string
+
+	mu     sync.Mutex
+	guilds map[string]*guildFacts
+}
+
+// NewStore loads the facts saved at path.
+func NewStore(path string) *Store {
+	s := &Store{path: path, guilds: make(map[string]*guildFacts)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &s.guilds); err != nil {
+			logger.WarnCF("facts", "Ignoring unreadable facts", map[string]any{
+				"path":  path,
+				"error": err.Error(),
+			})
+			s.guilds = make(map[string]*guildFacts)
+		}
+	}
+	return s
+}
+
+// List returns the facts of guild, oldest first.
+func (s *Store) List(guild string) []Fact {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g := s.guilds[guild]; g != nil {
+		return append([]Fact(nil), g.Facts...)
+	}
+	return nil
+}
+
+// Add pins text for guild. It fails when the facts of the guild would no
+// longer fit in maxTokens.
+func (s *Store) Add(guild, text, addedBy string, maxTokens int) (Fact, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Fact{}, errors.New("the fact is empty")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := s.guilds[guild]
+	if g == nil {
+		g = &guildFacts{NextID: 1}
+		s.guilds[guild] = g
+	}
+	used := EstimateTokens(text)
+	for _, f := range g.Facts {
+		used += EstimateTokens(f.Text)
+	}
+	if used > maxTokens {
+		return Fact{}, fmt.Errorf("the facts of this server would use about %d tokens, over the budget of %d; "+
+			"remove or shorten some first", used, maxTokens)
+	}
+	f := Fact{ID: g.NextID, Text: text, AddedBy: addedBy, Added: time.Now()}
+	g.NextID++
+	g.Facts = append(g.Facts, f)
+	return f, s.saveLocked()
+}
+
+// Remove deletes the fact with id from guild.
+func (s *Store) Remove(guild string, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := s.guilds[guild]
+	if g == nil {
+		return ErrUnknownFact
+	}
+	for i, f := range g.Facts {
+		if f.ID == id {
+			g.Facts = append(g.Facts[:i], g.Facts[i+1:]...)
+			return s.saveLocked()
+		}
+	}
+	return ErrUnknownFact
+}
+
+func (s *Store) saveLocked() error {
+	data, err := json.MarshalIndent(s.guilds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(s.path, data, 0o600)
+}
+
+// Prompt returns the system prompt section for facts, or "" when there are
+// none. Facts past maxTokens are left out, which only happens when the
+// budget was lowered after they were added.
+func Prompt(list []Fact, maxTokens int) string {
+	var b strings.Builder
+	used := 0
+	for _, f := range list {
+		used += EstimateTokens(f.Text)
+		if used > maxTokens {
+			break
+		}
+		b.WriteString("\n- " + f.Text)
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "## Server Facts\nThe operators of this server pinned these facts. " +
+		"Treat them as true and follow any rules they state." + b.String()
+}
@@ -0,0 +1,62 @@
+package facts
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore_AddRemovePersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "facts.json")
+	s := NewStore(path)
+	guild := GuildKey("discord", "g1")
+
+	first, err := s.Add(guild, "No spoilers outside #spoilers.", "discord:u1", 100)
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	second, _ := s.Add(guild, "The project is written in Go.", "discord:u1", 100)
+	if first.ID != 1 || second.ID != 2 {
+		t.Fatalf("IDs = %d, %d", first.ID, second.ID)
+	}
+	if err := s.Remove(guild, 1); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := s.Remove(guild, 1); !errors.Is(err, ErrUnknownFact) {
+		t.Fatalf("second Remove() error = %v", err)
+	}
+
+	reloaded := NewStore(path)
+	third, _ := reloaded.Add(guild, "Meetings are on Fridays.", "discord:u1", 100)
+	if third.ID != 3 {
+		t.Fatalf("ID after reload = %d, want 3 (IDs are not reused)", third.ID)
+	}
+	if got := reloaded.List(guild); len(got) != 2 || got[0].ID != 2 {
+		t.Fatalf("List() = %+v", got)
+	}
+	if got := reloaded.List(GuildKey("slack", "g1")); got != nil {
+		t.Fatalf("other guild sees facts: %+v", got)
+	}
+}
+
+func TestStore_Budget(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "facts.json"))
+	if _, err := s.Add("g", strings.Repeat("a", 40), "", 20); err != nil {
+		t.Fatalf("Add() within budget error = %v", err)
+	}
+	if _, err := s.Add("g", strings.Repeat("b", 20), "", 20); err == nil || !strings.Contains(err.Error(), "budget") {
+		t.Fatalf("Add() over budget error = %v", err)
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	if Prompt(nil, 100) != "" {
+		t.Fatal("no facts should give no prompt")
+	}
+	list := []Fact{{ID: 1, Text: "Be kind."}, {ID: 2, Text: strings.Repeat("x", 500)}}
+	got := Prompt(list, 50)
+	if !strings.Contains(got, "- Be kind.") || strings.Contains(got, "xxx") {
+		t.Fatalf("Prompt() = %q", got)
+	}
+}
@@ -0,0 +1,320 @@
+// Package feedback collects the ratings users give the bot's replies by
+// reacting with 👍 or 👎 or with the /feedback command, stored together with the prompt and the reply so
+// operators can build evaluation sets from real usage.
+//
+// Recent turns are remembered in memory until they are rated; each rating is
+// appended to a JSON Lines file in the workspace.
+package feedback
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Ratings.
+const (
+	RatingUp   = "up"
+	RatingDown = "down"
+)
+
+// maxTurns bounds the turns remembered for rating. Replies older than that
+// can no longer be rated.
+const maxTurns = 1000
+
+// ErrUnknownTurn means the rated reply is not among the remembered turns.
+var ErrUnknownTurn = errors.New("unknown or expired turn")
+
+// Turn is one prompt and the bot's reply to it.
+type Turn struct {
+	ID       string    `json:"turn_id"`
+	Time     time.Time `json:"time"`
+	Channel  string    `json:"channel"`
+	ChatID   string    `json:"chat_id"`
+	SenderID string    `json:"sender_id,omitempty"`
+	Prompt   string    `json:"prompt"`
+	Response string    `json:"response"`
+}
+
+// Record is a rating of a turn.
+type Record struct {
+	Turn
+	Rating  string    `json:"rating"`
+	RatedBy string    `json:"rated_by"`
+	RatedAt time.Time `json:"rated_at"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// Filter selects records. Zero fields match everything.
+type Filter struct {
+	Rating string
+	Since  time.Time // rated at or after
+}
+
+// RatingForEmoji returns the rating a reaction stands for, or "" when it is
+// not a feedback reaction. Skin tone modifiers are ignored.
+func RatingForEmoji(emoji string) string {
+	emoji = strings.NewReplacer("\U0001F3FB", "", "\U0001F3FC", "", "\U0001F3FD", "",
+		"\U0001F3FE", "", "\U0001F3FF", "", "\uFE0F", "").Replace(strings.Trim(emoji, ":"))
+	switch emoji {
+	case "👍", "+1", "thumbsup":
+		return RatingUp
+	case "👎", "-1", "thumbsdown":
+		return RatingDown
+	}
+	return ""
+}
+
+// Path returns the feedback location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "feedback.jsonl")
+}
+
+// Store remembers recent turns and saves their ratings. It is safe for
+// concurrent use; a nil *Store collects nothing.
+type Store struct {
+	path string
+	now  func() time.Time
+
+	mu    sync.Mutex
+	turns map[string]Turn
+	order []string // turn IDs, oldest first
+}
+
+// NewStore creates a store that saves ratings at path.
+func NewStore(path string) *Store {
+	return &Store{path: path, now: time.Now, turns: make(map[string]Turn)}
+}
+
+// Path returns where the store saves ratings.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Remember keeps t so that it can be rated later.
+func (s *Store) Remember(t Turn) {
+	if s == nil || t.ID == "" {
+		return
+	}
+	if t.Time.IsZero() {
+		t.Time = s.now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.turns[t.ID]; !ok {
+		s.order = append(s.order, t.ID)
+	}
+	s.turns[t.ID] = t
+	for len(s.order) > maxTurns {
+		delete(s.turns, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// Rate saves a rating of the turn id by the user by.
+func (s *Store) Rate(id, rating, by string) (Record, error) {
+	if s == nil {
+		return Record{}, ErrUnknownTurn
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.turns[id]
+	if !ok {
+		return Record{}, ErrUnknownTurn
+	}
+	return s.save(Record{Turn: t, Rating: rating, RatedBy: by})
+}
+
+// RateLatest saves a rating, with an optional comment, of the latest
+// remembered turn in a chat by the user by.
+func (s *Store) RateLatest(channel, chatID, rating, by, comment string) (Record, error) {
+	if s == nil {
+		return Record{}, ErrUnknownTurn
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.order) - 1; i >= 0; i-- {
+		if t := s.turns[s.order[i]]; t.Channel == channel && t.ChatID == chatID {
+			return s.save(Record{Turn: t, Rating: rating, RatedBy: by, Comment: comment})
+		}
+	}
+	return Record{}, ErrUnknownTurn
+}
+
+// save appends rec to the store's file. s.mu must be held.
+func (s *Store) save(rec Record) (Record, error) {
+	rec.RatedAt = s.now()
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return Record{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return Record{}, err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return Record{}, err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return Record{}, err
+	}
+	return rec, f.Close()
+}
+
+// ForgetUser removes what the store holds about senderID on channel: the
+// turns they prompted, remembered or rated, and the ratings they gave.
+// raters are the IDs their ratings were saved under. It returns the number
+// of turns and ratings removed.
+func (s *Store) ForgetUser(channel, senderID string, raters ...string) (int, error) {
+	if s == nil || senderID == "" {
+		return 0, nil
+	}
+	ratedBy := func(by string) bool {
+		return by == senderID || slices.Contains(raters, by)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if t := s.turns[id]; t.Channel == channel && t.SenderID == senderID {
+			delete(s.turns, id)
+			removed++
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+
+	n, err := fileutil.RewriteLines(s.path, 0o600, func(line []byte) ([]byte, bool) {
+		var rec Record
+		if json.Unmarshal(line, &rec) != nil || rec.Channel != channel {
+			return line, true
+		}
+		return line, rec.SenderID != senderID && !ratedBy(rec.RatedBy)
+	})
+	return removed + n, err
+}
+
+// ReadRecords reads the ratings saved at path that match f, oldest first.
+// When a user rated the same turn more than once only the latest rating is
+// kept.
+func ReadRecords(path string, f Filter) ([]Record, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	latest := make(map[string]int) // turn ID + rater → index in records
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			logger.WarnCF("feedback", "Skipping unreadable feedback record", map[string]any{"error": err.Error()})
+			continue
+		}
+		key := rec.ID + "\x00" + rec.RatedBy
+		if i, ok := latest[key]; ok {
+			records[i] = Record{} // superseded
+		}
+		latest[key] = len(records)
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	result := records[:0]
+	for _, rec := range records {
+		if rec.ID == "" ||
+			(f.Rating != "" && rec.Rating != f.Rating) ||
+			(!f.Since.IsZero() && rec.RatedAt.Before(f.Since)) {
+			continue
+		}
+		result = append(result, rec)
+	}
+	return result, nil
+}
+
+// WriteJSONL writes records as JSON Lines.
+func WriteJSONL(w io.Writer, records []Record) error {
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// datasetMessage is a message of a dataset example.
+type datasetMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// datasetExample is a rated turn in the chat format fine-tuning and
+// evaluation tools read.
+type datasetExample struct {
+	Messages []datasetMessage `json:"messages"`
+	Rating   string           `json:"rating"`
+	Comment  string           `json:"comment,omitempty"`
+	TurnID   string           `json:"turn_id"`
+}
+
+// WriteDataset writes records as JSON Lines of chat examples: the prompt as
+// the user message, the reply as the assistant message, and the rating.
+func WriteDataset(w io.Writer, records []Record) error {
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		ex := datasetExample{
+			Messages: []datasetMessage{
+				{Role: "user", Content: rec.Prompt},
+				{Role: "assistant", Content: rec.Response},
+			},
+			Rating:  rec.Rating,
+			Comment: rec.Comment,
+			TurnID:  rec.ID,
+		}
+		if err := enc.Encode(ex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes records as CSV with a header row.
+func WriteCSV(w io.Writer, records []Record) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"turn_id", "time", "channel", "chat_id", "sender_id", "prompt", "response",
+		"rating", "rated_by", "rated_at", "comment",
+	})
+	for _, rec := range records {
+		cw.Write([]string{
+			rec.ID, rec.Time.Format(time.RFC3339), rec.Channel, rec.ChatID, rec.SenderID,
+			rec.Prompt, rec.Response, rec.Rating, rec.RatedBy, rec.RatedAt.Format(time.RFC3339), rec.Comment,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,193 @@
+package feedback
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRatingForEmoji(t *testing.T) {
+	for emoji, want := range map[string]string{
+		"👍":          RatingUp,
+		"👍🏽":         RatingUp,
+		":thumbsup:": RatingUp,
+		"+1":         RatingUp,
+		"👎":          RatingDown,
+		":-1:":       RatingDown,
+		"❤️":         "",
+		"":           "",
+		"thumbs-meh": "",
+	} {
+		if got := RatingForEmoji(emoji); got != want {
+			t.Errorf("RatingForEmoji(%q) = %q, want %q", emoji, got, want)
+		}
+	}
+}
+
+func TestStore_RateAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feedback.jsonl")
+	s := NewStore(path)
+	s.Remember(Turn{ID: "t1", Channel: "discord", ChatID: "c1", Prompt: "hi", Response: "hello"})
+	s.Remember(Turn{ID: "t2", Channel: "discord", ChatID: "c1", Prompt: "2+2?", Response: "5"})
+
+	if _, err := s.Rate("missing", RatingUp, "u1"); err != ErrUnknownTurn {
+		t.Fatalf("Rate(missing) error = %v", err)
+	}
+	if _, err := s.Rate("t1", RatingUp, "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Rate("t2", RatingUp, "u1"); err != nil {
+		t.Fatal(err)
+	}
+	// A changed mind replaces the earlier rating of the same user.
+	rec, err := s.Rate("t2", RatingDown, "u1")
+	if err != nil || rec.Prompt != "2+2?" || rec.Response != "5" {
+		t.Fatalf("Rate(t2) = %+v, %v", rec, err)
+	}
+	if _, err := s.Rate("t2", RatingDown, "u2"); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := ReadRecords(path, Filter{})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("ReadRecords = %+v, %v", all, err)
+	}
+	down, _ := ReadRecords(path, Filter{Rating: RatingDown})
+	if len(down) != 2 || down[0].ID != "t2" || down[0].RatedBy != "u1" {
+		t.Errorf("down = %+v", down)
+	}
+	if later, _ := ReadRecords(path, Filter{Since: time.Now().Add(time.Hour)}); len(later) != 0 {
+		t.Errorf("since filter kept %+v", later)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, down); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 3 || rows[0][0] != "turn_id" || rows[1][7] != RatingDown {
+		t.Errorf("csv = %v, %v", rows, err)
+	}
+
+	buf.Reset()
+	if err := WriteJSONL(&buf, all); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 3 || !strings.Contains(buf.String(), `"turn_id":"t1"`) {
+		t.Errorf("jsonl = %s", buf.String())
+	}
+}
+
+func TestStore_ForgetsOldTurns(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "feedback.jsonl"))
+	for i := range maxTurns + 1 {
+		s.Remember(Turn{ID: fmt.Sprintf("t%d", i)})
+	}
+	if _, err := s.Rate("t0", RatingUp, "u1"); err != ErrUnknownTurn {
+		t.Errorf("oldest turn still rateable: %v", err)
+	}
+	if _, err := s.Rate(fmt.Sprintf("t%d", maxTurns), RatingUp, "u1"); err != nil {
+		t.Errorf("newest turn: %v", err)
+	}
+}
+
+func TestReadRecords_MissingFile(t *testing.T) {
+	records, err := ReadRecords(filepath.Join(t.TempDir(), "none.jsonl"), Filter{})
+	if err != nil || records != nil {
+		t.Errorf("ReadRecords = %v, %v", records, err)
+	}
+}
+
+func TestStore_ForgetUser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feedback.jsonl")
+	s := NewStore(path)
+	s.Remember(Turn{ID: "t1", Channel: "discord", SenderID: "u1", Prompt: "mine"})
+	s.Remember(Turn{ID: "t2", Channel: "discord", SenderID: "u2", Prompt: "theirs"})
+	s.Remember(Turn{ID: "t3", Channel: "discord", SenderID: "u1", Prompt: "unrated"})
+	s.Rate("t1", RatingUp, "u1")
+	s.Rate("t2", RatingDown, "discord:u1")
+	s.Rate("t2", RatingUp, "u3")
+
+	n, err := s.ForgetUser("discord", "u1", "discord:u1")
+	if err != nil || n != 4 {
+		t.Fatalf("ForgetUser = %d, %v", n, err)
+	}
+	left, _ := ReadRecords(path, Filter{})
+	if len(left) != 1 || left[0].RatedBy != "u3" {
+		t.Errorf("left = %+v", left)
+	}
+	if _, err := s.Rate("t3", RatingUp, "u3"); err != ErrUnknownTurn {
+		t.Errorf("forgotten turn still rateable: %v", err)
+	}
+}
+
+func TestStore_RateLatest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feedback.jsonl")
+	s := NewStore(path)
+	s.Remember(Turn{ID: "t1", Channel: "discord", ChatID: "c1", Prompt: "first", Response: "one"})
+	s.Remember(Turn{ID: "t2", Channel: "discord", ChatID: "c1", Prompt: "second", Response: "two"})
+	s.Remember(Turn{ID: "t3", Channel: "discord", ChatID: "c2", Prompt: "elsewhere", Response: "three"})
+
+	rec, err := s.RateLatest("discord", "c1", RatingDown, "u1", "wrong answer")
+	if err != nil || rec.ID != "t2" || rec.Comment != "wrong answer" {
+		t.Fatalf("RateLatest = %+v, %v", rec, err)
+	}
+	if _, err := s.RateLatest("telegram", "c1", RatingUp, "u1", ""); err != ErrUnknownTurn {
+		t.Errorf("RateLatest(no turns) error = %v", err)
+	}
+
+	records, _ := ReadRecords(path, Filter{})
+	var buf bytes.Buffer
+	if err := WriteDataset(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"messages":[{"role":"user","content":"second"},{"role":"assistant","content":"two"}],` +
+		`"rating":"down","comment":"wrong answer","turn_id":"t2"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("dataset = %s", buf.String())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feedback.jsonl")
+	s := NewStore(path)
+	s.Remember(Turn{ID: "t1", Channel: "discord", ChatID: "c1", Prompt: "hi", Response: "hello"})
+	s.Remember(Turn{ID: "t2", Channel: "discord", ChatID: "c1", Prompt: "2+2?", Response: "5"})
+	s.Rate("t1", RatingUp, "u1")
+	s.Rate("t2", RatingDown, "u1")
+	h := NewHandler([]string{"secret"}, path)
+
+	get := func(query, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, HTTPPath+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status %d", rec.Code)
+	}
+	if rec := get("?format=xml", "secret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad format: status %d", rec.Code)
+	}
+	rec := get("?rating=up&format=dataset", "secret")
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), "\n") != 1 ||
+		!strings.Contains(rec.Body.String(), `{"role":"assistant","content":"hello"}`) {
+		t.Errorf("dataset: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if rec := get("", "secret"); strings.Count(rec.Body.String(), "\n") != 2 {
+		t.Errorf("jsonl = %s", rec.Body.String())
+	}
+	if NewHandler(nil, path).authorized(httptest.NewRequest(http.MethodGet, HTTPPath, nil)) {
+		t.Error("the API should be off without a token")
+	}
+}
@@ -0,0 +1,88 @@
+package feedback
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/auth"
+)
+
+// HTTPPath is where the gateway mounts the feedback export API.
+const HTTPPath = "/feedback"
+
+// Handler serves the saved ratings for download.
+type Handler struct {
+	tokens []string
+	path   string
+}
+
+// NewHandler returns the API over the ratings saved at path. The API is off
+// without a token.
+func NewHandler(tokens []string, path string) *Handler {
+	return &Handler{tokens: tokens, path: path}
+}
+
+// ServeHTTP implements GET /feedback. The query takes rating (up or down;
+// default both), since (a YYYY-MM-DD local date or RFC 3339 time), and format:
+// jsonl for the records as saved (the default), dataset for chat examples
+// ready for fine-tuning or evaluation, or csv. It requires
+// "Authorization: Bearer <api_token>".
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	var filter Filter
+	switch rating := q.Get("rating"); rating {
+	case "", "all":
+	case RatingUp, RatingDown:
+		filter.Rating = rating
+	default:
+		http.Error(w, "rating must be up, down, or all", http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			since, err = time.ParseInLocation(time.DateOnly, v, time.Local)
+		}
+		if err != nil {
+			http.Error(w, "since must be a YYYY-MM-DD date or RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		filter.Since = since
+	}
+	var write func(io.Writer, []Record) error
+	contentType := "application/x-ndjson"
+	switch q.Get("format") {
+	case "", "jsonl":
+		write = WriteJSONL
+	case "dataset":
+		write = WriteDataset
+	case "csv":
+		write, contentType = WriteCSV, "text/csv"
+	default:
+		http.Error(w, "format must be jsonl, dataset, or csv", http.StatusBadRequest)
+		return
+	}
+
+	records, err := ReadRecords(h.path, filter)
+	if err != nil {
+		http.Error(w, "failed to read feedback: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	write(w, records)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	return auth.BearerTokenMatches(r, h.tokens...)
+}
@@ -0,0 +1,270 @@
+package feeds
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// DefaultDigestPrompt is used when feeds.digest.prompt is empty.
+const DefaultDigestPrompt = `Write a digest of these {{.Count}} new feed entries for a chat channel. ` +
+	`Start with the most important, group related entries, and give each a one-line summary ending with its link. ` +
+	`Do not use any tools.
+{{if .Omitted}}
+{{.Omitted}} more entries were left out; mention that at the end.
+{{end}}{{range .Entries}}
+---
+Feed: {{.Feed}}
+Title: {{.Title}}
+Link: {{.Link}}
+Published: {{.Published}}
+
+{{.Content}}
+{{end}}`
+
+const (
+	defaultDigestEntries = 30
+	digestContentRunes   = 1000
+	digestTimeout        = 5 * time.Minute
+	batchDigestTimeout   = 24 * time.Hour // for digests written through a batch API
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// DigestData is what a digest prompt template can reference.
+type DigestData struct {
+	Count   int
+	Omitted int
+	Entries []PromptData
+}
+
+// chat is where a digest is posted.
+type chat struct {
+	channel, chatID string
+}
+
+// digestItem is a pending entry and the feed it came from.
+type digestItem struct {
+	src   source
+	entry Entry
+}
+
+func (s *Service) digestEnabled() bool {
+	return s.cfg.Digest.Enabled
+}
+
+// digestChat reports whether the digest has a chat of its own.
+func (s *Service) digestChat() bool {
+	return s.cfg.Digest.Enabled && s.cfg.Digest.Channel != "" && s.cfg.Digest.ChatID != ""
+}
+
+func (s *Service) maxDigestEntries() int {
+	if s.cfg.Digest.MaxEntries > 0 {
+		return s.cfg.Digest.MaxEntries
+	}
+	return defaultDigestEntries
+}
+
+// buildDigest validates the digest settings and parses its prompt.
+func (s *Service) buildDigest() (*template.Template, error) {
+	d := s.cfg.Digest
+	if (d.Channel == "") != (d.ChatID == "") {
+		return nil, errors.New("feeds: digest needs both channel and chat_id, or neither")
+	}
+	if _, err := s.digestWeekday(); err != nil {
+		return nil, err
+	}
+	text := d.Prompt
+	if text == "" {
+		text = DefaultDigestPrompt
+	}
+	tmpl, err := template.New("digest").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("feeds: digest prompt: %w", err)
+	}
+	return tmpl, nil
+}
+
+func (s *Service) digestWeekday() (time.Weekday, error) {
+	if s.cfg.Digest.Weekday == "" {
+		return time.Monday, nil
+	}
+	wd, ok := weekdays[strings.ToLower(s.cfg.Digest.Weekday)]
+	if !ok {
+		return 0, fmt.Errorf("feeds: unknown digest weekday %q", s.cfg.Digest.Weekday)
+	}
+	return wd, nil
+}
+
+// NextDigest returns the first scheduled digest strictly after now.
+func (s *Service) NextDigest(now time.Time) time.Time {
+	hour := min(max(s.cfg.Digest.Hour, 0), 23)
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+
+	if strings.EqualFold(s.cfg.Digest.Schedule, "weekly") {
+		wd, _ := s.digestWeekday()
+		next = next.AddDate(0, 0, (int(wd)-int(next.Weekday())+7)%7)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	}
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// queueLocked keeps fresh entries, newest first as fetched, for the next
+// digest. A feed keeps at most one digest's worth, dropping the oldest, and
+// only as much content as the digest prompt uses.
+func (s *Service) queueLocked(st *feedState, fresh []Entry) {
+	for i := len(fresh) - 1; i >= 0; i-- {
+		e := fresh[i]
+		e.Content = utils.Truncate(e.Content, digestContentRunes)
+		st.Pending = append(st.Pending, e)
+	}
+	if over := len(st.Pending) - s.maxDigestEntries(); over > 0 {
+		st.Pending = st.Pending[over:]
+	}
+}
+
+// digestIfDue posts the digests once their scheduled time has passed. While
+// paused the entries wait for the first check after resuming. Batched
+// digests are written in the background so polling goes on meanwhile.
+func (s *Service) digestIfDue() {
+	s.mu.Lock()
+	due := s.digestEnabled() && !s.paused && !s.now().Before(s.nextDigest)
+	if due {
+		s.nextDigest = s.NextDigest(s.now())
+	}
+	s.mu.Unlock()
+	if !due {
+		return
+	}
+	if s.cfg.Digest.Batch {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), batchDigestTimeout)
+			defer cancel()
+			s.SendDigests(ctx)
+		}()
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), digestTimeout)
+	defer cancel()
+	s.SendDigests(ctx)
+}
+
+// SendDigests posts the pending entries of every feed as one digest per
+// chat and clears them. Chats without new entries get nothing.
+func (s *Service) SendDigests(ctx context.Context) {
+	s.mu.Lock()
+	var order []chat
+	groups := make(map[chat][]digestItem)
+	for _, src := range s.sources {
+		st := s.state[src.Name]
+		if st == nil || len(st.Pending) == 0 {
+			continue
+		}
+		dest := chat{src.Channel, src.ChatID}
+		if s.digestChat() {
+			dest = chat{s.cfg.Digest.Channel, s.cfg.Digest.ChatID}
+		}
+		if _, ok := groups[dest]; !ok {
+			order = append(order, dest)
+		}
+		for _, e := range st.Pending {
+			groups[dest] = append(groups[dest], digestItem{src: src, entry: e})
+		}
+		st.Pending = nil
+	}
+	if len(order) > 0 {
+		s.saveStateLocked()
+	}
+	s.mu.Unlock()
+
+	for _, dest := range order {
+		s.publish(ctx, bus.OutboundMessage{
+			Channel: dest.channel,
+			ChatID:  dest.chatID,
+			Content: s.renderDigest(ctx, groups[dest]),
+		})
+	}
+}
+
+func (s *Service) renderDigest(ctx context.Context, items []digestItem) string {
+	omitted := 0
+	if over := len(items) - s.maxDigestEntries(); over > 0 {
+		omitted = over
+		items = items[:s.maxDigestEntries()]
+	}
+	summarize := s.summarize
+	if s.digestSummarize != nil {
+		summarize = s.digestSummarize
+	}
+	if summarize == nil {
+		return digestFallback(items, omitted)
+	}
+
+	data := DigestData{Count: len(items), Omitted: omitted}
+	for _, it := range items {
+		pd := PromptData{
+			Feed:    it.src.Name,
+			Title:   it.entry.Title,
+			Link:    it.entry.Link,
+			Content: it.entry.Content,
+		}
+		if !it.entry.Published.IsZero() {
+			pd.Published = it.entry.Published.Format(time.RFC1123)
+		}
+		data.Entries = append(data.Entries, pd)
+	}
+	var buf bytes.Buffer
+	err := s.digestPrompt.Execute(&buf, data)
+	var text string
+	if err == nil {
+		text, err = summarize(ctx, buf.String())
+	}
+	if err == nil && strings.TrimSpace(text) == "" {
+		err = errors.New("empty summary")
+	}
+	if err != nil {
+		logger.WarnCF("feeds", "Digest summary failed, posting titles and links", map[string]any{
+			"entries": len(items),
+			"error":   err.Error(),
+		})
+		return digestFallback(items, omitted)
+	}
+	return text
+}
+
+func digestFallback(items []digestItem, omitted int) string {
+	var b strings.Builder
+	b.WriteString("📰 Feed digest")
+	for _, it := range items {
+		fmt.Fprintf(&b, "\n• %s: %s", it.src.Name, it.entry.Title)
+		if it.entry.Link != "" {
+			fmt.Fprintf(&b, "\n  %s", it.entry.Link)
+		}
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&b, "\n…and %d more", omitted)
+	}
+	return b.String()
+}
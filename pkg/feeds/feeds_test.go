@@ -0,0 +1,423 @@
+package feeds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const rssFeed = `<?xml version="1.0"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel><title>Blog</title>
+<item><title>Second &amp; newest</title><link>https://example.com/2</link><guid>post-2</guid>
+<description>short</description><content:encoded><![CDATA[<p>Full <b>body</b></p>]]></content:encoded>
+<pubDate>Tue, 03 Mar 2026 10:00:00 +0000</pubDate></item>
+<item><title>First</title><link>https://example.com/1</link><description>&lt;p&gt;Hello&lt;/p&gt;</description></item>
+</channel></rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Releases</title>
+<entry><id>tag:example.com,2026:v2</id><title>v2.0</title>
+<link rel="self" href="https://example.com/self"/><link href="https://example.com/v2"/>
+<summary>Summary</summary><content type="html">&lt;p&gt;Notes&lt;/p&gt;</content>
+<updated>2026-03-03T10:00:00Z</updated></entry>
+</feed>`
+
+func TestParse(t *testing.T) {
+	entries, err := Parse([]byte(rssFeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d rss entries", len(entries))
+	}
+	e := entries[0]
+	if e.ID != "post-2" || e.Title != "Second & newest" || e.Content != "Full body" || e.Published.Day() != 3 {
+		t.Errorf("rss entry = %+v", e)
+	}
+	if entries[1].ID != "https://example.com/1" || entries[1].Content != "Hello" {
+		t.Errorf("rss entry without guid = %+v", entries[1])
+	}
+
+	entries, err = Parse([]byte(atomFeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Link != "https://example.com/v2" || entries[0].Content != "Notes" {
+		t.Fatalf("atom entries = %+v", entries)
+	}
+
+	if _, err := Parse([]byte(`<html><body>nope</body></html>`)); err == nil {
+		t.Error("HTML page should not parse as a feed")
+	}
+}
+
+type feedServer struct {
+	mu    sync.Mutex
+	items []string
+}
+
+func (f *feedServer) add(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items = append([]string{id}, f.items...)
+}
+
+func (f *feedServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var b strings.Builder
+	b.WriteString(`<rss><channel>`)
+	for _, id := range f.items {
+		fmt.Fprintf(&b, `<item><guid>%s</guid><title>Post %s</title><link>https://example.com/%s</link></item>`, id, id, id)
+	}
+	b.WriteString(`</channel></rss>`)
+	w.Write([]byte(b.String()))
+}
+
+func newTestService(t *testing.T, url string, summarize Summarizer) (*Service, *bus.MessageBus) {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	t.Cleanup(msgBus.Close)
+	svc := NewService(config.FeedsConfig{
+		Enabled:  true,
+		MaxItems: 2,
+		Sources: []config.FeedSource{{
+			Name: "blog", URL: url, Channel: "telegram", ChatID: "42",
+			Prompt: "{{.Feed}}: {{.Title}} <{{.Link}}>",
+		}},
+	}, t.TempDir(), summarize)
+	svc.SetBus(msgBus)
+	sources, err := svc.buildSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.sources = sources
+	return svc, msgBus
+}
+
+func drain(msgBus *bus.MessageBus) []string {
+	var out []string
+	for {
+		select {
+		case msg := <-msgBus.OutboundChan():
+			out = append(out, msg.Channel+"/"+msg.ChatID+": "+msg.Content)
+		case <-time.After(50 * time.Millisecond):
+			return out
+		}
+	}
+}
+
+func TestService_PostsOnlyNewEntries(t *testing.T) {
+	feed := &feedServer{}
+	feed.add("1")
+	srv := httptest.NewServer(feed)
+	defer srv.Close()
+
+	var prompts []string
+	svc, msgBus := newTestService(t, srv.URL, func(_ context.Context, prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return "summary of " + prompt, nil
+	})
+	ctx := context.Background()
+
+	if err := svc.poll(ctx, svc.sources[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got := drain(msgBus); len(got) != 0 {
+		t.Fatalf("first poll should only record existing entries, posted %v", got)
+	}
+
+	feed.add("2")
+	feed.add("3")
+	feed.add("4")
+	if err := svc.poll(ctx, svc.sources[0]); err != nil {
+		t.Fatal(err)
+	}
+	got := drain(msgBus)
+	want := []string{
+		"telegram/42: summary of blog: Post 3 <https://example.com/3>",
+		"telegram/42: summary of blog: Post 4 <https://example.com/4>",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("posted %q, want %q (max_items, oldest first)", got, want)
+	}
+
+	if err := svc.poll(ctx, svc.sources[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got := drain(msgBus); len(got) != 0 {
+		t.Fatalf("unchanged feed posted %v", got)
+	}
+}
+
+func TestService_StateSurvivesRestart(t *testing.T) {
+	feed := &feedServer{}
+	feed.add("1")
+	srv := httptest.NewServer(feed)
+	defer srv.Close()
+
+	svc, _ := newTestService(t, srv.URL, nil)
+	svc.poll(context.Background(), svc.sources[0])
+
+	restarted := NewService(svc.cfg, "", nil)
+	restarted.statePath = svc.statePath
+	restarted.loadState()
+	st := restarted.state["blog"]
+	if st == nil || len(st.Seen) != 1 || st.LastPoll.IsZero() {
+		t.Fatalf("state after restart = %+v", st)
+	}
+}
+
+func TestService_FallsBackToTitleAndLink(t *testing.T) {
+	feed := &feedServer{}
+	srv := httptest.NewServer(feed)
+	defer srv.Close()
+
+	svc, msgBus := newTestService(t, srv.URL, func(context.Context, string) (string, error) {
+		return "", errors.New("provider down")
+	})
+	svc.poll(context.Background(), svc.sources[0])
+	feed.add("1")
+	svc.poll(context.Background(), svc.sources[0])
+
+	got := drain(msgBus)
+	if len(got) != 1 || got[0] != "telegram/42: 📰 Post 1\nhttps://example.com/1" {
+		t.Fatalf("posted %q", got)
+	}
+}
+
+func TestService_FailedFirstFetchDoesNotFlood(t *testing.T) {
+	feed := &feedServer{}
+	feed.add("1")
+	up := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		feed.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	svc, msgBus := newTestService(t, srv.URL, nil)
+	if err := svc.poll(context.Background(), svc.sources[0]); err == nil {
+		t.Fatal("expected fetch error")
+	}
+	up = true
+	svc.poll(context.Background(), svc.sources[0])
+	if got := drain(msgBus); len(got) != 0 {
+		t.Fatalf("first successful poll posted %v", got)
+	}
+}
+
+func TestService_StartValidates(t *testing.T) {
+	tests := []config.FeedSource{
+		{Name: "a", Channel: "telegram", ChatID: "1"},
+		{Name: "a", URL: "http://x"},
+		{Name: "a", URL: "http://x", Channel: "telegram", ChatID: "1", Prompt: "{{.Title"},
+	}
+	for _, src := range tests {
+		svc := NewService(config.FeedsConfig{Enabled: true, Sources: []config.FeedSource{src}}, t.TempDir(), nil)
+		if err := svc.Start(); err == nil {
+			svc.Stop()
+			t.Errorf("Start accepted %+v", src)
+		}
+	}
+
+	dup := config.FeedSource{Name: "a", URL: "http://x", Channel: "telegram", ChatID: "1"}
+	svc := NewService(config.FeedsConfig{Enabled: true, Sources: []config.FeedSource{dup, dup}}, t.TempDir(), nil)
+	if err := svc.Start(); err == nil {
+		svc.Stop()
+		t.Error("duplicate feed names should be rejected")
+	}
+}
+
+func TestService_PausedSkipsPolls(t *testing.T) {
+	var hits int
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+		w.Write([]byte(`<rss><channel></channel></rss>`))
+	}))
+	defer srv.Close()
+
+	svc, _ := newTestService(t, srv.URL, nil)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	svc.SetPaused(true)
+	svc.pollDue(stop)
+	svc.SetPaused(false)
+	svc.pollDue(stop)
+	svc.pollDue(stop) // not due again until the interval passes
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits != 1 {
+		t.Errorf("feed fetched %d times, want 1", hits)
+	}
+}
+
+func TestService_DigestCollectsEntriesUntilScheduled(t *testing.T) {
+	blog, releases := &feedServer{}, &feedServer{}
+	blog.add("1")
+	blogSrv, relSrv := httptest.NewServer(blog), httptest.NewServer(releases)
+	defer blogSrv.Close()
+	defer relSrv.Close()
+
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	var prompts []string
+	svc := NewService(config.FeedsConfig{
+		Enabled: true,
+		Sources: []config.FeedSource{
+			{Name: "blog", URL: blogSrv.URL},
+			{Name: "releases", URL: relSrv.URL},
+		},
+		Digest: config.FeedDigestConfig{
+			Enabled: true, Hour: 9, Channel: "discord", ChatID: "news", MaxEntries: 3,
+			Prompt: "{{.Count}}/{{.Omitted}}{{range .Entries}} {{.Feed}}:{{.Title}}{{end}}",
+		},
+	}, t.TempDir(), func(_ context.Context, prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return "digest: " + prompt, nil
+	})
+	now := time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	svc.SetBus(msgBus)
+	if err := svc.Start(); err != nil {
+		t.Fatal(err)
+	}
+	svc.Stop()
+	if want := time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC); !svc.nextDigest.Equal(want) {
+		t.Fatalf("next digest at %v, want %v", svc.nextDigest, want)
+	}
+
+	ctx := context.Background()
+	for _, src := range svc.sources {
+		svc.poll(ctx, src)
+	}
+	blog.add("2")
+	blog.add("3")
+	releases.add("v1")
+	releases.add("v2")
+	for _, src := range svc.sources {
+		svc.poll(ctx, src)
+	}
+	svc.digestIfDue()
+	if got := drain(msgBus); len(got) != 0 {
+		t.Fatalf("posted before the digest was due: %v", got)
+	}
+
+	// A restart keeps the pending entries
+	restarted := NewService(svc.cfg, "", svc.summarize)
+	restarted.statePath = svc.statePath
+	restarted.now = svc.now
+	restarted.SetBus(msgBus)
+	restarted.loadState()
+	restarted.sources, _ = restarted.buildSources()
+	restarted.digestPrompt, _ = restarted.buildDigest()
+	restarted.nextDigest = svc.nextDigest
+
+	now = now.Add(time.Hour)
+	restarted.digestIfDue()
+	got := drain(msgBus)
+	if len(got) != 1 || got[0] != "discord/news: digest: 3/1 blog:Post 2 blog:Post 3 releases:Post v1" {
+		t.Fatalf("digest = %q", got)
+	}
+	if !restarted.nextDigest.Equal(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("next digest at %v", restarted.nextDigest)
+	}
+
+	restarted.SendDigests(ctx)
+	if got := drain(msgBus); len(got) != 0 {
+		t.Errorf("sent entries were posted again: %v", got)
+	}
+}
+
+func TestService_DigestPerFeedChatAndFallback(t *testing.T) {
+	feed := &feedServer{}
+	srv := httptest.NewServer(feed)
+	defer srv.Close()
+
+	svc, msgBus := newTestService(t, srv.URL, func(context.Context, string) (string, error) {
+		return "", errors.New("provider down")
+	})
+	svc.cfg.Digest = config.FeedDigestConfig{Enabled: true}
+	svc.digestPrompt, _ = svc.buildDigest()
+
+	svc.poll(context.Background(), svc.sources[0])
+	feed.add("1")
+	svc.poll(context.Background(), svc.sources[0])
+	svc.SendDigests(context.Background())
+
+	got := drain(msgBus)
+	if len(got) != 1 || got[0] != "telegram/42: 📰 Feed digest\n• blog: Post 1\n  https://example.com/1" {
+		t.Fatalf("digest = %q", got)
+	}
+}
+
+func TestService_BatchedDigest(t *testing.T) {
+	feed := &feedServer{}
+	srv := httptest.NewServer(feed)
+	defer srv.Close()
+
+	svc, msgBus := newTestService(t, srv.URL, func(context.Context, string) (string, error) {
+		return "", errors.New("the entry summarizer wrote the digest")
+	})
+	svc.cfg.Digest = config.FeedDigestConfig{Enabled: true, Batch: true}
+	svc.digestPrompt, _ = svc.buildDigest()
+	release := make(chan struct{})
+	svc.SetDigestSummarizer(func(ctx context.Context, _ string) (string, error) {
+		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) < time.Hour {
+			return "", errors.New("no time for a batch")
+		}
+		<-release
+		return "batched digest", nil
+	})
+
+	svc.poll(context.Background(), svc.sources[0])
+	feed.add("1")
+	svc.poll(context.Background(), svc.sources[0])
+	svc.digestIfDue() // returns while the digest is written
+	close(release)
+
+	got := drain(msgBus)
+	if len(got) != 1 || got[0] != "telegram/42: batched digest" {
+		t.Fatalf("digest = %q", got)
+	}
+}
+
+func TestService_DigestValidates(t *testing.T) {
+	noChat := config.FeedSource{Name: "a", URL: "http://x"}
+	withChat := config.FeedSource{Name: "a", URL: "http://x", Channel: "telegram", ChatID: "1"}
+	tests := []struct {
+		src    config.FeedSource
+		digest config.FeedDigestConfig
+	}{
+		{noChat, config.FeedDigestConfig{Enabled: true}},
+		{withChat, config.FeedDigestConfig{Enabled: true, Channel: "telegram"}},
+		{withChat, config.FeedDigestConfig{Enabled: true, Weekday: "someday"}},
+		{withChat, config.FeedDigestConfig{Enabled: true, Prompt: "{{.Count"}},
+	}
+	for _, tt := range tests {
+		cfg := config.FeedsConfig{Enabled: true, Sources: []config.FeedSource{tt.src}, Digest: tt.digest}
+		svc := NewService(cfg, t.TempDir(), nil)
+		if err := svc.Start(); err == nil {
+			svc.Stop()
+			t.Errorf("Start accepted %+v with digest %+v", tt.src, tt.digest)
+		}
+	}
+}
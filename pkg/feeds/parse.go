@@ -0,0 +1,177 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Entry is one feed item, normalised across RSS and Atom.
+type Entry struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Link      string    `json:"link,omitempty"`
+	Content   string    `json:"content,omitempty"` // plain text, HTML removed
+	Published time.Time `json:"published,omitzero"`
+}
+
+type rssDoc struct {
+	Items []struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		GUID        string `xml:"guid"`
+		Description string `xml:"description"`
+		Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+		PubDate     string `xml:"pubDate"`
+	} `xml:"channel>item"`
+}
+
+type atomDoc struct {
+	Entries []struct {
+		ID    string `xml:"id"`
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Summary   string `xml:"summary"`
+		Content   string `xml:"content"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+// Parse reads an RSS 2.0 or Atom document. Entries are returned in document
+// order, which for both formats is normally newest first.
+func Parse(data []byte) ([]Entry, error) {
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
+	}
+	switch root {
+	case "rss":
+		return parseRSS(data)
+	case "feed":
+		return parseAtom(data)
+	default:
+		return nil, errors.New("not an RSS or Atom feed: root element <" + root + ">")
+	}
+}
+
+func rootElement(data []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func parseRSS(data []byte) ([]Entry, error) {
+	var doc rssDoc
+	if err := unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(doc.Items))
+	for _, it := range doc.Items {
+		content := it.Encoded
+		if content == "" {
+			content = it.Description
+		}
+		e := Entry{
+			ID:        firstNonEmpty(it.GUID, it.Link, it.Title),
+			Title:     plainText(it.Title),
+			Link:      strings.TrimSpace(it.Link),
+			Content:   plainText(content),
+			Published: parseTime(it.PubDate),
+		}
+		if e.ID != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func parseAtom(data []byte) ([]Entry, error) {
+	var doc atomDoc
+	if err := unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(doc.Entries))
+	for _, it := range doc.Entries {
+		var link string
+		for _, l := range it.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = l.Href
+				break
+			}
+		}
+		e := Entry{
+			ID:        firstNonEmpty(it.ID, link, it.Title),
+			Title:     plainText(it.Title),
+			Link:      strings.TrimSpace(link),
+			Content:   plainText(firstNonEmpty(it.Content, it.Summary)),
+			Published: parseTime(firstNonEmpty(it.Published, it.Updated)),
+		}
+		if e.ID != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func unmarshal(data []byte, v any) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	return dec.Decode(v)
+}
+
+var timeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+}
+
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+var (
+	tagRe   = regexp.MustCompile(`(?s)<[^>]*>`)
+	spaceRe = regexp.MustCompile(`\s+`)
+)
+
+// plainText strips markup so the prompt carries text, not HTML.
+func plainText(s string) string {
+	s = tagRe.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(spaceRe.ReplaceAllString(s, " "))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,436 @@
+// Package feeds watches RSS and Atom feeds and posts an LLM summary of each
+// new entry to a chat.
+package feeds
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// DefaultPrompt is used for feeds without their own prompt template.
+const DefaultPrompt = `Summarize this entry from the "{{.Feed}}" feed in two or three sentences for a chat channel. ` +
+	`Start with the title in bold and end with the link. Do not use any tools.
+
+Title: {{.Title}}
+Link: {{.Link}}
+Published: {{.Published}}
+
+{{.Content}}`
+
+const (
+	defaultInterval  = 30 * time.Minute
+	defaultMaxItems  = 5
+	maxContentRunes  = 4000
+	maxFeedBytes     = 5 << 20
+	fetchTimeout     = 30 * time.Second
+	summarizeTimeout = 2 * time.Minute
+	checkEvery       = time.Minute
+	userAgent        = "picoclaw-feeds/1.0"
+)
+
+// Summarizer turns a rendered prompt into the text posted for an entry.
+type Summarizer func(ctx context.Context, prompt string) (string, error)
+
+// PromptData is what a prompt template can reference.
+type PromptData struct {
+	Feed      string
+	Title     string
+	Link      string
+	Content   string
+	Published string
+}
+
+type feedState struct {
+	Seen     []string  `json:"seen"`
+	LastPoll time.Time `json:"last_poll"`
+	Pending  []Entry   `json:"pending,omitempty"` // waiting for the digest, oldest first
+}
+
+type source struct {
+	config.FeedSource
+	interval time.Duration
+	prompt   *template.Template
+}
+
+// Service polls the configured feeds on their intervals.
+type Service struct {
+	cfg       config.FeedsConfig
+	statePath string
+	summarize Summarizer
+	// digestSummarize writes digests when set, in place of summarize
+	digestSummarize Summarizer
+	client          *http.Client
+	now             func() time.Time
+
+	mu           sync.Mutex
+	bus          *bus.MessageBus
+	sources      []source
+	state        map[string]*feedState
+	digestPrompt *template.Template
+	nextDigest   time.Time
+	paused       bool
+	stopChan     chan struct{}
+}
+
+// StatePath returns where seen entries are recorded for a workspace.
+func StatePath(workspace string) string {
+	return filepath.Join(workspace, "state", "feeds.json")
+}
+
+// NewService creates a feed watcher. summarize may be nil, in which case
+// entries are posted as title and link.
+func NewService(cfg config.FeedsConfig, workspace string, summarize Summarizer) *Service {
+	return &Service{
+		cfg:       cfg,
+		statePath: StatePath(workspace),
+		summarize: summarize,
+		client:    &http.Client{Timeout: fetchTimeout},
+		now:       time.Now,
+		state:     make(map[string]*feedState),
+	}
+}
+
+// SetDigestSummarizer sets a summarizer for digests alone, such as one that
+// may answer through a batch API.
+func (s *Service) SetDigestSummarizer(summarize Summarizer) {
+	s.digestSummarize = summarize
+}
+
+// SetBus sets the message bus summaries are posted on.
+func (s *Service) SetBus(msgBus *bus.MessageBus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bus = msgBus
+}
+
+// SetPaused skips polls without stopping the service. Entries published
+// while paused are posted on the first poll after resuming.
+func (s *Service) SetPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+}
+
+// Start validates the feeds and begins polling. It returns an error for a
+// feed that is missing its URL or destination, for a bad prompt template,
+// or for bad digest settings.
+func (s *Service) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.cfg.Enabled || s.stopChan != nil {
+		return nil
+	}
+	sources, err := s.buildSources()
+	if err != nil {
+		return err
+	}
+	if s.digestEnabled() {
+		if s.digestPrompt, err = s.buildDigest(); err != nil {
+			return err
+		}
+		s.nextDigest = s.NextDigest(s.now())
+	}
+	s.sources = sources
+	s.loadState()
+
+	s.stopChan = make(chan struct{})
+	go s.runLoop(s.stopChan)
+	logger.InfoCF("feeds", "Feed watcher started", map[string]any{"feeds_count": len(sources)})
+	return nil
+}
+
+// Stop ends polling.
+func (s *Service) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopChan != nil {
+		close(s.stopChan)
+		s.stopChan = nil
+	}
+}
+
+func (s *Service) buildSources() ([]source, error) {
+	interval := time.Duration(s.cfg.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	defaultPrompt := s.cfg.Prompt
+	if defaultPrompt == "" {
+		defaultPrompt = DefaultPrompt
+	}
+
+	names := make(map[string]bool, len(s.cfg.Sources))
+	sources := make([]source, 0, len(s.cfg.Sources))
+	for i, fc := range s.cfg.Sources {
+		if fc.Name == "" {
+			fc.Name = fc.URL
+		}
+		switch {
+		case fc.URL == "":
+			return nil, fmt.Errorf("feeds: source %d has no url", i)
+		case (fc.Channel == "" || fc.ChatID == "") && !s.digestChat():
+			return nil, fmt.Errorf("feeds: %s needs channel and chat_id", fc.Name)
+		case names[fc.Name]:
+			return nil, fmt.Errorf("feeds: duplicate feed name %q", fc.Name)
+		}
+		names[fc.Name] = true
+
+		src := source{FeedSource: fc, interval: interval}
+		if fc.IntervalMinutes > 0 {
+			src.interval = time.Duration(fc.IntervalMinutes) * time.Minute
+		}
+		text := fc.Prompt
+		if text == "" {
+			text = defaultPrompt
+		}
+		tmpl, err := template.New(fc.Name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("feeds: %s prompt: %w", fc.Name, err)
+		}
+		src.prompt = tmpl
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+func (s *Service) runLoop(stopChan chan struct{}) {
+	ticker := time.NewTicker(checkEvery)
+	defer ticker.Stop()
+
+	s.pollDue(stopChan)
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+			s.pollDue(stopChan)
+			s.digestIfDue()
+		}
+	}
+}
+
+// pollDue polls every feed whose interval has elapsed.
+func (s *Service) pollDue(stopChan chan struct{}) {
+	s.mu.Lock()
+	if s.paused {
+		s.mu.Unlock()
+		return
+	}
+	now := s.now()
+	var due []source
+	for _, src := range s.sources {
+		if st := s.state[src.Name]; st == nil || now.Sub(st.LastPoll) >= src.interval {
+			due = append(due, src)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, src := range due {
+		select {
+		case <-stopChan:
+			return
+		default:
+		}
+		if err := s.poll(context.Background(), src); err != nil {
+			logger.WarnCF("feeds", "Feed poll failed", map[string]any{
+				"name":  src.Name,
+				"url":   src.URL,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// poll fetches one feed and posts its new entries, oldest first, or keeps
+// them for the digest. The first poll of a feed only records what is
+// already there, so adding a feed does not flood the channel with its back
+// catalogue.
+func (s *Service) poll(ctx context.Context, src source) error {
+	entries, err := s.fetch(ctx, src.URL)
+
+	s.mu.Lock()
+	st := s.state[src.Name]
+	if st == nil {
+		st = &feedState{}
+		s.state[src.Name] = st
+	}
+	// Seen stays nil until the first successful fetch.
+	first := st.Seen == nil
+	st.LastPoll = s.now()
+	if err != nil {
+		s.saveStateLocked()
+		s.mu.Unlock()
+		return err
+	}
+	seen := make(map[string]bool, len(st.Seen))
+	for _, id := range st.Seen {
+		seen[id] = true
+	}
+	current := make([]string, 0, len(entries))
+	var fresh []Entry
+	for _, e := range entries {
+		current = append(current, e.ID)
+		if !seen[e.ID] {
+			fresh = append(fresh, e)
+		}
+	}
+	st.Seen = current
+	digest := s.digestEnabled()
+	if digest && !first {
+		s.queueLocked(st, fresh)
+	}
+	s.saveStateLocked()
+	s.mu.Unlock()
+
+	if digest || first || len(fresh) == 0 {
+		return nil
+	}
+
+	maxItems := s.cfg.MaxItems
+	if maxItems <= 0 {
+		maxItems = defaultMaxItems
+	}
+	if len(fresh) > maxItems {
+		fresh = fresh[:maxItems]
+	}
+	for i := len(fresh) - 1; i >= 0; i-- {
+		s.post(ctx, src, fresh[i])
+	}
+	return nil
+}
+
+func (s *Service) fetch(ctx context.Context, url string) ([]Entry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedBytes))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func (s *Service) post(ctx context.Context, src source, e Entry) {
+	text, err := s.render(ctx, src, e)
+	if err != nil {
+		logger.WarnCF("feeds", "Summary failed, posting title and link", map[string]any{
+			"name":  src.Name,
+			"link":  e.Link,
+			"error": err.Error(),
+		})
+		text = fallbackText(e)
+	}
+	s.publish(ctx, bus.OutboundMessage{Channel: src.Channel, ChatID: src.ChatID, Content: text})
+}
+
+func (s *Service) publish(ctx context.Context, msg bus.OutboundMessage) {
+	s.mu.Lock()
+	msgBus := s.bus
+	s.mu.Unlock()
+	if msgBus == nil {
+		return
+	}
+	pubCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := msgBus.PublishOutbound(pubCtx, msg); err != nil {
+		logger.WarnCF("feeds", "Failed to post feed message", map[string]any{
+			"channel": msg.Channel,
+			"chat_id": msg.ChatID,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (s *Service) render(ctx context.Context, src source, e Entry) (string, error) {
+	if s.summarize == nil {
+		return fallbackText(e), nil
+	}
+	data := PromptData{
+		Feed:    src.Name,
+		Title:   e.Title,
+		Link:    e.Link,
+		Content: utils.Truncate(e.Content, maxContentRunes),
+	}
+	if !e.Published.IsZero() {
+		data.Published = e.Published.Format(time.RFC1123)
+	}
+	var buf bytes.Buffer
+	if err := src.prompt.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	sumCtx, cancel := context.WithTimeout(ctx, summarizeTimeout)
+	defer cancel()
+	text, err := s.summarize(sumCtx, buf.String())
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", errors.New("empty summary")
+	}
+	return text, nil
+}
+
+func fallbackText(e Entry) string {
+	if e.Link == "" {
+		return "📰 " + e.Title
+	}
+	return "📰 " + e.Title + "\n" + e.Link
+}
+
+func (s *Service) loadState() {
+	data, err := os.ReadFile(s.statePath)
+	if err != nil {
+		return
+	}
+	var state map[string]*feedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		logger.WarnCF("feeds", "Ignoring unreadable feed state", map[string]any{"path": s.statePath, "error": err.Error()})
+		return
+	}
+	for name, st := range state {
+		if st != nil {
+			s.state[name] = st
+		}
+	}
+}
+
+func (s *Service) saveStateLocked() {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err == nil {
+		err = fileutil.WriteFileAtomic(s.statePath, data, 0o644)
+	}
+	if err != nil {
+		logger.WarnCF("feeds", "Failed to save feed state", map[string]any{"error": err.Error()})
+	}
+}
@@ -0,0 +1,47 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+)
+
+// RewriteLines passes every line of a line-oriented file such as JSON Lines
+// through edit, which returns the replacement line and whether to keep it,
+// and atomically rewrites the file when edit changed anything. It returns
+// the number of lines edit changed or dropped. A missing file has no lines.
+func RewriteLines(path string, perm os.FileMode, edit func(line []byte) ([]byte, bool)) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var out bytes.Buffer
+	changed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		replaced, keep := edit(line)
+		if !keep {
+			changed++
+			continue
+		}
+		if !bytes.Equal(replaced, line) {
+			changed++
+		}
+		out.Write(replaced)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	return changed, WriteFileAtomic(path, out.Bytes(), perm)
+}
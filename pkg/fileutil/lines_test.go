@@ -0,0 +1,38 @@
+package fileutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRewriteLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	if err := os.WriteFile(path, []byte("keep\ndrop\nedit\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	n, err := RewriteLines(path, 0o600, func(line []byte) ([]byte, bool) {
+		switch string(line) {
+		case "drop":
+			return nil, false
+		case "edit":
+			return []byte("edited"), true
+		}
+		return line, true
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("RewriteLines = %d, %v", n, err)
+	}
+	data, _ := os.ReadFile(path)
+	if !bytes.Equal(data, []byte("keep\nedited\n")) {
+		t.Errorf("file = %q", data)
+	}
+
+	n, err = RewriteLines(filepath.Join(t.TempDir(), "missing"), 0o600, func(line []byte) ([]byte, bool) {
+		return nil, false
+	})
+	if n != 0 || err != nil {
+		t.Errorf("missing file = %d, %v", n, err)
+	}
+}
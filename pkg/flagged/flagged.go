@@ -0,0 +1,125 @@
+// Package flagged records conversations that need an operator's attention,
+// such as replies blocked by moderation or rated 👎 by a user, so they can be
+// reviewed in the flagged-conversation digest.
+package flagged
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+)
+
+// Sources of a flag.
+const (
+	SourceModeration = "moderation"
+	SourceFeedback   = "feedback"
+)
+
+// Flag is one flagged exchange: the user's message and the bot's reply.
+type Flag struct {
+	Time       time.Time `json:"time"`
+	Source     string    `json:"source"`
+	Reason     string    `json:"reason,omitempty"`
+	Channel    string    `json:"channel,omitempty"`
+	ChatID     string    `json:"chat_id,omitempty"`
+	SenderID   string    `json:"sender_id,omitempty"`
+	SessionKey string    `json:"session_key,omitempty"`
+	Prompt     string    `json:"prompt,omitempty"`
+	Response   string    `json:"response,omitempty"`
+}
+
+// Path returns the flag log location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "flagged.jsonl")
+}
+
+// Store appends flags to a JSONL file. It is safe for concurrent use.
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewStore creates a store writing to path.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Record appends f. A zero Time is set to now.
+func (s *Store) Record(f Flag) error {
+	if s == nil {
+		return nil
+	}
+	if f.Time.IsZero() {
+		f.Time = time.Now()
+	}
+	line, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load returns the flags in [from, to). A missing file yields no flags.
+func (s *Store) Load(from, to time.Time) ([]Flag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var out []Flag
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var f Flag
+		if err := json.Unmarshal(scanner.Bytes(), &f); err != nil {
+			// Skip torn lines from a crash mid-write.
+			continue
+		}
+		if !f.Time.Before(from) && f.Time.Before(to) {
+			out = append(out, f)
+		}
+	}
+	return out, scanner.Err()
+}
+
+// ForgetSender removes the flags of senderID's messages on channel and
+// returns how many were removed.
+func (s *Store) ForgetSender(channel, senderID string) (int, error) {
+	if s == nil || senderID == "" {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fileutil.RewriteLines(s.path, 0o600, func(line []byte) ([]byte, bool) {
+		var f Flag
+		return line, json.Unmarshal(line, &f) != nil || f.Channel != channel || f.SenderID != senderID
+	})
+}
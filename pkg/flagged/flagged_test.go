@@ -0,0 +1,45 @@
+package flagged
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStore_RecordAndLoad(t *testing.T) {
+	path := Path(t.TempDir())
+	s := NewStore(path)
+	now := time.Now()
+
+	if err := s.Record(Flag{Time: now.Add(-48 * time.Hour), Source: SourceModeration}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(Flag{Source: SourceFeedback, Prompt: "hi", Response: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	f.WriteString(`{"source":`)
+	f.Close()
+
+	flags, err := s.Load(now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil || len(flags) != 1 || flags[0].Source != SourceFeedback || flags[0].Time.IsZero() {
+		t.Fatalf("Load = %+v, %v", flags, err)
+	}
+	if all, _ := s.Load(time.Time{}, now.Add(time.Hour)); len(all) != 2 {
+		t.Errorf("Load all = %d flags, want 2", len(all))
+	}
+}
+
+func TestStore_ForgetSender(t *testing.T) {
+	s := NewStore(Path(t.TempDir()))
+	s.Record(Flag{Source: SourceFeedback, Channel: "telegram", SenderID: "7", Prompt: "mine"})
+	s.Record(Flag{Source: SourceFeedback, Channel: "telegram", SenderID: "8", Prompt: "theirs"})
+
+	if n, err := s.ForgetSender("telegram", "7"); err != nil || n != 1 {
+		t.Fatalf("ForgetSender = %d, %v", n, err)
+	}
+	flags, _ := s.Load(time.Time{}, time.Now().Add(time.Hour))
+	if len(flags) != 1 || flags[0].SenderID != "8" {
+		t.Errorf("left = %+v", flags)
+	}
+}
@@ -0,0 +1,152 @@
+package format
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mrkdwnEscaper   = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	htmlEscaper     = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+	markdownV2Chars = "\\_*[]()~`>#+-=|{}.!"
+	reSlackToken    = regexp.MustCompile(`<(?:[@#!][^<>\s]+|(?:https?|mailto):[^<>\s]+)>`)
+)
+
+var styles = map[Dialect]*style{
+	Discord: {
+		raw: true,
+		heading: func(level int, text string) string {
+			// Discord draws #, ## and ### only
+			if level > 3 {
+				return "**" + text + "**"
+			}
+			return strings.Repeat("#", level) + " " + text
+		},
+		table: fenced,
+	},
+
+	Slack: {
+		keep:   reSlackToken,
+		escape: mrkdwnEscaper.Replace,
+		code:   func(code string) string { return "`" + mrkdwnEscaper.Replace(code) + "`" },
+		link:   func(text, url string) string { return "<" + url + "|" + text + ">" },
+		bold:   [2]string{"*", "*"},
+		italic: [2]string{"_", "_"},
+		strike: [2]string{"~", "~"},
+		bullet: "• ",
+		heading: func(_ int, text string) string {
+			return "*" + text + "*"
+		},
+		// Slack only renders a quote when the line starts with a literal >
+		quote: func(text string) string { return prefixLines(text, "> ") },
+		// Language tags are dropped since Slack would show them as text
+		block: func(_, code string) string { return "```\n" + mrkdwnEscaper.Replace(code) + "\n```" },
+		table: func(text string) string { return "```\n" + mrkdwnEscaper.Replace(text) + "\n```" },
+	},
+
+	TelegramHTML: {
+		escape: htmlEscaper.Replace,
+		code:   func(code string) string { return "<code>" + htmlEscaper.Replace(code) + "</code>" },
+		link: func(text, url string) string {
+			return `<a href="` + htmlEscaper.Replace(url) + `">` + text + "</a>"
+		},
+		bold:   [2]string{"<b>", "</b>"},
+		italic: [2]string{"<i>", "</i>"},
+		strike: [2]string{"<s>", "</s>"},
+		bullet: "• ",
+		heading: func(_ int, text string) string {
+			return "<b>" + text + "</b>"
+		},
+		quote: func(text string) string { return "<blockquote>" + text + "</blockquote>" },
+		block: func(lang, code string) string {
+			if lang == "" {
+				return "<pre>" + htmlEscaper.Replace(code) + "</pre>"
+			}
+			return `<pre><code class="language-` + htmlEscaper.Replace(lang) + `">` +
+				htmlEscaper.Replace(code) + "</code></pre>"
+		},
+		table: func(text string) string { return "<pre>" + htmlEscaper.Replace(text) + "</pre>" },
+	},
+
+	TelegramMarkdownV2: {
+		escape: func(text string) string { return escapeMarkdownV2(text, markdownV2Chars) },
+		code:   func(code string) string { return "`" + escapeMarkdownV2(code, "\\`") + "`" },
+		link: func(text, url string) string {
+			return "[" + text + "](" + escapeMarkdownV2(url, "\\)") + ")"
+		},
+		bold:   [2]string{"*", "*"},
+		italic: [2]string{"_", "_"},
+		strike: [2]string{"~", "~"},
+		bullet: "• ",
+		heading: func(_ int, text string) string {
+			return "*" + text + "*"
+		},
+		quote: func(text string) string { return prefixLines(text, ">") },
+		block: func(lang, code string) string {
+			return "```" + lang + "\n" + escapeMarkdownV2(code, "\\`") + "\n```"
+		},
+		table: func(text string) string { return "```\n" + escapeMarkdownV2(text, "\\`") + "\n```" },
+	},
+
+	WhatsApp: {
+		escape: func(text string) string { return text },
+		code:   func(code string) string { return "`" + code + "`" },
+		link:   plainLink,
+		bold:   [2]string{"*", "*"},
+		italic: [2]string{"_", "_"},
+		strike: [2]string{"~", "~"},
+		bullet: "• ",
+		heading: func(_ int, text string) string {
+			return "*" + text + "*"
+		},
+		quote: func(text string) string { return prefixLines(text, "> ") },
+		// WhatsApp would show a language tag as the first line of code
+		block: func(_, code string) string { return "```\n" + code + "\n```" },
+		table: fenced,
+	},
+
+	Plain: {
+		escape: func(text string) string { return text },
+		code:   func(code string) string { return code },
+		link:   plainLink,
+		bullet: "• ",
+		heading: func(_ int, text string) string {
+			return text
+		},
+		quote: func(text string) string { return prefixLines(text, "> ") },
+		block: func(_, code string) string { return code },
+		table: func(text string) string { return text },
+	},
+}
+
+// plainLink writes a link as its text followed by the URL, or the URL alone
+// when the text is the URL.
+func plainLink(text, url string) string {
+	if text == url || strings.TrimPrefix(url, "mailto:") == text {
+		return url
+	}
+	return text + " (" + url + ")"
+}
+
+// fenced wraps text in a code block without a language.
+func fenced(text string) string {
+	return "```\n" + text + "\n```"
+}
+
+// prefixLines puts prefix before every line of text.
+func prefixLines(text, prefix string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
+
+// escapeMarkdownV2 puts a backslash before each of chars in text.
+func escapeMarkdownV2(text, chars string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range text {
+		if strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,294 @@
+// Package format converts the CommonMark the model writes into the markup
+// each chat platform renders, so replies do not show stray asterisks,
+// pound signs, or table pipes.
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Dialect names the markup a channel renders.
+type Dialect string
+
+const (
+	// Markdown is CommonMark, passed through unchanged.
+	Markdown Dialect = "markdown"
+	// Discord is Discord's markdown: no tables, headings up to ###.
+	Discord Dialect = "discord"
+	// Slack is Slack's mrkdwn.
+	Slack Dialect = "slack"
+	// TelegramHTML is the HTML subset of Telegram's HTML parse mode.
+	TelegramHTML Dialect = "telegram_html"
+	// TelegramMarkdownV2 is Telegram's MarkdownV2 parse mode.
+	TelegramMarkdownV2 Dialect = "telegram_markdownv2"
+	// WhatsApp is WhatsApp's formatting: *bold*, _italic_, ~strike~, and
+	// ``` blocks, without links or headings.
+	WhatsApp Dialect = "whatsapp"
+	// Plain is text without any markup, as sent to IRC or SMS.
+	Plain Dialect = "plain"
+)
+
+// rule is what horizontal rules become on platforms that do not draw them.
+const rule = "──────────"
+
+// Sentinels stand in for emphasis until the text around them is escaped.
+const (
+	boldOpen, boldClose     = "\x01", "\x02"
+	italicOpen, italicClose = "\x03", "\x04"
+	strikeOpen, strikeClose = "\x05", "\x06"
+)
+
+var (
+	reFence      = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})\\s*([^`\\s]*)[^`]*$")
+	reHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.+?)(?:\s+#+)?\s*$`)
+	reRule       = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	reQuote      = regexp.MustCompile(`^ {0,3}>[ \t]?(.*)$`)
+	reListItem   = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	reTableSep   = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(?:\|\s*:?-+:?\s*)*\|?\s*$`)
+	reInlineCode = regexp.MustCompile("```([^`\n]+?)```|``([^`\n]+?)``|`([^`\n]+)`")
+	reImage      = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	reLink       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	reAutolink   = regexp.MustCompile(`<((?:https?|mailto):[^<>\s]+)>`)
+	reBoldItalic = regexp.MustCompile(`\*\*\*(\S(?:.*?\S)?)\*\*\*`)
+	reBoldStar   = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`)
+	reBoldUnder  = regexp.MustCompile(`(^|[^\w])__(\S(?:.*?\S)?)__($|[^\w])`)
+	reItalicStar = regexp.MustCompile(`(^|[^*\w])\*([^*\s](?:[^*\n]*[^*\s])?)\*`)
+	reItalicUnd  = regexp.MustCompile(`(^|[^\w])_([^_\s](?:[^_\n]*[^_\s])?)_($|[^\w])`)
+	reStrike     = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+)
+
+// style describes how one dialect writes each construct. A nil block,
+// heading, or quote keeps the Markdown as written, and raw keeps inline
+// markup as written.
+type style struct {
+	raw     bool
+	keep    *regexp.Regexp // spans already in the dialect's syntax
+	escape  func(string) string
+	code    func(string) string
+	link    func(text, url string) string
+	bold    [2]string
+	italic  [2]string
+	strike  [2]string
+	bullet  string
+	heading func(level int, text string) string
+	quote   func(text string) string
+	block   func(lang, code string) string
+	table   func(text string) string
+}
+
+// Render converts md to the markup of d. Unknown dialects get md unchanged.
+func Render(md string, d Dialect) string {
+	s, ok := styles[d]
+	if !ok || md == "" {
+		return md
+	}
+	return render(strings.ReplaceAll(md, "\r\n", "\n"), s)
+}
+
+func render(md string, s *style) string {
+	lines := strings.Split(md, "\n")
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if m := reFence.FindStringSubmatch(line); m != nil {
+			end := closingFence(lines, i+1, m[1])
+			code := strings.Join(lines[i+1:end], "\n")
+			switch {
+			case s.block == nil:
+				out = append(out, lines[i:min(end+1, len(lines))]...)
+			default:
+				out = append(out, s.block(m[2], code))
+			}
+			i = end
+			continue
+		}
+
+		if i+1 < len(lines) && strings.Contains(line, "|") && isTableSep(lines[i+1]) {
+			end := i + 2
+			for end < len(lines) && strings.Contains(lines[end], "|") && strings.TrimSpace(lines[end]) != "" {
+				end++
+			}
+			if s.table == nil {
+				out = append(out, lines[i:end]...)
+			} else {
+				out = append(out, s.table(alignTable(lines[i:end])))
+			}
+			i = end - 1
+			continue
+		}
+
+		if s.quote != nil && reQuote.MatchString(line) {
+			var inner []string
+			for ; i < len(lines); i++ {
+				m := reQuote.FindStringSubmatch(lines[i])
+				if m == nil {
+					break
+				}
+				inner = append(inner, m[1])
+			}
+			i--
+			out = append(out, s.quote(render(strings.Join(inner, "\n"), s)))
+			continue
+		}
+
+		if reRule.MatchString(line) {
+			out = append(out, rule)
+			continue
+		}
+
+		if m := reHeading.FindStringSubmatch(line); m != nil && s.heading != nil {
+			out = append(out, s.heading(len(m[1]), inline(m[2], s)))
+			continue
+		}
+
+		if m := reListItem.FindStringSubmatch(line); m != nil && !s.raw {
+			out = append(out, m[1]+s.bullet+inline(m[2], s))
+			continue
+		}
+
+		out = append(out, inline(line, s))
+	}
+	return strings.Join(out, "\n")
+}
+
+// closingFence returns the index of the line closing the fence opened
+// before from, or len(lines) when it is never closed.
+func closingFence(lines []string, from int, fence string) int {
+	for i := from; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// inline converts the emphasis, code spans, and links of one line.
+func inline(text string, s *style) string {
+	if s.raw {
+		return text
+	}
+
+	var held []string
+	hold := func(v string) string {
+		held = append(held, v)
+		return fmt.Sprintf("\x00%d\x00", len(held)-1)
+	}
+
+	text = reInlineCode.ReplaceAllStringFunc(text, func(m string) string {
+		sub := reInlineCode.FindStringSubmatch(m)
+		return hold(s.code(sub[1] + sub[2] + sub[3]))
+	})
+	if s.keep != nil {
+		text = s.keep.ReplaceAllStringFunc(text, hold)
+	}
+	text = reAutolink.ReplaceAllStringFunc(text, func(m string) string {
+		url := reAutolink.FindStringSubmatch(m)[1]
+		return hold(s.link(s.escape(url), url))
+	})
+	text = reImage.ReplaceAllStringFunc(text, func(m string) string {
+		sub := reImage.FindStringSubmatch(m)
+		alt := sub[1]
+		if alt == "" {
+			alt = sub[2]
+		}
+		return hold(s.link(spans(alt, s), sub[2]))
+	})
+	text = reLink.ReplaceAllStringFunc(text, func(m string) string {
+		sub := reLink.FindStringSubmatch(m)
+		return hold(s.link(spans(sub[1], s), sub[2]))
+	})
+
+	text = spans(text, s)
+
+	// Links hold the code spans of their text, so restore the later ones first.
+	for i := len(held) - 1; i >= 0; i-- {
+		text = strings.ReplaceAll(text, fmt.Sprintf("\x00%d\x00", i), held[i])
+	}
+	return text
+}
+
+// spans converts emphasis and escapes the text around it.
+func spans(text string, s *style) string {
+	text = reBoldItalic.ReplaceAllString(text, boldOpen+italicOpen+"$1"+italicClose+boldClose)
+	text = reBoldStar.ReplaceAllString(text, boldOpen+"$1"+boldClose)
+	text = replaceAll(reBoldUnder, text, "${1}"+boldOpen+"${2}"+boldClose+"${3}")
+	text = reItalicStar.ReplaceAllString(text, "${1}"+italicOpen+"${2}"+italicClose)
+	text = replaceAll(reItalicUnd, text, "${1}"+italicOpen+"${2}"+italicClose+"${3}")
+	text = reStrike.ReplaceAllString(text, strikeOpen+"$1"+strikeClose)
+
+	text = s.escape(text)
+	return strings.NewReplacer(
+		boldOpen, s.bold[0], boldClose, s.bold[1],
+		italicOpen, s.italic[0], italicClose, s.italic[1],
+		strikeOpen, s.strike[0], strikeClose, s.strike[1],
+	).Replace(text)
+}
+
+// replaceAll applies re until nothing changes, for patterns that consume
+// the character after a match and so miss back-to-back matches.
+func replaceAll(re *regexp.Regexp, text, repl string) string {
+	for range 8 {
+		next := re.ReplaceAllString(text, repl)
+		if next == text {
+			break
+		}
+		text = next
+	}
+	return text
+}
+
+// isTableSep reports whether line is the delimiter row under a table header.
+func isTableSep(line string) bool {
+	return strings.Contains(line, "|") && strings.Contains(line, "-") && reTableSep.MatchString(line)
+}
+
+// alignTable lays out the rows of a Markdown table in padded columns,
+// dropping the separator row and the inline markup of the cells.
+func alignTable(lines []string) string {
+	var rows [][]string
+	var widths []int
+	for i, line := range lines {
+		if i == 1 {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+		line = strings.ReplaceAll(line, `\|`, "\x00")
+		cells := strings.Split(line, "|")
+		for j, cell := range cells {
+			cell = inline(strings.TrimSpace(strings.ReplaceAll(cell, "\x00", "|")), styles[Plain])
+			cells[j] = cell
+			if j == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[j] = max(widths[j], utf8.RuneCountInString(cell))
+		}
+		rows = append(rows, cells)
+	}
+
+	out := make([]string, 0, len(rows)+1)
+	for i, cells := range rows {
+		var line strings.Builder
+		for j, cell := range cells {
+			if j > 0 {
+				line.WriteString(" | ")
+			}
+			line.WriteString(cell)
+			line.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)))
+		}
+		out = append(out, strings.TrimRight(line.String(), " "))
+		if i == 0 {
+			sep := make([]string, len(widths))
+			for j, w := range widths {
+				sep[j] = strings.Repeat("-", w)
+			}
+			out = append(out, strings.Join(sep, "-+-"))
+		}
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,72 @@
+package format
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	table := "| Name | Size |\n|------|-----:|\n| **a.go** | 12 |\n| b | 3 |"
+	tests := []struct {
+		name    string
+		dialect Dialect
+		input   string
+		want    string
+	}{
+		{"markdown unchanged", Markdown, "# **Hi** | x", "# **Hi** | x"},
+		{"unknown dialect", Dialect("sms"), "**b**", "**b**"},
+
+		{"discord keeps markdown", Discord, "## Steps\n- **one** `x`\n> quote", "## Steps\n- **one** `x`\n> quote"},
+		{"discord deep heading", Discord, "#### Notes", "**Notes**"},
+		{"discord rule", Discord, "a\n\n---\nb", "a\n\n──────────\nb"},
+		{"discord table", Discord, table,
+			"```\nName | Size\n-----+-----\na.go | 12\nb    | 3\n```"},
+		{"discord code kept", Discord, "```go\n| a |\n|---|\n```", "```go\n| a |\n|---|\n```"},
+
+		{"slack bold", Slack, "a **b** c", "a *b* c"},
+		{"slack underscore bold", Slack, "__b__", "*b*"},
+		{"slack italic", Slack, "an *emphasized* word", "an _emphasized_ word"},
+		{"slack underscore italic", Slack, "_kept_", "_kept_"},
+		{"slack strike", Slack, "~~gone~~", "~gone~"},
+		{"slack link", Slack, "see [docs](https://example.com/a)", "see <https://example.com/a|docs>"},
+		{"slack heading", Slack, "## Steps", "*Steps*"},
+		{"slack list", Slack, "- one\n* two\n  - nested", "• one\n• two\n  • nested"},
+		{"slack quote", Slack, "> quoted & <b>", "> quoted &amp; &lt;b&gt;"},
+		{"slack mentions survive", Slack, "hi <@U123> see <https://x.io>", "hi <@U123> see <https://x.io>"},
+		{"slack inline code", Slack, "run `a **b** <c>`", "run `a **b** &lt;c&gt;`"},
+		{"slack code block", Slack, "```go\nx := *p\n```", "```\nx := *p\n```"},
+
+		{"html emphasis", TelegramHTML, "**b** *i* _u_ ~~s~~", "<b>b</b> <i>i</i> <i>u</i> <s>s</s>"},
+		{"html snake_case", TelegramHTML, "use snake_case_name", "use snake_case_name"},
+		{"html heading", TelegramHTML, "### A < B", "<b>A &lt; B</b>"},
+		{"html link", TelegramHTML, `[a "b"](https://x.io/?q=1&r=2)`,
+			`<a href="https://x.io/?q=1&amp;r=2">a &quot;b&quot;</a>`},
+		{"html link with code", TelegramHTML, "[`cfg`](https://x.io)", `<a href="https://x.io"><code>cfg</code></a>`},
+		{"html quote", TelegramHTML, "> one\n> **two**\nafter", "<blockquote>one\n<b>two</b></blockquote>\nafter"},
+		{"html code block", TelegramHTML, "```go\nif a < b {}\n```",
+			`<pre><code class="language-go">if a &lt; b {}</code></pre>`},
+		{"html unclosed fence", TelegramHTML, "```\nx\ny", "<pre>x\ny</pre>"},
+		{"html table", TelegramHTML, "|a|b|\n|-|-|\n|1|2|", "<pre>a | b\n--+--\n1 | 2</pre>"},
+
+		{"v2 escaping", TelegramMarkdownV2, "Done. Cost: 1+1=2 (ok)!", `Done\. Cost: 1\+1\=2 \(ok\)\!`},
+		{"v2 emphasis", TelegramMarkdownV2, "**b** *i* ~~s~~ snake_case", `*b* _i_ ~s~ snake\_case`},
+		{"v2 link", TelegramMarkdownV2, "[v1.2](https://x.io/a_b)", `[v1\.2](https://x.io/a_b)`},
+		{"v2 code", TelegramMarkdownV2, "`a\\b` and\n```sh\necho `x`\n```", "`a\\\\b` and\n```sh\necho \\`x\\`\n```"},
+		{"v2 heading and quote", TelegramMarkdownV2, "# Hi!\n> a-b", "*Hi\\!*\n>a\\-b"},
+
+		{"whatsapp", WhatsApp, "## Steps\n1. **run** `make`, *then* ~~wait~~\n- see [docs](https://x.io)",
+			"*Steps*\n1. *run* `make`, _then_ ~wait~\n• see docs (https://x.io)"},
+		{"whatsapp code block", WhatsApp, "```go\nx := *p\n```", "```\nx := *p\n```"},
+
+		{"plain", Plain, "## Steps\n1. **run** `make`\n- see [docs](https://x.io)",
+			"Steps\n1. run make\n• see docs (https://x.io)"},
+		{"plain bare link", Plain, "<https://x.io> and [https://y.io](https://y.io)", "https://x.io and https://y.io"},
+		{"plain code block", Plain, "```go\nx := *p\n```\ndone", "x := *p\ndone"},
+		{"plain image", Plain, "![chart](https://x.io/c.png)", "chart (https://x.io/c.png)"},
+		{"plain table", Plain, table, "Name | Size\n-----+-----\na.go | 12\nb    | 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.input, tt.dialect); got != tt.want {
+				t.Errorf("Render(%q, %s) =\n%q\nwant\n%q", tt.input, tt.dialect, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,22 +4,29 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/signal"
-	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"sync"
-	"syscall"
 	"time"
 
+	"github.com/adhocore/gronx"
+
+	"github.com/sipeed/picoclaw/pkg/admin"
 	"github.com/sipeed/picoclaw/pkg/agent"
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/calendar"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	_ "github.com/sipeed/picoclaw/pkg/channels/dingtalk"
 	_ "github.com/sipeed/picoclaw/pkg/channels/discord"
+	_ "github.com/sipeed/picoclaw/pkg/channels/email"
 	_ "github.com/sipeed/picoclaw/pkg/channels/feishu"
+	_ "github.com/sipeed/picoclaw/pkg/channels/httpapi"
 	_ "github.com/sipeed/picoclaw/pkg/channels/irc"
 	_ "github.com/sipeed/picoclaw/pkg/channels/line"
 	_ "github.com/sipeed/picoclaw/pkg/channels/maixcam"
 	_ "github.com/sipeed/picoclaw/pkg/channels/matrix"
+	_ "github.com/sipeed/picoclaw/pkg/channels/mattermost"
 	_ "github.com/sipeed/picoclaw/pkg/channels/onebot"
 	_ "github.com/sipeed/picoclaw/pkg/channels/pico"
 	_ "github.com/sipeed/picoclaw/pkg/channels/qq"
@@ -27,17 +34,36 @@ import (
 	_ "github.com/sipeed/picoclaw/pkg/channels/telegram"
 	_ "github.com/sipeed/picoclaw/pkg/channels/wecom"
 	_ "github.com/sipeed/picoclaw/pkg/channels/whatsapp"
+	_ "github.com/sipeed/picoclaw/pkg/channels/whatsapp_cloud"
 	_ "github.com/sipeed/picoclaw/pkg/channels/whatsapp_native"
+	_ "github.com/sipeed/picoclaw/pkg/channels/xmpp"
+	"github.com/sipeed/picoclaw/pkg/chatexport"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/dashboard"
 	"github.com/sipeed/picoclaw/pkg/devices"
+	"github.com/sipeed/picoclaw/pkg/digest"
+	"github.com/sipeed/picoclaw/pkg/erasure"
+	"github.com/sipeed/picoclaw/pkg/eventhook"
+	"github.com/sipeed/picoclaw/pkg/feedback"
+	"github.com/sipeed/picoclaw/pkg/feeds"
+	"github.com/sipeed/picoclaw/pkg/githook"
 	"github.com/sipeed/picoclaw/pkg/health"
 	"github.com/sipeed/picoclaw/pkg/heartbeat"
+	"github.com/sipeed/picoclaw/pkg/knowledge"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/maintenance"
 	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/middleware"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/providers/common"
+	"github.com/sipeed/picoclaw/pkg/recap"
 	"github.com/sipeed/picoclaw/pkg/state"
+	"github.com/sipeed/picoclaw/pkg/tenant"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/tracing"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+	"github.com/sipeed/picoclaw/pkg/usage"
 	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
@@ -50,10 +76,20 @@ const (
 type services struct {
 	CronService      *cron.CronService
 	HeartbeatService *heartbeat.HeartbeatService
+	DigestService    *digest.Service
+	FeedService      *feeds.Service
+	RecapService     *recap.Service
+	KnowledgeService *knowledge.Service
+	Maintenance      *maintenance.Controller
 	MediaStore       media.MediaStore
 	ChannelManager   *channels.Manager
 	DeviceService    *devices.Service
 	HealthServer     *health.Server
+	SchedulerLease   *schedulerLease
+	// ExtraChannels were added with AddChannel and join every channel manager
+	ExtraChannels []channels.Channel
+	// Admin is the gateway's part of the admin API
+	Admin admin.Backend
 }
 
 type startupBlockedProvider struct {
@@ -74,21 +110,38 @@ func (p *startupBlockedProvider) GetDefaultModel() string {
 	return ""
 }
 
-// Run starts the gateway runtime using the configuration loaded from configPath.
-func Run(debug bool, configPath string, allowEmptyStartup bool) error {
-	if debug {
-		logger.SetLevel(logger.DEBUG)
-		fmt.Println("🔍 Debug mode enabled")
-	}
+// Options configures a Gateway.
+type Options struct {
+	// ConfigPath is the file cfg was loaded from. With gateway.hot_reload it
+	// is watched and reloaded on change; empty turns hot reload off.
+	ConfigPath string
+	// Debug logs at debug level, whatever the logging config says.
+	Debug bool
+	// AllowEmptyStartup starts without a default model, answering every
+	// message with an error until one is configured.
+	AllowEmptyStartup bool
+}
 
-	cfg, err := config.LoadConfig(configPath)
-	if err != nil {
-		return fmt.Errorf("error loading config: %w", err)
-	}
+// Gateway runs the agent with its channels, schedulers, and HTTP server.
+// Channels and tools may be added before Run.
+type Gateway struct {
+	cfg       *config.Config
+	opts      Options
+	provider  providers.LLMProvider
+	msgBus    *bus.MessageBus
+	agentLoop *agent.AgentLoop
+	channels  []channels.Channel
+	reloads   chan *config.Config // reloads requested through the admin API
+	configMu  sync.Mutex          // serializes edits of the config file
+}
+
+// New creates the provider and the agent for cfg. Nothing runs until Run.
+func New(cfg *config.Config, opts Options) (*Gateway, error) {
+	configureLogging(cfg, opts.Debug)
 
-	provider, modelID, err := createStartupProvider(cfg, allowEmptyStartup)
+	provider, modelID, err := createStartupProvider(cfg, opts.AllowEmptyStartup)
 	if err != nil {
-		return fmt.Errorf("error creating provider: %w", err)
+		return nil, fmt.Errorf("error creating provider: %w", err)
 	}
 
 	if modelID != "" {
@@ -112,45 +165,166 @@ func Run(debug bool, configPath string, allowEmptyStartup bool) error {
 			"skills_available": skillsInfo["available"],
 		})
 
-	runningServices, err := setupAndStartServices(cfg, agentLoop, msgBus)
+	return &Gateway{
+		cfg:       cfg,
+		opts:      opts,
+		provider:  provider,
+		msgBus:    msgBus,
+		agentLoop: agentLoop,
+		reloads:   make(chan *config.Config, 1),
+	}, nil
+}
+
+// Bus returns the message bus, for channels added with AddChannel.
+func (g *Gateway) Bus() *bus.MessageBus {
+	return g.msgBus
+}
+
+// AgentLoop returns the agent.
+func (g *Gateway) AgentLoop() *agent.AgentLoop {
+	return g.agentLoop
+}
+
+// AddChannel adds a channel beside the configured ones. It is started and
+// stopped with them, also when a config reload restarts the channels. Call
+// before Run.
+func (g *Gateway) AddChannel(ch channels.Channel) {
+	g.channels = append(g.channels, ch)
+}
+
+// AddTool gives tool to every agent, replacing a built-in tool of the same
+// name, and keeps it across config reloads.
+func (g *Gateway) AddTool(tool tools.Tool) {
+	g.agentLoop.AddTool(tool)
+}
+
+// Use adds mw at point of the message pipeline; see package middleware.
+func (g *Gateway) Use(point string, mw middleware.Middleware) error {
+	return g.agentLoop.Use(point, mw)
+}
+
+// Run starts the services and serves until ctx is done, then shuts down,
+// finishing the messages being answered.
+func (g *Gateway) Run(ctx context.Context) error {
+	stopTracing, err := tracing.Setup(g.cfg.Tracing)
 	if err != nil {
+		logger.ErrorCF("tracing", "Tracing disabled: invalid config", map[string]any{"error": err.Error()})
+		stopTracing = func(context.Context) error { return nil }
+	} else if g.cfg.Tracing.Enabled {
+		fmt.Println("✓ Tracing enabled (OTLP)")
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopTracing(flushCtx)
+	}()
+
+	runningServices := &services{
+		ExtraChannels: g.channels,
+		Admin:         admin.Backend{Reload: g.requestReload, UpdateChannel: g.updateChannel},
+	}
+	if err := setupAndStartServices(g.cfg, g.agentLoop, g.msgBus, runningServices); err != nil {
 		return err
 	}
 
-	fmt.Printf("✓ Gateway started on %s:%d\n", cfg.Gateway.Host, cfg.Gateway.Port)
+	fmt.Printf("✓ Gateway started on %s:%d\n", g.cfg.Gateway.Host, g.cfg.Gateway.Port)
 	fmt.Println("Press Ctrl+C to stop")
 
-	ctx, cancel := context.WithCancel(context.Background())
+	// The agent outlives ctx until the shutdown has answered the queued messages
+	loopCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
 	defer cancel()
 
-	go agentLoop.Run(ctx)
+	go g.agentLoop.Run(loopCtx)
 
 	var configReloadChan <-chan *config.Config
 	stopWatch := func() {}
-	if cfg.Gateway.HotReload {
-		configReloadChan, stopWatch = setupConfigWatcherPolling(configPath, debug)
+	if g.cfg.Gateway.HotReload && g.opts.ConfigPath != "" {
+		configReloadChan, stopWatch = setupConfigWatcherPolling(g.opts.ConfigPath, g.opts.Debug)
 		logger.Info("Config hot reload enabled")
 	}
 	defer stopWatch()
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
 	for {
 		select {
-		case <-sigChan:
+		case <-ctx.Done():
 			logger.Info("Shutting down...")
-			shutdownGateway(runningServices, agentLoop, provider, true)
+			shutdownGateway(runningServices, g.agentLoop, g.provider, true)
 			return nil
 		case newCfg := <-configReloadChan:
-			err := handleConfigReload(ctx, agentLoop, newCfg, &provider, runningServices, msgBus, allowEmptyStartup)
-			if err != nil {
-				logger.Errorf("Config reload failed: %v", err)
+			g.reload(loopCtx, newCfg, runningServices)
+		case newCfg := <-g.reloads:
+			g.reload(loopCtx, newCfg, runningServices)
+		}
+	}
+}
+
+func (g *Gateway) reload(ctx context.Context, newCfg *config.Config, runningServices *services) {
+	err := handleConfigReload(ctx, g.agentLoop, newCfg, &g.provider, runningServices, g.msgBus,
+		g.opts.AllowEmptyStartup)
+	if err != nil {
+		logger.Errorf("Config reload failed: %v", err)
+	}
+}
+
+// requestReload loads the config file and hands it to Run, which applies it
+// as it applies a changed file. It replaces a reload Run has not taken yet.
+func (g *Gateway) requestReload() error {
+	if g.opts.ConfigPath == "" {
+		return admin.ErrNoConfigFile
+	}
+	newCfg, err := config.LoadConfig(g.opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	for {
+		select {
+		case g.reloads <- newCfg:
+			return nil
+		default:
+			select {
+			case <-g.reloads:
+			default:
 			}
 		}
 	}
 }
 
+// updateChannel changes the settings of a channel in the config file and
+// reloads it.
+func (g *Gateway) updateChannel(name string, patch map[string]any) error {
+	if g.opts.ConfigPath == "" {
+		return admin.ErrNoConfigFile
+	}
+	g.configMu.Lock()
+	defer g.configMu.Unlock()
+	cfg, err := config.LoadConfig(g.opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	if err := admin.PatchChannel(&cfg.Channels, name, patch); err != nil {
+		return err
+	}
+	if err := config.SaveConfig(g.opts.ConfigPath, cfg); err != nil {
+		return fmt.Errorf("error saving config: %w", err)
+	}
+	logger.InfoCF("admin", "Channel settings changed", map[string]any{"channel": name})
+	return g.requestReload()
+}
+
+// configureLogging applies the logging settings of cfg.
+func configureLogging(cfg *config.Config, debug bool) {
+	if debug {
+		fmt.Println("🔍 Debug mode enabled")
+	}
+	logger.SetRedaction(cfg.LogRedaction())
+	if err := logger.Configure(cfg.Logging.Level, cfg.Logging.Format); err != nil {
+		logger.WarnCF("logger", "Invalid logging config", map[string]any{"error": err.Error()})
+	}
+	if debug {
+		logger.SetLevel(logger.DEBUG)
+	}
+}
+
 func createStartupProvider(
 	cfg *config.Config,
 	allowEmptyStartup bool,
@@ -172,8 +346,18 @@ func setupAndStartServices(
 	cfg *config.Config,
 	agentLoop *agent.AgentLoop,
 	msgBus *bus.MessageBus,
-) (*services, error) {
-	runningServices := &services{}
+	runningServices *services,
+) error {
+	runningServices.Maintenance = maintenance.NewController(cfg.Maintenance, cfg.WorkspacePath())
+	runningServices.Maintenance.SetBus(msgBus)
+	runningServices.Maintenance.SetAdminToken(cfg.Admin.APIToken)
+	runningServices.Maintenance.OnChange(func(maintenance.State) {
+		applySchedulerPause(runningServices)
+	})
+	agentLoop.SetMaintenance(runningServices.Maintenance)
+	if err := startSchedulerLease(cfg, runningServices); err != nil {
+		return err
+	}
 
 	execTimeout := time.Duration(cfg.Tools.Cron.ExecTimeoutMinutes) * time.Minute
 	var err error
@@ -186,10 +370,12 @@ func setupAndStartServices(
 		cfg,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("error setting up cron service: %w", err)
+		return fmt.Errorf("error setting up cron service: %w", err)
 	}
+	// Start paused on a standby replica or in maintenance
+	applySchedulerPause(runningServices)
 	if err = runningServices.CronService.Start(); err != nil {
-		return nil, fmt.Errorf("error starting cron service: %w", err)
+		return fmt.Errorf("error starting cron service: %w", err)
 	}
 	fmt.Println("✓ Cron service started")
 
@@ -200,11 +386,20 @@ func setupAndStartServices(
 	)
 	runningServices.HeartbeatService.SetBus(msgBus)
 	runningServices.HeartbeatService.SetHandler(createHeartbeatHandler(agentLoop))
+	applySchedulerPause(runningServices)
 	if err = runningServices.HeartbeatService.Start(); err != nil {
-		return nil, fmt.Errorf("error starting heartbeat service: %w", err)
+		return fmt.Errorf("error starting heartbeat service: %w", err)
 	}
 	fmt.Println("✓ Heartbeat service started")
 
+	runningServices.DigestService = startDigestService(cfg, msgBus)
+	runningServices.FeedService = startFeedService(cfg, agentLoop, msgBus)
+	runningServices.KnowledgeService = startKnowledgeService(cfg)
+	applySchedulerPause(runningServices)
+	if runningServices.Maintenance.Active() {
+		fmt.Println("⚠ Maintenance mode is on")
+	}
+
 	runningServices.MediaStore = media.NewFileMediaStoreWithCleanup(media.MediaCleanerConfig{
 		Enabled:  cfg.Tools.MediaCleanup.Enabled,
 		MaxAge:   time.Duration(cfg.Tools.MediaCleanup.MaxAge) * time.Minute,
@@ -219,16 +414,23 @@ func setupAndStartServices(
 		if fms, ok := runningServices.MediaStore.(*media.FileMediaStore); ok {
 			fms.Stop()
 		}
-		return nil, fmt.Errorf("error creating channel manager: %w", err)
+		return fmt.Errorf("error creating channel manager: %w", err)
 	}
 
 	agentLoop.SetChannelManager(runningServices.ChannelManager)
 	agentLoop.SetMediaStore(runningServices.MediaStore)
+	runningServices.RecapService = startRecapService(cfg, agentLoop, msgBus, runningServices.ChannelManager)
+	applySchedulerPause(runningServices)
+	registerChannels(agentLoop, msgBus, runningServices)
 
 	if transcriber := voice.DetectTranscriber(cfg); transcriber != nil {
 		agentLoop.SetTranscriber(transcriber)
 		logger.InfoCF("voice", "Transcription enabled (agent-level)", map[string]any{"provider": transcriber.Name()})
 	}
+	if synthesizer := voice.DetectSynthesizer(cfg); synthesizer != nil {
+		agentLoop.SetSynthesizer(synthesizer)
+		logger.InfoCF("voice", "Spoken replies available", map[string]any{"provider": synthesizer.Name()})
+	}
 
 	enabledChannels := runningServices.ChannelManager.GetEnabledChannels()
 	if len(enabledChannels) > 0 {
@@ -239,10 +441,22 @@ func setupAndStartServices(
 
 	addr := fmt.Sprintf("%s:%d", cfg.Gateway.Host, cfg.Gateway.Port)
 	runningServices.HealthServer = health.NewServer(cfg.Gateway.Host, cfg.Gateway.Port)
+	runningServices.HealthServer.RegisterMetrics("providers", func() any { return common.Stats() })
 	runningServices.ChannelManager.SetupHTTPServer(addr, runningServices.HealthServer)
+	runningServices.ChannelManager.Handle(maintenance.HTTPPath, runningServices.Maintenance)
+	registerTranscripts(cfg, agentLoop, runningServices.ChannelManager)
+	registerErasure(cfg, agentLoop, runningServices.ChannelManager)
+	registerUsage(agentLoop, runningServices.ChannelManager)
+	registerFeedback(agentLoop, runningServices.ChannelManager)
+	registerConversations(cfg, agentLoop, runningServices.ChannelManager)
+	registerTenants(agentLoop, runningServices.ChannelManager)
+	registerAdmin(cfg, agentLoop, runningServices)
+	registerGitHubWebhook(cfg, agentLoop, msgBus, runningServices.ChannelManager)
+	registerEventWebhooks(cfg, agentLoop, msgBus, runningServices.ChannelManager)
+	registerCalendarLinker(cfg, agentLoop, runningServices.ChannelManager)
 
 	if err = runningServices.ChannelManager.StartAll(context.Background()); err != nil {
-		return nil, fmt.Errorf("error starting channels: %w", err)
+		return fmt.Errorf("error starting channels: %w", err)
 	}
 
 	fmt.Printf("✓ Health endpoints available at http://%s:%d/health and /ready\n", cfg.Gateway.Host, cfg.Gateway.Port)
@@ -259,7 +473,7 @@ func setupAndStartServices(
 		fmt.Println("✓ Device event service started")
 	}
 
-	return runningServices, nil
+	return nil
 }
 
 func stopAndCleanupServices(runningServices *services, shutdownTimeout time.Duration) {
@@ -269,20 +483,40 @@ func stopAndCleanupServices(runningServices *services, shutdownTimeout time.Dura
 	if runningServices.ChannelManager != nil {
 		runningServices.ChannelManager.StopAll(shutdownCtx)
 	}
+	stopSchedulers(runningServices)
+	runningServices.SchedulerLease.stop()
+	runningServices.SchedulerLease = nil
+	if runningServices.MediaStore != nil {
+		if fms, ok := runningServices.MediaStore.(*media.FileMediaStore); ok {
+			fms.Stop()
+		}
+	}
+}
+
+// stopSchedulers stops the services that run beside the channels, for a
+// shutdown or a config reload.
+func stopSchedulers(runningServices *services) {
 	if runningServices.DeviceService != nil {
 		runningServices.DeviceService.Stop()
 	}
 	if runningServices.HeartbeatService != nil {
 		runningServices.HeartbeatService.Stop()
 	}
+	if runningServices.DigestService != nil {
+		runningServices.DigestService.Stop()
+	}
+	if runningServices.FeedService != nil {
+		runningServices.FeedService.Stop()
+	}
+	if runningServices.RecapService != nil {
+		runningServices.RecapService.Stop()
+	}
+	if runningServices.KnowledgeService != nil {
+		runningServices.KnowledgeService.Stop()
+	}
 	if runningServices.CronService != nil {
 		runningServices.CronService.Stop()
 	}
-	if runningServices.MediaStore != nil {
-		if fms, ok := runningServices.MediaStore.(*media.FileMediaStore); ok {
-			fms.Stop()
-		}
-	}
 }
 
 func shutdownGateway(
@@ -291,13 +525,17 @@ func shutdownGateway(
 	provider providers.LLMProvider,
 	fullShutdown bool,
 ) {
+	// Queued messages are answered while the provider and channels still run;
+	// the rest are saved and answered after the restart
+	logger.Info("  Finishing queued messages...")
+	agentLoop.Stop()
+
 	if cp, ok := provider.(providers.StatefulProvider); ok && fullShutdown {
 		cp.Close()
 	}
 
 	stopAndCleanupServices(runningServices, gracefulShutdownTimeout)
 
-	agentLoop.Stop()
 	agentLoop.Close()
 
 	logger.Info("✓ Gateway stopped")
@@ -313,6 +551,10 @@ func handleConfigReload(
 	allowEmptyStartup bool,
 ) error {
 	logger.Info("🔄 Config file changed, reloading...")
+	logger.SetRedaction(newCfg.LogRedaction())
+	if err := logger.Configure(newCfg.Logging.Level, newCfg.Logging.Format); err != nil {
+		logger.WarnCF("logger", "Invalid logging config", map[string]any{"error": err.Error()})
+	}
 
 	newModel := newCfg.Agents.Defaults.ModelName
 	if newModel == "" {
@@ -321,14 +563,25 @@ func handleConfigReload(
 
 	logger.Infof(" New model is '%s', recreating provider...", newModel)
 
-	logger.Info("  Stopping all services...")
-	stopAndCleanupServices(runningServices, serviceShutdownTimeout)
+	// Without changes to what is read only at start, the channel manager
+	// and HTTP server keep running and only the changed channels cycle.
+	// With HA the replicas hold the channel leases, so the manager restarts.
+	restart := restartServices
+	inPlace := runningServices.ChannelManager != nil && !newCfg.Gateway.HA.Enabled
+	if inPlace && !servicesNeedRestart(al.GetConfig(), newCfg) {
+		restart = reloadServices
+		logger.Info("  Stopping background services...")
+		stopSchedulers(runningServices)
+	} else {
+		logger.Info("  Stopping all services...")
+		stopAndCleanupServices(runningServices, serviceShutdownTimeout)
+	}
 
 	newProvider, newModelID, err := createStartupProvider(newCfg, allowEmptyStartup)
 	if err != nil {
 		logger.Errorf("  ⚠ Error creating new provider: %v", err)
 		logger.Warn("  Attempting to restart services with old provider and config...")
-		if restartErr := restartServices(al, runningServices, msgBus); restartErr != nil {
+		if restartErr := restart(al, runningServices, msgBus); restartErr != nil {
 			logger.Errorf("  ⚠ Failed to restart services: %v", restartErr)
 		}
 		return fmt.Errorf("error creating new provider: %w", err)
@@ -347,7 +600,7 @@ func handleConfigReload(
 			cp.Close()
 		}
 		logger.Warn("  Attempting to restart services with old provider and config...")
-		if restartErr := restartServices(al, runningServices, msgBus); restartErr != nil {
+		if restartErr := restart(al, runningServices, msgBus); restartErr != nil {
 			logger.Errorf("  ⚠ Failed to restart services: %v", restartErr)
 		}
 		return fmt.Errorf("error reloading agent loop: %w", err)
@@ -355,8 +608,8 @@ func handleConfigReload(
 
 	*providerRef = newProvider
 
-	logger.Info("  Restarting all services with new configuration...")
-	if err := restartServices(al, runningServices, msgBus); err != nil {
+	logger.Info("  Restarting services with new configuration...")
+	if err := restart(al, runningServices, msgBus); err != nil {
 		logger.Errorf("  ⚠ Error restarting services: %v", err)
 		return fmt.Errorf("error restarting services: %w", err)
 	}
@@ -365,42 +618,71 @@ func handleConfigReload(
 	return nil
 }
 
-func restartServices(
+// servicesNeedRestart reports whether newCfg changes settings that are read
+// only when the services start: the gateway's HTTP server and HA, the APIs
+// mounted on it, the media store, voice, and the workspace. Everything else
+// is applied to the running services.
+func servicesNeedRestart(oldCfg, newCfg *config.Config) bool {
+	for _, pair := range [][2]any{
+		{oldCfg.Gateway, newCfg.Gateway},
+		{oldCfg.ObjectStorage, newCfg.ObjectStorage},
+		{oldCfg.Erasure, newCfg.Erasure},
+		{oldCfg.Usage, newCfg.Usage},
+		{oldCfg.Conversations, newCfg.Conversations},
+		{oldCfg.Tenants.APIToken, newCfg.Tenants.APIToken},
+		{oldCfg.Admin, newCfg.Admin},
+		{oldCfg.Dashboard, newCfg.Dashboard},
+		{oldCfg.GitHubWebhook, newCfg.GitHubWebhook},
+		{oldCfg.EventWebhooks, newCfg.EventWebhooks},
+		{oldCfg.Tools.Calendar, newCfg.Tools.Calendar},
+		{oldCfg.Tools.MediaCleanup, newCfg.Tools.MediaCleanup},
+		{oldCfg.Voice, newCfg.Voice},
+	} {
+		if !reflect.DeepEqual(pair[0], pair[1]) {
+			return true
+		}
+	}
+	return oldCfg.WorkspacePath() != newCfg.WorkspacePath()
+}
+
+// reloadServices restarts the schedulers with the agent's config and hands
+// it to the running channel manager, which cycles only the channels whose
+// config changed. The HTTP server and media store keep running.
+func reloadServices(
 	al *agent.AgentLoop,
 	runningServices *services,
 	msgBus *bus.MessageBus,
 ) error {
 	cfg := al.GetConfig()
-
-	execTimeout := time.Duration(cfg.Tools.Cron.ExecTimeoutMinutes) * time.Minute
-	var err error
-	runningServices.CronService, err = setupCronTool(
-		al,
-		msgBus,
-		cfg.WorkspacePath(),
-		cfg.Agents.Defaults.RestrictToWorkspace,
-		execTimeout,
-		cfg,
-	)
-	if err != nil {
-		return fmt.Errorf("error restarting cron service: %w", err)
+	if err := restartSchedulers(al, runningServices, msgBus); err != nil {
+		return err
 	}
-	if err = runningServices.CronService.Start(); err != nil {
-		return fmt.Errorf("error restarting cron service: %w", err)
+
+	reloadCtx, cancel := context.WithTimeout(context.Background(), serviceShutdownTimeout)
+	defer cancel()
+	if !runningServices.ChannelManager.Reload(reloadCtx, cfg) {
+		return fmt.Errorf("error reloading channels: the channel manager must be restarted")
 	}
-	fmt.Println("  ✓ Cron service restarted")
+	fmt.Println("  ✓ Channels reloaded")
+	runningServices.RecapService = startRecapService(cfg, al, msgBus, runningServices.ChannelManager)
+	applySchedulerPause(runningServices)
 
-	runningServices.HeartbeatService = heartbeat.NewHeartbeatService(
-		cfg.WorkspacePath(),
-		cfg.Heartbeat.Interval,
-		cfg.Heartbeat.Enabled,
-	)
-	runningServices.HeartbeatService.SetBus(msgBus)
-	runningServices.HeartbeatService.SetHandler(createHeartbeatHandler(al))
-	if err = runningServices.HeartbeatService.Start(); err != nil {
-		return fmt.Errorf("error restarting heartbeat service: %w", err)
+	restartDevices(al, runningServices, msgBus)
+	return nil
+}
+
+func restartServices(
+	al *agent.AgentLoop,
+	runningServices *services,
+	msgBus *bus.MessageBus,
+) error {
+	cfg := al.GetConfig()
+	if err := startSchedulerLease(cfg, runningServices); err != nil {
+		return err
+	}
+	if err := restartSchedulers(al, runningServices, msgBus); err != nil {
+		return err
 	}
-	fmt.Println("  ✓ Heartbeat service restarted")
 
 	runningServices.MediaStore = media.NewFileMediaStoreWithCleanup(media.MediaCleanerConfig{
 		Enabled:  cfg.Tools.MediaCleanup.Enabled,
@@ -412,11 +694,15 @@ func restartServices(
 	}
 	al.SetMediaStore(runningServices.MediaStore)
 
+	var err error
 	runningServices.ChannelManager, err = channels.NewManager(cfg, msgBus, runningServices.MediaStore)
 	if err != nil {
 		return fmt.Errorf("error recreating channel manager: %w", err)
 	}
 	al.SetChannelManager(runningServices.ChannelManager)
+	runningServices.RecapService = startRecapService(cfg, al, msgBus, runningServices.ChannelManager)
+	applySchedulerPause(runningServices)
+	registerChannels(al, msgBus, runningServices)
 
 	enabledChannels := runningServices.ChannelManager.GetEnabledChannels()
 	if len(enabledChannels) > 0 {
@@ -427,7 +713,19 @@ func restartServices(
 
 	addr := fmt.Sprintf("%s:%d", cfg.Gateway.Host, cfg.Gateway.Port)
 	runningServices.HealthServer = health.NewServer(cfg.Gateway.Host, cfg.Gateway.Port)
+	runningServices.HealthServer.RegisterMetrics("providers", func() any { return common.Stats() })
 	runningServices.ChannelManager.SetupHTTPServer(addr, runningServices.HealthServer)
+	runningServices.ChannelManager.Handle(maintenance.HTTPPath, runningServices.Maintenance)
+	registerTranscripts(cfg, al, runningServices.ChannelManager)
+	registerErasure(cfg, al, runningServices.ChannelManager)
+	registerUsage(al, runningServices.ChannelManager)
+	registerFeedback(al, runningServices.ChannelManager)
+	registerConversations(cfg, al, runningServices.ChannelManager)
+	registerTenants(al, runningServices.ChannelManager)
+	registerAdmin(cfg, al, runningServices)
+	registerGitHubWebhook(cfg, al, msgBus, runningServices.ChannelManager)
+	registerEventWebhooks(cfg, al, msgBus, runningServices.ChannelManager)
+	registerCalendarLinker(cfg, al, runningServices.ChannelManager)
 
 	if err = runningServices.ChannelManager.StartAll(context.Background()); err != nil {
 		return fmt.Errorf("error restarting channels: %w", err)
@@ -438,6 +736,65 @@ func restartServices(
 		cfg.Gateway.Port,
 	)
 
+	restartDevices(al, runningServices, msgBus)
+	return nil
+}
+
+// restartSchedulers restarts cron, heartbeats, digests, feeds, and the
+// knowledge sync with the agent's config.
+func restartSchedulers(
+	al *agent.AgentLoop,
+	runningServices *services,
+	msgBus *bus.MessageBus,
+) error {
+	cfg := al.GetConfig()
+
+	execTimeout := time.Duration(cfg.Tools.Cron.ExecTimeoutMinutes) * time.Minute
+	var err error
+	runningServices.CronService, err = setupCronTool(
+		al,
+		msgBus,
+		cfg.WorkspacePath(),
+		cfg.Agents.Defaults.RestrictToWorkspace,
+		execTimeout,
+		cfg,
+	)
+	if err != nil {
+		return fmt.Errorf("error restarting cron service: %w", err)
+	}
+	applySchedulerPause(runningServices)
+	if err = runningServices.CronService.Start(); err != nil {
+		return fmt.Errorf("error restarting cron service: %w", err)
+	}
+	fmt.Println("  ✓ Cron service restarted")
+
+	runningServices.HeartbeatService = heartbeat.NewHeartbeatService(
+		cfg.WorkspacePath(),
+		cfg.Heartbeat.Interval,
+		cfg.Heartbeat.Enabled,
+	)
+	runningServices.HeartbeatService.SetBus(msgBus)
+	runningServices.HeartbeatService.SetHandler(createHeartbeatHandler(al))
+	applySchedulerPause(runningServices)
+	if err = runningServices.HeartbeatService.Start(); err != nil {
+		return fmt.Errorf("error restarting heartbeat service: %w", err)
+	}
+	fmt.Println("  ✓ Heartbeat service restarted")
+
+	runningServices.DigestService = startDigestService(cfg, msgBus)
+	runningServices.FeedService = startFeedService(cfg, al, msgBus)
+	runningServices.KnowledgeService = startKnowledgeService(cfg)
+	runningServices.Maintenance.Configure(cfg.Maintenance)
+	runningServices.Maintenance.SetAdminToken(cfg.Admin.APIToken)
+	applySchedulerPause(runningServices)
+	return nil
+}
+
+// restartDevices restarts the device event service and re-detects the
+// transcriber with the agent's config.
+func restartDevices(al *agent.AgentLoop, runningServices *services, msgBus *bus.MessageBus) {
+	cfg := al.GetConfig()
+
 	stateManager := state.NewManager(cfg.WorkspacePath())
 	runningServices.DeviceService = devices.NewService(devices.Config{
 		Enabled:    cfg.Devices.Enabled,
@@ -457,8 +814,254 @@ func restartServices(
 	} else {
 		logger.InfoCF("voice", "Transcription disabled", nil)
 	}
+	al.SetSynthesizer(voice.DetectSynthesizer(cfg))
+}
+
+// pauseScheduler pauses or resumes cron jobs, heartbeats, feeds, and recaps.
+// applySchedulerPause decides when.
+func pauseScheduler(runningServices *services, paused bool) {
+	if runningServices.CronService != nil {
+		runningServices.CronService.SetPaused(paused)
+	}
+	if runningServices.HeartbeatService != nil {
+		runningServices.HeartbeatService.SetPaused(paused)
+	}
+	if runningServices.FeedService != nil {
+		runningServices.FeedService.SetPaused(paused)
+	}
+	if runningServices.RecapService != nil {
+		runningServices.RecapService.SetPaused(paused)
+	}
+}
 
-	return nil
+// startDigestService schedules the usage digest. A misconfigured digest is
+// logged rather than failing the gateway, since it is not needed to serve chats.
+func startDigestService(cfg *config.Config, msgBus *bus.MessageBus) *digest.Service {
+	svc := digest.NewService(cfg.Digest, cfg.WorkspacePath(), digest.PricingFromConfig(cfg))
+	svc.SetBus(msgBus)
+	if err := svc.Start(); err != nil {
+		logger.WarnCF("digest", "Usage digest not started", map[string]any{"error": err.Error()})
+		return nil
+	}
+	if cfg.Digest.Enabled {
+		fmt.Println("✓ Usage digest scheduled")
+	}
+	return svc
+}
+
+// startFeedService starts the RSS/Atom watcher, summarizing entries with the
+// default agent. Like the digest, a bad feed config is logged, not fatal.
+func startFeedService(cfg *config.Config, agentLoop *agent.AgentLoop, msgBus *bus.MessageBus) *feeds.Service {
+	svc := feeds.NewService(cfg.Feeds, cfg.WorkspacePath(), func(ctx context.Context, prompt string) (string, error) {
+		return agentLoop.ProcessBackground(ctx, prompt, "feeds")
+	})
+	if cfg.Feeds.Digest.Batch {
+		svc.SetDigestSummarizer(func(ctx context.Context, prompt string) (string, error) {
+			return agentLoop.ProcessDeferred(ctx, prompt, "feeds")
+		})
+	}
+	svc.SetBus(msgBus)
+	if err := svc.Start(); err != nil {
+		logger.WarnCF("feeds", "Feed watcher not started", map[string]any{"error": err.Error()})
+		return nil
+	}
+	if cfg.Feeds.Enabled {
+		fmt.Printf("✓ Watching %d feeds\n", len(cfg.Feeds.Sources))
+	}
+	return svc
+}
+
+// startRecapService schedules the daily chat recaps. Chats are read through
+// the channel manager and summarized by the default agent.
+func startRecapService(
+	cfg *config.Config,
+	agentLoop *agent.AgentLoop,
+	msgBus *bus.MessageBus,
+	cm *channels.Manager,
+) *recap.Service {
+	history := func(ctx context.Context, channel, chatID string, since time.Time, limit int) ([]recap.Message, error) {
+		msgs, err := cm.ReadHistory(ctx, channel, chatID, since, limit)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]recap.Message, 0, len(msgs))
+		for _, m := range msgs {
+			out = append(out, recap.Message{Time: m.Time, Author: m.Author, Text: m.Text})
+		}
+		return out, nil
+	}
+	svc := recap.NewService(cfg.Recaps, history, func(ctx context.Context, prompt string) (string, error) {
+		return agentLoop.ProcessBackground(ctx, prompt, "recaps")
+	})
+	svc.SetBus(msgBus)
+	if err := svc.Start(); err != nil {
+		logger.WarnCF("recap", "Daily recaps not started", map[string]any{"error": err.Error()})
+		return nil
+	}
+	if cfg.Recaps.Enabled {
+		fmt.Printf("✓ Daily recaps scheduled for %d chats\n", len(cfg.Recaps.Targets))
+	}
+	return svc
+}
+
+// startKnowledgeService syncs Notion and Confluence pages into the index
+// searched by the knowledge_search tool. Sync errors are logged, not fatal.
+func startKnowledgeService(cfg *config.Config) *knowledge.Service {
+	svc := knowledge.NewService(cfg.Knowledge, cfg.WorkspacePath())
+	if err := svc.Start(); err != nil {
+		logger.WarnCF("knowledge", "Knowledge sync not started", map[string]any{"error": err.Error()})
+		return nil
+	}
+	if cfg.Knowledge.Enabled {
+		fmt.Println("✓ Knowledge sync started")
+	}
+	return svc
+}
+
+// registerGitHubWebhook mounts the GitHub webhook handler on the shared HTTP
+// server. Summaries come from the default agent without chat history.
+func registerGitHubWebhook(
+	cfg *config.Config,
+	agentLoop *agent.AgentLoop,
+	msgBus *bus.MessageBus,
+	channelManager *channels.Manager,
+) {
+	if !cfg.GitHubWebhook.Enabled {
+		return
+	}
+	handler, err := githook.NewHandler(cfg.GitHubWebhook, func(ctx context.Context, prompt string) (string, error) {
+		return agentLoop.ProcessBackground(ctx, prompt, "github")
+	})
+	if err != nil {
+		logger.WarnCF("github", "GitHub webhook not enabled", map[string]any{"error": err.Error()})
+		return
+	}
+	handler.SetBus(msgBus)
+	channelManager.Handle(handler.Path(), handler)
+	fmt.Printf("✓ GitHub webhook available at http://%s:%d%s\n", cfg.Gateway.Host, cfg.Gateway.Port, handler.Path())
+}
+
+// registerEventWebhooks mounts the JSON event webhooks on the shared HTTP
+// server. Like the GitHub webhook, a bad config is logged, not fatal.
+func registerEventWebhooks(
+	cfg *config.Config,
+	agentLoop *agent.AgentLoop,
+	msgBus *bus.MessageBus,
+	channelManager *channels.Manager,
+) {
+	if !cfg.EventWebhooks.Enabled {
+		return
+	}
+	handler, err := eventhook.NewHandler(cfg.EventWebhooks, func(ctx context.Context, prompt string) (string, error) {
+		return agentLoop.ProcessBackground(ctx, prompt, "webhook")
+	})
+	if err != nil {
+		logger.WarnCF("webhook", "Event webhooks not enabled", map[string]any{"error": err.Error()})
+		return
+	}
+	handler.SetBus(msgBus)
+	channelManager.Handle(handler.Path(), handler)
+	fmt.Printf("✓ %d event webhooks available at http://%s:%d%s<name>\n",
+		len(cfg.EventWebhooks.Hooks), cfg.Gateway.Host, cfg.Gateway.Port, handler.Path())
+}
+
+// registerTranscripts mounts the tool-call transcripts API when transcripts
+// are enabled. The admin API token authorizes it too.
+func registerTranscripts(cfg *config.Config, agentLoop *agent.AgentLoop, channelManager *channels.Manager) {
+	if store := agentLoop.Transcripts(); store != nil {
+		store.SetAdminToken(cfg.Admin.APIToken)
+		channelManager.Handle(transcript.HTTPPath, store)
+	}
+}
+
+// registerErasure mounts the data-subject deletion API when it or the admin
+// API has a token.
+func registerErasure(cfg *config.Config, agentLoop *agent.AgentLoop, channelManager *channels.Manager) {
+	if tokens := cfg.APITokens(cfg.Erasure.APIToken); len(tokens) > 0 {
+		channelManager.Handle(erasure.HTTPPath, erasure.NewHandler(tokens, agentLoop.ForgetUser))
+	}
+}
+
+// registerUsage mounts the usage report API when it has a token.
+func registerUsage(agentLoop *agent.AgentLoop, channelManager *channels.Manager) {
+	if handler := agentLoop.UsageAPI(); handler != nil {
+		channelManager.Handle(usage.HTTPPath, handler)
+	}
+}
+
+// registerFeedback mounts the feedback export API when it has a token.
+func registerFeedback(agentLoop *agent.AgentLoop, channelManager *channels.Manager) {
+	if handler := agentLoop.FeedbackAPI(); handler != nil {
+		channelManager.Handle(feedback.HTTPPath, handler)
+	}
+}
+
+// registerConversations mounts the conversation export and import API when
+// it or the admin API has a token.
+func registerConversations(cfg *config.Config, agentLoop *agent.AgentLoop, channelManager *channels.Manager) {
+	if tokens := cfg.APITokens(cfg.Conversations.APIToken); len(tokens) > 0 {
+		channelManager.Handle(chatexport.HTTPPath,
+			chatexport.NewHandler(tokens, agentLoop.ExportConversation, agentLoop.ImportConversation))
+	}
+}
+
+// registerTenants mounts the tenants admin API in multi-tenant mode.
+func registerTenants(agentLoop *agent.AgentLoop, channelManager *channels.Manager) {
+	if handler := agentLoop.TenantsAPI(); handler != nil {
+		channelManager.Handle(tenant.HTTPPath, handler)
+		channelManager.Handle(tenant.HTTPPath+"/", handler)
+	}
+}
+
+// registerAdmin mounts the admin API when it has a token, and the
+// dashboard on it when enabled.
+func registerAdmin(cfg *config.Config, agentLoop *agent.AgentLoop, runningServices *services) {
+	handler := agentLoop.AdminAPI(runningServices.Admin)
+	if handler == nil {
+		if cfg.Dashboard.Enabled {
+			logger.WarnCF("dashboard", "Dashboard not served: it needs admin.api_token", nil)
+		}
+		return
+	}
+	runningServices.ChannelManager.Handle(admin.HTTPPath, handler)
+	runningServices.ChannelManager.Handle(admin.HTTPPath+"/", handler)
+	if cfg.Dashboard.Enabled {
+		runningServices.ChannelManager.Handle(dashboard.HTTPPath, dashboard.Handler())
+		fmt.Printf("✓ Dashboard available at http://%s:%d%s\n", cfg.Gateway.Host, cfg.Gateway.Port, dashboard.HTTPPath)
+	}
+}
+
+// registerChannels adds the channels added with AddChannel and those of
+// the running plugins, starting the plugins on first use. A configured
+// channel of the same name wins.
+func registerChannels(agentLoop *agent.AgentLoop, msgBus *bus.MessageBus, runningServices *services) {
+	channelManager := runningServices.ChannelManager
+	extra := slices.Concat(runningServices.ExtraChannels, agentLoop.StartPlugins(context.Background()).Channels(msgBus))
+	for _, ch := range extra {
+		if _, exists := channelManager.GetChannel(ch.Name()); exists {
+			logger.WarnCF("gateway", "Channel has the name of a configured channel, ignoring it",
+				map[string]any{"channel": ch.Name()})
+			continue
+		}
+		channelManager.RegisterChannel(ch.Name(), ch)
+	}
+}
+
+// registerCalendarLinker mounts the Google OAuth callback used by
+// /calendar link and hands the linker to the calendar tool.
+func registerCalendarLinker(cfg *config.Config, agentLoop *agent.AgentLoop, channelManager *channels.Manager) {
+	calCfg := cfg.Tools.Calendar
+	if !calCfg.Enabled || (calCfg.Provider != "" && !strings.EqualFold(calCfg.Provider, "google")) {
+		return
+	}
+	linker, err := calendar.NewLinker(calCfg.Google, cfg.WorkspacePath())
+	if err != nil {
+		logger.WarnCF("calendar", "Google Calendar linking not enabled", map[string]any{"error": err.Error()})
+		return
+	}
+	agentLoop.SetCalendarLinker(linker)
+	channelManager.Handle(linker.Path(), linker)
+	fmt.Printf("✓ Calendar OAuth callback available at http://%s:%d%s\n", cfg.Gateway.Host, cfg.Gateway.Port, linker.Path())
 }
 
 func setupConfigWatcherPolling(configPath string, debug bool) (chan *config.Config, func()) {
@@ -551,19 +1154,25 @@ func setupCronTool(
 	execTimeout time.Duration,
 	cfg *config.Config,
 ) (*cron.CronService, error) {
-	cronStorePath := filepath.Join(workspace, "cron", "jobs.json")
+	cronStorePath := cron.Path(workspace)
 
 	cronService := cron.NewCronService(cronStorePath, nil)
 
 	var cronTool *tools.CronTool
-	if cfg.Tools.IsToolEnabled("cron") {
+	enabled := cfg.Tools.IsToolEnabled("cron")
+	if enabled || len(cfg.Tools.Cron.Jobs) > 0 {
 		var err error
 		cronTool, err = tools.NewCronTool(cronService, agentLoop, msgBus, workspace, restrict, execTimeout, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("critical error during CronTool initialization: %w", err)
 		}
 
-		agentLoop.RegisterTool(cronTool)
+		if enabled {
+			agentLoop.RegisterTool(cronTool)
+		}
+	}
+	if err := cronService.SyncConfiguredJobs(configuredCronJobs(cfg.Tools.Cron.Jobs)); err != nil {
+		return nil, fmt.Errorf("error saving configured cron jobs: %w", err)
 	}
 
 	if cronTool != nil {
@@ -572,10 +1181,62 @@ func setupCronTool(
 			return result, nil
 		})
 	}
+	agentLoop.SetCronService(cronService)
 
 	return cronService, nil
 }
 
+// configuredCronJobs turns the jobs of tools.cron.jobs into cron jobs. Jobs
+// without a name, target chat, prompt, or valid schedule are logged and
+// left out.
+func configuredCronJobs(jobs []config.CronJobConfig) []cron.CronJob {
+	var out []cron.CronJob
+	seen := make(map[string]bool)
+	for _, j := range jobs {
+		name := strings.TrimSpace(j.Name)
+		var schedule cron.CronSchedule
+		var problem string
+		switch {
+		case name == "" || seen[name]:
+			problem = "every job needs a unique name"
+		case j.Channel == "" || j.ChatID == "" || strings.TrimSpace(j.Prompt) == "":
+			problem = "channel, chat_id, and prompt are required"
+		case (j.Cron == "") == (j.EverySeconds <= 0):
+			problem = "set either cron or every_seconds"
+		case j.Cron != "" && !gronx.IsValid(j.Cron):
+			problem = "invalid cron expression"
+		case j.Cron != "":
+			schedule = cron.CronSchedule{Kind: "cron", Expr: j.Cron, TZ: j.Timezone}
+		default:
+			everyMS := int64(j.EverySeconds) * 1000
+			schedule = cron.CronSchedule{Kind: "every", EveryMS: &everyMS}
+		}
+		if problem == "" && j.Timezone != "" {
+			if _, err := time.LoadLocation(j.Timezone); err != nil {
+				problem = "unknown time zone"
+			}
+		}
+		if problem != "" {
+			logger.WarnCF("cron", "Ignoring configured cron job", map[string]any{"name": j.Name, "problem": problem})
+			continue
+		}
+		seen[name] = true
+		out = append(out, cron.CronJob{
+			ID:       cron.ConfiguredJobPrefix + name,
+			Name:     name,
+			Schedule: schedule,
+			Payload: cron.CronPayload{
+				Kind:    "agent_turn",
+				Message: j.Prompt,
+				Deliver: j.Deliver,
+				Channel: j.Channel,
+				To:      j.ChatID,
+			},
+		})
+	}
+	return out
+}
+
 func createHeartbeatHandler(agentLoop *agent.AgentLoop) func(prompt, channel, chatID string) *tools.ToolResult {
 	return func(prompt, channel, chatID string) *tools.ToolResult {
 		if channel == "" || chatID == "" {
@@ -0,0 +1,83 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/leader"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// schedulerLeaseName is the lease held by the replica running the
+// scheduled jobs.
+const schedulerLeaseName = "scheduler"
+
+// schedulerLease campaigns for the scheduler lease when gateway HA is
+// enabled. Cron jobs, heartbeats, digests, feeds, and recaps run only on its
+// holder, so replicas sharing a workspace do not fire them twice.
+type schedulerLease struct {
+	elector *leader.Elector
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// startSchedulerLease starts campaigning for the scheduler lease when HA is
+// enabled. The scheduled jobs stay paused until the lease is won.
+func startSchedulerLease(cfg *config.Config, runningServices *services) error {
+	ha := cfg.Gateway.HA
+	if !ha.Enabled {
+		return nil
+	}
+	lock, err := leader.NewLock(ha)
+	if err != nil {
+		return fmt.Errorf("error creating scheduler lease: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	lease := &schedulerLease{
+		elector: leader.NewElector(lock, leader.LeaseKey(ha, schedulerLeaseName), leader.Identity(ha),
+			leader.LeaseDuration(ha)),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	lease.elector.OnStartedLeading = func(context.Context) {
+		logger.InfoCF("gateway", "Running the scheduled jobs on this replica", nil)
+		applySchedulerPause(runningServices)
+	}
+	lease.elector.OnStoppedLeading = func() {
+		logger.InfoCF("gateway", "Scheduler lease lost, pausing the scheduled jobs", nil)
+		applySchedulerPause(runningServices)
+	}
+	runningServices.SchedulerLease = lease
+	go func() {
+		defer close(lease.done)
+		lease.elector.Run(ctx)
+	}()
+	return nil
+}
+
+// standby reports whether another replica runs the scheduled jobs, or none
+// has been elected yet. A nil lease, without HA, is never on standby.
+func (l *schedulerLease) standby() bool {
+	return l != nil && !l.elector.IsLeader()
+}
+
+// stop releases the lease so another replica takes the jobs over right away.
+func (l *schedulerLease) stop() {
+	if l == nil {
+		return
+	}
+	l.cancel()
+	<-l.done
+}
+
+// applySchedulerPause pauses the scheduled jobs while maintenance mode is on
+// or another replica holds the scheduler lease. Digests are not paused for
+// maintenance, since they do not talk to the users.
+func applySchedulerPause(runningServices *services) {
+	standby := runningServices.SchedulerLease.standby()
+	pauseScheduler(runningServices, standby || runningServices.Maintenance.Active())
+	if runningServices.DigestService != nil {
+		runningServices.DigestService.SetPaused(standby)
+	}
+}
@@ -0,0 +1,262 @@
+package githook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Event names handled by the webhook, as sent in X-GitHub-Event.
+const (
+	EventPush         = "push"
+	EventPullRequest  = "pull_request"
+	EventIssueComment = "issue_comment"
+)
+
+const (
+	maxBodyRunes = 4000
+	maxCommits   = 20
+)
+
+// Event is a parsed webhook delivery that should be posted.
+type Event struct {
+	Name string
+	Repo string
+	Data any    // PushData, PullRequestData, or CommentData
+	Text string // plain notification used when the LLM is unavailable
+}
+
+// Commit is one commit in a push.
+type Commit struct {
+	ID      string
+	Message string
+	Author  string
+	URL     string
+}
+
+// PushData is available to the push prompt template.
+type PushData struct {
+	Repo    string
+	Branch  string
+	Pusher  string
+	Forced  bool
+	Compare string
+	Commits []Commit
+	More    int // commits left out of Commits
+}
+
+// PullRequestData is available to the pull_request prompt template.
+type PullRequestData struct {
+	Repo         string
+	Number       int
+	Title        string
+	Body         string
+	URL          string
+	Author       string
+	Base         string
+	Head         string
+	Draft        bool
+	Additions    int
+	Deletions    int
+	ChangedFiles int
+}
+
+// CommentData is available to the issue_comment prompt template.
+type CommentData struct {
+	Repo          string
+	Number        int
+	IssueTitle    string
+	IsPullRequest bool
+	Author        string
+	Body          string
+	URL           string
+}
+
+type repository struct {
+	FullName string `json:"full_name"`
+}
+
+type user struct {
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+type pushPayload struct {
+	Ref     string     `json:"ref"`
+	Deleted bool       `json:"deleted"`
+	Forced  bool       `json:"forced"`
+	Compare string     `json:"compare"`
+	Repo    repository `json:"repository"`
+	Pusher  struct {
+		Name string `json:"name"`
+	} `json:"pusher"`
+	Commits []struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+		URL     string `json:"url"`
+		Author  struct {
+			Name     string `json:"name"`
+			Username string `json:"username"`
+		} `json:"author"`
+	} `json:"commits"`
+}
+
+type pullRequestPayload struct {
+	Action      string     `json:"action"`
+	Repo        repository `json:"repository"`
+	PullRequest struct {
+		Number       int    `json:"number"`
+		Title        string `json:"title"`
+		Body         string `json:"body"`
+		HTMLURL      string `json:"html_url"`
+		Draft        bool   `json:"draft"`
+		Additions    int    `json:"additions"`
+		Deletions    int    `json:"deletions"`
+		ChangedFiles int    `json:"changed_files"`
+		User         user   `json:"user"`
+		Base         struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+		Head struct {
+			Ref string `json:"ref"`
+		} `json:"head"`
+	} `json:"pull_request"`
+}
+
+type issueCommentPayload struct {
+	Action string     `json:"action"`
+	Repo   repository `json:"repository"`
+	Issue  struct {
+		Number      int             `json:"number"`
+		Title       string          `json:"title"`
+		PullRequest json.RawMessage `json:"pull_request"`
+	} `json:"issue"`
+	Comment struct {
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+		User    user   `json:"user"`
+	} `json:"comment"`
+}
+
+// ParseEvent decodes a delivery. It returns nil without error for events and
+// actions that are not posted, such as edited comments or branch deletions.
+func ParseEvent(name string, body []byte) (*Event, error) {
+	switch name {
+	case EventPush:
+		return parsePush(body)
+	case EventPullRequest:
+		return parsePullRequest(body)
+	case EventIssueComment:
+		return parseIssueComment(body)
+	default:
+		return nil, nil
+	}
+}
+
+func parsePush(body []byte) (*Event, error) {
+	var p pushPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	if p.Deleted || len(p.Commits) == 0 {
+		return nil, nil
+	}
+
+	data := PushData{
+		Repo:    p.Repo.FullName,
+		Branch:  strings.TrimPrefix(strings.TrimPrefix(p.Ref, "refs/heads/"), "refs/tags/"),
+		Pusher:  p.Pusher.Name,
+		Forced:  p.Forced,
+		Compare: p.Compare,
+	}
+	for i, c := range p.Commits {
+		if i == maxCommits {
+			data.More = len(p.Commits) - maxCommits
+			break
+		}
+		author := c.Author.Username
+		if author == "" {
+			author = c.Author.Name
+		}
+		id := c.ID
+		if len(id) > 7 {
+			id = id[:7]
+		}
+		data.Commits = append(data.Commits, Commit{
+			ID:      id,
+			Message: utils.Truncate(c.Message, 500),
+			Author:  author,
+			URL:     c.URL,
+		})
+	}
+
+	noun := "commits"
+	if len(p.Commits) == 1 {
+		noun = "commit"
+	}
+	return &Event{
+		Name: EventPush,
+		Repo: data.Repo,
+		Data: data,
+		Text: fmt.Sprintf("[%s] %s pushed %d %s to %s\n%s", data.Repo, data.Pusher, len(p.Commits), noun, data.Branch, data.Compare),
+	}, nil
+}
+
+func parsePullRequest(body []byte) (*Event, error) {
+	var p pullRequestPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	if p.Action != "opened" {
+		return nil, nil
+	}
+	pr := p.PullRequest
+	data := PullRequestData{
+		Repo:         p.Repo.FullName,
+		Number:       pr.Number,
+		Title:        pr.Title,
+		Body:         utils.Truncate(pr.Body, maxBodyRunes),
+		URL:          pr.HTMLURL,
+		Author:       pr.User.Login,
+		Base:         pr.Base.Ref,
+		Head:         pr.Head.Ref,
+		Draft:        pr.Draft,
+		Additions:    pr.Additions,
+		Deletions:    pr.Deletions,
+		ChangedFiles: pr.ChangedFiles,
+	}
+	return &Event{
+		Name: EventPullRequest,
+		Repo: data.Repo,
+		Data: data,
+		Text: fmt.Sprintf("[%s] PR #%d opened by %s: %s\n%s", data.Repo, data.Number, data.Author, data.Title, data.URL),
+	}, nil
+}
+
+func parseIssueComment(body []byte) (*Event, error) {
+	var p issueCommentPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	// Skip edits, deletions, and bot comments (including our own integrations).
+	if p.Action != "created" || p.Comment.User.Type == "Bot" {
+		return nil, nil
+	}
+	data := CommentData{
+		Repo:          p.Repo.FullName,
+		Number:        p.Issue.Number,
+		IssueTitle:    p.Issue.Title,
+		IsPullRequest: len(p.Issue.PullRequest) > 0 && string(p.Issue.PullRequest) != "null",
+		Author:        p.Comment.User.Login,
+		Body:          utils.Truncate(p.Comment.Body, maxBodyRunes),
+		URL:           p.Comment.HTMLURL,
+	}
+	return &Event{
+		Name: EventIssueComment,
+		Repo: data.Repo,
+		Data: data,
+		Text: fmt.Sprintf("[%s] %s commented on #%d: %s\n%s", data.Repo, data.Author, data.Number, data.IssueTitle, data.URL),
+	}, nil
+}
@@ -0,0 +1,179 @@
+package githook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const secret = "s3cret"
+
+const prOpened = `{
+  "action": "opened",
+  "repository": {"full_name": "sipeed/picoclaw"},
+  "pull_request": {
+    "number": 42, "title": "Add feeds", "body": "Adds an RSS watcher.",
+    "html_url": "https://github.com/sipeed/picoclaw/pull/42",
+    "additions": 120, "deletions": 4, "changed_files": 6,
+    "user": {"login": "alice"}, "base": {"ref": "main"}, "head": {"ref": "feeds"}
+  }
+}`
+
+func sign(body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func post(h *Handler, event, body, signature string) int {
+	req := httptest.NewRequest(http.MethodPost, h.Path(), strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-Hub-Signature-256", signature)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code
+}
+
+func newTestHandler(t *testing.T, summarize Summarizer, routes ...config.GitHubRoute) (*Handler, *bus.MessageBus) {
+	t.Helper()
+	h, err := NewHandler(config.GitHubWebhookConfig{Secret: secret, Routes: routes}, summarize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgBus := bus.NewMessageBus()
+	t.Cleanup(msgBus.Close)
+	h.SetBus(msgBus)
+	return h, msgBus
+}
+
+func nextOutbound(t *testing.T, msgBus *bus.MessageBus) bus.OutboundMessage {
+	t.Helper()
+	select {
+	case msg := <-msgBus.OutboundChan():
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing was posted")
+		return bus.OutboundMessage{}
+	}
+}
+
+func TestHandler_PullRequestChecklist(t *testing.T) {
+	var prompt string
+	h, msgBus := newTestHandler(t, func(_ context.Context, p string) (string, error) {
+		prompt = p
+		return "checklist", nil
+	}, config.GitHubRoute{Repo: "sipeed/*", Channel: "discord", ChatID: "dev"})
+
+	if code := post(h, EventPullRequest, prOpened, sign(prOpened)); code != http.StatusAccepted {
+		t.Fatalf("status %d", code)
+	}
+	msg := nextOutbound(t, msgBus)
+	if msg.Channel != "discord" || msg.ChatID != "dev" || msg.Content != "checklist" {
+		t.Fatalf("posted %+v", msg)
+	}
+	for _, want := range []string{"#42", "review checklist", "feeds -> main", "+120 -4 in 6 files", "Adds an RSS watcher."} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestHandler_RejectsBadSignature(t *testing.T) {
+	h, _ := newTestHandler(t, nil, config.GitHubRoute{Repo: "*", Channel: "c", ChatID: "1"})
+	if code := post(h, EventPullRequest, prOpened, sign("other body")); code != http.StatusForbidden {
+		t.Errorf("status %d, want 403", code)
+	}
+	if code := post(h, EventPullRequest, prOpened, ""); code != http.StatusForbidden {
+		t.Errorf("unsigned: status %d, want 403", code)
+	}
+}
+
+func TestHandler_FallsBackWhenSummaryFails(t *testing.T) {
+	h, msgBus := newTestHandler(t, func(context.Context, string) (string, error) {
+		return "", errors.New("provider down")
+	}, config.GitHubRoute{Repo: "sipeed/picoclaw", Events: []string{EventPullRequest}, Channel: "c", ChatID: "1"})
+
+	post(h, EventPullRequest, prOpened, sign(prOpened))
+	msg := nextOutbound(t, msgBus)
+	want := "[sipeed/picoclaw] PR #42 opened by alice: Add feeds\nhttps://github.com/sipeed/picoclaw/pull/42"
+	if msg.Content != want {
+		t.Fatalf("posted %q, want %q", msg.Content, want)
+	}
+}
+
+func TestHandler_Routing(t *testing.T) {
+	h, _ := newTestHandler(t, nil,
+		config.GitHubRoute{Repo: "sipeed/picoclaw", Events: []string{EventPush}, Channel: "a", ChatID: "1"},
+		config.GitHubRoute{Repo: "sipeed/*", Channel: "b", ChatID: "2"},
+		config.GitHubRoute{Repo: "other/*", Channel: "c", ChatID: "3"},
+	)
+	got := h.match(&Event{Name: EventPullRequest, Repo: "Sipeed/PicoClaw"})
+	if len(got) != 1 || got[0].Channel != "b" {
+		t.Fatalf("match = %+v", got)
+	}
+	if got := h.match(&Event{Name: EventPush, Repo: "sipeed/picoclaw"}); len(got) != 2 {
+		t.Fatalf("push match = %+v", got)
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	push := `{"ref":"refs/heads/main","compare":"https://github.com/o/r/compare/a...b",
+	  "repository":{"full_name":"o/r"},"pusher":{"name":"bob"},
+	  "commits":[{"id":"0123456789abcdef","message":"Fix bug","author":{"name":"Bob","username":"bob"}}]}`
+	ev, err := ParseEvent(EventPush, []byte(push))
+	if err != nil || ev == nil {
+		t.Fatalf("push: %v %v", ev, err)
+	}
+	data := ev.Data.(PushData)
+	if data.Branch != "main" || data.Commits[0].ID != "0123456" || data.Commits[0].Author != "bob" {
+		t.Errorf("push data = %+v", data)
+	}
+	if ev.Text != "[o/r] bob pushed 1 commit to main\nhttps://github.com/o/r/compare/a...b" {
+		t.Errorf("push text = %q", ev.Text)
+	}
+
+	comment := `{"action":"created","repository":{"full_name":"o/r"},
+	  "issue":{"number":5,"title":"Crash","pull_request":{"url":"x"}},
+	  "comment":{"body":"LGTM","html_url":"https://github.com/o/r/pull/5#c1","user":{"login":"carol","type":"User"}}}`
+	ev, err = ParseEvent(EventIssueComment, []byte(comment))
+	if err != nil || ev == nil || !ev.Data.(CommentData).IsPullRequest {
+		t.Fatalf("comment: %+v %v", ev, err)
+	}
+
+	ignored := map[string]string{
+		EventPush:         `{"ref":"refs/heads/x","deleted":true,"commits":[]}`,
+		EventPullRequest:  `{"action":"closed"}`,
+		EventIssueComment: `{"action":"created","comment":{"user":{"login":"ci","type":"Bot"}}}`,
+		"ping":            `{"zen":"Keep it logically awesome."}`,
+	}
+	for name, body := range ignored {
+		if ev, err := ParseEvent(name, []byte(body)); ev != nil || err != nil {
+			t.Errorf("%s should be ignored, got %+v %v", name, ev, err)
+		}
+	}
+}
+
+func TestNewHandler_Validates(t *testing.T) {
+	route := config.GitHubRoute{Repo: "*", Channel: "c", ChatID: "1"}
+	tests := []config.GitHubWebhookConfig{
+		{Routes: []config.GitHubRoute{route}},
+		{Secret: secret, Routes: []config.GitHubRoute{{Repo: "*"}}},
+		{Secret: secret, Routes: []config.GitHubRoute{{Repo: "*", Channel: "c", ChatID: "1", Events: []string{"release"}}}},
+		{Secret: secret, Prompts: map[string]string{EventPush: "{{.Repo"}},
+	}
+	for _, cfg := range tests {
+		if _, err := NewHandler(cfg, nil); err == nil {
+			t.Errorf("NewHandler accepted %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Package githook receives GitHub webhooks and posts LLM summaries of pushes,
+// new pull requests, and issue comments to the chats mapped to a repository.
+package githook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// DefaultPath is where the handler is mounted when no path is configured.
+const DefaultPath = "/webhooks/github"
+
+// DefaultPrompts are the templates used for events without a configured prompt.
+var DefaultPrompts = map[string]string{
+	EventPush: `Summarize this push to {{.Repo}} for a team chat in two to four sentences: ` +
+		`what changed and why, based on the commit messages. End with the compare link. Do not use any tools.
+
+Branch: {{.Branch}}{{if .Forced}} (force-pushed){{end}}
+Pushed by: {{.Pusher}}
+Commits:
+{{range .Commits}}- {{.ID}} {{.Message}} ({{.Author}})
+{{end}}{{if .More}}...and {{.More}} more
+{{end}}
+Compare: {{.Compare}}`,
+
+	EventPullRequest: `Pull request #{{.Number}} was opened in {{.Repo}}. Write a one-paragraph summary, ` +
+		`then a review checklist of five to eight concrete things reviewers should check, ` +
+		`based on the description and size. End with the link. Do not use any tools.
+
+Title: {{.Title}}{{if .Draft}} (draft){{end}}
+Author: {{.Author}}
+Branch: {{.Head}} -> {{.Base}}
+Size: +{{.Additions}} -{{.Deletions}} in {{.ChangedFiles}} files
+
+{{.Body}}
+
+Link: {{.URL}}`,
+
+	EventIssueComment: `Summarize this new comment on {{if .IsPullRequest}}pull request{{else}}issue{{end}} ` +
+		`#{{.Number}} ({{.IssueTitle}}) in {{.Repo}} in one or two sentences for a team chat. ` +
+		`End with the link. Do not use any tools.
+
+Author: {{.Author}}
+
+{{.Body}}
+
+Link: {{.URL}}`,
+}
+
+const (
+	maxPayloadBytes  = 5 << 20
+	summarizeTimeout = 2 * time.Minute
+	maxConcurrent    = 4
+)
+
+// Summarizer turns a rendered prompt into the text posted for an event.
+type Summarizer func(ctx context.Context, prompt string) (string, error)
+
+// Handler is the http.Handler for GitHub webhook deliveries.
+type Handler struct {
+	path      string
+	secret    []byte
+	routes    []config.GitHubRoute
+	prompts   map[string]*template.Template
+	summarize Summarizer
+	sem       chan struct{}
+
+	mu  sync.RWMutex
+	bus *bus.MessageBus
+}
+
+// NewHandler validates cfg and builds a handler. summarize may be nil, in
+// which case a plain notification is posted.
+func NewHandler(cfg config.GitHubWebhookConfig, summarize Summarizer) (*Handler, error) {
+	if cfg.Secret == "" {
+		return nil, errors.New("github_webhook: secret is required")
+	}
+	for i, r := range cfg.Routes {
+		if r.Repo == "" || r.Channel == "" || r.ChatID == "" {
+			return nil, fmt.Errorf("github_webhook: route %d needs repo, channel, and chat_id", i)
+		}
+		for _, ev := range r.Events {
+			if _, ok := DefaultPrompts[ev]; !ok {
+				return nil, fmt.Errorf("github_webhook: route %d: unsupported event %q", i, ev)
+			}
+		}
+	}
+
+	h := &Handler{
+		path:      cfg.Path,
+		secret:    []byte(cfg.Secret),
+		routes:    cfg.Routes,
+		prompts:   make(map[string]*template.Template, len(DefaultPrompts)),
+		summarize: summarize,
+		sem:       make(chan struct{}, maxConcurrent),
+	}
+	if h.path == "" {
+		h.path = DefaultPath
+	}
+	for name, text := range DefaultPrompts {
+		if custom, ok := cfg.Prompts[name]; ok && custom != "" {
+			text = custom
+		}
+		tmpl, err := template.New(name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("github_webhook: %s prompt: %w", name, err)
+		}
+		h.prompts[name] = tmpl
+	}
+	return h, nil
+}
+
+// Path returns the path to mount the handler on.
+func (h *Handler) Path() string { return h.path }
+
+// SetBus sets the message bus summaries are posted on.
+func (h *Handler) SetBus(msgBus *bus.MessageBus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.bus = msgBus
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadBytes))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if !h.verifySignature(body, r.Header.Get("X-Hub-Signature-256")) {
+		logger.WarnC("github", "Invalid webhook signature")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	name := r.Header.Get("X-GitHub-Event")
+	event, err := ParseEvent(name, body)
+	if err != nil {
+		logger.WarnCF("github", "Failed to parse webhook payload", map[string]any{
+			"event": name,
+			"error": err.Error(),
+		})
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	// GitHub expects an answer within 10 seconds, so the LLM call happens
+	// after the delivery is acknowledged.
+	w.WriteHeader(http.StatusAccepted)
+	if event == nil {
+		return
+	}
+	routes := h.match(event)
+	if len(routes) == 0 {
+		logger.DebugCF("github", "No route for webhook event", map[string]any{"event": name, "repo": event.Repo})
+		return
+	}
+	logger.InfoCF("github", "Webhook event received", map[string]any{
+		"event":       name,
+		"repo":        event.Repo,
+		"delivery_id": r.Header.Get("X-GitHub-Delivery"),
+	})
+	go h.deliver(event, routes)
+}
+
+// verifySignature checks the sha256=<hex> HMAC GitHub sends for the secret.
+func (h *Handler) verifySignature(body []byte, signature string) bool {
+	sig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// match returns the routes that receive event.
+func (h *Handler) match(event *Event) []config.GitHubRoute {
+	var out []config.GitHubRoute
+	for _, r := range h.routes {
+		if repoMatches(r.Repo, event.Repo) && (len(r.Events) == 0 || slices.Contains(r.Events, event.Name)) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func repoMatches(pattern, repo string) bool {
+	pattern, repo = strings.ToLower(pattern), strings.ToLower(repo)
+	if pattern == "*" || pattern == repo {
+		return true
+	}
+	if owner, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(repo, owner+"/")
+	}
+	return false
+}
+
+func (h *Handler) deliver(event *Event, routes []config.GitHubRoute) {
+	h.sem <- struct{}{}
+	defer func() { <-h.sem }()
+
+	text, err := h.render(event)
+	if err != nil {
+		logger.WarnCF("github", "Summary failed, posting plain notification", map[string]any{
+			"event": event.Name,
+			"repo":  event.Repo,
+			"error": err.Error(),
+		})
+		text = event.Text
+	}
+
+	h.mu.RLock()
+	msgBus := h.bus
+	h.mu.RUnlock()
+	if msgBus == nil {
+		return
+	}
+	for _, r := range routes {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := msgBus.PublishOutbound(ctx, bus.OutboundMessage{
+			Channel: r.Channel,
+			ChatID:  r.ChatID,
+			Content: text,
+		}); err != nil {
+			logger.WarnCF("github", "Failed to post webhook summary", map[string]any{
+				"channel": r.Channel,
+				"chat_id": r.ChatID,
+				"error":   err.Error(),
+			})
+		}
+		cancel()
+	}
+}
+
+func (h *Handler) render(event *Event) (string, error) {
+	if h.summarize == nil {
+		return event.Text, nil
+	}
+	var buf bytes.Buffer
+	if err := h.prompts[event.Name].Execute(&buf, event.Data); err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), summarizeTimeout)
+	defer cancel()
+	text, err := h.summarize(ctx, buf.String())
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", errors.New("empty summary")
+	}
+	return text, nil
+}
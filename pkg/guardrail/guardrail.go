@@ -0,0 +1,278 @@
+// Package guardrail checks user messages and model replies with safety
+// services (Llama Guard, NeMo Guardrails, the OpenAI moderation API, a custom
+// HTTP endpoint, keyword and regex rules, or a local classifier) and decides
+// whether they may go on, go on flagged for review, go on redacted, or are
+// blocked.
+package guardrail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Stages a text is checked at.
+const (
+	StageInput  = "input"  // the user's message, before the model sees it
+	StageOutput = "output" // the model's reply, before the user sees it
+)
+
+// Actions taken on an unsafe text.
+const (
+	ActionBlock  = "block"
+	ActionRedact = "redact" // mask the matched parts, or block without matches
+	ActionFlag   = "flag"
+	ActionOff    = "off" // don't check, in the channels a service is off for
+)
+
+// redactedMark replaces the redacted parts of a text.
+const redactedMark = "[redacted]"
+
+const defaultBudget = 1500 * time.Millisecond
+
+// Request is a text to check.
+type Request struct {
+	Stage    string `json:"stage"`
+	Text     string `json:"text"`
+	Prompt   string `json:"prompt,omitempty"` // the user message a reply answers
+	Channel  string `json:"channel,omitempty"`
+	ChatID   string `json:"chat_id,omitempty"`
+	SenderID string `json:"sender_id,omitempty"`
+}
+
+// Result is a service's judgement of a text. Matches are the parts of the
+// text that made it unsafe, for services that can point them out.
+type Result struct {
+	Unsafe  bool     `json:"unsafe"`
+	Reason  string   `json:"reason,omitempty"`
+	Matches []string `json:"matches,omitempty"`
+}
+
+// Checker is one guardrail service.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context, req Request) (Result, error)
+}
+
+// Verdict is the outcome of checking a text with every service of its stage.
+// A zero Verdict lets the text through.
+type Verdict struct {
+	Action  string // "", ActionFlag, ActionRedact, or ActionBlock
+	Service string // the service that decided it
+	Reason  string
+	Matches []string // the parts ActionRedact masks
+}
+
+// Redact returns text with the matches of v masked where they are not part
+// of a longer word.
+func (v Verdict) Redact(text string) string {
+	matches := slices.Clone(v.Matches)
+	// Longer matches first, so a match inside another is not masked alone.
+	slices.SortFunc(matches, func(a, b string) int { return len(b) - len(a) })
+	patterns := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if m != "" {
+			patterns = append(patterns, wordPattern(m))
+		}
+	}
+	if len(patterns) == 0 {
+		return text
+	}
+	return regexp.MustCompile(strings.Join(patterns, "|")).ReplaceAllLiteralString(text, redactedMark)
+}
+
+// service is a checker with the settings of its config entry.
+type service struct {
+	checker    Checker
+	stages     []string
+	action     string
+	channels   map[string]string // channel name to the action there
+	timeout    time.Duration
+	failClosed bool
+}
+
+// Guard runs the configured services. A nil *Guard lets everything through.
+type Guard struct {
+	services []service
+	budget   time.Duration
+}
+
+// newGuard returns a guard for services.
+func newGuard(budget time.Duration, services ...service) *Guard {
+	if budget <= 0 {
+		budget = defaultBudget
+	}
+	return &Guard{services: services, budget: budget}
+}
+
+// NewGuardFromConfig builds the services listed in cfg. It returns nil when
+// guardrails are disabled or no service is configured.
+func NewGuardFromConfig(cfg config.GuardrailsConfig) (*Guard, error) {
+	if !cfg.Enabled || len(cfg.Services) == 0 {
+		return nil, nil
+	}
+	services := make([]service, 0, len(cfg.Services))
+	for i, sc := range cfg.Services {
+		svc, err := newService(sc)
+		if err != nil {
+			return nil, fmt.Errorf("guardrails.services[%d]: %w", i, err)
+		}
+		services = append(services, svc)
+	}
+	return newGuard(time.Duration(cfg.BudgetMS)*time.Millisecond, services...), nil
+}
+
+func newService(cfg config.GuardrailServiceConfig) (service, error) {
+	checker, err := NewChecker(cfg)
+	if err != nil {
+		return service{}, err
+	}
+	svc := service{
+		checker:    checker,
+		stages:     cfg.Stages,
+		timeout:    time.Duration(cfg.TimeoutMS) * time.Millisecond,
+		failClosed: cfg.FailClosed,
+	}
+	if len(svc.stages) == 0 {
+		svc.stages = []string{StageInput, StageOutput}
+	}
+	for _, stage := range svc.stages {
+		if stage != StageInput && stage != StageOutput {
+			return service{}, fmt.Errorf("unknown stage %q", stage)
+		}
+	}
+	if svc.action, err = parseAction(cfg.Action); err != nil {
+		return service{}, err
+	}
+	for channel, action := range cfg.Channels {
+		parsed, err := parseAction(action)
+		if err != nil {
+			return service{}, fmt.Errorf("channels.%s: %w", channel, err)
+		}
+		if svc.channels == nil {
+			svc.channels = make(map[string]string, len(cfg.Channels))
+		}
+		svc.channels[strings.ToLower(channel)] = parsed
+	}
+	return svc, nil
+}
+
+func parseAction(action string) (string, error) {
+	switch action = strings.ToLower(strings.TrimSpace(action)); action {
+	case "":
+		return ActionBlock, nil
+	case ActionBlock, ActionRedact, ActionFlag, ActionOff:
+		return action, nil
+	}
+	return "", fmt.Errorf("unknown action %q", action)
+}
+
+// actionFor returns the action of s on channel.
+func (s service) actionFor(channel string) string {
+	if action, ok := s.channels[strings.ToLower(channel)]; ok {
+		return action
+	}
+	return s.action
+}
+
+// Check sends req to every service of its stage at once and waits for them
+// within the guard's budget. A block from any service wins over a redaction,
+// and a redaction over a flag; the matches of all redactions are masked. A
+// service that fails or runs out of time lets the text through, unless it is
+// fail-closed, in which case its action applies.
+func (g *Guard) Check(ctx context.Context, req Request) Verdict {
+	if g == nil || strings.TrimSpace(req.Text) == "" {
+		return Verdict{}
+	}
+	ctx, cancel := context.WithTimeout(ctx, g.budget)
+	defer cancel()
+
+	verdicts := make([]Verdict, len(g.services))
+	var wg sync.WaitGroup
+	for i, svc := range g.services {
+		if !slices.Contains(svc.stages, req.Stage) || svc.actionFor(req.Channel) == ActionOff {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			verdicts[i] = svc.check(ctx, req)
+		}()
+	}
+	wg.Wait()
+
+	var out Verdict
+	var matches []string
+	for _, v := range verdicts {
+		if v.Action == ActionRedact {
+			matches = append(matches, v.Matches...)
+		}
+		if actionRank(v.Action) > actionRank(out.Action) {
+			out = v
+		}
+	}
+	if out.Action == ActionRedact {
+		out.Matches = matches
+	}
+	return out
+}
+
+// actionRank orders the actions by how much they hold back.
+func actionRank(action string) int {
+	switch action {
+	case ActionFlag:
+		return 1
+	case ActionRedact:
+		return 2
+	case ActionBlock:
+		return 3
+	}
+	return 0
+}
+
+func (s service) check(ctx context.Context, req Request) Verdict {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	action := s.actionFor(req.Channel)
+	start := time.Now()
+	res, err := s.checker.Check(ctx, req)
+	if err != nil {
+		reason := err.Error()
+		if errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			reason = "no answer within the latency budget"
+		}
+		logger.WarnCF("guardrail", "Guardrail service failed", map[string]any{
+			"service":     s.checker.Name(),
+			"stage":       req.Stage,
+			"elapsed_ms":  time.Since(start).Milliseconds(),
+			"fail_closed": s.failClosed,
+			"error":       reason,
+		})
+		if !s.failClosed {
+			return Verdict{}
+		}
+		if action == ActionRedact {
+			action = ActionBlock
+		}
+		return Verdict{Action: action, Service: s.checker.Name(), Reason: "service unavailable: " + reason}
+	}
+	if !res.Unsafe {
+		return Verdict{}
+	}
+	if action == ActionRedact && len(res.Matches) == 0 {
+		// Nothing to mask, so the text cannot go on.
+		action = ActionBlock
+	}
+	return Verdict{Action: action, Service: s.checker.Name(), Reason: res.Reason, Matches: res.Matches}
+}
@@ -0,0 +1,235 @@
+package guardrail
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type stubChecker struct {
+	name  string
+	res   Result
+	err   error
+	delay time.Duration
+}
+
+func (s stubChecker) Name() string { return s.name }
+
+func (s stubChecker) Check(ctx context.Context, req Request) (Result, error) {
+	select {
+	case <-time.After(s.delay):
+		return s.res, s.err
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+func stub(name string, unsafe bool, action string, stages ...string) service {
+	if len(stages) == 0 {
+		stages = []string{StageInput, StageOutput}
+	}
+	return service{checker: stubChecker{name: name, res: Result{Unsafe: unsafe, Reason: name}}, stages: stages, action: action}
+}
+
+func TestGuard_Check(t *testing.T) {
+	g := newGuard(time.Second,
+		stub("flagger", true, ActionFlag),
+		stub("blocker", true, ActionBlock, StageOutput),
+		stub("fine", false, ActionBlock),
+	)
+	in := g.Check(context.Background(), Request{Stage: StageInput, Text: "hi"})
+	if in.Action != ActionFlag || in.Service != "flagger" {
+		t.Errorf("input verdict = %+v", in)
+	}
+	out := g.Check(context.Background(), Request{Stage: StageOutput, Text: "hello"})
+	if out.Action != ActionBlock || out.Service != "blocker" {
+		t.Errorf("output verdict = %+v", out)
+	}
+	if v := (*Guard)(nil).Check(context.Background(), Request{Stage: StageInput, Text: "hi"}); v.Action != "" {
+		t.Errorf("nil guard = %+v", v)
+	}
+}
+
+func TestGuard_Budget(t *testing.T) {
+	slow := stubChecker{name: "slow", res: Result{Unsafe: true}, delay: time.Minute}
+	g := newGuard(20*time.Millisecond,
+		service{checker: slow, stages: []string{StageInput}, action: ActionBlock},
+	)
+	start := time.Now()
+	if v := g.Check(context.Background(), Request{Stage: StageInput, Text: "hi"}); v.Action != "" {
+		t.Errorf("fail-open service blocked: %+v", v)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("check ignored the budget: %v", elapsed)
+	}
+
+	g = newGuard(time.Second,
+		service{checker: slow, stages: []string{StageInput}, action: ActionBlock, timeout: 20 * time.Millisecond, failClosed: true},
+	)
+	v := g.Check(context.Background(), Request{Stage: StageInput, Text: "hi"})
+	if v.Action != ActionBlock || !strings.Contains(v.Reason, "latency budget") {
+		t.Errorf("fail-closed verdict = %+v", v)
+	}
+}
+
+func TestServices(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		json.NewDecoder(r.Body).Decode(&got)
+		if r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/chat/completions":
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"unsafe\nS1,S10"}}]}`))
+		case "/v1/chat/completions":
+			w.Write([]byte(`{"messages":[],"log":{"activated_rails":[{"type":"output","name":"self check output","stop":true}]}}`))
+		case "/check":
+			w.Write([]byte(`{"unsafe":false}`))
+		}
+	}))
+	defer srv.Close()
+
+	req := Request{Stage: StageOutput, Prompt: "hi", Text: "bad words"}
+	for _, tc := range []struct {
+		cfg  config.GuardrailServiceConfig
+		want Result
+	}{
+		{config.GuardrailServiceConfig{Type: "llama_guard", URL: srv.URL, Model: "llama-guard"}, Result{Unsafe: true, Reason: "S1,S10"}},
+		{config.GuardrailServiceConfig{Type: "nemo", URL: srv.URL, ConfigID: "main"}, Result{Unsafe: true, Reason: "self check output"}},
+		{config.GuardrailServiceConfig{Type: "http", URL: srv.URL + "/check"}, Result{}},
+	} {
+		tc.cfg.APIKey = "key"
+		c, err := NewChecker(tc.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := c.Check(context.Background(), req)
+		if err != nil || !reflect.DeepEqual(res, tc.want) {
+			t.Errorf("%s = %+v, %v; want %+v", tc.cfg.Type, res, err, tc.want)
+		}
+		if msgs, ok := got["messages"].([]any); tc.cfg.Type != "http" && (!ok || len(msgs) != 2) {
+			t.Errorf("%s sent %v", tc.cfg.Type, got)
+		}
+	}
+	if got["stage"] != StageOutput || got["text"] != "bad words" {
+		t.Errorf("http body = %v", got)
+	}
+
+	if _, err := NewGuardFromConfig(config.GuardrailsConfig{
+		Enabled:  true,
+		Services: []config.GuardrailServiceConfig{{Type: "http", URL: srv.URL, Action: "warn"}},
+	}); err == nil {
+		t.Error("unknown action accepted")
+	}
+}
+
+func TestOpenAIModeration(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		if r.URL.Path != "/v1/moderations" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"results":[{"flagged":true,"categories":{"violence":true,"hate":true,"sexual":false}}]}`))
+	}))
+	defer srv.Close()
+
+	c, err := NewChecker(config.GuardrailServiceConfig{Type: "openai_moderation", URL: srv.URL + "/v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.Check(context.Background(), Request{Stage: StageInput, Text: "bad words"})
+	if err != nil || !res.Unsafe || res.Reason != "hate,violence" {
+		t.Errorf("result = %+v, %v", res, err)
+	}
+	if got["model"] != defaultModerationModel || got["input"] != "bad words" {
+		t.Errorf("sent %v", got)
+	}
+}
+
+func TestRulesAndClassifier(t *testing.T) {
+	rules, err := NewChecker(config.GuardrailServiceConfig{
+		Type:     "rules",
+		Keywords: []string{"kill"},
+		Patterns: []string{`\b\d{3}-\d{4}\b`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ := rules.Check(context.Background(), Request{Text: "My skills: KILL bugs, call 555-1234"})
+	if !res.Unsafe || !reflect.DeepEqual(res.Matches, []string{"KILL", "555-1234"}) {
+		t.Errorf("rules = %+v", res)
+	}
+	if res, _ := rules.Check(context.Background(), Request{Text: "skills"}); res.Unsafe {
+		t.Errorf("a keyword inside a word matched: %+v", res)
+	}
+	if _, err := NewChecker(config.GuardrailServiceConfig{Type: "rules"}); err == nil {
+		t.Error("rules without keywords or patterns accepted")
+	}
+
+	path := filepath.Join(t.TempDir(), "weights.json")
+	os.WriteFile(path, []byte(`{"bias":-2,"weights":{"idiot":3,"stupid":1.5,"thanks":-2}}`), 0o600)
+	classifier, err := NewChecker(config.GuardrailServiceConfig{Type: "classifier", Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ = classifier.Check(context.Background(), Request{Text: "you Idiot"})
+	if !res.Unsafe || !reflect.DeepEqual(res.Matches, []string{"Idiot"}) {
+		t.Errorf("classifier = %+v", res)
+	}
+	if res, _ := classifier.Check(context.Background(), Request{Text: "stupid bug, thanks"}); res.Unsafe {
+		t.Errorf("classifier = %+v, want safe", res)
+	}
+}
+
+func TestGuard_RedactAndChannelActions(t *testing.T) {
+	rules, _ := newRules(config.GuardrailServiceConfig{Keywords: []string{"secret", "token"}})
+	redactor := service{
+		checker:  rules,
+		stages:   []string{StageInput},
+		action:   ActionRedact,
+		channels: map[string]string{"discord": ActionFlag, "cli": ActionOff},
+	}
+	check := func(g *Guard, channel, text string) Verdict {
+		return g.Check(context.Background(), Request{Stage: StageInput, Channel: channel, Text: text})
+	}
+	g := newGuard(time.Second, redactor, stub("flagger", true, ActionFlag))
+
+	v := check(g, "telegram", "the secret token")
+	if v.Action != ActionRedact || v.Redact("the secret token, secrets") != "the [redacted] [redacted], secrets" {
+		t.Errorf("telegram verdict = %+v", v)
+	}
+	if v := check(g, "discord", "secret"); v.Action != ActionFlag {
+		t.Errorf("discord verdict = %+v", v)
+	}
+	if v := check(newGuard(time.Second, redactor), "cli", "secret"); v.Action != "" {
+		t.Errorf("off for cli, verdict = %+v", v)
+	}
+
+	// A redacting service that cannot say what to mask blocks.
+	if v := check(newGuard(time.Second, stub("vague", true, ActionRedact)), "", "hi"); v.Action != ActionBlock {
+		t.Errorf("redaction without matches = %+v", v)
+	}
+
+	if _, err := NewGuardFromConfig(config.GuardrailsConfig{
+		Enabled: true,
+		Services: []config.GuardrailServiceConfig{
+			{Type: "rules", Keywords: []string{"x"}, Channels: map[string]string{"slack": "mute"}},
+		},
+	}); err == nil || !strings.Contains(err.Error(), "channels.slack") {
+		t.Errorf("unknown channel action: err = %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package guardrail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// rules matches keywords and regular expressions without calling out, and
+// reports what they matched so it can be redacted.
+type rules struct {
+	name    string
+	rules   []*regexp.Regexp
+	sources []string // the keyword or pattern of each rule, for the reason
+}
+
+func newRules(cfg config.GuardrailServiceConfig) (*rules, error) {
+	r := &rules{name: cfg.Name}
+	if r.name == "" {
+		r.name = "rules"
+	}
+	for _, keyword := range cfg.Keywords {
+		if keyword = strings.TrimSpace(keyword); keyword == "" {
+			continue
+		}
+		r.rules = append(r.rules, regexp.MustCompile(keywordPattern(keyword)))
+		r.sources = append(r.sources, fmt.Sprintf("keyword %q", keyword))
+	}
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+		r.rules = append(r.rules, re)
+		r.sources = append(r.sources, fmt.Sprintf("pattern %q", pattern))
+	}
+	if len(r.rules) == 0 {
+		return nil, errors.New("rules service requires keywords or patterns")
+	}
+	return r, nil
+}
+
+// keywordPattern matches keyword case-insensitively as a whole word.
+func keywordPattern(keyword string) string {
+	return "(?i)" + wordPattern(keyword)
+}
+
+// wordPattern matches s where it is not part of a longer word. Word
+// boundaries are only required where s starts or ends with an ASCII word
+// character, the only ones \b knows, so "c++" and non-Latin words still
+// match.
+func wordPattern(s string) string {
+	pattern := regexp.QuoteMeta(s)
+	if isASCIIWordByte(s[0]) {
+		pattern = `\b` + pattern
+	}
+	if isASCIIWordByte(s[len(s)-1]) {
+		pattern += `\b`
+	}
+	return pattern
+}
+
+func isASCIIWordByte(b byte) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (r *rules) Name() string { return r.name }
+
+func (r *rules) Check(_ context.Context, req Request) (Result, error) {
+	var res Result
+	for i, re := range r.rules {
+		found := re.FindAllString(req.Text, -1)
+		if len(found) == 0 {
+			continue
+		}
+		if !res.Unsafe {
+			res = Result{Unsafe: true, Reason: "matched " + r.sources[i]}
+		}
+		res.Matches = append(res.Matches, found...)
+	}
+	return res, nil
+}
+
+// classifier scores a text with a local logistic-regression model over its
+// words, read from a JSON file of {"bias": b, "weights": {"word": w}}. A
+// text whose score reaches the threshold is unsafe, and its words with a
+// positive weight are the matches.
+type classifier struct {
+	name      string
+	bias      float64
+	weights   map[string]float64
+	threshold float64
+}
+
+func newClassifier(cfg config.GuardrailServiceConfig) (*classifier, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("classifier service requires path")
+	}
+	data, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	var model struct {
+		Bias    float64            `json:"bias"`
+		Weights map[string]float64 `json:"weights"`
+	}
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.Path, err)
+	}
+	c := &classifier{name: cfg.Name, bias: model.Bias, threshold: cfg.Threshold}
+	if c.name == "" {
+		c.name = "classifier"
+	}
+	if c.threshold <= 0 {
+		c.threshold = 0.5
+	}
+	c.weights = make(map[string]float64, len(model.Weights))
+	for word, w := range model.Weights {
+		c.weights[strings.ToLower(word)] = w
+	}
+	return c, nil
+}
+
+func (c *classifier) Name() string { return c.name }
+
+func (c *classifier) Check(_ context.Context, req Request) (Result, error) {
+	seen := make(map[string]bool)
+	sum := c.bias
+	var matches []string
+	for _, word := range strings.FieldsFunc(req.Text, func(r rune) bool { return !isWordRune(r) }) {
+		w, ok := c.weights[strings.ToLower(word)]
+		if !ok {
+			continue
+		}
+		if w > 0 {
+			matches = append(matches, word)
+		}
+		if !seen[strings.ToLower(word)] {
+			seen[strings.ToLower(word)] = true
+			sum += w
+		}
+	}
+	score := 1 / (1 + math.Exp(-sum))
+	if score < c.threshold {
+		return Result{}, nil
+	}
+	return Result{Unsafe: true, Reason: fmt.Sprintf("classifier score %.2f", score), Matches: matches}, nil
+}
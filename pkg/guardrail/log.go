@@ -0,0 +1,88 @@
+package guardrail
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+)
+
+// LogEntry is one decision of the guardrails: a text that was blocked,
+// redacted, or flagged. Excerpt is the start of the text as it went on, so a
+// redacted text is logged redacted; it is empty for ephemeral conversations.
+type LogEntry struct {
+	Time      time.Time `json:"time"`
+	Stage     string    `json:"stage"`
+	Action    string    `json:"action"`
+	Service   string    `json:"service"`
+	Reason    string    `json:"reason,omitempty"`
+	Channel   string    `json:"channel,omitempty"`
+	ChatID    string    `json:"chat_id,omitempty"`
+	SenderID  string    `json:"sender_id,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Excerpt   string    `json:"excerpt,omitempty"`
+}
+
+// LogPath returns the moderation log location for a workspace.
+func LogPath(workspace string) string {
+	return filepath.Join(workspace, "state", "moderation.jsonl")
+}
+
+// Log appends entries to the moderation log, a JSONL file. It is safe for
+// concurrent use, and a nil *Log records nothing.
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewLog creates a log writing to path.
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// Record appends e. A zero Time is set to now.
+func (l *Log) Record(e LogEntry) error {
+	if l == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ForgetSender removes the entries of senderID's messages on channel and
+// returns how many were removed.
+func (l *Log) ForgetSender(channel, senderID string) (int, error) {
+	if l == nil || senderID == "" {
+		return 0, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fileutil.RewriteLines(l.path, 0o600, func(line []byte) ([]byte, bool) {
+		var e LogEntry
+		return line, json.Unmarshal(line, &e) != nil || e.Channel != channel || e.SenderID != senderID
+	})
+}
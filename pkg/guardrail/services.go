@@ -0,0 +1,254 @@
+package guardrail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// maxResponseBytes bounds what is read from a guardrail service.
+const maxResponseBytes = 1 << 20
+
+// The OpenAI moderation API used when a service gives no url or model.
+const (
+	defaultOpenAIBase      = "https://api.openai.com/v1"
+	defaultModerationModel = "omni-moderation-latest"
+)
+
+// NewChecker creates the service described by cfg.
+func NewChecker(cfg config.GuardrailServiceConfig) (Checker, error) {
+	kind := strings.ToLower(cfg.Type)
+	switch kind {
+	case "rules":
+		return newRules(cfg)
+	case "classifier":
+		return newClassifier(cfg)
+	case "openai_moderation":
+		if cfg.URL == "" {
+			cfg.URL = defaultOpenAIBase
+		}
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("%s service requires url", cfg.Type)
+	}
+	c := client{name: cfg.Name, apiKey: cfg.APIKey, headers: cfg.Headers, http: &http.Client{}}
+	switch kind {
+	case "openai_moderation":
+		c.url = strings.TrimRight(cfg.URL, "/") + "/moderations"
+		model := cfg.Model
+		if model == "" {
+			model = defaultModerationModel
+		}
+		return &openAIModeration{client: c.named("openai_moderation"), model: model}, nil
+	case "llama_guard":
+		if cfg.Model == "" {
+			return nil, errors.New("llama_guard service requires model")
+		}
+		c.url = strings.TrimRight(cfg.URL, "/") + "/chat/completions"
+		return &llamaGuard{client: c.named("llama_guard"), model: cfg.Model}, nil
+	case "nemo":
+		c.url = strings.TrimRight(cfg.URL, "/") + "/v1/chat/completions"
+		return &nemo{client: c.named("nemo"), configID: cfg.ConfigID}, nil
+	case "http":
+		c.url = cfg.URL
+		return &httpChecker{client: c.named("http")}, nil
+	default:
+		return nil, fmt.Errorf("unknown service type %q", cfg.Type)
+	}
+}
+
+// client POSTs JSON to a service. The service types differ in the body they
+// send and how they read the answer.
+type client struct {
+	name    string
+	url     string
+	apiKey  string
+	headers map[string]string
+	http    *http.Client
+}
+
+func (c client) named(def string) client {
+	if c.name == "" {
+		c.name = def
+	}
+	return c
+}
+
+func (c client) Name() string { return c.name }
+
+func (c client) post(ctx context.Context, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		if len(data) > 512 {
+			data = data[:512]
+		}
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("unreadable answer: %w", err)
+	}
+	return nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// conversation is the exchange to check: the message alone on input, the
+// message and the reply on output.
+func conversation(req Request) []chatMessage {
+	if req.Stage == StageOutput {
+		return []chatMessage{{Role: "user", Content: req.Prompt}, {Role: "assistant", Content: req.Text}}
+	}
+	return []chatMessage{{Role: "user", Content: req.Text}}
+}
+
+// llamaGuard asks a Llama Guard model behind an OpenAI-compatible chat API.
+// The model answers "safe", or "unsafe" followed by the violated categories.
+type llamaGuard struct {
+	client
+	model string
+}
+
+func (l *llamaGuard) Check(ctx context.Context, req Request) (Result, error) {
+	var resp struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	body := map[string]any{"model": l.model, "messages": conversation(req), "temperature": 0}
+	if err := l.post(ctx, body, &resp); err != nil {
+		return Result{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return Result{}, errors.New("answer has no choices")
+	}
+	verdict, categories, _ := strings.Cut(strings.TrimSpace(resp.Choices[0].Message.Content), "\n")
+	switch strings.ToLower(strings.TrimSpace(verdict)) {
+	case "safe":
+		return Result{}, nil
+	case "unsafe":
+		return Result{Unsafe: true, Reason: strings.TrimSpace(categories)}, nil
+	}
+	return Result{}, fmt.Errorf("unexpected answer %q", verdict)
+}
+
+// openAIModeration asks the OpenAI moderation API, which flags a text and
+// names the categories it falls in.
+type openAIModeration struct {
+	client
+	model string
+}
+
+func (o *openAIModeration) Check(ctx context.Context, req Request) (Result, error) {
+	var resp struct {
+		Results []struct {
+			Flagged    bool            `json:"flagged"`
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err := o.post(ctx, map[string]any{"model": o.model, "input": req.Text}, &resp); err != nil {
+		return Result{}, err
+	}
+	if len(resp.Results) == 0 {
+		return Result{}, errors.New("answer has no results")
+	}
+	res := resp.Results[0]
+	if !res.Flagged {
+		return Result{}, nil
+	}
+	var categories []string
+	for name, hit := range res.Categories {
+		if hit {
+			categories = append(categories, name)
+		}
+	}
+	slices.Sort(categories)
+	return Result{Unsafe: true, Reason: strings.Join(categories, ",")}, nil
+}
+
+// nemo runs only the input or output rails of a NeMo Guardrails server and
+// reads which rails stopped the exchange from the activated-rails log.
+type nemo struct {
+	client
+	configID string
+}
+
+func (n *nemo) Check(ctx context.Context, req Request) (Result, error) {
+	var resp struct {
+		Log struct {
+			ActivatedRails []struct {
+				Type string `json:"type"`
+				Name string `json:"name"`
+				Stop bool   `json:"stop"`
+			} `json:"activated_rails"`
+		} `json:"log"`
+	}
+	body := map[string]any{
+		"config_id": n.configID,
+		"messages":  conversation(req),
+		"options": map[string]any{
+			"rails": map[string]bool{
+				"input":     req.Stage == StageInput,
+				"output":    req.Stage == StageOutput,
+				"dialog":    false,
+				"retrieval": false,
+			},
+			"log": map[string]bool{"activated_rails": true},
+		},
+	}
+	if err := n.post(ctx, body, &resp); err != nil {
+		return Result{}, err
+	}
+	for _, rail := range resp.Log.ActivatedRails {
+		if rail.Stop {
+			return Result{Unsafe: true, Reason: rail.Name}, nil
+		}
+	}
+	return Result{}, nil
+}
+
+// httpChecker POSTs the Request as JSON to a custom endpoint, which answers
+// with a Result.
+type httpChecker struct {
+	client
+}
+
+func (h *httpChecker) Check(ctx context.Context, req Request) (Result, error) {
+	var res Result
+	err := h.post(ctx, req, &res)
+	return res, err
+}
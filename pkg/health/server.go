@@ -16,6 +16,7 @@ type Server struct {
 	mu        sync.RWMutex
 	ready     bool
 	checks    map[string]Check
+	metrics   map[string]func() any
 	startTime time.Time
 }
 
@@ -27,10 +28,11 @@ type Check struct {
 }
 
 type StatusResponse struct {
-	Status string           `json:"status"`
-	Uptime string           `json:"uptime"`
-	Checks map[string]Check `json:"checks,omitempty"`
-	Pid    int              `json:"pid"`
+	Status  string           `json:"status"`
+	Uptime  string           `json:"uptime"`
+	Checks  map[string]Check `json:"checks,omitempty"`
+	Metrics map[string]any   `json:"metrics,omitempty"`
+	Pid     int              `json:"pid"`
 }
 
 func NewServer(host string, port int) *Server {
@@ -38,6 +40,7 @@ func NewServer(host string, port int) *Server {
 	s := &Server{
 		ready:     false,
 		checks:    make(map[string]Check),
+		metrics:   make(map[string]func() any),
 		startTime: time.Now(),
 	}
 
@@ -106,6 +109,14 @@ func (s *Server) RegisterCheck(name string, checkFn func() (bool, string)) {
 	}
 }
 
+// RegisterMetrics adds the counters fn returns to the /health response
+// under name. fn is called on every request.
+func (s *Server) RegisterMetrics(name string, fn func() any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics[name] = fn
+}
+
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -116,6 +127,14 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 		Uptime: uptime.String(),
 		Pid:    os.Getpid(),
 	}
+	s.mu.RLock()
+	if len(s.metrics) > 0 {
+		resp.Metrics = make(map[string]any, len(s.metrics))
+		for name, fn := range s.metrics {
+			resp.Metrics[name] = fn()
+		}
+	}
+	s.mu.RUnlock()
 
 	json.NewEncoder(w).Encode(resp)
 }
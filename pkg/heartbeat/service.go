@@ -41,6 +41,7 @@ type HeartbeatService struct {
 	handler   HeartbeatHandler
 	interval  time.Duration
 	enabled   bool
+	paused    bool
 	mu        sync.RWMutex
 	stopChan  chan struct{}
 }
@@ -117,6 +118,13 @@ func (hs *HeartbeatService) Stop() {
 	hs.stopChan = nil
 }
 
+// SetPaused skips heartbeats without stopping the service.
+func (hs *HeartbeatService) SetPaused(paused bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.paused = paused
+}
+
 // IsRunning returns whether the service is running
 func (hs *HeartbeatService) IsRunning() bool {
 	hs.mu.RLock()
@@ -149,7 +157,7 @@ func (hs *HeartbeatService) executeHeartbeat() {
 	hs.mu.RLock()
 	enabled := hs.enabled
 	handler := hs.handler
-	if !hs.enabled || hs.stopChan == nil {
+	if !hs.enabled || hs.paused || hs.stopChan == nil {
 		hs.mu.RUnlock()
 		return
 	}
@@ -0,0 +1,196 @@
+// Package i18n localizes the text the bot writes itself: errors, throttle
+// notices, confirmations, and command descriptions. Messages are keyed by
+// their English text, which is shown whenever a language has no translation,
+// so wrapping a string with T or Sprintf is all it takes to make it
+// translatable. Bundles ship for a few languages and operators can add or
+// reword them with <code>.json files in the workspace.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// English is the language the messages are written in. It needs no bundle.
+const English = "en"
+
+//go:embed locales/*.json
+var builtin embed.FS
+
+// Bundle holds the translations of one language. Messages maps English
+// text, including fmt verbs, to its translation; Notifications maps
+// notification events to their template.
+type Bundle struct {
+	Name          string            `json:"name"`
+	Aliases       []string          `json:"aliases,omitempty"`
+	Messages      map[string]string `json:"messages"`
+	Notifications map[string]string `json:"notifications,omitempty"`
+}
+
+// Language is one language users can pick.
+type Language struct {
+	Code string
+	Name string
+}
+
+var (
+	mu      sync.RWMutex
+	bundles = mustLoadBuiltin()
+)
+
+func mustLoadBuiltin() map[string]*Bundle {
+	out := make(map[string]*Bundle)
+	if err := loadFS(builtin, "locales", out); err != nil {
+		panic(fmt.Sprintf("i18n: built-in locale bundles: %v", err))
+	}
+	return out
+}
+
+// loadFS merges the <code>.json bundles of dir into out.
+func loadFS(fsys fs.FS, dir string, out map[string]*Bundle) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		code, ok := strings.CutSuffix(e.Name(), ".json")
+		if e.IsDir() || !ok {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		var b Bundle
+		if err := json.Unmarshal(data, &b); err != nil {
+			return fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		merge(out, strings.ToLower(code), &b)
+	}
+	return nil
+}
+
+func merge(into map[string]*Bundle, code string, b *Bundle) {
+	cur := into[code]
+	if cur == nil {
+		cur = &Bundle{Messages: make(map[string]string), Notifications: make(map[string]string)}
+		into[code] = cur
+	}
+	if b.Name != "" {
+		cur.Name = b.Name
+	}
+	cur.Aliases = append(cur.Aliases, b.Aliases...)
+	for k, v := range b.Messages {
+		cur.Messages[k] = v
+	}
+	for k, v := range b.Notifications {
+		cur.Notifications[k] = v
+	}
+}
+
+// LoadDir adds the bundles in dir, named <code>.json like the built-in ones,
+// over them: their entries extend or replace the translations of a language,
+// and a new code adds a language. A missing dir is not an error.
+func LoadDir(dir string) error {
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	loaded := make(map[string]*Bundle)
+	if err := loadFS(os.DirFS(filepath.Clean(dir)), ".", loaded); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for code, b := range loaded {
+		merge(bundles, code, b)
+	}
+	return nil
+}
+
+// Match returns the code of the language lang names, which may be a code or
+// locale such as "pt-BR" or "es_419", or a name such as "German" or
+// "Deutsch". It returns "" when no bundle covers it.
+func Match(lang string) string {
+	l := strings.ToLower(strings.TrimSpace(strings.ReplaceAll(lang, "_", "-")))
+	if l == "" {
+		return ""
+	}
+	base, _, _ := strings.Cut(l, "-")
+	if base == English || l == "english" {
+		return English
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if _, ok := bundles[l]; ok {
+		return l
+	}
+	if _, ok := bundles[base]; ok {
+		return base
+	}
+	for code, b := range bundles {
+		if strings.EqualFold(b.Name, l) || slices.ContainsFunc(b.Aliases, func(a string) bool {
+			return strings.EqualFold(a, l)
+		}) {
+			return code
+		}
+	}
+	return ""
+}
+
+// Languages returns the languages there are bundles for, English first.
+func Languages() []Language {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]Language, 0, len(bundles))
+	for code, b := range bundles {
+		out = append(out, Language{Code: code, Name: b.Name})
+	}
+	slices.SortFunc(out, func(a, b Language) int { return strings.Compare(a.Code, b.Code) })
+	return append([]Language{{Code: English, Name: "English"}}, out...)
+}
+
+// T returns text in the language with code lang, or text itself when it has
+// no translation there.
+func T(lang, text string) string {
+	if lang == "" || lang == English {
+		return text
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if b := bundles[lang]; b != nil {
+		if t := b.Messages[text]; t != "" {
+			return t
+		}
+	}
+	return text
+}
+
+// Sprintf formats the translation of format in lang with args. Translations
+// keep the verbs of format, reordered with explicit indexes like %[2]s when
+// the language needs it.
+func Sprintf(lang, format string, args ...any) string {
+	return fmt.Sprintf(T(lang, format), args...)
+}
+
+// Notification returns the template of event in lang, or "" when there is
+// none.
+func Notification(lang, event string) string {
+	if lang == "" || lang == English {
+		return ""
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if b := bundles[lang]; b != nil {
+		return b.Notifications[event]
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var verb = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z%]`)
+
+// verbs returns the fmt verbs of s without their explicit indexes, sorted,
+// so a translation may reorder them.
+func verbs(s string) []string {
+	var out []string
+	for _, v := range verb.FindAllString(s, -1) {
+		out = append(out, regexp.MustCompile(`\[\d+\]`).ReplaceAllString(v, ""))
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestBuiltinBundles_KeepVerbs(t *testing.T) {
+	if len(bundles) < 4 {
+		t.Fatalf("loaded %d bundles", len(bundles))
+	}
+	for code, b := range bundles {
+		if b.Name == "" {
+			t.Errorf("%s has no name", code)
+		}
+		for en, tr := range b.Messages {
+			if !slices.Equal(verbs(en), verbs(tr)) {
+				t.Errorf("%s: %q changes the verbs of %q", code, tr, en)
+			}
+		}
+		for event, tmpl := range b.Notifications {
+			if strings.Count(tmpl, "{{") != strings.Count(tmpl, "}}") {
+				t.Errorf("%s: notification %s has unbalanced actions", code, event)
+			}
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := map[string]string{
+		"es-ES":      "es",
+		"es_419":     "es",
+		"pt-BR":      "pt",
+		"Deutsch":    "de",
+		"french":     "fr",
+		"en-US":      English,
+		"English":    English,
+		"Klingon":    "",
+		"   ":        "",
+		"Portuguese": "pt",
+	}
+	for in, want := range tests {
+		if got := Match(in); got != want {
+			t.Errorf("Match(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	if got := Sprintf("de", "You are not allowed to use /%s.", "model"); got != "Du darfst /model nicht verwenden." {
+		t.Errorf("Sprintf(de) = %q", got)
+	}
+	if got := Sprintf(English, "You are not allowed to use /%s.", "model"); got != "You are not allowed to use /model." {
+		t.Errorf("Sprintf(en) = %q", got)
+	}
+	if got := T("es", "Not a catalog message"); got != "Not a catalog message" {
+		t.Errorf("untranslated text changed: %q", got)
+	}
+	if Notification("fr", "queue.full") == "" || Notification(English, "queue.full") != "" {
+		t.Error("Notification should return translations only")
+	}
+}
+
+func TestLoadDir_OverridesAndAdds(t *testing.T) {
+	saved := bundles
+	t.Cleanup(func() { bundles = saved })
+	bundles = mustLoadBuiltin()
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("es.json", `{"messages": {"Chat history cleared!": "¡Listo, chat vacío!"}}`)
+	write("it.json", `{"name": "Italiano", "aliases": ["italian"],
+		"messages": {"Chat history cleared!": "Cronologia cancellata!"}}`)
+	if err := LoadDir(dir); err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+
+	if got := T("es", "Chat history cleared!"); got != "¡Listo, chat vacío!" {
+		t.Errorf("override = %q", got)
+	}
+	if got := T("es", "No description"); got != "Sin descripción" {
+		t.Errorf("the rest of the built-in bundle was lost: %q", got)
+	}
+	if Match("italian") != "it" || T("it", "Chat history cleared!") != "Cronologia cancellata!" {
+		t.Error("the added language is not used")
+	}
+	if err := LoadDir(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("missing dir: error = %v", err)
+	}
+}
@@ -31,6 +31,19 @@ func ParseCanonicalID(canonical string) (platform, id string, ok bool) {
 	return canonical[:idx], canonical[idx+1:], true
 }
 
+// SenderOrID returns sender, or when the channel reported no identity, one
+// built from channel and senderID, so "platform:id" entries still match.
+func SenderOrID(sender bus.SenderInfo, channel, senderID string) bus.SenderInfo {
+	if sender.PlatformID != "" || senderID == "" {
+		return sender
+	}
+	return bus.SenderInfo{
+		Platform:    channel,
+		PlatformID:  senderID,
+		CanonicalID: BuildCanonicalID(channel, senderID),
+	}
+}
+
 // MatchAllowed checks whether the given sender matches a single allow-list entry.
 // It is backward-compatible with all legacy formats:
 //
@@ -247,3 +247,17 @@ func TestIsNumeric(t *testing.T) {
 		}
 	}
 }
+
+func TestSenderOrID(t *testing.T) {
+	reported := bus.SenderInfo{Platform: "slack", PlatformID: "U1", Username: "alice"}
+	if got := SenderOrID(reported, "slack", "U1"); got != reported {
+		t.Errorf("SenderOrID(reported) = %+v", got)
+	}
+	got := SenderOrID(bus.SenderInfo{}, "Telegram", "42")
+	if !MatchAllowed(got, "telegram:42") || MatchAllowed(got, "discord:42") {
+		t.Errorf("SenderOrID(empty) = %+v", got)
+	}
+	if got := SenderOrID(bus.SenderInfo{}, "telegram", ""); got != (bus.SenderInfo{}) {
+		t.Errorf("SenderOrID without an ID = %+v", got)
+	}
+}
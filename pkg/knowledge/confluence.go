@@ -0,0 +1,123 @@
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const confluencePageSize = 50
+
+type confluenceSource struct {
+	baseURL  string
+	email    string
+	apiToken string
+	space    string
+	client   *http.Client
+}
+
+func (s *confluenceSource) Collection() string {
+	return "confluence:" + s.space
+}
+
+type confluencePage struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Version struct {
+		When time.Time `json:"when"`
+	} `json:"version"`
+	Body struct {
+		Storage struct {
+			Value string `json:"value"`
+		} `json:"storage"`
+	} `json:"body"`
+	Links struct {
+		WebUI string `json:"webui"`
+	} `json:"_links"`
+}
+
+// Changed returns the space's pages modified at or after since, or every
+// page when since is zero.
+func (s *confluenceSource) Changed(ctx context.Context, since time.Time) ([]*Document, error) {
+	cql := fmt.Sprintf("space = %s and type = page", cqlQuote(s.space))
+	if !since.IsZero() {
+		// CQL compares at minute precision in the server's time zone, which
+		// is UTC for Atlassian Cloud.
+		cql += fmt.Sprintf(" and lastmodified >= %q", since.UTC().Format("2006-01-02 15:04"))
+	}
+	cql += " order by lastmodified"
+
+	var docs []*Document
+	for start := 0; ; {
+		q := url.Values{}
+		q.Set("cql", cql)
+		q.Set("expand", "body.storage,version")
+		q.Set("limit", strconv.Itoa(confluencePageSize))
+		q.Set("start", strconv.Itoa(start))
+
+		var resp struct {
+			Results []confluencePage `json:"results"`
+			Size    int              `json:"size"`
+			Links   struct {
+				Next string `json:"next"`
+			} `json:"_links"`
+		}
+		if err := s.get(ctx, "/rest/api/content/search?"+q.Encode(), &resp); err != nil {
+			return nil, err
+		}
+		for _, page := range resp.Results {
+			text, err := utils.HtmlToMarkdown(page.Body.Storage.Value)
+			if err != nil {
+				return nil, fmt.Errorf("page %s: %w", page.ID, err)
+			}
+			docs = append(docs, &Document{
+				ID:         "confluence:" + page.ID,
+				Collection: s.Collection(),
+				Title:      page.Title,
+				URL:        s.baseURL + page.Links.WebUI,
+				Updated:    page.Version.When,
+				Chunks:     Chunk(text),
+			})
+		}
+		if resp.Links.Next == "" || resp.Size == 0 {
+			return docs, nil
+		}
+		start += resp.Size
+	}
+}
+
+func (s *confluenceSource) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.email, s.apiToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("confluence returned %s: %s", resp.Status, utils.Truncate(strings.TrimSpace(string(data)), 300))
+	}
+	return json.Unmarshal(data, out)
+}
+
+// cqlQuote quotes a CQL string literal.
+func cqlQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,291 @@
+// Package knowledge keeps a local index of pages synced from Notion and
+// Confluence, and retrieves the passages most relevant to a query together
+// with links to their source pages.
+package knowledge
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+)
+
+// chunkRunes is the target size of an indexed passage.
+const chunkRunes = 1200
+
+// Document is one synced page, split into passages for retrieval.
+type Document struct {
+	ID         string    `json:"id"`
+	Collection string    `json:"collection"` // e.g. "notion:<database id>", "confluence:<space>"
+	Title      string    `json:"title"`
+	URL        string    `json:"url"`
+	Updated    time.Time `json:"updated"`
+	Chunks     []string  `json:"chunks"`
+}
+
+// Hit is a retrieved passage.
+type Hit struct {
+	Title string
+	URL   string
+	Text  string
+	Score float64
+}
+
+type indexFile struct {
+	Documents []*Document          `json:"documents"`
+	Cursors   map[string]time.Time `json:"cursors"`
+}
+
+// Path returns the knowledge index location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "knowledge.json")
+}
+
+// Index is the set of synced documents, persisted as one JSON file. Readers
+// in other processes see updates because Search reloads the file when it
+// changes on disk.
+type Index struct {
+	path string
+
+	mu      sync.Mutex
+	docs    map[string]*Document
+	cursors map[string]time.Time
+	modTime time.Time
+	size    int64
+}
+
+// NewIndex opens the index at path. A missing file is an empty index.
+func NewIndex(path string) *Index {
+	idx := &Index{path: path}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.reloadLocked(true)
+	return idx
+}
+
+// reloadLocked reads the file if it changed since the last read.
+func (idx *Index) reloadLocked(force bool) {
+	info, err := os.Stat(idx.path)
+	if err != nil {
+		if idx.docs == nil {
+			idx.docs = make(map[string]*Document)
+			idx.cursors = make(map[string]time.Time)
+		}
+		return
+	}
+	if !force && info.ModTime().Equal(idx.modTime) && info.Size() == idx.size {
+		return
+	}
+	data, err := os.ReadFile(idx.path)
+	if err != nil {
+		return
+	}
+	var f indexFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return
+	}
+	idx.docs = make(map[string]*Document, len(f.Documents))
+	for _, d := range f.Documents {
+		if d != nil {
+			idx.docs[d.ID] = d
+		}
+	}
+	idx.cursors = f.Cursors
+	if idx.cursors == nil {
+		idx.cursors = make(map[string]time.Time)
+	}
+	idx.modTime, idx.size = info.ModTime(), info.Size()
+}
+
+// Upsert adds or replaces doc.
+func (idx *Index) Upsert(doc *Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.docs[doc.ID] = doc
+}
+
+// Prune removes the documents of collection whose IDs are not in keep.
+// It returns how many were removed.
+func (idx *Index) Prune(collection string, keep map[string]bool) int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	removed := 0
+	for id, d := range idx.docs {
+		if d.Collection == collection && !keep[id] {
+			delete(idx.docs, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Cursor returns when collection was last synced, or zero if never.
+func (idx *Index) Cursor(collection string) time.Time {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.cursors[collection]
+}
+
+// SetCursor records that collection is synced up to t.
+func (idx *Index) SetCursor(collection string, t time.Time) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.cursors[collection] = t
+}
+
+// Len returns the number of documents.
+func (idx *Index) Len() int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return len(idx.docs)
+}
+
+// Save writes the index to disk.
+func (idx *Index) Save() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	f := indexFile{Cursors: idx.cursors}
+	for _, d := range idx.docs {
+		f.Documents = append(f.Documents, d)
+	}
+	sort.Slice(f.Documents, func(i, j int) bool { return f.Documents[i].ID < f.Documents[j].ID })
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteFileAtomic(idx.path, data, 0o600); err != nil {
+		return err
+	}
+	if info, err := os.Stat(idx.path); err == nil {
+		idx.modTime, idx.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+// Search returns up to limit passages ranked by TF-IDF against query, at
+// most one per document so citations point at distinct pages.
+func (idx *Index) Search(query string, limit int) []Hit {
+	terms := tokenize(query)
+	if len(terms) == 0 || limit <= 0 {
+		return nil
+	}
+
+	idx.mu.Lock()
+	idx.reloadLocked(false)
+	type passage struct {
+		doc  *Document
+		text string
+		tf   map[string]int
+	}
+	var passages []passage
+	df := make(map[string]int)
+	for _, d := range idx.docs {
+		title := tokenize(d.Title)
+		for _, chunk := range d.Chunks {
+			tf := make(map[string]int)
+			for _, tok := range append(tokenize(chunk), title...) {
+				tf[tok]++
+			}
+			for _, term := range terms {
+				if tf[term] > 0 {
+					df[term]++
+				}
+			}
+			passages = append(passages, passage{doc: d, text: chunk, tf: tf})
+		}
+	}
+	idx.mu.Unlock()
+
+	best := make(map[string]Hit)
+	n := float64(len(passages))
+	for _, p := range passages {
+		var score float64
+		for _, term := range terms {
+			if tf := p.tf[term]; tf > 0 {
+				score += (1 + math.Log(float64(tf))) * math.Log(1+n/float64(df[term]))
+			}
+		}
+		if score == 0 {
+			continue
+		}
+		if prev, ok := best[p.doc.ID]; !ok || score > prev.Score {
+			best[p.doc.ID] = Hit{Title: p.doc.Title, URL: p.doc.URL, Text: p.text, Score: score}
+		}
+	}
+
+	hits := make([]Hit, 0, len(best))
+	for _, h := range best {
+		hits = append(hits, h)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].URL < hits[j].URL
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// tokenize lowercases s and splits it into words, dropping one-letter words.
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := fields[:0]
+	for _, f := range fields {
+		if len([]rune(f)) > 1 {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Chunk splits text into passages of about chunkRunes, breaking between
+// paragraphs where possible.
+func Chunk(text string) []string {
+	return ChunkSize(text, chunkRunes)
+}
+
+// ChunkSize splits text into passages of about size runes, breaking
+// between paragraphs where possible.
+func ChunkSize(text string, size int) []string {
+	var chunks []string
+	var cur strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		cur.Reset()
+	}
+	for _, para := range strings.Split(text, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		if cur.Len() > 0 && len([]rune(cur.String()))+len([]rune(para)) > size {
+			flush()
+		}
+		for runes := []rune(para); len(runes) > size; runes = []rune(para) {
+			cur.WriteString(string(runes[:size]))
+			flush()
+			para = string(runes[size:])
+		}
+		if cur.Len() > 0 {
+			cur.WriteString("\n\n")
+		}
+		cur.WriteString(para)
+	}
+	flush()
+	return chunks
+}
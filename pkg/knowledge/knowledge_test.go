@@ -0,0 +1,156 @@
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestIndex_SearchAndReload(t *testing.T) {
+	path := Path(t.TempDir())
+	idx := NewIndex(path)
+	idx.Upsert(&Document{
+		ID: "notion:1", Collection: "notion:db", Title: "Onboarding", URL: "https://notion.so/1",
+		Chunks: []string{"Request a laptop from IT on your first day.", "Expense reports are due monthly."},
+	})
+	idx.Upsert(&Document{
+		ID: "confluence:2", Collection: "confluence:ENG", Title: "Deploys", URL: "https://wiki/2",
+		Chunks: []string{"Deploys happen on Tuesdays. Ask in #releases before a deploy."},
+	})
+	if err := idx.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second reader, like the agent's tool, sees what the sync saved.
+	reader := NewIndex(path)
+	hits := reader.Search("when are expense reports due?", 5)
+	if len(hits) != 1 || hits[0].URL != "https://notion.so/1" || !strings.Contains(hits[0].Text, "Expense") {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if hits := reader.Search("deploy", 5); len(hits) != 1 || hits[0].Title != "Deploys" {
+		t.Errorf("deploy hits = %+v", hits)
+	}
+
+	idx.Prune("notion:db", map[string]bool{})
+	if err := idx.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if hits := reader.Search("expense", 5); len(hits) != 0 {
+		t.Errorf("reader did not reload pruned index: %+v", hits)
+	}
+}
+
+func TestChunk(t *testing.T) {
+	long := strings.Repeat("a", chunkRunes+10)
+	chunks := Chunk("first\n\nsecond\n\n" + long)
+	if len(chunks) != 3 || chunks[0] != "first\n\nsecond" || len([]rune(chunks[1])) != chunkRunes {
+		t.Fatalf("chunks = %d %q", len(chunks), chunks[0])
+	}
+}
+
+func TestNotionSource_Changed(t *testing.T) {
+	var filter map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Notion-Version") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/databases/db1/query":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			filter, _ = body["filter"].(map[string]any)
+			w.Write([]byte(`{"results":[{"id":"p1","url":"https://notion.so/p1","last_edited_time":"2026-03-01T10:00:00Z",
+				"properties":{"Name":{"type":"title","title":[{"plain_text":"Runbook"}]}}}],"has_more":false}`))
+		case "/blocks/p1/children":
+			w.Write([]byte(`{"results":[
+				{"id":"b1","type":"heading_1","heading_1":{"rich_text":[{"plain_text":"Restart"}]}},
+				{"id":"b2","type":"toggle","has_children":true,"toggle":{"rich_text":[{"plain_text":"Steps"}]}}]}`))
+		case "/blocks/b2/children":
+			w.Write([]byte(`{"results":[{"id":"b3","type":"bulleted_list_item",
+				"bulleted_list_item":{"rich_text":[{"plain_text":"run make restart"}]}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	src := &notionSource{token: "secret", databaseID: "db1", baseURL: srv.URL, client: srv.Client()}
+	since := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	docs, err := src.Changed(context.Background(), since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Title != "Runbook" || docs[0].ID != "notion:p1" {
+		t.Fatalf("docs = %+v", docs)
+	}
+	if got := strings.Join(docs[0].Chunks, "|"); got != "# Restart\n\nSteps\n\n- run make restart" {
+		t.Errorf("text = %q", got)
+	}
+	if filter["timestamp"] != "last_edited_time" {
+		t.Errorf("incremental query should filter by edit time, got %v", filter)
+	}
+}
+
+func TestService_IncrementalSyncAndPrune(t *testing.T) {
+	var cql []string
+	pages := `{"id":"1","title":"Deploys","version":{"when":"2026-03-01T10:00:00Z"},
+		"body":{"storage":{"value":"<p>Deploys happen on <b>Tuesdays</b>.</p>"}},"_links":{"webui":"/spaces/ENG/pages/1"}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "me@example.com" || pass != "tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		cql = append(cql, r.URL.Query().Get("cql"))
+		w.Write([]byte(`{"results":[` + pages + `],"size":1,"_links":{}}`))
+	}))
+	defer srv.Close()
+
+	workspace := t.TempDir()
+	svc := NewService(configFor(srv.URL), workspace)
+	sources, err := buildSources(svc.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.sources = sources
+	svc.index.Upsert(&Document{ID: "confluence:gone", Collection: "confluence:ENG", Title: "Deleted page"})
+
+	if err := svc.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(cql[0], "lastmodified >=") {
+		t.Errorf("first sync should be full: %s", cql[0])
+	}
+	if svc.index.Len() != 1 {
+		t.Errorf("full sync should drop deleted pages, %d documents left", svc.index.Len())
+	}
+
+	pages = `{"id":"2","title":"Oncall","version":{"when":"2026-03-02T10:00:00Z"},
+		"body":{"storage":{"value":"<p>Page the oncall.</p>"}},"_links":{"webui":"/spaces/ENG/pages/2"}}`
+	if err := svc.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(cql[1], `space = "ENG"`) || !strings.Contains(cql[1], "lastmodified >=") {
+		t.Errorf("second sync should be incremental: %s", cql[1])
+	}
+
+	hits := NewIndex(Path(workspace)).Search("tuesdays deploys", 3)
+	if svc.index.Len() != 2 || len(hits) != 1 || hits[0].URL != srv.URL+"/spaces/ENG/pages/1" {
+		t.Fatalf("len %d, hits %+v", svc.index.Len(), hits)
+	}
+}
+
+func configFor(baseURL string) config.KnowledgeConfig {
+	return config.KnowledgeConfig{
+		Enabled: true,
+		Confluence: config.ConfluenceSyncConfig{
+			BaseURL: baseURL, Email: "me@example.com", APIToken: "tok", Spaces: []string{"ENG"},
+		},
+	}
+}
@@ -0,0 +1,224 @@
+package knowledge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	notionDefaultBaseURL = "https://api.notion.com/v1"
+	notionVersion        = "2022-06-28"
+	// notionMaxDepth bounds how deep nested blocks (toggles, lists) are read.
+	notionMaxDepth = 3
+)
+
+type notionSource struct {
+	token      string
+	databaseID string
+	baseURL    string
+	client     *http.Client
+}
+
+func (s *notionSource) Collection() string {
+	return "notion:" + s.databaseID
+}
+
+type notionRichText struct {
+	PlainText string `json:"plain_text"`
+}
+
+type notionPage struct {
+	ID             string    `json:"id"`
+	URL            string    `json:"url"`
+	LastEditedTime time.Time `json:"last_edited_time"`
+	Properties     map[string]struct {
+		Type  string           `json:"type"`
+		Title []notionRichText `json:"title"`
+	} `json:"properties"`
+}
+
+func (p notionPage) title() string {
+	for _, prop := range p.Properties {
+		if prop.Type == "title" {
+			return joinRichText(prop.Title)
+		}
+	}
+	return "Untitled"
+}
+
+type notionBlock struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	HasChildren bool   `json:"has_children"`
+	// raw holds the type-specific object, e.g. {"rich_text": [...]}.
+	raw map[string]json.RawMessage
+}
+
+func (b *notionBlock) UnmarshalJSON(data []byte) error {
+	type plain notionBlock
+	if err := json.Unmarshal(data, (*plain)(b)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &b.raw)
+}
+
+func (b notionBlock) text() string {
+	var body struct {
+		RichText []notionRichText `json:"rich_text"`
+		Checked  bool             `json:"checked"`
+	}
+	if err := json.Unmarshal(b.raw[b.Type], &body); err != nil {
+		return ""
+	}
+	text := joinRichText(body.RichText)
+	if text == "" {
+		return ""
+	}
+	switch b.Type {
+	case "heading_1":
+		return "# " + text
+	case "heading_2":
+		return "## " + text
+	case "heading_3":
+		return "### " + text
+	case "bulleted_list_item", "numbered_list_item":
+		return "- " + text
+	case "to_do":
+		if body.Checked {
+			return "[x] " + text
+		}
+		return "[ ] " + text
+	case "quote", "callout":
+		return "> " + text
+	}
+	return text
+}
+
+func joinRichText(parts []notionRichText) string {
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteString(p.PlainText)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Changed returns the database pages edited at or after since, or every page
+// when since is zero.
+func (s *notionSource) Changed(ctx context.Context, since time.Time) ([]*Document, error) {
+	var docs []*Document
+	cursor := ""
+	for {
+		body := map[string]any{"page_size": 100}
+		if cursor != "" {
+			body["start_cursor"] = cursor
+		}
+		if !since.IsZero() {
+			body["filter"] = map[string]any{
+				"timestamp":        "last_edited_time",
+				"last_edited_time": map[string]any{"on_or_after": since.UTC().Format(time.RFC3339)},
+			}
+		}
+		var resp struct {
+			Results    []notionPage `json:"results"`
+			HasMore    bool         `json:"has_more"`
+			NextCursor string       `json:"next_cursor"`
+		}
+		if err := s.do(ctx, http.MethodPost, "/databases/"+s.databaseID+"/query", body, &resp); err != nil {
+			return nil, err
+		}
+		for _, page := range resp.Results {
+			var text strings.Builder
+			if err := s.readBlocks(ctx, page.ID, 0, &text); err != nil {
+				return nil, fmt.Errorf("page %s: %w", page.ID, err)
+			}
+			docs = append(docs, &Document{
+				ID:         "notion:" + page.ID,
+				Collection: s.Collection(),
+				Title:      page.title(),
+				URL:        page.URL,
+				Updated:    page.LastEditedTime,
+				Chunks:     Chunk(text.String()),
+			})
+		}
+		if !resp.HasMore || resp.NextCursor == "" {
+			return docs, nil
+		}
+		cursor = resp.NextCursor
+	}
+}
+
+// readBlocks appends the text of a block's children, one paragraph per block.
+func (s *notionSource) readBlocks(ctx context.Context, blockID string, depth int, out *strings.Builder) error {
+	cursor := ""
+	for {
+		path := "/blocks/" + blockID + "/children?page_size=100"
+		if cursor != "" {
+			path += "&start_cursor=" + cursor
+		}
+		var resp struct {
+			Results    []notionBlock `json:"results"`
+			HasMore    bool          `json:"has_more"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		if err := s.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+			return err
+		}
+		for _, b := range resp.Results {
+			if text := b.text(); text != "" {
+				out.WriteString(text + "\n\n")
+			}
+			// Child pages are synced as their own database entries.
+			if b.HasChildren && b.Type != "child_page" && b.Type != "child_database" && depth < notionMaxDepth {
+				if err := s.readBlocks(ctx, b.ID, depth+1, out); err != nil {
+					return err
+				}
+			}
+		}
+		if !resp.HasMore || resp.NextCursor == "" {
+			return nil
+		}
+		cursor = resp.NextCursor
+	}
+}
+
+func (s *notionSource) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Notion-Version", notionVersion)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("notion returned %s: %s", resp.Status, utils.Truncate(strings.TrimSpace(string(data)), 300))
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,205 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultInterval  = time.Hour
+	fetchTimeout     = 30 * time.Second
+	syncTimeout      = 30 * time.Minute
+	maxResponseBytes = 10 << 20
+	// cursorOverlap re-fetches pages edited shortly before the last sync,
+	// covering clock skew and the minute precision of Confluence queries.
+	cursorOverlap = 5 * time.Minute
+)
+
+// source is one synced collection, a Notion database or Confluence space.
+type source interface {
+	Collection() string
+	// Changed returns pages edited at or after since; all pages if since is zero.
+	Changed(ctx context.Context, since time.Time) ([]*Document, error)
+}
+
+// Service syncs the configured collections into the index on a schedule.
+// The first sync of each collection after startup is a full one, which also
+// drops pages deleted at the source; later syncs only fetch edited pages.
+type Service struct {
+	cfg     config.KnowledgeConfig
+	index   *Index
+	sources []source
+	now     func() time.Time
+
+	mu       sync.Mutex
+	fullDone map[string]bool
+	stopChan chan struct{}
+}
+
+// NewService creates a sync service writing to the workspace's index.
+func NewService(cfg config.KnowledgeConfig, workspace string) *Service {
+	return &Service{
+		cfg:      cfg,
+		index:    NewIndex(Path(workspace)),
+		now:      time.Now,
+		fullDone: make(map[string]bool),
+	}
+}
+
+// Start validates the sources and begins syncing.
+func (s *Service) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.cfg.Enabled || s.stopChan != nil {
+		return nil
+	}
+	sources, err := buildSources(s.cfg)
+	if err != nil {
+		return err
+	}
+	s.sources = sources
+
+	s.stopChan = make(chan struct{})
+	go s.runLoop(s.stopChan)
+	logger.InfoCF("knowledge", "Knowledge sync started", map[string]any{"collections": len(sources)})
+	return nil
+}
+
+// Stop ends syncing.
+func (s *Service) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopChan != nil {
+		close(s.stopChan)
+		s.stopChan = nil
+	}
+}
+
+func buildSources(cfg config.KnowledgeConfig) ([]source, error) {
+	client := &http.Client{Timeout: fetchTimeout}
+	var sources []source
+
+	if len(cfg.Notion.DatabaseIDs) > 0 {
+		if cfg.Notion.Token == "" {
+			return nil, errors.New("knowledge: notion needs a token")
+		}
+		for _, id := range cfg.Notion.DatabaseIDs {
+			sources = append(sources, &notionSource{
+				token:      cfg.Notion.Token,
+				databaseID: strings.TrimSpace(id),
+				baseURL:    notionDefaultBaseURL,
+				client:     client,
+			})
+		}
+	}
+	if len(cfg.Confluence.Spaces) > 0 {
+		cc := cfg.Confluence
+		if cc.BaseURL == "" || cc.Email == "" || cc.APIToken == "" {
+			return nil, errors.New("knowledge: confluence needs base_url, email, and api_token")
+		}
+		for _, space := range cc.Spaces {
+			sources = append(sources, &confluenceSource{
+				baseURL:  strings.TrimRight(cc.BaseURL, "/"),
+				email:    cc.Email,
+				apiToken: cc.APIToken,
+				space:    strings.TrimSpace(space),
+				client:   client,
+			})
+		}
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("knowledge: set notion.database_ids or confluence.spaces")
+	}
+	return sources, nil
+}
+
+func (s *Service) runLoop(stopChan chan struct{}) {
+	interval := time.Duration(s.cfg.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+		if err := s.Sync(ctx); err != nil {
+			logger.WarnCF("knowledge", "Knowledge sync failed", map[string]any{"error": err.Error()})
+		}
+		cancel()
+
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync brings every collection up to date and saves the index. A failing
+// collection keeps its previous pages and does not stop the others.
+func (s *Service) Sync(ctx context.Context) error {
+	s.mu.Lock()
+	sources := s.sources
+	s.mu.Unlock()
+
+	var errs []error
+	for _, src := range sources {
+		if err := s.syncSource(ctx, src); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", src.Collection(), err))
+		}
+	}
+	if err := s.index.Save(); err != nil {
+		errs = append(errs, fmt.Errorf("save index: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) syncSource(ctx context.Context, src source) error {
+	collection := src.Collection()
+	started := s.now()
+
+	s.mu.Lock()
+	full := !s.fullDone[collection]
+	s.mu.Unlock()
+	var since time.Time
+	if cursor := s.index.Cursor(collection); !full && !cursor.IsZero() {
+		since = cursor.Add(-cursorOverlap)
+	}
+
+	docs, err := src.Changed(ctx, since)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		s.index.Upsert(doc)
+		keep[doc.ID] = true
+	}
+	removed := 0
+	if since.IsZero() {
+		removed = s.index.Prune(collection, keep)
+	}
+	s.index.SetCursor(collection, started)
+
+	s.mu.Lock()
+	s.fullDone[collection] = true
+	s.mu.Unlock()
+
+	logger.InfoCF("knowledge", "Collection synced", map[string]any{
+		"collection": collection,
+		"full":       since.IsZero(),
+		"updated":    len(docs),
+		"removed":    removed,
+	})
+	return nil
+}
@@ -0,0 +1,252 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// microTimeFormat is the wire format of metav1.MicroTime.
+	microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// KubernetesLock implements Lock with coordination.k8s.io/v1 Lease objects,
+// talking to the API server directly with the pod's service account.
+type KubernetesLock struct {
+	baseURL   string
+	namespace string
+	token     string
+	client    *http.Client
+}
+
+// NewKubernetesLock creates a lock using the in-cluster configuration. The
+// namespace defaults to the pod's own namespace.
+func NewKubernetesLock(namespace string) (*KubernetesLock, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("ha: not running inside a Kubernetes cluster (KUBERNETES_SERVICE_HOST unset)")
+	}
+
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("ha: read service account token: %w", err)
+	}
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("ha: read pod namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+
+	caPEM, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("ha: read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("ha: cluster CA bundle contains no certificates")
+	}
+
+	return newKubernetesLock(
+		"https://"+net.JoinHostPort(host, port),
+		namespace,
+		strings.TrimSpace(string(token)),
+		&http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	), nil
+}
+
+func newKubernetesLock(baseURL, namespace, token string, client *http.Client) *KubernetesLock {
+	return &KubernetesLock{baseURL: baseURL, namespace: namespace, token: token, client: client}
+}
+
+type lease struct {
+	APIVersion string         `json:"apiVersion"`
+	Kind       string         `json:"kind"`
+	Metadata   leaseMetadata  `json:"metadata"`
+	Spec       leaseSpecField `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpecField struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+}
+
+// leaseName turns a lock key into a valid DNS-1123 object name.
+func leaseName(key string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(key) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('-')
+		}
+	}
+	return strings.Trim(sb.String(), "-.")
+}
+
+func (l *KubernetesLock) TryAcquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	name := leaseName(key)
+	now := time.Now().UTC()
+	seconds := max(int(ttl/time.Second), 1)
+
+	cur, status, err := l.get(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	if status == http.StatusNotFound {
+		created := lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   leaseMetadata{Name: name, Namespace: l.namespace},
+			Spec: leaseSpecField{
+				HolderIdentity:       holder,
+				LeaseDurationSeconds: seconds,
+				AcquireTime:          now.Format(microTimeFormat),
+				RenewTime:            now.Format(microTimeFormat),
+			},
+		}
+		code, err := l.write(ctx, http.MethodPost, l.leasesURL(""), created)
+		if err != nil {
+			return false, err
+		}
+		// 409 means another replica created it first.
+		return code == http.StatusCreated || code == http.StatusOK, nil
+	}
+
+	if cur.Spec.HolderIdentity != holder && !leaseExpired(cur.Spec, now) {
+		return false, nil
+	}
+
+	if cur.Spec.HolderIdentity != holder {
+		cur.Spec.AcquireTime = now.Format(microTimeFormat)
+	}
+	cur.Spec.HolderIdentity = holder
+	cur.Spec.LeaseDurationSeconds = seconds
+	cur.Spec.RenewTime = now.Format(microTimeFormat)
+
+	// The resourceVersion makes this a compare-and-swap: a concurrent
+	// update by another replica fails with 409 Conflict.
+	code, err := l.write(ctx, http.MethodPut, l.leasesURL(name), cur)
+	if err != nil {
+		return false, err
+	}
+	return code == http.StatusOK, nil
+}
+
+func (l *KubernetesLock) Release(ctx context.Context, key, holder string) error {
+	name := leaseName(key)
+	cur, status, err := l.get(ctx, name)
+	if err != nil || status == http.StatusNotFound || cur.Spec.HolderIdentity != holder {
+		return err
+	}
+	cur.Spec.HolderIdentity = ""
+	cur.Spec.RenewTime = ""
+	_, err = l.write(ctx, http.MethodPut, l.leasesURL(name), cur)
+	return err
+}
+
+func leaseExpired(spec leaseSpecField, now time.Time) bool {
+	if spec.HolderIdentity == "" || spec.RenewTime == "" {
+		return true
+	}
+	renewed, err := time.Parse(microTimeFormat, spec.RenewTime)
+	if err != nil {
+		renewed, err = time.Parse(time.RFC3339, spec.RenewTime)
+		if err != nil {
+			return true
+		}
+	}
+	return now.After(renewed.Add(time.Duration(spec.LeaseDurationSeconds) * time.Second))
+}
+
+func (l *KubernetesLock) leasesURL(name string) string {
+	u := fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", l.baseURL, l.namespace)
+	if name != "" {
+		u += "/" + name
+	}
+	return u
+}
+
+func (l *KubernetesLock) get(ctx context.Context, name string) (*lease, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.leasesURL(name), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := l.do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, resp.StatusCode, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, resp.StatusCode, fmt.Errorf("ha: get lease %s: %s: %s", name, resp.Status, body)
+	}
+	var out lease
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("ha: decode lease %s: %w", name, err)
+	}
+	return &out, resp.StatusCode, nil
+}
+
+// write sends obj and returns the status code. Conflicts are reported as a
+// status, not an error, since they just mean another replica won the race.
+func (l *KubernetesLock) write(ctx context.Context, method, url string, obj any) (int, error) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := l.do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusConflict:
+		return resp.StatusCode, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return resp.StatusCode, fmt.Errorf("ha: %s lease: %s: %s", method, resp.Status, msg)
+}
+
+func (l *KubernetesLock) do(req *http.Request) (*http.Response, error) {
+	if l.token != "" {
+		req.Header.Set("Authorization", "Bearer "+l.token)
+	}
+	req.Header.Set("Accept", "application/json")
+	return l.client.Do(req)
+}
@@ -0,0 +1,243 @@
+// Package leader provides lease-based leader election so several gateway
+// replicas can run side by side while only one of them holds each channel's
+// platform connection and runs the scheduled jobs.
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultLeaseDuration = 15 * time.Second
+	defaultLeasePrefix   = "picoclaw"
+)
+
+// Lock is a lease-based distributed lock. Implementations must be safe for
+// concurrent use.
+type Lock interface {
+	// TryAcquire acquires the lease for key on behalf of holder, or renews it
+	// if holder already owns it. It reports whether holder owns the lease.
+	TryAcquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error)
+	// Release gives up the lease if holder owns it.
+	Release(ctx context.Context, key, holder string) error
+}
+
+// NewLock creates the lock backend selected in cfg.
+func NewLock(cfg config.HAConfig) (Lock, error) {
+	switch strings.ToLower(cfg.Backend) {
+	case "kubernetes", "k8s", "":
+		return NewKubernetesLock(cfg.Namespace)
+	case "redis":
+		if cfg.RedisAddr == "" {
+			return nil, fmt.Errorf("ha: redis_addr is required for the redis backend")
+		}
+		return NewRedisLock(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB), nil
+	case "memory":
+		return NewMemoryLock(), nil
+	default:
+		return nil, fmt.Errorf("ha: unknown lock backend %q", cfg.Backend)
+	}
+}
+
+// Identity returns the configured replica identity, falling back to the
+// hostname (the pod name on Kubernetes) plus the process ID.
+func Identity(cfg config.HAConfig) string {
+	if cfg.Identity != "" {
+		return cfg.Identity
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "picoclaw"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// LeaseKey returns the key of the lease called name, under the configured
+// prefix so several deployments can share a lock backend.
+func LeaseKey(cfg config.HAConfig, name string) string {
+	prefix := cfg.LeasePrefix
+	if prefix == "" {
+		prefix = defaultLeasePrefix
+	}
+	return prefix + "-" + name
+}
+
+// LeaseDuration returns the configured lease duration or the default.
+func LeaseDuration(cfg config.HAConfig) time.Duration {
+	if cfg.LeaseSeconds > 0 {
+		return time.Duration(cfg.LeaseSeconds) * time.Second
+	}
+	return defaultLeaseDuration
+}
+
+// Elector campaigns for a single lease and runs callbacks on leadership changes.
+type Elector struct {
+	lock     Lock
+	key      string
+	identity string
+	ttl      time.Duration
+
+	// OnStartedLeading is called when this replica becomes leader.
+	OnStartedLeading func(ctx context.Context)
+	// OnStoppedLeading is called when leadership is lost while running. It is
+	// not called when Run returns because its context was canceled; the
+	// caller owns shutdown in that case.
+	OnStoppedLeading func()
+
+	mu     sync.RWMutex
+	leader bool
+}
+
+// NewElector creates an elector for key. The lease is renewed every ttl/3.
+func NewElector(lock Lock, key, identity string, ttl time.Duration) *Elector {
+	if ttl <= 0 {
+		ttl = defaultLeaseDuration
+	}
+	return &Elector{lock: lock, key: key, identity: identity, ttl: ttl}
+}
+
+// IsLeader reports whether this replica currently holds the lease.
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// Run campaigns until ctx is canceled, then releases the lease if held.
+func (e *Elector) Run(ctx context.Context) {
+	retry := e.ttl / 3
+	ticker := time.NewTicker(retry)
+	defer ticker.Stop()
+
+	var lastRenew time.Time
+	for {
+		e.tick(ctx, &lastRenew)
+
+		select {
+		case <-ctx.Done():
+			if e.IsLeader() {
+				e.setLeader(false)
+				releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				if err := e.lock.Release(releaseCtx, e.key, e.identity); err != nil {
+					logger.WarnCF("leader", "Failed to release lease", map[string]any{
+						"key":   e.key,
+						"error": err.Error(),
+					})
+				}
+				cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) tick(ctx context.Context, lastRenew *time.Time) {
+	wasLeader := e.IsLeader()
+	// The lease we hold runs out ttl after the request that renewed it was
+	// sent. A leader must have stepped down a margin before that, so a
+	// renewal that hangs is cut off at the deadline instead of running past
+	// the moment another replica may take over.
+	deadline := lastRenew.Add(e.ttl - e.ttl/10)
+	reqDeadline := time.Now().Add(e.ttl / 3)
+	if wasLeader && deadline.Before(reqDeadline) {
+		reqDeadline = deadline
+	}
+	reqCtx, cancel := context.WithDeadline(ctx, reqDeadline)
+	start := time.Now()
+	ok, err := e.lock.TryAcquire(reqCtx, e.key, e.identity, e.ttl)
+	cancel()
+	if ctx.Err() != nil {
+		return
+	}
+
+	switch {
+	case err != nil:
+		logger.WarnCF("leader", "Lease request failed", map[string]any{
+			"key":   e.key,
+			"error": err.Error(),
+		})
+		// Keep leading through transient errors only while the next attempt,
+		// a retry interval from now, could still renew before the deadline.
+		if wasLeader && time.Now().Add(e.ttl/3).After(deadline) {
+			e.stepDown()
+		}
+	case ok:
+		*lastRenew = start
+		if !wasLeader {
+			e.setLeader(true)
+			logger.InfoCF("leader", "Acquired leadership", map[string]any{
+				"key":      e.key,
+				"identity": e.identity,
+			})
+			if e.OnStartedLeading != nil {
+				e.OnStartedLeading(ctx)
+			}
+		}
+	case wasLeader:
+		e.stepDown()
+	}
+}
+
+func (e *Elector) stepDown() {
+	e.setLeader(false)
+	logger.WarnCF("leader", "Lost leadership", map[string]any{
+		"key":      e.key,
+		"identity": e.identity,
+	})
+	if e.OnStoppedLeading != nil {
+		e.OnStoppedLeading()
+	}
+}
+
+func (e *Elector) setLeader(v bool) {
+	e.mu.Lock()
+	e.leader = v
+	e.mu.Unlock()
+}
+
+// MemoryLock is an in-process Lock, useful for tests and single-host setups.
+type MemoryLock struct {
+	mu     sync.Mutex
+	leases map[string]memoryLease
+}
+
+type memoryLease struct {
+	holder  string
+	expires time.Time
+}
+
+// NewMemoryLock creates an empty in-process lock.
+func NewMemoryLock() *MemoryLock {
+	return &MemoryLock{leases: make(map[string]memoryLease)}
+}
+
+func (l *MemoryLock) TryAcquire(_ context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if cur, ok := l.leases[key]; ok && cur.holder != holder && now.Before(cur.expires) {
+		return false, nil
+	}
+	l.leases[key] = memoryLease{holder: holder, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (l *MemoryLock) Release(_ context.Context, key, holder string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if cur, ok := l.leases[key]; ok && cur.holder == holder {
+		delete(l.leases, key)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package leader
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryLock(t *testing.T) {
+	ctx := context.Background()
+	l := NewMemoryLock()
+
+	if ok, _ := l.TryAcquire(ctx, "k", "a", time.Minute); !ok {
+		t.Fatal("first holder should acquire")
+	}
+	if ok, _ := l.TryAcquire(ctx, "k", "b", time.Minute); ok {
+		t.Fatal("second holder must not acquire a live lease")
+	}
+	if ok, _ := l.TryAcquire(ctx, "k", "a", time.Minute); !ok {
+		t.Fatal("holder should renew its own lease")
+	}
+	_ = l.Release(ctx, "k", "b")
+	if ok, _ := l.TryAcquire(ctx, "k", "b", time.Minute); ok {
+		t.Fatal("release by a non-holder must be ignored")
+	}
+	_ = l.Release(ctx, "k", "a")
+	if ok, _ := l.TryAcquire(ctx, "k", "b", time.Minute); !ok {
+		t.Fatal("lease should be free after release")
+	}
+}
+
+func TestMemoryLock_Expiry(t *testing.T) {
+	ctx := context.Background()
+	l := NewMemoryLock()
+	l.TryAcquire(ctx, "k", "a", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if ok, _ := l.TryAcquire(ctx, "k", "b", time.Minute); !ok {
+		t.Fatal("expired lease should be taken over")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("condition not met in time")
+}
+
+func TestElector_Failover(t *testing.T) {
+	lock := NewMemoryLock()
+	ttl := 60 * time.Millisecond
+
+	var aStarted, bStarted atomic.Int32
+	a := NewElector(lock, "k", "a", ttl)
+	a.OnStartedLeading = func(context.Context) { aStarted.Add(1) }
+	b := NewElector(lock, "k", "b", ttl)
+	b.OnStartedLeading = func(context.Context) { bStarted.Add(1) }
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	doneA := make(chan struct{})
+	go func() { a.Run(ctxA); close(doneA) }()
+	waitFor(t, a.IsLeader)
+
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+	go b.Run(ctxB)
+
+	time.Sleep(3 * ttl)
+	if b.IsLeader() || bStarted.Load() != 0 {
+		t.Fatal("standby must not lead while the leader renews")
+	}
+
+	cancelA()
+	<-doneA
+	if a.IsLeader() {
+		t.Error("canceled elector should not report leadership")
+	}
+	waitFor(t, b.IsLeader)
+	if aStarted.Load() != 1 || bStarted.Load() != 1 {
+		t.Errorf("unexpected start counts: a=%d b=%d", aStarted.Load(), bStarted.Load())
+	}
+}
+
+// stealingLock lets a test hand the lease to someone else.
+type stealingLock struct {
+	*MemoryLock
+	mu     sync.Mutex
+	stolen bool
+}
+
+func (l *stealingLock) TryAcquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	stolen := l.stolen
+	l.mu.Unlock()
+	if stolen {
+		return false, nil
+	}
+	return l.MemoryLock.TryAcquire(ctx, key, holder, ttl)
+}
+
+func TestElector_StepsDownWhenRenewalFails(t *testing.T) {
+	lock := &stealingLock{MemoryLock: NewMemoryLock()}
+	e := NewElector(lock, "k", "a", 30*time.Millisecond)
+	stopped := make(chan struct{})
+	e.OnStoppedLeading = func() { close(stopped) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+	waitFor(t, e.IsLeader)
+
+	lock.mu.Lock()
+	lock.stolen = true
+	lock.mu.Unlock()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected OnStoppedLeading after losing the lease")
+	}
+	if e.IsLeader() {
+		t.Error("elector should no longer lead")
+	}
+}
+
+// hangingLock grants the lease once and fails the next renewal, then hangs
+// on every request until its context ends, like a backend that stopped
+// answering.
+type hangingLock struct {
+	mu      sync.Mutex
+	calls   int
+	granted time.Time
+}
+
+func (l *hangingLock) TryAcquire(ctx context.Context, _, _ string, _ time.Duration) (bool, error) {
+	l.mu.Lock()
+	l.calls++
+	calls := l.calls
+	if calls == 1 {
+		l.granted = time.Now()
+	}
+	l.mu.Unlock()
+	switch calls {
+	case 1:
+		return true, nil
+	case 2:
+		return false, errors.New("connection reset")
+	}
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func (l *hangingLock) Release(context.Context, string, string) error { return nil }
+
+func TestElector_StepsDownBeforeLeaseExpiresWhenBackendHangs(t *testing.T) {
+	lock := &hangingLock{}
+	ttl := 300 * time.Millisecond
+	e := NewElector(lock, "k", "a", ttl)
+	stopped := make(chan time.Time, 1)
+	e.OnStoppedLeading = func() { stopped <- time.Now() }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+	waitFor(t, e.IsLeader)
+
+	select {
+	case at := <-stopped:
+		lock.mu.Lock()
+		held := at.Sub(lock.granted)
+		lock.mu.Unlock()
+		if held >= ttl {
+			t.Errorf("stepped down %v after the last renewal, lease lasts %v", held, ttl)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected OnStoppedLeading while the backend hangs")
+	}
+}
+
+func TestLeaseName(t *testing.T) {
+	if got := leaseName("PicoClaw-channel-whatsapp_native"); got != "picoclaw-channel-whatsapp-native" {
+		t.Errorf("leaseName = %q", got)
+	}
+}
+
+// fakeLeaseServer is a minimal coordination.k8s.io API with resourceVersion checks.
+type fakeLeaseServer struct {
+	mu      sync.Mutex
+	leases  map[string]lease
+	version int
+}
+
+func (s *fakeLeaseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	const base = "/apis/coordination.k8s.io/v1/namespaces/ns/leases"
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, base), "/")
+
+	switch r.Method {
+	case http.MethodGet:
+		l, ok := s.leases[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(l)
+	case http.MethodPost, http.MethodPut:
+		var l lease
+		json.NewDecoder(r.Body).Decode(&l)
+		cur, exists := s.leases[l.Metadata.Name]
+		if (r.Method == http.MethodPost && exists) ||
+			(r.Method == http.MethodPut && (!exists || cur.Metadata.ResourceVersion != l.Metadata.ResourceVersion)) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.version++
+		l.Metadata.ResourceVersion = strings.Repeat("v", s.version)
+		s.leases[l.Metadata.Name] = l
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(l)
+	}
+}
+
+func TestKubernetesLock(t *testing.T) {
+	srv := httptest.NewServer(&fakeLeaseServer{leases: map[string]lease{}})
+	defer srv.Close()
+	l := newKubernetesLock(srv.URL, "ns", "token", srv.Client())
+	ctx := context.Background()
+
+	if ok, err := l.TryAcquire(ctx, "picoclaw-channel-telegram", "a", time.Minute); err != nil || !ok {
+		t.Fatalf("create: ok=%v err=%v", ok, err)
+	}
+	if ok, err := l.TryAcquire(ctx, "picoclaw-channel-telegram", "b", time.Minute); err != nil || ok {
+		t.Fatalf("contender: ok=%v err=%v", ok, err)
+	}
+	if ok, err := l.TryAcquire(ctx, "picoclaw-channel-telegram", "a", time.Minute); err != nil || !ok {
+		t.Fatalf("renew: ok=%v err=%v", ok, err)
+	}
+	if err := l.Release(ctx, "picoclaw-channel-telegram", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := l.TryAcquire(ctx, "picoclaw-channel-telegram", "b", time.Minute); err != nil || !ok {
+		t.Fatalf("after release: ok=%v err=%v", ok, err)
+	}
+}
+
+func TestLeaseExpired(t *testing.T) {
+	now := time.Now().UTC()
+	spec := leaseSpecField{
+		HolderIdentity:       "a",
+		LeaseDurationSeconds: 10,
+		RenewTime:            now.Add(-5 * time.Second).Format(microTimeFormat),
+	}
+	if leaseExpired(spec, now) {
+		t.Error("lease renewed 5s ago with 10s duration should be live")
+	}
+	spec.RenewTime = now.Add(-15 * time.Second).Format(microTimeFormat)
+	if !leaseExpired(spec, now) {
+		t.Error("lease renewed 15s ago with 10s duration should be expired")
+	}
+}
+
+func TestReadRESP(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"+OK\r\n", "OK", false},
+		{":1\r\n", "1", false},
+		{"$5\r\nhello\r\n", "hello", false},
+		{"$-1\r\n", "", false},
+		{"-ERR wrong\r\n", "", true},
+	}
+	for _, tt := range tests {
+		got, err := readRESP(bufio.NewReader(strings.NewReader(tt.in)))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("readRESP(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
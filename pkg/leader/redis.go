@@ -0,0 +1,160 @@
+package leader
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lua scripts run atomically on the Redis server so that only the current
+// holder can renew or release its lease.
+const (
+	redisRenewScript = `if redis.call("get", KEYS[1]) == ARGV[1] then ` +
+		`return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	redisReleaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then ` +
+		`return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+// RedisLock implements Lock with SET NX PX leases on a single Redis server.
+// It speaks the minimal subset of RESP it needs, one connection per call.
+type RedisLock struct {
+	addr     string
+	password string
+	db       int
+}
+
+// NewRedisLock creates a lock against the Redis server at addr.
+func NewRedisLock(addr, password string, db int) *RedisLock {
+	return &RedisLock{addr: addr, password: password, db: db}
+}
+
+func (l *RedisLock) TryAcquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	conn, err := l.dial(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+	reply, err := conn.do("SET", key, holder, "NX", "PX", ms)
+	if err != nil {
+		return false, err
+	}
+	if reply == "OK" {
+		return true, nil
+	}
+
+	// Someone holds it; renew if that someone is us.
+	reply, err = conn.do("EVAL", redisRenewScript, "1", key, holder, ms)
+	if err != nil {
+		return false, err
+	}
+	return reply == "1", nil
+}
+
+func (l *RedisLock) Release(ctx context.Context, key, holder string) error {
+	conn, err := l.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.do("EVAL", redisReleaseScript, "1", key, holder)
+	return err
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (l *RedisLock) dial(ctx context.Context) (*redisConn, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", l.addr)
+	if err != nil {
+		return nil, fmt.Errorf("ha: connect redis: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = c.SetDeadline(deadline)
+	} else {
+		_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+	}
+
+	conn := &redisConn{Conn: c, r: bufio.NewReader(c)}
+	if l.password != "" {
+		if _, err := conn.do("AUTH", l.password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if l.db != 0 {
+		if _, err := conn.do("SELECT", strconv.Itoa(l.db)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// do sends a command and returns its reply as a string. Nil replies become "".
+func (c *redisConn) do(args ...string) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := c.Write([]byte(sb.String())); err != nil {
+		return "", fmt.Errorf("ha: redis write: %w", err)
+	}
+	return readRESP(c.r)
+}
+
+func readRESP(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("ha: redis read: %w", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("ha: empty redis reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("ha: redis error: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("ha: bad redis bulk length %q", line)
+		}
+		if n < 0 {
+			return "", nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := readFull(r, buf); err != nil {
+			return "", fmt.Errorf("ha: redis read: %w", err)
+		}
+		return string(buf[:n]), nil
+	default:
+		return "", fmt.Errorf("ha: unsupported redis reply %q", line)
+	}
+}
+
+func readFull(r *bufio.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := r.Read(buf[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
@@ -1,7 +1,11 @@
 package logger
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -10,6 +14,8 @@ import (
 	"sync"
 
 	"github.com/rs/zerolog"
+
+	"github.com/sipeed/picoclaw/pkg/requestid"
 )
 
 type LogLevel = zerolog.Level
@@ -43,19 +49,71 @@ func init() {
 	once.Do(func() {
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
 
-		consoleWriter := zerolog.ConsoleWriter{
-			Out:        os.Stdout,
-			TimeFormat: "15:04:05", // TODO: make it configurable???
-
-			// Custom formatter to handle multiline strings and JSON objects
-			FormatFieldValue: formatFieldValue,
-		}
-
-		logger = zerolog.New(consoleWriter).With().Timestamp().Caller().Logger()
+		logger = zerolog.New(consoleWriter(FormatText, os.Stdout)).With().Timestamp().Caller().Logger()
 		fileLogger = zerolog.Logger{}
+
+		// Route log/slog, and the log package with it, through this logger.
+		slog.SetDefault(slog.New(NewSlogHandler("")))
 	})
 }
 
+// Console output formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// consoleWriter returns the writer of format for out.
+func consoleWriter(format string, out io.Writer) io.Writer {
+	if format == FormatJSON {
+		return out
+	}
+	// formatFieldValue handles multiline strings and JSON objects.
+	return zerolog.ConsoleWriter{Out: out, TimeFormat: "15:04:05", FormatFieldValue: formatFieldValue}
+}
+
+// ParseLevel returns the level named name: debug, info, warn, or error.
+func ParseLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return DEBUG, nil
+	case "info":
+		return INFO, nil
+	case "warn", "warning":
+		return WARN, nil
+	case "error":
+		return ERROR, nil
+	}
+	return INFO, fmt.Errorf("unknown log level %q; use debug, info, warn, or error", name)
+}
+
+// SetFormat switches the console between human-readable text, the default,
+// and JSON, one object per line for log collectors.
+func SetFormat(format string) error {
+	switch format = strings.ToLower(strings.TrimSpace(format)); format {
+	case "", FormatText, FormatJSON:
+	default:
+		return fmt.Errorf("unknown log format %q; use text or json", format)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	logger = zerolog.New(consoleWriter(format, os.Stdout)).Level(logger.GetLevel()).With().Timestamp().Caller().Logger()
+	return nil
+}
+
+// Configure sets the level and console format by name. An empty level
+// keeps the current one, and an empty format is text.
+func Configure(level, format string) error {
+	if level != "" {
+		l, err := ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		SetLevel(l)
+	}
+	return SetFormat(format)
+}
+
 func formatFieldValue(i any) string {
 	var s string
 
@@ -155,6 +213,7 @@ func getCallerSkip() int {
 		// bypass common loggers
 		if strings.HasSuffix(file, "/logger.go") ||
 			strings.HasSuffix(file, "/logger_3rd_party.go") ||
+			strings.HasSuffix(file, "/logger_slog.go") ||
 			strings.HasSuffix(file, "/log.go") {
 			continue
 		}
@@ -195,6 +254,10 @@ func logMessage(level LogLevel, component string, message string, fields map[str
 
 	skip := getCallerSkip()
 
+	r := lineRedaction(redaction.Load(), fields)
+	message = redactMessage(r, message)
+	fields = redactFields(r, fields)
+
 	event := getEvent(logger, level)
 
 	if component != "" {
@@ -222,6 +285,45 @@ func logMessage(level LogLevel, component string, message string, fields map[str
 	}
 }
 
+type fieldsKey struct{}
+
+// WithFields returns a copy of ctx carrying fields, which the *Ctx functions
+// add to every line they log with it. Fields of an outer context are kept
+// unless fields replaces them.
+func WithFields(ctx context.Context, fields map[string]any) context.Context {
+	merged := maps.Clone(contextFields(ctx))
+	if merged == nil {
+		merged = make(map[string]any, len(fields))
+	}
+	maps.Copy(merged, fields)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+func contextFields(ctx context.Context) map[string]any {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).(map[string]any)
+	return fields
+}
+
+// withContext returns fields with the request ID and fields of ctx added.
+// The fields of the line win over those of ctx.
+func withContext(ctx context.Context, fields map[string]any) map[string]any {
+	extra := contextFields(ctx)
+	id := requestid.FromContext(ctx)
+	if len(extra) == 0 && id == "" {
+		return fields
+	}
+	out := make(map[string]any, len(extra)+len(fields)+1)
+	if id != "" {
+		out["request_id"] = id
+	}
+	maps.Copy(out, extra)
+	maps.Copy(out, fields)
+	return out
+}
+
 func appendFields(event *zerolog.Event, fields map[string]any) {
 	for k, v := range fields {
 		// Type switch to avoid double JSON serialization of strings
@@ -262,6 +364,11 @@ func DebugCF(component string, message string, fields map[string]any) {
 	logMessage(DEBUG, component, message, fields)
 }
 
+// DebugCtx logs like DebugCF, with the request ID and fields of ctx.
+func DebugCtx(ctx context.Context, component string, message string, fields map[string]any) {
+	logMessage(DEBUG, component, message, withContext(ctx, fields))
+}
+
 func Info(message string) {
 	logMessage(INFO, "", message, nil)
 }
@@ -282,6 +389,11 @@ func InfoCF(component string, message string, fields map[string]any) {
 	logMessage(INFO, component, message, fields)
 }
 
+// InfoCtx logs like InfoCF, with the request ID and fields of ctx.
+func InfoCtx(ctx context.Context, component string, message string, fields map[string]any) {
+	logMessage(INFO, component, message, withContext(ctx, fields))
+}
+
 func Warn(message string) {
 	logMessage(WARN, "", message, nil)
 }
@@ -298,6 +410,11 @@ func WarnCF(component string, message string, fields map[string]any) {
 	logMessage(WARN, component, message, fields)
 }
 
+// WarnCtx logs like WarnCF, with the request ID and fields of ctx.
+func WarnCtx(ctx context.Context, component string, message string, fields map[string]any) {
+	logMessage(WARN, component, message, withContext(ctx, fields))
+}
+
 func Error(message string) {
 	logMessage(ERROR, "", message, nil)
 }
@@ -318,6 +435,11 @@ func ErrorCF(component string, message string, fields map[string]any) {
 	logMessage(ERROR, component, message, fields)
 }
 
+// ErrorCtx logs like ErrorCF, with the request ID and fields of ctx.
+func ErrorCtx(ctx context.Context, component string, message string, fields map[string]any) {
+	logMessage(ERROR, component, message, withContext(ctx, fields))
+}
+
 func Fatal(message string) {
 	logMessage(FATAL, "", message, nil)
 }
@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler sends log/slog records, and through slog those of the log
+// package, to this logger, so they get the same format, level, redaction,
+// and request IDs as the rest of the logs.
+type SlogHandler struct {
+	component string
+	attrs     map[string]any
+	group     string
+}
+
+// NewSlogHandler returns a handler logging under component.
+func NewSlogHandler(component string) *SlogHandler {
+	return &SlogHandler{component: component}
+}
+
+// Enabled implements slog.Handler.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return fromSlogLevel(level) >= GetLevel()
+}
+
+// Handle implements slog.Handler. A "component" attribute replaces the
+// component of the handler.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := make(map[string]any, len(h.attrs)+r.NumAttrs())
+	for k, v := range h.attrs {
+		fields[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addSlogAttr(fields, h.group, a)
+		return true
+	})
+	component := h.component
+	if c, ok := fields["component"].(string); ok {
+		component = c
+		delete(fields, "component")
+	}
+	logMessage(fromSlogLevel(r.Level), component, r.Message, withContext(ctx, fields))
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.attrs = make(map[string]any, len(h.attrs)+len(attrs))
+	for k, v := range h.attrs {
+		next.attrs[k] = v
+	}
+	for _, a := range attrs {
+		addSlogAttr(next.attrs, h.group, a)
+	}
+	return &next
+}
+
+// WithGroup implements slog.Handler. Attributes in a group are logged as
+// "group.key".
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	next := *h
+	next.group = h.group + name + "."
+	return &next
+}
+
+func addSlogAttr(fields map[string]any, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			addSlogAttr(fields, prefix, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	if err, ok := v.Any().(error); ok {
+		fields[prefix+a.Key] = err.Error()
+		return
+	}
+	fields[prefix+a.Key] = v.Any()
+}
+
+// fromSlogLevel maps a slog level to a level of this logger. Levels above
+// error stay errors, so slog never exits the program.
+func fromSlogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < slog.LevelWarn:
+		return INFO
+	case level < slog.LevelError:
+		return WARN
+	}
+	return ERROR
+}
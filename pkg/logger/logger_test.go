@@ -1,7 +1,15 @@
 package logger
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
 	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/sipeed/picoclaw/pkg/requestid"
 )
 
 func TestLogLevelFiltering(t *testing.T) {
@@ -252,3 +260,79 @@ func TestFormatFieldValue(t *testing.T) {
 		})
 	}
 }
+
+// captureJSON sends the console to a buffer as JSON until the test ends.
+func captureJSON(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	mu.Lock()
+	prev := logger
+	logger = zerolog.New(consoleWriter(FormatJSON, &buf))
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		logger = prev
+		mu.Unlock()
+	})
+	return &buf
+}
+
+func lastLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	var line map[string]any
+	if err := json.Unmarshal(lines[len(lines)-1], &line); err != nil {
+		t.Fatalf("not JSON: %q", buf.String())
+	}
+	return line
+}
+
+func TestCtxLoggingAddsRequestAndContextFields(t *testing.T) {
+	buf := captureJSON(t)
+	ctx := requestid.NewContext(context.Background(), "req_1")
+	ctx = WithFields(ctx, map[string]any{"session_key": "s1", "agent_id": "main"})
+	ctx = WithFields(ctx, map[string]any{"agent_id": "helper"})
+
+	InfoCtx(ctx, "agent", "hello", map[string]any{"session_key": "s2"})
+	line := lastLine(t, buf)
+	if line["request_id"] != "req_1" || line["agent_id"] != "helper" || line["session_key"] != "s2" {
+		t.Errorf("line = %v", line)
+	}
+	if line["component"] != "agent" || line["message"] != "hello" {
+		t.Errorf("line = %v", line)
+	}
+}
+
+func TestSlogHandlerRoutesThroughLogger(t *testing.T) {
+	buf := captureJSON(t)
+	log := slog.New(NewSlogHandler("skills")).With("registry", "hub").WithGroup("req")
+	ctx := requestid.NewContext(context.Background(), "req_2")
+
+	log.WarnContext(ctx, "search failed", "status", 503)
+	line := lastLine(t, buf)
+	if line["level"] != "warn" || line["component"] != "skills" || line["request_id"] != "req_2" {
+		t.Errorf("line = %v", line)
+	}
+	if line["registry"] != "hub" || line["req.status"] != float64(503) {
+		t.Errorf("line = %v", line)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	initialLevel := GetLevel()
+	defer SetLevel(initialLevel)
+	defer SetFormat(FormatText)
+
+	if err := Configure("warn", "json"); err != nil || GetLevel() != WARN {
+		t.Fatalf("Configure = %v, level %v", err, GetLevel())
+	}
+	if err := Configure("", ""); err != nil || GetLevel() != WARN {
+		t.Errorf("an empty level changed the level: %v, %v", err, GetLevel())
+	}
+	if err := Configure("loud", ""); err == nil {
+		t.Error("unknown level accepted")
+	}
+	if err := SetFormat("xml"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -0,0 +1,302 @@
+package logger
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Redaction controls what the logger masks before writing a line.
+type Redaction struct {
+	// Secrets masks API keys, tokens, and passwords in messages and fields.
+	Secrets bool
+	// Content replaces fields that carry user or model text (content,
+	// preview, args, ...) with their length.
+	Content bool
+	// PrivacyMode logs metadata only: every string field that is not a known
+	// identifier, name, or status is replaced with its length.
+	PrivacyMode bool
+	// Patterns are more secrets to mask, besides the built-in ones. Each
+	// match is replaced whole.
+	Patterns []*regexp.Regexp
+}
+
+// redaction is read on every log call, so it is swapped atomically.
+var redaction atomic.Pointer[Redaction]
+
+func init() {
+	redaction.Store(&Redaction{Secrets: true})
+}
+
+// SetRedaction replaces the redaction settings.
+func SetRedaction(r Redaction) {
+	redaction.Store(&r)
+}
+
+// GetRedaction returns the current redaction settings.
+func GetRedaction() Redaction {
+	return *redaction.Load()
+}
+
+// RedactContent returns s, or a length placeholder when content redaction
+// or privacy mode is on. Use it for user or model text that has to go into
+// the log message itself rather than a field.
+func RedactContent(s string) string {
+	if r := redaction.Load(); r.Content || r.PrivacyMode {
+		return redactedLength(s)
+	}
+	return s
+}
+
+// privateRequests holds the request IDs whose lines are always logged as in
+// privacy mode, e.g. the turns of ephemeral conversations.
+var privateRequests sync.Map
+
+// SetPrivateRequest turns privacy-mode logging on or off for the lines
+// carrying a request_id field of id.
+func SetPrivateRequest(id string, private bool) {
+	if id == "" {
+		return
+	}
+	if private {
+		privateRequests.Store(id, struct{}{})
+	} else {
+		privateRequests.Delete(id)
+	}
+}
+
+// RedactRequestContent is RedactContent for a line of request id, which
+// always redacts for private requests.
+func RedactRequestContent(id, s string) string {
+	if isPrivateRequest(id) {
+		return RedactPrivateContent(s)
+	}
+	return RedactContent(s)
+}
+
+// RedactPrivateContent returns the length placeholder of privacy mode for s
+// whatever the settings, for text that is never logged.
+func RedactPrivateContent(s string) string {
+	return redactedLength(s)
+}
+
+func isPrivateRequest(id string) bool {
+	if id == "" {
+		return false
+	}
+	_, ok := privateRequests.Load(id)
+	return ok
+}
+
+// lineRedaction returns the settings for a line with fields: privacy mode
+// for private requests, r otherwise.
+func lineRedaction(r *Redaction, fields map[string]any) *Redaction {
+	if id, _ := fields["request_id"].(string); isPrivateRequest(id) && !r.PrivacyMode {
+		private := *r
+		private.PrivacyMode = true
+		return &private
+	}
+	return r
+}
+
+// secretPatterns match credentials embedded in free text. Each keeps a short
+// prefix so the kind of secret is still recognisable in logs.
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// Telegram bot tokens in API URLs
+	{regexp.MustCompile(`(bot\d+:)([A-Za-z0-9_-]{4})[A-Za-z0-9_-]{12,}([A-Za-z0-9_-]{4})`), "${1}${2}****${3}"},
+	// Bare Telegram bot tokens
+	{regexp.MustCompile(`\b(\d{8,10}:)[A-Za-z0-9_-]{35}\b`), "${1}****"},
+	// OpenAI / Anthropic / OpenRouter style keys
+	{regexp.MustCompile(`\b(sk-(?:ant-|or-|proj-)?)[A-Za-z0-9_-]{16,}`), "${1}****"},
+	// Slack tokens
+	{regexp.MustCompile(`\b(xox[abposr]-)[A-Za-z0-9-]{10,}`), "${1}****"},
+	{regexp.MustCompile(`\b(xapp-)[A-Za-z0-9-]{10,}`), "${1}****"},
+	// GitHub tokens
+	{regexp.MustCompile(`\b(gh[pousr]_)[A-Za-z0-9]{20,}`), "${1}****"},
+	{regexp.MustCompile(`\b(github_pat_)[A-Za-z0-9_]{20,}`), "${1}****"},
+	// Google API keys
+	{regexp.MustCompile(`\b(AIza)[0-9A-Za-z_-]{35}`), "${1}****"},
+	// AWS access key IDs, and secret keys and session tokens next to their names
+	{regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`), "${1}****"},
+	{
+		regexp.MustCompile(`(?i)\b(aws_secret_access_key|aws_session_token)(["']?\s*[=:]\s*["']?)[A-Za-z0-9/+=]{20,}`),
+		"${1}${2}****",
+	},
+	// Discord bot tokens
+	{regexp.MustCompile(`\b([MNO][A-Za-z0-9_-]{23,27}\.)[A-Za-z0-9_-]{6}\.[A-Za-z0-9_-]{27,}`), "${1}****"},
+	// Stripe secret and restricted keys
+	{regexp.MustCompile(`\b((?:sk|rk)_live_)[A-Za-z0-9]{16,}`), "${1}****"},
+	// PEM private keys
+	{
+		regexp.MustCompile(`-----BEGIN ([A-Z ]*)PRIVATE KEY-----[\s\S]*?(-----END [A-Z ]*PRIVATE KEY-----|$)`),
+		"-----BEGIN ${1}PRIVATE KEY-----****",
+	},
+	// JWTs
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+`), "eyJ****"},
+	// Authorization headers
+	{regexp.MustCompile(`(?i)\b(bearer)\s+[A-Za-z0-9._~+/-]{8,}=*`), "${1} ****"},
+	{regexp.MustCompile(`(?i)\b(authorization:\s*basic)\s+[A-Za-z0-9+/]+=*`), "${1} ****"},
+	// key=value pairs in URLs, query strings, and DSNs
+	{
+		regexp.MustCompile(`(?i)\b((?:api[_-]?key|access[_-]?token|auth[_-]?token|token|secret|password|passwd)=)[^&\s"']+`),
+		"${1}****",
+	},
+	// user:password@ in URLs
+	{regexp.MustCompile(`(://[^:/@\s]+:)[^@/\s]+@`), "${1}****@"},
+}
+
+// maskSecrets replaces credentials embedded in s with a redacted placeholder.
+func maskSecrets(s string) string {
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	for _, re := range redaction.Load().Patterns {
+		s = re.ReplaceAllLiteralString(s, "****")
+	}
+	return s
+}
+
+// MaskSecrets masks the credentials in s that the logger masks, the
+// built-in kinds and the configured patterns, whatever the redaction
+// settings. Use it for text that leaves the process, such as replies.
+func MaskSecrets(s string) string {
+	return maskSecrets(s)
+}
+
+// secretKeyParts mark field names whose value is always a credential.
+var secretKeyParts = []string{"api_key", "apikey", "token", "secret", "password", "passwd", "authorization", "cookie"}
+
+// contentKeys are field names that carry user or model text.
+var contentKeys = map[string]bool{
+	"content":           true,
+	"preview":           true,
+	"text":              true,
+	"response":          true,
+	"result":            true,
+	"messages":          true,
+	"messages_json":     true,
+	"args":              true,
+	"input":             true,
+	"query":             true,
+	"search_query":      true,
+	"reasoning":         true,
+	"reasoning_content": true,
+	"tool_calls":        true,
+	"user_message":      true,
+	"system_prompt":     true,
+	"transcription":     true,
+	"old_text":          true,
+	"new_text":          true,
+	"caption":           true,
+	"body":              true,
+}
+
+// metadataSuffixes mark fields that stay readable in privacy mode.
+var metadataSuffixes = []string{
+	"_id", "_key", "_name", "_count", "_len", "_length", "_chars", "_size", "_ms", "_seconds", "_type", "_mode",
+}
+
+// metadataKeys stay readable in privacy mode.
+var metadataKeys = map[string]bool{
+	"id": true, "channel": true, "component": true, "model": true, "provider": true, "tool": true,
+	"agent_id": true, "status": true, "type": true, "variant": true, "path": true, "url": true, "error": true,
+	"action": true, "role": true, "name": true, "iteration": true, "duration": true, "mode": true,
+}
+
+func isSecretKey(key string) bool {
+	k := strings.ToLower(key)
+	// token counts such as prompt_tokens are metadata, not credentials
+	if strings.HasSuffix(k, "tokens") || strings.HasSuffix(k, "_count") || strings.HasSuffix(k, "_len") {
+		return false
+	}
+	for _, part := range secretKeyParts {
+		if strings.Contains(k, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func isContentKey(key string) bool {
+	k := strings.ToLower(key)
+	return contentKeys[k] || strings.HasSuffix(k, "_preview") || strings.HasSuffix(k, "_content")
+}
+
+func isMetadataKey(key string) bool {
+	k := strings.ToLower(key)
+	if metadataKeys[k] {
+		return true
+	}
+	for _, suffix := range metadataSuffixes {
+		if strings.HasSuffix(k, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactedLength describes a removed value without revealing it.
+func redactedLength(v any) string {
+	switch val := v.(type) {
+	case string:
+		return fmt.Sprintf("[redacted %d chars]", len([]rune(val)))
+	case []byte:
+		return fmt.Sprintf("[redacted %d bytes]", len(val))
+	default:
+		return "[redacted]"
+	}
+}
+
+// redactMessage applies the current settings to a log message.
+func redactMessage(r *Redaction, msg string) string {
+	if r.Secrets || r.PrivacyMode {
+		return maskSecrets(msg)
+	}
+	return msg
+}
+
+// redactFields returns fields with the current settings applied. The input
+// map is never modified, because callers often reuse it.
+func redactFields(r *Redaction, fields map[string]any) map[string]any {
+	if len(fields) == 0 || (!r.Secrets && !r.Content && !r.PrivacyMode) {
+		return fields
+	}
+
+	out := make(map[string]any, len(fields))
+	for k, v := range fields {
+		switch {
+		case (r.Secrets || r.PrivacyMode) && isSecretKey(k):
+			if v == nil || v == "" {
+				out[k] = v
+			} else {
+				out[k] = "****"
+			}
+		case (r.Content || r.PrivacyMode) && isContentKey(k) && !isScalar(v):
+			out[k] = redactedLength(v)
+		case r.PrivacyMode && !isMetadataKey(k) && !isScalar(v):
+			out[k] = redactedLength(v)
+		default:
+			if s, ok := v.(string); ok && (r.Secrets || r.PrivacyMode) {
+				v = maskSecrets(s)
+			}
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// isScalar reports whether v is a number, bool, or nil: values that cannot
+// carry message text.
+func isScalar(v any) bool {
+	switch v.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	default:
+		return false
+	}
+}
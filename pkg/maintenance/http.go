@@ -0,0 +1,78 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/auth"
+)
+
+// HTTPPath is where the gateway mounts the maintenance API.
+const HTTPPath = "/maintenance"
+
+type statusResponse struct {
+	Active  bool      `json:"active"`
+	Message string    `json:"message"`
+	Mode    string    `json:"mode"`
+	Queued  int       `json:"queued"`
+	Since   time.Time `json:"since,omitzero"`
+	By      string    `json:"by,omitempty"`
+}
+
+type toggleRequest struct {
+	Active  *bool  `json:"active"`
+	Message string `json:"message,omitempty"`
+}
+
+// ServeHTTP implements the maintenance API. GET returns the status; POST with
+// {"active": true|false, "message": "..."} toggles it. Both require
+// "Authorization: Bearer <api_token>", and the API is off without a token.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !c.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req toggleRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil || req.Active == nil {
+			http.Error(w, `body must be {"active": true|false}`, http.StatusBadRequest)
+			return
+		}
+		var err error
+		if *req.Active {
+			err = c.Enable("api", req.Message)
+		} else {
+			_, err = c.Disable("api")
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := c.Status()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statusResponse{
+		Active:  state.Active,
+		Message: c.Notice(),
+		Mode:    c.Mode(),
+		Queued:  c.Queued(),
+		Since:   state.Since,
+		By:      state.By,
+	})
+}
+
+func (c *Controller) authorized(r *http.Request) bool {
+	c.mu.Lock()
+	token, adminToken := c.cfg.APIToken, c.adminToken
+	c.mu.Unlock()
+	return auth.BearerTokenMatches(r, token, adminToken)
+}
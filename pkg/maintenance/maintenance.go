@@ -0,0 +1,256 @@
+// Package maintenance implements a switch that takes the bot out of service
+// without stopping the gateway, e.g. while providers are being migrated.
+// While maintenance is on, users get a notice instead of an answer, new work
+// is rejected or held in a bounded queue, and scheduled jobs are paused.
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Modes for work that arrives during maintenance.
+const (
+	ModeReject = "reject"
+	ModeQueue  = "queue"
+)
+
+const defaultMessage = "PicoClaw is down for maintenance. Please try again later."
+
+// State is the persisted maintenance status.
+type State struct {
+	Active  bool      `json:"active"`
+	Message string    `json:"message,omitempty"` // overrides the configured notice
+	Since   time.Time `json:"since,omitzero"`
+	By      string    `json:"by,omitempty"`
+}
+
+// Path returns the maintenance state location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "maintenance.json")
+}
+
+// Controller holds the maintenance state. It is safe for concurrent use; a
+// nil *Controller is never in maintenance.
+type Controller struct {
+	path string
+
+	mu         sync.Mutex
+	cfg        config.MaintenanceConfig
+	adminToken string
+	state      State
+	queue      []bus.InboundMessage
+	bus        *bus.MessageBus
+	listeners  []func(State)
+}
+
+// NewController loads the persisted state for workspace. Maintenance that was
+// switched on at runtime survives a restart; cfg.Enabled forces it on at startup.
+func NewController(cfg config.MaintenanceConfig, workspace string) *Controller {
+	c := &Controller{path: Path(workspace), cfg: cfg}
+	if data, err := os.ReadFile(c.path); err == nil {
+		if err := json.Unmarshal(data, &c.state); err != nil {
+			logger.WarnCF("maintenance", "Ignoring unreadable maintenance state", map[string]any{
+				"path":  c.path,
+				"error": err.Error(),
+			})
+			c.state = State{}
+		}
+	}
+	if cfg.Enabled && !c.state.Active {
+		c.state = State{Active: true, Since: time.Now(), By: "config"}
+	}
+	return c
+}
+
+// SetBus sets the bus that queued messages are republished on when
+// maintenance ends.
+func (c *Controller) SetBus(msgBus *bus.MessageBus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bus = msgBus
+}
+
+// Configure applies a reloaded config. The current state is kept.
+func (c *Controller) Configure(cfg config.MaintenanceConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg = cfg
+}
+
+// SetAdminToken lets the admin API token authorize the HTTP API as well as
+// api_token.
+func (c *Controller) SetAdminToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.adminToken = token
+}
+
+// OnChange registers fn to be called after maintenance is switched on or off.
+func (c *Controller) OnChange(fn func(State)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, fn)
+}
+
+// Active reports whether maintenance is on.
+func (c *Controller) Active() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.Active
+}
+
+// Status returns the current state.
+func (c *Controller) Status() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Queued returns the number of messages held for later.
+func (c *Controller) Queued() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.queue)
+}
+
+// Mode returns how new work is handled during maintenance.
+func (c *Controller) Mode() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.modeLocked()
+}
+
+func (c *Controller) modeLocked() string {
+	if strings.EqualFold(c.cfg.Mode, ModeQueue) {
+		return ModeQueue
+	}
+	return ModeReject
+}
+
+// Notice returns the text sent to users during maintenance.
+func (c *Controller) Notice() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.state.Message != "":
+		return c.state.Message
+	case c.cfg.Message != "":
+		return c.cfg.Message
+	default:
+		return defaultMessage
+	}
+}
+
+// IsAdmin reports whether the sender may toggle maintenance and keeps using
+// the bot while it is on. Entries use the same formats as allow_from.
+func (c *Controller) IsAdmin(sender bus.SenderInfo, senderID string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	admins := c.cfg.Admins
+	c.mu.Unlock()
+	for _, admin := range admins {
+		if identity.MatchAllowed(sender, admin) || (senderID != "" && strings.TrimSpace(admin) == senderID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enable switches maintenance on. An empty message keeps the configured notice.
+func (c *Controller) Enable(by, message string) error {
+	c.mu.Lock()
+	if c.state.Active && message == c.state.Message {
+		c.mu.Unlock()
+		return nil
+	}
+	c.state = State{Active: true, Message: message, Since: time.Now(), By: by}
+	return c.commitLocked()
+}
+
+// Disable switches maintenance off and republishes queued messages. It
+// returns the number of messages released.
+func (c *Controller) Disable(by string) (int, error) {
+	c.mu.Lock()
+	if !c.state.Active {
+		c.mu.Unlock()
+		return 0, nil
+	}
+	c.state = State{Active: false, Since: time.Now(), By: by}
+	queued, msgBus := c.queue, c.bus
+	c.queue = nil
+	// Release the queue even if saving failed; the messages would be lost otherwise.
+	err := c.commitLocked()
+
+	if len(queued) > 0 && msgBus != nil {
+		go func() {
+			for _, msg := range queued {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				if err := msgBus.PublishInbound(ctx, msg); err != nil {
+					logger.WarnCF("maintenance", "Dropped queued message", map[string]any{
+						"channel":    msg.Channel,
+						"chat_id":    msg.ChatID,
+						"request_id": msg.RequestID,
+						"error":      err.Error(),
+					})
+				}
+				cancel()
+			}
+		}()
+	}
+	return len(queued), err
+}
+
+// Hold queues msg for processing after maintenance. It returns false when
+// the controller rejects new work or the queue is full.
+func (c *Controller) Hold(msg bus.InboundMessage) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.state.Active || c.modeLocked() != ModeQueue {
+		return false
+	}
+	if c.cfg.QueueLimit > 0 && len(c.queue) >= c.cfg.QueueLimit {
+		return false
+	}
+	c.queue = append(c.queue, msg)
+	return true
+}
+
+// commitLocked persists the state, unlocks, and notifies listeners.
+func (c *Controller) commitLocked() error {
+	state, listeners := c.state, c.listeners
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		err = fileutil.WriteFileAtomic(c.path, data, 0o644)
+	}
+	c.mu.Unlock()
+
+	logger.InfoCF("maintenance", "Maintenance mode changed", map[string]any{
+		"active": state.Active,
+		"by":     state.By,
+	})
+	for _, fn := range listeners {
+		fn(state)
+	}
+	if err != nil {
+		return fmt.Errorf("maintenance state not saved: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package maintenance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestController_PersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	c := NewController(config.MaintenanceConfig{Message: "back soon"}, dir)
+	if c.Active() {
+		t.Fatal("should start inactive")
+	}
+
+	var changes []bool
+	c.OnChange(func(s State) { changes = append(changes, s.Active) })
+	if err := c.Enable("telegram:1", "migrating providers"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Notice(); got != "migrating providers" {
+		t.Errorf("Notice = %q", got)
+	}
+
+	restarted := NewController(config.MaintenanceConfig{Message: "back soon"}, dir)
+	if st := restarted.Status(); !st.Active || st.By != "telegram:1" {
+		t.Fatalf("state after restart = %+v", st)
+	}
+
+	if _, err := c.Disable("telegram:1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Notice(); got != "back soon" {
+		t.Errorf("Notice after disable = %q, want configured message", got)
+	}
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("OnChange got %v", changes)
+	}
+}
+
+func TestController_EnabledInConfig(t *testing.T) {
+	c := NewController(config.MaintenanceConfig{Enabled: true}, t.TempDir())
+	if !c.Active() || c.Status().By != "config" {
+		t.Fatalf("status = %+v", c.Status())
+	}
+	if c.Notice() != defaultMessage {
+		t.Errorf("Notice = %q", c.Notice())
+	}
+}
+
+func TestController_QueueAndRelease(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+
+	c := NewController(config.MaintenanceConfig{Mode: "queue", QueueLimit: 2}, t.TempDir())
+	c.SetBus(msgBus)
+
+	if c.Hold(bus.InboundMessage{Content: "early"}) {
+		t.Fatal("nothing should be held outside maintenance")
+	}
+	c.Enable("test", "")
+	for i, want := range []bool{true, true, false} {
+		if got := c.Hold(bus.InboundMessage{Channel: "cli", Content: string(rune('a' + i))}); got != want {
+			t.Errorf("Hold #%d = %v, want %v", i, got, want)
+		}
+	}
+
+	released, err := c.Disable("test")
+	if err != nil || released != 2 {
+		t.Fatalf("Disable = %d, %v", released, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, want := range []string{"a", "b"} {
+		select {
+		case msg := <-msgBus.InboundChan():
+			if msg.Content != want {
+				t.Fatalf("republished %q, want %q", msg.Content, want)
+			}
+		case <-ctx.Done():
+			t.Fatal("queued messages were not republished")
+		}
+	}
+}
+
+func TestController_RejectModeHoldsNothing(t *testing.T) {
+	c := NewController(config.MaintenanceConfig{}, t.TempDir())
+	c.Enable("test", "")
+	if c.Hold(bus.InboundMessage{}) {
+		t.Error("reject mode should not queue")
+	}
+}
+
+func TestController_IsAdmin(t *testing.T) {
+	c := NewController(config.MaintenanceConfig{Admins: []string{"telegram:42", "7"}}, t.TempDir())
+	if !c.IsAdmin(bus.SenderInfo{Platform: "telegram", PlatformID: "42"}, "") {
+		t.Error("canonical admin entry should match")
+	}
+	if !c.IsAdmin(bus.SenderInfo{}, "7") {
+		t.Error("raw sender ID should match")
+	}
+	if c.IsAdmin(bus.SenderInfo{Platform: "telegram", PlatformID: "43"}, "43") {
+		t.Error("non-admin matched")
+	}
+	var nilController *Controller
+	if nilController.IsAdmin(bus.SenderInfo{}, "7") || nilController.Active() {
+		t.Error("nil controller should be inert")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	c := NewController(config.MaintenanceConfig{APIToken: "secret"}, t.TempDir())
+
+	do := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, HTTPPath, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodGet, "wrong", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status %d", w.Code)
+	}
+	if w := do(http.MethodGet, "admin", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("admin token before SetAdminToken: status %d", w.Code)
+	}
+	c.SetAdminToken("admin")
+	if w := do(http.MethodGet, "admin", ""); w.Code != http.StatusOK {
+		t.Errorf("admin token: status %d", w.Code)
+	}
+	if w := do(http.MethodPost, "secret", `{"message":"x"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing active: status %d", w.Code)
+	}
+	w := do(http.MethodPost, "secret", `{"active":true,"message":"upgrading"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"message":"upgrading"`) {
+		t.Fatalf("enable: %d %s", w.Code, w.Body)
+	}
+	if st := c.Status(); !st.Active || st.By != "api" {
+		t.Errorf("status = %+v", st)
+	}
+	if w := do(http.MethodGet, "secret", ""); !strings.Contains(w.Body.String(), `"active":true`) {
+		t.Errorf("status body = %s", w.Body)
+	}
+
+	noToken := NewController(config.MaintenanceConfig{}, t.TempDir())
+	req := httptest.NewRequest(http.MethodGet, HTTPPath, nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	noToken.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("API without configured token should be closed, got %d", rec.Code)
+	}
+}
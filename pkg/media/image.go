@@ -0,0 +1,108 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register decoder
+	"image/jpeg"
+	_ "image/png" // register decoder
+)
+
+// Limits that images sent to vision models are kept within. The stricter
+// provider limits win: Claude scales anything past 1568px on the long side
+// down anyway and rejects images over 5 MB.
+const (
+	MaxImageDimension = 1568
+	MaxImageBytes     = 5 << 20
+
+	// maxDecodePixels refuses to decode images that would take too much
+	// memory, such as decompression bombs.
+	maxDecodePixels = 50_000_000
+)
+
+// jpegQualities are tried in turn until a downscaled image fits.
+var jpegQualities = []int{85, 70, 50}
+
+// FitImage returns an image that fits within maxDim pixels on its long side
+// and maxBytes. Images already within both are returned as they are, with
+// mime unchanged; others are scaled down and re-encoded as JPEG. Formats
+// that cannot be decoded (e.g. WebP) are only returned if they fit.
+func FitImage(data []byte, mime string, maxDim, maxBytes int) ([]byte, string, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if len(data) <= maxBytes {
+			return data, mime, nil
+		}
+		return nil, "", fmt.Errorf("image of %d bytes is too large and cannot be scaled: %w", len(data), err)
+	}
+	if len(data) <= maxBytes && cfg.Width <= maxDim && cfg.Height <= maxDim {
+		return data, mime, nil
+	}
+	if cfg.Width*cfg.Height > maxDecodePixels {
+		return nil, "", fmt.Errorf("image of %dx%d pixels is too large to scale", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decoding image: %w", err)
+	}
+	w, h := fitDimensions(cfg.Width, cfg.Height, maxDim)
+	dst := scaleDown(src, w, h)
+	for _, quality := range jpegQualities {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", fmt.Errorf("encoding image: %w", err)
+		}
+		if buf.Len() <= maxBytes {
+			return buf.Bytes(), "image/jpeg", nil
+		}
+	}
+	return nil, "", fmt.Errorf("image does not fit in %d bytes even when scaled to %dx%d", maxBytes, w, h)
+}
+
+// fitDimensions scales w×h to fit within maxDim on its long side, keeping
+// the aspect ratio.
+func fitDimensions(w, h, maxDim int) (int, int) {
+	if w <= maxDim && h <= maxDim {
+		return w, h
+	}
+	if w >= h {
+		return maxDim, max(1, h*maxDim/w)
+	}
+	return max(1, w*maxDim/h), maxDim
+}
+
+// scaleDown resizes src to w×h by averaging the source pixels that fall in
+// each destination pixel. Transparent areas are flattened onto white, since
+// JPEG has no alpha channel.
+func scaleDown(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		y0 := b.Min.Y + y*sh/h
+		y1 := max(b.Min.Y+(y+1)*sh/h, y0+1)
+		for x := range w {
+			x0 := b.Min.X + x*sw/w
+			x1 := max(b.Min.X+(x+1)*sw/w, x0+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			white := 0xffff - a/n
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8((r/n + white) >> 8),
+				G: uint8((g/n + white) >> 8),
+				B: uint8((bl/n + white) >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
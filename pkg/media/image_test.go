@@ -0,0 +1,65 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 0x80, A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestFitImage_SmallImageUnchanged(t *testing.T) {
+	data := encodePNG(t, 40, 30)
+	out, mime, err := FitImage(data, "image/png", 100, 1<<20)
+	if err != nil {
+		t.Fatalf("FitImage() error = %v", err)
+	}
+	if !bytes.Equal(out, data) || mime != "image/png" {
+		t.Errorf("FitImage() changed an image within the limits (mime %q)", mime)
+	}
+}
+
+func TestFitImage_ScalesLargeImageDown(t *testing.T) {
+	data := encodePNG(t, 400, 100)
+	out, mime, err := FitImage(data, "image/png", 100, 1<<20)
+	if err != nil {
+		t.Fatalf("FitImage() error = %v", err)
+	}
+	if mime != "image/jpeg" {
+		t.Errorf("mime = %q, want image/jpeg", mime)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("result is not a JPEG: %v", err)
+	}
+	if cfg.Width != 100 || cfg.Height != 25 {
+		t.Errorf("size = %dx%d, want 100x25", cfg.Width, cfg.Height)
+	}
+}
+
+func TestFitImage_UndecodableImage(t *testing.T) {
+	data := []byte("RIFF....WEBPVP8 not decodable here")
+	if out, mime, err := FitImage(data, "image/webp", 100, 1<<20); err != nil || mime != "image/webp" ||
+		!bytes.Equal(out, data) {
+		t.Errorf("FitImage() = %q, %q, %v; want the image unchanged", out, mime, err)
+	}
+	if _, _, err := FitImage(data, "image/webp", 100, 10); err == nil {
+		t.Error("FitImage() error = nil for an undecodable image over the byte limit")
+	}
+}
@@ -455,6 +455,31 @@ func (s *JSONLStore) rewriteJSONL(
 	return fileutil.WriteFileAtomic(s.jsonlPath(sessionKey), buf.Bytes(), 0o644)
 }
 
+// SessionKeys returns the keys of the sessions in the store. Implements
+// Lister.
+func (s *JSONLStore) SessionKeys(_ context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("memory: read sessions dir: %w", err)
+	}
+	var keys []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".meta.json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			continue
+		}
+		var meta sessionMeta
+		if json.Unmarshal(data, &meta) == nil && meta.Key != "" {
+			keys = append(keys, meta.Key)
+		}
+	}
+	return keys, nil
+}
+
 func (s *JSONLStore) Close() error {
 	return nil
 }
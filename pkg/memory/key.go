@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// keyCommandTimeout bounds how long LoadKey waits for a key command.
+const keyCommandTimeout = 30 * time.Second
+
+// LoadKey returns the session encryption key: the output of command when it
+// is set, such as a KMS or secret manager CLI decrypting the key, or else
+// the value of the environment variable env. Either must be the base64 of
+// 32 random bytes, e.g. from `openssl rand -base64 32`.
+func LoadKey(env string, command []string) ([]byte, error) {
+	var encoded string
+	if len(command) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), keyCommandTimeout)
+		defer cancel()
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("memory: key command %s: %w: %s", command[0], err, strings.TrimSpace(stderr.String()))
+		}
+		encoded = string(out)
+	} else {
+		if env == "" {
+			return nil, fmt.Errorf("memory: no encryption key source configured")
+		}
+		encoded = os.Getenv(env)
+		if encoded == "" {
+			return nil, fmt.Errorf("memory: encryption key variable %s is not set", env)
+		}
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("memory: encryption key is not base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("memory: encryption key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
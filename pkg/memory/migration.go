@@ -112,3 +112,88 @@ func MigrateFromJSON(
 
 	return migrated, nil
 }
+
+// MigrateFromJSONL copies the sessions of the JSONL store in sessionsDir
+// into store, for switching to another backend, and renames each copied
+// .jsonl and .meta.json file to .migrated as a backup. Returns the number
+// of sessions migrated.
+func MigrateFromJSONL(
+	ctx context.Context, sessionsDir string, store Store,
+) (int, error) {
+	entries, err := os.ReadDir(sessionsDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("memory: read sessions dir: %w", err)
+	}
+	src := &JSONLStore{dir: sessionsDir}
+
+	migrated := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".meta.json") {
+			continue
+		}
+		metaPath := filepath.Join(sessionsDir, name)
+		data, readErr := os.ReadFile(metaPath)
+		if readErr != nil {
+			log.Printf("memory: migrate: skip %s: %v", name, readErr)
+			continue
+		}
+		var meta sessionMeta
+		if parseErr := json.Unmarshal(data, &meta); parseErr != nil || meta.Key == "" {
+			log.Printf("memory: migrate: skip %s: no session key", name)
+			continue
+		}
+
+		history, histErr := src.GetHistory(ctx, meta.Key)
+		if histErr != nil {
+			log.Printf("memory: migrate: skip %s: %v", name, histErr)
+			continue
+		}
+		if setErr := importHistory(ctx, store, meta.Key, history); setErr != nil {
+			return migrated, fmt.Errorf("memory: migrate %s: set history: %w", name, setErr)
+		}
+		if meta.Summary != "" {
+			if sumErr := importSummary(ctx, store, meta.Key, meta.Summary); sumErr != nil {
+				return migrated, fmt.Errorf("memory: migrate %s: set summary: %w", name, sumErr)
+			}
+		}
+
+		for _, path := range []string{src.jsonlPath(meta.Key), metaPath} {
+			if renameErr := os.Rename(path, path+".migrated"); renameErr != nil && !os.IsNotExist(renameErr) {
+				log.Printf("memory: migrate: rename %s: %v", filepath.Base(path), renameErr)
+			}
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// importer is implemented by stores that keep messages in another form than
+// they return them in, such as SealedStore, to take messages that another
+// store of theirs stored.
+type importer interface {
+	importHistory(ctx context.Context, sessionKey string, stored []providers.Message) error
+	importSummary(ctx context.Context, sessionKey, stored string) error
+}
+
+// importHistory sets the history of a session to messages as the JSONL store
+// holds them.
+func importHistory(ctx context.Context, store Store, sessionKey string, stored []providers.Message) error {
+	if imp, ok := store.(importer); ok {
+		return imp.importHistory(ctx, sessionKey, stored)
+	}
+	return store.SetHistory(ctx, sessionKey, stored)
+}
+
+// importSummary sets the summary of a session to one as the JSONL store
+// holds it.
+func importSummary(ctx context.Context, store Store, sessionKey, stored string) error {
+	if imp, ok := store.(importer); ok {
+		return imp.importSummary(ctx, sessionKey, stored)
+	}
+	return store.SetSummary(ctx, sessionKey, stored)
+}
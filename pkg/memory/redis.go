@@ -0,0 +1,276 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// ErrConflict means another writer changed the session since this store
+// last read it, so a write that replaces its history was not applied.
+var ErrConflict = errors.New("memory: session was changed by another writer")
+
+// redisKeyPrefix namespaces the keys of every session.
+const redisKeyPrefix = "picoclaw:session:"
+
+// RedisStore implements Store in Redis so that several picoclaw instances
+// can share sessions. A session is a list of JSON messages, a summary
+// string, and a version counter that every history write increments; all
+// three expire after ttl without a write.
+//
+// Appends are atomic and never conflict. SetHistory and TruncateHistory
+// rewrite history based on what the caller read, so they are optimistic:
+// they fail with ErrConflict when another instance wrote to the session
+// since this store's last GetHistory of it, instead of dropping that write.
+// Clearing a session does not depend on what was read and always applies.
+type RedisStore struct {
+	client *redisClient
+	ttl    time.Duration
+
+	mu   sync.Mutex
+	seen map[string]int64 // session key -> version of the last read
+}
+
+// NewRedisStore connects to the Redis server at rawURL, e.g.
+// redis://:password@localhost:6379/0 or rediss:// for TLS. With ttl > 0,
+// sessions expire after ttl without a write.
+func NewRedisStore(ctx context.Context, rawURL string, ttl time.Duration) (*RedisStore, error) {
+	client, err := newRedisClient(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.do(ctx, "PING"); err != nil {
+		return nil, err
+	}
+	return &RedisStore{client: client, ttl: ttl, seen: make(map[string]int64)}, nil
+}
+
+func (s *RedisStore) messagesKey(sessionKey string) string {
+	return redisKeyPrefix + sessionKey + ":messages"
+}
+
+func (s *RedisStore) summaryKey(sessionKey string) string {
+	return redisKeyPrefix + sessionKey + ":summary"
+}
+
+func (s *RedisStore) versionKey(sessionKey string) string {
+	return redisKeyPrefix + sessionKey + ":version"
+}
+
+func (s *RedisStore) AddMessage(ctx context.Context, sessionKey, role, content string) error {
+	return s.AddFullMessage(ctx, sessionKey, providers.Message{Role: role, Content: content})
+}
+
+func (s *RedisStore) AddFullMessage(ctx context.Context, sessionKey string, msg providers.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("memory: marshal message: %w", err)
+	}
+	return s.client.with(ctx, func(conn *redisConn) error {
+		replies, err := s.exec(conn, sessionKey,
+			[]string{"RPUSH", s.messagesKey(sessionKey), string(data)},
+			[]string{"INCR", s.versionKey(sessionKey)})
+		if err != nil {
+			return fmt.Errorf("memory: append message: %w", err)
+		}
+		// Our own append keeps what we read current.
+		if v, ok := replies[1].(int64); ok {
+			s.mu.Lock()
+			if seen, ok := s.seen[sessionKey]; ok && seen == v-1 {
+				s.seen[sessionKey] = v
+			}
+			s.mu.Unlock()
+		}
+		return nil
+	})
+}
+
+func (s *RedisStore) GetHistory(ctx context.Context, sessionKey string) ([]providers.Message, error) {
+	var msgs []providers.Message
+	err := s.client.with(ctx, func(conn *redisConn) error {
+		replies, err := s.exec(conn, "",
+			[]string{"LRANGE", s.messagesKey(sessionKey), "0", "-1"},
+			[]string{"GET", s.versionKey(sessionKey)})
+		if err != nil {
+			return fmt.Errorf("memory: read history: %w", err)
+		}
+		items, _ := replies[0].([]any)
+		msgs = make([]providers.Message, 0, len(items))
+		for _, item := range items {
+			data, _ := item.(string)
+			var msg providers.Message
+			if err := json.Unmarshal([]byte(data), &msg); err != nil {
+				return fmt.Errorf("memory: decode message: %w", err)
+			}
+			msgs = append(msgs, msg)
+		}
+		version, err := parseVersion(replies[1])
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.seen[sessionKey] = version
+		s.mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (s *RedisStore) GetSummary(ctx context.Context, sessionKey string) (string, error) {
+	reply, err := s.client.do(ctx, "GET", s.summaryKey(sessionKey))
+	if err != nil {
+		return "", fmt.Errorf("memory: read summary: %w", err)
+	}
+	summary, _ := reply.(string)
+	return summary, nil
+}
+
+func (s *RedisStore) SetSummary(ctx context.Context, sessionKey, summary string) error {
+	return s.client.with(ctx, func(conn *redisConn) error {
+		if _, err := s.exec(conn, sessionKey, []string{"SET", s.summaryKey(sessionKey), summary}); err != nil {
+			return fmt.Errorf("memory: write summary: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *RedisStore) TruncateHistory(ctx context.Context, sessionKey string, keepLast int) error {
+	cmd := []string{"DEL", s.messagesKey(sessionKey)}
+	if keepLast > 0 {
+		cmd = []string{"LTRIM", s.messagesKey(sessionKey), strconv.Itoa(-keepLast), "-1"}
+	}
+	if err := s.rewrite(ctx, sessionKey, keepLast <= 0, cmd); err != nil {
+		return fmt.Errorf("memory: truncate history: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) SetHistory(ctx context.Context, sessionKey string, history []providers.Message) error {
+	cmds := [][]string{{"DEL", s.messagesKey(sessionKey)}}
+	if len(history) > 0 {
+		push := []string{"RPUSH", s.messagesKey(sessionKey)}
+		for _, msg := range history {
+			data, err := json.Marshal(msg)
+			if err != nil {
+				return fmt.Errorf("memory: marshal message: %w", err)
+			}
+			push = append(push, string(data))
+		}
+		cmds = append(cmds, push)
+	}
+	if err := s.rewrite(ctx, sessionKey, len(history) == 0, cmds...); err != nil {
+		return fmt.Errorf("memory: replace history: %w", err)
+	}
+	return nil
+}
+
+// rewrite runs cmds on the history of sessionKey if no other writer
+// changed it since this store last read it, or regardless with force.
+func (s *RedisStore) rewrite(ctx context.Context, sessionKey string, force bool, cmds ...[]string) error {
+	return s.client.with(ctx, func(conn *redisConn) error {
+		s.mu.Lock()
+		seen, read := s.seen[sessionKey]
+		s.mu.Unlock()
+		if read && !force {
+			if _, err := conn.do("WATCH", s.versionKey(sessionKey)); err != nil {
+				return err
+			}
+			reply, err := conn.do("GET", s.versionKey(sessionKey))
+			if err != nil {
+				return err
+			}
+			current, err := parseVersion(reply)
+			if err != nil {
+				return err
+			}
+			if seen != current {
+				if _, err := conn.do("UNWATCH"); err != nil {
+					return err
+				}
+				return ErrConflict
+			}
+		}
+
+		replies, err := s.exec(conn, sessionKey, append(cmds, []string{"INCR", s.versionKey(sessionKey)})...)
+		if err != nil {
+			return err
+		}
+		if replies == nil {
+			return ErrConflict
+		}
+		if v, ok := replies[len(cmds)].(int64); ok {
+			s.mu.Lock()
+			s.seen[sessionKey] = v
+			s.mu.Unlock()
+		}
+		return nil
+	})
+}
+
+// exec runs cmds in a MULTI/EXEC transaction and returns their replies, or
+// nil replies when a WATCHed key changed. With sessionKey set, the keys of
+// that session have their expiry renewed in the same transaction.
+func (s *RedisStore) exec(conn *redisConn, sessionKey string, cmds ...[]string) ([]any, error) {
+	if sessionKey != "" && s.ttl > 0 {
+		secs := strconv.Itoa(max(int(s.ttl/time.Second), 1))
+		for _, key := range []string{s.messagesKey(sessionKey), s.summaryKey(sessionKey), s.versionKey(sessionKey)} {
+			cmds = append(cmds, []string{"EXPIRE", key, secs})
+		}
+	}
+	if _, err := conn.do("MULTI"); err != nil {
+		return nil, err
+	}
+	for _, cmd := range cmds {
+		if _, err := conn.do(cmd...); err != nil {
+			conn.do("DISCARD")
+			return nil, err
+		}
+	}
+	reply, err := conn.do("EXEC")
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, nil
+	}
+	replies, ok := reply.([]any)
+	if !ok || len(replies) != len(cmds) {
+		return nil, fmt.Errorf("redis: unexpected EXEC reply %v", reply)
+	}
+	for _, r := range replies {
+		if err, ok := r.(redisError); ok {
+			return nil, err
+		}
+	}
+	return replies, nil
+}
+
+func parseVersion(reply any) (int64, error) {
+	s, ok := reply.(string)
+	if !ok {
+		return 0, nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("redis: invalid session version %q", s)
+	}
+	return v, nil
+}
+
+// Compact is a no-op: Redis frees truncated messages right away.
+func (s *RedisStore) Compact(context.Context, string) error {
+	return nil
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,344 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// fakeRedis serves the subset of Redis commands RedisStore uses.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu      sync.Mutex
+	strs    map[string]string
+	lists   map[string][]string
+	ttls    map[string]int
+	changes map[string]int // per key, for WATCH
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeRedis{
+		ln: ln, password: password,
+		strs: map[string]string{}, lists: map[string][]string{}, ttls: map[string]int{}, changes: map[string]int{},
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) url() string {
+	if f.password != "" {
+		return "redis://:" + f.password + "@" + f.ln.Addr().String() + "/2"
+	}
+	return "redis://" + f.ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	watched := map[string]int{}
+	var queued [][]string
+	multi := false
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+		var reply string
+		switch {
+		case name == "AUTH":
+			if args[len(args)-1] == f.password {
+				authed = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case name == "MULTI":
+			multi, queued = true, nil
+			reply = "+OK\r\n"
+		case name == "DISCARD":
+			multi, queued, watched = false, nil, map[string]int{}
+			reply = "+OK\r\n"
+		case name == "EXEC":
+			f.mu.Lock()
+			aborted := false
+			for key, n := range watched {
+				if f.changes[key] != n {
+					aborted = true
+				}
+			}
+			if aborted {
+				reply = "*-1\r\n"
+			} else {
+				reply = fmt.Sprintf("*%d\r\n", len(queued))
+				for _, cmd := range queued {
+					reply += f.run(cmd)
+				}
+			}
+			f.mu.Unlock()
+			multi, queued, watched = false, nil, map[string]int{}
+		case name == "WATCH":
+			f.mu.Lock()
+			for _, key := range args[1:] {
+				watched[key] = f.changes[key]
+			}
+			f.mu.Unlock()
+			reply = "+OK\r\n"
+		case name == "UNWATCH":
+			watched = map[string]int{}
+			reply = "+OK\r\n"
+		case multi:
+			queued = append(queued, args)
+			reply = "+QUEUED\r\n"
+		default:
+			f.mu.Lock()
+			reply = f.run(args)
+			f.mu.Unlock()
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// run executes one command with f.mu held and returns its encoded reply.
+func (f *fakeRedis) run(args []string) string {
+	bulk := func(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+	}
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		if v, ok := f.strs[key]; ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "SET":
+		f.strs[key] = args[2]
+		f.changes[key]++
+		return "+OK\r\n"
+	case "INCR":
+		n, _ := strconv.Atoi(f.strs[key])
+		f.strs[key] = strconv.Itoa(n + 1)
+		f.changes[key]++
+		return fmt.Sprintf(":%d\r\n", n+1)
+	case "DEL":
+		_, s := f.strs[key]
+		_, l := f.lists[key]
+		delete(f.strs, key)
+		delete(f.lists, key)
+		f.changes[key]++
+		if s || l {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "RPUSH":
+		f.lists[key] = append(f.lists[key], args[2:]...)
+		f.changes[key]++
+		return fmt.Sprintf(":%d\r\n", len(f.lists[key]))
+	case "LRANGE":
+		out := fmt.Sprintf("*%d\r\n", len(f.lists[key]))
+		for _, v := range f.lists[key] {
+			out += bulk(v)
+		}
+		return out
+	case "LTRIM": // only the LTRIM key -n -1 form
+		n, _ := strconv.Atoi(strings.TrimPrefix(args[2], "-"))
+		if l := f.lists[key]; len(l) > n {
+			f.lists[key] = l[len(l)-n:]
+		}
+		f.changes[key]++
+		return "+OK\r\n"
+	case "EXPIRE":
+		f.ttls[key], _ = strconv.Atoi(args[2])
+		return ":1\r\n"
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("bad command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func newTestRedisStore(t *testing.T, f *fakeRedis, ttl time.Duration) *RedisStore {
+	t.Helper()
+	store, err := NewRedisStore(context.Background(), f.url(), ttl)
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestRedisStore_Roundtrip(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	store := newTestRedisStore(t, f, 24*time.Hour)
+	ctx := context.Background()
+
+	if h, err := store.GetHistory(ctx, "empty"); err != nil || h == nil || len(h) != 0 {
+		t.Errorf("empty history = %#v, %v; want an empty slice", h, err)
+	}
+	store.AddMessage(ctx, "telegram:1", "user", "hello")
+	store.AddFullMessage(ctx, "telegram:1", providers.Message{
+		Role:      "assistant",
+		ToolCalls: []providers.ToolCall{{ID: "call_1", Name: "read_file"}},
+	})
+	if err := store.SetSummary(ctx, "telegram:1", "greetings"); err != nil {
+		t.Fatalf("SetSummary: %v", err)
+	}
+
+	history, err := store.GetHistory(ctx, "telegram:1")
+	if err != nil || len(history) != 2 || history[1].ToolCalls[0].ID != "call_1" {
+		t.Fatalf("history = %+v, %v", history, err)
+	}
+	if s, _ := store.GetSummary(ctx, "telegram:1"); s != "greetings" {
+		t.Errorf("summary = %q", s)
+	}
+
+	store.AddMessage(ctx, "telegram:1", "user", "third")
+	if err := store.TruncateHistory(ctx, "telegram:1", 2); err != nil {
+		t.Fatalf("TruncateHistory: %v", err)
+	}
+	store.GetHistory(ctx, "telegram:1")
+	if err := store.SetHistory(ctx, "telegram:1", []providers.Message{{Role: "user", Content: "x"}}); err != nil {
+		t.Fatalf("SetHistory: %v", err)
+	}
+	history, _ = store.GetHistory(ctx, "telegram:1")
+	if len(history) != 1 || history[0].Content != "x" {
+		t.Errorf("history after SetHistory = %+v", history)
+	}
+
+	f.mu.Lock()
+	ttl := f.ttls[redisKeyPrefix+"telegram:1:messages"]
+	f.mu.Unlock()
+	if ttl != 86400 {
+		t.Errorf("messages ttl = %d, want 86400", ttl)
+	}
+}
+
+func TestRedisStore_SharedBetweenInstances(t *testing.T) {
+	f := newFakeRedis(t, "")
+	a := newTestRedisStore(t, f, 0)
+	b := newTestRedisStore(t, f, 0)
+	ctx := context.Background()
+
+	a.AddMessage(ctx, "s", "user", "one")
+	a.AddMessage(ctx, "s", "assistant", "two")
+	if h, _ := b.GetHistory(ctx, "s"); len(h) != 2 {
+		t.Fatalf("instance b sees %d messages, want 2", len(h))
+	}
+
+	// a read the history, then b appended: a's rewrite must not drop b's message.
+	a.GetHistory(ctx, "s")
+	b.AddMessage(ctx, "s", "user", "three")
+	if err := a.TruncateHistory(ctx, "s", 1); !errors.Is(err, ErrConflict) {
+		t.Fatalf("stale TruncateHistory = %v, want ErrConflict", err)
+	}
+	stale := []providers.Message{{Role: "user", Content: "one"}}
+	if err := a.SetHistory(ctx, "s", stale); !errors.Is(err, ErrConflict) {
+		t.Fatalf("stale SetHistory = %v, want ErrConflict", err)
+	}
+	if h, _ := a.GetHistory(ctx, "s"); len(h) != 3 {
+		t.Fatalf("history = %+v, want all three messages", h)
+	}
+
+	// After reading again, and after its own appends, a may rewrite.
+	a.AddMessage(ctx, "s", "assistant", "four")
+	if err := a.TruncateHistory(ctx, "s", 2); err != nil {
+		t.Fatalf("TruncateHistory after read: %v", err)
+	}
+	if h, _ := b.GetHistory(ctx, "s"); len(h) != 2 || h[1].Content != "four" {
+		t.Errorf("history = %+v, want three and four", h)
+	}
+
+	// Clearing applies even when stale.
+	a.AddMessage(ctx, "s", "user", "five")
+	if err := b.SetHistory(ctx, "s", nil); err != nil {
+		t.Fatalf("clearing a stale session: %v", err)
+	}
+	if h, _ := a.GetHistory(ctx, "s"); len(h) != 0 {
+		t.Errorf("history after clear = %+v", h)
+	}
+}
+
+func TestRedisStore_Reconnects(t *testing.T) {
+	f := newFakeRedis(t, "")
+	store := newTestRedisStore(t, f, 0)
+	ctx := context.Background()
+
+	store.client.mu.Lock()
+	store.client.conn.Close()
+	store.client.mu.Unlock()
+
+	// The first command fails on the closed connection; the next redials.
+	store.AddMessage(ctx, "s", "user", "hello")
+	if err := store.AddMessage(ctx, "s", "user", "again"); err != nil {
+		t.Fatalf("AddMessage after reconnect: %v", err)
+	}
+	if h, _ := store.GetHistory(ctx, "s"); len(h) == 0 || h[len(h)-1].Content != "again" {
+		t.Errorf("history = %+v", h)
+	}
+}
+
+func TestNewRedisStore_BadURL(t *testing.T) {
+	for _, u := range []string{"http://localhost", "redis://localhost/notadb"} {
+		if _, err := NewRedisStore(context.Background(), u, 0); err == nil {
+			t.Errorf("NewRedisStore(%q) succeeded", u)
+		}
+	}
+}
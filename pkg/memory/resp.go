@@ -0,0 +1,226 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisTimeout bounds a command whose context has no deadline.
+const redisTimeout = 5 * time.Second
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// redisClient is a minimal RESP2 client owning one connection. Commands are
+// serialized on it, which also keeps a WATCH/MULTI/EXEC sequence on the
+// connection it was started on. A broken connection is redialed on the next
+// command.
+type redisClient struct {
+	addr     string
+	username string
+	password string
+	db       int
+	tls      *tls.Config
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// newRedisClient parses a redis:// or rediss:// URL of the form
+// redis://[[user]:password@]host[:port][/db]. It does not connect yet.
+func newRedisClient(rawURL string) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("redis: parse url: %w", err)
+	}
+	c := &redisClient{addr: u.Host}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		c.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("redis: unsupported url scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis: invalid database %q", db)
+		}
+	}
+	return c, nil
+}
+
+// with runs fn with the connection held, dialing it if needed. A
+// connection fn fails on with anything but an error reply is closed.
+func (c *redisClient) with(ctx context.Context, fn func(conn *redisConn) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.dial(ctx); err != nil {
+			return err
+		}
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	c.conn.SetDeadline(deadline)
+
+	err := fn(&redisConn{w: c.conn, r: c.r})
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		c.conn.Close()
+		c.conn, c.r = nil, nil
+	}
+	return err
+}
+
+// do runs one command.
+func (c *redisClient) do(ctx context.Context, args ...string) (any, error) {
+	var reply any
+	err := c.with(ctx, func(conn *redisConn) error {
+		var err error
+		reply, err = conn.do(args...)
+		return err
+	})
+	return reply, err
+}
+
+func (c *redisClient) dial(ctx context.Context) error {
+	d := net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if c.tls != nil {
+		conn, err = (&tls.Dialer{NetDialer: &d, Config: c.tls}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("redis: dial %s: %w", c.addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(redisTimeout))
+	rc := &redisConn{w: conn, r: bufio.NewReader(conn)}
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.username != "" {
+			args = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := rc.do(args...); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	if c.db != 0 {
+		if _, err := rc.do("SELECT", strconv.Itoa(c.db)); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	c.conn, c.r = conn, rc.r
+	return nil
+}
+
+func (c *redisClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn, c.r = nil, nil
+	return err
+}
+
+// redisConn sends commands and reads replies on a held connection.
+type redisConn struct {
+	w io.Writer
+	r *bufio.Reader
+}
+
+func (c *redisConn) do(args ...string) (any, error) {
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+func (c *redisConn) send(args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := io.WriteString(c.w, b.String())
+	return err
+}
+
+// read reads one reply: a string for simple and bulk strings, int64 for
+// integers, []any for arrays, and nil for null bulk strings and arrays.
+// An error reply is returned as a redisError.
+func (c *redisConn) read() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			// An error inside an array, as in an EXEC reply, belongs to
+			// that command only.
+			item, err := c.read()
+			var replyErr redisError
+			if errors.As(err, &replyErr) {
+				item = replyErr
+			} else if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,491 @@
+package memory
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	// stampedPrefix marks a stored message that is a plain JSON envelope.
+	stampedPrefix = "picoclaw-stamped:v1:"
+	// sealedPrefix marks a stored message or summary encrypted with AES-GCM:
+	// base64 of the nonce followed by the ciphertext.
+	sealedPrefix = "picoclaw-sealed:v1:"
+)
+
+// ErrUndecryptable means a stored message or summary could not be decrypted
+// with the configured key.
+var ErrUndecryptable = errors.New("memory: cannot decrypt session data; wrong or missing key")
+
+// Sealer encrypts data at rest with AES-256-GCM, each value bound to the
+// context it belongs to, such as its session key, so it cannot be moved to
+// another one.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// NewSealer returns a Sealer for a 32-byte AES-256 key.
+func NewSealer(key []byte) (*Sealer, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("memory: encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("memory: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("memory: %w", err)
+	}
+	return &Sealer{aead: aead}, nil
+}
+
+// Seal encrypts plaintext, bound to context.
+func (s *Sealer) Seal(context string, plaintext []byte) string {
+	nonce := make([]byte, s.aead.NonceSize())
+	rand.Read(nonce)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, plaintext, []byte(context)))
+}
+
+// Open decrypts a value sealed for context. A nil Sealer opens nothing.
+func (s *Sealer) Open(context, sealed string) ([]byte, error) {
+	if s == nil || !IsSealed(sealed) {
+		return nil, ErrUndecryptable
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+	if err != nil || len(data) < s.aead.NonceSize() {
+		return nil, ErrUndecryptable
+	}
+	n := s.aead.NonceSize()
+	plaintext, err := s.aead.Open(nil, data[:n], data[n:], []byte(context))
+	if err != nil {
+		return nil, ErrUndecryptable
+	}
+	return plaintext, nil
+}
+
+// IsSealed reports whether stored has the form of a sealed value. Only Open
+// tells whether it really is one.
+func IsSealed(stored string) bool {
+	return strings.HasPrefix(stored, sealedPrefix)
+}
+
+// Lister is implemented by stores that can list their sessions, which lets a
+// SealedStore sweep sessions nobody reads anymore.
+type Lister interface {
+	SessionKeys(ctx context.Context) ([]string, error)
+}
+
+// Retention returns how long the messages of a session are kept; 0 keeps
+// them forever.
+type Retention func(sessionKey string) time.Duration
+
+// envelope is a message as a SealedStore stores it.
+type envelope struct {
+	Time    time.Time         `json:"t"`
+	Message providers.Message `json:"m"`
+}
+
+// SealedStore wraps a Store to encrypt what it stores and to drop messages
+// older than their retention.
+//
+// Each message is stored as an envelope holding it and the time it was
+// added; only its role stays readable. With a key the envelope is encrypted
+// with AES-256-GCM, bound to the session key, and so are summaries.
+// Messages stored before the wrapping are read as they are and sealed by the
+// next sweep.
+//
+// Expired messages are never returned, and are deleted when their session
+// is read and by a sweep of every session, which runs in the background on
+// the first write of each day when the wrapped store is a Lister.
+type SealedStore struct {
+	inner  Store
+	sealer *Sealer // nil stores envelopes unencrypted
+	retain Retention
+	now    func() time.Time
+	locks  [numLockShards]sync.Mutex
+
+	mu    sync.Mutex
+	swept string // day of the last sweep
+}
+
+// NewSealedStore wraps inner. key is a 32-byte AES-256 key, or nil to store
+// messages unencrypted; retain may be nil to keep messages forever.
+func NewSealedStore(inner Store, key []byte, retain Retention) (*SealedStore, error) {
+	s := &SealedStore{inner: inner, retain: retain, now: time.Now}
+	if key != nil {
+		sealer, err := NewSealer(key)
+		if err != nil {
+			return nil, err
+		}
+		s.sealer = sealer
+	}
+	return s, nil
+}
+
+func (s *SealedStore) sessionLock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.locks[h.Sum32()%numLockShards]
+}
+
+// isFinal reports whether a message read from the wrapped store is already
+// in the form s stores messages in: sealed, or an envelope when there is no
+// key. A message sealed with another key cannot be sealed again and is kept
+// too.
+func (s *SealedStore) isFinal(stored providers.Message) bool {
+	return IsSealed(stored.Content) || s.sealer == nil && strings.HasPrefix(stored.Content, stampedPrefix)
+}
+
+// wrap returns the stored form of msg, added at t. Whatever its content
+// looks like, msg is always wrapped: only messages read from the wrapped
+// store are ever kept as they are.
+func (s *SealedStore) wrap(sessionKey string, msg providers.Message, t time.Time) (providers.Message, error) {
+	data, err := json.Marshal(envelope{Time: t.UTC(), Message: msg})
+	if err != nil {
+		return providers.Message{}, fmt.Errorf("memory: marshal message: %w", err)
+	}
+	if s.sealer == nil {
+		return providers.Message{Role: msg.Role, Content: stampedPrefix + string(data)}, nil
+	}
+	return providers.Message{Role: msg.Role, Content: s.sealer.Seal(sessionKey, data)}, nil
+}
+
+// unwrap returns the envelope of a stored message. Messages stored before
+// the wrapping have a zero time.
+func (s *SealedStore) unwrap(sessionKey string, stored providers.Message) (envelope, error) {
+	var data []byte
+	switch {
+	case IsSealed(stored.Content):
+		plaintext, err := s.sealer.Open(sessionKey, stored.Content)
+		if err != nil {
+			return envelope{}, err
+		}
+		data = plaintext
+	case strings.HasPrefix(stored.Content, stampedPrefix):
+		data = []byte(strings.TrimPrefix(stored.Content, stampedPrefix))
+	default:
+		return envelope{Message: stored}, nil
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return envelope{}, fmt.Errorf("memory: decode message: %w", err)
+	}
+	return env, nil
+}
+
+// cutoff returns the time before which the messages of a session expire, or
+// the zero time when they do not.
+func (s *SealedStore) cutoff(sessionKey string) time.Time {
+	if s.retain == nil {
+		return time.Time{}
+	}
+	maxAge := s.retain(sessionKey)
+	if maxAge <= 0 {
+		return time.Time{}
+	}
+	return s.now().Add(-maxAge)
+}
+
+func (s *SealedStore) AddMessage(ctx context.Context, sessionKey, role, content string) error {
+	return s.AddFullMessage(ctx, sessionKey, providers.Message{Role: role, Content: content})
+}
+
+func (s *SealedStore) AddFullMessage(ctx context.Context, sessionKey string, msg providers.Message) error {
+	s.maybeSweep()
+	stored, err := s.wrap(sessionKey, msg, s.now())
+	if err != nil {
+		return err
+	}
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+	return s.inner.AddFullMessage(ctx, sessionKey, stored)
+}
+
+func (s *SealedStore) GetHistory(ctx context.Context, sessionKey string) ([]providers.Message, error) {
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+
+	stored, err := s.inner.GetHistory(ctx, sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	envs, err := s.unwrapAll(sessionKey, stored)
+	if err != nil {
+		return nil, err
+	}
+	kept, expired := s.unexpired(sessionKey, stored, envs)
+	msgs := make([]providers.Message, 0, len(kept))
+	for i := range stored {
+		if !expired[i] {
+			msgs = append(msgs, envs[i].Message)
+		}
+	}
+	if len(kept) < len(stored) {
+		if err := s.replace(ctx, sessionKey, kept); err != nil {
+			log.Printf("memory: purge expired messages of %s: %v", sessionKey, err)
+		}
+	}
+	return msgs, nil
+}
+
+func (s *SealedStore) unwrapAll(sessionKey string, stored []providers.Message) ([]envelope, error) {
+	envs := make([]envelope, len(stored))
+	for i, msg := range stored {
+		env, err := s.unwrap(sessionKey, msg)
+		if err != nil {
+			return nil, err
+		}
+		envs[i] = env
+	}
+	return envs, nil
+}
+
+// unexpired returns the stored messages of a session that have not expired,
+// and which of them expired by index.
+func (s *SealedStore) unexpired(
+	sessionKey string, stored []providers.Message, envs []envelope,
+) (kept []providers.Message, expired map[int]bool) {
+	cutoff := s.cutoff(sessionKey)
+	expired = make(map[int]bool)
+	for i, env := range envs {
+		if !cutoff.IsZero() && !env.Time.IsZero() && env.Time.Before(cutoff) {
+			expired[i] = true
+			continue
+		}
+		kept = append(kept, stored[i])
+	}
+	return kept, expired
+}
+
+// replace sets the stored history of a session, clearing its summary as well
+// when no message is left.
+func (s *SealedStore) replace(ctx context.Context, sessionKey string, stored []providers.Message) error {
+	if err := s.inner.SetHistory(ctx, sessionKey, stored); err != nil {
+		return err
+	}
+	if len(stored) == 0 {
+		return s.inner.SetSummary(ctx, sessionKey, "")
+	}
+	return nil
+}
+
+func (s *SealedStore) GetSummary(ctx context.Context, sessionKey string) (string, error) {
+	summary, err := s.inner.GetSummary(ctx, sessionKey)
+	if err != nil || !IsSealed(summary) {
+		return summary, err
+	}
+	plaintext, err := s.sealer.Open("summary:"+sessionKey, summary)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (s *SealedStore) SetSummary(ctx context.Context, sessionKey, summary string) error {
+	if s.sealer != nil && summary != "" {
+		summary = s.sealer.Seal("summary:"+sessionKey, []byte(summary))
+	}
+	return s.inner.SetSummary(ctx, sessionKey, summary)
+}
+
+// importHistory sets the history of a session to messages read from another
+// store of the same kind, as a migration does. Those already in their final
+// form are kept as they are, with the time they were added, and the rest are
+// wrapped.
+func (s *SealedStore) importHistory(ctx context.Context, sessionKey string, stored []providers.Message) error {
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+
+	stored, err := s.rewrap(sessionKey, stored)
+	if err != nil {
+		return err
+	}
+	return s.inner.SetHistory(ctx, sessionKey, stored)
+}
+
+// importSummary sets the summary of a session to one read from another
+// store of the same kind.
+func (s *SealedStore) importSummary(ctx context.Context, sessionKey, stored string) error {
+	if IsSealed(stored) {
+		return s.inner.SetSummary(ctx, sessionKey, stored)
+	}
+	return s.SetSummary(ctx, sessionKey, stored)
+}
+
+// rewrap returns stored messages in their final form, wrapping the ones
+// that are not. Envelopes keep the time they were added; plain messages,
+// stored before the wrapping, count from now.
+func (s *SealedStore) rewrap(sessionKey string, stored []providers.Message) ([]providers.Message, error) {
+	out := make([]providers.Message, len(stored))
+	now := s.now()
+	for i, msg := range stored {
+		if s.isFinal(msg) {
+			out[i] = msg
+			continue
+		}
+		env, err := s.unwrap(sessionKey, msg)
+		if err != nil {
+			return nil, err
+		}
+		t := env.Time
+		if t.IsZero() {
+			t = now
+		}
+		if out[i], err = s.wrap(sessionKey, env.Message, t); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (s *SealedStore) TruncateHistory(ctx context.Context, sessionKey string, keepLast int) error {
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+	return s.inner.TruncateHistory(ctx, sessionKey, keepLast)
+}
+
+// SetHistory replaces the history of a session. Messages that were already
+// stored keep the time they were first added, so rewriting a history does
+// not extend its retention.
+func (s *SealedStore) SetHistory(ctx context.Context, sessionKey string, history []providers.Message) error {
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+
+	added := make(map[string][]time.Time)
+	if old, err := s.inner.GetHistory(ctx, sessionKey); err == nil {
+		for _, msg := range old {
+			env, err := s.unwrap(sessionKey, msg)
+			if err != nil || env.Time.IsZero() {
+				continue
+			}
+			data, _ := json.Marshal(env.Message)
+			added[string(data)] = append(added[string(data)], env.Time)
+		}
+	}
+	now := s.now()
+	stored := make([]providers.Message, len(history))
+	for i, msg := range history {
+		t := now
+		data, _ := json.Marshal(msg)
+		if times := added[string(data)]; len(times) > 0 {
+			t, added[string(data)] = times[0], times[1:]
+		}
+		wrapped, err := s.wrap(sessionKey, msg, t)
+		if err != nil {
+			return err
+		}
+		stored[i] = wrapped
+	}
+	return s.inner.SetHistory(ctx, sessionKey, stored)
+}
+
+func (s *SealedStore) Compact(ctx context.Context, sessionKey string) error {
+	return s.inner.Compact(ctx, sessionKey)
+}
+
+func (s *SealedStore) Close() error {
+	return s.inner.Close()
+}
+
+// maybeSweep starts a sweep in the background if none ran today.
+func (s *SealedStore) maybeSweep() {
+	if _, ok := s.inner.(Lister); !ok {
+		return
+	}
+	day := s.now().Format(time.DateOnly)
+	s.mu.Lock()
+	due := day > s.swept
+	if due {
+		s.swept = day
+	}
+	s.mu.Unlock()
+	if due {
+		go func() {
+			if n, err := s.Sweep(context.Background()); err != nil {
+				log.Printf("memory: sweep sessions: %v", err)
+			} else if n > 0 {
+				log.Printf("memory: removed %d expired message(s)", n)
+			}
+		}()
+	}
+}
+
+// Sweep deletes the expired messages of every session of a Lister store,
+// and seals the messages stored before the wrapping. It returns the number
+// of messages deleted.
+func (s *SealedStore) Sweep(ctx context.Context) (int, error) {
+	lister, ok := s.inner.(Lister)
+	if !ok {
+		return 0, nil
+	}
+	keys, err := lister.SessionKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, key := range keys {
+		n, err := s.sweepSession(ctx, key)
+		if err != nil {
+			log.Printf("memory: sweep %s: %v", key, err)
+			continue
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+func (s *SealedStore) sweepSession(ctx context.Context, sessionKey string) (int, error) {
+	l := s.sessionLock(sessionKey)
+	l.Lock()
+	defer l.Unlock()
+
+	stored, err := s.inner.GetHistory(ctx, sessionKey)
+	if err != nil {
+		return 0, err
+	}
+	envs, err := s.unwrapAll(sessionKey, stored)
+	if err != nil {
+		return 0, err
+	}
+	kept, _ := s.unexpired(sessionKey, stored, envs)
+	rewrite := len(kept) < len(stored)
+	for _, msg := range kept {
+		rewrite = rewrite || !s.isFinal(msg)
+	}
+	if rewrite {
+		if kept, err = s.rewrap(sessionKey, kept); err != nil {
+			return 0, err
+		}
+		if err := s.replace(ctx, sessionKey, kept); err != nil {
+			return 0, err
+		}
+	}
+	if s.sealer != nil {
+		if summary, err := s.inner.GetSummary(ctx, sessionKey); err == nil &&
+			summary != "" && !IsSealed(summary) {
+			if err := s.SetSummary(ctx, sessionKey, summary); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(stored) - len(kept), nil
+}
@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+func newTestSealedStore(t *testing.T, inner Store, key []byte, retain Retention) *SealedStore {
+	t.Helper()
+	s, err := NewSealedStore(inner, key, retain)
+	if err != nil {
+		t.Fatalf("NewSealedStore: %v", err)
+	}
+	s.swept = "9999-12-31" // no background sweeps
+	return s
+}
+
+func TestSealedStore_EncryptsAtRest(t *testing.T) {
+	dir := t.TempDir()
+	inner, _ := NewJSONLStore(dir)
+	s := newTestSealedStore(t, inner, testKey, nil)
+	ctx := context.Background()
+
+	s.AddMessage(ctx, "agent:main:main", "user", "my card is 4111")
+	s.AddFullMessage(ctx, "agent:main:main", providers.Message{
+		Role:      "assistant",
+		Content:   "noted",
+		ToolCalls: []providers.ToolCall{{ID: "c1", Function: &providers.FunctionCall{Name: "save_card"}}},
+	})
+	s.SetSummary(ctx, "agent:main:main", "talked about the card 4111")
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, f := range files {
+		data, _ := os.ReadFile(f)
+		if bytes.Contains(data, []byte("4111")) || bytes.Contains(data, []byte("save_card")) {
+			t.Errorf("%s holds plaintext: %s", filepath.Base(f), data)
+		}
+	}
+
+	history, err := s.GetHistory(ctx, "agent:main:main")
+	if err != nil || len(history) != 2 || history[0].Content != "my card is 4111" ||
+		history[1].ToolCalls[0].Function.Name != "save_card" {
+		t.Fatalf("GetHistory = %+v, %v", history, err)
+	}
+	if summary, _ := s.GetSummary(ctx, "agent:main:main"); summary != "talked about the card 4111" {
+		t.Errorf("summary = %q", summary)
+	}
+
+	otherKey := newTestSealedStore(t, inner, bytes.Repeat([]byte{8}, 32), nil)
+	if _, err := otherKey.GetHistory(ctx, "agent:main:main"); !errors.Is(err, ErrUndecryptable) {
+		t.Errorf("wrong key: error = %v", err)
+	}
+	if _, err := NewSealedStore(inner, []byte("short"), nil); err == nil {
+		t.Error("a short key was accepted")
+	}
+}
+
+func TestSealedStore_RetentionPurgesOldMessages(t *testing.T) {
+	inner := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "picoclaw.db"))
+	retain := func(sessionKey string) time.Duration {
+		if sessionKey == "keep" {
+			return 0
+		}
+		return 24 * time.Hour
+	}
+	s := newTestSealedStore(t, inner, nil, retain)
+	ctx := context.Background()
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	s.AddMessage(ctx, "chat", "user", "old question")
+	s.AddMessage(ctx, "keep", "user", "kept forever")
+	s.AddMessage(ctx, "idle", "user", "nobody reads this")
+	now = now.Add(20 * time.Hour)
+	s.AddMessage(ctx, "chat", "assistant", "recent answer")
+	// Rewriting the history keeps the time the old message was added.
+	history, _ := s.GetHistory(ctx, "chat")
+	s.SetHistory(ctx, "chat", history)
+	now = now.Add(5 * time.Hour)
+
+	history, err := s.GetHistory(ctx, "chat")
+	if err != nil || len(history) != 1 || history[0].Content != "recent answer" {
+		t.Fatalf("GetHistory = %+v, %v", history, err)
+	}
+	if stored, _ := inner.GetHistory(ctx, "chat"); len(stored) != 1 {
+		t.Errorf("expired message still stored: %+v", stored)
+	}
+
+	s.SetSummary(ctx, "idle", "a summary")
+	n, err := s.Sweep(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("Sweep = %d, %v", n, err)
+	}
+	if stored, _ := inner.GetHistory(ctx, "idle"); len(stored) != 0 {
+		t.Errorf("sweep kept %+v", stored)
+	}
+	if summary, _ := inner.GetSummary(ctx, "idle"); summary != "" {
+		t.Errorf("summary of an emptied session = %q", summary)
+	}
+	if kept, _ := s.GetHistory(ctx, "keep"); len(kept) != 1 {
+		t.Errorf("keep = %+v", kept)
+	}
+}
+
+func TestSealedStore_SweepSealsExistingHistory(t *testing.T) {
+	dir := t.TempDir()
+	inner, _ := NewJSONLStore(dir)
+	ctx := context.Background()
+	inner.AddMessage(ctx, "s1", "user", "written before encryption")
+	inner.SetSummary(ctx, "s1", "plain summary")
+
+	s := newTestSealedStore(t, inner, testKey, nil)
+	if history, _ := s.GetHistory(ctx, "s1"); len(history) != 1 || history[0].Content != "written before encryption" {
+		t.Fatalf("legacy history = %+v", history)
+	}
+	if _, err := s.Sweep(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := inner.GetHistory(ctx, "s1")
+	summary, _ := inner.GetSummary(ctx, "s1")
+	if len(stored) != 1 || !IsSealed(stored[0].Content) || summary == "plain summary" {
+		t.Errorf("not sealed: %+v, summary %q", stored, summary)
+	}
+	if history, _ := s.GetHistory(ctx, "s1"); len(history) != 1 || history[0].Content != "written before encryption" {
+		t.Errorf("sealed history = %+v", history)
+	}
+}
+
+func TestSealedStore_SealsContentThatLooksStored(t *testing.T) {
+	dir := t.TempDir()
+	inner, _ := NewJSONLStore(dir)
+	s := newTestSealedStore(t, inner, testKey, nil)
+	ctx := context.Background()
+
+	for _, content := range []string{sealedPrefix + "my card is 4111", stampedPrefix + `{"m": "my card is 4111"}`} {
+		if err := s.AddMessage(ctx, "s1", "user", content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.SetSummary(ctx, "s1", sealedPrefix+"the card 4111")
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, f := range files {
+		if data, _ := os.ReadFile(f); bytes.Contains(data, []byte("4111")) {
+			t.Errorf("%s holds plaintext: %s", filepath.Base(f), data)
+		}
+	}
+	history, err := s.GetHistory(ctx, "s1")
+	if err != nil || len(history) != 2 || history[0].Content != sealedPrefix+"my card is 4111" {
+		t.Errorf("GetHistory = %+v, %v", history, err)
+	}
+	if summary, _ := s.GetSummary(ctx, "s1"); summary != sealedPrefix+"the card 4111" {
+		t.Errorf("summary = %q", summary)
+	}
+}
+
+func TestMigrateFromJSONL_KeepsSealedSessions(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	jsonl, _ := NewJSONLStore(dir)
+	src := newTestSealedStore(t, jsonl, testKey, nil)
+	src.AddMessage(ctx, "s1", "user", "my card is 4111")
+	src.SetSummary(ctx, "s1", "the card")
+	// Stamped while only retention was on, before encryption
+	stamped := newTestSealedStore(t, jsonl, nil, nil)
+	stamped.AddMessage(ctx, "s1", "assistant", "noted 4111")
+
+	sqlite, err := NewSQLiteStore(ctx, filepath.Join(t.TempDir(), "picoclaw.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+	dst := newTestSealedStore(t, sqlite, testKey, nil)
+	if n, err := MigrateFromJSONL(ctx, dir, dst); err != nil || n != 1 {
+		t.Fatalf("MigrateFromJSONL = %d, %v", n, err)
+	}
+
+	stored, _ := sqlite.GetHistory(ctx, "s1")
+	for _, msg := range stored {
+		if !IsSealed(msg.Content) {
+			t.Errorf("migrated message not sealed: %+v", msg)
+		}
+	}
+	history, err := dst.GetHistory(ctx, "s1")
+	if err != nil || len(history) != 2 || history[0].Content != "my card is 4111" || history[1].Content != "noted 4111" {
+		t.Errorf("migrated history = %+v, %v", history, err)
+	}
+	if summary, _ := dst.GetSummary(ctx, "s1"); summary != "the card" {
+		t.Errorf("migrated summary = %q", summary)
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(testKey)
+	t.Setenv("TEST_SESSION_KEY", encoded+"\n")
+	if key, err := LoadKey("TEST_SESSION_KEY", nil); err != nil || !bytes.Equal(key, testKey) {
+		t.Errorf("LoadKey(env) = %x, %v", key, err)
+	}
+	if key, err := LoadKey("", []string{"echo", encoded}); err != nil || !bytes.Equal(key, testKey) {
+		t.Errorf("LoadKey(command) = %x, %v", key, err)
+	}
+	if _, err := LoadKey("TEST_SESSION_KEY_UNSET", nil); err == nil {
+		t.Error("an unset variable was accepted")
+	}
+	t.Setenv("TEST_SESSION_KEY", base64.StdEncoding.EncodeToString([]byte("too short")))
+	if _, err := LoadKey("TEST_SESSION_KEY", nil); err == nil {
+		t.Error("a short key was accepted")
+	}
+}
@@ -0,0 +1,200 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/database"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// sqliteTimeFormat is fixed-width so stored times sort and compare as text.
+const sqliteTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// SQLiteStore implements Store in the workspace SQLite database. Each
+// message is a row of session_messages, so truncating a session deletes
+// rows instead of leaving dead data behind like the JSONL store.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens the database at path, migrating it if needed.
+func NewSQLiteStore(ctx context.Context, path string) (*SQLiteStore, error) {
+	db, err := database.Open(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("memory: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) AddMessage(ctx context.Context, sessionKey, role, content string) error {
+	return s.AddFullMessage(ctx, sessionKey, providers.Message{Role: role, Content: content})
+}
+
+func (s *SQLiteStore) AddFullMessage(ctx context.Context, sessionKey string, msg providers.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("memory: marshal message: %w", err)
+	}
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := touchSession(ctx, tx, sessionKey); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO session_messages (session_key, seq, message)
+			SELECT ?, COALESCE(MAX(seq), 0) + 1, ? FROM session_messages WHERE session_key = ?`,
+			sessionKey, string(data), sessionKey)
+		if err != nil {
+			return fmt.Errorf("memory: append message: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) GetHistory(ctx context.Context, sessionKey string) ([]providers.Message, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT message FROM session_messages WHERE session_key = ? ORDER BY seq`, sessionKey)
+	if err != nil {
+		return nil, fmt.Errorf("memory: read history: %w", err)
+	}
+	defer rows.Close()
+
+	msgs := []providers.Message{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("memory: read history: %w", err)
+		}
+		var msg providers.Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return nil, fmt.Errorf("memory: decode message: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("memory: read history: %w", err)
+	}
+	return msgs, nil
+}
+
+func (s *SQLiteStore) GetSummary(ctx context.Context, sessionKey string) (string, error) {
+	var summary string
+	err := s.db.QueryRowContext(ctx, `SELECT summary FROM sessions WHERE key = ?`, sessionKey).Scan(&summary)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("memory: read summary: %w", err)
+	}
+	return summary, nil
+}
+
+func (s *SQLiteStore) SetSummary(ctx context.Context, sessionKey, summary string) error {
+	now := time.Now().UTC().Format(sqliteTimeFormat)
+	_, err := s.db.ExecContext(ctx, `INSERT INTO sessions (key, summary, created_at, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET summary = excluded.summary, updated_at = excluded.updated_at`,
+		sessionKey, summary, now, now)
+	if err != nil {
+		return fmt.Errorf("memory: write summary: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) TruncateHistory(ctx context.Context, sessionKey string, keepLast int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := touchSession(ctx, tx, sessionKey); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM session_messages WHERE session_key = ? AND seq <=
+			(SELECT COALESCE(MAX(seq), 0) FROM session_messages WHERE session_key = ?) - ?`,
+			sessionKey, sessionKey, max(keepLast, 0))
+		if err != nil {
+			return fmt.Errorf("memory: truncate history: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) SetHistory(ctx context.Context, sessionKey string, history []providers.Message) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := touchSession(ctx, tx, sessionKey); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM session_messages WHERE session_key = ?`, sessionKey); err != nil {
+			return fmt.Errorf("memory: replace history: %w", err)
+		}
+		for i, msg := range history {
+			data, err := json.Marshal(msg)
+			if err != nil {
+				return fmt.Errorf("memory: marshal message: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO session_messages (session_key, seq, message) VALUES (?, ?, ?)`,
+				sessionKey, i+1, string(data)); err != nil {
+				return fmt.Errorf("memory: replace history: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Compact is a no-op: SQLite deletes truncated messages right away.
+func (s *SQLiteStore) Compact(context.Context, string) error {
+	return nil
+}
+
+// SessionKeys returns the keys of the sessions in the store. Implements
+// Lister.
+func (s *SQLiteStore) SessionKeys(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT key FROM sessions ORDER BY key`)
+	if err != nil {
+		return nil, fmt.Errorf("memory: list sessions: %w", err)
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("memory: list sessions: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds.
+func (s *SQLiteStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("memory: begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("memory: commit: %w", err)
+	}
+	return nil
+}
+
+// touchSession creates the session row if needed and bumps updated_at.
+func touchSession(ctx context.Context, tx *sql.Tx, sessionKey string) error {
+	now := time.Now().UTC().Format(sqliteTimeFormat)
+	_, err := tx.ExecContext(ctx, `INSERT INTO sessions (key, created_at, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET updated_at = excluded.updated_at`,
+		sessionKey, now, now)
+	if err != nil {
+		return fmt.Errorf("memory: write session: %w", err)
+	}
+	return nil
+}
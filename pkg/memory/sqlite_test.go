@@ -0,0 +1,171 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func newTestSQLiteStore(t *testing.T, path string) *SQLiteStore {
+	t.Helper()
+	store, err := NewSQLiteStore(context.Background(), path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteStore_Roundtrip(t *testing.T) {
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "picoclaw.db"))
+	ctx := context.Background()
+
+	history, err := store.GetHistory(ctx, "empty")
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if history == nil || len(history) != 0 {
+		t.Errorf("empty session history = %#v, want an empty slice", history)
+	}
+
+	if err := store.AddMessage(ctx, "s1", "user", "hello"); err != nil {
+		t.Fatalf("AddMessage: %v", err)
+	}
+	err = store.AddFullMessage(ctx, "s1", providers.Message{
+		Role:      "assistant",
+		ToolCalls: []providers.ToolCall{{ID: "call_1", Name: "read_file"}},
+	})
+	if err != nil {
+		t.Fatalf("AddFullMessage: %v", err)
+	}
+	if err := store.AddMessage(ctx, "s2", "user", "other"); err != nil {
+		t.Fatalf("AddMessage: %v", err)
+	}
+
+	history, err = store.GetHistory(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].Content != "hello" || history[1].ToolCalls[0].ID != "call_1" {
+		t.Errorf("history = %+v", history)
+	}
+
+	if sum, _ := store.GetSummary(ctx, "s1"); sum != "" {
+		t.Errorf("summary = %q, want empty", sum)
+	}
+	if err := store.SetSummary(ctx, "s1", "greetings"); err != nil {
+		t.Fatalf("SetSummary: %v", err)
+	}
+	if sum, _ := store.GetSummary(ctx, "s1"); sum != "greetings" {
+		t.Errorf("summary = %q, want %q", sum, "greetings")
+	}
+}
+
+func TestSQLiteStore_TruncateAndSetHistory(t *testing.T) {
+	store := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "picoclaw.db"))
+	ctx := context.Background()
+
+	for _, c := range []string{"a", "b", "c", "d"} {
+		if err := store.AddMessage(ctx, "s1", "user", c); err != nil {
+			t.Fatalf("AddMessage: %v", err)
+		}
+	}
+	if err := store.TruncateHistory(ctx, "s1", 2); err != nil {
+		t.Fatalf("TruncateHistory: %v", err)
+	}
+	// Appending after a truncation continues the sequence.
+	if err := store.AddMessage(ctx, "s1", "user", "e"); err != nil {
+		t.Fatalf("AddMessage: %v", err)
+	}
+	history, _ := store.GetHistory(ctx, "s1")
+	if len(history) != 3 || history[0].Content != "c" || history[2].Content != "e" {
+		t.Errorf("history after truncate = %+v, want c, d, e", history)
+	}
+
+	if err := store.SetHistory(ctx, "s1", []providers.Message{{Role: "user", Content: "x"}}); err != nil {
+		t.Fatalf("SetHistory: %v", err)
+	}
+	history, _ = store.GetHistory(ctx, "s1")
+	if len(history) != 1 || history[0].Content != "x" {
+		t.Errorf("history after SetHistory = %+v", history)
+	}
+
+	if err := store.TruncateHistory(ctx, "s1", 0); err != nil {
+		t.Fatalf("TruncateHistory: %v", err)
+	}
+	if history, _ := store.GetHistory(ctx, "s1"); len(history) != 0 {
+		t.Errorf("history after keepLast=0 = %+v, want empty", history)
+	}
+}
+
+func TestSQLiteStore_PersistsAcrossInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "picoclaw.db")
+	ctx := context.Background()
+
+	first, err := NewSQLiteStore(ctx, path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	if err := first.AddMessage(ctx, "telegram:123", "user", "remember me"); err != nil {
+		t.Fatalf("AddMessage: %v", err)
+	}
+	if err := first.SetSummary(ctx, "telegram:123", "a summary"); err != nil {
+		t.Fatalf("SetSummary: %v", err)
+	}
+	first.Close()
+
+	second := newTestSQLiteStore(t, path)
+	history, _ := second.GetHistory(ctx, "telegram:123")
+	if len(history) != 1 || history[0].Content != "remember me" {
+		t.Errorf("history after reopen = %+v", history)
+	}
+	if sum, _ := second.GetSummary(ctx, "telegram:123"); sum != "a summary" {
+		t.Errorf("summary after reopen = %q", sum)
+	}
+}
+
+func TestMigrateFromJSONL(t *testing.T) {
+	sessionsDir := t.TempDir()
+	ctx := context.Background()
+
+	src, err := NewJSONLStore(sessionsDir)
+	if err != nil {
+		t.Fatalf("NewJSONLStore: %v", err)
+	}
+	src.AddMessage(ctx, "discord:42", "user", "hi")
+	src.AddMessage(ctx, "discord:42", "assistant", "hello")
+	src.SetSummary(ctx, "discord:42", "small talk")
+	src.Close()
+
+	dst := newTestSQLiteStore(t, filepath.Join(t.TempDir(), "picoclaw.db"))
+	n, err := MigrateFromJSONL(ctx, sessionsDir, dst)
+	if err != nil {
+		t.Fatalf("MigrateFromJSONL: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("migrated %d sessions, want 1", n)
+	}
+
+	history, _ := dst.GetHistory(ctx, "discord:42")
+	if len(history) != 2 || history[1].Content != "hello" {
+		t.Errorf("migrated history = %+v", history)
+	}
+	if sum, _ := dst.GetSummary(ctx, "discord:42"); sum != "small talk" {
+		t.Errorf("migrated summary = %q", sum)
+	}
+
+	entries, _ := os.ReadDir(sessionsDir)
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) != ".migrated" {
+			t.Errorf("%s was not renamed after migration", e.Name())
+		}
+	}
+
+	// Running again finds nothing left to migrate.
+	if n, err := MigrateFromJSONL(ctx, sessionsDir, dst); err != nil || n != 0 {
+		t.Errorf("second migration = %d, %v; want 0, nil", n, err)
+	}
+}
@@ -0,0 +1,144 @@
+// Package metacache keeps provider metadata, such as the model lists of
+// endpoints and downloaded tokenizer files, on disk for a while. Restarts do
+// not fetch it again each time, and a provider that is briefly unreachable
+// does not keep the bot from starting: when a refresh fails, the stale entry
+// is used instead.
+package metacache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Fetch gets fresh data for an entry.
+type Fetch func(ctx context.Context) ([]byte, error)
+
+// Cache is a directory of entries that are refreshed once older than the
+// TTL. A TTL of 0 refreshes entries on every use, keeping them only as a
+// fallback.
+type Cache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+
+	mu sync.Mutex // serializes refreshes, so one entry is fetched once
+}
+
+// New returns a cache keeping its entries in dir.
+func New(dir string, ttl time.Duration) *Cache {
+	return &Cache{dir: dir, ttl: ttl, now: time.Now}
+}
+
+// Dir returns the directory of workspace the cache keeps its entries in.
+func Dir(workspace string) string {
+	return filepath.Join(workspace, "cache", "providers")
+}
+
+// Path returns the file holding the entry of key, fetching it first when it
+// is missing or older than the TTL. If the fetch fails and the entry exists,
+// its file is returned however old it is.
+func (c *Cache) Path(ctx context.Context, key string, fetch Fetch) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	path := filepath.Join(c.dir, fileName(key))
+	info, statErr := os.Stat(path)
+	if statErr == nil && c.ttl > 0 && c.now().Sub(info.ModTime()) < c.ttl {
+		return path, nil
+	}
+
+	data, err := fetch(ctx)
+	if err == nil {
+		err = fileutil.WriteFileAtomic(path, data, 0o600)
+	}
+	if err != nil {
+		if statErr != nil {
+			return "", err
+		}
+		logger.WarnCF("metacache", "Using stale provider metadata", map[string]any{
+			"key":   key,
+			"age":   c.now().Sub(info.ModTime()).Round(time.Second).String(),
+			"error": err.Error(),
+		})
+	}
+	return path, nil
+}
+
+// Get returns the data of the entry of key, as Path does.
+func (c *Cache) Get(ctx context.Context, key string, fetch Fetch) ([]byte, error) {
+	path, err := c.Path(ctx, key, fetch)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// Models returns the models lister serves, cached under key. A nil cache
+// asks lister every time.
+func (c *Cache) Models(ctx context.Context, key string, lister providers.ModelLister) ([]string, error) {
+	if c == nil {
+		return lister.Models(ctx)
+	}
+	data, err := c.Get(ctx, "models:"+key, func(ctx context.Context) ([]byte, error) {
+		models, err := lister.Models(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(models)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var models []string
+	if err := json.Unmarshal(data, &models); err != nil {
+		return nil, fmt.Errorf("cached model list of %s: %w", key, err)
+	}
+	return models, nil
+}
+
+// maxDownload bounds a downloaded file; tokenizer rank files are a few MB.
+const maxDownload = 64 << 20
+
+// Download returns a Fetch that downloads url.
+func Download(url string) Fetch {
+	return func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("downloading %s: %s", url, resp.Status)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownload+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxDownload {
+			return nil, errors.New("downloading " + url + ": file too large")
+		}
+		return data, nil
+	}
+}
+
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,85 @@
+package metacache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+type lister struct {
+	models []string
+	err    error
+	calls  int
+}
+
+func (l *lister) Models(context.Context) ([]string, error) {
+	l.calls++
+	return l.models, l.err
+}
+
+func TestCache_Models(t *testing.T) {
+	c := New(t.TempDir(), time.Hour)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+	l := &lister{models: []string{"gpt-4o", "gpt-4o-mini"}}
+
+	for range 2 {
+		models, err := c.Models(ctx, "openai", l)
+		if err != nil || !slices.Equal(models, l.models) {
+			t.Fatalf("Models = %v, %v", models, err)
+		}
+	}
+	if l.calls != 1 {
+		t.Errorf("fresh entry fetched %d times, want once", l.calls)
+	}
+
+	// Past the TTL a refresh is tried; while the provider is down the stale
+	// list is still served
+	now = now.Add(2 * time.Hour)
+	l.err = errors.New("connection refused")
+	if models, err := c.Models(ctx, "openai", l); err != nil || len(models) != 2 || l.calls != 2 {
+		t.Errorf("stale Models = %v, %v after %d calls", models, err, l.calls)
+	}
+	if _, err := c.Models(ctx, "ollama", l); err == nil {
+		t.Error("a failed fetch without an entry returned no error")
+	}
+
+	var none *Cache
+	l.err = nil
+	if models, err := none.Models(ctx, "openai", l); err != nil || len(models) != 2 {
+		t.Errorf("nil cache Models = %v, %v", models, err)
+	}
+}
+
+func TestCache_Download(t *testing.T) {
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("rank file"))
+	}))
+	defer srv.Close()
+
+	c := New(t.TempDir(), 0)
+	ctx := context.Background()
+	path, err := c.Path(ctx, "tokenizer", Download(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	up = false
+	again, err := c.Path(ctx, "tokenizer", Download(srv.URL))
+	if err != nil || again != path {
+		t.Fatalf("Path while down = %q, %v", again, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "rank file" {
+		t.Errorf("cached file = %q", data)
+	}
+}
@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Factory builds a middleware from the settings of its config entry.
+type Factory func(settings map[string]any) (Middleware, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{
+		"log":     newLog,
+		"drop":    newDrop,
+		"replace": newReplace,
+	}
+)
+
+// Register makes a middleware type available to the config, for programs
+// embedding picoclaw. It replaces a type of the same name.
+func Register(typ string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[typ] = factory
+}
+
+func build(cfg config.MiddlewareConfig) (Middleware, error) {
+	if len(cfg.Points) == 0 {
+		return nil, fmt.Errorf("no points")
+	}
+	for _, point := range cfg.Points {
+		if !slices.Contains(Points, point) {
+			return nil, fmt.Errorf("unknown point %q", point)
+		}
+	}
+	factoriesMu.RLock()
+	factory, ok := factories[cfg.Type]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", cfg.Type)
+	}
+	return factory(cfg.Settings)
+}
+
+// decodeSettings fills v from the settings of a config entry.
+func decodeSettings(settings map[string]any, v any) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid settings: %w", err)
+	}
+	return nil
+}
+
+// newLog logs every message it sees, with its first 80 characters when
+// "content" is set.
+func newLog(settings map[string]any) (Middleware, error) {
+	var s struct {
+		Content bool `json:"content"`
+	}
+	if err := decodeSettings(settings, &s); err != nil {
+		return nil, err
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			fields := map[string]any{
+				"point":       msg.Point,
+				"channel":     msg.Channel,
+				"chat_id":     msg.ChatID,
+				"sender_id":   msg.SenderID,
+				"content_len": len(msg.Content),
+			}
+			if s.Content {
+				fields["content"] = utils.Truncate(msg.Content, 80)
+			}
+			logger.InfoCF("middleware", "Message", fields)
+			return next(ctx, msg)
+		}
+	}, nil
+}
+
+// newDrop stops messages whose text matches one of "patterns".
+func newDrop(settings map[string]any) (Middleware, error) {
+	var s struct {
+		Patterns []string `json:"patterns"`
+	}
+	if err := decodeSettings(settings, &s); err != nil {
+		return nil, err
+	}
+	if len(s.Patterns) == 0 {
+		return nil, fmt.Errorf("drop needs patterns")
+	}
+	patterns := make([]*regexp.Regexp, 0, len(s.Patterns))
+	for _, p := range s.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", p, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			if msg.Content == "" {
+				return next(ctx, msg)
+			}
+			for _, re := range patterns {
+				if re.MatchString(msg.Content) {
+					msg.Content = ""
+					msg.ToolCalls = nil
+					return nil
+				}
+			}
+			return next(ctx, msg)
+		}
+	}, nil
+}
+
+// newReplace rewrites the text with "rules", each replacing the matches of
+// "pattern" with "with", which may refer to groups as $1.
+func newReplace(settings map[string]any) (Middleware, error) {
+	var s struct {
+		Rules []struct {
+			Pattern string `json:"pattern"`
+			With    string `json:"with"`
+		} `json:"rules"`
+	}
+	if err := decodeSettings(settings, &s); err != nil {
+		return nil, err
+	}
+	if len(s.Rules) == 0 {
+		return nil, fmt.Errorf("replace needs rules")
+	}
+	type rule struct {
+		re   *regexp.Regexp
+		with string
+	}
+	rules := make([]rule, 0, len(s.Rules))
+	for _, r := range s.Rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", r.Pattern, err)
+		}
+		rules = append(rules, rule{re: re, with: r.With})
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			for _, r := range rules {
+				msg.Content = r.re.ReplaceAllString(msg.Content, r.with)
+			}
+			return next(ctx, msg)
+		}
+	}, nil
+}
@@ -0,0 +1,156 @@
+// Package middleware lets users filter, log, or rewrite messages at fixed
+// points of the pipeline, with middleware declared in the config or added
+// through the library API.
+//
+// A middleware wraps the next handler of its point:
+//
+//	func(next middleware.Handler) middleware.Handler {
+//		return func(ctx context.Context, msg *middleware.Message) error {
+//			msg.Content = strings.TrimSpace(msg.Content)
+//			return next(ctx, msg)
+//		}
+//	}
+//
+// It may change msg before or after calling next. One that returns without
+// calling next, or returns an error, stops the message: an inbound message
+// is ignored, a message from pre_send is not sent, and at pre_llm and
+// post_llm the turn ends with msg.Content as the reply, without one when it
+// is empty.
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Points of the pipeline middleware run at.
+const (
+	PointInbound = "inbound"  // a user's message, before the agent handles it
+	PointPreLLM  = "pre_llm"  // each request to the model
+	PointPostLLM = "post_llm" // each response of the model
+	PointPreSend = "pre_send" // each message a channel is about to send
+)
+
+// Points lists the points in pipeline order.
+var Points = []string{PointInbound, PointPreLLM, PointPostLLM, PointPreSend}
+
+// Message is what passes through a chain. Which fields are set depends on
+// the point:
+//
+//   - inbound: Content is the user's message, Metadata the channel's
+//     metadata of it.
+//   - pre_llm: Messages is the request to the model; changes apply to this
+//     request only. Content is the reply if the middleware stops it.
+//   - post_llm: Content is the model's text and ToolCalls the tools it
+//     asks for; clearing them ends the turn with Content.
+//   - pre_send: Content is the text about to be sent.
+//
+// Channel and ChatID are always set; SenderID and SessionKey are set where
+// a user's turn is known.
+type Message struct {
+	Point      string
+	Channel    string
+	ChatID     string
+	SenderID   string
+	SessionKey string
+	Content    string
+	Messages   []providers.Message
+	ToolCalls  []providers.ToolCall
+	Metadata   map[string]string
+}
+
+// Handler handles a message at one point.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Middleware wraps the next handler of its point.
+type Middleware func(next Handler) Handler
+
+// Chain holds the middleware of every point. Middleware added with Use
+// runs first, in the order added, then the configured middleware in config
+// order. It is safe for concurrent use; a nil *Chain passes everything.
+type Chain struct {
+	mu         sync.RWMutex
+	added      map[string][]Middleware
+	configured map[string][]Middleware
+}
+
+// NewChain returns a chain with the middleware of cfg.
+func NewChain(cfg []config.MiddlewareConfig) *Chain {
+	c := &Chain{added: make(map[string][]Middleware)}
+	c.Configure(cfg)
+	return c
+}
+
+// Use adds mw at point.
+func (c *Chain) Use(point string, mw Middleware) error {
+	if !slices.Contains(Points, point) {
+		return fmt.Errorf("unknown middleware point %q", point)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.added[point] = append(c.added[point], mw)
+	return nil
+}
+
+// Configure replaces the configured middleware with that of cfg. Entries
+// with an unknown type, point, or bad settings are logged and left out.
+func (c *Chain) Configure(cfg []config.MiddlewareConfig) {
+	configured := make(map[string][]Middleware)
+	for i, mcfg := range cfg {
+		mw, err := build(mcfg)
+		if err != nil {
+			logger.ErrorCF("middleware", "Middleware disabled: invalid config", map[string]any{
+				"index": i,
+				"type":  mcfg.Type,
+				"error": err.Error(),
+			})
+			continue
+		}
+		for _, point := range mcfg.Points {
+			configured[point] = append(configured[point], mw)
+		}
+	}
+	c.mu.Lock()
+	c.configured = configured
+	c.mu.Unlock()
+}
+
+// Run passes msg through the middleware of msg.Point and reports whether
+// it got through; see the package doc for what stopping means at each
+// point.
+func (c *Chain) Run(ctx context.Context, msg *Message) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.RLock()
+	mws := slices.Concat(c.added[msg.Point], c.configured[msg.Point])
+	c.mu.RUnlock()
+	if len(mws) == 0 {
+		return true
+	}
+
+	passed := false
+	var h Handler = func(context.Context, *Message) error {
+		passed = true
+		return nil
+	}
+	for _, mw := range slices.Backward(mws) {
+		h = mw(h)
+	}
+	if err := h(ctx, msg); err != nil {
+		logger.WarnCF("middleware", "Middleware stopped the message with an error", map[string]any{
+			"point":   msg.Point,
+			"channel": msg.Channel,
+			"chat_id": msg.ChatID,
+			"error":   err.Error(),
+		})
+		return false
+	}
+	return passed
+}
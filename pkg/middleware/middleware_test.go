@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func appendTag(tag string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			msg.Content += tag
+			return next(ctx, msg)
+		}
+	}
+}
+
+func TestChain_RunsInOrder(t *testing.T) {
+	c := NewChain([]config.MiddlewareConfig{{
+		Type:     "replace",
+		Points:   []string{PointInbound},
+		Settings: map[string]any{"rules": []any{map[string]any{"pattern": "b$", "with": "B"}}},
+	}})
+	if err := c.Use(PointInbound, appendTag("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Use(PointInbound, appendTag("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &Message{Point: PointInbound, Content: "x"}
+	if !c.Run(context.Background(), msg) {
+		t.Fatal("Run() stopped the message")
+	}
+	if msg.Content != "xaB" {
+		t.Errorf("Content = %q, want %q", msg.Content, "xaB")
+	}
+
+	other := &Message{Point: PointPreSend, Content: "x"}
+	if !c.Run(context.Background(), other) || other.Content != "x" {
+		t.Errorf("pre_send message = %+v, want it untouched", other)
+	}
+}
+
+func TestChain_Stops(t *testing.T) {
+	c := NewChain(nil)
+	var reached bool
+	_ = c.Use(PointPreSend, func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			if msg.Content == "secret" {
+				return nil
+			}
+			if msg.Content == "broken" {
+				return errors.New("boom")
+			}
+			return next(ctx, msg)
+		}
+	})
+	_ = c.Use(PointPreSend, func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			reached = true
+			return next(ctx, msg)
+		}
+	})
+
+	for _, content := range []string{"secret", "broken"} {
+		reached = false
+		if c.Run(context.Background(), &Message{Point: PointPreSend, Content: content}) {
+			t.Errorf("Run(%q) passed, want stopped", content)
+		}
+		if reached {
+			t.Errorf("Run(%q) reached the next middleware", content)
+		}
+	}
+	if !c.Run(context.Background(), &Message{Point: PointPreSend, Content: "hello"}) || !reached {
+		t.Error("Run(hello) did not pass through both middleware")
+	}
+}
+
+func TestChain_Nil(t *testing.T) {
+	var c *Chain
+	if !c.Run(context.Background(), &Message{Point: PointInbound}) {
+		t.Error("nil chain stopped the message")
+	}
+}
+
+func TestChain_UseUnknownPoint(t *testing.T) {
+	if err := NewChain(nil).Use("after_lunch", appendTag("a")); err == nil {
+		t.Error("Use() accepted an unknown point")
+	}
+}
+
+func TestConfigure_SkipsInvalidEntries(t *testing.T) {
+	c := NewChain([]config.MiddlewareConfig{
+		{Type: "unknown", Points: []string{PointInbound}},
+		{Type: "drop", Points: []string{"somewhere"}, Settings: map[string]any{"patterns": []any{"x"}}},
+		{Type: "drop", Points: []string{PointInbound}},
+		{Type: "drop", Points: []string{PointInbound}, Settings: map[string]any{"patterns": []any{"("}}},
+		{Type: "drop", Points: []string{PointInbound, PointPostLLM}, Settings: map[string]any{"patterns": []any{"(?i)spam"}}},
+	})
+	if got := len(c.configured[PointInbound]); got != 1 {
+		t.Fatalf("inbound middleware = %d, want only the valid drop", got)
+	}
+
+	msg := &Message{Point: PointPostLLM, Content: "Buy SPAM now"}
+	if c.Run(context.Background(), msg) {
+		t.Error("drop passed a matching message")
+	}
+	if msg.Content != "" {
+		t.Errorf("dropped Content = %q, want empty", msg.Content)
+	}
+	if !c.Run(context.Background(), &Message{Point: PointInbound, Content: "hello"}) {
+		t.Error("drop stopped a message that does not match")
+	}
+
+	// Reconfiguring replaces the configured middleware only
+	_ = c.Use(PointInbound, appendTag("!"))
+	c.Configure(nil)
+	msg = &Message{Point: PointInbound, Content: "spam"}
+	if !c.Run(context.Background(), msg) || msg.Content != "spam!" {
+		t.Errorf("after Configure(nil) message = %+v, want only the added middleware", msg)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test_upper", func(settings map[string]any) (Middleware, error) {
+		return appendTag(settings["tag"].(string)), nil
+	})
+	c := NewChain([]config.MiddlewareConfig{{
+		Type:     "test_upper",
+		Points:   []string{PointPreSend},
+		Settings: map[string]any{"tag": "?"},
+	}})
+	msg := &Message{Point: PointPreSend, Content: "ok"}
+	c.Run(context.Background(), msg)
+	if msg.Content != "ok?" {
+		t.Errorf("Content = %q, want %q", msg.Content, "ok?")
+	}
+}
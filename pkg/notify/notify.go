@@ -0,0 +1,199 @@
+// Package notify renders system-generated notifications (scheduler output,
+// task completions, quota warnings, ...) from templates, keeping them apart
+// from LLM output so operators can reword them per channel.
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/sipeed/picoclaw/pkg/i18n"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Event names for system-generated notifications.
+const (
+	EventCronCommandOutput   = "cron.command_output"
+	EventCronCommandError    = "cron.command_error"
+	EventCronCommandDisabled = "cron.command_disabled"
+	EventTaskCompleted       = "task.completed"
+	EventTaskFailed          = "task.failed"
+	EventQuotaWarning        = "quota.warning"
+	EventTenantQuota         = "tenant.quota_exceeded"
+	EventRateLimited         = "rate.limited"
+	EventQueueFull           = "queue.full"
+	EventInterrupted         = "shutdown.interrupted"
+	EventInterruptedDropped  = "shutdown.interrupted_ephemeral"
+)
+
+// DefaultChannel is the template key used when no channel-specific template exists.
+const DefaultChannel = "default"
+
+// Vars holds the variables available to a template.
+type Vars map[string]any
+
+// builtinTemplates are used for events that have no configured template.
+var builtinTemplates = map[string]string{
+	EventCronCommandOutput:   "Scheduled command '{{.Command}}' executed:\n{{.Output}}",
+	EventCronCommandError:    "Error executing scheduled command: {{.Error}}",
+	EventCronCommandDisabled: "Error executing scheduled command: command execution is disabled",
+	EventTaskCompleted:       "Task '{{.Label}}' completed.\n\nResult:\n{{.Result}}",
+	EventTaskFailed:          "Task '{{.Label}}' failed: {{.Error}}",
+	EventQuotaWarning:        "⚠️ {{.Resource}} usage is at {{.Used}} of {{.Limit}}.",
+	EventTenantQuota:         "This workspace has used its daily quota of {{.Limit}} {{.Resource}}. It resets at midnight.",
+	EventRateLimited: "{{if eq .Resource \"tokens\"}}Today's usage limit is reached, sorry. It resets at midnight." +
+		"{{else}}I'm getting a lot of messages right now. Please try again in {{.RetryAfter}}.{{end}}",
+	EventQueueFull:   "I'm busy with other messages right now. Please try again in a moment.",
+	EventInterrupted: "⏸️ I was interrupted by a restart. I'll answer this as soon as I'm back.",
+	EventInterruptedDropped: "⏸️ I was interrupted by a restart. This conversation is off the record, " +
+		"so I kept nothing: please send your message again when I'm back.",
+}
+
+// funcs are the helpers available inside templates.
+var funcs = template.FuncMap{
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"join":    strings.Join,
+	"default": defaultValue,
+	"truncate": func(n int, s string) string {
+		r := []rune(s)
+		if n <= 0 || len(r) <= n {
+			return s
+		}
+		return string(r[:n]) + "…"
+	},
+}
+
+// defaultValue returns def when v is nil or an empty string.
+func defaultValue(def, v any) any {
+	if v == nil {
+		return def
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return def
+	}
+	return v
+}
+
+// Renderer renders notifications using per-event, per-channel templates.
+// A nil *Renderer renders with the built-in templates.
+type Renderer struct {
+	templates map[string]map[string]*template.Template
+}
+
+// NewRenderer parses the configured templates (event → channel → template).
+// It returns an error naming the first template that fails to parse.
+func NewRenderer(overrides map[string]map[string]string) (*Renderer, error) {
+	r := &Renderer{templates: make(map[string]map[string]*template.Template)}
+
+	events := make([]string, 0, len(overrides))
+	for event := range overrides {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+
+	for _, event := range events {
+		for channel, text := range overrides[event] {
+			tmpl, err := parse(event+"/"+channel, text)
+			if err != nil {
+				return nil, fmt.Errorf("notification template %s for %s: %w", event, channel, err)
+			}
+			if r.templates[event] == nil {
+				r.templates[event] = make(map[string]*template.Template)
+			}
+			r.templates[event][channel] = tmpl
+		}
+	}
+	return r, nil
+}
+
+// Render renders the notification for event on channel. Lookup order is the
+// channel-specific template, then the "default" template, then the built-in
+// one. If a configured template fails to execute, the built-in is used.
+func (r *Renderer) Render(event, channel string, vars Vars) string {
+	return r.RenderIn("", event, channel, vars)
+}
+
+// RenderIn is Render for a user whose language has the i18n code lang. The
+// built-in template is replaced by its translation when there is one;
+// configured templates are used as written.
+func (r *Renderer) RenderIn(lang, event, channel string, vars Vars) string {
+	if r != nil {
+		tmpl := r.templates[event][channel]
+		if tmpl == nil {
+			tmpl = r.templates[event][DefaultChannel]
+		}
+
+		if tmpl != nil {
+			out, err := execute(tmpl, vars)
+			if err == nil {
+				return out
+			}
+			logger.WarnCF("notify", "Notification template failed, using built-in", map[string]any{
+				"event":   event,
+				"channel": channel,
+				"error":   err.Error(),
+			})
+		}
+	}
+	if text := i18n.Notification(lang, event); text != "" {
+		tmpl, err := parse(event+"/"+lang, text)
+		if err == nil {
+			var out string
+			if out, err = execute(tmpl, vars); err == nil {
+				return out
+			}
+		}
+		logger.WarnCF("notify", "Translated notification failed, using built-in", map[string]any{
+			"event":    event,
+			"language": lang,
+			"error":    err.Error(),
+		})
+	}
+	return renderBuiltin(event, vars)
+}
+
+// Render renders event with the built-in templates.
+func Render(event string, vars Vars) string {
+	return renderBuiltin(event, vars)
+}
+
+var (
+	builtinOnce   sync.Once
+	builtinParsed map[string]*template.Template
+)
+
+func renderBuiltin(event string, vars Vars) string {
+	builtinOnce.Do(func() {
+		builtinParsed = make(map[string]*template.Template, len(builtinTemplates))
+		for name, text := range builtinTemplates {
+			builtinParsed[name] = template.Must(parse(name, text))
+		}
+	})
+
+	tmpl, ok := builtinParsed[event]
+	if !ok {
+		return fmt.Sprintf("[%s]", event)
+	}
+	out, err := execute(tmpl, vars)
+	if err != nil {
+		return fmt.Sprintf("[%s]", event)
+	}
+	return out
+}
+
+func parse(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(text)
+}
+
+func execute(tmpl *template.Template, vars Vars) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, map[string]any(vars)); err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(sb.String(), "<no value>", ""), nil
+}
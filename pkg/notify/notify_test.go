@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender_Builtin(t *testing.T) {
+	got := Render(EventCronCommandOutput, Vars{"Command": "df -h", "Output": "ok"})
+	want := "Scheduled command 'df -h' executed:\nok"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestRender_UnknownEvent(t *testing.T) {
+	if got := Render("nope", nil); got != "[nope]" {
+		t.Errorf("Render(unknown) = %q", got)
+	}
+}
+
+func TestRenderer_NilUsesBuiltin(t *testing.T) {
+	var r *Renderer
+	got := r.Render(EventCronCommandError, "telegram", Vars{"Error": "boom"})
+	if got != "Error executing scheduled command: boom" {
+		t.Errorf("nil Renderer Render() = %q", got)
+	}
+}
+
+func TestRenderer_ChannelAndDefaultOverrides(t *testing.T) {
+	r, err := NewRenderer(map[string]map[string]string{
+		EventCronCommandOutput: {
+			"default": "[{{.JobName}}] {{.Output}}",
+			"discord": "**{{.JobName | upper}}**\n```\n{{.Output}}\n```",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRenderer() error: %v", err)
+	}
+
+	vars := Vars{"JobName": "backup", "Output": "done"}
+	if got := r.Render(EventCronCommandOutput, "discord", vars); got != "**BACKUP**\n```\ndone\n```" {
+		t.Errorf("discord template = %q", got)
+	}
+	if got := r.Render(EventCronCommandOutput, "slack", vars); got != "[backup] done" {
+		t.Errorf("default template = %q", got)
+	}
+	if got := r.Render(EventCronCommandError, "slack", Vars{"Error": "x"}); !strings.HasPrefix(got, "Error executing") {
+		t.Errorf("events without overrides should use built-in, got %q", got)
+	}
+}
+
+func TestRenderer_Funcs(t *testing.T) {
+	r, err := NewRenderer(map[string]map[string]string{
+		EventQuotaWarning: {"default": `{{default "tokens" .Resource}}: {{.Note | truncate 5}}`},
+	})
+	if err != nil {
+		t.Fatalf("NewRenderer() error: %v", err)
+	}
+	got := r.Render(EventQuotaWarning, "cli", Vars{"Note": "almost exhausted"})
+	if got != "tokens: almos…" {
+		t.Errorf("Render() = %q", got)
+	}
+}
+
+func TestRenderer_MissingVarsRenderEmpty(t *testing.T) {
+	got := Render(EventTaskFailed, Vars{"Label": "sync"})
+	if got != "Task 'sync' failed: " {
+		t.Errorf("Render() = %q", got)
+	}
+}
+
+func TestNewRenderer_ParseError(t *testing.T) {
+	_, err := NewRenderer(map[string]map[string]string{
+		EventCronCommandOutput: {"default": "{{.Output"},
+	})
+	if err == nil {
+		t.Fatal("expected parse error")
+	}
+	if !strings.Contains(err.Error(), EventCronCommandOutput) {
+		t.Errorf("error should name the event, got %v", err)
+	}
+}
+
+func TestRenderer_RenderInTranslatesBuiltins(t *testing.T) {
+	vars := Vars{"Resource": "requests", "RetryAfter": "30 segundos"}
+	var r *Renderer
+	if got := r.RenderIn("es", EventRateLimited, "discord", vars); !strings.Contains(got, "30 segundos") ||
+		!strings.HasPrefix(got, "Estoy") {
+		t.Errorf("RenderIn(es) = %q", got)
+	}
+	if got := r.RenderIn("xx", EventQueueFull, "discord", nil); got != Render(EventQueueFull, nil) {
+		t.Errorf("unknown language = %q", got)
+	}
+
+	configured, err := NewRenderer(map[string]map[string]string{EventQueueFull: {"default": "Busy."}})
+	if err != nil {
+		t.Fatalf("NewRenderer() error: %v", err)
+	}
+	if got := configured.RenderIn("es", EventQueueFull, "discord", nil); got != "Busy." {
+		t.Errorf("configured template should win over the translation, got %q", got)
+	}
+}
@@ -0,0 +1,192 @@
+// Package objectstore uploads files to an S3-compatible bucket and hands out
+// presigned download links. It speaks the S3 API with SigV4 signing, which
+// AWS S3, Google Cloud Storage (HMAC keys), MinIO, and R2 all accept.
+package objectstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	defaultLinkTTL = 24 * time.Hour
+	// maxLinkTTL is the longest expiry SigV4 presigned URLs allow.
+	maxLinkTTL    = 7 * 24 * time.Hour
+	uploadTimeout = 30 * time.Minute
+)
+
+// Client uploads objects to one bucket.
+type Client struct {
+	bucket    string
+	region    string
+	base      *url.URL
+	pathStyle bool
+	accessKey string
+	secretKey string
+	prefix    string
+	linkTTL   time.Duration
+	http      *http.Client
+	now       func() time.Time
+}
+
+// New creates a client for the configured bucket.
+func New(cfg config.ObjectStorageConfig) (*Client, error) {
+	if cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("object storage needs bucket, access_key_id, and secret_access_key")
+	}
+	c := &Client{
+		bucket:    cfg.Bucket,
+		region:    cfg.Region,
+		accessKey: cfg.AccessKeyID,
+		secretKey: cfg.SecretAccessKey,
+		prefix:    cfg.Prefix,
+		linkTTL:   time.Duration(cfg.LinkTTLMinutes) * time.Minute,
+		http:      &http.Client{Timeout: uploadTimeout},
+		now:       time.Now,
+	}
+	if c.linkTTL <= 0 {
+		c.linkTTL = defaultLinkTTL
+	}
+	c.linkTTL = min(c.linkTTL, maxLinkTTL)
+
+	endpoint := cfg.Endpoint
+	switch strings.ToLower(cfg.Provider) {
+	case "", "s3":
+		if c.region == "" {
+			c.region = "us-east-1"
+		}
+		if endpoint == "" {
+			endpoint = "https://" + cfg.Bucket + ".s3." + c.region + ".amazonaws.com"
+		} else {
+			c.pathStyle = true
+		}
+	case "gcs":
+		if c.region == "" {
+			c.region = "auto"
+		}
+		if endpoint == "" {
+			endpoint = "https://storage.googleapis.com"
+		}
+		c.pathStyle = true
+	default:
+		return nil, fmt.Errorf("unsupported object storage provider %q (expected s3 or gcs)", cfg.Provider)
+	}
+
+	base, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid object storage endpoint %q", endpoint)
+	}
+	c.base = base
+	return c, nil
+}
+
+// objectURL returns the unsigned URL of key.
+func (c *Client) objectURL(key string) *url.URL {
+	u := *c.base
+	p := u.Path
+	if c.pathStyle {
+		p += "/" + c.bucket
+	}
+	u.Path = p + "/" + key
+	u.RawPath = p + "/" + encodePath(key)
+	return &u
+}
+
+// Put uploads size bytes from body as key.
+func (c *Client) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.objectURL(key).String(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	c.sign(req, c.now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("upload returned %s: %s", resp.Status, utils.Truncate(strings.TrimSpace(string(data)), 300))
+	}
+	return nil
+}
+
+// PresignGet returns a link that downloads key as filename until it expires.
+func (c *Client) PresignGet(key, filename string) string {
+	u := c.objectURL(key)
+	if filename != "" {
+		u.RawQuery = url.Values{
+			"response-content-disposition": {`attachment; filename="` + strings.ReplaceAll(filename, `"`, "") + `"`},
+		}.Encode()
+	}
+	return c.presign(http.MethodGet, u, c.now(), c.linkTTL)
+}
+
+// LinkTTL returns how long presigned links stay valid.
+func (c *Client) LinkTTL() time.Duration {
+	return c.linkTTL
+}
+
+// UploadFile uploads the file at localPath under a unique key and returns a
+// presigned link to it.
+func (c *Client) UploadFile(ctx context.Context, localPath, filename, contentType string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if filename == "" {
+		filename = path.Base(localPath)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	key := c.prefix + c.now().UTC().Format("2006/01/02") + "/" + hex.EncodeToString(id) + "/" + safeName(filename)
+	if err := c.Put(ctx, key, f, info.Size(), contentType); err != nil {
+		return "", err
+	}
+	return c.PresignGet(key, filename), nil
+}
+
+// safeName keeps filenames readable in object keys while dropping path
+// separators and characters that need escaping.
+func safeName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	if s := strings.Trim(b.String(), "."); s != "" {
+		return s
+	}
+	return "file"
+}
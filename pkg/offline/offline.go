@@ -0,0 +1,362 @@
+// Package offline holds messages that arrive while no LLM provider answers.
+// Instead of an error the sender is told the answer is delayed; the oldest
+// message is retried periodically, and the whole queue is released as soon
+// as a provider answers again.
+package offline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// MetadataKey marks a message replayed from the queue. Its value is the time
+// the message was first queued.
+const MetadataKey = "offline_queued_at"
+
+const (
+	defaultMessage = "The AI provider is unavailable right now. Your message is queued " +
+		"and will be answered automatically once it is back."
+	expiredMessage = "Sorry, I could not answer your message from %s: the AI provider stayed unavailable."
+	defaultRetry   = time.Minute
+	defaultMaxAge  = 24 * time.Hour
+	// probeTimeout frees the queue when a replayed message never reports
+	// back, e.g. because it was held by maintenance mode.
+	probeTimeout = 10 * time.Minute
+)
+
+// Path returns the queue location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "offline_queue.json")
+}
+
+// Queue holds the delayed messages. It is safe for concurrent use; a nil
+// *Queue holds nothing.
+type Queue struct {
+	path string
+
+	mu       sync.Mutex
+	cfg      config.OfflineQueueConfig
+	queue    []bus.InboundMessage
+	bus      *bus.MessageBus
+	probeAt  time.Time // when the head of the queue was replayed; zero if not
+	stopChan chan struct{}
+}
+
+// NewQueue loads the messages queued before a restart.
+func NewQueue(cfg config.OfflineQueueConfig, path string) *Queue {
+	q := &Queue{path: path, cfg: cfg}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &q.queue); err != nil {
+			logger.WarnCF("offline", "Ignoring unreadable offline queue", map[string]any{
+				"path":  path,
+				"error": err.Error(),
+			})
+			q.queue = nil
+		}
+	}
+	return q
+}
+
+// SetBus sets the bus queued messages are replayed on.
+func (q *Queue) SetBus(msgBus *bus.MessageBus) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.bus = msgBus
+}
+
+// Configure applies a reloaded config. Queued messages are kept.
+func (q *Queue) Configure(cfg config.OfflineQueueConfig) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.cfg = cfg
+}
+
+// Notice returns the text sent to users whose message was queued.
+func (q *Queue) Notice() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.cfg.Message != "" {
+		return q.cfg.Message
+	}
+	return defaultMessage
+}
+
+// Len returns the number of queued messages.
+func (q *Queue) Len() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.queue)
+}
+
+// Replayed reports whether msg was replayed from the queue.
+func Replayed(msg bus.InboundMessage) bool {
+	return msg.Metadata[MetadataKey] != ""
+}
+
+// Hold queues msg until a provider answers. A replayed message goes back to
+// its place in the queue. It reports false when the queue is disabled or
+// full, in which case the caller should report the error as usual.
+func (q *Queue) Hold(msg bus.InboundMessage) bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if Replayed(msg) {
+		if !q.probeAt.IsZero() && len(q.queue) > 0 && sameMessage(q.queue[0], msg) {
+			// The retried head failed again; it stays where it is.
+			q.probeAt = time.Time{}
+			return true
+		}
+		// Keep the queue in arrival order.
+		at := queuedAt(msg)
+		i := 0
+		for i < len(q.queue) && !queuedAt(q.queue[i]).After(at) {
+			i++
+		}
+		q.queue = slices.Insert(q.queue, i, msg)
+		q.saveLocked()
+		return true
+	}
+	if !q.cfg.Enabled || (q.cfg.QueueLimit > 0 && len(q.queue) >= q.cfg.QueueLimit) {
+		return false
+	}
+	meta := make(map[string]string, len(msg.Metadata)+1)
+	for k, v := range msg.Metadata {
+		meta[k] = v
+	}
+	meta[MetadataKey] = time.Now().UTC().Format(time.RFC3339)
+	msg.Metadata = meta
+	q.queue = append(q.queue, msg)
+	q.saveLocked()
+	logger.InfoCF("offline", "Queued message while providers are unavailable", map[string]any{
+		"channel":    msg.Channel,
+		"chat_id":    msg.ChatID,
+		"request_id": msg.RequestID,
+		"queued":     len(q.queue),
+	})
+	return true
+}
+
+// Resume releases every queued message after a provider answered. A replayed
+// head counts as answered and is not published again.
+func (q *Queue) Resume() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	if len(q.queue) == 0 {
+		q.mu.Unlock()
+		return
+	}
+	queued := q.queue
+	if !q.probeAt.IsZero() {
+		queued = queued[1:]
+	}
+	q.queue = nil
+	q.probeAt = time.Time{}
+	q.saveLocked()
+	msgBus := q.bus
+	q.mu.Unlock()
+
+	if len(queued) == 0 {
+		return
+	}
+	logger.InfoCF("offline", "Providers are back, releasing queued messages", map[string]any{
+		"messages": len(queued),
+	})
+	if msgBus == nil {
+		return
+	}
+	go func() {
+		for _, msg := range queued {
+			publishInbound(msgBus, msg)
+		}
+	}()
+}
+
+// Forget drops the queued messages of senderID on channel and returns how
+// many were dropped.
+func (q *Queue) Forget(channel, senderID string) int {
+	if q == nil || senderID == "" {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := make([]bus.InboundMessage, 0, len(q.queue))
+	for i, msg := range q.queue {
+		if msg.Channel == channel && msg.SenderID == senderID {
+			if i == 0 {
+				q.probeAt = time.Time{}
+			}
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	dropped := len(q.queue) - len(kept)
+	if dropped > 0 {
+		q.queue = kept
+		q.saveLocked()
+	}
+	return dropped
+}
+
+// Start retries the queue until Stop is called.
+func (q *Queue) Start() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stopChan != nil {
+		return
+	}
+	q.stopChan = make(chan struct{})
+	go q.runLoop(q.stopChan)
+}
+
+// Stop ends the retries. Queued messages are kept for the next start.
+func (q *Queue) Stop() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stopChan == nil {
+		return
+	}
+	close(q.stopChan)
+	q.stopChan = nil
+}
+
+func (q *Queue) runLoop(stopChan chan struct{}) {
+	for {
+		timer := time.NewTimer(q.retryInterval())
+		select {
+		case <-stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		q.Retry(time.Now())
+	}
+}
+
+func (q *Queue) retryInterval() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.cfg.RetrySeconds > 0 {
+		return time.Duration(q.cfg.RetrySeconds) * time.Second
+	}
+	return defaultRetry
+}
+
+// Retry drops messages that waited too long and replays the oldest one to
+// find out whether a provider answers again. Nothing is replayed while an
+// earlier retry is still in flight.
+func (q *Queue) Retry(now time.Time) {
+	q.mu.Lock()
+	maxAge := defaultMaxAge
+	if q.cfg.MaxAgeHours > 0 {
+		maxAge = time.Duration(q.cfg.MaxAgeHours) * time.Hour
+	}
+	if !q.probeAt.IsZero() && now.Sub(q.probeAt) > probeTimeout {
+		q.probeAt = time.Time{}
+	}
+	var expired []bus.InboundMessage
+	kept := q.queue[:0]
+	for i, msg := range q.queue {
+		if (i > 0 || q.probeAt.IsZero()) && now.Sub(queuedAt(msg)) > maxAge {
+			expired = append(expired, msg)
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	q.queue = kept
+	var probe *bus.InboundMessage
+	if q.probeAt.IsZero() && len(q.queue) > 0 {
+		head := q.queue[0]
+		probe = &head
+		q.probeAt = now
+	}
+	if len(expired) > 0 {
+		q.saveLocked()
+	}
+	msgBus := q.bus
+	q.mu.Unlock()
+
+	if msgBus == nil {
+		return
+	}
+	for _, msg := range expired {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		msgBus.PublishOutbound(ctx, bus.OutboundMessage{
+			Channel: msg.Channel,
+			ChatID:  msg.ChatID,
+			Content: fmt.Sprintf(expiredMessage, queuedAt(msg).Local().Format("Jan 2 15:04")),
+		})
+		cancel()
+	}
+	if len(expired) > 0 {
+		logger.WarnCF("offline", "Dropped queued messages that waited too long", map[string]any{
+			"messages": len(expired),
+		})
+	}
+	if probe != nil {
+		publishInbound(msgBus, *probe)
+	}
+}
+
+func publishInbound(msgBus *bus.MessageBus, msg bus.InboundMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := msgBus.PublishInbound(ctx, msg); err != nil {
+		logger.WarnCF("offline", "Failed to replay queued message", map[string]any{
+			"channel":    msg.Channel,
+			"chat_id":    msg.ChatID,
+			"request_id": msg.RequestID,
+			"error":      err.Error(),
+		})
+	}
+}
+
+func queuedAt(msg bus.InboundMessage) time.Time {
+	t, _ := time.Parse(time.RFC3339, msg.Metadata[MetadataKey])
+	return t
+}
+
+func sameMessage(a, b bus.InboundMessage) bool {
+	return a.Channel == b.Channel && a.ChatID == b.ChatID && a.MessageID == b.MessageID &&
+		a.Content == b.Content && a.Metadata[MetadataKey] == b.Metadata[MetadataKey]
+}
+
+func (q *Queue) saveLocked() {
+	data, err := json.MarshalIndent(q.queue, "", "  ")
+	if err == nil {
+		err = fileutil.WriteFileAtomic(q.path, data, 0o600)
+	}
+	if err != nil {
+		logger.WarnCF("offline", "Failed to save offline queue", map[string]any{
+			"path":  q.path,
+			"error": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package offline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func receive(t *testing.T, ch <-chan bus.InboundMessage) bus.InboundMessage {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message replayed")
+		return bus.InboundMessage{}
+	}
+}
+
+func TestQueue_HoldRetryAndResume(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	path := t.TempDir() + "/state/offline_queue.json"
+
+	q := NewQueue(config.OfflineQueueConfig{QueueLimit: 2}, path)
+	q.SetBus(msgBus)
+	if q.Hold(bus.InboundMessage{Content: "a"}) {
+		t.Fatal("a disabled queue held a message")
+	}
+	q.Configure(config.OfflineQueueConfig{Enabled: true, QueueLimit: 2})
+	for i, want := range []bool{true, true, false} {
+		msg := bus.InboundMessage{Channel: "cli", ChatID: "1", Content: string(rune('a' + i))}
+		if got := q.Hold(msg); got != want {
+			t.Errorf("Hold #%d = %v, want %v", i, got, want)
+		}
+	}
+	if n := NewQueue(config.OfflineQueueConfig{}, path).Len(); n != 2 {
+		t.Fatalf("queue after restart has %d messages, want 2", n)
+	}
+
+	// The head is retried alone; failing again keeps it in place.
+	q.Retry(time.Now())
+	head := receive(t, msgBus.InboundChan())
+	if head.Content != "a" || !Replayed(head) {
+		t.Fatalf("retried %+v", head)
+	}
+	q.Retry(time.Now())
+	select {
+	case msg := <-msgBus.InboundChan():
+		t.Fatalf("second retry while the first is in flight: %+v", msg)
+	default:
+	}
+	if !q.Hold(head) || q.Len() != 2 {
+		t.Fatalf("requeued head: len = %d", q.Len())
+	}
+
+	// Once the retried head is answered, the rest is released.
+	q.Retry(time.Now())
+	receive(t, msgBus.InboundChan())
+	q.Resume()
+	if got := receive(t, msgBus.InboundChan()); got.Content != "b" {
+		t.Fatalf("released %q, want b", got.Content)
+	}
+	if q.Len() != 0 {
+		t.Fatalf("queue not empty after resume: %d", q.Len())
+	}
+}
+
+func TestQueue_ReplayedMessagesKeepArrivalOrder(t *testing.T) {
+	q := NewQueue(config.OfflineQueueConfig{Enabled: true}, t.TempDir()+"/q.json")
+	older := bus.InboundMessage{Content: "older", Metadata: map[string]string{MetadataKey: "2026-01-01T10:00:00Z"}}
+	newer := bus.InboundMessage{Content: "newer", Metadata: map[string]string{MetadataKey: "2026-01-01T11:00:00Z"}}
+	q.Hold(newer)
+	q.Hold(older)
+	if q.queue[0].Content != "older" || q.queue[1].Content != "newer" {
+		t.Fatalf("queue order = %q, %q", q.queue[0].Content, q.queue[1].Content)
+	}
+}
+
+func TestQueue_DropsExpiredMessages(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	q := NewQueue(config.OfflineQueueConfig{Enabled: true, MaxAgeHours: 1}, t.TempDir()+"/q.json")
+	q.SetBus(msgBus)
+	q.Hold(bus.InboundMessage{Channel: "telegram", ChatID: "42", Content: "hello"})
+
+	q.Retry(time.Now().Add(2 * time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	select {
+	case out := <-msgBus.OutboundChan():
+		if out.ChatID != "42" || !strings.HasPrefix(out.Content, "Sorry, I could not answer") {
+			t.Fatalf("apology = %+v", out)
+		}
+	case <-ctx.Done():
+		t.Fatal("no apology for the expired message")
+	}
+	if q.Len() != 0 {
+		t.Fatalf("expired message still queued")
+	}
+}
+
+func TestQueue_Forget(t *testing.T) {
+	path := t.TempDir() + "/q.json"
+	q := NewQueue(config.OfflineQueueConfig{Enabled: true}, path)
+	q.Hold(bus.InboundMessage{Channel: "telegram", SenderID: "7", Content: "a"})
+	q.Hold(bus.InboundMessage{Channel: "telegram", SenderID: "8", Content: "b"})
+	q.Hold(bus.InboundMessage{Channel: "telegram", SenderID: "7", Content: "c"})
+
+	if n := q.Forget("telegram", "7"); n != 2 {
+		t.Fatalf("Forget = %d, want 2", n)
+	}
+	reloaded := NewQueue(config.OfflineQueueConfig{}, path)
+	if reloaded.Len() != 1 || reloaded.queue[0].Content != "b" {
+		t.Errorf("queue after Forget = %+v", reloaded.queue)
+	}
+}
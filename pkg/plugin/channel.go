@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/identity"
+)
+
+// Channel is a chat platform connected by a plugin. Replies go out through
+// channels/send; the plugin's channels/message notifications come in as
+// messages from its users.
+type Channel struct {
+	*channels.BaseChannel
+	client *Client
+}
+
+func newChannel(client *Client, spec ChannelSpec, allowFrom []string, msgBus *bus.MessageBus) *Channel {
+	base := channels.NewBaseChannel(spec.Name, spec, msgBus, allowFrom,
+		channels.WithMaxMessageLength(spec.MaxMessageLength))
+	return &Channel{BaseChannel: base, client: client}
+}
+
+func (c *Channel) Start(ctx context.Context) error {
+	if err := c.client.Call(ctx, MethodChannelStart, ChannelParams{Channel: c.Name()}, nil); err != nil {
+		return fmt.Errorf("starting plugin channel %s: %w", c.Name(), err)
+	}
+	c.SetRunning(true)
+	return nil
+}
+
+func (c *Channel) Stop(ctx context.Context) error {
+	c.SetRunning(false)
+	if err := c.client.Call(ctx, MethodChannelStop, ChannelParams{Channel: c.Name()}, nil); err != nil {
+		return fmt.Errorf("stopping plugin channel %s: %w", c.Name(), err)
+	}
+	return nil
+}
+
+// Send hands the message to the plugin. Errors of the plugin are permanent;
+// a plugin that has exited is reported as not running.
+func (c *Channel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	var result SendResult
+	err := c.client.Call(ctx, MethodChannelSend, SendParams{
+		Channel:          c.Name(),
+		ChatID:           msg.ChatID,
+		Content:          msg.Content,
+		ReplyToMessageID: msg.ReplyToMessageID,
+	}, &result)
+	if err != nil {
+		if c.client.exitErr() != nil {
+			return fmt.Errorf("%w: %w", channels.ErrNotRunning, err)
+		}
+		return fmt.Errorf("%w: %w", channels.ErrSendFailed, err)
+	}
+	if result.MessageID != "" {
+		c.RecordReply(msg.ChatID, result.MessageID)
+	}
+	return nil
+}
+
+func (c *Channel) receive(msg InboundMessage) {
+	if !c.IsRunning() {
+		return
+	}
+	kind := msg.PeerKind
+	if kind == "" {
+		kind = "direct"
+	}
+	peerID := msg.ChatID
+	if kind == "direct" {
+		peerID = msg.SenderID
+	}
+	sender := bus.SenderInfo{
+		Platform:    c.Name(),
+		PlatformID:  msg.SenderID,
+		CanonicalID: identity.BuildCanonicalID(c.Name(), msg.SenderID),
+		Username:    msg.Username,
+		DisplayName: msg.DisplayName,
+	}
+	c.HandleMessage(context.Background(), bus.Peer{Kind: kind, ID: peerID}, msg.MessageID, msg.SenderID,
+		msg.ChatID, msg.Content, nil, msg.Metadata, sender)
+}
@@ -0,0 +1,258 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ErrExited is returned for requests to a plugin whose process has exited.
+var ErrExited = errors.New("plugin exited")
+
+const (
+	// initTimeout bounds the start of a plugin up to its manifest.
+	initTimeout = 10 * time.Second
+	// shutdownTimeout is how long a plugin may take to exit before it is killed.
+	shutdownTimeout = 5 * time.Second
+	// maxLineSize bounds one message from a plugin.
+	maxLineSize = 4 << 20
+)
+
+// NotifyFunc handles a notification from a plugin.
+type NotifyFunc func(method string, params json.RawMessage)
+
+// Client runs one plugin process and sends it requests. It is safe for
+// concurrent use.
+type Client struct {
+	name     string
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	notify   NotifyFunc
+	manifest Manifest
+
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu         sync.Mutex
+	pending    map[int64]chan message
+	stderrDone chan struct{}
+	exited     chan struct{}
+	err        error // why the process exited
+}
+
+// Start runs the plugin of cfg, called name in the config, in dir and
+// waits for its manifest. notify receives the plugin's notifications.
+func Start(ctx context.Context, name string, cfg config.PluginConfig, dir string, notify NotifyFunc) (*Client, error) {
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("plugin %s: command is required", name)
+	}
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", name, err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", name, err)
+	}
+
+	c := &Client{
+		name:       name,
+		cmd:        cmd,
+		stdin:      stdin,
+		notify:     notify,
+		pending:    make(map[int64]chan message),
+		stderrDone: make(chan struct{}),
+		exited:     make(chan struct{}),
+	}
+	go c.logStderr(stderr)
+	go c.readLoop(stdout)
+
+	initCtx, cancel := context.WithTimeout(ctx, initTimeout)
+	defer cancel()
+	err = c.Call(initCtx, MethodInitialize, InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Name:            name,
+		Settings:        cfg.Settings,
+	}, &c.manifest)
+	if err == nil && c.manifest.ProtocolVersion != ProtocolVersion {
+		err = fmt.Errorf("speaks protocol version %d, want %d", c.manifest.ProtocolVersion, ProtocolVersion)
+	}
+	if err != nil {
+		c.kill()
+		return nil, fmt.Errorf("plugin %s: initialize: %w", name, err)
+	}
+	return c, nil
+}
+
+// Name returns the plugin's key in the config.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Manifest returns the tools and channels the plugin provides.
+func (c *Client) Manifest() Manifest {
+	return c.manifest
+}
+
+// Call sends a request and decodes its result into result, unless nil.
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	id := c.nextID.Add(1)
+	reply := make(chan message, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return err
+	}
+	c.pending[id] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(message{JSONRPC: "2.0", ID: &id, Method: method, Params: raw}); err != nil {
+		return err
+	}
+	select {
+	case msg := <-reply:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, result)
+	case <-c.exited:
+		return c.exitErr()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close asks the plugin to shut down and kills it when it does not exit in
+// time.
+func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	_ = c.Call(ctx, MethodShutdown, struct{}{}, nil)
+	c.stdin.Close()
+	select {
+	case <-c.exited:
+	case <-ctx.Done():
+		c.kill()
+	}
+	return nil
+}
+
+func (c *Client) write(msg message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("plugin %s: %w", c.name, err)
+	}
+	return nil
+}
+
+func (c *Client) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			logger.WarnCF("plugin", "Ignoring malformed message from plugin", map[string]any{
+				"plugin": c.name,
+				"error":  err.Error(),
+			})
+			continue
+		}
+		switch {
+		case msg.ID != nil && msg.Method == "":
+			c.mu.Lock()
+			reply, ok := c.pending[*msg.ID]
+			c.mu.Unlock()
+			if ok {
+				reply <- msg
+			}
+		case msg.ID == nil && msg.Method != "":
+			if c.notify != nil {
+				c.notify(msg.Method, msg.Params)
+			}
+		default:
+			// Plugins do not call the host
+			if msg.ID != nil {
+				_ = c.write(message{JSONRPC: "2.0", ID: msg.ID, Error: &RPCError{Code: -32601, Message: "method not found"}})
+			}
+		}
+	}
+
+	<-c.stderrDone
+	err := c.cmd.Wait()
+	if err == nil {
+		err = ErrExited
+	} else {
+		err = fmt.Errorf("%w: %w", ErrExited, err)
+	}
+	c.mu.Lock()
+	c.err = fmt.Errorf("plugin %s: %w", c.name, err)
+	c.mu.Unlock()
+	close(c.exited)
+	logger.InfoCF("plugin", "Plugin exited", map[string]any{
+		"plugin": c.name,
+		"error":  err.Error(),
+	})
+}
+
+func (c *Client) logStderr(stderr io.Reader) {
+	defer close(c.stderrDone)
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		logger.InfoCF("plugin", scanner.Text(), map[string]any{"plugin": c.name})
+	}
+}
+
+func (c *Client) exitErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) kill() {
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+	<-c.exited
+}
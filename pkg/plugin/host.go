@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// Host runs the configured plugins. It is safe for concurrent use; a nil
+// *Host runs none.
+type Host struct {
+	clients []*Client
+	configs map[string]config.PluginConfig
+
+	mu       sync.Mutex
+	channels map[string]*Channel // by channel name, the adapters of the current channel manager
+}
+
+// StartHost starts every enabled plugin in cfg, in dir. A plugin that fails
+// to start is logged and left out, so one broken binary does not keep the
+// others or the bot from running.
+func StartHost(ctx context.Context, cfg map[string]config.PluginConfig, dir string) *Host {
+	h := &Host{configs: cfg, channels: make(map[string]*Channel)}
+	for _, name := range slices.Sorted(maps.Keys(cfg)) {
+		pcfg := cfg[name]
+		if !pcfg.Enabled {
+			continue
+		}
+		client, err := Start(ctx, name, pcfg, dir, h.handleNotify)
+		if err != nil {
+			logger.ErrorCF("plugin", "Failed to start plugin", map[string]any{
+				"plugin": name,
+				"error":  err.Error(),
+			})
+			continue
+		}
+		manifest := client.Manifest()
+		logger.InfoCF("plugin", "Plugin started", map[string]any{
+			"plugin":   name,
+			"tools":    len(manifest.Tools),
+			"channels": len(manifest.Channels),
+		})
+		h.clients = append(h.clients, client)
+	}
+	return h
+}
+
+// Tools returns an adapter for every tool of the running plugins.
+func (h *Host) Tools() []tools.Tool {
+	if h == nil {
+		return nil
+	}
+	var out []tools.Tool
+	for _, client := range h.clients {
+		for _, spec := range client.Manifest().Tools {
+			out = append(out, &Tool{client: client, spec: spec})
+		}
+	}
+	return out
+}
+
+// Channels returns a new adapter for every channel of the running plugins,
+// publishing on msgBus. Messages from the plugins go to the adapters of the
+// latest call, so a recreated channel manager takes over.
+func (h *Host) Channels(msgBus *bus.MessageBus) []channels.Channel {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.channels)
+	var out []channels.Channel
+	for _, client := range h.clients {
+		pcfg := h.configs[client.Name()]
+		for _, spec := range client.Manifest().Channels {
+			if _, dup := h.channels[spec.Name]; dup {
+				logger.WarnCF("plugin", "Channel provided by two plugins, ignoring the second", map[string]any{
+					"plugin":  client.Name(),
+					"channel": spec.Name,
+				})
+				continue
+			}
+			ch := newChannel(client, spec, pcfg.AllowFrom, msgBus)
+			h.channels[spec.Name] = ch
+			out = append(out, ch)
+		}
+	}
+	return out
+}
+
+// Close shuts the plugins down.
+func (h *Host) Close() {
+	if h == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, client := range h.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+func (h *Host) handleNotify(method string, params json.RawMessage) {
+	if method != MethodChannelMessage {
+		logger.DebugCF("plugin", "Ignoring unknown notification", map[string]any{"method": method})
+		return
+	}
+	var msg InboundMessage
+	if err := json.Unmarshal(params, &msg); err != nil {
+		logger.WarnCF("plugin", "Ignoring malformed channel message", map[string]any{"error": err.Error()})
+		return
+	}
+	h.mu.Lock()
+	ch := h.channels[msg.Channel]
+	h.mu.Unlock()
+	if ch == nil {
+		logger.WarnCF("plugin", "Message for an unknown plugin channel", map[string]any{"channel": msg.Channel})
+		return
+	}
+	ch.receive(msg)
+}
@@ -0,0 +1,196 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// TestMain turns the test binary into a fake plugin when it is started by
+// one of the tests.
+func TestMain(m *testing.M) {
+	switch os.Getenv("PICOCLAW_TEST_PLUGIN") {
+	case "":
+		os.Exit(m.Run())
+	case "exit":
+		fmt.Fprintln(os.Stderr, "refusing to start")
+		os.Exit(3)
+	default:
+		fakePlugin()
+		os.Exit(0)
+	}
+}
+
+func fakePlugin() {
+	out := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req message
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Exit(2)
+		}
+		reply := message{JSONRPC: "2.0", ID: req.ID}
+		var result any = struct{}{}
+		switch req.Method {
+		case MethodInitialize:
+			result = Manifest{
+				ProtocolVersion: ProtocolVersion,
+				Tools:           []ToolSpec{{Name: "echo", Description: "Echoes text"}},
+				Channels:        []ChannelSpec{{Name: "fake", MaxMessageLength: 100}},
+			}
+		case MethodToolCall:
+			var params ToolCallParams
+			_ = json.Unmarshal(req.Params, &params)
+			text, _ := params.Arguments["text"].(string)
+			result = ToolResult{Content: "echo: " + text, IsError: text == ""}
+		case MethodChannelSend:
+			var params SendParams
+			_ = json.Unmarshal(req.Params, &params)
+			if params.ChatID == "crash" {
+				os.Exit(1)
+			}
+			_ = out.Encode(message{JSONRPC: "2.0", Method: MethodChannelMessage, Params: mustJSON(InboundMessage{
+				Channel:  "fake",
+				ChatID:   params.ChatID,
+				SenderID: "user-1",
+				Content:  "got " + params.Content,
+			})})
+			result = SendResult{MessageID: "m1"}
+		case MethodChannelStart, MethodChannelStop:
+		case MethodShutdown:
+			reply.Result = mustJSON(result)
+			_ = out.Encode(reply)
+			return
+		default:
+			reply.Error = &RPCError{Code: -32601, Message: "method not found"}
+			_ = out.Encode(reply)
+			continue
+		}
+		reply.Result = mustJSON(result)
+		_ = out.Encode(reply)
+	}
+}
+
+func mustJSON(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func fakeConfig(mode string) config.PluginConfig {
+	return config.PluginConfig{
+		Enabled: true,
+		Command: os.Args[0],
+		Env:     map[string]string{"PICOCLAW_TEST_PLUGIN": mode},
+	}
+}
+
+func TestStartHost_ToolCall(t *testing.T) {
+	host := StartHost(context.Background(), map[string]config.PluginConfig{"fake": fakeConfig("run")}, t.TempDir())
+	defer host.Close()
+
+	tools := host.Tools()
+	if len(tools) != 1 || tools[0].Name() != "echo" {
+		t.Fatalf("Tools() = %v, want the echo tool", tools)
+	}
+	if got := tools[0].(*Tool).Plugin(); got != "fake" {
+		t.Errorf("Plugin() = %q, want %q", got, "fake")
+	}
+	if params := tools[0].Parameters(); params["type"] != "object" {
+		t.Errorf("Parameters() = %v, want an empty object schema", params)
+	}
+
+	result := tools[0].Execute(context.Background(), map[string]any{"text": "hi"})
+	if result.IsError || result.ForLLM != "echo: hi" {
+		t.Errorf("Execute() = %+v, want echo: hi", result)
+	}
+	result = tools[0].Execute(context.Background(), map[string]any{})
+	if !result.IsError {
+		t.Errorf("Execute() without text = %+v, want an error", result)
+	}
+}
+
+func TestStartHost_SkipsFailingPlugins(t *testing.T) {
+	host := StartHost(context.Background(), map[string]config.PluginConfig{
+		"broken":   fakeConfig("exit"),
+		"disabled": {Command: os.Args[0]},
+		"nocmd":    {Enabled: true},
+		"fake":     fakeConfig("run"),
+	}, t.TempDir())
+	defer host.Close()
+
+	if len(host.clients) != 1 || host.clients[0].Name() != "fake" {
+		t.Fatalf("running plugins = %d, want only fake", len(host.clients))
+	}
+}
+
+func TestStart_Exit(t *testing.T) {
+	_, err := Start(context.Background(), "broken", fakeConfig("exit"), t.TempDir(), nil)
+	if !errors.Is(err, ErrExited) {
+		t.Fatalf("Start() error = %v, want ErrExited", err)
+	}
+}
+
+func TestChannel_SendAndReceive(t *testing.T) {
+	host := StartHost(context.Background(), map[string]config.PluginConfig{"fake": fakeConfig("run")}, t.TempDir())
+	defer host.Close()
+
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	chans := host.Channels(msgBus)
+	if len(chans) != 1 || chans[0].Name() != "fake" {
+		t.Fatalf("Channels() = %v, want the fake channel", chans)
+	}
+	ch := chans[0]
+	ctx := context.Background()
+	if err := ch.Send(ctx, bus.OutboundMessage{ChatID: "c1", Content: "x"}); err == nil {
+		t.Fatal("Send() before Start succeeded")
+	}
+	if err := ch.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := ch.Send(ctx, bus.OutboundMessage{ChatID: "c1", Content: "hello"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	select {
+	case msg := <-msgBus.InboundChan():
+		if msg.Channel != "fake" || msg.ChatID != "c1" || msg.Sender.PlatformID != "user-1" || msg.Content != "got hello" {
+			t.Errorf("inbound message = %+v", msg)
+		}
+		if msg.Peer.Kind != "direct" || msg.Peer.ID != "user-1" {
+			t.Errorf("inbound peer = %+v, want the sender as a direct peer", msg.Peer)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no inbound message from the plugin")
+	}
+}
+
+func TestChannel_SendAfterExit(t *testing.T) {
+	host := StartHost(context.Background(), map[string]config.PluginConfig{"fake": fakeConfig("run")}, t.TempDir())
+	defer host.Close()
+
+	ch := host.Channels(bus.NewMessageBus())[0]
+	ctx := context.Background()
+	if err := ch.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	err := ch.Send(ctx, bus.OutboundMessage{ChatID: "crash", Content: "bye"})
+	if !errors.Is(err, ErrExited) {
+		t.Fatalf("Send() error = %v, want ErrExited", err)
+	}
+	result := host.Tools()[0].Execute(ctx, map[string]any{"text": "hi"})
+	if !result.IsError {
+		t.Errorf("Execute() after exit = %+v, want an error", result)
+	}
+}
@@ -0,0 +1,136 @@
+// Package plugin runs third-party tools and channels as separate binaries,
+// declared under "plugins" in the config, so they can be added without
+// recompiling picoclaw.
+//
+// A plugin is started as a subprocess and speaks JSON-RPC 2.0 on its stdin
+// and stdout, one JSON object per line; what it writes to stderr is logged.
+// The host sends these requests:
+//
+//	initialize     {"protocol_version", "name", "settings"} -> Manifest
+//	tools/call     {"name", "arguments", "channel", "chat_id"} -> ToolResult
+//	channels/start {"channel"}
+//	channels/stop  {"channel"}
+//	channels/send  {"channel", "chat_id", "content", "reply_to_message_id"} -> {"message_id"}
+//	shutdown       asks the plugin to exit; stdin is closed after it
+//
+// and a plugin sends the notification
+//
+//	channels/message  InboundMessage
+//
+// for every message a user sends on one of its channels.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ProtocolVersion is the version of the protocol spoken by this host.
+const ProtocolVersion = 1
+
+// Method names of the protocol.
+const (
+	MethodInitialize     = "initialize"
+	MethodToolCall       = "tools/call"
+	MethodChannelStart   = "channels/start"
+	MethodChannelStop    = "channels/stop"
+	MethodChannelSend    = "channels/send"
+	MethodShutdown       = "shutdown"
+	MethodChannelMessage = "channels/message"
+)
+
+// message is a JSON-RPC request, response, or notification.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is the error of a failed request.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("plugin error %d: %s", e.Code, e.Message)
+}
+
+// InitializeParams are sent with initialize.
+type InitializeParams struct {
+	ProtocolVersion int            `json:"protocol_version"`
+	Name            string         `json:"name"`               // the plugin's key in the config
+	Settings        map[string]any `json:"settings,omitempty"` // the plugin's settings in the config
+}
+
+// Manifest is what a plugin provides, returned by initialize.
+type Manifest struct {
+	ProtocolVersion int           `json:"protocol_version"`
+	Tools           []ToolSpec    `json:"tools,omitempty"`
+	Channels        []ChannelSpec `json:"channels,omitempty"`
+}
+
+// ToolSpec describes a tool. Parameters is a JSON schema, as for any tool.
+type ToolSpec struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+// ChannelSpec describes a channel. Replies longer than MaxMessageLength
+// runes are split before they are sent; 0 never splits.
+type ChannelSpec struct {
+	Name             string `json:"name"`
+	MaxMessageLength int    `json:"max_message_length,omitempty"`
+}
+
+// ToolCallParams are sent with tools/call.
+type ToolCallParams struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+	Channel   string         `json:"channel,omitempty"`
+	ChatID    string         `json:"chat_id,omitempty"`
+}
+
+// ToolResult is the result of tools/call. Content goes to the LLM, ForUser
+// straight to the user.
+type ToolResult struct {
+	Content string `json:"content"`
+	ForUser string `json:"for_user,omitempty"`
+	IsError bool   `json:"is_error,omitempty"`
+}
+
+// ChannelParams are sent with channels/start and channels/stop.
+type ChannelParams struct {
+	Channel string `json:"channel"`
+}
+
+// SendParams are sent with channels/send.
+type SendParams struct {
+	Channel          string `json:"channel"`
+	ChatID           string `json:"chat_id"`
+	Content          string `json:"content"`
+	ReplyToMessageID string `json:"reply_to_message_id,omitempty"`
+}
+
+// SendResult is the result of channels/send.
+type SendResult struct {
+	MessageID string `json:"message_id,omitempty"`
+}
+
+// InboundMessage is sent with channels/message. PeerKind is "direct" or
+// "group"; it defaults to direct.
+type InboundMessage struct {
+	Channel     string            `json:"channel"`
+	ChatID      string            `json:"chat_id"`
+	MessageID   string            `json:"message_id,omitempty"`
+	SenderID    string            `json:"sender_id"`
+	Username    string            `json:"username,omitempty"`
+	DisplayName string            `json:"display_name,omitempty"`
+	PeerKind    string            `json:"peer_kind,omitempty"`
+	Content     string            `json:"content"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
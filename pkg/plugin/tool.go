@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// Tool runs a plugin tool through tools/call.
+type Tool struct {
+	client *Client
+	spec   ToolSpec
+}
+
+func (t *Tool) Name() string {
+	return t.spec.Name
+}
+
+func (t *Tool) Description() string {
+	return t.spec.Description
+}
+
+func (t *Tool) Parameters() map[string]any {
+	if t.spec.Parameters == nil {
+		return map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	return t.spec.Parameters
+}
+
+// Plugin returns the name of the plugin providing the tool.
+func (t *Tool) Plugin() string {
+	return t.client.Name()
+}
+
+func (t *Tool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	var result ToolResult
+	err := t.client.Call(ctx, MethodToolCall, ToolCallParams{
+		Name:      t.spec.Name,
+		Arguments: args,
+		Channel:   tools.ToolChannel(ctx),
+		ChatID:    tools.ToolChatID(ctx),
+	}, &result)
+	if err != nil {
+		return tools.ErrorResult("plugin tool " + t.spec.Name + " failed: " + err.Error()).WithError(err)
+	}
+	if result.IsError {
+		return tools.ErrorResult(result.Content)
+	}
+	return &tools.ToolResult{ForLLM: result.Content, ForUser: result.ForUser}
+}
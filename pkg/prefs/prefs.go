@@ -0,0 +1,238 @@
+// Package prefs stores the preferences each user sets with /prefs and turns
+// them into instructions that are added to every request from that user.
+package prefs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Verbosity levels.
+const (
+	VerbosityBrief    = "brief"
+	VerbosityNormal   = "normal"
+	VerbosityDetailed = "detailed"
+)
+
+const maxPersonaLen = 300
+
+// Keys lists the preferences users can set, in display order.
+var Keys = []string{"language", "verbosity", "persona", "streaming", "tts"}
+
+// Prefs are one user's preferences. Zero values mean "use the default".
+type Prefs struct {
+	Language  string `json:"language,omitempty"`
+	Verbosity string `json:"verbosity,omitempty"`
+	Persona   string `json:"persona,omitempty"`
+	Streaming *bool  `json:"streaming,omitempty"`
+	TTS       *bool  `json:"tts,omitempty"`
+}
+
+// IsZero reports whether no preference is set.
+func (p Prefs) IsZero() bool {
+	return p == Prefs{}
+}
+
+// StreamingOn reports whether replies to the user should stream, falling
+// back to def when the user has not chosen.
+func (p Prefs) StreamingOn(def bool) bool {
+	if p.Streaming == nil {
+		return def
+	}
+	return *p.Streaming
+}
+
+// TTSOn reports whether replies to the user should include speech, falling
+// back to def when the user has not chosen.
+func (p Prefs) TTSOn(def bool) bool {
+	if p.TTS == nil {
+		return def
+	}
+	return *p.TTS
+}
+
+// Set parses value and stores it under key.
+func (p *Prefs) Set(key, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fmt.Errorf("%s needs a value", key)
+	}
+	switch strings.ToLower(key) {
+	case "language", "lang":
+		p.Language = value
+	case "verbosity":
+		switch v := strings.ToLower(value); v {
+		case VerbosityBrief, VerbosityNormal, VerbosityDetailed:
+			p.Verbosity = v
+		default:
+			return fmt.Errorf("verbosity must be %s, %s, or %s", VerbosityBrief, VerbosityNormal, VerbosityDetailed)
+		}
+	case "persona":
+		if utf8.RuneCountInString(value) > maxPersonaLen {
+			return fmt.Errorf("persona must be at most %d characters", maxPersonaLen)
+		}
+		p.Persona = value
+	case "streaming", "stream":
+		on, err := parseSwitch(value)
+		if err != nil {
+			return err
+		}
+		p.Streaming = &on
+	case "tts":
+		on, err := parseSwitch(value)
+		if err != nil {
+			return err
+		}
+		p.TTS = &on
+	default:
+		return fmt.Errorf("unknown preference %q; choose from %s", key, strings.Join(Keys, ", "))
+	}
+	return nil
+}
+
+// Reset clears key, or every preference when key is empty.
+func (p *Prefs) Reset(key string) error {
+	switch strings.ToLower(key) {
+	case "":
+		*p = Prefs{}
+	case "language", "lang":
+		p.Language = ""
+	case "verbosity":
+		p.Verbosity = ""
+	case "persona":
+		p.Persona = ""
+	case "streaming", "stream":
+		p.Streaming = nil
+	case "tts":
+		p.TTS = nil
+	default:
+		return fmt.Errorf("unknown preference %q; choose from %s", key, strings.Join(Keys, ", "))
+	}
+	return nil
+}
+
+func parseSwitch(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("expected on or off, got %q", value)
+	}
+}
+
+// Describe lists every preference with its current value.
+func (p Prefs) Describe() string {
+	show := func(v string) string {
+		if v == "" {
+			return "default"
+		}
+		return v
+	}
+	showSwitch := func(v *bool) string {
+		switch {
+		case v == nil:
+			return "default"
+		case *v:
+			return "on"
+		default:
+			return "off"
+		}
+	}
+	return fmt.Sprintf("language: %s\nverbosity: %s\npersona: %s\nstreaming: %s\ntts: %s",
+		show(p.Language), show(p.Verbosity), show(p.Persona), showSwitch(p.Streaming), showSwitch(p.TTS))
+}
+
+// Instructions returns the system prompt section for the preferences that
+// shape replies, or "" when none are set.
+func (p Prefs) Instructions() string {
+	var lines []string
+	if p.Language != "" {
+		lines = append(lines, "Reply in "+p.Language+" unless the user asks for another language.")
+	}
+	switch p.Verbosity {
+	case VerbosityBrief:
+		lines = append(lines, "Keep replies brief: a few sentences, no preamble.")
+	case VerbosityDetailed:
+		lines = append(lines, "Give detailed, thorough replies with explanations and examples.")
+	}
+	if p.Persona != "" {
+		lines = append(lines, "The user asked you to adopt this persona or tone: "+p.Persona)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "## User Preferences\n" + strings.Join(lines, "\n")
+}
+
+// Path returns the preferences location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "prefs.json")
+}
+
+// UserKey identifies a user across chats on one channel.
+func UserKey(channel, senderID string) string {
+	return channel + ":" + senderID
+}
+
+// Store holds every user's preferences. It is safe for concurrent use; a nil
+// *Store has no preferences.
+type Store struct {
+	path string
+
+	mu    sync.Mutex
+	users map[string]Prefs
+}
+
+// NewStore loads the preferences saved at path.
+func NewStore(path string) *Store {
+	s := &Store{path: path, users: make(map[string]Prefs)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &s.users); err != nil {
+			logger.WarnCF("prefs", "Ignoring unreadable preferences", map[string]any{
+				"path":  path,
+				"error": err.Error(),
+			})
+			s.users = make(map[string]Prefs)
+		}
+	}
+	return s
+}
+
+// Get returns the preferences of user.
+func (s *Store) Get(user string) Prefs {
+	if s == nil {
+		return Prefs{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users[user]
+}
+
+// Put replaces the preferences of user and saves the store.
+func (s *Store) Put(user string, p Prefs) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.IsZero() {
+		if _, ok := s.users[user]; !ok {
+			return nil
+		}
+		delete(s.users, user)
+	} else {
+		s.users[user] = p
+	}
+	data, err := json.MarshalIndent(s.users, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(s.path, data, 0o600)
+}
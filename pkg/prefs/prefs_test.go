@@ -0,0 +1,84 @@
+package prefs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrefs_SetAndReset(t *testing.T) {
+	var p Prefs
+	for key, value := range map[string]string{
+		"language":  "Spanish",
+		"verbosity": "Brief",
+		"persona":   "a patient teacher",
+		"streaming": "off",
+		"tts":       "on",
+	} {
+		if err := p.Set(key, value); err != nil {
+			t.Fatalf("Set(%q, %q) error = %v", key, value, err)
+		}
+	}
+	if p.Verbosity != VerbosityBrief || p.StreamingOn(true) || !p.TTSOn(false) {
+		t.Fatalf("prefs = %+v", p)
+	}
+	want := "language: Spanish\nverbosity: brief\npersona: a patient teacher\nstreaming: off\ntts: on"
+	if got := p.Describe(); got != want {
+		t.Fatalf("Describe() = %q, want %q", got, want)
+	}
+
+	if err := p.Set("verbosity", "chatty"); err == nil {
+		t.Error("expected an error for an unknown verbosity")
+	}
+	if err := p.Set("tts", "maybe"); err == nil {
+		t.Error("expected an error for a non on/off switch")
+	}
+	if err := p.Set("color", "blue"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+
+	if err := p.Reset("streaming"); err != nil || !p.StreamingOn(true) {
+		t.Fatalf("after reset streaming = %v, err = %v", p.Streaming, err)
+	}
+	if err := p.Reset(""); err != nil || !p.IsZero() {
+		t.Fatalf("after reset all prefs = %+v, err = %v", p, err)
+	}
+}
+
+func TestPrefs_Instructions(t *testing.T) {
+	if got := (Prefs{}).Instructions(); got != "" {
+		t.Fatalf("empty prefs instructions = %q", got)
+	}
+	p := Prefs{Language: "German", Verbosity: VerbosityDetailed, Persona: "pirate"}
+	got := p.Instructions()
+	for _, want := range []string{"## User Preferences", "Reply in German", "detailed", "persona or tone: pirate"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("instructions missing %q:\n%s", want, got)
+		}
+	}
+	on := true
+	if got := (Prefs{TTS: &on}).Instructions(); got != "" {
+		t.Errorf("tts-only prefs instructions = %q, want none", got)
+	}
+}
+
+func TestStore_Persists(t *testing.T) {
+	path := Path(t.TempDir())
+	user := UserKey("telegram", "42")
+	if err := NewStore(path).Put(user, Prefs{Language: "French"}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore(path)
+	if got := s.Get(user).Language; got != "French" {
+		t.Fatalf("reloaded language = %q, want French", got)
+	}
+	if err := s.Put(user, Prefs{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := NewStore(path).Get(user); !got.IsZero() {
+		t.Fatalf("prefs after clearing = %+v", got)
+	}
+	var nilStore *Store
+	if got := nilStore.Get(user); !got.IsZero() {
+		t.Fatalf("nil store prefs = %+v", got)
+	}
+}
@@ -0,0 +1,226 @@
+// Package promptlib keeps the prompt library: named templates, optionally
+// with few-shot examples, that users run with /prompt <name> <text>. The
+// templates come from the config and from operators, who add them in chat;
+// the ones added in chat are saved in the workspace and take precedence.
+package promptlib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// MaxExamples bounds the few-shot examples of one template, which are sent
+// with every use of it.
+const MaxExamples = 8
+
+// ErrUnknownPrompt means the library has no template with the given name.
+var ErrUnknownPrompt = errors.New("no prompt template with that name")
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// Template is one entry of the library.
+type Template struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Text        string                 `json:"template"`
+	Examples    []config.PromptExample `json:"examples,omitempty"`
+	AddedBy     string                 `json:"added_by,omitempty"`
+	Added       time.Time              `json:"added"`
+	Configured  bool                   `json:"-"` // comes from the config, not from chat
+}
+
+// Data is what a template can reference.
+type Data struct {
+	Text        string // what the user wrote after the template name
+	UserName    string
+	ChannelName string
+	Date        string // 2006-01-02
+	Weekday     string
+}
+
+// Path returns the location of the templates added in chat for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "prompts.json")
+}
+
+// NormalizeName returns the library name for name.
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Check reports whether t can be saved: it needs a valid name, a template
+// that parses, and examples with both sides.
+func Check(t Template) error {
+	if !validName.MatchString(t.Name) {
+		return fmt.Errorf("%q is not a valid name; use up to 32 lowercase letters, digits, - and _", t.Name)
+	}
+	if strings.TrimSpace(t.Text) == "" {
+		return errors.New("the template is empty")
+	}
+	if _, err := parse(t); err != nil {
+		return fmt.Errorf("the template does not parse: %w", err)
+	}
+	if len(t.Examples) > MaxExamples {
+		return fmt.Errorf("a template can have at most %d examples", MaxExamples)
+	}
+	for _, ex := range t.Examples {
+		if strings.TrimSpace(ex.User) == "" || strings.TrimSpace(ex.Assistant) == "" {
+			return errors.New("an example needs both a message and an answer")
+		}
+	}
+	return nil
+}
+
+func parse(t Template) (*template.Template, error) {
+	return template.New(t.Name).Option("missingkey=zero").Parse(t.Text)
+}
+
+// Render returns the message t makes of data. Text is appended when the
+// template does not place it, so a plain instruction works as a template.
+func Render(t Template, data Data) (string, error) {
+	tmpl, err := parse(t)
+	if err != nil {
+		return "", fmt.Errorf("prompt template %s does not parse: %w", t.Name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("prompt template %s failed: %w", t.Name, err)
+	}
+	out := strings.TrimSpace(b.String())
+	if text := strings.TrimSpace(data.Text); text != "" && !strings.Contains(t.Text, ".Text") {
+		out += "\n\n" + text
+	}
+	return out, nil
+}
+
+// Store holds the templates added in chat. It is safe for concurrent use; a
+// nil *Store holds none and only serves the configured templates.
+type Store struct {
+	path string
+
+	mu        sync.Mutex
+	templates map[string]Template
+}
+
+// NewStore loads the templates saved at path.
+func NewStore(path string) *Store {
+	s := &Store{path: path, templates: make(map[string]Template)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &s.templates); err != nil {
+			logger.WarnCF("promptlib", "Ignoring unreadable prompt templates", map[string]any{
+				"path":  path,
+				"error": err.Error(),
+			})
+			s.templates = make(map[string]Template)
+		}
+	}
+	return s
+}
+
+// List returns the templates of the library, configured ones overridden by
+// the ones added in chat, sorted by name.
+func (s *Store) List(configured map[string]config.PromptPreset) []Template {
+	merged := make(map[string]Template, len(configured))
+	for name, p := range configured {
+		name = NormalizeName(name)
+		merged[name] = fromPreset(name, p)
+	}
+	if s != nil {
+		s.mu.Lock()
+		for name, t := range s.templates {
+			merged[name] = t
+		}
+		s.mu.Unlock()
+	}
+	out := make([]Template, 0, len(merged))
+	for _, t := range merged {
+		out = append(out, t)
+	}
+	slices.SortFunc(out, func(a, b Template) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// Get returns the template called name.
+func (s *Store) Get(configured map[string]config.PromptPreset, name string) (Template, bool) {
+	name = NormalizeName(name)
+	if s != nil {
+		s.mu.Lock()
+		t, ok := s.templates[name]
+		s.mu.Unlock()
+		if ok {
+			return t, true
+		}
+	}
+	for n, p := range configured {
+		if NormalizeName(n) == name {
+			return fromPreset(name, p), true
+		}
+	}
+	return Template{}, false
+}
+
+// Put saves t, replacing any template added in chat with its name.
+func (s *Store) Put(t Template) error {
+	if s == nil {
+		return errors.New("the prompt library is not available")
+	}
+	t.Name = NormalizeName(t.Name)
+	if err := Check(t); err != nil {
+		return err
+	}
+	t.Configured = false
+	if t.Added.IsZero() {
+		t.Added = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates[t.Name] = t
+	return s.saveLocked()
+}
+
+// Remove deletes the template added in chat called name. A configured
+// template with the same name is used again afterwards.
+func (s *Store) Remove(name string) error {
+	if s == nil {
+		return ErrUnknownPrompt
+	}
+	name = NormalizeName(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.templates[name]; !ok {
+		return ErrUnknownPrompt
+	}
+	delete(s.templates, name)
+	return s.saveLocked()
+}
+
+func (s *Store) saveLocked() error {
+	data, err := json.MarshalIndent(s.templates, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(s.path, data, 0o600)
+}
+
+func fromPreset(name string, p config.PromptPreset) Template {
+	return Template{
+		Name:        name,
+		Description: p.Description,
+		Text:        p.Template,
+		Examples:    slices.Clone(p.Examples),
+		Configured:  true,
+	}
+}
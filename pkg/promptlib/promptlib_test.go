@@ -0,0 +1,85 @@
+package promptlib
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+var configured = map[string]config.PromptPreset{
+	"BugReport": {
+		Description: "Turn a complaint into a bug report",
+		Template:    "Write a bug report for: {{.Text}}",
+		Examples:    []config.PromptExample{{User: "it crashes", Assistant: "**Summary:** crash"}},
+	},
+}
+
+func TestStore_OverridesConfiguredTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "prompts.json")
+	s := NewStore(path)
+
+	got, ok := s.Get(configured, "bugreport")
+	if !ok || !got.Configured || len(got.Examples) != 1 {
+		t.Fatalf("Get(bugreport) = %+v, %v", got, ok)
+	}
+	got.Text = "Bug report, please: {{.Text}}"
+	got.AddedBy = "discord:u1"
+	if err := s.Put(got); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.Put(Template{Name: "tldr", Text: "Summarize in one line."}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	reloaded := NewStore(path)
+	list := reloaded.List(configured)
+	if len(list) != 2 || list[0].Name != "bugreport" || list[0].Configured || list[1].Name != "tldr" {
+		t.Fatalf("List() = %+v", list)
+	}
+	if err := reloaded.Remove("bugreport"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if got, _ := reloaded.Get(configured, "bugreport"); !got.Configured {
+		t.Errorf("removing the override should restore the configured template, got %+v", got)
+	}
+	if err := reloaded.Remove("bugreport"); !errors.Is(err, ErrUnknownPrompt) {
+		t.Errorf("removing a configured template: error = %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	bad := []Template{
+		{Name: "Has Space", Text: "x"},
+		{Name: "empty", Text: "  "},
+		{Name: "broken", Text: "{{.Text"},
+		{Name: "half", Text: "x", Examples: []config.PromptExample{{User: "hi"}}},
+	}
+	for _, tt := range bad {
+		if err := Check(tt); err == nil {
+			t.Errorf("Check(%+v) accepted it", tt)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Report from {{.UserName}}: {{.Text}}", "Report from Ana: the app crashes"},
+		{"Summarize in one line.", "Summarize in one line.\n\nthe app crashes"},
+	}
+	for _, tt := range tests {
+		got, err := Render(Template{Name: "t", Text: tt.text}, Data{Text: "the app crashes", UserName: "Ana"})
+		if err != nil || got != tt.want {
+			t.Errorf("Render(%q) = %q, %v; want %q", tt.text, got, err, tt.want)
+		}
+	}
+	// The user's text is data; template actions in it are not run.
+	got, _ := Render(Template{Name: "t", Text: "{{.Text}}"}, Data{Text: "{{.UserName}}", UserName: "Ana"})
+	if got != "{{.UserName}}" {
+		t.Errorf("user text was rendered: %q", got)
+	}
+}
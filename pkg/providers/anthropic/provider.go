@@ -11,6 +11,7 @@ import (
 	"github.com/anthropics/anthropic-sdk-go/option"
 
 	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+	"github.com/sipeed/picoclaw/pkg/requestid"
 )
 
 type (
@@ -88,6 +89,9 @@ func (p *Provider) Chat(
 			option.WithHeader("anthropic-beta", anthropicBetaHeader),
 		)
 	}
+	if id := requestid.FromContext(ctx); id != "" {
+		opts = append(opts, option.WithHeader(requestid.Header, id))
+	}
 
 	params, err := buildParams(messages, tools, model, options)
 	if err != nil {
@@ -169,9 +173,11 @@ func buildParams(
 					anthropic.NewUserMessage(anthropic.NewToolResultBlock(msg.ToolCallID, msg.Content, false)),
 				)
 			} else {
-				anthropicMessages = append(anthropicMessages,
-					anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Content)),
-				)
+				blocks := imageBlocks(msg.Media)
+				if msg.Content != "" || len(blocks) == 0 {
+					blocks = append(blocks, anthropic.NewTextBlock(msg.Content))
+				}
+				anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(blocks...))
 			}
 		case "assistant":
 			if len(msg.ToolCalls) > 0 {
@@ -305,9 +311,12 @@ func levelToBudget(level string) int {
 	}
 }
 
+// translateTools converts tool definitions to Anthropic tools. The last one
+// carries a cache breakpoint: tools come first in the prompt, so the tool
+// schemas stay cached even when the system prompt after them changes.
 func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 	result := make([]anthropic.ToolUnionParam, 0, len(tools))
-	for _, t := range tools {
+	for i, t := range tools {
 		tool := anthropic.ToolParam{
 			Name: t.Function.Name,
 			InputSchema: anthropic.ToolInputSchemaParam{
@@ -326,6 +335,9 @@ func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 			}
 			tool.InputSchema.Required = required
 		}
+		if i == len(tools)-1 {
+			tool.CacheControl = anthropic.NewCacheControlEphemeralParam()
+		}
 		result = append(result, anthropic.ToolUnionParam{OfTool: &tool})
 	}
 	return result
@@ -378,6 +390,8 @@ func parseResponse(resp *anthropic.Message) *LLMResponse {
 			PromptTokens:     int(resp.Usage.InputTokens),
 			CompletionTokens: int(resp.Usage.OutputTokens),
 			TotalTokens:      int(resp.Usage.InputTokens + resp.Usage.OutputTokens),
+			CacheReadTokens:  int(resp.Usage.CacheReadInputTokens),
+			CacheWriteTokens: int(resp.Usage.CacheCreationInputTokens),
 		},
 	}
 }
@@ -398,3 +412,19 @@ func normalizeBaseURL(apiBase string) string {
 
 	return base
 }
+
+// imageBlocks converts the base64 image data URLs among media to image
+// content blocks, placed before the text as Anthropic recommends. Other
+// media is skipped.
+func imageBlocks(media []string) []anthropic.ContentBlockParamUnion {
+	var blocks []anthropic.ContentBlockParamUnion
+	for _, ref := range media {
+		header, data, ok := strings.Cut(ref, ";base64,")
+		mediaType, isImage := strings.CutPrefix(header, "data:")
+		if !ok || !isImage || !strings.HasPrefix(mediaType, "image/") {
+			continue
+		}
+		blocks = append(blocks, anthropic.NewImageBlockBase64(mediaType, data))
+	}
+	return blocks
+}
@@ -101,6 +101,9 @@ func TestBuildParams_WithTools(t *testing.T) {
 	if len(params.Tools) != 1 {
 		t.Fatalf("len(Tools) = %d, want 1", len(params.Tools))
 	}
+	if cc := params.Tools[0].OfTool.CacheControl; cc.Type != "ephemeral" {
+		t.Errorf("last tool CacheControl = %+v, want ephemeral", cc)
+	}
 }
 
 func TestParseResponse_TextOnly(t *testing.T) {
@@ -167,8 +170,10 @@ func TestProvider_ChatRoundTrip(t *testing.T) {
 				{"type": "text", "text": "Hello! How can I help you?"},
 			},
 			"usage": map[string]any{
-				"input_tokens":  15,
-				"output_tokens": 8,
+				"input_tokens":                15,
+				"output_tokens":               8,
+				"cache_read_input_tokens":     1200,
+				"cache_creation_input_tokens": 30,
 			},
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -191,6 +196,9 @@ func TestProvider_ChatRoundTrip(t *testing.T) {
 	if resp.Usage.PromptTokens != 15 {
 		t.Errorf("PromptTokens = %d, want 15", resp.Usage.PromptTokens)
 	}
+	if resp.Usage.CacheReadTokens != 1200 || resp.Usage.CacheWriteTokens != 30 {
+		t.Errorf("cache usage = %+v", resp.Usage)
+	}
 }
 
 func TestProvider_GetDefaultModel(t *testing.T) {
@@ -328,3 +336,24 @@ func createAnthropicTestClient(baseURL, token string) *anthropic.Client {
 	)
 	return &c
 }
+
+func TestBuildParams_UserMessageWithImage(t *testing.T) {
+	messages := []Message{
+		{
+			Role:    "user",
+			Content: "What is this?",
+			Media:   []string{"data:image/png;base64,iVBORw0K", "https://example.com/a.pdf"},
+		},
+	}
+	params, err := buildParams(messages, nil, "claude-sonnet-4.6", map[string]any{"max_tokens": 1024})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	blocks := params.Messages[0].Content
+	if len(blocks) != 2 || blocks[0].OfImage == nil || blocks[1].OfText == nil {
+		t.Fatalf("content = %+v, want an image block then the text", blocks)
+	}
+	if src := blocks[0].OfImage.Source.OfBase64; src == nil || src.Data != "iVBORw0K" || src.MediaType != "image/png" {
+		t.Errorf("image source = %+v", blocks[0].OfImage.Source)
+	}
+}
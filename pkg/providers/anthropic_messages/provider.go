@@ -16,7 +16,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/providers/common"
 	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+	"github.com/sipeed/picoclaw/pkg/requestid"
 )
 
 type (
@@ -41,6 +43,7 @@ type Provider struct {
 	apiKey     string
 	apiBase    string
 	httpClient *http.Client
+	retrier    *common.Retrier
 }
 
 // NewProvider creates a new Anthropic Messages API provider.
@@ -62,6 +65,7 @@ func NewProviderWithTimeout(apiKey, apiBase string, timeoutSeconds int) *Provide
 		httpClient: &http.Client{
 			Timeout: timeout,
 		},
+		retrier: common.NewRetrier(common.DefaultRetryPolicy),
 	}
 }
 
@@ -83,64 +87,160 @@ func (p *Provider) Chat(
 		return nil, fmt.Errorf("building request body: %w", err)
 	}
 
-	// Serialize to JSON
-	jsonBody, err := json.Marshal(requestBody)
+	resp, err := p.send(ctx, "POST", "messages", requestBody)
 	if err != nil {
-		return nil, fmt.Errorf("serializing request body: %w", err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// Build request URL
-	endpointURL, err := url.JoinPath(p.apiBase, "messages")
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("building endpoint URL: %w", err)
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	if err := statusError(resp.StatusCode, body); err != nil {
+		return nil, err
 	}
 
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", endpointURL, bytes.NewReader(jsonBody))
+	// Parse response
+	return parseResponseBody(body)
+}
+
+// CountTokens implements providers.TokenCounter with the
+// /messages/count_tokens endpoint.
+func (p *Provider) CountTokens(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+) (int, error) {
+	if p.apiKey == "" {
+		return 0, fmt.Errorf("API key not configured")
+	}
+	requestBody, err := buildRequestBody(messages, tools, model, map[string]any{"max_tokens": 1})
 	if err != nil {
-		return nil, fmt.Errorf("creating HTTP request: %w", err)
+		return 0, fmt.Errorf("building request body: %w", err)
 	}
+	// The counting endpoint only takes the prompt
+	delete(requestBody, "max_tokens")
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", p.apiKey) //nolint:canonicalheader // Anthropic API requires exact header name
-	req.Header.Set("Anthropic-Version", defaultAPIVersion)
-
-	// Execute request
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.send(ctx, "POST", "messages/count_tokens", requestBody)
 	if err != nil {
-		return nil, fmt.Errorf("executing HTTP request: %w", err)
+		return 0, err
 	}
 	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("reading response body: %w", err)
+	}
+	if err := statusError(resp.StatusCode, body); err != nil {
+		return 0, err
+	}
 
-	// Read response body
+	var count struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.Unmarshal(body, &count); err != nil {
+		return 0, fmt.Errorf("parsing JSON response: %w", err)
+	}
+	return count.InputTokens, nil
+}
+
+// Models implements providers.ModelLister with the /models endpoint.
+func (p *Provider) Models(ctx context.Context) ([]string, error) {
+	if p.apiKey == "" {
+		return nil, fmt.Errorf("API key not configured")
+	}
+	resp, err := p.send(ctx, "GET", "models?limit=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("reading response body: %w", err)
 	}
+	if err := statusError(resp.StatusCode, body); err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("parsing JSON response: %w", err)
+	}
+	models := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
+
+// send makes an authenticated request to path (with an optional query)
+// under the API base, with payload as the JSON body when it is not nil.
+func (p *Provider) send(ctx context.Context, method, path string, payload map[string]any) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		jsonBody, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("serializing request body: %w", err)
+		}
+		body = bytes.NewReader(jsonBody)
+	}
+
+	path, query, _ := strings.Cut(path, "?")
+	endpointURL, err := url.JoinPath(p.apiBase, path)
+	if err != nil {
+		return nil, fmt.Errorf("building endpoint URL: %w", err)
+	}
+	if query != "" {
+		endpointURL += "?" + query
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpointURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-API-Key", p.apiKey) //nolint:canonicalheader // Anthropic API requires exact header name
+	req.Header.Set("Anthropic-Version", defaultAPIVersion)
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
 
-	// Check for HTTP errors with detailed messages
-	switch resp.StatusCode {
+	resp, err := p.retrier.Do(p.httpClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("executing HTTP request: %w", err)
+	}
+	return resp, nil
+}
+
+// statusError turns a failed response into an error with a detailed
+// message, or returns nil for 200 OK.
+func statusError(status int, body []byte) error {
+	switch status {
+	case http.StatusOK:
+		return nil
 	case http.StatusUnauthorized:
-		return nil, fmt.Errorf("authentication failed (401): check your API key")
+		return fmt.Errorf("authentication failed (401): check your API key")
 	case http.StatusTooManyRequests:
-		return nil, fmt.Errorf("rate limited (429): %s", string(body))
+		return fmt.Errorf("rate limited (429): %s", string(body))
 	case http.StatusBadRequest:
-		return nil, fmt.Errorf("bad request (400): %s", string(body))
+		return fmt.Errorf("bad request (400): %s", string(body))
 	case http.StatusNotFound:
-		return nil, fmt.Errorf("endpoint not found (404): %s", string(body))
+		return fmt.Errorf("endpoint not found (404): %s", string(body))
 	case http.StatusInternalServerError:
-		return nil, fmt.Errorf("internal server error (500): %s", string(body))
+		return fmt.Errorf("internal server error (500): %s", string(body))
 	case http.StatusServiceUnavailable:
-		return nil, fmt.Errorf("service unavailable (503): %s", string(body))
+		return fmt.Errorf("service unavailable (503): %s", string(body))
 	default:
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-		}
+		return fmt.Errorf("API request failed with status %d: %s", status, string(body))
 	}
-
-	// Parse response
-	return parseResponseBody(body)
 }
 
 // GetDefaultModel returns the default model for this provider.
@@ -149,6 +249,28 @@ func (p *Provider) GetDefaultModel() string {
 }
 
 // buildRequestBody converts internal message format to Anthropic Messages API format.
+// imageBlocks converts the base64 image data URLs among media to image
+// content blocks. Other media is skipped.
+func imageBlocks(media []string) []any {
+	var blocks []any
+	for _, ref := range media {
+		header, data, ok := strings.Cut(ref, ";base64,")
+		mediaType, isImage := strings.CutPrefix(header, "data:")
+		if !ok || !isImage || !strings.HasPrefix(mediaType, "image/") {
+			continue
+		}
+		blocks = append(blocks, map[string]any{
+			"type": "image",
+			"source": map[string]any{
+				"type":       "base64",
+				"media_type": mediaType,
+				"data":       data,
+			},
+		})
+	}
+	return blocks
+}
+
 func buildRequestBody(
 	messages []Message,
 	tools []ToolDefinition,
@@ -174,11 +296,24 @@ func buildRequestBody(
 
 	// Process messages
 	var systemPrompt string
+	var systemBlocks []any // set when a system message has SystemParts
 	var apiMessages []any
 
 	for _, msg := range messages {
 		switch msg.Role {
 		case "system":
+			// Structured SystemParts keep their cache_control, so the static
+			// part of the prompt is cached while the dynamic parts change
+			for _, part := range msg.SystemParts {
+				block := map[string]any{"type": "text", "text": part.Text}
+				if part.CacheControl != nil && part.CacheControl.Type == "ephemeral" {
+					block["cache_control"] = map[string]any{"type": "ephemeral"}
+				}
+				systemBlocks = append(systemBlocks, block)
+			}
+			if len(msg.SystemParts) == 0 && msg.Content != "" {
+				systemBlocks = append(systemBlocks, map[string]any{"type": "text", "text": msg.Content})
+			}
 			// Accumulate system messages
 			if systemPrompt != "" {
 				systemPrompt += "\n\n" + msg.Content
@@ -200,6 +335,16 @@ func buildRequestBody(
 					"role":    "user",
 					"content": content,
 				})
+			} else if images := imageBlocks(msg.Media); len(images) > 0 {
+				// User message with images: images first, as Anthropic recommends
+				content := images
+				if msg.Content != "" {
+					content = append(content, map[string]any{"type": "text", "text": msg.Content})
+				}
+				apiMessages = append(apiMessages, map[string]any{
+					"role":    "user",
+					"content": content,
+				})
 			} else {
 				// Regular user message
 				apiMessages = append(apiMessages, map[string]any{
@@ -260,7 +405,9 @@ func buildRequestBody(
 	result["messages"] = apiMessages
 
 	// Set system prompt if present
-	if systemPrompt != "" {
+	if hasSystemParts(messages) {
+		result["system"] = systemBlocks
+	} else if systemPrompt != "" {
 		result["system"] = systemPrompt
 	}
 
@@ -272,7 +419,18 @@ func buildRequestBody(
 	return result, nil
 }
 
-// buildTools converts tool definitions to Anthropic format.
+// hasSystemParts reports whether a system message has structured parts.
+func hasSystemParts(messages []Message) bool {
+	for _, msg := range messages {
+		if msg.Role == "system" && len(msg.SystemParts) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// buildTools converts tool definitions to Anthropic format. The last tool
+// carries a cache breakpoint, which caches all the tool schemas before it.
 func buildTools(tools []ToolDefinition) []any {
 	result := make([]any, len(tools))
 	for i, tool := range tools {
@@ -281,6 +439,9 @@ func buildTools(tools []ToolDefinition) []any {
 			"description":  tool.Function.Description,
 			"input_schema": tool.Function.Parameters,
 		}
+		if i == len(tools)-1 {
+			toolDef["cache_control"] = map[string]any{"type": "ephemeral"}
+		}
 		result[i] = toolDef
 	}
 	return result
@@ -292,7 +453,11 @@ func parseResponseBody(body []byte) (*LLMResponse, error) {
 	if err := json.Unmarshal(body, &resp); err != nil {
 		return nil, fmt.Errorf("parsing JSON response: %w", err)
 	}
+	return parseResponse(&resp), nil
+}
 
+// parseResponse converts a decoded Anthropic message to an LLMResponse.
+func parseResponse(resp *anthropicMessageResponse) *LLMResponse {
 	// Extract content and tool calls
 	var content strings.Builder
 	toolCalls := make([]ToolCall, 0) // Initialize as empty slice (not nil) for consistent JSON serialization
@@ -336,8 +501,10 @@ func parseResponseBody(body []byte) (*LLMResponse, error) {
 			PromptTokens:     int(resp.Usage.InputTokens),
 			CompletionTokens: int(resp.Usage.OutputTokens),
 			TotalTokens:      int(resp.Usage.InputTokens + resp.Usage.OutputTokens),
+			CacheReadTokens:  int(resp.Usage.CacheReadInputTokens),
+			CacheWriteTokens: int(resp.Usage.CacheCreationInputTokens),
 		},
-	}, nil
+	}
 }
 
 // normalizeBaseURL ensures the base URL is properly formatted.
@@ -416,6 +583,8 @@ type contentBlock struct {
 }
 
 type usageInfo struct {
-	InputTokens  int64 `json:"input_tokens"`
-	OutputTokens int64 `json:"output_tokens"`
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
 }
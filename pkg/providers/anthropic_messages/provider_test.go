@@ -8,9 +8,14 @@ package anthropicmessages
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
 
 func TestBuildRequestBody(t *testing.T) {
@@ -175,6 +180,7 @@ func TestBuildRequestBody(t *testing.T) {
 								},
 							},
 						},
+						"cache_control": map[string]any{"type": "ephemeral"},
 					},
 				},
 			},
@@ -620,3 +626,179 @@ func TestProviderChatErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestProviderChatStream(t *testing.T) {
+	var requestBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"usage":{"input_tokens":12,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"check."}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,` +
+				`"content_block":{"type":"tool_use","id":"toolu_1","name":"read_file","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"a.txt\"}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}`,
+			`{"type":"message_stop"}`,
+		} {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL)
+	var deltas []string
+	resp, err := p.ChatStream(context.Background(), []Message{{Role: "user", Content: "read a.txt"}}, nil,
+		"claude-sonnet-4-6", map[string]any{"max_tokens": 1024}, func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if requestBody["stream"] != true {
+		t.Errorf("stream = %v, want true", requestBody["stream"])
+	}
+	if strings.Join(deltas, "|") != "Let me |check." {
+		t.Errorf("deltas = %q", deltas)
+	}
+	if resp.Content != "Let me check." || resp.FinishReason != "tool_calls" {
+		t.Errorf("response = %q (%s)", resp.Content, resp.FinishReason)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "read_file" ||
+		resp.ToolCalls[0].Arguments["path"] != "a.txt" {
+		t.Errorf("tool calls = %+v", resp.ToolCalls)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 20 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestProviderChatStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`+"\n\n")
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL)
+	_, err := p.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil,
+		"claude-sonnet-4-6", map[string]any{"max_tokens": 1024}, nil)
+	if err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Fatalf("ChatStream() error = %v, want overloaded_error", err)
+	}
+}
+
+func TestProviderCountTokens(t *testing.T) {
+	var gotPath string
+	var requestBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		fmt.Fprint(w, `{"input_tokens":42}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL)
+	n, err := p.CountTokens(context.Background(), []Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "hi"},
+	}, nil, "claude-sonnet-4-6")
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if n != 42 {
+		t.Errorf("CountTokens() = %d, want 42", n)
+	}
+	if gotPath != "/v1/messages/count_tokens" {
+		t.Errorf("path = %s", gotPath)
+	}
+	if _, ok := requestBody["max_tokens"]; ok {
+		t.Error("count_tokens request should not carry max_tokens")
+	}
+	if requestBody["system"] != "Be brief." {
+		t.Errorf("system = %v", requestBody["system"])
+	}
+}
+
+func TestProviderModels(t *testing.T) {
+	var gotURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		if r.Header.Get("X-API-Key") != "key" { //nolint:canonicalheader
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"claude-sonnet-4-6","type":"model"},{"id":"claude-haiku-4-5","type":"model"}]}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL)
+	models, err := p.Models(context.Background())
+	if err != nil {
+		t.Fatalf("Models() error = %v", err)
+	}
+	if gotURL != "/v1/models?limit=1000" {
+		t.Errorf("url = %s", gotURL)
+	}
+	if !reflect.DeepEqual(models, []string{"claude-sonnet-4-6", "claude-haiku-4-5"}) {
+		t.Errorf("models = %v", models)
+	}
+
+	if _, err := NewProvider("wrong", server.URL).Models(context.Background()); err == nil {
+		t.Error("Models() with a bad key should fail")
+	}
+}
+
+func TestBuildRequestBody_UserMessageWithImage(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "What is this?", Media: []string{"data:image/jpeg;base64,/9j/4AAQ"}},
+	}
+	body, err := buildRequestBody(messages, nil, "claude-sonnet-4-6", map[string]any{"max_tokens": 1024})
+	if err != nil {
+		t.Fatalf("buildRequestBody() error: %v", err)
+	}
+	content, ok := body["messages"].([]any)[0].(map[string]any)["content"].([]any)
+	if !ok || len(content) != 2 {
+		t.Fatalf("content = %#v, want an image block and a text block", body["messages"])
+	}
+	image := content[0].(map[string]any)
+	source := image["source"].(map[string]any)
+	if image["type"] != "image" || source["media_type"] != "image/jpeg" || source["data"] != "/9j/4AAQ" {
+		t.Errorf("image block = %#v", image)
+	}
+	if text := content[1].(map[string]any); text["text"] != "What is this?" {
+		t.Errorf("text block = %#v", text)
+	}
+}
+
+func TestBuildRequestBody_SystemPartsAreCached(t *testing.T) {
+	messages := []Message{
+		{Role: "system", Content: "static\n\ndynamic", SystemParts: []protocoltypes.ContentBlock{
+			{Type: "text", Text: "static", CacheControl: &protocoltypes.CacheControl{Type: "ephemeral"}},
+			{Type: "text", Text: "dynamic"},
+		}},
+		{Role: "system", Content: "summary"},
+		{Role: "user", Content: "hi"},
+	}
+	body, err := buildRequestBody(messages, nil, "claude-sonnet-4-6", map[string]any{"max_tokens": 1024})
+	if err != nil {
+		t.Fatalf("buildRequestBody() error: %v", err)
+	}
+	want := []any{
+		map[string]any{"type": "text", "text": "static", "cache_control": map[string]any{"type": "ephemeral"}},
+		map[string]any{"type": "text", "text": "dynamic"},
+		map[string]any{"type": "text", "text": "summary"},
+	}
+	if !reflect.DeepEqual(body["system"], want) {
+		t.Errorf("system = %#v, want %#v", body["system"], want)
+	}
+
+	resp, err := parseResponseBody([]byte(`{"content": [{"type": "text", "text": "ok"}], "stop_reason": "end_turn",
+		"usage": {"input_tokens": 5, "output_tokens": 2, "cache_read_input_tokens": 900}}`))
+	if err != nil || resp.Usage.CacheReadTokens != 900 || resp.Usage.PromptTokens != 5 {
+		t.Errorf("parseResponseBody() = %+v, %v", resp.Usage, err)
+	}
+}
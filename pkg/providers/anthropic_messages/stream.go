@@ -0,0 +1,160 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package anthropicmessages
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxStreamLineSize bounds one server-sent event line.
+const maxStreamLineSize = 1 << 20
+
+// streamEvent is one "data:" event of a streamed message.
+type streamEvent struct {
+	Type    string                    `json:"type"`
+	Index   int                       `json:"index"`
+	Message *anthropicMessageResponse `json:"message"`
+	Block   *contentBlock             `json:"content_block"`
+	Delta   struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *usageInfo `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ChatStream implements providers.StreamingProvider. onDelta receives the
+// text of the reply as it is generated.
+func (p *Provider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	if p.apiKey == "" {
+		return nil, fmt.Errorf("API key not configured")
+	}
+	requestBody, err := buildRequestBody(messages, tools, model, options)
+	if err != nil {
+		return nil, fmt.Errorf("building request body: %w", err)
+	}
+	requestBody["stream"] = true
+
+	resp, err := p.send(ctx, "POST", "messages", requestBody)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading response body: %w", err)
+		}
+		if err := statusError(resp.StatusCode, body); err != nil {
+			return nil, err
+		}
+		out, err := parseResponseBody(body)
+		if err == nil && out.Content != "" && onDelta != nil {
+			onDelta(out.Content)
+		}
+		return out, err
+	}
+	return parseStream(resp.Body, onDelta)
+}
+
+// parseStream reads a streamed message (server-sent events) into an
+// LLMResponse.
+func parseStream(body io.Reader, onDelta func(delta string)) (*LLMResponse, error) {
+	var (
+		msg  anthropicMessageResponse
+		args = make(map[int]*strings.Builder)
+	)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxStreamLineSize)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "data:")
+		if !ok {
+			continue
+		}
+		var event streamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return nil, fmt.Errorf("parsing stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				msg.Usage = event.Message.Usage
+			}
+		case "content_block_start":
+			if event.Block == nil {
+				continue
+			}
+			for len(msg.Content) <= event.Index {
+				msg.Content = append(msg.Content, contentBlock{})
+			}
+			msg.Content[event.Index] = *event.Block
+			if event.Block.Type == "tool_use" {
+				args[event.Index] = &strings.Builder{}
+			}
+		case "content_block_delta":
+			if event.Index >= len(msg.Content) {
+				continue
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				msg.Content[event.Index].Text += event.Delta.Text
+				if onDelta != nil && event.Delta.Text != "" {
+					onDelta(event.Delta.Text)
+				}
+			case "input_json_delta":
+				if b := args[event.Index]; b != nil {
+					b.WriteString(event.Delta.PartialJSON)
+				}
+			}
+		case "message_delta":
+			if event.Delta.StopReason != "" {
+				msg.StopReason = event.Delta.StopReason
+			}
+			if event.Usage != nil {
+				msg.Usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			if event.Error != nil {
+				return nil, fmt.Errorf("stream error (%s): %s", event.Error.Type, event.Error.Message)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading stream: %w", err)
+	}
+
+	for i, b := range args {
+		if b.Len() == 0 {
+			continue
+		}
+		var input map[string]any
+		if err := json.Unmarshal([]byte(b.String()), &input); err != nil {
+			input = map[string]any{"raw": b.String()}
+		}
+		msg.Content[i].Input = input
+	}
+	return parseResponse(&msg), nil
+}
@@ -33,6 +33,7 @@ type Provider struct {
 	apiKey     string
 	apiBase    string
 	httpClient *http.Client
+	retrier    *common.Retrier
 }
 
 // Option configures the Azure Provider.
@@ -53,6 +54,7 @@ func NewProvider(apiKey, apiBase, proxy string, opts ...Option) *Provider {
 		apiKey:     apiKey,
 		apiBase:    strings.TrimRight(apiBase, "/"),
 		httpClient: common.NewHTTPClient(proxy),
+		retrier:    common.NewRetrier(common.DefaultRetryPolicy),
 	}
 
 	for _, opt := range opts {
@@ -131,7 +133,7 @@ func (p *Provider) Chat(
 		req.Header.Set("Api-Key", p.apiKey)
 	}
 
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.retrier.Do(p.httpClient, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
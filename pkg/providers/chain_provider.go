@@ -0,0 +1,301 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ChainMember is one backend of a ChainProvider.
+type ChainMember struct {
+	Name     string // Unique within the chain; keys health tracking and logs
+	Provider LLMProvider
+	Model    string // Model ID sent to this backend
+	Priority int    // Lower is tried first; equal priorities share load
+}
+
+// ChainProvider spreads requests over several backends serving the same
+// model. Members are tried in priority order, round-robin within a
+// priority. A member failing with a retriable error (429, 5xx, timeout,
+// auth) is put in cooldown and the request moves on to the next one, so
+// a flapping backend is skipped instead of being retried on every request.
+type ChainProvider struct {
+	members  []ChainMember
+	tiers    [][]int // member indexes grouped by priority, lowest first
+	models   map[string]bool
+	cooldown *CooldownTracker
+	next     atomic.Uint64
+}
+
+// NewChainProvider creates a chain over members. It panics on an empty
+// member list, which is a programming error.
+func NewChainProvider(members []ChainMember) *ChainProvider {
+	if len(members) == 0 {
+		panic("providers: NewChainProvider needs at least one member")
+	}
+	members = append([]ChainMember(nil), members...)
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].Priority < members[j].Priority
+	})
+
+	c := &ChainProvider{
+		members:  members,
+		models:   make(map[string]bool, len(members)),
+		cooldown: NewCooldownTracker(),
+	}
+	for i, m := range members {
+		c.models[m.Model] = true
+		if i == 0 || m.Priority != members[i-1].Priority {
+			c.tiers = append(c.tiers, nil)
+		}
+		c.tiers[len(c.tiers)-1] = append(c.tiers[len(c.tiers)-1], i)
+	}
+	return c
+}
+
+// Members returns the chain's backends in priority order.
+func (c *ChainProvider) Members() []ChainMember {
+	return append([]ChainMember(nil), c.members...)
+}
+
+func (c *ChainProvider) GetDefaultModel() string {
+	return c.members[0].Model
+}
+
+func (c *ChainProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	return c.run(ctx, model, func(m ChainMember, model string) (*LLMResponse, bool, error) {
+		resp, err := m.Provider.Chat(ctx, messages, tools, model, options)
+		return resp, false, err
+	})
+}
+
+// ChatStream implements StreamingProvider. Members without streaming
+// support deliver the whole reply as one delta. A member that fails after
+// it has streamed part of a reply is not failed over, since the caller
+// has already shown that text.
+func (c *ChainProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	return c.run(ctx, model, func(m ChainMember, model string) (*LLMResponse, bool, error) {
+		sp, ok := m.Provider.(StreamingProvider)
+		if !ok {
+			resp, err := m.Provider.Chat(ctx, messages, tools, model, options)
+			if err == nil && resp.Content != "" && onDelta != nil {
+				onDelta(resp.Content)
+			}
+			return resp, false, err
+		}
+		streamed := false
+		resp, err := sp.ChatStream(ctx, messages, tools, model, options, func(delta string) {
+			streamed = true
+			if onDelta != nil {
+				onDelta(delta)
+			}
+		})
+		return resp, streamed, err
+	})
+}
+
+// Embed implements Embedder over the members that have an embeddings
+// endpoint, failing over between them like Chat.
+func (c *ChainProvider) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	var (
+		lastErr   error
+		attempted int
+	)
+	for _, i := range c.order() {
+		m := c.members[i]
+		e, ok := m.Provider.(Embedder)
+		if !ok || !c.cooldown.IsAvailable(m.Name) {
+			continue
+		}
+		attempted++
+		vectors, err := e.Embed(ctx, texts, c.memberModel(m, model))
+		if err == nil {
+			c.cooldown.MarkSuccess(m.Name)
+			return vectors, nil
+		}
+		if !c.failover(ctx, m, &chainAttemptError{err: err}) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	if attempted == 0 {
+		return nil, fmt.Errorf("no backend with embeddings is available")
+	}
+	return nil, fmt.Errorf("all %d backends failed, last error: %w", attempted, lastErr)
+}
+
+// run tries members in order until one succeeds. call reports whether the
+// member already produced output, which rules out failing over.
+func (c *ChainProvider) run(
+	ctx context.Context,
+	model string,
+	call func(m ChainMember, model string) (*LLMResponse, bool, error),
+) (*LLMResponse, error) {
+	var (
+		lastErr   error
+		attempted int
+	)
+	order := c.order()
+	for _, i := range order {
+		m := c.members[i]
+		if !c.cooldown.IsAvailable(m.Name) {
+			continue
+		}
+		attempted++
+		resp, err := c.try(ctx, m, model, call)
+		if err == nil || !c.failover(ctx, m, err) {
+			return resp, unwrapAttempt(err)
+		}
+		lastErr = err
+	}
+
+	// Every member is cooling down: rather than failing outright, give the
+	// one whose cooldown ends first another chance.
+	if attempted == 0 {
+		m := c.members[order[0]]
+		for _, i := range order[1:] {
+			if c.cooldown.CooldownRemaining(c.members[i].Name) < c.cooldown.CooldownRemaining(m.Name) {
+				m = c.members[i]
+			}
+		}
+		attempted++
+		resp, err := c.try(ctx, m, model, call)
+		if err == nil || !c.failover(ctx, m, err) {
+			return resp, unwrapAttempt(err)
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("all %d backends failed, last error: %w", attempted, unwrapAttempt(lastErr))
+}
+
+// try runs call on one member. Errors returned before the member produced
+// any output are wrapped in a chainAttemptError.
+func (c *ChainProvider) try(
+	ctx context.Context,
+	m ChainMember,
+	model string,
+	call func(m ChainMember, model string) (*LLMResponse, bool, error),
+) (*LLMResponse, error) {
+	resp, streamed, err := call(m, c.memberModel(m, model))
+	if err == nil {
+		c.cooldown.MarkSuccess(m.Name)
+		return resp, nil
+	}
+	if streamed {
+		return nil, err
+	}
+	return nil, &chainAttemptError{err: err}
+}
+
+// failover reports whether err lets the request move on to the next
+// member, putting the member in cooldown if so.
+func (c *ChainProvider) failover(ctx context.Context, m ChainMember, err error) bool {
+	attemptErr, ok := err.(*chainAttemptError)
+	if !ok || ctx.Err() == context.Canceled {
+		return false
+	}
+	failErr := ClassifyError(attemptErr.err, m.Name, m.Model)
+	if failErr == nil || !failErr.IsRetriable() {
+		return false
+	}
+	c.cooldown.MarkFailure(m.Name, failErr.Reason)
+	logger.WarnCtx(ctx, "provider.chain", "Backend failed, trying the next one", map[string]any{
+		"backend":  m.Name,
+		"reason":   string(failErr.Reason),
+		"cooldown": c.cooldown.CooldownRemaining(m.Name).Round(time.Second).String(),
+		"error":    attemptErr.err.Error(),
+	})
+	return true
+}
+
+// order returns the member indexes for one request: tiers in priority
+// order, each rotated so consecutive requests start on different members.
+func (c *ChainProvider) order() []int {
+	n := c.next.Add(1) - 1
+	order := make([]int, 0, len(c.members))
+	for _, tier := range c.tiers {
+		start := int(n % uint64(len(tier)))
+		order = append(order, tier[start:]...)
+		order = append(order, tier[:start]...)
+	}
+	return order
+}
+
+// memberModel picks the model ID sent to m. Requests for the chain's own
+// model use each member's model ID; any other model (a /switch or a
+// fallback) is passed through unchanged.
+func (c *ChainProvider) memberModel(m ChainMember, model string) string {
+	if model == "" || c.models[model] {
+		return m.Model
+	}
+	return model
+}
+
+// SupportsThinking implements ThinkingCapable when every member does.
+func (c *ChainProvider) SupportsThinking() bool {
+	for _, m := range c.members {
+		if tc, ok := m.Provider.(ThinkingCapable); !ok || !tc.SupportsThinking() {
+			return false
+		}
+	}
+	return true
+}
+
+// SupportsNativeSearch implements NativeSearchCapable when every member
+// does, so the web_search tool is only hidden if no backend needs it.
+func (c *ChainProvider) SupportsNativeSearch() bool {
+	for _, m := range c.members {
+		if ns, ok := m.Provider.(NativeSearchCapable); !ok || !ns.SupportsNativeSearch() {
+			return false
+		}
+	}
+	return true
+}
+
+// Close closes the members that hold resources.
+func (c *ChainProvider) Close() {
+	for _, m := range c.members {
+		if sp, ok := m.Provider.(StatefulProvider); ok {
+			sp.Close()
+		}
+	}
+}
+
+// chainAttemptError marks an error a member returned before producing
+// output, which makes it a candidate for failover.
+type chainAttemptError struct {
+	err error
+}
+
+func (e *chainAttemptError) Error() string { return e.err.Error() }
+
+func (e *chainAttemptError) Unwrap() error { return e.err }
+
+func unwrapAttempt(err error) error {
+	if attemptErr, ok := err.(*chainAttemptError); ok {
+		return attemptErr.err
+	}
+	return err
+}
@@ -0,0 +1,297 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type stubProvider struct {
+	name   string
+	err    error
+	calls  int
+	models []string
+}
+
+func (p *stubProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	p.calls++
+	p.models = append(p.models, model)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &LLMResponse{Content: p.name}, nil
+}
+
+func (p *stubProvider) GetDefaultModel() string { return "" }
+
+type stubStreamProvider struct {
+	stubProvider
+	deltas []string
+}
+
+func (p *stubStreamProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	p.calls++
+	for _, d := range p.deltas {
+		onDelta(d)
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &LLMResponse{Content: strings.Join(p.deltas, "")}, nil
+}
+
+func TestChainProvider_FailsOverOnRetriableError(t *testing.T) {
+	primary := &stubProvider{name: "primary", err: errors.New("API request failed: status: 503")}
+	backup := &stubProvider{name: "backup"}
+	chain := NewChainProvider([]ChainMember{
+		{Name: "primary", Provider: primary, Model: "m1"},
+		{Name: "backup", Provider: backup, Model: "m2", Priority: 1},
+	})
+
+	resp, err := chain.Chat(context.Background(), nil, nil, "m1", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Content != "backup" {
+		t.Errorf("Content = %q, want backup", resp.Content)
+	}
+	if backup.models[0] != "m2" {
+		t.Errorf("backup model = %q, want its own model m2", backup.models[0])
+	}
+
+	// The primary is cooling down now, so the next request skips it.
+	if _, err := chain.Chat(context.Background(), nil, nil, "m1", nil); err != nil {
+		t.Fatalf("second Chat() error = %v", err)
+	}
+	if primary.calls != 1 {
+		t.Errorf("primary calls = %d, want 1 (skipped while cooling down)", primary.calls)
+	}
+	if backup.calls != 2 {
+		t.Errorf("backup calls = %d, want 2", backup.calls)
+	}
+}
+
+func TestChainProvider_NonRetriableErrorIsReturned(t *testing.T) {
+	primary := &stubProvider{err: errors.New("image dimensions exceed max allowed size")}
+	backup := &stubProvider{name: "backup"}
+	chain := NewChainProvider([]ChainMember{
+		{Name: "primary", Provider: primary, Model: "m"},
+		{Name: "backup", Provider: backup, Model: "m", Priority: 1},
+	})
+
+	_, err := chain.Chat(context.Background(), nil, nil, "m", nil)
+	if err == nil || err != primary.err {
+		t.Fatalf("Chat() error = %v, want the primary's error", err)
+	}
+	if backup.calls != 0 {
+		t.Errorf("backup calls = %d, want 0", backup.calls)
+	}
+}
+
+func TestChainProvider_AllFail(t *testing.T) {
+	a := &stubProvider{err: errors.New("status: 429 rate limited")}
+	b := &stubProvider{err: errors.New("status: 502 bad gateway")}
+	chain := NewChainProvider([]ChainMember{
+		{Name: "a", Provider: a, Model: "m"},
+		{Name: "b", Provider: b, Model: "m", Priority: 1},
+	})
+
+	_, err := chain.Chat(context.Background(), nil, nil, "m", nil)
+	if err == nil || !strings.Contains(err.Error(), "all 2 backends failed") || !errors.Is(err, b.err) {
+		t.Fatalf("Chat() error = %v, want both backends failed wrapping the last error", err)
+	}
+
+	// With every member cooling down, the chain still gives one a chance.
+	b.err = nil
+	a.err = nil
+	if _, err := chain.Chat(context.Background(), nil, nil, "m", nil); err != nil {
+		t.Fatalf("Chat() with all members cooling down error = %v", err)
+	}
+	if a.calls+b.calls != 3 {
+		t.Errorf("calls = %d, want 3", a.calls+b.calls)
+	}
+}
+
+func TestChainProvider_RoundRobinWithinPriority(t *testing.T) {
+	a := &stubProvider{name: "a"}
+	b := &stubProvider{name: "b"}
+	spare := &stubProvider{name: "spare"}
+	chain := NewChainProvider([]ChainMember{
+		{Name: "spare", Provider: spare, Model: "m", Priority: 5},
+		{Name: "a", Provider: a, Model: "m"},
+		{Name: "b", Provider: b, Model: "m"},
+	})
+
+	var got []string
+	for range 4 {
+		resp, err := chain.Chat(context.Background(), nil, nil, "m", nil)
+		if err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+		got = append(got, resp.Content)
+	}
+	if strings.Join(got, ",") != "a,b,a,b" {
+		t.Errorf("order = %v, want a,b,a,b", got)
+	}
+	if spare.calls != 0 {
+		t.Errorf("spare calls = %d, want 0", spare.calls)
+	}
+}
+
+func TestChainProvider_OtherModelPassesThrough(t *testing.T) {
+	p := &stubProvider{}
+	chain := NewChainProvider([]ChainMember{{Name: "p", Provider: p, Model: "m"}})
+
+	if _, err := chain.Chat(context.Background(), nil, nil, "other", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if p.models[0] != "other" {
+		t.Errorf("model = %q, want other", p.models[0])
+	}
+}
+
+func TestChainProvider_StreamDoesNotFailOverAfterOutput(t *testing.T) {
+	primary := &stubStreamProvider{
+		stubProvider: stubProvider{err: errors.New("status: 503")},
+		deltas:       []string{"Hel"},
+	}
+	backup := &stubProvider{name: "backup"}
+	chain := NewChainProvider([]ChainMember{
+		{Name: "primary", Provider: primary, Model: "m"},
+		{Name: "backup", Provider: backup, Model: "m", Priority: 1},
+	})
+
+	var out strings.Builder
+	_, err := chain.ChatStream(context.Background(), nil, nil, "m", nil, func(d string) { out.WriteString(d) })
+	if err == nil {
+		t.Fatal("ChatStream() error = nil, want the primary's error")
+	}
+	if backup.calls != 0 {
+		t.Errorf("backup calls = %d, want 0", backup.calls)
+	}
+
+	// Before any output, a streaming failure still fails over, and a
+	// non-streaming member delivers its reply as one delta.
+	primary.deltas = nil
+	chain = NewChainProvider(chain.Members())
+	out.Reset()
+	resp, err := chain.ChatStream(context.Background(), nil, nil, "m", nil, func(d string) { out.WriteString(d) })
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if resp.Content != "backup" || out.String() != "backup" {
+		t.Errorf("Content = %q, streamed %q; want backup", resp.Content, out.String())
+	}
+}
+
+func TestCreateProviderForModel_DuplicateNamesBuildChain(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ModelList = []config.ModelConfig{
+		{ModelName: "gpt", Model: "openai/gpt-5.4", APIBase: "https://backup.example.com/v1", APIKey: "k2", Priority: 1},
+		{ModelName: "gpt", Model: "openai/gpt-5.4", APIBase: "https://primary.example.com/v1", APIKey: "k1"},
+	}
+
+	provider, modelID, err := CreateProviderForModel(cfg, "gpt", t.TempDir())
+	if err != nil {
+		t.Fatalf("CreateProviderForModel() error = %v", err)
+	}
+	chain, ok := provider.(*ChainProvider)
+	if !ok {
+		t.Fatalf("provider = %T, want *ChainProvider", provider)
+	}
+	if modelID != "gpt-5.4" {
+		t.Errorf("modelID = %q, want gpt-5.4", modelID)
+	}
+	members := chain.Members()
+	if len(members) != 2 || !strings.Contains(members[0].Name, "primary.example.com") {
+		t.Errorf("members = %+v, want the priority 0 entry first", members)
+	}
+}
+
+func TestCreateProviderForModel_SingleEntry(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ModelList = []config.ModelConfig{
+		{ModelName: "gpt", Model: "openai/gpt-5.4", APIKey: "k"},
+	}
+
+	provider, _, err := CreateProviderForModel(cfg, "gpt", t.TempDir())
+	if err != nil {
+		t.Fatalf("CreateProviderForModel() error = %v", err)
+	}
+	if _, ok := provider.(*HTTPProvider); !ok {
+		t.Errorf("provider = %T, want *HTTPProvider", provider)
+	}
+	if _, _, err := CreateProviderForModel(cfg, "missing", t.TempDir()); err == nil {
+		t.Error("CreateProviderForModel(missing) error = nil, want not found")
+	}
+}
+
+type stubEmbedder struct {
+	stubProvider
+}
+
+func (p *stubEmbedder) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	p.calls++
+	p.models = append(p.models, model)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return [][]float32{{1}}, nil
+}
+
+func TestChainProvider_EmbedFailsOverToMembersThatEmbed(t *testing.T) {
+	chatOnly := &stubProvider{}
+	failing := &stubEmbedder{stubProvider{err: errors.New("status: 503")}}
+	backup := &stubEmbedder{}
+	chain := NewChainProvider([]ChainMember{
+		{Name: "chat", Provider: chatOnly, Model: "m"},
+		{Name: "failing", Provider: failing, Model: "m", Priority: 1},
+		{Name: "backup", Provider: backup, Model: "m2", Priority: 2},
+	})
+
+	vectors, err := chain.Embed(context.Background(), []string{"a"}, "m")
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if len(vectors) != 1 || failing.calls != 1 || backup.models[0] != "m2" {
+		t.Errorf("vectors = %v, failing calls = %d, backup models = %v", vectors, failing.calls, backup.models)
+	}
+	if chatOnly.calls != 0 {
+		t.Errorf("chat-only member calls = %d, want 0", chatOnly.calls)
+	}
+}
+
+func TestCreateEmbedder(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ModelList = []config.ModelConfig{
+		{ModelName: "embed", Model: "openai/text-embedding-3-small", APIKey: "k"},
+		{ModelName: "claude", Model: "anthropic-messages/claude-sonnet-4.6", APIKey: "k"},
+	}
+
+	embedder, modelID, err := CreateEmbedder(cfg, "embed", t.TempDir())
+	if err != nil {
+		t.Fatalf("CreateEmbedder() error = %v", err)
+	}
+	if _, ok := embedder.(*HTTPProvider); !ok || modelID != "text-embedding-3-small" {
+		t.Errorf("CreateEmbedder() = %T, %q", embedder, modelID)
+	}
+	if _, _, err := CreateEmbedder(cfg, "claude", t.TempDir()); err == nil {
+		t.Error("CreateEmbedder(claude) error = nil, want no embeddings support")
+	}
+}
@@ -62,7 +62,8 @@ func (p *CodexProvider) Chat(
 	accountID := p.accountID
 	resolvedModel, fallbackReason := resolveCodexModel(model)
 	if fallbackReason != "" {
-		logger.WarnCF(
+		logger.WarnCtx(
+			ctx,
 			"provider.codex",
 			"Requested model is not compatible with Codex backend, using fallback",
 			map[string]any{
@@ -85,7 +86,8 @@ func (p *CodexProvider) Chat(
 	if accountID != "" {
 		opts = append(opts, option.WithHeader("Chatgpt-Account-Id", accountID))
 	} else {
-		logger.WarnCF(
+		logger.WarnCtx(
+			ctx,
 			"provider.codex",
 			"No account id found for Codex request; backend may reject with 400",
 			map[string]any{
@@ -135,10 +137,10 @@ func (p *CodexProvider) Chat(
 				fields["hint"] = "verify account id header and model compatibility for codex backend"
 			}
 			if apiErr.Response != nil {
-				fields["request_id"] = apiErr.Response.Header.Get("x-request-id")
+				fields["upstream_request_id"] = apiErr.Response.Header.Get("x-request-id")
 			}
 		}
-		logger.ErrorCF("provider.codex", "Codex API call failed", fields)
+		logger.ErrorCtx(ctx, "provider.codex", "Codex API call failed", fields)
 		return nil, fmt.Errorf("codex API call: %w", err)
 	}
 	if resp == nil {
@@ -149,7 +151,7 @@ func (p *CodexProvider) Chat(
 			"tools_count":        len(tools),
 			"account_id_present": accountID != "",
 		}
-		logger.ErrorCF("provider.codex", "Codex stream ended without completed response event", fields)
+		logger.ErrorCtx(ctx, "provider.codex", "Codex stream ended without completed response event", fields)
 		return nil, fmt.Errorf("codex API call: stream ended without completed response")
 	}
 
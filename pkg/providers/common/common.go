@@ -18,6 +18,7 @@ import (
 	"net/url"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
@@ -159,7 +160,8 @@ func ParseResponse(body io.Reader) (*LLMResponse, error) {
 			} `json:"message"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
-		Usage *UsageInfo `json:"usage"`
+		Usage *UsageInfo      `json:"usage"`
+		Error json.RawMessage `json:"error"`
 	}
 
 	if err := json.NewDecoder(body).Decode(&apiResponse); err != nil {
@@ -167,6 +169,10 @@ func ParseResponse(body io.Reader) (*LLMResponse, error) {
 	}
 
 	if len(apiResponse.Choices) == 0 {
+		// Some gateways report upstream failures with 200 OK
+		if msg := errorText(apiResponse.Error); msg != "" {
+			return nil, fmt.Errorf("API returned an error: %s", msg)
+		}
 		return &LLMResponse{
 			Content:      "",
 			FinishReason: "stop",
@@ -255,16 +261,22 @@ func DecodeToolCallArguments(raw json.RawMessage, name string) map[string]any {
 
 // --- HTTP response helpers ---
 
+// maxErrorBodySize bounds how much of an error response is read.
+const maxErrorBodySize = 4096
+
 // HandleErrorResponse reads a non-200 response body and returns an appropriate error.
 func HandleErrorResponse(resp *http.Response, apiBase string) error {
 	contentType := resp.Header.Get("Content-Type")
-	body, readErr := io.ReadAll(io.LimitReader(resp.Body, 256))
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
 	if readErr != nil {
 		return fmt.Errorf("failed to read response: %w", readErr)
 	}
 	if LooksLikeHTML(body, contentType) {
 		return WrapHTMLResponseError(resp.StatusCode, body, contentType, apiBase)
 	}
+	if msg := ErrorMessage(body); msg != "" {
+		return fmt.Errorf("API request failed:\n  Status: %d\n  Error:  %s", resp.StatusCode, msg)
+	}
 	return fmt.Errorf(
 		"API request failed:\n  Status: %d\n  Body:   %s",
 		resp.StatusCode,
@@ -272,6 +284,78 @@ func HandleErrorResponse(resp *http.Response, apiBase string) error {
 	)
 }
 
+// ErrorMessage extracts the message of a JSON error payload, or returns ""
+// when body is not one. Servers disagree on the shape, so it accepts the
+// common ones:
+//
+//	{"error": {"message": "..."}}             OpenAI, OpenRouter, llama.cpp
+//	{"error": "..."}                          LM Studio, Ollama
+//	{"object": "error", "message": "..."}     vLLM
+//	{"detail": "..."} or {"detail": [{...}]}  FastAPI-based servers
+func ErrorMessage(body []byte) string {
+	var payload struct {
+		Error   json.RawMessage `json:"error"`
+		Detail  json.RawMessage `json:"detail"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(body), &payload); err != nil {
+		return ""
+	}
+	if msg := errorText(payload.Error); msg != "" {
+		return msg
+	}
+	if msg := errorText(payload.Detail); msg != "" {
+		return msg
+	}
+	return strings.TrimSpace(payload.Message)
+}
+
+// errorText reads an error field that may be a string, an object with a
+// message, or a list of such objects.
+func errorText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return strings.TrimSpace(text)
+	}
+
+	type errorObject struct {
+		Message  string `json:"message"`
+		Msg      string `json:"msg"`
+		Metadata struct {
+			Raw          string `json:"raw"`
+			ProviderName string `json:"provider_name"`
+		} `json:"metadata"`
+	}
+	var obj errorObject
+	if json.Unmarshal(raw, &obj) == nil {
+		msg := strings.TrimSpace(obj.Message + obj.Msg)
+		// OpenRouter passes the upstream provider's error along
+		if upstream := strings.TrimSpace(obj.Metadata.Raw); upstream != "" {
+			source := obj.Metadata.ProviderName
+			if source == "" {
+				source = "upstream"
+			}
+			msg += fmt.Sprintf(" (%s: %s)", source, ResponsePreview([]byte(upstream), 256))
+		}
+		return strings.TrimSpace(msg)
+	}
+
+	var list []errorObject
+	if json.Unmarshal(raw, &list) == nil {
+		msgs := make([]string, 0, len(list))
+		for _, o := range list {
+			if msg := strings.TrimSpace(o.Message + o.Msg); msg != "" {
+				msgs = append(msgs, msg)
+			}
+		}
+		return strings.Join(msgs, "; ")
+	}
+	return ""
+}
+
 // ReadAndParseResponse peeks at the response body to detect HTML errors,
 // then parses the JSON response into an LLMResponse.
 func ReadAndParseResponse(resp *http.Response, apiBase string) (*LLMResponse, error) {
@@ -345,6 +429,33 @@ func leadingTrimmedPrefix(body []byte, maxLen int) []byte {
 	return nil
 }
 
+// --- Token helpers ---
+
+// EstimateTokens approximates the prompt tokens of a request for backends
+// without a counting endpoint, with the 2.5 characters per token heuristic
+// the agent uses for its context budget. Tool calls and definitions count
+// as their JSON.
+func EstimateTokens(messages []Message, tools []ToolDefinition) int {
+	chars := 0
+	for _, m := range messages {
+		chars += utf8.RuneCountInString(m.Content)
+		for _, tc := range m.ToolCalls {
+			chars += utf8.RuneCountInString(tc.Name)
+			if tc.Function != nil && tc.Arguments == nil {
+				chars += utf8.RuneCountInString(tc.Function.Arguments)
+			} else if args, err := json.Marshal(tc.Arguments); err == nil {
+				chars += utf8.RuneCount(args)
+			}
+		}
+	}
+	if len(tools) > 0 {
+		if defs, err := json.Marshal(tools); err == nil {
+			chars += utf8.RuneCount(defs)
+		}
+	}
+	return (chars*2 + 4) / 5
+}
+
 // --- Numeric helpers ---
 
 // AsInt converts various numeric types to int.
@@ -556,3 +556,27 @@ func TestParseResponse_WithThoughtSignature(t *testing.T) {
 			out.ToolCalls[0].ExtraContent.Google.ThoughtSignature, "sig123")
 	}
 }
+
+// --- EstimateTokens tests ---
+
+func TestEstimateTokens_CountsContentCallsAndTools(t *testing.T) {
+	plain := EstimateTokens([]Message{{Role: "user", Content: strings.Repeat("字", 10)}}, nil)
+	if plain != 4 {
+		t.Fatalf("EstimateTokens(10 runes) = %d, want 4", plain)
+	}
+
+	withCall := EstimateTokens([]Message{{
+		Role:      "assistant",
+		Content:   strings.Repeat("字", 10),
+		ToolCalls: []ToolCall{{Name: "read_file", Arguments: map[string]any{"path": "a.txt"}}},
+	}}, nil)
+	if withCall <= plain {
+		t.Fatalf("tool call not counted: %d <= %d", withCall, plain)
+	}
+
+	tools := []ToolDefinition{{Type: "function", Function: ToolFunctionDefinition{Name: "read_file"}}}
+	withTools := EstimateTokens([]Message{{Role: "user", Content: strings.Repeat("字", 10)}}, tools)
+	if withTools <= plain {
+		t.Fatalf("tool definitions not counted: %d <= %d", withTools, plain)
+	}
+}
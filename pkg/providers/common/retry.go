@@ -0,0 +1,288 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ErrCircuitOpen is returned without contacting the provider while its
+// circuit is open after too many failed requests in a row.
+var ErrCircuitOpen = errors.New("provider unavailable: too many failed requests, circuit open")
+
+// RetryPolicy says how often a failed provider request is retried and when
+// the provider's circuit opens.
+type RetryPolicy struct {
+	MaxRetries       int           // attempts after the first one
+	BaseDelay        time.Duration // wait before the first retry, doubled for each further one
+	MaxDelay         time.Duration // longest wait; a longer Retry-After gives up instead
+	BreakerThreshold int           // failed requests in a row that open the circuit; 0 never opens it
+	BreakerCooldown  time.Duration // how long an open circuit turns requests away
+}
+
+// DefaultRetryPolicy retries twice, then fails fast for 30 seconds once five
+// requests in a row have failed.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:       2,
+	BaseDelay:        500 * time.Millisecond,
+	MaxDelay:         30 * time.Second,
+	BreakerThreshold: 5,
+	BreakerCooldown:  30 * time.Second,
+}
+
+// Retrier sends provider requests, retrying network errors and the statuses
+// of an overloaded or rate-limited server. Each provider has its own, so the
+// circuit of one failing endpoint does not hold back the others. It is safe
+// for concurrent use.
+type Retrier struct {
+	policy RetryPolicy
+	sleep  func(*http.Request, time.Duration) error // for testing
+
+	mu        sync.Mutex
+	failures  int       // failed requests in a row
+	openUntil time.Time // end of the cooldown of an open circuit
+	probing   bool      // a request is testing the circuit after its cooldown
+}
+
+// NewRetrier returns a Retrier following policy.
+func NewRetrier(policy RetryPolicy) *Retrier {
+	return &Retrier{policy: policy, sleep: sleepContext}
+}
+
+// Do sends req with client. A retryable failure is retried after an
+// exponential backoff with jitter, or the server's Retry-After, as long as
+// the request body can be replayed. The response of the last attempt is
+// returned as it came, so callers report errors as before. A nil *Retrier
+// sends req once.
+func (r *Retrier) Do(client *http.Client, req *http.Request) (*http.Response, error) {
+	if r == nil {
+		return client.Do(req)
+	}
+	stats := hostStatsFor(req.URL.Host)
+	stats.requests.Add(1)
+	if !r.allow() {
+		stats.rejected.Add(1)
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("%s: %w", req.URL.Host, ErrCircuitOpen)
+	}
+
+	attemptReq := req
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(attemptReq)
+		if req.Context().Err() != nil {
+			// Canceled by the caller, not the provider's fault
+			r.release()
+			return resp, err
+		}
+		if !failed(resp, err) {
+			r.record(req.URL.Host, true)
+			return resp, err
+		}
+
+		wait := r.backoff(attempt)
+		if after, ok := retryAfter(resp); ok {
+			wait = after
+		}
+		// A timed out attempt already took the whole request timeout, which
+		// the agent loop handles on its own
+		if attempt >= r.policy.MaxRetries || wait > r.policy.MaxDelay || timedOut(err) ||
+			(req.Body != nil && req.GetBody == nil) {
+			stats.gaveUp.Add(1)
+			r.record(req.URL.Host, false)
+			return resp, err
+		}
+		if resp != nil {
+			// Let the connection be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+			resp.Body.Close()
+		}
+		logger.WarnCF("provider", "Provider request failed, retrying", map[string]any{
+			"host":    req.URL.Host,
+			"attempt": attempt + 1,
+			"wait":    wait.String(),
+			"error":   failureText(resp, err),
+		})
+		if err := r.sleep(req, wait); err != nil {
+			r.release()
+			return nil, err
+		}
+		attemptReq = req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				r.release()
+				return nil, err
+			}
+			attemptReq.Body = body
+		}
+		stats.retries.Add(1)
+	}
+}
+
+// allow reports whether a request may be sent. Once an open circuit has
+// cooled down, one request at a time is let through to test it.
+func (r *Retrier) allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.policy.BreakerThreshold <= 0 || r.failures < r.policy.BreakerThreshold {
+		return true
+	}
+	if time.Now().Before(r.openUntil) || r.probing {
+		return false
+	}
+	r.probing = true
+	return true
+}
+
+// record counts the outcome of a request toward the circuit.
+func (r *Retrier) record(host string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wasProbing := r.probing
+	r.probing = false
+	if ok {
+		if r.failures >= r.policy.BreakerThreshold && r.policy.BreakerThreshold > 0 {
+			logger.InfoCF("provider", "Provider circuit closed", map[string]any{"host": host})
+		}
+		r.failures = 0
+		return
+	}
+	r.failures++
+	if r.policy.BreakerThreshold > 0 && r.failures >= r.policy.BreakerThreshold {
+		r.openUntil = time.Now().Add(r.policy.BreakerCooldown)
+		if r.failures == r.policy.BreakerThreshold || wasProbing {
+			logger.WarnCF("provider", "Provider circuit opened after failed requests", map[string]any{
+				"host":     host,
+				"failures": r.failures,
+				"cooldown": r.policy.BreakerCooldown.String(),
+			})
+		}
+	}
+}
+
+// release ends a request that was abandoned before its outcome was known.
+func (r *Retrier) release() {
+	r.mu.Lock()
+	r.probing = false
+	r.mu.Unlock()
+}
+
+// backoff returns the wait before retry attempt+1: the base delay doubled
+// per attempt, capped at the maximum, with the upper half jittered so
+// clients that failed together do not retry together.
+func (r *Retrier) backoff(attempt int) time.Duration {
+	d := r.policy.BaseDelay << attempt
+	if d <= 0 || d > r.policy.MaxDelay {
+		d = r.policy.MaxDelay
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
+
+func sleepContext(req *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// failed reports whether an attempt failed in a way another attempt may
+// not: a network error or an overloaded, rate-limited, or failing server.
+func failed(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+		529: // Anthropic's overloaded
+		return true
+	}
+	return false
+}
+
+func timedOut(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryAfter reads the Retry-After header, in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+func failureText(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return resp.Status
+}
+
+// RetryStats counts the requests sent to one provider host.
+type RetryStats struct {
+	Requests uint64 `json:"requests"`
+	Retries  uint64 `json:"retries"`  // attempts after the first
+	GaveUp   uint64 `json:"gave_up"`  // requests that failed after their retries
+	Rejected uint64 `json:"rejected"` // requests turned away by an open circuit
+}
+
+type hostStats struct {
+	requests, retries, gaveUp, rejected atomic.Uint64
+}
+
+var retryStats sync.Map // host -> *hostStats
+
+func hostStatsFor(host string) *hostStats {
+	if s, ok := retryStats.Load(host); ok {
+		return s.(*hostStats)
+	}
+	s, _ := retryStats.LoadOrStore(host, &hostStats{})
+	return s.(*hostStats)
+}
+
+// Stats returns the request counters of every provider host contacted since
+// the start.
+func Stats() map[string]RetryStats {
+	out := make(map[string]RetryStats)
+	retryStats.Range(func(key, value any) bool {
+		s := value.(*hostStats)
+		out[key.(string)] = RetryStats{
+			Requests: s.requests.Load(),
+			Retries:  s.retries.Load(),
+			GaveUp:   s.gaveUp.Load(),
+			Rejected: s.rejected.Load(),
+		}
+		return true
+	})
+	return out
+}
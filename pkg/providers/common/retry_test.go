@@ -0,0 +1,205 @@
+package common
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRetrier returns a retrier that records its waits instead of sleeping.
+func testRetrier(policy RetryPolicy) (*Retrier, *[]time.Duration) {
+	r := NewRetrier(policy)
+	var waits []time.Duration
+	r.sleep = func(_ *http.Request, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return r, &waits
+}
+
+func postRequest(t *testing.T, url, body string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), "POST", url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestRetrier_RetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("attempt %d body = %q, want payload", calls.Load()+1, body)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	r, waits := testRetrier(DefaultRetryPolicy)
+	resp, err := r.Do(server.Client(), postRequest(t, server.URL, "payload"))
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("status = %d after %d calls, want 200 after 3", resp.StatusCode, calls.Load())
+	}
+	if len(*waits) != 2 {
+		t.Fatalf("waits = %v, want 2", *waits)
+	}
+	for i, wait := range *waits {
+		full := DefaultRetryPolicy.BaseDelay << i
+		if wait < full/2 || wait >= full {
+			t.Errorf("wait %d = %v, want in [%v, %v)", i, wait, full/2, full)
+		}
+	}
+}
+
+func TestRetrier_HonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	r, waits := testRetrier(DefaultRetryPolicy)
+	resp, err := r.Do(server.Client(), postRequest(t, server.URL, "x"))
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+	if len(*waits) != 1 || (*waits)[0] != 3*time.Second {
+		t.Errorf("waits = %v, want [3s]", *waits)
+	}
+}
+
+func TestRetrier_GivesUp(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		wantCalls  int32
+	}{
+		{"not retryable", http.StatusBadRequest, "", 1},
+		{"retry after too long", http.StatusTooManyRequests, "3600", 1},
+		{"out of retries", http.StatusBadGateway, "", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, `{"error":"nope"}`)
+			}))
+			defer server.Close()
+
+			r, _ := testRetrier(DefaultRetryPolicy)
+			resp, err := r.Do(server.Client(), postRequest(t, server.URL, "x"))
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status || string(body) != `{"error":"nope"}` {
+				t.Errorf("got %d %q, want the last response as sent", resp.StatusCode, body)
+			}
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls.Load(), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetrier_CircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if healthy.Load() {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	policy := RetryPolicy{MaxRetries: 0, BreakerThreshold: 2, BreakerCooldown: time.Hour}
+	r, _ := testRetrier(policy)
+	for range 2 {
+		resp, err := r.Do(server.Client(), postRequest(t, server.URL, "x"))
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// Open: turned away without a call
+	_, err := r.Do(server.Client(), postRequest(t, server.URL, "x"))
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("error = %v, want ErrCircuitOpen", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", calls.Load())
+	}
+
+	// Cooled down: one request tests the circuit and closes it
+	r.mu.Lock()
+	r.openUntil = time.Now()
+	r.mu.Unlock()
+	healthy.Store(true)
+	for range 2 {
+		resp, err := r.Do(server.Client(), postRequest(t, server.URL, "x"))
+		if err != nil {
+			t.Fatalf("Do() after cooldown error = %v", err)
+		}
+		resp.Body.Close()
+	}
+	if calls.Load() != 4 {
+		t.Errorf("calls = %d, want 4", calls.Load())
+	}
+}
+
+func TestRetrier_Stats(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	r, _ := testRetrier(DefaultRetryPolicy)
+	req := postRequest(t, server.URL, "x")
+	resp, err := r.Do(server.Client(), req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+
+	got := Stats()[req.URL.Host]
+	if got.Requests != 1 || got.Retries != 1 || got.GaveUp != 0 || got.Rejected != 0 {
+		t.Errorf("stats = %+v, want 1 request with 1 retry", got)
+	}
+}
@@ -0,0 +1,151 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// maxStreamLineSize bounds one server-sent event line.
+const maxStreamLineSize = 1 << 20
+
+// streamChunk is one "data:" event of a streamed chat completion.
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			Reasoning        string `json:"reasoning"`
+			ToolCalls        []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function *struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage   *UsageInfo      `json:"usage"`
+	Error   json.RawMessage `json:"error"`
+	Object  string          `json:"object"`
+	Message string          `json:"message"`
+}
+
+// streamedToolCall collects the fragments of one tool call.
+type streamedToolCall struct {
+	id, name  string
+	arguments strings.Builder
+}
+
+// ParseStream reads a streamed chat completion (server-sent events) into an
+// LLMResponse. onDelta, when set, receives each piece of content as it
+// arrives.
+func ParseStream(body io.Reader, onDelta func(delta string)) (*LLMResponse, error) {
+	var (
+		content, reasoningContent, reasoning strings.Builder
+		finishReason                         string
+		usage                                *UsageInfo
+		calls                                = make(map[int]*streamedToolCall)
+	)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxStreamLineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			// llama.cpp reports failures mid-stream as "error: {...}"
+			if payload, isErr := strings.CutPrefix(line, "error:"); isErr {
+				if msg := ErrorMessage([]byte(payload)); msg != "" {
+					return nil, fmt.Errorf("stream error: %s", msg)
+				}
+				return nil, fmt.Errorf("stream error: %s", ResponsePreview([]byte(payload), 256))
+			}
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode stream event: %w", err)
+		}
+		if msg := errorText(chunk.Error); msg != "" {
+			return nil, fmt.Errorf("stream error: %s", msg)
+		}
+		if chunk.Object == "error" {
+			return nil, fmt.Errorf("stream error: %s", chunk.Message)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		choice := chunk.Choices[0]
+		if choice.Delta.Content != "" {
+			content.WriteString(choice.Delta.Content)
+			if onDelta != nil {
+				onDelta(choice.Delta.Content)
+			}
+		}
+		reasoningContent.WriteString(choice.Delta.ReasoningContent)
+		reasoning.WriteString(choice.Delta.Reasoning)
+		for _, tc := range choice.Delta.ToolCalls {
+			call := calls[tc.Index]
+			if call == nil {
+				call = &streamedToolCall{}
+				calls[tc.Index] = call
+			}
+			if tc.ID != "" {
+				call.id = tc.ID
+			}
+			if tc.Function != nil {
+				call.name += tc.Function.Name
+				call.arguments.WriteString(tc.Function.Arguments)
+			}
+		}
+		if choice.FinishReason != "" {
+			finishReason = choice.FinishReason
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	indexes := make([]int, 0, len(calls))
+	for i := range calls {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	toolCalls := make([]ToolCall, 0, len(calls))
+	for _, i := range indexes {
+		call := calls[i]
+		raw, _ := json.Marshal(call.arguments.String())
+		toolCalls = append(toolCalls, ToolCall{
+			ID:        call.id,
+			Name:      call.name,
+			Arguments: DecodeToolCallArguments(raw, call.name),
+		})
+	}
+
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+	return &LLMResponse{
+		Content:          content.String(),
+		ReasoningContent: reasoningContent.String(),
+		Reasoning:        reasoning.String(),
+		ToolCalls:        toolCalls,
+		FinishReason:     finishReason,
+		Usage:            usage,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStream_ContentAndUsage(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"choices":[{"delta":{"role":"assistant","content":"Hel"}}]}`,
+		``,
+		`: keep-alive`,
+		`data: {"choices":[{"delta":{"content":"lo","reasoning_content":"think"}}]}`,
+		`data: {"choices":[{"delta":{},"finish_reason":"stop"}]}`,
+		`data: {"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`,
+		`data: [DONE]`,
+		``,
+	}, "\n")
+
+	var deltas []string
+	resp, err := ParseStream(strings.NewReader(body), func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("ParseStream() error = %v", err)
+	}
+	if resp.Content != "Hello" || resp.ReasoningContent != "think" || resp.FinishReason != "stop" {
+		t.Errorf("response = %+v", resp)
+	}
+	if strings.Join(deltas, "|") != "Hel|lo" {
+		t.Errorf("deltas = %q", deltas)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 5 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestParseStream_AssemblesToolCalls(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"web_search","arguments":""}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"read_file","arguments":"{\"path\":"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"query\":"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"go\"}"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"a.txt\"}"}}]}}]}`,
+		`data: {"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+	}, "\n")
+
+	resp, err := ParseStream(strings.NewReader(body), nil)
+	if err != nil {
+		t.Fatalf("ParseStream() error = %v", err)
+	}
+	if len(resp.ToolCalls) != 2 || resp.FinishReason != "tool_calls" {
+		t.Fatalf("response = %+v", resp)
+	}
+	first, second := resp.ToolCalls[0], resp.ToolCalls[1]
+	if first.ID != "call_1" || first.Name != "web_search" || first.Arguments["query"] != "go" {
+		t.Errorf("first call = %+v", first)
+	}
+	if second.ID != "call_2" || second.Arguments["path"] != "a.txt" {
+		t.Errorf("second call = %+v", second)
+	}
+}
+
+func TestParseStream_ErrorEvent(t *testing.T) {
+	body := "data: {\"error\":{\"message\":\"overloaded\"}}\n"
+	if _, err := ParseStream(strings.NewReader(body), nil); err == nil || !strings.Contains(err.Error(), "overloaded") {
+		t.Errorf("err = %v, want the stream error", err)
+	}
+}
@@ -2,8 +2,11 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"regexp"
 	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/providers/common"
 )
 
 // Common patterns in Go HTTP error messages
@@ -123,6 +126,16 @@ func ClassifyError(err error, provider, model string) *FailoverError {
 		}
 	}
 
+	// Open provider circuit: the provider keeps failing, try the next one.
+	if errors.Is(err, common.ErrCircuitOpen) {
+		return &FailoverError{
+			Reason:   FailoverOverloaded,
+			Provider: provider,
+			Model:    model,
+			Wrapped:  err,
+		}
+	}
+
 	msg := strings.ToLower(err.Error())
 
 	// Image dimension/size errors: non-retriable, non-fallback.
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers/common"
 )
 
 func TestClassifyError_Nil(t *testing.T) {
@@ -31,6 +33,17 @@ func TestClassifyError_ContextDeadlineExceeded(t *testing.T) {
 	}
 }
 
+func TestClassifyError_CircuitOpen(t *testing.T) {
+	err := fmt.Errorf("failed to send request: %w", fmt.Errorf("api.example.com: %w", common.ErrCircuitOpen))
+	result := ClassifyError(err, "openai", "gpt-4")
+	if result == nil {
+		t.Fatal("expected non-nil for an open circuit")
+	}
+	if result.Reason != FailoverOverloaded {
+		t.Errorf("reason = %q, want overloaded", result.Reason)
+	}
+}
+
 func TestClassifyError_StatusCodes(t *testing.T) {
 	tests := []struct {
 		status int
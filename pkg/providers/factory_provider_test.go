@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers/ollama"
 )
 
 func TestExtractProtocol(t *testing.T) {
@@ -443,3 +444,51 @@ func TestCreateProviderFromConfig_AzureMissingAPIBase(t *testing.T) {
 		t.Fatal("CreateProviderFromConfig() expected error for missing API base")
 	}
 }
+
+func TestCreateProviderFromConfig_OllamaNative(t *testing.T) {
+	cfg := &config.ModelConfig{
+		ModelName: "qwen",
+		Model:     "ollama-native/qwen2.5:14b",
+		KeepAlive: "30m",
+		AutoPull:  true,
+	}
+
+	provider, modelID, err := CreateProviderFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, ok := provider.(*ollama.Provider); !ok {
+		t.Fatalf("provider = %T, want *ollama.Provider", provider)
+	}
+	if modelID != "qwen2.5:14b" {
+		t.Errorf("modelID = %q, want %q", modelID, "qwen2.5:14b")
+	}
+}
+
+func TestCreateProviderFromConfig_HeadersPropagation(t *testing.T) {
+	var title string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		title = r.Header.Get("X-Title")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.ModelConfig{
+		ModelName: "lm-studio",
+		Model:     "openai/qwen2.5-7b-instruct",
+		APIBase:   server.URL,
+		Headers:   map[string]string{"X-Title": "picoclaw"},
+	}
+
+	provider, modelID, err := CreateProviderFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, err := provider.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, modelID, nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if title != "picoclaw" {
+		t.Fatalf("X-Title = %q, want %q", title, "picoclaw")
+	}
+}
@@ -42,6 +42,14 @@ func NewHTTPProviderWithMaxTokensFieldAndRequestTimeout(
 	}
 }
 
+// NewHTTPProviderWithOptions creates an OpenAI-compatible provider with any
+// of the openai_compat options.
+func NewHTTPProviderWithOptions(apiKey, apiBase, proxy string, opts ...openai_compat.Option) *HTTPProvider {
+	return &HTTPProvider{
+		delegate: openai_compat.NewProvider(apiKey, apiBase, proxy, opts...),
+	}
+}
+
 func (p *HTTPProvider) Chat(
 	ctx context.Context,
 	messages []Message,
@@ -52,6 +60,34 @@ func (p *HTTPProvider) Chat(
 	return p.delegate.Chat(ctx, messages, tools, model, options)
 }
 
+func (p *HTTPProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	return p.delegate.ChatStream(ctx, messages, tools, model, options, onDelta)
+}
+
+func (p *HTTPProvider) CountTokens(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+) (int, error) {
+	return p.delegate.CountTokens(ctx, messages, tools, model)
+}
+
+func (p *HTTPProvider) Models(ctx context.Context) ([]string, error) {
+	return p.delegate.Models(ctx)
+}
+
+func (p *HTTPProvider) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	return p.delegate.Embed(ctx, texts, model)
+}
+
 func (p *HTTPProvider) GetDefaultModel() string {
 	return ""
 }
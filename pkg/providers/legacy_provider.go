@@ -9,6 +9,7 @@ import (
 	"fmt"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
 // CreateProvider creates a provider based on the configuration.
@@ -40,22 +41,81 @@ func CreateProvider(cfg *config.Config) (LLMProvider, string, error) {
 		return nil, "", fmt.Errorf("no providers configured. Please add entries to model_list in your config")
 	}
 
-	// Get model config from model_list
-	modelCfg, err := cfg.GetModelConfig(model)
+	provider, modelID, err := CreateProviderForModel(cfg, model, cfg.WorkspacePath())
 	if err != nil {
-		return nil, "", fmt.Errorf("model %q not found in model_list: %w", model, err)
+		return nil, "", err
 	}
+	return provider, modelID, nil
+}
 
-	// Inject global workspace if not set in model config
-	if modelCfg.Workspace == "" {
-		modelCfg.Workspace = cfg.WorkspacePath()
+// CreateProviderForModel creates the provider for the model_list entry named
+// modelName, injecting workspace into entries that set none. When several
+// entries share the name, it returns a ChainProvider that balances and fails
+// over between them; entries that cannot be created are left out of it.
+func CreateProviderForModel(cfg *config.Config, modelName, workspace string) (LLMProvider, string, error) {
+	matches := cfg.GetModelConfigs(modelName)
+	if len(matches) == 0 {
+		_, err := cfg.GetModelConfig(modelName)
+		return nil, "", fmt.Errorf("model %q not found in model_list: %w", modelName, err)
 	}
 
-	// Use factory to create provider
-	provider, modelID, err := CreateProviderFromConfig(modelCfg)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to create provider for model %q: %w", model, err)
+	var (
+		members []ChainMember
+		lastErr error
+	)
+	for i := range matches {
+		modelCfg := matches[i]
+		if modelCfg.Workspace == "" {
+			modelCfg.Workspace = workspace
+		}
+		provider, modelID, err := CreateProviderFromConfig(&modelCfg)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to create provider for model %q: %w", modelName, err)
+			if len(matches) > 1 {
+				logger.WarnCF("provider.chain", "Skipping model_list entry", map[string]any{
+					"model_name": modelName,
+					"index":      i,
+					"error":      err.Error(),
+				})
+			}
+			continue
+		}
+		if len(matches) == 1 {
+			return provider, modelID, nil
+		}
+		name := fmt.Sprintf("%s#%d", modelName, i+1)
+		if modelCfg.APIBase != "" {
+			name += " (" + modelCfg.APIBase + ")"
+		}
+		members = append(members, ChainMember{
+			Name:     name,
+			Provider: provider,
+			Model:    modelID,
+			Priority: modelCfg.Priority,
+		})
 	}
+	if len(members) == 0 {
+		return nil, "", lastErr
+	}
+	chain := NewChainProvider(members)
+	return chain, chain.GetDefaultModel(), nil
+}
 
-	return provider, modelID, nil
+// CreateEmbedder creates an embeddings client for the model_list entry named
+// modelName, such as one for openai/text-embedding-3-small or
+// ollama-native/nomic-embed-text. It fails if the entry's protocol has no
+// embeddings endpoint.
+func CreateEmbedder(cfg *config.Config, modelName, workspace string) (Embedder, string, error) {
+	provider, modelID, err := CreateProviderForModel(cfg, modelName, workspace)
+	if err != nil {
+		return nil, "", err
+	}
+	embedder, ok := provider.(Embedder)
+	if !ok {
+		if sp, ok := provider.(StatefulProvider); ok {
+			sp.Close()
+		}
+		return nil, "", fmt.Errorf("model %q does not support embeddings", modelName)
+	}
+	return embedder, modelID, nil
 }
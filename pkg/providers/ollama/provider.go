@@ -0,0 +1,493 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+// Package ollama implements Ollama's native HTTP API (/api/chat), which
+// unlike its OpenAI-compatible endpoint supports keep_alive and pulling
+// models.
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers/common"
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+	"github.com/sipeed/picoclaw/pkg/requestid"
+)
+
+type (
+	ToolCall       = protocoltypes.ToolCall
+	FunctionCall   = protocoltypes.FunctionCall
+	LLMResponse    = protocoltypes.LLMResponse
+	UsageInfo      = protocoltypes.UsageInfo
+	Message        = protocoltypes.Message
+	ToolDefinition = protocoltypes.ToolDefinition
+)
+
+const (
+	DefaultAPIBase = "http://localhost:11434"
+
+	// maxStreamLineSize bounds one streamed JSON line.
+	maxStreamLineSize = 1 << 20
+)
+
+// callSeq numbers tool calls, which Ollama returns without IDs.
+var callSeq atomic.Uint64
+
+// Provider talks to an Ollama server.
+type Provider struct {
+	apiBase    string
+	keepAlive  string
+	autoPull   bool
+	httpClient *http.Client
+	// pullClient has no timeout: pulls take as long as the download.
+	pullClient *http.Client
+	retrier    *common.Retrier
+}
+
+type Option func(*Provider)
+
+// WithKeepAlive sets how long the server keeps the model loaded after a
+// request, as an Ollama duration ("10m", "1h", "-1" for ever, "0" to unload
+// at once). Empty leaves the server default.
+func WithKeepAlive(keepAlive string) Option {
+	return func(p *Provider) {
+		p.keepAlive = keepAlive
+	}
+}
+
+// WithAutoPull makes the provider pull a model the server does not have
+// and retry, instead of failing.
+func WithAutoPull(autoPull bool) Option {
+	return func(p *Provider) {
+		p.autoPull = autoPull
+	}
+}
+
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(p *Provider) {
+		if timeout > 0 {
+			p.httpClient.Timeout = timeout
+		}
+	}
+}
+
+// NewProvider creates a provider for the server at apiBase, by default
+// http://localhost:11434. A trailing /v1 (the OpenAI-compatible path) is
+// dropped.
+func NewProvider(apiBase, proxy string, opts ...Option) *Provider {
+	p := &Provider{
+		apiBase:    normalizeBaseURL(apiBase),
+		httpClient: common.NewHTTPClient(proxy),
+		retrier:    common.NewRetrier(common.DefaultRetryPolicy),
+		pullClient: common.NewHTTPClient(proxy),
+	}
+	p.pullClient.Timeout = 0
+	for _, opt := range opts {
+		if opt != nil {
+			opt(p)
+		}
+	}
+	return p
+}
+
+func (p *Provider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	return p.chat(ctx, messages, tools, model, options, false, nil)
+}
+
+// ChatStream implements providers.StreamingProvider.
+func (p *Provider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	return p.chat(ctx, messages, tools, model, options, true, onDelta)
+}
+
+func (p *Provider) chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	stream bool,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	model = strings.TrimPrefix(model, "ollama/")
+	body := p.requestBody(messages, tools, model, options, stream)
+
+	resp, err := p.postModel(ctx, "/api/chat", model, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, readError(resp))
+	}
+	return parseStream(resp.Body, onDelta)
+}
+
+// Embed implements providers.Embedder with /api/embed.
+func (p *Provider) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	model = strings.TrimPrefix(model, "ollama/")
+	body := map[string]any{"model": model, "input": texts}
+	if p.keepAlive != "" {
+		body["keep_alive"] = p.keepAlive
+	}
+
+	resp, err := p.postModel(ctx, "/api/embed", model, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, readError(resp))
+	}
+
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse embeddings: %w", err)
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d inputs", len(result.Embeddings), len(texts))
+	}
+	return result.Embeddings, nil
+}
+
+// postModel posts a request for model, pulling the model first and trying
+// again if the server lacks it and auto_pull is on.
+func (p *Provider) postModel(ctx context.Context, path, model string, body map[string]any) (*http.Response, error) {
+	resp, err := p.post(ctx, p.httpClient, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound && p.autoPull {
+		msg := readError(resp)
+		resp.Body.Close()
+		if !strings.Contains(msg, "not found") {
+			return nil, fmt.Errorf("API request failed with status 404: %s", msg)
+		}
+		if err := p.Pull(ctx, model); err != nil {
+			return nil, err
+		}
+		return p.post(ctx, p.httpClient, path, body)
+	}
+	return resp, nil
+}
+
+// Pull downloads model to the server and waits until it is ready.
+func (p *Provider) Pull(ctx context.Context, model string) error {
+	logger.InfoCF("ollama", "Pulling model", map[string]any{"model": model})
+	resp, err := p.post(ctx, p.pullClient, "/api/pull", map[string]any{"model": model, "stream": false})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pulling %s failed with status %d: %s", model, resp.StatusCode, readError(resp))
+	}
+
+	var status struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("parsing pull response: %w", err)
+	}
+	if status.Error != "" {
+		return fmt.Errorf("pulling %s: %s", model, status.Error)
+	}
+	logger.InfoCF("ollama", "Model pulled", map[string]any{"model": model, "status": status.Status})
+	return nil
+}
+
+// CountTokens implements providers.TokenCounter. Ollama has no counting
+// endpoint, so this is an estimate.
+func (p *Provider) CountTokens(
+	_ context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	_ string,
+) (int, error) {
+	return common.EstimateTokens(messages, tools), nil
+}
+
+// Models implements providers.ModelLister with the locally available
+// models from /api/tags.
+func (p *Provider) Models(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.apiBase+"/api/tags", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := p.retrier.Do(p.httpClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, readError(resp))
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("failed to parse model list: %w", err)
+	}
+	models := make([]string, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		models = append(models, m.Name)
+	}
+	return models, nil
+}
+
+func (p *Provider) GetDefaultModel() string {
+	return ""
+}
+
+func (p *Provider) requestBody(
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	stream bool,
+) map[string]any {
+	body := map[string]any{
+		"model":    model,
+		"messages": serializeMessages(messages),
+		"stream":   stream,
+	}
+	if len(tools) > 0 {
+		body["tools"] = tools
+	}
+	if p.keepAlive != "" {
+		body["keep_alive"] = p.keepAlive
+	}
+	// A schema constrains the whole reply, which would rule out tool calls
+	if schema, ok := options["response_schema"].(map[string]any); ok && len(tools) == 0 {
+		body["format"] = schema
+	}
+
+	modelOptions := map[string]any{}
+	if maxTokens, ok := common.AsInt(options["max_tokens"]); ok {
+		modelOptions["num_predict"] = maxTokens
+	}
+	if temperature, ok := common.AsFloat(options["temperature"]); ok {
+		modelOptions["temperature"] = temperature
+	}
+	if len(modelOptions) > 0 {
+		body["options"] = modelOptions
+	}
+	return body
+}
+
+func (p *Provider) post(
+	ctx context.Context,
+	client *http.Client,
+	path string,
+	body map[string]any,
+) (*http.Response, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.apiBase+path, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+
+	resp, err := p.retrier.Do(client, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+type ollamaFunction struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+type ollamaToolCall struct {
+	Function ollamaFunction `json:"function"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+// serializeMessages converts messages to Ollama's format: images as bare
+// base64 and tool results named after the call they answer.
+func serializeMessages(messages []Message) []ollamaMessage {
+	toolNames := make(map[string]string)
+	out := make([]ollamaMessage, 0, len(messages))
+	for _, m := range messages {
+		msg := ollamaMessage{Role: m.Role, Content: m.Content}
+		for _, media := range m.Media {
+			if !strings.HasPrefix(media, "data:image/") {
+				continue
+			}
+			if _, data, ok := strings.Cut(media, ";base64,"); ok {
+				msg.Images = append(msg.Images, data)
+			}
+		}
+		for _, tc := range m.ToolCalls {
+			name, args := tc.Name, tc.Arguments
+			if tc.Function != nil {
+				if name == "" {
+					name = tc.Function.Name
+				}
+				if args == nil && tc.Function.Arguments != "" {
+					_ = json.Unmarshal([]byte(tc.Function.Arguments), &args)
+				}
+			}
+			if args == nil {
+				args = map[string]any{}
+			}
+			toolNames[tc.ID] = name
+			msg.ToolCalls = append(msg.ToolCalls, ollamaToolCall{Function: ollamaFunction{Name: name, Arguments: args}})
+		}
+		if m.ToolCallID != "" {
+			msg.Role = "tool"
+			msg.ToolName = toolNames[m.ToolCallID]
+		}
+		out = append(out, msg)
+	}
+	return out
+}
+
+// chatChunk is a /api/chat response, or one line of a streamed one.
+type chatChunk struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// parseStream reads a /api/chat response, either a single JSON object or
+// newline-delimited chunks, into an LLMResponse.
+func parseStream(body io.Reader, onDelta func(delta string)) (*LLMResponse, error) {
+	var (
+		content, thinking strings.Builder
+		toolCalls         []ToolCall
+		last              chatChunk
+	)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxStreamLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk chatChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+
+		content.WriteString(chunk.Message.Content)
+		thinking.WriteString(chunk.Message.Thinking)
+		if onDelta != nil && chunk.Message.Content != "" {
+			onDelta(chunk.Message.Content)
+		}
+		for _, tc := range chunk.Message.ToolCalls {
+			args, _ := json.Marshal(tc.Function.Arguments)
+			toolCalls = append(toolCalls, ToolCall{
+				ID:        fmt.Sprintf("call_ollama_%d", callSeq.Add(1)),
+				Type:      "function",
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+				Function:  &FunctionCall{Name: tc.Function.Name, Arguments: string(args)},
+			})
+		}
+		if chunk.Done {
+			last = chunk
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	finishReason := "stop"
+	switch {
+	case len(toolCalls) > 0:
+		finishReason = "tool_calls"
+	case last.DoneReason == "length":
+		finishReason = "length"
+	}
+	return &LLMResponse{
+		Content:      content.String(),
+		Reasoning:    thinking.String(),
+		ToolCalls:    toolCalls,
+		FinishReason: finishReason,
+		Usage: &UsageInfo{
+			PromptTokens:     last.PromptEvalCount,
+			CompletionTokens: last.EvalCount,
+			TotalTokens:      last.PromptEvalCount + last.EvalCount,
+		},
+	}, nil
+}
+
+// readError returns the message of an error response, which Ollama sends
+// as {"error": "..."}.
+func readError(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Error != "" {
+		return payload.Error
+	}
+	return common.ResponsePreview(body, 256)
+}
+
+func normalizeBaseURL(apiBase string) string {
+	base := strings.TrimRight(strings.TrimSpace(apiBase), "/")
+	base = strings.TrimSuffix(base, "/v1")
+	if base == "" {
+		return DefaultAPIBase
+	}
+	return base
+}
@@ -0,0 +1,230 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package ollama
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeBaseURL(t *testing.T) {
+	tests := map[string]string{
+		"":                           DefaultAPIBase,
+		"http://localhost:11434/v1":  "http://localhost:11434",
+		"http://gpu-box:11434/":      "http://gpu-box:11434",
+		" http://gpu-box:11434/v1/ ": "http://gpu-box:11434",
+	}
+	for in, want := range tests {
+		if got := normalizeBaseURL(in); got != want {
+			t.Errorf("normalizeBaseURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestProviderChat_SendsNativeRequest(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %s, want /api/chat", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"Hello!"},"done":true,"done_reason":"stop",`+
+			`"prompt_eval_count":9,"eval_count":3}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL+"/v1", "", WithKeepAlive("30m"))
+	resp, err := p.Chat(t.Context(), []Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "hi", Media: []string{"data:image/png;base64,iVBORw0K"}},
+	}, nil, "ollama/llama3.2", map[string]any{
+		"max_tokens":      256,
+		"temperature":     0.2,
+		"response_schema": map[string]any{"type": "object"},
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Content != "Hello!" || resp.FinishReason != "stop" {
+		t.Errorf("response = %q (%s)", resp.Content, resp.FinishReason)
+	}
+	if resp.Usage.PromptTokens != 9 || resp.Usage.CompletionTokens != 3 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+
+	if body["model"] != "llama3.2" || body["stream"] != false || body["keep_alive"] != "30m" {
+		t.Errorf("request = %v", body)
+	}
+	opts, _ := body["options"].(map[string]any)
+	if opts["num_predict"] != float64(256) || opts["temperature"] != 0.2 {
+		t.Errorf("options = %v", opts)
+	}
+	if format, _ := body["format"].(map[string]any); format["type"] != "object" {
+		t.Errorf("format = %v", body["format"])
+	}
+	msgs, _ := body["messages"].([]any)
+	user, _ := msgs[1].(map[string]any)
+	if images, _ := user["images"].([]any); len(images) != 1 || images[0] != "iVBORw0K" {
+		t.Errorf("images = %v", user["images"])
+	}
+}
+
+func TestProviderChatStream_StreamsContentAndToolCalls(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Let me "},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"look."},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"",`+
+			`"tool_calls":[{"function":{"name":"read_file","arguments":{"path":"a.txt"}}}]},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop",`+
+			`"prompt_eval_count":20,"eval_count":7}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL, "")
+	var deltas []string
+	resp, err := p.ChatStream(t.Context(), []Message{{Role: "user", Content: "read a.txt"}}, nil, "qwen3", nil,
+		func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if body["stream"] != true {
+		t.Errorf("stream = %v, want true", body["stream"])
+	}
+	if strings.Join(deltas, "|") != "Let me |look." {
+		t.Errorf("deltas = %q", deltas)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "read_file" || resp.ToolCalls[0].ID == "" {
+		t.Fatalf("tool calls = %+v", resp.ToolCalls)
+	}
+	if resp.ToolCalls[0].Function.Arguments != `{"path":"a.txt"}` || resp.FinishReason != "tool_calls" {
+		t.Errorf("tool call = %+v, finish = %s", resp.ToolCalls[0].Function, resp.FinishReason)
+	}
+}
+
+func TestProviderChatStream_ReportsStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hi"},"done":false}`)
+		fmt.Fprintln(w, `{"error":"model runner has unexpectedly stopped"}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL, "")
+	_, err := p.ChatStream(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "qwen3", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "unexpectedly stopped") {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+}
+
+func TestSerializeMessages_NamesToolResults(t *testing.T) {
+	msgs := serializeMessages([]Message{
+		{Role: "assistant", ToolCalls: []ToolCall{{
+			ID:       "call_1",
+			Function: &FunctionCall{Name: "read_file", Arguments: `{"path":"a.txt"}`},
+		}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "contents"},
+	})
+	if got := msgs[0].ToolCalls[0].Function; got.Name != "read_file" || got.Arguments["path"] != "a.txt" {
+		t.Errorf("tool call = %+v", got)
+	}
+	if msgs[1].Role != "tool" || msgs[1].ToolName != "read_file" {
+		t.Errorf("tool result = %+v", msgs[1])
+	}
+}
+
+func TestProviderChat_PullsMissingModel(t *testing.T) {
+	pulled := false
+	var pullBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/pull":
+			json.NewDecoder(r.Body).Decode(&pullBody)
+			pulled = true
+			fmt.Fprint(w, `{"status":"success"}`)
+		case "/api/chat":
+			if !pulled {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error":"model \"gemma3\" not found, try pulling it first"}`)
+				return
+			}
+			fmt.Fprint(w, `{"message":{"role":"assistant","content":"ready"},"done":true}`)
+		}
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL, "", WithAutoPull(true))
+	resp, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gemma3", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Content != "ready" || pullBody["model"] != "gemma3" {
+		t.Errorf("content = %q, pull = %v", resp.Content, pullBody)
+	}
+}
+
+func TestProviderChat_MissingModelWithoutAutoPull(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/pull" {
+			t.Error("model pulled without auto_pull")
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model \"gemma3\" not found, try pulling it first"}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider(server.URL, "")
+	_, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gemma3", nil)
+	if err == nil || !strings.Contains(err.Error(), "try pulling it first") {
+		t.Fatalf("Chat() error = %v", err)
+	}
+}
+
+func TestProviderModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Errorf("path = %s, want /api/tags", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"models":[{"name":"llama3.2:latest"},{"name":"qwen3:8b"}]}`)
+	}))
+	defer server.Close()
+
+	models, err := NewProvider(server.URL, "").Models(t.Context())
+	if err != nil {
+		t.Fatalf("Models() error = %v", err)
+	}
+	if strings.Join(models, ",") != "llama3.2:latest,qwen3:8b" {
+		t.Errorf("models = %v", models)
+	}
+}
+
+func TestProviderEmbed(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			t.Errorf("path = %s, want /api/embed", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"model":"nomic-embed-text","embeddings":[[0.1,0.2],[0.3,0.4]]}`)
+	}))
+	defer server.Close()
+
+	vectors, err := NewProvider(server.URL, "").Embed(t.Context(), []string{"a", "b"}, "ollama/nomic-embed-text")
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if body["model"] != "nomic-embed-text" {
+		t.Errorf("model = %v, want nomic-embed-text", body["model"])
+	}
+	if len(vectors) != 2 || vectors[1][1] != float32(0.4) {
+		t.Errorf("vectors = %v", vectors)
+	}
+}
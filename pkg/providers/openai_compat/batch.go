@@ -0,0 +1,210 @@
+package openai_compat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers/common"
+)
+
+// defaultBatchPoll is how often a pending batch is checked. Batches take
+// minutes to hours, so there is no point in asking more often.
+const defaultBatchPoll = 30 * time.Second
+
+// WithBatch lets requests whose options carry "batch": true go through the
+// Batch API (https://platform.openai.com/docs/guides/batch), which costs half
+// as much but answers within 24 hours rather than seconds. Only callers that
+// can wait, such as background summaries, set the option.
+func WithBatch(enabled bool) Option {
+	return func(p *Provider) {
+		p.batch = enabled
+	}
+}
+
+// batchObject is the part of a batch the provider looks at.
+type batchObject struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	OutputFileID string `json:"output_file_id"`
+	ErrorFileID  string `json:"error_file_id"`
+}
+
+// batchResult is one line of a batch's output or error file.
+type batchResult struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int             `json:"status_code"`
+		Body       json.RawMessage `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// chatBatch sends one chat completion as a batch of one and waits for it.
+// When ctx ends first the batch is cancelled.
+func (p *Provider) chatBatch(ctx context.Context, requestBody map[string]any) (*LLMResponse, error) {
+	line, err := json.Marshal(map[string]any{
+		"custom_id": "picoclaw",
+		"method":    "POST",
+		"url":       "/v1/chat/completions",
+		"body":      requestBody,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	fileID, err := p.uploadBatchFile(ctx, line)
+	if err != nil {
+		return nil, err
+	}
+
+	var batch batchObject
+	if err := p.batchCall(ctx, "POST", "/batches", map[string]any{
+		"input_file_id":     fileID,
+		"endpoint":          "/v1/chat/completions",
+		"completion_window": "24h",
+	}, &batch); err != nil {
+		return nil, fmt.Errorf("create batch: %w", err)
+	}
+
+	poll := p.batchPoll
+	if poll <= 0 {
+		poll = defaultBatchPoll
+	}
+	for batch.Status != "completed" {
+		switch batch.Status {
+		case "failed", "expired", "cancelled":
+			return nil, fmt.Errorf("batch %s %s", batch.ID, batch.Status)
+		}
+		select {
+		case <-ctx.Done():
+			p.cancelBatch(batch.ID)
+			return nil, ctx.Err()
+		case <-time.After(poll):
+		}
+		if err := p.batchCall(ctx, "GET", "/batches/"+batch.ID, nil, &batch); err != nil {
+			if ctx.Err() != nil {
+				p.cancelBatch(batch.ID)
+			}
+			return nil, fmt.Errorf("check batch: %w", err)
+		}
+	}
+
+	result, err := p.batchResult(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("batch request failed: %s", result.Error.Message)
+	}
+	if result.Response == nil {
+		return nil, fmt.Errorf("batch %s has no response", batch.ID)
+	}
+	if result.Response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("batch request failed with status %d: %s",
+			result.Response.StatusCode, common.ErrorMessage(result.Response.Body))
+	}
+	return common.ParseResponse(bytes.NewReader(result.Response.Body))
+}
+
+// uploadBatchFile uploads the JSON Lines input of a batch.
+func (p *Provider) uploadBatchFile(ctx context.Context, line []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("purpose", "batch")
+	part, err := form.CreateFormFile("file", "picoclaw-batch.jsonl")
+	if err != nil {
+		return "", err
+	}
+	part.Write(append(line, '\n'))
+	form.Close()
+
+	req, err := p.newRequest(ctx, "POST", "/files", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := p.doBatch(req, &file); err != nil {
+		return "", fmt.Errorf("upload batch input: %w", err)
+	}
+	return file.ID, nil
+}
+
+// batchResult returns the line of the completed batch's output, or of its
+// error file when the request failed.
+func (p *Provider) batchResult(ctx context.Context, batch batchObject) (batchResult, error) {
+	var result batchResult
+	fileID := batch.OutputFileID
+	if fileID == "" {
+		fileID = batch.ErrorFileID
+	}
+	if fileID == "" {
+		return result, fmt.Errorf("batch %s completed without output", batch.ID)
+	}
+	req, err := p.newRequest(ctx, "GET", "/files/"+fileID+"/content", nil)
+	if err != nil {
+		return result, err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("download batch output: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return result, common.HandleErrorResponse(resp, p.apiBase)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("decode batch output: %w", err)
+	}
+	return result, nil
+}
+
+// cancelBatch asks the server to stop a batch nobody waits for anymore, so
+// it is not billed. It gets its own short deadline since ctx has ended.
+func (p *Provider) cancelBatch(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	p.batchCall(ctx, "POST", "/batches/"+id+"/cancel", nil, &batchObject{})
+}
+
+// batchCall sends a JSON request to the Batch API and decodes the reply.
+func (p *Provider) batchCall(ctx context.Context, method, path string, payload, out any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := p.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return p.doBatch(req, out)
+}
+
+func (p *Provider) doBatch(req *http.Request, out any) error {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return common.HandleErrorResponse(resp, p.apiBase)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,125 @@
+package openai_compat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchServer fakes the Files and Batch APIs. A batch completes on the
+// second check unless hold is set.
+type batchServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	input     map[string]any // the line uploaded, decoded
+	checks    int
+	hold      bool
+	cancelled bool
+	chats     int
+}
+
+func newBatchServer(t *testing.T) *batchServer {
+	b := &batchServer{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/files":
+			if r.FormValue("purpose") != "batch" {
+				http.Error(w, "bad purpose", http.StatusBadRequest)
+				return
+			}
+			f, _, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(f)
+			json.Unmarshal(data, &b.input)
+			io.WriteString(w, `{"id": "file-in"}`)
+		case r.Method == "POST" && r.URL.Path == "/batches":
+			io.WriteString(w, `{"id": "batch-1", "status": "validating"}`)
+		case r.Method == "GET" && r.URL.Path == "/batches/batch-1":
+			b.checks++
+			if b.hold || b.checks < 2 {
+				io.WriteString(w, `{"id": "batch-1", "status": "in_progress"}`)
+				return
+			}
+			io.WriteString(w, `{"id": "batch-1", "status": "completed", "output_file_id": "file-out"}`)
+		case r.Method == "POST" && r.URL.Path == "/batches/batch-1/cancel":
+			b.cancelled = true
+			io.WriteString(w, `{"id": "batch-1", "status": "cancelling"}`)
+		case r.Method == "GET" && r.URL.Path == "/files/file-out/content":
+			io.WriteString(w, `{"custom_id": "picoclaw", "response": {"status_code": 200, "body": `+
+				`{"choices": [{"message": {"content": "digest"}, "finish_reason": "stop"}],`+
+				` "usage": {"prompt_tokens": 7, "completion_tokens": 1, "total_tokens": 8}}}}`+"\n")
+		case r.URL.Path == "/chat/completions":
+			b.chats++
+			io.WriteString(w, `{"choices": [{"message": {"content": "now"}, "finish_reason": "stop"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+func TestProviderChat_Batch(t *testing.T) {
+	b := newBatchServer(t)
+	p := NewProvider("key", b.URL, "", WithBatch(true))
+	p.batchPoll = time.Millisecond
+	messages := []Message{{Role: "user", Content: "summarize"}}
+
+	resp, err := p.Chat(t.Context(), messages, nil, "gpt-4o-mini", map[string]any{"max_tokens": 100, "batch": true})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Content != "digest" || resp.Usage == nil || resp.Usage.PromptTokens != 7 {
+		t.Errorf("Chat() = %+v", resp)
+	}
+	body, _ := b.input["body"].(map[string]any)
+	if b.input["url"] != "/v1/chat/completions" || body["model"] != "gpt-4o-mini" || body["max_tokens"] != 100.0 {
+		t.Errorf("batch input = %v", b.input)
+	}
+
+	// Interactive calls, and providers without batch, answer directly
+	for _, c := range []struct {
+		p    *Provider
+		opts map[string]any
+	}{
+		{p, map[string]any{}},
+		{NewProvider("key", b.URL, ""), map[string]any{"batch": true}},
+	} {
+		if resp, err := c.p.Chat(t.Context(), messages, nil, "gpt-4o-mini", c.opts); err != nil || resp.Content != "now" {
+			t.Errorf("Chat(%v) = %+v, %v", c.opts, resp, err)
+		}
+	}
+	if b.chats != 2 {
+		t.Errorf("chat completions = %d, want 2", b.chats)
+	}
+}
+
+func TestProviderChat_BatchCancelledWithContext(t *testing.T) {
+	b := newBatchServer(t)
+	b.hold = true
+	p := NewProvider("key", b.URL, "", WithBatch(true))
+	p.batchPoll = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	_, err := p.Chat(ctx, []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o-mini", map[string]any{"batch": true})
+	if err == nil || !strings.Contains(err.Error(), "deadline") {
+		t.Fatalf("Chat() error = %v, want the deadline", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.cancelled {
+		t.Error("the abandoned batch was not cancelled")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/sipeed/picoclaw/pkg/providers/common"
 	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+	"github.com/sipeed/picoclaw/pkg/requestid"
 )
 
 type (
@@ -31,7 +33,11 @@ type Provider struct {
 	apiKey         string
 	apiBase        string
 	maxTokensField string // Field name for max tokens (e.g., "max_completion_tokens" for o1/glm models)
+	headers        map[string]string
 	httpClient     *http.Client
+	retrier        *common.Retrier
+	batch          bool          // requests may take the Batch API; see WithBatch
+	batchPoll      time.Duration // how often pending batches are checked
 }
 
 type Option func(*Provider)
@@ -44,6 +50,15 @@ func WithMaxTokensField(maxTokensField string) Option {
 	}
 }
 
+// WithHeaders adds headers to every request, such as OpenRouter's
+// HTTP-Referer and X-Title or a gateway's own auth header. They are set
+// after the defaults, so they can replace Authorization.
+func WithHeaders(headers map[string]string) Option {
+	return func(p *Provider) {
+		p.headers = headers
+	}
+}
+
 func WithRequestTimeout(timeout time.Duration) Option {
 	return func(p *Provider) {
 		if timeout > 0 {
@@ -57,6 +72,7 @@ func NewProvider(apiKey, apiBase, proxy string, opts ...Option) *Provider {
 		apiKey:     apiKey,
 		apiBase:    strings.TrimRight(apiBase, "/"),
 		httpClient: common.NewHTTPClient(proxy),
+		retrier:    common.NewRetrier(common.DefaultRetryPolicy),
 	}
 
 	for _, opt := range opts {
@@ -92,17 +108,84 @@ func (p *Provider) Chat(
 	model string,
 	options map[string]any,
 ) (*LLMResponse, error) {
+	requestBody, err := p.requestBody(messages, tools, model, options)
+	if err != nil {
+		return nil, err
+	}
+	if deferred, _ := options["batch"].(bool); deferred && p.batch {
+		return p.chatBatch(ctx, requestBody)
+	}
+
+	resp, err := p.post(ctx, requestBody)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, common.HandleErrorResponse(resp, p.apiBase)
+	}
+
+	return common.ReadAndParseResponse(resp, p.apiBase)
+}
+
+// ChatStream implements providers.StreamingProvider. Servers that ignore
+// "stream" and answer with a single JSON body are handled like Chat.
+func (p *Provider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	requestBody, err := p.requestBody(messages, tools, model, options)
+	if err != nil {
+		return nil, err
+	}
+	requestBody["stream"] = true
+	requestBody["stream_options"] = map[string]any{"include_usage": true}
+
+	resp, err := p.post(ctx, requestBody)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, common.HandleErrorResponse(resp, p.apiBase)
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		out, err := common.ReadAndParseResponse(resp, p.apiBase)
+		if err == nil && out.Content != "" && onDelta != nil {
+			onDelta(out.Content)
+		}
+		return out, err
+	}
+	return common.ParseStream(resp.Body, onDelta)
+}
+
+func (p *Provider) requestBody(
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (map[string]any, error) {
 	if p.apiBase == "" {
 		return nil, fmt.Errorf("API base not configured")
 	}
 
+	// A request can target another model served by the same endpoint
+	if override, ok := options["model"].(string); ok && override != "" {
+		model = override
+	}
 	model = normalizeModel(model, p.apiBase)
 
 	requestBody := map[string]any{
 		"model":    model,
 		"messages": common.SerializeMessages(messages),
 	}
-
 	// When fallback uses a different provider (e.g. DeepSeek), that provider must not inject web_search_preview.
 	nativeSearch, _ := options["native_search"].(bool)
 	nativeSearch = nativeSearch && isNativeSearchHost(p.apiBase)
@@ -150,32 +233,150 @@ func (p *Provider) Chat(
 		}
 	}
 
+	// Structured outputs: the reply is constrained to the JSON schema. The
+	// caller validates it anyway, so endpoints without support only get it
+	// described in the prompt.
+	if schema, ok := options["response_schema"].(map[string]any); ok && supportsResponseSchema(p.apiBase) {
+		requestBody["response_format"] = map[string]any{
+			"type":        "json_schema",
+			"json_schema": map[string]any{"name": "response", "schema": schema},
+		}
+	}
+
+	return requestBody, nil
+}
+
+func (p *Provider) post(ctx context.Context, requestBody map[string]any) (*http.Response, error) {
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", p.apiBase+"/chat/completions", bytes.NewReader(jsonData))
+	req, err := p.newRequest(ctx, "POST", "/chat/completions", bytes.NewReader(jsonData))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
-
 	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.retrier.Do(p.httpClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+func (p *Provider) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, p.apiBase+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
 	if p.apiKey != "" {
 		req.Header.Set("Authorization", "Bearer "+p.apiKey)
 	}
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// CountTokens implements providers.TokenCounter. OpenAI-compatible APIs have
+// no counting endpoint, so this is an estimate.
+func (p *Provider) CountTokens(
+	_ context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	_ string,
+) (int, error) {
+	return common.EstimateTokens(messages, tools), nil
+}
 
-	resp, err := p.httpClient.Do(req)
+// Models implements providers.ModelLister with GET /models.
+func (p *Provider) Models(ctx context.Context) ([]string, error) {
+	if p.apiBase == "" {
+		return nil, fmt.Errorf("API base not configured")
+	}
+	req, err := p.newRequest(ctx, "GET", "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.retrier.Do(p.httpClient, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, common.HandleErrorResponse(resp, p.apiBase)
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to parse model list: %w", err)
+	}
+	models := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, m.ID)
+	}
+	return models, nil
+}
 
+// Embed implements providers.Embedder with POST /embeddings.
+func (p *Provider) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	if p.apiBase == "" {
+		return nil, fmt.Errorf("API base not configured")
+	}
+	jsonData, err := json.Marshal(map[string]any{
+		"model": normalizeModel(model, p.apiBase),
+		"input": texts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := p.newRequest(ctx, "POST", "/embeddings", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.retrier.Do(p.httpClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		return nil, common.HandleErrorResponse(resp, p.apiBase)
 	}
 
-	return common.ReadAndParseResponse(resp, p.apiBase)
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse embeddings: %w", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range for %d inputs", d.Index, len(texts))
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("no embedding returned for input %d", i)
+		}
+	}
+	return vectors, nil
 }
 
 func normalizeModel(model, apiBase string) string {
@@ -237,3 +438,10 @@ func supportsPromptCacheKey(apiBase string) bool {
 	host := u.Hostname()
 	return host == "api.openai.com" || strings.HasSuffix(host, ".openai.azure.com")
 }
+
+// supportsResponseSchema reports whether the given API base takes a
+// json_schema response_format. Like prompt_cache_key, other OpenAI-compatible
+// servers support it unevenly or reject it, so only OpenAI and Azure get it.
+func supportsResponseSchema(apiBase string) bool {
+	return supportsPromptCacheKey(apiBase)
+}
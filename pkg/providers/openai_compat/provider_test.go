@@ -14,6 +14,7 @@ import (
 
 	"github.com/sipeed/picoclaw/pkg/providers/common"
 	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+	"github.com/sipeed/picoclaw/pkg/requestid"
 )
 
 func TestProviderChat_UsesMaxCompletionTokensForGLM(t *testing.T) {
@@ -722,6 +723,12 @@ func TestSerializeMessages_MediaWithToolCallID(t *testing.T) {
 // chatWithCacheKey sets up a test server, sends a Chat request with prompt_cache_key,
 // and returns the decoded request body for assertion.
 func chatWithCacheKey(t *testing.T, apiBase string) map[string]any {
+	t.Helper()
+	return chatWithOptions(t, apiBase, map[string]any{"prompt_cache_key": "agent-main"})
+}
+
+// chatWithOptions is chatWithCacheKey with the given request options.
+func chatWithOptions(t *testing.T, apiBase string, options map[string]any) map[string]any {
 	t.Helper()
 	var requestBody map[string]any
 
@@ -757,7 +764,7 @@ func chatWithCacheKey(t *testing.T, apiBase string) map[string]any {
 		[]Message{{Role: "user", Content: "hi"}},
 		nil,
 		"test-model",
-		map[string]any{"prompt_cache_key": "agent-main"},
+		options,
 	)
 	if err != nil {
 		t.Fatalf("Chat() error = %v", err)
@@ -765,6 +772,22 @@ func chatWithCacheKey(t *testing.T, apiBase string) map[string]any {
 	return requestBody
 }
 
+func TestProviderChat_ResponseSchema(t *testing.T) {
+	schema := map[string]any{"type": "object", "required": []any{"ok"}}
+	body := chatWithOptions(t, "https://api.openai.com/v1", map[string]any{"response_schema": schema})
+	format, _ := body["response_format"].(map[string]any)
+	jsonSchema, _ := format["json_schema"].(map[string]any)
+	if format["type"] != "json_schema" || jsonSchema["schema"] == nil {
+		t.Errorf("response_format = %v", body["response_format"])
+	}
+
+	// Other servers are told about the schema in the prompt only
+	body = chatWithOptions(t, "https://api.deepseek.com/v1", map[string]any{"response_schema": schema})
+	if _, exists := body["response_format"]; exists {
+		t.Errorf("response_format sent to deepseek: %v", body["response_format"])
+	}
+}
+
 func TestProviderChat_PromptCacheKeySentToOpenAI(t *testing.T) {
 	body := chatWithCacheKey(t, "https://api.openai.com/v1")
 	if body["prompt_cache_key"] != "agent-main" {
@@ -1068,3 +1091,278 @@ func TestSerializeMessages_StripsSystemParts(t *testing.T) {
 		t.Fatal("system_parts should not appear in serialized output")
 	}
 }
+
+func TestProviderChat_ForwardsRequestID(t *testing.T) {
+	var gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get(requestid.Header)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]any{"content": "ok"}, "finish_reason": "stop"}},
+		})
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	ctx := requestid.NewContext(t.Context(), "req_abc")
+	if _, err := p.Chat(ctx, []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if gotHeader != "req_abc" {
+		t.Errorf("%s = %q, want req_abc", requestid.Header, gotHeader)
+	}
+}
+
+func TestProviderChatStream_StreamsContent(t *testing.T) {
+	var requestBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi \"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"there\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	var deltas []string
+	resp, err := p.ChatStream(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil,
+		func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if requestBody["stream"] != true {
+		t.Errorf("request stream = %v, want true", requestBody["stream"])
+	}
+	if resp.Content != "Hi there" || len(deltas) != 2 {
+		t.Errorf("content = %q, deltas = %q", resp.Content, deltas)
+	}
+}
+
+func TestProviderChatStream_JSONResponseFallsBack(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]any{"content": "whole"}, "finish_reason": "stop"}},
+		})
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	var deltas []string
+	resp, err := p.ChatStream(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil,
+		func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if resp.Content != "whole" || len(deltas) != 1 || deltas[0] != "whole" {
+		t.Errorf("content = %q, deltas = %q", resp.Content, deltas)
+	}
+}
+
+func TestProviderModels_ListsModelIDs(t *testing.T) {
+	var gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"}]}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	models, err := p.Models(t.Context())
+	if err != nil {
+		t.Fatalf("Models() error = %v", err)
+	}
+	if gotPath != "/models" || gotAuth != "Bearer key" {
+		t.Fatalf("request = %s with auth %q", gotPath, gotAuth)
+	}
+	if len(models) != 2 || models[0] != "gpt-4o" || models[1] != "gpt-4o-mini" {
+		t.Fatalf("models = %v", models)
+	}
+}
+
+func TestProviderModels_ReportsErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":{"message":"bad key"}}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	if _, err := p.Models(t.Context()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("Models() error = %v, want status 401", err)
+	}
+}
+
+func TestProviderCountTokens_Estimates(t *testing.T) {
+	p := NewProvider("key", "https://example.com/v1", "")
+	n, err := p.CountTokens(t.Context(), []Message{{Role: "user", Content: strings.Repeat("a", 25)}}, nil, "gpt-4o")
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if n != 10 {
+		t.Fatalf("CountTokens() = %d, want 10", n)
+	}
+}
+
+func TestProviderChat_SendsExtraHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "", WithHeaders(map[string]string{
+		"HTTP-Referer":  "https://example.com",
+		"X-Title":       "picoclaw",
+		"Authorization": "Token gateway-secret",
+	}))
+	if _, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "m", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if got.Get("HTTP-Referer") != "https://example.com" || got.Get("X-Title") != "picoclaw" {
+		t.Errorf("extra headers missing: %v", got)
+	}
+	if got.Get("Authorization") != "Token gateway-secret" {
+		t.Errorf("Authorization = %q, want the configured override", got.Get("Authorization"))
+	}
+}
+
+func TestProviderChat_ModelOptionOverridesModel(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	_, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "default-model",
+		map[string]any{"model": "Qwen/Qwen2.5-7B-Instruct"})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if body["model"] != "Qwen/Qwen2.5-7B-Instruct" {
+		t.Errorf("model = %v, want the override", body["model"])
+	}
+}
+
+func TestProviderChat_NonStandardErrorPayloads(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"llama.cpp", http.StatusBadRequest,
+			`{"error":{"code":400,"message":"the request exceeds the available context size","type":"invalid_request_error"}}`,
+			"exceeds the available context size"},
+		{"lm studio", http.StatusNotFound, `{"error":"Model not loaded"}`, "Model not loaded"},
+		{"vllm", http.StatusBadRequest,
+			`{"object":"error","message":"This model's maximum context length is 4096 tokens","type":"BadRequestError"}`,
+			"maximum context length"},
+		{"fastapi", http.StatusUnprocessableEntity, `{"detail":[{"loc":["body","model"],"msg":"field required"}]}`,
+			"field required"},
+		{"openrouter", http.StatusTooManyRequests,
+			`{"error":{"message":"Provider returned error","code":429,` +
+				`"metadata":{"raw":"rate limited upstream","provider_name":"Together"}}}`,
+			"Provider returned error (Together: rate limited upstream)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			p := NewProvider("key", server.URL, "")
+			_, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "m", nil)
+			if err == nil {
+				t.Fatal("Chat() error = nil")
+			}
+			if !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), fmt.Sprint(tt.status)) {
+				t.Errorf("error = %v, want status %d and %q", err, tt.status, tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderChat_ErrorInSuccessfulResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"error":{"message":"upstream timed out","code":502}}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	_, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "m", nil)
+	if err == nil || !strings.Contains(err.Error(), "upstream timed out") {
+		t.Fatalf("Chat() error = %v, want the payload's message", err)
+	}
+}
+
+func TestProviderChatStream_NonStandardStreamErrors(t *testing.T) {
+	for name, event := range map[string]string{
+		"llama.cpp": "error: {\"code\":500,\"message\":\"slot unavailable\",\"type\":\"server_error\"}\n\n",
+		"vllm":      "data: {\"object\":\"error\",\"message\":\"slot unavailable\",\"code\":500}\n\n",
+		"string":    "data: {\"error\":\"slot unavailable\"}\n\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+				fmt.Fprint(w, event)
+			}))
+			defer server.Close()
+
+			p := NewProvider("key", server.URL, "")
+			_, err := p.ChatStream(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "m", nil, nil)
+			if err == nil || !strings.Contains(err.Error(), "slot unavailable") {
+				t.Fatalf("ChatStream() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestProviderEmbed_OrdersByIndex(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("path = %s, want /embeddings", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"data":[{"index":1,"embedding":[0.5,0.25]},{"index":0,"embedding":[1,0]}]}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	vectors, err := p.Embed(t.Context(), []string{"a", "b"}, "text-embedding-3-small")
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if body["model"] != "text-embedding-3-small" {
+		t.Errorf("model = %v, want text-embedding-3-small", body["model"])
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][0] != 0.5 {
+		t.Errorf("vectors = %v, want them in input order", vectors)
+	}
+}
+
+func TestProviderEmbed_MissingVector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"index":0,"embedding":[1]}]}`)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	if _, err := p.Embed(t.Context(), []string{"a", "b"}, "m"); err == nil {
+		t.Fatal("Embed() error = nil, want a missing embedding")
+	}
+}
@@ -45,6 +45,10 @@ type UsageInfo struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	// Prompt caching (Anthropic): input tokens read from and written to the
+	// cache, which are not part of PromptTokens
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
 }
 
 // CacheControl marks a content block for LLM-side prefix caching.
@@ -0,0 +1,71 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import "context"
+
+// tokenStreamBuffer is how many deltas a TokenStream holds for a slow
+// reader before the provider waits.
+const tokenStreamBuffer = 64
+
+// TokenStream is a reply being generated, delivered as a channel of text
+// deltas. Read Deltas until it is closed, then call Wait for the complete
+// response.
+type TokenStream struct {
+	deltas chan string
+	done   chan struct{}
+	resp   *LLMResponse
+	err    error
+}
+
+// StreamChat sends a chat request and returns its reply as a TokenStream.
+// Providers that cannot stream deliver the whole reply as one delta.
+// Cancelling ctx cancels the request, which also ends the stream.
+func StreamChat(
+	ctx context.Context,
+	provider LLMProvider,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) *TokenStream {
+	s := &TokenStream{
+		deltas: make(chan string, tokenStreamBuffer),
+		done:   make(chan struct{}),
+	}
+	send := func(delta string) {
+		select {
+		case s.deltas <- delta:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer close(s.done)
+		defer close(s.deltas)
+		if sp, ok := provider.(StreamingProvider); ok {
+			s.resp, s.err = sp.ChatStream(ctx, messages, tools, model, options, send)
+			return
+		}
+		s.resp, s.err = provider.Chat(ctx, messages, tools, model, options)
+		if s.err == nil && s.resp != nil && s.resp.Content != "" {
+			send(s.resp.Content)
+		}
+	}()
+	return s
+}
+
+// Deltas returns the channel the reply text arrives on. It is closed when
+// the request ends, whether or not it succeeded.
+func (s *TokenStream) Deltas() <-chan string {
+	return s.deltas
+}
+
+// Wait blocks until the request ends and returns what Chat would have. The
+// caller must read Deltas until it is closed first, or cancel the request.
+func (s *TokenStream) Wait() (*LLMResponse, error) {
+	<-s.done
+	return s.resp, s.err
+}
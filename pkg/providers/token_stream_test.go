@@ -0,0 +1,57 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func collect(s *TokenStream) string {
+	var text strings.Builder
+	for delta := range s.Deltas() {
+		text.WriteString(delta)
+	}
+	return text.String()
+}
+
+func TestStreamChat_StreamingProvider(t *testing.T) {
+	p := &stubStreamProvider{deltas: []string{"Hel", "lo"}}
+	s := StreamChat(context.Background(), p, nil, nil, "m", nil)
+
+	if got := collect(s); got != "Hello" {
+		t.Errorf("deltas = %q, want Hello", got)
+	}
+	resp, err := s.Wait()
+	if err != nil || resp.Content != "Hello" {
+		t.Errorf("Wait() = %+v, %v", resp, err)
+	}
+}
+
+func TestStreamChat_NonStreamingProviderSendsOneDelta(t *testing.T) {
+	p := &stubProvider{name: "whole reply"}
+	s := StreamChat(context.Background(), p, nil, nil, "m", nil)
+
+	var deltas []string
+	for delta := range s.Deltas() {
+		deltas = append(deltas, delta)
+	}
+	if len(deltas) != 1 || deltas[0] != "whole reply" {
+		t.Errorf("deltas = %q, want the reply once", deltas)
+	}
+	if _, err := s.Wait(); err != nil {
+		t.Errorf("Wait() error = %v", err)
+	}
+}
+
+func TestStreamChat_ErrorClosesDeltas(t *testing.T) {
+	p := &stubProvider{err: errors.New("boom")}
+	s := StreamChat(context.Background(), p, nil, nil, "m", nil)
+
+	if got := collect(s); got != "" {
+		t.Errorf("deltas = %q, want none", got)
+	}
+	if _, err := s.Wait(); err == nil || err.Error() != "boom" {
+		t.Errorf("Wait() error = %v, want boom", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	anthropicmessages "github.com/sipeed/picoclaw/pkg/providers/anthropic_messages"
+	"github.com/sipeed/picoclaw/pkg/providers/ollama"
 	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
 
@@ -37,6 +39,64 @@ type StatefulProvider interface {
 	Close()
 }
 
+// StreamingProvider is an optional interface for providers that can stream
+// the reply while it is generated. onDelta receives each new piece of
+// content; the returned response is the one Chat would return.
+type StreamingProvider interface {
+	ChatStream(
+		ctx context.Context,
+		messages []Message,
+		tools []ToolDefinition,
+		model string,
+		options map[string]any,
+		onDelta func(delta string),
+	) (*LLMResponse, error)
+}
+
+// TokenCounter is an optional interface for providers that can count the
+// prompt tokens of a request before sending it. Backends without a counting
+// endpoint return an estimate.
+type TokenCounter interface {
+	CountTokens(
+		ctx context.Context,
+		messages []Message,
+		tools []ToolDefinition,
+		model string,
+	) (int, error)
+}
+
+// ModelLister is an optional interface for providers that can list the
+// models their endpoint serves.
+type ModelLister interface {
+	Models(ctx context.Context) ([]string, error)
+}
+
+// Embedder is an optional interface for providers with an embeddings
+// endpoint. Embed returns one vector per text, in order.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string, model string) ([][]float32, error)
+}
+
+// Provider is the full backend contract: chat, streaming, token counting
+// and model listing. Callers should still depend on LLMProvider and check
+// the optional interfaces, since CLI and OAuth backends implement less.
+type Provider interface {
+	LLMProvider
+	StreamingProvider
+	TokenCounter
+	ModelLister
+}
+
+var (
+	_ Provider = (*HTTPProvider)(nil)
+	_ Provider = (*anthropicmessages.Provider)(nil)
+	_ Provider = (*ollama.Provider)(nil)
+
+	_ Embedder = (*HTTPProvider)(nil)
+	_ Embedder = (*ollama.Provider)(nil)
+	_ Embedder = (*ChainProvider)(nil)
+)
+
 // ThinkingCapable is an optional interface for providers that support
 // extended thinking (e.g. Anthropic). Used by the agent loop to warn
 // when thinking_level is configured but the active provider cannot use it.
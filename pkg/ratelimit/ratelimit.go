@@ -0,0 +1,269 @@
+// Package ratelimit throttles the messages the agent answers per user, per
+// channel, and overall, by requests per minute and by LLM tokens per day.
+package ratelimit
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// Scopes a limit applies to.
+const (
+	ScopeUser    = "user"
+	ScopeChannel = "channel"
+	ScopeGlobal  = "global"
+)
+
+// Denial says which limit stopped a message.
+type Denial struct {
+	Scope      string // ScopeUser, ScopeChannel, or ScopeGlobal
+	Resource   string // "requests" or "tokens"
+	Limit      int
+	RetryAfter time.Duration
+}
+
+// ScopeStatus is what a user, their channel, or everyone used of a limit.
+type ScopeStatus struct {
+	Scope             string
+	Requests          int // in the last minute
+	RequestsPerMinute int
+	Tokens            int // today
+	TokensPerDay      int
+}
+
+// counter is the use of one key: the times of the requests of the last
+// minute, oldest first, and the tokens of today.
+type counter struct {
+	requests []time.Time
+	tokens   int
+}
+
+// Limiter checks messages against the configured limits. It is safe for
+// concurrent use; a nil *Limiter allows everything.
+type Limiter struct {
+	mu     sync.Mutex
+	cfg    config.RateLimitsConfig
+	day    string
+	counts map[string]*counter
+	now    func() time.Time
+}
+
+// New returns a limiter for cfg with nothing used yet.
+func New(cfg config.RateLimitsConfig) *Limiter {
+	return &Limiter{cfg: cfg, counts: make(map[string]*counter), now: time.Now}
+}
+
+// Configure replaces the limits, keeping what was used.
+func (l *Limiter) Configure(cfg config.RateLimitsConfig) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg
+}
+
+// Enabled reports whether limits are enforced.
+func (l *Limiter) Enabled() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cfg.Enabled
+}
+
+// UserKey identifies a sender on a channel.
+func UserKey(channel, senderID string) string {
+	return ScopeUser + "|" + channel + "|" + senderID
+}
+
+func channelKey(channel string) string {
+	return ScopeChannel + "|" + channel
+}
+
+// scopeKey is a counter a message counts against, with its limit.
+type scopeKey struct {
+	scope, key string
+	limit      config.RateLimit
+}
+
+// keys returns the counters a message of senderID on channel counts
+// against.
+func (l *Limiter) keys(channel, senderID string) []scopeKey {
+	return []scopeKey{
+		{ScopeUser, UserKey(channel, senderID), l.cfg.PerUser},
+		{ScopeChannel, channelKey(channel), l.cfg.PerChannel},
+		{ScopeGlobal, ScopeGlobal, l.cfg.Global},
+	}
+}
+
+// rollLocked starts a new day of token counts when the date changed.
+func (l *Limiter) rollLocked(now time.Time) {
+	if day := now.Format(time.DateOnly); day != l.day {
+		l.day = day
+		for _, c := range l.counts {
+			c.tokens = 0
+		}
+	}
+}
+
+// counterLocked returns the counter of key with the requests older than a
+// minute dropped.
+func (l *Limiter) counterLocked(key string, now time.Time) *counter {
+	c, ok := l.counts[key]
+	if !ok {
+		c = &counter{}
+		l.counts[key] = c
+	}
+	cutoff := now.Add(-time.Minute)
+	i := sort.Search(len(c.requests), func(i int) bool { return c.requests[i].After(cutoff) })
+	c.requests = c.requests[i:]
+	return c
+}
+
+// Allow counts a message of senderID on channel and reports true, or, when
+// a limit is reached, counts nothing and reports the limit. Exempt senders,
+// matched on sender, and disabled limits always pass.
+func (l *Limiter) Allow(channel, senderID string, sender bus.SenderInfo) (Denial, bool) {
+	if l == nil {
+		return Denial{}, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.cfg.Enabled || l.exemptLocked(channel, senderID, sender) {
+		return Denial{}, true
+	}
+	now := l.now()
+	l.rollLocked(now)
+
+	keys := l.keys(channel, senderID)
+	for _, k := range keys {
+		c := l.counterLocked(k.key, now)
+		if k.limit.TokensPerDay > 0 && c.tokens >= k.limit.TokensPerDay {
+			y, m, d := now.Date()
+			midnight := time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+			retry := midnight.Sub(now)
+			return Denial{Scope: k.scope, Resource: "tokens", Limit: k.limit.TokensPerDay, RetryAfter: retry}, false
+		}
+		if n := k.limit.RequestsPerMinute; n > 0 && len(c.requests) >= n {
+			retry := c.requests[len(c.requests)-n].Add(time.Minute).Sub(now)
+			return Denial{Scope: k.scope, Resource: "requests", Limit: n, RetryAfter: retry}, false
+		}
+	}
+	for _, k := range keys {
+		c := l.counts[k.key]
+		c.requests = append(c.requests, now)
+	}
+	return Denial{}, true
+}
+
+// Observe counts the tokens of one LLM call against its sender, channel,
+// and everyone. Calls from earlier days are ignored.
+func (l *Limiter) Observe(r usage.Record) {
+	if l == nil || r.Channel == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.rollLocked(now)
+	if !r.Time.IsZero() && r.Time.In(now.Location()).Format(time.DateOnly) != l.day {
+		return
+	}
+	tokens := r.PromptTokens + r.CompletionTokens
+	for _, k := range l.keys(r.Channel, r.SenderID) {
+		if k.scope == ScopeUser && r.SenderID == "" {
+			continue
+		}
+		l.counterLocked(k.key, now).tokens += tokens
+	}
+}
+
+// Seed counts the tokens of records already logged today, so a restart
+// does not reset the daily limits.
+func (l *Limiter) Seed(records []usage.Record) {
+	for _, r := range records {
+		if !r.Time.IsZero() {
+			l.Observe(r)
+		}
+	}
+}
+
+// Status returns what senderID on channel, the channel, and everyone used
+// of their limits.
+func (l *Limiter) Status(channel, senderID string) []ScopeStatus {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.rollLocked(now)
+	var out []ScopeStatus
+	for _, k := range l.keys(channel, senderID) {
+		c := l.counterLocked(k.key, now)
+		out = append(out, ScopeStatus{
+			Scope:             k.scope,
+			Requests:          len(c.requests),
+			RequestsPerMinute: k.limit.RequestsPerMinute,
+			Tokens:            c.tokens,
+			TokensPerDay:      k.limit.TokensPerDay,
+		})
+	}
+	return out
+}
+
+// Known reports whether senderID on channel used anything since the start.
+func (l *Limiter) Known(channel, senderID string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.counts[UserKey(channel, senderID)]
+	return ok
+}
+
+// Reset clears the counters of senderID on channel. The channel and global
+// counts keep what the user used.
+func (l *Limiter) Reset(channel, senderID string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.counts, UserKey(channel, senderID))
+}
+
+// ResetAll clears every counter.
+func (l *Limiter) ResetAll() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts = make(map[string]*counter)
+}
+
+// exemptLocked reports whether the sender is in the exempt list, whose
+// entries use the formats of allow_from.
+func (l *Limiter) exemptLocked(channel, senderID string, sender bus.SenderInfo) bool {
+	if senderID == "" {
+		return false
+	}
+	sender = identity.SenderOrID(sender, channel, senderID)
+	for _, entry := range l.cfg.Exempt {
+		if identity.MatchAllowed(sender, entry) || strings.TrimSpace(entry) == senderID {
+			return true
+		}
+	}
+	return false
+}
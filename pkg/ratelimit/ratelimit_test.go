@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func newTestLimiter(cfg config.RateLimitsConfig, now *time.Time) *Limiter {
+	cfg.Enabled = true
+	l := New(cfg)
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestAllow_RequestsPerMinuteSlide(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(config.RateLimitsConfig{PerUser: config.RateLimit{RequestsPerMinute: 2}}, &now)
+
+	for i := 0; i < 2; i++ {
+		if _, ok := l.Allow("telegram", "1", bus.SenderInfo{}); !ok {
+			t.Fatalf("request %d denied", i+1)
+		}
+		now = now.Add(20 * time.Second)
+	}
+	d, ok := l.Allow("telegram", "1", bus.SenderInfo{})
+	if ok || d.Scope != ScopeUser || d.Resource != "requests" || d.RetryAfter != 20*time.Second {
+		t.Fatalf("third request = %+v, %v", d, ok)
+	}
+	if _, ok := l.Allow("telegram", "2", bus.SenderInfo{}); !ok {
+		t.Error("another user was limited")
+	}
+	now = now.Add(20 * time.Second)
+	if _, ok := l.Allow("telegram", "1", bus.SenderInfo{}); !ok {
+		t.Error("the oldest request did not leave the window")
+	}
+}
+
+func TestAllow_ChannelAndGlobalLimits(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(config.RateLimitsConfig{
+		PerChannel: config.RateLimit{RequestsPerMinute: 1},
+		Global:     config.RateLimit{RequestsPerMinute: 2},
+		Exempt:     []string{"discord:9"},
+	}, &now)
+
+	l.Allow("telegram", "1", bus.SenderInfo{})
+	if d, ok := l.Allow("telegram", "2", bus.SenderInfo{}); ok || d.Scope != ScopeChannel {
+		t.Fatalf("second telegram request = %+v, %v", d, ok)
+	}
+	l.Allow("discord", "1", bus.SenderInfo{})
+	if d, ok := l.Allow("slack", "1", bus.SenderInfo{}); ok || d.Scope != ScopeGlobal {
+		t.Fatalf("third request = %+v, %v", d, ok)
+	}
+	if _, ok := l.Allow("discord", "9", bus.SenderInfo{}); !ok {
+		t.Error("an exempt user was limited")
+	}
+}
+
+func TestAllow_ExemptMatchesLikeAllowFrom(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(config.RateLimitsConfig{
+		PerUser: config.RateLimit{RequestsPerMinute: 1},
+		Exempt:  []string{"@alice"},
+	}, &now)
+
+	alice := bus.SenderInfo{Platform: "telegram", PlatformID: "7", CanonicalID: "telegram:7", Username: "alice"}
+	for i := 0; i < 3; i++ {
+		if _, ok := l.Allow("telegram", "7", alice); !ok {
+			t.Fatalf("request %d of @alice was limited", i+1)
+		}
+	}
+	mallory := bus.SenderInfo{Platform: "telegram", PlatformID: "8", CanonicalID: "telegram:8", Username: "mallory"}
+	l.Allow("telegram", "8", mallory)
+	if _, ok := l.Allow("telegram", "8", mallory); ok {
+		t.Error("a sender not in the exempt list was not limited")
+	}
+}
+
+func TestObserve_TokensPerDayResetAtMidnight(t *testing.T) {
+	now := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	l := newTestLimiter(config.RateLimitsConfig{PerUser: config.RateLimit{TokensPerDay: 100}}, &now)
+
+	l.Seed([]usage.Record{
+		{Time: now.Add(-time.Hour), Channel: "telegram", SenderID: "1", PromptTokens: 80, CompletionTokens: 20},
+		{Time: now.Add(-24 * time.Hour), Channel: "telegram", SenderID: "2", PromptTokens: 500},
+	})
+	d, ok := l.Allow("telegram", "1", bus.SenderInfo{})
+	if ok || d.Resource != "tokens" || d.RetryAfter != time.Hour {
+		t.Fatalf("over tokens = %+v, %v", d, ok)
+	}
+	if _, ok := l.Allow("telegram", "2", bus.SenderInfo{}); !ok {
+		t.Error("tokens of yesterday counted")
+	}
+	now = now.Add(time.Hour)
+	if _, ok := l.Allow("telegram", "1", bus.SenderInfo{}); !ok {
+		t.Error("tokens did not reset at midnight")
+	}
+}
+
+func TestStatusAndReset(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(config.RateLimitsConfig{PerUser: config.RateLimit{RequestsPerMinute: 1}}, &now)
+
+	l.Allow("telegram", "1", bus.SenderInfo{})
+	l.Observe(usage.Record{Time: now, Channel: "telegram", SenderID: "1", PromptTokens: 5})
+	if !l.Known("telegram", "1") || l.Known("telegram", "2") {
+		t.Fatal("Known does not follow the users seen")
+	}
+	st := l.Status("telegram", "1")
+	if len(st) != 3 || st[0].Requests != 1 || st[0].Tokens != 5 || st[2].Scope != ScopeGlobal || st[2].Tokens != 5 {
+		t.Fatalf("Status = %+v", st)
+	}
+	l.Reset("telegram", "1")
+	if _, ok := l.Allow("telegram", "1", bus.SenderInfo{}); !ok {
+		t.Error("Reset kept the user's requests")
+	}
+}
+
+func TestNilAndDisabledLimiterAllow(t *testing.T) {
+	var l *Limiter
+	if _, ok := l.Allow("telegram", "1", bus.SenderInfo{}); !ok {
+		t.Error("nil limiter denied")
+	}
+	l = New(config.RateLimitsConfig{PerUser: config.RateLimit{RequestsPerMinute: 1}})
+	l.Allow("telegram", "1", bus.SenderInfo{})
+	if _, ok := l.Allow("telegram", "1", bus.SenderInfo{}); !ok {
+		t.Error("disabled limiter denied")
+	}
+}
@@ -0,0 +1,258 @@
+package recall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrUnknownDocument means no document of the scope has the given ID.
+var ErrUnknownDocument = errors.New("no document with that number")
+
+// embedBatch is how many chunks are embedded per request.
+const embedBatch = 64
+
+// Document is one knowledge base document, saved in chunks.
+type Document struct {
+	ID      int64
+	Scope   string
+	Title   string
+	Source  string // URL or attachment file name
+	AddedBy string
+	Added   time.Time
+	Chunks  int
+}
+
+// Passage is a retrieved document chunk with its cosine similarity to the
+// query. Part counts the chunks of the document from 1.
+type Passage struct {
+	DocumentID int64
+	Title      string
+	Source     string
+	Part       int
+	Text       string
+	Score      float64
+}
+
+// AddDocument embeds chunks and saves them as a document of scope.
+func (s *Store) AddDocument(
+	ctx context.Context, scope, title, source, addedBy string, chunks []string,
+) (Document, error) {
+	kept := chunks[:0:0]
+	for _, c := range chunks {
+		if c = strings.TrimSpace(c); c != "" {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) == 0 {
+		return Document{}, errors.New("the document has no text")
+	}
+	vectors := make([][]float32, 0, len(kept))
+	for start := 0; start < len(kept); start += embedBatch {
+		batch, err := s.embed(ctx, kept[start:min(start+embedBatch, len(kept))])
+		if err != nil {
+			return Document{}, err
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	d := Document{Scope: scope, Title: title, Source: source, AddedBy: addedBy, Added: time.Now(), Chunks: len(kept)}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Document{}, fmt.Errorf("save document: %w", err)
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `INSERT INTO documents (scope, title, source, added_by, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		scope, s.seal(scope, title), s.seal(scope, source), addedBy, d.Added.UTC().Format(timeFormat))
+	if err != nil {
+		return Document{}, fmt.Errorf("save document: %w", err)
+	}
+	if d.ID, err = res.LastInsertId(); err != nil {
+		return Document{}, fmt.Errorf("save document: %w", err)
+	}
+	for i, text := range kept {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO document_chunks (document_id, seq, text, model, vector)
+			VALUES (?, ?, ?, ?, ?)`, d.ID, i+1, s.seal(scope, text), s.model, encodeVector(vectors[i])); err != nil {
+			return Document{}, fmt.Errorf("save document: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return Document{}, fmt.Errorf("save document: %w", err)
+	}
+	return d, nil
+}
+
+// Documents returns the documents of scope, oldest first.
+func (s *Store) Documents(ctx context.Context, scope string) ([]Document, error) {
+	if s == nil {
+		return nil, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT d.id, d.title, d.source, d.added_by, d.created_at,
+		(SELECT COUNT(*) FROM document_chunks c WHERE c.document_id = d.id)
+		FROM documents d WHERE d.scope = ? ORDER BY d.id`, scope)
+	if err != nil {
+		return nil, fmt.Errorf("list documents: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Document
+	for rows.Next() {
+		d := Document{Scope: scope}
+		var added string
+		if err := rows.Scan(&d.ID, &d.Title, &d.Source, &d.AddedBy, &added, &d.Chunks); err != nil {
+			return nil, fmt.Errorf("list documents: %w", err)
+		}
+		if d.Title, d.Source, err = s.openDocument(scope, d.Title, d.Source); err != nil {
+			return nil, fmt.Errorf("list documents: %w", err)
+		}
+		d.Added, _ = time.Parse(timeFormat, added)
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+// RemoveDocument deletes the document with id, and its chunks, from scope.
+func (s *Store) RemoveDocument(ctx context.Context, scope string, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM documents WHERE scope = ? AND id = ?`, scope, id)
+	if err != nil {
+		return fmt.Errorf("remove document: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUnknownDocument
+	}
+	return nil
+}
+
+// ForgetDocuments deletes the documents of scope and, when addedBy is set,
+// the ones addedBy added in any scope. It returns how many were deleted.
+func (s *Store) ForgetDocuments(ctx context.Context, scope, addedBy string) (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+	query, args := `DELETE FROM documents WHERE scope = ?`, []any{scope}
+	if addedBy != "" {
+		query += ` OR added_by = ?`
+		args = append(args, addedBy)
+	}
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("forget documents: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// SearchDocuments returns up to k chunks of the documents of scope whose
+// similarity to query is at least minScore, best first. Like Search, it
+// embeds chunks of another model again first.
+func (s *Store) SearchDocuments(ctx context.Context, scope, query string, k int, minScore float64) ([]Passage, error) {
+	if s == nil || strings.TrimSpace(query) == "" || k <= 0 {
+		return nil, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT c.id, d.id, d.title, d.source, c.seq, c.text, c.model, c.vector
+		FROM document_chunks c JOIN documents d ON d.id = c.document_id WHERE d.scope = ?`, scope)
+	if err != nil {
+		return nil, fmt.Errorf("search documents: %w", err)
+	}
+	var (
+		passages []Passage
+		ids      []int64
+		vectors  [][]float32
+		stale    []int
+	)
+	for rows.Next() {
+		var p Passage
+		var id int64
+		var model string
+		var blob []byte
+		if err := rows.Scan(&id, &p.DocumentID, &p.Title, &p.Source, &p.Part, &p.Text, &model, &blob); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("search documents: %w", err)
+		}
+		if p.Title, p.Source, err = s.openDocument(scope, p.Title, p.Source); err == nil {
+			p.Text, err = s.open(scope, p.Text)
+		}
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("search documents: %w", err)
+		}
+		if model != s.model {
+			stale = append(stale, len(passages))
+		}
+		passages = append(passages, p)
+		ids = append(ids, id)
+		vectors = append(vectors, decodeVector(blob))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("search documents: %w", err)
+	}
+	if len(passages) == 0 {
+		return nil, nil
+	}
+
+	embedded, err := s.embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	for start := 0; start < len(stale); start += embedBatch {
+		batch := stale[start:min(start+embedBatch, len(stale))]
+		texts := make([]string, len(batch))
+		for j, i := range batch {
+			texts[j] = passages[i].Text
+		}
+		again, err := s.embed(ctx, texts)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range batch {
+			vectors[i] = again[j]
+			if _, err := s.db.ExecContext(ctx, `UPDATE document_chunks SET model = ?, vector = ? WHERE id = ?`,
+				s.model, encodeVector(vectors[i]), ids[i]); err != nil {
+				return nil, fmt.Errorf("update document chunk: %w", err)
+			}
+		}
+	}
+
+	q := embedded[0]
+	kept := passages[:0]
+	for i := range passages {
+		passages[i].Score = cosine(q, vectors[i])
+		if passages[i].Score >= minScore {
+			kept = append(kept, passages[i])
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Score > kept[j].Score })
+	if len(kept) > k {
+		kept = kept[:k]
+	}
+	return kept, nil
+}
+
+// AskPrompt returns the prompt that answers question from passages, which
+// it numbers so the answer can cite them as [1], [2], and so on.
+func AskPrompt(question string, passages []Passage) string {
+	var b strings.Builder
+	b.WriteString("Answer the question using only the numbered excerpts from the knowledge base below. " +
+		"Cite the excerpts you use as [1], [2], and so on. " +
+		"If the excerpts do not contain the answer, say so.\n")
+	for i, p := range passages {
+		fmt.Fprintf(&b, "\n[%d] %s, part %d:\n%s\n", i+1, p.Title, p.Part, p.Text)
+	}
+	b.WriteString("\nQuestion: " + question)
+	return b.String()
+}
+
+// openDocument returns the stored title and source of a document of scope.
+func (s *Store) openDocument(scope, title, source string) (string, string, error) {
+	title, err := s.open(scope, title)
+	if err != nil {
+		return "", "", err
+	}
+	source, err = s.open(scope, source)
+	return title, source, err
+}
@@ -0,0 +1,71 @@
+package recall
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore_Documents(t *testing.T) {
+	ctx := context.Background()
+	e := &wordEmbedder{}
+	path := filepath.Join(t.TempDir(), "picoclaw.db")
+	s := openTestStore(t, path, e, "embed-1")
+
+	handbook, err := s.AddDocument(ctx, "dm:alice", "Handbook", "handbook.pdf", "telegram:1", []string{
+		"Vacation requests go to your manager",
+		"  ",
+		"The office opens at nine",
+	})
+	if err != nil {
+		t.Fatalf("AddDocument: %v", err)
+	}
+	if handbook.Chunks != 2 {
+		t.Errorf("Chunks = %d, want the blank chunk dropped", handbook.Chunks)
+	}
+	s.AddDocument(ctx, "dm:alice", "Menu", "https://example.com/menu", "telegram:1", []string{"Soup of the day"})
+	s.AddDocument(ctx, "dm:bob", "Other handbook", "", "telegram:2", []string{"The office opens at ten"})
+	if _, err := s.AddDocument(ctx, "dm:alice", "Empty", "", "telegram:1", nil); err == nil {
+		t.Error("AddDocument without text succeeded")
+	}
+
+	list, err := s.Documents(ctx, "dm:alice")
+	if err != nil || len(list) != 2 || list[0].Title != "Handbook" || list[0].Chunks != 2 {
+		t.Fatalf("Documents = %+v, %v", list, err)
+	}
+
+	// Chunks embedded with an older model are embedded again.
+	s = openTestStore(t, path, e, "embed-2")
+	hits, err := s.SearchDocuments(ctx, "dm:alice", "when does the office open", 2, 0.1)
+	if err != nil {
+		t.Fatalf("SearchDocuments: %v", err)
+	}
+	if len(hits) == 0 || hits[0].Title != "Handbook" || hits[0].Part != 2 || hits[0].Source != "handbook.pdf" {
+		t.Fatalf("hits = %+v, want part 2 of the handbook first", hits)
+	}
+	if e.models[len(e.models)-1] != "embed-2" {
+		t.Errorf("models = %v", e.models)
+	}
+
+	prompt := AskPrompt("when does the office open", hits[:1])
+	if !strings.Contains(prompt, "[1] Handbook, part 2:\nThe office opens at nine") {
+		t.Errorf("AskPrompt =\n%s", prompt)
+	}
+
+	if err := s.RemoveDocument(ctx, "dm:bob", handbook.ID); !errors.Is(err, ErrUnknownDocument) {
+		t.Errorf("RemoveDocument from another scope: err = %v", err)
+	}
+	if err := s.RemoveDocument(ctx, "dm:alice", handbook.ID); err != nil {
+		t.Fatalf("RemoveDocument: %v", err)
+	}
+	if hits, _ := s.SearchDocuments(ctx, "dm:alice", "office", 5, 0.1); len(hits) != 0 {
+		t.Errorf("chunks of the removed document are still found: %+v", hits)
+	}
+
+	n, err := s.ForgetDocuments(ctx, "dm:nobody", "telegram:2")
+	if err != nil || n != 1 {
+		t.Errorf("ForgetDocuments = %d, %v, want 1", n, err)
+	}
+}
@@ -0,0 +1,376 @@
+// Package recall keeps long-term memories, facts users save with /remember,
+// in the memories table of the workspace database. Each memory is stored
+// with its embedding, and the ones closest to a message are retrieved by
+// cosine similarity to be added to the system prompt. The knowledge base
+// documents added with /kb are kept the same way, chunk by chunk.
+package recall
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/database"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Defaults for a config that does not set them.
+const (
+	DefaultTopK     = 5
+	DefaultMinScore = 0.3
+)
+
+// ErrUnknownMemory means no memory of the scope has the given ID.
+var ErrUnknownMemory = errors.New("no memory with that number")
+
+// timeFormat is fixed-width so stored times sort and compare as text.
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// Memory is one saved memory.
+type Memory struct {
+	ID      int64
+	Scope   string
+	Text    string
+	AddedBy string
+	Added   time.Time
+}
+
+// Hit is a retrieved memory with its cosine similarity to the query.
+type Hit struct {
+	Memory
+	Score float64
+}
+
+// Store saves and retrieves memories. A nil *Store has no memories.
+type Store struct {
+	db       *sql.DB
+	embedder providers.Embedder
+	model    string
+	sealer   *memory.Sealer // nil stores text unencrypted
+}
+
+// Open opens the workspace database at path, migrating it if needed.
+// Memories are embedded with model through embedder.
+func Open(ctx context.Context, path string, embedder providers.Embedder, model string) (*Store, error) {
+	db, err := database.Open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db, embedder: embedder, model: model}, nil
+}
+
+// Encrypt has the text of memories and documents, and their titles and
+// sources, stored encrypted with sealer from now on, and encrypts what was
+// stored before. Their embeddings are kept as they are.
+func (s *Store) Encrypt(ctx context.Context, sealer *memory.Sealer) error {
+	s.sealer = sealer
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("encrypt memories: %w", err)
+	}
+	defer tx.Rollback()
+	for _, q := range []struct{ sel, upd string }{
+		{`SELECT id, scope, text FROM memories`, `UPDATE memories SET text = ? WHERE id = ?`},
+		{`SELECT id, scope, title FROM documents`, `UPDATE documents SET title = ? WHERE id = ?`},
+		{`SELECT id, scope, source FROM documents`, `UPDATE documents SET source = ? WHERE id = ?`},
+		{`SELECT c.id, d.scope, c.text FROM document_chunks c JOIN documents d ON d.id = c.document_id`,
+			`UPDATE document_chunks SET text = ? WHERE id = ?`},
+	} {
+		if err := s.sealColumn(ctx, tx, q.sel, q.upd); err != nil {
+			return fmt.Errorf("encrypt memories: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("encrypt memories: %w", err)
+	}
+	return nil
+}
+
+// sealColumn seals the values sel selects, with their row id and scope,
+// that are not sealed yet, using upd.
+func (s *Store) sealColumn(ctx context.Context, tx *sql.Tx, sel, upd string) error {
+	rows, err := tx.QueryContext(ctx, sel)
+	if err != nil {
+		return err
+	}
+	type row struct {
+		id    int64
+		value string
+	}
+	var plain []row
+	for rows.Next() {
+		var r row
+		var scope string
+		if err := rows.Scan(&r.id, &scope, &r.value); err != nil {
+			rows.Close()
+			return err
+		}
+		if r.value != "" && !memory.IsSealed(r.value) {
+			r.value = s.seal(scope, r.value)
+			plain = append(plain, r)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, r := range plain {
+		if _, err := tx.ExecContext(ctx, upd, r.value, r.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seal returns the stored form of a value of scope.
+func (s *Store) seal(scope, value string) string {
+	if s.sealer == nil || value == "" {
+		return value
+	}
+	return s.sealer.Seal("recall:"+scope, []byte(value))
+}
+
+// open returns a stored value of scope. Values stored before encryption was
+// turned on are returned as they are.
+func (s *Store) open(scope, stored string) (string, error) {
+	if !memory.IsSealed(stored) {
+		return stored, nil
+	}
+	plaintext, err := s.sealer.Open("recall:"+scope, stored)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Add embeds text and saves it as a memory of scope.
+func (s *Store) Add(ctx context.Context, scope, text, addedBy string) (Memory, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Memory{}, errors.New("the memory is empty")
+	}
+	vectors, err := s.embed(ctx, []string{text})
+	if err != nil {
+		return Memory{}, err
+	}
+	m := Memory{Scope: scope, Text: text, AddedBy: addedBy, Added: time.Now()}
+	res, err := s.db.ExecContext(ctx, `INSERT INTO memories (scope, text, added_by, created_at, model, vector)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		scope, s.seal(scope, text), addedBy, m.Added.UTC().Format(timeFormat), s.model, encodeVector(vectors[0]))
+	if err != nil {
+		return Memory{}, fmt.Errorf("save memory: %w", err)
+	}
+	if m.ID, err = res.LastInsertId(); err != nil {
+		return Memory{}, fmt.Errorf("save memory: %w", err)
+	}
+	return m, nil
+}
+
+// List returns the memories of scope, oldest first.
+func (s *Store) List(ctx context.Context, scope string) ([]Memory, error) {
+	if s == nil {
+		return nil, nil
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, text, added_by, created_at FROM memories WHERE scope = ? ORDER BY id`, scope)
+	if err != nil {
+		return nil, fmt.Errorf("list memories: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Memory
+	for rows.Next() {
+		m := Memory{Scope: scope}
+		var added string
+		if err := rows.Scan(&m.ID, &m.Text, &m.AddedBy, &added); err != nil {
+			return nil, fmt.Errorf("list memories: %w", err)
+		}
+		if m.Text, err = s.open(scope, m.Text); err != nil {
+			return nil, fmt.Errorf("list memories: %w", err)
+		}
+		m.Added, _ = time.Parse(timeFormat, added)
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// Remove deletes the memory with id from scope.
+func (s *Store) Remove(ctx context.Context, scope string, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM memories WHERE scope = ? AND id = ?`, scope, id)
+	if err != nil {
+		return fmt.Errorf("remove memory: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUnknownMemory
+	}
+	return nil
+}
+
+// Forget deletes the memories of scope and, when addedBy is set, the ones
+// addedBy saved in any scope. It returns how many were deleted.
+func (s *Store) Forget(ctx context.Context, scope, addedBy string) (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+	query, args := `DELETE FROM memories WHERE scope = ?`, []any{scope}
+	if addedBy != "" {
+		query += ` OR added_by = ?`
+		args = append(args, addedBy)
+	}
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("forget memories: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// Search returns up to k memories of scope whose similarity to query is at
+// least minScore, best first. It only embeds query when scope has
+// memories. Memories embedded with another model, as after a config
+// change, are embedded again first.
+func (s *Store) Search(ctx context.Context, scope, query string, k int, minScore float64) ([]Hit, error) {
+	if s == nil || strings.TrimSpace(query) == "" || k <= 0 {
+		return nil, nil
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, text, added_by, created_at, model, vector FROM memories WHERE scope = ?`, scope)
+	if err != nil {
+		return nil, fmt.Errorf("search memories: %w", err)
+	}
+	var (
+		hits    []Hit
+		vectors [][]float32
+		stale   []int
+	)
+	for rows.Next() {
+		h := Hit{Memory: Memory{Scope: scope}}
+		var added, model string
+		var blob []byte
+		if err := rows.Scan(&h.ID, &h.Text, &h.AddedBy, &added, &model, &blob); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("search memories: %w", err)
+		}
+		if h.Text, err = s.open(scope, h.Text); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("search memories: %w", err)
+		}
+		h.Added, _ = time.Parse(timeFormat, added)
+		if model != s.model {
+			stale = append(stale, len(hits))
+		}
+		hits = append(hits, h)
+		vectors = append(vectors, decodeVector(blob))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("search memories: %w", err)
+	}
+	if len(hits) == 0 {
+		return nil, nil
+	}
+
+	texts := []string{query}
+	for _, i := range stale {
+		texts = append(texts, hits[i].Text)
+	}
+	embedded, err := s.embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range stale {
+		vectors[i] = embedded[j+1]
+		if _, err := s.db.ExecContext(ctx, `UPDATE memories SET model = ?, vector = ? WHERE id = ?`,
+			s.model, encodeVector(vectors[i]), hits[i].ID); err != nil {
+			return nil, fmt.Errorf("update memory: %w", err)
+		}
+	}
+
+	q := embedded[0]
+	kept := hits[:0]
+	for i := range hits {
+		hits[i].Score = cosine(q, vectors[i])
+		if hits[i].Score >= minScore {
+			kept = append(kept, hits[i])
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Score > kept[j].Score })
+	if len(kept) > k {
+		kept = kept[:k]
+	}
+	return kept, nil
+}
+
+// embed embeds texts, checking that a vector came back for each.
+func (s *Store) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, err := s.embedder.Embed(ctx, texts, s.model)
+	if err != nil {
+		return nil, fmt.Errorf("embed: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embed: got %d vectors for %d texts", len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+// Prompt returns the system prompt section for hits, or "" when there are
+// none.
+func Prompt(hits []Hit) string {
+	if len(hits) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("## Memories\nThese were saved from earlier conversations and may be relevant. " +
+		"They can be outdated; the user's current messages take precedence.")
+	for _, h := range hits {
+		b.WriteString("\n- " + h.Text)
+	}
+	return b.String()
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v
+}
@@ -0,0 +1,201 @@
+package recall
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+// wordEmbedder embeds text as a bag of words hashed into 64 dimensions, so
+// texts that share words are similar.
+type wordEmbedder struct {
+	calls  int
+	models []string
+}
+
+func (e *wordEmbedder) Embed(_ context.Context, texts []string, model string) ([][]float32, error) {
+	e.calls++
+	e.models = append(e.models, model)
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, 64)
+		for _, w := range strings.Fields(strings.ToLower(text)) {
+			h := fnv.New32a()
+			h.Write([]byte(strings.Trim(w, ".,?!")))
+			v[h.Sum32()%64]++
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func openTestStore(t *testing.T, path string, e *wordEmbedder, model string) *Store {
+	t.Helper()
+	s, err := Open(context.Background(), path, e, model)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStore_SearchReturnsClosestMemories(t *testing.T) {
+	ctx := context.Background()
+	e := &wordEmbedder{}
+	s := openTestStore(t, filepath.Join(t.TempDir(), "picoclaw.db"), e, "embed-1")
+
+	for _, text := range []string{
+		"My dog is called Rex",
+		"I am allergic to peanuts",
+		"My favourite colour is green",
+	} {
+		if _, err := s.Add(ctx, "dm:alice", text, "telegram:1"); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	s.Add(ctx, "dm:bob", "My dog is called Max", "telegram:2")
+
+	hits, err := s.Search(ctx, "dm:alice", "what is my dog called?", 2, 0.5)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Text != "My dog is called Rex" {
+		t.Fatalf("hits = %+v, want only alice's dog", hits)
+	}
+	if p := Prompt(hits); !strings.Contains(p, "## Memories") || !strings.Contains(p, "- My dog is called Rex") {
+		t.Errorf("Prompt = %q", p)
+	}
+
+	// A scope without memories is not embedded.
+	calls := e.calls
+	if hits, err := s.Search(ctx, "dm:carol", "my dog", 5, 0); err != nil || hits != nil || e.calls != calls {
+		t.Errorf("empty scope: hits=%v err=%v embeds=%d", hits, err, e.calls-calls)
+	}
+}
+
+func TestStore_ListRemoveForget(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t, filepath.Join(t.TempDir(), "picoclaw.db"), &wordEmbedder{}, "embed-1")
+
+	a, _ := s.Add(ctx, "dm:alice", "first", "telegram:1")
+	s.Add(ctx, "dm:alice", "second", "telegram:1")
+	s.Add(ctx, "group:1", "said in a group", "telegram:1")
+	s.Add(ctx, "group:1", "someone else", "telegram:2")
+
+	if err := s.Remove(ctx, "group:1", a.ID); !errors.Is(err, ErrUnknownMemory) {
+		t.Errorf("Remove from another scope = %v, want ErrUnknownMemory", err)
+	}
+	if err := s.Remove(ctx, "dm:alice", a.ID); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	list, _ := s.List(ctx, "dm:alice")
+	if len(list) != 1 || list[0].Text != "second" || list[0].AddedBy != "telegram:1" {
+		t.Errorf("List = %+v", list)
+	}
+
+	n, err := s.Forget(ctx, "dm:alice", "telegram:1")
+	if err != nil || n != 2 {
+		t.Fatalf("Forget = %d, %v; want 2", n, err)
+	}
+	if list, _ := s.List(ctx, "group:1"); len(list) != 1 || list[0].Text != "someone else" {
+		t.Errorf("group memories after Forget = %+v", list)
+	}
+}
+
+func TestStore_ReembedsAfterModelChange(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "picoclaw.db")
+	old := openTestStore(t, path, &wordEmbedder{}, "embed-1")
+	if _, err := old.Add(ctx, "dm:alice", "I live in Lisbon", ""); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	old.Close()
+
+	e := &wordEmbedder{}
+	s := openTestStore(t, path, e, "embed-2")
+	hits, err := s.Search(ctx, "dm:alice", "where do I live?", 5, 0.1)
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("Search = %+v, %v", hits, err)
+	}
+	if e.calls != 1 {
+		t.Errorf("embedded %d times, want the query and the stale memory in one call", e.calls)
+	}
+
+	// Vectors are stored with the new model, so the next search embeds only the query.
+	s.Search(ctx, "dm:alice", "Lisbon", 5, 0.1)
+	if e.calls != 2 {
+		t.Errorf("embedded %d times, want 2", e.calls)
+	}
+}
+
+func TestStore_Encrypt(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "picoclaw.db")
+	e := &wordEmbedder{}
+	s := openTestStore(t, path, e, "embed-1")
+	if _, err := s.Add(ctx, "dm:alice", "My dog is called Rex", "telegram:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	sealer, err := memory.NewSealer(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Encrypt(ctx, sealer); err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if _, err := s.Add(ctx, "dm:alice", "I am allergic to peanuts", "telegram:1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddDocument(ctx, "dm:alice", "Rex's vet", "vet.pdf", "telegram:1",
+		[]string{"Rex sees the vet on Mondays"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored []string
+	for _, q := range []string{
+		`SELECT text FROM memories`, `SELECT title FROM documents`,
+		`SELECT source FROM documents`, `SELECT text FROM document_chunks`,
+	} {
+		rows, err := s.db.QueryContext(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var v string
+			rows.Scan(&v)
+			stored = append(stored, v)
+		}
+		rows.Close()
+	}
+	for _, v := range stored {
+		if !memory.IsSealed(v) {
+			t.Errorf("stored in plaintext: %q", v)
+		}
+	}
+
+	list, err := s.List(ctx, "dm:alice")
+	if err != nil || len(list) != 2 || list[0].Text != "My dog is called Rex" {
+		t.Errorf("List = %+v, %v", list, err)
+	}
+	hits, err := s.Search(ctx, "dm:alice", "what is my dog called?", 1, 0.1)
+	if err != nil || len(hits) != 1 || hits[0].Text != "My dog is called Rex" {
+		t.Errorf("Search = %+v, %v", hits, err)
+	}
+	passages, err := s.SearchDocuments(ctx, "dm:alice", "when does Rex see the vet?", 1, 0.1)
+	if err != nil || len(passages) != 1 || passages[0].Title != "Rex's vet" || passages[0].Source != "vet.pdf" ||
+		passages[0].Text != "Rex sees the vet on Mondays" {
+		t.Errorf("SearchDocuments = %+v, %v", passages, err)
+	}
+
+	// Without the key the memories cannot be read
+	if _, err := openTestStore(t, path, e, "embed-1").List(ctx, "dm:alice"); !errors.Is(err, memory.ErrUndecryptable) {
+		t.Errorf("List without the key error = %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+// Package recap posts a daily summary of what was said in selected chats.
+// The messages are read back from the platform, so a chat can only be
+// summarized on channels that expose their history to the bot.
+package recap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// DefaultPrompt is used when recaps.prompt is empty.
+const DefaultPrompt = `Summarize what happened in the chat "{{.Chat}}" on {{.Date}} for someone who missed it. ` +
+	`Group related messages into topics, note decisions, open questions, and anything people are waiting on, ` +
+	`and keep it under 200 words. Do not use any tools.
+
+{{.Count}} messages:
+{{.Messages}}`
+
+const (
+	defaultMaxMessages = 500
+	// maxTranscriptRunes bounds the transcript handed to the model; the
+	// oldest lines are dropped first.
+	maxTranscriptRunes = 24000
+	runTimeout         = 5 * time.Minute
+	// header starts every posted recap; earlier recaps are left out of the
+	// next one.
+	header = "📋 Recap of "
+)
+
+// Message is one chat message to summarize.
+type Message struct {
+	Time   time.Time
+	Author string
+	Text   string
+}
+
+// History returns up to limit messages of a chat sent since the given time,
+// oldest first.
+type History func(ctx context.Context, channel, chatID string, since time.Time, limit int) ([]Message, error)
+
+// Summarizer turns a rendered prompt into the summary text.
+type Summarizer func(ctx context.Context, prompt string) (string, error)
+
+// PromptData is what a prompt template can reference.
+type PromptData struct {
+	Chat     string
+	Date     string
+	Count    int
+	Messages string
+}
+
+// Service posts the recaps once a day at the configured hour.
+type Service struct {
+	cfg       config.RecapsConfig
+	history   History
+	summarize Summarizer
+	prompt    *template.Template
+
+	mu       sync.Mutex
+	bus      *bus.MessageBus
+	paused   bool
+	stopChan chan struct{}
+}
+
+// NewService creates a recap service that reads chats with history and
+// summarizes them with summarize.
+func NewService(cfg config.RecapsConfig, history History, summarize Summarizer) *Service {
+	return &Service{cfg: cfg, history: history, summarize: summarize}
+}
+
+// SetBus sets the message bus recaps are posted on.
+func (s *Service) SetBus(msgBus *bus.MessageBus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bus = msgBus
+}
+
+// SetPaused skips scheduled recaps without stopping the service.
+func (s *Service) SetPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+}
+
+// Start validates the targets and prompt and waits for the first run.
+func (s *Service) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.cfg.Enabled || s.stopChan != nil {
+		return nil
+	}
+	if len(s.cfg.Targets) == 0 {
+		return errors.New("recaps: no targets configured")
+	}
+	for i, t := range s.cfg.Targets {
+		if t.Channel == "" || t.ChatID == "" {
+			return fmt.Errorf("recaps: target %d needs channel and chat_id", i+1)
+		}
+	}
+	text := s.cfg.Prompt
+	if text == "" {
+		text = DefaultPrompt
+	}
+	prompt, err := template.New("recap").Parse(text)
+	if err != nil {
+		return fmt.Errorf("recaps: bad prompt: %w", err)
+	}
+	s.prompt = prompt
+
+	s.stopChan = make(chan struct{})
+	go s.runLoop(s.stopChan)
+	logger.InfoCF("recap", "Daily recaps scheduled", map[string]any{
+		"targets": len(s.cfg.Targets),
+		"next":    s.NextRun(time.Now()).Format(time.RFC3339),
+	})
+	return nil
+}
+
+// Stop cancels the schedule.
+func (s *Service) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopChan == nil {
+		return
+	}
+	close(s.stopChan)
+	s.stopChan = nil
+}
+
+func (s *Service) runLoop(stopChan chan struct{}) {
+	for {
+		next := s.NextRun(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.mu.Lock()
+		paused := s.paused
+		s.mu.Unlock()
+		if paused {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+		s.RunAll(ctx, next)
+		cancel()
+	}
+}
+
+// NextRun returns the first scheduled run strictly after now.
+func (s *Service) NextRun(now time.Time) time.Time {
+	hour := min(max(s.cfg.Hour, 0), 23)
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// RunAll posts the recap of the 24 hours before end for every target. A
+// failing target is logged and does not stop the others.
+func (s *Service) RunAll(ctx context.Context, end time.Time) {
+	for _, t := range s.cfg.Targets {
+		if err := s.Run(ctx, t, end); err != nil {
+			logger.WarnCF("recap", "Recap failed", map[string]any{
+				"channel": t.Channel,
+				"chat_id": t.ChatID,
+				"error":   err.Error(),
+			})
+		}
+	}
+}
+
+// Run summarizes the 24 hours of target before end and posts the summary.
+// Nothing is posted for a quiet day.
+func (s *Service) Run(ctx context.Context, t config.RecapTarget, end time.Time) error {
+	limit := s.cfg.MaxMessages
+	if limit <= 0 {
+		limit = defaultMaxMessages
+	}
+	since := end.AddDate(0, 0, -1)
+	msgs, err := s.history(ctx, t.Channel, t.ChatID, since, limit)
+	if err != nil {
+		return fmt.Errorf("read history: %w", err)
+	}
+	var day []Message
+	for _, m := range msgs {
+		if m.Time.Before(end) && strings.TrimSpace(m.Text) != "" && !strings.HasPrefix(m.Text, header) {
+			day = append(day, m)
+		}
+	}
+	if len(day) == 0 {
+		return nil
+	}
+
+	name := t.Name
+	if name == "" {
+		name = t.ChatID
+	}
+	var prompt bytes.Buffer
+	if err := s.prompt.Execute(&prompt, PromptData{
+		Chat:     name,
+		Date:     since.Format("Monday, January 2"),
+		Count:    len(day),
+		Messages: renderTranscript(day),
+	}); err != nil {
+		return fmt.Errorf("render prompt: %w", err)
+	}
+	summary, err := s.summarize(ctx, prompt.String())
+	if err != nil {
+		return fmt.Errorf("summarize: %w", err)
+	}
+
+	s.mu.Lock()
+	msgBus := s.bus
+	s.mu.Unlock()
+	if msgBus == nil {
+		return errors.New("message bus not configured")
+	}
+	postTo := t.PostTo
+	if postTo == "" {
+		postTo = t.ChatID
+	}
+	if err := msgBus.PublishOutbound(ctx, bus.OutboundMessage{
+		Channel: t.Channel,
+		ChatID:  postTo,
+		Content: fmt.Sprintf("%s%s for %s\n\n%s", header, name, since.Format("Jan 2"), strings.TrimSpace(summary)),
+	}); err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+	logger.InfoCF("recap", "Recap posted", map[string]any{
+		"channel":  t.Channel,
+		"chat_id":  t.ChatID,
+		"post_to":  postTo,
+		"messages": len(day),
+	})
+	return nil
+}
+
+// renderTranscript formats messages one per line, dropping the oldest ones
+// when the transcript would exceed maxTranscriptRunes.
+func renderTranscript(msgs []Message) string {
+	lines := make([]string, len(msgs))
+	total := 0
+	start := len(msgs)
+	for i := len(msgs) - 1; i >= 0; i-- {
+		m := msgs[i]
+		text := strings.Join(strings.Fields(m.Text), " ")
+		lines[i] = fmt.Sprintf("[%s] %s: %s", m.Time.Local().Format("15:04"), m.Author, text)
+		total += len([]rune(lines[i])) + 1
+		if total > maxTranscriptRunes && start < len(msgs) {
+			break
+		}
+		start = i
+	}
+	return strings.Join(lines[start:], "\n")
+}
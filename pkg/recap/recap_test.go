@@ -0,0 +1,134 @@
+package recap
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestService(t *testing.T, msgs []Message, summarize Summarizer) (*Service, *bus.MessageBus) {
+	t.Helper()
+	history := func(_ context.Context, channel, chatID string, since time.Time, limit int) ([]Message, error) {
+		if channel != "discord" || chatID != "c1" {
+			return nil, errors.New("unexpected chat " + channel + ":" + chatID)
+		}
+		var out []Message
+		for _, m := range msgs {
+			if !m.Time.Before(since) && len(out) < limit {
+				out = append(out, m)
+			}
+		}
+		return out, nil
+	}
+	svc := NewService(config.RecapsConfig{
+		Enabled: true,
+		Hour:    18,
+		Targets: []config.RecapTarget{{Name: "general", Channel: "discord", ChatID: "c1"}},
+	}, history, summarize)
+	msgBus := bus.NewMessageBus()
+	t.Cleanup(msgBus.Close)
+	svc.SetBus(msgBus)
+	if err := svc.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(svc.Stop)
+	return svc, msgBus
+}
+
+func TestRun_PostsSummary(t *testing.T) {
+	end := time.Date(2026, 3, 3, 18, 0, 0, 0, time.Local)
+	msgs := []Message{
+		{Time: end.Add(-30 * time.Hour), Author: "ann", Text: "yesterday's news"},
+		{Time: end.Add(-3 * time.Hour), Author: "ann", Text: "ship it\non friday?"},
+		{Time: end.Add(-2 * time.Hour), Author: "bot", Text: header + "general for Mar 1\n\nold recap"},
+		{Time: end.Add(-time.Hour), Author: "bob", Text: "yes"},
+	}
+	var prompt string
+	svc, msgBus := newTestService(t, msgs, func(_ context.Context, p string) (string, error) {
+		prompt = p
+		return "  Ann and Bob agreed to ship on Friday.  ", nil
+	})
+
+	if err := svc.Run(context.Background(), svc.cfg.Targets[0], end); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompt, `"general"`) || !strings.Contains(prompt, "2 messages:") ||
+		!strings.Contains(prompt, "ann: ship it on friday?") || strings.Contains(prompt, "yesterday") ||
+		strings.Contains(prompt, "old recap") {
+		t.Errorf("prompt = %q", prompt)
+	}
+	select {
+	case out := <-msgBus.OutboundChan():
+		want := header + "general for Mar 2\n\nAnn and Bob agreed to ship on Friday."
+		if out.Channel != "discord" || out.ChatID != "c1" || out.Content != want {
+			t.Errorf("posted %+v", out)
+		}
+	default:
+		t.Fatal("nothing posted")
+	}
+}
+
+func TestRun_QuietDayAndPostTo(t *testing.T) {
+	end := time.Now()
+	called := false
+	svc, msgBus := newTestService(t, nil, func(context.Context, string) (string, error) {
+		called = true
+		return "summary", nil
+	})
+	if err := svc.Run(context.Background(), svc.cfg.Targets[0], end); err != nil || called {
+		t.Fatalf("quiet day: err %v, summarized %v", err, called)
+	}
+
+	svc.history = func(context.Context, string, string, time.Time, int) ([]Message, error) {
+		return []Message{{Time: end.Add(-time.Minute), Author: "ann", Text: "hi"}}, nil
+	}
+	target := config.RecapTarget{Channel: "discord", ChatID: "c1", PostTo: "dm-42"}
+	if err := svc.Run(context.Background(), target, end); err != nil {
+		t.Fatal(err)
+	}
+	if out := <-msgBus.OutboundChan(); out.ChatID != "dm-42" || !strings.HasPrefix(out.Content, header+"c1 for") {
+		t.Errorf("posted %+v", out)
+	}
+}
+
+func TestStart_Validates(t *testing.T) {
+	bad := []config.RecapsConfig{
+		{Enabled: true},
+		{Enabled: true, Targets: []config.RecapTarget{{Channel: "discord"}}},
+		{Enabled: true, Prompt: "{{.Nope", Targets: []config.RecapTarget{{Channel: "discord", ChatID: "c1"}}},
+	}
+	for i, cfg := range bad {
+		if err := NewService(cfg, nil, nil).Start(); err == nil {
+			t.Errorf("config %d started", i)
+		}
+	}
+	if err := NewService(config.RecapsConfig{}, nil, nil).Start(); err != nil {
+		t.Errorf("disabled service: %v", err)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	svc := NewService(config.RecapsConfig{Hour: 18}, nil, nil)
+	morning := time.Date(2026, 3, 3, 9, 0, 0, 0, time.Local)
+	if got := svc.NextRun(morning); !got.Equal(time.Date(2026, 3, 3, 18, 0, 0, 0, time.Local)) {
+		t.Errorf("NextRun(morning) = %v", got)
+	}
+	evening := time.Date(2026, 3, 3, 18, 0, 0, 0, time.Local)
+	if got := svc.NextRun(evening); !got.Equal(time.Date(2026, 3, 4, 18, 0, 0, 0, time.Local)) {
+		t.Errorf("NextRun(evening) = %v", got)
+	}
+}
+
+func TestRenderTranscript_DropsOldest(t *testing.T) {
+	long := strings.Repeat("x", maxTranscriptRunes/2)
+	msgs := []Message{{Author: "a", Text: "first " + long}, {Author: "b", Text: "second " + long}, {Author: "c", Text: "third"}}
+	got := renderTranscript(msgs)
+	if strings.Contains(got, "first") || !strings.Contains(got, "second") || !strings.HasSuffix(got, "c: third") {
+		t.Errorf("transcript kept the wrong lines: %.60q…", got)
+	}
+}
@@ -0,0 +1,40 @@
+// Package requestid generates correlation IDs for inbound messages and
+// carries them through a context, so one message can be traced across logs,
+// provider calls, and usage records.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Header is the HTTP header used to forward the ID to upstream APIs.
+// OpenAI echoes it back in its own logs; other providers ignore it.
+const Header = "X-Client-Request-Id"
+
+type ctxKey struct{}
+
+// New returns a random ID such as "req_3f9a12c4e8b07d61".
+func New() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return "req_" + hex.EncodeToString(b[:])
+}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the ID carried by ctx, or "".
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
@@ -0,0 +1,30 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	a, b := New(), New()
+	if !strings.HasPrefix(a, "req_") || len(a) != len("req_")+16 {
+		t.Errorf("New() = %q", a)
+	}
+	if a == b {
+		t.Error("IDs should be unique")
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if FromContext(ctx) != "" {
+		t.Error("empty context should carry no ID")
+	}
+	if NewContext(ctx, "") != ctx {
+		t.Error("empty ID should not wrap the context")
+	}
+	if got := FromContext(NewContext(ctx, "req_1")); got != "req_1" {
+		t.Errorf("FromContext = %q", got)
+	}
+}
@@ -0,0 +1,275 @@
+// Package respcache answers a prompt the agent saw recently, with the same
+// system prompt and model, from the reply it gave then instead of asking the
+// LLM again. Recent replies are kept in memory, least recently used first out,
+// and optionally in a file so they survive a restart.
+package respcache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultTTL        = time.Hour
+	defaultMaxEntries = 500
+)
+
+// Path returns the cache file of a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "response_cache.json")
+}
+
+// Key identifies a prompt. Case and runs of whitespace in the prompt do not
+// matter; the system prompt, the conversation it was asked in, and the model
+// must match exactly.
+func Key(prompt, systemPrompt, conversation, model string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(prompt)), " ")
+	sum := sha256.Sum256([]byte(model + "\x00" + systemPrompt + "\x00" + conversation + "\x00" + normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+type entry struct {
+	Key      string    `json:"key"`
+	Response string    `json:"response"`
+	Stored   time.Time `json:"stored"`
+	Channel  string    `json:"channel,omitempty"`
+	SenderID string    `json:"sender_id,omitempty"`
+}
+
+// Cache holds recent replies. It is safe for concurrent use; a nil *Cache
+// holds nothing.
+type Cache struct {
+	path string
+
+	mu      sync.Mutex
+	cfg     config.ResponseCacheConfig
+	order   *list.List // of *entry, most recently used first
+	entries map[string]*list.Element
+}
+
+// New returns a cache with the replies saved in path, when the config
+// persists them.
+func New(cfg config.ResponseCacheConfig, path string) *Cache {
+	c := &Cache{path: path, cfg: cfg, order: list.New(), entries: make(map[string]*list.Element)}
+	if !cfg.Persist {
+		return c
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var saved []*entry
+	if err := json.Unmarshal(data, &saved); err != nil {
+		logger.WarnCF("respcache", "Ignoring unreadable response cache", map[string]any{
+			"path":  path,
+			"error": err.Error(),
+		})
+		return c
+	}
+	// saved is most recent first
+	for _, e := range saved {
+		if !c.expired(e, time.Now()) {
+			c.entries[e.Key] = c.order.PushBack(e)
+		}
+	}
+	c.trimLocked()
+	return c
+}
+
+// Configure applies a reloaded config. Cached replies are kept while the
+// cache stays enabled.
+func (c *Cache) Configure(cfg config.ResponseCacheConfig) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg = cfg
+	if !cfg.Enabled {
+		c.order.Init()
+		clear(c.entries)
+	}
+	c.trimLocked()
+}
+
+// Enabled reports whether replies on channel are cached.
+func (c *Cache) Enabled(channel string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.cfg.Enabled {
+		return false
+	}
+	if len(c.cfg.Channels) == 0 {
+		return true
+	}
+	for _, ch := range c.cfg.Channels {
+		if ch == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the reply cached for key, if it has not expired.
+func (c *Cache) Get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	e := el.Value.(*entry)
+	if c.expired(e, time.Now()) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return e.Response, true
+}
+
+// Put caches response for key, given to senderID on channel, dropping the
+// least recently used reply when the cache is full.
+func (c *Cache) Put(key, response, channel, senderID string) {
+	if c == nil || response == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.cfg.Enabled {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+	}
+	c.entries[key] = c.order.PushFront(&entry{
+		Key: key, Response: response, Stored: time.Now(), Channel: channel, SenderID: senderID,
+	})
+	c.trimLocked()
+	if c.cfg.Persist {
+		if err := c.saveLocked(); err != nil {
+			logger.WarnCF("respcache", "Failed to save response cache", map[string]any{
+				"path":  c.path,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// ForgetSender drops the replies given to senderID on channel and returns
+// how many were dropped. The cache file is cleared of them too, even when
+// the cache no longer persists.
+func (c *Cache) ForgetSender(channel, senderID string) (int, error) {
+	if c == nil {
+		return 0, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*entry); e.Channel == channel && e.SenderID == senderID {
+			c.order.Remove(el)
+			delete(c.entries, e.Key)
+			n++
+		}
+		el = next
+	}
+	if c.cfg.Persist {
+		return n, c.saveLocked()
+	}
+	dropped, err := forgetSaved(c.path, channel, senderID)
+	return max(n, dropped), err
+}
+
+// forgetSaved drops the replies given to senderID on channel from the cache
+// file at path, if there is one.
+func forgetSaved(path, channel, senderID string) (int, error) {
+	if path == "" {
+		return 0, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var saved []*entry
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return 0, err
+	}
+	kept := saved[:0]
+	for _, e := range saved {
+		if e.Channel != channel || e.SenderID != senderID {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(saved) {
+		return 0, nil
+	}
+	data, err = json.Marshal(kept)
+	if err != nil {
+		return 0, err
+	}
+	return len(saved) - len(kept), fileutil.WriteFileAtomic(path, data, 0o600)
+}
+
+// Len returns the number of cached replies.
+func (c *Cache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) expired(e *entry, now time.Time) bool {
+	ttl := defaultTTL
+	if c.cfg.TTLMinutes > 0 {
+		ttl = time.Duration(c.cfg.TTLMinutes) * time.Minute
+	}
+	return now.Sub(e.Stored) >= ttl
+}
+
+func (c *Cache) trimLocked() {
+	limit := defaultMaxEntries
+	if c.cfg.MaxEntries > 0 {
+		limit = c.cfg.MaxEntries
+	}
+	for c.order.Len() > limit {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*entry).Key)
+	}
+}
+
+func (c *Cache) saveLocked() error {
+	saved := make([]*entry, 0, c.order.Len())
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		saved = append(saved, el.Value.(*entry))
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(c.path, data, 0o600)
+}
@@ -0,0 +1,107 @@
+package respcache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestKey(t *testing.T) {
+	if Key("What is  Go?", "sys", "", "m") != Key("what is go?\n", "sys", "", "m") {
+		t.Error("case and whitespace should not change the key")
+	}
+	if Key("what is go?", "sys", "", "m") == Key("what is go?", "sys2", "", "m") ||
+		Key("what is go?", "sys", "", "m") == Key("what is go?", "sys", "", "m2") {
+		t.Error("system prompt and model should change the key")
+	}
+	if Key("yes", "sys", "user: deploy?", "m") == Key("yes", "sys", "user: delete?", "m") {
+		t.Error("the conversation should change the key")
+	}
+}
+
+func TestCache_GetPut(t *testing.T) {
+	c := New(config.ResponseCacheConfig{Enabled: true, MaxEntries: 2}, filepath.Join(t.TempDir(), "c.json"))
+	c.Put("a", "A", "telegram", "1")
+	c.Put("b", "B", "telegram", "1")
+	if got, ok := c.Get("a"); !ok || got != "A" {
+		t.Fatalf("Get(a) = %q, %v", got, ok)
+	}
+	c.Put("c", "C", "telegram", "1")
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("recently used entry was evicted")
+	}
+
+	el := c.entries["a"]
+	el.Value.(*entry).Stored = time.Now().Add(-2 * time.Hour)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expired entry was served")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len = %d, want 1", c.Len())
+	}
+}
+
+func TestCache_Persist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "c.json")
+	cfg := config.ResponseCacheConfig{Enabled: true, Persist: true, TTLMinutes: 5}
+	New(cfg, path).Put("a", "A", "telegram", "1")
+
+	if got, ok := New(cfg, path).Get("a"); !ok || got != "A" {
+		t.Fatalf("after reload Get(a) = %q, %v", got, ok)
+	}
+	cfg.Persist = false
+	if _, ok := New(cfg, path).Get("a"); ok {
+		t.Error("cache read from disk without persist")
+	}
+}
+
+func TestCache_Enabled(t *testing.T) {
+	var nilCache *Cache
+	if nilCache.Enabled("telegram") {
+		t.Error("nil cache enabled")
+	}
+	c := New(config.ResponseCacheConfig{Enabled: true, Channels: []string{"discord"}}, "")
+	if !c.Enabled("discord") || c.Enabled("telegram") {
+		t.Error("channel list not applied")
+	}
+	c.Put("a", "A", "telegram", "1")
+	c.Configure(config.ResponseCacheConfig{})
+	if c.Enabled("discord") || c.Len() != 0 {
+		t.Error("disabling did not clear the cache")
+	}
+}
+
+func TestCache_ForgetSender(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "c.json")
+	cfg := config.ResponseCacheConfig{Enabled: true, Persist: true}
+	c := New(cfg, path)
+	c.Put("a", "A", "telegram", "1")
+	c.Put("b", "B", "telegram", "2")
+	c.Put("c", "C", "discord", "1")
+
+	if n, err := c.ForgetSender("telegram", "1"); err != nil || n != 1 {
+		t.Fatalf("ForgetSender() = %d, %v", n, err)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("forgotten reply still cached")
+	}
+	reloaded := New(cfg, path)
+	if _, ok := reloaded.Get("a"); ok || reloaded.Len() != 2 {
+		t.Errorf("after reload Len = %d, forgotten reply found = %v", reloaded.Len(), ok)
+	}
+
+	// A cache that no longer persists still clears the file
+	cfg.Persist = false
+	if n, err := New(cfg, path).ForgetSender("telegram", "2"); err != nil || n != 1 {
+		t.Errorf("ForgetSender() without persist = %d, %v", n, err)
+	}
+	cfg.Persist = true
+	if _, ok := New(cfg, path).Get("b"); ok {
+		t.Error("forgotten reply left in the file")
+	}
+}
@@ -0,0 +1,71 @@
+package routing
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// DefaultCanaryLabel names the canary variant when no label is configured.
+const DefaultCanaryLabel = "canary"
+
+// CanaryConfig holds the validated canary rollout settings.
+// It mirrors config.CanaryConfig for the same reason RouterConfig does.
+type CanaryConfig struct {
+	// Label identifies the variant in logs and usage metrics.
+	Label string
+
+	// Percent is the share of sessions, in [0, 100], sent to the canary.
+	Percent float64
+
+	// Channels lists targets that always get the canary, either a whole
+	// channel ("telegram") or a single chat ("telegram:12345").
+	Channels []string
+}
+
+// Canary decides which sessions take part in a rollout.
+// It is safe for concurrent use from multiple goroutines.
+type Canary struct {
+	cfg     CanaryConfig
+	targets map[string]struct{}
+}
+
+// NewCanary creates a Canary. Percent is clamped to [0, 100].
+func NewCanary(cfg CanaryConfig) *Canary {
+	if cfg.Label == "" {
+		cfg.Label = DefaultCanaryLabel
+	}
+	cfg.Percent = min(max(cfg.Percent, 0), 100)
+
+	targets := make(map[string]struct{}, len(cfg.Channels))
+	for _, ch := range cfg.Channels {
+		if ch = strings.TrimSpace(ch); ch != "" {
+			targets[ch] = struct{}{}
+		}
+	}
+	return &Canary{cfg: cfg, targets: targets}
+}
+
+// Label returns the canary variant name.
+func (c *Canary) Label() string {
+	return c.cfg.Label
+}
+
+// Assign reports whether the session belongs to the canary. Pinned channels
+// always do; other sessions are bucketed by a stable hash of sessionKey, so
+// repeated calls agree and raising Percent only adds sessions.
+func (c *Canary) Assign(channel, chatID, sessionKey string) bool {
+	if _, ok := c.targets[channel]; ok {
+		return true
+	}
+	if _, ok := c.targets[channel+":"+chatID]; ok {
+		return true
+	}
+	if c.cfg.Percent <= 0 {
+		return false
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(sessionKey))
+	bucket := h.Sum32() % 10000
+	return float64(bucket) < c.cfg.Percent*100
+}
@@ -0,0 +1,51 @@
+package routing
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCanary_PinnedChannels(t *testing.T) {
+	c := NewCanary(CanaryConfig{Channels: []string{"discord", "telegram:42"}})
+
+	if !c.Assign("discord", "any", "s1") {
+		t.Error("whole pinned channel should be on the canary")
+	}
+	if !c.Assign("telegram", "42", "s2") {
+		t.Error("pinned chat should be on the canary")
+	}
+	if c.Assign("telegram", "43", "s3") {
+		t.Error("unpinned chat with 0% must stay stable")
+	}
+	if c.Label() != DefaultCanaryLabel {
+		t.Errorf("Label = %q, want default", c.Label())
+	}
+}
+
+func TestCanary_PercentIsStickyAndProportional(t *testing.T) {
+	c := NewCanary(CanaryConfig{Percent: 20})
+
+	hits := 0
+	for i := range 5000 {
+		key := fmt.Sprintf("session-%d", i)
+		got := c.Assign("cli", "", key)
+		if got != c.Assign("cli", "", key) {
+			t.Fatalf("assignment for %s is not stable", key)
+		}
+		if got {
+			hits++
+		}
+	}
+	if hits < 800 || hits > 1200 {
+		t.Errorf("expected about 20%% of sessions on the canary, got %d/5000", hits)
+	}
+}
+
+func TestCanary_PercentClamped(t *testing.T) {
+	if !NewCanary(CanaryConfig{Percent: 150}).Assign("cli", "", "x") {
+		t.Error("percent above 100 should put every session on the canary")
+	}
+	if NewCanary(CanaryConfig{Percent: -5}).Assign("cli", "", "x") {
+		t.Error("negative percent should put no session on the canary")
+	}
+}
@@ -14,6 +14,9 @@ type RouteInput struct {
 	ParentPeer *RoutePeer
 	GuildID    string
 	TeamID     string
+	// Agents, when set, limits the route to these agents: bindings to any
+	// other agent are skipped and the first one replaces the default agent.
+	Agents []string
 }
 
 // ResolvedRoute is the result of agent routing.
@@ -51,9 +54,15 @@ func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 	identityLinks := r.cfg.Session.IdentityLinks
 
 	bindings := r.filterBindings(channel, accountID)
+	if len(input.Agents) > 0 {
+		bindings = keepAgents(bindings, input.Agents)
+	}
 
 	choose := func(agentID string, matchedBy string) ResolvedRoute {
 		resolvedAgentID := r.pickAgentID(agentID)
+		if len(input.Agents) > 0 && !containsAgent(input.Agents, resolvedAgentID) {
+			resolvedAgentID = NormalizeAgentID(input.Agents[0])
+		}
 		sessionKey := strings.ToLower(BuildAgentPeerSessionKey(SessionKeyParams{
 			AgentID:       resolvedAgentID,
 			Channel:       channel,
@@ -133,6 +142,26 @@ func (r *RouteResolver) filterBindings(channel, accountID string) []config.Agent
 	return filtered
 }
 
+// keepAgents returns the bindings that route to one of agents.
+func keepAgents(bindings []config.AgentBinding, agents []string) []config.AgentBinding {
+	var kept []config.AgentBinding
+	for _, b := range bindings {
+		if containsAgent(agents, NormalizeAgentID(b.AgentID)) {
+			kept = append(kept, b)
+		}
+	}
+	return kept
+}
+
+func containsAgent(agents []string, agentID string) bool {
+	for _, id := range agents {
+		if NormalizeAgentID(id) == agentID {
+			return true
+		}
+	}
+	return false
+}
+
 func matchesAccountID(matchAccountID, actual string) bool {
 	trimmed := strings.TrimSpace(matchAccountID)
 	if trimmed == "" {
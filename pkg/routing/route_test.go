@@ -295,3 +295,24 @@ func TestResolveRoute_NoDefaultUsesFirst(t *testing.T) {
 		t.Errorf("AgentID = %q, want 'alpha' (first in list)", route.AgentID)
 	}
 }
+
+func TestResolveRoute_LimitedToAgents(t *testing.T) {
+	agents := []config.AgentConfig{{ID: "main", Default: true}, {ID: "acme"}, {ID: "acme-support"}}
+	bindings := []config.AgentBinding{
+		{AgentID: "main", Match: config.BindingMatch{Channel: "discord", GuildID: "g1"}},
+		{AgentID: "acme-support", Match: config.BindingMatch{Channel: "discord", TeamID: "t1"}},
+	}
+	r := NewRouteResolver(testConfig(agents, bindings))
+
+	route := r.ResolveRoute(RouteInput{Channel: "discord", GuildID: "g1", Agents: []string{"acme", "acme-support"}})
+	if route.AgentID != "acme" || route.MatchedBy != "default" {
+		t.Errorf("binding to an excluded agent used: %+v", route)
+	}
+	if route.SessionKey != "agent:acme:main" {
+		t.Errorf("SessionKey = %q", route.SessionKey)
+	}
+	route = r.ResolveRoute(RouteInput{Channel: "discord", TeamID: "t1", Agents: []string{"acme", "acme-support"}})
+	if route.AgentID != "acme-support" || route.MatchedBy != "binding.team" {
+		t.Errorf("binding to an allowed agent skipped: %+v", route)
+	}
+}
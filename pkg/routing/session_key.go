@@ -120,6 +120,21 @@ func ParseAgentSessionKey(sessionKey string) *ParsedSessionKey {
 	return &ParsedSessionKey{AgentID: agentID, Rest: rest}
 }
 
+// SessionChannel returns the channel of a session key built per channel,
+// such as "agent:main:discord:group:123", or "" for sessions shared across
+// channels ("agent:main:main", "agent:main:direct:alice").
+func SessionChannel(sessionKey string) string {
+	parsed := ParseAgentSessionKey(sessionKey)
+	if parsed == nil {
+		return ""
+	}
+	parts := strings.Split(parsed.Rest, ":")
+	if len(parts) < 3 || strings.EqualFold(parts[0], "subagent") {
+		return ""
+	}
+	return parts[0]
+}
+
 // IsSubagentSessionKey returns true if the session key represents a subagent.
 func IsSubagentSessionKey(sessionKey string) bool {
 	raw := strings.TrimSpace(sessionKey)
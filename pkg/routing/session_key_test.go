@@ -205,3 +205,21 @@ func TestIsSubagentSessionKey(t *testing.T) {
 		}
 	}
 }
+
+func TestSessionChannel(t *testing.T) {
+	tests := map[string]string{
+		"agent:main:telegram:direct:user123":     "telegram",
+		"agent:main:discord:group:42":            "discord",
+		"agent:main:slack:acct1:direct:u1":       "slack",
+		"agent:main:discord:group:42#experiment": "discord",
+		"agent:main:main":                        "",
+		"agent:main:direct:alice":                "",
+		"agent:main:subagent:task-1:worker:x":    "",
+		"not-a-session-key":                      "",
+	}
+	for key, want := range tests {
+		if got := SessionChannel(key); got != want {
+			t.Errorf("SessionChannel(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/database"
+)
+
+// timeFormat is fixed-width so stored times sort and compare as text.
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// Entry is one indexed message of a conversation.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Role       string    `json:"role"` // "user" or "assistant"
+	Text       string    `json:"text"`
+	AgentID    string    `json:"agent_id"`
+	SessionKey string    `json:"session_key"` // base key, shared by the session's branches
+	Channel    string    `json:"channel"`
+	ChatID     string    `json:"chat_id"`
+	SenderID   string    `json:"sender_id,omitempty"`
+	Link       string    `json:"link,omitempty"` // the platform message the turn started with
+}
+
+// Match is an indexed message that matches a query.
+type Match struct {
+	Entry
+	Excerpt string `json:"excerpt"` // with the matching words in **bold**
+}
+
+// Filter narrows a query; empty fields match everything.
+type Filter struct {
+	AgentID    string
+	SessionKey string
+	Channel    string
+	ChatID     string
+	Since      time.Time
+	Limit      int
+}
+
+// Index keeps the messages of conversations in the message_search table of
+// the workspace database, an FTS5 full-text index, so /search finds them
+// after they left the history the model sees. A nil *Index holds nothing.
+type Index struct {
+	db *sql.DB
+}
+
+// OpenIndex opens the workspace database at path, migrating it if needed.
+func OpenIndex(ctx context.Context, path string) (*Index, error) {
+	db, err := database.Open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+// Close closes the database.
+func (x *Index) Close() error {
+	if x == nil {
+		return nil
+	}
+	return x.db.Close()
+}
+
+// Add indexes entries. A zero Time means now.
+func (x *Index) Add(ctx context.Context, entries ...Entry) error {
+	if x == nil {
+		return nil
+	}
+	tx, err := x.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, e := range entries {
+		if strings.TrimSpace(e.Text) == "" {
+			continue
+		}
+		if e.Time.IsZero() {
+			e.Time = time.Now()
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO message_search (
+			text, role, agent_id, session_key, channel, chat_id, sender_id, link, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.Text, e.Role, e.AgentID, e.SessionKey, e.Channel, e.ChatID, e.SenderID, e.Link,
+			e.Time.UTC().Format(timeFormat),
+		); err != nil {
+			return fmt.Errorf("index message: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Query returns the messages that contain every word of query, best matches
+// first and newer ones first among equals. Words are matched whole,
+// ignoring case and accents; query syntax is not interpreted.
+func (x *Index) Query(ctx context.Context, query string, f Filter) ([]Match, error) {
+	match := matchExpression(query)
+	if x == nil || match == "" {
+		return nil, nil
+	}
+	where := []string{"message_search MATCH ?"}
+	args := []any{match}
+	for _, cond := range []struct{ column, value string }{
+		{"agent_id", f.AgentID},
+		{"session_key", f.SessionKey},
+		{"channel", f.Channel},
+		{"chat_id", f.ChatID},
+	} {
+		if cond.value != "" {
+			where = append(where, cond.column+" = ?")
+			args = append(args, cond.value)
+		}
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.Since.UTC().Format(timeFormat))
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	args = append(args, limit)
+
+	rows, err := x.db.QueryContext(ctx, `SELECT text, role, agent_id, session_key, channel, chat_id,
+		sender_id, link, created_at, snippet(message_search, 0, '**', '**', '…', 24)
+		FROM message_search WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY rank, created_at DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search messages: %w", err)
+	}
+	defer rows.Close()
+	var matches []Match
+	for rows.Next() {
+		var m Match
+		var created string
+		if err := rows.Scan(&m.Text, &m.Role, &m.AgentID, &m.SessionKey, &m.Channel, &m.ChatID,
+			&m.SenderID, &m.Link, &created, &m.Excerpt); err != nil {
+			return nil, err
+		}
+		m.Time, _ = time.Parse(timeFormat, created)
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// ForgetSession removes the messages of a session and its branches.
+func (x *Index) ForgetSession(ctx context.Context, sessionKey string) (int, error) {
+	if x == nil {
+		return 0, nil
+	}
+	res, err := x.db.ExecContext(ctx, `DELETE FROM message_search WHERE session_key = ?`, sessionKey)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// Expire removes the messages older than maxAge returns for their channel,
+// as of now; a maxAge of 0 keeps a channel's messages.
+func (x *Index) Expire(ctx context.Context, now time.Time, maxAge func(channel string) time.Duration) (int, error) {
+	if x == nil {
+		return 0, nil
+	}
+	rows, err := x.db.QueryContext(ctx, `SELECT DISTINCT channel FROM message_search`)
+	if err != nil {
+		return 0, err
+	}
+	var channels []string
+	for rows.Next() {
+		var channel string
+		if err := rows.Scan(&channel); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		channels = append(channels, channel)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, channel := range channels {
+		age := maxAge(channel)
+		if age <= 0 {
+			continue
+		}
+		res, err := x.db.ExecContext(ctx, `DELETE FROM message_search WHERE channel = ? AND created_at < ?`,
+			channel, now.Add(-age).UTC().Format(timeFormat))
+		if err != nil {
+			return total, err
+		}
+		n, _ := res.RowsAffected()
+		total += int(n)
+	}
+	return total, nil
+}
+
+// matchExpression turns query into an FTS5 expression that requires each of
+// its words, quoted so that operators and punctuation are taken literally.
+func matchExpression(query string) string {
+	words := strings.Fields(query)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
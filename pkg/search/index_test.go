@@ -0,0 +1,74 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIndex_Query(t *testing.T) {
+	ctx := context.Background()
+	x, err := OpenIndex(ctx, filepath.Join(t.TempDir(), "picoclaw.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+
+	weeksAgo := time.Now().Add(-21 * 24 * time.Hour)
+	err = x.Add(ctx,
+		Entry{Time: weeksAgo, Role: "user", Text: "How do we rotate the Postgres café credentials?",
+			SessionKey: "s1", Channel: "discord", ChatID: "c1", Link: "https://discord.com/channels/1/2/3"},
+		Entry{Time: weeksAgo, Role: "assistant", Text: "Rotate the postgres credentials with vault write.",
+			SessionKey: "s1", Channel: "discord", ChatID: "c1", Link: "https://discord.com/channels/1/2/3"},
+		Entry{Role: "user", Text: "postgres credentials for another chat", SessionKey: "s2", Channel: "slack"},
+		Entry{Role: "user", Text: "   "},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := x.Query(ctx, "POSTGRES Credentials", Filter{SessionKey: "s1"})
+	if err != nil || len(matches) != 2 {
+		t.Fatalf("Query = %+v, %v", matches, err)
+	}
+	for _, m := range matches {
+		if m.Link != "https://discord.com/channels/1/2/3" || m.ChatID != "c1" || m.Time.IsZero() {
+			t.Errorf("match = %+v", m)
+		}
+	}
+	if m := matches[0]; m.Excerpt == "" || m.Excerpt == m.Text {
+		t.Errorf("excerpt = %q", m.Excerpt)
+	}
+	if matches, _ := x.Query(ctx, "cafe", Filter{}); len(matches) != 1 {
+		t.Errorf("accents: %+v", matches)
+	}
+	if matches, _ := x.Query(ctx, "postgres", Filter{Since: time.Now().Add(-time.Hour)}); len(matches) != 1 {
+		t.Errorf("since: %+v", matches)
+	}
+	// Query syntax is taken literally
+	for _, q := range []string{`postgres OR "`, "NOT postgres", "postgres*", "(credentials"} {
+		if _, err := x.Query(ctx, q, Filter{}); err != nil {
+			t.Errorf("Query(%q): %v", q, err)
+		}
+	}
+	if matches, _ := x.Query(ctx, "postgres NEAR", Filter{}); len(matches) != 0 {
+		t.Errorf("operator matched as a word: %+v", matches)
+	}
+
+	n, err := x.Expire(ctx, time.Now(), func(channel string) time.Duration {
+		if channel == "discord" {
+			return 7 * 24 * time.Hour
+		}
+		return 0
+	})
+	if err != nil || n != 2 {
+		t.Errorf("Expire = %d, %v", n, err)
+	}
+	if n, err := x.ForgetSession(ctx, "s2"); err != nil || n != 1 {
+		t.Errorf("ForgetSession = %d, %v", n, err)
+	}
+	if matches, _ := x.Query(ctx, "postgres", Filter{}); len(matches) != 0 {
+		t.Errorf("left after expiry and erasure: %+v", matches)
+	}
+}
@@ -0,0 +1,157 @@
+// Package search finds earlier messages of a conversation by keyword, for
+// /search. Messages are ranked by TF-IDF, with a bonus for containing the
+// query as a phrase. Index keeps messages in a full-text index, so they are
+// found after they left the session history.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// excerptRunes is the length of the excerpt shown for a hit.
+const excerptRunes = 160
+
+// Message is one searchable message of a conversation.
+type Message struct {
+	Role string // "user" or "assistant"
+	Text string
+	Link string // link to the message on its platform, if known
+}
+
+// Hit is a matching message.
+type Hit struct {
+	Message
+	Excerpt string
+	Score   float64
+}
+
+// Search returns up to limit messages ranked against query, newest first
+// among equal scores. Messages that match no query term are left out.
+func Search(msgs []Message, query string, limit int) []Hit {
+	terms := uniqueTerms(query)
+	if len(terms) == 0 || limit <= 0 {
+		return nil
+	}
+	phrase := strings.Join(tokenize(query), " ")
+
+	tfs := make([]map[string]int, len(msgs))
+	df := make(map[string]int)
+	for i, m := range msgs {
+		tf := make(map[string]int)
+		for _, tok := range tokenize(m.Text) {
+			tf[tok]++
+		}
+		for _, term := range terms {
+			if tf[term] > 0 {
+				df[term]++
+			}
+		}
+		tfs[i] = tf
+	}
+
+	type ranked struct {
+		hit   Hit
+		order int
+	}
+	var hits []ranked
+	n := float64(len(msgs))
+	for i, m := range msgs {
+		var score float64
+		for _, term := range terms {
+			if tf := tfs[i][term]; tf > 0 {
+				score += (1 + math.Log(float64(tf))) * math.Log(1+n/float64(df[term]))
+			}
+		}
+		if score == 0 {
+			continue
+		}
+		if len(terms) > 1 && strings.Contains(strings.Join(tokenize(m.Text), " "), phrase) {
+			score *= 2
+		}
+		hits = append(hits, ranked{
+			hit:   Hit{Message: m, Excerpt: Excerpt(m.Text, terms), Score: score},
+			order: i,
+		})
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].hit.Score != hits[j].hit.Score {
+			return hits[i].hit.Score > hits[j].hit.Score
+		}
+		return hits[i].order > hits[j].order
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	out := make([]Hit, len(hits))
+	for i, h := range hits {
+		out[i] = h.hit
+	}
+	return out
+}
+
+// Excerpt returns about excerptRunes of text around the first occurrence of
+// any of terms, on one line.
+func Excerpt(text string, terms []string) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= excerptRunes {
+		return string(runes)
+	}
+	lower := []rune(strings.ToLower(string(runes)))
+	at := -1
+	for _, term := range terms {
+		if i := indexRunes(lower, []rune(term)); i >= 0 && (at < 0 || i < at) {
+			at = i
+		}
+	}
+	start := max(0, at-excerptRunes/3)
+	end := min(len(runes), start+excerptRunes)
+	start = max(0, end-excerptRunes)
+
+	out := strings.TrimSpace(string(runes[start:end]))
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(runes) {
+		out += "…"
+	}
+	return out
+}
+
+func indexRunes(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if string(s[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+func uniqueTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, t := range tokenize(query) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// tokenize lowercases s and splits it into words, dropping one-letter words.
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := fields[:0]
+	for _, f := range fields {
+		if len([]rune(f)) > 1 {
+			out = append(out, f)
+		}
+	}
+	return out
+}
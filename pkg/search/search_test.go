@@ -0,0 +1,44 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearch_RanksMatches(t *testing.T) {
+	msgs := []Message{
+		{Role: "user", Text: "How do I deploy the staging server?"},
+		{Role: "assistant", Text: "Run make deploy with ENV=staging."},
+		{Role: "user", Text: "What's for lunch?"},
+		{Role: "user", Text: "The staging server is down again", Link: "https://example.com/3"},
+	}
+
+	hits := Search(msgs, "staging server", 5)
+	if len(hits) != 3 {
+		t.Fatalf("got %d hits, want 3: %+v", len(hits), hits)
+	}
+	// Phrase matches rank first; the newer one wins the tie.
+	if hits[0].Link != "https://example.com/3" || hits[1].Text != msgs[0].Text {
+		t.Errorf("order = %q, %q", hits[0].Text, hits[1].Text)
+	}
+	if got := Search(msgs, "LUNCH", 5); len(got) != 1 || got[0].Text != "What's for lunch?" {
+		t.Errorf("case-insensitive search = %+v", got)
+	}
+	if got := Search(msgs, "staging", 1); len(got) != 1 {
+		t.Errorf("limit ignored: %d hits", len(got))
+	}
+	if got := Search(msgs, "a", 5); got != nil {
+		t.Errorf("one-letter query matched %+v", got)
+	}
+}
+
+func TestExcerpt_CentersOnMatch(t *testing.T) {
+	text := strings.Repeat("filler words here ", 30) + "the needle is here " + strings.Repeat("more text ", 30)
+	got := Excerpt(text, []string{"needle"})
+	if !strings.Contains(got, "needle") || !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("Excerpt = %q", got)
+	}
+	if got := Excerpt("short\n  text", nil); got != "short text" {
+		t.Errorf("short Excerpt = %q", got)
+	}
+}
@@ -0,0 +1,180 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// EphemeralStore keeps the sessions marked with MarkEphemeral in memory only
+// and passes every other session through to the wrapped store. An ephemeral
+// session never reaches the wrapped store: it starts empty, is not saved, and
+// is wiped once it has been idle for its TTL or the store is closed.
+type EphemeralStore struct {
+	persistent SessionStore
+
+	mu       sync.Mutex
+	sessions map[string]*ephemeralSession
+	now      func() time.Time
+}
+
+type ephemeralSession struct {
+	history  []providers.Message
+	summary  string
+	ttl      time.Duration
+	lastUsed time.Time
+}
+
+// NewEphemeralStore wraps persistent.
+func NewEphemeralStore(persistent SessionStore) *EphemeralStore {
+	return &EphemeralStore{
+		persistent: persistent,
+		sessions:   make(map[string]*ephemeralSession),
+		now:        time.Now,
+	}
+}
+
+// MarkEphemeral keeps key in memory from now on, wiping it after ttl without
+// use. Marking an ephemeral session again refreshes its TTL.
+func (s *EphemeralStore) MarkEphemeral(key string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweepLocked(now)
+	if sess, ok := s.sessions[key]; ok {
+		sess.ttl = ttl
+		sess.lastUsed = now
+		return
+	}
+	s.sessions[key] = &ephemeralSession{ttl: ttl, lastUsed: now}
+}
+
+// IsEphemeral reports whether key is an ephemeral session that has not
+// expired.
+func (s *EphemeralStore) IsEphemeral(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked(s.now())
+	_, ok := s.sessions[key]
+	return ok
+}
+
+// Wipe forgets an ephemeral session immediately.
+func (s *EphemeralStore) Wipe(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+}
+
+// sweepLocked wipes the sessions that have been idle for their TTL.
+func (s *EphemeralStore) sweepLocked(now time.Time) {
+	for key, sess := range s.sessions {
+		if sess.ttl > 0 && now.Sub(sess.lastUsed) >= sess.ttl {
+			delete(s.sessions, key)
+		}
+	}
+}
+
+// ephemeral returns the live ephemeral session for key, or nil.
+func (s *EphemeralStore) ephemeral(key string) *ephemeralSession {
+	now := s.now()
+	s.sweepLocked(now)
+	sess := s.sessions[key]
+	if sess != nil {
+		sess.lastUsed = now
+	}
+	return sess
+}
+
+func (s *EphemeralStore) AddMessage(sessionKey, role, content string) {
+	s.AddFullMessage(sessionKey, providers.Message{Role: role, Content: content})
+}
+
+func (s *EphemeralStore) AddFullMessage(sessionKey string, msg providers.Message) {
+	s.mu.Lock()
+	if sess := s.ephemeral(sessionKey); sess != nil {
+		sess.history = append(sess.history, msg)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	s.persistent.AddFullMessage(sessionKey, msg)
+}
+
+func (s *EphemeralStore) GetHistory(key string) []providers.Message {
+	s.mu.Lock()
+	if sess := s.ephemeral(key); sess != nil {
+		history := make([]providers.Message, len(sess.history))
+		copy(history, sess.history)
+		s.mu.Unlock()
+		return history
+	}
+	s.mu.Unlock()
+	return s.persistent.GetHistory(key)
+}
+
+func (s *EphemeralStore) GetSummary(key string) string {
+	s.mu.Lock()
+	if sess := s.ephemeral(key); sess != nil {
+		summary := sess.summary
+		s.mu.Unlock()
+		return summary
+	}
+	s.mu.Unlock()
+	return s.persistent.GetSummary(key)
+}
+
+func (s *EphemeralStore) SetSummary(key, summary string) {
+	s.mu.Lock()
+	if sess := s.ephemeral(key); sess != nil {
+		sess.summary = summary
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	s.persistent.SetSummary(key, summary)
+}
+
+func (s *EphemeralStore) SetHistory(key string, history []providers.Message) {
+	s.mu.Lock()
+	if sess := s.ephemeral(key); sess != nil {
+		sess.history = make([]providers.Message, len(history))
+		copy(sess.history, history)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	s.persistent.SetHistory(key, history)
+}
+
+func (s *EphemeralStore) TruncateHistory(key string, keepLast int) {
+	s.mu.Lock()
+	if sess := s.ephemeral(key); sess != nil {
+		if keepLast <= 0 {
+			sess.history = nil
+		} else if len(sess.history) > keepLast {
+			sess.history = append([]providers.Message(nil), sess.history[len(sess.history)-keepLast:]...)
+		}
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	s.persistent.TruncateHistory(key, keepLast)
+}
+
+// Save persists key unless it is ephemeral.
+func (s *EphemeralStore) Save(key string) error {
+	if s.IsEphemeral(key) {
+		return nil
+	}
+	return s.persistent.Save(key)
+}
+
+// Close wipes every ephemeral session and closes the wrapped store.
+func (s *EphemeralStore) Close() error {
+	s.mu.Lock()
+	s.sessions = make(map[string]*ephemeralSession)
+	s.mu.Unlock()
+	return s.persistent.Close()
+}
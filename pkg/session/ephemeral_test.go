@@ -0,0 +1,81 @@
+package session
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEphemeralStore_KeepsMarkedSessionsInMemory(t *testing.T) {
+	dir := t.TempDir()
+	store := NewEphemeralStore(NewSessionManager(dir))
+
+	store.AddMessage("public", "user", "saved")
+	store.MarkEphemeral("private", time.Hour)
+	store.AddMessage("private", "user", "not saved")
+	store.AddMessage("private", "assistant", "reply")
+	store.SetSummary("private", "a summary")
+	if err := store.Save("private"); err != nil {
+		t.Fatalf("Save(private): %v", err)
+	}
+	if err := store.Save("public"); err != nil {
+		t.Fatalf("Save(public): %v", err)
+	}
+
+	if got := store.GetHistory("private"); len(got) != 2 || got[0].Content != "not saved" {
+		t.Errorf("private history = %+v", got)
+	}
+	if got := store.GetSummary("private"); got != "a summary" {
+		t.Errorf("private summary = %q", got)
+	}
+	if got := store.GetHistory("public"); len(got) != 1 {
+		t.Errorf("public history = %+v", got)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "public.json" {
+		t.Errorf("session files = %v, want only public.json", entries)
+	}
+
+	store.TruncateHistory("private", 1)
+	if got := store.GetHistory("private"); len(got) != 1 || got[0].Content != "reply" {
+		t.Errorf("truncated private history = %+v", got)
+	}
+}
+
+func TestEphemeralStore_WipesIdleSessions(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := NewEphemeralStore(NewSessionManager(""))
+	store.now = func() time.Time { return now }
+
+	store.MarkEphemeral("private", 30*time.Minute)
+	store.AddMessage("private", "user", "hello")
+
+	now = now.Add(20 * time.Minute)
+	if got := store.GetHistory("private"); len(got) != 1 {
+		t.Fatalf("history before expiry = %+v", got)
+	}
+	// Using the session restarted the idle timer.
+	now = now.Add(20 * time.Minute)
+	if !store.IsEphemeral("private") {
+		t.Fatal("session expired although it was used 20 minutes ago")
+	}
+
+	now = now.Add(31 * time.Minute)
+	if store.IsEphemeral("private") {
+		t.Fatal("idle session was not wiped")
+	}
+	// An expired session falls through to the wrapped store, which never saw it.
+	if got := store.GetHistory("private"); len(got) != 0 {
+		t.Errorf("history after expiry = %+v", got)
+	}
+}
+
+func TestEphemeralStore_CloseWipes(t *testing.T) {
+	store := NewEphemeralStore(NewSessionManager(""))
+	store.MarkEphemeral("private", time.Hour)
+	store.AddMessage("private", "user", "hello")
+	store.Close()
+	if store.IsEphemeral("private") {
+		t.Error("Close kept the ephemeral session")
+	}
+}
@@ -0,0 +1,95 @@
+// Package shadow records what the bot would have replied in shadow mode,
+// where messages are answered but nothing is sent and no tool runs.
+package shadow
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+)
+
+// ToolCall is a tool call the model asked for, which was not run.
+type ToolCall struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// Entry is one message answered in shadow mode.
+type Entry struct {
+	Time      time.Time  `json:"time"`
+	Channel   string     `json:"channel"`
+	ChatID    string     `json:"chat_id"`
+	SenderID  string     `json:"sender_id,omitempty"`
+	RequestID string     `json:"request_id,omitempty"`
+	Agent     string     `json:"agent,omitempty"`
+	Model     string     `json:"model,omitempty"`
+	Message   string     `json:"message"`
+	Reply     string     `json:"reply"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// LogPath returns the shadow log location for a workspace.
+func LogPath(workspace string) string {
+	return filepath.Join(workspace, "state", "shadow.jsonl")
+}
+
+// Log appends entries to the shadow log, a JSONL file. It is safe for
+// concurrent use, and a nil *Log records nothing.
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewLog creates a log writing to path.
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// Record appends e. A zero Time is set to now.
+func (l *Log) Record(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ForgetSender removes the entries of senderID's messages on channel and
+// returns how many were removed.
+func (l *Log) ForgetSender(channel, senderID string) (int, error) {
+	if l == nil || senderID == "" {
+		return 0, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fileutil.RewriteLines(l.path, 0o600, func(line []byte) ([]byte, bool) {
+		var e Entry
+		return line, json.Unmarshal(line, &e) != nil || e.Channel != channel || e.SenderID != senderID
+	})
+}
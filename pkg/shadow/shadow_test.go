@@ -0,0 +1,72 @@
+package shadow
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readEntries(t *testing.T, path string) []Entry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var out []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("bad line %q: %v", scanner.Text(), err)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func TestLog_RecordAndForgetSender(t *testing.T) {
+	path := LogPath(t.TempDir())
+	l := NewLog(path)
+	if err := l.Record(Entry{
+		Channel:   "discord",
+		ChatID:    "c1",
+		SenderID:  "u1",
+		Message:   "what's the weather?",
+		Reply:     "Sunny.",
+		ToolCalls: []ToolCall{{Name: "weather", Arguments: map[string]any{"city": "Oslo"}}},
+	}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	second := Entry{Channel: "discord", ChatID: "c1", SenderID: "u2", Message: "hi", Reply: "Hello!"}
+	if err := l.Record(second); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if filepath.Base(path) != "shadow.jsonl" {
+		t.Errorf("LogPath() = %q", path)
+	}
+
+	entries := readEntries(t, path)
+	if len(entries) != 2 || entries[0].Time.IsZero() {
+		t.Fatalf("entries = %+v", entries)
+	}
+	calls := entries[0].ToolCalls
+	if len(calls) != 1 || calls[0].Name != "weather" || calls[0].Arguments["city"] != "Oslo" {
+		t.Errorf("tool calls = %+v", calls)
+	}
+
+	n, err := l.ForgetSender("discord", "u1")
+	if err != nil || n != 1 {
+		t.Fatalf("ForgetSender() = %d, %v", n, err)
+	}
+	if entries := readEntries(t, path); len(entries) != 1 || entries[0].SenderID != "u2" {
+		t.Errorf("after ForgetSender: %+v", entries)
+	}
+
+	var nilLog *Log
+	if err := nilLog.Record(Entry{}); err != nil {
+		t.Errorf("nil log Record() error = %v", err)
+	}
+}
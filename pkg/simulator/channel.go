@@ -0,0 +1,175 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+)
+
+// delivery is a message that reached a chat.
+type delivery struct {
+	channel string
+	chatID  string
+	content string
+	edit    bool // replaced a placeholder or earlier reply
+}
+
+// transcript collects deliveries from every channel in order.
+type transcript struct {
+	mu         sync.Mutex
+	deliveries []delivery
+	changed    chan struct{}
+}
+
+func newTranscript() *transcript {
+	return &transcript{changed: make(chan struct{}, 1)}
+}
+
+func (t *transcript) add(d delivery) {
+	t.mu.Lock()
+	t.deliveries = append(t.deliveries, d)
+	t.mu.Unlock()
+	select {
+	case t.changed <- struct{}{}:
+	default:
+	}
+}
+
+// since returns the deliveries after the first n that match channel and,
+// if set, chatID.
+func (t *transcript) since(n int, channel, chatID string) []delivery {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []delivery
+	for _, d := range t.deliveries[n:] {
+		if d.channel == channel && (chatID == "" || d.chatID == chatID) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func (t *transcript) len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.deliveries)
+}
+
+// heldMessage is an incoming message waiting for the channel to reconnect.
+type heldMessage struct {
+	id  string
+	msg Message
+}
+
+// simChannel is a chat platform that records what it is asked to deliver.
+// While disconnected it fails sends with a temporary error, so the manager
+// retries them, and holds incoming messages until it reconnects.
+type simChannel struct {
+	*channels.BaseChannel
+	spec ChannelSpec
+	out  *transcript
+
+	mu           sync.Mutex
+	disconnected bool
+	held         []heldMessage
+	nextID       int
+}
+
+func newSimChannel(spec ChannelSpec, msgBus *bus.MessageBus, out *transcript) *simChannel {
+	base := channels.NewBaseChannel(spec.Name, spec, msgBus, spec.AllowFrom,
+		channels.WithMaxMessageLength(spec.MaxLength))
+	return &simChannel{BaseChannel: base, spec: spec, out: out}
+}
+
+func (c *simChannel) Start(ctx context.Context) error {
+	c.SetRunning(true)
+	return nil
+}
+
+func (c *simChannel) Stop(ctx context.Context) error {
+	c.SetRunning(false)
+	return nil
+}
+
+func (c *simChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if err := c.reachable(); err != nil {
+		return err
+	}
+	c.out.add(delivery{channel: c.Name(), chatID: msg.ChatID, content: msg.Content})
+	c.RecordReply(msg.ChatID, c.newID())
+	return nil
+}
+
+func (c *simChannel) SendPlaceholder(ctx context.Context, chatID string) (string, error) {
+	if !c.spec.Placeholder {
+		return "", nil
+	}
+	if err := c.reachable(); err != nil {
+		return "", err
+	}
+	return c.newID(), nil
+}
+
+func (c *simChannel) EditMessage(ctx context.Context, chatID, messageID, content string) error {
+	if err := c.reachable(); err != nil {
+		return err
+	}
+	c.out.add(delivery{channel: c.Name(), chatID: chatID, content: content, edit: true})
+	return nil
+}
+
+func (c *simChannel) reachable() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.disconnected {
+		return fmt.Errorf("%s: connection lost: %w", c.Name(), channels.ErrTemporary)
+	}
+	return nil
+}
+
+func (c *simChannel) newID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	return strconv.Itoa(c.nextID)
+}
+
+// receive passes an incoming message to the pipeline, or holds it while
+// the channel is disconnected.
+func (c *simChannel) receive(ctx context.Context, msg Message) {
+	id := c.newID()
+	c.mu.Lock()
+	if c.disconnected {
+		c.held = append(c.held, heldMessage{id: id, msg: msg})
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+	c.deliver(ctx, id, msg)
+}
+
+func (c *simChannel) deliver(ctx context.Context, id string, msg Message) {
+	peer := bus.Peer{Kind: "direct", ID: msg.User}
+	if msg.Group {
+		peer = bus.Peer{Kind: "group", ID: msg.chat()}
+	}
+	c.HandleMessage(ctx, peer, id, msg.User, msg.chat(), msg.Text, nil, nil)
+}
+
+func (c *simChannel) setConnected(ctx context.Context, connected bool) {
+	c.mu.Lock()
+	c.disconnected = !connected
+	var held []heldMessage
+	if connected {
+		held, c.held = c.held, nil
+	}
+	c.mu.Unlock()
+	// Platforms hand over what arrived during the outage after reconnecting
+	for _, h := range held {
+		c.deliver(ctx, h.id, h.msg)
+	}
+}
@@ -0,0 +1,99 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// scriptedProvider answers with the first reply rule matching the latest
+// message of the request.
+type scriptedProvider struct {
+	rules []ReplySpec
+	calls atomic.Int64
+}
+
+func (p *scriptedProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	defs []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	n := p.calls.Add(1)
+	latest := ""
+	if len(messages) > 0 {
+		latest = strings.ToLower(messages[len(messages)-1].Content)
+	}
+	for _, r := range p.rules {
+		if r.When != "" && !strings.Contains(latest, strings.ToLower(r.When)) {
+			continue
+		}
+		if r.Delay > 0 {
+			select {
+			case <-time.After(time.Duration(r.Delay)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if r.Tool != "" {
+			return &providers.LLMResponse{
+				ToolCalls: []providers.ToolCall{{
+					ID:        fmt.Sprintf("call_%d", n),
+					Type:      "function",
+					Name:      r.Tool,
+					Arguments: r.Args,
+				}},
+				FinishReason: "tool_calls",
+			}, nil
+		}
+		content := r.Reply
+		if r.Repeat > 1 {
+			content = strings.TrimSuffix(strings.Repeat(r.Reply+"\n", r.Repeat), "\n")
+		}
+		return &providers.LLMResponse{Content: content, FinishReason: "stop"}, nil
+	}
+	return &providers.LLMResponse{Content: "OK", FinishReason: "stop"}, nil
+}
+
+func (p *scriptedProvider) GetDefaultModel() string {
+	return "simulator"
+}
+
+// scriptedTool returns a fixed result, after approval when the spec asks for
+// one.
+type scriptedTool struct {
+	spec      ToolSpec
+	approvals *tools.ApprovalQueue
+}
+
+func (t *scriptedTool) Name() string { return t.spec.Name }
+
+func (t *scriptedTool) Description() string {
+	if t.spec.Description != "" {
+		return t.spec.Description
+	}
+	return "Scripted tool " + t.spec.Name
+}
+
+func (t *scriptedTool) Parameters() map[string]any {
+	return map[string]any{"type": "object", "properties": map[string]any{}}
+}
+
+func (t *scriptedTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	result := t.spec.Result
+	if result == "" {
+		result = "done"
+	}
+	if !t.spec.Approval || t.approvals == nil {
+		return tools.NewToolResult(result)
+	}
+	return t.approvals.Hold(ctx, t.spec.Name, func(context.Context) *tools.ToolResult {
+		return tools.NewToolResult(result)
+	})
+}
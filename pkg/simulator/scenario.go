@@ -0,0 +1,214 @@
+// Package simulator runs scripted conversations through the real gateway
+// pipeline: channel manager, message bus, and agent loop. Channels and the
+// LLM provider are fakes driven by a YAML scenario, and every step checks
+// what reached the chat, so splitting, placeholder edits, approvals, and
+// session handling can be regression-tested end to end.
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is one scripted run.
+type Scenario struct {
+	Name string `yaml:"name"`
+	// Config is merged over the default configuration, with the keys of
+	// config.json. The workspace is always a temporary directory.
+	Config   map[string]any `yaml:"config"`
+	Channels []ChannelSpec  `yaml:"channels"`
+	Tools    []ToolSpec     `yaml:"tools"`
+	// Replies script the LLM. The first rule matching the latest message
+	// answers; a rule without When matches anything.
+	Replies []ReplySpec `yaml:"replies"`
+	Steps   []Step      `yaml:"steps"`
+}
+
+// ChannelSpec describes a fake chat platform.
+type ChannelSpec struct {
+	Name      string   `yaml:"name"`
+	MaxLength int      `yaml:"max_length"` // split replies longer than this; 0 never splits
+	AllowFrom []string `yaml:"allow_from"`
+	// Placeholder sends "Thinking..." on every message and edits it into the
+	// first chunk of the reply, like Telegram does.
+	Placeholder bool `yaml:"placeholder"`
+}
+
+// ToolSpec is a tool the scripted LLM can call.
+type ToolSpec struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Result      string `yaml:"result"`
+	// Approval holds the call until the user sends /approve.
+	Approval bool `yaml:"approval"`
+}
+
+// ReplySpec is one rule of the scripted LLM.
+type ReplySpec struct {
+	When   string         `yaml:"when"`   // case-insensitive substring of the latest message
+	Reply  string         `yaml:"reply"`  // text answer
+	Repeat int            `yaml:"repeat"` // repeat Reply this many lines, for long answers
+	Tool   string         `yaml:"tool"`   // call this tool instead of answering
+	Args   map[string]any `yaml:"args"`
+	Delay  duration       `yaml:"delay"` // think this long before answering
+}
+
+// Step is one event of a scenario. Exactly one of Send, Approve, Deny,
+// Disconnect, Reconnect, and Wait is set.
+type Step struct {
+	Send       *Message `yaml:"send"`
+	Approve    *Message `yaml:"approve"` // approves the latest prompt in the chat
+	Deny       *Message `yaml:"deny"`
+	Disconnect string   `yaml:"disconnect"` // channel that loses its connection
+	Reconnect  string   `yaml:"reconnect"`
+	Wait       duration `yaml:"wait"` // let time pass, e.g. for a slow reply
+	Expect     *Expect  `yaml:"expect"`
+}
+
+// Message is an incoming message from a user.
+type Message struct {
+	Channel string `yaml:"channel"`
+	User    string `yaml:"user"`
+	Chat    string `yaml:"chat"` // defaults to the user, for direct messages
+	Group   bool   `yaml:"group"`
+	Text    string `yaml:"text"`
+}
+
+// Expect is checked against what the step delivered: to the chat for
+// messages, to every chat of the channel for reconnects.
+type Expect struct {
+	Chunks   *int           `yaml:"chunks"`   // messages delivered, edits included
+	Edits    *int           `yaml:"edits"`    // chunks that replaced a placeholder
+	Contains []string       `yaml:"contains"` // substrings of the delivered text
+	Absent   []string       `yaml:"absent"`   // substrings that must not be delivered
+	Session  *SessionExpect `yaml:"session"`
+	Timeout  duration       `yaml:"timeout"` // how long to wait for the chunks; default 10s
+}
+
+// SessionExpect checks the stored conversation after the step.
+type SessionExpect struct {
+	Key      string `yaml:"key"`
+	Messages int    `yaml:"messages"`
+}
+
+// duration reads a YAML duration such as "2s" or "500ms".
+type duration time.Duration
+
+func (d *duration) UnmarshalYAML(node *yaml.Node) error {
+	v, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*d = duration(v)
+	return nil
+}
+
+// Load reads and checks a scenario file.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sc, nil
+}
+
+// Parse decodes and checks a YAML scenario.
+func Parse(data []byte) (*Scenario, error) {
+	var sc Scenario
+	if err := yaml.Unmarshal(data, &sc); err != nil {
+		return nil, err
+	}
+	if err := sc.validate(); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+func (sc *Scenario) validate() error {
+	channels := make(map[string]bool)
+	for _, ch := range sc.Channels {
+		if ch.Name == "" || channels[ch.Name] {
+			return fmt.Errorf("channel names must be set and unique: %q", ch.Name)
+		}
+		channels[ch.Name] = true
+	}
+	tools := make(map[string]bool)
+	for _, t := range sc.Tools {
+		if t.Name == "" {
+			return fmt.Errorf("tool without a name")
+		}
+		tools[t.Name] = true
+	}
+	for _, r := range sc.Replies {
+		if r.Tool != "" && !tools[r.Tool] {
+			return fmt.Errorf("reply calls unknown tool %q", r.Tool)
+		}
+	}
+	if len(sc.Steps) == 0 {
+		return fmt.Errorf("scenario has no steps")
+	}
+	for i, st := range sc.Steps {
+		var set []string
+		for name, msg := range map[string]*Message{"send": st.Send, "approve": st.Approve, "deny": st.Deny} {
+			if msg == nil {
+				continue
+			}
+			set = append(set, name)
+			if !channels[msg.Channel] || msg.User == "" {
+				return fmt.Errorf("step %d: %s needs a known channel and a user", i+1, name)
+			}
+		}
+		for name, ch := range map[string]string{"disconnect": st.Disconnect, "reconnect": st.Reconnect} {
+			if ch == "" {
+				continue
+			}
+			set = append(set, name)
+			if !channels[ch] {
+				return fmt.Errorf("step %d: unknown channel %q", i+1, ch)
+			}
+		}
+		if st.Wait > 0 {
+			set = append(set, "wait")
+		}
+		if len(set) != 1 {
+			slices.Sort(set)
+			return fmt.Errorf("step %d: needs exactly one action, has %d (%s)", i+1, len(set), strings.Join(set, ", "))
+		}
+	}
+	return nil
+}
+
+// chat returns the chat ID the message is sent in.
+func (m *Message) chat() string {
+	if m.Chat != "" {
+		return m.Chat
+	}
+	return m.User
+}
+
+// describe names a step in failure reports.
+func (st Step) describe() string {
+	switch {
+	case st.Send != nil:
+		return fmt.Sprintf("%s sends %q on %s", st.Send.User, st.Send.Text, st.Send.Channel)
+	case st.Approve != nil:
+		return fmt.Sprintf("%s approves on %s", st.Approve.User, st.Approve.Channel)
+	case st.Deny != nil:
+		return fmt.Sprintf("%s denies on %s", st.Deny.User, st.Deny.Channel)
+	case st.Disconnect != "":
+		return st.Disconnect + " disconnects"
+	case st.Wait > 0:
+		return "wait " + time.Duration(st.Wait).String()
+	default:
+		return st.Reconnect + " reconnects"
+	}
+}
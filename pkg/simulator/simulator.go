@@ -0,0 +1,304 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/routing"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	// settleTime is how long the chat must stay quiet before a step is
+	// considered delivered.
+	settleTime = 300 * time.Millisecond
+)
+
+var approvalIDRe = regexp.MustCompile(`/approve ([0-9A-F]+)`)
+
+// Failure is an expectation a step did not meet.
+type Failure struct {
+	Step    int // 1-based
+	Action  string
+	Message string
+}
+
+func (f Failure) String() string {
+	return fmt.Sprintf("step %d (%s): %s", f.Step, f.Action, f.Message)
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Scenario string
+	Steps    int
+	Failures []Failure
+}
+
+// Passed reports whether every expectation was met.
+func (r *Report) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// run is the pipeline of one scenario.
+type run struct {
+	agent    *agent.AgentLoop
+	channels map[string]*simChannel
+	out      *transcript
+}
+
+// Run plays sc against a fresh pipeline in a temporary workspace. The
+// returned error is for a pipeline that could not be set up; unmet
+// expectations are in the report.
+func Run(ctx context.Context, sc *Scenario) (*Report, error) {
+	workspace, err := os.MkdirTemp("", "picoclaw-sim-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workspace)
+
+	provider := &scriptedProvider{rules: sc.Replies}
+	cfg, err := buildConfig(sc, workspace, provider.GetDefaultModel())
+	if err != nil {
+		return nil, err
+	}
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	al := agent.NewAgentLoop(cfg, msgBus, provider)
+	defer al.Close()
+	for _, spec := range sc.Tools {
+		al.RegisterTool(&scriptedTool{spec: spec, approvals: al.Approvals()})
+	}
+
+	manager, err := channels.NewManager(cfg, msgBus, nil)
+	if err != nil {
+		return nil, err
+	}
+	al.SetChannelManager(manager)
+	r := &run{agent: al, channels: make(map[string]*simChannel), out: newTranscript()}
+	for _, spec := range sc.Channels {
+		ch := newSimChannel(spec, msgBus, r.out)
+		manager.RegisterChannel(spec.Name, ch)
+		r.channels[spec.Name] = ch
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := manager.StartAll(runCtx); err != nil {
+		return nil, err
+	}
+	defer manager.StopAll(context.Background())
+	go al.Run(runCtx)
+	defer al.Stop()
+
+	report := &Report{Scenario: sc.Name, Steps: len(sc.Steps)}
+	for i, st := range sc.Steps {
+		for _, msg := range r.step(runCtx, st) {
+			report.Failures = append(report.Failures, Failure{Step: i + 1, Action: st.describe(), Message: msg})
+		}
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+	}
+	return report, nil
+}
+
+func buildConfig(sc *Scenario, workspace, model string) (*config.Config, error) {
+	cfg := config.DefaultConfig()
+	if len(sc.Config) > 0 {
+		data, err := json.Marshal(sc.Config)
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	}
+	cfg.Agents.Defaults.Workspace = workspace
+	if cfg.Agents.Defaults.Model == "" {
+		cfg.Agents.Defaults.Model = model
+	}
+	return cfg, nil
+}
+
+// step performs st and returns the expectations it did not meet.
+func (r *run) step(ctx context.Context, st Step) []string {
+	mark := r.out.len()
+	var channel, chatID string
+	switch {
+	case st.Send != nil:
+		channel, chatID = st.Send.Channel, st.Send.chat()
+		r.channels[channel].receive(ctx, *st.Send)
+	case st.Approve != nil, st.Deny != nil:
+		msg, verb := st.Approve, "/approve "
+		if msg == nil {
+			msg, verb = st.Deny, "/deny "
+		}
+		channel, chatID = msg.Channel, msg.chat()
+		id := r.lastApproval(channel, chatID)
+		if id == "" {
+			return []string{"no approval prompt was delivered to the chat"}
+		}
+		answer := *msg
+		answer.Text = verb + id
+		r.channels[channel].receive(ctx, answer)
+	case st.Disconnect != "":
+		channel = st.Disconnect
+		r.channels[channel].setConnected(ctx, false)
+	case st.Wait > 0:
+		select {
+		case <-time.After(time.Duration(st.Wait)):
+		case <-ctx.Done():
+		}
+		return nil
+	default:
+		channel = st.Reconnect
+		r.channels[channel].setConnected(ctx, true)
+	}
+
+	got := r.wait(st.Expect, mark, channel, chatID)
+	failures := r.checkLengths(channel, got)
+	if st.Expect != nil {
+		failures = append(failures, r.check(st.Expect, got)...)
+	}
+	return failures
+}
+
+// wait returns what the step delivered once the expected number of chunks
+// arrived, or the chat has gone quiet, or the timeout passed.
+func (r *run) wait(exp *Expect, mark int, channel, chatID string) []delivery {
+	timeout, want := defaultTimeout, -1
+	if exp != nil {
+		if exp.Timeout > 0 {
+			timeout = time.Duration(exp.Timeout)
+		}
+		if exp.Chunks != nil {
+			want = *exp.Chunks
+		}
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for want < 0 || len(r.out.since(mark, channel, chatID)) < want {
+		select {
+		case <-r.out.changed:
+		case <-time.After(settleTime):
+			if want < 0 {
+				return r.out.since(mark, channel, chatID)
+			}
+		case <-deadline.C:
+			return r.out.since(mark, channel, chatID)
+		}
+	}
+	// Keep listening briefly so chunks beyond the expected count show up
+	for {
+		select {
+		case <-r.out.changed:
+		case <-time.After(settleTime):
+			return r.out.since(mark, channel, chatID)
+		}
+	}
+}
+
+// checkLengths verifies every chunk fits the channel's message limit.
+func (r *run) checkLengths(channel string, got []delivery) []string {
+	ch := r.channels[channel]
+	if ch.spec.MaxLength <= 0 {
+		return nil
+	}
+	lengthFn := ch.MessageLengthFunc()
+	if lengthFn == nil {
+		lengthFn = channels.RuneLength
+	}
+	var failures []string
+	for i, d := range got {
+		if n := lengthFn(d.content); n > ch.spec.MaxLength {
+			failures = append(failures, fmt.Sprintf("chunk %d is %d characters, over the limit of %d",
+				i+1, n, ch.spec.MaxLength))
+		}
+	}
+	return failures
+}
+
+func (r *run) check(exp *Expect, got []delivery) []string {
+	var failures []string
+	if exp.Chunks != nil && len(got) != *exp.Chunks {
+		failures = append(failures, fmt.Sprintf("got %d chunks, want %d%s", len(got), *exp.Chunks, quoteAll(got)))
+	}
+	if exp.Edits != nil {
+		edits := 0
+		for _, d := range got {
+			if d.edit {
+				edits++
+			}
+		}
+		if edits != *exp.Edits {
+			failures = append(failures, fmt.Sprintf("got %d edits, want %d", edits, *exp.Edits))
+		}
+	}
+	var text strings.Builder
+	for _, d := range got {
+		text.WriteString(d.content)
+		text.WriteString("\n")
+	}
+	for _, s := range exp.Contains {
+		if !strings.Contains(text.String(), s) {
+			failures = append(failures, fmt.Sprintf("no chunk contains %q%s", s, quoteAll(got)))
+		}
+	}
+	for _, s := range exp.Absent {
+		if strings.Contains(text.String(), s) {
+			failures = append(failures, fmt.Sprintf("a chunk contains %q", s))
+		}
+	}
+	if exp.Session != nil {
+		if msg := r.checkSession(exp.Session); msg != "" {
+			failures = append(failures, msg)
+		}
+	}
+	return failures
+}
+
+func (r *run) checkSession(exp *SessionExpect) string {
+	parsed := routing.ParseAgentSessionKey(exp.Key)
+	if parsed == nil {
+		return fmt.Sprintf("session key %q is not an agent session key", exp.Key)
+	}
+	ag, ok := r.agent.GetRegistry().GetAgent(parsed.AgentID)
+	if !ok {
+		return fmt.Sprintf("session %q belongs to unknown agent %q", exp.Key, parsed.AgentID)
+	}
+	if n := len(ag.Sessions.GetHistory(exp.Key)); n != exp.Messages {
+		return fmt.Sprintf("session %q has %d messages, want %d", exp.Key, n, exp.Messages)
+	}
+	return ""
+}
+
+// lastApproval returns the ID of the latest approval prompt in the chat.
+func (r *run) lastApproval(channel, chatID string) string {
+	id := ""
+	for _, d := range r.out.since(0, channel, chatID) {
+		if m := approvalIDRe.FindAllStringSubmatch(d.content, -1); len(m) > 0 {
+			id = m[len(m)-1][1]
+		}
+	}
+	return id
+}
+
+func quoteAll(got []delivery) string {
+	if len(got) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(got))
+	for i, d := range got {
+		quoted[i] = fmt.Sprintf("%q", d.content)
+	}
+	return ": " + strings.Join(quoted, ", ")
+}
@@ -0,0 +1,71 @@
+package simulator
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScenarios(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.yaml"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no scenarios: %v", err)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			t.Parallel()
+			sc, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := Run(context.Background(), sc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range report.Failures {
+				t.Error(f)
+			}
+		})
+	}
+}
+
+func TestRun_ReportsUnmetExpectations(t *testing.T) {
+	sc, err := Parse([]byte(`
+channels: [{name: telegram, max_length: 20}]
+replies: [{reply: "a reply that is far too long for one message"}]
+steps:
+  - send: {channel: telegram, user: alice, text: hi}
+    expect: {chunks: 1, contains: [missing], session: {key: "agent:main:main", messages: 2}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Run(context.Background(), sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range report.Failures {
+		got = append(got, f.Message)
+	}
+	joined := strings.Join(got, "\n")
+	for _, want := range []string{"chunks, want 1", `no chunk contains "missing"`, `session "agent:main:main" has 0 messages`} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("failures %q lack %q", got, want)
+		}
+	}
+}
+
+func TestParse_Validates(t *testing.T) {
+	for name, doc := range map[string]string{
+		"no steps":        `channels: [{name: telegram}]`,
+		"unknown channel": `steps: [{send: {channel: irc, user: a, text: x}}]`,
+		"two actions":     "channels: [{name: t}]\nsteps: [{send: {channel: t, user: a}, disconnect: t}]",
+		"unknown tool":    "replies: [{tool: nope}]\nsteps: [{wait: 1s}]",
+	} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
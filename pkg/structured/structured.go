@@ -0,0 +1,153 @@
+// Package structured asks the model for replies that follow a JSON schema
+// and checks that they do, for callers that read the reply as data rather
+// than show it to a person.
+//
+// Providers with native structured outputs are handed the schema with the
+// "response_schema" request option; every other model is told about it in
+// the system prompt. Either way the reply is validated, and the agent asks
+// again with the validation error when it does not conform.
+package structured
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+const (
+	// Option is the provider request option that carries the schema, as a
+	// map[string]any, to providers that can enforce it.
+	Option = "response_schema"
+	// MetadataKey is the inbound message metadata that asks for a reply
+	// conforming to the schema it holds as JSON text.
+	MetadataKey = "response_schema"
+)
+
+// ErrNoMatch is wrapped by the error for a reply that still does not
+// conform after the retries.
+var ErrNoMatch = errors.New("the reply does not match the response schema")
+
+// Schema is a parsed and resolved JSON schema.
+type Schema struct {
+	raw      map[string]any
+	text     string // compact JSON
+	resolved *jsonschema.Resolved
+}
+
+// Parse parses a JSON schema (draft 2020-12 or draft-07). Only references
+// within the schema are supported.
+func Parse(data []byte) (*Schema, error) {
+	var s jsonschema.Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("response schema: %w", err)
+	}
+	resolved, err := s.Resolve(nil)
+	if err != nil {
+		return nil, fmt.Errorf("response schema: %w", err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("response schema: a schema must be a JSON object")
+	}
+	var compact bytes.Buffer
+	json.Compact(&compact, data)
+	return &Schema{raw: raw, text: compact.String(), resolved: resolved}, nil
+}
+
+// Load parses arg as a schema when it is a JSON object, and otherwise reads
+// the schema from the file arg names.
+func Load(arg string) (*Schema, error) {
+	if strings.HasPrefix(strings.TrimSpace(arg), "{") {
+		return Parse([]byte(arg))
+	}
+	data, err := os.ReadFile(arg)
+	if err != nil {
+		return nil, fmt.Errorf("response schema: %w", err)
+	}
+	return Parse(data)
+}
+
+// String returns the schema as compact JSON.
+func (s *Schema) String() string {
+	if s == nil {
+		return ""
+	}
+	return s.text
+}
+
+// Map returns the schema as decoded JSON, for the request option.
+func (s *Schema) Map() map[string]any {
+	if s == nil {
+		return nil
+	}
+	return s.raw
+}
+
+// Instruction is the system prompt section that asks for a conforming
+// reply. A nil Schema asks for nothing.
+func (s *Schema) Instruction() string {
+	if s == nil {
+		return ""
+	}
+	return "## Response format\n\nYour final reply is read by a program, not a person. " +
+		"Reply with a single JSON value that conforms to this JSON schema, and nothing else: " +
+		"no prose, no Markdown, no code fences.\n\n" + s.text
+}
+
+// Validate checks that reply is a JSON value conforming to the schema and
+// returns it compacted. Code fences and text around a JSON object or array
+// are tolerated, since models add them even when told not to.
+func (s *Schema) Validate(reply string) (json.RawMessage, error) {
+	text := Extract(reply)
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return nil, fmt.Errorf("the reply is not JSON: %w", err)
+	}
+	if err := s.resolved.Validate(value); err != nil {
+		return nil, err
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(text)); err != nil {
+		return nil, err
+	}
+	return compact.Bytes(), nil
+}
+
+// RetryPrompt asks the model to correct a reply that failed validation.
+func RetryPrompt(err error) string {
+	return "Your reply does not conform to the JSON schema: " + err.Error() +
+		"\n\nReply again with only the corrected JSON value."
+}
+
+// Extract returns the JSON in reply: the content of a code fence, or the
+// span from the first opening to the last closing bracket around prose.
+func Extract(reply string) string {
+	text := strings.TrimSpace(reply)
+	if start := strings.Index(text, "```"); start >= 0 {
+		rest := text[start+3:]
+		if nl := strings.IndexByte(rest, '\n'); nl >= 0 {
+			rest = rest[nl+1:] // the language tag
+		}
+		if end := strings.Index(rest, "```"); end >= 0 {
+			return strings.TrimSpace(rest[:end])
+		}
+	}
+	if json.Valid([]byte(text)) {
+		return text
+	}
+	if start := strings.IndexAny(text, "{["); start >= 0 {
+		closing := "}"
+		if text[start] == '[' {
+			closing = "]"
+		}
+		if end := strings.LastIndex(text, closing); end > start {
+			return text[start : end+1]
+		}
+	}
+	return text
+}
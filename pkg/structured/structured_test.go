@@ -0,0 +1,78 @@
+package structured
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"properties": {"city": {"type": "string"}, "temp": {"type": "number"}},
+	"required": ["city", "temp"]
+}`
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if s.String() != `{"type":"object","properties":{"city":{"type":"string"},"temp":{"type":"number"}},`+
+		`"required":["city","temp"]}` {
+		t.Errorf("String() = %s", s)
+	}
+	if s.Map()["type"] != "object" || !strings.Contains(s.Instruction(), s.String()) {
+		t.Errorf("Map() = %v, Instruction() = %q", s.Map(), s.Instruction())
+	}
+	for _, bad := range []string{`not json`, `{"type": 3}`, `{"$ref": "#/$defs/missing"}`} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("Parse(%s) succeeded", bad)
+		}
+	}
+
+	var none *Schema
+	if none.String() != "" || none.Map() != nil || none.Instruction() != "" {
+		t.Error("a nil Schema asks for something")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	if _, err := Load(testSchema); err != nil {
+		t.Errorf("Load(inline) error = %v", err)
+	}
+	if _, err := Load(t.TempDir() + "/missing.json"); err == nil {
+		t.Error("Load(missing file) succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, reply := range []string{
+		`{"city": "Oslo", "temp": 4.5}`,
+		"```json\n{\"city\": \"Oslo\", \"temp\": 4.5}\n```",
+		`Here you go: {"city": "Oslo", "temp": 4.5}. Anything else?`,
+	} {
+		data, err := s.Validate(reply)
+		if err != nil {
+			t.Errorf("Validate(%q) error = %v", reply, err)
+			continue
+		}
+		if string(data) != `{"city":"Oslo","temp":4.5}` {
+			t.Errorf("Validate(%q) = %s", reply, data)
+		}
+	}
+	for _, reply := range []string{
+		`It is 4.5 degrees in Oslo.`,
+		`{"city": "Oslo"}`,
+		`{"city": "Oslo", "temp": "warm"}`,
+	} {
+		if _, err := s.Validate(reply); err == nil {
+			t.Errorf("Validate(%q) succeeded", reply)
+		} else if errors.Is(err, ErrNoMatch) {
+			t.Errorf("Validate(%q) wrapped ErrNoMatch, which is for the final failure", reply)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/auth"
+)
+
+// HTTPPath is where the gateway mounts the tenants API.
+const HTTPPath = "/tenants"
+
+// maxRequestBytes bounds the body of a tenant update.
+const maxRequestBytes = 64 << 10
+
+// view is a tenant as the API returns it: without its token, with today's
+// usage.
+type view struct {
+	Tenant
+	HasAPIToken bool  `json:"has_api_token"`
+	Today       Usage `json:"today"`
+}
+
+type listResponse struct {
+	Tenants []view `json:"tenants"`
+}
+
+// Handler serves the tenants API.
+type Handler struct {
+	store       *Store
+	meter       *Meter
+	adminTokens []string
+	check       func(Tenant) error
+}
+
+// NewHandler returns the API for store. adminTokens manage every tenant;
+// a tenant's own api_token reads it and updates its name and admins. check
+// validates a tenant against the deployment's agents and models before it
+// is saved.
+func NewHandler(store *Store, meter *Meter, adminTokens []string, check func(Tenant) error) *Handler {
+	return &Handler{store: store, meter: meter, adminTokens: adminTokens, check: check}
+}
+
+// ServeHTTP implements the tenants API:
+//
+//	GET    /tenants       list tenants (admin)
+//	GET    /tenants/<id>  show a tenant
+//	PUT    /tenants/<id>  create or replace a tenant
+//	DELETE /tenants/<id>  delete a tenant (admin)
+//
+// It requires "Authorization: Bearer <token>".
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, HTTPPath), "/")
+	admin := h.isAdmin(r)
+	if !admin && (id == "" || !h.isTenant(r, id)) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if id == "" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		resp := listResponse{Tenants: []view{}}
+		for _, t := range h.store.List() {
+			resp.Tenants = append(resp.Tenants, h.view(t))
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		t, ok := h.store.Get(id)
+		if !ok {
+			http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, h.view(t))
+	case http.MethodPut:
+		h.put(w, r, id, admin)
+	case http.MethodDelete:
+		if !admin {
+			http.Error(w, "only the admin token can delete tenants", http.StatusForbidden)
+			return
+		}
+		if err := h.store.Delete(id); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// put saves the tenant in the body under id. A tenant token may only change
+// the name and admins of its own tenant.
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, id string, admin bool) {
+	var body Tenant
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&body); err != nil {
+		http.Error(w, "body must be a JSON tenant", http.StatusBadRequest)
+		return
+	}
+	t := body
+	if !admin {
+		current, _ := h.store.Get(id)
+		current.Name, current.Admins = body.Name, body.Admins
+		t = current
+	} else if body.APIToken == "" {
+		// Leaving the token out keeps the current one.
+		if current, ok := h.store.Get(id); ok {
+			t.APIToken = current.APIToken
+		}
+	}
+	t.ID = id
+
+	if err := h.store.Check(t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.check != nil {
+		if err := h.check(t); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := h.store.Put(t); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	saved, _ := h.store.Get(id)
+	writeJSON(w, http.StatusOK, h.view(saved))
+}
+
+func (h *Handler) view(t Tenant) view {
+	v := view{Tenant: t, HasAPIToken: t.APIToken != "", Today: h.meter.Used(t.ID)}
+	v.APIToken = ""
+	return v
+}
+
+func (h *Handler) isAdmin(r *http.Request) bool {
+	return auth.BearerTokenMatches(r, h.adminTokens...)
+}
+
+func (h *Handler) isTenant(r *http.Request, id string) bool {
+	t, ok := h.store.Get(id)
+	return ok && auth.BearerTokenMatches(r, t.APIToken)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,79 @@
+package tenant
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// Usage is what a tenant used today.
+type Usage struct {
+	Messages int `json:"messages"`
+	Tokens   int `json:"tokens"`
+}
+
+// Meter counts each tenant's usage for the current local day. It is safe
+// for concurrent use; a nil *Meter counts nothing and allows everything.
+type Meter struct {
+	mu   sync.Mutex
+	day  string
+	used map[string]Usage
+	now  func() time.Time
+}
+
+// NewMeter returns an empty meter.
+func NewMeter() *Meter {
+	return &Meter{used: make(map[string]Usage), now: time.Now}
+}
+
+// rollLocked starts a new day when the date changed.
+func (m *Meter) rollLocked() {
+	if day := m.now().Format(time.DateOnly); day != m.day {
+		m.day = day
+		m.used = make(map[string]Usage)
+	}
+}
+
+// Observe counts one LLM call of tenantID. The first call of a turn counts
+// as a message.
+func (m *Meter) Observe(tenantID string, r usage.Record) {
+	if m == nil || tenantID == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollLocked()
+	if r.Time.IsZero() || r.Time.Local().Format(time.DateOnly) == m.day {
+		u := m.used[tenantID]
+		u.Tokens += r.PromptTokens + r.CompletionTokens
+		if r.TurnStart {
+			u.Messages++
+		}
+		m.used[tenantID] = u
+	}
+}
+
+// Used returns what tenantID used today.
+func (m *Meter) Used(tenantID string) Usage {
+	if m == nil {
+		return Usage{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollLocked()
+	return m.used[tenantID]
+}
+
+// Exceeded reports the first limit of q that tenantID reached today, as
+// "messages" or "tokens" with the limit, or "" when it may go on.
+func (m *Meter) Exceeded(tenantID string, q Quota) (resource string, limit int) {
+	used := m.Used(tenantID)
+	switch {
+	case q.DailyMessages > 0 && used.Messages >= q.DailyMessages:
+		return "messages", q.DailyMessages
+	case q.DailyTokens > 0 && used.Tokens >= q.DailyTokens:
+		return "tokens", q.DailyTokens
+	}
+	return "", 0
+}
@@ -0,0 +1,332 @@
+// Package tenant lets one deployment serve several organizations. A tenant
+// owns a set of agents (and with them their workspaces, sessions, and
+// memory), the chats its members talk in, the model_list entries whose API
+// keys it pays for, a daily quota, and its own admins. Messages from a
+// tenant's chats only reach its agents, and no other agent may use its
+// models.
+//
+// Tenants are kept in workspace/state/tenants.json and managed through the
+// admin API in http.go.
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/routing"
+)
+
+// ErrNotFound is returned for an unknown tenant ID.
+var ErrNotFound = errors.New("tenant not found")
+
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Match selects chats of a tenant. Channel is required; each other field
+// that is set must equal the message's.
+type Match struct {
+	Channel   string `json:"channel"`
+	AccountID string `json:"account_id,omitempty"`
+	GuildID   string `json:"guild_id,omitempty"`
+	TeamID    string `json:"team_id,omitempty"`
+	ChatID    string `json:"chat_id,omitempty"`
+}
+
+// specificity ranks m when it matches msg, or returns 0. A chat beats a
+// guild or team, which beats an account, which beats a whole channel.
+func (m Match) specificity(msg Match) int {
+	if !strings.EqualFold(m.Channel, msg.Channel) {
+		return 0
+	}
+	rank := 1
+	for _, f := range []struct {
+		want, got string
+		rank      int
+	}{
+		{m.AccountID, msg.AccountID, 2},
+		{m.GuildID, msg.GuildID, 3},
+		{m.TeamID, msg.TeamID, 3},
+		{m.ChatID, msg.ChatID, 4},
+	} {
+		if f.want == "" {
+			continue
+		}
+		if f.want != f.got {
+			return 0
+		}
+		rank = max(rank, f.rank)
+	}
+	return rank
+}
+
+// Quota limits what a tenant may use per day. Zero leaves a limit off.
+type Quota struct {
+	DailyMessages int `json:"daily_messages,omitempty"`
+	DailyTokens   int `json:"daily_tokens,omitempty"`
+}
+
+// Tenant is one organization.
+type Tenant struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name,omitempty"`
+	Agents   []string `json:"agents"` // the first one answers chats no binding assigns
+	Channels []Match  `json:"channels"`
+	Models   []string `json:"models,omitempty"` // model_list entries reserved for this tenant
+	Admins   []string `json:"admins,omitempty"` // allow_from format
+	Quota    Quota    `json:"quota"`
+	APIToken string   `json:"api_token,omitempty"`
+}
+
+// OwnsAgent reports whether agentID is one of the tenant's agents.
+func (t Tenant) OwnsAgent(agentID string) bool {
+	return slices.Contains(t.Agents, routing.NormalizeAgentID(agentID))
+}
+
+// normalize trims t and normalizes its IDs, leaving the caller's slices
+// untouched.
+func (t *Tenant) normalize() {
+	t.Agents = slices.Clone(t.Agents)
+	t.Channels = slices.Clone(t.Channels)
+	t.Models = slices.Clone(t.Models)
+	t.ID = strings.ToLower(strings.TrimSpace(t.ID))
+	t.Name = strings.TrimSpace(t.Name)
+	for i, id := range t.Agents {
+		t.Agents[i] = routing.NormalizeAgentID(id)
+	}
+	for i, m := range t.Channels {
+		t.Channels[i].Channel = strings.ToLower(strings.TrimSpace(m.Channel))
+	}
+	for i, model := range t.Models {
+		t.Models[i] = strings.TrimSpace(model)
+	}
+}
+
+// Validate checks t on its own.
+func (t Tenant) Validate() error {
+	if !validID.MatchString(t.ID) {
+		return fmt.Errorf("tenant id %q must be lowercase letters, digits, '-' or '_'", t.ID)
+	}
+	if len(t.Agents) == 0 {
+		return fmt.Errorf("tenant %s needs at least one agent", t.ID)
+	}
+	if len(t.Channels) == 0 {
+		return fmt.Errorf("tenant %s needs at least one channel", t.ID)
+	}
+	for _, m := range t.Channels {
+		if m.Channel == "" {
+			return fmt.Errorf("tenant %s has a channel match without a channel", t.ID)
+		}
+	}
+	if t.Quota.DailyMessages < 0 || t.Quota.DailyTokens < 0 {
+		return fmt.Errorf("tenant %s has a negative quota", t.ID)
+	}
+	return nil
+}
+
+// Path returns the tenants location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "tenants.json")
+}
+
+// Store holds the tenants of the deployment. It is safe for concurrent use;
+// a nil *Store has no tenants.
+type Store struct {
+	path string
+
+	mu      sync.RWMutex
+	tenants map[string]Tenant
+}
+
+// NewStore loads the tenants saved at path.
+func NewStore(path string) *Store {
+	s := &Store{path: path, tenants: make(map[string]Tenant)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &s.tenants); err != nil {
+			logger.WarnCF("tenant", "Ignoring unreadable tenants", map[string]any{
+				"path":  path,
+				"error": err.Error(),
+			})
+			s.tenants = make(map[string]Tenant)
+		}
+	}
+	return s
+}
+
+// List returns every tenant, ordered by ID.
+func (s *Store) List() []Tenant {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Tenant, 0, len(s.tenants))
+	for _, t := range s.tenants {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Get returns the tenant with id.
+func (s *Store) Get(id string) (Tenant, bool) {
+	if s == nil {
+		return Tenant{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.tenants[id]
+	return t, ok
+}
+
+// Empty reports whether no tenant is defined.
+func (s *Store) Empty() bool {
+	if s == nil {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.tenants) == 0
+}
+
+// Check validates t against the other tenants: an agent or a model belongs
+// to at most one tenant, and two tenants cannot claim the same chats.
+func (s *Store) Check(t Tenant) error {
+	t.normalize()
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.checkLocked(t)
+}
+
+func (s *Store) checkLocked(t Tenant) error {
+	for _, other := range s.tenants {
+		if other.ID == t.ID {
+			continue
+		}
+		for _, agent := range t.Agents {
+			if slices.Contains(other.Agents, agent) {
+				return fmt.Errorf("agent %s already belongs to tenant %s", agent, other.ID)
+			}
+		}
+		for _, model := range t.Models {
+			if slices.Contains(other.Models, model) {
+				return fmt.Errorf("model %s already belongs to tenant %s", model, other.ID)
+			}
+		}
+		for _, m := range t.Channels {
+			if slices.Contains(other.Channels, m) {
+				return fmt.Errorf("channel match %+v already belongs to tenant %s", m, other.ID)
+			}
+		}
+		if t.APIToken != "" && t.APIToken == other.APIToken {
+			return fmt.Errorf("api token already used by tenant %s", other.ID)
+		}
+	}
+	return nil
+}
+
+// Put adds or replaces t and saves the store.
+func (s *Store) Put(t Tenant) error {
+	t.normalize()
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkLocked(t); err != nil {
+		return err
+	}
+	s.tenants[t.ID] = t
+	return s.saveLocked()
+}
+
+// Delete removes the tenant with id and saves the store.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tenants[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.tenants, id)
+	return s.saveLocked()
+}
+
+func (s *Store) saveLocked() error {
+	data, err := json.MarshalIndent(s.tenants, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(s.path, data, 0o600)
+}
+
+// ForMessage returns the tenant whose most specific channel match covers
+// msg.
+func (s *Store) ForMessage(msg Match) (Tenant, bool) {
+	if s == nil {
+		return Tenant{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var best Tenant
+	bestRank := 0
+	for _, t := range s.tenants {
+		for _, m := range t.Channels {
+			if rank := m.specificity(msg); rank > bestRank || (rank == bestRank && rank > 0 && t.ID < best.ID) {
+				best, bestRank = t, rank
+			}
+		}
+	}
+	return best, bestRank > 0
+}
+
+// ForAgent returns the tenant that owns agentID.
+func (s *Store) ForAgent(agentID string) (Tenant, bool) {
+	if s == nil {
+		return Tenant{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, t := range s.tenants {
+		if t.OwnsAgent(agentID) {
+			return t, true
+		}
+	}
+	return Tenant{}, false
+}
+
+// ModelOwner returns the ID of the tenant that reserved model, or "".
+func (s *Store) ModelOwner(model string) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, t := range s.tenants {
+		if slices.Contains(t.Models, model) {
+			return t.ID
+		}
+	}
+	return ""
+}
+
+// SharedAgents returns the agents of ids that belong to no tenant, in order.
+func (s *Store) SharedAgents(ids []string) []string {
+	var shared []string
+	for _, id := range ids {
+		if _, owned := s.ForAgent(id); !owned {
+			shared = append(shared, id)
+		}
+	}
+	return shared
+}
@@ -0,0 +1,151 @@
+package tenant
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func acme() Tenant {
+	return Tenant{
+		ID:       "acme",
+		Agents:   []string{"acme", "acme-support"},
+		Channels: []Match{{Channel: "discord", GuildID: "g1"}, {Channel: "telegram", ChatID: "-100"}},
+		Models:   []string{"acme-gpt"},
+		Admins:   []string{"discord:1"},
+		Quota:    Quota{DailyMessages: 2},
+		APIToken: "acme-token",
+	}
+}
+
+func TestStore_PutAndMatch(t *testing.T) {
+	path := Path(t.TempDir())
+	s := NewStore(path)
+	if err := s.Put(acme()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(Tenant{ID: "globex", Agents: []string{"globex"}, Channels: []Match{{Channel: "discord"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := NewStore(path)
+	if got, ok := reloaded.ForMessage(Match{Channel: "discord", GuildID: "g1", ChatID: "c9"}); !ok || got.ID != "acme" {
+		t.Errorf("guild match = %q, %v", got.ID, ok)
+	}
+	if got, ok := reloaded.ForMessage(Match{Channel: "discord", GuildID: "g2"}); !ok || got.ID != "globex" {
+		t.Errorf("channel match = %q, %v", got.ID, ok)
+	}
+	if _, ok := reloaded.ForMessage(Match{Channel: "telegram", ChatID: "42"}); ok {
+		t.Error("unclaimed chat matched a tenant")
+	}
+	if got, ok := reloaded.ForAgent("Acme-Support"); !ok || got.ID != "acme" {
+		t.Errorf("ForAgent = %q, %v", got.ID, ok)
+	}
+	if reloaded.ModelOwner("acme-gpt") != "acme" || reloaded.ModelOwner("gpt-4") != "" {
+		t.Error("wrong model owner")
+	}
+	if shared := reloaded.SharedAgents([]string{"main", "acme", "globex"}); len(shared) != 1 || shared[0] != "main" {
+		t.Errorf("SharedAgents = %v", shared)
+	}
+}
+
+func TestStore_RejectsConflicts(t *testing.T) {
+	s := NewStore(Path(t.TempDir()))
+	if err := s.Put(acme()); err != nil {
+		t.Fatal(err)
+	}
+	for name, other := range map[string]Tenant{
+		"agent":   {ID: "b", Agents: []string{"acme"}, Channels: []Match{{Channel: "slack"}}},
+		"model":   {ID: "b", Agents: []string{"b"}, Channels: []Match{{Channel: "slack"}}, Models: []string{"acme-gpt"}},
+		"channel": {ID: "b", Agents: []string{"b"}, Channels: []Match{{Channel: "discord", GuildID: "g1"}}},
+		"id":      {ID: "Not Valid", Agents: []string{"b"}, Channels: []Match{{Channel: "slack"}}},
+		"empty":   {ID: "b", Channels: []Match{{Channel: "slack"}}},
+	} {
+		if err := s.Put(other); err == nil {
+			t.Errorf("%s conflict accepted", name)
+		}
+	}
+}
+
+func TestMeter(t *testing.T) {
+	m := NewMeter()
+	day := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	m.now = func() time.Time { return day }
+	q := Quota{DailyMessages: 2, DailyTokens: 1000}
+
+	m.Observe("acme", usage.Record{Time: day, TurnStart: true, PromptTokens: 100})
+	m.Observe("acme", usage.Record{Time: day, PromptTokens: 50})
+	m.Observe("acme", usage.Record{Time: day.AddDate(0, 0, -1), TurnStart: true, PromptTokens: 5000})
+	if used := m.Used("acme"); used.Messages != 1 || used.Tokens != 150 {
+		t.Fatalf("Used = %+v", used)
+	}
+	if resource, _ := m.Exceeded("acme", q); resource != "" {
+		t.Fatalf("exceeded %s early", resource)
+	}
+	m.Observe("acme", usage.Record{Time: day, TurnStart: true})
+	if resource, limit := m.Exceeded("acme", q); resource != "messages" || limit != 2 {
+		t.Errorf("Exceeded = %q, %d", resource, limit)
+	}
+
+	m.now = func() time.Time { return day.AddDate(0, 0, 1) }
+	if used := m.Used("acme"); used != (Usage{}) {
+		t.Errorf("usage not reset on a new day: %+v", used)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	s := NewStore(Path(t.TempDir()))
+	h := NewHandler(s, NewMeter(), []string{"admin"}, func(t Tenant) error { return nil })
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	body, _ := json.Marshal(acme())
+	if w := do(http.MethodPut, "/tenants/acme", "admin", string(body)); w.Code != http.StatusOK ||
+		strings.Contains(w.Body.String(), "acme-token") {
+		t.Fatalf("PUT: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/tenants", "acme-token", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("tenant token listed tenants: %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/tenants/acme", "acme-token", ""); w.Code != http.StatusOK ||
+		!strings.Contains(w.Body.String(), `"has_api_token":true`) {
+		t.Errorf("GET own tenant: %d %s", w.Code, w.Body.String())
+	}
+
+	// A tenant admin changes its admins but not its quota.
+	if w := do(http.MethodPut, "/tenants/acme", "acme-token",
+		`{"admins":["discord:2"],"quota":{"daily_messages":1000000}}`); w.Code != http.StatusOK {
+		t.Fatalf("tenant PUT: %d %s", w.Code, w.Body.String())
+	}
+	got, _ := s.Get("acme")
+	if len(got.Admins) != 1 || got.Admins[0] != "discord:2" || got.Quota.DailyMessages != 2 || got.APIToken != "acme-token" {
+		t.Errorf("after tenant PUT = %+v", got)
+	}
+	if w := do(http.MethodDelete, "/tenants/acme", "acme-token", ""); w.Code != http.StatusForbidden {
+		t.Errorf("tenant DELETE: %d", w.Code)
+	}
+
+	var list listResponse
+	w := do(http.MethodGet, "/tenants", "admin", "")
+	if json.Unmarshal(w.Body.Bytes(), &list) != nil || len(list.Tenants) != 1 {
+		t.Fatalf("GET /tenants: %s", w.Body.String())
+	}
+	if w := do(http.MethodDelete, "/tenants/acme", "admin", ""); w.Code != http.StatusNoContent {
+		t.Errorf("admin DELETE: %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/tenants/acme", "admin", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET deleted tenant: %d", w.Code)
+	}
+}
@@ -0,0 +1,223 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BPE is a byte pair encoding tokenizer using a tiktoken rank file, such as
+// cl100k_base.tiktoken or o200k_base.tiktoken. Text is split into pieces
+// the way cl100k_base splits it, so counts for o200k_base can be off by a
+// token here and there. Special tokens are counted as plain text.
+type BPE struct {
+	ranks map[string]int
+}
+
+// LoadBPE reads a tiktoken rank file: one base64 token and its rank per line.
+func LoadBPE(path string) (*BPE, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks := make(map[string]int)
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		token, rank, ok := bytes.Cut(text, []byte(" "))
+		if !ok {
+			return nil, fmt.Errorf("line %d: want a token and a rank", line)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(string(token))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		n, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranks[string(decoded)] = n
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("no tokens in %s", path)
+	}
+	return &BPE{ranks: ranks}, nil
+}
+
+func (b *BPE) Count(text string) int {
+	n := 0
+	for _, piece := range split(text) {
+		if _, ok := b.ranks[piece]; ok {
+			n++
+			continue
+		}
+		n += b.merge(piece)
+	}
+	return n
+}
+
+// merge returns how many tokens piece encodes to, merging the adjacent pair
+// with the lowest rank until no pair is in the vocabulary.
+func (b *BPE) merge(piece string) int {
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, 0
+		for i := 0; i+2 < len(bounds); i++ {
+			rank, ok := b.ranks[piece[bounds[i]:bounds[i+2]]]
+			if ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// split breaks text into the pieces tiktoken encodes separately, following
+// the cl100k_base pattern:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// Go's regexp has no lookahead, so the alternatives are matched by hand.
+func split(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		n := matchPiece(text)
+		pieces = append(pieces, text[:n])
+		text = text[n:]
+	}
+	return pieces
+}
+
+// matchPiece returns the length of the piece text starts with.
+func matchPiece(text string) int {
+	if n := matchContraction(text); n > 0 {
+		return n
+	}
+	r, size := utf8.DecodeRuneInString(text)
+
+	// [^\r\n\p{L}\p{N}]?\p{L}+
+	if isLetter(r) {
+		return size + spanOf(text[size:], isLetter)
+	}
+	if r != '\r' && r != '\n' && !isNumber(r) {
+		if n := spanOf(text[size:], isLetter); n > 0 {
+			return size + n
+		}
+	}
+
+	// \p{N}{1,3}
+	if isNumber(r) {
+		n := size
+		for i := 1; i < 3 && n < len(text); i++ {
+			next, nextSize := utf8.DecodeRuneInString(text[n:])
+			if !isNumber(next) {
+				break
+			}
+			n += nextSize
+		}
+		return n
+	}
+
+	// ' ?[^\s\p{L}\p{N}]+[\r\n]*'
+	start := 0
+	if r == ' ' {
+		start = size
+	}
+	if n := spanOf(text[start:], isPunct); n > 0 {
+		n += start
+		return n + spanOf(text[n:], isNewline)
+	}
+
+	// \s*[\r\n]+
+	space := spanOf(text, unicode.IsSpace)
+	if end := lastNewline(text[:space]); end > 0 {
+		return end
+	}
+
+	// \s+(?!\S)|\s+
+	if space < len(text) && space > size {
+		_, last := utf8.DecodeLastRuneInString(text[:space])
+		return space - last
+	}
+	if space > 0 {
+		return space
+	}
+	return size
+}
+
+// matchContraction matches 's, 't, 're, 've, 'm, 'll and 'd in any case.
+func matchContraction(text string) int {
+	if len(text) < 2 || text[0] != '\'' {
+		return 0
+	}
+	lower := func(c byte) byte {
+		if 'A' <= c && c <= 'Z' {
+			return c + 'a' - 'A'
+		}
+		return c
+	}
+	switch lower(text[1]) {
+	case 's', 't', 'm', 'd':
+		return 2
+	}
+	if len(text) < 3 {
+		return 0
+	}
+	switch string([]byte{lower(text[1]), lower(text[2])}) {
+	case "re", "ve", "ll":
+		return 3
+	}
+	return 0
+}
+
+// spanOf returns the length of the prefix of text whose runes satisfy f.
+func spanOf(text string, f func(rune) bool) int {
+	for i, r := range text {
+		if !f(r) {
+			return i
+		}
+	}
+	return len(text)
+}
+
+// lastNewline returns the length of space up to and including its last
+// \r or \n, or 0 if it has none.
+func lastNewline(space string) int {
+	for i := len(space) - 1; i >= 0; i-- {
+		if isNewline(rune(space[i])) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func isLetter(r rune) bool { return unicode.IsLetter(r) }
+
+func isNumber(r rune) bool { return unicode.IsNumber(r) }
+
+func isNewline(r rune) bool { return r == '\r' || r == '\n' }
+
+func isPunct(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
@@ -0,0 +1,46 @@
+// Package tokenizer counts the tokens a model sees in a piece of text. A
+// tiktoken rank file gives exact counts for OpenAI models and close ones for
+// most others; without one, a character heuristic that errs on the high side
+// is used.
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens in text.
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// Heuristic estimates 2.5 characters per token, which overcounts English
+// slightly so that CJK text and other dense scripts are not undercounted.
+type Heuristic struct{}
+
+func (Heuristic) Count(text string) int {
+	return (utf8.RuneCountInString(text)*2 + 4) / 5
+}
+
+var loaded sync.Map // path -> *BPE
+
+// Load returns the tokenizer named by spec: "" or "heuristic" for the
+// heuristic, otherwise the path of a tiktoken rank file. Rank files are
+// parsed once and shared.
+func Load(spec string) (Tokenizer, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "heuristic" {
+		return Heuristic{}, nil
+	}
+	if t, ok := loaded.Load(spec); ok {
+		return t.(*BPE), nil
+	}
+	t, err := LoadBPE(spec)
+	if err != nil {
+		return nil, fmt.Errorf("loading tokenizer %s: %w", spec, err)
+	}
+	actual, _ := loaded.LoadOrStore(spec, t)
+	return actual.(*BPE), nil
+}
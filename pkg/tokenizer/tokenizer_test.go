@@ -0,0 +1,106 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello world", []string{"Hello", " world"}},
+		{"don't STOP", []string{"don", "'t", " STOP"}},
+		{"12345", []string{"123", "45"}},
+		{"a  b", []string{"a", " ", " b"}},
+		{"end.\n\nNext", []string{"end", ".\n\n", "Next"}},
+		{"x\n  y", []string{"x", "\n", " ", " y"}},
+		{"(hi) !!", []string{"(hi", ")", " !!"}},
+		{"trailing  ", []string{"trailing", "  "}},
+	}
+	for _, tt := range tests {
+		if got := split(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("split(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func writeRanks(t *testing.T, tokens ...string) string {
+	t.Helper()
+	var b strings.Builder
+	rank := 0
+	for c := range 256 {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(c)}), rank)
+		rank++
+	}
+	for _, tok := range tokens {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(tok)), rank)
+		rank++
+	}
+	path := filepath.Join(t.TempDir(), "test.tiktoken")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBPE_Count(t *testing.T) {
+	bpe, err := LoadBPE(writeRanks(t, "he", "ll", "hell", " w", "or"))
+	if err != nil {
+		t.Fatalf("LoadBPE() error = %v", err)
+	}
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello", 2},       // hell + o
+		{"hello world", 6}, // hell o | " w" or l d
+		{"xyz", 3},
+	}
+	for _, tt := range tests {
+		if got := bpe.Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	for _, spec := range []string{"", "heuristic"} {
+		tok, err := Load(spec)
+		if err != nil {
+			t.Fatalf("Load(%q) error = %v", spec, err)
+		}
+		if _, ok := tok.(Heuristic); !ok {
+			t.Errorf("Load(%q) = %T, want Heuristic", spec, tok)
+		}
+	}
+
+	path := writeRanks(t)
+	first, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load(%s) error = %v", path, err)
+	}
+	if second, _ := Load(path); second != first {
+		t.Error("Load() parsed the same rank file twice")
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.tiktoken")); err == nil {
+		t.Error("Load(missing) error = nil")
+	}
+}
+
+func TestHeuristic_Count(t *testing.T) {
+	if got := (Heuristic{}).Count("hello"); got != 2 {
+		t.Errorf("Count(hello) = %d, want 2", got)
+	}
+	if got := (Heuristic{}).Count("你好世界"); got != 2 {
+		t.Errorf("Count(你好世界) = %d, want 2", got)
+	}
+}
@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const approvalTTL = 5 * time.Minute
+
+// ErrNoPendingApproval is returned when an approval ID is unknown, expired, or
+// belongs to another user.
+var ErrNoPendingApproval = errors.New("no pending action with that ID")
+
+type pendingAction struct {
+	summary  string
+	channel  string
+	senderID string
+	expires  time.Time
+	run      func(ctx context.Context) *ToolResult
+}
+
+// ApprovalQueue holds tool actions that must not run until the user who
+// asked for them confirms with /approve. Tools call Hold instead of acting;
+// the action runs from the /approve command, outside the LLM turn.
+type ApprovalQueue struct {
+	mu      sync.Mutex
+	pending map[string]*pendingAction
+	send    SendCallback
+	prompt  ApprovalPromptCallback
+}
+
+// ApprovalPromptCallback posts the prompt of the held action id, so the
+// sender can tie the message to it, for example to approve by reaction.
+type ApprovalPromptCallback func(channel, chatID, id, content string) error
+
+func NewApprovalQueue() *ApprovalQueue {
+	return &ApprovalQueue{pending: make(map[string]*pendingAction)}
+}
+
+// SetSendCallback sets how the approval prompt reaches the chat.
+func (q *ApprovalQueue) SetSendCallback(send SendCallback) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.send = send
+}
+
+// SetPromptCallback sets how the approval prompt reaches the chat, in place
+// of the SendCallback.
+func (q *ApprovalQueue) SetPromptCallback(prompt ApprovalPromptCallback) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prompt = prompt
+}
+
+// Hold parks run until the sender of the current message approves it, posts
+// the approval prompt to the chat, and returns the result for the model.
+func (q *ApprovalQueue) Hold(ctx context.Context, summary string, run func(ctx context.Context) *ToolResult) *ToolResult {
+	buf := make([]byte, 3)
+	if _, err := rand.Read(buf); err != nil {
+		return ErrorResult("failed to request approval: " + err.Error())
+	}
+	id := strings.ToUpper(hex.EncodeToString(buf))
+	channel, chatID := ToolChannel(ctx), ToolChatID(ctx)
+
+	q.mu.Lock()
+	now := time.Now()
+	for k, p := range q.pending {
+		if now.After(p.expires) {
+			delete(q.pending, k)
+		}
+	}
+	q.pending[id] = &pendingAction{
+		summary:  summary,
+		channel:  channel,
+		senderID: ToolSenderID(ctx),
+		expires:  now.Add(approvalTTL),
+		run:      run,
+	}
+	send, promptCallback := q.send, q.prompt
+	q.mu.Unlock()
+
+	prompt := fmt.Sprintf("⚠️ Approval needed: %s\nReply /approve %s to run it or /deny %s to cancel (expires in %d minutes).",
+		summary, id, id, int(approvalTTL.Minutes()))
+	if promptCallback != nil {
+		send = func(channel, chatID, content string) error { return promptCallback(channel, chatID, id, content) }
+	}
+	if send != nil && channel != "" {
+		err := send(channel, chatID, prompt)
+		if err == nil {
+			return SilentResult(fmt.Sprintf("The action has NOT run yet. The user was asked to approve it with /approve %s. "+
+				"Do not call the tool again for this action; tell the user it is waiting for their approval.", id))
+		}
+		logger.WarnCF("tool", "Failed to post approval prompt", map[string]any{"error": err.Error()})
+	}
+	return SilentResult("The action has NOT run yet. Show the user this message exactly:\n" + prompt)
+}
+
+// Resolve approves or denies the action id on behalf of channel/senderID.
+// Approved actions run with ctx and their result is returned.
+func (q *ApprovalQueue) Resolve(
+	ctx context.Context,
+	id, channel, senderID string,
+	approve bool,
+) (summary string, result *ToolResult, err error) {
+	id = strings.ToUpper(strings.TrimSpace(id))
+	q.mu.Lock()
+	p, ok := q.pending[id]
+	if ok && (p.channel != channel || p.senderID != senderID) {
+		ok = false
+	}
+	if ok {
+		delete(q.pending, id)
+	}
+	q.mu.Unlock()
+	if !ok || time.Now().After(p.expires) {
+		return "", nil, ErrNoPendingApproval
+	}
+
+	logger.InfoCF("tool", "Held tool action resolved", map[string]any{
+		"id":       id,
+		"approved": approve,
+		"action":   p.summary,
+		"channel":  channel,
+	})
+	if !approve {
+		return p.summary, nil, nil
+	}
+	return p.summary, p.run(ctx), nil
+}
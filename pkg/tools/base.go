@@ -1,6 +1,12 @@
 package tools
 
-import "context"
+import (
+	"context"
+	"slices"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/identity"
+)
 
 // Tool is the interface that all tools must implement.
 type Tool interface {
@@ -21,8 +27,11 @@ type Tool interface {
 type toolCtxKey struct{ name string }
 
 var (
-	ctxKeyChannel = &toolCtxKey{"channel"}
-	ctxKeyChatID  = &toolCtxKey{"chatID"}
+	ctxKeyChannel  = &toolCtxKey{"channel"}
+	ctxKeyChatID   = &toolCtxKey{"chatID"}
+	ctxKeyGuildID  = &toolCtxKey{"guildID"}
+	ctxKeySenderID = &toolCtxKey{"senderID"}
+	ctxKeySender   = &toolCtxKey{"sender"}
 )
 
 // WithToolContext returns a child context carrying channel and chatID.
@@ -44,6 +53,43 @@ func ToolChatID(ctx context.Context) string {
 	return v
 }
 
+// WithToolGuild returns a child context carrying the guild or workspace ID
+// (Discord guild, Slack team) the current message came from.
+func WithToolGuild(ctx context.Context, guildID string) context.Context {
+	return context.WithValue(ctx, ctxKeyGuildID, guildID)
+}
+
+// ToolGuildID extracts the guild ID from ctx, or "" if unset.
+func ToolGuildID(ctx context.Context) string {
+	v, _ := ctx.Value(ctxKeyGuildID).(string)
+	return v
+}
+
+// WithToolSender returns a child context carrying the ID of the user whose
+// message triggered the tool call.
+func WithToolSender(ctx context.Context, senderID string) context.Context {
+	return context.WithValue(ctx, ctxKeySenderID, senderID)
+}
+
+// ToolSenderID extracts the sender ID from ctx, or "" if unset.
+func ToolSenderID(ctx context.Context) string {
+	v, _ := ctx.Value(ctxKeySenderID).(string)
+	return v
+}
+
+// WithToolSenderInfo returns a child context carrying the identity of the
+// user whose message triggered the tool call, as the channel reported it.
+func WithToolSenderInfo(ctx context.Context, sender bus.SenderInfo) context.Context {
+	return context.WithValue(ctx, ctxKeySender, sender)
+}
+
+// ToolSender extracts the identity of the sender from ctx. Without one, it
+// is built from the channel and sender ID.
+func ToolSender(ctx context.Context) bus.SenderInfo {
+	v, _ := ctx.Value(ctxKeySender).(bus.SenderInfo)
+	return identity.SenderOrID(v, ToolChannel(ctx), ToolSenderID(ctx))
+}
+
 // AsyncCallback is a function type that async tools use to notify completion.
 // When an async tool finishes its work, it calls this callback with the result.
 //
@@ -81,6 +127,21 @@ type AsyncExecutor interface {
 	ExecuteAsync(ctx context.Context, args map[string]any, cb AsyncCallback) *ToolResult
 }
 
+// ChannelRestricted is an optional interface for tools offered only on some
+// channels. Requests from other channels do not list the tool, and the
+// registry refuses to run it there.
+type ChannelRestricted interface {
+	AvailableOn(channel string) bool
+}
+
+// channelAllowList implements ChannelRestricted for tools configured with a
+// list of channels. An empty list allows every channel.
+type channelAllowList []string
+
+func (l channelAllowList) AvailableOn(channel string) bool {
+	return len(l) == 0 || slices.Contains(l, channel)
+}
+
 func ToolToSchema(tool Tool) map[string]any {
 	return map[string]any{
 		"type": "function",
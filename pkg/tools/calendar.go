@@ -0,0 +1,313 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/calendar"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	calendarDefaultDays     = 7
+	calendarMaxDays         = 90
+	calendarDefaultLimit    = 20
+	calendarMaxLimit        = 100
+	calendarDefaultDuration = 60 * time.Minute
+)
+
+// CalendarTool lists and creates events. With Google Calendar each user's
+// own account is used, linked beforehand with /calendar link; with CalDAV
+// everyone shares the configured calendar.
+type CalendarTool struct {
+	cfg config.CalendarToolConfig
+	loc *time.Location
+
+	mu     sync.RWMutex
+	linker *calendar.Linker
+}
+
+func NewCalendarTool(cfg config.CalendarToolConfig) *CalendarTool {
+	loc := time.Local
+	if cfg.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Timezone); err == nil {
+			loc = l
+		}
+	}
+	return &CalendarTool{cfg: cfg, loc: loc}
+}
+
+// SetLinker injects the account linker used to find each user's Google token.
+func (t *CalendarTool) SetLinker(l *calendar.Linker) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.linker = l
+}
+
+func (t *CalendarTool) Name() string {
+	return "calendar"
+}
+
+func (t *CalendarTool) Description() string {
+	return "List upcoming events or create an event in the user's calendar. " +
+		"Convert natural-language dates and times to ISO 8601 (e.g. 2026-03-14T15:00) before calling; " +
+		fmt.Sprintf("times without an offset are in %s. ", t.loc) +
+		"For all-day events give dates only (YYYY-MM-DD)."
+}
+
+func (t *CalendarTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "create"},
+				"description": "Operation to perform",
+			},
+			"from": map[string]any{
+				"type":        "string",
+				"description": "Start of the range to list (list, default now)",
+			},
+			"to": map[string]any{
+				"type":        "string",
+				"description": "End of the range to list (list, default from + days)",
+			},
+			"days": map[string]any{
+				"type":        "integer",
+				"description": "Number of days to list when 'to' is not given (list, default 7)",
+				"minimum":     1.0,
+				"maximum":     float64(calendarMaxDays),
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum events to return (list, default 20)",
+				"minimum":     1.0,
+				"maximum":     float64(calendarMaxLimit),
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": "Event title (create)",
+			},
+			"start": map[string]any{
+				"type":        "string",
+				"description": "Event start date-time, or date for all-day events (create)",
+			},
+			"end": map[string]any{
+				"type":        "string",
+				"description": "Event end date-time, or last day for all-day events (create, optional)",
+			},
+			"duration_minutes": map[string]any{
+				"type":        "integer",
+				"description": "Event length when 'end' is not given (create, default 60)",
+				"minimum":     1.0,
+			},
+			"location": map[string]any{
+				"type":        "string",
+				"description": "Event location (create)",
+			},
+			"description": map[string]any{
+				"type":        "string",
+				"description": "Event notes (create)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *CalendarTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	client, err := t.client(ctx)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "list":
+		return t.list(ctx, client, args)
+	case "create":
+		return t.create(ctx, client, args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q (expected list or create)", action))
+	}
+}
+
+func (t *CalendarTool) list(ctx context.Context, client calendar.Client, args map[string]any) *ToolResult {
+	from := time.Now().In(t.loc)
+	if s, _ := args["from"].(string); s != "" {
+		parsed, _, err := parseCalendarTime(s, t.loc)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		from = parsed
+	}
+	days := calendarDefaultDays
+	if n, ok := args["days"].(float64); ok && n >= 1 {
+		days = min(int(n), calendarMaxDays)
+	}
+	to := from.AddDate(0, 0, days)
+	if s, _ := args["to"].(string); s != "" {
+		parsed, dateOnly, err := parseCalendarTime(s, t.loc)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		to = parsed
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+	if !to.After(from) {
+		return ErrorResult("'to' must be after 'from'")
+	}
+	limit := calendarDefaultLimit
+	if n, ok := args["limit"].(float64); ok && n >= 1 {
+		limit = min(int(n), calendarMaxLimit)
+	}
+
+	events, err := client.List(ctx, from, to, limit)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to list events: %v", err)).WithError(err)
+	}
+	rangeText := fmt.Sprintf("%s to %s", from.Format("Mon Jan 2 15:04"), to.Format("Mon Jan 2 15:04 MST"))
+	if len(events) == 0 {
+		return SilentResult("No events from " + rangeText)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d event(s) from %s:\n", len(events), rangeText)
+	for _, ev := range events {
+		b.WriteString(t.formatEvent(ev))
+	}
+	return SilentResult(b.String())
+}
+
+func (t *CalendarTool) create(ctx context.Context, client calendar.Client, args map[string]any) *ToolResult {
+	title, _ := args["title"].(string)
+	startText, _ := args["start"].(string)
+	if strings.TrimSpace(title) == "" || startText == "" {
+		return ErrorResult("title and start are required to create an event")
+	}
+	start, allDay, err := parseCalendarTime(startText, t.loc)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	ev := calendar.Event{Title: title, Start: start, AllDay: allDay}
+	ev.Location, _ = args["location"].(string)
+	ev.Description, _ = args["description"].(string)
+
+	if endText, _ := args["end"].(string); endText != "" {
+		end, endDateOnly, err := parseCalendarTime(endText, t.loc)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		if allDay != endDateOnly {
+			return ErrorResult("start and end must both be dates or both be date-times")
+		}
+		ev.End = end
+		if allDay {
+			ev.End = end.AddDate(0, 0, 1) // the last day is inclusive for the caller
+		}
+	} else if allDay {
+		ev.End = start.AddDate(0, 0, 1)
+	} else {
+		duration := calendarDefaultDuration
+		if n, ok := args["duration_minutes"].(float64); ok && n >= 1 {
+			duration = time.Duration(n) * time.Minute
+		}
+		ev.End = start.Add(duration)
+	}
+	if !ev.End.After(ev.Start) {
+		return ErrorResult("the event must end after it starts")
+	}
+
+	created, err := client.Create(ctx, ev)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create event: %v", err)).WithError(err)
+	}
+	return NewToolResult("Created event:\n" + t.formatEvent(*created))
+}
+
+// client returns the calendar for the user who sent the current message.
+func (t *CalendarTool) client(ctx context.Context) (calendar.Client, error) {
+	switch strings.ToLower(t.cfg.Provider) {
+	case "", "google":
+		t.mu.RLock()
+		linker := t.linker
+		t.mu.RUnlock()
+		if linker == nil {
+			return nil, errors.New("google calendar linking is only available when running the gateway")
+		}
+		token, err := linker.Token(ctx, calendar.UserKey(ToolChannel(ctx), ToolSenderID(ctx)))
+		if errors.Is(err, calendar.ErrNotLinked) {
+			return nil, errors.New("this user has not linked a Google Calendar; " +
+				"ask them to send /calendar link in a direct message")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return calendar.NewGoogleClient(token, t.cfg.Google.CalendarID, t.loc), nil
+	case "caldav":
+		if t.cfg.CalDAV.URL == "" {
+			return nil, errors.New("caldav needs a calendar url")
+		}
+		return calendar.NewCalDAVClient(t.cfg.CalDAV.URL, t.cfg.CalDAV.Username, t.cfg.CalDAV.Password, t.loc), nil
+	default:
+		return nil, fmt.Errorf("unsupported calendar provider %q (expected google or caldav)", t.cfg.Provider)
+	}
+}
+
+func (t *CalendarTool) formatEvent(ev calendar.Event) string {
+	var b strings.Builder
+	if ev.AllDay {
+		last := ev.End.AddDate(0, 0, -1)
+		fmt.Fprintf(&b, "- %s", ev.Start.Format("Mon Jan 2"))
+		if last.After(ev.Start) {
+			fmt.Fprintf(&b, " to %s", last.Format("Mon Jan 2"))
+		}
+		b.WriteString(" (all day)")
+	} else {
+		start, end := ev.Start.In(t.loc), ev.End.In(t.loc)
+		endLayout := "15:04"
+		if end.YearDay() != start.YearDay() || end.Year() != start.Year() {
+			endLayout = "Mon Jan 2 15:04"
+		}
+		fmt.Fprintf(&b, "- %s-%s", start.Format("Mon Jan 2 15:04"), end.Format(endLayout))
+	}
+	fmt.Fprintf(&b, " %s", ev.Title)
+	if ev.Location != "" {
+		fmt.Fprintf(&b, " @ %s", ev.Location)
+	}
+	if ev.URL != "" {
+		fmt.Fprintf(&b, " %s", ev.URL)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+var calendarTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// parseCalendarTime accepts RFC 3339, a local date-time without an offset,
+// or a bare date. dateOnly is true for bare dates.
+func parseCalendarTime(s string, loc *time.Location) (t time.Time, dateOnly bool, err error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	for _, layout := range calendarTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, false, nil
+		}
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("could not parse time %q; use ISO 8601 such as 2026-03-14T15:00", s)
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestParseCalendarTime(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Berlin")
+	tests := []struct {
+		in       string
+		want     string
+		dateOnly bool
+	}{
+		{"2026-03-14T15:00", "2026-03-14T15:00:00+01:00", false},
+		{"2026-03-14 15:00:30", "2026-03-14T15:00:30+01:00", false},
+		{"2026-03-14T15:00:00Z", "2026-03-14T15:00:00Z", false},
+		{"2026-03-14", "2026-03-14T00:00:00+01:00", true},
+	}
+	for _, tt := range tests {
+		got, dateOnly, err := parseCalendarTime(tt.in, loc)
+		if err != nil || got.Format(time.RFC3339) != tt.want || dateOnly != tt.dateOnly {
+			t.Errorf("parseCalendarTime(%q) = %s, %v, %v", tt.in, got.Format(time.RFC3339), dateOnly, err)
+		}
+	}
+	if _, _, err := parseCalendarTime("next tuesday", loc); err == nil {
+		t.Error("natural language should be converted by the model, not parsed here")
+	}
+}
+
+func TestCalendarTool_CreateAllDayEndIsInclusive(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	tool := NewCalendarTool(config.CalendarToolConfig{
+		Provider: "caldav",
+		Timezone: "UTC",
+		CalDAV:   config.CalDAVConfig{URL: srv.URL},
+	})
+	result := tool.Execute(context.Background(), map[string]any{
+		"action": "create",
+		"title":  "Conference",
+		"start":  "2026-03-14",
+		"end":    "2026-03-15",
+	})
+	if result.IsError {
+		t.Fatalf("create failed: %s", result.ForLLM)
+	}
+	if !strings.Contains(body, "DTSTART;VALUE=DATE:20260314") || !strings.Contains(body, "DTEND;VALUE=DATE:20260316") {
+		t.Errorf("ics body = %s", body)
+	}
+	if !strings.Contains(result.ForLLM, "Sat Mar 14 to Sun Mar 15 (all day) Conference") {
+		t.Errorf("result = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"action": "create", "title": "Bad", "start": "2026-03-14T10:00", "end": "2026-03-14T09:00",
+	})
+	if !result.IsError {
+		t.Error("event ending before it starts should be rejected")
+	}
+}
+
+func TestCalendarTool_GoogleNeedsLinkedAccount(t *testing.T) {
+	tool := NewCalendarTool(config.CalendarToolConfig{Provider: "google"})
+	result := tool.Execute(context.Background(), map[string]any{"action": "list"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "gateway") {
+		t.Errorf("without linker: %+v", result)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
 	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/notify"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
@@ -26,6 +27,7 @@ type CronTool struct {
 	execTool     *ExecTool
 	allowCommand bool
 	execEnabled  bool
+	notifier     *notify.Renderer
 }
 
 // NewCronTool creates a new CronTool
@@ -36,14 +38,20 @@ func NewCronTool(
 ) (*CronTool, error) {
 	allowCommand := true
 	execEnabled := true
+	var templates map[string]map[string]string
 	if config != nil {
 		allowCommand = config.Tools.Cron.AllowCommand
 		execEnabled = config.Tools.Exec.Enabled
+		templates = config.Notifications.Templates
+	}
+
+	notifier, err := notify.NewRenderer(templates)
+	if err != nil {
+		return nil, err
 	}
 
 	var execTool *ExecTool
 	if execEnabled {
-		var err error
 		execTool, err = NewExecToolWithConfig(workspace, restrict, config)
 		if err != nil {
 			return nil, fmt.Errorf("unable to configure exec tool: %w", err)
@@ -60,6 +68,7 @@ func NewCronTool(
 		execTool:     execTool,
 		allowCommand: allowCommand,
 		execEnabled:  execEnabled,
+		notifier:     notifier,
 	}, nil
 }
 
@@ -230,9 +239,10 @@ func (t *CronTool) addJob(ctx context.Context, args map[string]any) *ToolResult
 		return ErrorResult(fmt.Sprintf("Error adding job: %v", err))
 	}
 
-	if command != "" {
-		job.Payload.Command = command
-		// Need to save the updated payload
+	job.Payload.Command = command
+	job.CreatedBy = ToolSenderID(ctx)
+	if command != "" || job.CreatedBy != "" {
+		// Need to save the updated job
 		t.cronService.UpdateJob(job)
 	}
 
@@ -270,6 +280,9 @@ func (t *CronTool) removeJob(args map[string]any) *ToolResult {
 	if !ok || jobID == "" {
 		return ErrorResult("job_id is required for remove")
 	}
+	if strings.HasPrefix(jobID, cron.ConfiguredJobPrefix) {
+		return configuredJobError(jobID)
+	}
 
 	if t.cronService.RemoveJob(jobID) {
 		return SilentResult(fmt.Sprintf("Cron job removed: %s", jobID))
@@ -282,6 +295,9 @@ func (t *CronTool) enableJob(args map[string]any, enable bool) *ToolResult {
 	if !ok || jobID == "" {
 		return ErrorResult("job_id is required for enable/disable")
 	}
+	if strings.HasPrefix(jobID, cron.ConfiguredJobPrefix) {
+		return configuredJobError(jobID)
+	}
 
 	job := t.cronService.EnableJob(jobID, enable)
 	if job == nil {
@@ -295,6 +311,13 @@ func (t *CronTool) enableJob(args map[string]any, enable bool) *ToolResult {
 	return SilentResult(fmt.Sprintf("Cron job '%s' %s", job.Name, status))
 }
 
+// configuredJobError refuses changes to a job from tools.cron.jobs, which
+// would be undone on the next start.
+func configuredJobError(jobID string) *ToolResult {
+	return ErrorResult(fmt.Sprintf(
+		"job %s is set in the config file (tools.cron.jobs) and can only be changed there", jobID))
+}
+
 // ExecuteJob executes a cron job through the agent
 func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 	// Get channel/chatID from job payload
@@ -312,7 +335,10 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 	// Execute command if present
 	if job.Payload.Command != "" {
 		if !t.execEnabled || t.execTool == nil {
-			output := "Error executing scheduled command: command execution is disabled"
+			output := t.notifier.Render(notify.EventCronCommandDisabled, channel, notify.Vars{
+				"Command": job.Payload.Command,
+				"JobName": job.Name,
+			})
 			pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer pubCancel()
 			t.msgBus.PublishOutbound(pubCtx, bus.OutboundMessage{
@@ -331,10 +357,16 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 
 		result := t.execTool.Execute(ctx, args)
 		var output string
+		vars := notify.Vars{
+			"Command": job.Payload.Command,
+			"JobName": job.Name,
+		}
 		if result.IsError {
-			output = fmt.Sprintf("Error executing scheduled command: %s", result.ForLLM)
+			vars["Error"] = result.ForLLM
+			output = t.notifier.Render(notify.EventCronCommandError, channel, vars)
 		} else {
-			output = fmt.Sprintf("Scheduled command '%s' executed:\n%s", job.Payload.Command, result.ForLLM)
+			vars["Output"] = result.ForLLM
+			output = t.notifier.Render(notify.EventCronCommandOutput, channel, vars)
 		}
 
 		pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
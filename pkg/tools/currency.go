@@ -0,0 +1,219 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	frankfurterBaseURL = "https://api.frankfurter.app"
+	openExchangeURL    = "https://openexchangerates.org"
+)
+
+// CurrencyTool converts amounts between currencies. It uses the daily ECB
+// reference rates from Frankfurter, or Open Exchange Rates when an API key is
+// configured, which also covers currencies the ECB does not publish.
+type CurrencyTool struct {
+	channelAllowList
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+func NewCurrencyTool(cfg config.CurrencyToolConfig) *CurrencyTool {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = frankfurterBaseURL
+		if cfg.APIKey != "" {
+			baseURL = openExchangeURL
+		}
+	}
+	return &CurrencyTool{
+		channelAllowList: cfg.Channels,
+		apiKey:           cfg.APIKey,
+		baseURL:          strings.TrimRight(baseURL, "/"),
+		client:           &http.Client{Timeout: utilityToolTimeout},
+	}
+}
+
+func (t *CurrencyTool) Name() string {
+	return "currency"
+}
+
+func (t *CurrencyTool) Description() string {
+	return "Convert an amount between currencies at the latest exchange rates. " +
+		"Currencies are ISO 4217 codes such as USD, EUR, JPY."
+}
+
+func (t *CurrencyTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"amount": map[string]any{
+				"type":        "number",
+				"description": "Amount to convert (default 1)",
+			},
+			"from": map[string]any{
+				"type":        "string",
+				"description": "Currency code to convert from",
+			},
+			"to": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Currency codes to convert to",
+			},
+		},
+		"required": []string{"from", "to"},
+	}
+}
+
+func (t *CurrencyTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	amount := 1.0
+	if n, ok := args["amount"].(float64); ok {
+		amount = n
+	}
+	from, _ := args["from"].(string)
+	from = strings.ToUpper(strings.TrimSpace(from))
+	var to []string
+	if list, ok := args["to"].([]any); ok {
+		for _, v := range list {
+			if s, ok := v.(string); ok && strings.TrimSpace(s) != "" {
+				to = append(to, strings.ToUpper(strings.TrimSpace(s)))
+			}
+		}
+	}
+	if !isCurrencyCode(from) || len(to) == 0 {
+		return ErrorResult("from and to must be ISO 4217 currency codes")
+	}
+	for _, code := range to {
+		if !isCurrencyCode(code) {
+			return ErrorResult(fmt.Sprintf("invalid currency code %q", code))
+		}
+	}
+
+	var (
+		rates map[string]float64
+		date  string
+		err   error
+	)
+	if t.apiKey != "" {
+		rates, date, err = t.openExchangeRates(ctx, from, to)
+	} else {
+		rates, date, err = t.frankfurter(ctx, from, to)
+	}
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to get exchange rates: %v", err)).WithError(err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s at rates from %s:\n", formatAmount(amount), from, date)
+	var missing []string
+	for _, code := range to {
+		rate, ok := rates[code]
+		if !ok {
+			missing = append(missing, code)
+			continue
+		}
+		fmt.Fprintf(&b, "- %s %s (1 %s = %s %s)\n", formatAmount(amount*rate), code, from, formatAmount(rate), code)
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		fmt.Fprintf(&b, "No rate available for %s.\n", strings.Join(missing, ", "))
+	}
+	return SilentResult(b.String())
+}
+
+// frankfurter returns the rate from one unit of from to each of to.
+func (t *CurrencyTool) frankfurter(ctx context.Context, from string, to []string) (map[string]float64, string, error) {
+	rates := make(map[string]float64, len(to))
+	var symbols []string
+	for _, code := range to {
+		if code == from {
+			rates[code] = 1
+		} else {
+			symbols = append(symbols, code)
+		}
+	}
+	date := time.Now().Format("2006-01-02")
+	if len(symbols) == 0 {
+		return rates, date, nil
+	}
+
+	q := url.Values{"from": {from}, "to": {strings.Join(symbols, ",")}}
+	var resp struct {
+		Date  string             `json:"date"`
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := fetchJSON(ctx, t.client, t.baseURL+"/latest?"+q.Encode(), &resp); err != nil {
+		return nil, "", err
+	}
+	for code, rate := range resp.Rates {
+		rates[code] = rate
+	}
+	if resp.Date != "" {
+		date = resp.Date
+	}
+	return rates, date, nil
+}
+
+// openExchangeRates returns the rate from one unit of from to each of to. The
+// API quotes everything against USD, so other bases are computed as cross
+// rates.
+func (t *CurrencyTool) openExchangeRates(
+	ctx context.Context,
+	from string,
+	to []string,
+) (map[string]float64, string, error) {
+	q := url.Values{"app_id": {t.apiKey}, "symbols": {strings.Join(append([]string{from}, to...), ",")}}
+	var resp struct {
+		Timestamp int64              `json:"timestamp"`
+		Rates     map[string]float64 `json:"rates"`
+	}
+	if err := fetchJSON(ctx, t.client, t.baseURL+"/api/latest.json?"+q.Encode(), &resp); err != nil {
+		return nil, "", err
+	}
+	if resp.Rates == nil {
+		resp.Rates = map[string]float64{}
+	}
+	resp.Rates["USD"] = 1
+	base, ok := resp.Rates[from]
+	if !ok || base == 0 {
+		return nil, "", fmt.Errorf("no rate available for %s", from)
+	}
+	rates := make(map[string]float64, len(to))
+	for _, code := range to {
+		if rate, ok := resp.Rates[code]; ok {
+			rates[code] = rate / base
+		}
+	}
+	return rates, time.Unix(resp.Timestamp, 0).UTC().Format("2006-01-02 15:04 UTC"), nil
+}
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// formatAmount keeps four significant decimals for small values and two
+// decimals otherwise.
+func formatAmount(v float64) string {
+	if v != 0 && v < 1 && v > -1 {
+		return strconv.FormatFloat(v, 'g', 4, 64)
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestCurrencyTool_Frankfurter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/latest" || r.URL.Query().Get("from") != "USD" || r.URL.Query().Get("to") != "EUR,JPY,XXX" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"amount":1,"base":"USD","date":"2025-03-14","rates":{"EUR":0.92,"JPY":148.5}}`))
+	}))
+	defer srv.Close()
+
+	tool := NewCurrencyTool(config.CurrencyToolConfig{BaseURL: srv.URL})
+	result := tool.Execute(context.Background(), map[string]any{
+		"amount": 10.0,
+		"from":   "usd",
+		"to":     []any{"EUR", "JPY", "XXX"},
+	})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	for _, want := range []string{"rates from 2025-03-14", "- 9.20 EUR", "- 1485.00 JPY", "No rate available for XXX"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("result missing %q:\n%s", want, result.ForLLM)
+		}
+	}
+}
+
+func TestCurrencyTool_OpenExchangeRatesCrossRate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("app_id") != "key" {
+			t.Errorf("app_id = %q, want key", r.URL.Query().Get("app_id"))
+		}
+		w.Write([]byte(`{"timestamp":1741953600,"base":"USD","rates":{"EUR":0.8,"GBP":0.6}}`))
+	}))
+	defer srv.Close()
+
+	tool := NewCurrencyTool(config.CurrencyToolConfig{APIKey: "key", BaseURL: srv.URL})
+	result := tool.Execute(context.Background(), map[string]any{"from": "EUR", "to": []any{"GBP"}})
+	if result.IsError || !strings.Contains(result.ForLLM, "- 0.75 GBP") {
+		t.Fatalf("result = %q, want EUR→GBP cross rate of 0.75", result.ForLLM)
+	}
+}
+
+func TestCurrencyTool_InvalidCode(t *testing.T) {
+	tool := NewCurrencyTool(config.CurrencyToolConfig{})
+	result := tool.Execute(context.Background(), map[string]any{"from": "dollars", "to": []any{"EUR"}})
+	if !result.IsError {
+		t.Fatalf("expected an error for an invalid code, got %q", result.ForLLM)
+	}
+}
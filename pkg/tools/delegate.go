@@ -0,0 +1,305 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// delegateSystemPrompt starts the system prompt of every sub-agent.
+const delegateSystemPrompt = `You are a sub-agent working on one part of a larger task.
+Complete the given task independently and report only the result, clearly and concisely.
+The agent that delegated the task reads your answer and replies to the user.`
+
+// undelegatedTools may not be handed to a sub-agent: they run work outside
+// the delegate call, beyond its depth and concurrency limits.
+var undelegatedTools = []string{"spawn", "spawn_status", "subagent"}
+
+// DelegateOptions configures a DelegateTool.
+type DelegateOptions struct {
+	Provider providers.LLMProvider
+	Model    string
+	// Tools holds the tools sub-agents may be given, by name.
+	Tools *ToolRegistry
+	// ResolveModel returns the provider and model ID of a model a task asks
+	// for; nil allows only Model.
+	ResolveModel func(name string) (providers.LLMProvider, string, error)
+	LLMOptions   map[string]any
+
+	MaxDepth       int // levels of sub-agents below the agent; default 1
+	MaxConcurrency int // sub-agents of one call running at once; default 3
+	MaxTasks       int // tasks in one call; default 8
+	MaxIterations  int // tool loop iterations of each sub-agent; default 10
+}
+
+// DelegateTool hands sub-tasks to sub-agents that run in parallel, each
+// with its own persona, model, and subset of the agent's tools, and returns
+// their results together.
+type DelegateTool struct {
+	opts  DelegateOptions
+	depth int
+}
+
+func NewDelegateTool(opts DelegateOptions) *DelegateTool {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = 1
+	}
+	if opts.MaxConcurrency <= 0 {
+		opts.MaxConcurrency = 3
+	}
+	if opts.MaxTasks <= 0 {
+		opts.MaxTasks = 8
+	}
+	if opts.MaxIterations <= 0 {
+		opts.MaxIterations = 10
+	}
+	return &DelegateTool{opts: opts}
+}
+
+func (t *DelegateTool) Name() string {
+	return "delegate"
+}
+
+func (t *DelegateTool) Description() string {
+	return fmt.Sprintf("Hand independent sub-tasks to sub-agents that work on them in parallel, "+
+		"such as summarizing several pages at once, and get all their results back. "+
+		"Each task names the tools its sub-agent may use and can set a persona and a model. "+
+		"Up to %d tasks per call.", t.opts.MaxTasks)
+}
+
+func (t *DelegateTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"tasks": map[string]any{
+				"type":        "array",
+				"description": "The sub-tasks, each complete on its own",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"task": map[string]any{
+							"type":        "string",
+							"description": "What the sub-agent should do, with everything it needs to know",
+						},
+						"label": map[string]any{
+							"type":        "string",
+							"description": "Optional short label for the task",
+						},
+						"persona": map[string]any{
+							"type":        "string",
+							"description": "Optional role or instructions for the sub-agent, e.g. \"a terse technical editor\"",
+						},
+						"model": map[string]any{
+							"type":        "string",
+							"description": "Optional configured model to use instead of the agent's own",
+						},
+						"tools": map[string]any{
+							"type":        "array",
+							"items":       map[string]any{"type": "string"},
+							"description": "Names of the tools the sub-agent may use; none when omitted",
+						},
+					},
+					"required": []string{"task"},
+				},
+			},
+		},
+		"required": []string{"tasks"},
+	}
+}
+
+// DelegateTask is one sub-task of a delegate call.
+type DelegateTask struct {
+	Task    string
+	Label   string
+	Persona string
+	Model   string
+	Tools   []string
+}
+
+// delegateRun is a sub-task ready to run and, once run, its outcome.
+type delegateRun struct {
+	task     DelegateTask
+	provider providers.LLMProvider
+	model    string
+	tools    *ToolRegistry
+
+	result *ToolLoopResult
+	err    error
+}
+
+func (t *DelegateTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	tasks, err := parseDelegateTasks(args["tasks"])
+	if err != nil {
+		return ErrorResult(err.Error()).WithError(err)
+	}
+	if len(tasks) > t.opts.MaxTasks {
+		err := fmt.Errorf("at most %d tasks may be delegated at once, got %d", t.opts.MaxTasks, len(tasks))
+		return ErrorResult(err.Error()).WithError(err)
+	}
+
+	// Set up every sub-agent before running any, so a bad task fails the
+	// call without spending tokens on the others.
+	runs := make([]*delegateRun, len(tasks))
+	for i, task := range tasks {
+		run, err := t.prepare(task)
+		if err != nil {
+			err = fmt.Errorf("task %d: %w", i+1, err)
+			return ErrorResult(err.Error()).WithError(err)
+		}
+		runs[i] = run
+	}
+
+	channel := ToolChannel(ctx)
+	if channel == "" {
+		channel = "cli"
+	}
+	chatID := ToolChatID(ctx)
+	if chatID == "" {
+		chatID = "direct"
+	}
+
+	sem := make(chan struct{}, t.opts.MaxConcurrency)
+	var wg sync.WaitGroup
+	for _, run := range runs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				run.err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+			run.result, run.err = t.run(ctx, run, channel, chatID)
+		}()
+	}
+	wg.Wait()
+
+	return delegateResult(runs)
+}
+
+// prepare checks task and gives it its provider, model, and tools.
+func (t *DelegateTool) prepare(task DelegateTask) (*delegateRun, error) {
+	run := &delegateRun{task: task, provider: t.opts.Provider, model: t.opts.Model}
+	if task.Model != "" && task.Model != t.opts.Model {
+		if t.opts.ResolveModel == nil {
+			return nil, fmt.Errorf("model %q cannot be chosen here", task.Model)
+		}
+		provider, model, err := t.opts.ResolveModel(task.Model)
+		if err != nil {
+			return nil, err
+		}
+		run.provider, run.model = provider, model
+	}
+
+	run.tools = NewToolRegistry()
+	if t.opts.Tools != nil {
+		t.opts.Tools.mu.RLock()
+		run.tools.timeout, run.tools.timeouts = t.opts.Tools.timeout, t.opts.Tools.timeouts
+		t.opts.Tools.mu.RUnlock()
+	}
+	for _, name := range task.Tools {
+		switch {
+		case name == t.Name():
+			if t.depth+1 >= t.opts.MaxDepth {
+				return nil, fmt.Errorf("sub-agents may not delegate further (max depth %d)", t.opts.MaxDepth)
+			}
+		case slices.Contains(undelegatedTools, name):
+			return nil, fmt.Errorf("tool %s cannot be given to a sub-agent", name)
+		default:
+			if t.opts.Tools == nil {
+				return nil, fmt.Errorf("unknown tool %s", name)
+			}
+			tool, ok := t.opts.Tools.Get(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown tool %s", name)
+			}
+			run.tools.Register(tool)
+		}
+	}
+	// A sub-agent that may delegate chooses from its own tools only.
+	if slices.Contains(task.Tools, t.Name()) {
+		child := &DelegateTool{opts: t.opts, depth: t.depth + 1}
+		child.opts.Tools = run.tools
+		run.tools.Register(child)
+	}
+	return run, nil
+}
+
+func (t *DelegateTool) run(ctx context.Context, run *delegateRun, channel, chatID string) (*ToolLoopResult, error) {
+	system := delegateSystemPrompt
+	if run.task.Persona != "" {
+		system += "\n\n## Your role\n\n" + run.task.Persona
+	}
+	messages := []providers.Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: run.task.Task},
+	}
+	return RunToolLoop(ctx, ToolLoopConfig{
+		Provider:      run.provider,
+		Model:         run.model,
+		Tools:         run.tools,
+		MaxIterations: t.opts.MaxIterations,
+		LLMOptions:    t.opts.LLMOptions,
+	}, messages, channel, chatID)
+}
+
+// delegateResult reports the outcome of every run in task order. It is an
+// error only when every run failed.
+func delegateResult(runs []*delegateRun) *ToolResult {
+	var b strings.Builder
+	failed := 0
+	for i, run := range runs {
+		label := run.task.Label
+		if label == "" {
+			label = "(unnamed)"
+		}
+		if run.err != nil {
+			failed++
+			fmt.Fprintf(&b, "## Task %d: %s — failed\n%v\n\n", i+1, label, run.err)
+			continue
+		}
+		fmt.Fprintf(&b, "## Task %d: %s (iterations: %d)\n%s\n\n", i+1, label, run.result.Iterations, run.result.Content)
+	}
+	summary := fmt.Sprintf("Delegated %d tasks, %d failed.\n\n", len(runs), failed)
+	if failed == len(runs) {
+		return ErrorResult(summary + strings.TrimSpace(b.String()))
+	}
+	return SilentResult(summary + strings.TrimSpace(b.String()))
+}
+
+func parseDelegateTasks(raw any) ([]DelegateTask, error) {
+	items, ok := raw.([]any)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("tasks is required")
+	}
+	tasks := make([]DelegateTask, 0, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("task %d must be an object", i+1)
+		}
+		task := DelegateTask{}
+		task.Task, _ = m["task"].(string)
+		if strings.TrimSpace(task.Task) == "" {
+			return nil, fmt.Errorf("task %d has no task", i+1)
+		}
+		task.Label, _ = m["label"].(string)
+		task.Persona, _ = m["persona"].(string)
+		task.Model, _ = m["model"].(string)
+		if names, ok := m["tools"].([]any); ok {
+			for _, n := range names {
+				if name, ok := n.(string); ok && name != "" {
+					task.Tools = append(task.Tools, name)
+				}
+			}
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
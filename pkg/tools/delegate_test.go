@@ -0,0 +1,173 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// delegateProvider answers with the model, system prompt, and task it got,
+// and records how many calls ran at once.
+type delegateProvider struct {
+	MockLLMProvider
+	running, peak atomic.Int32
+	mu            sync.Mutex
+	tools         [][]string
+}
+
+func (p *delegateProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	n := p.running.Add(1)
+	defer p.running.Add(-1)
+	for {
+		peak := p.peak.Load()
+		if n <= peak || p.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	var names []string
+	for _, td := range tools {
+		names = append(names, td.Function.Name)
+	}
+	p.mu.Lock()
+	p.tools = append(p.tools, names)
+	p.mu.Unlock()
+
+	task := messages[len(messages)-1].Content
+	if task == "fail" {
+		return nil, errors.New("provider down")
+	}
+	return &providers.LLMResponse{Content: model + "|" + messages[0].Content + "|" + task}, nil
+}
+
+func delegateArgs(tasks ...map[string]any) map[string]any {
+	items := make([]any, len(tasks))
+	for i, task := range tasks {
+		items[i] = task
+	}
+	return map[string]any{"tasks": items}
+}
+
+func TestDelegateTool_RunsTasksInParallelWithinLimit(t *testing.T) {
+	p := &delegateProvider{}
+	parent := NewToolRegistry()
+	parent.Register(&mockRegistryTool{name: "web_fetch"})
+	parent.Register(&mockRegistryTool{name: "exec"})
+	tool := NewDelegateTool(DelegateOptions{
+		Provider:       p,
+		Model:          "main",
+		Tools:          parent,
+		MaxConcurrency: 2,
+		ResolveModel: func(name string) (providers.LLMProvider, string, error) {
+			return p, name + "-id", nil
+		},
+	})
+
+	result := tool.Execute(context.Background(), delegateArgs(
+		map[string]any{"task": "page 1", "label": "one", "tools": []any{"web_fetch"}},
+		map[string]any{"task": "page 2", "persona": "a terse editor"},
+		map[string]any{"task": "page 3", "model": "cheap"},
+		map[string]any{"task": "fail"},
+	))
+	if result.IsError || !result.Silent {
+		t.Fatalf("result = %+v", result)
+	}
+	for _, want := range []string{
+		"Delegated 4 tasks, 1 failed.",
+		"## Task 1: one (iterations: 1)\nmain|",
+		"page 1",
+		"## Your role\n\na terse editor|page 2",
+		"cheap-id|",
+		"## Task 4: (unnamed) — failed",
+	} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("result lacks %q:\n%s", want, result.ForLLM)
+		}
+	}
+	if strings.Index(result.ForLLM, "page 1") > strings.Index(result.ForLLM, "page 2") {
+		t.Error("results out of task order")
+	}
+	if peak := p.peak.Load(); peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak)
+	}
+	for _, names := range p.tools {
+		if len(names) > 1 || len(names) == 1 && names[0] != "web_fetch" {
+			t.Errorf("sub-agent given tools %v", names)
+		}
+	}
+}
+
+func TestDelegateTool_AllFailedIsError(t *testing.T) {
+	tool := NewDelegateTool(DelegateOptions{Provider: &delegateProvider{}, Model: "main"})
+	result := tool.Execute(context.Background(), delegateArgs(map[string]any{"task": "fail"}))
+	if !result.IsError || !strings.Contains(result.ForLLM, "provider down") {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestDelegateTool_RejectsBadTasks(t *testing.T) {
+	parent := NewToolRegistry()
+	parent.Register(&mockRegistryTool{name: "spawn"})
+	tool := NewDelegateTool(DelegateOptions{Provider: &delegateProvider{}, Model: "main", Tools: parent, MaxTasks: 2})
+
+	tests := []struct {
+		name string
+		args map[string]any
+		want string
+	}{
+		{"no tasks", map[string]any{}, "tasks is required"},
+		{"empty task", delegateArgs(map[string]any{"task": " "}), "has no task"},
+		{"too many", delegateArgs(map[string]any{"task": "a"}, map[string]any{"task": "b"}, map[string]any{"task": "c"}),
+			"at most 2"},
+		{"unknown tool", delegateArgs(map[string]any{"task": "a", "tools": []any{"exec"}}), "unknown tool exec"},
+		{"async tool", delegateArgs(map[string]any{"task": "a", "tools": []any{"spawn"}}), "cannot be given"},
+		{"max depth", delegateArgs(map[string]any{"task": "a", "tools": []any{"delegate"}}), "max depth 1"},
+		{"fixed model", delegateArgs(map[string]any{"task": "a", "model": "other"}), "cannot be chosen"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tool.Execute(context.Background(), tt.args)
+			if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+				t.Errorf("result = %q, want error containing %q", result.ForLLM, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelegateTool_NestedDelegationStopsAtMaxDepth(t *testing.T) {
+	parent := NewToolRegistry()
+	parent.Register(&mockRegistryTool{name: "web_fetch"})
+	tool := NewDelegateTool(DelegateOptions{Provider: &delegateProvider{}, Model: "main", Tools: parent, MaxDepth: 2})
+
+	run, err := tool.prepare(DelegateTask{Task: "a", Tools: []string{"delegate", "web_fetch"}})
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	got, ok := run.tools.Get("delegate")
+	if !ok {
+		t.Fatal("sub-agent lacks the delegate tool")
+	}
+	child := got.(*DelegateTool)
+	if child.depth != 1 || child.opts.Tools != run.tools {
+		t.Errorf("child depth %d, shares parent tools: %v", child.depth, child.opts.Tools == parent)
+	}
+	if _, err := child.prepare(DelegateTask{Task: "b", Tools: []string{"web_fetch"}}); err != nil {
+		t.Errorf("child cannot hand on its own tools: %v", err)
+	}
+	if _, err := child.prepare(DelegateTask{Task: "b", Tools: []string{"delegate"}}); err == nil {
+		t.Error("delegation went past max depth")
+	}
+}
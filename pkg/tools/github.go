@@ -0,0 +1,436 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	githubTimeout        = 30 * time.Second
+	githubDefaultBaseURL = "https://api.github.com"
+	githubDefaultLimit   = 10
+	githubMaxLimit       = 30
+)
+
+// GitHubTool reads issues and pull requests, comments on them, searches code,
+// and files issues in the GitHub repositories it is configured for.
+type GitHubTool struct {
+	cfg config.GitHubToolConfig
+	gh  *githubClient
+}
+
+func NewGitHubTool(cfg config.GitHubToolConfig) *GitHubTool {
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = githubDefaultBaseURL
+	}
+	gh := &githubClient{
+		baseURL: baseURL,
+		token:   cfg.Token,
+		client:  &http.Client{Timeout: githubTimeout},
+	}
+	if cfg.Token == "" && cfg.AppID != 0 {
+		gh.app = &githubAppAuth{
+			appID:          cfg.AppID,
+			installationID: cfg.InstallationID,
+			keyPath:        cfg.PrivateKeyPath,
+		}
+	}
+	return &GitHubTool{cfg: cfg, gh: gh}
+}
+
+func (t *GitHubTool) Name() string {
+	return "github"
+}
+
+func (t *GitHubTool) Description() string {
+	return fmt.Sprintf("Work with the team's GitHub repositories (%s). "+
+		"Use 'get' to read an issue or pull request with its latest comments, 'comment' to reply on one, "+
+		"'search_code' to find code, and 'create_issue' to file an issue: summarize the conversation "+
+		"into a clear title and a body with the problem, steps to reproduce, and expected behavior.",
+		strings.Join(t.cfg.Repos, ", "))
+}
+
+func (t *GitHubTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"get", "comment", "search_code", "create_issue"},
+				"description": "Operation to perform",
+			},
+			"repo": map[string]any{
+				"type":        "string",
+				"description": "Repository as owner/name; defaults to the first configured one (search_code: all of them)",
+			},
+			"number": map[string]any{
+				"type":        "integer",
+				"description": "Issue or pull request number (get, comment)",
+			},
+			"body": map[string]any{
+				"type":        "string",
+				"description": "Markdown text of the comment or issue (comment, create_issue)",
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": "Issue title (create_issue)",
+			},
+			"labels": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Labels for the new issue (create_issue)",
+			},
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Code search terms, e.g. a function name (search_code)",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum search results (default 10, max 30)",
+				"minimum":     1.0,
+				"maximum":     float64(githubMaxLimit),
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *GitHubTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	if t.cfg.Token == "" && t.gh.app == nil {
+		return ErrorResult("github needs a token or a GitHub App (app_id, installation_id, private_key_path)")
+	}
+	requested, _ := args["repo"].(string)
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "get":
+		repo, err := t.repo(requested)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		number, ok := githubNumber(args)
+		if !ok {
+			return ErrorResult("number is required to get an issue or pull request")
+		}
+		text, err := t.get(ctx, repo, number)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to get %s#%d: %v", repo, number, err)).WithError(err)
+		}
+		return SilentResult(text)
+
+	case "comment":
+		repo, err := t.repo(requested)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		number, ok := githubNumber(args)
+		if !ok {
+			return ErrorResult("number is required to comment")
+		}
+		body, _ := args["body"].(string)
+		if strings.TrimSpace(body) == "" {
+			return ErrorResult("body is required to comment")
+		}
+		var created struct {
+			HTMLURL string `json:"html_url"`
+		}
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number)
+		if err := t.gh.do(ctx, http.MethodPost, path, map[string]any{"body": body}, &created); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to comment on %s#%d: %v", repo, number, err)).WithError(err)
+		}
+		return NewToolResult(fmt.Sprintf("Commented on %s#%d\n%s", repo, number, created.HTMLURL))
+
+	case "search_code":
+		query, _ := args["query"].(string)
+		if strings.TrimSpace(query) == "" {
+			return ErrorResult("query is required to search code")
+		}
+		repos := t.cfg.Repos
+		if requested != "" {
+			repo, err := t.repo(requested)
+			if err != nil {
+				return ErrorResult(err.Error())
+			}
+			repos = []string{repo}
+		}
+		if len(repos) == 0 {
+			return ErrorResult("no GitHub repositories are configured")
+		}
+		limit := githubDefaultLimit
+		if n, ok := args["limit"].(float64); ok && n >= 1 {
+			limit = min(int(n), githubMaxLimit)
+		}
+		text, err := t.searchCode(ctx, query, repos, limit)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to search code: %v", err)).WithError(err)
+		}
+		return SilentResult(text)
+
+	case "create_issue":
+		repo, err := t.repo(requested)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		title, _ := args["title"].(string)
+		if strings.TrimSpace(title) == "" {
+			return ErrorResult("title is required to create an issue")
+		}
+		req := map[string]any{"title": title}
+		if body, _ := args["body"].(string); body != "" {
+			req["body"] = body
+		}
+		if raw, ok := args["labels"].([]any); ok && len(raw) > 0 {
+			var labels []string
+			for _, l := range raw {
+				if s, ok := l.(string); ok && s != "" {
+					labels = append(labels, s)
+				}
+			}
+			req["labels"] = labels
+		}
+		var created githubIssue
+		if err := t.gh.do(ctx, http.MethodPost, "/repos/"+repo+"/issues", req, &created); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to create issue in %s: %v", repo, err)).WithError(err)
+		}
+		return NewToolResult(fmt.Sprintf("Created %s#%d: %s\n%s", repo, created.Number, created.Title, created.HTMLURL))
+
+	default:
+		return ErrorResult(fmt.Sprintf(
+			"unknown action %q (expected get, comment, search_code, or create_issue)", action))
+	}
+}
+
+// repo returns the configured repository matching requested, or the first
+// one when requested is empty. Other repositories are refused.
+func (t *GitHubTool) repo(requested string) (string, error) {
+	if len(t.cfg.Repos) == 0 {
+		return "", fmt.Errorf("no GitHub repositories are configured")
+	}
+	requested = strings.Trim(strings.TrimSpace(requested), "/")
+	if requested == "" {
+		return t.cfg.Repos[0], nil
+	}
+	for _, repo := range t.cfg.Repos {
+		if strings.EqualFold(repo, requested) {
+			return repo, nil
+		}
+	}
+	return "", fmt.Errorf("repository %s is not configured (allowed: %s)", requested, strings.Join(t.cfg.Repos, ", "))
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+type githubIssue struct {
+	Number    int         `json:"number"`
+	Title     string      `json:"title"`
+	State     string      `json:"state"`
+	Body      string      `json:"body"`
+	HTMLURL   string      `json:"html_url"`
+	UpdatedAt string      `json:"updated_at"`
+	User      githubUser  `json:"user"`
+	Assignee  *githubUser `json:"assignee"`
+	Labels    []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+type githubPull struct {
+	Merged bool `json:"merged"`
+	Draft  bool `json:"draft"`
+	Head   struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	Additions    int `json:"additions"`
+	Deletions    int `json:"deletions"`
+	ChangedFiles int `json:"changed_files"`
+}
+
+// get describes issue or pull request number of repo with its latest
+// comments.
+func (t *GitHubTool) get(ctx context.Context, repo string, number int) (string, error) {
+	var it githubIssue
+	if err := t.gh.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d", repo, number), nil, &it); err != nil {
+		return "", err
+	}
+	issue := &Issue{
+		Key:         fmt.Sprintf("%s#%d", repo, it.Number),
+		Title:       it.Title,
+		Status:      it.State,
+		URL:         it.HTMLURL,
+		Description: it.Body,
+		Updated:     it.UpdatedAt,
+	}
+	if it.Assignee != nil {
+		issue.Assignee = it.Assignee.Login
+	}
+
+	var details []string
+	if it.User.Login != "" {
+		details = append(details, "Author: "+it.User.Login)
+	}
+	if len(it.Labels) > 0 {
+		names := make([]string, len(it.Labels))
+		for i, l := range it.Labels {
+			names[i] = l.Name
+		}
+		details = append(details, "Labels: "+strings.Join(names, ", "))
+	}
+	if it.PullRequest != nil {
+		var pr githubPull
+		if err := t.gh.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", repo, number), nil, &pr); err != nil {
+			return "", err
+		}
+		switch {
+		case pr.Merged:
+			issue.Status = "merged"
+		case pr.Draft && it.State == "open":
+			issue.Status = "draft"
+		}
+		details = append(details, fmt.Sprintf("Pull request: %s → %s, %d files changed (+%d −%d)",
+			pr.Head.Ref, pr.Base.Ref, pr.ChangedFiles, pr.Additions, pr.Deletions))
+	}
+	if len(details) > 0 {
+		issue.Description = strings.TrimSpace(strings.Join(details, "\n") + "\n\n" + issue.Description)
+	}
+
+	// The first page holds the oldest comments; long threads keep only the
+	// latest of those.
+	var comments []struct {
+		User githubUser `json:"user"`
+		Body string     `json:"body"`
+	}
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100", repo, number)
+	if err := t.gh.do(ctx, http.MethodGet, path, nil, &comments); err != nil {
+		return "", err
+	}
+	if len(comments) > issuesMaxComments {
+		comments = comments[len(comments)-issuesMaxComments:]
+	}
+	for _, c := range comments {
+		issue.Comments = append(issue.Comments, IssueComment{Author: c.User.Login, Body: c.Body})
+	}
+	return formatIssue(issue), nil
+}
+
+// searchCode searches the code of repos and lists the matching files with a
+// fragment of each match.
+func (t *GitHubTool) searchCode(ctx context.Context, query string, repos []string, limit int) (string, error) {
+	q := query
+	for _, repo := range repos {
+		q += " repo:" + repo
+	}
+	var resp struct {
+		TotalCount int `json:"total_count"`
+		Items      []struct {
+			Path       string `json:"path"`
+			HTMLURL    string `json:"html_url"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+			TextMatches []struct {
+				Fragment string `json:"fragment"`
+			} `json:"text_matches"`
+		} `json:"items"`
+	}
+	path := fmt.Sprintf("/search/code?q=%s&per_page=%d", url.QueryEscape(q), limit)
+	if err := t.gh.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Items) == 0 {
+		return fmt.Sprintf("No code found for %q", query), nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Found %d file(s) for %q", resp.TotalCount, query)
+	if resp.TotalCount > len(resp.Items) {
+		fmt.Fprintf(&b, ", showing %d", len(resp.Items))
+	}
+	b.WriteString(":\n")
+	for _, item := range resp.Items {
+		fmt.Fprintf(&b, "- %s/%s %s\n", item.Repository.FullName, item.Path, item.HTMLURL)
+		for _, m := range item.TextMatches {
+			fragment := strings.TrimSpace(utils.Truncate(m.Fragment, 300))
+			fmt.Fprintf(&b, "  %s\n", strings.ReplaceAll(fragment, "\n", "\n  "))
+		}
+	}
+	return b.String(), nil
+}
+
+func githubNumber(args map[string]any) (int, bool) {
+	n, ok := args["number"].(float64)
+	if !ok || n < 1 {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// githubClient talks to the GitHub REST API with a personal access token or
+// as a GitHub App installation.
+type githubClient struct {
+	baseURL string
+	token   string
+	app     *githubAppAuth
+	client  *http.Client
+}
+
+func (c *githubClient) do(ctx context.Context, method, path string, body, out any) error {
+	token := c.token
+	if c.app != nil {
+		var err error
+		if token, err = c.app.installationToken(ctx, c); err != nil {
+			return err
+		}
+	}
+	return c.doAs(ctx, "Bearer "+token, method, path, body, out)
+}
+
+func (c *githubClient) doAs(ctx context.Context, auth, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Accept", "application/vnd.github.text-match+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("github returned %s: %s", resp.Status, utils.Truncate(strings.TrimSpace(string(data)), 300))
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// githubAppAuth mints installation access tokens for a GitHub App and keeps
+// each until shortly before it expires.
+type githubAppAuth struct {
+	appID          int64
+	installationID int64
+	keyPath        string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// installationToken returns a valid installation access token, minting a new
+// one through c when the cached token is about to expire.
+func (a *githubAppAuth) installationToken(ctx context.Context, c *githubClient) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && time.Until(a.expires) > time.Minute {
+		return a.token, nil
+	}
+
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	path := fmt.Sprintf("/app/installations/%d/access_tokens", a.installationID)
+	if err := c.doAs(ctx, "Bearer "+jwt, http.MethodPost, path, nil, &resp); err != nil {
+		return "", fmt.Errorf("github app token: %w", err)
+	}
+	a.token, a.expires = resp.Token, resp.ExpiresAt
+	return a.token, nil
+}
+
+// jwt signs the short-lived RS256 token that authenticates the app itself.
+func (a *githubAppAuth) jwt(now time.Time) (string, error) {
+	key, err := readRSAKey(a.keyPath)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		// Backdated to allow for clock drift, as GitHub recommends
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// readRSAKey reads a PEM private key in PKCS #1 form, as GitHub issues them,
+// or PKCS #8.
+func readRSAKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read github app key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("github app key %s is not PEM", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse github app key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("github app key %s is not an RSA key", path)
+	}
+	return key, nil
+}
@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newGitHubTestTool(srv *httptest.Server) *GitHubTool {
+	return NewGitHubTool(config.GitHubToolConfig{
+		Token:   "ghp_test",
+		BaseURL: srv.URL,
+		Repos:   []string{"acme/app", "acme/docs"},
+	})
+}
+
+func TestGitHubTool_GetPullRequestWithComments(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer ghp_test" {
+			t.Errorf("Authorization = %q", got)
+		}
+		switch r.URL.Path {
+		case "/repos/acme/app/issues/7":
+			w.Write([]byte(`{"number":7,"title":"Fix login","state":"closed","body":"Fixes #3",
+				"html_url":"https://github.com/acme/app/pull/7","user":{"login":"ann"},
+				"labels":[{"name":"bug"}],"pull_request":{}}`))
+		case "/repos/acme/app/pulls/7":
+			w.Write([]byte(`{"merged":true,"head":{"ref":"fix"},"base":{"ref":"main"},
+				"additions":5,"deletions":2,"changed_files":1}`))
+		case "/repos/acme/app/issues/7/comments":
+			w.Write([]byte(`[{"user":{"login":"bob"},"body":"LGTM"}]`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	result := newGitHubTestTool(srv).Execute(context.Background(), map[string]any{"action": "get", "number": 7.0})
+	if result.IsError {
+		t.Fatalf("get failed: %s", result.ForLLM)
+	}
+	for _, want := range []string{
+		"acme/app#7: Fix login", "Status: merged", "Author: ann", "Labels: bug",
+		"fix → main, 1 files changed (+5 −2)", "Fixes #3", "- bob: LGTM",
+	} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("result lacks %q:\n%s", want, result.ForLLM)
+		}
+	}
+}
+
+func TestGitHubTool_CommentAndCreateIssue(t *testing.T) {
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s", r.Method)
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		switch r.URL.Path {
+		case "/repos/acme/docs/issues/3/comments":
+			w.Write([]byte(`{"html_url":"https://github.com/acme/docs/issues/3#issuecomment-1"}`))
+		case "/repos/acme/app/issues":
+			w.Write([]byte(`{"number":12,"title":"Crash on save","html_url":"https://github.com/acme/app/issues/12"}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	tool := newGitHubTestTool(srv)
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"action": "comment", "repo": "ACME/docs", "number": 3.0, "body": "Reproduced on 1.2",
+	})
+	if result.IsError || !strings.Contains(result.ForLLM, "issuecomment-1") {
+		t.Errorf("comment = %q", result.ForLLM)
+	}
+	result = tool.Execute(context.Background(), map[string]any{
+		"action": "create_issue", "title": "Crash on save", "body": "Summary", "labels": []any{"bug"},
+	})
+	if result.IsError || !strings.Contains(result.ForLLM, "Created acme/app#12") {
+		t.Errorf("create_issue = %q", result.ForLLM)
+	}
+	if len(bodies) != 2 || bodies[0]["body"] != "Reproduced on 1.2" || bodies[1]["labels"].([]any)[0] != "bug" {
+		t.Errorf("bodies = %v", bodies)
+	}
+}
+
+func TestGitHubTool_SearchCodeStaysInConfiguredRepos(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("q")
+		w.Write([]byte(`{"total_count":1,"items":[{"path":"auth/login.go","html_url":"https://github.com/x",
+			"repository":{"full_name":"acme/app"},"text_matches":[{"fragment":"func Login()"}]}]}`))
+	}))
+	defer srv.Close()
+	tool := newGitHubTestTool(srv)
+
+	result := tool.Execute(context.Background(), map[string]any{"action": "search_code", "query": "Login"})
+	if result.IsError || !strings.Contains(result.ForLLM, "acme/app/auth/login.go") ||
+		!strings.Contains(result.ForLLM, "func Login()") {
+		t.Errorf("search_code = %q", result.ForLLM)
+	}
+	if query != "Login repo:acme/app repo:acme/docs" {
+		t.Errorf("q = %q", query)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"action": "search_code", "query": "x", "repo": "evil/repo"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not configured") {
+		t.Errorf("search outside the repos = %q", result.ForLLM)
+	}
+}
+
+func TestGitHubTool_NeedsCredentials(t *testing.T) {
+	tool := NewGitHubTool(config.GitHubToolConfig{Repos: []string{"acme/app"}})
+	result := tool.Execute(context.Background(), map[string]any{"action": "get", "number": 1.0})
+	if !result.IsError || !strings.Contains(result.ForLLM, "token or a GitHub App") {
+		t.Errorf("result = %q", result.ForLLM)
+	}
+}
+
+func TestGitHubTool_AppInstallationToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "app.pem")
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, pemData, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	minted := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if r.URL.Path == "/app/installations/42/access_tokens" {
+			minted++
+			parts := strings.Split(auth, ".")
+			if len(parts) != 3 {
+				t.Fatalf("not a JWT: %q", auth)
+			}
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+				t.Errorf("JWT signature: %v", err)
+			}
+			claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			if !strings.Contains(string(claims), `"iss":7`) {
+				t.Errorf("claims = %s", claims)
+			}
+			json.NewEncoder(w).Encode(map[string]any{"token": "ghs_inst", "expires_at": time.Now().Add(time.Hour)})
+			return
+		}
+		if auth != "ghs_inst" {
+			t.Errorf("API call authenticated with %q", auth)
+		}
+		w.Write([]byte(`{"html_url":"https://github.com/acme/app/issues/1#c"}`))
+	}))
+	defer srv.Close()
+
+	tool := NewGitHubTool(config.GitHubToolConfig{
+		AppID:          7,
+		InstallationID: 42,
+		PrivateKeyPath: keyPath,
+		BaseURL:        srv.URL,
+		Repos:          []string{"acme/app"},
+	})
+	for range 2 {
+		result := tool.Execute(context.Background(), map[string]any{"action": "comment", "number": 1.0, "body": "hi"})
+		if result.IsError {
+			t.Fatalf("comment failed: %s", result.ForLLM)
+		}
+	}
+	if minted != 1 {
+		t.Errorf("minted %d installation tokens, want 1", minted)
+	}
+}
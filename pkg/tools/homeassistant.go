@@ -0,0 +1,278 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	homeAssistantTimeout   = 15 * time.Second
+	homeAssistantMaxList   = 50
+	homeAssistantMaxResult = 8000
+)
+
+// HomeAssistantTool reads entity states and calls services through the Home
+// Assistant REST API. Service calls go through the approval queue unless
+// approval is disabled in config.
+type HomeAssistantTool struct {
+	cfg       config.HomeAssistantToolConfig
+	baseURL   string
+	client    *http.Client
+	approvals *ApprovalQueue
+}
+
+func NewHomeAssistantTool(cfg config.HomeAssistantToolConfig, approvals *ApprovalQueue) *HomeAssistantTool {
+	return &HomeAssistantTool{
+		cfg:       cfg,
+		baseURL:   strings.TrimRight(cfg.URL, "/"),
+		client:    &http.Client{Timeout: homeAssistantTimeout},
+		approvals: approvals,
+	}
+}
+
+func (t *HomeAssistantTool) Name() string {
+	return "home_assistant"
+}
+
+func (t *HomeAssistantTool) Description() string {
+	desc := "Read sensors and control devices in Home Assistant. Use 'list' to find entity IDs " +
+		"(filter by domain such as light, switch, sensor, climate), 'get' for one entity's state " +
+		"and attributes, and 'call_service' to act, e.g. domain=light service=turn_on entity_id=light.kitchen."
+	if t.cfg.RequireApproval {
+		desc += " Service calls only run after the user approves them."
+	}
+	return desc
+}
+
+func (t *HomeAssistantTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "get", "call_service"},
+				"description": "Operation to perform",
+			},
+			"domain": map[string]any{
+				"type":        "string",
+				"description": "Entity domain to filter by (list) or service domain (call_service)",
+			},
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Text to match against entity IDs and names (list)",
+			},
+			"entity_id": map[string]any{
+				"type":        "string",
+				"description": "Entity ID such as sensor.living_room_temperature (get, call_service)",
+			},
+			"service": map[string]any{
+				"type":        "string",
+				"description": "Service to call such as turn_on, turn_off, toggle, set_temperature (call_service)",
+			},
+			"data": map[string]any{
+				"type":        "object",
+				"description": "Extra service data such as {\"brightness_pct\": 50} (call_service)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+type haState struct {
+	EntityID    string         `json:"entity_id"`
+	State       string         `json:"state"`
+	Attributes  map[string]any `json:"attributes"`
+	LastChanged string         `json:"last_changed"`
+}
+
+func (t *HomeAssistantTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	if t.baseURL == "" || t.cfg.Token == "" {
+		return ErrorResult("home assistant needs url and token in config")
+	}
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "list":
+		domain, _ := args["domain"].(string)
+		query, _ := args["query"].(string)
+		return t.list(ctx, domain, query)
+	case "get":
+		entityID, _ := args["entity_id"].(string)
+		if entityID == "" {
+			return ErrorResult("entity_id is required")
+		}
+		return t.get(ctx, entityID)
+	case "call_service":
+		return t.callService(ctx, args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q (expected list, get, or call_service)", action))
+	}
+}
+
+func (t *HomeAssistantTool) list(ctx context.Context, domain, query string) *ToolResult {
+	var states []haState
+	if err := t.do(ctx, http.MethodGet, "/api/states", nil, &states); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to list entities: %v", err)).WithError(err)
+	}
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	query = strings.ToLower(query)
+
+	var matched []haState
+	for _, st := range states {
+		if domain != "" && !strings.HasPrefix(st.EntityID, domain+".") {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(st.EntityID+" "+friendlyName(st)), query) {
+			continue
+		}
+		matched = append(matched, st)
+	}
+	if len(matched) == 0 {
+		return SilentResult("No matching entities")
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].EntityID < matched[j].EntityID })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d matching entities", len(matched))
+	if len(matched) > homeAssistantMaxList {
+		fmt.Fprintf(&b, " (showing %d; narrow with domain or query)", homeAssistantMaxList)
+		matched = matched[:homeAssistantMaxList]
+	}
+	b.WriteString(":\n")
+	for _, st := range matched {
+		fmt.Fprintf(&b, "- %s: %s", st.EntityID, stateWithUnit(st))
+		if name := friendlyName(st); name != "" {
+			fmt.Fprintf(&b, " (%s)", name)
+		}
+		b.WriteString("\n")
+	}
+	return SilentResult(b.String())
+}
+
+func (t *HomeAssistantTool) get(ctx context.Context, entityID string) *ToolResult {
+	var st haState
+	if err := t.do(ctx, http.MethodGet, "/api/states/"+url.PathEscape(entityID), nil, &st); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to get %s: %v", entityID, err)).WithError(err)
+	}
+	attrs, _ := json.MarshalIndent(st.Attributes, "", "  ")
+	return SilentResult(fmt.Sprintf("%s: %s\nLast changed: %s\nAttributes:\n%s",
+		st.EntityID, stateWithUnit(st), st.LastChanged, utils.Truncate(string(attrs), homeAssistantMaxResult)))
+}
+
+func (t *HomeAssistantTool) callService(ctx context.Context, args map[string]any) *ToolResult {
+	domain, _ := args["domain"].(string)
+	service, _ := args["service"].(string)
+	entityID, _ := args["entity_id"].(string)
+	if domain == "" || service == "" {
+		return ErrorResult("domain and service are required")
+	}
+	if strings.ContainsAny(domain+service, "/?#") {
+		return ErrorResult("invalid domain or service")
+	}
+	if len(t.cfg.AllowedDomains) > 0 && !slices.Contains(t.cfg.AllowedDomains, domain) {
+		return ErrorResult(fmt.Sprintf("controlling %q is not allowed; allowed domains: %s",
+			domain, strings.Join(t.cfg.AllowedDomains, ", ")))
+	}
+
+	data := map[string]any{}
+	if extra, ok := args["data"].(map[string]any); ok {
+		for k, v := range extra {
+			data[k] = v
+		}
+	}
+	if entityID != "" {
+		data["entity_id"] = entityID
+	}
+
+	summary := fmt.Sprintf("Home Assistant %s.%s", domain, service)
+	if entityID != "" {
+		summary += " on " + entityID
+	}
+	if extra, ok := args["data"].(map[string]any); ok && len(extra) > 0 {
+		encoded, _ := json.Marshal(extra)
+		summary += " with " + string(encoded)
+	}
+
+	run := func(ctx context.Context) *ToolResult {
+		var changed []haState
+		path := "/api/services/" + url.PathEscape(domain) + "/" + url.PathEscape(service)
+		if err := t.do(ctx, http.MethodPost, path, data, &changed); err != nil {
+			return ErrorResult(fmt.Sprintf("service call failed: %v", err)).WithError(err)
+		}
+		if len(changed) == 0 {
+			return NewToolResult(fmt.Sprintf("Called %s.%s", domain, service))
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "Called %s.%s. New states:\n", domain, service)
+		for _, st := range changed {
+			fmt.Fprintf(&b, "- %s: %s\n", st.EntityID, stateWithUnit(st))
+		}
+		return NewToolResult(b.String())
+	}
+
+	if t.cfg.RequireApproval {
+		if t.approvals == nil {
+			return ErrorResult("service calls need approval, but approvals are unavailable here")
+		}
+		return t.approvals.Hold(ctx, summary, run)
+	}
+	return run(ctx)
+}
+
+func (t *HomeAssistantTool) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, t.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.cfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("not found")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("home assistant returned %s: %s", resp.Status, utils.Truncate(strings.TrimSpace(string(data)), 300))
+	}
+	return json.Unmarshal(data, out)
+}
+
+func friendlyName(st haState) string {
+	name, _ := st.Attributes["friendly_name"].(string)
+	return name
+}
+
+func stateWithUnit(st haState) string {
+	if unit, ok := st.Attributes["unit_of_measurement"].(string); ok && unit != "" {
+		return st.State + " " + unit
+	}
+	return st.State
+}
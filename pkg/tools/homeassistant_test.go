@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newHomeAssistantServer(t *testing.T, calls *atomic.Int32, body *map[string]any) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ha-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/states":
+			w.Write([]byte(`[
+			  {"entity_id":"sensor.office_temp","state":"21.5","attributes":{"friendly_name":"Office Temperature","unit_of_measurement":"°C"}},
+			  {"entity_id":"light.kitchen","state":"off","attributes":{"friendly_name":"Kitchen"}},
+			  {"entity_id":"light.office","state":"on","attributes":{"friendly_name":"Office Lamp"}}]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/services/light/turn_on":
+			calls.Add(1)
+			json.NewDecoder(r.Body).Decode(body)
+			w.Write([]byte(`[{"entity_id":"light.kitchen","state":"on","attributes":{}}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHomeAssistantTool_List(t *testing.T) {
+	var calls atomic.Int32
+	srv := newHomeAssistantServer(t, &calls, nil)
+	tool := NewHomeAssistantTool(config.HomeAssistantToolConfig{URL: srv.URL + "/", Token: "ha-token"}, nil)
+
+	result := tool.Execute(context.Background(), map[string]any{"action": "list", "query": "office"})
+	if result.IsError {
+		t.Fatal(result.ForLLM)
+	}
+	want := "2 matching entities:\n- light.office: on (Office Lamp)\n- sensor.office_temp: 21.5 °C (Office Temperature)\n"
+	if result.ForLLM != want {
+		t.Errorf("list = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"action": "list", "domain": "light"})
+	if strings.Contains(result.ForLLM, "sensor.") {
+		t.Errorf("domain filter ignored: %q", result.ForLLM)
+	}
+}
+
+func TestHomeAssistantTool_ServiceCallWaitsForApproval(t *testing.T) {
+	var calls atomic.Int32
+	var body map[string]any
+	srv := newHomeAssistantServer(t, &calls, &body)
+
+	approvals := NewApprovalQueue()
+	var prompt string
+	approvals.SetSendCallback(func(channel, chatID, content string) error {
+		prompt = content
+		return nil
+	})
+	tool := NewHomeAssistantTool(config.HomeAssistantToolConfig{
+		URL: srv.URL, Token: "ha-token", RequireApproval: true, AllowedDomains: []string{"light"},
+	}, approvals)
+
+	ctx := WithToolSender(WithToolContext(context.Background(), "telegram", "chat-1"), "alice")
+	result := tool.Execute(ctx, map[string]any{
+		"action": "call_service", "domain": "light", "service": "turn_on",
+		"entity_id": "light.kitchen", "data": map[string]any{"brightness_pct": 40.0},
+	})
+	if result.IsError || calls.Load() != 0 {
+		t.Fatalf("service ran before approval: %+v", result)
+	}
+	if !strings.Contains(prompt, "light.turn_on on light.kitchen") || !strings.Contains(result.ForLLM, "NOT run") {
+		t.Fatalf("prompt %q, result %q", prompt, result.ForLLM)
+	}
+	id := strings.Fields(prompt[strings.Index(prompt, "/approve "):])[1]
+
+	if _, _, err := approvals.Resolve(context.Background(), id, "telegram", "mallory", true); !errors.Is(err, ErrNoPendingApproval) {
+		t.Fatalf("another user approved the action: %v", err)
+	}
+	summary, out, err := approvals.Resolve(context.Background(), strings.ToLower(id), "telegram", "alice", true)
+	if err != nil || out == nil || calls.Load() != 1 {
+		t.Fatalf("Resolve = %q, %+v, %v (calls %d)", summary, out, err, calls.Load())
+	}
+	if body["entity_id"] != "light.kitchen" || body["brightness_pct"] != 40.0 {
+		t.Errorf("service data = %v", body)
+	}
+	if _, _, err := approvals.Resolve(context.Background(), id, "telegram", "alice", true); !errors.Is(err, ErrNoPendingApproval) {
+		t.Error("an approval must only run once")
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "call_service", "domain": "lock", "service": "unlock"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not allowed") {
+		t.Errorf("disallowed domain: %+v", result)
+	}
+}
+
+func TestHomeAssistantTool_WithoutApproval(t *testing.T) {
+	var calls atomic.Int32
+	var body map[string]any
+	srv := newHomeAssistantServer(t, &calls, &body)
+	tool := NewHomeAssistantTool(config.HomeAssistantToolConfig{URL: srv.URL, Token: "ha-token"}, nil)
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"action": "call_service", "domain": "light", "service": "turn_on", "entity_id": "light.kitchen",
+	})
+	if result.IsError || calls.Load() != 1 || !strings.Contains(result.ForLLM, "light.kitchen: on") {
+		t.Fatalf("result = %+v, calls = %d", result, calls.Load())
+	}
+}
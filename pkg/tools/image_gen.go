@@ -0,0 +1,392 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/guardrail"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+const (
+	imageGenTimeout  = 2 * time.Minute
+	maxImageGenBytes = 32 << 20
+
+	defaultOpenAIImageBase    = "https://api.openai.com/v1"
+	defaultOpenAIImageModel   = "dall-e-3"
+	defaultStabilityImageBase = "https://api.stability.ai"
+	defaultStabilityModel     = "core"
+	defaultSDImageBase        = "http://127.0.0.1:7860"
+)
+
+// ImageGenTool generates an image from a prompt and sends it to the chat.
+// Prompts are checked before any image is made, by the configured
+// guardrails and by the tool's own checks.
+type ImageGenTool struct {
+	channelAllowList
+	provider   string
+	apiKey     string
+	apiBase    string
+	model      string
+	size       string
+	guards     []*guardrail.Guard
+	mediaStore media.MediaStore
+	client     *http.Client
+}
+
+// NewImageGenTool creates the tool. guard holds the configured guardrails
+// and may be nil.
+func NewImageGenTool(cfg config.ImageGenToolConfig, guard *guardrail.Guard) (*ImageGenTool, error) {
+	t := &ImageGenTool{
+		channelAllowList: cfg.Channels,
+		provider:         strings.ToLower(cfg.Provider),
+		apiKey:           cfg.APIKey,
+		apiBase:          strings.TrimRight(cfg.APIBase, "/"),
+		model:            cfg.Model,
+		size:             cfg.Size,
+		client:           &http.Client{Timeout: imageGenTimeout},
+	}
+	if t.provider == "" {
+		t.provider = "openai"
+	}
+	switch t.provider {
+	case "openai":
+		if t.apiBase == "" {
+			t.apiBase = defaultOpenAIImageBase
+		}
+		if t.model == "" {
+			t.model = defaultOpenAIImageModel
+		}
+	case "stability":
+		if t.apiBase == "" {
+			t.apiBase = defaultStabilityImageBase
+		}
+		if t.model == "" {
+			t.model = defaultStabilityModel
+		}
+	case "sd":
+		if t.apiBase == "" {
+			t.apiBase = defaultSDImageBase
+		}
+	default:
+		return nil, fmt.Errorf("unknown image provider %q", cfg.Provider)
+	}
+	if t.provider != "sd" && t.apiKey == "" {
+		return nil, fmt.Errorf("%s image provider requires api_key", t.provider)
+	}
+	if _, _, err := parseImageSize(t.size); t.size != "" && err != nil {
+		return nil, err
+	}
+
+	// The tool's own checks: the blocked terms, and OpenAI's moderation for
+	// the key that makes the image anyway. Prompts it cannot check are refused.
+	var own []config.GuardrailServiceConfig
+	if len(cfg.BlockedTerms) > 0 {
+		own = append(own, config.GuardrailServiceConfig{
+			Name: "blocked_terms", Type: "rules", Keywords: cfg.BlockedTerms, Action: guardrail.ActionBlock,
+		})
+	}
+	if t.provider == "openai" {
+		own = append(own, config.GuardrailServiceConfig{
+			Type: "openai_moderation", URL: t.apiBase, APIKey: t.apiKey, Action: guardrail.ActionBlock, FailClosed: true,
+		})
+	}
+	ownGuard, err := guardrail.NewGuardFromConfig(config.GuardrailsConfig{Enabled: true, Services: own})
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range []*guardrail.Guard{guard, ownGuard} {
+		if g != nil {
+			t.guards = append(t.guards, g)
+		}
+	}
+	return t, nil
+}
+
+func (t *ImageGenTool) Name() string { return "generate_image" }
+
+func (t *ImageGenTool) Description() string {
+	return "Generate an image from a text description and send it to the user in this chat."
+}
+
+func (t *ImageGenTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"prompt": map[string]any{
+				"type":        "string",
+				"description": "Detailed description of the image to generate",
+			},
+			"size": map[string]any{
+				"type":        "string",
+				"description": "Optional WIDTHxHEIGHT in pixels, such as 1024x1024 or 1792x1024",
+			},
+		},
+		"required": []string{"prompt"},
+	}
+}
+
+func (t *ImageGenTool) SetMediaStore(store media.MediaStore) {
+	t.mediaStore = store
+}
+
+func (t *ImageGenTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	prompt, _ := args["prompt"].(string)
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return ErrorResult("prompt is required")
+	}
+	size, _ := args["size"].(string)
+	if size = strings.TrimSpace(size); size == "" {
+		size = t.size
+	}
+	if _, _, err := parseImageSize(size); size != "" && err != nil {
+		return ErrorResult(err.Error())
+	}
+	channel, chatID := ToolChannel(ctx), ToolChatID(ctx)
+	if channel == "" || chatID == "" {
+		return ErrorResult("no target channel/chat available")
+	}
+	if t.mediaStore == nil {
+		return ErrorResult("media store not configured")
+	}
+
+	req := guardrail.Request{Stage: guardrail.StageInput, Text: prompt, Channel: channel, ChatID: chatID}
+	for _, g := range t.guards {
+		if v := g.Check(ctx, req); v.Action == guardrail.ActionBlock || v.Action == guardrail.ActionRedact {
+			logger.InfoCF("tool", "Refused image prompt", map[string]any{
+				"service": v.Service,
+				"reason":  v.Reason,
+				"channel": channel,
+			})
+			return ErrorResult("the image prompt was refused by the safety check; do not retry it reworded")
+		}
+	}
+
+	data, contentType, revised, err := t.generate(ctx, prompt, size)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("image generation failed: %v", err)).WithError(err)
+	}
+
+	ext := ".png"
+	if strings.Contains(contentType, "jpeg") {
+		ext = ".jpg"
+	} else if strings.Contains(contentType, "webp") {
+		ext = ".webp"
+	}
+	dir := media.TempDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save the image: %v", err))
+	}
+	path := filepath.Join(dir, "image-"+uuid.New().String()+ext)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save the image: %v", err))
+	}
+	ref, err := t.mediaStore.Store(path, media.MediaMeta{
+		Filename:    "image" + ext,
+		ContentType: contentType,
+		Source:      "tool:image-gen",
+	}, fmt.Sprintf("tool:generate_image:%s:%s", channel, chatID))
+	if err != nil {
+		os.Remove(path)
+		return ErrorResult(fmt.Sprintf("failed to register media: %v", err))
+	}
+
+	forLLM := "Image generated and sent to the user."
+	if revised != "" && revised != prompt {
+		forLLM += " The provider revised the prompt to: " + revised
+	}
+	return MediaResult(forLLM, []string{ref})
+}
+
+// generate asks the provider for one image and returns its bytes and type,
+// with the prompt the provider used when it reports a revised one.
+func (t *ImageGenTool) generate(ctx context.Context, prompt, size string) ([]byte, string, string, error) {
+	switch t.provider {
+	case "stability":
+		return t.generateStability(ctx, prompt, size)
+	case "sd":
+		return t.generateSD(ctx, prompt, size)
+	default:
+		return t.generateOpenAI(ctx, prompt, size)
+	}
+}
+
+func (t *ImageGenTool) generateOpenAI(ctx context.Context, prompt, size string) ([]byte, string, string, error) {
+	body := map[string]any{"model": t.model, "prompt": prompt, "n": 1}
+	if size != "" {
+		body["size"] = size
+	}
+	// gpt-image models always return base64 and reject response_format.
+	if !strings.HasPrefix(t.model, "gpt-image") {
+		body["response_format"] = "b64_json"
+	}
+	payload, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.apiBase+"/images/generations", bytes.NewReader(payload))
+	if err != nil {
+		return nil, "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	respBody, _, err := t.do(req)
+	if err != nil {
+		return nil, "", "", err
+	}
+	var out struct {
+		Data []struct {
+			B64JSON       string `json:"b64_json"`
+			URL           string `json:"url"`
+			RevisedPrompt string `json:"revised_prompt"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return nil, "", "", fmt.Errorf("decode response: %w", err)
+	}
+	if len(out.Data) == 0 {
+		return nil, "", "", errors.New("no image in the response")
+	}
+	image := out.Data[0]
+	if image.B64JSON != "" {
+		data, err := base64.StdEncoding.DecodeString(image.B64JSON)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("decode image: %w", err)
+		}
+		return data, http.DetectContentType(data), image.RevisedPrompt, nil
+	}
+	if image.URL == "" {
+		return nil, "", "", errors.New("no image in the response")
+	}
+	get, err := http.NewRequestWithContext(ctx, http.MethodGet, image.URL, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	data, contentType, err := t.do(get)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return data, contentType, image.RevisedPrompt, nil
+}
+
+func (t *ImageGenTool) generateStability(ctx context.Context, prompt, size string) ([]byte, string, string, error) {
+	var form bytes.Buffer
+	w := multipart.NewWriter(&form)
+	w.WriteField("prompt", prompt)
+	w.WriteField("output_format", "png")
+	if width, height, err := parseImageSize(size); size != "" && err == nil {
+		w.WriteField("aspect_ratio", aspectRatio(width, height))
+	}
+	w.Close()
+	url := t.apiBase + "/v2beta/stable-image/generate/" + t.model
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &form)
+	if err != nil {
+		return nil, "", "", err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	req.Header.Set("Accept", "image/*")
+	data, contentType, err := t.do(req)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return data, contentType, "", nil
+}
+
+func (t *ImageGenTool) generateSD(ctx context.Context, prompt, size string) ([]byte, string, string, error) {
+	body := map[string]any{"prompt": prompt}
+	if width, height, err := parseImageSize(size); size != "" && err == nil {
+		body["width"], body["height"] = width, height
+	}
+	if t.model != "" {
+		body["override_settings"] = map[string]any{"sd_model_checkpoint": t.model}
+	}
+	payload, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.apiBase+"/sdapi/v1/txt2img", bytes.NewReader(payload))
+	if err != nil {
+		return nil, "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+	respBody, _, err := t.do(req)
+	if err != nil {
+		return nil, "", "", err
+	}
+	var out struct {
+		Images []string `json:"images"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return nil, "", "", fmt.Errorf("decode response: %w", err)
+	}
+	if len(out.Images) == 0 {
+		return nil, "", "", errors.New("no image in the response")
+	}
+	data, err := base64.StdEncoding.DecodeString(out.Images[0])
+	if err != nil {
+		return nil, "", "", fmt.Errorf("decode image: %w", err)
+	}
+	return data, http.DetectContentType(data), "", nil
+}
+
+// do sends req and returns the body and content type of a 2xx response.
+func (t *ImageGenTool) do(req *http.Request) ([]byte, string, error) {
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxImageGenBytes))
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body[:min(len(body), 300)])))
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+func parseImageSize(size string) (width, height int, err error) {
+	w, h, ok := strings.Cut(strings.ToLower(size), "x")
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if !ok || errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid image size %q, want WIDTHxHEIGHT", size)
+	}
+	return width, height, nil
+}
+
+// aspectRatio returns the Stability aspect ratio closest to width:height.
+func aspectRatio(width, height int) string {
+	ratios := []string{"21:9", "16:9", "3:2", "5:4", "1:1", "4:5", "2:3", "9:16", "9:21"}
+	want := float64(width) / float64(height)
+	best, bestDiff := "1:1", -1.0
+	for _, r := range ratios {
+		a, b, _ := strings.Cut(r, ":")
+		x, _ := strconv.Atoi(a)
+		y, _ := strconv.Atoi(b)
+		diff := want - float64(x)/float64(y)
+		if diff < 0 {
+			diff = -diff
+		}
+		if bestDiff < 0 || diff < bestDiff {
+			best, bestDiff = r, diff
+		}
+	}
+	return best
+}
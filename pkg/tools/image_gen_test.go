@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestImageGenTool_OpenAI(t *testing.T) {
+	var generated []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer sk-test" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/moderations":
+			flagged := strings.Contains(body["input"].(string), "gore")
+			json.NewEncoder(w).Encode(map[string]any{"results": []map[string]any{
+				{"flagged": flagged, "categories": map[string]bool{"violence/graphic": flagged}},
+			}})
+		case "/images/generations":
+			generated = append(generated, body)
+			json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{
+				"b64_json":       base64.StdEncoding.EncodeToString(pngHeader),
+				"revised_prompt": "a watercolor fox in a snowy forest",
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tool, err := NewImageGenTool(config.ImageGenToolConfig{
+		APIKey: "sk-test", APIBase: srv.URL, Size: "1024x1024", BlockedTerms: []string{"celebrity"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := media.NewFileMediaStore()
+	tool.SetMediaStore(store)
+	ctx := WithToolContext(context.Background(), "discord", "chan-1")
+
+	result := tool.Execute(ctx, map[string]any{"prompt": "a fox", "size": "1792x1024"})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "a watercolor fox") {
+		t.Errorf("ForLLM = %q, want the revised prompt", result.ForLLM)
+	}
+	if len(generated) != 1 || generated[0]["model"] != "dall-e-3" || generated[0]["size"] != "1792x1024" ||
+		generated[0]["response_format"] != "b64_json" {
+		t.Errorf("generation requests = %v", generated)
+	}
+	if len(result.Media) != 1 {
+		t.Fatalf("Media = %v, want one ref", result.Media)
+	}
+	path, meta, err := store.ResolveWithMeta(result.Media[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	if meta.ContentType != "image/png" || meta.Source != "tool:image-gen" || meta.Filename != "image.png" {
+		t.Errorf("meta = %+v", meta)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(pngHeader) {
+		t.Errorf("stored image = %q", data)
+	}
+
+	for _, prompt := range []string{"a celebrity at the beach", "lots of gore"} {
+		if result := tool.Execute(ctx, map[string]any{"prompt": prompt}); !result.IsError ||
+			!strings.Contains(result.ForLLM, "refused") {
+			t.Errorf("Execute(%q) = %q, want refused", prompt, result.ForLLM)
+		}
+	}
+	if len(generated) != 1 {
+		t.Errorf("refused prompts reached the provider: %v", generated[1:])
+	}
+}
+
+func TestImageGenTool_StableDiffusion(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sdapi/v1/txt2img" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(map[string]any{"images": []string{base64.StdEncoding.EncodeToString(pngHeader)}})
+	}))
+	defer srv.Close()
+
+	tool, err := NewImageGenTool(config.ImageGenToolConfig{Provider: "sd", APIBase: srv.URL, Size: "512x768"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool.SetMediaStore(media.NewFileMediaStore())
+	ctx := WithToolContext(context.Background(), "telegram", "42")
+
+	result := tool.Execute(ctx, map[string]any{"prompt": "a lighthouse"})
+	if result.IsError || len(result.Media) != 1 {
+		t.Fatalf("Execute = %q, media %v", result.ForLLM, result.Media)
+	}
+	if body["prompt"] != "a lighthouse" || body["width"] != 512.0 || body["height"] != 768.0 {
+		t.Errorf("txt2img body = %v", body)
+	}
+
+	if result := tool.Execute(ctx, map[string]any{"prompt": "x", "size": "big"}); !result.IsError {
+		t.Error("expected an error for an invalid size")
+	}
+}
+
+func TestNewImageGenTool_Config(t *testing.T) {
+	if _, err := NewImageGenTool(config.ImageGenToolConfig{Provider: "openai"}, nil); err == nil {
+		t.Error("expected an error without api_key")
+	}
+	if _, err := NewImageGenTool(config.ImageGenToolConfig{Provider: "midjourney", APIKey: "k"}, nil); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+	if got := aspectRatio(1792, 1024); got != "16:9" {
+		t.Errorf("aspectRatio(1792, 1024) = %q, want 16:9", got)
+	}
+}
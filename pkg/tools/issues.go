@@ -0,0 +1,261 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	issuesTimeout        = 30 * time.Second
+	issuesDefaultLimit   = 10
+	issuesMaxLimit       = 50
+	issuesMaxTextRunes   = 4000
+	issuesMaxComments    = 10
+	issuesDefaultType    = "Task"
+	issuesProviderJira   = "jira"
+	issuesProviderLinear = "linear"
+)
+
+// Issue is a tracker issue, normalised across Jira and Linear.
+type Issue struct {
+	Key         string
+	Title       string
+	Status      string
+	Assignee    string
+	URL         string
+	Description string
+	Updated     string
+	Comments    []IssueComment
+}
+
+// IssueComment is one comment on an issue.
+type IssueComment struct {
+	Author string
+	Body   string
+}
+
+type issueTracker interface {
+	Create(ctx context.Context, project, title, description, issueType string) (*Issue, error)
+	Search(ctx context.Context, project, query string, limit int) ([]Issue, error)
+	Get(ctx context.Context, key string) (*Issue, error)
+}
+
+// IssuesTool creates, searches, and reads Jira or Linear issues. The tracker
+// is chosen per guild so each Discord server or Slack workspace files into its
+// own project with its own credentials.
+type IssuesTool struct {
+	cfg    config.IssuesToolConfig
+	client *http.Client
+}
+
+func NewIssuesTool(cfg config.IssuesToolConfig) *IssuesTool {
+	return &IssuesTool{
+		cfg:    cfg,
+		client: &http.Client{Timeout: issuesTimeout},
+	}
+}
+
+func (t *IssuesTool) Name() string {
+	return "issues"
+}
+
+func (t *IssuesTool) Description() string {
+	return "Create, search, and read issues in the team's issue tracker (Jira or Linear). " +
+		"Use 'create' to file a ticket: write a clear title and a description that captures " +
+		"the problem, steps to reproduce, and expected behavior from the conversation. " +
+		"Use 'search' to find existing issues before filing duplicates, and 'get' to read an " +
+		"issue with its comments when asked to summarize it."
+}
+
+func (t *IssuesTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"create", "search", "get"},
+				"description": "Operation to perform",
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": "Issue title (create)",
+			},
+			"description": map[string]any{
+				"type":        "string",
+				"description": "Issue body in plain text (create)",
+			},
+			"type": map[string]any{
+				"type":        "string",
+				"description": "Jira issue type such as Bug, Task, or Story (create, default Task; ignored by Linear)",
+			},
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Free-text search terms (search)",
+			},
+			"key": map[string]any{
+				"type":        "string",
+				"description": "Issue key such as PROJ-123 or ENG-42 (get)",
+			},
+			"project": map[string]any{
+				"type":        "string",
+				"description": "Jira project key or Linear team key; defaults to the configured project",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum search results (default 10, max 50)",
+				"minimum":     1.0,
+				"maximum":     float64(issuesMaxLimit),
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *IssuesTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	tracker, account, err := t.trackerFor(ToolGuildID(ctx))
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	project, _ := args["project"].(string)
+	if project == "" {
+		project = account.Project
+	}
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "create":
+		title, _ := args["title"].(string)
+		if strings.TrimSpace(title) == "" {
+			return ErrorResult("title is required to create an issue")
+		}
+		description, _ := args["description"].(string)
+		issueType, _ := args["type"].(string)
+		if issueType == "" {
+			issueType = issuesDefaultType
+		}
+		issue, err := tracker.Create(ctx, project, title, description, issueType)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to create issue: %v", err)).WithError(err)
+		}
+		return NewToolResult(fmt.Sprintf("Created %s: %s\n%s", issue.Key, issue.Title, issue.URL))
+
+	case "search":
+		query, _ := args["query"].(string)
+		if strings.TrimSpace(query) == "" {
+			return ErrorResult("query is required to search issues")
+		}
+		limit := issuesDefaultLimit
+		if n, ok := args["limit"].(float64); ok && n >= 1 {
+			limit = min(int(n), issuesMaxLimit)
+		}
+		issues, err := tracker.Search(ctx, project, query, limit)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to search issues: %v", err)).WithError(err)
+		}
+		if len(issues) == 0 {
+			return SilentResult(fmt.Sprintf("No issues found for %q", query))
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "Found %d issue(s) for %q:\n", len(issues), query)
+		for _, issue := range issues {
+			b.WriteString(formatIssueLine(issue))
+		}
+		return SilentResult(b.String())
+
+	case "get":
+		key, _ := args["key"].(string)
+		if strings.TrimSpace(key) == "" {
+			return ErrorResult("key is required to get an issue")
+		}
+		issue, err := tracker.Get(ctx, strings.TrimSpace(key))
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to get issue %s: %v", key, err)).WithError(err)
+		}
+		return SilentResult(formatIssue(issue))
+
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q (expected create, search, or get)", action))
+	}
+}
+
+// trackerFor returns the tracker for guildID, falling back to the top-level
+// account for chats outside a configured guild.
+func (t *IssuesTool) trackerFor(guildID string) (issueTracker, config.IssueTrackerConfig, error) {
+	account := t.cfg.IssueTrackerConfig
+	if guild, ok := t.cfg.Guilds[guildID]; ok && guildID != "" {
+		account = guild
+	}
+
+	switch strings.ToLower(account.Provider) {
+	case issuesProviderJira:
+		if account.BaseURL == "" || account.Email == "" || account.APIToken == "" {
+			return nil, account, fmt.Errorf("jira needs base_url, email, and api_token")
+		}
+		return &jiraTracker{
+			baseURL: strings.TrimRight(account.BaseURL, "/"),
+			email:   account.Email,
+			token:   account.APIToken,
+			client:  t.client,
+		}, account, nil
+	case issuesProviderLinear:
+		if account.APIToken == "" {
+			return nil, account, fmt.Errorf("linear needs api_token")
+		}
+		endpoint := account.BaseURL
+		if endpoint == "" {
+			endpoint = linearDefaultEndpoint
+		}
+		return &linearTracker{endpoint: endpoint, apiKey: account.APIToken, client: t.client}, account, nil
+	case "":
+		return nil, account, fmt.Errorf("no issue tracker is configured for this server")
+	default:
+		return nil, account, fmt.Errorf("unsupported issue tracker %q (expected jira or linear)", account.Provider)
+	}
+}
+
+func formatIssueLine(issue Issue) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "- %s [%s] %s", issue.Key, issue.Status, issue.Title)
+	if issue.Assignee != "" {
+		fmt.Fprintf(&b, " (%s)", issue.Assignee)
+	}
+	if issue.URL != "" {
+		fmt.Fprintf(&b, " %s", issue.URL)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func formatIssue(issue *Issue) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n", issue.Key, issue.Title)
+	fmt.Fprintf(&b, "Status: %s\n", issue.Status)
+	assignee := issue.Assignee
+	if assignee == "" {
+		assignee = "unassigned"
+	}
+	fmt.Fprintf(&b, "Assignee: %s\n", assignee)
+	if issue.Updated != "" {
+		fmt.Fprintf(&b, "Updated: %s\n", issue.Updated)
+	}
+	if issue.URL != "" {
+		fmt.Fprintf(&b, "URL: %s\n", issue.URL)
+	}
+	if issue.Description != "" {
+		fmt.Fprintf(&b, "\n%s\n", utils.Truncate(issue.Description, issuesMaxTextRunes))
+	}
+	if len(issue.Comments) > 0 {
+		b.WriteString("\nComments:\n")
+		for _, c := range issue.Comments {
+			fmt.Fprintf(&b, "- %s: %s\n", c.Author, utils.Truncate(c.Body, 1000))
+		}
+	}
+	return b.String()
+}
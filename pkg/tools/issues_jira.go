@@ -0,0 +1,221 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// jiraTracker talks to the Jira Cloud REST API v3 with an API token.
+type jiraTracker struct {
+	baseURL string
+	email   string
+	token   string
+	client  *http.Client
+}
+
+type jiraFields struct {
+	Summary string `json:"summary"`
+	Status  struct {
+		Name string `json:"name"`
+	} `json:"status"`
+	Assignee *struct {
+		DisplayName string `json:"displayName"`
+	} `json:"assignee"`
+	Updated     string   `json:"updated"`
+	Description *adfNode `json:"description"`
+	Comment     struct {
+		Comments []struct {
+			Author struct {
+				DisplayName string `json:"displayName"`
+			} `json:"author"`
+			Body *adfNode `json:"body"`
+		} `json:"comments"`
+	} `json:"comment"`
+}
+
+type jiraIssue struct {
+	Key    string     `json:"key"`
+	Fields jiraFields `json:"fields"`
+}
+
+// adfNode is a node of the Atlassian Document Format used for rich text.
+type adfNode struct {
+	Type    string    `json:"type"`
+	Text    string    `json:"text,omitempty"`
+	Version int       `json:"version,omitempty"`
+	Content []adfNode `json:"content,omitempty"`
+}
+
+func (j *jiraTracker) Create(ctx context.Context, project, title, description, issueType string) (*Issue, error) {
+	if project == "" {
+		return nil, fmt.Errorf("a Jira project key is required")
+	}
+	fields := map[string]any{
+		"project":   map[string]string{"key": project},
+		"summary":   title,
+		"issuetype": map[string]string{"name": issueType},
+	}
+	if strings.TrimSpace(description) != "" {
+		fields["description"] = adfFromText(description)
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := j.do(ctx, http.MethodPost, "/rest/api/3/issue", map[string]any{"fields": fields}, &created); err != nil {
+		return nil, err
+	}
+	return &Issue{Key: created.Key, Title: title, URL: j.browseURL(created.Key)}, nil
+}
+
+func (j *jiraTracker) Search(ctx context.Context, project, query string, limit int) ([]Issue, error) {
+	jql := fmt.Sprintf("text ~ %s ORDER BY updated DESC", jqlQuote(query))
+	if project != "" {
+		jql = fmt.Sprintf("project = %s AND %s", jqlQuote(project), jql)
+	}
+	req := map[string]any{
+		"jql":        jql,
+		"maxResults": limit,
+		"fields":     []string{"summary", "status", "assignee", "updated"},
+	}
+	var resp struct {
+		Issues []jiraIssue `json:"issues"`
+	}
+	if err := j.do(ctx, http.MethodPost, "/rest/api/3/search/jql", req, &resp); err != nil {
+		return nil, err
+	}
+	issues := make([]Issue, 0, len(resp.Issues))
+	for _, it := range resp.Issues {
+		issues = append(issues, j.toIssue(it))
+	}
+	return issues, nil
+}
+
+func (j *jiraTracker) Get(ctx context.Context, key string) (*Issue, error) {
+	path := "/rest/api/3/issue/" + url.PathEscape(key) +
+		"?fields=summary,status,assignee,updated,description,comment"
+	var it jiraIssue
+	if err := j.do(ctx, http.MethodGet, path, nil, &it); err != nil {
+		return nil, err
+	}
+	issue := j.toIssue(it)
+	issue.Description = adfText(it.Fields.Description)
+	comments := it.Fields.Comment.Comments
+	if len(comments) > issuesMaxComments {
+		comments = comments[len(comments)-issuesMaxComments:]
+	}
+	for _, c := range comments {
+		issue.Comments = append(issue.Comments, IssueComment{
+			Author: c.Author.DisplayName,
+			Body:   adfText(c.Body),
+		})
+	}
+	return &issue, nil
+}
+
+func (j *jiraTracker) toIssue(it jiraIssue) Issue {
+	issue := Issue{
+		Key:     it.Key,
+		Title:   it.Fields.Summary,
+		Status:  it.Fields.Status.Name,
+		URL:     j.browseURL(it.Key),
+		Updated: it.Fields.Updated,
+	}
+	if it.Fields.Assignee != nil {
+		issue.Assignee = it.Fields.Assignee.DisplayName
+	}
+	return issue
+}
+
+func (j *jiraTracker) browseURL(key string) string {
+	return j.baseURL + "/browse/" + key
+}
+
+func (j *jiraTracker) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, j.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(j.email, j.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("jira returned %s: %s", resp.Status, utils.Truncate(strings.TrimSpace(string(data)), 300))
+	}
+	return json.Unmarshal(data, out)
+}
+
+// jqlQuote quotes s as a JQL string literal.
+func jqlQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// adfFromText builds an ADF document with one paragraph per blank-line
+// separated block of text.
+func adfFromText(text string) adfNode {
+	doc := adfNode{Type: "doc", Version: 1}
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if para = strings.TrimSpace(para); para == "" {
+			continue
+		}
+		doc.Content = append(doc.Content, adfNode{
+			Type:    "paragraph",
+			Content: []adfNode{{Type: "text", Text: para}},
+		})
+	}
+	return doc
+}
+
+// adfText flattens an ADF document to plain text.
+func adfText(node *adfNode) string {
+	if node == nil {
+		return ""
+	}
+	var b strings.Builder
+	var walk func(n adfNode)
+	walk = func(n adfNode) {
+		switch n.Type {
+		case "text":
+			b.WriteString(n.Text)
+		case "hardBreak":
+			b.WriteString("\n")
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+		switch n.Type {
+		case "paragraph", "heading", "listItem", "codeBlock", "blockquote":
+			b.WriteString("\n")
+		}
+	}
+	walk(*node)
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const linearDefaultEndpoint = "https://api.linear.app/graphql"
+
+// linearTracker talks to the Linear GraphQL API with a personal API key.
+type linearTracker struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+type linearIssue struct {
+	Identifier  string `json:"identifier"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	UpdatedAt   string `json:"updatedAt"`
+	State       struct {
+		Name string `json:"name"`
+	} `json:"state"`
+	Assignee *struct {
+		Name string `json:"name"`
+	} `json:"assignee"`
+	Comments struct {
+		Nodes []struct {
+			Body string `json:"body"`
+			User *struct {
+				Name string `json:"name"`
+			} `json:"user"`
+		} `json:"nodes"`
+	} `json:"comments"`
+}
+
+const linearIssueFields = `identifier title url updatedAt state { name } assignee { name }`
+
+func (l *linearTracker) Create(ctx context.Context, project, title, description, _ string) (*Issue, error) {
+	if project == "" {
+		return nil, fmt.Errorf("a Linear team key is required")
+	}
+	var teams struct {
+		Teams struct {
+			Nodes []struct {
+				ID string `json:"id"`
+			} `json:"nodes"`
+		} `json:"teams"`
+	}
+	err := l.query(ctx, `query($key: String!) { teams(filter: { key: { eq: $key } }) { nodes { id } } }`,
+		map[string]any{"key": project}, &teams)
+	if err != nil {
+		return nil, err
+	}
+	if len(teams.Teams.Nodes) == 0 {
+		return nil, fmt.Errorf("linear team %q not found", project)
+	}
+
+	input := map[string]any{"teamId": teams.Teams.Nodes[0].ID, "title": title}
+	if strings.TrimSpace(description) != "" {
+		input["description"] = description
+	}
+	var created struct {
+		IssueCreate struct {
+			Success bool        `json:"success"`
+			Issue   linearIssue `json:"issue"`
+		} `json:"issueCreate"`
+	}
+	err = l.query(ctx, `mutation($input: IssueCreateInput!) { issueCreate(input: $input) { success issue { `+
+		linearIssueFields+` } } }`, map[string]any{"input": input}, &created)
+	if err != nil {
+		return nil, err
+	}
+	if !created.IssueCreate.Success {
+		return nil, errors.New("linear did not create the issue")
+	}
+	issue := l.toIssue(created.IssueCreate.Issue)
+	return &issue, nil
+}
+
+func (l *linearTracker) Search(ctx context.Context, project, query string, limit int) ([]Issue, error) {
+	vars := map[string]any{"term": query, "first": limit}
+	if project != "" {
+		vars["filter"] = map[string]any{"team": map[string]any{"key": map[string]any{"eq": project}}}
+	}
+	var resp struct {
+		SearchIssues struct {
+			Nodes []linearIssue `json:"nodes"`
+		} `json:"searchIssues"`
+	}
+	err := l.query(ctx, `query($term: String!, $first: Int, $filter: IssueFilter) { `+
+		`searchIssues(term: $term, first: $first, filter: $filter) { nodes { `+linearIssueFields+` } } }`,
+		vars, &resp)
+	if err != nil {
+		return nil, err
+	}
+	issues := make([]Issue, 0, len(resp.SearchIssues.Nodes))
+	for _, it := range resp.SearchIssues.Nodes {
+		issues = append(issues, l.toIssue(it))
+	}
+	return issues, nil
+}
+
+func (l *linearTracker) Get(ctx context.Context, key string) (*Issue, error) {
+	var resp struct {
+		Issue *linearIssue `json:"issue"`
+	}
+	err := l.query(ctx, fmt.Sprintf(`query($id: String!) { issue(id: $id) { %s description `+
+		`comments(last: %d) { nodes { body user { name } } } } }`, linearIssueFields, issuesMaxComments),
+		map[string]any{"id": key}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Issue == nil {
+		return nil, fmt.Errorf("issue %s not found", key)
+	}
+	issue := l.toIssue(*resp.Issue)
+	issue.Description = resp.Issue.Description
+	for _, c := range resp.Issue.Comments.Nodes {
+		author := "unknown"
+		if c.User != nil {
+			author = c.User.Name
+		}
+		issue.Comments = append(issue.Comments, IssueComment{Author: author, Body: c.Body})
+	}
+	return &issue, nil
+}
+
+func (l *linearTracker) toIssue(it linearIssue) Issue {
+	issue := Issue{
+		Key:     it.Identifier,
+		Title:   it.Title,
+		Status:  it.State.Name,
+		URL:     it.URL,
+		Updated: it.UpdatedAt,
+	}
+	if it.Assignee != nil {
+		issue.Assignee = it.Assignee.Name
+	}
+	return issue
+}
+
+func (l *linearTracker) query(ctx context.Context, query string, vars map[string]any, out any) error {
+	body, err := json.Marshal(map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", l.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("linear returned %s: %s", resp.Status, utils.Truncate(strings.TrimSpace(string(data)), 300))
+	}
+
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+	if len(envelope.Errors) > 0 {
+		return fmt.Errorf("linear: %s", envelope.Errors[0].Message)
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestIssuesTool_JiraCreateUsesGuildAccount(t *testing.T) {
+	var got map[string]any
+	var user, pass string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/rest/api/3/issue" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		user, pass, _ = r.BasicAuth()
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"id":"10001","key":"BUG-7"}`))
+	}))
+	defer srv.Close()
+
+	tool := NewIssuesTool(config.IssuesToolConfig{
+		IssueTrackerConfig: config.IssueTrackerConfig{Provider: "linear", APIToken: "default"},
+		Guilds: map[string]config.IssueTrackerConfig{
+			"guild-1": {Provider: "jira", BaseURL: srv.URL, Email: "bot@example.com", APIToken: "tok", Project: "BUG"},
+		},
+	})
+	ctx := WithToolGuild(context.Background(), "guild-1")
+	result := tool.Execute(ctx, map[string]any{
+		"action":      "create",
+		"title":       "Crash on startup",
+		"description": "Steps:\n1. run it\n\nExpected: no crash",
+		"type":        "Bug",
+	})
+	if result.IsError {
+		t.Fatalf("create failed: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "BUG-7") || !strings.Contains(result.ForLLM, srv.URL+"/browse/BUG-7") {
+		t.Errorf("result = %q", result.ForLLM)
+	}
+	if user != "bot@example.com" || pass != "tok" {
+		t.Errorf("basic auth = %q:%q", user, pass)
+	}
+	fields := got["fields"].(map[string]any)
+	if fields["project"].(map[string]any)["key"] != "BUG" || fields["issuetype"].(map[string]any)["name"] != "Bug" {
+		t.Errorf("fields = %v", fields)
+	}
+	if paras := fields["description"].(map[string]any)["content"].([]any); len(paras) != 2 {
+		t.Errorf("description should have 2 paragraphs, got %v", paras)
+	}
+}
+
+func TestIssuesTool_JiraGetFlattensDescription(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/3/issue/BUG-7" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"key":"BUG-7","fields":{"summary":"Crash","status":{"name":"In Progress"},
+		  "assignee":{"displayName":"Ada"},
+		  "description":{"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"It crashes."}]}]},
+		  "comment":{"comments":[{"author":{"displayName":"Bob"},"body":{"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"Repro'd"}]}]}}]}}}`))
+	}))
+	defer srv.Close()
+
+	tool := NewIssuesTool(config.IssuesToolConfig{
+		IssueTrackerConfig: config.IssueTrackerConfig{Provider: "jira", BaseURL: srv.URL, Email: "e", APIToken: "t"},
+	})
+	result := tool.Execute(context.Background(), map[string]any{"action": "get", "key": "BUG-7"})
+	for _, want := range []string{"BUG-7: Crash", "Status: In Progress", "Assignee: Ada", "It crashes.", "- Bob: Repro'd"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("get output missing %q:\n%s", want, result.ForLLM)
+		}
+	}
+}
+
+func TestIssuesTool_LinearSearch(t *testing.T) {
+	var req struct {
+		Query     string         `json:"query"`
+		Variables map[string]any `json:"variables"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "lin_key" {
+			t.Errorf("authorization = %q", r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		w.Write([]byte(`{"data":{"searchIssues":{"nodes":[
+		  {"identifier":"ENG-42","title":"Login broken","url":"https://linear.app/x/issue/ENG-42","state":{"name":"Todo"},"assignee":null}]}}}`))
+	}))
+	defer srv.Close()
+
+	tool := NewIssuesTool(config.IssuesToolConfig{
+		IssueTrackerConfig: config.IssueTrackerConfig{Provider: "linear", BaseURL: srv.URL, APIToken: "lin_key", Project: "ENG"},
+	})
+	result := tool.Execute(context.Background(), map[string]any{"action": "search", "query": "login", "limit": 5.0})
+	if result.IsError {
+		t.Fatalf("search failed: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "- ENG-42 [Todo] Login broken https://linear.app/x/issue/ENG-42") {
+		t.Errorf("result = %q", result.ForLLM)
+	}
+	if req.Variables["term"] != "login" || req.Variables["first"] != 5.0 || req.Variables["filter"] == nil {
+		t.Errorf("variables = %v", req.Variables)
+	}
+}
+
+func TestIssuesTool_LinearErrorsSurface(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors":[{"message":"Entity not found"}]}`))
+	}))
+	defer srv.Close()
+
+	tool := NewIssuesTool(config.IssuesToolConfig{
+		IssueTrackerConfig: config.IssueTrackerConfig{Provider: "linear", BaseURL: srv.URL, APIToken: "k"},
+	})
+	result := tool.Execute(context.Background(), map[string]any{"action": "get", "key": "ENG-1"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "Entity not found") {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestIssuesTool_RequiresConfiguredTracker(t *testing.T) {
+	tool := NewIssuesTool(config.IssuesToolConfig{
+		Guilds: map[string]config.IssueTrackerConfig{"other": {Provider: "linear", APIToken: "k"}},
+	})
+	ctx := WithToolGuild(context.Background(), "guild-1")
+	result := tool.Execute(ctx, map[string]any{"action": "search", "query": "x"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "no issue tracker") {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestJQLQuote(t *testing.T) {
+	if got := jqlQuote(`say "hi" \o/`); got != `"say \"hi\" \\o/"` {
+		t.Errorf("jqlQuote = %s", got)
+	}
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/knowledge"
+)
+
+const (
+	knowledgeDefaultLimit = 5
+	knowledgeMaxLimit     = 10
+)
+
+// KnowledgeSearchTool retrieves passages from pages synced from Notion and
+// Confluence, numbered so the model can cite them with their links.
+type KnowledgeSearchTool struct {
+	index *knowledge.Index
+}
+
+func NewKnowledgeSearchTool(index *knowledge.Index) *KnowledgeSearchTool {
+	return &KnowledgeSearchTool{index: index}
+}
+
+func (t *KnowledgeSearchTool) Name() string {
+	return "knowledge_search"
+}
+
+func (t *KnowledgeSearchTool) Description() string {
+	return "Search the team's internal documentation synced from Notion and Confluence. " +
+		"Use it for questions about internal processes, projects, and policies before answering from memory. " +
+		"Cite the passages you use as [n] and list their links at the end of the answer."
+}
+
+func (t *KnowledgeSearchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Keywords to search for",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum passages to return (default 5)",
+				"minimum":     1.0,
+				"maximum":     float64(knowledgeMaxLimit),
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *KnowledgeSearchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, _ := args["query"].(string)
+	if strings.TrimSpace(query) == "" {
+		return ErrorResult("query is required")
+	}
+	limit := knowledgeDefaultLimit
+	if n, ok := args["limit"].(float64); ok && n >= 1 {
+		limit = min(int(n), knowledgeMaxLimit)
+	}
+
+	hits := t.index.Search(query, limit)
+	if len(hits) == 0 {
+		return SilentResult(fmt.Sprintf("No documentation matches %q", query))
+	}
+	var b strings.Builder
+	for i, h := range hits {
+		fmt.Fprintf(&b, "[%d] %s\n%s\n\n%s\n\n", i+1, h.Title, h.URL, h.Text)
+	}
+	b.WriteString("Cite passages as [n] and list the links of the ones you used.")
+	return SilentResult(b.String())
+}
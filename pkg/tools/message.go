@@ -3,14 +3,14 @@ package tools
 import (
 	"context"
 	"fmt"
-	"sync/atomic"
+	"sync"
 )
 
 type SendCallback func(channel, chatID, content string) error
 
 type MessageTool struct {
 	sendCallback SendCallback
-	sentInRound  atomic.Bool // Tracks whether a message was sent in the current processing round
+	sentInRound  sync.Map // Conversations the tool sent to in their current processing round
 }
 
 func NewMessageTool() *MessageTool {
@@ -46,15 +46,18 @@ func (t *MessageTool) Parameters() map[string]any {
 	}
 }
 
-// ResetSentInRound resets the per-round send tracker.
+// ResetSentInRound resets the send tracker of a conversation.
 // Called by the agent loop at the start of each inbound message processing round.
-func (t *MessageTool) ResetSentInRound() {
-	t.sentInRound.Store(false)
+func (t *MessageTool) ResetSentInRound(channel, chatID string) {
+	t.sentInRound.Delete(channel + ":" + chatID)
 }
 
-// HasSentInRound returns true if the message tool sent a message during the current round.
-func (t *MessageTool) HasSentInRound() bool {
-	return t.sentInRound.Load()
+// HasSentInRound returns true if the message tool sent a message during the
+// current round of the conversation. Rounds of other conversations may run
+// at the same time, so each one is tracked on its own.
+func (t *MessageTool) HasSentInRound(channel, chatID string) bool {
+	_, ok := t.sentInRound.Load(channel + ":" + chatID)
+	return ok
 }
 
 func (t *MessageTool) SetSendCallback(callback SendCallback) {
@@ -67,6 +70,8 @@ func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 		return &ToolResult{ForLLM: "content is required", IsError: true}
 	}
 
+	// The round belongs to the conversation being answered, wherever it sends
+	round := ToolChannel(ctx) + ":" + ToolChatID(ctx)
 	channel, _ := args["channel"].(string)
 	chatID, _ := args["chat_id"].(string)
 
@@ -93,7 +98,7 @@ func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 		}
 	}
 
-	t.sentInRound.Store(true)
+	t.sentInRound.Store(round, true)
 	// Silent: user already received the message directly
 	return &ToolResult{
 		ForLLM: fmt.Sprintf("Message sent to %s:%s", channel, chatID),
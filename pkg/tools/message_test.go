@@ -92,6 +92,25 @@ func TestMessageTool_Execute_WithCustomChannel(t *testing.T) {
 	}
 }
 
+func TestMessageTool_SentInRoundPerConversation(t *testing.T) {
+	tool := NewMessageTool()
+	tool.SetSendCallback(func(channel, chatID, content string) error { return nil })
+
+	ctx := WithToolContext(context.Background(), "telegram", "a")
+	tool.Execute(ctx, map[string]any{"content": "hi", "channel": "slack", "chat_id": "x"})
+
+	if !tool.HasSentInRound("telegram", "a") {
+		t.Error("the conversation being answered should count as sent")
+	}
+	if tool.HasSentInRound("telegram", "b") || tool.HasSentInRound("slack", "x") {
+		t.Error("other conversations should not count as sent")
+	}
+	tool.ResetSentInRound("telegram", "a")
+	if tool.HasSentInRound("telegram", "a") {
+		t.Error("reset round still counts as sent")
+	}
+}
+
 func TestMessageTool_Execute_SendFailure(t *testing.T) {
 	tool := NewMessageTool()
 
@@ -0,0 +1,200 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PatchFileTool applies a unified diff to one file. Hunks are matched by
+// their context and removed lines, so a diff still applies when earlier
+// edits moved the lines it touches.
+type PatchFileTool struct {
+	fs fileSystem
+}
+
+func NewPatchFileTool(workspace string, restrict bool, allowPaths ...[]*regexp.Regexp) *PatchFileTool {
+	var patterns []*regexp.Regexp
+	if len(allowPaths) > 0 {
+		patterns = allowPaths[0]
+	}
+	return &PatchFileTool{fs: buildFs(workspace, restrict, patterns)}
+}
+
+func (t *PatchFileTool) Name() string {
+	return "patch_file"
+}
+
+func (t *PatchFileTool) Description() string {
+	return "Apply a unified diff (the output of diff -u or git diff) to a file. " +
+		"Include a few lines of context around each change; all hunks must apply or the file is left unchanged."
+}
+
+func (t *PatchFileTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "The file path to patch",
+			},
+			"patch": map[string]any{
+				"type":        "string",
+				"description": "Unified diff of the file, with @@ hunk headers",
+			},
+		},
+		"required": []string{"path", "patch"},
+	}
+}
+
+func (t *PatchFileTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	path, ok := args["path"].(string)
+	if !ok {
+		return ErrorResult("path is required")
+	}
+	patch, ok := args["patch"].(string)
+	if !ok {
+		return ErrorResult("patch is required")
+	}
+
+	content, err := t.fs.ReadFile(path)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	patched, hunks, err := applyUnifiedDiff(string(content), patch)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	if err := t.fs.WriteFile(path, []byte(patched)); err != nil {
+		return ErrorResult(err.Error())
+	}
+	return SilentResult(fmt.Sprintf("Applied %d hunks to %s", hunks, path))
+}
+
+var reHunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+type diffHunk struct {
+	oldStart int // 1-based line of the hunk in the original file
+	old      []string
+	new      []string
+}
+
+// parseUnifiedDiff reads the hunks of a single-file unified diff. File
+// headers and anything before the first hunk are ignored.
+func parseUnifiedDiff(patch string) ([]diffHunk, error) {
+	var hunks []diffHunk
+	var cur *diffHunk
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		isHeader := strings.HasPrefix(line, "diff ") ||
+			(strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "))
+		if isHeader {
+			if cur != nil {
+				return nil, fmt.Errorf("patch line %d: the patch covers more than one file", i+1)
+			}
+			continue
+		}
+		if m := reHunkHeader.FindStringSubmatch(line); m != nil {
+			start, _ := strconv.Atoi(m[1])
+			hunks = append(hunks, diffHunk{oldStart: start})
+			cur = &hunks[len(hunks)-1]
+			continue
+		}
+		if cur == nil || strings.HasPrefix(line, `\`) {
+			continue
+		}
+		switch {
+		case line == "":
+			// Editors and models often drop the space of empty context lines.
+			cur.old = append(cur.old, "")
+			cur.new = append(cur.new, "")
+		case line[0] == ' ':
+			cur.old = append(cur.old, line[1:])
+			cur.new = append(cur.new, line[1:])
+		case line[0] == '-':
+			cur.old = append(cur.old, line[1:])
+		case line[0] == '+':
+			cur.new = append(cur.new, line[1:])
+		default:
+			return nil, fmt.Errorf("patch line %d: expected ' ', '-', or '+' at the start of %q", i+1, line)
+		}
+	}
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("the patch has no @@ hunks")
+	}
+	for i := range hunks {
+		// A trailing empty line is the end of the patch, not context.
+		h := &hunks[i]
+		for len(h.old) > 0 && len(h.new) > 0 && h.old[len(h.old)-1] == "" && h.new[len(h.new)-1] == "" {
+			h.old, h.new = h.old[:len(h.old)-1], h.new[:len(h.new)-1]
+		}
+	}
+	return hunks, nil
+}
+
+// applyUnifiedDiff applies patch to content and returns the result and the
+// number of hunks applied. Each hunk is looked for at its line number first
+// and then ever further away from it.
+func applyUnifiedDiff(content, patch string) (string, int, error) {
+	hunks, err := parseUnifiedDiff(patch)
+	if err != nil {
+		return "", 0, err
+	}
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	offset := 0 // how far earlier hunks moved the lines after them
+	from := 0   // hunks apply in order, after the lines of the previous one
+	for i, h := range hunks {
+		want := max(h.oldStart-1+offset, 0)
+		if len(h.old) == 0 && h.oldStart == 0 {
+			want = 0
+		}
+		at := findHunk(lines, h.old, max(want, from), from)
+		if at < 0 {
+			return "", 0, fmt.Errorf("hunk %d (line %d) does not match the file; read it again and resend the patch",
+				i+1, h.oldStart)
+		}
+		rest := append(append([]string{}, h.new...), lines[at+len(h.old):]...)
+		lines = append(lines[:at], rest...)
+		offset += len(h.new) - len(h.old)
+		from = at + len(h.new)
+	}
+
+	out := strings.Join(lines, "\n")
+	if trailingNewline || (content == "" && len(lines) > 0) {
+		out += "\n"
+	}
+	return out, len(hunks), nil
+}
+
+// findHunk returns the index from from on in lines where old starts closest
+// to want, or -1.
+func findHunk(lines, old []string, want, from int) int {
+	matches := func(at int) bool {
+		if at < from || at+len(old) > len(lines) {
+			return false
+		}
+		for j, l := range old {
+			if strings.TrimRight(lines[at+j], " \t\r") != strings.TrimRight(l, " \t\r") {
+				return false
+			}
+		}
+		return true
+	}
+	for d := 0; d <= len(lines); d++ {
+		if matches(want - d) {
+			return want - d
+		}
+		if d > 0 && matches(want+d) {
+			return want + d
+		}
+	}
+	return -1
+}
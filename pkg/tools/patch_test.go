@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyUnifiedDiff(t *testing.T) {
+	content := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n\nfunc other() {}\n"
+	patch := `--- a/main.go
++++ b/main.go
+@@ -3,5 +3,5 @@
+ import "fmt"
+ 
+ func main() {
+-	fmt.Println("hi")
++	fmt.Println("hello")
+ }
+@@ -9,1 +9,3 @@
+-func other() {}
++func other() {
++	// -- not a file header
++}
+`
+	got, hunks, err := applyUnifiedDiff(content, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n\n" +
+		"func other() {\n\t// -- not a file header\n}\n"
+	if got != want || hunks != 2 {
+		t.Errorf("applied %d hunks:\n%s\nwant:\n%s", hunks, got, want)
+	}
+}
+
+func TestApplyUnifiedDiff_MovedLines(t *testing.T) {
+	// The hunk says line 2, but two lines were added above since.
+	content := "new1\nnew2\na\nb\nc\n"
+	got, _, err := applyUnifiedDiff(content, "@@ -2,2 +2,2 @@\n a\n-b\n+B\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "new1\nnew2\na\nB\nc\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestApplyUnifiedDiff_Errors(t *testing.T) {
+	for name, tc := range map[string]struct{ content, patch, want string }{
+		"no hunks":   {"a\n", "just text", "no @@ hunks"},
+		"mismatch":   {"a\nb\n", "@@ -1,1 +1,1 @@\n-x\n+y\n", "hunk 1 (line 1) does not match"},
+		"bad line":   {"a\n", "@@ -1,1 +1,1 @@\n-a\n*b\n", "expected ' ', '-', or '+'"},
+		"many files": {"a\n", "@@ -1 +1 @@\n-a\n+b\ndiff --git a/c b/c\n", "more than one file"},
+	} {
+		_, _, err := applyUnifiedDiff(tc.content, tc.patch)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", name, err, tc.want)
+		}
+	}
+}
+
+func TestApplyUnifiedDiff_NewFileContent(t *testing.T) {
+	got, _, err := applyUnifiedDiff("", "--- /dev/null\n+++ b/x\n@@ -0,0 +1,2 @@\n+one\n+two\n")
+	if err != nil || got != "one\ntwo\n" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
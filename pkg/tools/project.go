@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// projectTool exposes a file tool under a project_ name, scoped to the
+// project checkout instead of the agent workspace.
+type projectTool struct {
+	Tool
+	name        string
+	description string
+}
+
+func (t *projectTool) Name() string {
+	return t.name
+}
+
+func (t *projectTool) Description() string {
+	return t.description
+}
+
+// NewProjectTools returns the tools over the project checkout at cfg.Root:
+// project_read, project_list, and, unless the project is read-only,
+// project_write and project_patch. Paths are relative to the root, and
+// every access goes through os.Root, so neither ".." nor symlinks reach
+// outside it.
+func NewProjectTools(cfg config.ProjectToolsConfig, maxReadFileSize int) []Tool {
+	root := cfg.Root
+	const scope = " Paths are relative to the root of the project checkout."
+	tools := []Tool{
+		&projectTool{
+			Tool:        NewReadFileTool(root, true, maxReadFileSize),
+			name:        "project_read",
+			description: "Read a file of the project. Supports pagination via `offset` and `length`." + scope,
+		},
+		&projectTool{
+			Tool:        NewListDirTool(root, true),
+			name:        "project_list",
+			description: "List the files and directories in a directory of the project; use \".\" for the root." + scope,
+		},
+	}
+	if cfg.ReadOnly {
+		return tools
+	}
+	return append(tools,
+		&projectTool{
+			Tool:        NewWriteFileTool(root, true),
+			name:        "project_write",
+			description: "Write a whole file of the project, creating it and its directories if needed." + scope,
+		},
+		&projectTool{
+			Tool: NewPatchFileTool(root, true),
+			name: "project_patch",
+			description: "Apply a unified diff (the output of diff -u or git diff) to a file of the project. " +
+				"Include a few lines of context around each change; all hunks must apply or the file is left unchanged." +
+				scope,
+		},
+	)
+}
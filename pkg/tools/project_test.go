@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func projectToolsByName(cfg config.ProjectToolsConfig) map[string]Tool {
+	byName := make(map[string]Tool)
+	for _, tool := range NewProjectTools(cfg, 0) {
+		byName[tool.Name()] = tool
+	}
+	return byName
+}
+
+func TestProjectTools(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(root, "run.sh"), []byte("#!/bin/sh\necho one\n"), 0o755)
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644)
+	os.Symlink(outside, filepath.Join(root, "escape"))
+
+	tools := projectToolsByName(config.ProjectToolsConfig{Root: root})
+	if len(tools) != 4 {
+		t.Fatalf("tools = %v", tools)
+	}
+	ctx := context.Background()
+
+	result := tools["project_patch"].Execute(ctx, map[string]any{
+		"path":  "run.sh",
+		"patch": "@@ -1,2 +1,2 @@\n #!/bin/sh\n-echo one\n+echo two\n",
+	})
+	if result.IsError {
+		t.Fatalf("patch failed: %s", result.ForLLM)
+	}
+	data, _ := os.ReadFile(filepath.Join(root, "run.sh"))
+	if string(data) != "#!/bin/sh\necho two\n" {
+		t.Errorf("patched file = %q", data)
+	}
+
+	result = tools["project_write"].Execute(ctx, map[string]any{"path": "src/new.txt", "content": "hello"})
+	if result.IsError {
+		t.Fatalf("write failed: %s", result.ForLLM)
+	}
+	result = tools["project_list"].Execute(ctx, map[string]any{"path": "src"})
+	if !strings.Contains(result.ForLLM, "FILE: new.txt") {
+		t.Errorf("list = %s", result.ForLLM)
+	}
+
+	for _, path := range []string{"../" + filepath.Base(outside) + "/secret.txt", "escape/secret.txt"} {
+		if result := tools["project_read"].Execute(ctx, map[string]any{"path": path}); !result.IsError {
+			t.Errorf("read %s outside the project: %s", path, result.ForLLM)
+		}
+	}
+}
+
+func TestProjectTools_ReadOnly(t *testing.T) {
+	tools := projectToolsByName(config.ProjectToolsConfig{Root: t.TempDir(), ReadOnly: true})
+	if _, ok := tools["project_write"]; ok || len(tools) != 2 {
+		t.Errorf("read-only tools = %v", tools)
+	}
+}
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
@@ -10,6 +11,8 @@ import (
 
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tracing"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 type ToolEntry struct {
@@ -19,9 +22,11 @@ type ToolEntry struct {
 }
 
 type ToolRegistry struct {
-	tools   map[string]*ToolEntry
-	mu      sync.RWMutex
-	version atomic.Uint64 // incremented on Register/RegisterHidden for cache invalidation
+	tools    map[string]*ToolEntry
+	mu       sync.RWMutex
+	version  atomic.Uint64 // incremented on Register/RegisterHidden for cache invalidation
+	timeout  time.Duration
+	timeouts map[string]time.Duration
 }
 
 func NewToolRegistry() *ToolRegistry {
@@ -30,6 +35,24 @@ func NewToolRegistry() *ToolRegistry {
 	}
 }
 
+// SetTimeouts bounds how long one tool call may run. def applies to every
+// tool and perTool overrides it by tool name; zero means no limit.
+func (r *ToolRegistry) SetTimeouts(def time.Duration, perTool map[string]time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeout = def
+	r.timeouts = perTool
+}
+
+func (r *ToolRegistry) timeoutFor(name string) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if d, ok := r.timeouts[name]; ok {
+		return d
+	}
+	return r.timeout
+}
+
 func (r *ToolRegistry) Register(tool Tool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -165,7 +188,7 @@ func (r *ToolRegistry) ExecuteWithContext(
 	channel, chatID string,
 	asyncCallback AsyncCallback,
 ) *ToolResult {
-	logger.InfoCF("tool", "Tool execution started",
+	logger.InfoCtx(ctx, "tool", "Tool execution started",
 		map[string]any{
 			"tool": name,
 			"args": args,
@@ -173,12 +196,21 @@ func (r *ToolRegistry) ExecuteWithContext(
 
 	tool, ok := r.Get(name)
 	if !ok {
-		logger.ErrorCF("tool", "Tool not found",
+		logger.ErrorCtx(ctx, "tool", "Tool not found",
 			map[string]any{
 				"tool": name,
 			})
 		return ErrorResult(fmt.Sprintf("tool %q not found", name)).WithError(fmt.Errorf("tool not found"))
 	}
+	if !availableOn(tool, channel) {
+		logger.WarnCtx(ctx, "tool", "Tool not available on channel",
+			map[string]any{
+				"tool":    name,
+				"channel": channel,
+			})
+		return ErrorResult(fmt.Sprintf("tool %q is not available on %s", name, channel)).
+			WithError(fmt.Errorf("tool not available on channel"))
+	}
 
 	// Inject channel/chatID into ctx so tools read them via ToolChannel(ctx)/ToolChatID(ctx).
 	// Always inject — tools validate what they require.
@@ -186,35 +218,43 @@ func (r *ToolRegistry) ExecuteWithContext(
 
 	// If tool implements AsyncExecutor and callback is provided, use ExecuteAsync.
 	// The callback is a call parameter, not mutable state on the tool instance.
-	var result *ToolResult
-	start := time.Now()
-	if asyncExec, ok := tool.(AsyncExecutor); ok && asyncCallback != nil {
-		logger.DebugCF("tool", "Executing async tool via ExecuteAsync",
-			map[string]any{
-				"tool": name,
-			})
-		result = asyncExec.ExecuteAsync(ctx, args, asyncCallback)
-	} else {
-		result = tool.Execute(ctx, args)
+	call := func(ctx context.Context) *ToolResult {
+		if asyncExec, ok := tool.(AsyncExecutor); ok && asyncCallback != nil {
+			logger.DebugCtx(ctx, "tool", "Executing async tool via ExecuteAsync",
+				map[string]any{
+					"tool": name,
+				})
+			return asyncExec.ExecuteAsync(ctx, args, asyncCallback)
+		}
+		return tool.Execute(ctx, args)
 	}
+	ctx, span := tracing.Start(ctx, "tool "+name, tracing.KindInternal, map[string]any{"tool": name})
+	defer span.End()
+	start := time.Now()
+	result := callWithTimeout(ctx, name, r.timeoutFor(name), call)
 	duration := time.Since(start)
+	if result.IsError {
+		span.RecordError(errors.New(utils.Truncate(result.ForLLM, 200)))
+	} else if result.Async {
+		span.SetAttributes(map[string]any{"async": true})
+	}
 
 	// Log based on result type
 	if result.IsError {
-		logger.ErrorCF("tool", "Tool execution failed",
+		logger.ErrorCtx(ctx, "tool", "Tool execution failed",
 			map[string]any{
 				"tool":     name,
 				"duration": duration.Milliseconds(),
 				"error":    result.ForLLM,
 			})
 	} else if result.Async {
-		logger.InfoCF("tool", "Tool started (async)",
+		logger.InfoCtx(ctx, "tool", "Tool started (async)",
 			map[string]any{
 				"tool":     name,
 				"duration": duration.Milliseconds(),
 			})
 	} else {
-		logger.InfoCF("tool", "Tool execution completed",
+		logger.InfoCtx(ctx, "tool", "Tool execution completed",
 			map[string]any{
 				"tool":          name,
 				"duration_ms":   duration.Milliseconds(),
@@ -225,6 +265,34 @@ func (r *ToolRegistry) ExecuteWithContext(
 	return result
 }
 
+// callWithTimeout runs call, giving up once timeout has passed. A tool that
+// ignores its context keeps running in the background, but the turn moves
+// on with a timeout error.
+func callWithTimeout(
+	ctx context.Context,
+	name string,
+	timeout time.Duration,
+	call func(ctx context.Context) *ToolResult,
+) *ToolResult {
+	if timeout <= 0 {
+		return call(ctx)
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan *ToolResult, 1)
+	go func() { done <- call(callCtx) }()
+	select {
+	case result := <-done:
+		return result
+	case <-callCtx.Done():
+		if ctx.Err() != nil {
+			return ErrorResult(fmt.Sprintf("tool %q was cancelled", name)).WithError(ctx.Err())
+		}
+		return ErrorResult(fmt.Sprintf("tool %q timed out after %s", name, timeout)).
+			WithError(callCtx.Err())
+	}
+}
+
 // sortedToolNames returns tool names in sorted order for deterministic iteration.
 // This is critical for KV cache stability: non-deterministic map iteration would
 // produce different system prompts and tool definitions on each call, invalidating
@@ -259,6 +327,12 @@ func (r *ToolRegistry) GetDefinitions() []map[string]any {
 // ToProviderDefs converts tool definitions to provider-compatible format.
 // This is the format expected by LLM provider APIs.
 func (r *ToolRegistry) ToProviderDefs() []providers.ToolDefinition {
+	return r.ToProviderDefsFor("")
+}
+
+// ToProviderDefsFor is ToProviderDefs without the tools that are not
+// available on channel. An empty channel keeps every tool.
+func (r *ToolRegistry) ToProviderDefsFor(channel string) []providers.ToolDefinition {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -270,6 +344,9 @@ func (r *ToolRegistry) ToProviderDefs() []providers.ToolDefinition {
 		if !entry.IsCore && entry.TTL <= 0 {
 			continue
 		}
+		if !availableOn(entry.Tool, channel) {
+			continue
+		}
 
 		schema := ToolToSchema(entry.Tool)
 
@@ -295,6 +372,13 @@ func (r *ToolRegistry) ToProviderDefs() []providers.ToolDefinition {
 	return definitions
 }
 
+// availableOn reports whether tool may be used on channel. Tools without a
+// channel restriction, and calls without a channel, are always allowed.
+func availableOn(tool Tool, channel string) bool {
+	cr, ok := tool.(ChannelRestricted)
+	return !ok || channel == "" || cr.AvailableOn(channel)
+}
+
 // List returns a list of all registered tool names.
 func (r *ToolRegistry) List() []string {
 	r.mu.RLock()
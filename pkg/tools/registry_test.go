@@ -5,6 +5,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
@@ -335,6 +336,45 @@ func TestToolToSchema(t *testing.T) {
 	}
 }
 
+type mockChannelTool struct {
+	mockRegistryTool
+	channelAllowList
+}
+
+func TestToolRegistry_ChannelRestricted(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(newMockTool("everywhere", "unrestricted"))
+	r.Register(&mockChannelTool{
+		mockRegistryTool: *newMockTool("weather", "restricted"),
+		channelAllowList: channelAllowList{"telegram"},
+	})
+
+	names := func(defs []providers.ToolDefinition) []string {
+		var out []string
+		for _, d := range defs {
+			out = append(out, d.Function.Name)
+		}
+		return out
+	}
+	if got := names(r.ToProviderDefsFor("discord")); len(got) != 1 || got[0] != "everywhere" {
+		t.Errorf("discord defs = %v, want only the unrestricted tool", got)
+	}
+	if got := names(r.ToProviderDefsFor("telegram")); len(got) != 2 {
+		t.Errorf("telegram defs = %v, want both tools", got)
+	}
+	if got := names(r.ToProviderDefs()); len(got) != 2 {
+		t.Errorf("unfiltered defs = %v, want both tools", got)
+	}
+
+	ctx := context.Background()
+	if res := r.ExecuteWithContext(ctx, "weather", nil, "discord", "c1", nil); !res.IsError {
+		t.Error("expected the restricted tool to be refused on discord")
+	}
+	if res := r.ExecuteWithContext(ctx, "weather", nil, "telegram", "c1", nil); res.IsError {
+		t.Errorf("restricted tool on telegram failed: %s", res.ForLLM)
+	}
+}
+
 func TestToolRegistry_ConcurrentAccess(t *testing.T) {
 	r := NewToolRegistry()
 	var wg sync.WaitGroup
@@ -358,3 +398,34 @@ func TestToolRegistry_ConcurrentAccess(t *testing.T) {
 		t.Error("expected tools to be registered after concurrent access")
 	}
 }
+
+// blockingTool never returns on its own, even when its context is done.
+type blockingTool struct {
+	mockRegistryTool
+}
+
+func (m *blockingTool) Execute(_ context.Context, _ map[string]any) *ToolResult {
+	select {}
+}
+
+func TestToolRegistry_Execute_Timeout(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(&blockingTool{mockRegistryTool: *newMockTool("slow", "never finishes")})
+	r.Register(newMockTool("fast", "finishes at once"))
+	r.SetTimeouts(time.Hour, map[string]time.Duration{"slow": 20 * time.Millisecond})
+
+	result := r.Execute(context.Background(), "slow", nil)
+	if !result.IsError || !strings.Contains(result.ForLLM, "timed out after 20ms") {
+		t.Errorf("expected a timeout error, got %+v", result)
+	}
+	if result := r.Execute(context.Background(), "fast", nil); result.IsError {
+		t.Errorf("expected success within the default timeout, got %s", result.ForLLM)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.SetTimeouts(time.Hour, nil)
+	go cancel()
+	if result := r.Execute(ctx, "slow", nil); !result.IsError || !strings.Contains(result.ForLLM, "cancelled") {
+		t.Errorf("expected a cancellation error, got %+v", result)
+	}
+}
@@ -0,0 +1,296 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+)
+
+const (
+	sandboxModeRlimit = "rlimit"
+	sandboxModeDocker = "docker"
+
+	// sandboxExitSetupFailed is the exit status of the rlimit wrapper when a
+	// limit cannot be applied; the command is not run then.
+	sandboxExitSetupFailed = 126
+)
+
+// SandboxTool runs shell commands under resource limits. In rlimit mode the
+// command is a subprocess of the gateway with CPU, memory, process, and file
+// size limits and a scrubbed environment, in a scratch directory under the
+// workspace. In docker mode it runs in a throwaway container with no network,
+// a read-only root, and the scratch directory mounted at /work. Only the
+// users in the allowlist can trigger it; an empty allowlist allows nobody.
+type SandboxTool struct {
+	mode      string
+	dir       string
+	allowFrom []string
+	timeout   time.Duration
+	maxOutput int
+	cpu       int
+	memoryMB  int
+	procs     int
+	fileMB    int
+	image     string
+	network   bool
+	docker    string // docker binary, replaced by tests
+}
+
+func NewSandboxTool(workspace string, cfg config.SandboxToolConfig) *SandboxTool {
+	mode := strings.ToLower(strings.TrimSpace(cfg.Mode))
+	if mode == "" {
+		mode = sandboxModeRlimit
+	}
+	timeout := 30 * time.Second
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	maxOutput := cfg.MaxOutputBytes
+	if maxOutput <= 0 {
+		maxOutput = 16 * 1024
+	}
+	image := cfg.Image
+	if image == "" {
+		image = "alpine:3"
+	}
+	return &SandboxTool{
+		mode:      mode,
+		dir:       filepath.Join(workspace, "sandbox"),
+		allowFrom: cfg.AllowFrom,
+		timeout:   timeout,
+		maxOutput: maxOutput,
+		cpu:       cfg.CPUSeconds,
+		memoryMB:  cfg.MemoryMB,
+		procs:     cfg.MaxProcesses,
+		fileMB:    cfg.MaxFileMB,
+		image:     image,
+		network:   cfg.Network,
+		docker:    "docker",
+	}
+}
+
+func (t *SandboxTool) Name() string {
+	return "sandbox"
+}
+
+func (t *SandboxTool) Description() string {
+	desc := "Run a shell command in a restricted sandbox and return its exit code, stdout, and stderr. " +
+		"Files persist between calls in the sandbox's working directory."
+	if t.mode == sandboxModeDocker {
+		desc += fmt.Sprintf(" Commands run with sh in a %s container", t.image)
+		if !t.network {
+			desc += " without network access"
+		}
+		desc += "."
+	}
+	return desc
+}
+
+func (t *SandboxTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"command": map[string]any{
+				"type":        "string",
+				"description": "The shell command to run",
+			},
+		},
+		"required": []string{"command"},
+	}
+}
+
+func (t *SandboxTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	command, _ := args["command"].(string)
+	if strings.TrimSpace(command) == "" {
+		return ErrorResult("command is required")
+	}
+	if !t.allowed(ctx) {
+		return ErrorResult("the sandbox is not available to this user")
+	}
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create the sandbox directory: %v", err))
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	var container string
+	switch t.mode {
+	case sandboxModeRlimit:
+		if runtime.GOOS == "windows" {
+			return ErrorResult("the rlimit sandbox needs a Unix shell; use docker mode on Windows")
+		}
+		cmd = exec.CommandContext(cmdCtx, "sh", "-c", t.rlimitScript(), "sandbox", command)
+		cmd.Dir = t.dir
+		cmd.Env = []string{
+			"PATH=" + os.Getenv("PATH"),
+			"HOME=" + t.dir,
+			"TMPDIR=" + t.dir,
+			"LANG=C.UTF-8",
+		}
+	case sandboxModeDocker:
+		container = "picoclaw-sandbox-" + randomHex(6)
+		cmd = exec.CommandContext(cmdCtx, t.docker, t.dockerArgs(container, command)...)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown sandbox mode %q", t.mode))
+	}
+
+	stdout := &cappedBuffer{limit: t.maxOutput}
+	stderr := &cappedBuffer{limit: t.maxOutput}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	prepareCommandForTermination(cmd)
+	cmd.Cancel = func() error {
+		if container != "" {
+			// Killing the docker client leaves the container running.
+			_ = exec.Command(t.docker, "kill", container).Run()
+		}
+		return terminateProcessTree(cmd)
+	}
+	cmd.WaitDelay = 2 * time.Second
+
+	err := cmd.Run()
+	if errors.Is(cmdCtx.Err(), context.DeadlineExceeded) {
+		return ErrorResult(fmt.Sprintf("command timed out after %v", t.timeout))
+	}
+	exitCode := 0
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+	case err != nil:
+		return ErrorResult(fmt.Sprintf("failed to start the sandbox: %v", err))
+	}
+	if t.mode == sandboxModeRlimit && exitCode == sandboxExitSetupFailed && stdout.Len() == 0 {
+		if msg := strings.TrimSpace(stderr.String()); strings.Contains(msg, "ulimit") {
+			return ErrorResult("the sandbox limits could not be applied: " + msg)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Exit code: %d\n", exitCode)
+	for _, stream := range []struct {
+		name string
+		buf  *cappedBuffer
+	}{{"STDOUT", stdout}, {"STDERR", stderr}} {
+		if stream.buf.Len() == 0 && stream.buf.dropped == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n%s", stream.name, stream.buf.String())
+		if stream.buf.dropped > 0 {
+			fmt.Fprintf(&b, "\n... (truncated, %d more bytes)", stream.buf.dropped)
+		}
+		b.WriteString("\n")
+	}
+	result := NewToolResult(strings.TrimRight(b.String(), "\n"))
+	result.IsError = exitCode != 0
+	return result
+}
+
+// allowed reports whether the sender of ctx may use the sandbox. Entries use
+// the formats of allow_from.
+func (t *SandboxTool) allowed(ctx context.Context) bool {
+	senderID := ToolSenderID(ctx)
+	if senderID == "" {
+		return false
+	}
+	sender := ToolSender(ctx)
+	for _, entry := range t.allowFrom {
+		if identity.MatchAllowed(sender, entry) || strings.TrimSpace(entry) == senderID {
+			return true
+		}
+	}
+	return false
+}
+
+// rlimitScript sets the limits with ulimit and then runs the command, passed
+// as $1. A limit that cannot be set stops the script, so the command never
+// runs with fewer limits than configured. File sizes are in 512-byte blocks,
+// the unit of POSIX shells; -p is dash's spelling of bash's -u.
+func (t *SandboxTool) rlimitScript() string {
+	var limits []string
+	if t.cpu > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -t %d", t.cpu))
+	}
+	if t.memoryMB > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -v %d", t.memoryMB*1024))
+	}
+	if t.fileMB > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -f %d", t.fileMB*2048))
+	}
+	if t.procs > 0 {
+		limits = append(limits, fmt.Sprintf("{ ulimit -u %d 2>/dev/null || ulimit -p %d; }", t.procs, t.procs))
+	}
+	script := `exec sh -c "$1"`
+	if len(limits) > 0 {
+		script = fmt.Sprintf("%s || exit %d\n%s", strings.Join(limits, " && "), sandboxExitSetupFailed, script)
+	}
+	return script
+}
+
+// dockerArgs returns the arguments of the docker run that executes command
+// in a container named name.
+func (t *SandboxTool) dockerArgs(name, command string) []string {
+	args := []string{
+		"run", "--rm", "-i", "--name", name,
+		"--read-only", "--tmpfs", "/tmp:size=64m",
+		"--cap-drop", "ALL", "--security-opt", "no-new-privileges",
+		"-v", t.dir + ":/work", "-w", "/work",
+	}
+	if !t.network {
+		args = append(args, "--network", "none")
+	}
+	if uid, gid := os.Getuid(), os.Getgid(); uid >= 0 {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", uid, gid))
+	}
+	if t.memoryMB > 0 {
+		mem := fmt.Sprintf("%dm", t.memoryMB)
+		args = append(args, "--memory", mem, "--memory-swap", mem)
+	}
+	if t.procs > 0 {
+		args = append(args, "--pids-limit", fmt.Sprint(t.procs))
+	}
+	if t.cpu > 0 {
+		args = append(args, "--ulimit", fmt.Sprintf("cpu=%d:%d", t.cpu, t.cpu))
+	}
+	if t.fileMB > 0 {
+		size := t.fileMB << 20
+		args = append(args, "--ulimit", fmt.Sprintf("fsize=%d:%d", size, size))
+	}
+	return append(args, t.image, "sh", "-c", command)
+}
+
+// cappedBuffer keeps the first limit bytes written to it and counts the
+// rest, so a chatty command cannot fill the gateway's memory.
+type cappedBuffer struct {
+	strings.Builder
+	limit   int
+	dropped int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.limit - b.Len(); room < len(p) {
+		b.dropped += len(p) - max(room, 0)
+		p = p[:max(room, 0)]
+	}
+	b.Builder.Write(p)
+	return n, nil
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
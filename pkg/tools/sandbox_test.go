@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func sandboxContext(channel, sender string) context.Context {
+	return WithToolSender(WithToolContext(context.Background(), channel, "chat"), sender)
+}
+
+func TestSandboxTool_AllowList(t *testing.T) {
+	tool := NewSandboxTool(t.TempDir(), config.SandboxToolConfig{AllowFrom: []string{"telegram:42", "7", "@alice"}})
+	for _, tc := range []struct {
+		channel, sender string
+		want            bool
+	}{
+		{"telegram", "42", true},
+		{"Telegram", "42", true},
+		{"discord", "42", false},
+		{"discord", "7", true},
+		{"telegram", "", false},
+	} {
+		if got := tool.allowed(sandboxContext(tc.channel, tc.sender)); got != tc.want {
+			t.Errorf("allowed(%q, %q) = %v, want %v", tc.channel, tc.sender, got, tc.want)
+		}
+	}
+	alice := bus.SenderInfo{Platform: "slack", PlatformID: "U1", CanonicalID: "slack:U1", Username: "alice"}
+	if !tool.allowed(WithToolSenderInfo(sandboxContext("slack", "U1"), alice)) {
+		t.Error("@alice entry should match the sender's username")
+	}
+
+	closed := NewSandboxTool(t.TempDir(), config.SandboxToolConfig{})
+	result := closed.Execute(sandboxContext("telegram", "42"), map[string]any{"command": "echo hi"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not available") {
+		t.Errorf("empty allowlist result = %+v", result)
+	}
+}
+
+func TestSandboxTool_RlimitRuns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("rlimit mode needs a Unix shell")
+	}
+	t.Setenv("PICOCLAW_SANDBOX_SECRET", "hunter2")
+	workspace := t.TempDir()
+	tool := NewSandboxTool(workspace, config.SandboxToolConfig{
+		AllowFrom:      []string{"42"},
+		MaxOutputBytes: 64,
+		CPUSeconds:     5,
+		MemoryMB:       256,
+		MaxProcesses:   64,
+		MaxFileMB:      1,
+	})
+	ctx := sandboxContext("telegram", "42")
+
+	result := tool.Execute(ctx, map[string]any{
+		"command": `echo "secret=$PICOCLAW_SANDBOX_SECRET"; echo oops >&2; echo kept > out.txt; exit 3`,
+	})
+	if !result.IsError {
+		t.Errorf("non-zero exit is not an error: %s", result.ForLLM)
+	}
+	for _, want := range []string{"Exit code: 3", "STDOUT:\nsecret=\n", "STDERR:\noops"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("result missing %q:\n%s", want, result.ForLLM)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(workspace, "sandbox", "out.txt")); err != nil || string(data) != "kept\n" {
+		t.Errorf("sandbox file = %q, %v", data, err)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"command": "head -c 1000 /dev/zero | tr '\\0' x"})
+	if result.IsError || !strings.Contains(result.ForLLM, strings.Repeat("x", 64)+"\n... (truncated, 936 more bytes)") {
+		t.Errorf("output not capped:\n%s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"command": "head -c 2000000 /dev/zero > big"})
+	if !result.IsError {
+		t.Errorf("file over max_file_mb was written:\n%s", result.ForLLM)
+	}
+}
+
+func TestSandboxTool_Timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("rlimit mode needs a Unix shell")
+	}
+	tool := NewSandboxTool(t.TempDir(), config.SandboxToolConfig{AllowFrom: []string{"42"}, TimeoutSeconds: 1})
+	result := tool.Execute(sandboxContext("cli", "42"), map[string]any{"command": "sleep 10"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "timed out") {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestSandboxTool_DockerArgs(t *testing.T) {
+	tool := NewSandboxTool("/ws", config.SandboxToolConfig{
+		Mode:         "docker",
+		Image:        "python:3-slim",
+		MemoryMB:     256,
+		MaxProcesses: 32,
+		CPUSeconds:   10,
+	})
+	args := tool.dockerArgs("box", "python3 -c 'print(1)'")
+	joined := strings.Join(args, " ")
+	for _, want := range []string{
+		"run --rm -i --name box",
+		"--read-only",
+		"--cap-drop ALL",
+		"--network none",
+		"--memory 256m --memory-swap 256m",
+		"--pids-limit 32",
+		"--ulimit cpu=10:10",
+		"-v " + filepath.Join("/ws", "sandbox") + ":/work",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("docker args missing %q: %s", want, joined)
+		}
+	}
+	if tail := args[len(args)-4:]; !slices.Equal(tail, []string{"python:3-slim", "sh", "-c", "python3 -c 'print(1)'"}) {
+		t.Errorf("docker args end with %q", tail)
+	}
+
+	tool.network = true
+	if slices.Contains(tool.dockerArgs("box", "true"), "none") {
+		t.Error("network enabled but docker args still disable it")
+	}
+}
@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// timeInputLayouts are the formats accepted for the time to convert, besides
+// RFC 3339. Input is lowercased before matching.
+var timeInputLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02t15:04",
+	"2006-01-02 3:04pm",
+	"15:04",
+	"3:04pm",
+	"3pm",
+}
+
+// TimeTool tells the current time in any time zone and converts times
+// between zones. It needs no network access.
+type TimeTool struct {
+	channelAllowList
+	defaultZone string
+	now         func() time.Time
+}
+
+func NewTimeTool(cfg config.TimeToolConfig) *TimeTool {
+	return &TimeTool{
+		channelAllowList: cfg.Channels,
+		defaultZone:      cfg.Timezone,
+		now:              time.Now,
+	}
+}
+
+func (t *TimeTool) Name() string {
+	return "time"
+}
+
+func (t *TimeTool) Description() string {
+	return "Get the current time in one or more time zones, or convert a time from one zone to others. " +
+		"Time zones are IANA names such as America/New_York, Europe/Berlin, or Asia/Tokyo; UTC is also accepted."
+}
+
+func (t *TimeTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"now", "convert"},
+				"description": "'now' for the current time, 'convert' to translate a time between zones",
+			},
+			"timezones": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Zones to show the time in (now) or convert to (convert)",
+			},
+			"time": map[string]any{
+				"type":        "string",
+				"description": "Time to convert such as '2025-03-14 15:30', '15:30', or '3pm' (convert); a bare time means today",
+			},
+			"from_timezone": map[string]any{
+				"type":        "string",
+				"description": "Zone the time is in (convert)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *TimeTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	zones, err := t.zones(args["timezones"])
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "now":
+		now := t.now()
+		var b strings.Builder
+		for _, loc := range zones {
+			fmt.Fprintf(&b, "%s: %s\n", loc, formatZoneTime(now.In(loc)))
+		}
+		return SilentResult(b.String())
+	case "convert":
+		input, _ := args["time"].(string)
+		fromName, _ := args["from_timezone"].(string)
+		from, err := t.location(fromName)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		at, err := parseTimeIn(strings.TrimSpace(input), from, t.now())
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s in %s is:\n", formatZoneTime(at), from)
+		for _, loc := range zones {
+			fmt.Fprintf(&b, "- %s: %s\n", loc, formatZoneTime(at.In(loc)))
+		}
+		return SilentResult(b.String())
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q (expected now or convert)", action))
+	}
+}
+
+// zones resolves the requested zone names, defaulting to the configured zone.
+func (t *TimeTool) zones(raw any) ([]*time.Location, error) {
+	var names []string
+	if list, ok := raw.([]any); ok {
+		for _, v := range list {
+			if s, ok := v.(string); ok && strings.TrimSpace(s) != "" {
+				names = append(names, s)
+			}
+		}
+	}
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	zones := make([]*time.Location, 0, len(names))
+	for _, name := range names {
+		loc, err := t.location(name)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, loc)
+	}
+	return zones, nil
+}
+
+func (t *TimeTool) location(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = t.defaultZone
+	}
+	if name == "" {
+		return time.Local, nil
+	}
+	if strings.EqualFold(name, "utc") || strings.EqualFold(name, "gmt") {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q; use an IANA name such as Europe/Paris", name)
+	}
+	return loc, nil
+}
+
+// parseTimeIn parses input as a time in loc. Inputs without a date refer to
+// the current day in loc.
+func parseTimeIn(input string, loc *time.Location, now time.Time) (time.Time, error) {
+	if input == "" {
+		return time.Time{}, fmt.Errorf("time is required")
+	}
+	if parsed, err := time.Parse(time.RFC3339, input); err == nil {
+		return parsed, nil
+	}
+	value := strings.ToLower(input)
+	value = strings.NewReplacer(" am", "am", " pm", "pm").Replace(value)
+	for _, layout := range timeInputLayouts {
+		parsed, err := time.ParseInLocation(layout, value, loc)
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(layout, "2006") {
+			today := now.In(loc)
+			parsed = time.Date(today.Year(), today.Month(), today.Day(),
+				parsed.Hour(), parsed.Minute(), 0, 0, loc)
+		}
+		return parsed, nil
+	}
+	return time.Time{}, fmt.Errorf("could not parse time %q; use a form like '2025-03-14 15:30' or '15:30'", input)
+}
+
+func formatZoneTime(t time.Time) string {
+	return t.Format("Mon 2006-01-02 15:04 MST (UTC-07:00)")
+}
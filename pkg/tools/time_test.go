@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestTimeTool_Now(t *testing.T) {
+	tool := NewTimeTool(config.TimeToolConfig{Timezone: "UTC"})
+	tool.now = func() time.Time { return time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC) }
+
+	result := tool.Execute(context.Background(), map[string]any{"action": "now"})
+	if result.IsError || !strings.Contains(result.ForLLM, "UTC: Fri 2025-03-14 12:00") {
+		t.Fatalf("now without zones = %q, want the configured zone", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"action":    "now",
+		"timezones": []any{"Asia/Tokyo"},
+	})
+	if !strings.Contains(result.ForLLM, "Asia/Tokyo: Fri 2025-03-14 21:00 JST (UTC+09:00)") {
+		t.Fatalf("now in Tokyo = %q", result.ForLLM)
+	}
+}
+
+func TestTimeTool_Convert(t *testing.T) {
+	tool := NewTimeTool(config.TimeToolConfig{})
+	tool.now = func() time.Time { return time.Date(2025, 7, 1, 8, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"2025-01-10 09:30", "Europe/Berlin: Fri 2025-01-10 15:30 CET"},
+		{"3 PM", "Europe/Berlin: Tue 2025-07-01 21:00 CEST"},
+		{"2025-01-10T09:30:00Z", "Europe/Berlin: Fri 2025-01-10 10:30 CET"},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(context.Background(), map[string]any{
+			"action":        "convert",
+			"time":          tt.input,
+			"from_timezone": "America/New_York",
+			"timezones":     []any{"Europe/Berlin"},
+		})
+		if result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("convert %q = %q, want %q", tt.input, result.ForLLM, tt.want)
+		}
+	}
+}
+
+func TestTimeTool_UnknownZone(t *testing.T) {
+	tool := NewTimeTool(config.TimeToolConfig{})
+	result := tool.Execute(context.Background(), map[string]any{
+		"action":    "now",
+		"timezones": []any{"Mars/Olympus"},
+	})
+	if !result.IsError || !strings.Contains(result.ForLLM, "unknown time zone") {
+		t.Fatalf("result = %q, want unknown time zone error", result.ForLLM)
+	}
+}
@@ -0,0 +1,274 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	utilityToolTimeout = 15 * time.Second
+	weatherMaxDays     = 7
+
+	openMeteoGeocodeURL  = "https://geocoding-api.open-meteo.com/v1/search"
+	openMeteoForecastURL = "https://api.open-meteo.com/v1/forecast"
+)
+
+// WeatherTool reports current conditions and a daily forecast from
+// Open-Meteo, which needs no API key.
+type WeatherTool struct {
+	channelAllowList
+	imperial    bool
+	geocodeURL  string
+	forecastURL string
+	client      *http.Client
+}
+
+func NewWeatherTool(cfg config.WeatherToolConfig) *WeatherTool {
+	return &WeatherTool{
+		channelAllowList: cfg.Channels,
+		imperial:         strings.EqualFold(cfg.Units, "imperial"),
+		geocodeURL:       openMeteoGeocodeURL,
+		forecastURL:      openMeteoForecastURL,
+		client:           &http.Client{Timeout: utilityToolTimeout},
+	}
+}
+
+func (t *WeatherTool) Name() string {
+	return "weather"
+}
+
+func (t *WeatherTool) Description() string {
+	return "Get the current weather and a daily forecast for a place (city name, optionally with country)."
+}
+
+func (t *WeatherTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"location": map[string]any{
+				"type":        "string",
+				"description": "Place name such as 'Berlin' or 'Portland, Oregon'",
+			},
+			"days": map[string]any{
+				"type":        "integer",
+				"description": "Forecast days including today (default 3)",
+				"minimum":     1.0,
+				"maximum":     float64(weatherMaxDays),
+			},
+		},
+		"required": []string{"location"},
+	}
+}
+
+type geoPlace struct {
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Country   string  `json:"country"`
+	Admin1    string  `json:"admin1"`
+}
+
+type forecastResponse struct {
+	Timezone     string            `json:"timezone"`
+	Current      map[string]any    `json:"current"`
+	CurrentUnits map[string]string `json:"current_units"`
+	Daily        forecastDaily     `json:"daily"`
+	DailyUnits   map[string]string `json:"daily_units"`
+}
+
+type forecastDaily struct {
+	Time          []string  `json:"time"`
+	WeatherCode   []int     `json:"weather_code"`
+	TempMax       []float64 `json:"temperature_2m_max"`
+	TempMin       []float64 `json:"temperature_2m_min"`
+	Precipitation []float64 `json:"precipitation_probability_max"`
+}
+
+func (t *WeatherTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	location, _ := args["location"].(string)
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return ErrorResult("location is required")
+	}
+	days := 3
+	if n, ok := args["days"].(float64); ok && n >= 1 {
+		days = min(int(n), weatherMaxDays)
+	}
+
+	place, err := t.geocode(ctx, location)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to look up %q: %v", location, err)).WithError(err)
+	}
+	if place == nil {
+		return ErrorResult(fmt.Sprintf("no place found matching %q", location))
+	}
+
+	q := url.Values{
+		"latitude":      {strconv.FormatFloat(place.Latitude, 'f', 4, 64)},
+		"longitude":     {strconv.FormatFloat(place.Longitude, 'f', 4, 64)},
+		"current":       {"temperature_2m,apparent_temperature,relative_humidity_2m,weather_code,wind_speed_10m"},
+		"daily":         {"weather_code,temperature_2m_max,temperature_2m_min,precipitation_probability_max"},
+		"timezone":      {"auto"},
+		"forecast_days": {strconv.Itoa(days)},
+	}
+	if t.imperial {
+		q.Set("temperature_unit", "fahrenheit")
+		q.Set("wind_speed_unit", "mph")
+	}
+	var fc forecastResponse
+	if err := fetchJSON(ctx, t.client, t.forecastURL+"?"+q.Encode(), &fc); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to get the forecast: %v", err)).WithError(err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Weather for %s", placeLabel(place))
+	if fc.Timezone != "" {
+		fmt.Fprintf(&b, " (%s)", fc.Timezone)
+	}
+	b.WriteString("\n")
+	if len(fc.Current) > 0 {
+		fmt.Fprintf(&b, "Now: %s, %s (feels like %s), humidity %s, wind %s\n",
+			weatherDescription(int(weatherNumber(fc.Current["weather_code"]))),
+			withUnit(fc.Current, fc.CurrentUnits, "temperature_2m"),
+			withUnit(fc.Current, fc.CurrentUnits, "apparent_temperature"),
+			withUnit(fc.Current, fc.CurrentUnits, "relative_humidity_2m"),
+			withUnit(fc.Current, fc.CurrentUnits, "wind_speed_10m"))
+	}
+	tempUnit := fc.DailyUnits["temperature_2m_max"]
+	for i, day := range fc.Daily.Time {
+		fmt.Fprintf(&b, "%s: %s", day, weatherDescription(codeAt(fc.Daily.WeatherCode, i)))
+		if i < len(fc.Daily.TempMin) && i < len(fc.Daily.TempMax) {
+			fmt.Fprintf(&b, ", %g–%g%s", fc.Daily.TempMin[i], fc.Daily.TempMax[i], tempUnit)
+		}
+		if i < len(fc.Daily.Precipitation) {
+			fmt.Fprintf(&b, ", %g%% chance of precipitation", fc.Daily.Precipitation[i])
+		}
+		b.WriteString("\n")
+	}
+	return SilentResult(b.String())
+}
+
+func (t *WeatherTool) geocode(ctx context.Context, location string) (*geoPlace, error) {
+	// The geocoder matches names only, so "Portland, Oregon" is searched as
+	// "Portland" and the rest is used to pick among the results.
+	name, qualifier, _ := strings.Cut(location, ",")
+	q := url.Values{
+		"name":     {strings.TrimSpace(name)},
+		"count":    {"10"},
+		"language": {"en"},
+		"format":   {"json"},
+	}
+	var resp struct {
+		Results []geoPlace `json:"results"`
+	}
+	if err := fetchJSON(ctx, t.client, t.geocodeURL+"?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) == 0 {
+		return nil, nil
+	}
+	qualifier = strings.ToLower(strings.TrimSpace(qualifier))
+	if qualifier != "" {
+		for i, p := range resp.Results {
+			if strings.Contains(strings.ToLower(p.Admin1+" "+p.Country), qualifier) {
+				return &resp.Results[i], nil
+			}
+		}
+	}
+	return &resp.Results[0], nil
+}
+
+func placeLabel(p *geoPlace) string {
+	parts := []string{p.Name}
+	if p.Admin1 != "" && p.Admin1 != p.Name {
+		parts = append(parts, p.Admin1)
+	}
+	if p.Country != "" {
+		parts = append(parts, p.Country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func withUnit(values map[string]any, units map[string]string, key string) string {
+	return fmt.Sprintf("%g%s", weatherNumber(values[key]), units[key])
+}
+
+func weatherNumber(v any) float64 {
+	f, _ := v.(float64)
+	return f
+}
+
+func codeAt(codes []int, i int) int {
+	if i < len(codes) {
+		return codes[i]
+	}
+	return -1
+}
+
+// weatherDescription translates a WMO weather interpretation code.
+func weatherDescription(code int) string {
+	switch code {
+	case 0:
+		return "clear sky"
+	case 1:
+		return "mainly clear"
+	case 2:
+		return "partly cloudy"
+	case 3:
+		return "overcast"
+	case 45, 48:
+		return "fog"
+	case 51, 53, 55:
+		return "drizzle"
+	case 56, 57:
+		return "freezing drizzle"
+	case 61, 63, 65:
+		return "rain"
+	case 66, 67:
+		return "freezing rain"
+	case 71, 73, 75, 77:
+		return "snow"
+	case 80, 81, 82:
+		return "rain showers"
+	case 85, 86:
+		return "snow showers"
+	case 95:
+		return "thunderstorm"
+	case 96, 99:
+		return "thunderstorm with hail"
+	default:
+		return "unknown conditions"
+	}
+}
+
+// fetchJSON GETs rawURL and decodes the JSON response into out.
+func fetchJSON(ctx context.Context, client *http.Client, rawURL string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, utils.Truncate(strings.TrimSpace(string(data)), 300))
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestWeatherTool_Forecast(t *testing.T) {
+	var forecastQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/search":
+			if got := r.URL.Query().Get("name"); got != "Portland" {
+				t.Errorf("geocode name = %q, want Portland", got)
+			}
+			w.Write([]byte(`{"results":[
+				{"name":"Portland","latitude":43.66,"longitude":-70.25,"country":"United States","admin1":"Maine"},
+				{"name":"Portland","latitude":45.52,"longitude":-122.68,"country":"United States","admin1":"Oregon"}]}`))
+		case "/forecast":
+			forecastQuery = r.URL.RawQuery
+			w.Write([]byte(`{"timezone":"America/Los_Angeles",
+				"current":{"temperature_2m":55.4,"apparent_temperature":53.1,"relative_humidity_2m":80,"weather_code":61,"wind_speed_10m":7.2},
+				"current_units":{"temperature_2m":"°F","apparent_temperature":"°F","relative_humidity_2m":"%","wind_speed_10m":"mp/h"},
+				"daily":{"time":["2025-03-14","2025-03-15"],"weather_code":[61,2],"temperature_2m_max":[57,60],
+					"temperature_2m_min":[45,44],"precipitation_probability_max":[90,10]},
+				"daily_units":{"temperature_2m_max":"°F"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tool := NewWeatherTool(config.WeatherToolConfig{Units: "imperial"})
+	tool.geocodeURL = srv.URL + "/search"
+	tool.forecastURL = srv.URL + "/forecast"
+
+	result := tool.Execute(context.Background(), map[string]any{"location": "Portland, Oregon", "days": 2.0})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	for _, want := range []string{
+		"Portland, Oregon, United States",
+		"Now: rain, 55.4°F (feels like 53.1°F)",
+		"2025-03-15: partly cloudy, 44–60°F, 10% chance",
+	} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("result missing %q:\n%s", want, result.ForLLM)
+		}
+	}
+	for _, want := range []string{"latitude=45.5200", "temperature_unit=fahrenheit", "forecast_days=2"} {
+		if !strings.Contains(forecastQuery, want) {
+			t.Errorf("forecast query %q missing %q", forecastQuery, want)
+		}
+	}
+}
+
+func TestWeatherTool_UnknownPlace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	tool := NewWeatherTool(config.WeatherToolConfig{})
+	tool.geocodeURL = srv.URL
+
+	result := tool.Execute(context.Background(), map[string]any{"location": "Nowhereville"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "no place found") {
+		t.Fatalf("result = %+v, want no place found error", result)
+	}
+}
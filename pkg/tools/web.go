@@ -469,6 +469,7 @@ func (p *PerplexitySearchProvider) Search(ctx context.Context, query string, cou
 
 type SearXNGSearchProvider struct {
 	baseURL string
+	client  *http.Client
 }
 
 func (p *SearXNGSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
@@ -481,7 +482,10 @@ func (p *SearXNGSearchProvider) Search(ctx context.Context, query string, count
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := p.client
+	if client == nil {
+		client = &http.Client{Timeout: searchTimeout}
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("request failed: %w", err)
@@ -638,11 +642,44 @@ type WebSearchToolOptions struct {
 	GLMSearchMaxResults  int
 	GLMSearchEnabled     bool
 	Proxy                string
+	// Provider names the only engine to use, in place of the priority order
+	// of the enabled ones; empty or "auto" keeps the order.
+	Provider string
+}
+
+// only returns the options with every engine but provider disabled and
+// provider enabled.
+func (o WebSearchToolOptions) only(provider string) (WebSearchToolOptions, error) {
+	engines := map[string]*bool{
+		"perplexity": &o.PerplexityEnabled,
+		"brave":      &o.BraveEnabled,
+		"searxng":    &o.SearXNGEnabled,
+		"tavily":     &o.TavilyEnabled,
+		"duckduckgo": &o.DuckDuckGoEnabled,
+		"glm_search": &o.GLMSearchEnabled,
+	}
+	if _, ok := engines[provider]; !ok {
+		return o, fmt.Errorf("unknown web search provider %q", provider)
+	}
+	for name, enabled := range engines {
+		*enabled = name == provider
+	}
+	return o, nil
 }
 
 func NewWebSearchTool(opts WebSearchToolOptions) (*WebSearchTool, error) {
 	var provider SearchProvider
 	maxResults := 5
+	explicit := strings.ToLower(strings.TrimSpace(opts.Provider))
+	if explicit == "auto" {
+		explicit = ""
+	}
+	if explicit != "" {
+		var err error
+		if opts, err = opts.only(explicit); err != nil {
+			return nil, err
+		}
+	}
 	// Priority: Perplexity > Brave > SearXNG > Tavily > DuckDuckGo > GLM Search
 	if opts.PerplexityEnabled && len(opts.PerplexityAPIKeys) > 0 {
 		client, err := utils.CreateHTTPClient(opts.Proxy, perplexityTimeout)
@@ -667,7 +704,11 @@ func NewWebSearchTool(opts WebSearchToolOptions) (*WebSearchTool, error) {
 			maxResults = opts.BraveMaxResults
 		}
 	} else if opts.SearXNGEnabled && opts.SearXNGBaseURL != "" {
-		provider = &SearXNGSearchProvider{baseURL: opts.SearXNGBaseURL}
+		client, err := utils.CreateHTTPClient(opts.Proxy, searchTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HTTP client for SearXNG: %w", err)
+		}
+		provider = &SearXNGSearchProvider{baseURL: opts.SearXNGBaseURL, client: client}
 		if opts.SearXNGMaxResults > 0 {
 			maxResults = opts.SearXNGMaxResults
 		}
@@ -713,6 +754,8 @@ func NewWebSearchTool(opts WebSearchToolOptions) (*WebSearchTool, error) {
 		if opts.GLMSearchMaxResults > 0 {
 			maxResults = opts.GLMSearchMaxResults
 		}
+	} else if explicit != "" {
+		return nil, fmt.Errorf("web search provider %q is missing its API key or base URL", explicit)
 	} else {
 		return nil, nil
 	}
@@ -728,7 +771,8 @@ func (t *WebSearchTool) Name() string {
 }
 
 func (t *WebSearchTool) Description() string {
-	return "Search the web for current information. Returns titles, URLs, and snippets from search results."
+	return "Search the web for current information. Returns titles, URLs, and snippets from search results. " +
+		"Cite the URLs of the results you use."
 }
 
 func (t *WebSearchTool) Parameters() map[string]any {
@@ -859,7 +903,8 @@ func (t *WebFetchTool) Name() string {
 }
 
 func (t *WebFetchTool) Description() string {
-	return "Fetch a URL and extract readable content (HTML to text). Use this to get weather info, news, articles, or any web content."
+	return "Fetch a URL and extract readable content (HTML to text). " +
+		"Use this to get weather info, news, articles, or any web content. Cite the URL when you use what it says."
 }
 
 func (t *WebFetchTool) Parameters() map[string]any {
@@ -961,7 +1006,7 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		}
 	}
 
-	var text, extractor string
+	var text, extractor, title string
 
 	switch {
 	case mediaType == "application/json":
@@ -984,6 +1029,14 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 
 	case mediaType == "text/html" || looksLikeHTML(bodyStr):
 		switch strings.ToLower(t.format) {
+		case "readable":
+			article, err := utils.ReadableMarkdown(bodyStr, resp.Request.URL.String())
+			if err != nil {
+				return ErrorResult(fmt.Sprintf("failed to extract the page content: %v", err))
+			}
+			text, title = article.Markdown, article.Title
+			extractor = "readable"
+
 		case "markdown":
 			var err error
 			text, err = utils.HtmlToMarkdown(bodyStr)
@@ -1015,6 +1068,9 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		"length":    len(text),
 		"text":      text,
 	}
+	if title != "" {
+		result["title"] = title
+	}
 
 	resultJSON, _ := json.MarshalIndent(result, "", "  ")
 
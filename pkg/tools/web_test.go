@@ -1189,3 +1189,62 @@ func TestWebTool_GLMSearch_Priority(t *testing.T) {
 		t.Errorf("Expected GLMSearchProvider when only GLM enabled, got %T", tool2.provider)
 	}
 }
+
+func TestWebTool_WebSearch_ExplicitProvider(t *testing.T) {
+	tool, err := NewWebSearchTool(WebSearchToolOptions{
+		BraveEnabled:   true,
+		BraveAPIKeys:   []string{"key"},
+		SearXNGBaseURL: "https://searx.example.com",
+		Provider:       "SearXNG",
+	})
+	if err != nil {
+		t.Fatalf("NewWebSearchTool() error: %v", err)
+	}
+	if _, ok := tool.provider.(*SearXNGSearchProvider); !ok {
+		t.Errorf("provider = %T, want the requested SearXNG over Brave", tool.provider)
+	}
+
+	if _, err := NewWebSearchTool(WebSearchToolOptions{DuckDuckGoEnabled: true, Provider: "brave"}); err == nil {
+		t.Error("expected an error for a provider without an API key")
+	}
+	if _, err := NewWebSearchTool(WebSearchToolOptions{Provider: "altavista"}); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+}
+
+func TestWebTool_WebFetch_Readable(t *testing.T) {
+	withPrivateWebFetchHostsAllowed(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Launch day</title></head><body>
+<div class="menu"><a href="/">Home</a></div>
+<div class="story"><p>The rocket lifted off at dawn, carrying three satellites, a camera, and a crew of two.</p>
+<p>Read the <a href="/press">press release</a> for the full list of payloads, orbits, and partners.</p></div>
+</body></html>`))
+	}))
+	defer server.Close()
+
+	tool, err := NewWebFetchTool(50000, "readable", testFetchLimit)
+	if err != nil {
+		t.Fatalf("NewWebFetchTool() error: %v", err)
+	}
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL + "/news/launch"})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	var got struct {
+		Title     string `json:"title"`
+		Extractor string `json:"extractor"`
+		Text      string `json:"text"`
+	}
+	if err := json.Unmarshal([]byte(result.ForLLM), &got); err != nil {
+		t.Fatalf("result is not JSON: %v", err)
+	}
+	if got.Title != "Launch day" || got.Extractor != "readable" {
+		t.Errorf("title %q, extractor %q", got.Title, got.Extractor)
+	}
+	if !strings.Contains(got.Text, "[press release]("+server.URL+"/press)") || strings.Contains(got.Text, "Home") {
+		t.Errorf("text = %q", got.Text)
+	}
+}
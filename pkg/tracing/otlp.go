@@ -0,0 +1,289 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultEndpoint = "http://localhost:4318"
+	defaultService  = "picoclaw"
+	flushInterval   = 5 * time.Second
+	batchSize       = 256
+	maxQueued       = 4096
+	exportTimeout   = 10 * time.Second
+)
+
+// Tracer batches ended spans and posts them to an OTLP/HTTP collector.
+type Tracer struct {
+	url     string
+	headers map[string]string
+	service string
+	ratio   float64
+	client  *http.Client
+
+	mu      sync.Mutex
+	queue   []*Span
+	dropped int
+	kick    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Setup starts exporting the spans of Start as configured and returns the
+// function that flushes the spans left and stops the export. Without
+// tracing enabled it does nothing.
+func Setup(cfg config.TracingConfig) (shutdown func(context.Context) error, err error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing: sample_ratio must be between 0 and 1, got %v", cfg.SampleRatio)
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	service := cfg.ServiceName
+	if service == "" {
+		service = defaultService
+	}
+	t := &Tracer{
+		url:     url,
+		headers: cfg.Headers,
+		service: service,
+		ratio:   cfg.SampleRatio,
+		client:  &http.Client{Timeout: exportTimeout},
+		kick:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go t.run()
+	global.Store(t)
+	return t.Shutdown, nil
+}
+
+// Shutdown stops recording, exports the queued spans, and waits for the
+// export until ctx ends.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	global.CompareAndSwap(t, nil)
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sample decides whether a new trace is exported. A ratio of 0 keeps all.
+func (t *Tracer) sample() bool {
+	return t.ratio == 0 || t.ratio == 1 || sampleFloat() < t.ratio
+}
+
+func (t *Tracer) enqueue(s *Span) {
+	t.mu.Lock()
+	if len(t.queue) >= maxQueued {
+		t.dropped++
+		t.mu.Unlock()
+		return
+	}
+	t.queue = append(t.queue, s)
+	full := len(t.queue) >= batchSize
+	t.mu.Unlock()
+	if full {
+		select {
+		case t.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.kick:
+		case <-t.stop:
+			t.flush()
+			return
+		}
+		t.flush()
+	}
+}
+
+// flush exports the queued spans in batches. A failed batch is dropped,
+// so a collector that is down never holds up the bot.
+func (t *Tracer) flush() {
+	for {
+		t.mu.Lock()
+		n := min(len(t.queue), batchSize)
+		batch := t.queue[:n:n]
+		t.queue = t.queue[n:]
+		dropped := t.dropped
+		t.dropped = 0
+		t.mu.Unlock()
+		if dropped > 0 {
+			logger.WarnCF("tracing", "Dropped spans, the export queue was full", map[string]any{"spans": dropped})
+		}
+		if n == 0 {
+			return
+		}
+		if err := t.export(batch); err != nil {
+			logger.WarnCF("tracing", "Span export failed", map[string]any{
+				"endpoint": t.url,
+				"spans":    n,
+				"error":    err.Error(),
+			})
+		}
+	}
+}
+
+func (t *Tracer) export(spans []*Span) error {
+	body, err := json.Marshal(t.request(spans))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP JSON request types, trimmed to what spans here use.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+// statusError is the OTLP code of a failed span.
+const statusError = 2
+
+func (t *Tracer) request(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		o := otlpSpan{
+			TraceID:           hex.EncodeToString(s.trace[:]),
+			SpanID:            hex.EncodeToString(s.id[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        attributes(s.attrs),
+		}
+		if s.parent != (spanID{}) {
+			o.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		if s.errMsg != "" {
+			o.Status = &otlpStatus{Code: statusError, Message: s.errMsg}
+		}
+		s.mu.Unlock()
+		out = append(out, o)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: attributes(map[string]any{"service.name": t.service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/sipeed/picoclaw"}, Spans: out}},
+	}}}
+}
+
+// attributes encodes attrs as OTLP key-values, sorted by key.
+func attributes(attrs map[string]any) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		var value map[string]any
+		switch v := v.(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, otlpKeyValue{Key: k, Value: value})
+	}
+	slices.SortFunc(out, func(a, b otlpKeyValue) int { return strings.Compare(a.Key, b.Key) })
+	return out
+}
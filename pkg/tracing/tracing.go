@@ -0,0 +1,172 @@
+// Package tracing records OpenTelemetry spans of the message pipeline and
+// exports them over OTLP/HTTP in its JSON encoding, so a slow reply can be
+// broken down by stage in Jaeger, Tempo, or any other OTLP collector.
+// Without Setup every span is a no-op.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Span kinds, as numbered by OTLP.
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+	KindConsumer = 5
+)
+
+type (
+	traceID [16]byte
+	spanID  [8]byte
+)
+
+// Span is one timed stage of a trace. A nil *Span records nothing, so
+// callers need not check whether tracing is on.
+type Span struct {
+	tracer  *Tracer
+	name    string
+	kind    int
+	trace   traceID
+	id      spanID
+	parent  spanID
+	sampled bool
+	start   time.Time
+
+	mu     sync.Mutex
+	end    time.Time
+	attrs  map[string]any
+	errMsg string
+	ended  bool
+}
+
+type spanKey struct{}
+
+// global is the tracer Start records with, or nil.
+var global atomic.Pointer[Tracer]
+
+// Start begins a span with name as a child of the span of ctx, or as the
+// root of a new trace, and returns a context carrying it. The trace ID of a
+// new trace is added to the log fields of the context.
+func Start(ctx context.Context, name string, kind int, attrs map[string]any) (context.Context, *Span) {
+	t := global.Load()
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now(), attrs: make(map[string]any, len(attrs))}
+	for k, v := range attrs {
+		s.attrs[k] = v
+	}
+	rand.Read(s.id[:])
+	if parent, ok := ctx.Value(spanKey{}).(spanContext); ok {
+		s.trace, s.parent, s.sampled = parent.trace, parent.span, parent.sampled
+	} else {
+		rand.Read(s.trace[:])
+		s.sampled = t.sample()
+		if s.sampled {
+			ctx = logger.WithFields(ctx, map[string]any{"trace_id": hex.EncodeToString(s.trace[:])})
+		}
+	}
+	return context.WithValue(ctx, spanKey{}, spanContext{trace: s.trace, span: s.id, sampled: s.sampled}), s
+}
+
+// SetAttributes adds attributes to s, replacing those of the same key.
+func (s *Span) SetAttributes(attrs map[string]any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range attrs {
+		s.attrs[k] = v
+	}
+}
+
+// RecordError marks s failed with err. A nil err and context cancellation,
+// which is how a user stops a reply, are not failures.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errMsg = err.Error()
+}
+
+// End finishes s and queues it for export. Calls after the first are
+// ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended, s.end = true, time.Now()
+	s.mu.Unlock()
+	if s.sampled {
+		s.tracer.enqueue(s)
+	}
+}
+
+// spanContext is what a context carries of its span: enough to parent
+// children, also across the message bus.
+type spanContext struct {
+	trace   traceID
+	span    spanID
+	sampled bool
+}
+
+// TraceParent returns the span of ctx as a W3C traceparent header value,
+// or "" without one. It carries a trace across the message bus.
+func TraceParent(ctx context.Context) string {
+	sc, ok := ctx.Value(spanKey{}).(spanContext)
+	if !ok {
+		return ""
+	}
+	flags := "00"
+	if sc.sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.trace[:]) + "-" + hex.EncodeToString(sc.span[:]) + "-" + flags
+}
+
+// WithTraceParent returns ctx with the span of a traceparent value as the
+// parent of the spans started from it. An invalid value leaves ctx alone.
+func WithTraceParent(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	var sc spanContext
+	if _, err := hex.Decode(sc.trace[:], []byte(parts[1])); err != nil {
+		return ctx
+	}
+	if _, err := hex.Decode(sc.span[:], []byte(parts[2])); err != nil {
+		return ctx
+	}
+	sc.sampled = parts[3] == "01"
+	ctx = logger.WithFields(ctx, map[string]any{"trace_id": parts[1]})
+	return context.WithValue(ctx, spanKey{}, sc)
+}
+
+// sampleFloat returns a uniform float in [0, 1).
+func sampleFloat() float64 {
+	n, err := rand.Int(rand.Reader, big.NewInt(1<<53))
+	if err != nil {
+		return 0
+	}
+	return float64(n.Int64()) / (1 << 53)
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestStartWithoutSetupIsNoop(t *testing.T) {
+	ctx, span := Start(context.Background(), "message", KindServer, nil)
+	if span != nil || TraceParent(ctx) != "" {
+		t.Fatal("a span was recorded without a tracer")
+	}
+	span.SetAttributes(map[string]any{"k": "v"})
+	span.RecordError(errors.New("boom"))
+	span.End()
+}
+
+func TestSpansAreExportedAsOTLP(t *testing.T) {
+	var mu sync.Mutex
+	var spans []otlpSpan
+	var service string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer k" {
+			t.Errorf("request to %s, auth %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range req.ResourceSpans {
+			service = rs.Resource.Attributes[0].Value["stringValue"].(string)
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	shutdown, err := Setup(config.TracingConfig{
+		Enabled: true, Endpoint: srv.URL, Headers: map[string]string{"Authorization": "Bearer k"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, root := Start(context.Background(), "message", KindServer, map[string]any{"channel": "telegram"})
+	_, tool := Start(ctx, "tool exec", KindInternal, nil)
+	tool.RecordError(errors.New("exit status 1"))
+	tool.End()
+	// The delivery continues the trace on the other side of the bus.
+	_, deliver := Start(WithTraceParent(context.Background(), TraceParent(ctx)), "deliver", KindConsumer, nil)
+	deliver.End()
+	root.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(spans) != 3 || service != "picoclaw" {
+		t.Fatalf("exported %d spans of %q", len(spans), service)
+	}
+	byName := map[string]otlpSpan{}
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+	msg, exec, del := byName["message"], byName["tool exec"], byName["deliver"]
+	if msg.ParentSpanID != "" || exec.ParentSpanID != msg.SpanID || del.ParentSpanID != msg.SpanID {
+		t.Errorf("parents: message %q, tool %q, deliver %q (message is %q)",
+			msg.ParentSpanID, exec.ParentSpanID, del.ParentSpanID, msg.SpanID)
+	}
+	if exec.TraceID != msg.TraceID || del.TraceID != msg.TraceID || len(msg.TraceID) != 32 {
+		t.Errorf("trace IDs %q %q %q", msg.TraceID, exec.TraceID, del.TraceID)
+	}
+	if exec.Status == nil || exec.Status.Code != statusError || msg.Status != nil {
+		t.Errorf("statuses: tool %+v, message %+v", exec.Status, msg.Status)
+	}
+	if len(msg.Attributes) != 1 || msg.Attributes[0].Value["stringValue"] != "telegram" {
+		t.Errorf("attributes %+v", msg.Attributes)
+	}
+
+	if _, span := Start(context.Background(), "after", KindServer, nil); span != nil {
+		t.Error("spans are recorded after shutdown")
+	}
+}
+
+func TestSetupRejectsBadSampleRatio(t *testing.T) {
+	if _, err := Setup(config.TracingConfig{Enabled: true, SampleRatio: 2}); err == nil {
+		t.Fatal("sample_ratio 2 accepted")
+	}
+}
+
+func TestWithTraceParentIgnoresInvalidValues(t *testing.T) {
+	ctx := context.Background()
+	for _, v := range []string{"", "00-xyz-abc-01", "00-" + string(make([]byte, 32)) + "-0000000000000001-01"} {
+		if got := WithTraceParent(ctx, v); got != ctx {
+			t.Errorf("WithTraceParent(%q) changed the context", v)
+		}
+	}
+}
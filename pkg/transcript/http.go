@@ -0,0 +1,83 @@
+package transcript
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/auth"
+)
+
+// HTTPPath is where the gateway mounts the transcripts API.
+const HTTPPath = "/transcripts"
+
+const (
+	defaultAPILimit = 100
+	maxAPILimit     = 1000
+)
+
+type listResponse struct {
+	Entries []Entry `json:"entries"`
+}
+
+// ServeHTTP implements the transcripts API. GET returns the latest entries,
+// optionally filtered by the id, session, tool, and since (RFC 3339) query
+// parameters; limit caps the count. It requires
+// "Authorization: Bearer <api_token>", and the API is off without a token.
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	s.ServeQuery(w, r)
+}
+
+// ServeQuery answers a request of the transcripts API without checking its
+// token, for APIs that check their own.
+func (s *Store) ServeQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	f := Filter{
+		ID:      q.Get("id"),
+		Session: q.Get("session"),
+		Tool:    q.Get("tool"),
+		Limit:   defaultAPILimit,
+	}
+	if v := q.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		f.Since = since
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		f.Limit = min(n, maxAPILimit)
+	}
+
+	entries, err := s.Query(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []Entry{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listResponse{Entries: entries})
+}
+
+func (s *Store) authorized(r *http.Request) bool {
+	return auth.BearerTokenMatches(r, s.APITokens()...)
+}
@@ -0,0 +1,273 @@
+// Package transcript keeps a persistent record of every tool call the agent
+// makes (arguments, result, duration, and outcome) so admins can audit what
+// the agent did after the fact. Entries are appended to one JSON Lines file
+// per day and old days are removed after the retention period.
+package transcript
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Outcomes of a tool call.
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+	StatusAsync = "async" // the tool went on in the background
+)
+
+const dayLayout = "2006-01-02"
+
+// Entry is one tool call.
+type Entry struct {
+	ID         string         `json:"id"` // tool call ID assigned by the model
+	Time       time.Time      `json:"time"`
+	Session    string         `json:"session"`
+	Channel    string         `json:"channel,omitempty"`
+	ChatID     string         `json:"chat_id,omitempty"`
+	SenderID   string         `json:"sender_id,omitempty"`
+	RequestID  string         `json:"request_id,omitempty"`
+	Agent      string         `json:"agent,omitempty"`
+	Tool       string         `json:"tool"`
+	Arguments  map[string]any `json:"arguments,omitempty"`
+	Result     string         `json:"result,omitempty"`
+	Truncated  bool           `json:"truncated,omitempty"` // Result was cut to max_result_chars
+	Error      string         `json:"error,omitempty"`
+	DurationMS int64          `json:"duration_ms"`
+	Status     string         `json:"status"`
+}
+
+// Filter selects entries. Zero fields match everything.
+type Filter struct {
+	ID      string
+	Session string
+	Tool    string
+	Since   time.Time
+	Limit   int // latest entries to return; 0 means all
+}
+
+func (f Filter) match(e Entry) bool {
+	return (f.ID == "" || e.ID == f.ID) &&
+		(f.Session == "" || e.Session == f.Session) &&
+		(f.Tool == "" || e.Tool == f.Tool) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since))
+}
+
+// Dir returns the transcripts directory for a workspace.
+func Dir(workspace string) string {
+	return filepath.Join(workspace, "state", "transcripts")
+}
+
+// Store appends and reads transcripts. It is safe for concurrent use; a nil
+// *Store records nothing.
+type Store struct {
+	dir string
+	now func() time.Time
+
+	mu         sync.Mutex
+	cfg        config.TranscriptsConfig
+	adminToken string
+	pruned     string // day of the last retention sweep
+}
+
+// NewStore creates a store that keeps its files in dir.
+func NewStore(dir string, cfg config.TranscriptsConfig) *Store {
+	return &Store{dir: dir, cfg: cfg, now: time.Now}
+}
+
+// APITokens returns the tokens the transcripts API accepts: api_token and
+// the admin API token.
+func (s *Store) APITokens() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return []string{s.cfg.APIToken, s.adminToken}
+}
+
+// SetAdminToken lets the admin API token authorize the transcripts API as
+// well as api_token.
+func (s *Store) SetAdminToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adminToken = token
+}
+
+// Record appends e to the transcript of its day.
+func (s *Store) Record(e Entry) error {
+	if s == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = s.now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if limit := s.cfg.MaxResultChars; limit > 0 && utf8.RuneCountInString(e.Result) > limit {
+		e.Result = string([]rune(e.Result)[:limit])
+		e.Truncated = true
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	day := e.Time.UTC().Format(dayLayout)
+	f, err := os.OpenFile(filepath.Join(s.dir, day+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if s.pruned != day {
+		s.pruned = day
+		s.pruneLocked()
+	}
+	return nil
+}
+
+// pruneLocked removes the days that fell out of the retention period.
+func (s *Store) pruneLocked() {
+	if s.cfg.RetentionDays <= 0 {
+		return
+	}
+	cutoff := s.now().UTC().AddDate(0, 0, -s.cfg.RetentionDays).Format(dayLayout)
+	days, err := s.days()
+	if err != nil {
+		return
+	}
+	for _, day := range days {
+		if day >= cutoff {
+			break
+		}
+		if err := os.Remove(filepath.Join(s.dir, day+".jsonl")); err != nil {
+			logger.WarnCF("transcript", "Failed to remove old transcript", map[string]any{
+				"day":   day,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// days returns the days that have a transcript, oldest first.
+func (s *Store) days() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var days []string
+	for _, entry := range entries {
+		day, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if _, err := time.Parse(dayLayout, day); ok && err == nil {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// Query returns the entries matching f, oldest first. With a limit only the
+// latest entries are returned.
+func (s *Store) Query(f Filter) ([]Entry, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	days, err := s.days()
+	if err != nil {
+		return nil, err
+	}
+	var since string
+	if !f.Since.IsZero() {
+		since = f.Since.UTC().Format(dayLayout)
+	}
+
+	var result []Entry
+	for i := len(days) - 1; i >= 0; i-- {
+		if days[i] < since {
+			break
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, days[i]+".jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		var matched []Entry
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 16<<20)
+		for scanner.Scan() {
+			var e Entry
+			if json.Unmarshal(scanner.Bytes(), &e) == nil && f.match(e) {
+				matched = append(matched, e)
+			}
+		}
+		result = append(matched, result...)
+		if f.Limit > 0 && len(result) >= f.Limit {
+			return result[len(result)-f.Limit:], nil
+		}
+	}
+	return result, nil
+}
+
+// ForgetSender removes every entry recorded for senderID on channel and
+// returns how many were removed.
+func (s *Store) ForgetSender(channel, senderID string) (int, error) {
+	if s == nil || senderID == "" {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	days, err := s.days()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, day := range days {
+		n, err := fileutil.RewriteLines(filepath.Join(s.dir, day+".jsonl"), 0o600, func(line []byte) ([]byte, bool) {
+			var e Entry
+			return line, json.Unmarshal(line, &e) != nil || e.Channel != channel || e.SenderID != senderID
+		})
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// Find returns the latest entry of the tool call id in session.
+func (s *Store) Find(session, id string) (Entry, bool) {
+	entries, err := s.Query(Filter{ID: id, Session: session, Limit: 1})
+	if err != nil || len(entries) == 0 {
+		return Entry{}, false
+	}
+	return entries[0], true
+}
@@ -0,0 +1,147 @@
+package transcript
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestStore_RecordAndQuery(t *testing.T) {
+	s := NewStore(t.TempDir(), config.TranscriptsConfig{MaxResultChars: 5})
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	records := []Entry{
+		{ID: "a", Time: base, Session: "s1", Tool: "exec", Result: "hello world", Status: StatusOK},
+		{ID: "b", Time: base.Add(time.Minute), Session: "s2", Tool: "exec", Status: StatusError, Error: "boom"},
+		{ID: "c", Time: base.AddDate(0, 0, 1), Session: "s1", Tool: "web_fetch", Status: StatusOK},
+	}
+	for _, e := range records {
+		if err := s.Record(e); err != nil {
+			t.Fatalf("Record(%s): %v", e.ID, err)
+		}
+	}
+
+	all, err := s.Query(Filter{Session: "s1"})
+	if err != nil || len(all) != 2 || all[0].ID != "a" || all[1].ID != "c" {
+		t.Fatalf("Query(s1) = %+v, %v", all, err)
+	}
+	if all[0].Result != "hello" || !all[0].Truncated {
+		t.Errorf("result not truncated: %+v", all[0])
+	}
+
+	latest, _ := s.Query(Filter{Limit: 2})
+	if len(latest) != 2 || latest[0].ID != "b" || latest[1].ID != "c" {
+		t.Errorf("Query(limit 2) = %+v", latest)
+	}
+	since, _ := s.Query(Filter{Since: base.Add(30 * time.Second)})
+	if len(since) != 2 || since[0].ID != "b" {
+		t.Errorf("Query(since) = %+v", since)
+	}
+	if tool, _ := s.Query(Filter{Tool: "web_fetch"}); len(tool) != 1 || tool[0].ID != "c" {
+		t.Errorf("Query(tool) = %+v", tool)
+	}
+
+	if e, ok := s.Find("s2", "b"); !ok || e.Error != "boom" {
+		t.Errorf("Find(s2, b) = %+v, %v", e, ok)
+	}
+	if _, ok := s.Find("s1", "b"); ok {
+		t.Error("Find must not return calls of another session")
+	}
+}
+
+func TestStore_RetentionRemovesOldDays(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir, config.TranscriptsConfig{RetentionDays: 7})
+	now := time.Date(2026, 3, 20, 9, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	old := filepath.Join(dir, "2026-03-01.jsonl")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(old, []byte(`{"id":"x"}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(Entry{ID: "new", Tool: "exec", Status: StatusOK}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("old transcript kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2026-03-20.jsonl")); err != nil {
+		t.Errorf("new transcript missing: %v", err)
+	}
+}
+
+func TestStore_NilRecordsNothing(t *testing.T) {
+	var s *Store
+	if err := s.Record(Entry{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := s.Query(Filter{}); err != nil || entries != nil {
+		t.Errorf("Query on nil store = %v, %v", entries, err)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	s := NewStore(t.TempDir(), config.TranscriptsConfig{APIToken: "secret"})
+	s.Record(Entry{ID: "a", Session: "s1", Tool: "exec", Status: StatusOK})
+	s.Record(Entry{ID: "b", Session: "s2", Tool: "exec", Status: StatusOK})
+
+	get := func(token, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, HTTPPath+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("wrong", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status %d", w.Code)
+	}
+	s.SetAdminToken("admin")
+	if w := get("admin", ""); w.Code != http.StatusOK {
+		t.Errorf("admin token: status %d", w.Code)
+	}
+	if w := get("secret", "?limit=x"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad limit: status %d", w.Code)
+	}
+	w := get("secret", "?session=s2")
+	var resp listResponse
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil ||
+		len(resp.Entries) != 1 || resp.Entries[0].ID != "b" {
+		t.Fatalf("GET ?session=s2: %d %s", w.Code, w.Body.String())
+	}
+
+	closed := NewStore(t.TempDir(), config.TranscriptsConfig{})
+	req := httptest.NewRequest(http.MethodGet, HTTPPath, nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	closed.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("API without configured token should be closed, got %d", rec.Code)
+	}
+}
+
+func TestStore_ForgetSender(t *testing.T) {
+	s := NewStore(t.TempDir(), config.TranscriptsConfig{})
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.Record(Entry{ID: "a", Time: base, Channel: "telegram", SenderID: "7", Tool: "exec"})
+	s.Record(Entry{ID: "b", Time: base, Channel: "telegram", SenderID: "8", Tool: "exec"})
+	s.Record(Entry{ID: "c", Time: base.AddDate(0, 0, 1), Channel: "telegram", SenderID: "7", Tool: "exec"})
+
+	n, err := s.ForgetSender("telegram", "7")
+	if err != nil || n != 2 {
+		t.Fatalf("ForgetSender = %d, %v", n, err)
+	}
+	if left, _ := s.Query(Filter{}); len(left) != 1 || left[0].ID != "b" {
+		t.Errorf("left = %+v", left)
+	}
+}
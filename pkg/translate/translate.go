@@ -0,0 +1,123 @@
+// Package translate keeps track of chats in auto-translate mode and builds
+// the prompts used to translate messages with the configured provider.
+package translate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// NoTranslation is the reply the model gives when a message in an
+// auto-translate chat needs no translation.
+const NoTranslation = "NONE"
+
+// Path returns the auto-translate settings location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "translate.json")
+}
+
+// Store holds the languages of each chat in auto-translate mode. It is safe
+// for concurrent use.
+type Store struct {
+	path string
+
+	mu    sync.Mutex
+	chats map[string][]string
+}
+
+// NewStore loads the settings saved at path.
+func NewStore(path string) *Store {
+	s := &Store{path: path, chats: make(map[string][]string)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &s.chats); err != nil {
+			logger.WarnCF("translate", "Ignoring unreadable auto-translate settings", map[string]any{
+				"path":  path,
+				"error": err.Error(),
+			})
+			s.chats = make(map[string][]string)
+		}
+	}
+	return s
+}
+
+func chatKey(channel, chatID string) string {
+	return channel + ":" + chatID
+}
+
+// Languages returns the auto-translate languages of a chat, or nil when the
+// chat is not in auto-translate mode.
+func (s *Store) Languages(channel, chatID string) []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.chats[chatKey(channel, chatID)]...)
+}
+
+// Set turns auto-translate on for a chat, or off when languages is empty,
+// and saves the settings.
+func (s *Store) Set(channel, chatID string, languages []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := chatKey(channel, chatID)
+	if len(languages) == 0 {
+		if _, ok := s.chats[key]; !ok {
+			return nil
+		}
+		delete(s.chats, key)
+	} else {
+		s.chats[key] = append([]string(nil), languages...)
+	}
+	data, err := json.MarshalIndent(s.chats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(s.path, data, 0o600)
+}
+
+// Prompt asks for text to be translated into target.
+func Prompt(target, text string) string {
+	return "Translate the text inside <text> into " + target + ". " +
+		"Reply with only the translation. Keep formatting, names, URLs, and code unchanged.\n\n" +
+		"<text>\n" + text + "\n</text>"
+}
+
+// AutoPrompt asks for a message in an auto-translate chat to be translated.
+// With one language, messages in other languages are translated into it.
+// With two, messages in either are translated into the other. The model
+// replies NoTranslation when there is nothing to translate. languages must
+// not be empty.
+func AutoPrompt(languages []string, text string) string {
+	var rule string
+	if len(languages) == 1 {
+		rule = "If the text is already in " + languages[0] + ", reply with exactly " + NoTranslation +
+			". Otherwise translate it into " + languages[0] + "."
+	} else {
+		a, b := languages[0], languages[1]
+		rule = "This chat is bilingual in " + a + " and " + b + ". If the text is in " + a +
+			", translate it into " + b + ". If it is in " + b + ", translate it into " + a +
+			". If it is in any other language, translate it into " + a + "."
+	}
+	return rule + " If the text has nothing to translate, such as only emoji, links, or code, reply with exactly " +
+		NoTranslation + ". Otherwise reply with only the translation. " +
+		"Keep formatting, names, URLs, and code unchanged.\n\n" +
+		"<text>\n" + text + "\n</text>"
+}
+
+// Clean trims a model reply. It returns false when the reply says there is
+// nothing to translate.
+func Clean(reply string) (string, bool) {
+	reply = strings.TrimSpace(reply)
+	reply = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(reply, "<text>"), "</text>"))
+	if reply == "" || strings.EqualFold(strings.Trim(reply, ". "), NoTranslation) {
+		return "", false
+	}
+	return reply, true
+}
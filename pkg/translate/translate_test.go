@@ -0,0 +1,65 @@
+package translate
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStore_SetPersists(t *testing.T) {
+	path := Path(t.TempDir())
+	s := NewStore(path)
+	if got := s.Languages("slack", "C1"); got != nil {
+		t.Fatalf("new store languages = %v, want nil", got)
+	}
+	if err := s.Set("slack", "C1", []string{"English", "Japanese"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := NewStore(path)
+	if got := reloaded.Languages("slack", "C1"); !slices.Equal(got, []string{"English", "Japanese"}) {
+		t.Fatalf("reloaded languages = %v", got)
+	}
+	if got := reloaded.Languages("slack", "C2"); got != nil {
+		t.Fatalf("other chat languages = %v, want nil", got)
+	}
+
+	if err := reloaded.Set("slack", "C1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := NewStore(path).Languages("slack", "C1"); got != nil {
+		t.Fatalf("languages after off = %v, want nil", got)
+	}
+}
+
+func TestAutoPrompt(t *testing.T) {
+	p := AutoPrompt([]string{"English", "Spanish"}, "hola")
+	for _, want := range []string{"bilingual in English and Spanish", "<text>\nhola\n</text>", NoTranslation} {
+		if !strings.Contains(p, want) {
+			t.Errorf("prompt missing %q:\n%s", want, p)
+		}
+	}
+	if p := AutoPrompt([]string{"German"}, "hi"); !strings.Contains(p, "already in German") {
+		t.Errorf("single-language prompt = %q", p)
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  string
+		ok    bool
+	}{
+		{"  Hello there \n", "Hello there", true},
+		{"<text>\nBonjour\n</text>", "Bonjour", true},
+		{"NONE", "", false},
+		{"none.", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Clean(tt.reply)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Clean(%q) = %q, %v; want %q, %v", tt.reply, got, ok, tt.want, tt.ok)
+		}
+	}
+}
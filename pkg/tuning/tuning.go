@@ -0,0 +1,198 @@
+// Package tuning stores the generation settings a chat adjusts with /temp,
+// /maxtokens, /verbosity, /model, and /persona, and a guild with /config,
+// and keeps them within the ranges the operator configured.
+package tuning
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/prefs"
+)
+
+// Settings are one session's overrides. Zero values mean "use the agent's
+// configured value".
+type Settings struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Verbosity   string   `json:"verbosity,omitempty"`
+	Model       string   `json:"model,omitempty"` // model_list name
+	Persona     string   `json:"persona,omitempty"`
+	Language    string   `json:"language,omitempty"` // i18n code of the bot's own messages
+}
+
+// IsZero reports whether no override is set.
+func (s Settings) IsZero() bool {
+	return s.Temperature == nil && s.MaxTokens == 0 && s.Verbosity == "" && s.Model == "" && s.Persona == "" &&
+		s.Language == ""
+}
+
+// Over returns s with the settings it leaves unset taken from base, such as
+// the settings of the session's guild. A persona s picks brings its own
+// model and temperature, so those are not taken from base with it.
+func (s Settings) Over(base Settings) Settings {
+	if s.Persona == "" {
+		s.Persona = base.Persona
+		if s.Model == "" {
+			s.Model = base.Model
+		}
+		if s.Temperature == nil {
+			s.Temperature = base.Temperature
+		}
+	}
+	if s.MaxTokens == 0 {
+		s.MaxTokens = base.MaxTokens
+	}
+	if s.Verbosity == "" {
+		s.Verbosity = base.Verbosity
+	}
+	if s.Language == "" {
+		s.Language = base.Language
+	}
+	return s
+}
+
+// GuildKey returns the key the settings of a guild are stored under. No
+// session key has this form.
+func GuildKey(channel, guildID string) string {
+	return "guild:" + channel + ":" + guildID
+}
+
+// Limits are the allowed ranges together with the agent's own values, which
+// apply while a session has no override.
+type Limits struct {
+	config.GenerationConfig
+	DefaultTemperature float64
+	DefaultMaxTokens   int
+}
+
+// Temperature returns the temperature to use for a session.
+func (l Limits) Temperature(s Settings) float64 {
+	if s.Temperature == nil {
+		return l.DefaultTemperature
+	}
+	return clampFloat(*s.Temperature, l.GenerationConfig.Temperature)
+}
+
+// MaxTokens returns the completion token limit to use for a session.
+func (l Limits) MaxTokens(s Settings) int {
+	if s.MaxTokens == 0 {
+		return l.DefaultMaxTokens
+	}
+	return clampInt(s.MaxTokens, l.GenerationConfig.MaxTokens)
+}
+
+// ParseTemperature parses value and clamps it to the allowed range. It
+// reports whether the value had to be clamped.
+func (l Limits) ParseTemperature(value string) (float64, bool, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("temperature must be a number, got %q", value)
+	}
+	clamped := clampFloat(v, l.GenerationConfig.Temperature)
+	return clamped, clamped != v, nil
+}
+
+// ParseMaxTokens parses value and clamps it to the allowed range. It reports
+// whether the value had to be clamped.
+func (l Limits) ParseMaxTokens(value string) (int, bool, error) {
+	v, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || v < 1 {
+		return 0, false, fmt.Errorf("max tokens must be a positive whole number, got %q", value)
+	}
+	clamped := clampInt(v, l.GenerationConfig.MaxTokens)
+	return clamped, clamped != v, nil
+}
+
+// ParseVerbosity validates a verbosity level.
+func ParseVerbosity(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case prefs.VerbosityBrief, prefs.VerbosityNormal, prefs.VerbosityDetailed:
+		return v, nil
+	default:
+		return "", fmt.Errorf("verbosity must be %s, %s, or %s",
+			prefs.VerbosityBrief, prefs.VerbosityNormal, prefs.VerbosityDetailed)
+	}
+}
+
+// clampFloat limits v to r; a zero bound is open.
+func clampFloat(v float64, r config.FloatRange) float64 {
+	if r.Max > 0 && v > r.Max {
+		v = r.Max
+	}
+	return max(v, r.Min)
+}
+
+// clampInt limits v to r; a zero bound is open.
+func clampInt(v int, r config.IntRange) int {
+	if r.Max > 0 && v > r.Max {
+		v = r.Max
+	}
+	return max(v, r.Min)
+}
+
+// Path returns the generation settings location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "tuning.json")
+}
+
+// Store holds the settings of every session. It is safe for concurrent use;
+// a nil *Store has no overrides.
+type Store struct {
+	path string
+
+	mu       sync.Mutex
+	sessions map[string]Settings
+}
+
+// NewStore loads the settings saved at path.
+func NewStore(path string) *Store {
+	s := &Store{path: path, sessions: make(map[string]Settings)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &s.sessions); err != nil {
+			logger.WarnCF("tuning", "Ignoring unreadable generation settings", map[string]any{
+				"path":  path,
+				"error": err.Error(),
+			})
+			s.sessions = make(map[string]Settings)
+		}
+	}
+	return s
+}
+
+// Get returns the settings of session.
+func (s *Store) Get(session string) Settings {
+	if s == nil {
+		return Settings{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[session]
+}
+
+// Put replaces the settings of session and saves the store.
+func (s *Store) Put(session string, settings Settings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if settings.IsZero() {
+		if _, ok := s.sessions[session]; !ok {
+			return nil
+		}
+		delete(s.sessions, session)
+	} else {
+		s.sessions[session] = settings
+	}
+	data, err := json.MarshalIndent(s.sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(s.path, data, 0o600)
+}
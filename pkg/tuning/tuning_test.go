@@ -0,0 +1,80 @@
+package tuning
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestLimits_ClampOverrides(t *testing.T) {
+	l := Limits{
+		GenerationConfig: config.GenerationConfig{
+			Temperature: config.FloatRange{Min: 0.2, Max: 1},
+			MaxTokens:   config.IntRange{Min: 256},
+		},
+		DefaultTemperature: 0.7,
+		DefaultMaxTokens:   4096,
+	}
+	if got := l.Temperature(Settings{}); got != 0.7 {
+		t.Errorf("default temperature = %v", got)
+	}
+	hot := 2.0
+	if got := l.Temperature(Settings{Temperature: &hot}); got != 1 {
+		t.Errorf("clamped temperature = %v", got)
+	}
+	if v, clamped, err := l.ParseTemperature("0.1"); err != nil || !clamped || v != 0.2 {
+		t.Errorf("ParseTemperature(0.1) = %v, %v, %v", v, clamped, err)
+	}
+	if v, clamped, err := l.ParseMaxTokens("100000"); err != nil || clamped || v != 100000 {
+		t.Errorf("open maximum: ParseMaxTokens = %v, %v, %v", v, clamped, err)
+	}
+	for _, bad := range []string{"0", "-5", "1.5", "many"} {
+		if _, _, err := l.ParseMaxTokens(bad); err == nil {
+			t.Errorf("ParseMaxTokens(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestStore_PersistsSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "tuning.json")
+	s := NewStore(path)
+	temp := 0.3
+	if err := s.Put("agent:main:main", Settings{Temperature: &temp, Verbosity: "brief"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := NewStore(path).Get("agent:main:main")
+	if got.Temperature == nil || *got.Temperature != 0.3 || got.Verbosity != "brief" {
+		t.Fatalf("reloaded settings = %+v", got)
+	}
+	if err := s.Put("agent:main:main", Settings{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := NewStore(path).Get("agent:main:main"); !got.IsZero() {
+		t.Fatalf("cleared settings = %+v", got)
+	}
+
+	var none *Store
+	if !none.Get("x").IsZero() {
+		t.Fatal("nil store returned settings")
+	}
+}
+
+func TestSettings_OverGuild(t *testing.T) {
+	cool, warm := 0.3, 0.9
+	guild := Settings{Temperature: &cool, MaxTokens: 512, Model: "small", Persona: "support"}
+
+	got := Settings{Verbosity: "brief"}.Over(guild)
+	if got.Temperature != &cool || got.MaxTokens != 512 || got.Model != "small" || got.Persona != "support" ||
+		got.Verbosity != "brief" {
+		t.Errorf("session without overrides = %+v", got)
+	}
+	if got := (Settings{Temperature: &warm}).Over(guild); *got.Temperature != 0.9 || got.Model != "small" {
+		t.Errorf("session temperature = %+v", got)
+	}
+	got = Settings{Persona: "pirate"}.Over(guild)
+	if got.Persona != "pirate" || got.Model != "" || got.Temperature != nil || got.MaxTokens != 512 {
+		t.Errorf("session persona should keep its own model and temperature: %+v", got)
+	}
+}
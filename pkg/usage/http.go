@@ -0,0 +1,87 @@
+package usage
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/auth"
+)
+
+// HTTPPath is where the gateway mounts the usage report API.
+const HTTPPath = "/usage"
+
+// Handler serves usage reports as JSON.
+type Handler struct {
+	tokens  []string
+	tracker *Tracker
+	pricing func() Pricing
+}
+
+// NewHandler returns the API over the records of tracker, priced by the
+// pricing returned by pricing at each request. The API is off without a
+// token.
+func NewHandler(tokens []string, tracker *Tracker, pricing func() Pricing) *Handler {
+	return &Handler{tokens: tokens, tracker: tracker, pricing: pricing}
+}
+
+// ServeHTTP implements GET /usage. The query takes from and to, as
+// YYYY-MM-DD local dates with to included (default: the month so far),
+// group_by (user, channel, day, or model; default day), and the channel and
+// sender_id filters. It requires "Authorization: Bearer <api_token>".
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.Local)
+	for name, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		day, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+		if err != nil {
+			http.Error(w, name+" must be a YYYY-MM-DD date", http.StatusBadRequest)
+			return
+		}
+		if name == "to" {
+			day = day.AddDate(0, 0, 1)
+		}
+		*t = day
+	}
+	groupBy := q.Get("group_by")
+	if groupBy == "" {
+		groupBy = GroupDay
+	}
+	channel, senderID := q.Get("channel"), q.Get("sender_id")
+	keep := func(rec Record) bool {
+		return (channel == "" || rec.Channel == channel) && (senderID == "" || rec.SenderID == senderID)
+	}
+
+	records, err := h.tracker.Load(from)
+	if err != nil {
+		http.Error(w, "failed to read usage: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rep, err := BuildReport(records, from, to, h.pricing(), groupBy, keep)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rep)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	return auth.BearerTokenMatches(r, h.tokens...)
+}
@@ -0,0 +1,117 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Ways a report groups its records.
+const (
+	GroupUser    = "user"
+	GroupChannel = "channel"
+	GroupDay     = "day"
+	GroupModel   = "model"
+)
+
+// ReportRow is the usage and cost of one group of a report.
+type ReportRow struct {
+	Key              string  `json:"key"`
+	Messages         int     `json:"messages"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost_usd"`
+}
+
+func (row *ReportRow) add(r Record, cost float64) {
+	if r.TurnStart {
+		row.Messages++
+	}
+	row.Requests++
+	row.PromptTokens += r.PromptTokens
+	row.CompletionTokens += r.CompletionTokens
+	row.Cost += cost
+}
+
+// Report is the usage of a period, in total and by group.
+type Report struct {
+	From    time.Time   `json:"from"`
+	To      time.Time   `json:"to"`
+	GroupBy string      `json:"group_by"`
+	Total   ReportRow   `json:"total"`
+	Rows    []ReportRow `json:"rows"`
+}
+
+// BuildReport aggregates the records in [from, to) that keep accepts, or
+// all of them for a nil keep, by groupBy. Users are keyed "channel:sender_id"
+// and days by their local date. Day rows are in date order, the others by
+// cost and then tokens, highest first. Models without a price cost nothing.
+func BuildReport(
+	records []Record,
+	from, to time.Time,
+	pricing Pricing,
+	groupBy string,
+	keep func(Record) bool,
+) (Report, error) {
+	key, err := reportKey(groupBy)
+	if err != nil {
+		return Report{}, err
+	}
+	rep := Report{From: from, To: to, GroupBy: groupBy, Total: ReportRow{Key: "total"}, Rows: []ReportRow{}}
+	rows := make(map[string]*ReportRow)
+	for _, r := range records {
+		if r.Time.Before(from) || !r.Time.Before(to) || (keep != nil && !keep(r)) {
+			continue
+		}
+		cost := pricing.Cost(r)
+		rep.Total.add(r, cost)
+		k := key(r)
+		row, ok := rows[k]
+		if !ok {
+			row = &ReportRow{Key: k}
+			rows[k] = row
+		}
+		row.add(r, cost)
+	}
+	for _, row := range rows {
+		rep.Rows = append(rep.Rows, *row)
+	}
+	sort.Slice(rep.Rows, func(i, j int) bool {
+		a, b := rep.Rows[i], rep.Rows[j]
+		if groupBy != GroupDay {
+			if a.Cost != b.Cost {
+				return a.Cost > b.Cost
+			}
+			if ta, tb := a.PromptTokens+a.CompletionTokens, b.PromptTokens+b.CompletionTokens; ta != tb {
+				return ta > tb
+			}
+		}
+		return a.Key < b.Key
+	})
+	return rep, nil
+}
+
+func reportKey(groupBy string) (func(Record) string, error) {
+	switch groupBy {
+	case GroupUser:
+		return func(r Record) string {
+			if r.SenderID == "" {
+				return "(unknown)"
+			}
+			return r.Channel + ":" + r.SenderID
+		}, nil
+	case GroupChannel:
+		return func(r Record) string {
+			if r.Channel == "" {
+				return "(unknown)"
+			}
+			return r.Channel
+		}, nil
+	case GroupDay:
+		return func(r Record) string { return r.Time.Local().Format(time.DateOnly) }, nil
+	case GroupModel:
+		return func(r Record) string { return r.Model }, nil
+	}
+	return nil, fmt.Errorf("unknown grouping %q; use user, channel, day, or model", groupBy)
+}
@@ -0,0 +1,84 @@
+package usage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBuildReport_GroupsAndPrices(t *testing.T) {
+	day := time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local)
+	records := []Record{
+		{
+			Time: day, Channel: "telegram", SenderID: "1", Model: "a",
+			PromptTokens: 1000, CompletionTokens: 100, TurnStart: true,
+		},
+		{Time: day.Add(time.Minute), Channel: "telegram", SenderID: "1", Model: "a", PromptTokens: 500},
+		{Time: day.Add(time.Hour), Channel: "discord", SenderID: "2", Model: "b", PromptTokens: 9000, TurnStart: true},
+		{Time: day.AddDate(0, 0, 1), Channel: "discord", SenderID: "2", Model: "b", PromptTokens: 1, TurnStart: true},
+		{Time: day.AddDate(0, 0, -1), Channel: "discord", SenderID: "2", Model: "b", PromptTokens: 7},
+	}
+	pricing := Pricing{"a": {Input: 10, Output: 20}}
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)
+
+	rep, err := BuildReport(records, from, from.AddDate(0, 0, 2), pricing, GroupUser, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Total.Requests != 4 || rep.Total.Messages != 3 || rep.Total.PromptTokens != 10501 {
+		t.Fatalf("total = %+v", rep.Total)
+	}
+	// The priced user ranks first although the other used more tokens.
+	if len(rep.Rows) != 2 || rep.Rows[0].Key != "telegram:1" || rep.Rows[0].Cost != 0.017 {
+		t.Fatalf("rows = %+v", rep.Rows)
+	}
+
+	rep, _ = BuildReport(records, from, from.AddDate(0, 0, 2), pricing, GroupDay, func(r Record) bool {
+		return r.Channel == "discord"
+	})
+	if len(rep.Rows) != 2 || rep.Rows[0].Key != "2026-03-02" || rep.Rows[1].PromptTokens != 1 {
+		t.Fatalf("days = %+v", rep.Rows)
+	}
+
+	if _, err := BuildReport(records, from, from, pricing, "week", nil); err == nil {
+		t.Error("unknown grouping accepted")
+	}
+}
+
+func TestHandler_ServesReports(t *testing.T) {
+	tr := NewTracker(Path(t.TempDir()))
+	now := time.Now()
+	tr.Record(Record{Time: now, Channel: "telegram", SenderID: "1", Model: "a", PromptTokens: 3})
+	tr.Record(Record{Time: now, Channel: "discord", SenderID: "2", Model: "a", PromptTokens: 5})
+	h := NewHandler([]string{"secret"}, tr, func() Pricing { return nil })
+
+	get := func(token, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, HTTPPath+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token = %d", rec.Code)
+	}
+	if rec := get("secret", "?group_by=week"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad grouping = %d", rec.Code)
+	}
+	rec := get("secret", "?group_by=channel&channel=telegram")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var rep Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Total.PromptTokens != 3 || len(rep.Rows) != 1 || rep.Rows[0].Key != "telegram" {
+		t.Errorf("report = %+v", rep)
+	}
+}
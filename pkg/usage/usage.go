@@ -0,0 +1,236 @@
+// Package usage records per-request LLM usage so operators can compare
+// models, rollout variants, and costs over time.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+)
+
+// VariantStable is the variant recorded for traffic outside any rollout.
+const VariantStable = "stable"
+
+// Record is one LLM call.
+type Record struct {
+	Time             time.Time `json:"time"`
+	AgentID          string    `json:"agent_id,omitempty"`
+	Channel          string    `json:"channel,omitempty"`
+	ChatID           string    `json:"chat_id,omitempty"`
+	SenderID         string    `json:"sender_id,omitempty"`
+	Model            string    `json:"model"`
+	Variant          string    `json:"variant,omitempty"`
+	RequestID        string    `json:"request_id,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	DurationMS       int64     `json:"duration_ms"`
+	Error            bool      `json:"error,omitempty"`
+	// TurnStart marks the first LLM call made for an inbound message, so
+	// messages handled can be counted apart from tool follow-ups and retries.
+	TurnStart bool `json:"turn_start,omitempty"`
+}
+
+// Path returns the usage log location for a workspace.
+func Path(workspace string) string {
+	return filepath.Join(workspace, "state", "usage.jsonl")
+}
+
+// Tracker appends usage records to a JSONL file. It is safe for concurrent use.
+type Tracker struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewTracker creates a tracker writing to path.
+func NewTracker(path string) *Tracker {
+	return &Tracker{path: path}
+}
+
+// Record appends r. A zero Time is set to now.
+func (t *Tracker) Record(r Record) error {
+	if t == nil {
+		return nil
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	if r.Variant == "" {
+		r.Variant = VariantStable
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load returns the records at or after since. A missing file yields no records.
+func (t *Tracker) Load(since time.Time) ([]Record, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f, err := os.Open(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// Skip torn lines from a crash mid-write.
+			continue
+		}
+		if !r.Time.Before(since) {
+			out = append(out, r)
+		}
+	}
+	return out, scanner.Err()
+}
+
+// Anonymize clears the chat and sender of the records senderID caused on
+// channel, keeping their tokens and timing for cost reports. It returns the
+// number of records changed.
+func (t *Tracker) Anonymize(channel, senderID string) (int, error) {
+	if t == nil || senderID == "" {
+		return 0, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return fileutil.RewriteLines(t.path, 0o644, func(line []byte) ([]byte, bool) {
+		var r Record
+		if json.Unmarshal(line, &r) != nil || r.Channel != channel || r.SenderID != senderID {
+			return line, true
+		}
+		r.SenderID, r.ChatID = "", ""
+		out, err := json.Marshal(r)
+		if err != nil {
+			return line, true
+		}
+		return out, true
+	})
+}
+
+// Price is the cost of a model in USD per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Pricing maps a model name to its price.
+type Pricing map[string]Price
+
+// Cost returns the cost of r in USD, or 0 when its model has no price.
+func (p Pricing) Cost(r Record) float64 {
+	price, ok := p[r.Model]
+	if !ok {
+		return 0
+	}
+	return (float64(r.PromptTokens)*price.Input + float64(r.CompletionTokens)*price.Output) / 1e6
+}
+
+// Summary aggregates a set of records.
+type Summary struct {
+	Requests         int
+	Errors           int
+	PromptTokens     int
+	CompletionTokens int
+	totalDurationMS  int64
+}
+
+// Add folds r into s.
+func (s *Summary) Add(r Record) {
+	s.Requests++
+	if r.Error {
+		s.Errors++
+	}
+	s.PromptTokens += r.PromptTokens
+	s.CompletionTokens += r.CompletionTokens
+	s.totalDurationMS += r.DurationMS
+}
+
+// TotalTokens returns prompt plus completion tokens.
+func (s Summary) TotalTokens() int {
+	return s.PromptTokens + s.CompletionTokens
+}
+
+// ErrorRate returns the fraction of failed requests.
+func (s Summary) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Requests)
+}
+
+// AvgLatency returns the mean request duration.
+func (s Summary) AvgLatency() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return time.Duration(s.totalDurationMS/int64(s.Requests)) * time.Millisecond
+}
+
+// GroupBy aggregates records by the key returned for each one.
+func GroupBy(records []Record, key func(Record) string) map[string]*Summary {
+	out := make(map[string]*Summary)
+	for _, r := range records {
+		k := key(r)
+		s, ok := out[k]
+		if !ok {
+			s = &Summary{}
+			out[k] = s
+		}
+		s.Add(r)
+	}
+	return out
+}
+
+// ByVariant groups records by rollout variant and model, so a canary is
+// shown side by side with stable traffic.
+func ByVariant(records []Record) map[string]*Summary {
+	return GroupBy(records, func(r Record) string {
+		v := r.Variant
+		if v == "" {
+			v = VariantStable
+		}
+		return v + " (" + r.Model + ")"
+	})
+}
+
+// SortedKeys returns the keys of a grouped summary in order.
+func SortedKeys(m map[string]*Summary) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,85 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTracker_RecordAndLoad(t *testing.T) {
+	tr := NewTracker(Path(t.TempDir()))
+	old := time.Now().Add(-48 * time.Hour)
+
+	if err := tr.Record(Record{Time: old, Model: "a", PromptTokens: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Record(Record{Model: "b", Variant: "canary", PromptTokens: 10, CompletionTokens: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := tr.Load(time.Time{})
+	if err != nil || len(all) != 2 {
+		t.Fatalf("Load all = %d records, err=%v", len(all), err)
+	}
+	if all[0].Variant != VariantStable {
+		t.Errorf("empty variant should be recorded as stable, got %q", all[0].Variant)
+	}
+
+	recent, _ := tr.Load(time.Now().Add(-time.Hour))
+	if len(recent) != 1 || recent[0].Model != "b" {
+		t.Errorf("Load since = %+v", recent)
+	}
+}
+
+func TestTracker_LoadMissingAndTornLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	tr := NewTracker(path)
+	if recs, err := tr.Load(time.Time{}); err != nil || recs != nil {
+		t.Fatalf("missing file: %v, %v", recs, err)
+	}
+
+	os.WriteFile(path, []byte("{\"model\":\"a\",\"time\":\"2026-01-01T00:00:00Z\"}\n{\"model\":"), 0o644)
+	recs, err := tr.Load(time.Time{})
+	if err != nil || len(recs) != 1 {
+		t.Errorf("torn line should be skipped, got %d records, err=%v", len(recs), err)
+	}
+}
+
+func TestByVariant(t *testing.T) {
+	groups := ByVariant([]Record{
+		{Model: "m1", Variant: "stable", PromptTokens: 10, DurationMS: 100},
+		{Model: "m1", PromptTokens: 20, DurationMS: 300, Error: true},
+		{Model: "m2", Variant: "canary", CompletionTokens: 7, DurationMS: 50},
+	})
+
+	stable := groups["stable (m1)"]
+	if stable == nil || stable.Requests != 2 || stable.TotalTokens() != 30 {
+		t.Fatalf("stable summary = %+v", stable)
+	}
+	if stable.ErrorRate() != 0.5 || stable.AvgLatency() != 200*time.Millisecond {
+		t.Errorf("stable error rate %.2f, latency %s", stable.ErrorRate(), stable.AvgLatency())
+	}
+	if keys := SortedKeys(groups); len(keys) != 2 || keys[0] != "canary (m2)" {
+		t.Errorf("SortedKeys = %v", keys)
+	}
+}
+
+func TestTracker_Anonymize(t *testing.T) {
+	tr := NewTracker(Path(t.TempDir()))
+	tr.Record(Record{Channel: "telegram", ChatID: "42", SenderID: "7", Model: "a", PromptTokens: 3})
+	tr.Record(Record{Channel: "telegram", ChatID: "42", SenderID: "8", Model: "a"})
+	tr.Record(Record{Channel: "discord", ChatID: "1", SenderID: "7", Model: "a"})
+
+	n, err := tr.Anonymize("telegram", "7")
+	if err != nil || n != 1 {
+		t.Fatalf("Anonymize = %d, %v", n, err)
+	}
+	all, _ := tr.Load(time.Time{})
+	if len(all) != 3 || all[0].SenderID != "" || all[0].ChatID != "" || all[0].PromptTokens != 3 {
+		t.Errorf("anonymized record = %+v", all[0])
+	}
+	if all[1].SenderID != "8" || all[2].SenderID != "7" {
+		t.Errorf("other records changed: %+v", all[1:])
+	}
+}
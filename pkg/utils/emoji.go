@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// emojiShortcodes lists the shortcodes we translate, in preference order: when
+// several shortcodes map to the same emoji, the first one is used for the
+// reverse (emoji → shortcode) direction.
+var emojiShortcodes = []struct {
+	code  string
+	emoji string
+}{
+	{"smile", "😄"},
+	{"smiley", "😃"},
+	{"grinning", "😀"},
+	{"grin", "😁"},
+	{"joy", "😂"},
+	{"rofl", "🤣"},
+	{"slightly_smiling_face", "🙂"},
+	{"wink", "😉"},
+	{"blush", "😊"},
+	{"heart_eyes", "😍"},
+	{"kissing_heart", "😘"},
+	{"yum", "😋"},
+	{"stuck_out_tongue", "😛"},
+	{"sunglasses", "😎"},
+	{"thinking", "🤔"},
+	{"thinking_face", "🤔"},
+	{"neutral_face", "😐"},
+	{"expressionless", "😑"},
+	{"unamused", "😒"},
+	{"roll_eyes", "🙄"},
+	{"smirk", "😏"},
+	{"relieved", "😌"},
+	{"pensive", "😔"},
+	{"sleeping", "😴"},
+	{"confused", "😕"},
+	{"worried", "😟"},
+	{"cry", "😢"},
+	{"sob", "😭"},
+	{"angry", "😠"},
+	{"rage", "😡"},
+	{"scream", "😱"},
+	{"flushed", "😳"},
+	{"sweat_smile", "😅"},
+	{"innocent", "😇"},
+	{"nerd_face", "🤓"},
+	{"partying_face", "🥳"},
+	{"exploding_head", "🤯"},
+	{"shrug", "🤷"},
+	{"facepalm", "🤦"},
+	{"thumbsup", "👍"},
+	{"+1", "👍"},
+	{"thumbsdown", "👎"},
+	{"-1", "👎"},
+	{"ok_hand", "👌"},
+	{"wave", "👋"},
+	{"clap", "👏"},
+	{"pray", "🙏"},
+	{"raised_hands", "🙌"},
+	{"muscle", "💪"},
+	{"point_up", "☝️"},
+	{"point_right", "👉"},
+	{"point_left", "👈"},
+	{"eyes", "👀"},
+	{"brain", "🧠"},
+	{"heart", "❤️"},
+	{"broken_heart", "💔"},
+	{"sparkling_heart", "💖"},
+	{"fire", "🔥"},
+	{"sparkles", "✨"},
+	{"star", "⭐"},
+	{"tada", "🎉"},
+	{"rocket", "🚀"},
+	{"zap", "⚡"},
+	{"boom", "💥"},
+	{"100", "💯"},
+	{"white_check_mark", "✅"},
+	{"heavy_check_mark", "✔️"},
+	{"x", "❌"},
+	{"warning", "⚠️"},
+	{"no_entry", "⛔"},
+	{"question", "❓"},
+	{"exclamation", "❗"},
+	{"bulb", "💡"},
+	{"memo", "📝"},
+	{"pencil", "📝"},
+	{"book", "📖"},
+	{"books", "📚"},
+	{"clipboard", "📋"},
+	{"pushpin", "📌"},
+	{"link", "🔗"},
+	{"lock", "🔒"},
+	{"key", "🔑"},
+	{"bell", "🔔"},
+	{"mag", "🔍"},
+	{"gear", "⚙️"},
+	{"wrench", "🔧"},
+	{"hammer", "🔨"},
+	{"package", "📦"},
+	{"computer", "💻"},
+	{"bug", "🐛"},
+	{"robot", "🤖"},
+	{"lobster", "🦞"},
+	{"hourglass", "⌛"},
+	{"stopwatch", "⏱️"},
+	{"calendar", "📅"},
+	{"chart_with_upwards_trend", "📈"},
+	{"chart_with_downwards_trend", "📉"},
+	{"wastebasket", "🗑️"},
+	{"repeat", "🔁"},
+	{"arrows_counterclockwise", "🔄"},
+	{"coffee", "☕"},
+	{"pizza", "🍕"},
+	{"beers", "🍻"},
+	{"sun", "☀️"},
+	{"cloud", "☁️"},
+	{"rainbow", "🌈"},
+	{"snowflake", "❄️"},
+	{"earth_americas", "🌎"},
+	{"globe_with_meridians", "🌐"},
+	{"dog", "🐶"},
+	{"cat", "🐱"},
+	{"see_no_evil", "🙈"},
+	{"skull", "💀"},
+	{"ghost", "👻"},
+	{"poop", "💩"},
+}
+
+var (
+	shortcodeToEmoji map[string]string
+	emojiToShortcode *strings.Replacer
+
+	// shortcodeRe matches :name: tokens. Names follow the common Slack/GitHub
+	// charset so that times like 10:30:00 are never mistaken for shortcodes.
+	shortcodeRe = regexp.MustCompile(`:([a-z0-9_+\-]+):`)
+)
+
+func init() {
+	shortcodeToEmoji = make(map[string]string, len(emojiShortcodes))
+	seen := make(map[string]bool, len(emojiShortcodes))
+	var pairs [][2]string
+	for _, e := range emojiShortcodes {
+		shortcodeToEmoji[e.code] = e.emoji
+		if !seen[e.emoji] {
+			seen[e.emoji] = true
+			pairs = append(pairs, [2]string{e.emoji, ":" + e.code + ":"})
+			// Accept the bare form too when the canonical one carries a
+			// variation selector, since clients send either.
+			if bare := strings.TrimSuffix(e.emoji, "\ufe0f"); bare != e.emoji && !seen[bare] {
+				seen[bare] = true
+				pairs = append(pairs, [2]string{bare, ":" + e.code + ":"})
+			}
+		}
+	}
+	emojiToShortcode = strings.NewReplacer(flattenLongestFirst(pairs)...)
+}
+
+// ShortcodesToEmoji replaces :shortcode: tokens with their Unicode emoji.
+// Entries in custom take precedence and may map a shortcode to any
+// platform-specific syntax (for example Discord's <:name:id>). Unknown
+// shortcodes and anything inside code spans or fenced code blocks are kept.
+func ShortcodesToEmoji(text string, custom map[string]string) string {
+	if !strings.Contains(text, ":") {
+		return text
+	}
+	return mapOutsideCode(text, func(s string) string {
+		return shortcodeRe.ReplaceAllStringFunc(s, func(tok string) string {
+			name := tok[1 : len(tok)-1]
+			if v, ok := custom[name]; ok {
+				return v
+			}
+			if v, ok := shortcodeToEmoji[name]; ok {
+				return v
+			}
+			return tok
+		})
+	})
+}
+
+// EmojiToShortcodes is the inverse of ShortcodesToEmoji: known Unicode emoji
+// and the platform syntax values from custom are rewritten to :shortcode:
+// form. Code spans and fenced code blocks are kept as-is.
+func EmojiToShortcodes(text string, custom map[string]string) string {
+	return mapOutsideCode(text, func(s string) string {
+		if len(custom) > 0 {
+			pairs := make([][2]string, 0, len(custom))
+			for name, v := range custom {
+				if v != "" {
+					pairs = append(pairs, [2]string{v, ":" + name + ":"})
+				}
+			}
+			s = strings.NewReplacer(flattenLongestFirst(pairs)...).Replace(s)
+		}
+		return emojiToShortcode.Replace(s)
+	})
+}
+
+// flattenLongestFirst turns (old, new) pairs into strings.NewReplacer arguments,
+// ordering longer patterns first so multi-rune sequences win over prefixes.
+func flattenLongestFirst(pairs [][2]string) []string {
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if len(pairs[i][0]) != len(pairs[j][0]) {
+			return len(pairs[i][0]) > len(pairs[j][0])
+		}
+		return pairs[i][0] < pairs[j][0]
+	})
+	out := make([]string, 0, len(pairs)*2)
+	for _, p := range pairs {
+		out = append(out, p[0], p[1])
+	}
+	return out
+}
+
+// mapOutsideCode applies fn to every part of text that is not inside a fenced
+// code block or an inline code span.
+func mapOutsideCode(text string, fn func(string) string) string {
+	lines := strings.Split(text, "\n")
+	fenced := FencedLines(lines)
+	var sb strings.Builder
+	sb.Grow(len(text))
+
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() == 0 {
+			return
+		}
+		parts := strings.Split(plain.String(), "`")
+		for i := 0; i < len(parts); i += 2 {
+			parts[i] = fn(parts[i])
+		}
+		sb.WriteString(strings.Join(parts, "`"))
+		plain.Reset()
+	}
+
+	for i, line := range lines {
+		if i < len(lines)-1 {
+			line += "\n"
+		}
+		if fenced[i] != NotFenced {
+			flush()
+			sb.WriteString(line)
+			continue
+		}
+		plain.WriteString(line)
+	}
+	flush()
+	return sb.String()
+}
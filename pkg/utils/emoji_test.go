@@ -0,0 +1,65 @@
+package utils
+
+import "testing"
+
+func TestShortcodesToEmoji(t *testing.T) {
+	custom := map[string]string{"party": "<:party:123>", "fire": "<:fire_custom:9>"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain text", "hello world", "hello world"},
+		{"known shortcode", "nice :thumbsup:", "nice 👍"},
+		{"multiple shortcodes", ":tada: done :rocket:", "🎉 done 🚀"},
+		{"unknown shortcode kept", "see :not_an_emoji:", "see :not_an_emoji:"},
+		{"custom emoji", "let's :party:", "let's <:party:123>"},
+		{"custom overrides builtin", "this is :fire:", "this is <:fire_custom:9>"},
+		{"time untouched", "meet at 10:30:00", "meet at 10:30:00"},
+		{"inline code untouched", "use `:smile:` or :smile:", "use `:smile:` or 😄"},
+		{"fenced code untouched", "```\n:smile:\n```\n:smile:", "```\n:smile:\n```\n😄"},
+		{"tilde fence untouched", "~~~\n:smile:\n```\n:smile:\n~~~\n:smile:", "~~~\n:smile:\n```\n:smile:\n~~~\n😄"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ShortcodesToEmoji(tc.input, custom); got != tc.want {
+				t.Errorf("ShortcodesToEmoji(%q) = %q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEmojiToShortcodes(t *testing.T) {
+	custom := map[string]string{"party": "<:party:123>"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain text", "hello", "hello"},
+		{"unicode emoji", "nice 👍", "nice :thumbsup:"},
+		{"preferred name for duplicates", "hmm 🤔", "hmm :thinking:"},
+		{"variation selector", "love ❤️", "love :heart:"},
+		{"bare form without selector", "love ❤", "love :heart:"},
+		{"custom emoji", "let's <:party:123>", "let's :party:"},
+		{"inline code untouched", "`👍` 👍", "`👍` :thumbsup:"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := EmojiToShortcodes(tc.input, custom); got != tc.want {
+				t.Errorf("EmojiToShortcodes(%q) = %q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEmojiRoundTrip(t *testing.T) {
+	input := "Shipped :rocket: :tada: with :heart:"
+	if got := EmojiToShortcodes(ShortcodesToEmoji(input, nil), nil); got != input {
+		t.Errorf("round trip = %q, want %q", got, input)
+	}
+}
@@ -0,0 +1,146 @@
+package utils
+
+import "strings"
+
+// FenceKind is the place of a Markdown line relative to fenced code blocks.
+type FenceKind int
+
+const (
+	// NotFenced is a line outside any fenced code block.
+	NotFenced FenceKind = iota
+	// FenceOpen is the line that opens a fenced code block.
+	FenceOpen
+	// FenceClose is the line that closes a fenced code block.
+	FenceClose
+	// FencedCode is a line inside a fenced code block.
+	FencedCode
+)
+
+// FencedLines classifies each of lines, the lines of one Markdown text, by
+// its place in fenced code blocks. A block that is never closed runs to the
+// end of the text, as in CommonMark.
+func FencedLines(lines []string) []FenceKind {
+	runes := []rune(strings.Join(lines, "\n"))
+	kinds := make([]FenceKind, len(lines))
+	open := ""
+	start := 0
+	for i, line := range lines {
+		end := start + len([]rune(line))
+		at := start
+		for at < end && runes[at] == ' ' {
+			at++
+		}
+		n := 0
+		if at < end {
+			n = FenceRunAt(runes, at, end)
+		}
+		switch {
+		case open == "" && n > 0:
+			kinds[i], open = FenceOpen, string(runes[at:at+n])
+		case open == "":
+			kinds[i] = NotFenced
+		case n > 0 && ClosesFence(runes, at, n, open):
+			kinds[i], open = FenceClose, ""
+		default:
+			kinds[i] = FencedCode
+		}
+		start = end + 1
+	}
+	return kinds
+}
+
+// FenceInfo returns the info string of an opening fence line, such as "go"
+// for "```go", or "" when it has none.
+func FenceInfo(line string) string {
+	return strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "`~"))
+}
+
+// FenceRunAt returns the length of the code fence starting at runes[i], or
+// 0 when there is none. As in CommonMark, a fence is a run of at least three
+// backticks or tildes that begins a line, indented by at most three spaces
+// beyond the content of the list item it is in, and the info string after
+// a backtick fence holds no backticks, so ```x``` in a line is inline code.
+func FenceRunAt(runes []rune, i, end int) int {
+	c := runes[i]
+	if c != '`' && c != '~' {
+		return 0
+	}
+	lineStart := i
+	for lineStart > 0 && runes[lineStart-1] == ' ' {
+		lineStart--
+	}
+	if lineStart > 0 && runes[lineStart-1] != '\n' {
+		return 0
+	}
+	if indent := i - lineStart; indent > 3 {
+		// More indentation makes an indented code block, unless the line
+		// belongs to a list item.
+		if item := listContentIndent(runes, lineStart); item == 0 || indent < item || indent-item > 3 {
+			return 0
+		}
+	}
+
+	n := 0
+	for i+n < end && runes[i+n] == c {
+		n++
+	}
+	if n < 3 {
+		return 0
+	}
+	if c == '`' && strings.ContainsRune(restOfLine(runes, i+n), '`') {
+		return 0
+	}
+	return n
+}
+
+// ClosesFence reports whether the fence of n runes at runes[i] ends a block
+// opened with open: a fence of the same character that is at least as long,
+// with nothing but spaces after it on its line.
+func ClosesFence(runes []rune, i, n int, open string) bool {
+	return runes[i] == rune(open[0]) && n >= len(open) && strings.TrimSpace(restOfLine(runes, i+n)) == ""
+}
+
+// ListMarkerWidth returns the length of the list marker and the space after
+// it that start trimmed, or 0 when it does not start a list item.
+func ListMarkerWidth(trimmed string) int {
+	if len(trimmed) >= 2 && strings.ContainsRune("-*+", rune(trimmed[0])) && trimmed[1] == ' ' {
+		return 2
+	}
+	digits := len(trimmed) - len(strings.TrimLeft(trimmed, "0123456789"))
+	rest := trimmed[digits:]
+	if digits > 0 && (strings.HasPrefix(rest, ". ") || strings.HasPrefix(rest, ") ")) {
+		return digits + 2
+	}
+	return 0
+}
+
+// restOfLine returns the text from runes[i] to the end of its line.
+func restOfLine(runes []rune, i int) string {
+	end := i
+	for end < len(runes) && runes[end] != '\n' {
+		end++
+	}
+	return string(runes[i:end])
+}
+
+// listContentIndent returns the column where the content of the list item
+// holding the line at lineStart begins, or 0 when the line is not in one.
+func listContentIndent(runes []rune, lineStart int) int {
+	for end := lineStart - 1; end > 0; {
+		from := end
+		for from > 0 && runes[from-1] != '\n' {
+			from--
+		}
+		line := string(runes[from:end])
+		trimmed := strings.TrimLeft(line, " ")
+		switch {
+		case strings.TrimSpace(line) == "":
+		case ListMarkerWidth(trimmed) > 0:
+			return len(line) - len(trimmed) + ListMarkerWidth(trimmed)
+		case len(trimmed) == len(line):
+			return 0
+		}
+		end = from - 1
+	}
+	return 0
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFencedLines(t *testing.T) {
+	const (
+		T = NotFenced
+		O = FenceOpen
+		C = FenceClose
+		F = FencedCode
+	)
+	tests := []struct {
+		name  string
+		input string
+		want  []FenceKind
+	}{
+		{"backticks", "a\n```go\nx\n```\nb", []FenceKind{T, O, F, C, T}},
+		{"tildes", "~~~md\n[x]: y\n~~~", []FenceKind{O, F, C}},
+		{"longer fence holds shorter", "````\n```\n````", []FenceKind{O, F, C}},
+		{"other character does not close", "~~~\n```\n~~~", []FenceKind{O, F, C}},
+		{"info string does not close", "```\n```go\n```", []FenceKind{O, F, C}},
+		{"inline code is no fence", "```x``` y\n:z:", []FenceKind{T, T}},
+		{"indented code is no fence", "    ```\nx", []FenceKind{T, T}},
+		{"fence in list item", "- item\n\n    ```\n    x\n    ```", []FenceKind{T, T, O, F, C}},
+		{"unclosed runs to the end", "```\nx\ny", []FenceKind{O, F, F}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := FencedLines(strings.Split(tc.input, "\n"))
+			if len(got) != len(tc.want) {
+				t.Fatalf("FencedLines(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("FencedLines(%q) = %v, want %v", tc.input, got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFenceInfo(t *testing.T) {
+	for line, want := range map[string]string{"```go": "go", "  ~~~ ansi ": "ansi", "````": ""} {
+		if got := FenceInfo(line); got != want {
+			t.Errorf("FenceInfo(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
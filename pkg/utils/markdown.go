@@ -375,9 +375,13 @@ func HtmlToMarkdown(htmlStr string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return nodeToMarkdown(doc), nil
+}
 
+// nodeToMarkdown converts the subtree of n.
+func nodeToMarkdown(n *html.Node) string {
 	c := newConverter()
-	c.walk(doc)
+	c.walk(n)
 
 	res := c.stack[0].String()
 
@@ -407,5 +411,5 @@ func HtmlToMarkdown(htmlStr string) (string, error) {
 	// by a non-whitespace char, so "    - nested" (4 spaces) is left untouched.
 	res = reLeadingLineSpace.ReplaceAllString(res, "$2")
 
-	return res, nil
+	return res
 }
@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Article is the main content of a web page.
+type Article struct {
+	Title    string
+	Markdown string
+}
+
+var (
+	positiveClass = []string{"article", "body", "content", "entry", "main", "page", "post", "story", "text"}
+	negativeClass = []string{
+		"comment", "meta", "footer", "footnote", "related", "sidebar", "sponsor", "ad-", "promo", "share", "widget",
+	}
+)
+
+// ReadableMarkdown extracts the main content of an HTML page, the way reader
+// views do, and converts it to Markdown. Paragraphs are scored by length and
+// commas, their containers add up the scores of their paragraphs, and the
+// container with the least link-heavy best score wins; without one the whole
+// body is converted. Relative links and images are resolved against pageURL,
+// so the model can cite them.
+func ReadableMarkdown(htmlStr, pageURL string) (Article, error) {
+	doc, err := html.Parse(strings.NewReader(htmlStr))
+	if err != nil {
+		return Article{}, err
+	}
+	root := mainContent(doc)
+	if base, err := url.Parse(pageURL); err == nil && base.IsAbs() {
+		absolutizeLinks(root, base)
+	}
+	return Article{Title: pageTitle(doc), Markdown: nodeToMarkdown(root)}, nil
+}
+
+// pageTitle returns the og:title of the page, or else its <title>.
+func pageTitle(doc *html.Node) string {
+	var title, og string
+	walkElements(doc, func(n *html.Node) bool {
+		switch n.Data {
+		case "meta":
+			if og == "" && getAttr(n, "property") == "og:title" {
+				og = strings.TrimSpace(getAttr(n, "content"))
+			}
+		case "title":
+			if title == "" {
+				title = strings.TrimSpace(reSpaces.ReplaceAllString(textOf(n), " "))
+			}
+		case "body":
+			return false
+		}
+		return true
+	})
+	if og != "" {
+		return og
+	}
+	return title
+}
+
+// mainContent returns the node holding the main content of doc.
+func mainContent(doc *html.Node) *html.Node {
+	scores := make(map[*html.Node]float64)
+	var order []*html.Node
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode || n.Data == "body" || n.Data == "html" {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = classWeight(n)
+			order = append(order, n)
+		}
+		scores[n] += score
+	}
+
+	walkElements(doc, func(n *html.Node) bool {
+		if skipTags[n.Data] || isUnlikelyNode(n) {
+			return false
+		}
+		if n.Data != "p" && n.Data != "pre" && n.Data != "blockquote" && n.Data != "td" {
+			return true
+		}
+		text := strings.TrimSpace(textOf(n))
+		if len(text) < 25 {
+			return false
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		addScore(n.Parent, score)
+		if n.Parent != nil {
+			addScore(n.Parent.Parent, score/2)
+		}
+		return false
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, n := range order {
+		score := scores[n] * (1 - linkDensity(n))
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best != nil {
+		return best
+	}
+	if body := findElement(doc, "body"); body != nil {
+		return body
+	}
+	return doc
+}
+
+// classWeight rates a node by the words in its class and id.
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	switch n.Data {
+	case "article", "main":
+		weight += 10
+	}
+	classID := strings.ToLower(getAttr(n, "class") + " " + getAttr(n, "id"))
+	for _, word := range positiveClass {
+		if strings.Contains(classID, word) {
+			weight += 25
+			break
+		}
+	}
+	for _, word := range negativeClass {
+		if strings.Contains(classID, word) {
+			weight -= 25
+			break
+		}
+	}
+	return weight
+}
+
+// linkDensity is the share of the text of n that is link text.
+func linkDensity(n *html.Node) float64 {
+	total := len(strings.TrimSpace(textOf(n)))
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	walkElements(n, func(c *html.Node) bool {
+		if c.Data == "a" {
+			links += len(strings.TrimSpace(textOf(c)))
+			return false
+		}
+		return true
+	})
+	return float64(links) / float64(total)
+}
+
+// absolutizeLinks resolves the relative link and image URLs under n.
+func absolutizeLinks(n *html.Node, base *url.URL) {
+	walkElements(n, func(c *html.Node) bool {
+		for i, a := range c.Attr {
+			if (c.Data == "a" && a.Key == "href") || (c.Data == "img" && (a.Key == "src" || a.Key == "data-src")) {
+				ref, err := url.Parse(normalizeAttr(a.Val))
+				if err == nil && !ref.IsAbs() && isSafeHref(a.Val) {
+					c.Attr[i].Val = base.ResolveReference(ref).String()
+				}
+			}
+		}
+		return true
+	})
+}
+
+// walkElements calls fn for each element under n, depth first, descending
+// into the children of the elements for which fn returns true.
+func walkElements(n *html.Node, fn func(*html.Node) bool) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && !fn(c) {
+			continue
+		}
+		walkElements(c, fn)
+	}
+}
+
+func findElement(n *html.Node, tag string) *html.Node {
+	var found *html.Node
+	walkElements(n, func(c *html.Node) bool {
+		if found == nil && c.Data == tag {
+			found = c
+		}
+		return found == nil
+	})
+	return found
+}
+
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style") {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadableMarkdown(t *testing.T) {
+	page := `<html><head>
+<title>Site | Ignored</title><meta property="og:title" content="How tides work">
+</head><body>
+<div id="sidebar"><p>Subscribe to our newsletter, follow us, and share this page with your friends.</p></div>
+<div class="links"><a href="/a">Tides, explained in a longer headline link text</a></div>
+<div class="post-content">
+<h2>The Moon</h2>
+<p>Tides are caused by the gravity of the Moon and, to a lesser extent, the Sun, pulling on the oceans.</p>
+<p>Most coasts see two high tides a day, as explained by <a href="refs/newton.html">Newton</a> in 1687.</p>
+<img src="/img/tides.png" alt="Tide chart">
+</div>
+<div class="comments"><p>Great article, thanks, I learned a lot about tides, moons, and oceans today!</p></div>
+</body></html>`
+	article, err := ReadableMarkdown(page, "https://example.com/science/tides")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Title != "How tides work" {
+		t.Errorf("title = %q", article.Title)
+	}
+	for _, want := range []string{
+		"## The Moon",
+		"gravity of the Moon",
+		"[Newton](https://example.com/science/refs/newton.html)",
+		"![Tide chart](https://example.com/img/tides.png)",
+	} {
+		if !strings.Contains(article.Markdown, want) {
+			t.Errorf("markdown missing %q:\n%s", want, article.Markdown)
+		}
+	}
+	for _, unwanted := range []string{"newsletter", "Great article", "headline link"} {
+		if strings.Contains(article.Markdown, unwanted) {
+			t.Errorf("markdown kept %q:\n%s", unwanted, article.Markdown)
+		}
+	}
+}
+
+func TestReadableMarkdown_FallsBackToBody(t *testing.T) {
+	article, err := ReadableMarkdown(`<html><head><title>Short</title></head><body><h1>Hi</h1></body></html>`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Title != "Short" || article.Markdown != "# Hi" {
+		t.Errorf("article = %+v", article)
+	}
+}
@@ -0,0 +1,198 @@
+package voice
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Opus audio is exchanged with voice platforms as raw packets, while speech
+// APIs take and return files. An Ogg container bridges the two without
+// decoding the audio.
+
+const (
+	oggHeaderBOS = 0x02
+	oggHeaderEOS = 0x04
+
+	// opusSampleRate is the rate Opus granule positions and frame sizes are
+	// counted in, whatever the input was.
+	opusSampleRate = 48000
+)
+
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for range 8 {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+func oggCRC(b []byte) uint32 {
+	var crc uint32
+	for _, c := range b {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^c]
+	}
+	return crc
+}
+
+// OpusPacketSamples returns the duration of an Opus packet in 48 kHz
+// samples, read from its TOC byte, or 0 for a malformed packet.
+func OpusPacketSamples(packet []byte) int {
+	if len(packet) == 0 {
+		return 0
+	}
+	config := packet[0] >> 3
+	var frame int
+	switch {
+	case config < 12: // SILK: 10, 20, 40, 60 ms
+		frame = []int{480, 960, 1920, 2880}[config%4]
+	case config < 16: // Hybrid: 10, 20 ms
+		frame = []int{480, 960}[config%2]
+	default: // CELT: 2.5, 5, 10, 20 ms
+		frame = []int{120, 240, 480, 960}[config%4]
+	}
+	switch packet[0] & 0x03 {
+	case 0:
+		return frame
+	case 1, 2:
+		return 2 * frame
+	default:
+		if len(packet) < 2 {
+			return 0
+		}
+		return int(packet[1]&0x3f) * frame
+	}
+}
+
+// OggOpusWriter writes Opus packets as an Ogg Opus file, one packet per
+// page.
+type OggOpusWriter struct {
+	w       io.Writer
+	serial  uint32
+	seq     uint32
+	granule uint64
+	started bool
+}
+
+// NewOggOpusWriter returns a writer for a stream with the given number of
+// channels.
+func NewOggOpusWriter(w io.Writer, channels int) (*OggOpusWriter, error) {
+	ow := &OggOpusWriter{w: w, serial: 0x70636c77}
+
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1 // version
+	head[9] = byte(channels)
+	binary.LittleEndian.PutUint32(head[12:], opusSampleRate)
+	if err := ow.writePage(head, oggHeaderBOS); err != nil {
+		return nil, err
+	}
+
+	vendor := "picoclaw"
+	tags := make([]byte, 8+4+len(vendor)+4)
+	copy(tags, "OpusTags")
+	binary.LittleEndian.PutUint32(tags[8:], uint32(len(vendor)))
+	copy(tags[12:], vendor)
+	if err := ow.writePage(tags, 0); err != nil {
+		return nil, err
+	}
+	ow.started = true
+	return ow, nil
+}
+
+// WritePacket appends one Opus packet.
+func (ow *OggOpusWriter) WritePacket(packet []byte) error {
+	ow.granule += uint64(OpusPacketSamples(packet))
+	return ow.writePage(packet, 0)
+}
+
+// Close ends the stream. It does not close the underlying writer.
+func (ow *OggOpusWriter) Close() error {
+	return ow.writePage(nil, oggHeaderEOS)
+}
+
+func (ow *OggOpusWriter) writePage(packet []byte, headerType byte) error {
+	segments := len(packet)/255 + 1
+	if segments > 255 {
+		return fmt.Errorf("opus packet of %d bytes does not fit in an ogg page", len(packet))
+	}
+
+	page := make([]byte, 27+segments, 27+segments+len(packet))
+	copy(page, "OggS")
+	page[5] = headerType
+	granule := ow.granule
+	if !ow.started {
+		granule = 0
+	}
+	binary.LittleEndian.PutUint64(page[6:], granule)
+	binary.LittleEndian.PutUint32(page[14:], ow.serial)
+	binary.LittleEndian.PutUint32(page[18:], ow.seq)
+	page[26] = byte(segments)
+	for i := range segments - 1 {
+		page[27+i] = 255
+	}
+	page[27+segments-1] = byte(len(packet) % 255)
+	page = append(page, packet...)
+	binary.LittleEndian.PutUint32(page[22:], oggCRC(page))
+
+	ow.seq++
+	_, err := ow.w.Write(page)
+	return err
+}
+
+// ReadOggOpus reads the audio packets of an Ogg Opus stream, skipping the
+// OpusHead and OpusTags headers.
+func ReadOggOpus(r io.Reader) ([][]byte, error) {
+	br := bufio.NewReader(r)
+	var (
+		packets [][]byte
+		partial []byte
+		header  [27]byte
+	)
+	for {
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to read ogg page: %w", err)
+		}
+		if !bytes.Equal(header[:4], []byte("OggS")) {
+			return nil, errors.New("not an ogg stream")
+		}
+		table := make([]byte, header[26])
+		if _, err := io.ReadFull(br, table); err != nil {
+			return nil, fmt.Errorf("failed to read ogg page: %w", err)
+		}
+		for _, size := range table {
+			segment := make([]byte, size)
+			if _, err := io.ReadFull(br, segment); err != nil {
+				return nil, fmt.Errorf("failed to read ogg page: %w", err)
+			}
+			partial = append(partial, segment...)
+			if size < 255 {
+				packets = append(packets, partial)
+				partial = nil
+			}
+		}
+	}
+
+	audio := packets[:0]
+	for _, p := range packets {
+		if bytes.HasPrefix(p, []byte("OpusHead")) || bytes.HasPrefix(p, []byte("OpusTags")) || len(p) == 0 {
+			continue
+		}
+		audio = append(audio, p)
+	}
+	return audio, nil
+}
@@ -0,0 +1,93 @@
+package voice
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestOggCRC(t *testing.T) {
+	// CRC-32/POSIX without its final inversion, as Ogg uses it
+	if got := oggCRC([]byte("123456789")); got != 0x89a1897f {
+		t.Errorf("oggCRC = %#x", got)
+	}
+}
+
+func TestOpusPacketSamples(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet []byte
+		want   int
+	}{
+		{"empty", nil, 0},
+		{"celt 20ms", []byte{0xf8, 0xff, 0xfe}, 960},
+		{"silk 60ms", []byte{3 << 3}, 2880},
+		{"hybrid 10ms two frames", []byte{12<<3 | 1}, 960},
+		{"celt 2.5ms six frames", []byte{16<<3 | 3, 6}, 720},
+	}
+	for _, tc := range tests {
+		if got := OpusPacketSamples(tc.packet); got != tc.want {
+			t.Errorf("%s: OpusPacketSamples = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestOggOpus_RoundTrip(t *testing.T) {
+	packets := [][]byte{
+		{0xfc, 1, 2, 3},
+		bytes.Repeat([]byte{0xfc}, 255), // exactly one lacing segment long
+		bytes.Repeat([]byte{0xfc}, 700),
+	}
+	var buf bytes.Buffer
+	w, err := NewOggOpusWriter(&buf, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range packets {
+		if err := w.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("OggS")) || data[5] != oggHeaderBOS {
+		t.Fatalf("stream does not start with a BOS page: % x", data[:8])
+	}
+	// Every page's checksum covers the page with the checksum zeroed.
+	for off := 0; off < len(data); {
+		segments := int(data[off+26])
+		size := 27 + segments
+		for _, l := range data[off+27 : off+27+segments] {
+			size += int(l)
+		}
+		page := append([]byte(nil), data[off:off+size]...)
+		want := binary.LittleEndian.Uint32(page[22:])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		if got := oggCRC(page); got != want {
+			t.Errorf("page at %d: crc %#x, want %#x", off, got, want)
+		}
+		off += size
+	}
+
+	got, err := ReadOggOpus(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(packets) {
+		t.Fatalf("read %d packets, want %d", len(got), len(packets))
+	}
+	for i := range packets {
+		if !bytes.Equal(got[i], packets[i]) {
+			t.Errorf("packet %d differs", i)
+		}
+	}
+}
+
+func TestReadOggOpus_RejectsOtherData(t *testing.T) {
+	if _, err := ReadOggOpus(bytes.NewReader(bytes.Repeat([]byte("RIFF"), 10))); err == nil {
+		t.Error("expected an error for non-ogg data")
+	}
+}
@@ -0,0 +1,161 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	defaultSpeechModel = "gpt-4o-mini-tts"
+	defaultSpeechVoice = "alloy"
+	// maxSpeechInput is the longest text the speech API accepts.
+	maxSpeechInput = 4096
+	maxSpeechBytes = 25 << 20
+)
+
+// ErrNoOpus means a synthesizer cannot speak in voice channels, which take
+// Opus audio only.
+var ErrNoOpus = errors.New("speech provider cannot produce Opus audio")
+
+// Synthesizer turns text into speech.
+type Synthesizer interface {
+	Name() string
+	// Synthesize returns the spoken text as Opus packets.
+	Synthesize(ctx context.Context, text string) ([][]byte, error)
+	// SynthesizeFile returns the spoken text as an audio file.
+	SynthesizeFile(ctx context.Context, text string) (Audio, error)
+}
+
+// Audio is an audio file. Ext starts with a dot.
+type Audio struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+}
+
+// OpenAISynthesizer uses an OpenAI-compatible /audio/speech endpoint.
+type OpenAISynthesizer struct {
+	apiKey     string
+	apiBase    string
+	model      string
+	voice      string
+	httpClient *http.Client
+}
+
+// NewOpenAISynthesizer creates a synthesizer from cfg. Empty fields fall back
+// to OpenAI's API and defaults.
+func NewOpenAISynthesizer(cfg config.TTSConfig) *OpenAISynthesizer {
+	s := &OpenAISynthesizer{
+		apiKey:     cfg.APIKey,
+		apiBase:    strings.TrimRight(cfg.APIBase, "/"),
+		model:      cfg.Model,
+		voice:      cfg.Voice,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+	if s.apiBase == "" {
+		s.apiBase = defaultWhisperAPIBase
+	}
+	if s.model == "" {
+		s.model = defaultSpeechModel
+	}
+	if s.voice == "" {
+		s.voice = defaultSpeechVoice
+	}
+	return s
+}
+
+func (s *OpenAISynthesizer) Name() string {
+	return "openai"
+}
+
+func (s *OpenAISynthesizer) Synthesize(ctx context.Context, text string) ([][]byte, error) {
+	audio, err := s.SynthesizeFile(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return ReadOggOpus(bytes.NewReader(audio.Data))
+}
+
+func (s *OpenAISynthesizer) SynthesizeFile(ctx context.Context, text string) (Audio, error) {
+	body, err := json.Marshal(map[string]string{
+		"model":           s.model,
+		"voice":           s.voice,
+		"input":           truncateSpeech(text),
+		"response_format": "opus",
+	})
+	if err != nil {
+		return Audio{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiBase+"/audio/speech", bytes.NewReader(body))
+	if err != nil {
+		return Audio{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+	data, err := fetchSpeech(s.httpClient, req)
+	if err != nil {
+		return Audio{}, err
+	}
+	return Audio{Data: data, ContentType: "audio/ogg", Ext: ".ogg"}, nil
+}
+
+// truncateSpeech cuts text to the longest input the speech APIs accept.
+func truncateSpeech(text string) string {
+	if r := []rune(text); len(r) > maxSpeechInput {
+		return string(r[:maxSpeechInput])
+	}
+	return text
+}
+
+// fetchSpeech sends req and returns the audio of a 200 response.
+func fetchSpeech(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(msg))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSpeechBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio: %w", err)
+	}
+	return data, nil
+}
+
+// DetectSynthesizer returns the speech synthesizer configured in
+// voice.tts, falling back to the OpenAI provider, or nil when neither is
+// set up.
+func DetectSynthesizer(cfg *config.Config) Synthesizer {
+	tts := cfg.Voice.TTS
+	switch tts.Provider {
+	case "elevenlabs":
+		if tts.APIKey == "" {
+			return nil
+		}
+		return NewElevenLabsSynthesizer(tts)
+	case "piper":
+		return NewPiperSynthesizer(tts)
+	}
+	if tts.APIKey == "" && tts.APIBase == "" {
+		tts.APIKey = cfg.Providers.OpenAI.APIKey
+		tts.APIBase = cfg.Providers.OpenAI.APIBase
+	}
+	if tts.APIKey == "" && tts.APIBase == "" {
+		return nil
+	}
+	return NewOpenAISynthesizer(tts)
+}
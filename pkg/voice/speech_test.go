@@ -0,0 +1,54 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestOpenAISynthesizer(t *testing.T) {
+	var audio bytes.Buffer
+	w, _ := NewOggOpusWriter(&audio, 1)
+	_ = w.WritePacket([]byte{0xf8, 1})
+	_ = w.WritePacket([]byte{0xf8, 2})
+	_ = w.Close()
+
+	var req map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/speech" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_, _ = rw.Write(audio.Bytes())
+	}))
+	defer srv.Close()
+
+	s := NewOpenAISynthesizer(config.TTSConfig{APIKey: "sk-test", APIBase: srv.URL, Voice: "nova"})
+	packets, err := s.Synthesize(context.Background(), "Hello!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 2 || packets[1][1] != 2 {
+		t.Errorf("packets = %v", packets)
+	}
+	if req["input"] != "Hello!" || req["voice"] != "nova" || req["model"] != defaultSpeechModel ||
+		req["response_format"] != "opus" {
+		t.Errorf("request = %v", req)
+	}
+}
+
+func TestDetectSynthesizer(t *testing.T) {
+	if s := DetectSynthesizer(&config.Config{}); s != nil {
+		t.Errorf("DetectSynthesizer() = %v without any key", s)
+	}
+	cfg := &config.Config{}
+	cfg.Providers.OpenAI.APIKey = "sk-openai"
+	if s := DetectSynthesizer(cfg); s == nil || s.Name() != "openai" {
+		t.Errorf("DetectSynthesizer() = %v, want the openai fallback", s)
+	}
+}
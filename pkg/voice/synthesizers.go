@@ -0,0 +1,142 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	defaultElevenLabsAPIBase = "https://api.elevenlabs.io"
+	defaultElevenLabsModel   = "eleven_multilingual_v2"
+	defaultElevenLabsVoice   = "21m00Tcm4TlvDq8Ty7sV" // Rachel
+	defaultPiperAPIBase      = "http://127.0.0.1:5000"
+)
+
+// ElevenLabsSynthesizer uses the ElevenLabs text-to-speech API. The voice
+// of the config is an ElevenLabs voice ID.
+type ElevenLabsSynthesizer struct {
+	apiKey     string
+	apiBase    string
+	model      string
+	voice      string
+	httpClient *http.Client
+}
+
+// NewElevenLabsSynthesizer creates a synthesizer from cfg. Empty fields fall
+// back to ElevenLabs' API and defaults.
+func NewElevenLabsSynthesizer(cfg config.TTSConfig) *ElevenLabsSynthesizer {
+	s := &ElevenLabsSynthesizer{
+		apiKey:     cfg.APIKey,
+		apiBase:    strings.TrimRight(cfg.APIBase, "/"),
+		model:      cfg.Model,
+		voice:      cfg.Voice,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+	if s.apiBase == "" {
+		s.apiBase = defaultElevenLabsAPIBase
+	}
+	if s.model == "" {
+		s.model = defaultElevenLabsModel
+	}
+	if s.voice == "" {
+		s.voice = defaultElevenLabsVoice
+	}
+	return s
+}
+
+func (s *ElevenLabsSynthesizer) Name() string {
+	return "elevenlabs"
+}
+
+func (s *ElevenLabsSynthesizer) Synthesize(ctx context.Context, text string) ([][]byte, error) {
+	data, err := s.speak(ctx, text, "opus_48000_64")
+	if err != nil {
+		return nil, err
+	}
+	return ReadOggOpus(bytes.NewReader(data))
+}
+
+func (s *ElevenLabsSynthesizer) SynthesizeFile(ctx context.Context, text string) (Audio, error) {
+	data, err := s.speak(ctx, text, "mp3_44100_128")
+	if err != nil {
+		return Audio{}, err
+	}
+	return Audio{Data: data, ContentType: "audio/mpeg", Ext: ".mp3"}, nil
+}
+
+func (s *ElevenLabsSynthesizer) speak(ctx context.Context, text, format string) ([]byte, error) {
+	body, err := json.Marshal(map[string]string{"text": truncateSpeech(text), "model_id": s.model})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/v1/text-to-speech/%s?output_format=%s",
+		s.apiBase, url.PathEscape(s.voice), url.QueryEscape(format))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("xi-api-key", s.apiKey)
+	return fetchSpeech(s.httpClient, req)
+}
+
+// PiperSynthesizer uses a local Piper HTTP server (python -m
+// piper.http_server). Piper makes WAV audio, so it cannot speak in voice
+// channels.
+type PiperSynthesizer struct {
+	apiBase    string
+	voice      string
+	httpClient *http.Client
+}
+
+// NewPiperSynthesizer creates a synthesizer for the Piper server at
+// cfg.APIBase, by default http://127.0.0.1:5000. An empty voice uses the
+// server's default.
+func NewPiperSynthesizer(cfg config.TTSConfig) *PiperSynthesizer {
+	s := &PiperSynthesizer{
+		apiBase:    strings.TrimRight(cfg.APIBase, "/"),
+		voice:      cfg.Voice,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+	if s.apiBase == "" {
+		s.apiBase = defaultPiperAPIBase
+	}
+	return s
+}
+
+func (s *PiperSynthesizer) Name() string {
+	return "piper"
+}
+
+func (s *PiperSynthesizer) Synthesize(context.Context, string) ([][]byte, error) {
+	return nil, ErrNoOpus
+}
+
+func (s *PiperSynthesizer) SynthesizeFile(ctx context.Context, text string) (Audio, error) {
+	fields := map[string]string{"text": truncateSpeech(text)}
+	if s.voice != "" {
+		fields["voice"] = s.voice
+	}
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return Audio{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiBase+"/", bytes.NewReader(body))
+	if err != nil {
+		return Audio{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	data, err := fetchSpeech(s.httpClient, req)
+	if err != nil {
+		return Audio{}, err
+	}
+	return Audio{Data: data, ContentType: "audio/wav", Ext: ".wav"}, nil
+}
@@ -0,0 +1,93 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestElevenLabsSynthesizer(t *testing.T) {
+	var ogg bytes.Buffer
+	w, _ := NewOggOpusWriter(&ogg, 1)
+	_ = w.WritePacket([]byte{0xf8, 1})
+	_ = w.Close()
+
+	var paths, formats []string
+	var req map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("xi-api-key") != "xi-test" {
+			t.Errorf("xi-api-key = %q", r.Header.Get("xi-api-key"))
+		}
+		paths = append(paths, r.URL.Path)
+		formats = append(formats, r.URL.Query().Get("output_format"))
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Query().Get("output_format") == "mp3_44100_128" {
+			_, _ = rw.Write([]byte("ID3mp3"))
+			return
+		}
+		_, _ = rw.Write(ogg.Bytes())
+	}))
+	defer srv.Close()
+
+	s := NewElevenLabsSynthesizer(config.TTSConfig{APIKey: "xi-test", APIBase: srv.URL, Voice: "voice-1"})
+	audio, err := s.SynthesizeFile(context.Background(), "Hello!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(audio.Data) != "ID3mp3" || audio.ContentType != "audio/mpeg" || audio.Ext != ".mp3" {
+		t.Errorf("audio = %+v", audio)
+	}
+	if req["text"] != "Hello!" || req["model_id"] != defaultElevenLabsModel {
+		t.Errorf("request = %v", req)
+	}
+	packets, err := s.Synthesize(context.Background(), "Hello!")
+	if err != nil || len(packets) != 1 {
+		t.Errorf("Synthesize = %v, %v", packets, err)
+	}
+	if paths[0] != "/v1/text-to-speech/voice-1" || formats[1] != "opus_48000_64" {
+		t.Errorf("paths = %v, formats = %v", paths, formats)
+	}
+}
+
+func TestPiperSynthesizer(t *testing.T) {
+	var req map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_, _ = rw.Write([]byte("RIFFwav"))
+	}))
+	defer srv.Close()
+
+	s := NewPiperSynthesizer(config.TTSConfig{APIBase: srv.URL, Voice: "en_US-lessac-medium"})
+	audio, err := s.SynthesizeFile(context.Background(), "Hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(audio.Data) != "RIFFwav" || audio.Ext != ".wav" || req["voice"] != "en_US-lessac-medium" {
+		t.Errorf("audio = %+v, request = %v", audio, req)
+	}
+	if _, err := s.Synthesize(context.Background(), "Hi"); !errors.Is(err, ErrNoOpus) {
+		t.Errorf("Synthesize err = %v, want ErrNoOpus", err)
+	}
+}
+
+func TestDetectSynthesizer_Providers(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Voice.TTS.Provider = "elevenlabs"
+	if s := DetectSynthesizer(cfg); s != nil {
+		t.Errorf("DetectSynthesizer() = %v for elevenlabs without a key", s)
+	}
+	cfg.Voice.TTS.APIKey = "xi"
+	if s := DetectSynthesizer(cfg); s == nil || s.Name() != "elevenlabs" {
+		t.Errorf("DetectSynthesizer() = %v, want elevenlabs", s)
+	}
+	cfg.Voice.TTS = config.TTSConfig{Provider: "piper"}
+	if s := DetectSynthesizer(cfg); s == nil || s.Name() != "piper" {
+		t.Errorf("DetectSynthesizer() = %v, want piper", s)
+	}
+}
@@ -166,6 +166,24 @@ func (t *GroqTranscriber) Name() string {
 // DetectTranscriber inspects cfg and returns the appropriate Transcriber, or
 // nil if no supported transcription provider is configured.
 func DetectTranscriber(cfg *config.Config) Transcriber {
+	// An explicitly chosen provider wins.
+	stt := cfg.Voice.STT
+	switch stt.Provider {
+	case "whisper":
+		key := stt.APIKey
+		if key == "" && stt.APIBase == "" {
+			key = cfg.Providers.OpenAI.APIKey
+		}
+		if key != "" || stt.APIBase != "" {
+			return NewWhisperTranscriber(key, stt.APIBase, stt.Model)
+		}
+	case "whisper_cpp":
+		return NewWhisperCppTranscriber(stt.APIBase)
+	case "groq":
+		if stt.APIKey != "" {
+			return NewGroqTranscriber(stt.APIKey)
+		}
+	}
 	// Direct Groq provider config takes priority.
 	if key := cfg.Providers.Groq.APIKey; key != "" {
 		return NewGroqTranscriber(key)
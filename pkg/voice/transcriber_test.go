@@ -74,6 +74,33 @@ func TestDetectTranscriber(t *testing.T) {
 			},
 			wantName: "groq",
 		},
+		{
+			name: "whisper provider falls back to the openai key",
+			cfg: &config.Config{
+				Voice: config.VoiceConfig{STT: config.STTConfig{Provider: "whisper"}},
+				Providers: config.ProvidersConfig{
+					OpenAI: config.OpenAIProviderConfig{ProviderConfig: config.ProviderConfig{APIKey: "sk-openai"}},
+				},
+			},
+			wantName: "whisper",
+		},
+		{
+			name: "whisper provider without any key",
+			cfg: &config.Config{
+				Voice: config.VoiceConfig{STT: config.STTConfig{Provider: "whisper"}},
+			},
+			wantNil: true,
+		},
+		{
+			name: "chosen provider takes priority over groq",
+			cfg: &config.Config{
+				Voice: config.VoiceConfig{STT: config.STTConfig{Provider: "whisper_cpp"}},
+				Providers: config.ProvidersConfig{
+					Groq: config.ProviderConfig{APIKey: "sk-groq-direct"},
+				},
+			},
+			wantName: "whisper_cpp",
+		},
 	}
 
 	for _, tc := range tests {
@@ -0,0 +1,159 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	defaultWhisperAPIBase    = "https://api.openai.com/v1"
+	defaultWhisperModel      = "whisper-1"
+	defaultWhisperCppAPIBase = "http://127.0.0.1:8080"
+)
+
+// WhisperTranscriber uses an OpenAI-compatible /audio/transcriptions
+// endpoint, such as OpenAI's Whisper API.
+type WhisperTranscriber struct {
+	apiKey     string
+	apiBase    string
+	model      string
+	httpClient *http.Client
+}
+
+// NewWhisperTranscriber creates a transcriber for the API at apiBase. Empty
+// arguments fall back to OpenAI and whisper-1.
+func NewWhisperTranscriber(apiKey, apiBase, model string) *WhisperTranscriber {
+	if apiBase == "" {
+		apiBase = defaultWhisperAPIBase
+	}
+	if model == "" {
+		model = defaultWhisperModel
+	}
+	return &WhisperTranscriber{
+		apiKey:     apiKey,
+		apiBase:    strings.TrimRight(apiBase, "/"),
+		model:      model,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (t *WhisperTranscriber) Name() string {
+	return "whisper"
+}
+
+func (t *WhisperTranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
+	return postAudio(ctx, t.httpClient, t.apiBase+"/audio/transcriptions", t.apiKey, audioFilePath, map[string]string{
+		"model":           t.model,
+		"response_format": "json",
+	})
+}
+
+// WhisperCppTranscriber uses a local whisper.cpp server (whisper-server).
+// The server must run with --convert to accept formats other than WAV.
+type WhisperCppTranscriber struct {
+	apiBase    string
+	httpClient *http.Client
+}
+
+// NewWhisperCppTranscriber creates a transcriber for the whisper.cpp server
+// at apiBase, by default http://127.0.0.1:8080.
+func NewWhisperCppTranscriber(apiBase string) *WhisperCppTranscriber {
+	if apiBase == "" {
+		apiBase = defaultWhisperCppAPIBase
+	}
+	return &WhisperCppTranscriber{
+		apiBase: strings.TrimRight(apiBase, "/"),
+		// Local models on small machines can take a while
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func (t *WhisperCppTranscriber) Name() string {
+	return "whisper_cpp"
+}
+
+func (t *WhisperCppTranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
+	return postAudio(ctx, t.httpClient, t.apiBase+"/inference", "", audioFilePath, map[string]string{
+		"response_format": "json",
+	})
+}
+
+// postAudio uploads an audio file with form fields and decodes the
+// transcription in the JSON response.
+func postAudio(
+	ctx context.Context,
+	client *http.Client,
+	url, apiKey, audioFilePath string,
+	fields map[string]string,
+) (*TranscriptionResponse, error) {
+	audioFile, err := os.Open(audioFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer audioFile.Close()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filepath.Base(audioFilePath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, audioFile); err != nil {
+		return nil, fmt.Errorf("failed to copy file content: %w", err)
+	}
+	for k, v := range fields {
+		if err := writer.WriteField(k, v); err != nil {
+			return nil, fmt.Errorf("failed to write %s field: %w", k, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var result TranscriptionResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	result.Text = strings.TrimSpace(result.Text)
+
+	logger.InfoCF("voice", "Transcription completed successfully", map[string]any{
+		"text_length":           len(result.Text),
+		"transcription_preview": utils.Truncate(result.Text, 50),
+	})
+	return &result, nil
+}
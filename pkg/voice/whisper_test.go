@@ -0,0 +1,53 @@
+package voice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	_ Transcriber = (*WhisperTranscriber)(nil)
+	_ Transcriber = (*WhisperCppTranscriber)(nil)
+)
+
+func TestWhisperTranscribers(t *testing.T) {
+	audioPath := filepath.Join(t.TempDir(), "voice.ogg")
+	if err := os.WriteFile(audioPath, []byte("fake-audio-data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct{ path, auth, model, format string }
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.path = r.URL.Path
+		got.auth = r.Header.Get("Authorization")
+		got.model = r.FormValue("model")
+		got.format = r.FormValue("response_format")
+		if _, _, err := r.FormFile("file"); err != nil {
+			t.Errorf("request has no file: %v", err)
+		}
+		_ = json.NewEncoder(w).Encode(TranscriptionResponse{Text: " hello there\n"})
+	}))
+	defer srv.Close()
+
+	resp, err := NewWhisperTranscriber("sk-test", srv.URL+"/", "").Transcribe(context.Background(), audioPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "hello there" || got.path != "/audio/transcriptions" || got.auth != "Bearer sk-test" ||
+		got.model != defaultWhisperModel || got.format != "json" {
+		t.Errorf("whisper: text %q, request %+v", resp.Text, got)
+	}
+
+	resp, err = NewWhisperCppTranscriber(srv.URL).Transcribe(context.Background(), audioPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "hello there" || got.path != "/inference" || got.auth != "" {
+		t.Errorf("whisper.cpp: text %q, request %+v", resp.Text, got)
+	}
+}